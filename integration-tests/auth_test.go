@@ -7,9 +7,11 @@ import (
 	"github.com/datastax/go-cassandra-native-protocol/frame"
 	"github.com/datastax/go-cassandra-native-protocol/message"
 	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
 	"github.com/datastax/zdm-proxy/integration-tests/setup"
 	"github.com/datastax/zdm-proxy/proxy/pkg/config"
 	"github.com/datastax/zdm-proxy/proxy/pkg/health"
+	"github.com/datastax/zdm-proxy/proxy/pkg/zdmproxy"
 	"github.com/stretchr/testify/require"
 	"strings"
 	"sync"
@@ -499,7 +501,7 @@ func TestAuth(t *testing.T) {
 
 	originAddress := "127.0.1.1"
 	targetAddress := "127.0.1.2"
-	version := primitive.ProtocolVersion4
+	version := env.ProtocolVersion
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -536,7 +538,7 @@ func TestAuth(t *testing.T) {
 					client.NewDriverConnectionInitializationHandler("target", "dc2", func(_ string) {}),
 				}
 
-				err = testSetup.Start(nil, false, primitive.ProtocolVersion4)
+				err = testSetup.Start(nil, false, env.ProtocolVersion)
 				require.Nil(t, err)
 
 				proxy, err := setup.NewProxyInstanceWithConfig(proxyConf)
@@ -566,7 +568,7 @@ func TestAuth(t *testing.T) {
 				require.Nil(t, err, "client connection failed: %v", err)
 				defer cqlConn.Close()
 
-				err = cqlConn.InitiateHandshake(primitive.ProtocolVersion4, 0)
+				err = cqlConn.InitiateHandshake(env.ProtocolVersion, 0)
 
 				originRequestsByConn := originRequestHandler.GetRequests()
 				targetRequestsByConn := targetRequestHandler.GetRequests()
@@ -1030,13 +1032,47 @@ func TestProxyStartupAndHealthCheckWithAuth(t *testing.T) {
 				require.Equal(t, health.UP, r.Status)
 				require.Equal(t, health.UP, r.OriginStatus.Status)
 				require.Equal(t, 0, r.OriginStatus.CurrentFailureCount)
+				require.Equal(t, zdmproxy.ClusterHealthUp, r.OriginStatus.HealthState)
 				require.Equal(t, health.UP, r.TargetStatus.Status)
 				require.Equal(t, 0, r.TargetStatus.CurrentFailureCount)
+				require.Equal(t, zdmproxy.ClusterHealthUp, r.TargetStatus.HealthState)
 			}
 		})
 	}
 }
 
+func TestProxyStartupWithAstraToken(t *testing.T) {
+	originAddress := "127.0.1.1"
+	targetAddress := "127.0.1.2"
+	astraToken := "AstraCS:clientId:secret"
+
+	serverConf := setup.NewTestConfig(originAddress, targetAddress)
+	serverConf.TargetUsername = "token"
+	serverConf.TargetPassword = astraToken
+	testSetup, err := setup.NewCqlServerTestSetup(t, serverConf, true, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+
+	proxyConf := setup.NewTestConfig(originAddress, targetAddress)
+	proxyConf.HeartbeatIntervalMs = 200
+	proxyConf.HeartbeatRetryIntervalMaxMs = 500
+	proxyConf.HeartbeatRetryIntervalMinMs = 200
+	proxyConf.TargetUsername = ""
+	proxyConf.TargetPassword = ""
+	proxyConf.TargetAstraToken = astraToken
+	proxy, err := setup.NewProxyInstanceWithConfig(proxyConf)
+	if proxy != nil {
+		defer proxy.Shutdown()
+	}
+	require.Nil(t, err)
+
+	time.Sleep(time.Duration(proxyConf.HeartbeatIntervalMs) * time.Millisecond * 5)
+	r := health.PerformHealthCheck(proxy)
+	require.Equal(t, health.UP, r.Status)
+	require.Equal(t, health.UP, r.TargetStatus.Status)
+	require.Equal(t, 0, r.TargetStatus.CurrentFailureCount)
+}
+
 type FakeRequestHandler struct {
 	lock         *sync.Mutex
 	contexts     map[*client.CqlServerConnection]client.RequestHandlerContext
@@ -1090,3 +1126,96 @@ func (recv *FakeRequestHandler) Clear() {
 	recv.orderedConns = make([]*client.CqlServerConnection, 0)
 	recv.contexts = map[*client.CqlServerConnection]client.RequestHandlerContext{}
 }
+
+func TestOriginAuthPassthrough(t *testing.T) {
+	originAddress := "127.0.1.1"
+	targetAddress := "127.0.1.2"
+	serverConf := setup.NewTestConfig(originAddress, targetAddress)
+	serverConf.TargetUsername = "target_username"
+	serverConf.TargetPassword = "targetPassword"
+
+	proxyConf := setup.NewTestConfig(originAddress, targetAddress)
+	proxyConf.OriginUsername = "origin_username"
+	proxyConf.OriginPassword = "originPassword"
+	proxyConf.TargetUsername = "target_username"
+	proxyConf.TargetPassword = "targetPassword"
+	proxyConf.OriginAuthPassthroughEnabled = true
+
+	testSetup, err := setup.NewCqlServerTestSetup(t, serverConf, false, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+
+	// origin accepts the credentials of the proxy (control connection) and an opaque token from the client
+	proxyToken := (&zdmproxy.AuthCredentials{Username: proxyConf.OriginUsername, Password: proxyConf.OriginPassword}).Marshal()
+	var clientTokens []string
+	clientTokensLock := &sync.Mutex{}
+	originAuthHandler := func(request *frame.Frame, _ *client.CqlServerConnection, _ client.RequestHandlerContext) *frame.Frame {
+		var msg message.Message
+		switch typedMsg := request.Body.Message.(type) {
+		case *message.Startup:
+			msg = &message.Authenticate{Authenticator: "com.datastax.bdp.cassandra.auth.DseAuthenticator"}
+		case *message.AuthResponse:
+			switch string(typedMsg.Token) {
+			case "PLAIN":
+				msg = &message.AuthChallenge{Token: []byte("PLAIN-START")}
+			case "TOKEN":
+				msg = &message.AuthChallenge{Token: []byte("TOKEN-START")}
+			case string(proxyToken):
+				msg = &message.AuthSuccess{}
+			default:
+				clientTokensLock.Lock()
+				clientTokens = append(clientTokens, string(typedMsg.Token))
+				clientTokensLock.Unlock()
+				msg = &message.AuthSuccess{}
+			}
+		default:
+			return nil
+		}
+		return frame.NewFrame(request.Header.Version, request.Header.StreamId, msg)
+	}
+	testSetup.Origin.CqlServer.RequestHandlers = []client.RequestHandler{
+		originAuthHandler,
+		client.HeartbeatHandler,
+		client.RegisterHandler,
+		client.NewSetKeyspaceHandler(func(_ string) {}),
+		client.NewSystemTablesHandler("origin", "dc1"),
+	}
+	testSetup.Target.CqlServer.RequestHandlers = []client.RequestHandler{
+		client.NewDriverConnectionInitializationHandler("target", "dc2", func(_ string) {})}
+
+	err = testSetup.Start(nil, false, env.ProtocolVersion)
+	require.Nil(t, err)
+	proxy, err := setup.NewProxyInstanceWithConfig(proxyConf)
+	require.Nil(t, err)
+	defer proxy.Shutdown()
+
+	cqlConn, err := client.NewCqlClient(
+		fmt.Sprintf("%s:%d", proxyConf.ProxyListenAddress, proxyConf.ProxyListenPort), nil).Connect(context.Background())
+	require.Nil(t, err)
+	defer cqlConn.Close()
+	send := func(msg message.Message) (*frame.Frame, error) {
+		return cqlConn.SendAndReceive(frame.NewFrame(env.ProtocolVersion, 0, msg))
+	}
+
+	response, err := send(message.NewStartup())
+	require.Nil(t, err)
+	require.IsType(t, &message.Authenticate{}, response.Body.Message)
+	response, err = send(&message.AuthResponse{Token: []byte("TOKEN")})
+	require.Nil(t, err)
+	require.Equal(t, &message.AuthChallenge{Token: []byte("TOKEN-START")}, response.Body.Message)
+	// not a plain-text token, the proxy can't parse it
+	response, err = send(&message.AuthResponse{Token: []byte("opaque-token")})
+	require.Nil(t, err)
+	require.IsType(t, &message.AuthSuccess{}, response.Body.Message)
+
+	response, err = send(&message.Query{
+		Query:   "SELECT * FROM system.peers",
+		Options: &message.QueryOptions{Consistency: primitive.ConsistencyLevelOne},
+	})
+	require.Nil(t, err)
+	require.Equal(t, primitive.OpCodeResult, response.Header.OpCode, response.Body.Message)
+
+	clientTokensLock.Lock()
+	defer clientTokensLock.Unlock()
+	require.Equal(t, []string{"opaque-token"}, clientTokens)
+}
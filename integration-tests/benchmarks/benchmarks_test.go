@@ -0,0 +1,161 @@
+package benchmarks
+
+import (
+	"flag"
+	"fmt"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/datastax/zdm-proxy/integration-tests/simulacron"
+	"github.com/datastax/zdm-proxy/integration-tests/utils"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"github.com/gocql/gocql"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	benchWriteQuery = "INSERT INTO bench.kv (id, payload) VALUES (?, ?)"
+	benchReadQuery  = "SELECT payload FROM bench.kv WHERE id = ?"
+)
+
+var concurrencyFlag = flag.String("BENCH_CONCURRENCY", "1,16,64", "comma separated list of number of concurrent requests")
+var payloadSizesFlag = flag.String("BENCH_PAYLOAD_SIZES", "100,1000,10000", "comma separated list of payload sizes in bytes")
+
+func TestMain(m *testing.M) {
+	env.InitGlobalVars()
+	if env.Debug {
+		log.SetLevel(log.DebugLevel)
+	} else {
+		// the proxy logs every connection and handshake at INFO level, that would skew the results
+		log.SetLevel(log.WarnLevel)
+	}
+	os.Exit(runBenchmarks(m))
+}
+
+func runBenchmarks(m *testing.M) int {
+	defer setup.CleanUpClusters()
+	return m.Run()
+}
+
+// BenchmarkWrites measures INSERT requests which the proxy sends to both clusters.
+//
+// Run it with: go test ./integration-tests/benchmarks -run '^$' -bench BenchmarkWrites -benchtime 5000x
+func BenchmarkWrites(b *testing.B) {
+	simulacronSetup, session := newBenchmarkSetup(b, config.ReadModePrimaryOnly)
+	defer simulacronSetup.Cleanup()
+	defer session.Close()
+
+	for _, payloadSize := range parseIntList(b, *payloadSizesFlag) {
+		payload := make([]byte, payloadSize)
+		for _, concurrency := range parseIntList(b, *concurrencyFlag) {
+			b.Run(fmt.Sprintf("payload=%d/concurrency=%d", payloadSize, concurrency), func(b *testing.B) {
+				runBenchmark(b, concurrency, func(worker int) error {
+					return session.Query(benchWriteQuery, worker, payload).Exec()
+				})
+			})
+		}
+	}
+}
+
+// BenchmarkReads measures SELECT requests which return a single row with a payload of the configured size with both
+// PRIMARY_ONLY and DUAL_ASYNC_ON_SECONDARY read modes.
+//
+// Run it with: go test ./integration-tests/benchmarks -run '^$' -bench BenchmarkReads -benchtime 5000x
+func BenchmarkReads(b *testing.B) {
+	for _, readMode := range []string{config.ReadModePrimaryOnly, config.ReadModeDualAsyncOnSecondary} {
+		b.Run(fmt.Sprintf("readMode=%v", readMode), func(b *testing.B) {
+			simulacronSetup, session := newBenchmarkSetup(b, readMode)
+			defer simulacronSetup.Cleanup()
+			defer session.Close()
+
+			for _, payloadSize := range parseIntList(b, *payloadSizesFlag) {
+				primeBenchmarkRead(b, simulacronSetup, payloadSize)
+				for _, concurrency := range parseIntList(b, *concurrencyFlag) {
+					b.Run(fmt.Sprintf("payload=%d/concurrency=%d", payloadSize, concurrency), func(b *testing.B) {
+						runBenchmark(b, concurrency, func(worker int) error {
+							var payload string
+							return session.Query(benchReadQuery, worker).Scan(&payload)
+						})
+					})
+				}
+			}
+		})
+	}
+}
+
+// runBenchmark runs b.N requests and reports the latency percentiles, the throughput and the allocations
+// (of both the client and the proxy since the proxy runs in the same process).
+func runBenchmark(b *testing.B, concurrency int, op func(worker int) error) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := time.Now()
+	recorder := RunWorkload(b.N, concurrency, op)
+	elapsed := time.Since(start)
+	b.StopTimer()
+
+	if recorder.Errors() > 0 {
+		b.Fatalf("%d out of %d requests failed", recorder.Errors(), b.N)
+	}
+
+	b.ReportMetric(toMillis(recorder.Percentile(50)), "p50-ms")
+	b.ReportMetric(toMillis(recorder.Percentile(95)), "p95-ms")
+	b.ReportMetric(toMillis(recorder.Percentile(99)), "p99-ms")
+	b.ReportMetric(float64(recorder.Count())/elapsed.Seconds(), "req/s")
+}
+
+func newBenchmarkSetup(b *testing.B, readMode string) (*setup.SimulacronTestSetup, *gocql.Session) {
+	conf := setup.NewTestConfig("", "")
+	conf.ReadMode = readMode
+	conf.LogLevel = "WARN"
+	simulacronSetup, err := setup.NewSimulacronTestSetupWithConfig(b, conf)
+	if err != nil {
+		b.Fatalf("could not start proxy: %v", err)
+	}
+
+	cluster := utils.NewCluster("127.0.0.1", "", "", 14002)
+	cluster.Timeout = 10 * time.Second
+	session, err := cluster.CreateSession()
+	if err != nil {
+		simulacronSetup.Cleanup()
+		b.Fatalf("could not connect to proxy: %v", err)
+	}
+	return simulacronSetup, session
+}
+
+func primeBenchmarkRead(b *testing.B, simulacronSetup *setup.SimulacronTestSetup, payloadSize int) {
+	rows := simulacron.NewRowsResult(map[string]simulacron.DataType{
+		"payload": simulacron.DataTypeText,
+	}).WithRow(map[string]interface{}{
+		"payload": strings.Repeat("a", payloadSize),
+	})
+	for _, cluster := range []*simulacron.Cluster{simulacronSetup.Origin, simulacronSetup.Target} {
+		err := cluster.ClearPrimes()
+		if err != nil {
+			b.Fatalf("could not clear primes: %v", err)
+		}
+		err = cluster.Prime(simulacron.WhenQuery(benchReadQuery, simulacron.NewWhenQueryOptions()).ThenRowsSuccess(rows))
+		if err != nil {
+			b.Fatalf("could not prime read query: %v", err)
+		}
+	}
+}
+
+func parseIntList(b *testing.B, list string) []int {
+	var values []int
+	for _, value := range strings.Split(list, ",") {
+		parsed, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || parsed < 1 {
+			b.Fatalf("invalid value in %q, expected positive integers", list)
+		}
+		values = append(values, parsed)
+	}
+	return values
+}
+
+func toMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,70 @@
+package benchmarks
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// LatencyRecorder collects request latencies so that percentiles can be computed at the end of a benchmark run.
+// It is safe for concurrent use.
+type LatencyRecorder struct {
+	lock      *sync.Mutex
+	latencies []time.Duration
+	errors    int
+	sorted    bool
+}
+
+func NewLatencyRecorder() *LatencyRecorder {
+	return &LatencyRecorder{
+		lock: &sync.Mutex{},
+	}
+}
+
+func (recv *LatencyRecorder) Record(latency time.Duration, err error) {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	if err != nil {
+		recv.errors++
+		return
+	}
+	recv.latencies = append(recv.latencies, latency)
+	recv.sorted = false
+}
+
+func (recv *LatencyRecorder) Count() int {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	return len(recv.latencies)
+}
+
+func (recv *LatencyRecorder) Errors() int {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	return recv.errors
+}
+
+// Percentile returns the latency below which the provided percentage (between 0 and 100) of the successful requests
+// fall using the nearest-rank method. It returns 0 if no latency was recorded.
+func (recv *LatencyRecorder) Percentile(percentile float64) time.Duration {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	if len(recv.latencies) == 0 {
+		return 0
+	}
+
+	if !recv.sorted {
+		sort.Slice(recv.latencies, func(i, j int) bool {
+			return recv.latencies[i] < recv.latencies[j]
+		})
+		recv.sorted = true
+	}
+
+	rank := int(percentile/100*float64(len(recv.latencies))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	} else if rank >= len(recv.latencies) {
+		rank = len(recv.latencies) - 1
+	}
+	return recv.latencies[rank]
+}
@@ -0,0 +1,40 @@
+package benchmarks
+
+import (
+	"errors"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestLatencyRecorder(t *testing.T) {
+	recorder := NewLatencyRecorder()
+	require.Equal(t, time.Duration(0), recorder.Percentile(50))
+
+	for i := 100; i >= 1; i-- {
+		recorder.Record(time.Duration(i)*time.Millisecond, nil)
+	}
+	recorder.Record(time.Second, errors.New("test"))
+
+	require.Equal(t, 100, recorder.Count())
+	require.Equal(t, 1, recorder.Errors())
+	require.Equal(t, 1*time.Millisecond, recorder.Percentile(0))
+	require.Equal(t, 50*time.Millisecond, recorder.Percentile(50))
+	require.Equal(t, 95*time.Millisecond, recorder.Percentile(95))
+	require.Equal(t, 99*time.Millisecond, recorder.Percentile(99))
+	require.Equal(t, 100*time.Millisecond, recorder.Percentile(100))
+}
+
+func TestRunWorkload(t *testing.T) {
+	workers := make([]int, 4)
+	recorder := RunWorkload(100, len(workers), func(worker int) error {
+		workers[worker]++
+		return nil
+	})
+	require.Equal(t, 100, recorder.Count())
+	total := 0
+	for _, count := range workers {
+		total += count
+	}
+	require.Equal(t, 100, total)
+}
@@ -0,0 +1,35 @@
+package benchmarks
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RunWorkload executes the provided operation the given number of times using concurrency goroutines and records the
+// latency of each execution.
+//
+// The operation receives the index of the goroutine that runs it so that each goroutine can use its own state.
+func RunWorkload(requests int, concurrency int, op func(worker int) error) *LatencyRecorder {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	recorder := NewLatencyRecorder()
+	remaining := int64(requests)
+	wg := &sync.WaitGroup{}
+	wg.Add(concurrency)
+	for i := 0; i < concurrency; i++ {
+		worker := i
+		go func() {
+			defer wg.Done()
+			for atomic.AddInt64(&remaining, -1) >= 0 {
+				start := time.Now()
+				err := op(worker)
+				recorder.Record(time.Since(start), err)
+			}
+		}()
+	}
+	wg.Wait()
+	return recorder
+}
@@ -1,38 +1,74 @@
 package ccm
 
 import (
+	"errors"
 	"fmt"
 	"github.com/datastax/zdm-proxy/integration-tests/env"
 	"github.com/gocql/gocql"
+	log "github.com/sirupsen/logrus"
+	"time"
 )
 
+const (
+	// DefaultUsername and DefaultPassword are the credentials of the superuser that Cassandra creates
+	// when PasswordAuthenticator is enabled.
+	DefaultUsername = "cassandra"
+	DefaultPassword = "cassandra"
+
+	sessionCreationAttempts = 30
+	sessionCreationDelay    = time.Second
+)
+
+type ClusterOptions struct {
+	NumberOfNodes int
+	EnableAuth    bool
+}
+
 type Cluster struct {
 	name                string
 	version             string
 	initialContactPoint string
 	isDse               bool
 	numberOfSeedNodes   int
+	authEnabled         bool
 
 	startNodeIndex int
 	session        *gocql.Session
 }
 
-func newCluster(name string, version string, isDse bool, startNodeIndex int, numberOfSeedNodes int) *Cluster {
+func newCluster(
+	name string, version string, isDse bool, startNodeIndex int, numberOfSeedNodes int, authEnabled bool) *Cluster {
 	return &Cluster{
 		name:                name,
 		version:             version,
 		initialContactPoint: fmt.Sprintf("127.0.0.%d", startNodeIndex),
 		isDse:               isDse,
 		numberOfSeedNodes:   numberOfSeedNodes,
+		authEnabled:         authEnabled,
 		startNodeIndex:      startNodeIndex,
 		session:             nil,
 	}
 }
 
 func GetNewCluster(id uint64, startNodeIndex int, numberOfNodes int, start bool) (*Cluster, error) {
+	return GetNewClusterWithOptions(id, startNodeIndex, ClusterOptions{NumberOfNodes: numberOfNodes}, start)
+}
+
+// GetNewClusterWithOptions creates a CCM cluster with the provided number of nodes (all of them are seeds) and,
+// if EnableAuth is set, with PasswordAuthenticator and CassandraAuthorizer enabled.
+// Authentication can only be enabled on Cassandra clusters because DSE configures it in dse.yaml.
+func GetNewClusterWithOptions(id uint64, startNodeIndex int, options ClusterOptions, start bool) (*Cluster, error) {
+	if options.NumberOfNodes < 1 || options.NumberOfNodes > env.MaxNodesPerCluster {
+		return nil, fmt.Errorf("invalid number of nodes %d, it must be between 1 and %d",
+			options.NumberOfNodes, env.MaxNodesPerCluster)
+	}
+	if options.EnableAuth && env.IsDse {
+		return nil, errors.New("authentication is not supported on DSE test clusters")
+	}
+
 	name := fmt.Sprintf("test_cluster%d", id)
-	cluster := newCluster(name, env.ServerVersion, env.IsDse, startNodeIndex, numberOfNodes)
-	err := cluster.Create(numberOfNodes, start)
+	cluster := newCluster(name, env.ServerVersion, env.IsDse, startNodeIndex, options.NumberOfNodes, options.EnableAuth)
+	err := cluster.Create(options.NumberOfNodes, start)
 	if err != nil {
 		return nil, err
 	}
@@ -59,6 +95,10 @@ func (ccmCluster *Cluster) GetNumberOfSeedNodes() int {
 	return ccmCluster.numberOfSeedNodes
 }
 
+func (ccmCluster *Cluster) IsAuthEnabled() bool {
+	return ccmCluster.authEnabled
+}
+
 func (ccmCluster *Cluster) Create(numberOfNodes int, start bool) error {
 	_, err := Create(ccmCluster.name, ccmCluster.version, ccmCluster.isDse)
 
@@ -82,6 +122,15 @@ func (ccmCluster *Cluster) Create(numberOfNodes int, start bool) error {
 		}
 	}
 
+	if ccmCluster.authEnabled {
+		_, err = UpdateConf("authenticator: PasswordAuthenticator", "authorizer: CassandraAuthorizer")
+
+		if err != nil {
+			Remove(ccmCluster.name)
+			return err
+		}
+	}
+
 	if start {
 		_, err = Start()
 
@@ -90,8 +139,7 @@ func (ccmCluster *Cluster) Create(numberOfNodes int, start bool) error {
 			return err
 		}
 
-		gocqlCluster := gocql.NewCluster(ccmCluster.initialContactPoint)
-		ccmCluster.session, err = gocqlCluster.CreateSession()
+		ccmCluster.session, err = ccmCluster.createSession()
 
 		if err != nil {
 			Remove(ccmCluster.name)
@@ -102,6 +150,28 @@ func (ccmCluster *Cluster) Create(numberOfNodes int, start bool) error {
 	return nil
 }
 
+func (ccmCluster *Cluster) createSession() (*gocql.Session, error) {
+	gocqlCluster := gocql.NewCluster(ccmCluster.initialContactPoint)
+	if !ccmCluster.authEnabled {
+		return gocqlCluster.CreateSession()
+	}
+
+	// the default superuser is created asynchronously after the native transport is up
+	gocqlCluster.Authenticator = gocql.PasswordAuthenticator{Username: DefaultUsername, Password: DefaultPassword}
+	var session *gocql.Session
+	var err error
+	for i := 0; i < sessionCreationAttempts; i++ {
+		session, err = gocqlCluster.CreateSession()
+		if err == nil {
+			return session, nil
+		}
+		log.Infof("Could not create session for %v (attempt %d/%d): %v",
+			ccmCluster.name, i+1, sessionCreationAttempts, err)
+		time.Sleep(sessionCreationDelay)
+	}
+	return nil, err
+}
+
 func (ccmCluster *Cluster) UpdateConf(yamlChanges ...string) error {
 	err := ccmCluster.SwitchToThis()
 	if err != nil {
@@ -0,0 +1,107 @@
+package integration_tests
+
+import (
+	"fmt"
+	"github.com/datastax/zdm-proxy/integration-tests/ccm"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/datastax/zdm-proxy/integration-tests/utils"
+	"github.com/gocql/gocql"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+const ccmTestTable = "ccm_dual_writes"
+
+// TestCcmMultiNodeDualWrites writes through the proxy to multi node origin and target clusters and checks that
+// every row was written to all replicas of both clusters.
+func TestCcmMultiNodeDualWrites(t *testing.T) {
+	if !env.RunCcmTests {
+		t.Skip("Test requires CCM, set RUN_CCMTESTS env variable to TRUE")
+	}
+
+	ccmSetup, err := setup.NewTemporaryCcmTestSetupWithOptions(
+		true, true, ccm.ClusterOptions{NumberOfNodes: 3}, ccm.ClusterOptions{NumberOfNodes: 3})
+	require.Nil(t, err)
+	defer ccmSetup.Cleanup()
+
+	for _, cluster := range []*ccm.Cluster{ccmSetup.Origin, ccmSetup.Target} {
+		createCcmTestSchema(t, cluster.GetSession(), 3)
+	}
+
+	proxy, err := utils.ConnectToCluster("127.0.0.1", "", "", 14002)
+	require.Nil(t, err)
+	defer proxy.Close()
+
+	writeCcmTestRows(t, proxy, 50, gocql.Quorum)
+
+	for _, cluster := range []*ccm.Cluster{ccmSetup.Origin, ccmSetup.Target} {
+		requireCcmTestRows(t, cluster.GetSession(), 50, gocql.All)
+	}
+}
+
+// TestCcmAuth checks that the proxy authenticates with both clusters when PasswordAuthenticator is enabled and that
+// client credentials are validated.
+func TestCcmAuth(t *testing.T) {
+	if !env.RunCcmTests {
+		t.Skip("Test requires CCM, set RUN_CCMTESTS env variable to TRUE")
+	}
+	if env.IsDse {
+		t.Skip("Test requires Cassandra, authentication is not supported on DSE test clusters")
+	}
+
+	ccmSetup, err := setup.NewTemporaryCcmTestSetupWithOptions(
+		true, true,
+		ccm.ClusterOptions{NumberOfNodes: env.OriginNodes, EnableAuth: true},
+		ccm.ClusterOptions{NumberOfNodes: env.TargetNodes, EnableAuth: true})
+	require.Nil(t, err)
+	defer ccmSetup.Cleanup()
+
+	for _, cluster := range []*ccm.Cluster{ccmSetup.Origin, ccmSetup.Target} {
+		createCcmTestSchema(t, cluster.GetSession(), 1)
+	}
+
+	_, err = utils.ConnectToCluster("127.0.0.1", ccm.DefaultUsername, "wrong_password", 14002)
+	require.NotNil(t, err)
+
+	proxy, err := utils.ConnectToCluster("127.0.0.1", ccm.DefaultUsername, ccm.DefaultPassword, 14002)
+	require.Nil(t, err)
+	defer proxy.Close()
+
+	writeCcmTestRows(t, proxy, 10, gocql.One)
+
+	for _, cluster := range []*ccm.Cluster{ccmSetup.Origin, ccmSetup.Target} {
+		requireCcmTestRows(t, cluster.GetSession(), 10, gocql.One)
+	}
+}
+
+func createCcmTestSchema(t *testing.T, session *gocql.Session, replicationFactor int) {
+	err := session.Query(fmt.Sprintf(
+		"CREATE KEYSPACE IF NOT EXISTS %s WITH replication = {'class':'SimpleStrategy', 'replication_factor':%d};",
+		setup.TestKeyspace, replicationFactor)).Exec()
+	require.Nil(t, err)
+
+	err = session.Query(fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s.%s(id int PRIMARY KEY, task text);", setup.TestKeyspace, ccmTestTable)).Exec()
+	require.Nil(t, err)
+}
+
+func writeCcmTestRows(t *testing.T, session *gocql.Session, numberOfRows int, consistency gocql.Consistency) {
+	for i := 0; i < numberOfRows; i++ {
+		err := session.Query(
+			fmt.Sprintf("INSERT INTO %s.%s (id, task) VALUES (?, ?)", setup.TestKeyspace, ccmTestTable),
+			i, fmt.Sprintf("task%d", i)).Consistency(consistency).Exec()
+		require.Nil(t, err)
+	}
+}
+
+func requireCcmTestRows(t *testing.T, session *gocql.Session, numberOfRows int, consistency gocql.Consistency) {
+	for i := 0; i < numberOfRows; i++ {
+		var task string
+		err := session.Query(
+			fmt.Sprintf("SELECT task FROM %s.%s WHERE id = ?", setup.TestKeyspace, ccmTestTable),
+			i).Consistency(consistency).Scan(&task)
+		require.Nil(t, err)
+		require.Equal(t, fmt.Sprintf("task%d", i), task)
+	}
+}
@@ -0,0 +1,224 @@
+package chaos
+
+import (
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Proxy is a TCP proxy that can be placed between the ZDM proxy and a cluster node to inject network faults.
+//
+// Each accepted connection is forwarded to the target address. Faults apply to connections that are accepted
+// after they are enabled, except for latency which also applies to existing connections.
+type Proxy struct {
+	listenAddress string
+	targetAddress string
+
+	listener    net.Listener
+	lock        *sync.Mutex
+	connections map[*proxyConnection]struct{}
+	wg          *sync.WaitGroup
+	closed      bool
+
+	latency           time.Duration
+	dropAfterBytes    int
+	rejectConnections bool
+}
+
+type proxyConnection struct {
+	client net.Conn
+	server net.Conn
+	once   *sync.Once
+}
+
+func (recv *proxyConnection) close() {
+	recv.once.Do(func() {
+		_ = recv.client.Close()
+		_ = recv.server.Close()
+	})
+}
+
+func NewProxy(listenAddress string, targetAddress string) *Proxy {
+	return &Proxy{
+		listenAddress: listenAddress,
+		targetAddress: targetAddress,
+		lock:          &sync.Mutex{},
+		connections:   map[*proxyConnection]struct{}{},
+		wg:            &sync.WaitGroup{},
+	}
+}
+
+func (recv *Proxy) GetListenAddress() string {
+	return recv.listenAddress
+}
+
+func (recv *Proxy) Start() error {
+	listener, err := net.Listen("tcp", recv.listenAddress)
+	if err != nil {
+		return fmt.Errorf("could not start chaos proxy on %v: %w", recv.listenAddress, err)
+	}
+
+	recv.lock.Lock()
+	recv.listener = listener
+	recv.lock.Unlock()
+
+	recv.wg.Add(1)
+	go func() {
+		defer recv.wg.Done()
+		for {
+			clientConn, err := listener.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					log.Warnf("[chaos proxy %v] could not accept connection: %v", recv.listenAddress, err)
+				}
+				return
+			}
+			recv.handleConnection(clientConn)
+		}
+	}()
+	return nil
+}
+
+// Close stops accepting connections and closes all existing connections.
+func (recv *Proxy) Close() error {
+	recv.lock.Lock()
+	recv.closed = true
+	listener := recv.listener
+	recv.lock.Unlock()
+
+	var err error
+	if listener != nil {
+		err = listener.Close()
+	}
+	recv.DropAllConnections()
+	recv.wg.Wait()
+	return err
+}
+
+// SetLatency delays every chunk of data sent by the target back to the client by the provided duration.
+func (recv *Proxy) SetLatency(latency time.Duration) {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	recv.latency = latency
+}
+
+// DropConnectionsAfter makes new connections close after forwarding the provided number of bytes from the client to
+// the target. It can be used to drop connections in the middle of the CQL handshake, e.g., a value of 9 forwards
+// the OPTIONS request (a frame header without body) and closes the connection before the STARTUP request is sent.
+//
+// A value of 0 disables this fault.
+func (recv *Proxy) DropConnectionsAfter(bytes int) {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	recv.dropAfterBytes = bytes
+}
+
+// RejectConnections makes the proxy close new connections right after accepting them.
+func (recv *Proxy) RejectConnections(reject bool) {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	recv.rejectConnections = reject
+}
+
+// DropAllConnections closes all existing connections but keeps accepting new ones.
+func (recv *Proxy) DropAllConnections() {
+	recv.lock.Lock()
+	connections := make([]*proxyConnection, 0, len(recv.connections))
+	for conn := range recv.connections {
+		connections = append(connections, conn)
+	}
+	recv.lock.Unlock()
+
+	for _, conn := range connections {
+		conn.close()
+	}
+}
+
+func (recv *Proxy) GetNumberOfConnections() int {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	return len(recv.connections)
+}
+
+func (recv *Proxy) getLatency() time.Duration {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	return recv.latency
+}
+
+func (recv *Proxy) handleConnection(clientConn net.Conn) {
+	recv.lock.Lock()
+	reject := recv.rejectConnections || recv.closed
+	dropAfterBytes := recv.dropAfterBytes
+	recv.lock.Unlock()
+
+	if reject {
+		log.Infof("[chaos proxy %v] rejecting connection from %v", recv.listenAddress, clientConn.RemoteAddr())
+		_ = clientConn.Close()
+		return
+	}
+
+	serverConn, err := net.DialTimeout("tcp", recv.targetAddress, 5*time.Second)
+	if err != nil {
+		log.Warnf("[chaos proxy %v] could not connect to %v: %v", recv.listenAddress, recv.targetAddress, err)
+		_ = clientConn.Close()
+		return
+	}
+
+	conn := &proxyConnection{client: clientConn, server: serverConn, once: &sync.Once{}}
+	recv.lock.Lock()
+	if recv.closed {
+		recv.lock.Unlock()
+		conn.close()
+		return
+	}
+	recv.connections[conn] = struct{}{}
+	recv.lock.Unlock()
+
+	connWg := &sync.WaitGroup{}
+	connWg.Add(2)
+	recv.wg.Add(1)
+	go func() {
+		defer connWg.Done()
+		defer conn.close()
+		var src io.Reader = clientConn
+		if dropAfterBytes > 0 {
+			src = io.LimitReader(clientConn, int64(dropAfterBytes))
+		}
+		_, _ = io.Copy(serverConn, src)
+		if dropAfterBytes > 0 {
+			log.Infof("[chaos proxy %v] dropping connection from %v after %d bytes",
+				recv.listenAddress, clientConn.RemoteAddr(), dropAfterBytes)
+		}
+	}()
+	go func() {
+		defer connWg.Done()
+		defer conn.close()
+		buf := make([]byte, 8192)
+		for {
+			n, err := serverConn.Read(buf)
+			if n > 0 {
+				if latency := recv.getLatency(); latency > 0 {
+					time.Sleep(latency)
+				}
+				if _, writeErr := clientConn.Write(buf[:n]); writeErr != nil {
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	go func() {
+		defer recv.wg.Done()
+		connWg.Wait()
+		recv.lock.Lock()
+		delete(recv.connections, conn)
+		recv.lock.Unlock()
+	}()
+}
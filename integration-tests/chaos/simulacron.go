@@ -0,0 +1,50 @@
+package chaos
+
+import (
+	"fmt"
+	"github.com/datastax/zdm-proxy/integration-tests/simulacron"
+	"time"
+)
+
+// SimulacronTarget is implemented by simulacron clusters, datacenters and nodes.
+type SimulacronTarget interface {
+	GetId() string
+	Prime(then simulacron.Then) error
+	DropAllConnections() error
+	DisableConnectionListener() error
+	EnableConnectionListener() error
+}
+
+// KillNode simulates a node (or every node of a cluster or datacenter) going down: new connections are refused and
+// existing connections are closed.
+func KillNode(target SimulacronTarget) error {
+	err := target.DisableConnectionListener()
+	if err != nil {
+		return fmt.Errorf("could not disable connection listener of %v: %w", target.GetId(), err)
+	}
+	err = target.DropAllConnections()
+	if err != nil {
+		return fmt.Errorf("could not drop connections of %v: %w", target.GetId(), err)
+	}
+	return nil
+}
+
+// ReviveNode accepts connections again after KillNode.
+func ReviveNode(target SimulacronTarget) error {
+	err := target.EnableConnectionListener()
+	if err != nil {
+		return fmt.Errorf("could not enable connection listener of %v: %w", target.GetId(), err)
+	}
+	return nil
+}
+
+// InjectQueryLatency primes the provided query to succeed (without rows) after the provided delay.
+func InjectQueryLatency(target SimulacronTarget, query string, latency time.Duration) error {
+	err := target.Prime(simulacron.WhenQuery(query, simulacron.NewWhenQueryOptions()).
+		ThenSuccess().
+		WithDelay(latency))
+	if err != nil {
+		return fmt.Errorf("could not inject latency on %v: %w", target.GetId(), err)
+	}
+	return nil
+}
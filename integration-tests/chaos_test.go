@@ -0,0 +1,222 @@
+package integration_tests
+
+import (
+	"context"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/integration-tests/chaos"
+	"github.com/datastax/zdm-proxy/integration-tests/cqlserver"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/datastax/zdm-proxy/integration-tests/utils"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+const chaosTestQuery = "INSERT INTO ks.chaos (id) VALUES (1)"
+
+// TestChaosDropConnectionMidHandshake checks that the proxy fails to start and rejects client connections while the
+// connections to a cluster are dropped in the middle of the handshake and that it recovers once the fault is removed.
+func TestChaosDropConnectionMidHandshake(t *testing.T) {
+	for _, cluster := range []string{"origin", "target"} {
+		t.Run(cluster, func(t *testing.T) {
+			testSetup, conf, originChaos, targetChaos := newChaosTestSetup(t)
+			defer testSetup.Cleanup()
+			faultyProxy := originChaos
+			if cluster == "target" {
+				faultyProxy = targetChaos
+			}
+
+			faultyProxy.DropConnectionsAfter(9)
+			proxy, err := setup.NewProxyInstanceWithConfig(conf)
+			if proxy != nil {
+				proxy.Shutdown()
+			}
+			require.NotNil(t, err, "proxy should not start while connections are dropped mid-handshake")
+
+			faultyProxy.DropConnectionsAfter(0)
+			err = testSetup.Start(conf, true, env.ProtocolVersion)
+			require.Nil(t, err)
+			sendChaosTestQuery(t, testSetup.Client.CqlConnection)
+
+			faultyProxy.DropConnectionsAfter(9)
+			_, err = connectChaosTestClient(conf)
+			require.NotNil(t, err, "client handshake should fail while connections are dropped mid-handshake")
+
+			// the existing connection is not affected
+			sendChaosTestQuery(t, testSetup.Client.CqlConnection)
+
+			faultyProxy.DropConnectionsAfter(0)
+			newClient, err := connectChaosTestClient(conf)
+			require.Nil(t, err)
+			defer newClient.Close()
+			sendChaosTestQuery(t, newClient)
+		})
+	}
+}
+
+// TestChaosDropConnections checks that the proxy closes client connections when its connections to a cluster are
+// dropped and that new client connections work afterwards.
+func TestChaosDropConnections(t *testing.T) {
+	for _, cluster := range []string{"origin", "target"} {
+		t.Run(cluster, func(t *testing.T) {
+			testSetup, conf, originChaos, targetChaos := newChaosTestSetup(t)
+			defer testSetup.Cleanup()
+			faultyProxy := originChaos
+			if cluster == "target" {
+				faultyProxy = targetChaos
+			}
+
+			err := testSetup.Start(conf, true, env.ProtocolVersion)
+			require.Nil(t, err)
+			sendChaosTestQuery(t, testSetup.Client.CqlConnection)
+
+			faultyProxy.DropAllConnections()
+			utils.RequireWithRetries(t, func() (err error, fatal bool) {
+				if testSetup.Client.CqlConnection.IsClosed() {
+					return nil, false
+				}
+				return fmt.Errorf("client connection should have been closed"), false
+			}, 25, 200*time.Millisecond)
+
+			// the control connection and the new cluster connections go through the chaos proxy too
+			utils.RequireWithRetries(t, func() (err error, fatal bool) {
+				newClient, err := connectChaosTestClient(conf)
+				if err != nil {
+					return err, false
+				}
+				defer newClient.Close()
+				sendChaosTestQuery(t, newClient)
+				return nil, false
+			}, 25, 200*time.Millisecond)
+		})
+	}
+}
+
+// TestChaosLatency checks that responses are delayed by the latency injected on the secondary cluster (writes are sent
+// to both clusters) and that requests are fast again once the latency is removed.
+func TestChaosLatency(t *testing.T) {
+	testSetup, conf, _, targetChaos := newChaosTestSetup(t)
+	defer testSetup.Cleanup()
+
+	err := testSetup.Start(conf, true, env.ProtocolVersion)
+	require.Nil(t, err)
+
+	latency := 500 * time.Millisecond
+	targetChaos.SetLatency(latency)
+	start := time.Now()
+	sendChaosTestQuery(t, testSetup.Client.CqlConnection)
+	require.GreaterOrEqual(t, time.Since(start), latency)
+
+	targetChaos.SetLatency(0)
+	start = time.Now()
+	sendChaosTestQuery(t, testSetup.Client.CqlConnection)
+	require.Less(t, time.Since(start), latency)
+}
+
+// TestChaosSimulacronNodeKilled checks that the proxy rejects new client connections while a cluster is down and
+// accepts them again once it is back up.
+func TestChaosSimulacronNodeKilled(t *testing.T) {
+	for _, cluster := range []string{"origin", "target"} {
+		t.Run(cluster, func(t *testing.T) {
+			simulacronSetup, err := setup.NewSimulacronTestSetup(t)
+			require.Nil(t, err)
+			defer simulacronSetup.Cleanup()
+			var faultyCluster chaos.SimulacronTarget = simulacronSetup.Origin
+			if cluster == "target" {
+				faultyCluster = simulacronSetup.Target
+			}
+
+			err = chaos.KillNode(faultyCluster)
+			require.Nil(t, err)
+
+			testClient, err := client.NewCqlClient("127.0.0.1:14002", nil).ConnectAndInit(
+				context.Background(), env.ProtocolVersion, client.ManagedStreamId)
+			if err == nil {
+				_ = testClient.Close()
+			}
+			require.NotNil(t, err, "client handshake should fail while %v is down", cluster)
+
+			err = chaos.ReviveNode(faultyCluster)
+			require.Nil(t, err)
+
+			utils.RequireWithRetries(t, func() (err error, fatal bool) {
+				testClient, err := client.NewCqlClient("127.0.0.1:14002", nil).ConnectAndInit(
+					context.Background(), env.ProtocolVersion, client.ManagedStreamId)
+				if err != nil {
+					return err, false
+				}
+				defer testClient.Close()
+				response, err := testClient.SendAndReceive(
+					frame.NewFrame(env.ProtocolVersion, 0, &message.Query{Query: "SELECT * FROM system.peers"}))
+				if err != nil {
+					return err, false
+				}
+				if response.Header.OpCode != primitive.OpCodeResult {
+					return fmt.Errorf("expected result but got %v", response.Body.Message), false
+				}
+				return nil, false
+			}, 25, 200*time.Millisecond)
+		})
+	}
+}
+
+// newChaosTestSetup creates a cql server test setup where the proxy connects to each cluster through a chaos proxy.
+// Host assignment is disabled so that the proxy doesn't bypass the chaos proxies with the addresses in system.local.
+func newChaosTestSetup(t *testing.T) (*setup.CqlServerTestSetup, *config.Config, *chaos.Proxy, *chaos.Proxy) {
+	conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+	require.Nil(t, err)
+	testSetup.Origin.CqlServer.RequestHandlers = []client.RequestHandler{
+		client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+		client.NewSystemTablesHandler("cluster1", "dc1"), newChaosTestQueryHandler()}
+	testSetup.Target.CqlServer.RequestHandlers = []client.RequestHandler{
+		client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+		client.NewSystemTablesHandler("cluster2", "dc2"), newChaosTestQueryHandler()}
+
+	originChaos := chaos.NewProxy("127.0.1.3:9042", testSetup.Origin.InitialContactPoint)
+	require.Nil(t, originChaos.Start())
+	t.Cleanup(func() { _ = originChaos.Close() })
+	targetChaos := chaos.NewProxy("127.0.1.4:9042", testSetup.Target.InitialContactPoint)
+	require.Nil(t, targetChaos.Start())
+	t.Cleanup(func() { _ = targetChaos.Close() })
+
+	conf.OriginContactPoints = "127.0.1.3"
+	conf.TargetContactPoints = "127.0.1.4"
+	conf.OriginEnableHostAssignment = false
+	conf.TargetEnableHostAssignment = false
+	return testSetup, conf, originChaos, targetChaos
+}
+
+func newChaosTestQueryHandler() client.RequestHandler {
+	return func(request *frame.Frame, conn *client.CqlServerConnection, ctx client.RequestHandlerContext) *frame.Frame {
+		if query, ok := request.Body.Message.(*message.Query); ok && query.Query == chaosTestQuery {
+			return frame.NewFrame(request.Header.Version, request.Header.StreamId, &message.VoidResult{})
+		}
+		return nil
+	}
+}
+
+func connectChaosTestClient(conf *config.Config) (*client.CqlClientConnection, error) {
+	testClient, err := cqlserver.NewCqlClient(
+		conf.ProxyListenAddress, conf.ProxyListenPort, conf.OriginUsername, conf.OriginPassword, false)
+	if err != nil {
+		return nil, err
+	}
+	err = testClient.Connect(env.ProtocolVersion)
+	if err != nil {
+		return nil, err
+	}
+	return testClient.CqlConnection, nil
+}
+
+func sendChaosTestQuery(t *testing.T, conn *client.CqlClientConnection) {
+	response, err := conn.SendAndReceive(frame.NewFrame(env.ProtocolVersion, 0, &message.Query{Query: chaosTestQuery}))
+	require.Nil(t, err)
+	require.IsType(t, &message.VoidResult{}, response.Body.Message)
+}
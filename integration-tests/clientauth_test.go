@@ -0,0 +1,227 @@
+package integration_tests
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestProxyClientAuthentication(t *testing.T) {
+	credentialsFile := filepath.Join(t.TempDir(), "credentials")
+	err := os.WriteFile(credentialsFile, []byte(
+		"# application users\n"+
+			"app:appPassword\n"+
+			// sha256 of "hashedPassword"
+			"hashed:sha256:e2794d1158af4b21fbdd0e742cfb74fa5672b4f0c82f18547c3f5da43d816be8\n"), 0600)
+	require.Nil(t, err)
+
+	tests := []struct {
+		name           string
+		clusterAuth    bool
+		clientUsername string
+		clientPassword string
+		success        bool
+	}{
+		{"cluster auth, valid client credentials", true, "app", "appPassword", true},
+		{"cluster auth, valid hashed client credentials", true, "hashed", "hashedPassword", true},
+		{"cluster auth, invalid client password", true, "app", "cassandra", false},
+		{"cluster auth, cluster credentials", true, "cassandra", "cassandra", false},
+		{"no cluster auth, valid client credentials", false, "app", "appPassword", true},
+		{"no cluster auth, no client credentials", false, "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverConf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+			if !tt.clusterAuth {
+				serverConf.OriginUsername, serverConf.OriginPassword = "", ""
+				serverConf.TargetUsername, serverConf.TargetPassword = "", ""
+			}
+			testSetup, err := setup.NewCqlServerTestSetup(t, serverConf, true, false, false)
+			require.Nil(t, err)
+			defer testSetup.Cleanup()
+
+			proxyConf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+			proxyConf.ProxyClientCredentialsFile = credentialsFile
+			proxy, err := setup.NewProxyInstanceWithConfig(proxyConf)
+			require.Nil(t, err)
+			defer proxy.Shutdown()
+
+			var authCreds *client.AuthCredentials
+			if tt.clientUsername != "" {
+				authCreds = &client.AuthCredentials{Username: tt.clientUsername, Password: tt.clientPassword}
+			}
+			testClient := client.NewCqlClient(
+				fmt.Sprintf("%s:%d", proxyConf.ProxyListenAddress, proxyConf.ProxyListenPort), authCreds)
+			cqlConn, err := testClient.Connect(context.Background())
+			require.Nil(t, err)
+			defer cqlConn.Close()
+
+			err = cqlConn.InitiateHandshake(env.ProtocolVersion, 0)
+			if !tt.success {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+
+			response, err := cqlConn.SendAndReceive(frame.NewFrame(env.ProtocolVersion, 0, &message.Query{
+				Query:   "SELECT * FROM system.peers",
+				Options: &message.QueryOptions{Consistency: primitive.ConsistencyLevelOne},
+			}))
+			require.Nil(t, err)
+			require.Equal(t, primitive.OpCodeResult, response.Body.Message.GetOpCode(), response.Body.Message)
+		})
+	}
+}
+
+// TestProxyClientAuthentication_RequestBeforeAuthentication checks that the requests that a client sends after the
+// AUTHENTICATE response of the proxy, instead of an AUTH_RESPONSE, are not forwarded to the clusters even though the
+// clusters accepted the handshake of the proxy.
+func TestProxyClientAuthentication_RequestBeforeAuthentication(t *testing.T) {
+	credentialsFile := filepath.Join(t.TempDir(), "credentials")
+	require.Nil(t, os.WriteFile(credentialsFile, []byte("app:appPassword\n"), 0600))
+
+	serverConf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	serverConf.OriginUsername, serverConf.OriginPassword = "", ""
+	serverConf.TargetUsername, serverConf.TargetPassword = "", ""
+	testSetup, err := setup.NewCqlServerTestSetup(t, serverConf, true, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+
+	proxyConf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	proxyConf.ProxyClientCredentialsFile = credentialsFile
+	proxy, err := setup.NewProxyInstanceWithConfig(proxyConf)
+	require.Nil(t, err)
+	defer proxy.Shutdown()
+
+	requests := map[string]message.Message{
+		"QUERY": &message.Query{
+			Query:   "SELECT * FROM system.peers",
+			Options: &message.QueryOptions{Consistency: primitive.ConsistencyLevelOne}},
+		"PREPARE":  &message.Prepare{Query: "SELECT * FROM system.peers"},
+		"REGISTER": &message.Register{EventTypes: []primitive.EventType{primitive.EventTypeSchemaChange}},
+	}
+	for name, request := range requests {
+		t.Run(name, func(t *testing.T) {
+			testClient := client.NewCqlClient(
+				fmt.Sprintf("%s:%d", proxyConf.ProxyListenAddress, proxyConf.ProxyListenPort), nil)
+			cqlConn, err := testClient.Connect(context.Background())
+			require.Nil(t, err)
+			defer cqlConn.Close()
+
+			response, err := cqlConn.SendAndReceive(frame.NewFrame(env.ProtocolVersion, 0, message.NewStartup()))
+			require.Nil(t, err)
+			require.Equal(t, primitive.OpCodeAuthenticate, response.Body.Message.GetOpCode(), response.Body.Message)
+
+			// a protocol error is fatal so the driver closes the connection afterwards
+			response, err = cqlConn.SendAndReceive(frame.NewFrame(env.ProtocolVersion, 0, request))
+			require.Nil(t, err)
+			protocolError, ok := response.Body.Message.(*message.ProtocolError)
+			require.True(t, ok, response.Body.Message)
+			require.Equal(t,
+				fmt.Sprintf("%v requests are not allowed before the client authenticated with the proxy",
+					request.GetOpCode()), protocolError.ErrorMessage)
+		})
+	}
+}
+
+func TestProxyClientJwtAuthentication(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+	dir := t.TempDir()
+
+	jwks, err := json.Marshal(map[string]interface{}{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": "test",
+		"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}}})
+	require.Nil(t, err)
+	jwksPath := filepath.Join(dir, "jwks.json")
+	require.Nil(t, os.WriteFile(jwksPath, jwks, 0600))
+
+	credentialsPath := filepath.Join(dir, "credentials.json")
+	require.Nil(t, os.WriteFile(credentialsPath, []byte(`{
+		"app": {"target": {"username": "cassandra", "password": "cassandra"}},
+		"invalid": {"target": {"username": "invalid", "password": "invalid"}}}`), 0600))
+
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		errMsg string
+	}{
+		{"valid token", map[string]interface{}{"role": "app"}, ""},
+		{"unmapped role", map[string]interface{}{"role": "other"},
+			"Provided username jwt and/or password are incorrect"},
+		{"expired token", map[string]interface{}{"role": "app", "exp": time.Now().Add(-time.Hour).Unix()},
+			"Provided username jwt and/or password are incorrect"},
+		{"role mapped to invalid cluster credentials", map[string]interface{}{"role": "invalid"},
+			"invalid credentials"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverConf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+			testSetup, err := setup.NewCqlServerTestSetup(t, serverConf, true, false, false)
+			require.Nil(t, err)
+			defer testSetup.Cleanup()
+
+			proxyConf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+			proxyConf.ProxyClientJwtJwksUrl = jwksPath
+			proxyConf.ProxyClientJwtIssuer = "https://issuer.example.com"
+			proxyConf.ProxyClientJwtCredentialsClaim = "role"
+			proxyConf.ProxyClientJwtCredentialsFile = credentialsPath
+			proxy, err := setup.NewProxyInstanceWithConfig(proxyConf)
+			require.Nil(t, err)
+			defer proxy.Shutdown()
+
+			claims := map[string]interface{}{"iss": "https://issuer.example.com", "exp": time.Now().Add(time.Hour).Unix()}
+			for name, value := range tt.claims {
+				claims[name] = value
+			}
+			testClient := client.NewCqlClient(
+				fmt.Sprintf("%s:%d", proxyConf.ProxyListenAddress, proxyConf.ProxyListenPort),
+				&client.AuthCredentials{Username: "jwt", Password: signRs256Jwt(t, key, claims)})
+			cqlConn, err := testClient.Connect(context.Background())
+			require.Nil(t, err)
+			defer cqlConn.Close()
+
+			err = cqlConn.InitiateHandshake(env.ProtocolVersion, 0)
+			if tt.errMsg == "" {
+				require.Nil(t, err)
+			} else {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			}
+		})
+	}
+}
+
+func signRs256Jwt(t *testing.T, key *rsa.PrivateKey, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "kid": "test"})
+	require.Nil(t, err)
+	payload, err := json.Marshal(claims)
+	require.Nil(t, err)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	require.Nil(t, err)
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
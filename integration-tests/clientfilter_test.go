@@ -0,0 +1,55 @@
+package integration_tests
+
+import (
+	"context"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestClientAddressFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed string
+		denied  string
+		success bool
+	}{
+		{"allowed", "127.0.0.0/8", "", true},
+		{"not allowed", "10.0.0.0/8", "", false},
+		{"denied", "", "127.0.0.1", false},
+		{"allowed and denied", "127.0.0.0/8", "127.0.0.1/32", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverConf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+			testSetup, err := setup.NewCqlServerTestSetup(t, serverConf, true, false, false)
+			require.Nil(t, err)
+			defer testSetup.Cleanup()
+
+			proxyConf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+			proxyConf.ProxyClientAllowedCidrs = tt.allowed
+			proxyConf.ProxyClientDeniedCidrs = tt.denied
+			proxy, err := setup.NewProxyInstanceWithConfig(proxyConf)
+			require.Nil(t, err)
+			defer proxy.Shutdown()
+
+			testClient := client.NewCqlClient(
+				fmt.Sprintf("%s:%d", proxyConf.ProxyListenAddress, proxyConf.ProxyListenPort),
+				&client.AuthCredentials{Username: serverConf.TargetUsername, Password: serverConf.TargetPassword})
+			cqlConn, err := testClient.Connect(context.Background())
+			require.Nil(t, err)
+			defer cqlConn.Close()
+
+			err = cqlConn.InitiateHandshake(env.ProtocolVersion, 0)
+			if tt.success {
+				require.Nil(t, err)
+			} else {
+				require.NotNil(t, err)
+			}
+		})
+	}
+}
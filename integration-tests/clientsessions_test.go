@@ -0,0 +1,115 @@
+package integration_tests
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/stretchr/testify/require"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestClientSessions(t *testing.T) {
+	conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+	testSetup.Origin.CqlServer.RequestHandlers = []client.RequestHandler{
+		client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+		client.NewSystemTablesHandler("cluster1", "dc1"), client.NewSetKeyspaceHandler(func(string) {})}
+	testSetup.Target.CqlServer.RequestHandlers = []client.RequestHandler{
+		client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+		client.NewSystemTablesHandler("cluster2", "dc2"), client.NewSetKeyspaceHandler(func(string) {})}
+
+	err = testSetup.Start(conf, true, env.ProtocolVersion)
+	require.Nil(t, err)
+
+	response, err := testSetup.Client.CqlConnection.SendAndReceive(
+		frame.NewFrame(env.ProtocolVersion, 5, &message.Query{Query: "USE ks1"}))
+	require.Nil(t, err)
+	require.Equal(t, &message.SetKeyspaceResult{Keyspace: "ks1"}, response.Body.Message)
+
+	sessions := testSetup.Proxy.GetClientSessions()
+	require.Len(t, sessions, 1)
+	require.Equal(t, testSetup.Client.CqlConnection.LocalAddr().String(), sessions[0].Client)
+	require.Equal(t, env.ProtocolVersion.String(), sessions[0].ProtocolVersion)
+	require.Equal(t, "ks1", sessions[0].Keyspace)
+	require.Equal(t, 0, sessions[0].InFlightRequests)
+	require.Greater(t, sessions[0].BytesReceived, uint64(0))
+	require.Greater(t, sessions[0].BytesSent, uint64(0))
+	require.Equal(t, uint64(0), sessions[0].Reads)
+	require.Equal(t, uint64(1), sessions[0].Writes)
+	require.Equal(t, uint64(0), sessions[0].Errors)
+	require.Greater(t, sessions[0].TotalLatencyMs, 0.0)
+
+	require.Nil(t, testSetup.Client.CqlConnection.Close())
+	require.Eventually(t, func() bool {
+		return len(testSetup.Proxy.GetClientSessions()) == 0
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestCloseClientSessions(t *testing.T) {
+	conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+	testSetup.Origin.CqlServer.RequestHandlers = []client.RequestHandler{
+		client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+		client.NewSystemTablesHandler("cluster1", "dc1"), newSlowQueryHandler()}
+	testSetup.Target.CqlServer.RequestHandlers = []client.RequestHandler{
+		client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+		client.NewSystemTablesHandler("cluster2", "dc2"), newSlowQueryHandler()}
+
+	err = testSetup.Start(conf, true, env.ProtocolVersion)
+	require.Nil(t, err)
+	cqlConn := testSetup.Client.CqlConnection
+
+	sessions := testSetup.Proxy.GetClientSessions()
+	require.Len(t, sessions, 1)
+	require.Empty(t, testSetup.Proxy.CloseClientSessions("127.0.0.2"))
+
+	inFlightRequest, err := cqlConn.Send(
+		frame.NewFrame(env.ProtocolVersion, 2, &message.Query{Query: "SELECT * FROM ks.slow"}))
+	require.Nil(t, err)
+	require.Eventually(t, func() bool {
+		return testSetup.Proxy.GetClientSessions()[0].InFlightRequests == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	closedSessions := testSetup.Proxy.CloseClientSessions(strconv.FormatUint(sessions[0].Id, 10))
+	require.Len(t, closedSessions, 1)
+	require.True(t, closedSessions[0].Closing)
+
+	// new requests are rejected while the pending ones are answered
+	response, err := cqlConn.SendAndReceive(
+		frame.NewFrame(env.ProtocolVersion, 3, &message.Query{Query: "SELECT * FROM ks.tbl"}))
+	require.Nil(t, err)
+	require.Equal(t, primitive.OpCodeError, response.Header.OpCode)
+	require.IsType(t, &message.Overloaded{}, response.Body.Message)
+
+	select {
+	case response = <-inFlightRequest.Incoming():
+		require.Equal(t, &message.VoidResult{}, response.Body.Message)
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the pending request")
+	}
+
+	require.Eventually(t, cqlConn.IsClosed, 5*time.Second, 50*time.Millisecond)
+	require.Eventually(t, func() bool {
+		return len(testSetup.Proxy.GetClientSessions()) == 0
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+func newSlowQueryHandler() client.RequestHandler {
+	return func(request *frame.Frame, conn *client.CqlServerConnection, ctx client.RequestHandlerContext) *frame.Frame {
+		query, ok := request.Body.Message.(*message.Query)
+		if !ok || query.Query != "SELECT * FROM ks.slow" {
+			return nil
+		}
+		time.Sleep(time.Second)
+		return frame.NewFrame(request.Header.Version, request.Header.StreamId, &message.VoidResult{})
+	}
+}
@@ -221,6 +221,121 @@ func TestReturnedProtocolVersionUnsupportedByProxy(t *testing.T) {
 	}
 }
 
+func TestProtocolVersionUnsupportedByOneCluster(t *testing.T) {
+	cfg := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	testSetup, err := setup.NewCqlServerTestSetup(t, cfg, false, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+
+	v3OnlyHandler := func(request *frame.Frame, _ *client2.CqlServerConnection, _ client2.RequestHandlerContext) *frame.Frame {
+		switch request.Body.Message.(type) {
+		case *message.Startup:
+			if request.Header.Version != primitive.ProtocolVersion3 {
+				return frame.NewFrame(primitive.ProtocolVersion3, request.Header.StreamId, &message.ProtocolError{
+					ErrorMessage: "Invalid or unsupported protocol version: 4"})
+			}
+		case *message.Options:
+			return frame.NewFrame(request.Header.Version, request.Header.StreamId, &message.Supported{
+				Options: map[string][]string{"PROTOCOL_VERSIONS": {"3/v3"}}})
+		}
+		return nil
+	}
+	testSetup.Origin.CqlServer.RequestHandlers = []client2.RequestHandler{
+		newOptionsHandlerWithOptions(map[string][]string{"PROTOCOL_VERSIONS": {"3/v3", "4/v4"}}),
+		client2.NewDriverConnectionInitializationHandler("origin", "dc1", func(_ string) {})}
+	testSetup.Target.CqlServer.RequestHandlers = []client2.RequestHandler{
+		v3OnlyHandler, client2.NewDriverConnectionInitializationHandler("target", "dc1", func(_ string) {})}
+
+	err = testSetup.Start(cfg, false, primitive.ProtocolVersion3)
+	require.Nil(t, err)
+
+	testClient, err := client.NewTestClient(context.Background(), "127.0.0.1:14002")
+	require.Nil(t, err)
+
+	rsp, _, err := testClient.SendRequest(context.Background(), frame.NewFrame(primitive.ProtocolVersion4, 0, message.NewStartup()))
+	require.Nil(t, err)
+	require.Equal(t, &message.ProtocolError{
+		ErrorMessage: "Invalid or unsupported protocol version (4); supported versions are (3/v3)"}, rsp.Body.Message)
+}
+
+func TestMaxClientProtocolVersion(t *testing.T) {
+	cfg := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	cfg.MaxClientProtocolVersion = "3"
+	testSetup, err := setup.NewCqlServerTestSetup(t, cfg, false, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+
+	options := map[string][]string{"PROTOCOL_VERSIONS": {"3/v3", "4/v4"}}
+	testSetup.Origin.CqlServer.RequestHandlers = []client2.RequestHandler{newOptionsHandlerWithOptions(options),
+		client2.NewDriverConnectionInitializationHandler("origin", "dc1", func(_ string) {})}
+	testSetup.Target.CqlServer.RequestHandlers = []client2.RequestHandler{newOptionsHandlerWithOptions(options),
+		client2.NewDriverConnectionInitializationHandler("target", "dc1", func(_ string) {})}
+
+	err = testSetup.Start(cfg, false, primitive.ProtocolVersion3)
+	require.Nil(t, err)
+
+	testClient, err := client.NewTestClient(context.Background(), "127.0.0.1:14002")
+	require.Nil(t, err)
+	rsp, _, err := testClient.SendRequest(context.Background(), frame.NewFrame(primitive.ProtocolVersion4, 0, message.NewStartup()))
+	require.Nil(t, err)
+	require.Equal(t, &message.ProtocolError{ErrorMessage: "Invalid or unsupported protocol version (4)"}, rsp.Body.Message)
+
+	testClient, err = client.NewTestClient(context.Background(), "127.0.0.1:14002")
+	require.Nil(t, err)
+	rsp, _, err = testClient.SendRequest(context.Background(), frame.NewFrame(primitive.ProtocolVersion3, 0, &message.Options{}))
+	require.Nil(t, err)
+	require.Equal(t, []string{"3/v3"}, rsp.Body.Message.(*message.Supported).Options["PROTOCOL_VERSIONS"])
+	rsp, _, err = testClient.SendRequest(context.Background(), frame.NewFrame(primitive.ProtocolVersion3, 0, message.NewStartup()))
+	require.Nil(t, err)
+	require.IsType(t, &message.Authenticate{}, rsp.Body.Message)
+}
+
+func TestUseBetaFlag(t *testing.T) {
+	cfg := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	testSetup, err := setup.NewCqlServerTestSetup(t, cfg, false, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+
+	betaFlagReceived := &atomic.Value{}
+	betaFlagReceived.Store(false)
+	betaFlagHandler := func(request *frame.Frame, _ *client2.CqlServerConnection, _ client2.RequestHandlerContext) *frame.Frame {
+		if _, ok := request.Body.Message.(*message.Startup); ok && request.Header.Flags.Contains(primitive.HeaderFlagUseBeta) {
+			betaFlagReceived.Store(true)
+		}
+		return nil
+	}
+	testSetup.Origin.CqlServer.RequestHandlers = []client2.RequestHandler{
+		newOptionsHandlerWithOptions(map[string][]string{"PROTOCOL_VERSIONS": {"3/v3", "4/v4", "5/v5-beta"}}),
+		betaFlagHandler, client2.NewDriverConnectionInitializationHandler("origin", "dc1", func(_ string) {})}
+	testSetup.Target.CqlServer.RequestHandlers = []client2.RequestHandler{
+		newOptionsHandlerWithOptions(map[string][]string{"PROTOCOL_VERSIONS": {"3/v3", "4/v4"}}),
+		betaFlagHandler, client2.NewDriverConnectionInitializationHandler("target", "dc1", func(_ string) {})}
+
+	err = testSetup.Start(cfg, false, primitive.ProtocolVersion3)
+	require.Nil(t, err)
+
+	// the flag of a version that is not beta is not forwarded
+	testClient, err := client.NewTestClient(context.Background(), "127.0.0.1:14002")
+	require.Nil(t, err)
+	request := frame.NewFrame(primitive.ProtocolVersion4, 0, message.NewStartup())
+	request.Header.Flags = request.Header.Flags.Add(primitive.HeaderFlagUseBeta)
+	rsp, _, err := testClient.SendRequest(context.Background(), request)
+	require.Nil(t, err)
+	require.IsType(t, &message.Authenticate{}, rsp.Body.Message)
+	require.False(t, betaFlagReceived.Load().(bool))
+
+	// a beta version of one cluster only is rejected by the proxy
+	testClient, err = client.NewTestClient(context.Background(), "127.0.0.1:14002")
+	require.Nil(t, err)
+	request = frame.NewFrame(primitive.ProtocolVersion5, 0, message.NewStartup())
+	request.Header.Flags = request.Header.Flags.Add(primitive.HeaderFlagUseBeta)
+	rsp, _, err = testClient.SendRequest(context.Background(), request)
+	require.Nil(t, err)
+	require.Equal(t, &message.ProtocolError{
+		ErrorMessage: "Beta version of the protocol used (5/v5-beta) is only supported by ORIGIN, TARGET doesn't support it"},
+		rsp.Body.Message)
+}
+
 func createFrameWithUnsupportedVersion(version primitive.ProtocolVersion, streamId int16, isResponse bool) ([]byte, error) {
 	mostSimilarVersion := primitive.ProtocolVersion4
 	if version > primitive.ProtocolVersionDse2 {
@@ -250,3 +365,32 @@ func createFrameWithUnsupportedVersion(version primitive.ProtocolVersion, stream
 	encoded[1] = 0
 	return encoded, nil
 }
+
+func TestContactPointPort(t *testing.T) {
+	serverConf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	serverConf.OriginPort = 19042
+	testSetup, err := setup.NewCqlServerTestSetup(t, serverConf, false, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+	err = testSetup.Start(nil, false, primitive.ProtocolVersion4)
+	require.Nil(t, err)
+
+	// the discovered origin node is reported with the default port, the proxy must use the one of the contact point
+	proxyConf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	proxyConf.OriginContactPoints = "127.0.1.1:19042"
+	proxy, err := setup.NewProxyInstanceWithConfig(proxyConf)
+	require.Nil(t, err)
+	defer proxy.Shutdown()
+
+	testClient, err := client.NewTestClient(context.Background(), "127.0.0.1:14002")
+	require.Nil(t, err)
+	err = testClient.PerformDefaultHandshake(context.Background(), primitive.ProtocolVersion4, true)
+	require.Nil(t, err)
+	query := frame.NewFrame(primitive.ProtocolVersion4, 0, &message.Query{
+		Query:   "SELECT * FROM system.peers",
+		Options: &message.QueryOptions{Consistency: primitive.ConsistencyLevelOne},
+	})
+	rsp, _, err := testClient.SendRequest(context.Background(), query)
+	require.Nil(t, err)
+	require.Equal(t, primitive.OpCodeResult, rsp.Header.OpCode, rsp.Body.Message)
+}
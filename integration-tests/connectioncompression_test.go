@@ -0,0 +1,77 @@
+package integration_tests
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConnectionCompression(t *testing.T) {
+	tests := []struct {
+		name               string
+		compression        string
+		expectedCompressed bool
+	}{
+		{"none", "NONE", false},
+		{"lz4", "LZ4", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+			conf.OriginConnectionCompression = tt.compression
+			conf.TargetConnectionCompression = tt.compression
+			testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+			require.Nil(t, err)
+			defer testSetup.Cleanup()
+			testSetup.Origin.CqlServer.RequestHandlers = []client.RequestHandler{
+				client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+				client.NewSystemTablesHandler("cluster1", "dc1"), newCompressionCheckHandler()}
+			testSetup.Target.CqlServer.RequestHandlers = []client.RequestHandler{
+				client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+				client.NewSystemTablesHandler("cluster2", "dc2"), newCompressionCheckHandler()}
+
+			// the client doesn't negotiate compression
+			err = testSetup.Start(conf, true, env.ProtocolVersion)
+			require.Nil(t, err)
+
+			for _, query := range []string{"SELECT * FROM ks.compression", "INSERT INTO ks.compression (k) VALUES (1)"} {
+				response, err := testSetup.Client.CqlConnection.SendAndReceive(
+					frame.NewFrame(env.ProtocolVersion, 0, &message.Query{Query: query}))
+				require.Nil(t, err)
+				require.False(t, response.Header.Flags.Contains(primitive.HeaderFlagCompressed))
+				rows, ok := response.Body.Message.(*message.RowsResult)
+				require.True(t, ok, response.Body.Message)
+				require.Equal(t, tt.expectedCompressed, rows.Data[0][0][0] == 1, query)
+			}
+		})
+	}
+}
+
+// newCompressionCheckHandler returns a single row with a boolean that tells whether the request was compressed
+func newCompressionCheckHandler() client.RequestHandler {
+	return func(request *frame.Frame, conn *client.CqlServerConnection, ctx client.RequestHandlerContext) *frame.Frame {
+		query, ok := request.Body.Message.(*message.Query)
+		if !ok {
+			return nil
+		}
+		switch query.Query {
+		case "SELECT * FROM ks.compression", "INSERT INTO ks.compression (k) VALUES (1)":
+		default:
+			return nil
+		}
+		compressed := byte(0)
+		if request.Header.Flags.Contains(primitive.HeaderFlagCompressed) {
+			compressed = 1
+		}
+		return frame.NewFrame(request.Header.Version, request.Header.StreamId, &message.RowsResult{
+			Metadata: &message.RowsMetadata{ColumnCount: 1},
+			Data:     message.RowSet{{{compressed}}},
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package integration_tests
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestClusterConnectionLossFailsInFlightRequests(t *testing.T) {
+	conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+	testSetup.Origin.CqlServer.RequestHandlers = []client.RequestHandler{
+		client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+		client.NewSystemTablesHandler("cluster1", "dc1"), newConnectionClosingQueryHandler()}
+	testSetup.Target.CqlServer.RequestHandlers = []client.RequestHandler{
+		client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+		client.NewSystemTablesHandler("cluster2", "dc2"), newDelayedQueryHandler()}
+
+	err = testSetup.Start(conf, true, env.ProtocolVersion)
+	require.Nil(t, err)
+
+	start := time.Now()
+	response, err := testSetup.Client.CqlConnection.SendAndReceive(
+		frame.NewFrame(env.ProtocolVersion, 0, &message.Query{Query: "SELECT * FROM ks.close_connection"}))
+	require.Nil(t, err)
+	require.Less(t, time.Since(start), 4*time.Second)
+	overloaded, ok := response.Body.Message.(*message.Overloaded)
+	require.True(t, ok, "expected OVERLOADED but got %v", response.Body.Message)
+	require.Equal(t, "Connection to the ORIGIN cluster was lost, please retry on next host.", overloaded.ErrorMessage)
+
+	require.Eventually(t, testSetup.Client.CqlConnection.IsClosed, 5*time.Second, 50*time.Millisecond)
+}
+
+// newConnectionClosingQueryHandler closes the connection instead of answering "SELECT * FROM ks.close_connection"
+func newConnectionClosingQueryHandler() client.RequestHandler {
+	return func(request *frame.Frame, conn *client.CqlServerConnection, ctx client.RequestHandlerContext) *frame.Frame {
+		query, ok := request.Body.Message.(*message.Query)
+		if !ok || query.Query != "SELECT * FROM ks.close_connection" {
+			return nil
+		}
+		// Close waits for the request handlers to return
+		go func() {
+			_ = conn.Close()
+		}()
+		return nil
+	}
+}
@@ -0,0 +1,50 @@
+package integration_tests
+
+import (
+	"context"
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/stretchr/testify/require"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestConnectionStormProtection(t *testing.T) {
+	conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	conf.ProxyMaxConcurrentHandshakes = 1
+	conf.ProxyHandshakeQueueTimeoutMs = 300
+	testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+
+	err = testSetup.Start(conf, false, env.ProtocolVersion)
+	require.Nil(t, err)
+
+	// holds the only handshake slot because it never sends STARTUP
+	idleConn, err := net.Dial("tcp", "127.0.0.1:14002")
+	require.Nil(t, err)
+	defer idleConn.Close()
+	time.Sleep(100 * time.Millisecond)
+
+	// waits in the queue until it's closed
+	before := time.Now()
+	_, err = testSetup.Client.CqlClient.ConnectAndInit(
+		context.Background(), env.ProtocolVersion, client.ManagedStreamId)
+	require.NotNil(t, err)
+	require.GreaterOrEqual(t, time.Since(before), 300*time.Millisecond)
+
+	// closing the idle connection releases its slot
+	require.Nil(t, idleConn.Close())
+	testClient, err := testSetup.Client.CqlClient.ConnectAndInit(
+		context.Background(), env.ProtocolVersion, client.ManagedStreamId)
+	require.Nil(t, err)
+	defer testClient.Close()
+
+	// the slot is released once the handshake succeeded
+	otherClient, err := testSetup.Client.CqlClient.ConnectAndInit(
+		context.Background(), env.ProtocolVersion, client.ManagedStreamId)
+	require.Nil(t, err)
+	defer otherClient.Close()
+}
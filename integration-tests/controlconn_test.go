@@ -465,7 +465,7 @@ func TestConnectionAssignment(t *testing.T) {
 			queryString := fmt.Sprintf("INSERT INTO testconnections_%d (a) VALUES ('a')", i)
 
 			openConnectionAndSendRequestFunc := func() {
-				cqlConn, err := testClient.ConnectAndInit(context.Background(), primitive.ProtocolVersion4, 1)
+				cqlConn, err := testClient.ConnectAndInit(context.Background(), env.ProtocolVersion, 1)
 				require.Nil(t, err, "testClient setup failed: %v", err)
 				defer cqlConn.Close()
 
@@ -474,7 +474,7 @@ func TestConnectionAssignment(t *testing.T) {
 					Options: nil,
 				}
 
-				queryFrame := frame.NewFrame(primitive.ProtocolVersion4, 5, queryMsg)
+				queryFrame := frame.NewFrame(env.ProtocolVersion, 5, queryMsg)
 				_, err = cqlConn.SendAndReceive(queryFrame)
 				require.Nil(t, err)
 			}
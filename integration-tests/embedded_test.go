@@ -0,0 +1,89 @@
+package integration_tests
+
+import (
+	"context"
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/datastax/zdm-proxy/proxy/pkg/embedded"
+	"github.com/datastax/zdm-proxy/proxy/pkg/health"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEmbeddedProxy(t *testing.T) {
+	conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	conf.ProxyListenPort = 0
+	testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+	err = testSetup.Start(nil, false, env.ProtocolVersion)
+	require.Nil(t, err)
+
+	proxy, err := embedded.Run(context.Background(), conf)
+	require.Nil(t, err)
+	defer proxy.Shutdown()
+
+	listenAddress := proxy.ListenAddress()
+	require.NotNil(t, listenAddress)
+	require.False(t, strings.HasSuffix(listenAddress.String(), ":0"))
+
+	credentials := &client.AuthCredentials{
+		Username: "cassandra",
+		Password: "cassandra",
+	}
+	cqlConn, err := client.NewCqlClient(listenAddress.String(), credentials).ConnectAndInit(
+		context.Background(), env.ProtocolVersion, client.ManagedStreamId)
+	require.Nil(t, err)
+	defer cqlConn.Close()
+	response, err := cqlConn.SendAndReceive(
+		frame.NewFrame(env.ProtocolVersion, 0, &message.Query{Query: "SELECT * FROM system.local"}))
+	require.Nil(t, err)
+	require.Equal(t, primitive.OpCodeResult, response.Header.OpCode)
+
+	require.Equal(t, health.UP, proxy.Health().Status)
+	require.NotNil(t, proxy.Metrics())
+
+	rsp := httptest.NewRecorder()
+	proxy.ReadinessHandler().ServeHTTP(rsp, httptest.NewRequest(http.MethodGet, "/health/readiness", nil))
+	require.Equal(t, http.StatusOK, rsp.Code)
+
+	rsp = httptest.NewRecorder()
+	proxy.MetricsHandler().ServeHTTP(rsp, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rsp.Code)
+	require.Contains(t, rsp.Body.String(), "zdm_proxy_")
+
+	proxy.Shutdown()
+	<-proxy.Done()
+	require.Nil(t, proxy.ListenAddress())
+	proxy.Shutdown()
+}
+
+func TestEmbeddedProxyContextCanceled(t *testing.T) {
+	conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+	err = testSetup.Start(nil, false, env.ProtocolVersion)
+	require.Nil(t, err)
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+	proxy, err := embedded.Run(ctx, conf)
+	require.Nil(t, err)
+	defer proxy.Shutdown()
+
+	cancelFn()
+	select {
+	case <-proxy.Done():
+	case <-time.After(10 * time.Second):
+		require.FailNow(t, "proxy was not shut down after the context was canceled")
+	}
+}
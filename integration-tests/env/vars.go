@@ -2,6 +2,8 @@ package env
 
 import (
 	"flag"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	log "github.com/sirupsen/logrus"
 	"math/rand"
 	"os"
 	"strconv"
@@ -9,10 +11,9 @@ import (
 	"time"
 )
 
-const (
-	OriginNodes = 1
-	TargetNodes = 1
-)
+// MaxNodesPerCluster is the maximum number of CCM nodes per test cluster, node addresses of different clusters are
+// 10 apart (e.g. 127.0.0.1 and 127.0.0.10).
+const MaxNodesPerCluster = 9
 
 var Rand = rand.New(rand.NewSource(time.Now().UTC().UnixNano()))
 var ServerVersion string
@@ -23,6 +24,15 @@ var RunCcmTests bool
 var RunMockTests bool
 var RunAllTlsTests bool
 var Debug bool
+var OriginNodes int
+var TargetNodes int
+
+// ProtocolVersion is the protocol version used by the test clients that connect to the proxy.
+var ProtocolVersion primitive.ProtocolVersion
+
+// SupportedProtocolVersions are the protocol versions that can be set with PROTOCOL_VERSION, the proxy doesn't support
+// protocol v5.
+var SupportedProtocolVersions = []primitive.ProtocolVersion{primitive.ProtocolVersion3, primitive.ProtocolVersion4}
 
 func InitGlobalVars() {
 	flags := map[string]interface{}{
@@ -51,6 +61,21 @@ func InitGlobalVars() {
 			getEnvironmentVariableOrDefault("RUN_ALL_TLS_TESTS", "false"),
 			"RUN_ALL_TLS_TESTS"),
 
+		"ORIGIN_NODES": flag.Int(
+			"ORIGIN_NODES",
+			getEnvironmentVariableIntOrDefault("ORIGIN_NODES", 1),
+			"ORIGIN_NODES"),
+
+		"TARGET_NODES": flag.Int(
+			"TARGET_NODES",
+			getEnvironmentVariableIntOrDefault("TARGET_NODES", 1),
+			"TARGET_NODES"),
+
+		"PROTOCOL_VERSION": flag.Int(
+			"PROTOCOL_VERSION",
+			getEnvironmentVariableIntOrDefault("PROTOCOL_VERSION", int(primitive.ProtocolVersion4)),
+			"PROTOCOL_VERSION"),
+
 		"DEBUG": flag.Bool(
 			"DEBUG",
 			getEnvironmentVariableBoolOrDefault("DEBUG", false),
@@ -65,6 +90,9 @@ func InitGlobalVars() {
 	runMockTests := *flags["RUN_MOCKTESTS"].(*string)
 	runAllTlsTests := *flags["RUN_ALL_TLS_TESTS"].(*string)
 	Debug = *flags["DEBUG"].(*bool)
+	OriginNodes = getNumberOfNodes(*flags["ORIGIN_NODES"].(*int))
+	TargetNodes = getNumberOfNodes(*flags["TARGET_NODES"].(*int))
+	ProtocolVersion = getProtocolVersion(*flags["PROTOCOL_VERSION"].(*int))
 
 	if DseVersion != "" {
 		IsDse = true
@@ -87,6 +115,26 @@ func InitGlobalVars() {
 	}
 }
 
+func getNumberOfNodes(nodes int) int {
+	if nodes < 1 {
+		return 1
+	}
+	if nodes > MaxNodesPerCluster {
+		return MaxNodesPerCluster
+	}
+	return nodes
+}
+
+func getProtocolVersion(version int) primitive.ProtocolVersion {
+	for _, v := range SupportedProtocolVersions {
+		if int(v) == version {
+			return v
+		}
+	}
+	log.Fatalf("Unsupported PROTOCOL_VERSION %v, it must be 3 or 4 (the proxy doesn't support protocol v5).", version)
+	return 0
+}
+
 func getEnvironmentVariableOrDefault(key string, defaultValue string) string {
 	if value, ok := os.LookupEnv(key); ok {
 		return value
@@ -107,3 +155,16 @@ func getEnvironmentVariableBoolOrDefault(key string, defaultValue bool) bool {
 		return defaultValue
 	}
 }
+
+func getEnvironmentVariableIntOrDefault(key string, defaultValue int) int {
+	if value, ok := os.LookupEnv(key); ok {
+		result, err := strconv.Atoi(value)
+		if err != nil {
+			return defaultValue
+		} else {
+			return result
+		}
+	} else {
+		return defaultValue
+	}
+}
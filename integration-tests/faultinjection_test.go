@@ -0,0 +1,69 @@
+package integration_tests
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/zdmproxy"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestFaultInjection(t *testing.T) {
+	conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	conf.FaultInjectionEnabled = true
+	testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+	testSetup.Origin.CqlServer.RequestHandlers = []client.RequestHandler{
+		client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+		client.NewSystemTablesHandler("cluster1", "dc1"), newDelayedQueryHandler()}
+	testSetup.Target.CqlServer.RequestHandlers = []client.RequestHandler{
+		client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+		client.NewSystemTablesHandler("cluster2", "dc2"), newDelayedQueryHandler()}
+
+	err = testSetup.Start(conf, true, env.ProtocolVersion)
+	require.Nil(t, err)
+	faultInjector := testSetup.Proxy.GetFaultInjector()
+	require.NotNil(t, faultInjector)
+
+	sendRead := func() (message.Message, time.Duration) {
+		start := time.Now()
+		response, err := testSetup.Client.CqlConnection.SendAndReceive(
+			frame.NewFrame(env.ProtocolVersion, 0, &message.Query{Query: "SELECT * FROM ks.delayed_200"}))
+		require.Nil(t, err)
+		return response.Body.Message, time.Since(start)
+	}
+
+	responseMsg, _ := sendRead()
+	require.Equal(t, &message.VoidResult{}, responseMsg)
+
+	// the reads are sent to origin, the faults of target don't affect them
+	require.Nil(t, faultInjector.SetFault(common.ClusterTypeTarget, &zdmproxy.Fault{Error: "SERVER_ERROR", Percent: 100}))
+	responseMsg, _ = sendRead()
+	require.Equal(t, &message.VoidResult{}, responseMsg)
+
+	require.Nil(t, faultInjector.SetFault(common.ClusterTypeOrigin, &zdmproxy.Fault{Error: "OVERLOADED", Percent: 100}))
+	responseMsg, _ = sendRead()
+	require.Equal(t, &message.Overloaded{ErrorMessage: "Error injected by the proxy (fault injection)"}, responseMsg)
+
+	require.Nil(t, faultInjector.SetFault(common.ClusterTypeOrigin, &zdmproxy.Fault{LatencyMs: 500, Percent: 100}))
+	responseMsg, elapsed := sendRead()
+	require.Equal(t, &message.VoidResult{}, responseMsg)
+	require.GreaterOrEqual(t, elapsed, 700*time.Millisecond)
+
+	// heartbeats are not affected
+	response, err := testSetup.Client.CqlConnection.SendAndReceive(
+		frame.NewFrame(env.ProtocolVersion, 0, &message.Options{}))
+	require.Nil(t, err)
+	require.IsType(t, &message.Supported{}, response.Body.Message)
+
+	faultInjector.ClearFault(common.ClusterTypeOrigin)
+	responseMsg, elapsed = sendRead()
+	require.Equal(t, &message.VoidResult{}, responseMsg)
+	require.Less(t, elapsed, 500*time.Millisecond)
+}
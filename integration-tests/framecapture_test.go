@@ -0,0 +1,54 @@
+package integration_tests
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/datastax/zdm-proxy/proxy/pkg/zdmproxy"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestFrameCapture(t *testing.T) {
+	conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	conf.FrameCaptureSize = 100
+	conf.FrameCaptureBodiesEnabled = true
+	testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+	testSetup.Origin.CqlServer.RequestHandlers = []client.RequestHandler{
+		client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+		client.NewSystemTablesHandler("cluster1", "dc1"), newDelayedQueryHandler()}
+	testSetup.Target.CqlServer.RequestHandlers = []client.RequestHandler{
+		client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+		client.NewSystemTablesHandler("cluster2", "dc2"), newDelayedQueryHandler()}
+
+	err = testSetup.Start(conf, true, env.ProtocolVersion)
+	require.Nil(t, err)
+
+	response, err := testSetup.Client.CqlConnection.SendAndReceive(
+		frame.NewFrame(env.ProtocolVersion, 5, &message.Query{Query: "SELECT * FROM ks.delayed_200"}))
+	require.Nil(t, err)
+	require.Equal(t, &message.VoidResult{}, response.Body.Message)
+
+	dumps, enabled := testSetup.Proxy.DumpFrameCaptures("127.0.0.1")
+	require.True(t, enabled)
+	require.Len(t, dumps, 1)
+	var queryFrames []*zdmproxy.CapturedFrame
+	for _, f := range dumps[0].Frames {
+		if f.StreamId == 5 {
+			queryFrames = append(queryFrames, f)
+		}
+	}
+	require.Len(t, queryFrames, 3)
+	require.Equal(t, zdmproxy.FrameDirectionClientRequest, queryFrames[0].Direction)
+	require.Equal(t, "QUERY SELECT * FROM ks.delayed_200 (0 values)", queryFrames[0].Body)
+	require.Equal(t, zdmproxy.FrameDirectionOriginResponse, queryFrames[1].Direction)
+	require.Equal(t, zdmproxy.FrameDirectionClientResponse, queryFrames[2].Direction)
+	require.Equal(t, "RESULT VOID", queryFrames[2].Body)
+
+	_, enabled = testSetup.Proxy.DumpFrameCaptures("127.0.0.2")
+	require.True(t, enabled)
+}
@@ -0,0 +1,50 @@
+package integration_tests
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFrameExport(t *testing.T) {
+	conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	conf.FrameExportDir = t.TempDir()
+	conf.FrameExportFormat = config.FrameExportFormatHex
+	testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+	testSetup.Origin.CqlServer.RequestHandlers = []client.RequestHandler{
+		client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+		client.NewSystemTablesHandler("cluster1", "dc1"), newDelayedQueryHandler()}
+	testSetup.Target.CqlServer.RequestHandlers = []client.RequestHandler{
+		client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+		client.NewSystemTablesHandler("cluster2", "dc2"), newDelayedQueryHandler()}
+
+	err = testSetup.Start(conf, true, env.ProtocolVersion)
+	require.Nil(t, err)
+
+	// the export is enabled for the existing connection of the client
+	frameExporter := testSetup.Proxy.GetFrameExporter()
+	require.NotNil(t, frameExporter)
+	require.Nil(t, frameExporter.Enable("127.0.0.1"))
+	response, err := testSetup.Client.CqlConnection.SendAndReceive(
+		frame.NewFrame(env.ProtocolVersion, 5, &message.Query{Query: "SELECT * FROM ks.delayed_200"}))
+	require.Nil(t, err)
+	require.Equal(t, &message.VoidResult{}, response.Body.Message)
+	require.Nil(t, frameExporter.Disable("127.0.0.1"))
+
+	files, err := filepath.Glob(filepath.Join(conf.FrameExportDir, "zdm-frames-127.0.0.1_*.txt"))
+	require.Nil(t, err)
+	require.Len(t, files, 1)
+	content, err := os.ReadFile(files[0])
+	require.Nil(t, err)
+	require.Contains(t, string(content), " CLIENT_REQUEST 127.0.0.1:")
+	require.Contains(t, string(content), " CLIENT_RESPONSE 127.0.0.1:")
+}
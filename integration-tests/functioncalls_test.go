@@ -10,6 +10,7 @@ import (
 	"github.com/datastax/go-cassandra-native-protocol/frame"
 	"github.com/datastax/go-cassandra-native-protocol/message"
 	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
 	"github.com/datastax/zdm-proxy/integration-tests/setup"
 	"github.com/datastax/zdm-proxy/integration-tests/simulacron"
 	"github.com/google/uuid"
@@ -153,7 +154,7 @@ func TestNowFunctionReplacementSimpleStatement(t *testing.T) {
 		defer simulacronSetup.Cleanup()
 
 		testClient := client.NewCqlClient("127.0.0.1:14002", nil)
-		cqlConn, err := testClient.ConnectAndInit(context.Background(), primitive.ProtocolVersion4, 1)
+		cqlConn, err := testClient.ConnectAndInit(context.Background(), env.ProtocolVersion, 1)
 		require.Nil(t, err, "testClient setup failed: %v", err)
 
 		defer cqlConn.Close()
@@ -165,7 +166,7 @@ func TestNowFunctionReplacementSimpleStatement(t *testing.T) {
 					Options: test.queryOpts,
 				}
 
-				f := frame.NewFrame(primitive.ProtocolVersion4, 2, queryMsg)
+				f := frame.NewFrame(env.ProtocolVersion, 2, queryMsg)
 				_, err := cqlConn.SendAndReceive(f)
 				require.Nil(tt, err)
 
@@ -1356,7 +1357,7 @@ func TestNowFunctionReplacementPreparedStatement(t *testing.T) {
 		defer simulacronSetup.Cleanup()
 
 		testClient := client.NewCqlClient("127.0.0.1:14002", nil)
-		cqlConn, err := testClient.ConnectAndInit(context.Background(), primitive.ProtocolVersion4, 1)
+		cqlConn, err := testClient.ConnectAndInit(context.Background(), env.ProtocolVersion, 1)
 		require.Nil(t, err, "testClient setup failed: %v", err)
 
 		defer cqlConn.Close()
@@ -1418,7 +1419,7 @@ func TestNowFunctionReplacementPreparedStatement(t *testing.T) {
 					Query: test.originalQuery,
 				}
 
-				f := frame.NewFrame(primitive.ProtocolVersion4, 0, queryMsg)
+				f := frame.NewFrame(env.ProtocolVersion, 0, queryMsg)
 				resp, err := cqlConn.SendAndReceive(f)
 				require.Nil(t, err)
 
@@ -1465,7 +1466,7 @@ func TestNowFunctionReplacementPreparedStatement(t *testing.T) {
 					ResultMetadataId: prepared.ResultMetadataId,
 					Options:          queryOpts,
 				}
-				f = frame.NewFrame(primitive.ProtocolVersion4, 0, executeMsg)
+				f = frame.NewFrame(env.ProtocolVersion, 0, executeMsg)
 				resp, err = cqlConn.SendAndReceive(f)
 				require.Nil(t, err)
 
@@ -1577,7 +1578,7 @@ func TestNowFunctionReplacementPreparedStatement(t *testing.T) {
 						ResultMetadataId: prepared.ResultMetadataId,
 						Options:          queryOptsNamed,
 					}
-					f = frame.NewFrame(primitive.ProtocolVersion4, 0, executeMsg)
+					f = frame.NewFrame(env.ProtocolVersion, 0, executeMsg)
 					_, err = cqlConn.SendAndReceive(f)
 					require.Nil(t, err)
 
@@ -2172,7 +2173,7 @@ func TestNowFunctionReplacementBatchStatement(t *testing.T) {
 		defer simulacronSetup.Cleanup()
 
 		testClient := client.NewCqlClient("127.0.0.1:14002", nil)
-		cqlConn, err := testClient.ConnectAndInit(context.Background(), primitive.ProtocolVersion4, 1)
+		cqlConn, err := testClient.ConnectAndInit(context.Background(), env.ProtocolVersion, 1)
 		require.Nil(t, err, "testClient setup failed: %v", err)
 
 		defer cqlConn.Close()
@@ -2254,7 +2255,7 @@ func TestNowFunctionReplacementBatchStatement(t *testing.T) {
 						if !p.isReplacedNow {
 							codec, err := datacodec.NewCodec(p.dataType)
 							require.Nil(t, err)
-							value, err := codec.Encode(p.value, primitive.ProtocolVersion4)
+							value, err := codec.Encode(p.value, env.ProtocolVersion)
 							require.Nil(t, err)
 							positionalValues = append(positionalValues, primitive.NewValue(value))
 						}
@@ -2280,7 +2281,7 @@ func TestNowFunctionReplacementBatchStatement(t *testing.T) {
 						prepareMsg := &message.Prepare{
 							Query: childStatement.originalQuery,
 						}
-						f := frame.NewFrame(primitive.ProtocolVersion4, 0, prepareMsg)
+						f := frame.NewFrame(env.ProtocolVersion, 0, prepareMsg)
 						resp, err := cqlConn.SendAndReceive(f)
 						require.Nil(t, err)
 						prepared, ok := resp.Body.Message.(*message.PreparedResult)
@@ -2303,7 +2304,7 @@ func TestNowFunctionReplacementBatchStatement(t *testing.T) {
 					Children: batchChildStatements,
 				}
 
-				f := frame.NewFrame(primitive.ProtocolVersion4, 0, batchMsg)
+				f := frame.NewFrame(env.ProtocolVersion, 0, batchMsg)
 				resp, err := cqlConn.SendAndReceive(f)
 				require.Nil(t, err)
 
@@ -0,0 +1,69 @@
+package integration_tests
+
+import (
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+// TestGuardrails tests that the writes that exceed a fail threshold of the guardrails are rejected by the proxy and
+// that the writes that exceed a warn threshold get a client warning
+func TestGuardrails(t *testing.T) {
+	conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	conf.GuardrailBatchStatementsWarn = 1
+	conf.GuardrailMutationSizeFailBytes = 200
+	conf.GuardrailClientWarningsEnabled = true
+	testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+	testSetup.Origin.CqlServer.RequestHandlers = append(testSetup.Origin.CqlServer.RequestHandlers, handleGuardedWrites)
+	testSetup.Target.CqlServer.RequestHandlers = append(testSetup.Target.CqlServer.RequestHandlers, handleGuardedWrites)
+	err = testSetup.Start(conf, true, env.ProtocolVersion)
+	require.Nil(t, err)
+	cqlConn := testSetup.Client.CqlConnection
+
+	insert := "INSERT INTO ks.tbl (pk, v) VALUES (1, 'v')"
+	response, err := cqlConn.SendAndReceive(frame.NewFrame(env.ProtocolVersion, 0, &message.Query{
+		Query:   insert,
+		Options: &message.QueryOptions{Consistency: primitive.ConsistencyLevelOne},
+	}))
+	require.Nil(t, err)
+	require.Equal(t, primitive.OpCodeResult, response.Body.Message.GetOpCode(), response.Body.Message)
+	require.Empty(t, response.Body.Warnings)
+
+	largeInsert := fmt.Sprintf("INSERT INTO ks.tbl (pk, v) VALUES (1, '%s')", strings.Repeat("v", 200))
+	response, err = cqlConn.SendAndReceive(frame.NewFrame(env.ProtocolVersion, 0, &message.Query{
+		Query:   largeInsert,
+		Options: &message.QueryOptions{Consistency: primitive.ConsistencyLevelOne},
+	}))
+	require.Nil(t, err)
+	require.IsType(t, &message.Invalid{}, response.Body.Message, response.Body.Message)
+	require.Contains(t, response.Body.Message.(*message.Invalid).ErrorMessage,
+		"exceeds the fail threshold of 200 bytes (ZDM_GUARDRAIL_MUTATION_SIZE_FAIL_BYTES)")
+
+	response, err = cqlConn.SendAndReceive(frame.NewFrame(env.ProtocolVersion, 0, &message.Batch{
+		Children:    []*message.BatchChild{{QueryOrId: insert}, {QueryOrId: insert}},
+		Consistency: primitive.ConsistencyLevelOne,
+	}))
+	require.Nil(t, err)
+	require.Equal(t, primitive.OpCodeResult, response.Body.Message.GetOpCode(), response.Body.Message)
+	require.Equal(t, []string{
+		"Batch of 2 statements exceeds the warn threshold of 1 statements (ZDM_GUARDRAIL_BATCH_STATEMENTS_WARN)"},
+		response.Body.Warnings)
+}
+
+func handleGuardedWrites(request *frame.Frame, _ *client.CqlServerConnection, _ client.RequestHandlerContext) *frame.Frame {
+	switch request.Body.Message.(type) {
+	case *message.Query, *message.Batch:
+		return frame.NewFrame(request.Header.Version, request.Header.StreamId, &message.VoidResult{})
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,51 @@
+package integration_tests
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestHeavyHitters(t *testing.T) {
+	conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	conf.HeavyHittersSize = 10
+	testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+	testSetup.Origin.CqlServer.RequestHandlers = []client.RequestHandler{
+		client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+		client.NewSystemTablesHandler("cluster1", "dc1"), newDelayedQueryHandler()}
+	testSetup.Target.CqlServer.RequestHandlers = []client.RequestHandler{
+		client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+		client.NewSystemTablesHandler("cluster2", "dc2"), newDelayedQueryHandler()}
+
+	err = testSetup.Start(conf, true, env.ProtocolVersion)
+	require.Nil(t, err)
+
+	for i := 0; i < 3; i++ {
+		response, err := testSetup.Client.CqlConnection.SendAndReceive(
+			frame.NewFrame(env.ProtocolVersion, 5, &message.Query{Query: "SELECT * FROM ks.delayed_200"}))
+		require.Nil(t, err)
+		require.Equal(t, &message.VoidResult{}, response.Body.Message)
+	}
+
+	heavyHitters, _, enabled := testSetup.Proxy.GetHeavyHitters()
+	require.True(t, enabled)
+	origin := heavyHitters[common.ClusterTypeOrigin]
+	require.Len(t, origin.ByCount, 1)
+	require.Equal(t, "select * from ks.delayed_200", origin.ByCount[0].Statement)
+	require.Equal(t, uint64(3), origin.ByCount[0].Count)
+	require.GreaterOrEqual(t, origin.ByCount[0].TotalLatencyMs, 600.0)
+	require.Equal(t, origin.ByCount[0].Fingerprint, origin.ByLatency[0].Fingerprint)
+	// reads are only sent to origin
+	require.Empty(t, heavyHitters[common.ClusterTypeTarget].ByCount)
+
+	require.True(t, testSetup.Proxy.ResetHeavyHitters())
+	heavyHitters, _, _ = testSetup.Proxy.GetHeavyHitters()
+	require.Empty(t, heavyHitters[common.ClusterTypeOrigin].ByCount)
+}
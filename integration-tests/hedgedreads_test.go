@@ -0,0 +1,98 @@
+package integration_tests
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/datatype"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+const hedgedReadQuery = "SELECT * FROM ks.hedged"
+
+func TestHedgedReads(t *testing.T) {
+	tests := []struct {
+		name           string
+		originDelay    time.Duration
+		originError    bool
+		targetDelay    time.Duration
+		targetError    bool
+		expectedResult string
+		expectedError  bool
+	}{
+		{name: "origin slower", originDelay: 500 * time.Millisecond, expectedResult: "target"},
+		{name: "target slower", targetDelay: 500 * time.Millisecond, expectedResult: "origin"},
+		{name: "target error", targetError: true, originDelay: 200 * time.Millisecond, expectedResult: "origin"},
+		{name: "origin error", originError: true, targetDelay: 200 * time.Millisecond, expectedResult: "target"},
+		{name: "both error", originError: true, targetError: true, expectedError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+			conf.ReadMode = config.ReadModeHedged
+			testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+			require.Nil(t, err)
+			defer testSetup.Cleanup()
+			testSetup.Origin.CqlServer.RequestHandlers = []client.RequestHandler{
+				client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler, client.NewSystemTablesHandler("cluster1", "dc1"),
+				newHedgedReadHandler("origin", tt.originDelay, tt.originError)}
+			testSetup.Target.CqlServer.RequestHandlers = []client.RequestHandler{
+				client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler, client.NewSystemTablesHandler("cluster2", "dc2"),
+				newHedgedReadHandler("target", tt.targetDelay, tt.targetError)}
+
+			err = testSetup.Start(conf, true, env.ProtocolVersion)
+			require.Nil(t, err)
+
+			// send the same stream id multiple times to make sure late responses are not returned for the next read
+			for i := 0; i < 3; i++ {
+				request := frame.NewFrame(env.ProtocolVersion, 10, &message.Query{Query: hedgedReadQuery})
+				response, err := testSetup.Client.CqlConnection.SendAndReceive(request)
+				require.Nil(t, err)
+				if tt.expectedError {
+					require.IsType(t, &message.Invalid{}, response.Body.Message)
+					require.Equal(t, "origin", response.Body.Message.(*message.Invalid).ErrorMessage)
+					continue
+				}
+				require.IsType(t, &message.RowsResult{}, response.Body.Message)
+				rows := response.Body.Message.(*message.RowsResult)
+				require.Equal(t, 1, len(rows.Data))
+				require.Equal(t, tt.expectedResult, string(rows.Data[0][0]))
+			}
+		})
+	}
+}
+
+func newHedgedReadHandler(cluster string, delay time.Duration, returnError bool) client.RequestHandler {
+	return func(
+		request *frame.Frame,
+		conn *client.CqlServerConnection,
+		ctx client.RequestHandlerContext,
+	) (response *frame.Frame) {
+		query, ok := request.Body.Message.(*message.Query)
+		if !ok || query.Query != hedgedReadQuery {
+			return nil
+		}
+		time.Sleep(delay)
+		var msg message.Message
+		if returnError {
+			msg = &message.Invalid{ErrorMessage: cluster}
+		} else {
+			msg = &message.RowsResult{
+				Metadata: &message.RowsMetadata{
+					ColumnCount: 1,
+					Columns: []*message.ColumnMetadata{
+						{Keyspace: "ks", Table: "hedged", Name: "cluster", Type: datatype.Varchar},
+					},
+				},
+				Data: message.RowSet{message.Row{message.Column(cluster)}},
+			}
+		}
+		return frame.NewFrame(request.Header.Version, request.Header.StreamId, msg)
+	}
+}
@@ -0,0 +1,89 @@
+package integration_tests
+
+import (
+	"context"
+	"errors"
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/datastax/zdm-proxy/proxy/pkg/embedded"
+	"github.com/datastax/zdm-proxy/proxy/pkg/zdmproxy"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+// testInterceptor rejects DELETE statements and answers queries on the "local" keyspace without forwarding them.
+type testInterceptor struct {
+	codec frame.RawCodec
+}
+
+func (recv *testInterceptor) OnRequest(
+	_ *zdmproxy.InterceptedConnection, request *frame.RawFrame) (*frame.RawFrame, *frame.RawFrame, error) {
+	if request.Header.OpCode != primitive.OpCodeQuery {
+		return request, nil, nil
+	}
+	decoded, err := recv.codec.ConvertFromRawFrame(request)
+	if err != nil {
+		return nil, nil, err
+	}
+	query := decoded.Body.Message.(*message.Query).Query
+	if strings.HasPrefix(query, "DELETE") {
+		return nil, nil, errors.New("DELETE is not allowed")
+	}
+	if strings.Contains(query, "local.") {
+		response, err := recv.codec.ConvertToRawFrame(
+			frame.NewFrame(request.Header.Version, 0, &message.SetKeyspaceResult{Keyspace: "local"}))
+		return nil, response, err
+	}
+	return request, nil, nil
+}
+
+func (recv *testInterceptor) OnResponse(
+	_ *zdmproxy.InterceptedConnection, _ *frame.RawFrame, response *frame.RawFrame) (*frame.RawFrame, error) {
+	return response, nil
+}
+
+func TestRequestInterceptor(t *testing.T) {
+	conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+	err = testSetup.Start(nil, false, env.ProtocolVersion)
+	require.Nil(t, err)
+
+	proxy, err := embedded.RunWithExtensions(context.Background(), conf, &zdmproxy.Extensions{
+		RequestInterceptors: []zdmproxy.RequestInterceptor{&testInterceptor{codec: frame.NewRawCodec()}},
+	})
+	require.Nil(t, err)
+	defer proxy.Shutdown()
+
+	credentials := &client.AuthCredentials{
+		Username: "cassandra",
+		Password: "cassandra",
+	}
+	cqlConn, err := client.NewCqlClient(proxy.ListenAddress().String(), credentials).ConnectAndInit(
+		context.Background(), env.ProtocolVersion, client.ManagedStreamId)
+	require.Nil(t, err)
+	defer cqlConn.Close()
+
+	response, err := cqlConn.SendAndReceive(
+		frame.NewFrame(env.ProtocolVersion, 0, &message.Query{Query: "SELECT * FROM system.local"}))
+	require.Nil(t, err)
+	require.Equal(t, primitive.OpCodeResult, response.Header.OpCode)
+
+	response, err = cqlConn.SendAndReceive(
+		frame.NewFrame(env.ProtocolVersion, 0, &message.Query{Query: "SELECT * FROM local.tb"}))
+	require.Nil(t, err)
+	require.Equal(t, &message.SetKeyspaceResult{Keyspace: "local"}, response.Body.Message)
+
+	response, err = cqlConn.SendAndReceive(
+		frame.NewFrame(env.ProtocolVersion, 0, &message.Query{Query: "DELETE FROM ks.tb WHERE k = 1"}))
+	require.Nil(t, err)
+	serverError, ok := response.Body.Message.(*message.ServerError)
+	require.True(t, ok, response.Body.Message)
+	require.Equal(t, "Request rejected by the proxy: DELETE is not allowed", serverError.ErrorMessage)
+}
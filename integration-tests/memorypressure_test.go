@@ -0,0 +1,56 @@
+package integration_tests
+
+import (
+	"context"
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestMemoryPressureLoadShedding(t *testing.T) {
+	conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	// the heap of the test process is always above this limit so the proxy starts shedding load after the first check
+	conf.ProxyMemorySoftLimitMb = 1
+	conf.ProxyMemoryCheckIntervalMs = 1000
+	testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+	testSetup.Origin.CqlServer.RequestHandlers = []client.RequestHandler{
+		client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+		client.NewSystemTablesHandler("cluster1", "dc1"), newDelayedQueryHandler()}
+	testSetup.Target.CqlServer.RequestHandlers = []client.RequestHandler{
+		client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+		client.NewSystemTablesHandler("cluster2", "dc2"), newDelayedQueryHandler()}
+
+	err = testSetup.Start(conf, true, env.ProtocolVersion)
+	require.Nil(t, err)
+
+	var lastResponse message.Message
+	require.Eventually(t, func() bool {
+		response, err := testSetup.Client.CqlConnection.SendAndReceive(
+			frame.NewFrame(env.ProtocolVersion, 0, &message.Query{Query: "SELECT * FROM ks.delayed_200"}))
+		require.Nil(t, err)
+		lastResponse = response.Body.Message
+		_, overloaded := lastResponse.(*message.Overloaded)
+		return overloaded
+	}, 10*time.Second, 100*time.Millisecond)
+	require.Equal(t, &message.Overloaded{
+		ErrorMessage: "Memory usage of the proxy is too high, please retry on next host."}, lastResponse)
+
+	// heartbeats are not rejected
+	response, err := testSetup.Client.CqlConnection.SendAndReceive(
+		frame.NewFrame(env.ProtocolVersion, 0, &message.Options{}))
+	require.Nil(t, err)
+	require.IsType(t, &message.Supported{}, response.Body.Message)
+
+	newClient := client.NewCqlClient("127.0.0.1:14002", nil)
+	ctx, cancelFn := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFn()
+	_, err = newClient.ConnectAndInit(ctx, env.ProtocolVersion, 0)
+	require.NotNil(t, err)
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/datastax/zdm-proxy/proxy/pkg/config"
 	"github.com/datastax/zdm-proxy/proxy/pkg/httpzdmproxy"
 	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	"github.com/datastax/zdm-proxy/proxy/pkg/runner"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
@@ -42,8 +43,14 @@ var nodeMetrics = []metrics.Metric{
 	metrics.OpenOriginConnections,
 	metrics.OpenTargetConnections,
 
+	metrics.InFlightRequestsOrigin,
+	metrics.InFlightRequestsTarget,
+
 	metrics.OriginUsedStreamIds,
 	metrics.TargetUsedStreamIds,
+
+	metrics.OriginStuckConnections,
+	metrics.TargetStuckConnections,
 }
 
 var proxyMetrics = []metrics.Metric{
@@ -65,6 +72,27 @@ var proxyMetrics = []metrics.Metric{
 	metrics.InFlightWrites,
 
 	metrics.OpenClientConnections,
+
+	metrics.OpenOriginControlConnections,
+	metrics.OpenTargetControlConnections,
+
+	metrics.MemoryPressureRejectedConnections,
+	metrics.MemoryPressureRejectedRequests,
+
+	metrics.ClientAddressRejectedConnections,
+
+	metrics.WriteQueueOverflowRejectedRequests,
+	metrics.StreamedResponses,
+	metrics.RateLimitedRequests,
+	metrics.GlobalRateLimitedRequests,
+
+	metrics.MutationExportDropped,
+	metrics.TargetWriteSamplingSkippedWrites,
+
+	metrics.FleetLeader,
+
+	metrics.BuildInfo,
+	metrics.ConfigInfo,
 }
 
 var allMetrics = append(proxyMetrics, nodeMetrics...)
@@ -206,7 +234,7 @@ func containsLine(lines []string, line string) bool {
 func startMetricsHandler(
 	t *testing.T, conf *config.Config, wg *sync.WaitGroup, metricsHandler *httpzdmproxy.HandlerWithFallback) *http.Server {
 	httpAddr := fmt.Sprintf("%s:%d", conf.MetricsAddress, conf.MetricsPort)
-	srv := httpzdmproxy.StartHttpServer(httpAddr, wg)
+	srv := httpzdmproxy.StartHttpServer(httpAddr, nil, wg)
 	require.NotNil(t, srv)
 	metricsHandler.SetHandler(promhttp.Handler())
 	return srv
@@ -288,6 +316,11 @@ func checkMetrics(
 	require.Contains(t, lines, fmt.Sprintf("%v 0", getPrometheusName(prefix, metrics.InFlightReadsOrigin)))
 	require.Contains(t, lines, fmt.Sprintf("%v 0", getPrometheusName(prefix, metrics.InFlightReadsTarget)))
 
+	require.Contains(t, lines, fmt.Sprintf("%v 1", getPrometheusName(prefix, metrics.OpenOriginControlConnections)))
+	require.Contains(t, lines, fmt.Sprintf("%v 1", getPrometheusName(prefix, metrics.OpenTargetControlConnections)))
+
+	require.Contains(t, lines, fmt.Sprintf("%v 1", getPrometheusName(prefix, metrics.BuildInfo)))
+
 	if successOrigin == 0 {
 		require.Contains(t, lines, fmt.Sprintf("%v 0", getPrometheusNameWithSuffix(prefix, metrics.ProxyReadsOriginDuration, "sum")))
 	} else {
@@ -330,6 +363,12 @@ func checkMetrics(
 		require.Contains(t, lines, fmt.Sprintf("%v{node=\"%v\"} %v", getPrometheusName(prefix, metrics.OpenOriginConnections), originHost, openOriginConns))
 		require.Contains(t, lines, fmt.Sprintf("%v{node=\"%v\"} %v", getPrometheusName(prefix, metrics.OpenTargetConnections), targetHost, openTargetConns))
 
+		require.Contains(t, lines, fmt.Sprintf("%v 0", getPrometheusNameWithNodeLabel(prefix, metrics.InFlightRequestsOrigin, originHost)))
+		require.Contains(t, lines, fmt.Sprintf("%v 0", getPrometheusNameWithNodeLabel(prefix, metrics.InFlightRequestsTarget, targetHost)))
+
+		require.Contains(t, lines, fmt.Sprintf("%v 0", getPrometheusNameWithNodeLabel(prefix, metrics.OriginStuckConnections, originHost)))
+		require.Contains(t, lines, fmt.Sprintf("%v 0", getPrometheusNameWithNodeLabel(prefix, metrics.TargetStuckConnections, targetHost)))
+
 		if asyncEnabled {
 			requireEventuallyContainsLine(t, lines, fmt.Sprintf("%v{node=\"%v\"} %v", getPrometheusName(prefix, metrics.OpenAsyncConnections), asyncHost, openAsyncConns))
 			requireEventuallyContainsLine(t, lines, fmt.Sprintf("%v 0", getPrometheusNameWithNodeLabel(prefix, metrics.AsyncReadTimeouts, asyncHost)))
@@ -519,3 +558,68 @@ func getPrometheusNameWithSuffixAndNodeLabel(prefix string, mn metrics.Metric, s
 func getPrometheusNameWithSuffix(prefix string, mn metrics.Metric, suffix string) string {
 	return getPrometheusNameWithSuffixAndNodeLabel(prefix, mn, suffix, "")
 }
+
+func TestRoutingMetrics(t *testing.T) {
+	metricsHandler, _ := runner.SetupHandlers()
+
+	tests := []struct {
+		name          string
+		readMode      string
+		expectedAsync uint64
+	}{
+		{name: "primary only", readMode: config.ReadModePrimaryOnly, expectedAsync: 0},
+		{name: "dual reads, async on secondary", readMode: config.ReadModeDualAsyncOnSecondary, expectedAsync: 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+			conf.ReadMode = test.readMode
+			testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+			require.Nil(t, err)
+			defer testSetup.Cleanup()
+			testSetup.Origin.CqlServer.RequestHandlers = []client.RequestHandler{client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler, client.NewSystemTablesHandler("cluster1", "dc1"), handleReads, handleWrites}
+			testSetup.Target.CqlServer.RequestHandlers = []client.RequestHandler{client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler, client.NewSystemTablesHandler("cluster2", "dc2"), handleReads, handleWrites}
+			err = testSetup.Start(conf, true, primitive.ProtocolVersion4)
+			require.Nil(t, err)
+
+			wg := &sync.WaitGroup{}
+			defer wg.Wait()
+			srv := startMetricsHandler(t, conf, wg, metricsHandler)
+			defer func() {
+				err := srv.Close()
+				if err != nil {
+					log.Warnf("error cleaning http server: %v", err)
+				}
+			}()
+			EnsureMetricsServerListening(t, conf)
+
+			asserter := utils.NewMetricsAsserter(t, fmt.Sprintf("%s:%d", conf.MetricsAddress, conf.MetricsPort), conf.MetricsPrefix)
+			asserter.Reset()
+			for i := 0; i < 3; i++ {
+				_, err = testSetup.Client.CqlConnection.SendAndReceive(insertQuery)
+				require.Nil(t, err)
+			}
+			for i := 0; i < 2; i++ {
+				_, err = testSetup.Client.CqlConnection.SendAndReceive(selectQuery)
+				require.Nil(t, err)
+			}
+
+			// the writes are sent to both clusters, the reads to the primary cluster (and to the secondary one
+			// asynchronously with DUAL_ASYNC_ON_SECONDARY)
+			asserter.RequireHistogramCount(metrics.OriginRequestDuration, nil, 5)
+			asserter.RequireHistogramCount(metrics.TargetRequestDuration, map[string]string{"node": "127.0.1.2:9042"}, 3)
+			asserter.RequireHistogramCount(metrics.AsyncRequestDuration, nil, test.expectedAsync)
+			asserter.RequireHistogramCount(metrics.ProxyWritesDuration, nil, 3)
+			asserter.RequireHistogramCount(metrics.ProxyReadsOriginDuration, nil, 2)
+			asserter.RequireHistogramCount(metrics.ProxyReadsTargetDuration, nil, 0)
+			asserter.RequireValue(metrics.FailedWritesOnTarget, nil, 0)
+
+			asserter.Reset()
+			_, err = testSetup.Client.CqlConnection.SendAndReceive(insertQuery)
+			require.Nil(t, err)
+			asserter.RequireHistogramCount(metrics.OriginRequestDuration, nil, 1)
+			asserter.RequireHistogramCount(metrics.TargetRequestDuration, nil, 1)
+			asserter.RequireValue(metrics.OpenClientConnections, nil, 0)
+		})
+	}
+}
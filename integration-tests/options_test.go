@@ -1,10 +1,12 @@
 package integration_tests
 
 import (
+	"context"
+	"fmt"
 	"github.com/datastax/go-cassandra-native-protocol/client"
 	"github.com/datastax/go-cassandra-native-protocol/frame"
 	"github.com/datastax/go-cassandra-native-protocol/message"
-	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
 	"github.com/datastax/zdm-proxy/integration-tests/setup"
 	"github.com/stretchr/testify/require"
 	"testing"
@@ -19,10 +21,10 @@ func TestOptionsShouldComeFromTarget(t *testing.T) {
 	testSetup.Origin.CqlServer.RequestHandlers = []client.RequestHandler{client.RegisterHandler, newOptionsHandler("origin"), client.HandshakeHandler, client.NewSystemTablesHandler("cluster2", "dc2")}
 	testSetup.Target.CqlServer.RequestHandlers = []client.RequestHandler{client.RegisterHandler, newOptionsHandler("target"), client.HandshakeHandler, client.NewSystemTablesHandler("cluster1", "dc1")}
 
-	err = testSetup.Start(conf, true, primitive.ProtocolVersion4)
+	err = testSetup.Start(conf, true, env.ProtocolVersion)
 	require.Nil(t, err)
 
-	request := frame.NewFrame(primitive.ProtocolVersion4, client.ManagedStreamId, &message.Options{})
+	request := frame.NewFrame(env.ProtocolVersion, client.ManagedStreamId, &message.Options{})
 	response, err := testSetup.Client.CqlConnection.SendAndReceive(request)
 	require.Nil(t, err)
 	require.IsType(t, &message.Supported{}, response.Body.Message)
@@ -31,7 +33,105 @@ func TestOptionsShouldComeFromTarget(t *testing.T) {
 
 }
 
+func TestOptionsShouldNotContainScyllaExtensions(t *testing.T) {
+
+	conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+	scyllaOptions := map[string][]string{
+		"CQL_VERSION":             {"3.3.1"},
+		"SCYLLA_SHARD":            {"0"},
+		"SCYLLA_NR_SHARDS":        {"4"},
+		"SCYLLA_PARTITIONER":      {"org.apache.cassandra.dht.Murmur3Partitioner"},
+		"SCYLLA_SHARD_AWARE_PORT": {"19042"},
+	}
+	testSetup.Origin.CqlServer.RequestHandlers = []client.RequestHandler{client.RegisterHandler, newOptionsHandler("origin"), client.HandshakeHandler, client.NewSystemTablesHandler("cluster2", "dc2")}
+	testSetup.Target.CqlServer.RequestHandlers = []client.RequestHandler{client.RegisterHandler, newOptionsHandlerWithOptions(scyllaOptions), client.HandshakeHandler, client.NewSystemTablesHandler("cluster1", "dc1")}
+
+	err = testSetup.Start(conf, true, env.ProtocolVersion)
+	require.Nil(t, err)
+
+	request := frame.NewFrame(env.ProtocolVersion, client.ManagedStreamId, &message.Options{})
+	response, err := testSetup.Client.CqlConnection.SendAndReceive(request)
+	require.Nil(t, err)
+	require.IsType(t, &message.Supported{}, response.Body.Message)
+	supported := response.Body.Message.(*message.Supported)
+	require.Equal(t, map[string][]string{"CQL_VERSION": {"3.3.1"}}, supported.Options)
+}
+
+func TestOptionsDuringHandshakeShouldBeMerged(t *testing.T) {
+
+	conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+	originOptions := map[string][]string{
+		"CQL_VERSION":       {"3.4.4"},
+		"COMPRESSION":       {"snappy", "lz4"},
+		"PROTOCOL_VERSIONS": {"3/v3", "4/v4"},
+	}
+	targetOptions := map[string][]string{
+		"CQL_VERSION":       {"3.4.5"},
+		"COMPRESSION":       {"lz4"},
+		"PROTOCOL_VERSIONS": {"3/v3", "4/v4", "5/v5"},
+		"PAGE_UNIT":         {"bytes"},
+	}
+	testSetup.Origin.CqlServer.RequestHandlers = []client.RequestHandler{client.RegisterHandler, newOptionsHandlerWithOptions(originOptions), client.HandshakeHandler, client.NewSystemTablesHandler("cluster2", "dc2")}
+	testSetup.Target.CqlServer.RequestHandlers = []client.RequestHandler{client.RegisterHandler, newOptionsHandlerWithOptions(targetOptions), client.HandshakeHandler, client.NewSystemTablesHandler("cluster1", "dc1")}
+
+	err = testSetup.Start(conf, false, env.ProtocolVersion)
+	require.Nil(t, err)
+
+	testClient := client.NewCqlClient(fmt.Sprintf("%s:%d", conf.ProxyListenAddress, conf.ProxyListenPort),
+		&client.AuthCredentials{Username: "cassandra", Password: "cassandra"})
+	cqlConn, err := testClient.Connect(context.Background())
+	require.Nil(t, err)
+	defer cqlConn.Close()
+
+	request := frame.NewFrame(env.ProtocolVersion, 0, &message.Options{})
+	response, err := cqlConn.SendAndReceive(request)
+	require.Nil(t, err)
+	require.IsType(t, &message.Supported{}, response.Body.Message)
+	supported := response.Body.Message.(*message.Supported)
+	require.Equal(t, map[string][]string{
+		"CQL_VERSION":       {"3.4.4"},
+		"COMPRESSION":       {"lz4"},
+		"PROTOCOL_VERSIONS": {"3/v3", "4/v4"},
+	}, supported.Options)
+
+	err = cqlConn.InitiateHandshake(env.ProtocolVersion, 0)
+	require.Nil(t, err)
+}
+
+func TestHeartbeatLocalResponse(t *testing.T) {
+
+	conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	conf.HeartbeatLocalResponseEnabled = true
+	testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+	originOptions := map[string][]string{"CQL_VERSION": {"3.4.4"}, "FROM": {"origin"}}
+	targetOptions := map[string][]string{"CQL_VERSION": {"3.4.5"}, "FROM": {"target"}}
+	testSetup.Origin.CqlServer.RequestHandlers = []client.RequestHandler{client.RegisterHandler, newOptionsHandlerWithOptions(originOptions), client.HandshakeHandler, client.NewSystemTablesHandler("cluster2", "dc2")}
+	testSetup.Target.CqlServer.RequestHandlers = []client.RequestHandler{client.RegisterHandler, newOptionsHandlerWithOptions(targetOptions), client.HandshakeHandler, client.NewSystemTablesHandler("cluster1", "dc1")}
+
+	err = testSetup.Start(conf, true, env.ProtocolVersion)
+	require.Nil(t, err)
+
+	request := frame.NewFrame(env.ProtocolVersion, client.ManagedStreamId, &message.Options{})
+	response, err := testSetup.Client.CqlConnection.SendAndReceive(request)
+	require.Nil(t, err)
+	require.IsType(t, &message.Supported{}, response.Body.Message)
+	supported := response.Body.Message.(*message.Supported)
+	require.Equal(t, map[string][]string{"CQL_VERSION": {"3.4.4"}}, supported.Options)
+}
+
 func newOptionsHandler(from string) client.RequestHandler {
+	return newOptionsHandlerWithOptions(map[string][]string{"FROM": {from}})
+}
+
+func newOptionsHandlerWithOptions(options map[string][]string) client.RequestHandler {
 	return func(
 		request *frame.Frame,
 		conn *client.CqlServerConnection,
@@ -41,7 +141,7 @@ func newOptionsHandler(from string) client.RequestHandler {
 			response = frame.NewFrame(
 				request.Header.Version,
 				request.Header.StreamId,
-				&message.Supported{Options: map[string][]string{"FROM": {from}}},
+				&message.Supported{Options: options},
 			)
 		}
 		return
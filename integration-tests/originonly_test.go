@@ -0,0 +1,104 @@
+package integration_tests
+
+import (
+	"context"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/zdm-proxy/integration-tests/cqlserver"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/datastax/zdm-proxy/integration-tests/utils"
+	"github.com/datastax/zdm-proxy/proxy/pkg/journal"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestOriginOnlyFallback tests that with the ORIGIN_ONLY target down policy the writes are only sent to origin and
+// journaled while target is down, and that the proxy switches back to dual writes once target is up again
+func TestOriginOnlyFallback(t *testing.T) {
+	journalFile := filepath.Join(t.TempDir(), "target.journal")
+	conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	conf.TargetDownPolicy = "ORIGIN_ONLY"
+	conf.OriginOnlyJournalFile = journalFile
+	conf.HeartbeatIntervalMs = 200
+	conf.HeartbeatRetryIntervalMaxMs = 200
+	// the client connections that are opened before target is considered DOWN fail fast
+	conf.TargetConnectionTimeoutMs = 1000
+	testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+	testSetup.Origin.CqlServer.RequestHandlers = append(testSetup.Origin.CqlServer.RequestHandlers, handleWrites)
+	testSetup.Target.CqlServer.RequestHandlers = append(testSetup.Target.CqlServer.RequestHandlers, handleWrites)
+
+	err = testSetup.Start(conf, false, env.ProtocolVersion)
+	require.Nil(t, err)
+
+	insert := func() (*frame.Frame, error) {
+		testClient, err := testSetup.Client.CqlClient.ConnectAndInit(
+			context.Background(), env.ProtocolVersion, client.ManagedStreamId)
+		if err != nil {
+			return nil, err
+		}
+		defer testClient.Close()
+		return testClient.SendAndReceive(
+			frame.NewFrame(env.ProtocolVersion, 0, &message.Query{Query: "INSERT INTO ks.tbl (pk) VALUES (1)"}))
+	}
+
+	response, err := insert()
+	require.Nil(t, err)
+	require.Equal(t, &message.VoidResult{}, response.Body.Message)
+	require.Empty(t, response.Body.Warnings)
+
+	require.Nil(t, testSetup.Target.Close())
+
+	utils.RequireWithRetries(t, func() (err error, fatal bool) {
+		response, err := insert()
+		if err != nil {
+			return fmt.Errorf("expected the proxy to serve the client with origin only: %w", err), false
+		}
+		if len(response.Body.Warnings) == 0 {
+			return fmt.Errorf("expected an origin-only warning"), false
+		}
+		require.Equal(t, &message.VoidResult{}, response.Body.Message)
+		require.Contains(t, response.Body.Warnings[0], "journaled for TARGET")
+		return nil, false
+	}, 50, 200*time.Millisecond)
+
+	entries, err := journal.ReadFile(journalFile)
+	require.Nil(t, err)
+	require.Len(t, entries, 1)
+	require.Equal(t, []string{"INSERT INTO ks.tbl (pk) VALUES (1)"}, entries[0].Statements)
+
+	target, err := cqlserver.NewCqlServerCluster(
+		conf.TargetContactPoints, conf.TargetPort, conf.TargetUsername, conf.TargetPassword, false)
+	require.Nil(t, err)
+	target.CqlServer.RequestHandlers = append(target.CqlServer.RequestHandlers, handleWrites)
+	require.Nil(t, target.Start())
+	testSetup.Target = target
+
+	utils.RequireWithRetries(t, func() (err error, fatal bool) {
+		response, err := insert()
+		if err != nil {
+			return fmt.Errorf("expected the proxy to serve the client: %w", err), false
+		}
+		if len(response.Body.Warnings) != 0 {
+			return fmt.Errorf("expected dual writes once target is up again"), false
+		}
+		return nil, false
+	}, 50, 200*time.Millisecond)
+
+	// the writes are no longer journaled
+	entries, err = journal.ReadFile(journalFile)
+	require.Nil(t, err)
+	journaled := len(entries)
+	response, err = insert()
+	require.Nil(t, err)
+	require.Empty(t, response.Body.Warnings)
+	entries, err = journal.ReadFile(journalFile)
+	require.Nil(t, err)
+	require.Len(t, entries, journaled)
+}
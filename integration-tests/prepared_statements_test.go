@@ -3,6 +3,7 @@ package integration_tests
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	client2 "github.com/datastax/go-cassandra-native-protocol/client"
 	"github.com/datastax/go-cassandra-native-protocol/datatype"
@@ -10,6 +11,7 @@ import (
 	"github.com/datastax/go-cassandra-native-protocol/message"
 	"github.com/datastax/go-cassandra-native-protocol/primitive"
 	"github.com/datastax/zdm-proxy/integration-tests/client"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
 	"github.com/datastax/zdm-proxy/integration-tests/setup"
 	"github.com/datastax/zdm-proxy/integration-tests/simulacron"
 	"github.com/datastax/zdm-proxy/integration-tests/utils"
@@ -18,6 +20,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/require"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -33,7 +36,7 @@ func TestPreparedIdProxyCacheMiss(t *testing.T) {
 
 	defer testClient.Shutdown()
 
-	err = testClient.PerformDefaultHandshake(context.Background(), primitive.ProtocolVersion4, false)
+	err = testClient.PerformDefaultHandshake(context.Background(), env.ProtocolVersion, false)
 	require.True(t, err == nil, "No-auth handshake failed: %s", err)
 
 	preparedId := []byte{143, 7, 36, 50, 225, 104, 157, 89, 199, 177, 239, 231, 82, 201, 142, 253}
@@ -42,7 +45,7 @@ func TestPreparedIdProxyCacheMiss(t *testing.T) {
 		QueryId:          preparedId,
 		ResultMetadataId: nil,
 	}
-	response, requestStreamId, err := testClient.SendMessage(context.Background(), primitive.ProtocolVersion4, executeMsg)
+	response, requestStreamId, err := testClient.SendMessage(context.Background(), env.ProtocolVersion, executeMsg)
 	require.True(t, err == nil, "execute request send failed: %s", err)
 	require.True(t, response != nil, "response received was null")
 
@@ -63,6 +66,81 @@ func TestPreparedIdProxyCacheMiss(t *testing.T) {
 
 }
 
+func TestPrepareCoalescing(t *testing.T) {
+	conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	conf.PrepareCoalescingEnabled = true
+	testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+
+	preparedId := []byte{143, 7, 36, 50, 225, 104, 157, 89, 199, 177, 239, 231, 82, 201, 142, 253}
+	releasePrepares := make(chan struct{})
+	newDelayedPrepareHandler := func(prepareCount *int32) client2.RequestHandler {
+		return func(request *frame.Frame, _ *client2.CqlServerConnection, _ client2.RequestHandlerContext) *frame.Frame {
+			if request.Header.OpCode != primitive.OpCodePrepare {
+				return nil
+			}
+			atomic.AddInt32(prepareCount, 1)
+			<-releasePrepares
+			return frame.NewFrame(request.Header.Version, request.Header.StreamId,
+				&message.PreparedResult{PreparedQueryId: preparedId})
+		}
+	}
+	var originPrepares, targetPrepares int32
+	testSetup.Origin.CqlServer.RequestHandlers = []client2.RequestHandler{
+		client2.NewDriverConnectionInitializationHandler("origin", "dc1", func(_ string) {}),
+		newDelayedPrepareHandler(&originPrepares)}
+	testSetup.Target.CqlServer.RequestHandlers = []client2.RequestHandler{
+		client2.NewDriverConnectionInitializationHandler("target", "dc1", func(_ string) {}),
+		newDelayedPrepareHandler(&targetPrepares)}
+
+	err = testSetup.Start(conf, false, env.ProtocolVersion)
+	require.Nil(t, err)
+
+	const clientCount = 5
+	responses := make(chan *frame.Frame, clientCount)
+	errs := make(chan error, clientCount)
+	for i := 0; i < clientCount; i++ {
+		cqlConn, err := client2.NewCqlClient(
+			"127.0.0.1:14002", &client2.AuthCredentials{Username: conf.TargetUsername, Password: conf.TargetPassword}).ConnectAndInit(
+			context.Background(), env.ProtocolVersion, client2.ManagedStreamId)
+		require.Nil(t, err)
+		defer cqlConn.Close()
+		go func() {
+			response, err := cqlConn.SendAndReceive(
+				frame.NewFrame(env.ProtocolVersion, client2.ManagedStreamId, &message.Prepare{Query: "SELECT * FROM ks.tbl"}))
+			if err != nil {
+				errs <- err
+				return
+			}
+			responses <- response
+		}()
+	}
+
+	utils.RequireWithRetries(t, func() (err error, fatal bool) {
+		if atomic.LoadInt32(&originPrepares) == 0 || atomic.LoadInt32(&targetPrepares) == 0 {
+			return errors.New("the PREPARE was not received by both clusters yet"), false
+		}
+		return nil, false
+	}, 50, 100*time.Millisecond)
+	// let the other PREPAREs reach the proxy before the first one is answered
+	time.Sleep(500 * time.Millisecond)
+	close(releasePrepares)
+
+	for i := 0; i < clientCount; i++ {
+		select {
+		case response := <-responses:
+			preparedResult, ok := response.Body.Message.(*message.PreparedResult)
+			require.True(t, ok, "prepared result was type %T", response.Body.Message)
+			require.Equal(t, preparedId, preparedResult.PreparedQueryId)
+		case err := <-errs:
+			require.Nil(t, err)
+		}
+	}
+	require.Equal(t, int32(1), atomic.LoadInt32(&originPrepares))
+	require.Equal(t, int32(1), atomic.LoadInt32(&targetPrepares))
+}
+
 func TestPreparedIdPreparationMismatch(t *testing.T) {
 
 	simulacronSetup, err := setup.NewSimulacronTestSetup(t)
@@ -74,7 +152,7 @@ func TestPreparedIdPreparationMismatch(t *testing.T) {
 
 	defer testClient.Shutdown()
 
-	err = testClient.PerformDefaultHandshake(context.Background(), primitive.ProtocolVersion4, false)
+	err = testClient.PerformDefaultHandshake(context.Background(), env.ProtocolVersion, false)
 	require.True(t, err == nil, "No-auth handshake failed: %s", err)
 
 	tests := map[string]struct {
@@ -138,7 +216,7 @@ func TestPreparedIdPreparationMismatch(t *testing.T) {
 				Keyspace: "",
 			}
 
-			response, requestStreamId, err := testClient.SendMessage(context.Background(), primitive.ProtocolVersion4, prepareMsg)
+			response, requestStreamId, err := testClient.SendMessage(context.Background(), env.ProtocolVersion, prepareMsg)
 			require.True(t, err == nil, "prepare request send failed: %s", err)
 
 			preparedResponse, ok := response.Body.Message.(*message.PreparedResult)
@@ -153,7 +231,7 @@ func TestPreparedIdPreparationMismatch(t *testing.T) {
 				ResultMetadataId: preparedResponse.ResultMetadataId,
 			}
 
-			response, requestStreamId, err = testClient.SendMessage(context.Background(), primitive.ProtocolVersion4, executeMsg)
+			response, requestStreamId, err = testClient.SendMessage(context.Background(), env.ProtocolVersion, executeMsg)
 			require.True(t, err == nil, "execute request send failed: %s", err)
 
 			if test.expectedUnprepared {
@@ -329,7 +407,7 @@ func TestPreparedIdReplacement(t *testing.T) {
 					test.expectedBatchQuery, targetPreparedId, targetBatchPreparedId, targetKey, targetValue, map[string]interface{}{}, false,
 					test.expectedVariables, test.expectedBatchPreparedStmtVariables, dualReadsEnabled && test.read)}
 
-			err = testSetup.Start(conf, true, primitive.ProtocolVersion4)
+			err = testSetup.Start(conf, true, env.ProtocolVersion)
 			require.Nil(t, err)
 
 			prepareMsg := &message.Prepare{
@@ -342,7 +420,7 @@ func TestPreparedIdReplacement(t *testing.T) {
 			}
 
 			prepareResp, err := testSetup.Client.CqlConnection.SendAndReceive(
-				frame.NewFrame(primitive.ProtocolVersion4, 10, prepareMsg))
+				frame.NewFrame(env.ProtocolVersion, 10, prepareMsg))
 			require.Nil(t, err)
 
 			preparedResult, ok := prepareResp.Body.Message.(*message.PreparedResult)
@@ -358,7 +436,7 @@ func TestPreparedIdReplacement(t *testing.T) {
 				expectedBatchPrepareMsg = batchPrepareMsg.Clone().(*message.Prepare)
 				expectedBatchPrepareMsg.Query = test.expectedBatchQuery
 				prepareResp, err = testSetup.Client.CqlConnection.SendAndReceive(
-					frame.NewFrame(primitive.ProtocolVersion4, 10, batchPrepareMsg))
+					frame.NewFrame(env.ProtocolVersion, 10, batchPrepareMsg))
 				require.Nil(t, err)
 
 				preparedResult, ok = prepareResp.Body.Message.(*message.PreparedResult)
@@ -374,7 +452,7 @@ func TestPreparedIdReplacement(t *testing.T) {
 			}
 
 			executeResp, err := testSetup.Client.CqlConnection.SendAndReceive(
-				frame.NewFrame(primitive.ProtocolVersion4, 20, executeMsg))
+				frame.NewFrame(env.ProtocolVersion, 20, executeMsg))
 			require.Nil(t, err)
 
 			rowsResult, ok := executeResp.Body.Message.(*message.RowsResult)
@@ -410,7 +488,7 @@ func TestPreparedIdReplacement(t *testing.T) {
 				}
 
 				batchResp, err := testSetup.Client.CqlConnection.SendAndReceive(
-					frame.NewFrame(primitive.ProtocolVersion4, 30, batchMsg))
+					frame.NewFrame(env.ProtocolVersion, 30, batchMsg))
 				require.Nil(t, err)
 
 				batchResult, ok := batchResp.Body.Message.(*message.VoidResult)
@@ -655,7 +733,7 @@ func TestUnpreparedIdReplacement(t *testing.T) {
 					test.batchQuery, targetPreparedId, targetBatchPreparedId, targetKey, targetValue, targetCtx, test.targetUnprepared,
 					nil, nil, dualReadsEnabled && test.read)}
 
-			err = testSetup.Start(conf, true, primitive.ProtocolVersion4)
+			err = testSetup.Start(conf, true, env.ProtocolVersion)
 			require.Nil(t, err)
 
 			prepareMsg := &message.Prepare{
@@ -664,7 +742,7 @@ func TestUnpreparedIdReplacement(t *testing.T) {
 			}
 
 			prepareResp, err := testSetup.Client.CqlConnection.SendAndReceive(
-				frame.NewFrame(primitive.ProtocolVersion4, 10, prepareMsg))
+				frame.NewFrame(env.ProtocolVersion, 10, prepareMsg))
 			require.Nil(t, err)
 
 			preparedResult, ok := prepareResp.Body.Message.(*message.PreparedResult)
@@ -679,7 +757,7 @@ func TestUnpreparedIdReplacement(t *testing.T) {
 			}
 
 			executeResp, err := testSetup.Client.CqlConnection.SendAndReceive(
-				frame.NewFrame(primitive.ProtocolVersion4, 20, executeMsg))
+				frame.NewFrame(env.ProtocolVersion, 20, executeMsg))
 			require.Nil(t, err)
 
 			unPreparedResult, ok := executeResp.Body.Message.(*message.Unprepared)
@@ -688,7 +766,7 @@ func TestUnpreparedIdReplacement(t *testing.T) {
 			require.Equal(t, originPreparedId, unPreparedResult.Id)
 
 			prepareResp, err = testSetup.Client.CqlConnection.SendAndReceive(
-				frame.NewFrame(primitive.ProtocolVersion4, 10, prepareMsg))
+				frame.NewFrame(env.ProtocolVersion, 10, prepareMsg))
 			require.Nil(t, err)
 
 			preparedResult, ok = prepareResp.Body.Message.(*message.PreparedResult)
@@ -697,7 +775,7 @@ func TestUnpreparedIdReplacement(t *testing.T) {
 			require.Equal(t, originPreparedId, preparedResult.PreparedQueryId)
 
 			executeResp, err = testSetup.Client.CqlConnection.SendAndReceive(
-				frame.NewFrame(primitive.ProtocolVersion4, 20, executeMsg))
+				frame.NewFrame(env.ProtocolVersion, 20, executeMsg))
 			require.Nil(t, err)
 
 			rowsResult, ok := executeResp.Body.Message.(*message.RowsResult)
@@ -709,7 +787,7 @@ func TestUnpreparedIdReplacement(t *testing.T) {
 				batchPrepareMsg = prepareMsg.Clone().(*message.Prepare)
 				batchPrepareMsg.Query = test.batchQuery
 				prepareResp, err = testSetup.Client.CqlConnection.SendAndReceive(
-					frame.NewFrame(primitive.ProtocolVersion4, 10, batchPrepareMsg))
+					frame.NewFrame(env.ProtocolVersion, 10, batchPrepareMsg))
 				require.Nil(t, err)
 
 				preparedResult, ok = prepareResp.Body.Message.(*message.PreparedResult)
@@ -739,7 +817,7 @@ func TestUnpreparedIdReplacement(t *testing.T) {
 				}
 
 				batchResp, err := testSetup.Client.CqlConnection.SendAndReceive(
-					frame.NewFrame(primitive.ProtocolVersion4, 30, batchMsg))
+					frame.NewFrame(env.ProtocolVersion, 30, batchMsg))
 				require.Nil(t, err)
 
 				unPreparedResult, ok := batchResp.Body.Message.(*message.Unprepared)
@@ -748,7 +826,7 @@ func TestUnpreparedIdReplacement(t *testing.T) {
 				require.Equal(t, originBatchPreparedId, unPreparedResult.Id)
 
 				prepareResp, err = testSetup.Client.CqlConnection.SendAndReceive(
-					frame.NewFrame(primitive.ProtocolVersion4, 10, batchPrepareMsg))
+					frame.NewFrame(env.ProtocolVersion, 10, batchPrepareMsg))
 				require.Nil(t, err)
 
 				preparedResult, ok = prepareResp.Body.Message.(*message.PreparedResult)
@@ -757,7 +835,7 @@ func TestUnpreparedIdReplacement(t *testing.T) {
 				require.Equal(t, originBatchPreparedId, preparedResult.PreparedQueryId)
 
 				batchResp, err = testSetup.Client.CqlConnection.SendAndReceive(
-					frame.NewFrame(primitive.ProtocolVersion4, 30, batchMsg))
+					frame.NewFrame(env.ProtocolVersion, 30, batchMsg))
 				require.Nil(t, err)
 
 				batchResult, ok := batchResp.Body.Message.(*message.VoidResult)
@@ -0,0 +1,83 @@
+package integration_tests
+
+import (
+	"context"
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/datastax/zdm-proxy/proxy/pkg/embedded"
+	"github.com/datastax/zdm-proxy/proxy/pkg/zdmproxy"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type testReadRepairVerifier struct {
+	repairer zdmproxy.TargetRepairer
+}
+
+func (recv *testReadRepairVerifier) ReadVerificationStats() (uint64, uint64) {
+	return 0, 0
+}
+
+func (recv *testReadRepairVerifier) SetTargetRepairer(repairer zdmproxy.TargetRepairer) {
+	recv.repairer = repairer
+}
+
+func TestReadRepairToTarget(t *testing.T) {
+	conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	conf.ReadRepairToTargetEnabled = true
+	testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+
+	var inserts []*message.Query
+	insertsLock := &sync.Mutex{}
+	insertHandler := func(request *frame.Frame, _ *client.CqlServerConnection, _ client.RequestHandlerContext) *frame.Frame {
+		query, ok := request.Body.Message.(*message.Query)
+		if !ok || !strings.HasPrefix(query.Query, "INSERT") {
+			return nil
+		}
+		insertsLock.Lock()
+		inserts = append(inserts, query)
+		insertsLock.Unlock()
+		return frame.NewFrame(request.Header.Version, request.Header.StreamId, &message.VoidResult{})
+	}
+	testSetup.Target.CqlServer.RequestHandlers = []client.RequestHandler{
+		insertHandler, client.NewDriverConnectionInitializationHandler("target", "dc1", func(_ string) {})}
+	err = testSetup.Start(nil, false, env.ProtocolVersion)
+	require.Nil(t, err)
+
+	_, err = embedded.RunWithExtensions(context.Background(), conf, &zdmproxy.Extensions{})
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "ZDM_READ_REPAIR_TO_TARGET_ENABLED is true but there is no read verifier")
+
+	verifier := &testReadRepairVerifier{}
+	proxy, err := embedded.RunWithExtensions(context.Background(), conf, &zdmproxy.Extensions{ReadVerifier: verifier})
+	require.Nil(t, err)
+	defer proxy.Shutdown()
+	require.NotNil(t, verifier.repairer)
+
+	err = verifier.repairer(context.Background(), &zdmproxy.RowRepair{
+		Keyspace:   "ks",
+		Table:      "tb",
+		PrimaryKey: []*zdmproxy.RepairedColumn{{Name: "id", Value: []byte{0, 0, 0, 1}}},
+		Columns: []*zdmproxy.RepairedColumn{
+			{Name: "a", Value: []byte("x"), WriteTime: 1000},
+			{Name: "b", Value: []byte("y"), WriteTime: 2000},
+		},
+	})
+	require.Nil(t, err)
+
+	insertsLock.Lock()
+	defer insertsLock.Unlock()
+	require.Equal(t, 2, len(inserts))
+	require.Equal(t, `INSERT INTO "ks"."tb" ("id", "a") VALUES (?, ?)`, inserts[0].Query)
+	require.Equal(t, int64(1000), inserts[0].Options.DefaultTimestamp.Value)
+	require.Equal(t, []byte("x"), inserts[0].Options.PositionalValues[1].Contents)
+	require.Equal(t, `INSERT INTO "ks"."tb" ("id", "b") VALUES (?, ?)`, inserts[1].Query)
+	require.Equal(t, int64(2000), inserts[1].Options.DefaultTimestamp.Value)
+}
@@ -0,0 +1,82 @@
+package integration_tests
+
+import (
+	"bytes"
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/datatype"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestStreamingOfLargeResults(t *testing.T) {
+	conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	conf.ResponseStreamingThresholdBytes = 1024
+	testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+	testSetup.Origin.CqlServer.RequestHandlers = []client.RequestHandler{
+		client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+		client.NewSystemTablesHandler("cluster1", "dc1"), newLargeResultHandler()}
+	testSetup.Target.CqlServer.RequestHandlers = []client.RequestHandler{
+		client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+		client.NewSystemTablesHandler("cluster2", "dc2"), newLargeResultHandler()}
+
+	err = testSetup.Start(conf, true, env.ProtocolVersion)
+	require.Nil(t, err)
+
+	for _, query := range []string{"SELECT * FROM ks.large", "SELECT * FROM ks.small", "SELECT * FROM ks.large"} {
+		response, err := testSetup.Client.CqlConnection.SendAndReceive(
+			frame.NewFrame(env.ProtocolVersion, 0, &message.Query{Query: query}))
+		require.Nil(t, err)
+		require.Equal(t, largeResultRows(query), response.Body.Message, query)
+	}
+
+	// the streamed responses are accounted like the buffered ones
+	sessions := testSetup.Proxy.GetClientSessions()
+	require.Len(t, sessions, 1)
+	require.Equal(t, uint64(3), sessions[0].Reads)
+	require.Equal(t, uint64(0), sessions[0].Errors)
+}
+
+func newLargeResultHandler() client.RequestHandler {
+	return func(request *frame.Frame, conn *client.CqlServerConnection, ctx client.RequestHandlerContext) *frame.Frame {
+		query, ok := request.Body.Message.(*message.Query)
+		if !ok {
+			return nil
+		}
+		result := largeResultRows(query.Query)
+		if result == nil {
+			return nil
+		}
+		return frame.NewFrame(request.Header.Version, request.Header.StreamId, result)
+	}
+}
+
+func largeResultRows(query string) *message.RowsResult {
+	var rowCount int
+	switch query {
+	case "SELECT * FROM ks.large":
+		rowCount = 1000
+	case "SELECT * FROM ks.small":
+		rowCount = 1
+	default:
+		return nil
+	}
+	rows := message.RowSet{}
+	for i := 0; i < rowCount; i++ {
+		rows = append(rows, message.Row{bytes.Repeat([]byte{byte(i)}, 100)})
+	}
+	return &message.RowsResult{
+		Metadata: &message.RowsMetadata{
+			ColumnCount: 1,
+			Columns: []*message.ColumnMetadata{
+				{Keyspace: "ks", Table: "large", Name: "value", Type: datatype.Blob},
+			},
+		},
+		Data: rows,
+	}
+}
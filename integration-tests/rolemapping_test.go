@@ -0,0 +1,61 @@
+package integration_tests
+
+import (
+	"context"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRoleMapping(t *testing.T) {
+	roleMappingFile := filepath.Join(t.TempDir(), "roles.json")
+	require.Nil(t, os.WriteFile(roleMappingFile, []byte(`{"app_user": {"target": {"username": "app-user"}}}`), 0600))
+
+	tests := []struct {
+		name            string
+		roleMappingFile string
+		success         bool
+	}{
+		{"client role mapped to target role", roleMappingFile, true},
+		{"without role mapping", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serverConf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+			serverConf.OriginUsername, serverConf.OriginPassword = "originUser", "originPassword"
+			serverConf.TargetUsername, serverConf.TargetPassword = "app-user", "appPassword"
+			testSetup, err := setup.NewCqlServerTestSetup(t, serverConf, true, false, false)
+			require.Nil(t, err)
+			defer testSetup.Cleanup()
+
+			proxyConf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+			proxyConf.OriginUsername, proxyConf.OriginPassword = "originUser", "originPassword"
+			proxyConf.TargetUsername, proxyConf.TargetPassword = "app-user", "appPassword"
+			proxyConf.RoleMappingFile = tt.roleMappingFile
+			proxy, err := setup.NewProxyInstanceWithConfig(proxyConf)
+			require.Nil(t, err)
+			defer proxy.Shutdown()
+
+			// the client uses the role naming convention of ORIGIN with the TARGET password
+			testClient := client.NewCqlClient(
+				fmt.Sprintf("%s:%d", proxyConf.ProxyListenAddress, proxyConf.ProxyListenPort),
+				&client.AuthCredentials{Username: "app_user", Password: "appPassword"})
+			cqlConn, err := testClient.Connect(context.Background())
+			require.Nil(t, err)
+			defer cqlConn.Close()
+
+			err = cqlConn.InitiateHandshake(env.ProtocolVersion, 0)
+			if tt.success {
+				require.Nil(t, err)
+			} else {
+				require.NotNil(t, err)
+			}
+		})
+	}
+}
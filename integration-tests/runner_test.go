@@ -10,6 +10,7 @@ import (
 	"github.com/datastax/zdm-proxy/proxy/pkg/httpzdmproxy"
 	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
 	"github.com/datastax/zdm-proxy/proxy/pkg/runner"
+	"github.com/datastax/zdm-proxy/proxy/pkg/zdmproxy"
 	"github.com/stretchr/testify/require"
 	"net/http"
 	"sync"
@@ -127,12 +128,18 @@ func testHttpEndpointsWithProxyInitialized(
 		CurrentFailureCount:   0,
 		FailureCountThreshold: conf.HeartbeatFailureThreshold,
 		Status:                health.UP,
+		HealthState:           zdmproxy.ClusterHealthUp,
+		HealthReason:          "connected",
+		HealthSince:           report.OriginStatus.HealthSince,
 	}, report.OriginStatus)
 	require.Equal(t, &health.ControlConnStatus{
 		Addr:                  fmt.Sprintf("%s:%d", simulacronSetup.Target.GetInitialContactPoint(), 9042),
 		CurrentFailureCount:   0,
 		FailureCountThreshold: conf.HeartbeatFailureThreshold,
 		Status:                health.UP,
+		HealthState:           zdmproxy.ClusterHealthUp,
+		HealthReason:          "connected",
+		HealthSince:           report.TargetStatus.HealthSince,
 	}, report.TargetStatus)
 	require.Equal(t, health.UP, report.Status)
 }
@@ -215,6 +222,7 @@ func testHttpEndpointsWithUnavailableNode(
 	require.Equal(t, health.UP, healthReport.Status)
 
 	require.Equal(t, health.UP, healthReport.OriginStatus.Status)
+	require.Equal(t, zdmproxy.ClusterHealthDegraded, healthReport.OriginStatus.HealthState)
 	require.Equal(t, conf.HeartbeatFailureThreshold, healthReport.OriginStatus.FailureCountThreshold)
 	require.Greater(t, healthReport.OriginStatus.CurrentFailureCount, 0)
 	require.Less(t, healthReport.OriginStatus.CurrentFailureCount, healthReport.OriginStatus.FailureCountThreshold)
@@ -250,10 +258,12 @@ func testHttpEndpointsWithUnavailableNode(
 	require.Equal(t, health.DOWN, healthReport.Status)
 
 	require.Equal(t, health.DOWN, healthReport.OriginStatus.Status)
+	require.Equal(t, zdmproxy.ClusterHealthDown, healthReport.OriginStatus.HealthState)
 	require.Equal(t, conf.HeartbeatFailureThreshold, healthReport.OriginStatus.FailureCountThreshold)
 	require.GreaterOrEqual(t, healthReport.OriginStatus.CurrentFailureCount, healthReport.OriginStatus.FailureCountThreshold)
 
 	require.Equal(t, health.UP, healthReport.TargetStatus.Status)
+	require.Equal(t, zdmproxy.ClusterHealthUp, healthReport.TargetStatus.HealthState)
 	require.Equal(t, conf.HeartbeatFailureThreshold, healthReport.TargetStatus.FailureCountThreshold)
 	require.Equal(t, 0, healthReport.TargetStatus.CurrentFailureCount)
 
@@ -107,7 +107,10 @@ func createClusters() error {
 	return nil
 }
 
+// CleanUpClusters removes the global CCM clusters and stops the Simulacron process started by the tests.
 func CleanUpClusters() {
+	simulacron.StopGlobalSimulacronProcess()
+
 	if !createdGlobalClusters {
 		return
 	}
@@ -122,27 +125,33 @@ type SimulacronTestSetup struct {
 	Proxy  *zdmproxy.ZdmProxy
 }
 
-func NewSimulacronTestSetupWithSession(t *testing.T, createProxy bool, createSession bool) (*SimulacronTestSetup, error) {
+func NewSimulacronTestSetupWithSession(t testing.TB, createProxy bool, createSession bool) (*SimulacronTestSetup, error) {
 	return NewSimulacronTestSetupWithSessionAndConfig(t, createProxy, createSession, nil)
 }
 
-func NewSimulacronTestSetupWithSessionAndConfig(t *testing.T, createProxy bool, createSession bool, config *config.Config) (*SimulacronTestSetup, error) {
+func NewSimulacronTestSetupWithSessionAndConfig(t testing.TB, createProxy bool, createSession bool, config *config.Config) (*SimulacronTestSetup, error) {
 	return NewSimulacronTestSetupWithSessionAndNodesAndConfig(t, createProxy, createSession, 1, config)
 }
 
-func NewSimulacronTestSetupWithSessionAndNodes(t *testing.T, createProxy bool, createSession bool, nodes int) (*SimulacronTestSetup, error) {
+func NewSimulacronTestSetupWithSessionAndNodes(t testing.TB, createProxy bool, createSession bool, nodes int) (*SimulacronTestSetup, error) {
 	return NewSimulacronTestSetupWithSessionAndNodesAndConfig(t, createProxy, createSession, nodes, nil)
 }
 
-func NewSimulacronTestSetupWithSessionAndNodesAndConfig(t *testing.T, createProxy bool, createSession bool, nodes int, config *config.Config) (*SimulacronTestSetup, error) {
+func NewSimulacronTestSetupWithSessionAndNodesAndConfig(t testing.TB, createProxy bool, createSession bool, nodes int, config *config.Config) (*SimulacronTestSetup, error) {
+	return NewSimulacronTestSetupWithDatacenters(t, createProxy, createSession, []int{nodes}, config)
+}
+
+// NewSimulacronTestSetupWithDatacenters creates origin and target clusters with one datacenter per element of
+// nodesPerDatacenter (named dc1, dc2, etc.) and each datacenter has the specified number of nodes.
+func NewSimulacronTestSetupWithDatacenters(t testing.TB, createProxy bool, createSession bool, nodesPerDatacenter []int, config *config.Config) (*SimulacronTestSetup, error) {
 	if !env.RunMockTests {
 		t.Skip("Skipping Simulacron tests, RUN_MOCKTESTS is set false")
 	}
-	origin, err := simulacron.GetNewCluster(createSession, nodes)
+	origin, err := simulacron.GetNewClusterWithDatacenters(createSession, nodesPerDatacenter...)
 	if err != nil {
 		log.Panic("simulacron origin startup failed: ", err)
 	}
-	target, err := simulacron.GetNewCluster(createSession, nodes)
+	target, err := simulacron.GetNewClusterWithDatacenters(createSession, nodesPerDatacenter...)
 	if err != nil {
 		log.Panic("simulacron target startup failed: ", err)
 	}
@@ -168,11 +177,11 @@ func NewSimulacronTestSetupWithSessionAndNodesAndConfig(t *testing.T, createProx
 	}, nil
 }
 
-func NewSimulacronTestSetup(t *testing.T) (*SimulacronTestSetup, error) {
+func NewSimulacronTestSetup(t testing.TB) (*SimulacronTestSetup, error) {
 	return NewSimulacronTestSetupWithSession(t, true, false)
 }
 
-func NewSimulacronTestSetupWithConfig(t *testing.T, c *config.Config) (*SimulacronTestSetup, error) {
+func NewSimulacronTestSetupWithConfig(t testing.TB, c *config.Config) (*SimulacronTestSetup, error) {
 	return NewSimulacronTestSetupWithSessionAndConfig(t, true, false, c)
 }
 
@@ -199,14 +208,22 @@ type CcmTestSetup struct {
 }
 
 func NewTemporaryCcmTestSetup(start bool, createProxy bool) (*CcmTestSetup, error) {
+	return NewTemporaryCcmTestSetupWithOptions(
+		start, createProxy, ccm.ClusterOptions{NumberOfNodes: env.OriginNodes}, ccm.ClusterOptions{NumberOfNodes: env.TargetNodes})
+}
+
+// NewTemporaryCcmTestSetupWithOptions creates two CCM clusters that are removed on Cleanup. Use it instead of
+// NewTemporaryCcmTestSetup when a test needs multi node clusters or authentication.
+func NewTemporaryCcmTestSetupWithOptions(
+	start bool, createProxy bool, originOptions ccm.ClusterOptions, targetOptions ccm.ClusterOptions) (*CcmTestSetup, error) {
 	firstClusterId := env.Rand.Uint64() % (math.MaxUint64 - 1)
-	origin, err := ccm.GetNewCluster(firstClusterId, 20, env.OriginNodes, start)
+	origin, err := ccm.GetNewClusterWithOptions(firstClusterId, 20, originOptions, start)
 	if err != nil {
 		return nil, err
 	}
 
 	secondClusterId := firstClusterId + 1
-	target, err := ccm.GetNewCluster(secondClusterId, 30, env.TargetNodes, start)
+	target, err := ccm.GetNewClusterWithOptions(secondClusterId, 30, targetOptions, start)
 	if err != nil {
 		origin.Remove()
 		return nil, err
@@ -216,6 +233,8 @@ func NewTemporaryCcmTestSetup(start bool, createProxy bool) (*CcmTestSetup, erro
 	if createProxy {
 		proxyInstance, err = NewProxyInstance(origin, target)
 		if err != nil {
+			target.Remove()
+			origin.Remove()
 			return nil, err
 		}
 	} else {
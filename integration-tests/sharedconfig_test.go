@@ -0,0 +1,170 @@
+package integration_tests
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeConsul implements the blocking queries of the Consul KV API for the keys of a single prefix and grants the
+// leadership of the fleet to the first session that asks for it.
+type fakeConsul struct {
+	*httptest.Server
+	lock    *sync.Mutex
+	index   int
+	values  map[string]string
+	changed chan struct{}
+}
+
+func newFakeConsul(values map[string]string) *fakeConsul {
+	consul := &fakeConsul{lock: &sync.Mutex{}, index: 1, values: values, changed: make(chan struct{})}
+	consul.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPut {
+			switch {
+			case r.URL.Path == "/v1/session/create":
+				_, _ = w.Write([]byte(`{"ID":"session-1"}`))
+			case strings.HasPrefix(r.URL.Path, "/v1/session/"):
+				_, _ = w.Write([]byte(`[]`))
+			default:
+				_, _ = w.Write([]byte(strconv.FormatBool(r.URL.Query().Get("acquire") == "session-1")))
+			}
+			return
+		}
+
+		consul.lock.Lock()
+		index, changed := consul.index, consul.changed
+		consul.lock.Unlock()
+		if r.URL.Query().Get("index") == strconv.Itoa(index) {
+			select {
+			case <-changed:
+			case <-r.Context().Done():
+				return
+			}
+		}
+
+		consul.lock.Lock()
+		defer consul.lock.Unlock()
+		w.Header().Set("X-Consul-Index", strconv.Itoa(consul.index))
+		response := "["
+		for key, value := range consul.values {
+			if len(response) > 1 {
+				response += ","
+			}
+			response += fmt.Sprintf(`{"Key":"zdm-proxy/%v","Value":%q}`, key, base64.StdEncoding.EncodeToString([]byte(value)))
+		}
+		_, _ = w.Write([]byte(response + "]"))
+	}))
+	return consul
+}
+
+func (c *fakeConsul) set(values map[string]string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.values = values
+	c.index++
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+func TestSharedConfig(t *testing.T) {
+	const selectQuery = "SELECT * FROM ks.tb"
+	conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+
+	reads := make(chan string, 10)
+	newReadHandler := func(cluster string) client.RequestHandler {
+		return func(request *frame.Frame, conn *client.CqlServerConnection, ctx client.RequestHandlerContext) *frame.Frame {
+			if query, ok := request.Body.Message.(*message.Query); ok && query.Query == selectQuery {
+				reads <- cluster
+				return frame.NewFrame(request.Header.Version, request.Header.StreamId, &message.RowsResult{
+					Metadata: &message.RowsMetadata{ColumnCount: 0},
+					Data:     message.RowSet{},
+				})
+			}
+			return nil
+		}
+	}
+	testSetup.Origin.CqlServer.RequestHandlers = []client.RequestHandler{
+		client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+		client.NewSystemTablesHandler("cluster1", "dc1"), newReadHandler("ORIGIN")}
+	testSetup.Target.CqlServer.RequestHandlers = []client.RequestHandler{
+		client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+		client.NewSystemTablesHandler("cluster2", "dc2"), newReadHandler("TARGET")}
+	err = testSetup.Start(nil, false, primitive.ProtocolVersion4)
+	require.Nil(t, err)
+
+	consul := newFakeConsul(map[string]string{"primary_cluster": "TARGET", "request_rate_limit": "1"})
+	defer consul.Close()
+
+	conf.PrimaryCluster = "ORIGIN"
+	conf.SharedConfigBackend = "CONSUL"
+	conf.SharedConfigEndpoints = consul.URL
+	conf.SharedConfigPrefix = "zdm-proxy/"
+	conf.SharedConfigLeaderTtlMs = 15000
+	conf.ProxyGlobalRequestRateLimitKey = "zdm-proxy-rate-limit/bucket"
+	proxy, err := setup.NewProxyInstanceWithConfig(conf)
+	require.Nil(t, err)
+	defer proxy.Shutdown()
+
+	query := func(cqlConn *client.CqlClientConnection) message.Message {
+		response, err := cqlConn.SendAndReceive(frame.NewFrame(primitive.ProtocolVersion4, 0, &message.Query{
+			Query:   selectQuery,
+			Options: &message.QueryOptions{Consistency: primitive.ConsistencyLevelOne},
+		}))
+		require.Nil(t, err)
+		return response.Body.Message
+	}
+	connect := func() *client.CqlClientConnection {
+		testClient := client.NewCqlClient(
+			fmt.Sprintf("%s:%d", conf.ProxyListenAddress, conf.ProxyListenPort),
+			&client.AuthCredentials{Username: conf.TargetUsername, Password: conf.TargetPassword})
+		cqlConn, err := testClient.Connect(context.Background())
+		require.Nil(t, err)
+		require.Nil(t, cqlConn.InitiateHandshake(primitive.ProtocolVersion4, 0))
+		return cqlConn
+	}
+
+	// a single proxy instance, it is elected as the leader of the fleet
+	require.Eventually(t, proxy.IsFleetLeader, 10*time.Second, 100*time.Millisecond)
+
+	// the proxy starts with the shared settings instead of its own configuration
+	cqlConn := connect()
+	defer cqlConn.Close()
+	require.Equal(t, primitive.OpCodeResult, query(cqlConn).GetOpCode())
+	require.Equal(t, "TARGET", <-reads)
+	overloaded, ok := query(cqlConn).(*message.Overloaded)
+	require.True(t, ok)
+	require.Equal(t, "Request rate limit of the proxy exceeded, please retry on next host.", overloaded.ErrorMessage)
+
+	consul.set(map[string]string{"primary_cluster": "ORIGIN"})
+
+	require.Eventually(t, func() bool {
+		newConn := connect()
+		defer newConn.Close()
+		if query(newConn).GetOpCode() != primitive.OpCodeResult {
+			return false
+		}
+		return <-reads == "ORIGIN"
+	}, 10*time.Second, 100*time.Millisecond)
+
+	// the existing connection keeps its routing but the rate limit is removed
+	for i := 0; i < 5; i++ {
+		require.Equal(t, primitive.OpCodeResult, query(cqlConn).GetOpCode())
+		require.Equal(t, "TARGET", <-reads)
+	}
+}
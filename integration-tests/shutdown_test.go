@@ -9,6 +9,7 @@ import (
 	"github.com/datastax/go-cassandra-native-protocol/message"
 	"github.com/datastax/go-cassandra-native-protocol/primitive"
 	"github.com/datastax/zdm-proxy/integration-tests/client"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
 	"github.com/datastax/zdm-proxy/integration-tests/setup"
 	"github.com/datastax/zdm-proxy/integration-tests/simulacron"
 	"github.com/datastax/zdm-proxy/proxy/pkg/config"
@@ -55,7 +56,7 @@ func TestShutdownInFlightRequests(t *testing.T) {
 			}()
 
 			cqlClient := client2.NewCqlClient("127.0.0.1:14002", nil)
-			cqlConn, err := cqlClient.ConnectAndInit(context.Background(), primitive.ProtocolVersion4, 0)
+			cqlConn, err := cqlClient.ConnectAndInit(context.Background(), env.ProtocolVersion, 0)
 			if err != nil {
 				t.Fatalf("could not connect: %v", err)
 			}
@@ -88,15 +89,15 @@ func TestShutdownInFlightRequests(t *testing.T) {
 
 			beginTimestamp := time.Now()
 
-			reqFrame := frame.NewFrame(primitive.ProtocolVersion4, 2, queryMsg1)
+			reqFrame := frame.NewFrame(env.ProtocolVersion, 2, queryMsg1)
 			inflightRequest, err := cqlConn.Send(reqFrame)
 			require.Nil(t, err)
 
-			reqFrame2 := frame.NewFrame(primitive.ProtocolVersion4, 3, queryMsg2)
+			reqFrame2 := frame.NewFrame(env.ProtocolVersion, 3, queryMsg2)
 			inflightRequest2, err := cqlConn.Send(reqFrame2)
 			require.Nil(t, err)
 
-			reqFrame3 := frame.NewFrame(primitive.ProtocolVersion4, 4, queryMsg3)
+			reqFrame3 := frame.NewFrame(env.ProtocolVersion, 4, queryMsg3)
 			inflightRequest3, err := cqlConn.Send(reqFrame3)
 			require.Nil(t, err)
 
@@ -125,7 +126,7 @@ func TestShutdownInFlightRequests(t *testing.T) {
 			default:
 			}
 
-			reqFrame4 := frame.NewFrame(primitive.ProtocolVersion4, 5, queryMsg1)
+			reqFrame4 := frame.NewFrame(env.ProtocolVersion, 5, queryMsg1)
 			inflightRequest4, err := cqlConn.Send(reqFrame4)
 			require.Nil(t, err)
 
@@ -236,7 +237,7 @@ func TestStressShutdown(t *testing.T) {
 				require.Nil(t, err)
 				defer cqlConn.Shutdown()
 
-				err = cqlConn.PerformDefaultHandshake(context.Background(), primitive.ProtocolVersion4, false)
+				err = cqlConn.PerformDefaultHandshake(context.Background(), env.ProtocolVersion, false)
 				require.Nil(t, err)
 
 				// create a channel that will receive errors from goroutines that are sending requests,
@@ -284,7 +285,7 @@ func TestStressShutdown(t *testing.T) {
 											case <-defaultHandshakeDoneCh:
 												return
 											default:
-												rspFrame, _, err := tempCqlConn.SendMessage(context.Background(), primitive.ProtocolVersion4, &message.Options{})
+												rspFrame, _, err := tempCqlConn.SendMessage(context.Background(), env.ProtocolVersion, &message.Options{})
 												if err != nil {
 													if !shutdownProxyTriggered.Load().(bool) {
 														errChan <- fmt.Errorf("[%v] unexpected error in heartbeat: %w", id, err)
@@ -311,7 +312,7 @@ func TestStressShutdown(t *testing.T) {
 								case <-time.After(time.Duration(r) * time.Millisecond):
 								case <-globalCtx.Done():
 								}
-								err = tempCqlConn.PerformDefaultHandshake(context.Background(), primitive.ProtocolVersion4, false)
+								err = tempCqlConn.PerformDefaultHandshake(context.Background(), env.ProtocolVersion, false)
 								defaultHandshakeDoneCh <- true
 								optionsWg.Wait()
 								_ = tempCqlConn.Shutdown()
@@ -336,7 +337,7 @@ func TestStressShutdown(t *testing.T) {
 							Query:   "SELECT * FROM system.local",
 							Options: &message.QueryOptions{Consistency: primitive.ConsistencyLevelLocalOne},
 						}
-						rsp, _, err := cqlConn.SendMessage(context.Background(), primitive.ProtocolVersion4, queryMsg)
+						rsp, _, err := cqlConn.SendMessage(context.Background(), env.ProtocolVersion, queryMsg)
 
 						if err != nil {
 							if !shutdownProxyTriggered.Load().(bool) {
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package simulacron
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+func isProcessAlive(pid int) bool {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	// signal 0 only checks that the process exists, EPERM means that it belongs to another user
+	err = process.Signal(syscall.Signal(0))
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
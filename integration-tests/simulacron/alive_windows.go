@@ -0,0 +1,17 @@
+//go:build windows
+
+package simulacron
+
+import (
+	"os"
+)
+
+func isProcessAlive(pid int) bool {
+	// FindProcess opens a handle to the process on Windows, it fails if the process doesn't exist
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = process.Release()
+	return true
+}
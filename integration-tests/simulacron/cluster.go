@@ -84,13 +84,17 @@ func (baseSimulacron *baseSimulacron) GetId() string {
 }
 
 func GetNewCluster(startSession bool, numberOfNodes int) (*Cluster, error) {
+	return GetNewClusterWithDatacenters(startSession, numberOfNodes)
+}
+
+func GetNewClusterWithDatacenters(startSession bool, nodesPerDatacenter ...int) (*Cluster, error) {
 	process, err := GetOrCreateGlobalSimulacronProcess()
 
 	if err != nil {
 		return nil, err
 	}
 
-	cluster, createErr := process.Create(startSession, numberOfNodes)
+	cluster, createErr := process.CreateWithDatacenters(startSession, nodesPerDatacenter...)
 
 	if createErr != nil {
 		return nil, createErr
@@ -130,7 +134,7 @@ func (baseSimulacron *baseSimulacron) ClearPrimes() error {
 }
 
 func (baseSimulacron *baseSimulacron) GetConnections() ([]string, error) {
-	bytes, err := baseSimulacron.process.execHttp("DELETE", baseSimulacron.getPath("connections"), nil)
+	bytes, err := baseSimulacron.process.execHttp("GET", baseSimulacron.getPath("connections"), nil)
 	if err != nil {
 		return nil, err
 	}
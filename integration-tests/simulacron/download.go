@@ -0,0 +1,90 @@
+package simulacron
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	defaultSimulacronVersion = "0.10.0"
+	simulacronDownloadUrl    = "https://github.com/datastax/simulacron/releases/download/%[1]v/simulacron-standalone-%[1]v.jar"
+)
+
+var downloadClient = &http.Client{
+	Timeout: 5 * time.Minute,
+}
+
+// resolveJarPath returns SIMULACRON_PATH if it is set, otherwise the jar of SIMULACRON_VERSION (0.10.0 by default)
+// in the user cache directory, which is downloaded from the Simulacron releases the first time it is needed.
+func resolveJarPath() (string, error) {
+	if simulacronPath := os.Getenv("SIMULACRON_PATH"); simulacronPath != "" {
+		if _, err := os.Stat(simulacronPath); err != nil {
+			return "", fmt.Errorf("could not read the Simulacron jar of SIMULACRON_PATH: %w", err)
+		}
+		return simulacronPath, nil
+	}
+
+	version := os.Getenv("SIMULACRON_VERSION")
+	if version == "" {
+		version = defaultSimulacronVersion
+	}
+	return getOrDownloadJar(getSimulacronDir(), version, fmt.Sprintf(simulacronDownloadUrl, version))
+}
+
+// getSimulacronDir returns the directory of the downloaded jars and of the registry of the running instances, in the
+// user cache directory or in the temporary directory if there is no cache directory.
+func getSimulacronDir() string {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		cacheDir = os.TempDir()
+	}
+	return filepath.Join(cacheDir, "zdm-proxy", "simulacron")
+}
+
+// getOrDownloadJar returns the path of the jar of the given version in dir, downloading it from url if it is not there
+// yet. The jar is downloaded to a temporary file first so that the test binaries of other packages, which may be
+// downloading it at the same time, never see a partial jar.
+func getOrDownloadJar(dir string, version string, url string) (string, error) {
+	jarPath := filepath.Join(dir, fmt.Sprintf("simulacron-standalone-%v.jar", version))
+	if _, err := os.Stat(jarPath); err == nil {
+		return jarPath, nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("could not create the Simulacron download directory: %w", err)
+	}
+
+	log.Infof("Downloading Simulacron %v from %v to %v.", version, url, jarPath)
+	resp, err := downloadClient.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("could not download Simulacron %v, set SIMULACRON_PATH to a local jar instead: %w", version, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("could not download Simulacron %v from %v, unexpected status code: %d",
+			version, url, resp.StatusCode)
+	}
+
+	tmpFile, err := os.CreateTemp(dir, "simulacron-*.jar.tmp")
+	if err != nil {
+		return "", fmt.Errorf("could not create the Simulacron jar: %w", err)
+	}
+	_, err = io.Copy(tmpFile, resp.Body)
+	closeErr := tmpFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpFile.Name(), jarPath)
+	}
+	if err != nil {
+		_ = os.Remove(tmpFile.Name())
+		return "", fmt.Errorf("could not download Simulacron %v: %w", version, err)
+	}
+	return jarPath, nil
+}
@@ -0,0 +1,72 @@
+package simulacron
+
+import (
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+)
+
+func TestGetOrDownloadJar(t *testing.T) {
+	var downloads int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&downloads, 1)
+		if r.URL.Path != "/simulacron-standalone-0.10.0.jar" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("jar"))
+	}))
+	defer server.Close()
+
+	dir := filepath.Join(t.TempDir(), "simulacron")
+	jarPath, err := getOrDownloadJar(dir, "0.10.0", server.URL+"/simulacron-standalone-0.10.0.jar")
+	require.Nil(t, err)
+	require.Equal(t, filepath.Join(dir, "simulacron-standalone-0.10.0.jar"), jarPath)
+	contents, err := os.ReadFile(jarPath)
+	require.Nil(t, err)
+	require.Equal(t, "jar", string(contents))
+
+	// the downloaded jar is reused
+	jarPath, err = getOrDownloadJar(dir, "0.10.0", server.URL+"/simulacron-standalone-0.10.0.jar")
+	require.Nil(t, err)
+	require.Equal(t, filepath.Join(dir, "simulacron-standalone-0.10.0.jar"), jarPath)
+	require.Equal(t, int32(1), atomic.LoadInt32(&downloads))
+
+	// a failed download leaves no file behind
+	_, err = getOrDownloadJar(dir, "0.9.0", server.URL+"/simulacron-standalone-0.9.0.jar")
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "unexpected status code: 404")
+	files, err := os.ReadDir(dir)
+	require.Nil(t, err)
+	require.Len(t, files, 1)
+}
+
+func TestAllocateHttpPort(t *testing.T) {
+	port, err := allocateHttpPort(0)
+	require.Nil(t, err)
+	require.Greater(t, port, 0)
+
+	// a port used by another server is not reused unless it is Simulacron
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+	busyPort := server.Listener.Addr().(*net.TCPAddr).Port
+	port, err = allocateHttpPort(busyPort)
+	require.Nil(t, err)
+	require.NotEqual(t, busyPort, port)
+
+	simulacronServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[{"id":0,"data_centers":[]}]`))
+	}))
+	defer simulacronServer.Close()
+	simulacronPort := simulacronServer.Listener.Addr().(*net.TCPAddr).Port
+	port, err = allocateHttpPort(simulacronPort)
+	require.Nil(t, err)
+	require.Equal(t, simulacronPort, port)
+}
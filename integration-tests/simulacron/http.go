@@ -11,6 +11,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strconv"
+	"strings"
 )
 
 type ClusterData struct {
@@ -32,10 +33,27 @@ type NodeData struct {
 const createUrl = "/cluster?data_centers=%s&cassandra_version=%s&dse_version=%s&name=%s&activity_log=%s&num_tokens=%d"
 
 func (process *Process) Create(startSession bool, numberOfNodes int) (*Cluster, error) {
+	return process.CreateWithDatacenters(startSession, numberOfNodes)
+}
+
+// CreateWithDatacenters creates a cluster with one datacenter per element of nodesPerDatacenter.
+// Simulacron names the datacenters dc1, dc2, etc.
+func (process *Process) CreateWithDatacenters(startSession bool, nodesPerDatacenter ...int) (*Cluster, error) {
+	if len(nodesPerDatacenter) == 0 {
+		return nil, errors.New("at least one datacenter is required")
+	}
+	dataCenters := make([]string, len(nodesPerDatacenter))
+	for i, nodes := range nodesPerDatacenter {
+		if nodes <= 0 {
+			return nil, fmt.Errorf("invalid number of nodes for datacenter %d: %d", i+1, nodes)
+		}
+		dataCenters[i] = strconv.FormatInt(int64(nodes), 10)
+	}
+
 	name := "test_" + uuid.New().String()
 	resp, err := process.execHttp(
 		"POST",
-		fmt.Sprintf(createUrl, strconv.FormatInt(int64(numberOfNodes), 10), env.CassandraVersion, env.DseVersion, name, "true", 1),
+		fmt.Sprintf(createUrl, strings.Join(dataCenters, ","), env.CassandraVersion, env.DseVersion, name, "true", 1),
 		nil)
 
 	if err != nil {
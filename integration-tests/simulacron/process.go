@@ -3,11 +3,13 @@ package simulacron
 import (
 	"bufio"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	log "github.com/sirupsen/logrus"
 	"io"
+	"net"
 	"net/http"
-	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
@@ -28,6 +30,7 @@ type Process struct {
 	baseUrl    string
 	waitGroup  *sync.WaitGroup
 	failedBind bool
+	registry   *instanceRegistry
 }
 
 var globalInstance = &atomic.Value{}
@@ -35,11 +38,14 @@ var globalSimulacronMutex = &sync.Mutex{}
 var httpClient = &http.Client{
 	Timeout: 30 * time.Second,
 }
-var simulacronPath = os.Getenv("SIMULACRON_PATH")
+var probeClient = &http.Client{
+	Timeout: 2 * time.Second,
+}
 
 const (
 	defaultHttpPort = 8188
 	defaultStartIp  = "127.0.0.40"
+	readyTimeout    = 60 * time.Second
 )
 
 func NewSimulacronProcess(httpPort int, startIp string) *Process {
@@ -108,9 +114,26 @@ func (process *Process) Start() error {
 		return nil
 	}
 
+	if isSimulacronListening(process.baseUrl) {
+		log.Infof("Simulacron is already running on %v, reusing it.", process.baseUrl)
+		process.failedBind = true
+		process.started = true
+		return nil
+	}
+
+	simulacronPath, err := resolveJarPath()
+	if err != nil {
+		return err
+	}
+
+	javaPath, err := exec.LookPath("java")
+	if err != nil {
+		return fmt.Errorf("java is required to run Simulacron, install a JRE or set RUN_MOCKTESTS=false: %w", err)
+	}
+
 	process.cmd = exec.CommandContext(
 		process.ctx,
-		"java",
+		javaPath,
 		"-jar",
 		simulacronPath,
 		"--ip",
@@ -233,7 +256,11 @@ func (process *Process) Start() error {
 		}
 	}()
 
-	err := <-mainChannel
+	err = <-mainChannel
+	if err == nil {
+		// the startup log line is printed before the http server accepts requests
+		err = process.waitUntilReady(readyTimeout)
+	}
 	if err == nil {
 		process.started = true
 		return nil
@@ -243,36 +270,145 @@ func (process *Process) Start() error {
 	}
 }
 
-func GetGlobalSimulacronProcess() *Process {
-	instance := globalInstance.Load()
-	if instance != nil {
-		return instance.(*Process)
+func (process *Process) waitUntilReady(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		_, err := process.execHttp("GET", "/cluster", nil)
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("simulacron is not ready on %v after %v: %w", process.baseUrl, timeout, err)
+		}
+		select {
+		case <-process.ctx.Done():
+			return process.ctx.Err()
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// isSimulacronListening returns true if the http server on baseUrl lists the Simulacron clusters, e.g. when the test
+// binary of another package started Simulacron.
+func isSimulacronListening(baseUrl string) bool {
+	resp, err := probeClient.Get(baseUrl + "/cluster")
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+	var clusters []*ClusterData
+	return json.NewDecoder(resp.Body).Decode(&clusters) == nil
+}
+
+// allocateHttpPort returns the preferred port if it is free or if Simulacron is already listening on it, otherwise a
+// free port picked by the OS.
+func allocateHttpPort(preferredPort int) (int, error) {
+	if isSimulacronListening("http://127.0.0.1:" + strconv.Itoa(preferredPort)) {
+		return preferredPort, nil
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:"+strconv.Itoa(preferredPort))
+	if err != nil {
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			return 0, fmt.Errorf("could not allocate a port for the Simulacron http server: %w", err)
+		}
+		log.Infof("Port %d is in use, Simulacron will listen on port %d.",
+			preferredPort, listener.Addr().(*net.TCPAddr).Port)
+	}
+	port := listener.Addr().(*net.TCPAddr).Port
+	err = listener.Close()
+	if err != nil {
+		return 0, fmt.Errorf("could not allocate a port for the Simulacron http server: %w", err)
 	}
+	return port, nil
+}
 
-	return nil
+func GetGlobalSimulacronProcess() *Process {
+	instance, _ := globalInstance.Load().(*Process)
+	return instance
 }
 
 func GetOrCreateGlobalSimulacronProcess() (*Process, error) {
-	instance := globalInstance.Load()
+	instance := GetGlobalSimulacronProcess()
 	if instance != nil {
-		return instance.(*Process), nil
+		return instance, nil
 	}
 
 	globalSimulacronMutex.Lock()
 	defer globalSimulacronMutex.Unlock()
 
-	instance = globalInstance.Load()
+	instance = GetGlobalSimulacronProcess()
 	if instance != nil {
-		return instance.(*Process), nil
+		return instance, nil
 	}
 
-	newInstance := NewSimulacronProcess(defaultHttpPort, defaultStartIp)
-	err := newInstance.Start()
+	httpPort, err := allocateHttpPort(defaultHttpPort)
+	if err != nil {
+		return nil, err
+	}
 
+	// the binary registers before checking whether Simulacron is already running so that the binary that started it
+	// doesn't stop it in the meantime
+	registry := newInstanceRegistry(getSimulacronDir(), httpPort)
+	unlock, err := registry.lock()
 	if err != nil {
 		return nil, err
+	}
+	defer unlock()
+	err = registry.register()
+	if err != nil {
+		return nil, err
+	}
+
+	newInstance := NewSimulacronProcess(httpPort, defaultStartIp)
+	newInstance.registry = registry
+	err = newInstance.Start()
+	if err == nil && !newInstance.failedBind {
+		err = registry.setServerPid(newInstance.cmd.Process.Pid)
+		if err != nil {
+			newInstance.Cancel()
+		}
+	}
+
+	if err != nil {
+		registry.unregister()
+		return nil, err
 	} else {
 		globalInstance.Store(newInstance)
 		return newInstance, nil
 	}
 }
+
+// StopGlobalSimulacronProcess releases the Simulacron instance of GetOrCreateGlobalSimulacronProcess, if any. The
+// instance is only stopped if no other test binary uses it, whichever binary started it. A Simulacron instance that
+// wasn't started by the tests is left running.
+func StopGlobalSimulacronProcess() {
+	globalSimulacronMutex.Lock()
+	defer globalSimulacronMutex.Unlock()
+
+	instance := GetGlobalSimulacronProcess()
+	if instance == nil {
+		return
+	}
+	unlock, err := instance.registry.lock()
+	if err != nil {
+		log.Warnf("Leaving Simulacron running: %v", err)
+	} else {
+		instance.registry.unregister()
+		if instance.registry.hasOtherUsers() {
+			log.Infof("Leaving Simulacron running on %v, other test binaries are using it.", instance.baseUrl)
+		} else if instance.failedBind {
+			instance.registry.stopServer()
+		} else {
+			instance.Cancel()
+			instance.registry.clearServerPid()
+		}
+		unlock()
+	}
+	// atomic.Value can't store nil, the typed nil makes the next call of GetOrCreateGlobalSimulacronProcess start a
+	// new process
+	globalInstance.Store((*Process)(nil))
+}
@@ -0,0 +1,178 @@
+package simulacron
+
+import (
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the lock is held while the jar is downloaded and Simulacron starts
+const registryLockTimeout = 10 * time.Minute
+
+// instanceRegistry keeps track of the test binaries that use the Simulacron instance of a port, e.g. the ones of
+// integration-tests and integration-tests/benchmarks which run in parallel with go test ./..., so that the instance
+// is only stopped by the last binary that uses it, whether that binary started it or not. Each binary registers a file
+// named after its pid in the users directory, the files of binaries that exited without unregistering are ignored.
+type instanceRegistry struct {
+	usersDir    string
+	lockPath    string
+	pidPath     string
+	lockTimeout time.Duration
+}
+
+func newInstanceRegistry(dir string, httpPort int) *instanceRegistry {
+	return &instanceRegistry{
+		usersDir:    filepath.Join(dir, fmt.Sprintf("users-%d", httpPort)),
+		lockPath:    filepath.Join(dir, fmt.Sprintf("simulacron-%d.lock", httpPort)),
+		pidPath:     filepath.Join(dir, fmt.Sprintf("simulacron-%d.pid", httpPort)),
+		lockTimeout: registryLockTimeout,
+	}
+}
+
+// lock acquires the lock file of the registry, which is held by the binaries while they decide whether to start,
+// reuse or stop the instance. A lock file left by a binary that is no longer running is taken over.
+func (r *instanceRegistry) lock() (unlock func(), err error) {
+	err = os.MkdirAll(r.usersDir, 0755)
+	if err != nil {
+		return nil, fmt.Errorf("could not create the Simulacron registry: %w", err)
+	}
+	deadline := time.Now().Add(r.lockTimeout)
+	for {
+		file, err := os.OpenFile(r.lockPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			_, err = file.WriteString(strconv.Itoa(os.Getpid()))
+			closeErr := file.Close()
+			if err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				_ = os.Remove(r.lockPath)
+				return nil, fmt.Errorf("could not write the Simulacron registry lock: %w", err)
+			}
+			return func() {
+				if err := os.Remove(r.lockPath); err != nil {
+					log.Warnf("Could not remove the Simulacron registry lock %v: %v", r.lockPath, err)
+				}
+			}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("could not create the Simulacron registry lock: %w", err)
+		}
+		if holder, ok := readPidFile(r.lockPath); ok && !isProcessAlive(holder) {
+			r.removeStaleLock(holder)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timeout while waiting for the Simulacron registry lock %v", r.lockPath)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// removeStaleLock removes the lock file if it's still the one of the given process that is no longer running. Another
+// waiter may have taken it over and acquired the lock since its pid was read, so the file is first moved to a name that
+// is unique to this binary and put back if it turns out to be the lock of another binary.
+func (r *instanceRegistry) removeStaleLock(holder int) {
+	stalePath := fmt.Sprintf("%v.%d", r.lockPath, os.Getpid())
+	if err := os.Rename(r.lockPath, stalePath); err != nil {
+		// already removed by another waiter
+		return
+	}
+	if pid, ok := readPidFile(stalePath); ok && pid != holder {
+		// Link fails instead of replacing a lock that was acquired while the file was moved
+		if err := os.Link(stalePath, r.lockPath); err != nil {
+			log.Warnf("Could not restore the Simulacron registry lock of process %d: %v", pid, err)
+		}
+	} else {
+		log.Infof("Taking over the Simulacron registry lock of process %d which is no longer running.", holder)
+	}
+	_ = os.Remove(stalePath)
+}
+
+func (r *instanceRegistry) register() error {
+	err := os.WriteFile(filepath.Join(r.usersDir, strconv.Itoa(os.Getpid())), nil, 0644)
+	if err != nil {
+		return fmt.Errorf("could not register in the Simulacron registry: %w", err)
+	}
+	return nil
+}
+
+func (r *instanceRegistry) unregister() {
+	err := os.Remove(filepath.Join(r.usersDir, strconv.Itoa(os.Getpid())))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warnf("Could not unregister from the Simulacron registry: %v", err)
+	}
+}
+
+// hasOtherUsers returns true if another running binary is registered, the files of the binaries that are no longer
+// running are removed.
+func (r *instanceRegistry) hasOtherUsers() bool {
+	entries, err := os.ReadDir(r.usersDir)
+	if err != nil {
+		log.Warnf("Could not read the Simulacron registry, assuming that Simulacron is still used: %v", err)
+		return true
+	}
+	found := false
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil || pid == os.Getpid() {
+			continue
+		}
+		if isProcessAlive(pid) {
+			found = true
+		} else {
+			_ = os.Remove(filepath.Join(r.usersDir, entry.Name()))
+		}
+	}
+	return found
+}
+
+// setServerPid records the pid of the Simulacron process so that the binary that stops using it last can stop it,
+// even if it didn't start it.
+func (r *instanceRegistry) setServerPid(pid int) error {
+	err := os.WriteFile(r.pidPath, []byte(strconv.Itoa(pid)), 0644)
+	if err != nil {
+		return fmt.Errorf("could not record the pid of Simulacron: %w", err)
+	}
+	return nil
+}
+
+func (r *instanceRegistry) clearServerPid() {
+	_ = os.Remove(r.pidPath)
+}
+
+// stopServer kills the Simulacron process recorded by setServerPid, if it's still running. An instance that wasn't
+// started by the tests has no pid file and is left running.
+func (r *instanceRegistry) stopServer() {
+	pid, ok := readPidFile(r.pidPath)
+	r.clearServerPid()
+	if !ok || !isProcessAlive(pid) {
+		return
+	}
+	process, err := os.FindProcess(pid)
+	if err == nil {
+		err = process.Kill()
+	}
+	if err != nil {
+		log.Warnf("Could not stop the Simulacron process %d: %v", pid, err)
+		return
+	}
+	log.Infof("Stopped the Simulacron process %d started by another test binary.", pid)
+}
+
+func readPidFile(path string) (int, bool) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(contents)))
+	if err != nil {
+		return 0, false
+	}
+	return pid, true
+}
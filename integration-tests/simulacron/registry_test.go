@@ -0,0 +1,98 @@
+package simulacron
+
+import (
+	"github.com/stretchr/testify/require"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// exitedPid returns the pid of a process that is no longer running.
+func exitedPid(t *testing.T) int {
+	cmd := exec.Command(os.Args[0], "-test.run", "^$")
+	require.Nil(t, cmd.Run())
+	return cmd.Process.Pid
+}
+
+func TestInstanceRegistry_Users(t *testing.T) {
+	registry := newInstanceRegistry(t.TempDir(), 8188)
+	unlock, err := registry.lock()
+	require.Nil(t, err)
+	defer unlock()
+
+	require.Nil(t, registry.register())
+	require.False(t, registry.hasOtherUsers())
+
+	// the binaries that exited without unregistering are ignored
+	stalePath := filepath.Join(registry.usersDir, strconv.Itoa(exitedPid(t)))
+	require.Nil(t, os.WriteFile(stalePath, nil, 0644))
+	require.False(t, registry.hasOtherUsers())
+	require.NoFileExists(t, stalePath)
+
+	require.Nil(t, os.WriteFile(filepath.Join(registry.usersDir, strconv.Itoa(os.Getppid())), nil, 0644))
+	require.True(t, registry.hasOtherUsers())
+
+	registry.unregister()
+	require.NoFileExists(t, filepath.Join(registry.usersDir, strconv.Itoa(os.Getpid())))
+}
+
+func TestInstanceRegistry_Lock(t *testing.T) {
+	registry := newInstanceRegistry(t.TempDir(), 8188)
+	registry.lockTimeout = 200 * time.Millisecond
+
+	unlock, err := registry.lock()
+	require.Nil(t, err)
+	_, err = registry.lock()
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "timeout while waiting for the Simulacron registry lock")
+	unlock()
+	require.NoFileExists(t, registry.lockPath)
+
+	// the lock of a binary that is no longer running is taken over
+	require.Nil(t, os.WriteFile(registry.lockPath, []byte(strconv.Itoa(exitedPid(t))), 0644))
+	unlock, err = registry.lock()
+	require.Nil(t, err)
+	unlock()
+
+	// a lock acquired by another waiter after the stale pid was read is kept
+	stalePid := exitedPid(t)
+	require.Nil(t, os.WriteFile(registry.lockPath, []byte(strconv.Itoa(os.Getppid())), 0644))
+	registry.removeStaleLock(stalePid)
+	holder, ok := readPidFile(registry.lockPath)
+	require.True(t, ok)
+	require.Equal(t, os.Getppid(), holder)
+
+	require.Nil(t, os.WriteFile(registry.lockPath, []byte(strconv.Itoa(stalePid)), 0644))
+	registry.removeStaleLock(stalePid)
+	require.NoFileExists(t, registry.lockPath)
+	files, err := os.ReadDir(filepath.Dir(registry.lockPath))
+	require.Nil(t, err)
+	for _, file := range files {
+		require.NotContains(t, file.Name(), filepath.Base(registry.lockPath))
+	}
+}
+
+func TestInstanceRegistry_StopServer(t *testing.T) {
+	registry := newInstanceRegistry(t.TempDir(), 8188)
+
+	// an instance that wasn't started by the tests is left running
+	registry.stopServer()
+
+	cmd := exec.Command(os.Args[0], "-test.run", "^TestHelperSleep$")
+	cmd.Env = append(os.Environ(), "SIMULACRON_TEST_HELPER_SLEEP=1")
+	require.Nil(t, cmd.Start())
+	require.Nil(t, registry.setServerPid(cmd.Process.Pid))
+	registry.stopServer()
+	require.NotNil(t, cmd.Wait())
+	require.NoFileExists(t, registry.pidPath)
+}
+
+func TestHelperSleep(t *testing.T) {
+	if os.Getenv("SIMULACRON_TEST_HELPER_SLEEP") != "1" {
+		t.Skip("only runs as the process stopped by TestInstanceRegistry_StopServer")
+	}
+	time.Sleep(time.Minute)
+}
@@ -0,0 +1,64 @@
+package integration_tests
+
+import (
+	"context"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+func TestStartupStrippedOptions(t *testing.T) {
+	conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	conf.StartupStrippedOptions = "THROW_ON_OVERLOAD,DRIVER_*"
+	testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+
+	lock := &sync.Mutex{}
+	var originStartupOptions []map[string]string
+	recordStartupHandler := func(
+		request *frame.Frame, _ *client.CqlServerConnection, _ client.RequestHandlerContext) *frame.Frame {
+		if startup, ok := request.Body.Message.(*message.Startup); ok {
+			lock.Lock()
+			originStartupOptions = append(originStartupOptions, startup.Options)
+			lock.Unlock()
+		}
+		return nil
+	}
+	testSetup.Origin.CqlServer.RequestHandlers = append(
+		[]client.RequestHandler{recordStartupHandler}, testSetup.Origin.CqlServer.RequestHandlers...)
+
+	err = testSetup.Start(conf, false, env.ProtocolVersion)
+	require.Nil(t, err)
+
+	testClient := client.NewCqlClient(fmt.Sprintf("%s:%d", conf.ProxyListenAddress, conf.ProxyListenPort), nil)
+	cqlConn, err := testClient.Connect(context.Background())
+	require.Nil(t, err)
+	defer cqlConn.Close()
+
+	startup := &message.Startup{Options: map[string]string{
+		"CQL_VERSION":       "3.0.0",
+		"THROW_ON_OVERLOAD": "1",
+		"DRIVER_NAME":       "test driver",
+		"DRIVER_VERSION":    "1.0.0",
+		"APPLICATION_NAME":  "test application",
+	}}
+	response, err := cqlConn.SendAndReceive(frame.NewFrame(env.ProtocolVersion, 0, startup))
+	require.Nil(t, err)
+	require.Equal(t, primitive.OpCodeAuthenticate, response.Header.OpCode)
+
+	lock.Lock()
+	defer lock.Unlock()
+	require.NotEmpty(t, originStartupOptions)
+	require.Equal(t, map[string]string{
+		"CQL_VERSION":      "3.0.0",
+		"APPLICATION_NAME": "test application",
+	}, originStartupOptions[len(originStartupOptions)-1])
+}
@@ -0,0 +1,75 @@
+package integration_tests
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/datastax/zdm-proxy/integration-tests/utils"
+	"github.com/datastax/zdm-proxy/proxy/pkg/zdmproxy"
+	"github.com/jpillora/backoff"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestStartupPolicyRefuseConnections tests that with the REFUSE_CONNECTIONS startup policy the proxy listens for client
+// connections and closes them while target is unavailable, and that it serves them once target comes online
+func TestStartupPolicyRefuseConnections(t *testing.T) {
+	conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	conf.StartupPolicy = "REFUSE_CONNECTIONS"
+	testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+	require.Nil(t, testSetup.Origin.Start())
+
+	waitGroup := &sync.WaitGroup{}
+	ctx, cancelFunc := context.WithCancel(context.Background())
+	defer waitGroup.Wait()
+	defer cancelFunc()
+
+	b := &backoff.Backoff{Factor: 2, Jitter: false, Min: 100 * time.Millisecond, Max: 500 * time.Millisecond}
+	waitGroup.Add(1)
+	go func() {
+		defer waitGroup.Done()
+		p, err := zdmproxy.RunWithRetries(conf, ctx, b)
+		if err == nil {
+			<-ctx.Done()
+			p.Shutdown()
+		}
+	}()
+
+	utils.RequireWithRetries(t, func() (err error, fatal bool) {
+		conn, err := net.Dial("tcp", "127.0.0.1:14002")
+		if err != nil {
+			return fmt.Errorf("expected the proxy to accept the tcp connection: %w", err), false
+		}
+		defer conn.Close()
+		err = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if err != nil {
+			return err, true
+		}
+		_, err = conn.Read(make([]byte, 1))
+		if !errors.Is(err, io.EOF) {
+			return fmt.Errorf("expected the proxy to close the connection but got %v", err), true
+		}
+		return nil, false
+	}, 10, 100*time.Millisecond)
+
+	require.Nil(t, testSetup.Target.Start())
+
+	utils.RequireWithRetries(t, func() (err error, fatal bool) {
+		testClient, err := testSetup.Client.CqlClient.ConnectAndInit(
+			context.Background(), env.ProtocolVersion, client.ManagedStreamId)
+		if err != nil {
+			return fmt.Errorf("expected successful handshake after target came online: %w", err), false
+		}
+		testClient.Close()
+		return nil, false
+	}, 20, 200*time.Millisecond)
+}
@@ -0,0 +1,52 @@
+package integration_tests
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// voidResultQueryHandler returns a VOID result to all the QUERY requests
+func voidResultQueryHandler(
+	request *frame.Frame, _ *client.CqlServerConnection, _ client.RequestHandlerContext) *frame.Frame {
+	if _, ok := request.Body.Message.(*message.Query); !ok {
+		return nil
+	}
+	return frame.NewFrame(request.Header.Version, request.Header.StreamId, &message.VoidResult{})
+}
+
+func TestStatementMetrics(t *testing.T) {
+	conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	conf.StatementMetricsMaxFingerprints = 1
+	testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+	testSetup.Origin.CqlServer.RequestHandlers = []client.RequestHandler{
+		client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+		client.NewSystemTablesHandler("cluster1", "dc1"), voidResultQueryHandler}
+	testSetup.Target.CqlServer.RequestHandlers = []client.RequestHandler{
+		client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+		client.NewSystemTablesHandler("cluster2", "dc2"), voidResultQueryHandler}
+
+	err = testSetup.Start(conf, true, env.ProtocolVersion)
+	require.Nil(t, err)
+
+	_, enabled := testSetup.Proxy.GetStatementFingerprints()
+	require.True(t, enabled)
+	queries := []string{"SELECT * FROM ks.tbl WHERE id = 1", "select * from KS.TBL where id = 2", "SELECT * FROM ks.other"}
+	for _, query := range queries {
+		_, err := testSetup.Client.CqlConnection.SendAndReceive(
+			frame.NewFrame(env.ProtocolVersion, 5, &message.Query{Query: query}))
+		require.Nil(t, err)
+	}
+
+	// the second statement has the same fingerprint and the third one exceeds the cap
+	fingerprints, _ := testSetup.Proxy.GetStatementFingerprints()
+	require.Len(t, fingerprints, 1)
+	require.Equal(t, "select * from ks.tbl where id = ?", fingerprints[0].Statement)
+	require.Len(t, fingerprints[0].Fingerprint, 16)
+}
@@ -0,0 +1,67 @@
+package integration_tests
+
+import (
+	"context"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStatementRules(t *testing.T) {
+	rulesFile := filepath.Join(t.TempDir(), "rules.json")
+	require.Nil(t, os.WriteFile(rulesFile, []byte(`{"rules": [
+		{"name": "no-filtering", "action": "deny", "pattern": "(?i)\\bALLOW\\s+FILTERING\\b",
+		 "error": "ALLOW FILTERING is not allowed"}]}`), 0600))
+
+	serverConf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	testSetup, err := setup.NewCqlServerTestSetup(t, serverConf, true, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+
+	proxyConf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	proxyConf.StatementRulesFile = rulesFile
+	proxy, err := setup.NewProxyInstanceWithConfig(proxyConf)
+	require.Nil(t, err)
+	defer proxy.Shutdown()
+
+	testClient := client.NewCqlClient(
+		fmt.Sprintf("%s:%d", proxyConf.ProxyListenAddress, proxyConf.ProxyListenPort),
+		&client.AuthCredentials{Username: serverConf.TargetUsername, Password: serverConf.TargetPassword})
+	cqlConn, err := testClient.Connect(context.Background())
+	require.Nil(t, err)
+	defer cqlConn.Close()
+	require.Nil(t, cqlConn.InitiateHandshake(env.ProtocolVersion, 0))
+
+	tests := []struct {
+		name             string
+		query            string
+		expectedResponse message.Message
+	}{
+		{"allowed", "SELECT * FROM system.peers", nil},
+		{"denied", "SELECT * FROM system.peers WHERE rack = 'r1' ALLOW FILTERING",
+			&message.Unauthorized{ErrorMessage: "ALLOW FILTERING is not allowed"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := cqlConn.SendAndReceive(frame.NewFrame(env.ProtocolVersion, 0, &message.Query{
+				Query:   tt.query,
+				Options: &message.QueryOptions{Consistency: primitive.ConsistencyLevelOne},
+			}))
+			require.Nil(t, err)
+			if tt.expectedResponse == nil {
+				require.Equal(t, primitive.OpCodeResult, response.Body.Message.GetOpCode(), response.Body.Message)
+			} else {
+				require.Equal(t, tt.expectedResponse, response.Body.Message)
+			}
+		})
+	}
+}
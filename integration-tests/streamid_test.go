@@ -7,6 +7,7 @@ import (
 	"github.com/datastax/go-cassandra-native-protocol/message"
 	"github.com/datastax/go-cassandra-native-protocol/primitive"
 	"github.com/datastax/zdm-proxy/integration-tests/client"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
 	"github.com/datastax/zdm-proxy/integration-tests/setup"
 	"github.com/datastax/zdm-proxy/integration-tests/simulacron"
 	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
@@ -194,7 +195,7 @@ func executeQuery(t *testing.T, client *client.TestClient, query string) *frame.
 	q := &message.Query{
 		Query: query,
 	}
-	response, _, err := client.SendMessage(context.Background(), primitive.ProtocolVersion4, q)
+	response, _, err := client.SendMessage(context.Background(), env.ProtocolVersion, q)
 	if err != nil {
 		t.Fatal("query failed:", err)
 	}
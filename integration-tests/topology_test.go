@@ -0,0 +1,252 @@
+package integration_tests
+
+import (
+	"context"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/integration-tests/chaos"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/datastax/zdm-proxy/integration-tests/simulacron"
+	"github.com/datastax/zdm-proxy/integration-tests/utils"
+	"github.com/datastax/zdm-proxy/proxy/pkg/zdmproxy"
+	"github.com/stretchr/testify/require"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+const topologyTestQuery = "SELECT * FROM topology_test.tb WHERE id = %d"
+
+// TestMultiNodeRouting checks that each client connection is assigned to a different node of each cluster
+// and that the requests of that connection are sent to the assigned nodes only.
+func TestMultiNodeRouting(t *testing.T) {
+	testSetup, err := setup.NewSimulacronTestSetupWithSessionAndNodes(t, true, false, 3)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+
+	for i := 0; i < 3; i++ {
+		cqlConn := connectToProxy(t)
+		sendTopologyTestQuery(t, cqlConn, i)
+		_ = cqlConn.Close()
+	}
+
+	for _, cluster := range []*simulacron.Cluster{testSetup.Origin, testSetup.Target} {
+		receivedQueries := make(map[string]string)
+		for _, node := range cluster.Datacenters[0].Nodes {
+			queries := getTopologyTestQueries(t, node)
+			require.Equal(t, 1, len(queries), "node %v of %v should have received exactly one query: %v",
+				node.Address, cluster.Name, queries)
+			previousNode, ok := receivedQueries[queries[0]]
+			require.False(t, ok, "query %v was sent to both %v and %v", queries[0], previousNode, node.Address)
+			receivedQueries[queries[0]] = node.Address
+		}
+	}
+}
+
+// TestMultiDatacenterRouting checks that requests are only sent to the nodes of the configured local datacenter
+// which can be different for origin and target.
+func TestMultiDatacenterRouting(t *testing.T) {
+	conf := setup.NewTestConfig("", "")
+	conf.OriginLocalDatacenter = "dc2"
+	conf.TargetLocalDatacenter = "dc1"
+	testSetup, err := setup.NewSimulacronTestSetupWithDatacenters(t, true, false, []int{2, 2}, conf)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+
+	tests := []struct {
+		name          string
+		cluster       *simulacron.Cluster
+		localDcIndex  int
+		remoteDcIndex int
+		localDc       string
+		hosts         func() ([]string, error)
+	}{
+		{"origin", testSetup.Origin, 1, 0, "dc2", func() ([]string, error) {
+			return getHostsInLocalDc(testSetup.Proxy.GetOriginControlConn().GetOrderedHostsInLocalDatacenter())
+		}},
+		{"target", testSetup.Target, 0, 1, "dc1", func() ([]string, error) {
+			return getHostsInLocalDc(testSetup.Proxy.GetTargetControlConn().GetOrderedHostsInLocalDatacenter())
+		}},
+	}
+
+	for i := 0; i < 4; i++ {
+		cqlConn := connectToProxy(t)
+		sendTopologyTestQuery(t, cqlConn, i)
+		_ = cqlConn.Close()
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var expectedHosts []string
+			for _, node := range tt.cluster.Datacenters[tt.localDcIndex].Nodes {
+				expectedHosts = append(expectedHosts, node.Address)
+			}
+			hosts, err := tt.hosts()
+			require.Nil(t, err)
+			require.ElementsMatch(t, expectedHosts, hosts, "hosts in %v should be the nodes of %v", tt.name, tt.localDc)
+
+			for _, node := range tt.cluster.Datacenters[tt.localDcIndex].Nodes {
+				require.Equal(t, 2, len(getTopologyTestQueries(t, node)),
+					"node %v in local datacenter %v should have received 2 queries", node.Address, tt.localDc)
+			}
+			for _, node := range tt.cluster.Datacenters[tt.remoteDcIndex].Nodes {
+				require.Equal(t, 0, len(getTopologyTestQueries(t, node)),
+					"node %v in remote datacenter should not have received queries", node.Address)
+			}
+		})
+	}
+}
+
+// TestMultiNodeNodeDown checks that only the client connections assigned to a node that is down fail and that
+// the other nodes keep serving requests.
+func TestMultiNodeNodeDown(t *testing.T) {
+	for _, cluster := range []string{"origin", "target"} {
+		t.Run(cluster, func(t *testing.T) {
+			testSetup, err := setup.NewSimulacronTestSetupWithSessionAndNodes(t, true, false, 3)
+			require.Nil(t, err)
+			defer testSetup.Cleanup()
+
+			faultyCluster := testSetup.Origin
+			if cluster == "target" {
+				faultyCluster = testSetup.Target
+			}
+			// the control connection uses the first node (contact point)
+			downNode := faultyCluster.Datacenters[0].Nodes[1]
+			err = chaos.KillNode(downNode)
+			require.Nil(t, err)
+
+			failed := 0
+			for i := 0; i < 3; i++ {
+				cqlConn, err := client.NewCqlClient("127.0.0.1:14002", nil).ConnectAndInit(
+					context.Background(), env.ProtocolVersion, client.ManagedStreamId)
+				if err != nil {
+					failed++
+					continue
+				}
+				sendTopologyTestQuery(t, cqlConn, i)
+				_ = cqlConn.Close()
+			}
+			require.Equal(t, 1, failed, "only the connection assigned to %v should fail", downNode.Address)
+
+			for _, node := range faultyCluster.Datacenters[0].Nodes {
+				expected := 1
+				if node == downNode {
+					expected = 0
+				}
+				require.Equal(t, expected, len(getTopologyTestQueries(t, node)),
+					"unexpected number of queries on node %v", node.Address)
+			}
+
+			err = chaos.ReviveNode(downNode)
+			require.Nil(t, err)
+
+			for i := 0; i < 3; i++ {
+				utils.RequireWithRetries(t, func() (err error, fatal bool) {
+					cqlConn, err := client.NewCqlClient("127.0.0.1:14002", nil).ConnectAndInit(
+						context.Background(), env.ProtocolVersion, client.ManagedStreamId)
+					if err != nil {
+						return err, false
+					}
+					_ = cqlConn.Close()
+					return nil, false
+				}, 25, 200*time.Millisecond)
+			}
+		})
+	}
+}
+
+// TestMultiDatacenterTopologyVirtualization checks that the proxy replaces the multi datacenter topology of the
+// clusters with the proxy instances and that topology and status events are not forwarded to the client.
+func TestMultiDatacenterTopologyVirtualization(t *testing.T) {
+	conf := setup.NewTestConfig("", "")
+	conf.ProxyTopologyAddresses = "127.0.0.1,127.0.0.2,127.0.0.3"
+	conf.OriginLocalDatacenter = "dc2"
+	testSetup, err := setup.NewSimulacronTestSetupWithDatacenters(t, true, false, []int{3, 3}, conf)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+
+	cqlConn := connectToProxy(t)
+	defer cqlConn.Close()
+
+	response, err := cqlConn.SendAndReceive(frame.NewFrame(env.ProtocolVersion, 0,
+		&message.Register{EventTypes: []primitive.EventType{primitive.EventTypeTopologyChange, primitive.EventTypeStatusChange}}))
+	require.Nil(t, err)
+	require.IsType(t, &message.Ready{}, response.Body.Message)
+
+	local := queryTopologyTable(t, cqlConn, "SELECT rpc_address, data_center FROM system.local")
+	require.Equal(t, 1, len(local))
+	require.Equal(t, "127.0.0.1", local[0][0])
+	require.Equal(t, "dc2", local[0][1])
+
+	peers := queryTopologyTable(t, cqlConn, "SELECT rpc_address, data_center FROM system.peers")
+	require.ElementsMatch(t, [][]string{{"127.0.0.2", "dc2"}, {"127.0.0.3", "dc2"}}, peers)
+
+	// restart nodes of the remote datacenters (target infers dc1 from the contact point) so that the connections
+	// of this client are not closed
+	for _, node := range []*simulacron.Node{testSetup.Origin.Datacenters[0].Nodes[2], testSetup.Target.Datacenters[1].Nodes[2]} {
+		require.Nil(t, chaos.KillNode(node))
+		require.Nil(t, chaos.ReviveNode(node))
+	}
+
+	eventFrame, err := cqlConn.ReceiveEvent()
+	require.NotNil(t, err, "did not expect to receive an event: %v", eventFrame)
+}
+
+func connectToProxy(t *testing.T) *client.CqlClientConnection {
+	cqlConn, err := client.NewCqlClient("127.0.0.1:14002", nil).ConnectAndInit(
+		context.Background(), env.ProtocolVersion, client.ManagedStreamId)
+	require.Nil(t, err)
+	return cqlConn
+}
+
+func sendTopologyTestQuery(t *testing.T, cqlConn *client.CqlClientConnection, id int) {
+	response, err := cqlConn.SendAndReceive(frame.NewFrame(env.ProtocolVersion, 0,
+		&message.Query{Query: fmt.Sprintf(topologyTestQuery, id)}))
+	require.Nil(t, err)
+	require.Equal(t, primitive.OpCodeResult, response.Header.OpCode, "unexpected response: %v", response.Body.Message)
+}
+
+func getTopologyTestQueries(t *testing.T, node *simulacron.Node) []string {
+	logs, err := node.GetLogsByType(simulacron.QueryTypeQuery)
+	require.Nil(t, err)
+	var queries []string
+	for _, dc := range logs.Datacenters {
+		for _, n := range dc.Nodes {
+			for _, entry := range n.Queries {
+				if strings.HasPrefix(entry.Query, "SELECT * FROM topology_test.tb") {
+					queries = append(queries, entry.Query)
+				}
+			}
+		}
+	}
+	return queries
+}
+
+func getHostsInLocalDc(hosts []*zdmproxy.Host, err error) ([]string, error) {
+	if err != nil {
+		return nil, err
+	}
+	var addresses []string
+	for _, h := range hosts {
+		addresses = append(addresses, fmt.Sprintf("%v:%d", h.Address, h.Port))
+	}
+	return addresses, nil
+}
+
+func queryTopologyTable(t *testing.T, cqlConn *client.CqlClientConnection, query string) [][]string {
+	response, err := cqlConn.SendAndReceive(frame.NewFrame(env.ProtocolVersion, 0, &message.Query{Query: query}))
+	require.Nil(t, err)
+	rows, ok := response.Body.Message.(*message.RowsResult)
+	require.True(t, ok, "expected rows result but got %v", response.Body.Message)
+	var result [][]string
+	for _, row := range rows.Data {
+		require.Equal(t, 2, len(row))
+		result = append(result, []string{net.IP(row[0]).String(), string(row[1])})
+	}
+	return result
+}
@@ -0,0 +1,72 @@
+package integration_tests
+
+import (
+	"context"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func TestTraceContextPropagation(t *testing.T) {
+	const insertQuery = "INSERT INTO ks.tb (pk) VALUES (1)"
+	conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	testSetup, err := setup.NewCqlServerTestSetup(t, conf, false, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+
+	newTraceParentHandler := func(traceParents chan string) client.RequestHandler {
+		return func(request *frame.Frame, conn *client.CqlServerConnection, ctx client.RequestHandlerContext) *frame.Frame {
+			if query, ok := request.Body.Message.(*message.Query); ok && query.Query == insertQuery {
+				traceParents <- string(request.Body.CustomPayload["traceparent"])
+				return frame.NewFrame(request.Header.Version, request.Header.StreamId, &message.VoidResult{})
+			}
+			return nil
+		}
+	}
+	originTraceParents := make(chan string, 1)
+	targetTraceParents := make(chan string, 1)
+	testSetup.Origin.CqlServer.RequestHandlers = []client.RequestHandler{
+		client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+		client.NewSystemTablesHandler("cluster1", "dc1"), newTraceParentHandler(originTraceParents)}
+	testSetup.Target.CqlServer.RequestHandlers = []client.RequestHandler{
+		client.RegisterHandler, client.HeartbeatHandler, client.HandshakeHandler,
+		client.NewSystemTablesHandler("cluster2", "dc2"), newTraceParentHandler(targetTraceParents)}
+	err = testSetup.Start(nil, false, primitive.ProtocolVersion4)
+	require.Nil(t, err)
+
+	conf.TraceContextPropagation = true
+	proxy, err := setup.NewProxyInstanceWithConfig(conf)
+	require.Nil(t, err)
+	defer proxy.Shutdown()
+
+	testClient := client.NewCqlClient(
+		fmt.Sprintf("%s:%d", conf.ProxyListenAddress, conf.ProxyListenPort),
+		&client.AuthCredentials{Username: conf.TargetUsername, Password: conf.TargetPassword})
+	cqlConn, err := testClient.Connect(context.Background())
+	require.Nil(t, err)
+	defer cqlConn.Close()
+	require.Nil(t, cqlConn.InitiateHandshake(primitive.ProtocolVersion4, 0))
+
+	request := frame.NewFrame(primitive.ProtocolVersion4, 0, &message.Query{
+		Query:   insertQuery,
+		Options: &message.QueryOptions{Consistency: primitive.ConsistencyLevelOne},
+	})
+	request.SetCustomPayload(map[string][]byte{
+		"traceparent": []byte("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")})
+	response, err := cqlConn.SendAndReceive(request)
+	require.Nil(t, err)
+	require.Equal(t, primitive.OpCodeResult, response.Body.Message.GetOpCode(), response.Body.Message)
+
+	originTraceParent := <-originTraceParents
+	targetTraceParent := <-targetTraceParents
+	require.True(t, strings.HasPrefix(originTraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-"), originTraceParent)
+	require.True(t, strings.HasSuffix(originTraceParent, "-01"), originTraceParent)
+	require.NotContains(t, originTraceParent, "00f067aa0ba902b7")
+	require.Equal(t, originTraceParent, targetTraceParent)
+}
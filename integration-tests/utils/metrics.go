@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"fmt"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// MetricsSnapshot holds the metric families served by the metrics endpoint of the proxy at some point in time.
+type MetricsSnapshot struct {
+	families map[string]*dto.MetricFamily
+}
+
+// ScrapeMetrics parses the metrics served on http://<ipEndPoint>/metrics.
+func ScrapeMetrics(ipEndPoint string) (*MetricsSnapshot, error) {
+	statusCode, body, err := GetMetrics(ipEndPoint)
+	if err != nil {
+		return nil, err
+	}
+	if statusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", statusCode)
+	}
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(strings.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse metrics: %w", err)
+	}
+	return &MetricsSnapshot{families: families}, nil
+}
+
+// Value returns the sum of the counters, gauges or untyped metrics named name whose labels include the provided ones,
+// e.g. the requests of every node when the node label is not provided. It returns 0 if there is no such metric.
+func (s *MetricsSnapshot) Value(name string, labels map[string]string) float64 {
+	var value float64
+	for _, m := range s.find(name, labels) {
+		switch {
+		case m.GetCounter() != nil:
+			value += m.GetCounter().GetValue()
+		case m.GetGauge() != nil:
+			value += m.GetGauge().GetValue()
+		case m.GetUntyped() != nil:
+			value += m.GetUntyped().GetValue()
+		}
+	}
+	return value
+}
+
+// HistogramCount returns the sum of the sample counts of the histograms named name whose labels include the provided
+// ones.
+func (s *MetricsSnapshot) HistogramCount(name string, labels map[string]string) uint64 {
+	var count uint64
+	for _, m := range s.find(name, labels) {
+		count += m.GetHistogram().GetSampleCount()
+	}
+	return count
+}
+
+// HistogramSum returns the sum of the sample sums of the histograms named name whose labels include the provided
+// ones.
+func (s *MetricsSnapshot) HistogramSum(name string, labels map[string]string) float64 {
+	var sum float64
+	for _, m := range s.find(name, labels) {
+		sum += m.GetHistogram().GetSampleSum()
+	}
+	return sum
+}
+
+func (s *MetricsSnapshot) find(name string, labels map[string]string) []*dto.Metric {
+	if s == nil {
+		return nil
+	}
+	family, ok := s.families[name]
+	if !ok {
+		return nil
+	}
+	var found []*dto.Metric
+	for _, m := range family.GetMetric() {
+		matchedLabels := 0
+		for _, label := range m.GetLabel() {
+			if value, ok := labels[label.GetName()]; ok && value == label.GetValue() {
+				matchedLabels++
+			}
+		}
+		if matchedLabels == len(labels) {
+			found = append(found, m)
+		}
+	}
+	return found
+}
+
+// MetricsAsserter asserts the values of the metrics of a proxy, the assertions are retried until the metrics are
+// updated since the proxy records some of them after the response was returned to the client.
+type MetricsAsserter struct {
+	t          *testing.T
+	ipEndPoint string
+	prefix     string
+	baseline   *MetricsSnapshot
+}
+
+// NewMetricsAsserter returns an asserter for the metrics served on http://<ipEndPoint>/metrics by a proxy with
+// ZDM_METRICS_PREFIX set to prefix. The asserted values are absolute until Reset is called.
+func NewMetricsAsserter(t *testing.T, ipEndPoint string, prefix string) *MetricsAsserter {
+	return &MetricsAsserter{t: t, ipEndPoint: ipEndPoint, prefix: prefix}
+}
+
+// Reset scrapes the current values of the metrics, the assertions that follow are relative to them, e.g. to assert
+// the requests of the last step of a test only.
+func (a *MetricsAsserter) Reset() {
+	snapshot, err := ScrapeMetrics(a.ipEndPoint)
+	require.Nil(a.t, err, "could not scrape the metrics of the proxy: %v", err)
+	a.baseline = snapshot
+}
+
+// RequireValue requires the counter, gauge or untyped metric (summed across the series that have the labels of mn and
+// the provided ones) to have increased by expected since the last Reset.
+func (a *MetricsAsserter) RequireValue(mn metrics.Metric, labels map[string]string, expected float64) {
+	name, mergedLabels := a.nameAndLabels(mn, labels)
+	a.requireEventually(name, mergedLabels, expected, func(s *MetricsSnapshot) float64 {
+		return s.Value(name, mergedLabels)
+	})
+}
+
+// RequireHistogramCount requires the number of observations of the histogram (summed across the series that have the
+// labels of mn and the provided ones) to have increased by expected since the last Reset, e.g. the number of requests
+// that were sent to a cluster.
+func (a *MetricsAsserter) RequireHistogramCount(mn metrics.Metric, labels map[string]string, expected uint64) {
+	name, mergedLabels := a.nameAndLabels(mn, labels)
+	a.requireEventually(name, mergedLabels, float64(expected), func(s *MetricsSnapshot) float64 {
+		return float64(s.HistogramCount(name, mergedLabels))
+	})
+}
+
+func (a *MetricsAsserter) nameAndLabels(mn metrics.Metric, labels map[string]string) (string, map[string]string) {
+	mergedLabels := make(map[string]string, len(mn.GetLabels())+len(labels))
+	for key, value := range mn.GetLabels() {
+		mergedLabels[key] = value
+	}
+	for key, value := range labels {
+		mergedLabels[key] = value
+	}
+	return fmt.Sprintf("%v_%v", a.prefix, mn.GetName()), mergedLabels
+}
+
+func (a *MetricsAsserter) requireEventually(
+	name string, labels map[string]string, expected float64, value func(s *MetricsSnapshot) float64) {
+	RequireWithRetries(a.t, func() (err error, fatal bool) {
+		snapshot, err := ScrapeMetrics(a.ipEndPoint)
+		if err != nil {
+			return err, false
+		}
+		if actual := value(snapshot) - value(a.baseline); actual != expected {
+			return fmt.Errorf("expected %v%v to be %v but was %v", name, labels, expected, actual), false
+		}
+		return nil, false
+	}, 25, 200*time.Millisecond)
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"github.com/datastax/zdm-proxy/integration-tests/env"
 	"github.com/datastax/zdm-proxy/proxy/pkg/health"
 	"github.com/gocql/gocql"
 	"github.com/rs/zerolog"
@@ -135,6 +136,7 @@ func NewCluster(hostname string, username string, password string, port int) *go
 		Password: password,
 	}
 	cluster.Port = port
+	cluster.ProtoVersion = int(env.ProtocolVersion)
 	return cluster
 }
 
@@ -0,0 +1,49 @@
+package integration_tests
+
+import (
+	"context"
+	"github.com/datastax/zdm-proxy/integration-tests/setup"
+	"github.com/datastax/zdm-proxy/proxy/pkg/zdmproxy"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestValidateDeploymentProbe(t *testing.T) {
+	serverConf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+	testSetup, err := setup.NewCqlServerTestSetup(t, serverConf, true, false, false)
+	require.Nil(t, err)
+	defer testSetup.Cleanup()
+
+	tests := []struct {
+		name           string
+		targetPassword string
+		targetErr      string
+	}{
+		{"valid credentials", serverConf.TargetPassword, ""},
+		{"invalid target credentials", "invalid", "could not open control connection to TARGET"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := setup.NewTestConfig("127.0.1.1", "127.0.1.2")
+			conf.TargetPassword = tt.targetPassword
+			require.Nil(t, conf.Validate())
+
+			ctx, cancelFn := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancelFn()
+			checks := zdmproxy.ValidateDeployment(ctx, conf, true)
+			require.Len(t, checks, 7)
+			for _, check := range checks {
+				if check.Name == "TARGET connection" && tt.targetErr != "" {
+					require.NotNil(t, check.Err)
+					require.Contains(t, check.Err.Error(), tt.targetErr)
+					continue
+				}
+				require.Nil(t, check.Err, check.Name)
+			}
+			require.Equal(t, "ORIGIN connection", checks[3].Name)
+			require.Contains(t, checks[3].Detail, "authentication enabled: true")
+		})
+	}
+}
@@ -666,7 +666,7 @@ func TestInterceptedQueries(t *testing.T) {
 				dcodec, err := datacodec.NewCodec(queryRowsResult.Metadata.Columns[j].Type)
 				require.Nil(t, err)
 				var dest interface{}
-				wasNull, err := dcodec.Decode(value, &dest, primitive.ProtocolVersion4)
+				wasNull, err := dcodec.Decode(value, &dest, env.ProtocolVersion)
 				require.Nil(t, err)
 				switch queryRowsResult.Metadata.Columns[j].Name {
 				case "schema_version":
@@ -707,7 +707,7 @@ func TestInterceptedQueries(t *testing.T) {
 			defer proxy.Shutdown()
 
 			testClient := client.NewCqlClient(fmt.Sprintf("%v:14002", proxyAddressToConnect), nil)
-			cqlConnection, err := testClient.ConnectAndInit(context.Background(), primitive.ProtocolVersion4, 0)
+			cqlConnection, err := testClient.ConnectAndInit(context.Background(), env.ProtocolVersion, 0)
 			require.Nil(t, err)
 			defer cqlConnection.Close()
 
@@ -715,7 +715,7 @@ func TestInterceptedQueries(t *testing.T) {
 				Query:   testVars.query,
 				Options: nil,
 			}
-			queryFrame := frame.NewFrame(primitive.ProtocolVersion4, 0, queryMsg)
+			queryFrame := frame.NewFrame(env.ProtocolVersion, 0, queryMsg)
 			queryResponseFrame, err := cqlConnection.SendAndReceive(queryFrame)
 			require.Nil(t, err)
 			if testVars.errExpected != nil {
@@ -728,7 +728,7 @@ func TestInterceptedQueries(t *testing.T) {
 				Query:    testVars.query,
 				Keyspace: "",
 			}
-			prepareFrame := frame.NewFrame(primitive.ProtocolVersion4, 0, prepareMsg)
+			prepareFrame := frame.NewFrame(env.ProtocolVersion, 0, prepareMsg)
 			prepareResponseFrame, err := cqlConnection.SendAndReceive(prepareFrame)
 			require.Nil(t, err)
 			if testVars.errExpected != nil {
@@ -741,7 +741,7 @@ func TestInterceptedQueries(t *testing.T) {
 					ResultMetadataId: preparedMsg.ResultMetadataId,
 					Options:          nil,
 				}
-				executeFrame := frame.NewFrame(primitive.ProtocolVersion4, 0, executeMsg)
+				executeFrame := frame.NewFrame(env.ProtocolVersion, 0, executeMsg)
 				executeResponseFrame, err := cqlConnection.SendAndReceive(executeFrame)
 				require.Nil(t, err)
 				checkRowsResultFunc(t, testVars, executeResponseFrame)
@@ -846,7 +846,7 @@ func TestVirtualizationPartitioner(t *testing.T) {
 			client.NewDriverConnectionInitializationHandler("target", "dc2", func(_ string) {}),
 		}
 
-		err = testSetup.Start(nil, false, primitive.ProtocolVersion4)
+		err = testSetup.Start(nil, false, env.ProtocolVersion)
 		require.Nil(t, err)
 
 		validatePartitionerFromSystemLocal(t, originAddress+":9042", credentials, originPartitioner)
@@ -968,7 +968,7 @@ func computeReplicas(n int, numTokens int) []*replica {
 func validatePartitionerFromSystemLocal(t *testing.T, remoteEndpoint string, credentials *client.AuthCredentials, expectedPartitioner string) {
 
 	testClient := client.NewCqlClient(remoteEndpoint, credentials)
-	cqlConn, err := testClient.ConnectAndInit(context.Background(), primitive.ProtocolVersion4, 1)
+	cqlConn, err := testClient.ConnectAndInit(context.Background(), env.ProtocolVersion, 1)
 	require.Nil(t, err, "testClient setup failed", err)
 	require.NotNil(t, cqlConn, "cql connection could not be opened")
 	defer func() {
@@ -984,7 +984,7 @@ func validatePartitionerFromSystemLocal(t *testing.T, remoteEndpoint string, cre
 		},
 	}
 
-	queryFrame := frame.NewFrame(primitive.ProtocolVersion4, 0, requestMsg)
+	queryFrame := frame.NewFrame(env.ProtocolVersion, 0, requestMsg)
 	response, err := cqlConn.SendAndReceive(queryFrame)
 	require.Nil(t, err)
 
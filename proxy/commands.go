@@ -0,0 +1,287 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/bench"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"github.com/datastax/zdm-proxy/proxy/pkg/zdmproxy"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runCommand runs the subcommand in args[0] and returns the exit code of the process.
+func runCommand(args []string) int {
+	switch args[0] {
+	case "validate":
+		return runValidateCommand(args[1:])
+	case "generate-config":
+		return runGenerateConfigCommand(args[1:], os.Stdin)
+	case "bench":
+		return runBenchCommand(args[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %v, possible commands are: validate, generate-config and bench\n", args[0])
+		return 2
+	}
+}
+
+// runValidateCommand parses the configuration from the environment like the proxy does and runs the checks of
+// zdmproxy.ValidateDeployment. It is meant to be used in deployment pipelines before the proxy is (re)started: the exit
+// code is 0 only if every check passed.
+func runValidateCommand(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	probe := flags.Bool("probe", false, "Connect and authenticate to both clusters")
+	timeout := flags.Duration("timeout", 30*time.Second, "Maximum duration of the checks")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: zdm-proxy validate [-probe] [-timeout duration]\n\n"+
+			"Validates the configuration in the ZDM_* environment variables without serving traffic.\n\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	// only the results of the checks are printed unless a more verbose log level is configured
+	log.SetLevel(log.WarnLevel)
+	conf, err := config.New().ParseEnvVars()
+	if err != nil {
+		fmt.Printf("[FAILED] configuration: %v\n", err)
+		return 1
+	}
+	if logLevel, err := conf.ParseLogLevel(); err == nil && logLevel > log.InfoLevel {
+		log.SetLevel(logLevel)
+	}
+	fmt.Printf("[OK] configuration\n")
+
+	ctx, cancelFn := context.WithTimeout(context.Background(), *timeout)
+	defer cancelFn()
+	exitCode := 0
+	for _, check := range zdmproxy.ValidateDeployment(ctx, conf, *probe) {
+		if check.Err != nil {
+			exitCode = 1
+			fmt.Printf("[FAILED] %v: %v\n", check.Name, check.Err)
+		} else if check.Detail != "" {
+			fmt.Printf("[OK] %v: %v\n", check.Name, check.Detail)
+		} else {
+			fmt.Printf("[OK] %v\n", check.Name)
+		}
+	}
+	return exitCode
+}
+
+// runGenerateConfigCommand writes the environment file of one of the config.Scenarios. Every setting of the scenario
+// can be provided with a flag (e.g. -origin-contact-points for ZDM_ORIGIN_CONTACT_POINTS), the settings that are not
+// provided are prompted on the terminal unless -non-interactive is set. Prompts are written to stderr so that the
+// configuration can be redirected when -output is not set.
+func runGenerateConfigCommand(args []string, input io.Reader) int {
+	flags := flag.NewFlagSet("generate-config", flag.ContinueOnError)
+	scenarioName := flags.String("scenario", "", "Scenario of the configuration")
+	output := flags.String("output", "", "File the configuration is written to (default: stdout)")
+	nonInteractive := flags.Bool("non-interactive", false, "Fail instead of prompting the settings that are not provided")
+	settingFlags := make(map[string]*string)
+	for _, scenario := range config.Scenarios {
+		for _, setting := range scenario.Settings {
+			if _, ok := settingFlags[setting.EnvVar]; !ok {
+				settingFlags[setting.EnvVar] = flags.String(settingFlagName(setting.EnvVar), "", setting.Prompt)
+			}
+		}
+	}
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: zdm-proxy generate-config [-scenario name] [-output file] [-non-interactive] "+
+			"[-<setting> value ...]\n\nGenerates a validated ZDM_* environment file. Scenarios:\n")
+		for _, scenario := range config.Scenarios {
+			fmt.Fprintf(flags.Output(), "  %v: %v\n", scenario.Name, scenario.Description)
+		}
+		fmt.Fprintln(flags.Output())
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	log.SetLevel(log.WarnLevel)
+	reader := bufio.NewReader(input)
+	prompt := func(text string) (string, error) {
+		fmt.Fprintf(os.Stderr, "%v: ", text)
+		line, err := reader.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return "", fmt.Errorf("could not read %v: %w", text, err)
+		}
+		return strings.TrimSpace(line), nil
+	}
+
+	if *scenarioName == "" {
+		if *nonInteractive {
+			fmt.Fprintln(os.Stderr, "-scenario is required")
+			return 2
+		}
+		fmt.Fprintf(os.Stderr, "Scenarios:\n")
+		for i, scenario := range config.Scenarios {
+			fmt.Fprintf(os.Stderr, "  %d. %v: %v\n", i+1, scenario.Name, scenario.Description)
+		}
+		choice, err := prompt("Scenario (name or number)")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		if index, err := strconv.Atoi(choice); err == nil && index >= 1 && index <= len(config.Scenarios) {
+			choice = config.Scenarios[index-1].Name
+		}
+		*scenarioName = choice
+	}
+	scenario := config.FindScenario(*scenarioName)
+	if scenario == nil {
+		fmt.Fprintf(os.Stderr, "Unknown scenario %v\n", *scenarioName)
+		return 2
+	}
+
+	values := make(map[string]string)
+	for _, setting := range scenario.Settings {
+		if value := *settingFlags[setting.EnvVar]; value != "" {
+			values[setting.EnvVar] = value
+			continue
+		}
+		if *nonInteractive {
+			continue
+		}
+		text := setting.Prompt
+		if setting.Default != "" {
+			text = fmt.Sprintf("%v [%v]", text, setting.Default)
+		}
+		value, err := prompt(text)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		values[setting.EnvVar] = value
+	}
+
+	envFile, err := config.GenerateEnvFile(scenario, values)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if *output == "" {
+		fmt.Print(envFile)
+		return 0
+	}
+	// the file can contain credentials
+	err = os.WriteFile(*output, []byte(envFile), 0600)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Could not write the configuration: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "Configuration written to %v, check it with: zdm-proxy validate -probe\n", *output)
+	return 0
+}
+
+// runBenchCommand runs a synthetic workload against the proxy at -address, or against a proxy started in this process
+// from the ZDM_* environment variables with -in-process, and prints the throughput and latencies of the client and the
+// requests that the proxy sent to each cluster. The per cluster results are read from the metrics of the proxy so they
+// include the requests of the other clients of a running proxy.
+func runBenchCommand(args []string) int {
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	inProcess := flags.Bool("in-process", false, "Start a proxy from the ZDM_* environment variables in this process")
+	address := flags.String("address", "127.0.0.1:9042", "Address of the proxy (ignored with -in-process)")
+	metricsUrl := flags.String("metrics-url", "http://127.0.0.1:14001/metrics",
+		"Metrics endpoint of the proxy, empty to skip the per cluster results (ignored with -in-process)")
+	metricsPrefix := flags.String("metrics-prefix", "zdm", "ZDM_METRICS_PREFIX of the proxy (ignored with -in-process)")
+	username := flags.String("username", "", "Username of the client")
+	password := flags.String("password", "", "Password of the client")
+	protocolVersion := flags.Int("protocol-version", int(primitive.ProtocolVersion4), "Protocol version of the client")
+	workload := flags.String("workload", string(bench.WorkloadMixed), "Workload: write, read or mixed (50% reads)")
+	concurrency := flags.Int("concurrency", 16, "Number of connections, each one sends one request at a time")
+	duration := flags.Duration("duration", 30*time.Second, "Duration of the workload")
+	keyspace := flags.String("keyspace", "zdm_bench", "Keyspace of the table of the workload")
+	replicationFactor := flags.Int("replication-factor", 1, "Replication factor of the keyspace when it is created")
+	createSchema := flags.Bool("create-schema", true, "Create the keyspace and table of the workload on both clusters")
+	partitions := flags.Int64("partitions", 100000, "Number of distinct partitions")
+	valueSize := flags.Int("value-size", 100, "Size in bytes of the values that are written")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: zdm-proxy bench [-in-process | -address host:port [-metrics-url url]] "+
+			"[-workload name] [-concurrency n] [-duration duration] [...]\n\n"+
+			"Drives a synthetic CQL workload through the proxy and reports its throughput and latencies.\n\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	parsedWorkload, err := bench.ParseWorkload(*workload)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	log.SetLevel(log.WarnLevel)
+	// the CQL client logs every connection
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+	runSignalListener(cancelFn)
+
+	benchConfig := &bench.Config{
+		Address:           *address,
+		ProtocolVersion:   primitive.ProtocolVersion(*protocolVersion),
+		Workload:          parsedWorkload,
+		Concurrency:       *concurrency,
+		Duration:          *duration,
+		Keyspace:          *keyspace,
+		ReplicationFactor: *replicationFactor,
+		CreateSchema:      *createSchema,
+		Partitions:        *partitions,
+		ValueSize:         *valueSize,
+		MetricsPrefix:     *metricsPrefix,
+	}
+	if *username != "" {
+		benchConfig.Credentials = &client.AuthCredentials{Username: *username, Password: *password}
+	}
+	if *metricsUrl != "" {
+		benchConfig.Metrics = bench.HttpMetricsSource(*metricsUrl)
+	}
+	if *inProcess {
+		conf, err := config.New().ParseEnvVars()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid configuration: %v\n", err)
+			return 1
+		}
+		zdmProxy, err := zdmproxy.Run(conf, ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Could not start the proxy: %v\n", err)
+			return 1
+		}
+		defer zdmProxy.Shutdown()
+		benchConfig.Address = net.JoinHostPort(conf.ProxyListenAddress, strconv.Itoa(conf.ProxyListenPort))
+		benchConfig.Metrics, benchConfig.MetricsPrefix = nil, conf.MetricsPrefix
+		if conf.MetricsEnabled {
+			benchConfig.Metrics = bench.GathererMetricsSource(prometheus.DefaultGatherer)
+		}
+	}
+
+	report, err := bench.Run(ctx, benchConfig)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Benchmark failed: %v\n", err)
+		return 1
+	}
+	if err = report.Write(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// settingFlagName returns the flag of the generate-config command for a setting, e.g. origin-contact-points for
+// ZDM_ORIGIN_CONTACT_POINTS.
+func settingFlagName(envVar string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimPrefix(envVar, "ZDM_"), "_", "-"))
+}
@@ -2,14 +2,21 @@ package main
 
 import (
 	"context"
+	"github.com/datastax/zdm-proxy/proxy/pkg/buildinfo"
 	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"github.com/datastax/zdm-proxy/proxy/pkg/errorreporting"
+	"github.com/datastax/zdm-proxy/proxy/pkg/logging"
+	"github.com/datastax/zdm-proxy/proxy/pkg/profiling"
 	"github.com/datastax/zdm-proxy/proxy/pkg/runner"
+	"github.com/datastax/zdm-proxy/proxy/pkg/zdmproxy"
 	log "github.com/sirupsen/logrus"
 	"os"
 	"os/signal"
 	"syscall"
 )
 
+const ZdmVersionString = buildinfo.Version
+
 func runSignalListener(cancelFunc context.CancelFunc) {
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
@@ -37,6 +44,37 @@ func launchProxy(profilingSupported bool) {
 	}
 	log.SetLevel(logLevel)
 
+	logOutput, err := logging.Install(conf)
+	if err != nil {
+		log.Errorf("Error loading log output configuration: %v. Aborting startup.", err)
+		os.Exit(-1)
+	}
+	if logOutput != nil {
+		defer logOutput.Close()
+	}
+
+	logRedactionConfig, err := conf.ParseLogRedactionConfig()
+	if err != nil {
+		log.Errorf("Error loading log redaction configuration: %v. Aborting startup.", err)
+		os.Exit(-1)
+	}
+	zdmproxy.SetLogRedaction(logRedactionConfig)
+	if logRedactionConfig != nil {
+		log.Infof("Log redaction enabled: %v.", logRedactionConfig)
+	}
+
+	errorReporter, err := errorreporting.NewReporter(conf, ZdmVersionString)
+	if err != nil {
+		log.Errorf("Error loading error reporting configuration: %v. Aborting startup.", err)
+		os.Exit(-1)
+	}
+	if errorReporter != nil {
+		errorReporter.Install()
+		defer errorReporter.Close()
+		defer errorreporting.ReportPanic()
+		log.Infof("Errors and panics are reported to the error reporting server.")
+	}
+
 	if profilingSupported {
 		log.Debugf("Proxy built with profiling support")
 	} else {
@@ -47,6 +85,15 @@ func launchProxy(profilingSupported bool) {
 	runSignalListener(cancelFunc)
 	log.Info("SIGINT/SIGTERM listener started.")
 
+	profilingPusher, err := profiling.NewPusher(conf, ZdmVersionString)
+	if err != nil {
+		log.Errorf("Error loading continuous profiling configuration: %v. Aborting startup.", err)
+		os.Exit(-1)
+	}
+	if profilingPusher != nil {
+		go profilingPusher.Run(ctx)
+	}
+
 	metricsHandler, readinessHandler := runner.SetupHandlers()
 	runner.RunMain(conf, ctx, metricsHandler, readinessHandler)
 }
@@ -10,24 +10,26 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/datastax/zdm-proxy/proxy/pkg/buildinfo"
 	log "github.com/sirupsen/logrus"
 )
 
-// TODO: to be managed externally
-const ZdmVersionString = "2.1.0"
-
 var displayVersion = flag.Bool("version", false, "Display the ZDM proxy version and exit")
 
 func main() {
 
 	flag.Parse()
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(flag.Args()))
+	}
 	if *displayVersion {
-		fmt.Printf("ZDM proxy version %v\n", ZdmVersionString)
+		info := buildinfo.Get()
+		fmt.Printf("ZDM proxy version %v (git sha %v, built %v with %v)\n", info.Version, info.GitSha, info.BuildDate, info.GoVersion)
 		os.Exit(0)
 	}
 
 	// Always record version information (very) early in the log
-	log.Infof("Starting ZDM proxy version %v", ZdmVersionString)
+	log.Infof("Starting ZDM proxy version %v (git sha %v)", ZdmVersionString, buildinfo.Get().GitSha)
 
 	launchProxy(false)
 }
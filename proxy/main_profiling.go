@@ -19,6 +19,9 @@ var memProfile = flag.String("mem_profile", "", "write memory profile to the spe
 func main() {
 
 	flag.Parse()
+	if flag.NArg() > 0 {
+		os.Exit(runCommand(flag.Args()))
+	}
 
 	// the cpu profiling is enabled at startup and is periodically collected while the proxy is running
 	// if cpu profiling is requested, any error configuring or starting it will cause the proxy startup to fail
@@ -0,0 +1,346 @@
+package admin
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/zdmproxy"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultHandler is served while the proxy is not running or when ZDM_ADMIN_API_ENABLED is false.
+func DefaultHandler() http.Handler {
+	return http.NotFoundHandler()
+}
+
+// Handler serves the admin operations of the proxy:
+//
+//	POST /admin/cluster-roles/swap          makes the secondary cluster the primary one, see ZdmProxy.SwapClusterRoles
+//	GET /admin/faults                       returns the injected faults by cluster, see zdmproxy.FaultInjector
+//	PUT /admin/faults/{origin|target}       injects the fault of the JSON body (zdmproxy.Fault) in a cluster
+//	DELETE /admin/faults/{origin|target}    stops injecting faults in a cluster
+//	GET /admin/frames[?client=host[:port]]  returns the recent frames of the client connections, see ZDM_FRAME_CAPTURE_SIZE
+//	GET /admin/frame-export                 returns the format, directory and client IPs of the frame export
+//	PUT /admin/frame-export/{ip}            starts exporting the frames of the connections of a client IP to files
+//	DELETE /admin/frame-export/{ip}         stops exporting the frames of the connections of a client IP
+//	GET /admin/heavy-hitters                returns the top statements by count and by latency of each cluster
+//	DELETE /admin/heavy-hitters             clears the tracked statements, see ZDM_HEAVY_HITTERS_SIZE
+//	GET /admin/statement-fingerprints       returns the statements of the fingerprint labels of the statement metrics
+//	GET /admin/prepared-statements[?sort=executions|last_used]
+//	                                        returns the usage statistics of the cached prepared statements
+//	GET /admin/statement-rules              returns the statement rules, see ZDM_STATEMENT_RULES_FILE
+//	POST /admin/statement-rules[?position=n]
+//	                                        adds the statement rule of the JSON body (zdmproxy.StatementRule)
+//	DELETE /admin/statement-rules/{name}    removes a statement rule
+//	GET /admin/schema-diff                  compares the schemas of the tables and UDTs of both clusters, see ZdmProxy.CheckSchemas
+//	GET /admin/sessions                     returns the active client connections, see zdmproxy.ClientSession
+//	DELETE /admin/sessions/{id|ip}          gracefully closes a client connection or all the connections of a client IP
+//
+// The faults can only be injected if ZDM_FAULT_INJECTION_ENABLED is true and the frames can only be exported if
+// ZDM_FRAME_EXPORT_DIR is set. The changes of the statement rules are logged and they are lost when the proxy restarts.
+// If the tokens or client certificate names of the roles are set, the GET operations require the read-only role and
+// the other ones require the operator role, see httpzdmproxy.Authorizer.
+func Handler(proxy *zdmproxy.ZdmProxy) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/admin/cluster-roles/swap", func(rsp http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			rsp.Header().Set("Allow", http.MethodPost)
+			http.Error(rsp, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		primaryCluster, err := proxy.SwapClusterRoles(req.Context())
+		if err != nil {
+			log.Errorf("Admin API: %v", err)
+			http.Error(rsp, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJson(rsp, &ClusterRoles{PrimaryCluster: string(primaryCluster)})
+	})
+	mux.HandleFunc("/admin/faults", func(rsp http.ResponseWriter, req *http.Request) {
+		faultInjector := proxy.GetFaultInjector()
+		if faultInjector == nil {
+			http.Error(rsp, faultInjectionDisabledMsg, http.StatusNotFound)
+			return
+		}
+		if req.Method != http.MethodGet {
+			rsp.Header().Set("Allow", http.MethodGet)
+			http.Error(rsp, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJson(rsp, faultInjector.GetFaults())
+	})
+	mux.HandleFunc("/admin/faults/", func(rsp http.ResponseWriter, req *http.Request) {
+		faultInjector := proxy.GetFaultInjector()
+		if faultInjector == nil {
+			http.Error(rsp, faultInjectionDisabledMsg, http.StatusNotFound)
+			return
+		}
+		cluster := common.ClusterType(strings.ToUpper(strings.TrimPrefix(req.URL.Path, "/admin/faults/")))
+		if cluster != common.ClusterTypeOrigin && cluster != common.ClusterTypeTarget {
+			http.NotFound(rsp, req)
+			return
+		}
+		switch req.Method {
+		case http.MethodPut:
+			// all the responses are affected unless the body sets another percentage
+			fault := &zdmproxy.Fault{Percent: 100}
+			err := json.NewDecoder(req.Body).Decode(fault)
+			if err == nil {
+				err = faultInjector.SetFault(cluster, fault)
+			}
+			if err != nil {
+				http.Error(rsp, fmt.Sprintf("invalid fault: %v", err), http.StatusBadRequest)
+				return
+			}
+		case http.MethodDelete:
+			faultInjector.ClearFault(cluster)
+		default:
+			rsp.Header().Set("Allow", http.MethodPut+", "+http.MethodDelete)
+			http.Error(rsp, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJson(rsp, faultInjector.GetFaults())
+	})
+	mux.HandleFunc("/admin/frames", func(rsp http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			rsp.Header().Set("Allow", http.MethodGet)
+			http.Error(rsp, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		dumps, enabled := proxy.DumpFrameCaptures(req.URL.Query().Get("client"))
+		if !enabled {
+			http.Error(rsp, "frame capture is disabled, set ZDM_FRAME_CAPTURE_SIZE to enable it", http.StatusNotFound)
+			return
+		}
+		writeJson(rsp, dumps)
+	})
+	mux.HandleFunc("/admin/frame-export", func(rsp http.ResponseWriter, req *http.Request) {
+		frameExporter := proxy.GetFrameExporter()
+		if frameExporter == nil {
+			http.Error(rsp, frameExportDisabledMsg, http.StatusNotFound)
+			return
+		}
+		if req.Method != http.MethodGet {
+			rsp.Header().Set("Allow", http.MethodGet)
+			http.Error(rsp, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJson(rsp, newFrameExport(frameExporter))
+	})
+	mux.HandleFunc("/admin/frame-export/", func(rsp http.ResponseWriter, req *http.Request) {
+		frameExporter := proxy.GetFrameExporter()
+		if frameExporter == nil {
+			http.Error(rsp, frameExportDisabledMsg, http.StatusNotFound)
+			return
+		}
+		client := strings.TrimPrefix(req.URL.Path, "/admin/frame-export/")
+		var err error
+		switch req.Method {
+		case http.MethodPut:
+			err = frameExporter.Enable(client)
+		case http.MethodDelete:
+			err = frameExporter.Disable(client)
+		default:
+			rsp.Header().Set("Allow", http.MethodPut+", "+http.MethodDelete)
+			http.Error(rsp, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if err != nil {
+			http.Error(rsp, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJson(rsp, newFrameExport(frameExporter))
+	})
+	mux.HandleFunc("/admin/heavy-hitters", func(rsp http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodGet:
+			heavyHitters, since, enabled := proxy.GetHeavyHitters()
+			if !enabled {
+				http.Error(rsp, heavyHittersDisabledMsg, http.StatusNotFound)
+				return
+			}
+			writeJson(rsp, &HeavyHitters{Since: since, Clusters: heavyHitters})
+		case http.MethodDelete:
+			if !proxy.ResetHeavyHitters() {
+				http.Error(rsp, heavyHittersDisabledMsg, http.StatusNotFound)
+				return
+			}
+			rsp.WriteHeader(http.StatusNoContent)
+		default:
+			rsp.Header().Set("Allow", http.MethodGet+", "+http.MethodDelete)
+			http.Error(rsp, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+	mux.HandleFunc("/admin/statement-fingerprints", func(rsp http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			rsp.Header().Set("Allow", http.MethodGet)
+			http.Error(rsp, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		fingerprints, enabled := proxy.GetStatementFingerprints()
+		if !enabled {
+			http.Error(rsp, "statement metrics are disabled, set ZDM_STATEMENT_METRICS_MAX_FINGERPRINTS to enable them",
+				http.StatusNotFound)
+			return
+		}
+		writeJson(rsp, fingerprints)
+	})
+	mux.HandleFunc("/admin/prepared-statements", func(rsp http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			rsp.Header().Set("Allow", http.MethodGet)
+			http.Error(rsp, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		stats := proxy.PreparedStatementCache.GetStats()
+		switch req.URL.Query().Get("sort") {
+		case "", "executions":
+		case "last_used":
+			// the statements that were never executed or not for the longest time come first, they are the stale ones
+			sort.SliceStable(stats, func(i, j int) bool {
+				if stats[i].LastUsed == nil || stats[j].LastUsed == nil {
+					return stats[i].LastUsed == nil && stats[j].LastUsed != nil
+				}
+				return stats[i].LastUsed.Before(*stats[j].LastUsed)
+			})
+		default:
+			http.Error(rsp, "invalid sort, possible values are: executions and last_used", http.StatusBadRequest)
+			return
+		}
+		writeJson(rsp, stats)
+	})
+	mux.HandleFunc("/admin/statement-rules", func(rsp http.ResponseWriter, req *http.Request) {
+		switch req.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			position := -1
+			if value := req.URL.Query().Get("position"); value != "" {
+				var err error
+				position, err = strconv.Atoi(value)
+				if err != nil || position < 0 {
+					http.Error(rsp, "invalid position, it must be a positive integer or 0", http.StatusBadRequest)
+					return
+				}
+			}
+			rule := &zdmproxy.StatementRule{}
+			err := json.NewDecoder(req.Body).Decode(rule)
+			if err == nil {
+				err = proxy.AddStatementRule(rule, position, req.RemoteAddr)
+			}
+			if err != nil {
+				http.Error(rsp, fmt.Sprintf("invalid statement rule: %v", err), http.StatusBadRequest)
+				return
+			}
+		default:
+			rsp.Header().Set("Allow", http.MethodGet+", "+http.MethodPost)
+			http.Error(rsp, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		rules, _ := proxy.GetStatementRules()
+		writeJson(rsp, rules)
+	})
+	mux.HandleFunc("/admin/statement-rules/", func(rsp http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodDelete {
+			rsp.Header().Set("Allow", http.MethodDelete)
+			http.Error(rsp, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		removed, err := proxy.RemoveStatementRule(strings.TrimPrefix(req.URL.Path, "/admin/statement-rules/"), req.RemoteAddr)
+		if err != nil {
+			http.Error(rsp, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !removed {
+			http.NotFound(rsp, req)
+			return
+		}
+		rules, _ := proxy.GetStatementRules()
+		writeJson(rsp, rules)
+	})
+	mux.HandleFunc("/admin/schema-diff", func(rsp http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			rsp.Header().Set("Allow", http.MethodGet)
+			http.Error(rsp, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		diff, err := proxy.CheckSchemas(req.Context())
+		if err != nil {
+			log.Errorf("Admin API: %v", err)
+			http.Error(rsp, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		writeJson(rsp, diff)
+	})
+	mux.HandleFunc("/admin/sessions", func(rsp http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			rsp.Header().Set("Allow", http.MethodGet)
+			http.Error(rsp, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJson(rsp, proxy.GetClientSessions())
+	})
+	mux.HandleFunc("/admin/sessions/", func(rsp http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodDelete {
+			rsp.Header().Set("Allow", http.MethodDelete)
+			http.Error(rsp, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		sessions := proxy.CloseClientSessions(strings.TrimPrefix(req.URL.Path, "/admin/sessions/"))
+		if len(sessions) == 0 {
+			http.NotFound(rsp, req)
+			return
+		}
+		writeJson(rsp, sessions)
+	})
+	return mux
+}
+
+const (
+	faultInjectionDisabledMsg = "fault injection is disabled, set ZDM_FAULT_INJECTION_ENABLED to enable it"
+	frameExportDisabledMsg    = "frame export is disabled, set ZDM_FRAME_EXPORT_DIR to enable it"
+	heavyHittersDisabledMsg   = "heavy hitter tracking is disabled, set ZDM_HEAVY_HITTERS_SIZE to enable it"
+)
+
+// ClusterRoles is the response of the swap of the cluster roles.
+type ClusterRoles struct {
+	PrimaryCluster string `json:"primary_cluster"`
+}
+
+// FrameExport is the state of the frame export.
+type FrameExport struct {
+	Format  string   `json:"format"`
+	Dir     string   `json:"dir"`
+	Clients []string `json:"clients"`
+}
+
+func newFrameExport(frameExporter *zdmproxy.FrameExporter) *FrameExport {
+	return &FrameExport{
+		Format:  frameExporter.GetFormat().String(),
+		Dir:     frameExporter.GetDir(),
+		Clients: frameExporter.GetEnabledClients(),
+	}
+}
+
+// HeavyHitters are the top statements of each cluster since the proxy started or since they were cleared.
+type HeavyHitters struct {
+	Since    time.Time                                     `json:"since"`
+	Clusters map[common.ClusterType]*zdmproxy.HeavyHitters `json:"clusters"`
+}
+
+func writeJson(rsp http.ResponseWriter, value interface{}) {
+	bytes, err := json.Marshal(value)
+	if err != nil {
+		http.Error(rsp, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	rsp.Header().Set("Content-Type", "application/json")
+	rsp.WriteHeader(http.StatusOK)
+	_, err = rsp.Write(bytes)
+	if err != nil {
+		log.Warnf("Admin API: could not write the response: %v", err)
+	}
+}
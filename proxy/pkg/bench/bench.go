@@ -0,0 +1,375 @@
+// Package bench drives synthetic CQL workloads through a proxy to size the proxy instances before the cutover. The
+// latencies are measured by the client for each operation and, when the metrics of the proxy are available, the
+// requests that the proxy sent to each cluster are derived from the difference of its node metrics before and after
+// the workload.
+package bench
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"io"
+	"math/rand"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+type Workload string
+
+const (
+	WorkloadWrite = Workload("write")
+	WorkloadRead  = Workload("read")
+	WorkloadMixed = Workload("mixed")
+)
+
+func ParseWorkload(workload string) (Workload, error) {
+	switch Workload(strings.ToLower(workload)) {
+	case WorkloadWrite:
+		return WorkloadWrite, nil
+	case WorkloadRead:
+		return WorkloadRead, nil
+	case WorkloadMixed:
+		return WorkloadMixed, nil
+	default:
+		return "", fmt.Errorf("unknown workload %v, possible values are: write, read and mixed", workload)
+	}
+}
+
+const (
+	operationRead  = "read"
+	operationWrite = "write"
+	operationTotal = "total"
+
+	benchTable = "bench"
+)
+
+type Config struct {
+	// Address of the CQL listener of the proxy (host:port)
+	Address         string
+	Credentials     *client.AuthCredentials
+	ProtocolVersion primitive.ProtocolVersion
+
+	Workload Workload
+	// number of connections, each one sends one request at a time
+	Concurrency int
+	Duration    time.Duration
+
+	Keyspace          string
+	ReplicationFactor int
+	CreateSchema      bool
+	// the partitions are chosen randomly in [0, Partitions)
+	Partitions int64
+	ValueSize  int
+
+	// optional, the per cluster results are only reported when it is set
+	Metrics       MetricsSource
+	MetricsPrefix string
+}
+
+func (c *Config) validate() error {
+	if c.Concurrency <= 0 {
+		return fmt.Errorf("invalid concurrency %d, it must be positive", c.Concurrency)
+	}
+	if c.Duration <= 0 {
+		return fmt.Errorf("invalid duration %v, it must be positive", c.Duration)
+	}
+	if c.Partitions <= 0 {
+		return fmt.Errorf("invalid number of partitions %d, it must be positive", c.Partitions)
+	}
+	if c.ValueSize < 0 {
+		return fmt.Errorf("invalid value size %d, it must not be negative", c.ValueSize)
+	}
+	if c.Keyspace == "" {
+		return errors.New("the keyspace is required")
+	}
+	if c.CreateSchema && c.ReplicationFactor <= 0 {
+		return fmt.Errorf("invalid replication factor %d, it must be positive", c.ReplicationFactor)
+	}
+	return nil
+}
+
+type OperationResult struct {
+	Operation string
+	Requests  int64
+	Errors    int64
+	Mean      time.Duration
+	P50       time.Duration
+	P95       time.Duration
+	P99       time.Duration
+	Max       time.Duration
+}
+
+type ClusterResult struct {
+	Cluster  common.ClusterType
+	Requests int64
+	Mean     time.Duration
+	// upper bound of the bucket of the node metrics histogram that contains the 99th percentile, zero if it is above
+	// the largest bucket
+	P99 time.Duration
+}
+
+type Report struct {
+	Address     string
+	Workload    Workload
+	Concurrency int
+	Duration    time.Duration
+
+	// read and write (depending on the workload) followed by the total
+	Operations []*OperationResult
+	Clusters   []*ClusterResult
+	// set when the metrics of the proxy could not be read, Clusters is empty in that case
+	ClustersErr error
+}
+
+// Run creates the schema of the workload if requested and sends requests to the proxy from Config.Concurrency
+// connections for Config.Duration. The errors returned by the proxy are counted, the connection errors abort the run.
+func Run(ctx context.Context, conf *Config) (*Report, error) {
+	if err := conf.validate(); err != nil {
+		return nil, err
+	}
+
+	cqlClient := client.NewCqlClient(conf.Address, conf.Credentials)
+	connections := make([]*client.CqlClientConnection, 0, conf.Concurrency)
+	defer func() {
+		for _, conn := range connections {
+			_ = conn.Close()
+		}
+	}()
+	for i := 0; i < conf.Concurrency; i++ {
+		conn, err := cqlClient.ConnectAndInit(ctx, conf.ProtocolVersion, client.ManagedStreamId)
+		if err != nil {
+			return nil, fmt.Errorf("could not connect to %v: %w", conf.Address, err)
+		}
+		connections = append(connections, conn)
+	}
+
+	if conf.CreateSchema {
+		if err := createSchema(connections[0], conf); err != nil {
+			return nil, err
+		}
+	}
+
+	var metricsBefore map[string]*histogramSnapshot
+	var metricsErr error
+	if conf.Metrics != nil {
+		metricsBefore, metricsErr = readClusterHistograms(ctx, conf.Metrics, conf.MetricsPrefix)
+	}
+
+	workloadCtx, cancelFn := context.WithTimeout(ctx, conf.Duration)
+	defer cancelFn()
+	workers := make([]*worker, len(connections))
+	wg := &sync.WaitGroup{}
+	start := time.Now()
+	for i, conn := range connections {
+		workers[i] = newWorker(conn, conf, rand.New(rand.NewSource(start.UnixNano()+int64(i))))
+		wg.Add(1)
+		go func(w *worker) {
+			defer wg.Done()
+			w.run(workloadCtx)
+		}(workers[i])
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+	for _, w := range workers {
+		if w.err != nil {
+			return nil, w.err
+		}
+	}
+
+	report := &Report{
+		Address:     conf.Address,
+		Workload:    conf.Workload,
+		Concurrency: conf.Concurrency,
+		Duration:    elapsed,
+	}
+	total := &operationStats{latencies: newLatencyHistogram()}
+	for _, operation := range []string{operationRead, operationWrite} {
+		stats := &operationStats{latencies: newLatencyHistogram()}
+		for _, w := range workers {
+			stats.merge(w.stats[operation])
+		}
+		if stats.latencies.count == 0 && stats.errors == 0 {
+			continue
+		}
+		total.merge(stats)
+		report.Operations = append(report.Operations, stats.result(operation))
+	}
+	report.Operations = append(report.Operations, total.result(operationTotal))
+
+	if conf.Metrics != nil {
+		var metricsAfter map[string]*histogramSnapshot
+		if metricsErr == nil {
+			metricsAfter, metricsErr = readClusterHistograms(ctx, conf.Metrics, conf.MetricsPrefix)
+		}
+		if metricsErr != nil {
+			report.ClustersErr = metricsErr
+		} else {
+			for _, cluster := range []common.ClusterType{common.ClusterTypeOrigin, common.ClusterTypeTarget} {
+				report.Clusters = append(report.Clusters,
+					metricsAfter[string(cluster)].subtract(metricsBefore[string(cluster)]).result(cluster))
+			}
+		}
+	}
+	return report, nil
+}
+
+func createSchema(conn *client.CqlClientConnection, conf *Config) error {
+	statements := []string{
+		fmt.Sprintf("CREATE KEYSPACE IF NOT EXISTS %v WITH replication = "+
+			"{'class': 'SimpleStrategy', 'replication_factor': %d}", conf.Keyspace, conf.ReplicationFactor),
+		fmt.Sprintf("CREATE TABLE IF NOT EXISTS %v.%v (id bigint PRIMARY KEY, value blob)", conf.Keyspace, benchTable),
+	}
+	for _, statement := range statements {
+		response, err := conn.SendAndReceive(frame.NewFrame(conf.ProtocolVersion, client.ManagedStreamId, &message.Query{
+			Query:   statement,
+			Options: &message.QueryOptions{Consistency: primitive.ConsistencyLevelOne},
+		}))
+		if err != nil {
+			return fmt.Errorf("could not create the schema of the workload: %w", err)
+		}
+		if errorMsg, ok := response.Body.Message.(message.Error); ok {
+			return fmt.Errorf("could not create the schema of the workload: %v", errorMsg.GetErrorMessage())
+		}
+	}
+	return nil
+}
+
+// operationStats are only accessed by the goroutine of a worker until the workload is done.
+type operationStats struct {
+	latencies *latencyHistogram
+	errors    int64
+}
+
+func (s *operationStats) merge(other *operationStats) {
+	if other == nil {
+		return
+	}
+	s.latencies.merge(other.latencies)
+	s.errors += other.errors
+}
+
+func (s *operationStats) result(operation string) *OperationResult {
+	return &OperationResult{
+		Operation: operation,
+		Requests:  s.latencies.count,
+		Errors:    s.errors,
+		Mean:      s.latencies.mean(),
+		P50:       s.latencies.percentile(0.5),
+		P95:       s.latencies.percentile(0.95),
+		P99:       s.latencies.percentile(0.99),
+		Max:       s.latencies.max,
+	}
+}
+
+type worker struct {
+	conn     *client.CqlClientConnection
+	conf     *Config
+	rand     *rand.Rand
+	value    []byte
+	readCql  string
+	writeCql string
+
+	stats map[string]*operationStats
+	// the connection error that stopped the worker
+	err error
+}
+
+func newWorker(conn *client.CqlClientConnection, conf *Config, rand *rand.Rand) *worker {
+	value := make([]byte, conf.ValueSize)
+	rand.Read(value)
+	return &worker{
+		conn:     conn,
+		conf:     conf,
+		rand:     rand,
+		value:    value,
+		readCql:  fmt.Sprintf("SELECT value FROM %v.%v WHERE id = ?", conf.Keyspace, benchTable),
+		writeCql: fmt.Sprintf("INSERT INTO %v.%v (id, value) VALUES (?, ?)", conf.Keyspace, benchTable),
+		stats: map[string]*operationStats{
+			operationRead:  {latencies: newLatencyHistogram()},
+			operationWrite: {latencies: newLatencyHistogram()},
+		},
+	}
+}
+
+func (w *worker) run(ctx context.Context) {
+	partitionKey := make([]byte, 8)
+	for ctx.Err() == nil {
+		operation := operationWrite
+		if w.conf.Workload == WorkloadRead || (w.conf.Workload == WorkloadMixed && w.rand.Intn(2) == 0) {
+			operation = operationRead
+		}
+		binary.BigEndian.PutUint64(partitionKey, uint64(w.rand.Int63n(w.conf.Partitions)))
+		query := &message.Query{
+			Query:   w.writeCql,
+			Options: &message.QueryOptions{Consistency: primitive.ConsistencyLevelLocalQuorum},
+		}
+		if operation == operationRead {
+			query.Query = w.readCql
+			query.Options.PositionalValues = []*primitive.Value{primitive.NewValue(partitionKey)}
+		} else {
+			query.Options.PositionalValues = []*primitive.Value{primitive.NewValue(partitionKey), primitive.NewValue(w.value)}
+		}
+
+		start := time.Now()
+		response, err := w.conn.SendAndReceive(frame.NewFrame(w.conf.ProtocolVersion, client.ManagedStreamId, query))
+		latency := time.Since(start)
+		if err != nil {
+			if ctx.Err() == nil {
+				w.err = fmt.Errorf("%v request failed: %w", operation, err)
+			}
+			return
+		}
+		stats := w.stats[operation]
+		if _, ok := response.Body.Message.(message.Error); ok {
+			stats.errors++
+		} else {
+			stats.latencies.record(latency)
+		}
+	}
+}
+
+// Write prints the report as tables.
+func (r *Report) Write(writer io.Writer) error {
+	tw := tabwriter.NewWriter(writer, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "Workload %v with %d connections to %v for %v\n\n",
+		r.Workload, r.Concurrency, r.Address, r.Duration.Round(time.Millisecond))
+	fmt.Fprintf(tw, "OPERATION\tREQUESTS\tERRORS\tOPS/S\tMEAN\tP50\tP95\tP99\tMAX\n")
+	for _, op := range r.Operations {
+		fmt.Fprintf(tw, "%v\t%d\t%d\t%.1f\t%v\t%v\t%v\t%v\t%v\n", op.Operation, op.Requests, op.Errors,
+			r.throughput(op.Requests), formatLatency(op.Mean), formatLatency(op.P50), formatLatency(op.P95),
+			formatLatency(op.P99), formatLatency(op.Max))
+	}
+	if r.ClustersErr != nil {
+		fmt.Fprintf(tw, "\nThe per cluster results are not available: %v\n", r.ClustersErr)
+	} else if len(r.Clusters) > 0 {
+		fmt.Fprintf(tw, "\nCLUSTER\tREQUESTS\tOPS/S\tMEAN\tP99\n")
+		for _, cluster := range r.Clusters {
+			p99 := formatLatency(cluster.P99)
+			if cluster.P99 == 0 && cluster.Requests > 0 {
+				p99 = "n/a"
+			}
+			fmt.Fprintf(tw, "%v\t%d\t%.1f\t%v\t%v\n", cluster.Cluster, cluster.Requests,
+				r.throughput(cluster.Requests), formatLatency(cluster.Mean), p99)
+		}
+	}
+	return tw.Flush()
+}
+
+func (r *Report) throughput(requests int64) float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(requests) / r.Duration.Seconds()
+}
+
+func formatLatency(latency time.Duration) string {
+	return latency.Round(time.Microsecond).String()
+}
@@ -0,0 +1,192 @@
+package bench
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/datastax/go-cassandra-native-protocol/client"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+	"math"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// startFakeProxy starts a CQL server that returns an error for the writes of the partitions with an even id.
+func startFakeProxy(t *testing.T) (*client.CqlServer, *sync.Map) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	address := listener.Addr().String()
+	require.Nil(t, listener.Close())
+
+	queries := &sync.Map{}
+	server := client.NewCqlServer(address, nil)
+	server.RequestHandlers = []client.RequestHandler{
+		client.HandshakeHandler,
+		func(request *frame.Frame, conn *client.CqlServerConnection, ctx client.RequestHandlerContext) *frame.Frame {
+			query, ok := request.Body.Message.(*message.Query)
+			if !ok {
+				return nil
+			}
+			count, _ := queries.LoadOrStore(strings.SplitN(query.Query, " ", 2)[0], new(int64))
+			atomic.AddInt64(count.(*int64), 1)
+			var response message.Message = &message.VoidResult{}
+			if strings.HasPrefix(query.Query, "SELECT") {
+				response = &message.RowsResult{Metadata: &message.RowsMetadata{ColumnCount: 1}}
+			} else if strings.HasPrefix(query.Query, "INSERT") && query.Options.PositionalValues[0].Contents[7]%2 == 0 {
+				response = &message.Overloaded{ErrorMessage: "overloaded"}
+			}
+			return frame.NewFrame(request.Header.Version, request.Header.StreamId, response)
+		},
+	}
+	require.Nil(t, server.Start(context.Background()))
+	t.Cleanup(func() { _ = server.Close() })
+	return server, queries
+}
+
+func newHistogramFamily(name string, count uint64, sumSeconds float64, buckets map[float64]uint64) *dto.MetricFamily {
+	histogram := &dto.Histogram{SampleCount: &count, SampleSum: &sumSeconds}
+	for upperBound, cumulativeCount := range buckets {
+		upperBound, cumulativeCount := upperBound, cumulativeCount
+		histogram.Bucket = append(histogram.Bucket, &dto.Bucket{UpperBound: &upperBound, CumulativeCount: &cumulativeCount})
+	}
+	return &dto.MetricFamily{Name: &name, Metric: []*dto.Metric{{Histogram: histogram}}}
+}
+
+func TestRun(t *testing.T) {
+	server, queries := startFakeProxy(t)
+
+	// the first read of the metrics is before the workload, the second one after it
+	scrapes := 0
+	metricsSource := func(_ context.Context) (map[string]*dto.MetricFamily, error) {
+		scrapes++
+		if scrapes == 1 {
+			return map[string]*dto.MetricFamily{
+				"zdm_origin_request_duration_seconds": newHistogramFamily("zdm_origin_request_duration_seconds",
+					10, 0.1, map[float64]uint64{0.001: 5, 0.01: 10, math.Inf(1): 10}),
+			}, nil
+		}
+		return map[string]*dto.MetricFamily{
+			"zdm_origin_request_duration_seconds": newHistogramFamily("zdm_origin_request_duration_seconds",
+				110, 1.1, map[float64]uint64{0.001: 5, 0.01: 110, math.Inf(1): 110}),
+			"zdm_target_request_duration_seconds": newHistogramFamily("zdm_target_request_duration_seconds",
+				50, 5, map[float64]uint64{0.001: 0, 0.01: 0, math.Inf(1): 50}),
+		}, nil
+	}
+
+	report, err := Run(context.Background(), &Config{
+		Address:           server.ListenAddress,
+		ProtocolVersion:   primitive.ProtocolVersion4,
+		Workload:          WorkloadMixed,
+		Concurrency:       2,
+		Duration:          200 * time.Millisecond,
+		Keyspace:          "zdm_bench",
+		ReplicationFactor: 1,
+		CreateSchema:      true,
+		Partitions:        100,
+		ValueSize:         10,
+		Metrics:           metricsSource,
+		MetricsPrefix:     "zdm",
+	})
+	require.Nil(t, err)
+	require.Equal(t, 2, scrapes)
+
+	creates, _ := queries.Load("CREATE")
+	require.Equal(t, int64(2), *creates.(*int64))
+	reads, _ := queries.Load("SELECT")
+	writes, _ := queries.Load("INSERT")
+	require.Len(t, report.Operations, 3)
+	read, write, total := report.Operations[0], report.Operations[1], report.Operations[2]
+	require.Equal(t, "read", read.Operation)
+	require.Equal(t, *reads.(*int64), read.Requests)
+	require.Equal(t, int64(0), read.Errors)
+	require.Equal(t, "write", write.Operation)
+	require.Equal(t, *writes.(*int64), write.Requests+write.Errors)
+	require.Greater(t, write.Errors, int64(0))
+	require.Equal(t, "total", total.Operation)
+	require.Equal(t, read.Requests+write.Requests, total.Requests)
+	require.Equal(t, write.Errors, total.Errors)
+	require.LessOrEqual(t, total.P50, total.P99)
+	require.LessOrEqual(t, total.P99, total.Max)
+
+	require.Nil(t, report.ClustersErr)
+	require.Equal(t, []*ClusterResult{
+		{Cluster: common.ClusterTypeOrigin, Requests: 100, Mean: 10 * time.Millisecond, P99: 10 * time.Millisecond},
+		{Cluster: common.ClusterTypeTarget, Requests: 50, Mean: 100 * time.Millisecond},
+	}, report.Clusters)
+
+	output := &bytes.Buffer{}
+	require.Nil(t, report.Write(output))
+	require.Contains(t, output.String(), "Workload mixed with 2 connections to "+server.ListenAddress)
+	require.Contains(t, output.String(), "ORIGIN   100")
+	require.Contains(t, output.String(), "n/a")
+}
+
+func TestRun_Errors(t *testing.T) {
+	server, _ := startFakeProxy(t)
+	conf := func(modify func(conf *Config)) *Config {
+		conf := &Config{
+			Address:         server.ListenAddress,
+			ProtocolVersion: primitive.ProtocolVersion4,
+			Workload:        WorkloadRead,
+			Concurrency:     1,
+			Duration:        50 * time.Millisecond,
+			Keyspace:        "zdm_bench",
+			Partitions:      100,
+		}
+		modify(conf)
+		return conf
+	}
+
+	tests := []struct {
+		name          string
+		conf          *Config
+		expectedError string
+	}{
+		{name: "concurrency", conf: conf(func(conf *Config) { conf.Concurrency = 0 }),
+			expectedError: "invalid concurrency 0, it must be positive"},
+		{name: "partitions", conf: conf(func(conf *Config) { conf.Partitions = 0 }),
+			expectedError: "invalid number of partitions 0, it must be positive"},
+		{name: "replication factor", conf: conf(func(conf *Config) { conf.CreateSchema = true }),
+			expectedError: "invalid replication factor 0, it must be positive"},
+		{name: "connection refused", conf: conf(func(conf *Config) { conf.Address = "127.0.0.1:1" }),
+			expectedError: "could not connect to 127.0.0.1:1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Run(context.Background(), tt.conf)
+			require.NotNil(t, err)
+			require.Contains(t, err.Error(), tt.expectedError)
+		})
+	}
+
+	// the client results are still reported when the metrics are not available
+	report, err := Run(context.Background(), conf(func(conf *Config) {
+		conf.Metrics = func(_ context.Context) (map[string]*dto.MetricFamily, error) {
+			return nil, errors.New("connection refused")
+		}
+	}))
+	require.Nil(t, err)
+	require.Len(t, report.Operations, 2)
+	require.Greater(t, report.Operations[0].Requests, int64(0))
+	require.Empty(t, report.Clusters)
+	require.EqualError(t, report.ClustersErr, "connection refused")
+}
+
+func TestParseWorkload(t *testing.T) {
+	for _, workload := range []Workload{WorkloadWrite, WorkloadRead, WorkloadMixed} {
+		parsed, err := ParseWorkload(strings.ToUpper(string(workload)))
+		require.Nil(t, err)
+		require.Equal(t, workload, parsed)
+	}
+	_, err := ParseWorkload("scan")
+	require.EqualError(t, err, "unknown workload scan, possible values are: write, read and mixed")
+}
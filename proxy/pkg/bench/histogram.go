@@ -0,0 +1,91 @@
+package bench
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	minRecordedLatency = 10 * time.Microsecond
+	maxRecordedLatency = time.Minute
+	// every bucket is 5% wider than the previous one so the percentiles are accurate to 5%
+	latencyBucketGrowth = 1.05
+)
+
+var latencyBucketCount = int(math.Ceil(
+	math.Log(float64(maxRecordedLatency)/float64(minRecordedLatency))/math.Log(latencyBucketGrowth))) + 1
+
+// latencyHistogram records latencies in exponential buckets, the memory it uses doesn't depend on the number of
+// requests of the workload.
+type latencyHistogram struct {
+	buckets []int64
+	count   int64
+	sum     time.Duration
+	max     time.Duration
+}
+
+func newLatencyHistogram() *latencyHistogram {
+	return &latencyHistogram{buckets: make([]int64, latencyBucketCount)}
+}
+
+func latencyBucketIndex(latency time.Duration) int {
+	if latency <= minRecordedLatency {
+		return 0
+	}
+	index := int(math.Ceil(math.Log(float64(latency)/float64(minRecordedLatency)) / math.Log(latencyBucketGrowth)))
+	if index >= latencyBucketCount {
+		return latencyBucketCount - 1
+	}
+	return index
+}
+
+func latencyBucketUpperBound(index int) time.Duration {
+	return time.Duration(float64(minRecordedLatency) * math.Pow(latencyBucketGrowth, float64(index)))
+}
+
+func (h *latencyHistogram) record(latency time.Duration) {
+	h.buckets[latencyBucketIndex(latency)]++
+	h.count++
+	h.sum += latency
+	if latency > h.max {
+		h.max = latency
+	}
+}
+
+func (h *latencyHistogram) merge(other *latencyHistogram) {
+	for i, count := range other.buckets {
+		h.buckets[i] += count
+	}
+	h.count += other.count
+	h.sum += other.sum
+	if other.max > h.max {
+		h.max = other.max
+	}
+}
+
+func (h *latencyHistogram) mean() time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	return h.sum / time.Duration(h.count)
+}
+
+// percentile returns the upper bound of the bucket that contains the percentile, capped by the maximum latency. The
+// maximum latency is returned for the last bucket, which also counts the latencies above maxRecordedLatency.
+func (h *latencyHistogram) percentile(quantile float64) time.Duration {
+	if h.count == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(quantile * float64(h.count)))
+	var cumulative int64
+	for i, count := range h.buckets {
+		cumulative += count
+		if cumulative >= rank {
+			if upperBound := latencyBucketUpperBound(i); i < len(h.buckets)-1 && upperBound < h.max {
+				return upperBound
+			}
+			return h.max
+		}
+	}
+	return h.max
+}
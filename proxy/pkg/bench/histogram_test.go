@@ -0,0 +1,34 @@
+package bench
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	histogram := newLatencyHistogram()
+	require.Equal(t, time.Duration(0), histogram.percentile(0.99))
+	require.Equal(t, time.Duration(0), histogram.mean())
+
+	for i := 1; i <= 100; i++ {
+		histogram.record(time.Duration(i) * time.Millisecond)
+	}
+	other := newLatencyHistogram()
+	other.record(2 * time.Second)
+	histogram.merge(other)
+
+	require.Equal(t, int64(101), histogram.count)
+	require.Equal(t, 2*time.Second, histogram.max)
+	require.Equal(t, (5050*time.Millisecond+2*time.Second)/101, histogram.mean())
+	require.InEpsilon(t, float64(51*time.Millisecond), float64(histogram.percentile(0.5)), latencyBucketGrowth-1)
+	require.InEpsilon(t, float64(100*time.Millisecond), float64(histogram.percentile(0.99)), latencyBucketGrowth-1)
+	require.Equal(t, 2*time.Second, histogram.percentile(1))
+
+	// the latencies outside of the range of the buckets are still counted
+	histogram.record(time.Microsecond)
+	histogram.record(2 * time.Minute)
+	require.Equal(t, int64(1), histogram.buckets[0])
+	require.Equal(t, int64(1), histogram.buckets[latencyBucketCount-1])
+	require.Equal(t, 2*time.Minute, histogram.percentile(1))
+}
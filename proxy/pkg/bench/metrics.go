@@ -0,0 +1,140 @@
+package bench
+
+import (
+	"context"
+	"fmt"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// MetricsSource returns the Prometheus metric families of the proxy by name.
+type MetricsSource func(ctx context.Context) (map[string]*dto.MetricFamily, error)
+
+// HttpMetricsSource scrapes the metrics endpoint of a running proxy, e.g. http://127.0.0.1:14001/metrics.
+func HttpMetricsSource(url string) MetricsSource {
+	return func(ctx context.Context) (map[string]*dto.MetricFamily, error) {
+		request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, err
+		}
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			return nil, fmt.Errorf("could not scrape the metrics of the proxy: %w", err)
+		}
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("could not scrape the metrics of the proxy: %v returned %v", url, response.Status)
+		}
+		var parser expfmt.TextParser
+		families, err := parser.TextToMetricFamilies(response.Body)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse the metrics of the proxy: %w", err)
+		}
+		return families, nil
+	}
+}
+
+// GathererMetricsSource reads the metrics of a proxy that runs in the same process.
+func GathererMetricsSource(gatherer prometheus.Gatherer) MetricsSource {
+	return func(_ context.Context) (map[string]*dto.MetricFamily, error) {
+		families, err := gatherer.Gather()
+		if err != nil {
+			return nil, fmt.Errorf("could not gather the metrics of the proxy: %w", err)
+		}
+		familiesByName := make(map[string]*dto.MetricFamily, len(families))
+		for _, family := range families {
+			familiesByName[family.GetName()] = family
+		}
+		return familiesByName, nil
+	}
+}
+
+// histogramSnapshot is the sum of the histograms of every node of a cluster.
+type histogramSnapshot struct {
+	count uint64
+	sum   float64
+	// cumulative counts by upper bound in seconds
+	buckets map[float64]uint64
+}
+
+// readClusterHistograms returns the snapshots of the request duration node metrics of ORIGIN and TARGET, by cluster.
+func readClusterHistograms(
+	ctx context.Context, source MetricsSource, metricsPrefix string) (map[string]*histogramSnapshot, error) {
+	families, err := source(ctx)
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make(map[string]*histogramSnapshot)
+	for cluster, metric := range map[common.ClusterType]metrics.Metric{
+		common.ClusterTypeOrigin: metrics.OriginRequestDuration,
+		common.ClusterTypeTarget: metrics.TargetRequestDuration,
+	} {
+		snapshot := &histogramSnapshot{buckets: make(map[float64]uint64)}
+		name := prometheus.BuildFQName(metricsPrefix, "", metric.GetName())
+		if family, ok := families[name]; ok {
+			for _, m := range family.GetMetric() {
+				histogram := m.GetHistogram()
+				snapshot.count += histogram.GetSampleCount()
+				snapshot.sum += histogram.GetSampleSum()
+				for _, bucket := range histogram.GetBucket() {
+					snapshot.buckets[bucket.GetUpperBound()] += bucket.GetCumulativeCount()
+				}
+			}
+		}
+		snapshots[string(cluster)] = snapshot
+	}
+	return snapshots, nil
+}
+
+// subtract returns the requests that were recorded since the before snapshot. It's nil safe.
+func (s *histogramSnapshot) subtract(before *histogramSnapshot) *histogramSnapshot {
+	if s == nil {
+		return &histogramSnapshot{}
+	}
+	if before == nil {
+		return s
+	}
+	delta := &histogramSnapshot{
+		count:   s.count - before.count,
+		sum:     s.sum - before.sum,
+		buckets: make(map[float64]uint64, len(s.buckets)),
+	}
+	for upperBound, count := range s.buckets {
+		delta.buckets[upperBound] = count - before.buckets[upperBound]
+	}
+	return delta
+}
+
+func (s *histogramSnapshot) result(cluster common.ClusterType) *ClusterResult {
+	result := &ClusterResult{Cluster: cluster, Requests: int64(s.count)}
+	if s.count == 0 {
+		return result
+	}
+	result.Mean = secondsToDuration(s.sum / float64(s.count))
+	upperBounds := make([]float64, 0, len(s.buckets))
+	for upperBound := range s.buckets {
+		upperBounds = append(upperBounds, upperBound)
+	}
+	sort.Float64s(upperBounds)
+	rank := uint64(math.Ceil(0.99 * float64(s.count)))
+	for _, upperBound := range upperBounds {
+		if s.buckets[upperBound] >= rank {
+			if !math.IsInf(upperBound, 1) {
+				result.P99 = secondsToDuration(upperBound)
+			}
+			break
+		}
+	}
+	return result
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,78 @@
+package buildinfo
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+)
+
+// TODO: to be managed externally
+const Version = "2.1.0"
+
+// GitSha and BuildDate are set at build time, e.g.
+//
+//	go build -ldflags "-X github.com/datastax/zdm-proxy/proxy/pkg/buildinfo.GitSha=$(git rev-parse HEAD)
+//	  -X github.com/datastax/zdm-proxy/proxy/pkg/buildinfo.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./proxy
+//
+// If they are not set, the VCS information that the go command embeds in binaries built from a git checkout is used.
+var (
+	GitSha    = ""
+	BuildDate = ""
+)
+
+const unknown = "unknown"
+
+// BuildInfo describes the binary of the proxy.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	GitSha    string `json:"git_sha"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+}
+
+var current = newBuildInfo(debug.ReadBuildInfo)
+
+func newBuildInfo(readBuildInfo func() (*debug.BuildInfo, bool)) *BuildInfo {
+	info := &BuildInfo{Version: Version, GitSha: GitSha, BuildDate: BuildDate, GoVersion: runtime.Version()}
+	if goBuildInfo, ok := readBuildInfo(); ok {
+		for _, setting := range goBuildInfo.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && info.GitSha == "":
+				info.GitSha = setting.Value
+			case setting.Key == "vcs.time" && info.BuildDate == "":
+				info.BuildDate = setting.Value
+			}
+		}
+	}
+	if info.GitSha == "" {
+		info.GitSha = unknown
+	}
+	if info.BuildDate == "" {
+		info.BuildDate = unknown
+	}
+	return info
+}
+
+// Get returns the BuildInfo of the running binary.
+func Get() *BuildInfo {
+	return current
+}
+
+// Labels returns the labels of the build info metric.
+func (b *BuildInfo) Labels() map[string]string {
+	return map[string]string{
+		"version":    b.Version,
+		"git_sha":    b.GitSha,
+		"build_date": b.BuildDate,
+		"go_version": b.GoVersion,
+	}
+}
+
+// Handler serves the BuildInfo of the running binary as JSON.
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(Get())
+	})
+}
@@ -0,0 +1,68 @@
+package buildinfo
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"net/http/httptest"
+	"runtime"
+	"runtime/debug"
+	"testing"
+)
+
+func TestNewBuildInfo(t *testing.T) {
+	vcsBuildInfo := func() (*debug.BuildInfo, bool) {
+		return &debug.BuildInfo{Settings: []debug.BuildSetting{
+			{Key: "vcs", Value: "git"},
+			{Key: "vcs.revision", Value: "a1b2c3d"},
+			{Key: "vcs.time", Value: "2026-10-01T12:00:00Z"},
+		}}, true
+	}
+	noBuildInfo := func() (*debug.BuildInfo, bool) {
+		return nil, false
+	}
+
+	tests := []struct {
+		name              string
+		gitSha            string
+		buildDate         string
+		readBuildInfo     func() (*debug.BuildInfo, bool)
+		expectedGitSha    string
+		expectedBuildDate string
+	}{
+		{"ldflags", "ffffff", "2026-10-14T08:00:00Z", vcsBuildInfo, "ffffff", "2026-10-14T08:00:00Z"},
+		{"vcs", "", "", vcsBuildInfo, "a1b2c3d", "2026-10-01T12:00:00Z"},
+		{"unknown", "", "", noBuildInfo, unknown, unknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			GitSha, BuildDate = tt.gitSha, tt.buildDate
+			defer func() {
+				GitSha, BuildDate = "", ""
+			}()
+			info := newBuildInfo(tt.readBuildInfo)
+			require.Equal(t, &BuildInfo{
+				Version:   Version,
+				GitSha:    tt.expectedGitSha,
+				BuildDate: tt.expectedBuildDate,
+				GoVersion: runtime.Version(),
+			}, info)
+			require.Equal(t, map[string]string{
+				"version":    Version,
+				"git_sha":    tt.expectedGitSha,
+				"build_date": tt.expectedBuildDate,
+				"go_version": runtime.Version(),
+			}, info.Labels())
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/version", nil))
+	require.Equal(t, 200, recorder.Code)
+	require.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+
+	var info BuildInfo
+	require.Nil(t, json.Unmarshal(recorder.Body.Bytes(), &info))
+	require.Equal(t, Get(), &info)
+}
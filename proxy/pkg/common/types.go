@@ -3,6 +3,7 @@ package common
 import (
 	"fmt"
 	"net"
+	"time"
 )
 
 // TopologyConfig contains configuration parameters for 2 features related to multi zdm-proxy instance deployment:
@@ -31,11 +32,20 @@ type ClusterTlsConfig struct {
 	ClientCertPath          string
 	ClientKeyPath           string
 	SecureConnectBundlePath string
+
+	// CrlPath is optional, the server certificates are checked against the certificate revocation lists in this file
+	CrlPath string
+
+	// MinVersion and CipherSuites are left empty to use the defaults of the crypto/tls package
+	MinVersion   uint16
+	CipherSuites []uint16
 }
 
 func (recv *ClusterTlsConfig) String() string {
-	return fmt.Sprintf("ClusterTlsConfig{TlsEnabled=%v, ProxyCaPath=%v, ClientCertPath=%v, ClientKeyPath=%v}",
-		recv.TlsEnabled, recv.ServerCaPath, recv.ClientCertPath, recv.ClientKeyPath)
+	return fmt.Sprintf("ClusterTlsConfig{TlsEnabled=%v, ProxyCaPath=%v, ClientCertPath=%v, ClientKeyPath=%v, "+
+		"CrlPath=%v, MinVersion=%v, CipherSuites=%v}",
+		recv.TlsEnabled, recv.ServerCaPath, recv.ClientCertPath, recv.ClientKeyPath, recv.CrlPath, recv.MinVersion,
+		recv.CipherSuites)
 }
 
 // ProxyTlsConfig contains all TLS configuration parameters to enable TLS at proxy level
@@ -47,12 +57,117 @@ type ProxyTlsConfig struct {
 	ProxyCertPath string
 	ProxyKeyPath  string
 	ClientAuth    bool
+	MinVersion    uint16
+	CipherSuites  []uint16
 }
 
 func (recv *ProxyTlsConfig) String() string {
-	return fmt.Sprintf("ProxyTlsConfig{TlsEnabled=%v, ProxyCaPath=%v, ProxyCertPath=%v, ProxyKeyPath=%v, ClientAuth=%v}",
-		recv.TlsEnabled, recv.ProxyCaPath, recv.ProxyCertPath, recv.ProxyKeyPath, recv.ClientAuth)
+	return fmt.Sprintf("ProxyTlsConfig{TlsEnabled=%v, ProxyCaPath=%v, ProxyCertPath=%v, ProxyKeyPath=%v, ClientAuth=%v, "+
+		"MinVersion=%v, CipherSuites=%v}",
+		recv.TlsEnabled, recv.ProxyCaPath, recv.ProxyCertPath, recv.ProxyKeyPath, recv.ClientAuth, recv.MinVersion,
+		recv.CipherSuites)
+
+}
+
+// HttpTlsConfig is the TLS configuration of the http server of the metrics, health checks and admin API, the client
+// certificates are only verified if ClientCaPath is set.
+type HttpTlsConfig struct {
+	TlsEnabled   bool
+	CertPath     string
+	KeyPath      string
+	ClientCaPath string
+}
 
+// AdminApiAuthConfig holds the bearer tokens and the client certificate common names of the operator and read-only
+// roles of the admin API. MetricsAuthRequired requires one of the roles on /metrics too.
+type AdminApiAuthConfig struct {
+	OperatorTokens      []string
+	ReadOnlyTokens      []string
+	OperatorCertNames   []string
+	ReadOnlyCertNames   []string
+	MetricsAuthRequired bool
+}
+
+// Enabled returns true if the admin API requires authentication.
+func (recv *AdminApiAuthConfig) Enabled() bool {
+	return len(recv.OperatorTokens) > 0 || len(recv.ReadOnlyTokens) > 0 ||
+		len(recv.OperatorCertNames) > 0 || len(recv.ReadOnlyCertNames) > 0
+}
+
+// JournalRetention limits the size of a journal and the age of its entries, a limit of 0 is disabled. The journal is
+// compacted every CompactionInterval to enforce them.
+type JournalRetention struct {
+	MaxSizeBytes       int64
+	MaxAge             time.Duration
+	CompactionInterval time.Duration
+}
+
+// Enabled returns true if the journal has a size or an age limit.
+func (recv *JournalRetention) Enabled() bool {
+	return recv.MaxSizeBytes > 0 || recv.MaxAge > 0
+}
+
+// GoroutineAuditConfig holds how often the goroutines of the client handlers are reconciled and how long the goroutines
+// of a closed client handler can keep running before it's reported as leaking them.
+type GoroutineAuditConfig struct {
+	Interval        time.Duration
+	LeakGracePeriod time.Duration
+}
+
+// Enabled returns true if the goroutines are audited.
+func (recv *GoroutineAuditConfig) Enabled() bool {
+	return recv.Interval > 0
+}
+
+// ClockSkewCheckConfig holds how often the clocks of the clusters are compared and the skew above which it's reported.
+type ClockSkewCheckConfig struct {
+	Interval  time.Duration
+	Threshold time.Duration
+}
+
+// Enabled returns true if the clocks of the clusters are compared.
+func (recv *ClockSkewCheckConfig) Enabled() bool {
+	return recv.Interval > 0
+}
+
+// AutoCutoverConfig holds the thresholds of the automatic cutover of reads to Target: reads are switched once the
+// mismatch rate reported by the read verifier stays at or below MaxMismatchRate for StableDuration, every check
+// interval must compare at least MinComparedReads reads to count.
+type AutoCutoverConfig struct {
+	MaxMismatchRate  float64
+	MinComparedReads int
+	StableDuration   time.Duration
+	CheckInterval    time.Duration
+}
+
+func (recv *AutoCutoverConfig) String() string {
+	return fmt.Sprintf("AutoCutoverConfig{MaxMismatchRate=%v, MinComparedReads=%v, StableDuration=%v, CheckInterval=%v}",
+		recv.MaxMismatchRate, recv.MinComparedReads, recv.StableDuration, recv.CheckInterval)
+}
+
+// GuardrailsConfig holds the thresholds of the write guardrails in bytes of request body or number of BATCH
+// statements, a threshold of 0 is disabled. The writes that exceed a warn threshold are counted (and get a client
+// warning if ClientWarningsEnabled is set), the writes that exceed a fail threshold are rejected. MaxQueryLength and
+// MaxValuesSizeBytes limit the statements and the bound values of all the requests, reads included.
+type GuardrailsConfig struct {
+	BatchSizeWarnBytes    int
+	BatchSizeFailBytes    int
+	BatchStatementsWarn   int
+	BatchStatementsFail   int
+	MutationSizeWarnBytes int
+	MutationSizeFailBytes int
+	MaxQueryLength        int
+	MaxValuesSizeBytes    int
+	ClientWarningsEnabled bool
+}
+
+func (recv *GuardrailsConfig) String() string {
+	return fmt.Sprintf("GuardrailsConfig{BatchSizeWarnBytes=%v, BatchSizeFailBytes=%v, BatchStatementsWarn=%v, "+
+		"BatchStatementsFail=%v, MutationSizeWarnBytes=%v, MutationSizeFailBytes=%v, MaxQueryLength=%v, "+
+		"MaxValuesSizeBytes=%v, ClientWarningsEnabled=%v}",
+		recv.BatchSizeWarnBytes, recv.BatchSizeFailBytes, recv.BatchStatementsWarn, recv.BatchStatementsFail,
+		recv.MutationSizeWarnBytes, recv.MutationSizeFailBytes, recv.MaxQueryLength, recv.MaxValuesSizeBytes,
+		recv.ClientWarningsEnabled)
 }
 
 type ReadMode struct {
@@ -67,6 +182,7 @@ var (
 	ReadModeUndefined            = ReadMode{""}
 	ReadModePrimaryOnly          = ReadMode{"PRIMARY_ONLY"}
 	ReadModeDualAsyncOnSecondary = ReadMode{"DUAL_ASYNC_ON_SECONDARY"}
+	ReadModeHedged               = ReadMode{"HEDGED"}
 )
 
 type SystemQueriesMode struct {
@@ -83,6 +199,38 @@ var (
 	SystemQueriesModeTarget    = SystemQueriesMode{"TARGET"}
 )
 
+type EventsSource struct {
+	slug string
+}
+
+func (r EventsSource) String() string {
+	return r.slug
+}
+
+var (
+	EventsSourceUndefined = EventsSource{""}
+	EventsSourceDefault   = EventsSource{"DEFAULT"}
+	EventsSourceOrigin    = EventsSource{"ORIGIN"}
+	EventsSourceTarget    = EventsSource{"TARGET"}
+	EventsSourcePrimary   = EventsSource{"PRIMARY"}
+	EventsSourceNone      = EventsSource{"NONE"}
+)
+
+type QueueOverflowPolicy struct {
+	slug string
+}
+
+func (r QueueOverflowPolicy) String() string {
+	return r.slug
+}
+
+var (
+	QueueOverflowPolicyUndefined   = QueueOverflowPolicy{""}
+	QueueOverflowPolicyBlock       = QueueOverflowPolicy{"BLOCK"}
+	QueueOverflowPolicyShed        = QueueOverflowPolicy{"SHED"}
+	QueueOverflowPolicyPreferReads = QueueOverflowPolicy{"PREFER_READS"}
+)
+
 type ClusterType string
 
 const (
@@ -90,3 +238,222 @@ const (
 	ClusterTypeOrigin = ClusterType("ORIGIN")
 	ClusterTypeTarget = ClusterType("TARGET")
 )
+
+type CompatibilityProfile struct {
+	slug string
+}
+
+func (r CompatibilityProfile) String() string {
+	return r.slug
+}
+
+var (
+	CompatibilityProfileUndefined = CompatibilityProfile{""}
+	CompatibilityProfileNone      = CompatibilityProfile{"NONE"}
+	CompatibilityProfileAstra     = CompatibilityProfile{"ASTRA"}
+	CompatibilityProfileKeyspaces = CompatibilityProfile{"KEYSPACES"}
+	CompatibilityProfileCosmos    = CompatibilityProfile{"COSMOS"}
+)
+
+type ConnectionCompression struct {
+	slug string
+}
+
+func (r ConnectionCompression) String() string {
+	return r.slug
+}
+
+var (
+	ConnectionCompressionUndefined = ConnectionCompression{""}
+	ConnectionCompressionNone      = ConnectionCompression{"NONE"}
+	ConnectionCompressionLz4       = ConnectionCompression{"LZ4"}
+)
+
+type FrameExportFormat struct {
+	slug string
+}
+
+func (r FrameExportFormat) String() string {
+	return r.slug
+}
+
+var (
+	FrameExportFormatUndefined = FrameExportFormat{""}
+	FrameExportFormatPcap      = FrameExportFormat{"PCAP"}
+	FrameExportFormatHex       = FrameExportFormat{"HEX"}
+)
+
+type LogOutput struct {
+	slug string
+}
+
+func (r LogOutput) String() string {
+	return r.slug
+}
+
+var (
+	LogOutputUndefined = LogOutput{""}
+	LogOutputStderr    = LogOutput{"STDERR"}
+	LogOutputFile      = LogOutput{"FILE"}
+	LogOutputSyslog    = LogOutput{"SYSLOG"}
+)
+
+// LogOutputConfig describes where the logs are written, the File* fields are only set for LogOutputFile and the
+// Syslog* fields for LogOutputSyslog. A FileMaxSizeBytes or FileRotationInterval of 0 disables the matching rotation
+// and a FileMaxBackups of 0 keeps all the rotated files.
+type LogOutputConfig struct {
+	Output               LogOutput
+	FilePath             string
+	FileMaxSizeBytes     int64
+	FileRotationInterval time.Duration
+	FileMaxBackups       int
+	SyslogNetwork        string // udp, tcp or unix
+	SyslogAddress        string // host:port or socket path
+	SyslogFacility       int
+	SyslogAppName        string
+}
+
+func (recv *LogOutputConfig) String() string {
+	switch recv.Output {
+	case LogOutputFile:
+		return fmt.Sprintf("LogOutputConfig{Output=%v, FilePath=%v, FileMaxSizeBytes=%v, FileRotationInterval=%v, "+
+			"FileMaxBackups=%v}", recv.Output, recv.FilePath, recv.FileMaxSizeBytes, recv.FileRotationInterval,
+			recv.FileMaxBackups)
+	case LogOutputSyslog:
+		return fmt.Sprintf("LogOutputConfig{Output=%v, SyslogNetwork=%v, SyslogAddress=%v, SyslogFacility=%v, "+
+			"SyslogAppName=%v}", recv.Output, recv.SyslogNetwork, recv.SyslogAddress, recv.SyslogFacility,
+			recv.SyslogAppName)
+	default:
+		return fmt.Sprintf("LogOutputConfig{Output=%v}", recv.Output)
+	}
+}
+
+type LogRedactionMode struct {
+	slug string
+}
+
+func (r LogRedactionMode) String() string {
+	return r.slug
+}
+
+var (
+	LogRedactionModeUndefined = LogRedactionMode{""}
+	LogRedactionModeNone      = LogRedactionMode{"NONE"}
+	LogRedactionModeAll       = LogRedactionMode{"ALL"}
+	LogRedactionModeMatching  = LogRedactionMode{"MATCHING"}
+)
+
+// LogRedactionConfig describes which statements have their literals and bound values masked in the logs. With
+// LogRedactionModeMatching, only the statements on a table that matches one of the Tables patterns (keyspace.table or
+// table) or that reference a column that matches one of the Columns patterns are masked.
+type LogRedactionConfig struct {
+	Mode    LogRedactionMode
+	Tables  []string
+	Columns []string
+}
+
+func (recv *LogRedactionConfig) String() string {
+	return fmt.Sprintf("LogRedactionConfig{Mode=%v, Tables=%v, Columns=%v}", recv.Mode, recv.Tables, recv.Columns)
+}
+
+type StartupPolicy struct {
+	slug string
+}
+
+func (r StartupPolicy) String() string {
+	return r.slug
+}
+
+var (
+	StartupPolicyUndefined         = StartupPolicy{""}
+	StartupPolicyDelayListener     = StartupPolicy{"DELAY_LISTENER"}
+	StartupPolicyRefuseConnections = StartupPolicy{"REFUSE_CONNECTIONS"}
+)
+
+type TargetDownPolicy struct {
+	slug string
+}
+
+func (r TargetDownPolicy) String() string {
+	return r.slug
+}
+
+var (
+	TargetDownPolicyUndefined  = TargetDownPolicy{""}
+	TargetDownPolicyFail       = TargetDownPolicy{"FAIL"}
+	TargetDownPolicyOriginOnly = TargetDownPolicy{"ORIGIN_ONLY"}
+)
+
+type TargetWritePipeline struct {
+	slug string
+}
+
+func (r TargetWritePipeline) String() string {
+	return r.slug
+}
+
+var (
+	TargetWritePipelineUndefined = TargetWritePipeline{""}
+	TargetWritePipelineDirect    = TargetWritePipeline{"DIRECT"}
+	TargetWritePipelineKafka     = TargetWritePipeline{"KAFKA"}
+)
+
+type ScrubbedErrorDetail struct {
+	slug string
+}
+
+func (r ScrubbedErrorDetail) String() string {
+	return r.slug
+}
+
+var (
+	ScrubbedErrorDetailUndefined     = ScrubbedErrorDetail{""}
+	ScrubbedErrorDetailNodeAddresses = ScrubbedErrorDetail{"NODE_ADDRESSES"}
+	ScrubbedErrorDetailClusterNames  = ScrubbedErrorDetail{"CLUSTER_NAMES"}
+)
+
+type SharedConfigBackend struct {
+	slug string
+}
+
+func (r SharedConfigBackend) String() string {
+	return r.slug
+}
+
+var (
+	SharedConfigBackendUndefined = SharedConfigBackend{""}
+	SharedConfigBackendNone      = SharedConfigBackend{"NONE"}
+	SharedConfigBackendEtcd      = SharedConfigBackend{"ETCD"}
+	SharedConfigBackendConsul    = SharedConfigBackend{"CONSUL"}
+)
+
+type SecondaryErrorAction struct {
+	slug string
+}
+
+func (r SecondaryErrorAction) String() string {
+	return r.slug
+}
+
+var (
+	SecondaryErrorActionUndefined = SecondaryErrorAction{""}
+	SecondaryErrorActionError     = SecondaryErrorAction{"ERROR"}
+	SecondaryErrorActionWarning   = SecondaryErrorAction{"WARNING"}
+	SecondaryErrorActionMetric    = SecondaryErrorAction{"METRIC"}
+)
+
+type DualWriteResponsePolicy struct {
+	slug string
+}
+
+func (r DualWriteResponsePolicy) String() string {
+	return r.slug
+}
+
+var (
+	DualWriteResponsePolicyUndefined = DualWriteResponsePolicy{""}
+	DualWriteResponsePolicyBoth      = DualWriteResponsePolicy{"BOTH"}
+	DualWriteResponsePolicyPrimary   = DualWriteResponsePolicy{"PRIMARY"}
+	DualWriteResponsePolicyEither    = DualWriteResponsePolicy{"EITHER"}
+	DualWriteResponsePolicyQuorum    = DualWriteResponsePolicy{"QUORUM"}
+)
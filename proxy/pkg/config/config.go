@@ -1,14 +1,20 @@
 package config
 
 import (
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
 	"github.com/datastax/zdm-proxy/proxy/pkg/common"
 	"github.com/kelseyhightower/envconfig"
 	log "github.com/sirupsen/logrus"
 	"net"
+	"net/url"
+	"os"
+	"path"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Config holds the values of environment variables necessary for proper Proxy function.
@@ -16,11 +22,29 @@ type Config struct {
 
 	// Global bucket
 
-	PrimaryCluster          string `default:"ORIGIN" split_words:"true"`
-	ReadMode                string `default:"PRIMARY_ONLY" split_words:"true"`
-	ReplaceCqlFunctions     bool   `default:"false" split_words:"true"`
-	AsyncHandshakeTimeoutMs int    `default:"4000" split_words:"true"`
-	LogLevel                string `default:"INFO" split_words:"true"`
+	PrimaryCluster                      string `default:"ORIGIN" split_words:"true"`
+	ReadMode                            string `default:"PRIMARY_ONLY" split_words:"true"`
+	ReplaceCqlFunctions                 bool   `default:"false" split_words:"true"`
+	AsyncHandshakeTimeoutMs             int    `default:"4000" split_words:"true"`
+	AsyncReadComparisonEnabled          bool   `default:"false" split_words:"true"`
+	LogLevel                            string `default:"INFO" split_words:"true"`
+	LogRedaction                        string `default:"NONE" split_words:"true"`
+	LogRedactionTables                  string `split_words:"true"`
+	LogRedactionColumns                 string `split_words:"true"`
+	RoutingHintsEnabled                 bool   `default:"false" split_words:"true"`
+	ConsistencyDowngradeRetryEnabled    bool   `default:"false" split_words:"true"`
+	SecondaryWriteFailureWarningEnabled bool   `default:"false" split_words:"true"`
+	SecondaryErrorPolicy                string `split_words:"true"`
+	DualWriteResponsePolicy             string `split_words:"true"`
+	LwtMetricsEnabled                   bool   `default:"false" split_words:"true"`
+	OriginLwtSerialConsistency          string `split_words:"true"`
+	TargetLwtSerialConsistency          string `split_words:"true"`
+	ClusterWarningsAggregationEnabled   bool   `default:"false" split_words:"true"`
+	WasmHookPath                        string `split_words:"true"`
+	StartupStrippedOptions              string `split_words:"true"`
+	MaxClientProtocolVersion            string `split_words:"true"`
+	EventsSource                        string `default:"DEFAULT" split_words:"true"`
+	ErrorMessageScrubbing               string `split_words:"true"`
 
 	// Proxy Topology (also known as system.peers "virtualization") bucket
 
@@ -32,29 +56,53 @@ type Config struct {
 
 	OriginContactPoints           string `split_words:"true"`
 	OriginPort                    int    `default:"9042" split_words:"true"`
+	OriginPeerPortMapping         string `split_words:"true"`
 	OriginSecureConnectBundlePath string `split_words:"true"`
 	OriginLocalDatacenter         string `split_words:"true"`
-	OriginUsername                string `required:"true" split_words:"true"`
-	OriginPassword                string `required:"true" split_words:"true" json:"-"`
+	OriginUsername                string `split_words:"true"`
+	OriginPassword                string `split_words:"true" json:"-"`
+	OriginAstraToken              string `split_words:"true" json:"-"`
 	OriginConnectionTimeoutMs     int    `default:"30000" split_words:"true"`
 
 	OriginTlsServerCaPath   string `split_words:"true"`
 	OriginTlsClientCertPath string `split_words:"true"`
 	OriginTlsClientKeyPath  string `split_words:"true"`
+	OriginTlsMinVersion     string `split_words:"true"`
+	OriginTlsCipherSuites   string `split_words:"true"`
+	OriginTlsCrlPath        string `split_words:"true"`
+
+	OriginSigv4Region       string `split_words:"true"`
+	OriginSigv4SessionToken string `split_words:"true" json:"-"`
+
+	OriginCompatibilityProfile string `split_words:"true"`
+
+	OriginConnectionCompression string `default:"NONE" split_words:"true"`
 
 	// Target bucket
 
 	TargetContactPoints           string `split_words:"true"`
 	TargetPort                    int    `default:"9042" split_words:"true"`
+	TargetPeerPortMapping         string `split_words:"true"`
 	TargetSecureConnectBundlePath string `split_words:"true"`
 	TargetLocalDatacenter         string `split_words:"true"`
-	TargetUsername                string `required:"true" split_words:"true"`
-	TargetPassword                string `required:"true" split_words:"true" json:"-"`
+	TargetUsername                string `split_words:"true"`
+	TargetPassword                string `split_words:"true" json:"-"`
+	TargetAstraToken              string `split_words:"true" json:"-"`
 	TargetConnectionTimeoutMs     int    `default:"30000" split_words:"true"`
 
 	TargetTlsServerCaPath   string `split_words:"true"`
 	TargetTlsClientCertPath string `split_words:"true"`
 	TargetTlsClientKeyPath  string `split_words:"true"`
+	TargetTlsMinVersion     string `split_words:"true"`
+	TargetTlsCipherSuites   string `split_words:"true"`
+	TargetTlsCrlPath        string `split_words:"true"`
+
+	TargetSigv4Region       string `split_words:"true"`
+	TargetSigv4SessionToken string `split_words:"true" json:"-"`
+
+	TargetCompatibilityProfile string `split_words:"true"`
+
+	TargetConnectionCompression string `default:"NONE" split_words:"true"`
 
 	// Proxy bucket
 
@@ -64,10 +112,131 @@ type Config struct {
 	ProxyMaxClientConnections int    `default:"1000" split_words:"true"`
 	ProxyMaxStreamIds         int    `default:"2048" split_words:"true"`
 
+	ProxyStuckConnectionTimeoutMs int `default:"0" split_words:"true"`
+
+	// ProxyRequestDeadlineMs bounds the total time that the proxy spends on a request. Without it every request sent to
+	// the clusters gets ProxyRequestTimeoutMs from the moment it's sent so the time spent before (in the request queue,
+	// journaling the write, waiting for an identical PREPARE, etc.) adds up and the client can time out before the proxy
+	// does. With a deadline, the timeout of the requests sent to the clusters is what remains of the deadline since the
+	// request was read from the client connection (capped by ProxyRequestTimeoutMs), the retries with a lower
+	// consistency level are only sent while the deadline hasn't elapsed and a request whose deadline elapsed before it
+	// could be sent is rejected with an OVERLOADED error so that the driver retries it on the next host.
+	ProxyRequestDeadlineMs int `default:"0" split_words:"true"`
+
+	ProxyClientAllowedCidrs string `split_words:"true"`
+	ProxyClientDeniedCidrs  string `split_words:"true"`
+
+	ProxyMemorySoftLimitMb     int `default:"0" split_words:"true"`
+	ProxyMemoryCheckIntervalMs int `default:"1000" split_words:"true"`
+
+	ProxyGoroutineAuditIntervalMs   int `default:"60000" split_words:"true"`
+	ProxyGoroutineLeakGracePeriodMs int `default:"30000" split_words:"true"`
+
+	ProxyRequestRateLimit int `default:"0" split_words:"true"`
+
+	ProxyGlobalRequestRateLimit    int    `default:"0" split_words:"true"`
+	ProxyGlobalRequestRateLimitKey string `default:"zdm-proxy-rate-limit/bucket" split_words:"true"`
+
+	StartupPolicy string `default:"DELAY_LISTENER" split_words:"true"`
+
+	TargetDownPolicy      string `default:"FAIL" split_words:"true"`
+	OriginOnlyJournalFile string `split_words:"true"`
+	// OriginOnlyJournalMaxSizeMb and OriginOnlyJournalMaxAgeHours drop the oldest journaled writes (which are then
+	// never applied to Target) so that the journal doesn't fill the disk, 0 disables the limit
+	OriginOnlyJournalMaxSizeMb            int `default:"0" split_words:"true"`
+	OriginOnlyJournalMaxAgeHours          int `default:"0" split_words:"true"`
+	OriginOnlyJournalCompactionIntervalMs int `default:"60000" split_words:"true"`
+
+	TargetWritePipeline                   string `default:"DIRECT" split_words:"true"`
+	TargetWritePipelineKafkaBrokers       string `split_words:"true"`
+	TargetWritePipelineKafkaTopic         string `split_words:"true"`
+	TargetWritePipelineKafkaConsumerGroup string `default:"zdm-proxy" split_words:"true"`
+	TargetWritePipelineDedupWindowSize    int    `default:"100000" split_words:"true"`
+
+	GuardrailBatchSizeWarnBytes    int  `default:"0" split_words:"true"`
+	GuardrailBatchSizeFailBytes    int  `default:"0" split_words:"true"`
+	GuardrailBatchStatementsWarn   int  `default:"0" split_words:"true"`
+	GuardrailBatchStatementsFail   int  `default:"0" split_words:"true"`
+	GuardrailMutationSizeWarnBytes int  `default:"0" split_words:"true"`
+	GuardrailMutationSizeFailBytes int  `default:"0" split_words:"true"`
+	GuardrailMaxQueryLength        int  `default:"0" split_words:"true"`
+	GuardrailMaxValuesSizeBytes    int  `default:"0" split_words:"true"`
+	GuardrailClientWarningsEnabled bool `default:"false" split_words:"true"`
+
+	PageSizeMax     int `default:"0" split_words:"true"`
+	PageSizeDefault int `default:"0" split_words:"true"`
+
+	ProxyConnectionAcceptRateLimit int `default:"0" split_words:"true"`
+	ProxyMaxConcurrentHandshakes   int `default:"0" split_words:"true"`
+	ProxyHandshakeQueueTimeoutMs   int `default:"10000" split_words:"true"`
+
 	ProxyTlsCaPath            string `split_words:"true"`
 	ProxyTlsCertPath          string `split_words:"true"`
 	ProxyTlsKeyPath           string `split_words:"true"`
 	ProxyTlsRequireClientAuth bool   `split_words:"true"`
+	ProxyTlsMinVersion        string `split_words:"true"`
+	ProxyTlsCipherSuites      string `split_words:"true"`
+
+	TlsReloadIntervalMs int `default:"0" split_words:"true"`
+
+	ProxyClientCredentialsFile     string `split_words:"true"`
+	ProxyClientJwtJwksUrl          string `split_words:"true"`
+	ProxyClientJwtIssuer           string `split_words:"true"`
+	ProxyClientJwtAudience         string `split_words:"true"`
+	ProxyClientJwtCredentialsClaim string `split_words:"true"`
+	ProxyClientJwtCredentialsFile  string `split_words:"true"`
+
+	RoleMappingFile string `split_words:"true"`
+
+	OriginAuthPassthroughEnabled bool `default:"false" split_words:"true"`
+
+	StatementRulesFile string `split_words:"true"`
+
+	TokenRangeRoutingFile             string `split_words:"true"`
+	TokenRangeRoutingReloadIntervalMs int    `default:"10000" split_words:"true"`
+
+	MigrationStatusTable             string `split_words:"true"`
+	MigrationStatusRefreshIntervalMs int    `default:"30000" split_words:"true"`
+
+	AutoCutoverEnabled          bool    `default:"false" split_words:"true"`
+	AutoCutoverMaxMismatchRate  float64 `default:"0.0001" split_words:"true"`
+	AutoCutoverMinComparedReads int     `default:"1000" split_words:"true"`
+	AutoCutoverStableDurationMs int     `default:"3600000" split_words:"true"`
+	AutoCutoverCheckIntervalMs  int     `default:"60000" split_words:"true"`
+
+	ReadRepairToTargetEnabled bool `default:"false" split_words:"true"`
+
+	SchemaCheckEnabled   bool   `default:"false" split_words:"true"`
+	SchemaCheckKeyspaces string `split_words:"true"`
+
+	ViewIndexCheckEnabled           bool `default:"false" split_words:"true"`
+	ViewIndexCheckRefreshIntervalMs int  `default:"300000" split_words:"true"`
+
+	ClockSkewCheckEnabled    bool `default:"false" split_words:"true"`
+	ClockSkewCheckIntervalMs int  `default:"60000" split_words:"true"`
+	ClockSkewThresholdMs     int  `default:"100" split_words:"true"`
+
+	PrepareCoalescingEnabled bool `default:"false" split_words:"true"`
+
+	TargetWriteSamplingEnabled bool    `default:"false" split_words:"true"`
+	TargetWriteSamplingPercent float64 `default:"100" split_words:"true"`
+
+	TraceContextPropagation bool `default:"false" split_words:"true"`
+
+	FrameCaptureSize          int  `default:"0" split_words:"true"`
+	FrameCaptureBodiesEnabled bool `default:"false" split_words:"true"`
+
+	FrameExportDir    string `split_words:"true"`
+	FrameExportFormat string `default:"PCAP" split_words:"true"`
+
+	HeavyHittersSize int `default:"0" split_words:"true"`
+
+	StatementMetricsMaxFingerprints int `default:"0" split_words:"true"`
+
+	// PayloadSizeMetricsEnabled records the size of the requests and responses of each cluster per statement type in
+	// histograms with the PayloadSizeMetricsBucketsBytes buckets
+	PayloadSizeMetricsEnabled      bool   `default:"false" split_words:"true"`
+	PayloadSizeMetricsBucketsBytes string `default:"64, 256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304, 16777216" split_words:"true"`
 
 	// Metrics bucket
 
@@ -80,6 +249,30 @@ type Config struct {
 	MetricsTargetLatencyBucketsMs    string `default:"1, 4, 7, 10, 25, 40, 60, 80, 100, 150, 250, 500, 1000, 2500, 5000, 10000, 15000" split_words:"true"`
 	MetricsAsyncReadLatencyBucketsMs string `default:"1, 4, 7, 10, 25, 40, 60, 80, 100, 150, 250, 500, 1000, 2500, 5000, 10000, 15000" split_words:"true"`
 
+	// MetricsTlsCertPath and MetricsTlsKeyPath serve the metrics, health checks and admin API over HTTPS, the client
+	// certificates are verified against MetricsTlsClientCaPath if it's set (clients without certificate can still
+	// connect, e.g. the health probes)
+	MetricsTlsCertPath     string `split_words:"true"`
+	MetricsTlsKeyPath      string `split_words:"true"`
+	MetricsTlsClientCaPath string `split_words:"true"`
+
+	// AdminApiEnabled serves the admin operations under /admin/ on the http server of the metrics
+	AdminApiEnabled bool `default:"false" split_words:"true"`
+	// FaultInjectionEnabled allows injecting latency and errors in the responses of the clusters (/admin/faults),
+	// it is meant for test environments only
+	FaultInjectionEnabled bool `default:"false" split_words:"true"`
+	// AdminApiOperatorTokens and AdminApiReadOnlyTokens are the comma separated bearer tokens of the operator role,
+	// which can call every admin operation, and of the read-only role, which can only call the GET operations. The
+	// admin API requires one of the roles if a token or a client certificate name is set.
+	AdminApiOperatorTokens string `split_words:"true" json:"-"`
+	AdminApiReadOnlyTokens string `split_words:"true" json:"-"`
+	// AdminApiOperatorCertNames and AdminApiReadOnlyCertNames are the comma separated common names of the client
+	// certificates of each role, they require ZDM_METRICS_TLS_CLIENT_CA_PATH
+	AdminApiOperatorCertNames string `split_words:"true"`
+	AdminApiReadOnlyCertNames string `split_words:"true"`
+	// MetricsAuthRequired requires the read-only (or operator) role on /metrics too
+	MetricsAuthRequired bool `default:"false" split_words:"true"`
+
 	// Heartbeat bucket
 
 	HeartbeatIntervalMs int `default:"30000" split_words:"true"`
@@ -89,6 +282,48 @@ type Config struct {
 	HeartbeatRetryBackoffFactor float64 `default:"2" split_words:"true"`
 	HeartbeatFailureThreshold   int     `default:"1" split_words:"true"`
 
+	HeartbeatLocalResponseEnabled bool `default:"false" split_words:"true"`
+
+	// Mutation export bucket
+
+	MutationExportKafkaBrokers string `split_words:"true"`
+	MutationExportKafkaTopic   string `split_words:"true"`
+	MutationExportQueueSize    int    `default:"10000" split_words:"true"`
+
+	// Continuous profiling bucket
+
+	ContinuousProfilingServerUrl       string `split_words:"true"`
+	ContinuousProfilingApplicationName string `default:"zdm-proxy" split_words:"true"`
+	ContinuousProfilingInstance        string `split_words:"true"`
+	ContinuousProfilingProfileTypes    string `default:"cpu, alloc" split_words:"true"`
+	ContinuousProfilingIntervalMs      int    `default:"10000" split_words:"true"`
+	ContinuousProfilingAuthToken       string `split_words:"true" json:"-"`
+
+	// Error reporting bucket
+
+	ErrorReportingDsn         string `split_words:"true" json:"-"`
+	ErrorReportingEnvironment string `split_words:"true"`
+
+	// Logging bucket
+
+	LogOutput                    string `default:"STDERR" split_words:"true"`
+	LogFilePath                  string `split_words:"true"`
+	LogFileMaxSizeMb             int    `default:"100" split_words:"true"`
+	LogFileRotationIntervalHours int    `default:"24" split_words:"true"`
+	LogFileMaxBackups            int    `default:"7" split_words:"true"`
+	LogSyslogAddress             string `default:"unix:///dev/log" split_words:"true"`
+	LogSyslogFacility            string `default:"LOCAL0" split_words:"true"`
+	LogSyslogAppName             string `default:"zdm-proxy" split_words:"true"`
+
+	// Shared configuration bucket
+
+	SharedConfigBackend   string `default:"NONE" split_words:"true"`
+	SharedConfigEndpoints string `split_words:"true"`
+	SharedConfigPrefix    string `default:"zdm-proxy/" split_words:"true"`
+	SharedConfigToken     string `split_words:"true" json:"-"`
+
+	SharedConfigLeaderTtlMs int `default:"15000" split_words:"true"`
+
 	//////////////////////////////////////////////////////////////////////
 	/// THE SETTINGS BELOW AREN'T SUPPORTED AND MAY CHANGE AT ANY TIME ///
 	//////////////////////////////////////////////////////////////////////
@@ -108,10 +343,14 @@ type Config struct {
 	RequestWriteBufferSizeBytes int `default:"4096" split_words:"true"`
 	RequestReadBufferSizeBytes  int `default:"32768" split_words:"true"`
 
+	RequestWriteQueueOverflowPolicy string `default:"BLOCK" split_words:"true"`
+
 	ResponseWriteQueueSizeFrames int `default:"128" split_words:"true"`
 	ResponseWriteBufferSizeBytes int `default:"8192" split_words:"true"`
 	ResponseReadBufferSizeBytes  int `default:"32768" split_words:"true"`
 
+	ResponseStreamingThresholdBytes int `default:"0" split_words:"true"`
+
 	RequestResponseMaxWorkers int `default:"-1" split_words:"true"`
 	WriteMaxWorkers           int `default:"-1" split_words:"true"`
 	ReadMaxWorkers            int `default:"-1" split_words:"true"`
@@ -141,6 +380,11 @@ func (c *Config) ParseEnvVars() (*Config, error) {
 		return nil, fmt.Errorf("could not load environment variables: %w", err)
 	}
 
+	err = c.checkRequiredCredentialEnvVars()
+	if err != nil {
+		return nil, fmt.Errorf("could not load environment variables: %w", err)
+	}
+
 	err = c.Validate()
 	if err != nil {
 		return nil, err
@@ -227,6 +471,11 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid log level: %w", err)
 	}
 
+	_, err = c.ParseLogRedactionConfig()
+	if err != nil {
+		return err
+	}
+
 	_, err = c.ParseTargetContactPoints()
 	if err != nil {
 		return fmt.Errorf("invalid target configuration: %w", err)
@@ -237,6 +486,16 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid origin configuration: %w", err)
 	}
 
+	_, err = c.ParseOriginPeerPortMapping()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.ParseTargetPeerPortMapping()
+	if err != nil {
+		return err
+	}
+
 	_, err = c.ParseOriginBuckets()
 	if err != nil {
 		return fmt.Errorf("could not parse origin buckets: %v", err)
@@ -252,6 +511,16 @@ func (c *Config) Validate() error {
 		return err
 	}
 
+	_, err = c.ParseProxyClientAllowedCidrs()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.ParseProxyClientDeniedCidrs()
+	if err != nil {
+		return err
+	}
+
 	_, err = c.ParseOriginTlsConfig(false)
 	if err != nil {
 		return err
@@ -267,339 +536,2205 @@ func (c *Config) Validate() error {
 		return err
 	}
 
-	_, err = c.ParsePrimaryCluster()
+	_, err = c.ParseMetricsTlsConfig()
 	if err != nil {
 		return err
 	}
 
-	_, err = c.ParseSystemQueriesMode()
+	_, err = c.ParseAdminApiAuthConfig()
 	if err != nil {
 		return err
 	}
 
-	_, err = c.ParseReadMode()
+	_, _, err = c.ParseOriginCredentials()
 	if err != nil {
 		return err
 	}
 
-	return nil
-}
+	_, _, err = c.ParseTargetCredentials()
+	if err != nil {
+		return err
+	}
 
-const (
-	SystemQueriesModeOrigin = "ORIGIN"
-	SystemQueriesModeTarget = "TARGET"
-)
+	err = c.validateSigv4Config()
+	if err != nil {
+		return err
+	}
 
-func (c *Config) ParseSystemQueriesMode() (common.SystemQueriesMode, error) {
-	switch strings.ToUpper(c.SystemQueriesMode) {
-	case SystemQueriesModeTarget:
-		return common.SystemQueriesModeTarget, nil
-	case SystemQueriesModeOrigin:
-		return common.SystemQueriesModeOrigin, nil
-	default:
-		return common.SystemQueriesModeUndefined, fmt.Errorf("invalid value for ZDM_SYSTEM_QUERIES_MODE; possible values are: %v and %v",
-			SystemQueriesModeTarget, SystemQueriesModeOrigin)
+	err = c.validateProxyClientAuthConfig()
+	if err != nil {
+		return err
 	}
-}
 
-const (
-	PrimaryClusterOrigin = "ORIGIN"
-	PrimaryClusterTarget = "TARGET"
-)
+	err = c.validateOriginAuthPassthroughConfig()
+	if err != nil {
+		return err
+	}
 
-func (c *Config) ParsePrimaryCluster() (common.ClusterType, error) {
-	switch strings.ToUpper(c.PrimaryCluster) {
-	case PrimaryClusterOrigin:
-		return common.ClusterTypeOrigin, nil
-	case PrimaryClusterTarget:
-		return common.ClusterTypeTarget, nil
-	default:
-		return common.ClusterTypeNone, fmt.Errorf("invalid value for ZDM_PRIMARY_CLUSTER; possible values are: %v and %v",
-			PrimaryClusterOrigin, PrimaryClusterTarget)
+	_, err = c.ParsePrimaryCluster()
+	if err != nil {
+		return err
 	}
-}
 
-const (
-	ReadModePrimaryOnly          = "PRIMARY_ONLY"
-	ReadModeDualAsyncOnSecondary = "DUAL_ASYNC_ON_SECONDARY"
-)
+	_, err = c.ParseSystemQueriesMode()
+	if err != nil {
+		return err
+	}
 
-func (c *Config) ParseReadMode() (common.ReadMode, error) {
-	switch strings.ToUpper(c.ReadMode) {
-	case ReadModePrimaryOnly:
-		return common.ReadModePrimaryOnly, nil
-	case ReadModeDualAsyncOnSecondary:
-		return common.ReadModeDualAsyncOnSecondary, nil
-	default:
-		return common.ReadModeUndefined, fmt.Errorf("invalid value for ZDM_READ_MODE; possible values are: %v and %v",
-			ReadModePrimaryOnly, ReadModeDualAsyncOnSecondary)
+	_, err = c.ParseReadMode()
+	if err != nil {
+		return err
 	}
-}
 
-func (c *Config) ParseLogLevel() (log.Level, error) {
-	level, err := log.ParseLevel(strings.TrimSpace(c.LogLevel))
+	_, err = c.ParseOriginCompatibilityProfile()
 	if err != nil {
-		var lvl log.Level
-		return lvl, fmt.Errorf("invalid log level, valid log levels are "+
-			"PANIC, FATAL, ERROR, WARN or WARNING, INFO, DEBUG and TRACE; original err: %w", err)
+		return err
 	}
 
-	return level, nil
-}
+	_, err = c.ParseTargetCompatibilityProfile()
+	if err != nil {
+		return err
+	}
 
-func (c *Config) ParseOriginBuckets() ([]float64, error) {
-	return c.parseBuckets(c.MetricsOriginLatencyBucketsMs)
-}
+	_, err = c.ParseOriginConnectionCompression()
+	if err != nil {
+		return err
+	}
 
-func (c *Config) ParseTargetBuckets() ([]float64, error) {
-	return c.parseBuckets(c.MetricsTargetLatencyBucketsMs)
-}
+	_, err = c.ParseTargetConnectionCompression()
+	if err != nil {
+		return err
+	}
 
-func (c *Config) ParseAsyncBuckets() ([]float64, error) {
-	return c.parseBuckets(c.MetricsAsyncReadLatencyBucketsMs)
-}
+	_, err = c.ParseMutationExportKafkaBrokers()
+	if err != nil {
+		return err
+	}
 
-func (c *Config) parseBuckets(bucketsConfigStr string) ([]float64, error) {
-	var bucketsArr []float64
-	bucketsStrArr := strings.Split(bucketsConfigStr, ",")
-	if len(bucketsStrArr) == 0 {
-		return nil, fmt.Errorf("unable to parse buckets from %v: at least one bucket is required", bucketsConfigStr)
+	_, err = c.ParseStartupStrippedOptions()
+	if err != nil {
+		return err
 	}
 
-	for _, bucketStr := range bucketsStrArr {
-		bucket, err := strconv.ParseFloat(strings.TrimSpace(bucketStr), 64)
-		if err != nil {
-			return nil, fmt.Errorf(
-				"unable to parse buckets from %v: could not convert %v to float",
-				bucketsConfigStr,
-				bucketStr)
-		}
-		bucketsArr = append(bucketsArr, bucket/1000) // convert ms to seconds
+	_, err = c.ParseMaxClientProtocolVersion()
+	if err != nil {
+		return err
 	}
 
-	return bucketsArr, nil
-}
+	_, err = c.ParseContinuousProfilingProfileTypes()
+	if err != nil {
+		return err
+	}
 
-func (c *Config) ParseOriginContactPoints() ([]string, error) {
-	if isDefined(c.OriginSecureConnectBundlePath) && isDefined(c.OriginContactPoints) {
-		return nil, fmt.Errorf("OriginSecureConnectBundlePath and OriginContactPoints are mutually exclusive. Please specify only one of them.")
+	_, err = c.ParseErrorReportingDsn()
+	if err != nil {
+		return err
 	}
 
-	if isDefined(c.OriginSecureConnectBundlePath) && isDefined(c.OriginLocalDatacenter) {
-		return nil, fmt.Errorf("OriginSecureConnectBundlePath and OriginLocalDatacenter are mutually exclusive. Please specify only one of them.")
+	_, err = c.ParseLogOutputConfig()
+	if err != nil {
+		return err
 	}
 
-	if isNotDefined(c.OriginSecureConnectBundlePath) && isNotDefined(c.OriginContactPoints) {
-		return nil, fmt.Errorf("Both OriginSecureConnectBundlePath and OriginContactPoints are empty. Please specify either one of them.")
+	_, err = c.ParseEventsSource()
+	if err != nil {
+		return err
 	}
 
-	if isDefined(c.OriginContactPoints) && (c.OriginPort == 0) {
-		return nil, fmt.Errorf("OriginContactPoints was specified but the port is missing. Please provide OriginPort")
+	_, err = c.ParseErrorMessageScrubbing()
+	if err != nil {
+		return err
 	}
 
-	if (c.OriginEnableHostAssignment == false) && (isDefined(c.OriginLocalDatacenter)) {
-		return nil, fmt.Errorf("OriginLocalDatacenter was specified but OriginEnableHostAssignment is false. Please enable host assignment or don't set the datacenter.")
+	_, err = c.ParseMemorySoftLimitBytes()
+	if err != nil {
+		return err
 	}
 
-	if isNotDefined(c.OriginSecureConnectBundlePath) {
-		contactPoints := parseContactPoints(c.OriginContactPoints)
-		if len(contactPoints) <= 0 {
-			return nil, fmt.Errorf("could not parse origin contact points: %v", c.OriginContactPoints)
-		}
+	_, err = c.ParseGoroutineAuditConfig()
+	if err != nil {
+		return err
+	}
 
-		return contactPoints, nil
+	_, err = c.ParseRequestWriteQueueOverflowPolicy()
+	if err != nil {
+		return err
 	}
 
-	return nil, nil
-}
+	_, err = c.ParseProxyRequestRateLimit()
+	if err != nil {
+		return err
+	}
 
-func (c *Config) ParseTargetContactPoints() ([]string, error) {
-	if isDefined(c.TargetSecureConnectBundlePath) && isDefined(c.TargetContactPoints) {
-		return nil, fmt.Errorf("TargetSecureConnectBundlePath and TargetContactPoints are mutually exclusive. Please specify only one of them.")
+	_, err = c.ParseProxyRequestDeadline()
+	if err != nil {
+		return err
 	}
 
-	if isDefined(c.TargetSecureConnectBundlePath) && isDefined(c.TargetLocalDatacenter) {
-		return nil, fmt.Errorf("TargetSecureConnectBundlePath and TargetLocalDatacenter are mutually exclusive. Please specify only one of them.")
+	_, err = c.ParseProxyGlobalRequestRateLimit()
+	if err != nil {
+		return err
 	}
 
-	if isNotDefined(c.TargetSecureConnectBundlePath) && isNotDefined(c.TargetContactPoints) {
-		return nil, fmt.Errorf("Both TargetSecureConnectBundlePath and TargetContactPoints are empty. Please specify either one of them.")
+	_, err = c.ParseSharedConfigEndpoints()
+	if err != nil {
+		return err
 	}
 
-	if (isDefined(c.TargetContactPoints)) && (c.TargetPort == 0) {
-		return nil, fmt.Errorf("TargetContactPoints was specified but the port is missing. Please provide TargetPort")
+	_, err = c.ParseMigrationStatusTable()
+	if err != nil {
+		return err
 	}
 
-	if (c.TargetEnableHostAssignment == false) && (isDefined(c.TargetLocalDatacenter)) {
-		return nil, fmt.Errorf("TargetLocalDatacenter was specified but TargetEnableHostAssignment is false. Please enable host assignment or don't set the datacenter.")
+	_, err = c.ParseAutoCutoverConfig()
+	if err != nil {
+		return err
 	}
 
-	if isNotDefined(c.TargetSecureConnectBundlePath) {
-		contactPoints := parseContactPoints(c.TargetContactPoints)
-		if len(contactPoints) <= 0 {
+	_, err = c.ParseSchemaCheckKeyspaces()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.ParseViewIndexCheckRefreshInterval()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.ParseClockSkewCheckConfig()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.ParseTargetWriteSamplingPercent()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.ParseFrameCaptureSize()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.ParseFrameExportFormat()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.ParseHeavyHittersSize()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.ParseStatementMetricsMaxFingerprints()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.ParsePayloadSizeMetricsBuckets()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.ParseStartupPolicy()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.ParseTargetDownPolicy()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.ParseOriginOnlyJournalRetention()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.ParseTargetWritePipeline()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.ParseProxyConnectionAcceptRateLimit()
+	if err != nil {
+		return err
+	}
+
+	_, _, err = c.ParseProxyMaxConcurrentHandshakes()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.ParseGuardrailsConfig()
+	if err != nil {
+		return err
+	}
+
+	_, _, err = c.ParsePageSizeOverride()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.ParseSecondaryErrorPolicy()
+	if err != nil {
+		return err
+	}
+
+	_, err = c.ParseDualWriteResponsePolicy()
+	if err != nil {
+		return err
+	}
+
+	_, _, err = c.ParseLwtSerialConsistencies()
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+const (
+	SystemQueriesModeOrigin = "ORIGIN"
+	SystemQueriesModeTarget = "TARGET"
+)
+
+func (c *Config) ParseSystemQueriesMode() (common.SystemQueriesMode, error) {
+	switch strings.ToUpper(c.SystemQueriesMode) {
+	case SystemQueriesModeTarget:
+		return common.SystemQueriesModeTarget, nil
+	case SystemQueriesModeOrigin:
+		return common.SystemQueriesModeOrigin, nil
+	default:
+		return common.SystemQueriesModeUndefined, fmt.Errorf("invalid value for ZDM_SYSTEM_QUERIES_MODE; possible values are: %v and %v",
+			SystemQueriesModeTarget, SystemQueriesModeOrigin)
+	}
+}
+
+const (
+	PrimaryClusterOrigin = "ORIGIN"
+	PrimaryClusterTarget = "TARGET"
+)
+
+func (c *Config) ParsePrimaryCluster() (common.ClusterType, error) {
+	switch strings.ToUpper(c.PrimaryCluster) {
+	case PrimaryClusterOrigin:
+		return common.ClusterTypeOrigin, nil
+	case PrimaryClusterTarget:
+		return common.ClusterTypeTarget, nil
+	default:
+		return common.ClusterTypeNone, fmt.Errorf("invalid value for ZDM_PRIMARY_CLUSTER; possible values are: %v and %v",
+			PrimaryClusterOrigin, PrimaryClusterTarget)
+	}
+}
+
+const (
+	ReadModePrimaryOnly          = "PRIMARY_ONLY"
+	ReadModeDualAsyncOnSecondary = "DUAL_ASYNC_ON_SECONDARY"
+	ReadModeHedged               = "HEDGED"
+)
+
+func (c *Config) ParseReadMode() (common.ReadMode, error) {
+	switch strings.ToUpper(c.ReadMode) {
+	case ReadModePrimaryOnly:
+		return common.ReadModePrimaryOnly, nil
+	case ReadModeDualAsyncOnSecondary:
+		return common.ReadModeDualAsyncOnSecondary, nil
+	case ReadModeHedged:
+		return common.ReadModeHedged, nil
+	default:
+		return common.ReadModeUndefined, fmt.Errorf("invalid value for ZDM_READ_MODE; possible values are: %v, %v and %v",
+			ReadModePrimaryOnly, ReadModeDualAsyncOnSecondary, ReadModeHedged)
+	}
+}
+
+const (
+	EventsSourceDefault = "DEFAULT"
+	EventsSourceOrigin  = "ORIGIN"
+	EventsSourceTarget  = "TARGET"
+	EventsSourcePrimary = "PRIMARY"
+	EventsSourceNone    = "NONE"
+)
+
+// ParseEventsSource returns the cluster whose EVENT messages are forwarded to the clients that registered for them.
+// DEFAULT forwards schema change events from ORIGIN and status and topology change events from TARGET.
+func (c *Config) ParseEventsSource() (common.EventsSource, error) {
+	switch strings.ToUpper(strings.TrimSpace(c.EventsSource)) {
+	case "", EventsSourceDefault:
+		return common.EventsSourceDefault, nil
+	case EventsSourceOrigin:
+		return common.EventsSourceOrigin, nil
+	case EventsSourceTarget:
+		return common.EventsSourceTarget, nil
+	case EventsSourcePrimary:
+		return common.EventsSourcePrimary, nil
+	case EventsSourceNone:
+		return common.EventsSourceNone, nil
+	default:
+		return common.EventsSourceUndefined, fmt.Errorf(
+			"invalid value for ZDM_EVENTS_SOURCE; possible values are: %v, %v, %v, %v and %v",
+			EventsSourceDefault, EventsSourceOrigin, EventsSourceTarget, EventsSourcePrimary, EventsSourceNone)
+	}
+}
+
+const (
+	CompatibilityProfileNone      = "NONE"
+	CompatibilityProfileAstra     = "ASTRA"
+	CompatibilityProfileKeyspaces = "KEYSPACES"
+	CompatibilityProfileCosmos    = "COSMOS"
+)
+
+// ParseOriginCompatibilityProfile returns the compatibility profile of ORIGIN. If ZDM_ORIGIN_COMPATIBILITY_PROFILE
+// is not set then ASTRA is used when a secure connect bundle is provided and NONE otherwise.
+func (c *Config) ParseOriginCompatibilityProfile() (common.CompatibilityProfile, error) {
+	return parseCompatibilityProfile(
+		c.OriginCompatibilityProfile, c.OriginSecureConnectBundlePath, "ZDM_ORIGIN_COMPATIBILITY_PROFILE")
+}
+
+// ParseTargetCompatibilityProfile returns the compatibility profile of TARGET. If ZDM_TARGET_COMPATIBILITY_PROFILE
+// is not set then ASTRA is used when a secure connect bundle is provided and NONE otherwise.
+func (c *Config) ParseTargetCompatibilityProfile() (common.CompatibilityProfile, error) {
+	return parseCompatibilityProfile(
+		c.TargetCompatibilityProfile, c.TargetSecureConnectBundlePath, "ZDM_TARGET_COMPATIBILITY_PROFILE")
+}
+
+func parseCompatibilityProfile(
+	profile string, secureConnectBundlePath string, envVarName string) (common.CompatibilityProfile, error) {
+	switch strings.ToUpper(strings.TrimSpace(profile)) {
+	case "":
+		if isDefined(secureConnectBundlePath) {
+			return common.CompatibilityProfileAstra, nil
+		}
+		return common.CompatibilityProfileNone, nil
+	case CompatibilityProfileNone:
+		return common.CompatibilityProfileNone, nil
+	case CompatibilityProfileAstra:
+		return common.CompatibilityProfileAstra, nil
+	case CompatibilityProfileKeyspaces:
+		return common.CompatibilityProfileKeyspaces, nil
+	case CompatibilityProfileCosmos:
+		return common.CompatibilityProfileCosmos, nil
+	default:
+		return common.CompatibilityProfileUndefined, fmt.Errorf("invalid value for %v; possible values are: %v, %v, %v and %v",
+			envVarName, CompatibilityProfileNone, CompatibilityProfileAstra, CompatibilityProfileKeyspaces, CompatibilityProfileCosmos)
+	}
+}
+
+const (
+	ConnectionCompressionNone = "NONE"
+	ConnectionCompressionLz4  = "LZ4"
+)
+
+// ParseOriginConnectionCompression returns the compression that the proxy negotiates on its connections to ORIGIN
+// when the client doesn't use compression.
+func (c *Config) ParseOriginConnectionCompression() (common.ConnectionCompression, error) {
+	return parseConnectionCompression(c.OriginConnectionCompression, "ZDM_ORIGIN_CONNECTION_COMPRESSION")
+}
+
+// ParseTargetConnectionCompression returns the compression that the proxy negotiates on its connections to TARGET
+// when the client doesn't use compression.
+func (c *Config) ParseTargetConnectionCompression() (common.ConnectionCompression, error) {
+	return parseConnectionCompression(c.TargetConnectionCompression, "ZDM_TARGET_CONNECTION_COMPRESSION")
+}
+
+func parseConnectionCompression(compression string, envVarName string) (common.ConnectionCompression, error) {
+	switch strings.ToUpper(strings.TrimSpace(compression)) {
+	case "", ConnectionCompressionNone:
+		return common.ConnectionCompressionNone, nil
+	case ConnectionCompressionLz4:
+		return common.ConnectionCompressionLz4, nil
+	default:
+		return common.ConnectionCompressionUndefined, fmt.Errorf("invalid value for %v; possible values are: %v and %v",
+			envVarName, ConnectionCompressionNone, ConnectionCompressionLz4)
+	}
+}
+
+func (c *Config) ParseLogLevel() (log.Level, error) {
+	level, err := log.ParseLevel(strings.TrimSpace(c.LogLevel))
+	if err != nil {
+		var lvl log.Level
+		return lvl, fmt.Errorf("invalid log level, valid log levels are "+
+			"PANIC, FATAL, ERROR, WARN or WARNING, INFO, DEBUG and TRACE; original err: %w", err)
+	}
+
+	return level, nil
+}
+
+const (
+	LogRedactionNone     = "NONE"
+	LogRedactionAll      = "ALL"
+	LogRedactionMatching = "MATCHING"
+)
+
+// ParseLogRedactionConfig returns which statements have their literals and bound values masked in the logs, nil if
+// ZDM_LOG_REDACTION is NONE.
+func (c *Config) ParseLogRedactionConfig() (*common.LogRedactionConfig, error) {
+	var mode common.LogRedactionMode
+	switch strings.ToUpper(strings.TrimSpace(c.LogRedaction)) {
+	case "", LogRedactionNone:
+		mode = common.LogRedactionModeNone
+	case LogRedactionAll:
+		mode = common.LogRedactionModeAll
+	case LogRedactionMatching:
+		mode = common.LogRedactionModeMatching
+	default:
+		return nil, fmt.Errorf("invalid value for ZDM_LOG_REDACTION; possible values are: %v, %v and %v",
+			LogRedactionNone, LogRedactionAll, LogRedactionMatching)
+	}
+
+	tables, err := parseLogRedactionPatterns("ZDM_LOG_REDACTION_TABLES", c.LogRedactionTables)
+	if err != nil {
+		return nil, err
+	}
+	columns, err := parseLogRedactionPatterns("ZDM_LOG_REDACTION_COLUMNS", c.LogRedactionColumns)
+	if err != nil {
+		return nil, err
+	}
+	if mode == common.LogRedactionModeMatching && len(tables) == 0 && len(columns) == 0 {
+		return nil, fmt.Errorf("ZDM_LOG_REDACTION is %v but neither ZDM_LOG_REDACTION_TABLES nor "+
+			"ZDM_LOG_REDACTION_COLUMNS is set", LogRedactionMatching)
+	}
+	if mode != common.LogRedactionModeMatching && (len(tables) > 0 || len(columns) > 0) {
+		return nil, fmt.Errorf("ZDM_LOG_REDACTION_TABLES and ZDM_LOG_REDACTION_COLUMNS require ZDM_LOG_REDACTION "+
+			"to be %v", LogRedactionMatching)
+	}
+	if mode == common.LogRedactionModeNone {
+		return nil, nil
+	}
+	return &common.LogRedactionConfig{Mode: mode, Tables: tables, Columns: columns}, nil
+}
+
+// parseLogRedactionPatterns returns the (lower case) glob patterns of a comma separated list, e.g. "ks.users,*.*_pii".
+func parseLogRedactionPatterns(name string, value string) ([]string, error) {
+	var patterns []string
+	for _, pattern := range strings.Split(value, ",") {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		if pattern == "" {
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid value for %v: %v, %v is not a valid pattern", name, value, pattern)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+func (c *Config) ParseOriginBuckets() ([]float64, error) {
+	return c.parseBuckets(c.MetricsOriginLatencyBucketsMs)
+}
+
+func (c *Config) ParseTargetBuckets() ([]float64, error) {
+	return c.parseBuckets(c.MetricsTargetLatencyBucketsMs)
+}
+
+func (c *Config) ParseAsyncBuckets() ([]float64, error) {
+	return c.parseBuckets(c.MetricsAsyncReadLatencyBucketsMs)
+}
+
+func (c *Config) parseBuckets(bucketsConfigStr string) ([]float64, error) {
+	bucketsArr, err := parseBucketValues(bucketsConfigStr)
+	if err != nil {
+		return nil, err
+	}
+	for i := range bucketsArr {
+		bucketsArr[i] = bucketsArr[i] / 1000 // convert ms to seconds
+	}
+	return bucketsArr, nil
+}
+
+func parseBucketValues(bucketsConfigStr string) ([]float64, error) {
+	var bucketsArr []float64
+	bucketsStrArr := strings.Split(bucketsConfigStr, ",")
+	if len(bucketsStrArr) == 0 {
+		return nil, fmt.Errorf("unable to parse buckets from %v: at least one bucket is required", bucketsConfigStr)
+	}
+
+	for _, bucketStr := range bucketsStrArr {
+		bucket, err := strconv.ParseFloat(strings.TrimSpace(bucketStr), 64)
+		if err != nil {
+			return nil, fmt.Errorf(
+				"unable to parse buckets from %v: could not convert %v to float",
+				bucketsConfigStr,
+				bucketStr)
+		}
+		bucketsArr = append(bucketsArr, bucket)
+	}
+
+	return bucketsArr, nil
+}
+
+func (c *Config) ParseOriginContactPoints() ([]string, error) {
+	if isDefined(c.OriginSecureConnectBundlePath) && isDefined(c.OriginContactPoints) {
+		return nil, fmt.Errorf("OriginSecureConnectBundlePath and OriginContactPoints are mutually exclusive. Please specify only one of them.")
+	}
+
+	if isDefined(c.OriginSecureConnectBundlePath) && isDefined(c.OriginLocalDatacenter) {
+		return nil, fmt.Errorf("OriginSecureConnectBundlePath and OriginLocalDatacenter are mutually exclusive. Please specify only one of them.")
+	}
+
+	if isNotDefined(c.OriginSecureConnectBundlePath) && isNotDefined(c.OriginContactPoints) {
+		return nil, fmt.Errorf("Both OriginSecureConnectBundlePath and OriginContactPoints are empty. Please specify either one of them.")
+	}
+
+	if isDefined(c.OriginContactPoints) && (c.OriginPort == 0) {
+		return nil, fmt.Errorf("OriginContactPoints was specified but the port is missing. Please provide OriginPort")
+	}
+
+	if (c.OriginEnableHostAssignment == false) && (isDefined(c.OriginLocalDatacenter)) {
+		return nil, fmt.Errorf("OriginLocalDatacenter was specified but OriginEnableHostAssignment is false. Please enable host assignment or don't set the datacenter.")
+	}
+
+	if isNotDefined(c.OriginSecureConnectBundlePath) {
+		contactPoints := parseContactPoints(c.OriginContactPoints)
+		if len(contactPoints) <= 0 {
+			return nil, fmt.Errorf("could not parse origin contact points: %v", c.OriginContactPoints)
+		}
+		for _, contactPoint := range contactPoints {
+			if _, _, err := SplitContactPoint(contactPoint, c.OriginPort); err != nil {
+				return nil, err
+			}
+		}
+
+		return contactPoints, nil
+	}
+
+	return nil, nil
+}
+
+func (c *Config) ParseTargetContactPoints() ([]string, error) {
+	if isDefined(c.TargetSecureConnectBundlePath) && isDefined(c.TargetContactPoints) {
+		return nil, fmt.Errorf("TargetSecureConnectBundlePath and TargetContactPoints are mutually exclusive. Please specify only one of them.")
+	}
+
+	if isDefined(c.TargetSecureConnectBundlePath) && isDefined(c.TargetLocalDatacenter) {
+		return nil, fmt.Errorf("TargetSecureConnectBundlePath and TargetLocalDatacenter are mutually exclusive. Please specify only one of them.")
+	}
+
+	if isNotDefined(c.TargetSecureConnectBundlePath) && isNotDefined(c.TargetContactPoints) {
+		return nil, fmt.Errorf("Both TargetSecureConnectBundlePath and TargetContactPoints are empty. Please specify either one of them.")
+	}
+
+	if (isDefined(c.TargetContactPoints)) && (c.TargetPort == 0) {
+		return nil, fmt.Errorf("TargetContactPoints was specified but the port is missing. Please provide TargetPort")
+	}
+
+	if (c.TargetEnableHostAssignment == false) && (isDefined(c.TargetLocalDatacenter)) {
+		return nil, fmt.Errorf("TargetLocalDatacenter was specified but TargetEnableHostAssignment is false. Please enable host assignment or don't set the datacenter.")
+	}
+
+	if isNotDefined(c.TargetSecureConnectBundlePath) {
+		contactPoints := parseContactPoints(c.TargetContactPoints)
+		if len(contactPoints) <= 0 {
 			return nil, fmt.Errorf("could not parse target contact points: %v", c.TargetContactPoints)
 		}
+		for _, contactPoint := range contactPoints {
+			if _, _, err := SplitContactPoint(contactPoint, c.TargetPort); err != nil {
+				return nil, err
+			}
+		}
+
+		return contactPoints, nil
+	}
+
+	return nil, nil
+}
+
+func parseContactPoints(setting string) []string {
+	return strings.Split(strings.ReplaceAll(setting, " ", ""), ",")
+}
+
+// SplitContactPoint returns the host and the port of a contact point, the port can be set for each contact point
+// (e.g. 10.0.0.1:19042 or [::1]:19042) and defaults to ZDM_ORIGIN_PORT or ZDM_TARGET_PORT.
+func SplitContactPoint(contactPoint string, defaultPort int) (string, int, error) {
+	if !strings.Contains(contactPoint, ":") || net.ParseIP(contactPoint) != nil {
+		return contactPoint, defaultPort, nil
+	}
+	host, portStr, err := net.SplitHostPort(contactPoint)
+	if err != nil {
+		return "", -1, fmt.Errorf("could not parse contact point %v: %w", contactPoint, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return "", -1, fmt.Errorf("invalid port in contact point %v", contactPoint)
+	}
+	return host, port, nil
+}
+
+// ParseOriginPeerPortMapping returns the native port of each ORIGIN node that the proxy must use instead of the one
+// that the cluster reports (e.g. port-forwarded or NATed nodes), keyed by RPC address. The nodes of the contact points
+// that have a port are added to it. It's nil when there's no override.
+func (c *Config) ParseOriginPeerPortMapping() (map[string]int, error) {
+	return parsePeerPortMapping(c.OriginPeerPortMapping, c.OriginContactPoints, c.OriginPort, "ZDM_ORIGIN_PEER_PORT_MAPPING")
+}
+
+// ParseTargetPeerPortMapping is the TARGET counterpart of ParseOriginPeerPortMapping.
+func (c *Config) ParseTargetPeerPortMapping() (map[string]int, error) {
+	return parsePeerPortMapping(c.TargetPeerPortMapping, c.TargetContactPoints, c.TargetPort, "ZDM_TARGET_PEER_PORT_MAPPING")
+}
+
+func parsePeerPortMapping(setting string, contactPoints string, defaultPort int, envVarName string) (map[string]int, error) {
+	var mapping map[string]int
+	if isDefined(setting) {
+		mapping = map[string]int{}
+		for _, entry := range strings.Split(setting, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			parts := strings.Split(entry, "=")
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("invalid value for %v: %v, expected <ADDRESS>=<PORT>", envVarName, entry)
+			}
+			addr := net.ParseIP(strings.Trim(strings.TrimSpace(parts[0]), "[]"))
+			if addr == nil {
+				return nil, fmt.Errorf("invalid value for %v: %v is not an IP address", envVarName, parts[0])
+			}
+			port, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+			if err != nil || port <= 0 || port > 65535 {
+				return nil, fmt.Errorf("invalid value for %v: %v is not a valid port", envVarName, parts[1])
+			}
+			if _, exists := mapping[addr.String()]; exists {
+				return nil, fmt.Errorf("invalid value for %v: %v is listed more than once", envVarName, addr)
+			}
+			mapping[addr.String()] = port
+		}
+	}
+
+	if isNotDefined(contactPoints) {
+		return mapping, nil
+	}
+	for _, contactPoint := range parseContactPoints(contactPoints) {
+		host, port, err := SplitContactPoint(contactPoint, defaultPort)
+		if err != nil {
+			return nil, err
+		}
+		addr := net.ParseIP(host)
+		if port == defaultPort || addr == nil {
+			// hostnames can't be matched with the addresses of the nodes
+			continue
+		}
+		if mapping == nil {
+			mapping = map[string]int{}
+		}
+		if _, exists := mapping[addr.String()]; !exists {
+			mapping[addr.String()] = port
+		}
+	}
+	return mapping, nil
+}
+
+func (c *Config) ParseOriginTlsConfig(displayLogMessages bool) (*common.ClusterTlsConfig, error) {
+
+	minVersion, err := parseTlsMinVersion(c.OriginTlsMinVersion, "ZDM_ORIGIN_TLS_MIN_VERSION")
+	if err != nil {
+		return &common.ClusterTlsConfig{}, err
+	}
+	cipherSuites, err := parseTlsCipherSuites(c.OriginTlsCipherSuites, "ZDM_ORIGIN_TLS_CIPHER_SUITES")
+	if err != nil {
+		return &common.ClusterTlsConfig{}, err
+	}
+	if isDefined(c.OriginTlsCrlPath) && isNotDefined(c.OriginTlsServerCaPath) {
+		return &common.ClusterTlsConfig{}, fmt.Errorf("incorrect TLS configuration for Origin: " +
+			"ZDM_ORIGIN_TLS_CRL_PATH can only be used with ZDM_ORIGIN_TLS_SERVER_CA_PATH")
+	}
+
+	// No TLS defined
+
+	if isNotDefined(c.OriginSecureConnectBundlePath) &&
+		isNotDefined(c.OriginTlsServerCaPath) &&
+		isNotDefined(c.OriginTlsClientCertPath) &&
+		isNotDefined(c.OriginTlsClientKeyPath) {
+		if displayLogMessages {
+			log.Infof("TLS was not configured for Origin")
+		}
+		return &common.ClusterTlsConfig{
+			TlsEnabled: false,
+		}, nil
+	}
+
+	//SCB specified
+
+	if isDefined(c.OriginSecureConnectBundlePath) {
+		if isDefined(c.OriginTlsServerCaPath) || isDefined(c.OriginTlsClientCertPath) || isDefined(c.OriginTlsClientKeyPath) {
+			return &common.ClusterTlsConfig{}, fmt.Errorf("Incorrect TLS configuration for Origin: Secure Connect Bundle and custom TLS parameters cannot be specified at the same time.")
+		}
+
+		if displayLogMessages {
+			log.Infof("Mutual TLS configured for Origin using an Astra secure connect bundle")
+		}
+		return &common.ClusterTlsConfig{
+			TlsEnabled:              true,
+			SecureConnectBundlePath: c.OriginSecureConnectBundlePath,
+			MinVersion:              minVersion,
+			CipherSuites:            cipherSuites,
+		}, nil
+	}
+
+	// Custom TLS params specified
+
+	if isDefined(c.OriginTlsServerCaPath) && (isNotDefined(c.OriginTlsClientCertPath) && isNotDefined(c.OriginTlsClientKeyPath)) {
+		if displayLogMessages {
+			log.Infof("One-way TLS configured for Origin. Please note that hostname verification is not currently supported.")
+		}
+		return &common.ClusterTlsConfig{
+			TlsEnabled:   true,
+			ServerCaPath: c.OriginTlsServerCaPath,
+			CrlPath:      c.OriginTlsCrlPath,
+			MinVersion:   minVersion,
+			CipherSuites: cipherSuites,
+		}, nil
+	}
+
+	if isDefined(c.OriginTlsServerCaPath) && isDefined(c.OriginTlsClientCertPath) && isDefined(c.OriginTlsClientKeyPath) {
+		if displayLogMessages {
+			log.Infof("Mutual TLS configured for Origin. Please note that hostname verification is not currently supported.")
+		}
+		return &common.ClusterTlsConfig{
+			TlsEnabled:     true,
+			ServerCaPath:   c.OriginTlsServerCaPath,
+			ClientCertPath: c.OriginTlsClientCertPath,
+			ClientKeyPath:  c.OriginTlsClientKeyPath,
+			CrlPath:        c.OriginTlsCrlPath,
+			MinVersion:     minVersion,
+			CipherSuites:   cipherSuites,
+		}, nil
+	}
+
+	return &common.ClusterTlsConfig{}, fmt.Errorf("incomplete TLS configuration for Origin: when using mutual TLS, " +
+		"please specify Server CA path, Client Cert path and Client Key path")
+
+}
+
+func (c *Config) ParseTargetTlsConfig(displayLogMessages bool) (*common.ClusterTlsConfig, error) {
+
+	minVersion, err := parseTlsMinVersion(c.TargetTlsMinVersion, "ZDM_TARGET_TLS_MIN_VERSION")
+	if err != nil {
+		return &common.ClusterTlsConfig{}, err
+	}
+	cipherSuites, err := parseTlsCipherSuites(c.TargetTlsCipherSuites, "ZDM_TARGET_TLS_CIPHER_SUITES")
+	if err != nil {
+		return &common.ClusterTlsConfig{}, err
+	}
+	if isDefined(c.TargetTlsCrlPath) && isNotDefined(c.TargetTlsServerCaPath) {
+		return &common.ClusterTlsConfig{}, fmt.Errorf("incorrect TLS configuration for Target: " +
+			"ZDM_TARGET_TLS_CRL_PATH can only be used with ZDM_TARGET_TLS_SERVER_CA_PATH")
+	}
+
+	// No TLS defined
+
+	if isNotDefined(c.TargetSecureConnectBundlePath) &&
+		isNotDefined(c.TargetTlsServerCaPath) &&
+		isNotDefined(c.TargetTlsClientCertPath) &&
+		isNotDefined(c.TargetTlsClientKeyPath) {
+		if displayLogMessages {
+			log.Infof("TLS was not configured for Target")
+		}
+		return &common.ClusterTlsConfig{
+			TlsEnabled: false,
+		}, nil
+	}
+
+	//SCB specified
+
+	if isDefined(c.TargetSecureConnectBundlePath) {
+		if isDefined(c.TargetTlsServerCaPath) || isDefined(c.TargetTlsClientCertPath) || isDefined(c.TargetTlsClientKeyPath) {
+			return &common.ClusterTlsConfig{}, fmt.Errorf("Incorrect TLS configuration for Target: Secure Connect Bundle and custom TLS parameters cannot be specified at the same time.")
+		}
+
+		return &common.ClusterTlsConfig{
+			TlsEnabled:              true,
+			SecureConnectBundlePath: c.TargetSecureConnectBundlePath,
+			MinVersion:              minVersion,
+			CipherSuites:            cipherSuites,
+		}, nil
+	}
+
+	// Custom TLS params specified
+
+	if isDefined(c.TargetTlsServerCaPath) && (isNotDefined(c.TargetTlsClientCertPath) && isNotDefined(c.TargetTlsClientKeyPath)) {
+		if displayLogMessages {
+			log.Infof("One-way TLS configured for Target. Please note that hostname verification is not currently supported.")
+		}
+		return &common.ClusterTlsConfig{
+			TlsEnabled:   true,
+			ServerCaPath: c.TargetTlsServerCaPath,
+			CrlPath:      c.TargetTlsCrlPath,
+			MinVersion:   minVersion,
+			CipherSuites: cipherSuites,
+		}, nil
+	}
+
+	if isDefined(c.TargetTlsServerCaPath) && isDefined(c.TargetTlsClientCertPath) && isDefined(c.TargetTlsClientKeyPath) {
+		if displayLogMessages {
+			log.Infof("Mutual TLS configured for Target. Please note that hostname verification is not currently supported.")
+		}
+		return &common.ClusterTlsConfig{
+			TlsEnabled:     true,
+			ServerCaPath:   c.TargetTlsServerCaPath,
+			ClientCertPath: c.TargetTlsClientCertPath,
+			ClientKeyPath:  c.TargetTlsClientKeyPath,
+			CrlPath:        c.TargetTlsCrlPath,
+			MinVersion:     minVersion,
+			CipherSuites:   cipherSuites,
+		}, nil
+	}
+
+	return &common.ClusterTlsConfig{}, fmt.Errorf("incomplete TLS configuration for Target: when using mutual TLS, please specify Server CA path, Client Cert path and Client Key path")
+}
+
+func (c *Config) ParseProxyTlsConfig(displayLogMessages bool) (*common.ProxyTlsConfig, error) {
+
+	minVersion, err := parseTlsMinVersion(c.ProxyTlsMinVersion, "ZDM_PROXY_TLS_MIN_VERSION")
+	if err != nil {
+		return &common.ProxyTlsConfig{}, err
+	}
+	cipherSuites, err := parseTlsCipherSuites(c.ProxyTlsCipherSuites, "ZDM_PROXY_TLS_CIPHER_SUITES")
+	if err != nil {
+		return &common.ProxyTlsConfig{}, err
+	}
+
+	if isNotDefined(c.ProxyTlsCaPath) &&
+		isNotDefined(c.ProxyTlsCertPath) &&
+		isNotDefined(c.ProxyTlsKeyPath) {
+		if displayLogMessages {
+			log.Info("Proxy TLS was not configured.")
+		}
+		return &common.ProxyTlsConfig{
+			TlsEnabled: false,
+		}, nil
+	}
+
+	if isDefined(c.ProxyTlsCaPath) && isDefined(c.ProxyTlsCertPath) && isDefined(c.ProxyTlsKeyPath) {
+		if displayLogMessages {
+			log.Info("Proxy TLS configured. Please note that hostname verification is not currently supported.")
+		}
+		return &common.ProxyTlsConfig{
+			TlsEnabled:    true,
+			ProxyCaPath:   c.ProxyTlsCaPath,
+			ProxyCertPath: c.ProxyTlsCertPath,
+			ProxyKeyPath:  c.ProxyTlsKeyPath,
+			ClientAuth:    c.ProxyTlsRequireClientAuth,
+			MinVersion:    minVersion,
+			CipherSuites:  cipherSuites,
+		}, nil
+	}
+
+	return &common.ProxyTlsConfig{}, fmt.Errorf("incomplete Proxy TLS configuration: when enabling proxy TLS, please specify CA path, Cert path and Key path")
+}
+
+// ParseMetricsTlsConfig returns the TLS configuration of the http server of the metrics, health checks and admin API.
+func (c *Config) ParseMetricsTlsConfig() (*common.HttpTlsConfig, error) {
+	if isNotDefined(c.MetricsTlsCertPath) && isNotDefined(c.MetricsTlsKeyPath) && isNotDefined(c.MetricsTlsClientCaPath) {
+		return &common.HttpTlsConfig{TlsEnabled: false}, nil
+	}
+	if isNotDefined(c.MetricsTlsCertPath) || isNotDefined(c.MetricsTlsKeyPath) {
+		return &common.HttpTlsConfig{}, fmt.Errorf("incomplete TLS configuration of the metrics endpoint: please " +
+			"specify ZDM_METRICS_TLS_CERT_PATH and ZDM_METRICS_TLS_KEY_PATH")
+	}
+	return &common.HttpTlsConfig{
+		TlsEnabled:   true,
+		CertPath:     c.MetricsTlsCertPath,
+		KeyPath:      c.MetricsTlsKeyPath,
+		ClientCaPath: c.MetricsTlsClientCaPath,
+	}, nil
+}
+
+// ParseAdminApiAuthConfig returns the credentials of the roles of the admin API, the authentication is disabled if
+// none is set.
+func (c *Config) ParseAdminApiAuthConfig() (*common.AdminApiAuthConfig, error) {
+	authConfig := &common.AdminApiAuthConfig{
+		OperatorTokens:      parseAdminApiCredentials(c.AdminApiOperatorTokens),
+		ReadOnlyTokens:      parseAdminApiCredentials(c.AdminApiReadOnlyTokens),
+		OperatorCertNames:   parseAdminApiCredentials(c.AdminApiOperatorCertNames),
+		ReadOnlyCertNames:   parseAdminApiCredentials(c.AdminApiReadOnlyCertNames),
+		MetricsAuthRequired: c.MetricsAuthRequired,
+	}
+	for _, operatorToken := range authConfig.OperatorTokens {
+		for _, readOnlyToken := range authConfig.ReadOnlyTokens {
+			if operatorToken == readOnlyToken {
+				return nil, fmt.Errorf("invalid value for ZDM_ADMIN_API_READ_ONLY_TOKENS: " +
+					"a token can not be in ZDM_ADMIN_API_OPERATOR_TOKENS too")
+			}
+		}
+	}
+	if (len(authConfig.OperatorCertNames) > 0 || len(authConfig.ReadOnlyCertNames) > 0) &&
+		isNotDefined(c.MetricsTlsClientCaPath) {
+		return nil, fmt.Errorf("the client certificate names of the admin API roles require ZDM_METRICS_TLS_CLIENT_CA_PATH")
+	}
+	if authConfig.MetricsAuthRequired && !authConfig.Enabled() {
+		return nil, fmt.Errorf("ZDM_METRICS_AUTH_REQUIRED requires the tokens or the client certificate names " +
+			"of the admin API roles")
+	}
+	return authConfig, nil
+}
+
+func parseAdminApiCredentials(setting string) []string {
+	var credentials []string
+	for _, credential := range strings.Split(setting, ",") {
+		credential = strings.TrimSpace(credential)
+		if credential != "" {
+			credentials = append(credentials, credential)
+		}
+	}
+	return credentials
+}
+
+const (
+	TlsVersion10 = "TLS1.0"
+	TlsVersion11 = "TLS1.1"
+	TlsVersion12 = "TLS1.2"
+	TlsVersion13 = "TLS1.3"
+)
+
+// parseTlsMinVersion returns 0 if the value is empty, crypto/tls uses its own default minimum version in that case.
+func parseTlsMinVersion(value string, envVarName string) (uint16, error) {
+	switch strings.ToUpper(strings.TrimSpace(value)) {
+	case "":
+		return 0, nil
+	case TlsVersion10:
+		return tls.VersionTLS10, nil
+	case TlsVersion11:
+		return tls.VersionTLS11, nil
+	case TlsVersion12:
+		return tls.VersionTLS12, nil
+	case TlsVersion13:
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("invalid value for %v; possible values are: %v, %v, %v and %v",
+			envVarName, TlsVersion10, TlsVersion11, TlsVersion12, TlsVersion13)
+	}
+}
+
+// parseTlsCipherSuites parses a comma separated list of cipher suite names (e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256).
+// The cipher suites of TLS 1.3 are not configurable in crypto/tls so they are rejected instead of being ignored.
+func parseTlsCipherSuites(value string, envVarName string) ([]uint16, error) {
+	if isNotDefined(value) {
+		return nil, nil
+	}
+
+	supportedCipherSuites := make(map[string]*tls.CipherSuite)
+	for _, cipherSuite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		supportedCipherSuites[cipherSuite.Name] = cipherSuite
+	}
+
+	var cipherSuites []uint16
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		cipherSuite, ok := supportedCipherSuites[name]
+		if !ok {
+			return nil, fmt.Errorf("invalid value for %v; %v is not a supported cipher suite", envVarName, name)
+		}
+		if len(cipherSuite.SupportedVersions) == 1 && cipherSuite.SupportedVersions[0] == tls.VersionTLS13 {
+			return nil, fmt.Errorf("invalid value for %v; %v is a TLS 1.3 cipher suite and those can not be configured",
+				envVarName, name)
+		}
+		if cipherSuite.Insecure {
+			log.Warnf("%v contains the insecure cipher suite %v.", envVarName, name)
+		}
+		cipherSuites = append(cipherSuites, cipherSuite.ID)
+	}
+	return cipherSuites, nil
+}
+
+// checkRequiredCredentialEnvVars requires the username and password variables of a cluster unless an Astra token is
+// used instead. They can be empty (authentication disabled) but they have to be set, like envconfig's required keys.
+func (c *Config) checkRequiredCredentialEnvVars() error {
+	requiredEnvVars := []struct {
+		name       string
+		astraToken string
+	}{
+		{"ZDM_ORIGIN_USERNAME", c.OriginAstraToken},
+		{"ZDM_ORIGIN_PASSWORD", c.OriginAstraToken},
+		{"ZDM_TARGET_USERNAME", c.TargetAstraToken},
+		{"ZDM_TARGET_PASSWORD", c.TargetAstraToken},
+	}
+	for _, requiredEnvVar := range requiredEnvVars {
+		if isDefined(requiredEnvVar.astraToken) {
+			continue
+		}
+		if _, ok := os.LookupEnv(requiredEnvVar.name); !ok {
+			return fmt.Errorf("required key %v missing value", requiredEnvVar.name)
+		}
+	}
+	return nil
+}
+
+const (
+	astraTokenUsername = "token"
+	astraTokenPrefix   = "AstraCS:"
+)
+
+// ParseOriginCredentials returns the username and password that the proxy uses to authenticate with ORIGIN.
+func (c *Config) ParseOriginCredentials() (string, string, error) {
+	return parseClusterCredentials(c.OriginUsername, c.OriginPassword, c.OriginAstraToken, "ORIGIN")
+}
+
+// ParseTargetCredentials returns the username and password that the proxy uses to authenticate with TARGET.
+func (c *Config) ParseTargetCredentials() (string, string, error) {
+	return parseClusterCredentials(c.TargetUsername, c.TargetPassword, c.TargetAstraToken, "TARGET")
+}
+
+// parseClusterCredentials converts an Astra token to the plain-text credentials that Astra expects, i.e. the literal
+// "token" as the username and the token as the password.
+func parseClusterCredentials(username string, password string, astraToken string, clusterName string) (string, string, error) {
+	astraToken = strings.TrimSpace(astraToken)
+	if astraToken == "" {
+		return username, password, nil
+	}
+	if isDefined(username) || isDefined(password) {
+		return "", "", fmt.Errorf("invalid %v configuration: ZDM_%v_ASTRA_TOKEN can not be used with "+
+			"ZDM_%v_USERNAME or ZDM_%v_PASSWORD", strings.ToLower(clusterName), clusterName, clusterName, clusterName)
+	}
+	if !strings.HasPrefix(astraToken, astraTokenPrefix) {
+		return "", "", fmt.Errorf("invalid %v configuration: ZDM_%v_ASTRA_TOKEN must start with %v",
+			strings.ToLower(clusterName), clusterName, astraTokenPrefix)
+	}
+	return astraTokenUsername, astraToken, nil
+}
+
+// validateSigv4Config checks that TLS is enabled for every cluster that uses SigV4 authentication
+// because Amazon Keyspaces only accepts TLS connections.
+func (c *Config) validateSigv4Config() error {
+	if isDefined(c.OriginSigv4Region) {
+		originTlsConfig, err := c.ParseOriginTlsConfig(false)
+		if err != nil {
+			return err
+		}
+		if !originTlsConfig.TlsEnabled {
+			return fmt.Errorf("invalid origin configuration: ZDM_ORIGIN_SIGV4_REGION is set but TLS is not configured for Origin")
+		}
+	}
+
+	if isDefined(c.TargetSigv4Region) {
+		targetTlsConfig, err := c.ParseTargetTlsConfig(false)
+		if err != nil {
+			return err
+		}
+		if !targetTlsConfig.TlsEnabled {
+			return fmt.Errorf("invalid target configuration: ZDM_TARGET_SIGV4_REGION is set but TLS is not configured for Target")
+		}
+	}
+
+	return nil
+}
+
+// validateProxyClientAuthConfig checks that at most one proxy-level client authentication mechanism is configured, that
+// the JWT settings are only used together with ZDM_PROXY_CLIENT_JWT_JWKS_URL and that the role mapping (which applies
+// to forwarded client credentials) is not combined with proxy-level client authentication.
+func (c *Config) validateProxyClientAuthConfig() error {
+	if isDefined(c.RoleMappingFile) && (isDefined(c.ProxyClientCredentialsFile) || isDefined(c.ProxyClientJwtJwksUrl)) {
+		return fmt.Errorf("invalid proxy client authentication configuration: " +
+			"ZDM_ROLE_MAPPING_FILE can not be used with proxy-level client authentication")
+	}
+	if isNotDefined(c.ProxyClientJwtJwksUrl) {
+		if isDefined(c.ProxyClientJwtIssuer) || isDefined(c.ProxyClientJwtAudience) ||
+			isDefined(c.ProxyClientJwtCredentialsClaim) || isDefined(c.ProxyClientJwtCredentialsFile) {
+			return fmt.Errorf("invalid proxy client authentication configuration: " +
+				"the ZDM_PROXY_CLIENT_JWT_* settings can only be used with ZDM_PROXY_CLIENT_JWT_JWKS_URL")
+		}
+		return nil
+	}
+	if isDefined(c.ProxyClientCredentialsFile) {
+		return fmt.Errorf("invalid proxy client authentication configuration: " +
+			"ZDM_PROXY_CLIENT_CREDENTIALS_FILE and ZDM_PROXY_CLIENT_JWT_JWKS_URL can not be used together")
+	}
+	if isNotDefined(c.ProxyClientJwtIssuer) {
+		return fmt.Errorf("invalid proxy client authentication configuration: " +
+			"ZDM_PROXY_CLIENT_JWT_ISSUER is required when ZDM_PROXY_CLIENT_JWT_JWKS_URL is set")
+	}
+	if isDefined(c.ProxyClientJwtCredentialsClaim) != isDefined(c.ProxyClientJwtCredentialsFile) {
+		return fmt.Errorf("invalid proxy client authentication configuration: " +
+			"ZDM_PROXY_CLIENT_JWT_CREDENTIALS_CLAIM and ZDM_PROXY_CLIENT_JWT_CREDENTIALS_FILE must be set together")
+	}
+	return nil
+}
+
+// validateOriginAuthPassthroughConfig checks that the target credentials are configured when the tokens of the client
+// are forwarded to ORIGIN as is. The role mapping and the proxy-level client authentication need the client
+// credentials so they can't be combined with it.
+func (c *Config) validateOriginAuthPassthroughConfig() error {
+	if !c.OriginAuthPassthroughEnabled {
+		return nil
+	}
+	if isDefined(c.RoleMappingFile) || isDefined(c.ProxyClientCredentialsFile) || isDefined(c.ProxyClientJwtJwksUrl) {
+		return fmt.Errorf("invalid origin auth passthrough configuration: ZDM_ORIGIN_AUTH_PASSTHROUGH_ENABLED can not " +
+			"be used with ZDM_ROLE_MAPPING_FILE or proxy-level client authentication")
+	}
+	targetUsername, _, err := c.ParseTargetCredentials()
+	if err != nil {
+		return err
+	}
+	if isNotDefined(targetUsername) {
+		return fmt.Errorf("invalid origin auth passthrough configuration: the target credentials " +
+			"(ZDM_TARGET_USERNAME and ZDM_TARGET_PASSWORD or ZDM_TARGET_ASTRA_TOKEN) are required")
+	}
+	return nil
+}
+
+// ParseMutationExportKafkaBrokers returns the Kafka brokers that dual-written mutations are exported to or nil if the
+// mutation export is disabled.
+func (c *Config) ParseMutationExportKafkaBrokers() ([]string, error) {
+	if !isDefined(c.MutationExportKafkaBrokers) {
+		return nil, nil
+	}
+
+	brokers := parseKafkaBrokers(c.MutationExportKafkaBrokers)
+	if len(brokers) == 0 {
+		return nil, fmt.Errorf("invalid value for ZDM_MUTATION_EXPORT_KAFKA_BROKERS: %v", c.MutationExportKafkaBrokers)
+	}
+	if !isDefined(c.MutationExportKafkaTopic) {
+		return nil, fmt.Errorf("ZDM_MUTATION_EXPORT_KAFKA_BROKERS is set but ZDM_MUTATION_EXPORT_KAFKA_TOPIC is not")
+	}
+	if c.MutationExportQueueSize <= 0 {
+		return nil, fmt.Errorf("invalid value for ZDM_MUTATION_EXPORT_QUEUE_SIZE: %v, it must be positive", c.MutationExportQueueSize)
+	}
+	return brokers, nil
+}
+
+const (
+	ContinuousProfilingProfileTypeCpu       = "cpu"
+	ContinuousProfilingProfileTypeAlloc     = "alloc"
+	ContinuousProfilingProfileTypeGoroutine = "goroutine"
+)
+
+// ParseContinuousProfilingProfileTypes returns the profiles that are pushed to ZDM_CONTINUOUS_PROFILING_SERVER_URL or
+// nil if continuous profiling is disabled.
+func (c *Config) ParseContinuousProfilingProfileTypes() ([]string, error) {
+	if isNotDefined(c.ContinuousProfilingServerUrl) {
+		return nil, nil
+	}
+
+	serverUrl, err := url.Parse(c.ContinuousProfilingServerUrl)
+	if err != nil || (serverUrl.Scheme != "http" && serverUrl.Scheme != "https") || serverUrl.Host == "" {
+		return nil, fmt.Errorf("invalid value for ZDM_CONTINUOUS_PROFILING_SERVER_URL: %v, it must be an http or https url",
+			c.ContinuousProfilingServerUrl)
+	}
+	if isNotDefined(c.ContinuousProfilingApplicationName) {
+		return nil, fmt.Errorf("ZDM_CONTINUOUS_PROFILING_SERVER_URL is set but ZDM_CONTINUOUS_PROFILING_APPLICATION_NAME is empty")
+	}
+	if c.ContinuousProfilingIntervalMs < 1000 {
+		return nil, fmt.Errorf("invalid value for ZDM_CONTINUOUS_PROFILING_INTERVAL_MS: %v, it must be at least 1000",
+			c.ContinuousProfilingIntervalMs)
+	}
+
+	var profileTypes []string
+	for _, profileType := range strings.Split(c.ContinuousProfilingProfileTypes, ",") {
+		profileType = strings.ToLower(strings.TrimSpace(profileType))
+		switch profileType {
+		case "":
+			continue
+		case ContinuousProfilingProfileTypeCpu, ContinuousProfilingProfileTypeAlloc, ContinuousProfilingProfileTypeGoroutine:
+			profileTypes = append(profileTypes, profileType)
+		default:
+			return nil, fmt.Errorf("invalid value for ZDM_CONTINUOUS_PROFILING_PROFILE_TYPES: %v; possible values are: %v, %v and %v",
+				profileType, ContinuousProfilingProfileTypeCpu, ContinuousProfilingProfileTypeAlloc,
+				ContinuousProfilingProfileTypeGoroutine)
+		}
+	}
+	if len(profileTypes) == 0 {
+		return nil, fmt.Errorf("ZDM_CONTINUOUS_PROFILING_SERVER_URL is set but ZDM_CONTINUOUS_PROFILING_PROFILE_TYPES is empty")
+	}
+	return profileTypes, nil
+}
+
+// ParseErrorReportingDsn returns the Sentry DSN (https://<public key>@<host>/<project id>) that errors and panics are
+// reported to or nil if error reporting is disabled.
+func (c *Config) ParseErrorReportingDsn() (*url.URL, error) {
+	if isNotDefined(c.ErrorReportingDsn) {
+		return nil, nil
+	}
+
+	dsn, err := url.Parse(c.ErrorReportingDsn)
+	if err != nil || (dsn.Scheme != "http" && dsn.Scheme != "https") || dsn.Host == "" ||
+		dsn.User == nil || dsn.User.Username() == "" || strings.Trim(dsn.Path, "/") == "" {
+		return nil, fmt.Errorf("invalid value for ZDM_ERROR_REPORTING_DSN, "+
+			"it must be a url like https://<public key>@<host>/<project id>: %v", redactDsn(c.ErrorReportingDsn))
+	}
+	return dsn, nil
+}
+
+const (
+	LogOutputStderr = "STDERR"
+	LogOutputFile   = "FILE"
+	LogOutputSyslog = "SYSLOG"
+)
+
+var syslogFacilities = map[string]int{
+	"USER": 1, "DAEMON": 3, "LOCAL0": 16, "LOCAL1": 17, "LOCAL2": 18, "LOCAL3": 19,
+	"LOCAL4": 20, "LOCAL5": 21, "LOCAL6": 22, "LOCAL7": 23,
+}
+
+// ParseLogOutputConfig returns where the proxy writes its logs: the standard error (default), a file that is rotated
+// when it reaches ZDM_LOG_FILE_MAX_SIZE_MB or when it's older than ZDM_LOG_FILE_ROTATION_INTERVAL_HOURS, or a syslog
+// server (RFC5424).
+func (c *Config) ParseLogOutputConfig() (*common.LogOutputConfig, error) {
+	logOutputConfig := &common.LogOutputConfig{}
+	switch strings.ToUpper(strings.TrimSpace(c.LogOutput)) {
+	case "", LogOutputStderr:
+		logOutputConfig.Output = common.LogOutputStderr
+		return logOutputConfig, nil
+	case LogOutputFile:
+		logOutputConfig.Output = common.LogOutputFile
+	case LogOutputSyslog:
+		logOutputConfig.Output = common.LogOutputSyslog
+	default:
+		return nil, fmt.Errorf("invalid value for ZDM_LOG_OUTPUT; possible values are: %v, %v and %v",
+			LogOutputStderr, LogOutputFile, LogOutputSyslog)
+	}
+
+	if logOutputConfig.Output == common.LogOutputFile {
+		if isNotDefined(c.LogFilePath) {
+			return nil, fmt.Errorf("ZDM_LOG_FILE_PATH is required when ZDM_LOG_OUTPUT is %v", LogOutputFile)
+		}
+		if c.LogFileMaxSizeMb < 0 {
+			return nil, fmt.Errorf("invalid value for ZDM_LOG_FILE_MAX_SIZE_MB: %v, it must be positive or 0",
+				c.LogFileMaxSizeMb)
+		}
+		if c.LogFileRotationIntervalHours < 0 {
+			return nil, fmt.Errorf("invalid value for ZDM_LOG_FILE_ROTATION_INTERVAL_HOURS: %v, it must be positive or 0",
+				c.LogFileRotationIntervalHours)
+		}
+		if c.LogFileMaxBackups < 0 {
+			return nil, fmt.Errorf("invalid value for ZDM_LOG_FILE_MAX_BACKUPS: %v, it must be positive or 0",
+				c.LogFileMaxBackups)
+		}
+		logOutputConfig.FilePath = c.LogFilePath
+		logOutputConfig.FileMaxSizeBytes = int64(c.LogFileMaxSizeMb) * 1024 * 1024
+		logOutputConfig.FileRotationInterval = time.Duration(c.LogFileRotationIntervalHours) * time.Hour
+		logOutputConfig.FileMaxBackups = c.LogFileMaxBackups
+		return logOutputConfig, nil
+	}
+
+	address, err := url.Parse(strings.TrimSpace(c.LogSyslogAddress))
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for ZDM_LOG_SYSLOG_ADDRESS: %v, it must be a url like "+
+			"udp://<host>:<port>, tcp://<host>:<port> or unix://<socket path>", c.LogSyslogAddress)
+	}
+	switch address.Scheme {
+	case "udp", "tcp":
+		if address.Host == "" || address.Port() == "" {
+			err = fmt.Errorf("%v is missing the port", address.Scheme)
+		}
+		logOutputConfig.SyslogAddress = address.Host
+	case "unix":
+		if address.Path == "" {
+			err = fmt.Errorf("unix is missing the socket path")
+		}
+		logOutputConfig.SyslogAddress = address.Path
+	default:
+		err = fmt.Errorf("unsupported scheme %v", address.Scheme)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for ZDM_LOG_SYSLOG_ADDRESS: %v, it must be a url like "+
+			"udp://<host>:<port>, tcp://<host>:<port> or unix://<socket path> (%v)", c.LogSyslogAddress, err)
+	}
+	logOutputConfig.SyslogNetwork = address.Scheme
+
+	facility, ok := syslogFacilities[strings.ToUpper(strings.TrimSpace(c.LogSyslogFacility))]
+	if !ok {
+		return nil, fmt.Errorf("invalid value for ZDM_LOG_SYSLOG_FACILITY: %v; possible values are: USER, DAEMON "+
+			"and LOCAL0 to LOCAL7", c.LogSyslogFacility)
+	}
+	logOutputConfig.SyslogFacility = facility
+	logOutputConfig.SyslogAppName = strings.TrimSpace(c.LogSyslogAppName)
+	if logOutputConfig.SyslogAppName == "" {
+		logOutputConfig.SyslogAppName = "-"
+	}
+	return logOutputConfig, nil
+}
+
+// redactDsn removes the keys of the DSN so that they are not logged.
+func redactDsn(dsn string) string {
+	if i := strings.LastIndex(dsn, "@"); i >= 0 {
+		if j := strings.Index(dsn, "://"); j >= 0 && j < i {
+			return dsn[:j+3] + "<redacted>" + dsn[i:]
+		}
+		return "<redacted>" + dsn[i:]
+	}
+	return dsn
+}
+
+// ParseStartupStrippedOptions returns the (upper case) names of the STARTUP options that the proxy removes before
+// forwarding the STARTUP request to the clusters. A name that ends with * matches every option with that prefix.
+func (c *Config) ParseStartupStrippedOptions() ([]string, error) {
+	var options []string
+	for _, option := range strings.Split(c.StartupStrippedOptions, ",") {
+		option = strings.ToUpper(strings.TrimSpace(option))
+		if option == "" {
+			continue
+		}
+		if option == "CQL_VERSION" ||
+			(strings.HasSuffix(option, "*") && strings.HasPrefix("CQL_VERSION", strings.TrimSuffix(option, "*"))) {
+			return nil, fmt.Errorf("invalid value for ZDM_STARTUP_STRIPPED_OPTIONS: %v, CQL_VERSION is a mandatory STARTUP option", c.StartupStrippedOptions)
+		}
+		options = append(options, option)
+	}
+	return options, nil
+}
+
+// ParseMaxClientProtocolVersion returns the highest protocol version that clients can use, e.g. to keep using v4 with
+// clusters that support DSE_V2. It returns 0 if the protocol version is not pinned. The DSE versions are considered
+// higher than v4 like the drivers do when they downgrade.
+func (c *Config) ParseMaxClientProtocolVersion() (primitive.ProtocolVersion, error) {
+	switch strings.ToUpper(strings.TrimSpace(c.MaxClientProtocolVersion)) {
+	case "":
+		return 0, nil
+	case "2", "V2":
+		return primitive.ProtocolVersion2, nil
+	case "3", "V3":
+		return primitive.ProtocolVersion3, nil
+	case "4", "V4":
+		return primitive.ProtocolVersion4, nil
+	case "DSE_V1":
+		return primitive.ProtocolVersionDse1, nil
+	case "DSE_V2":
+		return primitive.ProtocolVersionDse2, nil
+	default:
+		return 0, fmt.Errorf(
+			"invalid value for ZDM_MAX_CLIENT_PROTOCOL_VERSION; possible values are: 2, 3, 4, DSE_V1 and DSE_V2")
+	}
+}
+
+const (
+	ScrubbedErrorDetailNodeAddresses = "NODE_ADDRESSES"
+	ScrubbedErrorDetailClusterNames  = "CLUSTER_NAMES"
+)
+
+// ParseErrorMessageScrubbing returns the backend details that are removed from the error messages that the proxy
+// sends to clients.
+func (c *Config) ParseErrorMessageScrubbing() ([]common.ScrubbedErrorDetail, error) {
+	var details []common.ScrubbedErrorDetail
+	for _, detail := range strings.Split(c.ErrorMessageScrubbing, ",") {
+		detail = strings.ToUpper(strings.TrimSpace(detail))
+		switch detail {
+		case "":
+		case ScrubbedErrorDetailNodeAddresses:
+			details = append(details, common.ScrubbedErrorDetailNodeAddresses)
+		case ScrubbedErrorDetailClusterNames:
+			details = append(details, common.ScrubbedErrorDetailClusterNames)
+		default:
+			return nil, fmt.Errorf(
+				"invalid value for ZDM_ERROR_MESSAGE_SCRUBBING: %v; possible values are: %v and %v",
+				detail, ScrubbedErrorDetailNodeAddresses, ScrubbedErrorDetailClusterNames)
+		}
+	}
+	return details, nil
+}
+
+// ParseProxyClientAllowedCidrs returns the networks that clients are allowed to connect from, an empty list means
+// that every address is allowed unless it is denied by ZDM_PROXY_CLIENT_DENIED_CIDRS.
+func (c *Config) ParseProxyClientAllowedCidrs() ([]*net.IPNet, error) {
+	return parseCidrs(c.ProxyClientAllowedCidrs, "ZDM_PROXY_CLIENT_ALLOWED_CIDRS")
+}
+
+// ParseProxyClientDeniedCidrs returns the networks that clients are not allowed to connect from.
+func (c *Config) ParseProxyClientDeniedCidrs() ([]*net.IPNet, error) {
+	return parseCidrs(c.ProxyClientDeniedCidrs, "ZDM_PROXY_CLIENT_DENIED_CIDRS")
+}
+
+// parseCidrs parses a comma separated list of CIDR blocks, a single IP address is accepted as a block that only
+// contains that address.
+func parseCidrs(value string, envVarName string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, cidr := range strings.Split(value, ",") {
+		cidr = strings.TrimSpace(cidr)
+		if cidr == "" {
+			continue
+		}
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid value for %v: %v is not a CIDR block or an IP address", envVarName, cidr)
+			}
+			if ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %v: %v is not a CIDR block or an IP address", envVarName, cidr)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func isDefined(propertyValue string) bool {
+	return propertyValue != ""
+}
+
+func isNotDefined(propertyValue string) bool {
+	return !isDefined(propertyValue)
+}
+
+// ParseMemorySoftLimitBytes returns the heap size above which the proxy starts rejecting new connections and requests,
+// 0 means that load shedding based on memory usage is disabled.
+func (c *Config) ParseMemorySoftLimitBytes() (uint64, error) {
+	if c.ProxyMemorySoftLimitMb < 0 {
+		return 0, fmt.Errorf("invalid value for ZDM_PROXY_MEMORY_SOFT_LIMIT_MB: %v, it must not be negative", c.ProxyMemorySoftLimitMb)
+	}
+	if c.ProxyMemorySoftLimitMb > 0 && c.ProxyMemoryCheckIntervalMs <= 0 {
+		return 0, fmt.Errorf("invalid value for ZDM_PROXY_MEMORY_CHECK_INTERVAL_MS: %v, it must be positive", c.ProxyMemoryCheckIntervalMs)
+	}
+	return uint64(c.ProxyMemorySoftLimitMb) * 1024 * 1024, nil
+}
+
+// ParseGoroutineAuditConfig returns how the goroutines of the client handlers are audited, an interval of 0 disables
+// the audit.
+func (c *Config) ParseGoroutineAuditConfig() (*common.GoroutineAuditConfig, error) {
+	if c.ProxyGoroutineAuditIntervalMs < 0 {
+		return nil, fmt.Errorf("invalid value for ZDM_PROXY_GOROUTINE_AUDIT_INTERVAL_MS: %v, it must not be negative",
+			c.ProxyGoroutineAuditIntervalMs)
+	}
+	if c.ProxyGoroutineLeakGracePeriodMs < 0 {
+		return nil, fmt.Errorf("invalid value for ZDM_PROXY_GOROUTINE_LEAK_GRACE_PERIOD_MS: %v, it must not be negative",
+			c.ProxyGoroutineLeakGracePeriodMs)
+	}
+	return &common.GoroutineAuditConfig{
+		Interval:        time.Duration(c.ProxyGoroutineAuditIntervalMs) * time.Millisecond,
+		LeakGracePeriod: time.Duration(c.ProxyGoroutineLeakGracePeriodMs) * time.Millisecond,
+	}, nil
+}
+
+const (
+	QueueOverflowPolicyBlock       = "BLOCK"
+	QueueOverflowPolicyShed        = "SHED"
+	QueueOverflowPolicyPreferReads = "PREFER_READS"
+)
+
+// ParseRequestWriteQueueOverflowPolicy returns what the proxy does with a request when the write queue of a cluster
+// connection that it should be sent to is full. BLOCK waits until there is room in the queue, SHED fails the request
+// with OVERLOADED and PREFER_READS only fails the requests that are sent to both clusters (reads keep waiting).
+func (c *Config) ParseRequestWriteQueueOverflowPolicy() (common.QueueOverflowPolicy, error) {
+	switch strings.ToUpper(strings.TrimSpace(c.RequestWriteQueueOverflowPolicy)) {
+	case "", QueueOverflowPolicyBlock:
+		return common.QueueOverflowPolicyBlock, nil
+	case QueueOverflowPolicyShed:
+		return common.QueueOverflowPolicyShed, nil
+	case QueueOverflowPolicyPreferReads:
+		return common.QueueOverflowPolicyPreferReads, nil
+	default:
+		return common.QueueOverflowPolicyUndefined, fmt.Errorf(
+			"invalid value for ZDM_REQUEST_WRITE_QUEUE_OVERFLOW_POLICY; possible values are: %v, %v and %v",
+			QueueOverflowPolicyBlock, QueueOverflowPolicyShed, QueueOverflowPolicyPreferReads)
+	}
+}
+
+// ParseProxyRequestRateLimit returns the maximum number of requests per second that this proxy instance forwards,
+// 0 means that requests are not rate limited.
+func (c *Config) ParseProxyRequestRateLimit() (int, error) {
+	if c.ProxyRequestRateLimit < 0 {
+		return 0, fmt.Errorf("invalid value for ZDM_PROXY_REQUEST_RATE_LIMIT: %v, it must not be negative", c.ProxyRequestRateLimit)
+	}
+	return c.ProxyRequestRateLimit, nil
+}
+
+// ParseProxyRequestDeadline returns the total time that the proxy spends on a request, from the moment it's read from
+// the client connection, across the retries, the hedged reads and the wait for the responses of both clusters. 0 means
+// that there is no total deadline, each request sent to the clusters has ZDM_PROXY_REQUEST_TIMEOUT_MS.
+func (c *Config) ParseProxyRequestDeadline() (time.Duration, error) {
+	if c.ProxyRequestDeadlineMs < 0 {
+		return 0, fmt.Errorf("invalid value for ZDM_PROXY_REQUEST_DEADLINE_MS: %v, it must not be negative",
+			c.ProxyRequestDeadlineMs)
+	}
+	return time.Duration(c.ProxyRequestDeadlineMs) * time.Millisecond, nil
+}
+
+// ParseProxyGlobalRequestRateLimit returns the maximum number of requests per second that the whole proxy fleet
+// forwards, 0 means that there is no global limit. The token bucket of the limit is shared through the key-value store
+// of the shared configuration so the limit doesn't grow with the number of instances. Each instance holds at least one
+// token of the bucket, so a limit lower than the number of instances allows fewer requests to the busy instances.
+func (c *Config) ParseProxyGlobalRequestRateLimit() (int, error) {
+	if c.ProxyGlobalRequestRateLimit < 0 {
+		return 0, fmt.Errorf("invalid value for ZDM_PROXY_GLOBAL_REQUEST_RATE_LIMIT: %v, it must not be negative",
+			c.ProxyGlobalRequestRateLimit)
+	}
+	if c.ProxyGlobalRequestRateLimit == 0 {
+		return 0, nil
+	}
+	backend, err := c.ParseSharedConfigBackend()
+	if err != nil {
+		return 0, err
+	}
+	if backend == common.SharedConfigBackendNone {
+		return 0, fmt.Errorf("ZDM_PROXY_GLOBAL_REQUEST_RATE_LIMIT is %v but ZDM_SHARED_CONFIG_BACKEND is %v, "+
+			"the global limit requires a shared configuration backend", c.ProxyGlobalRequestRateLimit, backend)
+	}
+	return c.ProxyGlobalRequestRateLimit, nil
+}
 
-		return contactPoints, nil
+// ParseProxyGlobalRequestRateLimitKey returns the key that holds the token bucket of the global request rate limit. It
+// must not be under ZDM_SHARED_CONFIG_PREFIX, otherwise every update of the bucket would wake up the watchers of the
+// shared settings.
+func (c *Config) ParseProxyGlobalRequestRateLimitKey() (string, error) {
+	key := strings.TrimSpace(c.ProxyGlobalRequestRateLimitKey)
+	if key == "" {
+		return "", fmt.Errorf("invalid value for ZDM_PROXY_GLOBAL_REQUEST_RATE_LIMIT_KEY: it must not be empty")
 	}
+	if strings.HasPrefix(key, c.SharedConfigPrefix) {
+		return "", fmt.Errorf("invalid value for ZDM_PROXY_GLOBAL_REQUEST_RATE_LIMIT_KEY: %v, "+
+			"it must not be under ZDM_SHARED_CONFIG_PREFIX (%v)", key, c.SharedConfigPrefix)
+	}
+	return key, nil
+}
 
-	return nil, nil
+const (
+	SharedConfigBackendNone   = "NONE"
+	SharedConfigBackendEtcd   = "ETCD"
+	SharedConfigBackendConsul = "CONSUL"
+)
+
+// ParseMigrationStatusTable returns the keyspace qualified name of the table of Target that tracks the progress of the
+// historical data migration, or an empty string if the routing of reads doesn't depend on it.
+func (c *Config) ParseMigrationStatusTable() (string, error) {
+	table := strings.TrimSpace(c.MigrationStatusTable)
+	if table == "" {
+		return "", nil
+	}
+	parts := strings.Split(table, ".")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("invalid value for ZDM_MIGRATION_STATUS_TABLE: %v, it must be a keyspace qualified "+
+			"table name (keyspace.table)", c.MigrationStatusTable)
+	}
+	if c.MigrationStatusRefreshIntervalMs <= 0 {
+		return "", fmt.Errorf("invalid value for ZDM_MIGRATION_STATUS_REFRESH_INTERVAL_MS: %v, it must be positive",
+			c.MigrationStatusRefreshIntervalMs)
+	}
+	return table, nil
 }
 
-func parseContactPoints(setting string) []string {
-	return strings.Split(strings.ReplaceAll(setting, " ", ""), ",")
+// ParseAutoCutoverConfig returns the thresholds of the automatic cutover of reads to Target or nil if
+// ZDM_AUTO_CUTOVER_ENABLED is false.
+func (c *Config) ParseAutoCutoverConfig() (*common.AutoCutoverConfig, error) {
+	if !c.AutoCutoverEnabled {
+		return nil, nil
+	}
+	if c.AutoCutoverMaxMismatchRate < 0 || c.AutoCutoverMaxMismatchRate > 1 {
+		return nil, fmt.Errorf("invalid value for ZDM_AUTO_CUTOVER_MAX_MISMATCH_RATE: %v, it must be between 0 and 1",
+			c.AutoCutoverMaxMismatchRate)
+	}
+	if c.AutoCutoverMinComparedReads <= 0 {
+		return nil, fmt.Errorf("invalid value for ZDM_AUTO_CUTOVER_MIN_COMPARED_READS: %v, it must be positive",
+			c.AutoCutoverMinComparedReads)
+	}
+	if c.AutoCutoverStableDurationMs <= 0 {
+		return nil, fmt.Errorf("invalid value for ZDM_AUTO_CUTOVER_STABLE_DURATION_MS: %v, it must be positive",
+			c.AutoCutoverStableDurationMs)
+	}
+	if c.AutoCutoverCheckIntervalMs <= 0 {
+		return nil, fmt.Errorf("invalid value for ZDM_AUTO_CUTOVER_CHECK_INTERVAL_MS: %v, it must be positive",
+			c.AutoCutoverCheckIntervalMs)
+	}
+	return &common.AutoCutoverConfig{
+		MaxMismatchRate:  c.AutoCutoverMaxMismatchRate,
+		MinComparedReads: c.AutoCutoverMinComparedReads,
+		StableDuration:   time.Duration(c.AutoCutoverStableDurationMs) * time.Millisecond,
+		CheckInterval:    time.Duration(c.AutoCutoverCheckIntervalMs) * time.Millisecond,
+	}, nil
 }
 
-func (c *Config) ParseOriginTlsConfig(displayLogMessages bool) (*common.ClusterTlsConfig, error) {
+// ParseSchemaCheckKeyspaces returns the keyspaces whose tables and UDTs are compared between Origin and Target by the
+// schema check, nil means all the non system keyspaces of Origin.
+func (c *Config) ParseSchemaCheckKeyspaces() ([]string, error) {
+	if !isDefined(c.SchemaCheckKeyspaces) {
+		return nil, nil
+	}
+	var keyspaces []string
+	for _, keyspace := range strings.Split(c.SchemaCheckKeyspaces, ",") {
+		keyspace = strings.TrimSpace(keyspace)
+		if keyspace == "" || strings.Contains(keyspace, ".") {
+			return nil, fmt.Errorf("invalid value for ZDM_SCHEMA_CHECK_KEYSPACES: %v, expected a comma separated "+
+				"list of keyspace names", c.SchemaCheckKeyspaces)
+		}
+		keyspaces = append(keyspaces, keyspace)
+	}
+	return keyspaces, nil
+}
 
-	// No TLS defined
+// ParseViewIndexCheckRefreshInterval returns how often the materialized views and secondary indexes of both clusters
+// are compared or 0 if ZDM_VIEW_INDEX_CHECK_ENABLED is false.
+func (c *Config) ParseViewIndexCheckRefreshInterval() (time.Duration, error) {
+	if !c.ViewIndexCheckEnabled {
+		return 0, nil
+	}
+	if c.ViewIndexCheckRefreshIntervalMs <= 0 {
+		return 0, fmt.Errorf("invalid value for ZDM_VIEW_INDEX_CHECK_REFRESH_INTERVAL_MS: %v, it must be positive",
+			c.ViewIndexCheckRefreshIntervalMs)
+	}
+	return time.Duration(c.ViewIndexCheckRefreshIntervalMs) * time.Millisecond, nil
+}
 
-	if isNotDefined(c.OriginSecureConnectBundlePath) &&
-		isNotDefined(c.OriginTlsServerCaPath) &&
-		isNotDefined(c.OriginTlsClientCertPath) &&
-		isNotDefined(c.OriginTlsClientKeyPath) {
-		if displayLogMessages {
-			log.Infof("TLS was not configured for Origin")
-		}
-		return &common.ClusterTlsConfig{
-			TlsEnabled: false,
-		}, nil
+// ParseClockSkewCheckConfig returns how the clocks of the clusters are compared, the interval is 0 if
+// ZDM_CLOCK_SKEW_CHECK_ENABLED is false.
+func (c *Config) ParseClockSkewCheckConfig() (*common.ClockSkewCheckConfig, error) {
+	if !c.ClockSkewCheckEnabled {
+		return &common.ClockSkewCheckConfig{}, nil
+	}
+	if c.ClockSkewCheckIntervalMs <= 0 {
+		return nil, fmt.Errorf("invalid value for ZDM_CLOCK_SKEW_CHECK_INTERVAL_MS: %v, it must be positive",
+			c.ClockSkewCheckIntervalMs)
+	}
+	if c.ClockSkewThresholdMs <= 0 {
+		return nil, fmt.Errorf("invalid value for ZDM_CLOCK_SKEW_THRESHOLD_MS: %v, it must be positive",
+			c.ClockSkewThresholdMs)
 	}
+	return &common.ClockSkewCheckConfig{
+		Interval:  time.Duration(c.ClockSkewCheckIntervalMs) * time.Millisecond,
+		Threshold: time.Duration(c.ClockSkewThresholdMs) * time.Millisecond,
+	}, nil
+}
 
-	//SCB specified
+// ParseTargetWriteSamplingPercent returns the percentage of the writes that are also sent to Target, 100 if
+// ZDM_TARGET_WRITE_SAMPLING_ENABLED is false.
+func (c *Config) ParseTargetWriteSamplingPercent() (float64, error) {
+	if !c.TargetWriteSamplingEnabled {
+		return 100, nil
+	}
+	if c.TargetWriteSamplingPercent < 0 || c.TargetWriteSamplingPercent > 100 {
+		return 0, fmt.Errorf("invalid value for ZDM_TARGET_WRITE_SAMPLING_PERCENT: %v, it must be between 0 and 100",
+			c.TargetWriteSamplingPercent)
+	}
+	return c.TargetWriteSamplingPercent, nil
+}
 
-	if isDefined(c.OriginSecureConnectBundlePath) {
-		if isDefined(c.OriginTlsServerCaPath) || isDefined(c.OriginTlsClientCertPath) || isDefined(c.OriginTlsClientKeyPath) {
-			return &common.ClusterTlsConfig{}, fmt.Errorf("Incorrect TLS configuration for Origin: Secure Connect Bundle and custom TLS parameters cannot be specified at the same time.")
-		}
+// ParseFrameCaptureSize returns the number of recent frames that are kept for each client connection, 0 if the frame
+// capture is disabled.
+func (c *Config) ParseFrameCaptureSize() (int, error) {
+	if c.FrameCaptureSize < 0 {
+		return 0, fmt.Errorf("invalid value for ZDM_FRAME_CAPTURE_SIZE: %v, it must not be negative", c.FrameCaptureSize)
+	}
+	return c.FrameCaptureSize, nil
+}
 
-		if displayLogMessages {
-			log.Infof("Mutual TLS configured for Origin using an Astra secure connect bundle")
+// ParseHeavyHittersSize returns the number of statements that are reported by the heavy hitter tracking of each
+// cluster, 0 if the tracking is disabled.
+func (c *Config) ParseHeavyHittersSize() (int, error) {
+	if c.HeavyHittersSize < 0 {
+		return 0, fmt.Errorf("invalid value for ZDM_HEAVY_HITTERS_SIZE: %v, it must not be negative", c.HeavyHittersSize)
+	}
+	return c.HeavyHittersSize, nil
+}
+
+// ParseStatementMetricsMaxFingerprints returns the maximum number of statement fingerprints that have their own
+// metrics, 0 if the statement metrics are disabled.
+func (c *Config) ParseStatementMetricsMaxFingerprints() (int, error) {
+	if c.StatementMetricsMaxFingerprints < 0 {
+		return 0, fmt.Errorf("invalid value for ZDM_STATEMENT_METRICS_MAX_FINGERPRINTS: %v, it must not be negative",
+			c.StatementMetricsMaxFingerprints)
+	}
+	return c.StatementMetricsMaxFingerprints, nil
+}
+
+// ParsePayloadSizeMetricsBuckets returns the buckets in bytes of the request and response size histograms, nil if
+// the payload size metrics are disabled.
+func (c *Config) ParsePayloadSizeMetricsBuckets() ([]float64, error) {
+	if !c.PayloadSizeMetricsEnabled {
+		return nil, nil
+	}
+	buckets, err := parseBucketValues(c.PayloadSizeMetricsBucketsBytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for ZDM_PAYLOAD_SIZE_METRICS_BUCKETS_BYTES: %w", err)
+	}
+	for i, bucket := range buckets {
+		if bucket <= 0 || (i > 0 && bucket <= buckets[i-1]) {
+			return nil, fmt.Errorf("invalid value for ZDM_PAYLOAD_SIZE_METRICS_BUCKETS_BYTES: %v, "+
+				"the buckets must be positive and in increasing order", c.PayloadSizeMetricsBucketsBytes)
 		}
-		return &common.ClusterTlsConfig{
-			TlsEnabled:              true,
-			SecureConnectBundlePath: c.OriginSecureConnectBundlePath,
-		}, nil
 	}
+	return buckets, nil
+}
 
-	// Custom TLS params specified
+const (
+	StartupPolicyDelayListener     = "DELAY_LISTENER"
+	StartupPolicyRefuseConnections = "REFUSE_CONNECTIONS"
+)
 
-	if isDefined(c.OriginTlsServerCaPath) && (isNotDefined(c.OriginTlsClientCertPath) && isNotDefined(c.OriginTlsClientKeyPath)) {
-		if displayLogMessages {
-			log.Infof("One-way TLS configured for Origin. Please note that hostname verification is not currently supported.")
+// ParseStartupPolicy returns what the proxy does with the client listener until the control connections to both
+// clusters are established and authenticated: DELAY_LISTENER doesn't bind it and REFUSE_CONNECTIONS binds it right
+// away but closes the client connections.
+func (c *Config) ParseStartupPolicy() (common.StartupPolicy, error) {
+	switch strings.ToUpper(strings.TrimSpace(c.StartupPolicy)) {
+	case "", StartupPolicyDelayListener:
+		return common.StartupPolicyDelayListener, nil
+	case StartupPolicyRefuseConnections:
+		return common.StartupPolicyRefuseConnections, nil
+	default:
+		return common.StartupPolicyUndefined, fmt.Errorf(
+			"invalid value for ZDM_STARTUP_POLICY; possible values are: %v and %v",
+			StartupPolicyDelayListener, StartupPolicyRefuseConnections)
+	}
+}
+
+const (
+	TargetDownPolicyFail       = "FAIL"
+	TargetDownPolicyOriginOnly = "ORIGIN_ONLY"
+)
+
+// ParseTargetDownPolicy returns what the proxy does while Target is DOWN: FAIL forwards the requests to both clusters
+// as usual (so the writes fail) and ORIGIN_ONLY serves the clients with Origin only and journals the writes meant for
+// Target to ZDM_ORIGIN_ONLY_JOURNAL_FILE.
+func (c *Config) ParseTargetDownPolicy() (common.TargetDownPolicy, error) {
+	switch strings.ToUpper(strings.TrimSpace(c.TargetDownPolicy)) {
+	case "", TargetDownPolicyFail:
+		return common.TargetDownPolicyFail, nil
+	case TargetDownPolicyOriginOnly:
+		if !isDefined(c.OriginOnlyJournalFile) {
+			return common.TargetDownPolicyUndefined, fmt.Errorf(
+				"ZDM_ORIGIN_ONLY_JOURNAL_FILE must be set when ZDM_TARGET_DOWN_POLICY is %v", TargetDownPolicyOriginOnly)
 		}
-		return &common.ClusterTlsConfig{
-			TlsEnabled:   true,
-			ServerCaPath: c.OriginTlsServerCaPath,
-		}, nil
+		return common.TargetDownPolicyOriginOnly, nil
+	default:
+		return common.TargetDownPolicyUndefined, fmt.Errorf(
+			"invalid value for ZDM_TARGET_DOWN_POLICY; possible values are: %v and %v",
+			TargetDownPolicyFail, TargetDownPolicyOriginOnly)
 	}
+}
 
-	if isDefined(c.OriginTlsServerCaPath) && isDefined(c.OriginTlsClientCertPath) && isDefined(c.OriginTlsClientKeyPath) {
-		if displayLogMessages {
-			log.Infof("Mutual TLS configured for Origin. Please note that hostname verification is not currently supported.")
+// ParseOriginOnlyJournalRetention returns the limits of the size and of the age of the entries of
+// ZDM_ORIGIN_ONLY_JOURNAL_FILE and the interval at which the file is compacted to enforce them.
+func (c *Config) ParseOriginOnlyJournalRetention() (*common.JournalRetention, error) {
+	if c.OriginOnlyJournalMaxSizeMb < 0 {
+		return nil, fmt.Errorf("invalid value for ZDM_ORIGIN_ONLY_JOURNAL_MAX_SIZE_MB: %v, it must not be negative",
+			c.OriginOnlyJournalMaxSizeMb)
+	}
+	if c.OriginOnlyJournalMaxAgeHours < 0 {
+		return nil, fmt.Errorf("invalid value for ZDM_ORIGIN_ONLY_JOURNAL_MAX_AGE_HOURS: %v, it must not be negative",
+			c.OriginOnlyJournalMaxAgeHours)
+	}
+	retention := &common.JournalRetention{
+		MaxSizeBytes:       int64(c.OriginOnlyJournalMaxSizeMb) * 1024 * 1024,
+		MaxAge:             time.Duration(c.OriginOnlyJournalMaxAgeHours) * time.Hour,
+		CompactionInterval: time.Duration(c.OriginOnlyJournalCompactionIntervalMs) * time.Millisecond,
+	}
+	if retention.Enabled() && c.OriginOnlyJournalCompactionIntervalMs <= 0 {
+		return nil, fmt.Errorf("invalid value for ZDM_ORIGIN_ONLY_JOURNAL_COMPACTION_INTERVAL_MS: %v, it must be positive",
+			c.OriginOnlyJournalCompactionIntervalMs)
+	}
+	return retention, nil
+}
+
+func parseKafkaBrokers(brokersStr string) []string {
+	var brokers []string
+	for _, broker := range strings.Split(brokersStr, ",") {
+		broker = strings.TrimSpace(broker)
+		if broker != "" {
+			brokers = append(brokers, broker)
 		}
-		return &common.ClusterTlsConfig{
-			TlsEnabled:     true,
-			ServerCaPath:   c.OriginTlsServerCaPath,
-			ClientCertPath: c.OriginTlsClientCertPath,
-			ClientKeyPath:  c.OriginTlsClientKeyPath,
-		}, nil
 	}
+	return brokers
+}
 
-	return &common.ClusterTlsConfig{}, fmt.Errorf("incomplete TLS configuration for Origin: when using mutual TLS, " +
-		"please specify Server CA path, Client Cert path and Client Key path")
+const (
+	TargetWritePipelineDirect = "DIRECT"
+	TargetWritePipelineKafka  = "KAFKA"
+)
+
+// ParseTargetWritePipeline returns how the writes reach Target: DIRECT sends them to both clusters and KAFKA only sends
+// them to Origin and writes them synchronously to ZDM_TARGET_WRITE_PIPELINE_KAFKA_TOPIC, from which they are applied to
+// Target by the proxy instances of ZDM_TARGET_WRITE_PIPELINE_KAFKA_CONSUMER_GROUP.
+func (c *Config) ParseTargetWritePipeline() (common.TargetWritePipeline, error) {
+	switch strings.ToUpper(strings.TrimSpace(c.TargetWritePipeline)) {
+	case "", TargetWritePipelineDirect:
+		return common.TargetWritePipelineDirect, nil
+	case TargetWritePipelineKafka:
+	default:
+		return common.TargetWritePipelineUndefined, fmt.Errorf(
+			"invalid value for ZDM_TARGET_WRITE_PIPELINE; possible values are: %v and %v",
+			TargetWritePipelineDirect, TargetWritePipelineKafka)
+	}
 
+	if len(parseKafkaBrokers(c.TargetWritePipelineKafkaBrokers)) == 0 {
+		return common.TargetWritePipelineUndefined, fmt.Errorf(
+			"ZDM_TARGET_WRITE_PIPELINE_KAFKA_BROKERS must be set when ZDM_TARGET_WRITE_PIPELINE is %v", TargetWritePipelineKafka)
+	}
+	if !isDefined(c.TargetWritePipelineKafkaTopic) {
+		return common.TargetWritePipelineUndefined, fmt.Errorf(
+			"ZDM_TARGET_WRITE_PIPELINE_KAFKA_TOPIC must be set when ZDM_TARGET_WRITE_PIPELINE is %v", TargetWritePipelineKafka)
+	}
+	if !isDefined(c.TargetWritePipelineKafkaConsumerGroup) {
+		return common.TargetWritePipelineUndefined, fmt.Errorf(
+			"ZDM_TARGET_WRITE_PIPELINE_KAFKA_CONSUMER_GROUP must be set when ZDM_TARGET_WRITE_PIPELINE is %v",
+			TargetWritePipelineKafka)
+	}
+	if c.TargetWritePipelineDedupWindowSize < 0 {
+		return common.TargetWritePipelineUndefined, fmt.Errorf(
+			"invalid value for ZDM_TARGET_WRITE_PIPELINE_DEDUP_WINDOW_SIZE: %v, it must be positive or 0",
+			c.TargetWritePipelineDedupWindowSize)
+	}
+	targetDownPolicy, err := c.ParseTargetDownPolicy()
+	if err != nil {
+		return common.TargetWritePipelineUndefined, err
+	}
+	if targetDownPolicy == common.TargetDownPolicyOriginOnly {
+		return common.TargetWritePipelineUndefined, fmt.Errorf(
+			"ZDM_TARGET_DOWN_POLICY can't be %v when ZDM_TARGET_WRITE_PIPELINE is %v, "+
+				"the writes are already applied to TARGET asynchronously", TargetDownPolicyOriginOnly, TargetWritePipelineKafka)
+	}
+	return common.TargetWritePipelineKafka, nil
 }
 
-func (c *Config) ParseTargetTlsConfig(displayLogMessages bool) (*common.ClusterTlsConfig, error) {
+// ParseTargetWritePipelineKafkaBrokers returns the Kafka brokers of the KAFKA target write pipeline.
+func (c *Config) ParseTargetWritePipelineKafkaBrokers() []string {
+	return parseKafkaBrokers(c.TargetWritePipelineKafkaBrokers)
+}
 
-	// No TLS defined
+// ParseProxyConnectionAcceptRateLimit returns the maximum number of client connections per second that the listener
+// accepts, 0 means that the accept rate is not limited. The connections above the rate wait in the accept backlog.
+func (c *Config) ParseProxyConnectionAcceptRateLimit() (int, error) {
+	if c.ProxyConnectionAcceptRateLimit < 0 {
+		return 0, fmt.Errorf("invalid value for ZDM_PROXY_CONNECTION_ACCEPT_RATE_LIMIT: %v, it must not be negative",
+			c.ProxyConnectionAcceptRateLimit)
+	}
+	return c.ProxyConnectionAcceptRateLimit, nil
+}
 
-	if isNotDefined(c.TargetSecureConnectBundlePath) &&
-		isNotDefined(c.TargetTlsServerCaPath) &&
-		isNotDefined(c.TargetTlsClientCertPath) &&
-		isNotDefined(c.TargetTlsClientKeyPath) {
-		if displayLogMessages {
-			log.Infof("TLS was not configured for Target")
-		}
-		return &common.ClusterTlsConfig{
-			TlsEnabled: false,
-		}, nil
+// ParseProxyMaxConcurrentHandshakes returns the maximum number of client connections that can be in the handshake
+// phase at the same time (0 means no limit) and how long the other connections wait for a handshake slot before they
+// are closed.
+func (c *Config) ParseProxyMaxConcurrentHandshakes() (int, time.Duration, error) {
+	if c.ProxyMaxConcurrentHandshakes < 0 {
+		return 0, 0, fmt.Errorf("invalid value for ZDM_PROXY_MAX_CONCURRENT_HANDSHAKES: %v, it must not be negative",
+			c.ProxyMaxConcurrentHandshakes)
 	}
+	if c.ProxyMaxConcurrentHandshakes > 0 && c.ProxyHandshakeQueueTimeoutMs <= 0 {
+		return 0, 0, fmt.Errorf("invalid value for ZDM_PROXY_HANDSHAKE_QUEUE_TIMEOUT_MS: %v, it must be positive "+
+			"when ZDM_PROXY_MAX_CONCURRENT_HANDSHAKES is set", c.ProxyHandshakeQueueTimeoutMs)
+	}
+	return c.ProxyMaxConcurrentHandshakes, time.Duration(c.ProxyHandshakeQueueTimeoutMs) * time.Millisecond, nil
+}
 
-	//SCB specified
+const (
+	FrameExportFormatPcap = "PCAP"
+	FrameExportFormatHex  = "HEX"
+)
 
-	if isDefined(c.TargetSecureConnectBundlePath) {
-		if isDefined(c.TargetTlsServerCaPath) || isDefined(c.TargetTlsClientCertPath) || isDefined(c.TargetTlsClientKeyPath) {
-			return &common.ClusterTlsConfig{}, fmt.Errorf("Incorrect TLS configuration for Target: Secure Connect Bundle and custom TLS parameters cannot be specified at the same time.")
-		}
+// ParseFrameExportFormat returns the format of the files that the frames of the client connections are exported to
+// when the export is enabled for their client address.
+func (c *Config) ParseFrameExportFormat() (common.FrameExportFormat, error) {
+	switch strings.ToUpper(strings.TrimSpace(c.FrameExportFormat)) {
+	case "", FrameExportFormatPcap:
+		return common.FrameExportFormatPcap, nil
+	case FrameExportFormatHex:
+		return common.FrameExportFormatHex, nil
+	default:
+		return common.FrameExportFormatUndefined, fmt.Errorf(
+			"invalid value for ZDM_FRAME_EXPORT_FORMAT; possible values are: %v and %v",
+			FrameExportFormatPcap, FrameExportFormatHex)
+	}
+}
 
-		return &common.ClusterTlsConfig{
-			TlsEnabled:              true,
-			SecureConnectBundlePath: c.TargetSecureConnectBundlePath,
-		}, nil
+// ParseSharedConfigBackend returns the key-value store that the shared settings of the proxy fleet are read from.
+func (c *Config) ParseSharedConfigBackend() (common.SharedConfigBackend, error) {
+	switch strings.ToUpper(strings.TrimSpace(c.SharedConfigBackend)) {
+	case "", SharedConfigBackendNone:
+		return common.SharedConfigBackendNone, nil
+	case SharedConfigBackendEtcd:
+		return common.SharedConfigBackendEtcd, nil
+	case SharedConfigBackendConsul:
+		return common.SharedConfigBackendConsul, nil
+	default:
+		return common.SharedConfigBackendUndefined, fmt.Errorf(
+			"invalid value for ZDM_SHARED_CONFIG_BACKEND; possible values are: %v, %v and %v",
+			SharedConfigBackendNone, SharedConfigBackendEtcd, SharedConfigBackendConsul)
 	}
+}
 
-	// Custom TLS params specified
+// ParseSharedConfigEndpoints returns the urls of the etcd or Consul servers (e.g. http://10.0.0.1:2379) or nil if
+// shared configuration is disabled.
+func (c *Config) ParseSharedConfigEndpoints() ([]*url.URL, error) {
+	backend, err := c.ParseSharedConfigBackend()
+	if err != nil {
+		return nil, err
+	}
+	if backend == common.SharedConfigBackendNone {
+		return nil, nil
+	}
 
-	if isDefined(c.TargetTlsServerCaPath) && (isNotDefined(c.TargetTlsClientCertPath) && isNotDefined(c.TargetTlsClientKeyPath)) {
-		if displayLogMessages {
-			log.Infof("One-way TLS configured for Target. Please note that hostname verification is not currently supported.")
+	var endpoints []*url.URL
+	for _, endpoint := range strings.Split(c.SharedConfigEndpoints, ",") {
+		endpoint = strings.TrimSpace(endpoint)
+		if endpoint == "" {
+			continue
 		}
-		return &common.ClusterTlsConfig{
-			TlsEnabled:   true,
-			ServerCaPath: c.TargetTlsServerCaPath,
-		}, nil
+		endpointUrl, err := url.Parse(endpoint)
+		if err != nil || (endpointUrl.Scheme != "http" && endpointUrl.Scheme != "https") || endpointUrl.Host == "" {
+			return nil, fmt.Errorf("invalid value for ZDM_SHARED_CONFIG_ENDPOINTS: %v, it must be a list of http or https urls",
+				c.SharedConfigEndpoints)
+		}
+		endpoints = append(endpoints, endpointUrl)
+	}
+	if len(endpoints) == 0 {
+		return nil, fmt.Errorf("ZDM_SHARED_CONFIG_BACKEND is %v but ZDM_SHARED_CONFIG_ENDPOINTS is empty", backend)
+	}
+	if strings.TrimSpace(c.SharedConfigPrefix) == "" {
+		return nil, fmt.Errorf("ZDM_SHARED_CONFIG_BACKEND is %v but ZDM_SHARED_CONFIG_PREFIX is empty", backend)
+	}
+	_, err = c.ParseSharedConfigLeaderTtl()
+	if err != nil {
+		return nil, err
 	}
+	_, err = c.ParseProxyGlobalRequestRateLimitKey()
+	if err != nil {
+		return nil, err
+	}
+	return endpoints, nil
+}
 
-	if isDefined(c.TargetTlsServerCaPath) && isDefined(c.TargetTlsClientCertPath) && isDefined(c.TargetTlsClientKeyPath) {
-		if displayLogMessages {
-			log.Infof("Mutual TLS configured for Target. Please note that hostname verification is not currently supported.")
+// ParseSharedConfigLeaderTtl returns the time after which another instance can be elected as the leader of the fleet
+// when the leader stops renewing its session (e.g. because it crashed).
+func (c *Config) ParseSharedConfigLeaderTtl() (time.Duration, error) {
+	if c.SharedConfigLeaderTtlMs < 10000 {
+		return 0, fmt.Errorf("invalid value for ZDM_SHARED_CONFIG_LEADER_TTL_MS: %v, it must be at least 10000",
+			c.SharedConfigLeaderTtlMs)
+	}
+	return time.Duration(c.SharedConfigLeaderTtlMs) * time.Millisecond, nil
+}
+
+// ParseGuardrailsConfig returns the thresholds of the write guardrails or nil if they are all 0.
+func (c *Config) ParseGuardrailsConfig() (*common.GuardrailsConfig, error) {
+	thresholds := []struct {
+		warnName string
+		warn     int
+		failName string
+		fail     int
+	}{
+		{"ZDM_GUARDRAIL_BATCH_SIZE_WARN_BYTES", c.GuardrailBatchSizeWarnBytes,
+			"ZDM_GUARDRAIL_BATCH_SIZE_FAIL_BYTES", c.GuardrailBatchSizeFailBytes},
+		{"ZDM_GUARDRAIL_BATCH_STATEMENTS_WARN", c.GuardrailBatchStatementsWarn,
+			"ZDM_GUARDRAIL_BATCH_STATEMENTS_FAIL", c.GuardrailBatchStatementsFail},
+		{"ZDM_GUARDRAIL_MUTATION_SIZE_WARN_BYTES", c.GuardrailMutationSizeWarnBytes,
+			"ZDM_GUARDRAIL_MUTATION_SIZE_FAIL_BYTES", c.GuardrailMutationSizeFailBytes},
+	}
+	enabled := false
+	for _, threshold := range thresholds {
+		if threshold.warn < 0 {
+			return nil, fmt.Errorf("invalid value for %v: %v, it must be positive or 0", threshold.warnName, threshold.warn)
 		}
-		return &common.ClusterTlsConfig{
-			TlsEnabled:     true,
-			ServerCaPath:   c.TargetTlsServerCaPath,
-			ClientCertPath: c.TargetTlsClientCertPath,
-			ClientKeyPath:  c.TargetTlsClientKeyPath,
-		}, nil
+		if threshold.fail < 0 {
+			return nil, fmt.Errorf("invalid value for %v: %v, it must be positive or 0", threshold.failName, threshold.fail)
+		}
+		if threshold.warn > 0 && threshold.fail > 0 && threshold.warn > threshold.fail {
+			return nil, fmt.Errorf("invalid value for %v: %v, it must be greater than or equal to %v (%v)",
+				threshold.failName, threshold.fail, threshold.warnName, threshold.warn)
+		}
+		enabled = enabled || threshold.warn > 0 || threshold.fail > 0
+	}
+	if c.GuardrailMaxQueryLength < 0 {
+		return nil, fmt.Errorf("invalid value for ZDM_GUARDRAIL_MAX_QUERY_LENGTH: %v, it must be positive or 0",
+			c.GuardrailMaxQueryLength)
+	}
+	if c.GuardrailMaxValuesSizeBytes < 0 {
+		return nil, fmt.Errorf("invalid value for ZDM_GUARDRAIL_MAX_VALUES_SIZE_BYTES: %v, it must be positive or 0",
+			c.GuardrailMaxValuesSizeBytes)
+	}
+	enabled = enabled || c.GuardrailMaxQueryLength > 0 || c.GuardrailMaxValuesSizeBytes > 0
+	if !enabled {
+		return nil, nil
+	}
+	return &common.GuardrailsConfig{
+		BatchSizeWarnBytes:    c.GuardrailBatchSizeWarnBytes,
+		BatchSizeFailBytes:    c.GuardrailBatchSizeFailBytes,
+		BatchStatementsWarn:   c.GuardrailBatchStatementsWarn,
+		BatchStatementsFail:   c.GuardrailBatchStatementsFail,
+		MutationSizeWarnBytes: c.GuardrailMutationSizeWarnBytes,
+		MutationSizeFailBytes: c.GuardrailMutationSizeFailBytes,
+		MaxQueryLength:        c.GuardrailMaxQueryLength,
+		MaxValuesSizeBytes:    c.GuardrailMaxValuesSizeBytes,
+		ClientWarningsEnabled: c.GuardrailClientWarningsEnabled,
+	}, nil
+}
+
+// ParsePageSizeOverride returns the maximum page size that the proxy enforces and the page size that it sets on the
+// requests that don't have one, 0 if the matching override is disabled.
+func (c *Config) ParsePageSizeOverride() (int, int, error) {
+	if c.PageSizeMax < 0 {
+		return 0, 0, fmt.Errorf("invalid value for ZDM_PAGE_SIZE_MAX: %v, it must be positive or 0", c.PageSizeMax)
+	}
+	if c.PageSizeDefault < 0 {
+		return 0, 0, fmt.Errorf("invalid value for ZDM_PAGE_SIZE_DEFAULT: %v, it must be positive or 0", c.PageSizeDefault)
+	}
+	if c.PageSizeMax > 0 && c.PageSizeDefault > c.PageSizeMax {
+		return 0, 0, fmt.Errorf("invalid value for ZDM_PAGE_SIZE_DEFAULT: %v, it must be lower than or equal to "+
+			"ZDM_PAGE_SIZE_MAX (%v)", c.PageSizeDefault, c.PageSizeMax)
 	}
+	return c.PageSizeMax, c.PageSizeDefault, nil
+}
 
-	return &common.ClusterTlsConfig{}, fmt.Errorf("incomplete TLS configuration for Target: when using mutual TLS, please specify Server CA path, Client Cert path and Client Key path")
+const (
+	SecondaryErrorActionError   = "ERROR"
+	SecondaryErrorActionWarning = "WARNING"
+	SecondaryErrorActionMetric  = "METRIC"
+
+	// SecondaryErrorCodeDefault is the key of ZDM_SECONDARY_ERROR_POLICY that applies to the error codes that are not listed
+	SecondaryErrorCodeDefault = "DEFAULT"
+)
+
+// SecondaryErrorCodes are the error codes that can be mapped by ZDM_SECONDARY_ERROR_POLICY. UNPREPARED is not one of
+// them because the client has to prepare the statement again on both clusters.
+var SecondaryErrorCodes = []string{
+	"SERVER_ERROR", "UNAVAILABLE", "OVERLOADED", "IS_BOOTSTRAPPING", "TRUNCATE_ERROR", "WRITE_TIMEOUT", "READ_TIMEOUT",
+	"READ_FAILURE", "FUNCTION_FAILURE", "WRITE_FAILURE", "SYNTAX_ERROR", "UNAUTHORIZED", "INVALID", "CONFIG_ERROR",
+	"ALREADY_EXISTS",
 }
 
-func (c *Config) ParseProxyTlsConfig(displayLogMessages bool) (*common.ProxyTlsConfig, error) {
+// ParseSecondaryErrorPolicy returns how the errors of the writes that only failed on the secondary cluster are
+// surfaced, keyed by error code (e.g. WRITE_TIMEOUT) or DEFAULT for the codes that are not listed. The value is a comma
+// separated list of <ERROR_CODE>=<ACTION> pairs, it returns nil when it's not set (every error is returned to the
+// client).
+func (c *Config) ParseSecondaryErrorPolicy() (map[string]common.SecondaryErrorAction, error) {
+	if !isDefined(c.SecondaryErrorPolicy) {
+		return nil, nil
+	}
+	policy := make(map[string]common.SecondaryErrorAction)
+	for _, entry := range strings.Split(c.SecondaryErrorPolicy, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		separatorIdx := strings.Index(entry, "=")
+		if separatorIdx < 0 {
+			return nil, fmt.Errorf(
+				"invalid value for ZDM_SECONDARY_ERROR_POLICY: %v, expected <ERROR_CODE>=<ACTION>", entry)
+		}
+		errorCode := strings.ToUpper(strings.TrimSpace(entry[:separatorIdx]))
+		if errorCode == "UNPREPARED" {
+			return nil, fmt.Errorf("invalid value for ZDM_SECONDARY_ERROR_POLICY: %v, UNPREPARED errors are always "+
+				"returned to the client so that it prepares the statement again", entry)
+		}
+		if errorCode != SecondaryErrorCodeDefault && !isSecondaryErrorCode(errorCode) {
+			return nil, fmt.Errorf("invalid error code in ZDM_SECONDARY_ERROR_POLICY: %v; possible values are: %v and %v",
+				errorCode, strings.Join(SecondaryErrorCodes, ", "), SecondaryErrorCodeDefault)
+		}
+		if _, ok := policy[errorCode]; ok {
+			return nil, fmt.Errorf("invalid value for ZDM_SECONDARY_ERROR_POLICY: %v is listed more than once", errorCode)
+		}
+		switch strings.ToUpper(strings.TrimSpace(entry[separatorIdx+1:])) {
+		case SecondaryErrorActionError:
+			policy[errorCode] = common.SecondaryErrorActionError
+		case SecondaryErrorActionWarning:
+			policy[errorCode] = common.SecondaryErrorActionWarning
+		case SecondaryErrorActionMetric:
+			policy[errorCode] = common.SecondaryErrorActionMetric
+		default:
+			return nil, fmt.Errorf("invalid action for %v in ZDM_SECONDARY_ERROR_POLICY; possible values are: %v, %v and %v",
+				errorCode, SecondaryErrorActionError, SecondaryErrorActionWarning, SecondaryErrorActionMetric)
+		}
+	}
+	return policy, nil
+}
 
-	if isNotDefined(c.ProxyTlsCaPath) &&
-		isNotDefined(c.ProxyTlsCertPath) &&
-		isNotDefined(c.ProxyTlsKeyPath) {
-		if displayLogMessages {
-			log.Info("Proxy TLS was not configured.")
+func isSecondaryErrorCode(errorCode string) bool {
+	for _, code := range SecondaryErrorCodes {
+		if code == errorCode {
+			return true
 		}
-		return &common.ProxyTlsConfig{
-			TlsEnabled: false,
-		}, nil
 	}
+	return false
+}
 
-	if isDefined(c.ProxyTlsCaPath) && isDefined(c.ProxyTlsCertPath) && isDefined(c.ProxyTlsKeyPath) {
-		if displayLogMessages {
-			log.Info("Proxy TLS configured. Please note that hostname verification is not currently supported.")
+const (
+	DualWriteResponsePolicyBoth    = "BOTH"
+	DualWriteResponsePolicyPrimary = "PRIMARY"
+	DualWriteResponsePolicyEither  = "EITHER"
+	DualWriteResponsePolicyQuorum  = "QUORUM"
+
+	// DualWriteStatementTypeDefault is the key of ZDM_DUAL_WRITE_RESPONSE_POLICY that applies to the statement types
+	// that are not listed
+	DualWriteStatementTypeDefault = "DEFAULT"
+)
+
+// DualWriteStatementTypes are the statement types that can be listed in ZDM_DUAL_WRITE_RESPONSE_POLICY. The other
+// statements that are sent to both clusters (e.g. USE and schema changes) always need both responses to succeed.
+var DualWriteStatementTypes = []string{"INSERT", "UPDATE", "DELETE", "BATCH"}
+
+// ParseDualWriteResponsePolicy returns which responses of a write sent to both clusters must be successful for the
+// client to see a success, keyed by statement type (e.g. INSERT) or DEFAULT for the types that are not listed. The
+// value is a comma separated list of <STATEMENT_TYPE>=<POLICY> pairs, it returns nil when it's not set (both
+// responses must be successful). QUORUM behaves like EITHER with a warning added to the response: with two clusters a
+// single successful response is enough.
+func (c *Config) ParseDualWriteResponsePolicy() (map[string]common.DualWriteResponsePolicy, error) {
+	if !isDefined(c.DualWriteResponsePolicy) {
+		return nil, nil
+	}
+	policy := make(map[string]common.DualWriteResponsePolicy)
+	for _, entry := range strings.Split(c.DualWriteResponsePolicy, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		separatorIdx := strings.Index(entry, "=")
+		if separatorIdx < 0 {
+			return nil, fmt.Errorf(
+				"invalid value for ZDM_DUAL_WRITE_RESPONSE_POLICY: %v, expected <STATEMENT_TYPE>=<POLICY>", entry)
+		}
+		statementType := strings.ToUpper(strings.TrimSpace(entry[:separatorIdx]))
+		if statementType != DualWriteStatementTypeDefault && !isDualWriteStatementType(statementType) {
+			return nil, fmt.Errorf("invalid statement type in ZDM_DUAL_WRITE_RESPONSE_POLICY: %v; possible values are: "+
+				"%v and %v", statementType, strings.Join(DualWriteStatementTypes, ", "), DualWriteStatementTypeDefault)
+		}
+		if _, ok := policy[statementType]; ok {
+			return nil, fmt.Errorf(
+				"invalid value for ZDM_DUAL_WRITE_RESPONSE_POLICY: %v is listed more than once", statementType)
+		}
+		switch strings.ToUpper(strings.TrimSpace(entry[separatorIdx+1:])) {
+		case DualWriteResponsePolicyBoth:
+			policy[statementType] = common.DualWriteResponsePolicyBoth
+		case DualWriteResponsePolicyPrimary:
+			policy[statementType] = common.DualWriteResponsePolicyPrimary
+		case DualWriteResponsePolicyEither:
+			policy[statementType] = common.DualWriteResponsePolicyEither
+		case DualWriteResponsePolicyQuorum:
+			policy[statementType] = common.DualWriteResponsePolicyQuorum
+		default:
+			return nil, fmt.Errorf("invalid policy for %v in ZDM_DUAL_WRITE_RESPONSE_POLICY; possible values are: "+
+				"%v, %v, %v and %v", statementType, DualWriteResponsePolicyBoth, DualWriteResponsePolicyPrimary,
+				DualWriteResponsePolicyEither, DualWriteResponsePolicyQuorum)
 		}
-		return &common.ProxyTlsConfig{
-			TlsEnabled:    true,
-			ProxyCaPath:   c.ProxyTlsCaPath,
-			ProxyCertPath: c.ProxyTlsCertPath,
-			ProxyKeyPath:  c.ProxyTlsKeyPath,
-			ClientAuth:    c.ProxyTlsRequireClientAuth,
-		}, nil
 	}
+	return policy, nil
+}
 
-	return &common.ProxyTlsConfig{}, fmt.Errorf("incomplete Proxy TLS configuration: when enabling proxy TLS, please specify CA path, Cert path and Key path")
+// ParseLwtSerialConsistencies returns the serial consistency levels that replace the ones of the lightweight
+// transactions sent to ORIGIN and TARGET. A cluster whose serial consistency is not replaced gets
+// primitive.ConsistencyLevelAny (the zero value), which is never a valid serial consistency.
+func (c *Config) ParseLwtSerialConsistencies() (primitive.ConsistencyLevel, primitive.ConsistencyLevel, error) {
+	originSerialConsistency, err := parseSerialConsistency(
+		"ZDM_ORIGIN_LWT_SERIAL_CONSISTENCY", c.OriginLwtSerialConsistency)
+	if err != nil {
+		return 0, 0, err
+	}
+	targetSerialConsistency, err := parseSerialConsistency(
+		"ZDM_TARGET_LWT_SERIAL_CONSISTENCY", c.TargetLwtSerialConsistency)
+	if err != nil {
+		return 0, 0, err
+	}
+	return originSerialConsistency, targetSerialConsistency, nil
 }
 
-func isDefined(propertyValue string) bool {
-	return propertyValue != ""
+func parseSerialConsistency(envVarName string, value string) (primitive.ConsistencyLevel, error) {
+	switch strings.ToUpper(strings.TrimSpace(value)) {
+	case "":
+		return primitive.ConsistencyLevelAny, nil
+	case "SERIAL":
+		return primitive.ConsistencyLevelSerial, nil
+	case "LOCAL_SERIAL":
+		return primitive.ConsistencyLevelLocalSerial, nil
+	default:
+		return 0, fmt.Errorf("invalid value for %v: %v; possible values are: SERIAL and LOCAL_SERIAL", envVarName, value)
+	}
 }
 
-func isNotDefined(propertyValue string) bool {
-	return !isDefined(propertyValue)
+func isDualWriteStatementType(statementType string) bool {
+	for _, stmtType := range DualWriteStatementTypes {
+		if stmtType == statementType {
+			return true
+		}
+	}
+	return false
 }
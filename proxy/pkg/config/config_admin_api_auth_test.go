@@ -0,0 +1,103 @@
+package config
+
+import (
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfig_ParseAdminApiAuthConfig(t *testing.T) {
+
+	type test struct {
+		name               string
+		envVars            []envVar
+		expectedAuthConfig *common.AdminApiAuthConfig
+		errExpected        bool
+		errMsg             string
+	}
+
+	tests := []test{
+		{
+			name:               "Valid: authentication disabled by default",
+			expectedAuthConfig: &common.AdminApiAuthConfig{},
+		},
+		{
+			name: "Valid: tokens of both roles",
+			envVars: []envVar{
+				{"ZDM_ADMIN_API_OPERATOR_TOKENS", "op1, op2"},
+				{"ZDM_ADMIN_API_READ_ONLY_TOKENS", "ro1,"},
+				{"ZDM_METRICS_AUTH_REQUIRED", "true"}},
+			expectedAuthConfig: &common.AdminApiAuthConfig{
+				OperatorTokens:      []string{"op1", "op2"},
+				ReadOnlyTokens:      []string{"ro1"},
+				MetricsAuthRequired: true,
+			},
+		},
+		{
+			name: "Valid: client certificate names",
+			envVars: []envVar{
+				{"ZDM_METRICS_TLS_CERT_PATH", "/tmp/server.crt"},
+				{"ZDM_METRICS_TLS_KEY_PATH", "/tmp/server.key"},
+				{"ZDM_METRICS_TLS_CLIENT_CA_PATH", "/tmp/ca.crt"},
+				{"ZDM_ADMIN_API_READ_ONLY_CERT_NAMES", "monitoring"}},
+			expectedAuthConfig: &common.AdminApiAuthConfig{
+				ReadOnlyCertNames: []string{"monitoring"},
+			},
+		},
+		{
+			name: "Invalid: token of both roles",
+			envVars: []envVar{
+				{"ZDM_ADMIN_API_OPERATOR_TOKENS", "op1,shared"},
+				{"ZDM_ADMIN_API_READ_ONLY_TOKENS", "shared"}},
+			errExpected: true,
+			errMsg: "invalid value for ZDM_ADMIN_API_READ_ONLY_TOKENS: " +
+				"a token can not be in ZDM_ADMIN_API_OPERATOR_TOKENS too",
+		},
+		{
+			name:        "Invalid: client certificate names without client CA",
+			envVars:     []envVar{{"ZDM_ADMIN_API_OPERATOR_CERT_NAMES", "admin"}},
+			errExpected: true,
+			errMsg:      "the client certificate names of the admin API roles require ZDM_METRICS_TLS_CLIENT_CA_PATH",
+		},
+		{
+			name:        "Invalid: metrics authentication without credentials",
+			envVars:     []envVar{{"ZDM_METRICS_AUTH_REQUIRED", "true"}},
+			errExpected: true,
+			errMsg:      "ZDM_METRICS_AUTH_REQUIRED requires the tokens or the client certificate names",
+		},
+		{
+			name:        "Invalid: client CA without server certificate",
+			envVars:     []envVar{{"ZDM_METRICS_TLS_CLIENT_CA_PATH", "/tmp/ca.crt"}},
+			errExpected: true,
+			errMsg:      "incomplete TLS configuration of the metrics endpoint",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				authConfig, err := conf.ParseAdminApiAuthConfig()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedAuthConfig, authConfig)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestConfig_ParseAutoCutoverConfig(t *testing.T) {
+
+	type test struct {
+		name           string
+		envVars        []envVar
+		expectedConfig *common.AutoCutoverConfig
+		errExpected    bool
+		errMsg         string
+	}
+
+	tests := []test{
+		{
+			name:           "Valid: disabled",
+			envVars:        []envVar{{"ZDM_AUTO_CUTOVER_MAX_MISMATCH_RATE", "2"}},
+			expectedConfig: nil,
+		},
+		{
+			name:    "Valid: defaults",
+			envVars: []envVar{{"ZDM_AUTO_CUTOVER_ENABLED", "true"}},
+			expectedConfig: &common.AutoCutoverConfig{
+				MaxMismatchRate:  0.0001,
+				MinComparedReads: 1000,
+				StableDuration:   time.Hour,
+				CheckInterval:    time.Minute,
+			},
+		},
+		{
+			name: "Valid: custom thresholds",
+			envVars: []envVar{
+				{"ZDM_AUTO_CUTOVER_ENABLED", "true"},
+				{"ZDM_AUTO_CUTOVER_MAX_MISMATCH_RATE", "0"},
+				{"ZDM_AUTO_CUTOVER_MIN_COMPARED_READS", "50"},
+				{"ZDM_AUTO_CUTOVER_STABLE_DURATION_MS", "86400000"},
+				{"ZDM_AUTO_CUTOVER_CHECK_INTERVAL_MS", "10000"},
+			},
+			expectedConfig: &common.AutoCutoverConfig{
+				MaxMismatchRate:  0,
+				MinComparedReads: 50,
+				StableDuration:   24 * time.Hour,
+				CheckInterval:    10 * time.Second,
+			},
+		},
+		{
+			name:        "Invalid: mismatch rate",
+			envVars:     []envVar{{"ZDM_AUTO_CUTOVER_ENABLED", "true"}, {"ZDM_AUTO_CUTOVER_MAX_MISMATCH_RATE", "1.5"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_AUTO_CUTOVER_MAX_MISMATCH_RATE: 1.5, it must be between 0 and 1",
+		},
+		{
+			name:        "Invalid: no compared reads required",
+			envVars:     []envVar{{"ZDM_AUTO_CUTOVER_ENABLED", "true"}, {"ZDM_AUTO_CUTOVER_MIN_COMPARED_READS", "0"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_AUTO_CUTOVER_MIN_COMPARED_READS: 0, it must be positive",
+		},
+		{
+			name:        "Invalid: check interval",
+			envVars:     []envVar{{"ZDM_AUTO_CUTOVER_ENABLED", "true"}, {"ZDM_AUTO_CUTOVER_CHECK_INTERVAL_MS", "-1"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_AUTO_CUTOVER_CHECK_INTERVAL_MS: -1, it must be positive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				autoCutoverConfig, err := conf.ParseAutoCutoverConfig()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedConfig, autoCutoverConfig)
+			}
+		})
+	}
+}
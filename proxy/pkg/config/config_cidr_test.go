@@ -0,0 +1,91 @@
+package config
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfig_ParseProxyClientCidrs(t *testing.T) {
+
+	type test struct {
+		name            string
+		envVars         []envVar
+		expectedAllowed []string
+		expectedDenied  []string
+		errExpected     bool
+		errMsg          string
+	}
+
+	tests := []test{
+		{
+			name:    "Valid: default",
+			envVars: []envVar{},
+		},
+		{
+			name: "Valid: allowed and denied",
+			envVars: []envVar{
+				{"ZDM_PROXY_CLIENT_ALLOWED_CIDRS", "10.0.0.0/8, 192.168.1.0/24"},
+				{"ZDM_PROXY_CLIENT_DENIED_CIDRS", "10.0.0.1,fd00::/8"}},
+			expectedAllowed: []string{"10.0.0.0/8", "192.168.1.0/24"},
+			expectedDenied:  []string{"10.0.0.1/32", "fd00::/8"},
+		},
+		{
+			name:           "Valid: ipv6 address",
+			envVars:        []envVar{{"ZDM_PROXY_CLIENT_DENIED_CIDRS", "fd00::1"}},
+			expectedDenied: []string{"fd00::1/128"},
+		},
+		{
+			name:        "Invalid: allowed",
+			envVars:     []envVar{{"ZDM_PROXY_CLIENT_ALLOWED_CIDRS", "10.0.0.0/33"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_PROXY_CLIENT_ALLOWED_CIDRS: 10.0.0.0/33 is not a CIDR block or an IP address",
+		},
+		{
+			name:        "Invalid: denied",
+			envVars:     []envVar{{"ZDM_PROXY_CLIENT_DENIED_CIDRS", "10.0.0.0/8,localhost"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_PROXY_CLIENT_DENIED_CIDRS: localhost is not a CIDR block or an IP address",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Equal(t, tt.errMsg, err.Error())
+				return
+			}
+			require.Nil(t, err)
+
+			allowed, err := conf.ParseProxyClientAllowedCidrs()
+			require.Nil(t, err)
+			var allowedStr []string
+			for _, network := range allowed {
+				allowedStr = append(allowedStr, network.String())
+			}
+			require.Equal(t, tt.expectedAllowed, allowedStr)
+
+			denied, err := conf.ParseProxyClientDeniedCidrs()
+			require.Nil(t, err)
+			var deniedStr []string
+			for _, network := range denied {
+				deniedStr = append(deniedStr, network.String())
+			}
+			require.Equal(t, tt.expectedDenied, deniedStr)
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package config
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfig_ProxyClientAuth(t *testing.T) {
+
+	type test struct {
+		name        string
+		envVars     []envVar
+		errExpected bool
+		errMsg      string
+	}
+
+	tests := []test{
+		{
+			name:    "Valid: disabled",
+			envVars: []envVar{},
+		},
+		{
+			name:    "Valid: credentials file",
+			envVars: []envVar{{"ZDM_PROXY_CLIENT_CREDENTIALS_FILE", "/path/to/credentials"}},
+		},
+		{
+			name: "Valid: jwt",
+			envVars: []envVar{
+				{"ZDM_PROXY_CLIENT_JWT_JWKS_URL", "https://issuer.example.com/jwks.json"},
+				{"ZDM_PROXY_CLIENT_JWT_ISSUER", "https://issuer.example.com"},
+				{"ZDM_PROXY_CLIENT_JWT_AUDIENCE", "zdm-proxy"}},
+		},
+		{
+			name: "Valid: jwt with credentials mapping",
+			envVars: []envVar{
+				{"ZDM_PROXY_CLIENT_JWT_JWKS_URL", "/path/to/jwks.json"},
+				{"ZDM_PROXY_CLIENT_JWT_ISSUER", "https://issuer.example.com"},
+				{"ZDM_PROXY_CLIENT_JWT_CREDENTIALS_CLAIM", "roles"},
+				{"ZDM_PROXY_CLIENT_JWT_CREDENTIALS_FILE", "/path/to/mapping.json"}},
+		},
+		{
+			name:    "Valid: role mapping",
+			envVars: []envVar{{"ZDM_ROLE_MAPPING_FILE", "/path/to/roles.json"}},
+		},
+		{
+			name: "Invalid: role mapping and credentials file",
+			envVars: []envVar{
+				{"ZDM_ROLE_MAPPING_FILE", "/path/to/roles.json"},
+				{"ZDM_PROXY_CLIENT_CREDENTIALS_FILE", "/path/to/credentials"}},
+			errExpected: true,
+			errMsg: "invalid proxy client authentication configuration: " +
+				"ZDM_ROLE_MAPPING_FILE can not be used with proxy-level client authentication",
+		},
+		{
+			name: "Invalid: jwt and credentials file",
+			envVars: []envVar{
+				{"ZDM_PROXY_CLIENT_CREDENTIALS_FILE", "/path/to/credentials"},
+				{"ZDM_PROXY_CLIENT_JWT_JWKS_URL", "https://issuer.example.com/jwks.json"},
+				{"ZDM_PROXY_CLIENT_JWT_ISSUER", "https://issuer.example.com"}},
+			errExpected: true,
+			errMsg: "invalid proxy client authentication configuration: " +
+				"ZDM_PROXY_CLIENT_CREDENTIALS_FILE and ZDM_PROXY_CLIENT_JWT_JWKS_URL can not be used together",
+		},
+		{
+			name:        "Invalid: jwt without issuer",
+			envVars:     []envVar{{"ZDM_PROXY_CLIENT_JWT_JWKS_URL", "https://issuer.example.com/jwks.json"}},
+			errExpected: true,
+			errMsg: "invalid proxy client authentication configuration: " +
+				"ZDM_PROXY_CLIENT_JWT_ISSUER is required when ZDM_PROXY_CLIENT_JWT_JWKS_URL is set",
+		},
+		{
+			name:        "Invalid: issuer without jwks url",
+			envVars:     []envVar{{"ZDM_PROXY_CLIENT_JWT_ISSUER", "https://issuer.example.com"}},
+			errExpected: true,
+			errMsg: "invalid proxy client authentication configuration: " +
+				"the ZDM_PROXY_CLIENT_JWT_* settings can only be used with ZDM_PROXY_CLIENT_JWT_JWKS_URL",
+		},
+		{
+			name: "Invalid: credentials claim without credentials file",
+			envVars: []envVar{
+				{"ZDM_PROXY_CLIENT_JWT_JWKS_URL", "https://issuer.example.com/jwks.json"},
+				{"ZDM_PROXY_CLIENT_JWT_ISSUER", "https://issuer.example.com"},
+				{"ZDM_PROXY_CLIENT_JWT_CREDENTIALS_CLAIM", "roles"}},
+			errExpected: true,
+			errMsg: "invalid proxy client authentication configuration: " +
+				"ZDM_PROXY_CLIENT_JWT_CREDENTIALS_CLAIM and ZDM_PROXY_CLIENT_JWT_CREDENTIALS_FILE must be set together",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			_, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Equal(t, tt.errMsg, err.Error())
+				return
+			}
+			require.Nil(t, err)
+		})
+	}
+}
+
+func TestConfig_OriginAuthPassthrough(t *testing.T) {
+
+	type test struct {
+		name        string
+		envVars     []envVar
+		errExpected bool
+		errMsg      string
+	}
+
+	tests := []test{
+		{
+			name:    "Valid: passthrough",
+			envVars: []envVar{{"ZDM_ORIGIN_AUTH_PASSTHROUGH_ENABLED", "true"}},
+		},
+		{
+			name: "Valid: passthrough with astra token",
+			envVars: []envVar{
+				{"ZDM_ORIGIN_AUTH_PASSTHROUGH_ENABLED", "true"},
+				{"ZDM_TARGET_USERNAME", ""},
+				{"ZDM_TARGET_PASSWORD", ""},
+				{"ZDM_TARGET_ASTRA_TOKEN", "AstraCS:abc"}},
+		},
+		{
+			name: "Invalid: passthrough without target credentials",
+			envVars: []envVar{
+				{"ZDM_ORIGIN_AUTH_PASSTHROUGH_ENABLED", "true"},
+				{"ZDM_TARGET_USERNAME", ""},
+				{"ZDM_TARGET_PASSWORD", ""}},
+			errExpected: true,
+			errMsg: "invalid origin auth passthrough configuration: the target credentials " +
+				"(ZDM_TARGET_USERNAME and ZDM_TARGET_PASSWORD or ZDM_TARGET_ASTRA_TOKEN) are required",
+		},
+		{
+			name: "Invalid: passthrough and role mapping",
+			envVars: []envVar{
+				{"ZDM_ORIGIN_AUTH_PASSTHROUGH_ENABLED", "true"},
+				{"ZDM_ROLE_MAPPING_FILE", "/path/to/roles.json"}},
+			errExpected: true,
+			errMsg: "invalid origin auth passthrough configuration: ZDM_ORIGIN_AUTH_PASSTHROUGH_ENABLED can not " +
+				"be used with ZDM_ROLE_MAPPING_FILE or proxy-level client authentication",
+		},
+		{
+			name: "Invalid: passthrough and credentials file",
+			envVars: []envVar{
+				{"ZDM_ORIGIN_AUTH_PASSTHROUGH_ENABLED", "true"},
+				{"ZDM_PROXY_CLIENT_CREDENTIALS_FILE", "/path/to/credentials"}},
+			errExpected: true,
+			errMsg: "invalid origin auth passthrough configuration: ZDM_ORIGIN_AUTH_PASSTHROUGH_ENABLED can not " +
+				"be used with ZDM_ROLE_MAPPING_FILE or proxy-level client authentication",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			_, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Equal(t, tt.errMsg, err.Error())
+				return
+			}
+			require.Nil(t, err)
+		})
+	}
+}
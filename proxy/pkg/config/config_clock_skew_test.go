@@ -0,0 +1,89 @@
+package config
+
+import (
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestConfig_ParseClockSkewCheckConfig(t *testing.T) {
+
+	type test struct {
+		name           string
+		envVars        []envVar
+		expectedConfig *common.ClockSkewCheckConfig
+		errExpected    bool
+		errMsg         string
+	}
+
+	tests := []test{
+		{
+			name:           "Valid: disabled by default",
+			envVars:        []envVar{},
+			expectedConfig: &common.ClockSkewCheckConfig{},
+		},
+		{
+			name:           "Valid: enabled",
+			envVars:        []envVar{{"ZDM_CLOCK_SKEW_CHECK_ENABLED", "true"}},
+			expectedConfig: &common.ClockSkewCheckConfig{Interval: time.Minute, Threshold: 100 * time.Millisecond},
+		},
+		{
+			name: "Valid: interval and threshold",
+			envVars: []envVar{
+				{"ZDM_CLOCK_SKEW_CHECK_ENABLED", "true"},
+				{"ZDM_CLOCK_SKEW_CHECK_INTERVAL_MS", "5000"},
+				{"ZDM_CLOCK_SKEW_THRESHOLD_MS", "20"},
+			},
+			expectedConfig: &common.ClockSkewCheckConfig{Interval: 5 * time.Second, Threshold: 20 * time.Millisecond},
+		},
+		{
+			name: "Valid: interval is ignored when disabled",
+			envVars: []envVar{
+				{"ZDM_CLOCK_SKEW_CHECK_ENABLED", "false"}, {"ZDM_CLOCK_SKEW_CHECK_INTERVAL_MS", "0"}},
+			expectedConfig: &common.ClockSkewCheckConfig{},
+		},
+		{
+			name: "Invalid: interval",
+			envVars: []envVar{
+				{"ZDM_CLOCK_SKEW_CHECK_ENABLED", "true"}, {"ZDM_CLOCK_SKEW_CHECK_INTERVAL_MS", "0"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_CLOCK_SKEW_CHECK_INTERVAL_MS: 0, it must be positive",
+		},
+		{
+			name: "Invalid: threshold",
+			envVars: []envVar{
+				{"ZDM_CLOCK_SKEW_CHECK_ENABLED", "true"}, {"ZDM_CLOCK_SKEW_THRESHOLD_MS", "-1"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_CLOCK_SKEW_THRESHOLD_MS: -1, it must be positive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				clockSkewConfig, err := conf.ParseClockSkewCheckConfig()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedConfig, clockSkewConfig)
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package config
+
+import (
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfig_ParseCompatibilityProfile(t *testing.T) {
+
+	type test struct {
+		name                  string
+		envVars               []envVar
+		expectedOriginProfile common.CompatibilityProfile
+		expectedTargetProfile common.CompatibilityProfile
+		errExpected           bool
+		errMsg                string
+	}
+
+	tests := []test{
+		{
+			name:                  "Valid: profiles unset",
+			envVars:               []envVar{},
+			expectedOriginProfile: common.CompatibilityProfileNone,
+			expectedTargetProfile: common.CompatibilityProfileNone,
+		},
+		{
+			name: "Valid: keyspaces and cosmos",
+			envVars: []envVar{
+				{"ZDM_ORIGIN_COMPATIBILITY_PROFILE", "cosmos"},
+				{"ZDM_TARGET_COMPATIBILITY_PROFILE", "KEYSPACES"},
+			},
+			expectedOriginProfile: common.CompatibilityProfileCosmos,
+			expectedTargetProfile: common.CompatibilityProfileKeyspaces,
+		},
+		{
+			name:                  "Valid: astra when secure connect bundle is used",
+			envVars:               []envVar{{"ZDM_TARGET_SECURE_CONNECT_BUNDLE_PATH", "/path/to/bundle"}},
+			expectedOriginProfile: common.CompatibilityProfileNone,
+			expectedTargetProfile: common.CompatibilityProfileAstra,
+		},
+		{
+			name: "Valid: none overrides secure connect bundle",
+			envVars: []envVar{
+				{"ZDM_TARGET_SECURE_CONNECT_BUNDLE_PATH", "/path/to/bundle"},
+				{"ZDM_TARGET_COMPATIBILITY_PROFILE", "NONE"},
+			},
+			expectedOriginProfile: common.CompatibilityProfileNone,
+			expectedTargetProfile: common.CompatibilityProfileNone,
+		},
+		{
+			name:        "Invalid: unknown profile",
+			envVars:     []envVar{{"ZDM_ORIGIN_COMPATIBILITY_PROFILE", "DYNAMO"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_ORIGIN_COMPATIBILITY_PROFILE; possible values are: NONE, ASTRA, KEYSPACES and COSMOS",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				if envVar.vName == "ZDM_TARGET_SECURE_CONNECT_BUNDLE_PATH" {
+					setEnvVar("ZDM_TARGET_CONTACT_POINTS", "")
+				}
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				originProfile, err := conf.ParseOriginCompatibilityProfile()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedOriginProfile, originProfile)
+				targetProfile, err := conf.ParseTargetCompatibilityProfile()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedTargetProfile, targetProfile)
+			}
+		})
+	}
+}
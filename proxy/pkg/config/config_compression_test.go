@@ -0,0 +1,84 @@
+package config
+
+import (
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfig_ParseConnectionCompression(t *testing.T) {
+
+	type test struct {
+		name                      string
+		envVars                   []envVar
+		expectedOriginCompression common.ConnectionCompression
+		expectedTargetCompression common.ConnectionCompression
+		errExpected               bool
+		errMsg                    string
+	}
+
+	tests := []test{
+		{
+			name:                      "Valid: default",
+			envVars:                   []envVar{},
+			expectedOriginCompression: common.ConnectionCompressionNone,
+			expectedTargetCompression: common.ConnectionCompressionNone,
+		},
+		{
+			name:                      "Valid: target only",
+			envVars:                   []envVar{{"ZDM_TARGET_CONNECTION_COMPRESSION", "lz4"}},
+			expectedOriginCompression: common.ConnectionCompressionNone,
+			expectedTargetCompression: common.ConnectionCompressionLz4,
+		},
+		{
+			name: "Valid: both",
+			envVars: []envVar{
+				{"ZDM_ORIGIN_CONNECTION_COMPRESSION", "LZ4"}, {"ZDM_TARGET_CONNECTION_COMPRESSION", "LZ4"}},
+			expectedOriginCompression: common.ConnectionCompressionLz4,
+			expectedTargetCompression: common.ConnectionCompressionLz4,
+		},
+		{
+			name:        "Invalid: origin",
+			envVars:     []envVar{{"ZDM_ORIGIN_CONNECTION_COMPRESSION", "SNAPPY"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_ORIGIN_CONNECTION_COMPRESSION; possible values are: NONE and LZ4",
+		},
+		{
+			name:        "Invalid: target",
+			envVars:     []envVar{{"ZDM_TARGET_CONNECTION_COMPRESSION", "ZSTD"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_TARGET_CONNECTION_COMPRESSION; possible values are: NONE and LZ4",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				originCompression, err := conf.ParseOriginConnectionCompression()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedOriginCompression, originCompression)
+				targetCompression, err := conf.ParseTargetConnectionCompression()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedTargetCompression, targetCompression)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package config
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestConfig_ParseConnectionStormProtection(t *testing.T) {
+
+	type test struct {
+		name                  string
+		envVars               []envVar
+		expectedAcceptRate    int
+		expectedMaxHandshakes int
+		expectedQueueTimeout  time.Duration
+		errExpected           bool
+		errMsg                string
+	}
+
+	tests := []test{
+		{
+			name:                 "Valid: disabled by default",
+			envVars:              []envVar{},
+			expectedQueueTimeout: 10 * time.Second,
+		},
+		{
+			name: "Valid: limits",
+			envVars: []envVar{
+				{"ZDM_PROXY_CONNECTION_ACCEPT_RATE_LIMIT", "100"},
+				{"ZDM_PROXY_MAX_CONCURRENT_HANDSHAKES", "50"},
+				{"ZDM_PROXY_HANDSHAKE_QUEUE_TIMEOUT_MS", "2000"},
+			},
+			expectedAcceptRate:    100,
+			expectedMaxHandshakes: 50,
+			expectedQueueTimeout:  2 * time.Second,
+		},
+		{
+			name:        "Invalid: negative accept rate",
+			envVars:     []envVar{{"ZDM_PROXY_CONNECTION_ACCEPT_RATE_LIMIT", "-1"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_PROXY_CONNECTION_ACCEPT_RATE_LIMIT: -1, it must not be negative",
+		},
+		{
+			name:        "Invalid: negative concurrent handshakes",
+			envVars:     []envVar{{"ZDM_PROXY_MAX_CONCURRENT_HANDSHAKES", "-1"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_PROXY_MAX_CONCURRENT_HANDSHAKES: -1, it must not be negative",
+		},
+		{
+			name: "Invalid: no queue timeout",
+			envVars: []envVar{
+				{"ZDM_PROXY_MAX_CONCURRENT_HANDSHAKES", "50"},
+				{"ZDM_PROXY_HANDSHAKE_QUEUE_TIMEOUT_MS", "0"},
+			},
+			errExpected: true,
+			errMsg: "invalid value for ZDM_PROXY_HANDSHAKE_QUEUE_TIMEOUT_MS: 0, it must be positive when " +
+				"ZDM_PROXY_MAX_CONCURRENT_HANDSHAKES is set",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				acceptRate, err := conf.ParseProxyConnectionAcceptRateLimit()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedAcceptRate, acceptRate)
+				maxHandshakes, queueTimeout, err := conf.ParseProxyMaxConcurrentHandshakes()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedMaxHandshakes, maxHandshakes)
+				require.Equal(t, tt.expectedQueueTimeout, queueTimeout)
+			}
+		})
+	}
+}
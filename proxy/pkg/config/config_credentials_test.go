@@ -0,0 +1,117 @@
+package config
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfig_ParseCredentials(t *testing.T) {
+
+	type test struct {
+		name                   string
+		envVars                []envVar
+		expectedOriginUsername string
+		expectedOriginPassword string
+		expectedTargetUsername string
+		expectedTargetPassword string
+		errExpected            bool
+		errMsg                 string
+	}
+
+	tests := []test{
+		{
+			name: "Valid: username and password",
+			envVars: []envVar{
+				{"ZDM_ORIGIN_USERNAME", "originUser"}, {"ZDM_ORIGIN_PASSWORD", "originPassword"},
+				{"ZDM_TARGET_USERNAME", "targetUser"}, {"ZDM_TARGET_PASSWORD", "targetPassword"}},
+			expectedOriginUsername: "originUser",
+			expectedOriginPassword: "originPassword",
+			expectedTargetUsername: "targetUser",
+			expectedTargetPassword: "targetPassword",
+		},
+		{
+			name: "Valid: empty credentials",
+			envVars: []envVar{
+				{"ZDM_ORIGIN_USERNAME", ""}, {"ZDM_ORIGIN_PASSWORD", ""},
+				{"ZDM_TARGET_USERNAME", ""}, {"ZDM_TARGET_PASSWORD", ""}},
+		},
+		{
+			name: "Valid: target astra token",
+			envVars: []envVar{
+				{"ZDM_ORIGIN_USERNAME", "originUser"}, {"ZDM_ORIGIN_PASSWORD", "originPassword"},
+				{"ZDM_TARGET_ASTRA_TOKEN", "AstraCS:abc:123"}},
+			expectedOriginUsername: "originUser",
+			expectedOriginPassword: "originPassword",
+			expectedTargetUsername: "token",
+			expectedTargetPassword: "AstraCS:abc:123",
+		},
+		{
+			name: "Valid: origin astra token",
+			envVars: []envVar{
+				{"ZDM_ORIGIN_ASTRA_TOKEN", "AstraCS:def:456"},
+				{"ZDM_TARGET_USERNAME", "targetUser"}, {"ZDM_TARGET_PASSWORD", "targetPassword"}},
+			expectedOriginUsername: "token",
+			expectedOriginPassword: "AstraCS:def:456",
+			expectedTargetUsername: "targetUser",
+			expectedTargetPassword: "targetPassword",
+		},
+		{
+			name: "Invalid: missing target credentials",
+			envVars: []envVar{
+				{"ZDM_ORIGIN_USERNAME", "originUser"}, {"ZDM_ORIGIN_PASSWORD", "originPassword"},
+				{"ZDM_TARGET_USERNAME", "targetUser"}},
+			errExpected: true,
+			errMsg:      "could not load environment variables: required key ZDM_TARGET_PASSWORD missing value",
+		},
+		{
+			name: "Invalid: astra token and username",
+			envVars: []envVar{
+				{"ZDM_ORIGIN_USERNAME", "originUser"}, {"ZDM_ORIGIN_PASSWORD", "originPassword"},
+				{"ZDM_TARGET_USERNAME", "targetUser"}, {"ZDM_TARGET_ASTRA_TOKEN", "AstraCS:abc:123"}},
+			errExpected: true,
+			errMsg: "invalid target configuration: ZDM_TARGET_ASTRA_TOKEN can not be used with " +
+				"ZDM_TARGET_USERNAME or ZDM_TARGET_PASSWORD",
+		},
+		{
+			name: "Invalid: astra token prefix",
+			envVars: []envVar{
+				{"ZDM_ORIGIN_ASTRA_TOKEN", "abc:123"},
+				{"ZDM_TARGET_USERNAME", "targetUser"}, {"ZDM_TARGET_PASSWORD", "targetPassword"}},
+			errExpected: true,
+			errMsg:      "invalid origin configuration: ZDM_ORIGIN_ASTRA_TOKEN must start with AstraCS:",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Equal(t, tt.errMsg, err.Error())
+				return
+			}
+			require.Nil(t, err)
+
+			originUsername, originPassword, err := conf.ParseOriginCredentials()
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedOriginUsername, originUsername)
+			require.Equal(t, tt.expectedOriginPassword, originPassword)
+
+			targetUsername, targetPassword, err := conf.ParseTargetCredentials()
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedTargetUsername, targetUsername)
+			require.Equal(t, tt.expectedTargetPassword, targetPassword)
+		})
+	}
+}
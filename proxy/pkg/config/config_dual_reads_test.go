@@ -31,12 +31,19 @@ func TestConfig_ParseReadMode(t *testing.T) {
 			errExpected:      false,
 			errMsg:           "",
 		},
+		{
+			name:             "Valid: Hedged reads enabled",
+			envVars:          []envVar{{"ZDM_READ_MODE", "HEDGED"}},
+			expectedReadMode: common.ReadModeHedged,
+			errExpected:      false,
+			errMsg:           "",
+		},
 		{
 			name:             "Invalid: Dual reads enabled but async reads on secondary disabled",
 			envVars:          []envVar{{"ZDM_READ_MODE", "DUAL_SYNC"}},
 			expectedReadMode: common.ReadModeUndefined,
 			errExpected:      true,
-			errMsg:           "invalid value for ZDM_READ_MODE; possible values are: PRIMARY_ONLY and DUAL_ASYNC_ON_SECONDARY",
+			errMsg:           "invalid value for ZDM_READ_MODE; possible values are: PRIMARY_ONLY, DUAL_ASYNC_ON_SECONDARY and HEDGED",
 		},
 		{
 			name:             "Valid: Read mode unset",
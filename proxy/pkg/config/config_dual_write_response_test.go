@@ -0,0 +1,98 @@
+package config
+
+import (
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfig_ParseDualWriteResponsePolicy(t *testing.T) {
+
+	type test struct {
+		name           string
+		envVars        []envVar
+		expectedPolicy map[string]common.DualWriteResponsePolicy
+		errExpected    bool
+		errMsg         string
+	}
+
+	tests := []test{
+		{
+			name:    "Valid: disabled by default",
+			envVars: []envVar{},
+		},
+		{
+			name: "Valid: policies per statement type",
+			envVars: []envVar{
+				{"ZDM_DUAL_WRITE_RESPONSE_POLICY", "insert=either, BATCH=PRIMARY,DELETE = quorum"},
+			},
+			expectedPolicy: map[string]common.DualWriteResponsePolicy{
+				"INSERT": common.DualWriteResponsePolicyEither,
+				"BATCH":  common.DualWriteResponsePolicyPrimary,
+				"DELETE": common.DualWriteResponsePolicyQuorum,
+			},
+		},
+		{
+			name:    "Valid: default policy",
+			envVars: []envVar{{"ZDM_DUAL_WRITE_RESPONSE_POLICY", "DEFAULT=QUORUM,UPDATE=BOTH,"}},
+			expectedPolicy: map[string]common.DualWriteResponsePolicy{
+				"DEFAULT": common.DualWriteResponsePolicyQuorum,
+				"UPDATE":  common.DualWriteResponsePolicyBoth,
+			},
+		},
+		{
+			name:        "Invalid: missing policy",
+			envVars:     []envVar{{"ZDM_DUAL_WRITE_RESPONSE_POLICY", "INSERT"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_DUAL_WRITE_RESPONSE_POLICY: INSERT, expected <STATEMENT_TYPE>=<POLICY>",
+		},
+		{
+			name:        "Invalid: unknown statement type",
+			envVars:     []envVar{{"ZDM_DUAL_WRITE_RESPONSE_POLICY", "USE=EITHER"}},
+			errExpected: true,
+			errMsg: "invalid statement type in ZDM_DUAL_WRITE_RESPONSE_POLICY: USE; possible values are: " +
+				"INSERT, UPDATE, DELETE, BATCH and DEFAULT",
+		},
+		{
+			name:        "Invalid: duplicate statement type",
+			envVars:     []envVar{{"ZDM_DUAL_WRITE_RESPONSE_POLICY", "INSERT=EITHER,insert=BOTH"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_DUAL_WRITE_RESPONSE_POLICY: INSERT is listed more than once",
+		},
+		{
+			name:        "Invalid: unknown policy",
+			envVars:     []envVar{{"ZDM_DUAL_WRITE_RESPONSE_POLICY", "INSERT=ANY"}},
+			errExpected: true,
+			errMsg: "invalid policy for INSERT in ZDM_DUAL_WRITE_RESPONSE_POLICY; " +
+				"possible values are: BOTH, PRIMARY, EITHER and QUORUM",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				policy, err := conf.ParseDualWriteResponsePolicy()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedPolicy, policy)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfig_ParseEventsSource(t *testing.T) {
+
+	type test struct {
+		name           string
+		envVars        []envVar
+		expectedSource common.EventsSource
+		errExpected    bool
+		errMsg         string
+	}
+
+	tests := []test{
+		{
+			name:           "Valid: default",
+			envVars:        []envVar{},
+			expectedSource: common.EventsSourceDefault,
+		},
+		{
+			name:           "Valid: primary",
+			envVars:        []envVar{{"ZDM_EVENTS_SOURCE", "primary"}},
+			expectedSource: common.EventsSourcePrimary,
+		},
+		{
+			name:           "Valid: none",
+			envVars:        []envVar{{"ZDM_EVENTS_SOURCE", "NONE"}},
+			expectedSource: common.EventsSourceNone,
+		},
+		{
+			name:        "Invalid: unknown source",
+			envVars:     []envVar{{"ZDM_EVENTS_SOURCE", "BOTH"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_EVENTS_SOURCE; possible values are: DEFAULT, ORIGIN, TARGET, PRIMARY and NONE",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				source, err := conf.ParseEventsSource()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedSource, source)
+			}
+		})
+	}
+}
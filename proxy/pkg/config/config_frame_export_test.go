@@ -0,0 +1,70 @@
+package config
+
+import (
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfig_ParseFrameExportFormat(t *testing.T) {
+
+	type test struct {
+		name           string
+		envVars        []envVar
+		expectedFormat common.FrameExportFormat
+		errExpected    bool
+		errMsg         string
+	}
+
+	tests := []test{
+		{
+			name:           "Valid: pcap by default",
+			envVars:        []envVar{{"ZDM_FRAME_EXPORT_DIR", "/tmp"}},
+			expectedFormat: common.FrameExportFormatPcap,
+		},
+		{
+			name:           "Valid: hex",
+			envVars:        []envVar{{"ZDM_FRAME_EXPORT_DIR", "/tmp"}, {"ZDM_FRAME_EXPORT_FORMAT", "hex"}},
+			expectedFormat: common.FrameExportFormatHex,
+		},
+		{
+			name:           "Valid: pcap",
+			envVars:        []envVar{{"ZDM_FRAME_EXPORT_FORMAT", "PCAP"}},
+			expectedFormat: common.FrameExportFormatPcap,
+		},
+		{
+			name:        "Invalid: unknown format",
+			envVars:     []envVar{{"ZDM_FRAME_EXPORT_FORMAT", "pcapng"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_FRAME_EXPORT_FORMAT; possible values are: PCAP and HEX",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				format, err := conf.ParseFrameExportFormat()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedFormat, format)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfig_ParseGuardrailsConfig(t *testing.T) {
+
+	type test struct {
+		name           string
+		envVars        []envVar
+		expectedConfig *common.GuardrailsConfig
+		errExpected    bool
+		errMsg         string
+	}
+
+	tests := []test{
+		{
+			name:           "Valid: disabled by default",
+			envVars:        []envVar{},
+			expectedConfig: nil,
+		},
+		{
+			name: "Valid: all thresholds",
+			envVars: []envVar{
+				{"ZDM_GUARDRAIL_BATCH_SIZE_WARN_BYTES", "5120"},
+				{"ZDM_GUARDRAIL_BATCH_SIZE_FAIL_BYTES", "51200"},
+				{"ZDM_GUARDRAIL_BATCH_STATEMENTS_WARN", "50"},
+				{"ZDM_GUARDRAIL_BATCH_STATEMENTS_FAIL", "50"},
+				{"ZDM_GUARDRAIL_MUTATION_SIZE_WARN_BYTES", "1048576"},
+				{"ZDM_GUARDRAIL_MUTATION_SIZE_FAIL_BYTES", "16777216"},
+				{"ZDM_GUARDRAIL_CLIENT_WARNINGS_ENABLED", "true"},
+			},
+			expectedConfig: &common.GuardrailsConfig{
+				BatchSizeWarnBytes:    5120,
+				BatchSizeFailBytes:    51200,
+				BatchStatementsWarn:   50,
+				BatchStatementsFail:   50,
+				MutationSizeWarnBytes: 1048576,
+				MutationSizeFailBytes: 16777216,
+				ClientWarningsEnabled: true,
+			},
+		},
+		{
+			name:           "Valid: fail threshold only",
+			envVars:        []envVar{{"ZDM_GUARDRAIL_BATCH_STATEMENTS_FAIL", "100"}},
+			expectedConfig: &common.GuardrailsConfig{BatchStatementsFail: 100},
+		},
+		{
+			name: "Valid: query length and values size limits",
+			envVars: []envVar{
+				{"ZDM_GUARDRAIL_MAX_QUERY_LENGTH", "65536"},
+				{"ZDM_GUARDRAIL_MAX_VALUES_SIZE_BYTES", "1048576"},
+			},
+			expectedConfig: &common.GuardrailsConfig{MaxQueryLength: 65536, MaxValuesSizeBytes: 1048576},
+		},
+		{
+			name:        "Invalid: negative query length",
+			envVars:     []envVar{{"ZDM_GUARDRAIL_MAX_QUERY_LENGTH", "-1"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_GUARDRAIL_MAX_QUERY_LENGTH: -1, it must be positive or 0",
+		},
+		{
+			name:        "Invalid: negative values size",
+			envVars:     []envVar{{"ZDM_GUARDRAIL_MAX_VALUES_SIZE_BYTES", "-5"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_GUARDRAIL_MAX_VALUES_SIZE_BYTES: -5, it must be positive or 0",
+		},
+		{
+			name:        "Invalid: negative threshold",
+			envVars:     []envVar{{"ZDM_GUARDRAIL_MUTATION_SIZE_WARN_BYTES", "-1"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_GUARDRAIL_MUTATION_SIZE_WARN_BYTES: -1, it must be positive or 0",
+		},
+		{
+			name: "Invalid: fail threshold lower than warn threshold",
+			envVars: []envVar{
+				{"ZDM_GUARDRAIL_BATCH_SIZE_WARN_BYTES", "5120"},
+				{"ZDM_GUARDRAIL_BATCH_SIZE_FAIL_BYTES", "1024"},
+			},
+			errExpected: true,
+			errMsg: "invalid value for ZDM_GUARDRAIL_BATCH_SIZE_FAIL_BYTES: 1024, it must be greater than or equal to " +
+				"ZDM_GUARDRAIL_BATCH_SIZE_WARN_BYTES (5120)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				guardrailsConfig, err := conf.ParseGuardrailsConfig()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedConfig, guardrailsConfig)
+			}
+		})
+	}
+}
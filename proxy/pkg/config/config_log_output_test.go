@@ -0,0 +1,148 @@
+package config
+
+import (
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestConfig_ParseLogOutputConfig(t *testing.T) {
+
+	type test struct {
+		name           string
+		envVars        []envVar
+		expectedConfig *common.LogOutputConfig
+		errExpected    bool
+		errMsg         string
+	}
+
+	tests := []test{
+		{
+			name:           "Valid: standard error by default",
+			envVars:        []envVar{},
+			expectedConfig: &common.LogOutputConfig{Output: common.LogOutputStderr},
+		},
+		{
+			name: "Valid: file with default rotation",
+			envVars: []envVar{
+				{"ZDM_LOG_OUTPUT", "FILE"},
+				{"ZDM_LOG_FILE_PATH", "/var/log/zdm-proxy.log"},
+			},
+			expectedConfig: &common.LogOutputConfig{
+				Output:               common.LogOutputFile,
+				FilePath:             "/var/log/zdm-proxy.log",
+				FileMaxSizeBytes:     100 * 1024 * 1024,
+				FileRotationInterval: 24 * time.Hour,
+				FileMaxBackups:       7,
+			},
+		},
+		{
+			name: "Valid: file without rotation",
+			envVars: []envVar{
+				{"ZDM_LOG_OUTPUT", "file"},
+				{"ZDM_LOG_FILE_PATH", "zdm-proxy.log"},
+				{"ZDM_LOG_FILE_MAX_SIZE_MB", "0"},
+				{"ZDM_LOG_FILE_ROTATION_INTERVAL_HOURS", "0"},
+				{"ZDM_LOG_FILE_MAX_BACKUPS", "0"},
+			},
+			expectedConfig: &common.LogOutputConfig{Output: common.LogOutputFile, FilePath: "zdm-proxy.log"},
+		},
+		{
+			name:    "Valid: syslog with defaults",
+			envVars: []envVar{{"ZDM_LOG_OUTPUT", "SYSLOG"}},
+			expectedConfig: &common.LogOutputConfig{
+				Output:         common.LogOutputSyslog,
+				SyslogNetwork:  "unix",
+				SyslogAddress:  "/dev/log",
+				SyslogFacility: 16,
+				SyslogAppName:  "zdm-proxy",
+			},
+		},
+		{
+			name: "Valid: remote syslog",
+			envVars: []envVar{
+				{"ZDM_LOG_OUTPUT", "SYSLOG"},
+				{"ZDM_LOG_SYSLOG_ADDRESS", "tcp://syslog.example.com:601"},
+				{"ZDM_LOG_SYSLOG_FACILITY", "daemon"},
+				{"ZDM_LOG_SYSLOG_APP_NAME", "zdm-proxy-1"},
+			},
+			expectedConfig: &common.LogOutputConfig{
+				Output:         common.LogOutputSyslog,
+				SyslogNetwork:  "tcp",
+				SyslogAddress:  "syslog.example.com:601",
+				SyslogFacility: 3,
+				SyslogAppName:  "zdm-proxy-1",
+			},
+		},
+		{
+			name:        "Invalid: unknown output",
+			envVars:     []envVar{{"ZDM_LOG_OUTPUT", "JOURNALD"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_LOG_OUTPUT; possible values are: STDERR, FILE and SYSLOG",
+		},
+		{
+			name:        "Invalid: file without path",
+			envVars:     []envVar{{"ZDM_LOG_OUTPUT", "FILE"}},
+			errExpected: true,
+			errMsg:      "ZDM_LOG_FILE_PATH is required when ZDM_LOG_OUTPUT is FILE",
+		},
+		{
+			name: "Invalid: negative max size",
+			envVars: []envVar{
+				{"ZDM_LOG_OUTPUT", "FILE"},
+				{"ZDM_LOG_FILE_PATH", "zdm-proxy.log"},
+				{"ZDM_LOG_FILE_MAX_SIZE_MB", "-1"},
+			},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_LOG_FILE_MAX_SIZE_MB: -1, it must be positive or 0",
+		},
+		{
+			name: "Invalid: syslog address without port",
+			envVars: []envVar{
+				{"ZDM_LOG_OUTPUT", "SYSLOG"},
+				{"ZDM_LOG_SYSLOG_ADDRESS", "udp://syslog.example.com"},
+			},
+			errExpected: true,
+			errMsg: "invalid value for ZDM_LOG_SYSLOG_ADDRESS: udp://syslog.example.com, it must be a url like " +
+				"udp://<host>:<port>, tcp://<host>:<port> or unix://<socket path> (udp is missing the port)",
+		},
+		{
+			name: "Invalid: syslog facility",
+			envVars: []envVar{
+				{"ZDM_LOG_OUTPUT", "SYSLOG"},
+				{"ZDM_LOG_SYSLOG_FACILITY", "KERN"},
+			},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_LOG_SYSLOG_FACILITY: KERN; possible values are: USER, DAEMON and LOCAL0 to LOCAL7",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				logOutputConfig, err := conf.ParseLogOutputConfig()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedConfig, logOutputConfig)
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfig_ParseLogRedactionConfig(t *testing.T) {
+
+	type test struct {
+		name           string
+		envVars        []envVar
+		expectedConfig *common.LogRedactionConfig
+		errExpected    bool
+		errMsg         string
+	}
+
+	tests := []test{
+		{
+			name:           "Valid: disabled by default",
+			envVars:        []envVar{},
+			expectedConfig: nil,
+		},
+		{
+			name:           "Valid: ALL",
+			envVars:        []envVar{{"ZDM_LOG_REDACTION", "all"}},
+			expectedConfig: &common.LogRedactionConfig{Mode: common.LogRedactionModeAll},
+		},
+		{
+			name: "Valid: MATCHING",
+			envVars: []envVar{
+				{"ZDM_LOG_REDACTION", "MATCHING"},
+				{"ZDM_LOG_REDACTION_TABLES", "ks.Users, *.cards"},
+				{"ZDM_LOG_REDACTION_COLUMNS", "*_ssn"},
+			},
+			expectedConfig: &common.LogRedactionConfig{
+				Mode:    common.LogRedactionModeMatching,
+				Tables:  []string{"ks.users", "*.cards"},
+				Columns: []string{"*_ssn"},
+			},
+		},
+		{
+			name:        "Invalid: unknown mode",
+			envVars:     []envVar{{"ZDM_LOG_REDACTION", "SOME"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_LOG_REDACTION; possible values are: NONE, ALL and MATCHING",
+		},
+		{
+			name:        "Invalid: MATCHING without patterns",
+			envVars:     []envVar{{"ZDM_LOG_REDACTION", "MATCHING"}},
+			errExpected: true,
+			errMsg:      "ZDM_LOG_REDACTION is MATCHING but neither ZDM_LOG_REDACTION_TABLES nor ZDM_LOG_REDACTION_COLUMNS is set",
+		},
+		{
+			name:        "Invalid: patterns without MATCHING",
+			envVars:     []envVar{{"ZDM_LOG_REDACTION_COLUMNS", "email"}},
+			errExpected: true,
+			errMsg:      "ZDM_LOG_REDACTION_TABLES and ZDM_LOG_REDACTION_COLUMNS require ZDM_LOG_REDACTION to be MATCHING",
+		},
+		{
+			name: "Invalid: malformed pattern",
+			envVars: []envVar{
+				{"ZDM_LOG_REDACTION", "MATCHING"},
+				{"ZDM_LOG_REDACTION_TABLES", "ks.[users"},
+			},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_LOG_REDACTION_TABLES: ks.[users, ks.[users is not a valid pattern",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				logRedactionConfig, err := conf.ParseLogRedactionConfig()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedConfig, logRedactionConfig)
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfig_ParseLwtSerialConsistencies(t *testing.T) {
+
+	type test struct {
+		name                      string
+		envVars                   []envVar
+		expectedOriginConsistency primitive.ConsistencyLevel
+		expectedTargetConsistency primitive.ConsistencyLevel
+		errExpected               bool
+		errMsg                    string
+	}
+
+	tests := []test{
+		{
+			name:                      "Valid: not remapped by default",
+			envVars:                   []envVar{},
+			expectedOriginConsistency: primitive.ConsistencyLevelAny,
+			expectedTargetConsistency: primitive.ConsistencyLevelAny,
+		},
+		{
+			name:                      "Valid: target remapped",
+			envVars:                   []envVar{{"ZDM_TARGET_LWT_SERIAL_CONSISTENCY", "local_serial"}},
+			expectedOriginConsistency: primitive.ConsistencyLevelAny,
+			expectedTargetConsistency: primitive.ConsistencyLevelLocalSerial,
+		},
+		{
+			name: "Valid: both remapped",
+			envVars: []envVar{
+				{"ZDM_ORIGIN_LWT_SERIAL_CONSISTENCY", "SERIAL"},
+				{"ZDM_TARGET_LWT_SERIAL_CONSISTENCY", " LOCAL_SERIAL "},
+			},
+			expectedOriginConsistency: primitive.ConsistencyLevelSerial,
+			expectedTargetConsistency: primitive.ConsistencyLevelLocalSerial,
+		},
+		{
+			name:        "Invalid: not a serial consistency",
+			envVars:     []envVar{{"ZDM_ORIGIN_LWT_SERIAL_CONSISTENCY", "QUORUM"}},
+			errExpected: true,
+			errMsg: "invalid value for ZDM_ORIGIN_LWT_SERIAL_CONSISTENCY: QUORUM; " +
+				"possible values are: SERIAL and LOCAL_SERIAL",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				originConsistency, targetConsistency, err := conf.ParseLwtSerialConsistencies()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedOriginConsistency, originConsistency)
+				require.Equal(t, tt.expectedTargetConsistency, targetConsistency)
+			}
+		})
+	}
+}
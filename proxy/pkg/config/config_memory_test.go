@@ -0,0 +1,150 @@
+package config
+
+import (
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestConfig_ParseMemorySoftLimitBytes(t *testing.T) {
+
+	type test struct {
+		name          string
+		envVars       []envVar
+		expectedLimit uint64
+		errExpected   bool
+		errMsg        string
+	}
+
+	tests := []test{
+		{
+			name:          "Valid: default",
+			envVars:       []envVar{},
+			expectedLimit: 0,
+		},
+		{
+			name:          "Valid: soft limit",
+			envVars:       []envVar{{"ZDM_PROXY_MEMORY_SOFT_LIMIT_MB", "512"}},
+			expectedLimit: 512 * 1024 * 1024,
+		},
+		{
+			name: "Valid: check interval is ignored when disabled",
+			envVars: []envVar{
+				{"ZDM_PROXY_MEMORY_SOFT_LIMIT_MB", "0"}, {"ZDM_PROXY_MEMORY_CHECK_INTERVAL_MS", "0"}},
+			expectedLimit: 0,
+		},
+		{
+			name:        "Invalid: negative soft limit",
+			envVars:     []envVar{{"ZDM_PROXY_MEMORY_SOFT_LIMIT_MB", "-1"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_PROXY_MEMORY_SOFT_LIMIT_MB: -1, it must not be negative",
+		},
+		{
+			name: "Invalid: check interval",
+			envVars: []envVar{
+				{"ZDM_PROXY_MEMORY_SOFT_LIMIT_MB", "512"}, {"ZDM_PROXY_MEMORY_CHECK_INTERVAL_MS", "0"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_PROXY_MEMORY_CHECK_INTERVAL_MS: 0, it must be positive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				limit, err := conf.ParseMemorySoftLimitBytes()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedLimit, limit)
+			}
+		})
+	}
+}
+
+func TestConfig_ParseGoroutineAuditConfig(t *testing.T) {
+
+	type test struct {
+		name           string
+		envVars        []envVar
+		expectedConfig *common.GoroutineAuditConfig
+		errExpected    bool
+		errMsg         string
+	}
+
+	tests := []test{
+		{
+			name:           "Valid: default",
+			envVars:        []envVar{},
+			expectedConfig: &common.GoroutineAuditConfig{Interval: time.Minute, LeakGracePeriod: 30 * time.Second},
+		},
+		{
+			name: "Valid: custom",
+			envVars: []envVar{
+				{"ZDM_PROXY_GOROUTINE_AUDIT_INTERVAL_MS", "5000"}, {"ZDM_PROXY_GOROUTINE_LEAK_GRACE_PERIOD_MS", "0"}},
+			expectedConfig: &common.GoroutineAuditConfig{Interval: 5 * time.Second},
+		},
+		{
+			name:           "Valid: disabled",
+			envVars:        []envVar{{"ZDM_PROXY_GOROUTINE_AUDIT_INTERVAL_MS", "0"}},
+			expectedConfig: &common.GoroutineAuditConfig{LeakGracePeriod: 30 * time.Second},
+		},
+		{
+			name:        "Invalid: negative interval",
+			envVars:     []envVar{{"ZDM_PROXY_GOROUTINE_AUDIT_INTERVAL_MS", "-1"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_PROXY_GOROUTINE_AUDIT_INTERVAL_MS: -1, it must not be negative",
+		},
+		{
+			name:        "Invalid: negative grace period",
+			envVars:     []envVar{{"ZDM_PROXY_GOROUTINE_LEAK_GRACE_PERIOD_MS", "-1"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_PROXY_GOROUTINE_LEAK_GRACE_PERIOD_MS: -1, it must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				auditConfig, err := conf.ParseGoroutineAuditConfig()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedConfig, auditConfig)
+				require.Equal(t, tt.expectedConfig.Interval > 0, auditConfig.Enabled())
+			}
+		})
+	}
+}
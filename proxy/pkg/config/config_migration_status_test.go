@@ -0,0 +1,78 @@
+package config
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfig_ParseMigrationStatusTable(t *testing.T) {
+
+	type test struct {
+		name          string
+		envVars       []envVar
+		expectedTable string
+		errExpected   bool
+		errMsg        string
+	}
+
+	tests := []test{
+		{
+			name:          "Valid: default",
+			envVars:       []envVar{},
+			expectedTable: "",
+		},
+		{
+			name:          "Valid: status table",
+			envVars:       []envVar{{"ZDM_MIGRATION_STATUS_TABLE", "zdm.migration_status"}},
+			expectedTable: "zdm.migration_status",
+		},
+		{
+			name: "Valid: refresh interval is ignored when disabled",
+			envVars: []envVar{
+				{"ZDM_MIGRATION_STATUS_TABLE", ""}, {"ZDM_MIGRATION_STATUS_REFRESH_INTERVAL_MS", "0"}},
+			expectedTable: "",
+		},
+		{
+			name:        "Invalid: table without keyspace",
+			envVars:     []envVar{{"ZDM_MIGRATION_STATUS_TABLE", "migration_status"}},
+			errExpected: true,
+			errMsg: "invalid value for ZDM_MIGRATION_STATUS_TABLE: migration_status, " +
+				"it must be a keyspace qualified table name (keyspace.table)",
+		},
+		{
+			name: "Invalid: refresh interval",
+			envVars: []envVar{
+				{"ZDM_MIGRATION_STATUS_TABLE", "zdm.migration_status"}, {"ZDM_MIGRATION_STATUS_REFRESH_INTERVAL_MS", "0"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_MIGRATION_STATUS_REFRESH_INTERVAL_MS: 0, it must be positive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				table, err := conf.ParseMigrationStatusTable()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedTable, table)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfig_ParseMutationExportKafkaBrokers(t *testing.T) {
+
+	type test struct {
+		name            string
+		envVars         []envVar
+		expectedBrokers []string
+		errExpected     bool
+		errMsg          string
+	}
+
+	tests := []test{
+		{
+			name:            "Valid: export disabled",
+			envVars:         []envVar{},
+			expectedBrokers: nil,
+		},
+		{
+			name: "Valid: multiple brokers",
+			envVars: []envVar{
+				{"ZDM_MUTATION_EXPORT_KAFKA_BROKERS", "kafka1:9092, kafka2:9092"},
+				{"ZDM_MUTATION_EXPORT_KAFKA_TOPIC", "mutations"},
+			},
+			expectedBrokers: []string{"kafka1:9092", "kafka2:9092"},
+		},
+		{
+			name:        "Invalid: topic not set",
+			envVars:     []envVar{{"ZDM_MUTATION_EXPORT_KAFKA_BROKERS", "kafka1:9092"}},
+			errExpected: true,
+			errMsg:      "ZDM_MUTATION_EXPORT_KAFKA_BROKERS is set but ZDM_MUTATION_EXPORT_KAFKA_TOPIC is not",
+		},
+		{
+			name: "Invalid: empty broker list",
+			envVars: []envVar{
+				{"ZDM_MUTATION_EXPORT_KAFKA_BROKERS", " , "},
+				{"ZDM_MUTATION_EXPORT_KAFKA_TOPIC", "mutations"},
+			},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_MUTATION_EXPORT_KAFKA_BROKERS",
+		},
+		{
+			name: "Invalid: queue size",
+			envVars: []envVar{
+				{"ZDM_MUTATION_EXPORT_KAFKA_BROKERS", "kafka1:9092"},
+				{"ZDM_MUTATION_EXPORT_KAFKA_TOPIC", "mutations"},
+				{"ZDM_MUTATION_EXPORT_QUEUE_SIZE", "0"},
+			},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_MUTATION_EXPORT_QUEUE_SIZE: 0, it must be positive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				brokers, err := conf.ParseMutationExportKafkaBrokers()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedBrokers, brokers)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfig_ParsePageSizeOverride(t *testing.T) {
+
+	type test struct {
+		name                    string
+		envVars                 []envVar
+		expectedMaxPageSize     int
+		expectedDefaultPageSize int
+		errExpected             bool
+		errMsg                  string
+	}
+
+	tests := []test{
+		{
+			name:    "Valid: disabled by default",
+			envVars: []envVar{},
+		},
+		{
+			name: "Valid: maximum and default page sizes",
+			envVars: []envVar{
+				{"ZDM_PAGE_SIZE_MAX", "1000"},
+				{"ZDM_PAGE_SIZE_DEFAULT", "500"},
+			},
+			expectedMaxPageSize:     1000,
+			expectedDefaultPageSize: 500,
+		},
+		{
+			name:                    "Valid: default page size only",
+			envVars:                 []envVar{{"ZDM_PAGE_SIZE_DEFAULT", "5000"}},
+			expectedDefaultPageSize: 5000,
+		},
+		{
+			name:        "Invalid: negative maximum page size",
+			envVars:     []envVar{{"ZDM_PAGE_SIZE_MAX", "-1"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_PAGE_SIZE_MAX: -1, it must be positive or 0",
+		},
+		{
+			name:        "Invalid: negative default page size",
+			envVars:     []envVar{{"ZDM_PAGE_SIZE_DEFAULT", "-100"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_PAGE_SIZE_DEFAULT: -100, it must be positive or 0",
+		},
+		{
+			name: "Invalid: default page size greater than maximum page size",
+			envVars: []envVar{
+				{"ZDM_PAGE_SIZE_MAX", "1000"},
+				{"ZDM_PAGE_SIZE_DEFAULT", "5000"},
+			},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_PAGE_SIZE_DEFAULT: 5000, it must be lower than or equal to ZDM_PAGE_SIZE_MAX (1000)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				maxPageSize, defaultPageSize, err := conf.ParsePageSizeOverride()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedMaxPageSize, maxPageSize)
+				require.Equal(t, tt.expectedDefaultPageSize, defaultPageSize)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfig_ParsePayloadSizeMetricsBuckets(t *testing.T) {
+
+	type test struct {
+		name            string
+		envVars         []envVar
+		expectedBuckets []float64
+		errExpected     bool
+		errMsg          string
+	}
+
+	tests := []test{
+		{
+			name:            "Valid: disabled by default",
+			envVars:         []envVar{},
+			expectedBuckets: nil,
+		},
+		{
+			name:            "Valid: default buckets",
+			envVars:         []envVar{{"ZDM_PAYLOAD_SIZE_METRICS_ENABLED", "true"}},
+			expectedBuckets: []float64{64, 256, 1024, 4096, 16384, 65536, 262144, 1048576, 4194304, 16777216},
+		},
+		{
+			name: "Valid: custom buckets",
+			envVars: []envVar{
+				{"ZDM_PAYLOAD_SIZE_METRICS_ENABLED", "true"},
+				{"ZDM_PAYLOAD_SIZE_METRICS_BUCKETS_BYTES", "100, 1000,10000"}},
+			expectedBuckets: []float64{100, 1000, 10000},
+		},
+		{
+			name: "Valid: invalid buckets are ignored when disabled",
+			envVars: []envVar{
+				{"ZDM_PAYLOAD_SIZE_METRICS_BUCKETS_BYTES", "abc"}},
+			expectedBuckets: nil,
+		},
+		{
+			name: "Invalid: not a number",
+			envVars: []envVar{
+				{"ZDM_PAYLOAD_SIZE_METRICS_ENABLED", "true"},
+				{"ZDM_PAYLOAD_SIZE_METRICS_BUCKETS_BYTES", "100, abc"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_PAYLOAD_SIZE_METRICS_BUCKETS_BYTES: unable to parse buckets from 100, abc",
+		},
+		{
+			name: "Invalid: not in increasing order",
+			envVars: []envVar{
+				{"ZDM_PAYLOAD_SIZE_METRICS_ENABLED", "true"},
+				{"ZDM_PAYLOAD_SIZE_METRICS_BUCKETS_BYTES", "1000, 100"}},
+			errExpected: true,
+			errMsg: "invalid value for ZDM_PAYLOAD_SIZE_METRICS_BUCKETS_BYTES: 1000, 100, " +
+				"the buckets must be positive and in increasing order",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				buckets, err := conf.ParsePayloadSizeMetricsBuckets()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedBuckets, buckets)
+			}
+		})
+	}
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfig_ParsePeerPortMapping(t *testing.T) {
+
+	type test struct {
+		name                  string
+		envVars               []envVar
+		expectedOriginMapping map[string]int
+		expectedTargetMapping map[string]int
+		errExpected           bool
+		errMsg                string
+	}
+
+	tests := []test{
+		{
+			name:    "Valid: no override",
+			envVars: []envVar{},
+		},
+		{
+			name: "Valid: peer port mapping",
+			envVars: []envVar{
+				{"ZDM_ORIGIN_PEER_PORT_MAPPING", "10.0.0.2=19042, 10.0.0.3 = 19043,"},
+				{"ZDM_TARGET_PEER_PORT_MAPPING", "[::2]=29042"},
+			},
+			expectedOriginMapping: map[string]int{"10.0.0.2": 19042, "10.0.0.3": 19043},
+			expectedTargetMapping: map[string]int{"::2": 29042},
+		},
+		{
+			name: "Valid: contact point ports",
+			envVars: []envVar{
+				{"ZDM_ORIGIN_CONTACT_POINTS", "10.0.0.1:19042,10.0.0.2:7890,10.0.0.3,cassandra.local:19044"},
+				{"ZDM_TARGET_CONTACT_POINTS", "[::1]:19045,::2"},
+			},
+			expectedOriginMapping: map[string]int{"10.0.0.1": 19042},
+			expectedTargetMapping: map[string]int{"::1": 19045},
+		},
+		{
+			name: "Valid: peer port mapping takes precedence over the contact point port",
+			envVars: []envVar{
+				{"ZDM_ORIGIN_CONTACT_POINTS", "10.0.0.1:19042,10.0.0.2:19043"},
+				{"ZDM_ORIGIN_PEER_PORT_MAPPING", "10.0.0.1=29042"},
+			},
+			expectedOriginMapping: map[string]int{"10.0.0.1": 29042, "10.0.0.2": 19043},
+		},
+		{
+			name:        "Invalid: contact point port",
+			envVars:     []envVar{{"ZDM_ORIGIN_CONTACT_POINTS", "10.0.0.1:abc"}},
+			errExpected: true,
+			errMsg:      "invalid origin configuration: invalid port in contact point 10.0.0.1:abc",
+		},
+		{
+			name:        "Invalid: missing port",
+			envVars:     []envVar{{"ZDM_TARGET_PEER_PORT_MAPPING", "10.0.0.1"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_TARGET_PEER_PORT_MAPPING: 10.0.0.1, expected <ADDRESS>=<PORT>",
+		},
+		{
+			name:        "Invalid: hostname",
+			envVars:     []envVar{{"ZDM_ORIGIN_PEER_PORT_MAPPING", "cassandra.local=19042"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_ORIGIN_PEER_PORT_MAPPING: cassandra.local is not an IP address",
+		},
+		{
+			name:        "Invalid: port out of range",
+			envVars:     []envVar{{"ZDM_ORIGIN_PEER_PORT_MAPPING", "10.0.0.1=70000"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_ORIGIN_PEER_PORT_MAPPING: 70000 is not a valid port",
+		},
+		{
+			name:        "Invalid: duplicate address",
+			envVars:     []envVar{{"ZDM_ORIGIN_PEER_PORT_MAPPING", "10.0.0.1=19042,10.0.0.1=19043"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_ORIGIN_PEER_PORT_MAPPING: 10.0.0.1 is listed more than once",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Equal(t, tt.errMsg, err.Error())
+				return
+			}
+			require.Nil(t, err)
+			originMapping, err := conf.ParseOriginPeerPortMapping()
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedOriginMapping, originMapping)
+			targetMapping, err := conf.ParseTargetPeerPortMapping()
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedTargetMapping, targetMapping)
+		})
+	}
+}
+
+func TestSplitContactPoint(t *testing.T) {
+	tests := []struct {
+		contactPoint string
+		expectedHost string
+		expectedPort int
+	}{
+		{"10.0.0.1", "10.0.0.1", 9042},
+		{"10.0.0.1:19042", "10.0.0.1", 19042},
+		{"cassandra.local", "cassandra.local", 9042},
+		{"cassandra.local:19042", "cassandra.local", 19042},
+		{"::1", "::1", 9042},
+		{"[::1]:19042", "::1", 19042},
+	}
+	for _, tt := range tests {
+		t.Run(tt.contactPoint, func(t *testing.T) {
+			host, port, err := SplitContactPoint(tt.contactPoint, 9042)
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedHost, host)
+			require.Equal(t, tt.expectedPort, port)
+		})
+	}
+}
@@ -0,0 +1,75 @@
+package config
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfig_ParseMaxClientProtocolVersion(t *testing.T) {
+
+	type test struct {
+		name            string
+		envVars         []envVar
+		expectedVersion primitive.ProtocolVersion
+		errExpected     bool
+		errMsg          string
+	}
+
+	tests := []test{
+		{
+			name:            "Valid: not pinned by default",
+			envVars:         []envVar{},
+			expectedVersion: 0,
+		},
+		{
+			name:            "Valid: v3",
+			envVars:         []envVar{{"ZDM_MAX_CLIENT_PROTOCOL_VERSION", "3"}},
+			expectedVersion: primitive.ProtocolVersion3,
+		},
+		{
+			name:            "Valid: v4 with prefix",
+			envVars:         []envVar{{"ZDM_MAX_CLIENT_PROTOCOL_VERSION", "v4"}},
+			expectedVersion: primitive.ProtocolVersion4,
+		},
+		{
+			name:            "Valid: DSE_V1",
+			envVars:         []envVar{{"ZDM_MAX_CLIENT_PROTOCOL_VERSION", "dse_v1"}},
+			expectedVersion: primitive.ProtocolVersionDse1,
+		},
+		{
+			name:        "Invalid: v5",
+			envVars:     []envVar{{"ZDM_MAX_CLIENT_PROTOCOL_VERSION", "5"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_MAX_CLIENT_PROTOCOL_VERSION; possible values are: 2, 3, 4, DSE_V1 and DSE_V2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				version, err := conf.ParseMaxClientProtocolVersion()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedVersion, version)
+			}
+		})
+	}
+}
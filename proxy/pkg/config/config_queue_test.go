@@ -0,0 +1,70 @@
+package config
+
+import (
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfig_ParseRequestWriteQueueOverflowPolicy(t *testing.T) {
+
+	type test struct {
+		name           string
+		envVars        []envVar
+		expectedPolicy common.QueueOverflowPolicy
+		errExpected    bool
+		errMsg         string
+	}
+
+	tests := []test{
+		{
+			name:           "Valid: default",
+			envVars:        []envVar{},
+			expectedPolicy: common.QueueOverflowPolicyBlock,
+		},
+		{
+			name:           "Valid: shed",
+			envVars:        []envVar{{"ZDM_REQUEST_WRITE_QUEUE_OVERFLOW_POLICY", "shed"}},
+			expectedPolicy: common.QueueOverflowPolicyShed,
+		},
+		{
+			name:           "Valid: prefer reads",
+			envVars:        []envVar{{"ZDM_REQUEST_WRITE_QUEUE_OVERFLOW_POLICY", "PREFER_READS"}},
+			expectedPolicy: common.QueueOverflowPolicyPreferReads,
+		},
+		{
+			name:        "Invalid: unknown policy",
+			envVars:     []envVar{{"ZDM_REQUEST_WRITE_QUEUE_OVERFLOW_POLICY", "DROP"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_REQUEST_WRITE_QUEUE_OVERFLOW_POLICY; possible values are: BLOCK, SHED and PREFER_READS",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				policy, err := conf.ParseRequestWriteQueueOverflowPolicy()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedPolicy, policy)
+			}
+		})
+	}
+}
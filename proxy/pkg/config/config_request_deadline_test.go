@@ -0,0 +1,24 @@
+package config
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestConfig_ParseProxyRequestDeadline(t *testing.T) {
+	conf := New()
+	deadline, err := conf.ParseProxyRequestDeadline()
+	require.Nil(t, err)
+	require.Equal(t, time.Duration(0), deadline)
+
+	conf.ProxyRequestDeadlineMs = 12000
+	deadline, err = conf.ParseProxyRequestDeadline()
+	require.Nil(t, err)
+	require.Equal(t, 12*time.Second, deadline)
+
+	conf.ProxyRequestDeadlineMs = -1
+	_, err = conf.ParseProxyRequestDeadline()
+	require.NotNil(t, err)
+	require.Equal(t, "invalid value for ZDM_PROXY_REQUEST_DEADLINE_MS: -1, it must not be negative", err.Error())
+}
@@ -0,0 +1,72 @@
+package config
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfig_ParseSchemaCheckKeyspaces(t *testing.T) {
+
+	type test struct {
+		name              string
+		envVars           []envVar
+		expectedKeyspaces []string
+		errExpected       bool
+		errMsg            string
+	}
+
+	tests := []test{
+		{
+			name:              "Valid: default",
+			envVars:           []envVar{},
+			expectedKeyspaces: nil,
+		},
+		{
+			name:              "Valid: keyspaces",
+			envVars:           []envVar{{"ZDM_SCHEMA_CHECK_ENABLED", "true"}, {"ZDM_SCHEMA_CHECK_KEYSPACES", "ks1, ks2"}},
+			expectedKeyspaces: []string{"ks1", "ks2"},
+		},
+		{
+			name:        "Invalid: empty keyspace",
+			envVars:     []envVar{{"ZDM_SCHEMA_CHECK_KEYSPACES", "ks1,,ks2"}},
+			errExpected: true,
+			errMsg: "invalid value for ZDM_SCHEMA_CHECK_KEYSPACES: ks1,,ks2, " +
+				"expected a comma separated list of keyspace names",
+		},
+		{
+			name:        "Invalid: table name",
+			envVars:     []envVar{{"ZDM_SCHEMA_CHECK_KEYSPACES", "ks1.tbl"}},
+			errExpected: true,
+			errMsg: "invalid value for ZDM_SCHEMA_CHECK_KEYSPACES: ks1.tbl, " +
+				"expected a comma separated list of keyspace names",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				keyspaces, err := conf.ParseSchemaCheckKeyspaces()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedKeyspaces, keyspaces)
+			}
+		})
+	}
+}
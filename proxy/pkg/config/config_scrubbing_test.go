@@ -0,0 +1,72 @@
+package config
+
+import (
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfig_ParseErrorMessageScrubbing(t *testing.T) {
+
+	type test struct {
+		name            string
+		envVars         []envVar
+		expectedDetails []common.ScrubbedErrorDetail
+		errExpected     bool
+		errMsg          string
+	}
+
+	tests := []test{
+		{
+			name:            "Valid: default",
+			envVars:         []envVar{},
+			expectedDetails: nil,
+		},
+		{
+			name:            "Valid: node addresses",
+			envVars:         []envVar{{"ZDM_ERROR_MESSAGE_SCRUBBING", "node_addresses"}},
+			expectedDetails: []common.ScrubbedErrorDetail{common.ScrubbedErrorDetailNodeAddresses},
+		},
+		{
+			name:    "Valid: node addresses and cluster names",
+			envVars: []envVar{{"ZDM_ERROR_MESSAGE_SCRUBBING", "NODE_ADDRESSES, CLUSTER_NAMES"}},
+			expectedDetails: []common.ScrubbedErrorDetail{
+				common.ScrubbedErrorDetailNodeAddresses, common.ScrubbedErrorDetailClusterNames},
+		},
+		{
+			name:        "Invalid: unknown detail",
+			envVars:     []envVar{{"ZDM_ERROR_MESSAGE_SCRUBBING", "NODE_ADDRESSES,HOST_IDS"}},
+			errExpected: true,
+			errMsg: "invalid value for ZDM_ERROR_MESSAGE_SCRUBBING: HOST_IDS; " +
+				"possible values are: NODE_ADDRESSES and CLUSTER_NAMES",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				details, err := conf.ParseErrorMessageScrubbing()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedDetails, details)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfig_ParseSecondaryErrorPolicy(t *testing.T) {
+
+	type test struct {
+		name           string
+		envVars        []envVar
+		expectedPolicy map[string]common.SecondaryErrorAction
+		errExpected    bool
+		errMsg         string
+	}
+
+	tests := []test{
+		{
+			name:    "Valid: disabled by default",
+			envVars: []envVar{},
+		},
+		{
+			name: "Valid: actions per error code",
+			envVars: []envVar{
+				{"ZDM_SECONDARY_ERROR_POLICY", "unavailable=warning, WRITE_TIMEOUT=METRIC,OVERLOADED = ERROR"},
+			},
+			expectedPolicy: map[string]common.SecondaryErrorAction{
+				"UNAVAILABLE":   common.SecondaryErrorActionWarning,
+				"WRITE_TIMEOUT": common.SecondaryErrorActionMetric,
+				"OVERLOADED":    common.SecondaryErrorActionError,
+			},
+		},
+		{
+			name:    "Valid: default action",
+			envVars: []envVar{{"ZDM_SECONDARY_ERROR_POLICY", "DEFAULT=WARNING,INVALID=ERROR,"}},
+			expectedPolicy: map[string]common.SecondaryErrorAction{
+				"DEFAULT": common.SecondaryErrorActionWarning,
+				"INVALID": common.SecondaryErrorActionError,
+			},
+		},
+		{
+			name:        "Invalid: missing action",
+			envVars:     []envVar{{"ZDM_SECONDARY_ERROR_POLICY", "UNAVAILABLE"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_SECONDARY_ERROR_POLICY: UNAVAILABLE, expected <ERROR_CODE>=<ACTION>",
+		},
+		{
+			name:        "Invalid: unknown error code",
+			envVars:     []envVar{{"ZDM_SECONDARY_ERROR_POLICY", "TIMEOUT=METRIC"}},
+			errExpected: true,
+			errMsg:      "invalid error code in ZDM_SECONDARY_ERROR_POLICY: TIMEOUT; possible values are: SERVER_ERROR, ",
+		},
+		{
+			name:        "Invalid: unprepared",
+			envVars:     []envVar{{"ZDM_SECONDARY_ERROR_POLICY", "UNPREPARED=METRIC"}},
+			errExpected: true,
+			errMsg:      "UNPREPARED errors are always returned to the client",
+		},
+		{
+			name:        "Invalid: duplicate error code",
+			envVars:     []envVar{{"ZDM_SECONDARY_ERROR_POLICY", "UNAVAILABLE=METRIC,unavailable=WARNING"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_SECONDARY_ERROR_POLICY: UNAVAILABLE is listed more than once",
+		},
+		{
+			name:        "Invalid: unknown action",
+			envVars:     []envVar{{"ZDM_SECONDARY_ERROR_POLICY", "UNAVAILABLE=IGNORE"}},
+			errExpected: true,
+			errMsg: "invalid action for UNAVAILABLE in ZDM_SECONDARY_ERROR_POLICY; " +
+				"possible values are: ERROR, WARNING and METRIC",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				policy, err := conf.ParseSecondaryErrorPolicy()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedPolicy, policy)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfig_ParseSharedConfig(t *testing.T) {
+
+	type test struct {
+		name              string
+		envVars           []envVar
+		expectedBackend   common.SharedConfigBackend
+		expectedEndpoints []string
+		errExpected       bool
+		errMsg            string
+	}
+
+	tests := []test{
+		{
+			name:            "Valid: shared configuration disabled",
+			envVars:         []envVar{},
+			expectedBackend: common.SharedConfigBackendNone,
+		},
+		{
+			name: "Valid: etcd",
+			envVars: []envVar{
+				{"ZDM_SHARED_CONFIG_BACKEND", "etcd"},
+				{"ZDM_SHARED_CONFIG_ENDPOINTS", "http://10.0.0.1:2379, https://10.0.0.2:2379"},
+			},
+			expectedBackend:   common.SharedConfigBackendEtcd,
+			expectedEndpoints: []string{"http://10.0.0.1:2379", "https://10.0.0.2:2379"},
+		},
+		{
+			name: "Valid: Consul",
+			envVars: []envVar{
+				{"ZDM_SHARED_CONFIG_BACKEND", "CONSUL"},
+				{"ZDM_SHARED_CONFIG_ENDPOINTS", "http://consul:8500"},
+			},
+			expectedBackend:   common.SharedConfigBackendConsul,
+			expectedEndpoints: []string{"http://consul:8500"},
+		},
+		{
+			name:        "Invalid: unknown backend",
+			envVars:     []envVar{{"ZDM_SHARED_CONFIG_BACKEND", "ZOOKEEPER"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_SHARED_CONFIG_BACKEND; possible values are: NONE, ETCD and CONSUL",
+		},
+		{
+			name:        "Invalid: no endpoints",
+			envVars:     []envVar{{"ZDM_SHARED_CONFIG_BACKEND", "ETCD"}},
+			errExpected: true,
+			errMsg:      "ZDM_SHARED_CONFIG_BACKEND is ETCD but ZDM_SHARED_CONFIG_ENDPOINTS is empty",
+		},
+		{
+			name: "Invalid: endpoint without scheme",
+			envVars: []envVar{
+				{"ZDM_SHARED_CONFIG_BACKEND", "ETCD"},
+				{"ZDM_SHARED_CONFIG_ENDPOINTS", "10.0.0.1:2379"},
+			},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_SHARED_CONFIG_ENDPOINTS: 10.0.0.1:2379, it must be a list of http or https urls",
+		},
+		{
+			name: "Invalid: empty prefix",
+			envVars: []envVar{
+				{"ZDM_SHARED_CONFIG_BACKEND", "CONSUL"},
+				{"ZDM_SHARED_CONFIG_ENDPOINTS", "http://consul:8500"},
+				{"ZDM_SHARED_CONFIG_PREFIX", " "},
+			},
+			errExpected: true,
+			errMsg:      "ZDM_SHARED_CONFIG_BACKEND is CONSUL but ZDM_SHARED_CONFIG_PREFIX is empty",
+		},
+		{
+			name: "Invalid: leader TTL below the minimum session TTL of Consul",
+			envVars: []envVar{
+				{"ZDM_SHARED_CONFIG_BACKEND", "CONSUL"},
+				{"ZDM_SHARED_CONFIG_ENDPOINTS", "http://consul:8500"},
+				{"ZDM_SHARED_CONFIG_LEADER_TTL_MS", "5000"},
+			},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_SHARED_CONFIG_LEADER_TTL_MS: 5000, it must be at least 10000",
+		},
+		{
+			name: "Invalid: global rate limit bucket under the prefix of the shared settings",
+			envVars: []envVar{
+				{"ZDM_SHARED_CONFIG_BACKEND", "ETCD"},
+				{"ZDM_SHARED_CONFIG_ENDPOINTS", "http://10.0.0.1:2379"},
+				{"ZDM_PROXY_GLOBAL_REQUEST_RATE_LIMIT_KEY", "zdm-proxy/bucket"},
+			},
+			errExpected: true,
+			errMsg: "invalid value for ZDM_PROXY_GLOBAL_REQUEST_RATE_LIMIT_KEY: zdm-proxy/bucket, " +
+				"it must not be under ZDM_SHARED_CONFIG_PREFIX (zdm-proxy/)",
+		},
+		{
+			name:        "Invalid: global rate limit without shared configuration",
+			envVars:     []envVar{{"ZDM_PROXY_GLOBAL_REQUEST_RATE_LIMIT", "1000"}},
+			errExpected: true,
+			errMsg: "ZDM_PROXY_GLOBAL_REQUEST_RATE_LIMIT is 1000 but ZDM_SHARED_CONFIG_BACKEND is NONE, " +
+				"the global limit requires a shared configuration backend",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				backend, err := conf.ParseSharedConfigBackend()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedBackend, backend)
+				endpoints, err := conf.ParseSharedConfigEndpoints()
+				require.Nil(t, err)
+				var endpointStrings []string
+				for _, endpoint := range endpoints {
+					endpointStrings = append(endpointStrings, endpoint.String())
+				}
+				require.Equal(t, tt.expectedEndpoints, endpointStrings)
+			}
+		})
+	}
+}
+
+func TestConfig_ParseProxyRequestRateLimit(t *testing.T) {
+	conf := New()
+	conf.ProxyRequestRateLimit = 1000
+	rateLimit, err := conf.ParseProxyRequestRateLimit()
+	require.Nil(t, err)
+	require.Equal(t, 1000, rateLimit)
+
+	conf.ProxyRequestRateLimit = -5
+	_, err = conf.ParseProxyRequestRateLimit()
+	require.NotNil(t, err)
+	require.Equal(t, "invalid value for ZDM_PROXY_REQUEST_RATE_LIMIT: -5, it must not be negative", err.Error())
+}
+
+func TestConfig_ParseProxyGlobalRequestRateLimit(t *testing.T) {
+	conf := New()
+	conf.SharedConfigBackend = "CONSUL"
+	conf.ProxyGlobalRequestRateLimit = 20000
+	rateLimit, err := conf.ParseProxyGlobalRequestRateLimit()
+	require.Nil(t, err)
+	require.Equal(t, 20000, rateLimit)
+
+	conf.ProxyGlobalRequestRateLimit = -1
+	_, err = conf.ParseProxyGlobalRequestRateLimit()
+	require.NotNil(t, err)
+	require.Equal(t, "invalid value for ZDM_PROXY_GLOBAL_REQUEST_RATE_LIMIT: -1, it must not be negative", err.Error())
+}
@@ -0,0 +1,65 @@
+package config
+
+import (
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfig_ParseStartupPolicy(t *testing.T) {
+
+	type test struct {
+		name           string
+		envVars        []envVar
+		expectedPolicy common.StartupPolicy
+		errExpected    bool
+		errMsg         string
+	}
+
+	tests := []test{
+		{
+			name:           "Valid: delay the listener by default",
+			envVars:        []envVar{},
+			expectedPolicy: common.StartupPolicyDelayListener,
+		},
+		{
+			name:           "Valid: refuse connections",
+			envVars:        []envVar{{"ZDM_STARTUP_POLICY", "refuse_connections"}},
+			expectedPolicy: common.StartupPolicyRefuseConnections,
+		},
+		{
+			name:        "Invalid: unknown policy",
+			envVars:     []envVar{{"ZDM_STARTUP_POLICY", "WAIT"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_STARTUP_POLICY; possible values are: DELAY_LISTENER and REFUSE_CONNECTIONS",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				policy, err := conf.ParseStartupPolicy()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedPolicy, policy)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package config
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfig_ParseStartupStrippedOptions(t *testing.T) {
+
+	type test struct {
+		name            string
+		envVars         []envVar
+		expectedOptions []string
+		errExpected     bool
+		errMsg          string
+	}
+
+	tests := []test{
+		{
+			name:            "Valid: no stripped options",
+			envVars:         []envVar{},
+			expectedOptions: nil,
+		},
+		{
+			name:            "Valid: multiple options",
+			envVars:         []envVar{{"ZDM_STARTUP_STRIPPED_OPTIONS", "compression, THROW_ON_OVERLOAD,,DRIVER_*"}},
+			expectedOptions: []string{"COMPRESSION", "THROW_ON_OVERLOAD", "DRIVER_*"},
+		},
+		{
+			name:        "Invalid: CQL_VERSION",
+			envVars:     []envVar{{"ZDM_STARTUP_STRIPPED_OPTIONS", "COMPRESSION,cql_version"}},
+			errExpected: true,
+			errMsg:      "CQL_VERSION is a mandatory STARTUP option",
+		},
+		{
+			name:        "Invalid: prefix that matches CQL_VERSION",
+			envVars:     []envVar{{"ZDM_STARTUP_STRIPPED_OPTIONS", "CQL*"}},
+			errExpected: true,
+			errMsg:      "CQL_VERSION is a mandatory STARTUP option",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				options, err := conf.ParseStartupStrippedOptions()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedOptions, options)
+			}
+		})
+	}
+}
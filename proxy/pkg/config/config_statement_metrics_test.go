@@ -0,0 +1,64 @@
+package config
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfig_ParseStatementMetricsMaxFingerprints(t *testing.T) {
+
+	type test struct {
+		name         string
+		envVars      []envVar
+		expectedSize int
+		errExpected  bool
+		errMsg       string
+	}
+
+	tests := []test{
+		{
+			name:         "Valid: disabled by default",
+			envVars:      []envVar{},
+			expectedSize: 0,
+		},
+		{
+			name:         "Valid: size",
+			envVars:      []envVar{{"ZDM_STATEMENT_METRICS_MAX_FINGERPRINTS", "200"}},
+			expectedSize: 200,
+		},
+		{
+			name:        "Invalid: negative",
+			envVars:     []envVar{{"ZDM_STATEMENT_METRICS_MAX_FINGERPRINTS", "-1"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_STATEMENT_METRICS_MAX_FINGERPRINTS: -1, it must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				size, err := conf.ParseStatementMetricsMaxFingerprints()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedSize, size)
+			}
+		})
+	}
+}
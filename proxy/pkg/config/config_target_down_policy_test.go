@@ -0,0 +1,103 @@
+package config
+
+import (
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestConfig_ParseTargetDownPolicy(t *testing.T) {
+
+	type test struct {
+		name           string
+		envVars        []envVar
+		expectedPolicy common.TargetDownPolicy
+		errExpected    bool
+		errMsg         string
+	}
+
+	tests := []test{
+		{
+			name:           "Valid: fail by default",
+			envVars:        []envVar{},
+			expectedPolicy: common.TargetDownPolicyFail,
+		},
+		{
+			name: "Valid: origin only",
+			envVars: []envVar{
+				{"ZDM_TARGET_DOWN_POLICY", "origin_only"},
+				{"ZDM_ORIGIN_ONLY_JOURNAL_FILE", "/var/lib/zdm/target.journal"},
+			},
+			expectedPolicy: common.TargetDownPolicyOriginOnly,
+		},
+		{
+			name:        "Invalid: origin only without journal file",
+			envVars:     []envVar{{"ZDM_TARGET_DOWN_POLICY", "ORIGIN_ONLY"}},
+			errExpected: true,
+			errMsg:      "ZDM_ORIGIN_ONLY_JOURNAL_FILE must be set when ZDM_TARGET_DOWN_POLICY is ORIGIN_ONLY",
+		},
+		{
+			name:        "Invalid: unknown policy",
+			envVars:     []envVar{{"ZDM_TARGET_DOWN_POLICY", "IGNORE"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_TARGET_DOWN_POLICY; possible values are: FAIL and ORIGIN_ONLY",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				policy, err := conf.ParseTargetDownPolicy()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedPolicy, policy)
+			}
+		})
+	}
+}
+
+func TestConfig_ParseOriginOnlyJournalRetention(t *testing.T) {
+	conf := New()
+	conf.OriginOnlyJournalCompactionIntervalMs = 60000
+	retention, err := conf.ParseOriginOnlyJournalRetention()
+	require.Nil(t, err)
+	require.False(t, retention.Enabled())
+
+	conf.OriginOnlyJournalMaxSizeMb = 512
+	conf.OriginOnlyJournalMaxAgeHours = 72
+	retention, err = conf.ParseOriginOnlyJournalRetention()
+	require.Nil(t, err)
+	require.Equal(t, &common.JournalRetention{
+		MaxSizeBytes:       512 * 1024 * 1024,
+		MaxAge:             72 * time.Hour,
+		CompactionInterval: time.Minute,
+	}, retention)
+
+	conf.OriginOnlyJournalCompactionIntervalMs = 0
+	_, err = conf.ParseOriginOnlyJournalRetention()
+	require.NotNil(t, err)
+	require.Equal(t, "invalid value for ZDM_ORIGIN_ONLY_JOURNAL_COMPACTION_INTERVAL_MS: 0, it must be positive", err.Error())
+
+	conf.OriginOnlyJournalMaxSizeMb = -1
+	_, err = conf.ParseOriginOnlyJournalRetention()
+	require.NotNil(t, err)
+	require.Equal(t, "invalid value for ZDM_ORIGIN_ONLY_JOURNAL_MAX_SIZE_MB: -1, it must not be negative", err.Error())
+}
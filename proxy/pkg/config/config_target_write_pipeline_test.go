@@ -0,0 +1,107 @@
+package config
+
+import (
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfig_ParseTargetWritePipeline(t *testing.T) {
+
+	type test struct {
+		name             string
+		envVars          []envVar
+		expectedPipeline common.TargetWritePipeline
+		expectedBrokers  []string
+		errExpected      bool
+		errMsg           string
+	}
+
+	kafkaEnvVars := []envVar{
+		{"ZDM_TARGET_WRITE_PIPELINE", "kafka"},
+		{"ZDM_TARGET_WRITE_PIPELINE_KAFKA_BROKERS", "kafka1:9092, kafka2:9092"},
+		{"ZDM_TARGET_WRITE_PIPELINE_KAFKA_TOPIC", "target-writes"},
+	}
+
+	tests := []test{
+		{
+			name:             "Valid: direct by default",
+			envVars:          []envVar{},
+			expectedPipeline: common.TargetWritePipelineDirect,
+		},
+		{
+			name:             "Valid: kafka",
+			envVars:          kafkaEnvVars,
+			expectedPipeline: common.TargetWritePipelineKafka,
+			expectedBrokers:  []string{"kafka1:9092", "kafka2:9092"},
+		},
+		{
+			name:        "Invalid: kafka without brokers",
+			envVars:     []envVar{{"ZDM_TARGET_WRITE_PIPELINE", "KAFKA"}, {"ZDM_TARGET_WRITE_PIPELINE_KAFKA_TOPIC", "writes"}},
+			errExpected: true,
+			errMsg:      "ZDM_TARGET_WRITE_PIPELINE_KAFKA_BROKERS must be set when ZDM_TARGET_WRITE_PIPELINE is KAFKA",
+		},
+		{
+			name:        "Invalid: kafka without topic",
+			envVars:     []envVar{{"ZDM_TARGET_WRITE_PIPELINE", "KAFKA"}, {"ZDM_TARGET_WRITE_PIPELINE_KAFKA_BROKERS", "kafka1:9092"}},
+			errExpected: true,
+			errMsg:      "ZDM_TARGET_WRITE_PIPELINE_KAFKA_TOPIC must be set when ZDM_TARGET_WRITE_PIPELINE is KAFKA",
+		},
+		{
+			name:        "Invalid: kafka without consumer group",
+			envVars:     append([]envVar{{"ZDM_TARGET_WRITE_PIPELINE_KAFKA_CONSUMER_GROUP", ""}}, kafkaEnvVars...),
+			errExpected: true,
+			errMsg:      "ZDM_TARGET_WRITE_PIPELINE_KAFKA_CONSUMER_GROUP must be set when ZDM_TARGET_WRITE_PIPELINE is KAFKA",
+		},
+		{
+			name: "Invalid: kafka with origin only target down policy",
+			envVars: append([]envVar{
+				{"ZDM_TARGET_DOWN_POLICY", "ORIGIN_ONLY"},
+				{"ZDM_ORIGIN_ONLY_JOURNAL_FILE", "/var/lib/zdm/target.journal"},
+			}, kafkaEnvVars...),
+			errExpected: true,
+			errMsg:      "ZDM_TARGET_DOWN_POLICY can't be ORIGIN_ONLY when ZDM_TARGET_WRITE_PIPELINE is KAFKA",
+		},
+		{
+			name:        "Invalid: negative dedup window size",
+			envVars:     append([]envVar{{"ZDM_TARGET_WRITE_PIPELINE_DEDUP_WINDOW_SIZE", "-1"}}, kafkaEnvVars...),
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_TARGET_WRITE_PIPELINE_DEDUP_WINDOW_SIZE: -1, it must be positive or 0",
+		},
+		{
+			name:        "Invalid: unknown pipeline",
+			envVars:     []envVar{{"ZDM_TARGET_WRITE_PIPELINE", "PULSAR"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_TARGET_WRITE_PIPELINE; possible values are: DIRECT and KAFKA",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				pipeline, err := conf.ParseTargetWritePipeline()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedPipeline, pipeline)
+				require.Equal(t, tt.expectedBrokers, conf.ParseTargetWritePipelineKafkaBrokers())
+			}
+		})
+	}
+}
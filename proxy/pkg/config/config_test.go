@@ -93,3 +93,41 @@ func TestTargetConfig_WithHostnameButWithoutPort(t *testing.T) {
 	require.Nil(t, err)
 	require.Equal(t, 9042, c.TargetPort)
 }
+
+func TestTargetConfig_Sigv4WithoutTls(t *testing.T) {
+	defer clearAllEnvVars()
+
+	// general setup
+	clearAllEnvVars()
+	setOriginCredentialsEnvVars()
+	setTargetCredentialsEnvVars()
+	setOriginContactPointsAndPortEnvVars()
+	setTargetContactPointsAndPortEnvVars()
+
+	// test-specific setup
+	setEnvVar("ZDM_TARGET_SIGV4_REGION", "us-east-1")
+
+	_, err := New().ParseEnvVars()
+	require.Error(t, err, "invalid target configuration: ZDM_TARGET_SIGV4_REGION is set but TLS is not configured for Target")
+}
+
+func TestTargetConfig_Sigv4WithTls(t *testing.T) {
+	defer clearAllEnvVars()
+
+	// general setup
+	clearAllEnvVars()
+	setOriginCredentialsEnvVars()
+	setTargetCredentialsEnvVars()
+	setOriginContactPointsAndPortEnvVars()
+	setTargetContactPointsAndPortEnvVars()
+
+	// test-specific setup
+	setEnvVar("ZDM_TARGET_SIGV4_REGION", "us-east-1")
+	setEnvVar("ZDM_TARGET_SIGV4_SESSION_TOKEN", "token")
+	setEnvVar("ZDM_TARGET_TLS_SERVER_CA_PATH", "/path/to/sf-class2-root.crt")
+
+	conf, err := New().ParseEnvVars()
+	require.Nil(t, err)
+	require.Equal(t, "us-east-1", conf.TargetSigv4Region)
+	require.Equal(t, "token", conf.TargetSigv4SessionToken)
+}
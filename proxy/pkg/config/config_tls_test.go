@@ -1,6 +1,7 @@
 package config
 
 import (
+	"crypto/tls"
 	"github.com/datastax/zdm-proxy/proxy/pkg/common"
 	"github.com/stretchr/testify/require"
 	"testing"
@@ -171,6 +172,19 @@ func TestOriginConfig_ClusterTlsConfig(t *testing.T) {
 			errExpected:    true,
 			errMsg:         "incomplete TLS configuration for Origin: when using mutual TLS, please specify Server CA path, Client Cert path and Client Key path",
 		},
+		{name: "CRL without custom TLS config",
+			needsContactPoints: true,
+			envVars: []envVar{
+				{"ZDM_ORIGIN_TLS_CRL_PATH", "/path/to/origin/crl"},
+			},
+			tlsEnabled:     false,
+			serverCaPath:   "",
+			clientCertPath: "",
+			clientKeyPath:  "",
+			scbPath:        "",
+			errExpected:    true,
+			errMsg:         "incorrect TLS configuration for Origin: ZDM_ORIGIN_TLS_CRL_PATH can only be used with ZDM_ORIGIN_TLS_SERVER_CA_PATH",
+		},
 	}
 
 	for _, tt := range tests {
@@ -415,3 +429,97 @@ func TestTargetConfig_ClusterTlsConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestConfig_TlsProtocolOptions(t *testing.T) {
+
+	tests := []struct {
+		name                 string
+		envVars              []envVar
+		expectedMinVersion   uint16
+		expectedCipherSuites []uint16
+		errExpected          bool
+		errMsg               string
+	}{
+		{
+			name:                 "defaults",
+			envVars:              []envVar{},
+			expectedMinVersion:   0,
+			expectedCipherSuites: nil,
+		},
+		{
+			name: "min version and cipher suites",
+			envVars: []envVar{
+				{"ZDM_ORIGIN_TLS_MIN_VERSION", "tls1.2"},
+				{"ZDM_ORIGIN_TLS_CIPHER_SUITES", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"},
+				{"ZDM_TARGET_TLS_MIN_VERSION", "TLS1.2"},
+				{"ZDM_TARGET_TLS_CIPHER_SUITES", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"},
+				{"ZDM_PROXY_TLS_MIN_VERSION", "TLS1.2"},
+				{"ZDM_PROXY_TLS_CIPHER_SUITES", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384"},
+			},
+			expectedMinVersion:   tls.VersionTLS12,
+			expectedCipherSuites: []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384},
+		},
+		{
+			name:        "invalid min version",
+			envVars:     []envVar{{"ZDM_PROXY_TLS_MIN_VERSION", "SSL3"}},
+			errExpected: true,
+			errMsg: "invalid value for ZDM_PROXY_TLS_MIN_VERSION; " +
+				"possible values are: TLS1.0, TLS1.1, TLS1.2 and TLS1.3",
+		},
+		{
+			name:        "unknown cipher suite",
+			envVars:     []envVar{{"ZDM_ORIGIN_TLS_CIPHER_SUITES", "TLS_UNKNOWN"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_ORIGIN_TLS_CIPHER_SUITES; TLS_UNKNOWN is not a supported cipher suite",
+		},
+		{
+			name:        "TLS 1.3 cipher suite",
+			envVars:     []envVar{{"ZDM_TARGET_TLS_CIPHER_SUITES", "TLS_AES_128_GCM_SHA256"}},
+			errExpected: true,
+			errMsg: "invalid value for ZDM_TARGET_TLS_CIPHER_SUITES; " +
+				"TLS_AES_128_GCM_SHA256 is a TLS 1.3 cipher suite and those can not be configured",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+			setEnvVar("ZDM_ORIGIN_TLS_SERVER_CA_PATH", "/path/to/origin/server/ca")
+			setEnvVar("ZDM_TARGET_TLS_SERVER_CA_PATH", "/path/to/target/server/ca")
+			setEnvVar("ZDM_PROXY_TLS_CA_PATH", "/path/to/proxy/ca")
+			setEnvVar("ZDM_PROXY_TLS_CERT_PATH", "/path/to/proxy/cert")
+			setEnvVar("ZDM_PROXY_TLS_KEY_PATH", "/path/to/proxy/key")
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+				return
+			}
+			require.Nil(t, err)
+
+			originTlsConf, err := conf.ParseOriginTlsConfig(false)
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedMinVersion, originTlsConf.MinVersion)
+			require.Equal(t, tt.expectedCipherSuites, originTlsConf.CipherSuites)
+
+			targetTlsConf, err := conf.ParseTargetTlsConfig(false)
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedMinVersion, targetTlsConf.MinVersion)
+			require.Equal(t, tt.expectedCipherSuites, targetTlsConf.CipherSuites)
+
+			proxyTlsConf, err := conf.ParseProxyTlsConfig(false)
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedMinVersion, proxyTlsConf.MinVersion)
+			require.Equal(t, tt.expectedCipherSuites, proxyTlsConf.CipherSuites)
+		})
+	}
+}
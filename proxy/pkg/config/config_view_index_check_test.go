@@ -0,0 +1,78 @@
+package config
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestConfig_ParseViewIndexCheckRefreshInterval(t *testing.T) {
+
+	type test struct {
+		name             string
+		envVars          []envVar
+		expectedInterval time.Duration
+		errExpected      bool
+		errMsg           string
+	}
+
+	tests := []test{
+		{
+			name:             "Valid: default",
+			envVars:          []envVar{},
+			expectedInterval: 0,
+		},
+		{
+			name:             "Valid: enabled",
+			envVars:          []envVar{{"ZDM_VIEW_INDEX_CHECK_ENABLED", "true"}},
+			expectedInterval: 5 * time.Minute,
+		},
+		{
+			name: "Valid: refresh interval",
+			envVars: []envVar{
+				{"ZDM_VIEW_INDEX_CHECK_ENABLED", "true"}, {"ZDM_VIEW_INDEX_CHECK_REFRESH_INTERVAL_MS", "1000"}},
+			expectedInterval: time.Second,
+		},
+		{
+			name: "Valid: refresh interval is ignored when disabled",
+			envVars: []envVar{
+				{"ZDM_VIEW_INDEX_CHECK_ENABLED", "false"}, {"ZDM_VIEW_INDEX_CHECK_REFRESH_INTERVAL_MS", "0"}},
+			expectedInterval: 0,
+		},
+		{
+			name: "Invalid: refresh interval",
+			envVars: []envVar{
+				{"ZDM_VIEW_INDEX_CHECK_ENABLED", "true"}, {"ZDM_VIEW_INDEX_CHECK_REFRESH_INTERVAL_MS", "0"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_VIEW_INDEX_CHECK_REFRESH_INTERVAL_MS: 0, it must be positive",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				interval, err := conf.ParseViewIndexCheckRefreshInterval()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedInterval, interval)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfig_ParseTargetWriteSamplingPercent(t *testing.T) {
+
+	type test struct {
+		name            string
+		envVars         []envVar
+		expectedPercent float64
+		errExpected     bool
+		errMsg          string
+	}
+
+	tests := []test{
+		{
+			name:            "Valid: disabled",
+			envVars:         []envVar{{"ZDM_TARGET_WRITE_SAMPLING_PERCENT", "10"}},
+			expectedPercent: 100,
+		},
+		{
+			name:            "Valid: default percentage",
+			envVars:         []envVar{{"ZDM_TARGET_WRITE_SAMPLING_ENABLED", "true"}},
+			expectedPercent: 100,
+		},
+		{
+			name:            "Valid: fraction of a percent",
+			envVars:         []envVar{{"ZDM_TARGET_WRITE_SAMPLING_ENABLED", "true"}, {"ZDM_TARGET_WRITE_SAMPLING_PERCENT", "0.5"}},
+			expectedPercent: 0.5,
+		},
+		{
+			name:            "Valid: no writes",
+			envVars:         []envVar{{"ZDM_TARGET_WRITE_SAMPLING_ENABLED", "true"}, {"ZDM_TARGET_WRITE_SAMPLING_PERCENT", "0"}},
+			expectedPercent: 0,
+		},
+		{
+			name:        "Invalid: above 100",
+			envVars:     []envVar{{"ZDM_TARGET_WRITE_SAMPLING_ENABLED", "true"}, {"ZDM_TARGET_WRITE_SAMPLING_PERCENT", "150"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_TARGET_WRITE_SAMPLING_PERCENT: 150, it must be between 0 and 100",
+		},
+		{
+			name:        "Invalid: negative",
+			envVars:     []envVar{{"ZDM_TARGET_WRITE_SAMPLING_ENABLED", "true"}, {"ZDM_TARGET_WRITE_SAMPLING_PERCENT", "-1"}},
+			errExpected: true,
+			errMsg:      "invalid value for ZDM_TARGET_WRITE_SAMPLING_PERCENT: -1, it must be between 0 and 100",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+
+			// set other general env vars
+			setOriginCredentialsEnvVars()
+			setTargetCredentialsEnvVars()
+			setOriginContactPointsAndPortEnvVars()
+			setTargetContactPointsAndPortEnvVars()
+
+			// set test-specific env vars
+			for _, envVar := range tt.envVars {
+				setEnvVar(envVar.vName, envVar.vValue)
+			}
+
+			conf, err := New().ParseEnvVars()
+			if tt.errExpected {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				percent, err := conf.ParseTargetWriteSamplingPercent()
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedPercent, percent)
+			}
+		})
+	}
+}
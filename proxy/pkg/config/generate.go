@@ -0,0 +1,151 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Scenario is a common deployment of the proxy for which a configuration can be generated with GenerateEnvFile.
+type Scenario struct {
+	Name        string
+	Description string
+	Settings    []*ScenarioSetting
+}
+
+// ScenarioSetting is an environment variable of a Scenario, it is prompted by the generate-config command unless it is
+// provided with a flag. Settings with KeepEmpty are written even without a value, e.g. the credentials which must be
+// set (possibly empty) for clusters that don't use an Astra token.
+type ScenarioSetting struct {
+	EnvVar    string
+	Prompt    string
+	Default   string
+	Required  bool
+	KeepEmpty bool
+}
+
+func originSettings(description string, defaultPort string) []*ScenarioSetting {
+	return []*ScenarioSetting{
+		{EnvVar: "ZDM_ORIGIN_CONTACT_POINTS", Prompt: fmt.Sprintf("Contact points of the %v cluster (comma separated)", description), Required: true},
+		{EnvVar: "ZDM_ORIGIN_PORT", Prompt: "CQL port of the ORIGIN cluster", Default: defaultPort, Required: true},
+		{EnvVar: "ZDM_ORIGIN_LOCAL_DATACENTER", Prompt: "Local datacenter of the ORIGIN cluster (empty to use the datacenter of the contact points)"},
+		{EnvVar: "ZDM_ORIGIN_USERNAME", Prompt: "Username for the ORIGIN cluster (empty if authentication is disabled)", KeepEmpty: true},
+		{EnvVar: "ZDM_ORIGIN_PASSWORD", Prompt: "Password for the ORIGIN cluster", KeepEmpty: true},
+	}
+}
+
+func proxySettings() []*ScenarioSetting {
+	return []*ScenarioSetting{
+		{EnvVar: "ZDM_PROXY_LISTEN_ADDRESS", Prompt: "Address the proxy listens on", Default: "0.0.0.0", Required: true},
+		{EnvVar: "ZDM_PROXY_LISTEN_PORT", Prompt: "Port the proxy listens on", Default: "9042", Required: true},
+		{EnvVar: "ZDM_PROXY_TOPOLOGY_ADDRESSES", Prompt: "Addresses of all the proxy instances (comma separated, empty for a single instance)"},
+		{EnvVar: "ZDM_PROXY_TOPOLOGY_INDEX", Prompt: "Index of this instance in the proxy addresses", Default: "0", Required: true},
+		{EnvVar: "ZDM_PRIMARY_CLUSTER", Prompt: "Cluster that serves the reads (ORIGIN or TARGET)", Default: PrimaryClusterOrigin, Required: true},
+		{EnvVar: "ZDM_READ_MODE", Prompt: "Read mode (PRIMARY_ONLY or DUAL_ASYNC_ON_SECONDARY)", Default: ReadModePrimaryOnly, Required: true},
+	}
+}
+
+// Scenarios are the deployments supported by the generate-config command.
+var Scenarios = []*Scenario{
+	{
+		Name:        "cassandra-to-astra",
+		Description: "self-managed Apache Cassandra or DSE cluster to Astra DB",
+		Settings: append(append(originSettings("self-managed ORIGIN", "9042"),
+			&ScenarioSetting{EnvVar: "ZDM_TARGET_SECURE_CONNECT_BUNDLE_PATH", Prompt: "Path of the secure connect bundle of the Astra database", Required: true},
+			&ScenarioSetting{EnvVar: "ZDM_TARGET_ASTRA_TOKEN", Prompt: "Astra application token (AstraCS:...)", Required: true}),
+			proxySettings()...),
+	},
+	{
+		Name:        "dse-to-cassandra",
+		Description: "DSE cluster to self-managed Apache Cassandra cluster",
+		Settings: append(append(originSettings("DSE ORIGIN", "9042"),
+			&ScenarioSetting{EnvVar: "ZDM_TARGET_CONTACT_POINTS", Prompt: "Contact points of the Apache Cassandra TARGET cluster (comma separated)", Required: true},
+			&ScenarioSetting{EnvVar: "ZDM_TARGET_PORT", Prompt: "CQL port of the TARGET cluster", Default: "9042", Required: true},
+			&ScenarioSetting{EnvVar: "ZDM_TARGET_LOCAL_DATACENTER", Prompt: "Local datacenter of the TARGET cluster (empty to use the datacenter of the contact points)"},
+			&ScenarioSetting{EnvVar: "ZDM_TARGET_USERNAME", Prompt: "Username for the TARGET cluster (empty if authentication is disabled)", KeepEmpty: true},
+			&ScenarioSetting{EnvVar: "ZDM_TARGET_PASSWORD", Prompt: "Password for the TARGET cluster", KeepEmpty: true}),
+			proxySettings()...),
+	},
+}
+
+// FindScenario returns the scenario with the given name or nil if there is no such scenario.
+func FindScenario(name string) *Scenario {
+	for _, scenario := range Scenarios {
+		if scenario.Name == name {
+			return scenario
+		}
+	}
+	return nil
+}
+
+// GenerateEnvFile returns an environment file (ZDM_*=value lines, as read by docker --env-file or systemd) with the
+// values of the settings of the scenario, settings without a value use their default and are omitted if they don't
+// have one. The generated configuration is validated with ValidateEnvVars.
+func GenerateEnvFile(scenario *Scenario, values map[string]string) (string, error) {
+	envVars := make(map[string]string)
+	for _, setting := range scenario.Settings {
+		value, ok := values[setting.EnvVar]
+		if !ok || value == "" {
+			value = setting.Default
+		}
+		if value == "" && !setting.KeepEmpty {
+			if setting.Required {
+				return "", fmt.Errorf("%v is required", setting.EnvVar)
+			}
+			continue
+		}
+		if strings.ContainsAny(value, "\n\r") {
+			return "", fmt.Errorf("invalid value for %v: it must not contain line breaks", setting.EnvVar)
+		}
+		envVars[setting.EnvVar] = value
+	}
+
+	err := ValidateEnvVars(envVars)
+	if err != nil {
+		return "", fmt.Errorf("the generated configuration is invalid: %w", err)
+	}
+
+	sb := &strings.Builder{}
+	sb.WriteString(fmt.Sprintf("# ZDM proxy configuration for the %v scenario (%v)\n", scenario.Name, scenario.Description))
+	sb.WriteString("# generated by zdm-proxy generate-config\n")
+	for _, setting := range scenario.Settings {
+		if value, ok := envVars[setting.EnvVar]; ok {
+			sb.WriteString(fmt.Sprintf("%v=%v\n", setting.EnvVar, value))
+		}
+	}
+	return sb.String(), nil
+}
+
+// ValidateEnvVars parses and validates the configuration made of the given environment variables (and the defaults
+// of the other settings) like the proxy does at startup. The ZDM_* variables of the process are replaced while the
+// configuration is parsed so this function must not be called concurrently with anything that reads them.
+func ValidateEnvVars(envVars map[string]string) error {
+	previous := make(map[string]string)
+	for _, env := range os.Environ() {
+		if name, value, found := strings.Cut(env, "="); found && strings.HasPrefix(name, "ZDM_") {
+			previous[name] = value
+			_ = os.Unsetenv(name)
+		}
+	}
+	names := make([]string, 0, len(envVars))
+	for name := range envVars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		_ = os.Setenv(name, envVars[name])
+	}
+
+	defer func() {
+		for _, name := range names {
+			_ = os.Unsetenv(name)
+		}
+		for name, value := range previous {
+			_ = os.Setenv(name, value)
+		}
+	}()
+
+	_, err := New().ParseEnvVars()
+	return err
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"github.com/stretchr/testify/require"
+	"os"
+	"testing"
+)
+
+func TestGenerateEnvFile(t *testing.T) {
+	tests := []struct {
+		name            string
+		scenario        string
+		values          map[string]string
+		expectedEnvFile string
+		errMsg          string
+	}{
+		{
+			name:     "dse to cassandra with defaults",
+			scenario: "dse-to-cassandra",
+			values: map[string]string{
+				"ZDM_ORIGIN_CONTACT_POINTS": "10.0.0.1,10.0.0.2",
+				"ZDM_ORIGIN_USERNAME":       "cassandra",
+				"ZDM_ORIGIN_PASSWORD":       "cassandra",
+				"ZDM_TARGET_CONTACT_POINTS": "10.1.0.1",
+				"ZDM_READ_MODE":             "DUAL_ASYNC_ON_SECONDARY",
+			},
+			expectedEnvFile: "# ZDM proxy configuration for the dse-to-cassandra scenario " +
+				"(DSE cluster to self-managed Apache Cassandra cluster)\n" +
+				"# generated by zdm-proxy generate-config\n" +
+				"ZDM_ORIGIN_CONTACT_POINTS=10.0.0.1,10.0.0.2\n" +
+				"ZDM_ORIGIN_PORT=9042\n" +
+				"ZDM_ORIGIN_USERNAME=cassandra\n" +
+				"ZDM_ORIGIN_PASSWORD=cassandra\n" +
+				"ZDM_TARGET_CONTACT_POINTS=10.1.0.1\n" +
+				"ZDM_TARGET_PORT=9042\n" +
+				"ZDM_TARGET_USERNAME=\n" +
+				"ZDM_TARGET_PASSWORD=\n" +
+				"ZDM_PROXY_LISTEN_ADDRESS=0.0.0.0\n" +
+				"ZDM_PROXY_LISTEN_PORT=9042\n" +
+				"ZDM_PROXY_TOPOLOGY_INDEX=0\n" +
+				"ZDM_PRIMARY_CLUSTER=ORIGIN\n" +
+				"ZDM_READ_MODE=DUAL_ASYNC_ON_SECONDARY\n",
+		},
+		{
+			name:     "cassandra to astra",
+			scenario: "cassandra-to-astra",
+			values: map[string]string{
+				"ZDM_ORIGIN_CONTACT_POINTS":             "10.0.0.1",
+				"ZDM_TARGET_SECURE_CONNECT_BUNDLE_PATH": "/secure-connect-db.zip",
+				"ZDM_TARGET_ASTRA_TOKEN":                "AstraCS:token",
+				"ZDM_PROXY_TOPOLOGY_ADDRESSES":          "10.2.0.1,10.2.0.2",
+				"ZDM_PROXY_TOPOLOGY_INDEX":              "1",
+			},
+			expectedEnvFile: "# ZDM proxy configuration for the cassandra-to-astra scenario " +
+				"(self-managed Apache Cassandra or DSE cluster to Astra DB)\n" +
+				"# generated by zdm-proxy generate-config\n" +
+				"ZDM_ORIGIN_CONTACT_POINTS=10.0.0.1\n" +
+				"ZDM_ORIGIN_PORT=9042\n" +
+				"ZDM_ORIGIN_USERNAME=\n" +
+				"ZDM_ORIGIN_PASSWORD=\n" +
+				"ZDM_TARGET_SECURE_CONNECT_BUNDLE_PATH=/secure-connect-db.zip\n" +
+				"ZDM_TARGET_ASTRA_TOKEN=AstraCS:token\n" +
+				"ZDM_PROXY_LISTEN_ADDRESS=0.0.0.0\n" +
+				"ZDM_PROXY_LISTEN_PORT=9042\n" +
+				"ZDM_PROXY_TOPOLOGY_ADDRESSES=10.2.0.1,10.2.0.2\n" +
+				"ZDM_PROXY_TOPOLOGY_INDEX=1\n" +
+				"ZDM_PRIMARY_CLUSTER=ORIGIN\n" +
+				"ZDM_READ_MODE=PRIMARY_ONLY\n",
+		},
+		{
+			name:     "missing required setting",
+			scenario: "cassandra-to-astra",
+			values:   map[string]string{"ZDM_ORIGIN_CONTACT_POINTS": "10.0.0.1"},
+			errMsg:   "ZDM_TARGET_SECURE_CONNECT_BUNDLE_PATH is required",
+		},
+		{
+			name:     "invalid configuration",
+			scenario: "dse-to-cassandra",
+			values: map[string]string{
+				"ZDM_ORIGIN_CONTACT_POINTS": "10.0.0.1",
+				"ZDM_TARGET_CONTACT_POINTS": "10.1.0.1",
+				"ZDM_PROXY_TOPOLOGY_INDEX":  "3",
+			},
+			errMsg: "the generated configuration is invalid",
+		},
+		{
+			name:     "line break",
+			scenario: "dse-to-cassandra",
+			values: map[string]string{
+				"ZDM_ORIGIN_CONTACT_POINTS": "10.0.0.1\nZDM_LOG_LEVEL=TRACE",
+				"ZDM_TARGET_CONTACT_POINTS": "10.1.0.1",
+			},
+			errMsg: "invalid value for ZDM_ORIGIN_CONTACT_POINTS: it must not contain line breaks",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clearAllEnvVars()
+			setEnvVar("ZDM_LOG_LEVEL", "DEBUG")
+
+			scenario := FindScenario(tt.scenario)
+			require.NotNil(t, scenario)
+			envFile, err := GenerateEnvFile(scenario, tt.values)
+			if tt.errMsg != "" {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedEnvFile, envFile)
+			}
+
+			// the environment of the process is restored
+			require.Equal(t, []string{"ZDM_LOG_LEVEL=DEBUG"}, os.Environ())
+		})
+	}
+}
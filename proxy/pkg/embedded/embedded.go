@@ -0,0 +1,119 @@
+// Package embedded runs the ZDM proxy inside another Go process, e.g. a test harness or a custom supervisor.
+//
+// The config is not read from the environment, use config.New().ParseEnvVars() or fill out the config.Config struct
+// directly. The proxy metrics are registered in the default prometheus registry so only one proxy with metrics enabled
+// can run in the same process at a time.
+package embedded
+
+import (
+	"context"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"github.com/datastax/zdm-proxy/proxy/pkg/health"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	"github.com/datastax/zdm-proxy/proxy/pkg/zdmproxy"
+	"github.com/jpillora/backoff"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// Proxy is a handle to a proxy that is running in the current process.
+type Proxy struct {
+	proxy        *zdmproxy.ZdmProxy
+	cancelFn     context.CancelFunc
+	shutdownOnce *sync.Once
+	done         chan struct{}
+}
+
+// Run starts a proxy with the provided config. It returns an error if the proxy can not connect to both clusters.
+//
+// The proxy is shut down when the context is canceled or when Shutdown is called.
+func Run(ctx context.Context, conf *config.Config) (*Proxy, error) {
+	return RunWithExtensions(ctx, conf, nil)
+}
+
+// RunWithExtensions is the same as Run but the proxy also uses the provided custom components (e.g. request
+// interceptors).
+func RunWithExtensions(ctx context.Context, conf *config.Config, extensions *zdmproxy.Extensions) (*Proxy, error) {
+	proxyCtx, cancelFn := context.WithCancel(ctx)
+	zdmProxy, err := zdmproxy.RunWithExtensions(conf, proxyCtx, extensions)
+	if err != nil {
+		cancelFn()
+		return nil, err
+	}
+	return newProxy(proxyCtx, cancelFn, zdmProxy), nil
+}
+
+// RunWithRetries starts a proxy with the provided config and extensions (which can be nil) and retries with the
+// provided backoff until it succeeds, which is what the standalone binary does. It returns zdmproxy.ShutdownErr if
+// the context is canceled before the proxy starts.
+func RunWithRetries(
+	ctx context.Context, conf *config.Config, b *backoff.Backoff, extensions *zdmproxy.Extensions) (*Proxy, error) {
+	proxyCtx, cancelFn := context.WithCancel(ctx)
+	zdmProxy, err := zdmproxy.RunWithRetriesAndExtensions(conf, proxyCtx, b, extensions)
+	if err != nil {
+		cancelFn()
+		return nil, err
+	}
+	return newProxy(proxyCtx, cancelFn, zdmProxy), nil
+}
+
+func newProxy(ctx context.Context, cancelFn context.CancelFunc, zdmProxy *zdmproxy.ZdmProxy) *Proxy {
+	p := &Proxy{
+		proxy:        zdmProxy,
+		cancelFn:     cancelFn,
+		shutdownOnce: &sync.Once{},
+		done:         make(chan struct{}),
+	}
+	go func() {
+		<-ctx.Done()
+		p.Shutdown()
+	}()
+	return p
+}
+
+// Shutdown closes the client listener and all client connections and waits until the proxy is stopped.
+// It is safe to call it multiple times.
+func (p *Proxy) Shutdown() {
+	p.shutdownOnce.Do(func() {
+		p.cancelFn()
+		p.proxy.Shutdown()
+		close(p.done)
+	})
+	<-p.done
+}
+
+// Done returns a channel that is closed when the proxy is shut down.
+func (p *Proxy) Done() <-chan struct{} {
+	return p.done
+}
+
+// ListenAddress returns the address that clients connect to. It returns nil after the proxy is shut down.
+func (p *Proxy) ListenAddress() net.Addr {
+	return p.proxy.GetListenAddress()
+}
+
+// Metrics returns the proxy level metrics (e.g. failed reads and in flight requests).
+func (p *Proxy) Metrics() *metrics.ProxyMetrics {
+	return p.proxy.GetMetricHandler().GetProxyMetrics()
+}
+
+// MetricsHandler returns the http handler that the standalone binary serves on /metrics.
+func (p *Proxy) MetricsHandler() http.Handler {
+	return p.proxy.GetMetricHandler().GetHttpHandler()
+}
+
+// ReadinessHandler returns the http handler that the standalone binary serves on /health/readiness.
+func (p *Proxy) ReadinessHandler() http.Handler {
+	return health.ReadinessHandler(p.proxy)
+}
+
+// Health returns the status of the control connections of both clusters.
+func (p *Proxy) Health() *health.StatusReport {
+	return health.PerformHealthCheck(p.proxy)
+}
+
+// Unwrap returns the underlying proxy. Unlike the methods of Proxy, its API can change between releases.
+func (p *Proxy) Unwrap() *zdmproxy.ZdmProxy {
+	return p.proxy
+}
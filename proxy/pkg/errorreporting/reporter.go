@@ -0,0 +1,364 @@
+package errorreporting
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	eventQueueSize = 100
+	sendTimeout    = 10 * time.Second
+	flushTimeout   = 5 * time.Second
+
+	maxStackFrames = 64
+	inAppPrefix    = "github.com/datastax/zdm-proxy/"
+)
+
+// Reporter sends the errors logged by the proxy and the panics of its goroutines to a Sentry-compatible server
+// (Sentry, GlitchTip, ...) with the envelope endpoint of the Sentry protocol.
+//
+// Reporter is a logrus hook so every entry logged with the error level or above is reported with the stack trace of
+// the log call, its fields and the proxy context (host, version and the main settings) as tags. Events are sent by
+// a background goroutine and dropped when the queue is full so that logging never blocks on the server. Fatal and
+// panic entries and the panics reported with ReportPanic are sent synchronously because the process is exiting.
+type Reporter struct {
+	envelopeUrl string
+	authHeader  string
+	dsn         string
+	release     string
+	serverName  string
+	environment string
+	tags        map[string]string
+	httpClient  *http.Client
+
+	queue  chan *event
+	wg     *sync.WaitGroup
+	lock   *sync.RWMutex
+	closed bool
+}
+
+var defaultReporter = &atomic.Value{}
+
+// NewReporter returns the Reporter configured with ZDM_ERROR_REPORTING_DSN or nil if error reporting is disabled.
+func NewReporter(conf *config.Config, version string) (*Reporter, error) {
+	dsn, err := conf.ParseErrorReportingDsn()
+	if err != nil {
+		return nil, err
+	}
+	if dsn == nil {
+		return nil, nil
+	}
+	return newReporter(dsn, conf, version), nil
+}
+
+func newReporter(dsn *url.URL, conf *config.Config, version string) *Reporter {
+	path := strings.Trim(dsn.Path, "/")
+	projectId := path
+	prefix := ""
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		prefix, projectId = "/"+path[:i], path[i+1:]
+	}
+	envelopeUrl := fmt.Sprintf("%v://%v%v/api/%v/envelope/", dsn.Scheme, dsn.Host, prefix, projectId)
+
+	authHeader := fmt.Sprintf("Sentry sentry_version=7, sentry_client=zdm-proxy/%v, sentry_key=%v",
+		version, dsn.User.Username())
+	if secret, ok := dsn.User.Password(); ok {
+		authHeader += ", sentry_secret=" + secret
+	}
+
+	serverName, err := os.Hostname()
+	if err != nil {
+		serverName = fmt.Sprintf("%v:%d", conf.ProxyListenAddress, conf.ProxyListenPort)
+	}
+
+	r := &Reporter{
+		envelopeUrl: envelopeUrl,
+		authHeader:  authHeader,
+		dsn:         dsn.String(),
+		release:     "zdm-proxy@" + version,
+		serverName:  serverName,
+		environment: conf.ErrorReportingEnvironment,
+		tags: map[string]string{
+			"version":         version,
+			"proxy_index":     fmt.Sprintf("%d", conf.ProxyTopologyIndex),
+			"primary_cluster": conf.PrimaryCluster,
+			"read_mode":       conf.ReadMode,
+		},
+		httpClient: &http.Client{Timeout: sendTimeout},
+		queue:      make(chan *event, eventQueueSize),
+		wg:         &sync.WaitGroup{},
+		lock:       &sync.RWMutex{},
+	}
+	r.wg.Add(1)
+	go r.run()
+	return r
+}
+
+// Install adds the Reporter to the hooks of the standard logger and makes it the Reporter of ReportPanic.
+func (r *Reporter) Install() {
+	log.AddHook(r)
+	defaultReporter.Store(r)
+}
+
+// Close sends the queued events and stops the Reporter, events are no longer reported after Close.
+func (r *Reporter) Close() {
+	r.lock.Lock()
+	if r.closed {
+		r.lock.Unlock()
+		return
+	}
+	r.closed = true
+	close(r.queue)
+	r.lock.Unlock()
+	defaultReporter.CompareAndSwap(r, (*Reporter)(nil))
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(flushTimeout):
+		log.Debugf("Timed out sending the queued error reports.")
+	}
+}
+
+func (r *Reporter) Levels() []log.Level {
+	return []log.Level{log.PanicLevel, log.FatalLevel, log.ErrorLevel}
+}
+
+func (r *Reporter) Fire(entry *log.Entry) error {
+	evt := r.newEvent(entry.Level.String(), entry.Message, "", captureStacktrace())
+	for key, value := range entry.Data {
+		if err, ok := value.(error); ok {
+			value = err.Error()
+		}
+		evt.Extra[key] = value
+	}
+	if entry.Level <= log.FatalLevel {
+		r.send(evt)
+		return nil
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	if r.closed {
+		return nil
+	}
+	select {
+	case r.queue <- evt:
+	default:
+		// the queue is full, this error is not reported
+	}
+	return nil
+}
+
+// ReportPanic reports the panic of the calling goroutine, if any, and panics again with the same value so that the
+// behavior of the proxy is not changed. It must be deferred, e.g. defer errorreporting.ReportPanic().
+func ReportPanic() {
+	recovered := recover()
+	if recovered == nil {
+		return
+	}
+	if r, _ := defaultReporter.Load().(*Reporter); r != nil {
+		r.send(r.newEvent("fatal", fmt.Sprintf("panic: %v", recovered), "panic", captureStacktrace()))
+	}
+	panic(recovered)
+}
+
+func (r *Reporter) run() {
+	defer r.wg.Done()
+	for evt := range r.queue {
+		r.send(evt)
+	}
+}
+
+func (r *Reporter) send(evt *event) {
+	err := r.post(evt)
+	if err != nil {
+		// not logged as an error, it would be reported again
+		log.Debugf("Could not send the error report to the error reporting server: %v", err)
+	}
+}
+
+func (r *Reporter) post(evt *event) error {
+	payload, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	envelopeHeader, err := json.Marshal(map[string]string{
+		"event_id": evt.EventId,
+		"dsn":      r.dsn,
+		"sent_at":  time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return err
+	}
+	itemHeader, err := json.Marshal(map[string]interface{}{"type": "event", "length": len(payload)})
+	if err != nil {
+		return err
+	}
+	body := &bytes.Buffer{}
+	for _, line := range [][]byte{envelopeHeader, itemHeader, payload} {
+		body.Write(line)
+		body.WriteByte('\n')
+	}
+
+	request, err := http.NewRequest(http.MethodPost, r.envelopeUrl, body)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-sentry-envelope")
+	request.Header.Set("X-Sentry-Auth", r.authHeader)
+	response, err := r.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		responseBody, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("unexpected status %v: %v", response.Status, strings.TrimSpace(string(responseBody)))
+	}
+	return nil
+}
+
+type event struct {
+	EventId     string                 `json:"event_id"`
+	Timestamp   string                 `json:"timestamp"`
+	Level       string                 `json:"level"`
+	Platform    string                 `json:"platform"`
+	Logger      string                 `json:"logger"`
+	Release     string                 `json:"release"`
+	Environment string                 `json:"environment,omitempty"`
+	ServerName  string                 `json:"server_name"`
+	Message     *eventMessage          `json:"message,omitempty"`
+	Exception   []*eventException      `json:"exception,omitempty"`
+	Tags        map[string]string      `json:"tags"`
+	Extra       map[string]interface{} `json:"extra"`
+}
+
+type eventMessage struct {
+	Formatted string `json:"formatted"`
+}
+
+type eventException struct {
+	Type       string           `json:"type"`
+	Value      string           `json:"value"`
+	Mechanism  *eventMechanism  `json:"mechanism,omitempty"`
+	Stacktrace *eventStacktrace `json:"stacktrace,omitempty"`
+}
+
+type eventMechanism struct {
+	Type    string `json:"type"`
+	Handled bool   `json:"handled"`
+}
+
+type eventStacktrace struct {
+	Frames []*eventFrame `json:"frames"`
+}
+
+type eventFrame struct {
+	Function string `json:"function"`
+	Module   string `json:"module"`
+	AbsPath  string `json:"abs_path"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+func (r *Reporter) newEvent(level string, message string, mechanism string, frames []*eventFrame) *event {
+	eventId := make([]byte, 16)
+	_, _ = rand.Read(eventId)
+	evt := &event{
+		EventId:     hex.EncodeToString(eventId),
+		Timestamp:   time.Now().UTC().Format(time.RFC3339Nano),
+		Level:       level,
+		Platform:    "go",
+		Logger:      "zdm-proxy",
+		Release:     r.release,
+		Environment: r.environment,
+		ServerName:  r.serverName,
+		Tags:        r.tags,
+		Extra:       make(map[string]interface{}),
+	}
+	if mechanism == "" {
+		// the stack trace of a logged error is the stack trace of the log call
+		evt.Message = &eventMessage{Formatted: message}
+		evt.Exception = []*eventException{{Type: "error", Value: message, Stacktrace: &eventStacktrace{Frames: frames}}}
+	} else {
+		evt.Exception = []*eventException{{
+			Type:       mechanism,
+			Value:      message,
+			Mechanism:  &eventMechanism{Type: mechanism, Handled: false},
+			Stacktrace: &eventStacktrace{Frames: frames},
+		}}
+	}
+	return evt
+}
+
+const reporterModule = "github.com/datastax/zdm-proxy/proxy/pkg/errorreporting"
+
+var reporterFunctions = map[string]bool{"captureStacktrace": true, "(*Reporter).Fire": true, "ReportPanic": true}
+
+// captureStacktrace returns the frames of the calling goroutine in the order expected by Sentry (the innermost frame
+// last) without the innermost frames of logrus, of this package and of the runtime, i.e. the stack trace starts at the
+// log call or at the function that panicked.
+func captureStacktrace() []*eventFrame {
+	pcs := make([]uintptr, maxStackFrames)
+	n := runtime.Callers(1, pcs)
+	callersFrames := runtime.CallersFrames(pcs[:n])
+
+	var frames []*eventFrame
+	skipping := true
+	for {
+		frame, more := callersFrames.Next()
+		module, function := splitFunctionName(frame.Function)
+		if skipping && (strings.HasPrefix(module, "github.com/sirupsen/logrus") || module == "runtime" ||
+			(module == reporterModule && reporterFunctions[function])) {
+			if !more {
+				break
+			}
+			continue
+		}
+		skipping = false
+		frames = append(frames, &eventFrame{
+			Function: function,
+			Module:   module,
+			AbsPath:  frame.File,
+			Lineno:   frame.Line,
+			InApp:    strings.HasPrefix(frame.Function, inAppPrefix),
+		})
+		if !more {
+			break
+		}
+	}
+	for i, j := 0, len(frames)-1; i < j; i, j = i+1, j-1 {
+		frames[i], frames[j] = frames[j], frames[i]
+	}
+	return frames
+}
+
+// splitFunctionName splits e.g. github.com/datastax/zdm-proxy/proxy/pkg/zdmproxy.(*ClientHandler).requestLoop.func1
+// into the package and the function.
+func splitFunctionName(name string) (string, string) {
+	lastSlash := strings.LastIndex(name, "/")
+	dot := strings.Index(name[lastSlash+1:], ".")
+	if dot < 0 {
+		return "", name
+	}
+	return name[:lastSlash+1+dot], name[lastSlash+1+dot+1:]
+}
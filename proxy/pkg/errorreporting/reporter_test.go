@@ -0,0 +1,144 @@
+package errorreporting
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type reportedEvent struct {
+	path       string
+	authHeader string
+	envelope   map[string]string
+	item       map[string]interface{}
+	event      *event
+}
+
+type testSentryServer struct {
+	*httptest.Server
+	lock   *sync.Mutex
+	events []*reportedEvent
+}
+
+func newTestSentryServer(t *testing.T) *testSentryServer {
+	server := &testSentryServer{lock: &sync.Mutex{}}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reported := &reportedEvent{path: r.URL.Path, authHeader: r.Header.Get("X-Sentry-Auth")}
+		reader := bufio.NewReader(r.Body)
+		readLine := func(v interface{}) {
+			line, err := reader.ReadBytes('\n')
+			require.True(t, err == nil || err == io.EOF, err)
+			require.Nil(t, json.Unmarshal(line, v))
+		}
+		readLine(&reported.envelope)
+		readLine(&reported.item)
+		readLine(&reported.event)
+		server.lock.Lock()
+		server.events = append(server.events, reported)
+		server.lock.Unlock()
+	}))
+	return server
+}
+
+func (s *testSentryServer) getEvents() []*reportedEvent {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return append([]*reportedEvent{}, s.events...)
+}
+
+func newTestReporter(t *testing.T, serverUrl string) *Reporter {
+	reporter, err := NewReporter(&config.Config{
+		ErrorReportingDsn:         strings.Replace(serverUrl, "://", "://publicKey@", 1) + "/sentry/42",
+		ErrorReportingEnvironment: "test",
+		PrimaryCluster:            "ORIGIN",
+		ReadMode:                  "PRIMARY_ONLY",
+	}, "2.1.0")
+	require.Nil(t, err)
+	require.NotNil(t, reporter)
+	return reporter
+}
+
+func TestReporter_LoggedErrors(t *testing.T) {
+	server := newTestSentryServer(t)
+	defer server.Close()
+	reporter := newTestReporter(t, server.URL)
+
+	logger := log.New()
+	logger.SetOutput(io.Discard)
+	logger.AddHook(reporter)
+	logger.Warnf("not reported")
+	logger.WithField("client", "127.0.0.1:9042").WithError(errors.New("connection reset")).
+		Errorf("Error while handling request: %v", "connection reset")
+	reporter.Close()
+	// events are no longer reported after Close
+	logger.Errorf("not reported")
+
+	events := server.getEvents()
+	require.Len(t, events, 1)
+	reported := events[0]
+	require.Equal(t, "/sentry/api/42/envelope/", reported.path)
+	require.Equal(t, "Sentry sentry_version=7, sentry_client=zdm-proxy/2.1.0, sentry_key=publicKey", reported.authHeader)
+	require.Equal(t, reported.event.EventId, reported.envelope["event_id"])
+	require.Equal(t, "event", reported.item["type"])
+
+	evt := reported.event
+	require.Equal(t, "error", evt.Level)
+	require.Equal(t, "zdm-proxy@2.1.0", evt.Release)
+	require.Equal(t, "test", evt.Environment)
+	require.Equal(t, "Error while handling request: connection reset", evt.Message.Formatted)
+	require.Equal(t, map[string]interface{}{"client": "127.0.0.1:9042", "error": "connection reset"}, evt.Extra)
+	require.Equal(t, "2.1.0", evt.Tags["version"])
+	require.Equal(t, "ORIGIN", evt.Tags["primary_cluster"])
+
+	frames := evt.Exception[0].Stacktrace.Frames
+	require.Equal(t, "TestReporter_LoggedErrors", frames[len(frames)-1].Function)
+	require.Equal(t, "github.com/datastax/zdm-proxy/proxy/pkg/errorreporting", frames[len(frames)-1].Module)
+	require.True(t, frames[len(frames)-1].InApp)
+}
+
+func TestReportPanic(t *testing.T) {
+	server := newTestSentryServer(t)
+	defer server.Close()
+	reporter := newTestReporter(t, server.URL)
+	defer reporter.Close()
+	defaultReporter.Store(reporter)
+
+	var recovered interface{}
+	func() {
+		defer func() {
+			recovered = recover()
+		}()
+		panicInRequestLoop()
+	}()
+	require.Equal(t, "index out of range", recovered)
+
+	events := server.getEvents()
+	require.Len(t, events, 1)
+	evt := events[0].event
+	require.Equal(t, "fatal", evt.Level)
+	require.Equal(t, "panic", evt.Exception[0].Type)
+	require.Equal(t, "panic: index out of range", evt.Exception[0].Value)
+	require.False(t, evt.Exception[0].Mechanism.Handled)
+	frames := evt.Exception[0].Stacktrace.Frames
+	require.Equal(t, "panicInRequestLoop", frames[len(frames)-1].Function)
+}
+
+func panicInRequestLoop() {
+	defer ReportPanic()
+	panic("index out of range")
+}
+
+func TestNewReporter_Disabled(t *testing.T) {
+	reporter, err := NewReporter(&config.Config{}, "2.1.0")
+	require.Nil(t, err)
+	require.Nil(t, reporter)
+}
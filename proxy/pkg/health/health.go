@@ -7,6 +7,7 @@ import (
 	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 	"net/http"
+	"time"
 )
 
 func DefaultReadinessHandler() http.Handler {
@@ -24,6 +25,10 @@ type ControlConnStatus struct {
 	CurrentFailureCount   int
 	FailureCountThreshold int
 	Status                Status
+	// HealthState is UP, DEGRADED, DOWN or AUTH_FAILING, the status is UP in the first two states.
+	HealthState  zdmproxy.ClusterHealthState
+	HealthReason string
+	HealthSince  time.Time
 }
 
 type Status string
@@ -104,14 +109,20 @@ func newControlConnStatus(controlConn *zdmproxy.ControlConn, failureThreshold in
 		addr = currentEndpoint.GetEndpointIdentifier()
 	}
 
+	// the failure count is read first, the health state is updated before a new count is visible
+	currentFailureCount := controlConn.ReadFailureCounter()
+	health := controlConn.GetHealth()
 	controlConnReport := &ControlConnStatus{
 		Addr:                  addr,
-		CurrentFailureCount:   controlConn.ReadFailureCounter(),
+		CurrentFailureCount:   currentFailureCount,
 		FailureCountThreshold: failureThreshold,
 		Status:                UP,
+		HealthState:           health.State,
+		HealthReason:          health.Reason,
+		HealthSince:           health.Since,
 	}
 
-	if controlConnReport.CurrentFailureCount >= controlConnReport.FailureCountThreshold {
+	if health.State != zdmproxy.ClusterHealthUp && health.State != zdmproxy.ClusterHealthDegraded {
 		controlConnReport.Status = DOWN
 	}
 
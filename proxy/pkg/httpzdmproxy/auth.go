@@ -0,0 +1,124 @@
+package httpzdmproxy
+
+import (
+	"crypto/subtle"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"strings"
+)
+
+type Role int
+
+const (
+	RoleNone = Role(iota)
+	RoleReadOnly
+	RoleOperator
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleReadOnly:
+		return "read-only"
+	case RoleOperator:
+		return "operator"
+	default:
+		return "none"
+	}
+}
+
+// Authorizer authenticates the requests of the http server with a bearer token (Authorization: Bearer <token>) or with
+// the common name of a verified client certificate. The read-only role can only send GET and HEAD requests, the
+// operator role can send any request.
+type Authorizer struct {
+	conf *common.AdminApiAuthConfig
+}
+
+// NewAuthorizer returns nil if the authentication is disabled, a nil Authorizer authorizes every request.
+func NewAuthorizer(conf *common.AdminApiAuthConfig) *Authorizer {
+	if conf == nil || !conf.Enabled() {
+		return nil
+	}
+	return &Authorizer{conf: conf}
+}
+
+// Role returns the highest role of the credentials of the request.
+func (a *Authorizer) Role(req *http.Request) Role {
+	if a == nil {
+		return RoleOperator
+	}
+	role := RoleNone
+	if token, ok := getBearerToken(req); ok {
+		if containsToken(a.conf.OperatorTokens, token) {
+			return RoleOperator
+		}
+		if containsToken(a.conf.ReadOnlyTokens, token) {
+			role = RoleReadOnly
+		}
+	}
+	if req.TLS != nil && len(req.TLS.VerifiedChains) > 0 && len(req.TLS.VerifiedChains[0]) > 0 {
+		commonName := req.TLS.VerifiedChains[0][0].Subject.CommonName
+		if containsName(a.conf.OperatorCertNames, commonName) {
+			return RoleOperator
+		}
+		if containsName(a.conf.ReadOnlyCertNames, commonName) {
+			role = RoleReadOnly
+		}
+	}
+	return role
+}
+
+// Handler returns a handler that rejects the requests without the required role with 401 (no role) or 403 (a
+// read-only role for a request that is not a GET or HEAD) before calling handler.
+func (a *Authorizer) Handler(handler http.Handler) http.Handler {
+	if a == nil {
+		return handler
+	}
+	return http.HandlerFunc(func(rsp http.ResponseWriter, req *http.Request) {
+		requiredRole := RoleOperator
+		if req.Method == http.MethodGet || req.Method == http.MethodHead {
+			requiredRole = RoleReadOnly
+		}
+		role := a.Role(req)
+		if role >= requiredRole {
+			handler.ServeHTTP(rsp, req)
+			return
+		}
+		log.Debugf("Rejected %v %v from %v: role %v is required, the request has role %v.",
+			req.Method, req.URL.Path, req.RemoteAddr, requiredRole, role)
+		if role == RoleNone {
+			rsp.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(rsp, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		http.Error(rsp, "forbidden", http.StatusForbidden)
+	})
+}
+
+func getBearerToken(req *http.Request) (string, bool) {
+	authorization := req.Header.Get("Authorization")
+	if len(authorization) < len("Bearer ") || !strings.EqualFold(authorization[:len("Bearer ")], "Bearer ") {
+		return "", false
+	}
+	return strings.TrimSpace(authorization[len("Bearer "):]), true
+}
+
+// containsToken compares the tokens in constant time so that the response time doesn't reveal a valid token.
+func containsToken(tokens []string, token string) bool {
+	found := false
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			found = true
+		}
+	}
+	return found
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
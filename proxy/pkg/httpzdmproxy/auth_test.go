@@ -0,0 +1,78 @@
+package httpzdmproxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthorizer(t *testing.T) {
+	authorizer := NewAuthorizer(&common.AdminApiAuthConfig{
+		OperatorTokens:    []string{"op"},
+		ReadOnlyTokens:    []string{"ro"},
+		OperatorCertNames: []string{"admin"},
+		ReadOnlyCertNames: []string{"monitoring"},
+	})
+	handler := authorizer.Handler(http.HandlerFunc(func(rsp http.ResponseWriter, req *http.Request) {
+		rsp.WriteHeader(http.StatusNoContent)
+	}))
+	withCert := func(req *http.Request, commonName string) *http.Request {
+		req.TLS = &tls.ConnectionState{
+			VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: commonName}}}},
+		}
+		return req
+	}
+
+	tests := []struct {
+		name          string
+		method        string
+		authorization string
+		certName      string
+		role          Role
+		statusCode    int
+	}{
+		{name: "no credentials", method: http.MethodGet, role: RoleNone, statusCode: http.StatusUnauthorized},
+		{name: "unknown token", method: http.MethodGet, authorization: "Bearer other", role: RoleNone, statusCode: http.StatusUnauthorized},
+		{name: "basic authentication", method: http.MethodGet, authorization: "Basic cm86cm8=", role: RoleNone, statusCode: http.StatusUnauthorized},
+		{name: "read-only token GET", method: http.MethodGet, authorization: "Bearer ro", role: RoleReadOnly, statusCode: http.StatusNoContent},
+		{name: "read-only token POST", method: http.MethodPost, authorization: "Bearer ro", role: RoleReadOnly, statusCode: http.StatusForbidden},
+		{name: "operator token POST", method: http.MethodPost, authorization: "bearer op", role: RoleOperator, statusCode: http.StatusNoContent},
+		{name: "operator token DELETE", method: http.MethodDelete, authorization: "Bearer op", role: RoleOperator, statusCode: http.StatusNoContent},
+		{name: "read-only certificate HEAD", method: http.MethodHead, certName: "monitoring", role: RoleReadOnly, statusCode: http.StatusNoContent},
+		{name: "read-only certificate PUT", method: http.MethodPut, certName: "monitoring", role: RoleReadOnly, statusCode: http.StatusForbidden},
+		{name: "operator certificate PUT", method: http.MethodPut, certName: "admin", role: RoleOperator, statusCode: http.StatusNoContent},
+		{name: "unknown certificate", method: http.MethodGet, certName: "other", role: RoleNone, statusCode: http.StatusUnauthorized},
+		{name: "highest role", method: http.MethodPost, authorization: "Bearer ro", certName: "admin", role: RoleOperator, statusCode: http.StatusNoContent},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/admin/sessions", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			if tt.certName != "" {
+				req = withCert(req, tt.certName)
+			}
+			require.Equal(t, tt.role, authorizer.Role(req))
+			rsp := httptest.NewRecorder()
+			handler.ServeHTTP(rsp, req)
+			require.Equal(t, tt.statusCode, rsp.Code)
+		})
+	}
+
+	// the authentication is disabled without credentials
+	require.Nil(t, NewAuthorizer(&common.AdminApiAuthConfig{MetricsAuthRequired: true}))
+	var disabled *Authorizer
+	rsp := httptest.NewRecorder()
+	disabled.Handler(http.HandlerFunc(func(rsp http.ResponseWriter, req *http.Request) {
+		rsp.WriteHeader(http.StatusNoContent)
+	})).ServeHTTP(rsp, httptest.NewRequest(http.MethodPost, "/admin/cluster-roles/swap", nil))
+	require.Equal(t, http.StatusNoContent, rsp.Code)
+	require.Equal(t, RoleOperator, disabled.Role(httptest.NewRequest(http.MethodPost, "/admin/cluster-roles/swap", nil)))
+}
@@ -1,19 +1,32 @@
 package httpzdmproxy
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
 	log "github.com/sirupsen/logrus"
 	"net/http"
+	"os"
 	"sync"
 )
 
-func StartHttpServer(addr string, wg *sync.WaitGroup) *http.Server {
-	srv := &http.Server{Addr: addr}
+// StartHttpServer serves HTTPS if tlsConfig is not nil.
+func StartHttpServer(addr string, tlsConfig *tls.Config, wg *sync.WaitGroup) *http.Server {
+	srv := &http.Server{Addr: addr, TLSConfig: tlsConfig}
 
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
 
-		if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+		var err error
+		if tlsConfig != nil {
+			// the certificates are in TLSConfig
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != http.ErrServerClosed {
 			log.Errorf("Failed to listen on the metrics endpoint: %v. "+
 				"The proxy will stay up and listen for CQL requests.", err)
 		}
@@ -21,3 +34,33 @@ func StartHttpServer(addr string, wg *sync.WaitGroup) *http.Server {
 
 	return srv
 }
+
+// NewServerTlsConfig loads the certificates of the http server, it returns nil if TLS is disabled. The client
+// certificates are verified if they are sent but they are not required so that the health probes can still connect.
+func NewServerTlsConfig(conf *common.HttpTlsConfig) (*tls.Config, error) {
+	if !conf.TlsEnabled {
+		return nil, nil
+	}
+	serverCert, err := tls.LoadX509KeyPair(conf.CertPath, conf.KeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not load the certificate of the metrics endpoint: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientAuth:   tls.NoClientCert,
+	}
+	if conf.ClientCaPath != "" {
+		caCert, err := os.ReadFile(conf.ClientCaPath)
+		if err != nil {
+			return nil, fmt.Errorf("could not read the client CA of the metrics endpoint: %w", err)
+		}
+		clientCAs := x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("the client CA of the metrics endpoint (%v) does not contain any certificate",
+				conf.ClientCaPath)
+		}
+		tlsConfig.ClientCAs = clientCAs
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,67 @@
+package journal
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DedupWindow remembers the operation ids of the last applied entries so that an entry that is delivered again, e.g.
+// because its offset could not be committed after it was applied, is not applied twice. The oldest id is forgotten
+// when the window is full.
+type DedupWindow struct {
+	size  int
+	lock  *sync.Mutex
+	ids   map[string]*list.Element
+	order *list.List
+}
+
+// NewDedupWindow returns nil if size is 0, the methods of a nil window don't remember anything.
+func NewDedupWindow(size int) *DedupWindow {
+	if size <= 0 {
+		return nil
+	}
+	return &DedupWindow{
+		size:  size,
+		lock:  &sync.Mutex{},
+		ids:   make(map[string]*list.Element, size),
+		order: list.New(),
+	}
+}
+
+// Contains returns true if the operation id was added and not forgotten yet.
+func (w *DedupWindow) Contains(operationId string) bool {
+	if w == nil || operationId == "" {
+		return false
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	_, ok := w.ids[operationId]
+	return ok
+}
+
+// Add remembers the operation id, the entries without operation id (written by older proxy versions) are ignored.
+func (w *DedupWindow) Add(operationId string) {
+	if w == nil || operationId == "" {
+		return
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if _, ok := w.ids[operationId]; ok {
+		return
+	}
+	if w.order.Len() >= w.size {
+		oldest := w.order.Front()
+		w.order.Remove(oldest)
+		delete(w.ids, oldest.Value.(string))
+	}
+	w.ids[operationId] = w.order.PushBack(operationId)
+}
+
+func (w *DedupWindow) Len() int {
+	if w == nil {
+		return 0
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return w.order.Len()
+}
@@ -0,0 +1,32 @@
+package journal
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestDedupWindow(t *testing.T) {
+	w := NewDedupWindow(2)
+	w.Add("op1")
+	w.Add("op2")
+	w.Add("op2")
+	require.Equal(t, 2, w.Len())
+	require.True(t, w.Contains("op1"))
+	require.True(t, w.Contains("op2"))
+
+	// the oldest operation is forgotten
+	w.Add("op3")
+	require.False(t, w.Contains("op1"))
+	require.True(t, w.Contains("op2"))
+	require.True(t, w.Contains("op3"))
+
+	w.Add("")
+	require.False(t, w.Contains(""))
+	require.Equal(t, 2, w.Len())
+
+	disabled := NewDedupWindow(0)
+	require.Nil(t, disabled)
+	disabled.Add("op1")
+	require.False(t, disabled.Contains("op1"))
+	require.Equal(t, 0, disabled.Len())
+}
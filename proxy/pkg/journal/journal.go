@@ -0,0 +1,139 @@
+// Package journal stores the writes that the proxy did not send to Target so that they can be applied to it later,
+// either in a local file or in a Kafka topic.
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+// Entry is a client request that was only sent to Origin. Frame is the request as it was received from the client
+// (header and body) so that it can be sent to Target as is, Statements are the CQL statements of the request (the
+// prepared statements for EXECUTE and one per child for BATCH, empty if unknown) so that the prepared statements can
+// be prepared again on Target. OperationId is a unique time UUID of the request, the entries with the same operation id
+// are the same write and must only be applied once.
+type Entry struct {
+	OperationId     string    `json:"operationId"`
+	Timestamp       time.Time `json:"timestamp"`
+	Client          string    `json:"client"`
+	Keyspace        string    `json:"keyspace"`
+	OpCode          string    `json:"opCode"`
+	ProtocolVersion int       `json:"protocolVersion"`
+	Statements      []string  `json:"statements"`
+	Frame           []byte    `json:"frame"`
+}
+
+// Journal stores entries durably, Append is called on the request path and returns once the entry is stored.
+type Journal interface {
+	Append(entry *Entry) error
+	Close() error
+}
+
+// FileJournal appends the entries to a file as JSON lines, the file is created if it doesn't exist and the existing
+// entries are kept.
+type FileJournal struct {
+	path           string
+	lock           *sync.Mutex
+	file           *os.File
+	compactionLock *sync.Mutex
+}
+
+func NewFileJournal(path string) (*FileJournal, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("could not open journal file: %w", err)
+	}
+	return &FileJournal{path: path, lock: &sync.Mutex{}, file: file, compactionLock: &sync.Mutex{}}, nil
+}
+
+func (j *FileJournal) GetPath() string {
+	return j.path
+}
+
+// GetSizeBytes returns the size of the file, i.e. of the entries that were not applied to Target yet.
+func (j *FileJournal) GetSizeBytes() (int64, error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if j.file == nil {
+		return 0, fmt.Errorf("journal %v is closed", j.path)
+	}
+	info, err := j.file.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("could not stat journal %v: %w", j.path, err)
+	}
+	return info.Size(), nil
+}
+
+// Append writes the entry with a single write call so that the entries of concurrent requests don't interleave, and
+// syncs the file before returning so that the entry survives a crash of the host.
+func (j *FileJournal) Append(entry *Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("could not encode journal entry: %w", err)
+	}
+	line = append(line, '\n')
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if j.file == nil {
+		return fmt.Errorf("journal %v is closed", j.path)
+	}
+	_, err = j.file.Write(line)
+	if err != nil {
+		return fmt.Errorf("could not write to journal %v: %w", j.path, err)
+	}
+	err = j.file.Sync()
+	if err != nil {
+		return fmt.Errorf("could not sync journal %v: %w", j.path, err)
+	}
+	return nil
+}
+
+// Close syncs and closes the file, Append returns an error afterwards.
+func (j *FileJournal) Close() error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if j.file == nil {
+		return nil
+	}
+	file := j.file
+	j.file = nil
+	err := file.Sync()
+	closeErr := file.Close()
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// ReadFile returns the entries of a journal file in the order in which they were appended.
+func ReadFile(path string) ([]*Entry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []*Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 256*1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		entry := &Entry{}
+		err = json.Unmarshal(scanner.Bytes(), entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid journal entry at line %d of %v: %w", lineNumber, path, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read journal %v: %w", path, err)
+	}
+	return entries, nil
+}
@@ -0,0 +1,64 @@
+package journal
+
+import (
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	j, err := NewFileJournal(path)
+	require.Nil(t, err)
+
+	timestamp := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			require.Nil(t, j.Append(&Entry{
+				Timestamp:       timestamp,
+				Client:          "127.0.0.1:50000",
+				Keyspace:        "ks",
+				OpCode:          "QUERY",
+				ProtocolVersion: 4,
+				Statements:      []string{"INSERT INTO ks.tb (k) VALUES (1)"},
+				Frame:           []byte{0x04, 0x00, 0x00, 0x01, 0x07},
+			}))
+		}()
+	}
+	wg.Wait()
+	require.Nil(t, j.Close())
+	require.NotNil(t, j.Append(&Entry{}))
+
+	entries, err := ReadFile(path)
+	require.Nil(t, err)
+	require.Len(t, entries, 50)
+	for _, entry := range entries {
+		require.Equal(t, timestamp, entry.Timestamp.UTC())
+		require.Equal(t, []string{"INSERT INTO ks.tb (k) VALUES (1)"}, entry.Statements)
+		require.Equal(t, []byte{0x04, 0x00, 0x00, 0x01, 0x07}, entry.Frame)
+	}
+
+	// the existing entries are kept when the journal is opened again
+	j, err = NewFileJournal(path)
+	require.Nil(t, err)
+	require.Nil(t, j.Append(&Entry{OpCode: "BATCH"}))
+	require.Nil(t, j.Close())
+	entries, err = ReadFile(path)
+	require.Nil(t, err)
+	require.Len(t, entries, 51)
+	require.Equal(t, "BATCH", entries[50].OpCode)
+}
+
+func TestReadFile_InvalidEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	require.Nil(t, os.WriteFile(path, []byte("{\"opCode\":\"QUERY\"}\nnot json\n"), 0600))
+	_, err := ReadFile(path)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "invalid journal entry at line 2")
+}
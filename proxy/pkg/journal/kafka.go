@@ -0,0 +1,218 @@
+package journal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	"github.com/jpillora/backoff"
+	"github.com/segmentio/kafka-go"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+const (
+	kafkaBatchTimeout      = 5 * time.Millisecond
+	kafkaWriteTimeout      = 10 * time.Second
+	kafkaRetryIntervalMin  = 100 * time.Millisecond
+	kafkaRetryIntervalMax  = 10 * time.Second
+	kafkaReaderMaxBytes    = 10e6
+	kafkaReaderMaxWaitTime = time.Second
+)
+
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+type messageReader interface {
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// KafkaJournal writes the entries as JSON messages to a Kafka topic with the keyspace as the message key, so the
+// entries of a keyspace are stored in the same partition in the order in which they were appended.
+//
+// Append returns once the message was acknowledged by all the in-sync replicas, the concurrent Append calls are written
+// in the same batch.
+type KafkaJournal struct {
+	writer messageWriter
+	topic  string
+}
+
+func NewKafkaJournal(brokers []string, topic string) *KafkaJournal {
+	return newKafkaJournal(&kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		BatchTimeout: kafkaBatchTimeout,
+		WriteTimeout: kafkaWriteTimeout,
+		RequiredAcks: kafka.RequireAll,
+	}, topic)
+}
+
+func newKafkaJournal(writer messageWriter, topic string) *KafkaJournal {
+	return &KafkaJournal{writer: writer, topic: topic}
+}
+
+func (j *KafkaJournal) GetTopic() string {
+	return j.topic
+}
+
+func (j *KafkaJournal) Append(entry *Entry) error {
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("could not encode journal entry: %w", err)
+	}
+	ctx, cancelFn := context.WithTimeout(context.Background(), kafkaWriteTimeout)
+	defer cancelFn()
+	err = j.writer.WriteMessages(ctx, kafka.Message{Key: []byte(entry.Keyspace), Value: value})
+	if err != nil {
+		return fmt.Errorf("could not write to Kafka topic %v: %w", j.topic, err)
+	}
+	return nil
+}
+
+func (j *KafkaJournal) Close() error {
+	return j.writer.Close()
+}
+
+// PermanentError is returned by the function that applies an entry when the entry can never be applied, e.g. when
+// Target rejects the statement, so that the consumer skips the entry instead of retrying it.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// KafkaConsumer reads the entries of a Kafka topic as a member of a consumer group, the partitions of the topic are
+// shared by the proxy instances of the group.
+//
+// An entry is committed once it was applied so the entries are applied at least once: the entries that were applied
+// but not committed when a proxy stops are applied again by the proxy that takes over the partition. The operation ids
+// of the applied entries are kept in a DedupWindow so that the entries that are delivered again to the same proxy (the
+// offsets that could not be committed, the messages that the writer wrote twice when it retried a partially failed
+// write, etc.) are skipped instead of being applied twice.
+type KafkaConsumer struct {
+	reader      messageReader
+	topic       string
+	dedupWindow *DedupWindow
+	skipped     metrics.Counter
+	duplicates  metrics.Counter
+}
+
+func NewKafkaConsumer(
+	brokers []string, topic string, groupId string, dedupWindow *DedupWindow,
+	skipped metrics.Counter, duplicates metrics.Counter) *KafkaConsumer {
+	return newKafkaConsumer(kafka.NewReader(kafka.ReaderConfig{
+		Brokers:  brokers,
+		GroupID:  groupId,
+		Topic:    topic,
+		MaxBytes: kafkaReaderMaxBytes,
+		MaxWait:  kafkaReaderMaxWaitTime,
+	}), topic, dedupWindow, skipped, duplicates)
+}
+
+func newKafkaConsumer(
+	reader messageReader, topic string, dedupWindow *DedupWindow,
+	skipped metrics.Counter, duplicates metrics.Counter) *KafkaConsumer {
+	return &KafkaConsumer{
+		reader:      reader,
+		topic:       topic,
+		dedupWindow: dedupWindow,
+		skipped:     skipped,
+		duplicates:  duplicates,
+	}
+}
+
+// Run calls apply for each entry until ctx is done. apply is retried with backoff until it succeeds so that the
+// entries of a partition are applied in order, unless it returns a PermanentError in which case the entry is skipped
+// (and counted in the provided counter) like the entries that can't be decoded.
+func (c *KafkaConsumer) Run(ctx context.Context, apply func(entry *Entry) error) {
+	b := &backoff.Backoff{Min: kafkaRetryIntervalMin, Max: kafkaRetryIntervalMax, Factor: 2, Jitter: true}
+	for {
+		msg, err := c.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			nextDuration := b.Duration()
+			log.Warnf("Could not read from Kafka topic %v, retrying in %v: %v", c.topic, nextDuration, err)
+			if !sleepWithContext(ctx, nextDuration) {
+				return
+			}
+			continue
+		}
+		b.Reset()
+
+		entry := &Entry{}
+		err = json.Unmarshal(msg.Value, entry)
+		if err != nil {
+			log.Errorf("Skipping invalid journal entry at offset %d of partition %d of Kafka topic %v: %v",
+				msg.Offset, msg.Partition, c.topic, err)
+			c.skipped.Add(1)
+		} else if !c.apply(ctx, msg, entry, apply) {
+			return
+		}
+
+		err = c.reader.CommitMessages(ctx, msg)
+		if err != nil && ctx.Err() == nil {
+			log.Warnf("Could not commit offset %d of partition %d of Kafka topic %v, the entry will be applied again: %v",
+				msg.Offset, msg.Partition, c.topic, err)
+		}
+	}
+}
+
+// apply returns false if ctx was done before the entry could be applied.
+func (c *KafkaConsumer) apply(ctx context.Context, msg kafka.Message, entry *Entry, apply func(entry *Entry) error) bool {
+	if c.dedupWindow.Contains(entry.OperationId) {
+		log.Debugf("Skipping journal entry at offset %d of partition %d of Kafka topic %v, "+
+			"operation %v was already applied.", msg.Offset, msg.Partition, c.topic, entry.OperationId)
+		c.duplicates.Add(1)
+		return true
+	}
+	b := &backoff.Backoff{Min: kafkaRetryIntervalMin, Max: kafkaRetryIntervalMax, Factor: 2, Jitter: true}
+	for {
+		err := apply(entry)
+		if err == nil {
+			c.dedupWindow.Add(entry.OperationId)
+			return true
+		}
+		var permanentErr *PermanentError
+		if errors.As(err, &permanentErr) {
+			log.Errorf("Skipping journal entry at offset %d of partition %d of Kafka topic %v: %v",
+				msg.Offset, msg.Partition, c.topic, err)
+			c.skipped.Add(1)
+			return true
+		}
+		nextDuration := b.Duration()
+		log.Warnf("Could not apply journal entry at offset %d of partition %d of Kafka topic %v, retrying in %v: %v",
+			msg.Offset, msg.Partition, c.topic, nextDuration, err)
+		if !sleepWithContext(ctx, nextDuration) {
+			return false
+		}
+	}
+}
+
+func (c *KafkaConsumer) Close() error {
+	return c.reader.Close()
+}
+
+func sleepWithContext(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,203 @@
+package journal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeWriter struct {
+	lock     *sync.Mutex
+	messages []kafka.Message
+	err      error
+}
+
+func (recv *fakeWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	if recv.err != nil {
+		return recv.err
+	}
+	recv.messages = append(recv.messages, msgs...)
+	return nil
+}
+
+func (recv *fakeWriter) Close() error {
+	return nil
+}
+
+type fakeReader struct {
+	messages  chan kafka.Message
+	lock      *sync.Mutex
+	committed []int64
+}
+
+func newFakeReader(msgs ...kafka.Message) *fakeReader {
+	r := &fakeReader{messages: make(chan kafka.Message, len(msgs)), lock: &sync.Mutex{}}
+	for _, msg := range msgs {
+		r.messages <- msg
+	}
+	return r
+}
+
+func (recv *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	select {
+	case msg := <-recv.messages:
+		return msg, nil
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	}
+}
+
+func (recv *fakeReader) CommitMessages(_ context.Context, msgs ...kafka.Message) error {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	for _, msg := range msgs {
+		recv.committed = append(recv.committed, msg.Offset)
+	}
+	return nil
+}
+
+func (recv *fakeReader) getCommitted() []int64 {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	return append([]int64(nil), recv.committed...)
+}
+
+func (recv *fakeReader) Close() error {
+	return nil
+}
+
+type fakeCounter struct {
+	value int64
+}
+
+func (recv *fakeCounter) Add(valueToAdd int) {
+	atomic.AddInt64(&recv.value, int64(valueToAdd))
+}
+
+func (recv *fakeCounter) get() int {
+	return int(atomic.LoadInt64(&recv.value))
+}
+
+func TestKafkaJournal_Append(t *testing.T) {
+	writer := &fakeWriter{lock: &sync.Mutex{}}
+	j := newKafkaJournal(writer, "writes")
+	entry := &Entry{
+		Timestamp:  time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC),
+		Keyspace:   "ks",
+		OpCode:     "QUERY",
+		Statements: []string{"INSERT INTO tb (k) VALUES (1)"},
+		Frame:      []byte{0x04, 0x00, 0x00, 0x01, 0x07},
+	}
+	require.Nil(t, j.Append(entry))
+	require.Len(t, writer.messages, 1)
+	require.Equal(t, []byte("ks"), writer.messages[0].Key)
+	decoded := &Entry{}
+	require.Nil(t, json.Unmarshal(writer.messages[0].Value, decoded))
+	require.Equal(t, entry, decoded)
+
+	writer.err = errors.New("not enough replicas")
+	err := j.Append(entry)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "could not write to Kafka topic writes: not enough replicas")
+}
+
+func TestKafkaConsumer_Run(t *testing.T) {
+	newMessage := func(offset int64, statement string) kafka.Message {
+		value, err := json.Marshal(&Entry{Statements: []string{statement}})
+		require.Nil(t, err)
+		return kafka.Message{Offset: offset, Value: value}
+	}
+	reader := newFakeReader(
+		newMessage(0, "INSERT 0"),
+		newMessage(1, "INSERT 1"),
+		kafka.Message{Offset: 2, Value: []byte("{")},
+		newMessage(3, "INVALID 3"),
+		newMessage(4, "INSERT 4"))
+	skipped := &fakeCounter{}
+	consumer := newKafkaConsumer(reader, "writes", nil, skipped, &fakeCounter{})
+
+	var applied []string
+	failures := 0
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		consumer.Run(ctx, func(entry *Entry) error {
+			statement := entry.Statements[0]
+			switch {
+			case statement == "INSERT 1" && failures < 2:
+				// retried until it succeeds
+				failures++
+				return errors.New("write timeout")
+			case statement == "INVALID 3":
+				return &PermanentError{Err: errors.New("syntax error")}
+			}
+			applied = append(applied, statement)
+			if statement == "INSERT 4" {
+				cancelFn()
+			}
+			return nil
+		})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the consumer to apply the entries")
+	}
+	require.Equal(t, []string{"INSERT 0", "INSERT 1", "INSERT 4"}, applied)
+	require.Equal(t, 2, failures)
+	require.Equal(t, 2, skipped.get())
+	require.Equal(t, []int64{0, 1, 2, 3}, reader.getCommitted()[:4])
+}
+
+func TestKafkaConsumer_RunSkipsDuplicates(t *testing.T) {
+	newMessage := func(offset int64, operationId string, statement string) kafka.Message {
+		value, err := json.Marshal(&Entry{OperationId: operationId, Statements: []string{statement}})
+		require.Nil(t, err)
+		return kafka.Message{Offset: offset, Value: value}
+	}
+	reader := newFakeReader(
+		newMessage(0, "op1", "UPDATE 0"),
+		newMessage(1, "op2", "UPDATE 1"),
+		newMessage(2, "op1", "UPDATE 0"),
+		newMessage(3, "", "UPDATE 3"),
+		newMessage(4, "", "UPDATE 3"),
+		newMessage(5, "op3", "UPDATE 5"))
+	duplicates := &fakeCounter{}
+	consumer := newKafkaConsumer(reader, "writes", NewDedupWindow(10), &fakeCounter{}, duplicates)
+
+	var applied []string
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		consumer.Run(ctx, func(entry *Entry) error {
+			applied = append(applied, entry.Statements[0])
+			if entry.OperationId == "op3" {
+				cancelFn()
+			}
+			return nil
+		})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the consumer to apply the entries")
+	}
+	// the entries without operation id can't be deduplicated
+	require.Equal(t, []string{"UPDATE 0", "UPDATE 1", "UPDATE 3", "UPDATE 3", "UPDATE 5"}, applied)
+	require.Equal(t, 1, duplicates.get())
+	require.Equal(t, []int64{0, 1, 2, 3, 4}, reader.getCommitted()[:5])
+}
@@ -0,0 +1,168 @@
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// CompactionResult is the state of a file journal after a compaction. The dropped entries are lost, they can't be
+// applied to Target anymore.
+type CompactionResult struct {
+	SizeBytes           int64
+	Entries             int
+	ExpiredEntries      int
+	SizeExceededEntries int
+}
+
+// Compact removes the entries whose timestamp is older than maxAge at now and then the oldest entries until the file
+// is not larger than maxSizeBytes, a maxAge or maxSizeBytes of 0 disables the matching retention. The lines that can't
+// be decoded are kept.
+//
+// The file is rewritten to a temporary file next to it that replaces it once done, Append is only blocked while the
+// entries appended during the compaction are copied to the new file. The file is not rewritten if nothing is removed.
+func (j *FileJournal) Compact(maxSizeBytes int64, maxAge time.Duration, now time.Time) (*CompactionResult, error) {
+	j.compactionLock.Lock()
+	defer j.compactionLock.Unlock()
+
+	compactedSize, err := j.GetSizeBytes()
+	if err != nil {
+		return nil, err
+	}
+	// the first pass finds the entries to remove, the second one writes the other ones to the compacted file
+	dropped := map[int]bool{}
+	var lineSizes []int64
+	keptSize := int64(0)
+	err = scanLines(j.path, compactedSize, func(i int, line []byte) error {
+		lineSizes = append(lineSizes, int64(len(line))+1)
+		if maxAge > 0 {
+			// only the timestamp is decoded, not the statements and the frame
+			entry := &struct {
+				Timestamp time.Time `json:"timestamp"`
+			}{}
+			if json.Unmarshal(line, entry) == nil && !entry.Timestamp.IsZero() && now.Sub(entry.Timestamp) > maxAge {
+				dropped[i] = true
+				return nil
+			}
+		}
+		keptSize += int64(len(line)) + 1
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := &CompactionResult{ExpiredEntries: len(dropped)}
+	for i := 0; maxSizeBytes > 0 && keptSize > maxSizeBytes && i < len(lineSizes); i++ {
+		if !dropped[i] {
+			dropped[i] = true
+			keptSize -= lineSizes[i]
+			result.SizeExceededEntries++
+		}
+	}
+	result.Entries = len(lineSizes) - len(dropped)
+	if len(dropped) == 0 {
+		result.SizeBytes = compactedSize
+		return result, nil
+	}
+
+	tmpPath := j.path + ".compacting"
+	tmpFile, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("could not create compacted journal %v: %w", tmpPath, err)
+	}
+	defer func() {
+		if tmpFile != nil {
+			_ = tmpFile.Close()
+			_ = os.Remove(tmpPath)
+		}
+	}()
+	writer := bufio.NewWriter(tmpFile)
+	err = scanLines(j.path, compactedSize, func(i int, line []byte) error {
+		if dropped[i] {
+			return nil
+		}
+		if _, err := writer.Write(line); err != nil {
+			return err
+		}
+		return writer.WriteByte('\n')
+	})
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not write compacted journal %v: %w", tmpPath, err)
+	}
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	if j.file == nil {
+		return nil, fmt.Errorf("journal %v is closed", j.path)
+	}
+	// the entries that were appended since the compaction started
+	currentFile, err := os.Open(j.path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read journal %v: %w", j.path, err)
+	}
+	_, err = currentFile.Seek(compactedSize, io.SeekStart)
+	if err == nil {
+		_, err = io.Copy(tmpFile, currentFile)
+	}
+	_ = currentFile.Close()
+	if err != nil {
+		return nil, fmt.Errorf("could not copy the new entries of journal %v: %w", j.path, err)
+	}
+	if err = tmpFile.Sync(); err != nil {
+		return nil, fmt.Errorf("could not sync compacted journal %v: %w", tmpPath, err)
+	}
+	info, err := tmpFile.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("could not stat compacted journal %v: %w", tmpPath, err)
+	}
+	if err = tmpFile.Close(); err != nil {
+		return nil, fmt.Errorf("could not close compacted journal %v: %w", tmpPath, err)
+	}
+	tmpFile = nil
+	if err = os.Rename(tmpPath, j.path); err != nil {
+		_ = os.Remove(tmpPath)
+		return nil, fmt.Errorf("could not replace journal %v: %w", j.path, err)
+	}
+	file, err := os.OpenFile(j.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		// the previous file was renamed over, keeping it would lose the next entries
+		_ = j.file.Close()
+		j.file = nil
+		return nil, fmt.Errorf("could not open compacted journal %v: %w", j.path, err)
+	}
+	_ = j.file.Close()
+	j.file = file
+	result.SizeBytes = info.Size()
+	return result, nil
+}
+
+// scanLines calls onLine with the index and the content of each non empty line of the first size bytes of a file.
+func scanLines(path string, size int64, onLine func(i int, line []byte) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("could not read journal %v: %w", path, err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(io.LimitReader(file, size))
+	scanner.Buffer(make([]byte, 64*1024), 256*1024*1024)
+	for i := 0; scanner.Scan(); {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if err = onLine(i, scanner.Bytes()); err != nil {
+			return err
+		}
+		i++
+	}
+	if err = scanner.Err(); err != nil {
+		return fmt.Errorf("could not read journal %v: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package journal
+
+import (
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileJournal_Compact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	j, err := NewFileJournal(path)
+	require.Nil(t, err)
+	defer j.Close()
+
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 10; i++ {
+		// one entry per hour, the first one is 9 hours old
+		require.Nil(t, j.Append(&Entry{OperationId: string(rune('a' + i)), Timestamp: now.Add(time.Duration(i-9) * time.Hour)}))
+	}
+	size, err := j.GetSizeBytes()
+	require.Nil(t, err)
+	entrySize := size / 10
+
+	// nothing to remove
+	result, err := j.Compact(0, 24*time.Hour, now)
+	require.Nil(t, err)
+	require.Equal(t, &CompactionResult{SizeBytes: size, Entries: 10}, result)
+
+	result, err = j.Compact(0, 5*time.Hour+30*time.Minute, now)
+	require.Nil(t, err)
+	require.Equal(t, &CompactionResult{SizeBytes: 6 * entrySize, Entries: 6, ExpiredEntries: 4}, result)
+
+	result, err = j.Compact(4*entrySize+1, 0, now)
+	require.Nil(t, err)
+	require.Equal(t, &CompactionResult{SizeBytes: 4 * entrySize, Entries: 4, SizeExceededEntries: 2}, result)
+
+	// the journal is still appended to after a compaction
+	require.Nil(t, j.Append(&Entry{OperationId: "k", Timestamp: now}))
+	entries, err := ReadFile(path)
+	require.Nil(t, err)
+	var operationIds []string
+	for _, entry := range entries {
+		operationIds = append(operationIds, entry.OperationId)
+	}
+	require.Equal(t, []string{"g", "h", "i", "j", "k"}, operationIds)
+	_, err = os.Stat(path + ".compacting")
+	require.True(t, os.IsNotExist(err))
+
+	require.Nil(t, j.Close())
+	_, err = j.Compact(0, time.Hour, now)
+	require.NotNil(t, err)
+}
@@ -0,0 +1,160 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const rotatedFileTimeFormat = "20060102T150405.000"
+
+// RotatingFile is an io.Writer that appends to a file and renames it to <path>.<timestamp> when it reaches its
+// maximum size or when its rotation interval elapsed, so that the logs of a proxy that runs for months don't fill the
+// disk. Only the maxBackups most recent rotated files are kept.
+//
+// The rotation is checked on each write, a proxy that doesn't log anything keeps its file open past the interval.
+type RotatingFile struct {
+	path             string
+	maxSizeBytes     int64
+	rotationInterval time.Duration
+	maxBackups       int
+	now              func() time.Time
+
+	lock     *sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+// NewRotatingFile opens (or creates) the file at path, a maxSizeBytes or rotationInterval of 0 disables the matching
+// rotation and a maxBackups of 0 keeps all the rotated files.
+func NewRotatingFile(path string, maxSizeBytes int64, rotationInterval time.Duration, maxBackups int) (*RotatingFile, error) {
+	return newRotatingFile(path, maxSizeBytes, rotationInterval, maxBackups, time.Now)
+}
+
+func newRotatingFile(
+	path string, maxSizeBytes int64, rotationInterval time.Duration, maxBackups int,
+	now func() time.Time) (*RotatingFile, error) {
+	f := &RotatingFile{
+		path:             path,
+		maxSizeBytes:     maxSizeBytes,
+		rotationInterval: rotationInterval,
+		maxBackups:       maxBackups,
+		now:              now,
+		lock:             &sync.Mutex{},
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.shouldRotate(int64(len(p))) {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *RotatingFile) Close() error {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *RotatingFile) shouldRotate(writeSize int64) bool {
+	if f.size == 0 {
+		// an entry larger than the maximum size is written to its own file instead of rotating an empty one
+		return false
+	}
+	if f.maxSizeBytes > 0 && f.size+writeSize > f.maxSizeBytes {
+		return true
+	}
+	return f.rotationInterval > 0 && f.now().Sub(f.openedAt) >= f.rotationInterval
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("could not open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return fmt.Errorf("could not stat log file: %w", err)
+	}
+	f.file = file
+	f.size = info.Size()
+	// the modification time is not the creation time but it avoids rotating a file that was just written to on start
+	f.openedAt = f.now()
+	if f.size > 0 && info.ModTime().Before(f.openedAt) {
+		f.openedAt = info.ModTime()
+	}
+	return nil
+}
+
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return fmt.Errorf("could not close log file: %w", err)
+	}
+	f.file = nil
+	rotatedPath := f.path + "." + f.now().Format(rotatedFileTimeFormat)
+	if err := os.Rename(f.path, rotatedPath); err != nil {
+		return fmt.Errorf("could not rotate log file: %w", err)
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.removeOldBackups()
+	return nil
+}
+
+func (f *RotatingFile) removeOldBackups() {
+	if f.maxBackups <= 0 {
+		return
+	}
+	backups := f.getBackups()
+	if len(backups) <= f.maxBackups {
+		return
+	}
+	for _, backup := range backups[:len(backups)-f.maxBackups] {
+		// the error can't be logged since this is the logger, the file is removed on the next rotation
+		_ = os.Remove(backup)
+	}
+}
+
+// getBackups returns the rotated files from the oldest to the most recent one, the timestamp suffix sorts in the
+// order of the rotations.
+func (f *RotatingFile) getBackups() []string {
+	matches, err := filepath.Glob(f.path + ".*")
+	if err != nil {
+		return nil
+	}
+	var backups []string
+	prefix := f.path + "."
+	for _, match := range matches {
+		if _, err := time.Parse(rotatedFileTimeFormat, strings.TrimPrefix(match, prefix)); err == nil {
+			backups = append(backups, match)
+		}
+	}
+	sort.Strings(backups)
+	return backups
+}
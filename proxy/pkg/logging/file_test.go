@@ -0,0 +1,85 @@
+package logging
+
+import (
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile_RotatesWhenMaxSizeIsReached(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zdm-proxy.log")
+	now := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+	clock := func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+	f, err := newRotatingFile(path, 10, 0, 2, clock)
+	require.Nil(t, err)
+	defer f.Close()
+
+	for _, entry := range []string{"entry 1\n", "entry 2\n", "entry 3\n", "entry 4\n"} {
+		_, err = f.Write([]byte(entry))
+		require.Nil(t, err)
+	}
+
+	content, err := os.ReadFile(path)
+	require.Nil(t, err)
+	require.Equal(t, "entry 4\n", string(content))
+	backups := f.getBackups()
+	require.Equal(t, 2, len(backups))
+	content, err = os.ReadFile(backups[0])
+	require.Nil(t, err)
+	require.Equal(t, "entry 2\n", string(content))
+	content, err = os.ReadFile(backups[1])
+	require.Nil(t, err)
+	require.Equal(t, "entry 3\n", string(content))
+}
+
+func TestRotatingFile_RotatesWhenIntervalElapsed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zdm-proxy.log")
+	now := time.Now()
+	clock := func() time.Time {
+		return now
+	}
+	f, err := newRotatingFile(path, 0, time.Hour, 0, clock)
+	require.Nil(t, err)
+	defer f.Close()
+
+	_, err = f.Write([]byte("entry 1\n"))
+	require.Nil(t, err)
+	now = now.Add(59 * time.Minute)
+	_, err = f.Write([]byte("entry 2\n"))
+	require.Nil(t, err)
+	require.Empty(t, f.getBackups())
+
+	now = now.Add(time.Minute)
+	_, err = f.Write([]byte("entry 3\n"))
+	require.Nil(t, err)
+	backups := f.getBackups()
+	require.Equal(t, []string{path + "." + now.Format(rotatedFileTimeFormat)}, backups)
+	content, err := os.ReadFile(backups[0])
+	require.Nil(t, err)
+	require.Equal(t, "entry 1\nentry 2\n", string(content))
+	content, err = os.ReadFile(path)
+	require.Nil(t, err)
+	require.Equal(t, "entry 3\n", string(content))
+}
+
+func TestRotatingFile_AppendsToExistingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zdm-proxy.log")
+	require.Nil(t, os.WriteFile(path, []byte("previous run\n"), 0644))
+
+	f, err := NewRotatingFile(path, 1024, 0, 0)
+	require.Nil(t, err)
+	_, err = f.Write([]byte("entry\n"))
+	require.Nil(t, err)
+	require.Nil(t, f.Close())
+
+	content, err := os.ReadFile(path)
+	require.Nil(t, err)
+	require.Equal(t, "previous run\nentry\n", string(content))
+	_, err = f.Write([]byte("entry\n"))
+	require.ErrorIs(t, err, os.ErrClosed)
+}
@@ -0,0 +1,41 @@
+// Package logging writes the logs of the proxy to a rotated file or to a syslog server instead of the standard error,
+// for the hosts that don't run a log collector.
+package logging
+
+import (
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"os"
+)
+
+// Install sets the output (and the formatter for syslog) of the global logger according to ZDM_LOG_OUTPUT. It returns
+// the writer that must be closed when the process exits, nil if the logs are written to the standard error.
+func Install(conf *config.Config) (io.Closer, error) {
+	logOutputConfig, err := conf.ParseLogOutputConfig()
+	if err != nil {
+		return nil, err
+	}
+	switch logOutputConfig.Output {
+	case common.LogOutputFile:
+		file, err := NewRotatingFile(logOutputConfig.FilePath, logOutputConfig.FileMaxSizeBytes,
+			logOutputConfig.FileRotationInterval, logOutputConfig.FileMaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		log.SetOutput(file)
+		return file, nil
+	case common.LogOutputSyslog:
+		writer, err := NewSyslogWriter(logOutputConfig.SyslogNetwork, logOutputConfig.SyslogAddress)
+		if err != nil {
+			return nil, err
+		}
+		log.SetFormatter(NewSyslogFormatter(logOutputConfig.SyslogFacility, logOutputConfig.SyslogAppName))
+		log.SetOutput(writer)
+		return writer, nil
+	default:
+		log.SetOutput(os.Stderr)
+		return nil, nil
+	}
+}
@@ -0,0 +1,143 @@
+package logging
+
+import (
+	"bytes"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	syslogDialTimeout  = 5 * time.Second
+	syslogWriteTimeout = 5 * time.Second
+)
+
+// severities of RFC5424, the panics are logged right before the process exits like the fatal entries
+var syslogSeverities = map[log.Level]int{
+	log.PanicLevel: 1, // alert
+	log.FatalLevel: 2, // critical
+	log.ErrorLevel: 3,
+	log.WarnLevel:  4,
+	log.InfoLevel:  6,
+	log.DebugLevel: 7,
+	log.TraceLevel: 7,
+}
+
+// SyslogFormatter formats the log entries as RFC5424 syslog messages, the message is the output of the logrus text
+// formatter (without its timestamp since the header has it) so that the fields are kept.
+type SyslogFormatter struct {
+	facility int
+	hostname string
+	appName  string
+	procId   string
+	message  *log.TextFormatter
+}
+
+func NewSyslogFormatter(facility int, appName string) *SyslogFormatter {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	return &SyslogFormatter{
+		facility: facility,
+		hostname: hostname,
+		appName:  appName,
+		procId:   strconv.Itoa(os.Getpid()),
+		message:  &log.TextFormatter{DisableTimestamp: true, DisableColors: true},
+	}
+}
+
+func (f *SyslogFormatter) Format(entry *log.Entry) ([]byte, error) {
+	message, err := f.message.Format(entry)
+	if err != nil {
+		return nil, err
+	}
+	severity, ok := syslogSeverities[entry.Level]
+	if !ok {
+		severity = 6
+	}
+	// <PRI>VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG, without MSGID and STRUCTURED-DATA
+	header := fmt.Sprintf("<%d>1 %v %v %v %v - - ", f.facility*8+severity,
+		entry.Time.UTC().Format("2006-01-02T15:04:05.000000Z07:00"), f.hostname, f.appName, f.procId)
+	return append([]byte(header), bytes.TrimRight(message, "\n")...), nil
+}
+
+// SyslogWriter sends each write (one formatted entry) to a syslog server as a message. The messages sent over TCP
+// are framed with octet counting (RFC6587). The connection is established again on the next write when it fails, the
+// entries that could not be sent are dropped so that logging never blocks the proxy for long.
+type SyslogWriter struct {
+	network string
+	address string
+
+	lock *sync.Mutex
+	conn net.Conn
+}
+
+// NewSyslogWriter connects to the syslog server, network is udp, tcp or unix (datagram or stream socket).
+func NewSyslogWriter(network string, address string) (*SyslogWriter, error) {
+	w := &SyslogWriter{network: network, address: address, lock: &sync.Mutex{}}
+	conn, err := w.dial()
+	if err != nil {
+		return nil, err
+	}
+	w.conn = conn
+	return w, nil
+}
+
+func (w *SyslogWriter) dial() (net.Conn, error) {
+	if w.network != "unix" {
+		conn, err := net.DialTimeout(w.network, w.address, syslogDialTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("could not connect to syslog server %v://%v: %w", w.network, w.address, err)
+		}
+		return conn, nil
+	}
+	// /dev/log is usually a datagram socket
+	conn, err := net.DialTimeout("unixgram", w.address, syslogDialTimeout)
+	if err == nil {
+		return conn, nil
+	}
+	conn, err = net.DialTimeout("unix", w.address, syslogDialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("could not connect to syslog socket %v: %w", w.address, err)
+	}
+	return conn, nil
+}
+
+func (w *SyslogWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.conn == nil {
+		conn, err := w.dial()
+		if err != nil {
+			return 0, err
+		}
+		w.conn = conn
+	}
+	message := p
+	if w.conn.LocalAddr().Network() == "tcp" || w.conn.LocalAddr().Network() == "unix" {
+		message = append([]byte(strconv.Itoa(len(p))+" "), p...)
+	}
+	_ = w.conn.SetWriteDeadline(time.Now().Add(syslogWriteTimeout))
+	if _, err := w.conn.Write(message); err != nil {
+		_ = w.conn.Close()
+		w.conn = nil
+		return 0, fmt.Errorf("could not send log entry to syslog server: %w", err)
+	}
+	return len(p), nil
+}
+
+func (w *SyslogWriter) Close() error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
@@ -0,0 +1,95 @@
+package logging
+
+import (
+	"bufio"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSyslogFormatter_Format(t *testing.T) {
+	formatter := NewSyslogFormatter(16, "zdm-proxy")
+	entry := &log.Entry{
+		Logger:  log.New(),
+		Time:    time.Date(2026, 10, 14, 9, 30, 0, 123456000, time.UTC),
+		Level:   log.WarnLevel,
+		Message: "Target is down",
+		Data:    log.Fields{"cluster": "TARGET"},
+	}
+
+	formatted, err := formatter.Format(entry)
+	require.Nil(t, err)
+	hostname, _ := os.Hostname()
+	require.Equal(t, fmt.Sprintf(
+		`<132>1 2026-10-14T09:30:00.123456Z %v zdm-proxy %d - - level=warning msg="Target is down" cluster=TARGET`,
+		hostname, os.Getpid()), string(formatted))
+}
+
+func TestSyslogWriter_Udp(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer server.Close()
+
+	writer, err := NewSyslogWriter("udp", server.LocalAddr().String())
+	require.Nil(t, err)
+	defer writer.Close()
+	_, err = writer.Write([]byte("<134>1 message"))
+	require.Nil(t, err)
+
+	buf := make([]byte, 1024)
+	_ = server.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := server.ReadFrom(buf)
+	require.Nil(t, err)
+	require.Equal(t, "<134>1 message", string(buf[:n]))
+}
+
+func TestSyslogWriter_TcpOctetCounting(t *testing.T) {
+	server, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer server.Close()
+	received := make(chan string, 2)
+	go func() {
+		conn, err := server.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for {
+			length, err := reader.ReadString(' ')
+			if err != nil {
+				return
+			}
+			var n int
+			_, _ = fmt.Sscanf(strings.TrimSpace(length), "%d", &n)
+			message := make([]byte, n)
+			if _, err = io.ReadFull(reader, message); err != nil {
+				return
+			}
+			received <- string(message)
+		}
+	}()
+
+	writer, err := NewSyslogWriter("tcp", server.Addr().String())
+	require.Nil(t, err)
+	defer writer.Close()
+	_, err = writer.Write([]byte("<134>1 first message"))
+	require.Nil(t, err)
+	_, err = writer.Write([]byte("<134>1 second"))
+	require.Nil(t, err)
+
+	for _, expected := range []string{"<134>1 first message", "<134>1 second"} {
+		select {
+		case message := <-received:
+			require.Equal(t, expected, message)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "timed out waiting for syslog message")
+		}
+	}
+}
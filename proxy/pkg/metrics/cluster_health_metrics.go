@@ -0,0 +1,83 @@
+package metrics
+
+import "sync"
+
+const (
+	clusterHealthStateName        = "cluster_health_state"
+	clusterHealthStateDescription = "1 for the current health state of a cluster, 0 for the other states"
+
+	clusterHealthTransitionsName        = "cluster_health_transitions_total"
+	clusterHealthTransitionsDescription = "Running total of the transitions of the health state of a cluster by new state and reason"
+
+	clusterHealthClusterLabel = "cluster"
+	clusterHealthStateLabel   = "state"
+	clusterHealthReasonLabel  = "reason"
+
+	ClusterHealthOrigin = "origin"
+	ClusterHealthTarget = "target"
+)
+
+var (
+	ClusterHealthState       = NewMetric(clusterHealthStateName, clusterHealthStateDescription)
+	ClusterHealthTransitions = NewMetric(clusterHealthTransitionsName, clusterHealthTransitionsDescription)
+)
+
+// ClusterHealthMetrics are the metrics of the health state of a cluster, the transition counters are created the first
+// time a cluster enters a state for a reason.
+type ClusterHealthMetrics struct {
+	metricFactory MetricFactory
+	cluster       string
+	states        map[string]Gauge
+
+	lock        *sync.Mutex
+	transitions map[[2]string]Counter
+}
+
+func CreateClusterHealthMetrics(
+	metricFactory MetricFactory, cluster string, states []string) (*ClusterHealthMetrics, error) {
+	m := &ClusterHealthMetrics{
+		metricFactory: metricFactory,
+		cluster:       cluster,
+		states:        make(map[string]Gauge, len(states)),
+		lock:          &sync.Mutex{},
+		transitions:   map[[2]string]Counter{},
+	}
+	for _, state := range states {
+		gauge, err := metricFactory.GetOrCreateGauge(ClusterHealthState.WithLabels(
+			map[string]string{clusterHealthClusterLabel: cluster, clusterHealthStateLabel: state}))
+		if err != nil {
+			return nil, err
+		}
+		m.states[state] = gauge
+	}
+	return m, nil
+}
+
+// RecordTransition sets the state gauge of the new state to 1, the others to 0, and counts the transition.
+func (m *ClusterHealthMetrics) RecordTransition(state string, reason string) error {
+	if m == nil {
+		return nil
+	}
+	for s, gauge := range m.states {
+		if s == state {
+			gauge.Set(1)
+		} else {
+			gauge.Set(0)
+		}
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	counter, ok := m.transitions[[2]string{state, reason}]
+	if !ok {
+		var err error
+		counter, err = m.metricFactory.GetOrCreateCounter(ClusterHealthTransitions.WithLabels(map[string]string{
+			clusterHealthClusterLabel: m.cluster, clusterHealthStateLabel: state, clusterHealthReasonLabel: reason}))
+		if err != nil {
+			return err
+		}
+		m.transitions[[2]string{state, reason}] = counter
+	}
+	counter.Add(1)
+	return nil
+}
@@ -16,5 +16,8 @@ type GaugeFunc interface {
 }
 
 type Histogram interface {
+	// Track observes the time elapsed since begin, in seconds
 	Track(begin time.Time)
+	// Observe observes a value that is not a duration, e.g. a size in bytes
+	Observe(value float64)
 }
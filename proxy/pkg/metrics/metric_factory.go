@@ -4,7 +4,9 @@ import (
 	"net/http"
 )
 
-type MetricFactory interface {
+// MetricsSink is a metrics backend. The proxy creates all its metrics through this interface so custom backends can
+// be added, see NewMultiSinkMetricFactory.
+type MetricsSink interface {
 	GetOrCreateCounter(mn Metric) (Counter, error)
 	GetOrCreateGauge(mn Metric) (Gauge, error)
 	GetOrCreateGaugeFunc(mn Metric, mf func() float64) (GaugeFunc, error)
@@ -13,6 +15,10 @@ type MetricFactory interface {
 	// Unregisters all registered metrics and discards all internal references to them.
 	// An error is returned if at least one metric could not be unregistered.
 	UnregisterAllMetrics() error
+}
+
+type MetricFactory interface {
+	MetricsSink
 
 	// Returns the http handler implementation for the metrics endpoint.
 	HttpHandler() http.Handler
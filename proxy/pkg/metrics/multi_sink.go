@@ -0,0 +1,137 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type multiSinkMetricFactory struct {
+	primary MetricFactory
+	sinks   []MetricsSink
+}
+
+// NewMultiSinkMetricFactory returns a MetricFactory that records every metric in the primary factory and in all the
+// provided sinks. The metrics endpoint is still served by the primary factory.
+func NewMultiSinkMetricFactory(primary MetricFactory, sinks ...MetricsSink) MetricFactory {
+	if len(sinks) == 0 {
+		return primary
+	}
+	return &multiSinkMetricFactory{
+		primary: primary,
+		sinks:   sinks,
+	}
+}
+
+func (recv *multiSinkMetricFactory) allSinks() []MetricsSink {
+	return append([]MetricsSink{recv.primary}, recv.sinks...)
+}
+
+func (recv *multiSinkMetricFactory) GetOrCreateCounter(mn Metric) (Counter, error) {
+	counters := make(multiCounter, 0, len(recv.sinks)+1)
+	for _, sink := range recv.allSinks() {
+		c, err := sink.GetOrCreateCounter(mn)
+		if err != nil {
+			return nil, err
+		}
+		counters = append(counters, c)
+	}
+	return counters, nil
+}
+
+func (recv *multiSinkMetricFactory) GetOrCreateGauge(mn Metric) (Gauge, error) {
+	gauges := make(multiGauge, 0, len(recv.sinks)+1)
+	for _, sink := range recv.allSinks() {
+		g, err := sink.GetOrCreateGauge(mn)
+		if err != nil {
+			return nil, err
+		}
+		gauges = append(gauges, g)
+	}
+	return gauges, nil
+}
+
+func (recv *multiSinkMetricFactory) GetOrCreateGaugeFunc(mn Metric, mf func() float64) (GaugeFunc, error) {
+	gaugeFuncs := make([]GaugeFunc, 0, len(recv.sinks)+1)
+	for _, sink := range recv.allSinks() {
+		gf, err := sink.GetOrCreateGaugeFunc(mn, mf)
+		if err != nil {
+			return nil, err
+		}
+		gaugeFuncs = append(gaugeFuncs, gf)
+	}
+	return gaugeFuncs, nil
+}
+
+func (recv *multiSinkMetricFactory) GetOrCreateHistogram(mn Metric, buckets []float64) (Histogram, error) {
+	histograms := make(multiHistogram, 0, len(recv.sinks)+1)
+	for _, sink := range recv.allSinks() {
+		h, err := sink.GetOrCreateHistogram(mn, buckets)
+		if err != nil {
+			return nil, err
+		}
+		histograms = append(histograms, h)
+	}
+	return histograms, nil
+}
+
+// UnregisterAllMetrics unregisters the metrics of every sink even if some of them return an error.
+func (recv *multiSinkMetricFactory) UnregisterAllMetrics() error {
+	var errMsgs []string
+	for _, sink := range recv.allSinks() {
+		if err := sink.UnregisterAllMetrics(); err != nil {
+			errMsgs = append(errMsgs, err.Error())
+		}
+	}
+	if len(errMsgs) > 0 {
+		return fmt.Errorf("failed to unregister metrics of %d sink(s): %v", len(errMsgs), strings.Join(errMsgs, "; "))
+	}
+	return nil
+}
+
+func (recv *multiSinkMetricFactory) HttpHandler() http.Handler {
+	return recv.primary.HttpHandler()
+}
+
+type multiCounter []Counter
+
+func (recv multiCounter) Add(valueToAdd int) {
+	for _, c := range recv {
+		c.Add(valueToAdd)
+	}
+}
+
+type multiGauge []Gauge
+
+func (recv multiGauge) Add(valueToAdd int) {
+	for _, g := range recv {
+		g.Add(valueToAdd)
+	}
+}
+
+func (recv multiGauge) Subtract(valueToSubtract int) {
+	for _, g := range recv {
+		g.Subtract(valueToSubtract)
+	}
+}
+
+func (recv multiGauge) Set(valueToSet int) {
+	for _, g := range recv {
+		g.Set(valueToSet)
+	}
+}
+
+type multiHistogram []Histogram
+
+func (recv multiHistogram) Track(begin time.Time) {
+	for _, h := range recv {
+		h.Track(begin)
+	}
+}
+
+func (recv multiHistogram) Observe(value float64) {
+	for _, h := range recv {
+		h.Observe(value)
+	}
+}
@@ -0,0 +1,113 @@
+package metrics_test
+
+import (
+	"errors"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics/noopmetrics"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type recordingSink struct {
+	values        map[string]int
+	histograms    map[string]int
+	gaugeFuncs    map[string]func() float64
+	unregisterErr error
+}
+
+func newRecordingSink() *recordingSink {
+	return &recordingSink{
+		values:     map[string]int{},
+		histograms: map[string]int{},
+		gaugeFuncs: map[string]func() float64{},
+	}
+}
+
+type recordingMetric struct {
+	name string
+	sink *recordingSink
+}
+
+func (recv *recordingMetric) Add(valueToAdd int) {
+	recv.sink.values[recv.name] += valueToAdd
+}
+
+func (recv *recordingMetric) Subtract(valueToSubtract int) {
+	recv.sink.values[recv.name] -= valueToSubtract
+}
+
+func (recv *recordingMetric) Set(valueToSet int) {
+	recv.sink.values[recv.name] = valueToSet
+}
+
+func (recv *recordingMetric) Track(begin time.Time) {
+	recv.sink.histograms[recv.name]++
+}
+
+func (recv *recordingMetric) Observe(value float64) {
+	recv.sink.histograms[recv.name]++
+}
+
+func (recv *recordingSink) GetOrCreateCounter(mn metrics.Metric) (metrics.Counter, error) {
+	return &recordingMetric{name: mn.String(), sink: recv}, nil
+}
+
+func (recv *recordingSink) GetOrCreateGauge(mn metrics.Metric) (metrics.Gauge, error) {
+	return &recordingMetric{name: mn.String(), sink: recv}, nil
+}
+
+func (recv *recordingSink) GetOrCreateGaugeFunc(mn metrics.Metric, mf func() float64) (metrics.GaugeFunc, error) {
+	recv.gaugeFuncs[mn.String()] = mf
+	return mf, nil
+}
+
+func (recv *recordingSink) GetOrCreateHistogram(mn metrics.Metric, buckets []float64) (metrics.Histogram, error) {
+	return &recordingMetric{name: mn.String(), sink: recv}, nil
+}
+
+func (recv *recordingSink) UnregisterAllMetrics() error {
+	return recv.unregisterErr
+}
+
+func TestMultiSinkMetricFactory(t *testing.T) {
+	sink1 := newRecordingSink()
+	sink2 := newRecordingSink()
+	factory := metrics.NewMultiSinkMetricFactory(noopmetrics.NewNoopMetricFactory(), sink1, sink2)
+
+	counter, err := factory.GetOrCreateCounter(metrics.NewMetric("counter", "test counter"))
+	require.Nil(t, err)
+	counter.Add(2)
+	counter.Add(3)
+
+	gauge, err := factory.GetOrCreateGauge(metrics.NewMetricWithLabels("gauge", "test gauge", map[string]string{"l": "v"}))
+	require.Nil(t, err)
+	gauge.Set(10)
+	gauge.Subtract(4)
+	gauge.Add(1)
+
+	histogram, err := factory.GetOrCreateHistogram(metrics.NewMetric("histogram", "test histogram"), []float64{1})
+	require.Nil(t, err)
+	histogram.Track(time.Now())
+	histogram.Observe(512)
+
+	_, err = factory.GetOrCreateGaugeFunc(metrics.NewMetric("gauge_func", "test gauge func"), func() float64 { return 7 })
+	require.Nil(t, err)
+
+	for _, sink := range []*recordingSink{sink1, sink2} {
+		require.Equal(t, map[string]int{"counter": 5, "gauge{l=\"v\"}": 7}, sink.values)
+		require.Equal(t, map[string]int{"histogram": 2}, sink.histograms)
+		require.Equal(t, float64(7), sink.gaugeFuncs["gauge_func"]())
+	}
+
+	require.Nil(t, factory.UnregisterAllMetrics())
+	sink1.unregisterErr = errors.New("sink1 failure")
+	err = factory.UnregisterAllMetrics()
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "sink1 failure")
+}
+
+func TestMultiSinkMetricFactory_NoSinks(t *testing.T) {
+	primary := noopmetrics.NewNoopMetricFactory()
+	require.Same(t, primary, metrics.NewMultiSinkMetricFactory(primary))
+}
@@ -245,6 +245,14 @@ var (
 		"Number of connections currently open for async requests",
 	)
 
+	InFlightRequestsOrigin = NewMetric(
+		"origin_inflight_requests_total",
+		"Number of requests currently in flight on Origin Cassandra",
+	)
+	InFlightRequestsTarget = NewMetric(
+		"target_inflight_requests_total",
+		"Number of requests currently in flight on Target Cassandra",
+	)
 	InFlightRequestsAsync = NewMetric(
 		"async_inflight_requests_total",
 		"Number of async requests currently in flight",
@@ -261,6 +269,26 @@ var (
 	AsyncUsedStreamIds = NewMetric(
 		"async_used_stream_ids_total",
 		"Number of used stream ids in Async connections")
+
+	OriginStuckConnections = NewMetric(
+		"origin_stuck_connections_total",
+		"Running total of Origin connections that were closed because they stopped receiving responses")
+
+	TargetStuckConnections = NewMetric(
+		"target_stuck_connections_total",
+		"Running total of Target connections that were closed because they stopped receiving responses")
+
+	AsyncStuckConnections = NewMetric(
+		"async_stuck_connections_total",
+		"Running total of Async connections that were closed because they stopped receiving responses")
+
+	OriginConnectionLossFailedRequests = NewMetric(
+		"origin_connection_loss_failed_requests_total",
+		"Running total of in flight requests that failed with an OVERLOADED error because an Origin connection was lost")
+
+	TargetConnectionLossFailedRequests = NewMetric(
+		"target_connection_loss_failed_requests_total",
+		"Running total of in flight requests that failed with an OVERLOADED error because a Target connection was lost")
 )
 
 type NodeMetrics struct {
@@ -287,6 +315,11 @@ type NodeMetricsInstance struct {
 	InFlightRequests Gauge
 
 	UsedStreamIds Gauge
+
+	StuckConnections Counter
+
+	// nil for the async connector, its loss doesn't affect the requests of the client
+	ConnectionLossFailedRequests Counter
 }
 
 func CreateCounterNodeMetric(metricFactory MetricFactory, nodeDescription string, mn Metric) (Counter, error) {
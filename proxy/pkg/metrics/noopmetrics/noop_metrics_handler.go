@@ -48,3 +48,5 @@ func (recv *NoopMetric) Subtract(val int) {}
 func (recv *NoopMetric) Set(val int) {}
 
 func (recv *NoopMetric) Track(begin time.Time) {}
+
+func (recv *NoopMetric) Observe(value float64) {}
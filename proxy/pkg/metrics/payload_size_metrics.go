@@ -0,0 +1,37 @@
+package metrics
+
+const (
+	requestSizeName        = "request_size_bytes"
+	requestSizeDescription = "Histogram that tracks the size of the request payloads sent to a cluster per statement type"
+
+	responseSizeName        = "response_size_bytes"
+	responseSizeDescription = "Histogram that tracks the size of the response payloads returned by a cluster per statement type"
+
+	payloadSizeClusterLabel       = "cluster"
+	payloadSizeStatementTypeLabel = "statement_type"
+)
+
+var (
+	RequestSize  = NewMetric(requestSizeName, requestSizeDescription)
+	ResponseSize = NewMetric(responseSizeName, responseSizeDescription)
+)
+
+// PayloadSizeMetrics are the size metrics of the requests of a statement type on a cluster.
+type PayloadSizeMetrics struct {
+	RequestSize  Histogram
+	ResponseSize Histogram
+}
+
+func CreatePayloadSizeMetrics(
+	metricFactory MetricFactory, cluster string, statementType string, buckets []float64) (*PayloadSizeMetrics, error) {
+	labels := map[string]string{payloadSizeClusterLabel: cluster, payloadSizeStatementTypeLabel: statementType}
+	requestSize, err := metricFactory.GetOrCreateHistogram(RequestSize.WithLabels(labels), buckets)
+	if err != nil {
+		return nil, err
+	}
+	responseSize, err := metricFactory.GetOrCreateHistogram(ResponseSize.WithLabels(labels), buckets)
+	if err != nil {
+		return nil, err
+	}
+	return &PayloadSizeMetrics{RequestSize: requestSize, ResponseSize: responseSize}, nil
+}
@@ -42,3 +42,7 @@ func (recv *PrometheusHistogram) Track(begin time.Time) {
 	elapsedTimeInSeconds := float64(time.Since(begin)) / float64(time.Second)
 	recv.h.Observe(elapsedTimeInSeconds)
 }
+
+func (recv *PrometheusHistogram) Observe(value float64) {
+	recv.h.Observe(value)
+}
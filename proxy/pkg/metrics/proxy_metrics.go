@@ -1,5 +1,7 @@
 package metrics
 
+import "github.com/datastax/zdm-proxy/proxy/pkg/buildinfo"
+
 const (
 	typeReadsOrigin = "reads_origin"
 	typeReadsTarget = "reads_target"
@@ -24,6 +26,70 @@ const (
 	inFlightRequestsName        = "proxy_inflight_requests_total"
 	inFlightRequestsTypeLabel   = "type"
 	inFlightRequestsDescription = "Number of requests currently in flight in the proxy"
+
+	memoryPressureRejectionsName        = "proxy_memory_pressure_rejections_total"
+	memoryPressureRejectionsTypeLabel   = "type"
+	memoryPressureRejectionsDescription = "Running total of client connections and requests rejected because the heap usage exceeded the soft limit"
+	memoryPressureRejectionsConnections = "connections"
+	memoryPressureRejectionsRequests    = "requests"
+
+	guardrailWarningsName          = "guardrail_warnings_total"
+	guardrailWarningsDescription   = "Running total of writes that exceeded a warn threshold of the guardrails"
+	guardrailRejectionsName        = "guardrail_rejections_total"
+	guardrailRejectionsDescription = "Running total of writes that were rejected because they exceeded a fail threshold of the guardrails"
+	guardrailLabel                 = "guardrail"
+	guardrailBatchSize             = "batch_size"
+	guardrailBatchStatements       = "batch_statements"
+	guardrailMutationSize          = "mutation_size"
+	guardrailQueryLength           = "query_length"
+	guardrailValuesSize            = "values_size"
+
+	clusterWarningsName         = "cluster_warnings_total"
+	clusterWarningsDescription  = "Running total of distinct warnings that the clusters returned for the requests sent to both of them"
+	clusterWarningsClusterLabel = "cluster"
+
+	translatedSecondaryErrorsName        = "translated_secondary_errors_total"
+	translatedSecondaryErrorsDescription = "Running total of writes that only failed on the secondary cluster and whose error was not returned to the client because of ZDM_SECONDARY_ERROR_POLICY"
+	translatedSecondaryErrorsActionLabel = "action"
+
+	dualWriteResponsePolicyName        = "dual_write_response_policy_total"
+	dualWriteResponsePolicyDescription = "Running total of writes that only failed on one cluster and whose response was chosen by ZDM_DUAL_WRITE_RESPONSE_POLICY"
+	dualWriteResponsePolicyLabel       = "policy"
+
+	lwtRequestsName                         = "lwt_requests_total"
+	lwtRequestsDescription                  = "Running total of lightweight transactions that got a response from a cluster"
+	lwtNotAppliedName                       = "lwt_not_applied_total"
+	lwtNotAppliedDescription                = "Running total of lightweight transactions whose condition was not met on a cluster ([applied] = false)"
+	lwtSerialConsistencyRemappedName        = "lwt_serial_consistency_remapped_total"
+	lwtSerialConsistencyRemappedDescription = "Running total of lightweight transactions whose serial consistency was replaced before they were sent to a cluster"
+	lwtClusterLabel                         = "cluster"
+
+	clusterClockOffsetName        = "cluster_clock_offset_ms"
+	clusterClockOffsetDescription = "Offset in milliseconds of the clock of the probed node of a cluster relative to the clock of the proxy"
+	clusterClockOffsetLabel       = "cluster"
+
+	asyncReadDivergencesName        = "async_read_divergences_total"
+	asyncReadDivergencesDescription = "Running total of async reads whose response differed from the response of the primary cluster"
+	asyncReadDivergencesKindLabel   = "kind"
+	asyncReadDivergenceOutcome      = "outcome"
+	asyncReadDivergenceRowCount     = "row_count"
+	asyncReadDivergenceResultSize   = "result_size"
+)
+
+const (
+	clientHandlerGoroutinesName        = "client_handler_goroutines"
+	clientHandlerGoroutinesDescription = "Number of goroutines of the client handlers at the last goroutine audit, the goroutines of the closed client handlers should all have exited"
+	clientHandlerGoroutinesStateLabel  = "state"
+	clientHandlerGoroutinesStateOpen   = "open"
+	clientHandlerGoroutinesStateClosed = "closed"
+)
+
+const (
+	originOnlyJournalDroppedEntriesName        = "origin_only_journal_dropped_entries_total"
+	originOnlyJournalDroppedEntriesDescription = "Running total of journaled writes that were dropped by the retention of the journal, they are never applied to TARGET"
+	originOnlyJournalDroppedReasonLabel        = "reason"
+	originOnlyJournalDroppedReasonAge          = "age"
+	originOnlyJournalDroppedReasonSize         = "size"
 )
 
 var (
@@ -120,6 +186,408 @@ var (
 		"client_connections_total",
 		"Number of client connections currently open",
 	)
+
+	HandshakesInProgress = NewMetric(
+		"client_handshakes_in_progress",
+		"Number of client connections holding a handshake slot, see ZDM_PROXY_MAX_CONCURRENT_HANDSHAKES",
+	)
+	HandshakeQueueTimeouts = NewMetric(
+		"client_handshake_queue_timeouts_total",
+		"Running total of client connections closed because they waited too long for a handshake slot",
+	)
+
+	OpenOriginControlConnections = NewMetric(
+		"origin_control_connections_total",
+		"Number of control connections to Origin Cassandra currently open",
+	)
+	OpenTargetControlConnections = NewMetric(
+		"target_control_connections_total",
+		"Number of control connections to Target Cassandra currently open",
+	)
+
+	MemoryPressureRejectedConnections = NewMetricWithLabels(
+		memoryPressureRejectionsName,
+		memoryPressureRejectionsDescription,
+		map[string]string{
+			memoryPressureRejectionsTypeLabel: memoryPressureRejectionsConnections,
+		},
+	)
+	MemoryPressureRejectedRequests = NewMetricWithLabels(
+		memoryPressureRejectionsName,
+		memoryPressureRejectionsDescription,
+		map[string]string{
+			memoryPressureRejectionsTypeLabel: memoryPressureRejectionsRequests,
+		},
+	)
+
+	Goroutines = NewMetric(
+		"proxy_goroutines",
+		"Number of goroutines of the proxy process at the last goroutine audit, see ZDM_PROXY_GOROUTINE_AUDIT_INTERVAL_MS",
+	)
+	OpenClientHandlerGoroutines = NewMetricWithLabels(
+		clientHandlerGoroutinesName,
+		clientHandlerGoroutinesDescription,
+		map[string]string{
+			clientHandlerGoroutinesStateLabel: clientHandlerGoroutinesStateOpen,
+		},
+	)
+	ClosedClientHandlerGoroutines = NewMetricWithLabels(
+		clientHandlerGoroutinesName,
+		clientHandlerGoroutinesDescription,
+		map[string]string{
+			clientHandlerGoroutinesStateLabel: clientHandlerGoroutinesStateClosed,
+		},
+	)
+	GoroutineLeakSuspects = NewMetric(
+		"client_handler_goroutine_leak_suspects_total",
+		"Running total of closed client handlers whose goroutines were still running after ZDM_PROXY_GOROUTINE_LEAK_GRACE_PERIOD_MS",
+	)
+
+	ClientAddressRejectedConnections = NewMetric(
+		"client_address_rejections_total",
+		"Running total of client connections rejected because of the allowed and denied client CIDR blocks",
+	)
+
+	WriteQueueOverflowRejectedRequests = NewMetric(
+		"write_queue_overflow_rejections_total",
+		"Running total of requests rejected because the write queue of a cluster connection was full",
+	)
+
+	StreamedResponses = NewMetric(
+		"streamed_responses_total",
+		"Running total of responses that were forwarded to the client while being read from the cluster connection",
+	)
+
+	RateLimitedRequests = NewMetric(
+		"request_rate_limit_rejections_total",
+		"Running total of requests rejected because the request rate limit of the proxy was exceeded",
+	)
+
+	GlobalRateLimitedRequests = NewMetric(
+		"global_request_rate_limit_rejections_total",
+		"Running total of requests rejected because the global request rate limit of the proxy fleet was exceeded",
+	)
+
+	MutationExportDropped = NewMetric(
+		"mutation_export_dropped_total",
+		"Running total of dual-written mutations that could not be exported",
+	)
+
+	TargetWriteSamplingSkippedWrites = NewMetric(
+		"target_write_sampling_skipped_writes_total",
+		"Running total of writes that were only sent to ORIGIN because they were not sampled for TARGET",
+	)
+
+	OriginOnlyFallbackActive = NewMetric(
+		"origin_only_fallback_active",
+		"1 while TARGET is DOWN and the clients are served by ORIGIN only, see ZDM_TARGET_DOWN_POLICY",
+	)
+	OriginOnlyJournaledRequests = NewMetric(
+		"origin_only_journaled_requests_total",
+		"Running total of requests that were only sent to ORIGIN and journaled for TARGET",
+	)
+	OriginOnlyJournalFailures = NewMetric(
+		"origin_only_journal_failures_total",
+		"Running total of requests that were rejected because they could not be journaled",
+	)
+	OriginOnlyJournalSize = NewMetric(
+		"origin_only_journal_size_bytes",
+		"Size of the journal of the writes that were only sent to ORIGIN, see ZDM_ORIGIN_ONLY_JOURNAL_FILE",
+	)
+	OriginOnlyJournalDroppedEntriesAge = NewMetricWithLabels(
+		originOnlyJournalDroppedEntriesName,
+		originOnlyJournalDroppedEntriesDescription,
+		map[string]string{
+			originOnlyJournalDroppedReasonLabel: originOnlyJournalDroppedReasonAge,
+		},
+	)
+	OriginOnlyJournalDroppedEntriesSize = NewMetricWithLabels(
+		originOnlyJournalDroppedEntriesName,
+		originOnlyJournalDroppedEntriesDescription,
+		map[string]string{
+			originOnlyJournalDroppedReasonLabel: originOnlyJournalDroppedReasonSize,
+		},
+	)
+
+	TargetWritePipelinePublishedRequests = NewMetric(
+		"target_write_pipeline_published_requests_total",
+		"Running total of writes that were published to Kafka for TARGET, see ZDM_TARGET_WRITE_PIPELINE",
+	)
+	TargetWritePipelinePublishFailures = NewMetric(
+		"target_write_pipeline_publish_failures_total",
+		"Running total of writes that were rejected because they could not be published to Kafka",
+	)
+	TargetWritePipelineAppliedRequests = NewMetric(
+		"target_write_pipeline_applied_requests_total",
+		"Running total of writes that were consumed from Kafka and applied to TARGET",
+	)
+	TargetWritePipelineSkippedRequests = NewMetric(
+		"target_write_pipeline_skipped_requests_total",
+		"Running total of writes that were consumed from Kafka but could not be applied to TARGET",
+	)
+	TargetWritePipelineDuplicateRequests = NewMetric(
+		"target_write_pipeline_duplicate_requests_total",
+		"Running total of writes that were consumed from Kafka again after they were applied to TARGET and were skipped",
+	)
+
+	ReadRepairs = NewMetric(
+		"read_repairs_total",
+		"Running total of rows that the read verifier reported and that were written to TARGET, see ZDM_READ_REPAIR_TO_TARGET_ENABLED",
+	)
+	ReadRepairFailures = NewMetric(
+		"read_repair_failures_total",
+		"Running total of rows that the read verifier reported but that could not be written to TARGET",
+	)
+
+	AsymmetricTables = NewMetric(
+		"asymmetric_tables",
+		"Number of tables with materialized views or secondary indexes that only exist on one cluster, see ZDM_VIEW_INDEX_CHECK_ENABLED",
+	)
+	AsymmetricTableWrites = NewMetric(
+		"asymmetric_table_writes_total",
+		"Running total of dual writes to tables with materialized views or secondary indexes that only exist on one cluster",
+	)
+
+	PreparedMetadataMismatches = NewMetric(
+		"prepared_metadata_mismatches_total",
+		"Running total of bound variables and result columns of prepared statements whose types differ between ORIGIN and TARGET",
+	)
+
+	AsyncReadComparisons = NewMetric(
+		"async_read_comparisons_total",
+		"Running total of async reads whose response was compared with the response of the primary cluster",
+	)
+	AsyncReadDivergencesOutcome = NewMetricWithLabels(
+		asyncReadDivergencesName,
+		asyncReadDivergencesDescription,
+		map[string]string{
+			asyncReadDivergencesKindLabel: asyncReadDivergenceOutcome,
+		},
+	)
+	AsyncReadDivergencesRowCount = NewMetricWithLabels(
+		asyncReadDivergencesName,
+		asyncReadDivergencesDescription,
+		map[string]string{
+			asyncReadDivergencesKindLabel: asyncReadDivergenceRowCount,
+		},
+	)
+	AsyncReadDivergencesResultSize = NewMetricWithLabels(
+		asyncReadDivergencesName,
+		asyncReadDivergencesDescription,
+		map[string]string{
+			asyncReadDivergencesKindLabel: asyncReadDivergenceResultSize,
+		},
+	)
+
+	RequestDeadlineExceeded = NewMetric(
+		"request_deadline_exceeded_total",
+		"Running total of requests that the proxy stopped processing because their ZDM_PROXY_REQUEST_DEADLINE_MS elapsed",
+	)
+
+	CoalescedPrepares = NewMetric(
+		"coalesced_prepares_total",
+		"Running total of PREPARE requests that were answered with the response of an identical PREPARE that was already in flight",
+	)
+
+	GuardrailWarningsBatchSize = NewMetricWithLabels(
+		guardrailWarningsName,
+		guardrailWarningsDescription,
+		map[string]string{
+			guardrailLabel: guardrailBatchSize,
+		},
+	)
+	GuardrailWarningsBatchStatements = NewMetricWithLabels(
+		guardrailWarningsName,
+		guardrailWarningsDescription,
+		map[string]string{
+			guardrailLabel: guardrailBatchStatements,
+		},
+	)
+	GuardrailWarningsMutationSize = NewMetricWithLabels(
+		guardrailWarningsName,
+		guardrailWarningsDescription,
+		map[string]string{
+			guardrailLabel: guardrailMutationSize,
+		},
+	)
+	GuardrailRejectionsBatchSize = NewMetricWithLabels(
+		guardrailRejectionsName,
+		guardrailRejectionsDescription,
+		map[string]string{
+			guardrailLabel: guardrailBatchSize,
+		},
+	)
+	GuardrailRejectionsBatchStatements = NewMetricWithLabels(
+		guardrailRejectionsName,
+		guardrailRejectionsDescription,
+		map[string]string{
+			guardrailLabel: guardrailBatchStatements,
+		},
+	)
+	GuardrailRejectionsMutationSize = NewMetricWithLabels(
+		guardrailRejectionsName,
+		guardrailRejectionsDescription,
+		map[string]string{
+			guardrailLabel: guardrailMutationSize,
+		},
+	)
+	GuardrailRejectionsQueryLength = NewMetricWithLabels(
+		guardrailRejectionsName,
+		guardrailRejectionsDescription,
+		map[string]string{
+			guardrailLabel: guardrailQueryLength,
+		},
+	)
+	GuardrailRejectionsValuesSize = NewMetricWithLabels(
+		guardrailRejectionsName,
+		guardrailRejectionsDescription,
+		map[string]string{
+			guardrailLabel: guardrailValuesSize,
+		},
+	)
+
+	PageSizeOverriddenRequests = NewMetric(
+		"page_size_overridden_requests_total",
+		"Running total of requests whose page size was lowered to ZDM_PAGE_SIZE_MAX or set to ZDM_PAGE_SIZE_DEFAULT",
+	)
+
+	ClusterWarningsOrigin = NewMetricWithLabels(
+		clusterWarningsName,
+		clusterWarningsDescription,
+		map[string]string{
+			clusterWarningsClusterLabel: failedRequestsClusterOrigin,
+		},
+	)
+	ClusterWarningsTarget = NewMetricWithLabels(
+		clusterWarningsName,
+		clusterWarningsDescription,
+		map[string]string{
+			clusterWarningsClusterLabel: failedRequestsClusterTarget,
+		},
+	)
+
+	TranslatedSecondaryErrorsWarning = NewMetricWithLabels(
+		translatedSecondaryErrorsName,
+		translatedSecondaryErrorsDescription,
+		map[string]string{
+			translatedSecondaryErrorsActionLabel: "warning",
+		},
+	)
+	TranslatedSecondaryErrorsMetric = NewMetricWithLabels(
+		translatedSecondaryErrorsName,
+		translatedSecondaryErrorsDescription,
+		map[string]string{
+			translatedSecondaryErrorsActionLabel: "metric",
+		},
+	)
+
+	DualWriteResponsePolicyPrimary = NewMetricWithLabels(
+		dualWriteResponsePolicyName,
+		dualWriteResponsePolicyDescription,
+		map[string]string{
+			dualWriteResponsePolicyLabel: "primary",
+		},
+	)
+	DualWriteResponsePolicyEither = NewMetricWithLabels(
+		dualWriteResponsePolicyName,
+		dualWriteResponsePolicyDescription,
+		map[string]string{
+			dualWriteResponsePolicyLabel: "either",
+		},
+	)
+	DualWriteResponsePolicyQuorum = NewMetricWithLabels(
+		dualWriteResponsePolicyName,
+		dualWriteResponsePolicyDescription,
+		map[string]string{
+			dualWriteResponsePolicyLabel: "quorum",
+		},
+	)
+
+	LwtRequestsOrigin = NewMetricWithLabels(
+		lwtRequestsName,
+		lwtRequestsDescription,
+		map[string]string{
+			lwtClusterLabel: failedRequestsClusterOrigin,
+		},
+	)
+	LwtRequestsTarget = NewMetricWithLabels(
+		lwtRequestsName,
+		lwtRequestsDescription,
+		map[string]string{
+			lwtClusterLabel: failedRequestsClusterTarget,
+		},
+	)
+	LwtNotAppliedOrigin = NewMetricWithLabels(
+		lwtNotAppliedName,
+		lwtNotAppliedDescription,
+		map[string]string{
+			lwtClusterLabel: failedRequestsClusterOrigin,
+		},
+	)
+	LwtNotAppliedTarget = NewMetricWithLabels(
+		lwtNotAppliedName,
+		lwtNotAppliedDescription,
+		map[string]string{
+			lwtClusterLabel: failedRequestsClusterTarget,
+		},
+	)
+	LwtSerialConsistencyRemappedOrigin = NewMetricWithLabels(
+		lwtSerialConsistencyRemappedName,
+		lwtSerialConsistencyRemappedDescription,
+		map[string]string{
+			lwtClusterLabel: failedRequestsClusterOrigin,
+		},
+	)
+	LwtSerialConsistencyRemappedTarget = NewMetricWithLabels(
+		lwtSerialConsistencyRemappedName,
+		lwtSerialConsistencyRemappedDescription,
+		map[string]string{
+			lwtClusterLabel: failedRequestsClusterTarget,
+		},
+	)
+	ClusterClockOffsetOrigin = NewMetricWithLabels(
+		clusterClockOffsetName,
+		clusterClockOffsetDescription,
+		map[string]string{
+			clusterClockOffsetLabel: failedRequestsClusterOrigin,
+		},
+	)
+	ClusterClockOffsetTarget = NewMetricWithLabels(
+		clusterClockOffsetName,
+		clusterClockOffsetDescription,
+		map[string]string{
+			clusterClockOffsetLabel: failedRequestsClusterTarget,
+		},
+	)
+	ClusterClockSkew = NewMetric(
+		"cluster_clock_skew_ms",
+		"Difference in milliseconds between the clock of TARGET and the clock of ORIGIN measured by the last probe",
+	)
+	ClusterClockSkewExceeded = NewMetric(
+		"cluster_clock_skew_exceeded_total",
+		"Running total of probes that measured a clock skew between the clusters above ZDM_CLOCK_SKEW_THRESHOLD_MS",
+	)
+	LwtAppliedDivergences = NewMetric(
+		"lwt_applied_divergences_total",
+		"Running total of lightweight transactions sent to both clusters that were only applied on one of them",
+	)
+
+	FleetLeader = NewMetric(
+		"fleet_leader",
+		"1 if this instance runs the tasks that run once per proxy fleet, 0 otherwise",
+	)
+
+	BuildInfo = NewMetricWithLabels(
+		"build_info",
+		"Constant 1 labeled with the version, git sha, build date and Go version of the proxy",
+		buildinfo.Get().Labels(),
+	)
+
+	// the labels are the settings of the proxy, see ZdmProxy.configInfoLabels
+	ConfigInfo = NewMetric(
+		"config_info",
+		"Constant 1 labeled with the settings that should be the same on every instance of the proxy fleet",
+	)
 )
 
 type ProxyMetrics struct {
@@ -141,4 +609,104 @@ type ProxyMetrics struct {
 	InFlightWrites      Gauge
 
 	OpenClientConnections GaugeFunc
+
+	HandshakesInProgress   GaugeFunc
+	HandshakeQueueTimeouts Counter
+
+	OpenOriginControlConnections Gauge
+	OpenTargetControlConnections Gauge
+
+	MemoryPressureRejectedConnections Counter
+	MemoryPressureRejectedRequests    Counter
+
+	Goroutines                    Gauge
+	OpenClientHandlerGoroutines   Gauge
+	ClosedClientHandlerGoroutines Gauge
+	GoroutineLeakSuspects         Counter
+
+	ClientAddressRejectedConnections Counter
+
+	WriteQueueOverflowRejectedRequests Counter
+
+	StreamedResponses Counter
+
+	RateLimitedRequests       Counter
+	GlobalRateLimitedRequests Counter
+
+	MutationExportDropped Counter
+
+	TargetWriteSamplingSkippedWrites Counter
+
+	OriginOnlyFallbackActive    GaugeFunc
+	OriginOnlyJournaledRequests Counter
+	OriginOnlyJournalFailures   Counter
+	OriginOnlyJournalSize       GaugeFunc
+
+	OriginOnlyJournalDroppedEntriesAge  Counter
+	OriginOnlyJournalDroppedEntriesSize Counter
+
+	TargetWritePipelinePublishedRequests Counter
+	TargetWritePipelinePublishFailures   Counter
+	TargetWritePipelineAppliedRequests   Counter
+	TargetWritePipelineSkippedRequests   Counter
+	TargetWritePipelineDuplicateRequests Counter
+
+	ReadRepairs        Counter
+	ReadRepairFailures Counter
+
+	AsymmetricTables      Gauge
+	AsymmetricTableWrites Counter
+
+	PreparedMetadataMismatches Counter
+
+	CoalescedPrepares Counter
+
+	RequestDeadlineExceeded Counter
+
+	AsyncReadComparisons           Counter
+	AsyncReadDivergencesOutcome    Counter
+	AsyncReadDivergencesRowCount   Counter
+	AsyncReadDivergencesResultSize Counter
+
+	GuardrailWarningsBatchSize         Counter
+	GuardrailWarningsBatchStatements   Counter
+	GuardrailWarningsMutationSize      Counter
+	GuardrailRejectionsBatchSize       Counter
+	GuardrailRejectionsBatchStatements Counter
+	GuardrailRejectionsMutationSize    Counter
+	GuardrailRejectionsQueryLength     Counter
+	GuardrailRejectionsValuesSize      Counter
+
+	PageSizeOverriddenRequests Counter
+
+	ClusterWarningsOrigin Counter
+	ClusterWarningsTarget Counter
+
+	TranslatedSecondaryErrorsWarning Counter
+	TranslatedSecondaryErrorsMetric  Counter
+
+	DualWriteResponsePolicyPrimary Counter
+	DualWriteResponsePolicyEither  Counter
+	DualWriteResponsePolicyQuorum  Counter
+
+	LwtRequestsOrigin                  Counter
+	LwtRequestsTarget                  Counter
+	LwtNotAppliedOrigin                Counter
+	LwtNotAppliedTarget                Counter
+	LwtSerialConsistencyRemappedOrigin Counter
+	LwtSerialConsistencyRemappedTarget Counter
+	LwtAppliedDivergences              Counter
+
+	ClusterClockOffsetOrigin Gauge
+	ClusterClockOffsetTarget Gauge
+	ClusterClockSkew         Gauge
+	ClusterClockSkewExceeded Counter
+
+	FleetLeader Gauge
+
+	BuildInfo  Gauge
+	ConfigInfo Gauge
+
+	OriginClusterHealth *ClusterHealthMetrics
+	TargetClusterHealth *ClusterHealthMetrics
 }
@@ -0,0 +1,43 @@
+package metrics
+
+const (
+	statementRequestDurationName        = "statement_request_duration_seconds"
+	statementRequestDurationDescription = "Histogram that tracks the latency of the requests of a statement fingerprint on a cluster"
+
+	statementFailedRequestsName        = "statement_requests_failed_total"
+	statementFailedRequestsDescription = "Running total of the requests of a statement fingerprint that failed on a cluster"
+
+	statementFingerprintLabel = "fingerprint"
+	statementClusterLabel     = "cluster"
+
+	StatementClusterOrigin = "origin"
+	StatementClusterTarget = "target"
+
+	// StatementFingerprintOther is the fingerprint label of the statements that exceed the cardinality cap
+	StatementFingerprintOther = "other"
+)
+
+var (
+	StatementRequestDuration = NewMetric(statementRequestDurationName, statementRequestDurationDescription)
+	StatementFailedRequests  = NewMetric(statementFailedRequestsName, statementFailedRequestsDescription)
+)
+
+// StatementMetrics are the metrics of a statement fingerprint on a cluster.
+type StatementMetrics struct {
+	RequestDuration Histogram
+	FailedRequests  Counter
+}
+
+func CreateStatementMetrics(
+	metricFactory MetricFactory, fingerprint string, cluster string, buckets []float64) (*StatementMetrics, error) {
+	labels := map[string]string{statementFingerprintLabel: fingerprint, statementClusterLabel: cluster}
+	requestDuration, err := metricFactory.GetOrCreateHistogram(StatementRequestDuration.WithLabels(labels), buckets)
+	if err != nil {
+		return nil, err
+	}
+	failedRequests, err := metricFactory.GetOrCreateCounter(StatementFailedRequests.WithLabels(labels))
+	if err != nil {
+		return nil, err
+	}
+	return &StatementMetrics{RequestDuration: requestDuration, FailedRequests: failedRequests}, nil
+}
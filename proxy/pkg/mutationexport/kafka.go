@@ -0,0 +1,131 @@
+package mutationexport
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	"github.com/segmentio/kafka-go"
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+const (
+	kafkaMaxBatchSize  = 100
+	kafkaBatchTimeout  = 10 * time.Millisecond
+	kafkaWriteTimeout  = 10 * time.Second
+	kafkaCloseTimeout  = 5 * time.Second
+	kafkaErrorLogDelay = 30 * time.Second
+)
+
+type messageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// KafkaPublisher writes mutations as JSON messages to a Kafka topic with "keyspace.table" as the message key.
+//
+// Mutations are queued and written by a background goroutine, they are dropped (and counted in the provided counter)
+// when the queue is full so that a slow or unavailable Kafka cluster doesn't slow down the client requests.
+type KafkaPublisher struct {
+	writer  messageWriter
+	queue   chan *Mutation
+	dropped metrics.Counter
+
+	closeOnce *sync.Once
+	done      chan struct{}
+}
+
+func NewKafkaPublisher(brokers []string, topic string, queueSize int, dropped metrics.Counter) *KafkaPublisher {
+	return newKafkaPublisher(&kafka.Writer{
+		Addr:         kafka.TCP(brokers...),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		BatchSize:    kafkaMaxBatchSize,
+		BatchTimeout: kafkaBatchTimeout,
+		WriteTimeout: kafkaWriteTimeout,
+		RequiredAcks: kafka.RequireAll,
+	}, queueSize, dropped)
+}
+
+func newKafkaPublisher(writer messageWriter, queueSize int, dropped metrics.Counter) *KafkaPublisher {
+	p := &KafkaPublisher{
+		writer:    writer,
+		queue:     make(chan *Mutation, queueSize),
+		dropped:   dropped,
+		closeOnce: &sync.Once{},
+		done:      make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+func (p *KafkaPublisher) Publish(mutations []*Mutation) {
+	for _, mutation := range mutations {
+		select {
+		case p.queue <- mutation:
+		default:
+			p.dropped.Add(1)
+		}
+	}
+}
+
+// Close writes the mutations that are still queued and closes the Kafka writer. Publish must not be called after
+// Close.
+func (p *KafkaPublisher) Close() error {
+	var err error
+	p.closeOnce.Do(func() {
+		close(p.queue)
+		select {
+		case <-p.done:
+		case <-time.After(kafkaCloseTimeout):
+			log.Warnf("Timed out waiting for the queued mutations to be exported to Kafka.")
+		}
+		err = p.writer.Close()
+	})
+	return err
+}
+
+func (p *KafkaPublisher) run() {
+	defer close(p.done)
+	var lastErrorLog time.Time
+	batch := make([]kafka.Message, 0, kafkaMaxBatchSize)
+	for mutation := range p.queue {
+		batch = append(batch[:0], p.toMessage(mutation))
+	drain:
+		for len(batch) < kafkaMaxBatchSize {
+			select {
+			case next, ok := <-p.queue:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, p.toMessage(next))
+			default:
+				break drain
+			}
+		}
+
+		ctx, cancelFn := context.WithTimeout(context.Background(), kafkaWriteTimeout)
+		err := p.writer.WriteMessages(ctx, batch...)
+		cancelFn()
+		if err != nil {
+			p.dropped.Add(len(batch))
+			if time.Since(lastErrorLog) > kafkaErrorLogDelay {
+				log.Warnf("Could not export %d mutation(s) to Kafka: %v", len(batch), err)
+				lastErrorLog = time.Now()
+			}
+		}
+	}
+}
+
+func (p *KafkaPublisher) toMessage(mutation *Mutation) kafka.Message {
+	value, err := json.Marshal(mutation)
+	if err != nil {
+		// can't happen, Mutation only contains strings and a timestamp
+		log.Errorf("Could not encode mutation %v: %v", mutation, err)
+	}
+	return kafka.Message{
+		Key:   []byte(mutation.Keyspace + "." + mutation.Table),
+		Value: value,
+	}
+}
@@ -0,0 +1,130 @@
+package mutationexport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeWriter struct {
+	lock     *sync.Mutex
+	messages []kafka.Message
+	err      error
+	block    chan struct{}
+	closed   bool
+}
+
+func newFakeWriter() *fakeWriter {
+	return &fakeWriter{lock: &sync.Mutex{}}
+}
+
+func (recv *fakeWriter) WriteMessages(_ context.Context, msgs ...kafka.Message) error {
+	if recv.block != nil {
+		<-recv.block
+	}
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	if recv.err != nil {
+		return recv.err
+	}
+	recv.messages = append(recv.messages, msgs...)
+	return nil
+}
+
+func (recv *fakeWriter) Close() error {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+	recv.closed = true
+	return nil
+}
+
+type fakeCounter struct {
+	value int64
+}
+
+func (recv *fakeCounter) Add(valueToAdd int) {
+	atomic.AddInt64(&recv.value, int64(valueToAdd))
+}
+
+func (recv *fakeCounter) get() int {
+	return int(atomic.LoadInt64(&recv.value))
+}
+
+func newTestMutation(table string) *Mutation {
+	return &Mutation{
+		Fingerprint:   Fingerprint("INSERT INTO ks." + table + " (a) VALUES (?)"),
+		Keyspace:      "ks",
+		Table:         table,
+		StatementType: "insert",
+		Timestamp:     time.Date(2022, 1, 2, 3, 4, 5, 0, time.UTC),
+		OriginOutcome: OutcomeSuccess,
+		TargetOutcome: "WriteTimeout",
+	}
+}
+
+func TestKafkaPublisher_Publish(t *testing.T) {
+	writer := newFakeWriter()
+	dropped := &fakeCounter{}
+	publisher := newKafkaPublisher(writer, 10, dropped)
+
+	publisher.Publish([]*Mutation{newTestMutation("tb1"), newTestMutation("tb2")})
+	require.Nil(t, publisher.Close())
+	require.Nil(t, publisher.Close())
+
+	require.True(t, writer.closed)
+	require.Equal(t, 0, dropped.get())
+	require.Len(t, writer.messages, 2)
+	require.Equal(t, "ks.tb1", string(writer.messages[0].Key))
+	require.Equal(t, "ks.tb2", string(writer.messages[1].Key))
+
+	decoded := &Mutation{}
+	require.Nil(t, json.Unmarshal(writer.messages[0].Value, decoded))
+	require.Equal(t, newTestMutation("tb1"), decoded)
+	require.JSONEq(t, `{"fingerprint":"`+decoded.Fingerprint+`","keyspace":"ks","table":"tb1","statementType":"insert",`+
+		`"timestamp":"2022-01-02T03:04:05Z","originOutcome":"Success","targetOutcome":"WriteTimeout"}`,
+		string(writer.messages[0].Value))
+}
+
+func TestKafkaPublisher_QueueFull(t *testing.T) {
+	writer := newFakeWriter()
+	writer.block = make(chan struct{})
+	dropped := &fakeCounter{}
+	publisher := newKafkaPublisher(writer, 2, dropped)
+
+	publisher.Publish([]*Mutation{newTestMutation("tb1")})
+	// wait until the background goroutine is blocked writing the first mutation
+	require.Eventually(t, func() bool { return len(publisher.queue) == 0 }, time.Second, time.Millisecond)
+	publisher.Publish([]*Mutation{newTestMutation("tb2"), newTestMutation("tb3"), newTestMutation("tb4")})
+	require.Equal(t, 1, dropped.get())
+
+	close(writer.block)
+	require.Nil(t, publisher.Close())
+	require.Len(t, writer.messages, 3)
+}
+
+func TestKafkaPublisher_WriteError(t *testing.T) {
+	writer := newFakeWriter()
+	writer.err = errors.New("broker not available")
+	dropped := &fakeCounter{}
+	publisher := newKafkaPublisher(writer, 10, dropped)
+
+	publisher.Publish([]*Mutation{newTestMutation("tb1"), newTestMutation("tb2")})
+	require.Nil(t, publisher.Close())
+	require.Equal(t, 2, dropped.get())
+}
+
+func TestFingerprint(t *testing.T) {
+	require.Equal(t,
+		Fingerprint("INSERT INTO ks.tb (a, b) VALUES (?, ?)"),
+		Fingerprint("  insert into ks.tb\n(a, b)   VALUES (?, ?) "))
+	require.NotEqual(t,
+		Fingerprint("INSERT INTO ks.tb (a, b) VALUES (?, ?)"),
+		Fingerprint("INSERT INTO ks.tb2 (a, b) VALUES (?, ?)"))
+	require.Len(t, Fingerprint("SELECT * FROM ks.tb"), 16)
+}
@@ -0,0 +1,40 @@
+// Package mutationexport publishes the mutations that the proxy wrote to both clusters so that external tools (e.g.
+// reconciliation jobs or audit pipelines) can consume them.
+package mutationexport
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"time"
+)
+
+const (
+	OutcomeSuccess = "Success"
+)
+
+// Mutation describes a write request that the proxy forwarded to both clusters. BATCH requests are exported as
+// one Mutation per child statement.
+type Mutation struct {
+	Fingerprint   string    `json:"fingerprint"`
+	Keyspace      string    `json:"keyspace"`
+	Table         string    `json:"table"`
+	StatementType string    `json:"statementType"`
+	Timestamp     time.Time `json:"timestamp"`
+	OriginOutcome string    `json:"originOutcome"`
+	TargetOutcome string    `json:"targetOutcome"`
+}
+
+// Publisher exports mutations. Publish is called on the request path so it must not block.
+type Publisher interface {
+	Publish(mutations []*Mutation)
+	Close() error
+}
+
+// Fingerprint returns an identifier of the statement text that doesn't depend on its whitespace or letter case so
+// that mutations of the same (prepared) statement can be grouped.
+func Fingerprint(query string) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(strings.ToLower(strings.Join(strings.Fields(query), " "))))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
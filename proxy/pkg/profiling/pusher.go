@@ -0,0 +1,260 @@
+package profiling
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	pushTimeout   = 10 * time.Second
+	errorLogDelay = time.Minute
+
+	// cpuSampleRate is the default sampling frequency of the Go CPU profiler
+	cpuSampleRate = 100
+)
+
+var invalidLabelValueChars = regexp.MustCompile("[^a-zA-Z0-9_.:-]")
+
+// Pusher periodically collects the pprof profiles of the proxy and pushes them to a continuous profiling server with
+// the Pyroscope ingest API (POST /ingest?format=pprof), which is also implemented by Grafana Pyroscope and Phlare.
+//
+// Every profile is labeled with the instance of the proxy and its version so that profiles of different instances can
+// be compared and regressions can be correlated with upgrades. Allocation profiles are cumulative so the previous
+// profile is pushed too and the server computes the allocations of each interval.
+type Pusher struct {
+	ingestUrl    *url.URL
+	name         string
+	profileTypes map[string]bool
+	interval     time.Duration
+	authToken    string
+	httpClient   *http.Client
+
+	previousAllocProfile []byte
+	lastErrorLog         time.Time
+}
+
+// NewPusher returns the Pusher configured with the ZDM_CONTINUOUS_PROFILING_* settings or nil if continuous profiling
+// is disabled.
+func NewPusher(conf *config.Config, version string) (*Pusher, error) {
+	profileTypes, err := conf.ParseContinuousProfilingProfileTypes()
+	if err != nil {
+		return nil, err
+	}
+	if profileTypes == nil {
+		return nil, nil
+	}
+
+	ingestUrl, err := url.Parse(conf.ContinuousProfilingServerUrl)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse continuous profiling server url: %w", err)
+	}
+	ingestUrl.Path = strings.TrimSuffix(ingestUrl.Path, "/") + "/ingest"
+
+	instance := conf.ContinuousProfilingInstance
+	if instance == "" {
+		instance, err = os.Hostname()
+		if err != nil {
+			instance = fmt.Sprintf("%v:%d", conf.ProxyListenAddress, conf.ProxyListenPort)
+		}
+	}
+
+	pusher := &Pusher{
+		ingestUrl: ingestUrl,
+		name: fmt.Sprintf("%v{instance=%v,version=%v}", conf.ContinuousProfilingApplicationName,
+			labelValue(instance), labelValue(version)),
+		profileTypes: make(map[string]bool),
+		interval:     time.Duration(conf.ContinuousProfilingIntervalMs) * time.Millisecond,
+		authToken:    conf.ContinuousProfilingAuthToken,
+		httpClient:   &http.Client{Timeout: pushTimeout},
+	}
+	for _, profileType := range profileTypes {
+		pusher.profileTypes[profileType] = true
+	}
+	return pusher, nil
+}
+
+func labelValue(value string) string {
+	return invalidLabelValueChars.ReplaceAllString(value, "_")
+}
+
+// Run collects and pushes the profiles until the context is canceled.
+func (p *Pusher) Run(ctx context.Context) {
+	log.Infof("Pushing %v profiles of %v to %v every %v.",
+		p.profileTypeNames(), p.name, p.ingestUrl.Redacted(), p.interval)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	from := time.Now()
+	cpuProfile := p.startCpuProfile()
+	for {
+		select {
+		case <-ctx.Done():
+			if cpuProfile != nil {
+				pprof.StopCPUProfile()
+			}
+			return
+		case until := <-ticker.C:
+			if cpuProfile != nil {
+				pprof.StopCPUProfile()
+				p.push(ctx, config.ContinuousProfilingProfileTypeCpu, cpuProfile.Bytes(), nil, from, until)
+				// the next profile starts right away so that the cpu usage of the push is profiled as well
+				cpuProfile = p.startCpuProfile()
+			}
+			p.pushLookupProfiles(ctx, from, until)
+			from = until
+		}
+	}
+}
+
+func (p *Pusher) startCpuProfile() *bytes.Buffer {
+	if !p.profileTypes[config.ContinuousProfilingProfileTypeCpu] {
+		return nil
+	}
+	cpuProfile := &bytes.Buffer{}
+	err := pprof.StartCPUProfile(cpuProfile)
+	if err != nil {
+		// e.g. the cpu profile of the profiling build is enabled
+		log.Warnf("Could not start the cpu profile, cpu profiles will not be pushed: %v", err)
+		delete(p.profileTypes, config.ContinuousProfilingProfileTypeCpu)
+		return nil
+	}
+	return cpuProfile
+}
+
+func (p *Pusher) pushLookupProfiles(ctx context.Context, from time.Time, until time.Time) {
+	if p.profileTypes[config.ContinuousProfilingProfileTypeAlloc] {
+		allocProfile, err := lookupProfile("allocs")
+		if err != nil {
+			p.logError("Could not collect the allocation profile: %v", err)
+		} else {
+			p.push(ctx, config.ContinuousProfilingProfileTypeAlloc, allocProfile, p.previousAllocProfile, from, until)
+			p.previousAllocProfile = allocProfile
+		}
+	}
+	if p.profileTypes[config.ContinuousProfilingProfileTypeGoroutine] {
+		goroutineProfile, err := lookupProfile("goroutine")
+		if err != nil {
+			p.logError("Could not collect the goroutine profile: %v", err)
+		} else {
+			p.push(ctx, config.ContinuousProfilingProfileTypeGoroutine, goroutineProfile, nil, from, until)
+		}
+	}
+}
+
+func lookupProfile(name string) ([]byte, error) {
+	profile := pprof.Lookup(name)
+	if profile == nil {
+		return nil, fmt.Errorf("profile %v not found", name)
+	}
+	buf := &bytes.Buffer{}
+	err := profile.WriteTo(buf, 0)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (p *Pusher) push(
+	ctx context.Context, profileType string, profile []byte, previousProfile []byte, from time.Time, until time.Time) {
+	err := p.upload(ctx, profile, previousProfile, from, until)
+	if err != nil {
+		if ctx.Err() == nil {
+			p.logError("Could not push the %v profile to the continuous profiling server: %v", profileType, err)
+		}
+		return
+	}
+	log.Tracef("Pushed %v profile (%d bytes) of %v.", profileType, len(profile), p.name)
+}
+
+func (p *Pusher) upload(
+	ctx context.Context, profile []byte, previousProfile []byte, from time.Time, until time.Time) error {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	err := writeFormFile(writer, "profile", profile)
+	if err != nil {
+		return err
+	}
+	if previousProfile != nil {
+		err = writeFormFile(writer, "prev_profile", previousProfile)
+		if err != nil {
+			return err
+		}
+	}
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+
+	ingestUrl := *p.ingestUrl
+	ingestUrl.RawQuery = url.Values{
+		"name":       {p.name},
+		"from":       {strconv.FormatInt(from.Unix(), 10)},
+		"until":      {strconv.FormatInt(until.Unix(), 10)},
+		"format":     {"pprof"},
+		"spyName":    {"gospy"},
+		"sampleRate": {strconv.Itoa(cpuSampleRate)},
+	}.Encode()
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, ingestUrl.String(), body)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", writer.FormDataContentType())
+	if p.authToken != "" {
+		request.Header.Set("Authorization", "Bearer "+p.authToken)
+	}
+
+	response, err := p.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode/100 != 2 {
+		responseBody, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("unexpected status %v: %v", response.Status, strings.TrimSpace(string(responseBody)))
+	}
+	return nil
+}
+
+func writeFormFile(writer *multipart.Writer, name string, content []byte) error {
+	part, err := writer.CreateFormFile(name, "profile.pprof")
+	if err != nil {
+		return err
+	}
+	_, err = part.Write(content)
+	return err
+}
+
+// logError logs at most one warning per minute so that an unavailable server doesn't flood the log.
+func (p *Pusher) logError(format string, args ...interface{}) {
+	if time.Since(p.lastErrorLog) < errorLogDelay {
+		log.Debugf(format, args...)
+		return
+	}
+	p.lastErrorLog = time.Now()
+	log.Warnf(format, args...)
+}
+
+func (p *Pusher) profileTypeNames() []string {
+	var names []string
+	for _, profileType := range []string{config.ContinuousProfilingProfileTypeCpu,
+		config.ContinuousProfilingProfileTypeAlloc, config.ContinuousProfilingProfileTypeGoroutine} {
+		if p.profileTypes[profileType] {
+			names = append(names, profileType)
+		}
+	}
+	return names
+}
@@ -0,0 +1,94 @@
+package profiling
+
+import (
+	"context"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type pushedProfile struct {
+	path            string
+	query           url.Values
+	authorization   string
+	profile         []byte
+	previousProfile []byte
+}
+
+func TestPusher(t *testing.T) {
+	pushed := make(chan *pushedProfile, 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		profile := &pushedProfile{path: r.URL.Path, query: r.URL.Query(), authorization: r.Header.Get("Authorization")}
+		require.Nil(t, r.ParseMultipartForm(1<<20))
+		profile.profile = readFormFile(t, r, "profile")
+		if _, ok := r.MultipartForm.File["prev_profile"]; ok {
+			profile.previousProfile = readFormFile(t, r, "prev_profile")
+		}
+		pushed <- profile
+	}))
+	defer server.Close()
+
+	pusher, err := NewPusher(&config.Config{
+		ContinuousProfilingServerUrl:       server.URL + "/pyroscope/",
+		ContinuousProfilingApplicationName: "zdm-proxy",
+		ContinuousProfilingInstance:        "proxy 1",
+		ContinuousProfilingProfileTypes:    "cpu, alloc",
+		ContinuousProfilingIntervalMs:      1000,
+		ContinuousProfilingAuthToken:       "token",
+	}, "2.1.0")
+	require.Nil(t, err)
+	pusher.interval = 100 * time.Millisecond
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		pusher.Run(ctx)
+	}()
+
+	// every interval the cpu profile is pushed and then the allocation profile
+	var profiles []*pushedProfile
+	for len(profiles) < 4 {
+		select {
+		case profile := <-pushed:
+			require.Equal(t, "/pyroscope/ingest", profile.path)
+			require.Equal(t, "zdm-proxy{instance=proxy_1,version=2.1.0}", profile.query.Get("name"))
+			require.Equal(t, "pprof", profile.query.Get("format"))
+			require.NotEmpty(t, profile.query.Get("from"))
+			require.NotEmpty(t, profile.query.Get("until"))
+			require.Equal(t, "Bearer token", profile.authorization)
+			// pprof profiles are gzipped protobufs
+			require.Equal(t, []byte{0x1f, 0x8b}, profile.profile[:2])
+			profiles = append(profiles, profile)
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "timed out waiting for the pushed profiles")
+		}
+	}
+	cancelFn()
+	<-done
+
+	require.Nil(t, profiles[0].previousProfile)
+	require.Nil(t, profiles[1].previousProfile)
+	require.Nil(t, profiles[2].previousProfile)
+	require.Equal(t, profiles[1].profile, profiles[3].previousProfile)
+}
+
+func TestNewPusher_Disabled(t *testing.T) {
+	pusher, err := NewPusher(&config.Config{}, "2.1.0")
+	require.Nil(t, err)
+	require.Nil(t, pusher)
+}
+
+func readFormFile(t *testing.T, r *http.Request, name string) []byte {
+	file, _, err := r.FormFile(name)
+	require.Nil(t, err)
+	defer file.Close()
+	content, err := io.ReadAll(file)
+	require.Nil(t, err)
+	return content
+}
@@ -2,8 +2,11 @@ package runner
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"github.com/datastax/zdm-proxy/proxy/pkg/admin"
+	"github.com/datastax/zdm-proxy/proxy/pkg/buildinfo"
 	"github.com/datastax/zdm-proxy/proxy/pkg/config"
 	"github.com/datastax/zdm-proxy/proxy/pkg/health"
 	"github.com/datastax/zdm-proxy/proxy/pkg/httpzdmproxy"
@@ -19,6 +22,7 @@ import (
 var (
 	metricsHandler   = httpzdmproxy.NewHandlerWithFallback(metrics.DefaultHttpHandler())
 	readinessHandler = httpzdmproxy.NewHandlerWithFallback(health.DefaultReadinessHandler())
+	adminHandler     = httpzdmproxy.NewHandlerWithFallback(admin.DefaultHandler())
 	registerHandler  = &sync.Mutex{}
 	registered       = false
 )
@@ -33,6 +37,8 @@ func SetupHandlers() (*httpzdmproxy.HandlerWithFallback, *httpzdmproxy.HandlerWi
 	http.Handle("/metrics", metricsHandler.Handler())
 	http.Handle("/health/readiness", readinessHandler.Handler())
 	http.Handle("/health/liveness", health.LivenessHandler())
+	http.Handle("/version", buildinfo.Handler())
+	http.Handle("/admin/", adminHandler.Handler())
 	return metricsHandler, readinessHandler
 }
 
@@ -42,9 +48,33 @@ func RunMain(
 	metricsHandler *httpzdmproxy.HandlerWithFallback,
 	readinessHandler *httpzdmproxy.HandlerWithFallback) {
 
-	log.Infof("Starting http server (metrics and health checks) on %v:%d", conf.MetricsAddress, conf.MetricsPort)
+	authConfig, err := conf.ParseAdminApiAuthConfig()
+	if err != nil {
+		log.Errorf("Invalid authentication of the admin API: %v.", err)
+		return
+	}
+	authorizer := httpzdmproxy.NewAuthorizer(authConfig)
+	if conf.AdminApiEnabled && authorizer == nil {
+		log.Warnf("The admin API is enabled without authentication, set ZDM_ADMIN_API_OPERATOR_TOKENS (or the " +
+			"client certificate names of the operator role) to protect it.")
+	}
+
 	wg := &sync.WaitGroup{}
-	srv := httpzdmproxy.StartHttpServer(fmt.Sprintf("%s:%d", conf.MetricsAddress, conf.MetricsPort), wg)
+	var srv *http.Server
+	httpTlsConfig, err := conf.ParseMetricsTlsConfig()
+	if err == nil {
+		var tlsConfig *tls.Config
+		tlsConfig, err = httpzdmproxy.NewServerTlsConfig(httpTlsConfig)
+		if err == nil {
+			log.Infof("Starting http server (metrics and health checks) on %v:%d (TLS enabled: %v)",
+				conf.MetricsAddress, conf.MetricsPort, httpTlsConfig.TlsEnabled)
+			srv = httpzdmproxy.StartHttpServer(fmt.Sprintf("%s:%d", conf.MetricsAddress, conf.MetricsPort), tlsConfig, wg)
+		}
+	}
+	if err != nil {
+		log.Errorf("Failed to start the http server (metrics and health checks): %v. "+
+			"The proxy will stay up and listen for CQL requests.", err)
+	}
 
 	b := &backoff.Backoff{
 		Min:    100 * time.Millisecond,
@@ -56,12 +86,20 @@ func RunMain(
 	zdmProxy, err := zdmproxy.RunWithRetries(conf, ctx, b)
 
 	if err == nil {
-		metricsHandler.SetHandler(zdmProxy.GetMetricHandler().GetHttpHandler())
+		if authConfig.MetricsAuthRequired {
+			metricsHandler.SetHandler(authorizer.Handler(zdmProxy.GetMetricHandler().GetHttpHandler()))
+		} else {
+			metricsHandler.SetHandler(zdmProxy.GetMetricHandler().GetHttpHandler())
+		}
 		readinessHandler.SetHandler(health.ReadinessHandler(zdmProxy))
+		if conf.AdminApiEnabled {
+			adminHandler.SetHandler(authorizer.Handler(admin.Handler(zdmProxy)))
+		}
 
 		log.Info("Proxy started. Waiting for SIGINT/SIGTERM to shutdown.")
 		<-ctx.Done()
 
+		adminHandler.ClearHandler()
 		zdmProxy.Shutdown()
 		metricsHandler.ClearHandler()
 		readinessHandler.ClearHandler()
@@ -69,10 +107,12 @@ func RunMain(
 		log.Errorf("Error launching proxy: %v", err)
 	}
 
-	log.Info("Shutting down httpzdmproxy server, waiting up to 5 seconds.")
-	srvShutdownCtx, _ := context.WithTimeout(context.Background(), 5*time.Second)
-	if err := srv.Shutdown(srvShutdownCtx); err != nil {
-		log.Errorf("Failed to gracefully shutdown httpzdmproxy server: %v", err)
+	if srv != nil {
+		log.Info("Shutting down httpzdmproxy server, waiting up to 5 seconds.")
+		srvShutdownCtx, _ := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := srv.Shutdown(srvShutdownCtx); err != nil {
+			log.Errorf("Failed to gracefully shutdown httpzdmproxy server: %v", err)
+		}
 	}
 
 	wg.Wait()
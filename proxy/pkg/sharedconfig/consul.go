@@ -0,0 +1,249 @@
+package sharedconfig
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// consulSource reads the keys from the Consul KV store, changes are watched with blocking queries.
+type consulSource struct {
+	client *endpointClient
+}
+
+type consulEntry struct {
+	Key         string
+	Value       *string
+	ModifyIndex uint64
+}
+
+func (s *consulSource) get(ctx context.Context, prefix string) (map[string]string, uint64, error) {
+	ctx, cancelFn := context.WithTimeout(ctx, requestTimeout)
+	defer cancelFn()
+	return s.query(ctx, prefix, url.Values{})
+}
+
+func (s *consulSource) wait(ctx context.Context, prefix string, index uint64) (map[string]string, uint64, error) {
+	ctx, cancelFn := context.WithTimeout(ctx, watchTimeout+requestTimeout)
+	defer cancelFn()
+	query := url.Values{}
+	query.Set("index", strconv.FormatUint(index, 10))
+	query.Set("wait", fmt.Sprintf("%ds", int(watchTimeout.Seconds())))
+	values, newIndex, err := s.query(ctx, prefix, query)
+	if err == nil && newIndex < index {
+		// the index went backwards (e.g. the data of the servers was restored), the next query must start over
+		newIndex = 0
+	}
+	return values, newIndex, err
+}
+
+func (s *consulSource) query(ctx context.Context, prefix string, query url.Values) (map[string]string, uint64, error) {
+	query.Set("recurse", "true")
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "/v1/kv/"+strings.TrimPrefix(prefix, "/"), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	request.URL.RawQuery = query.Encode()
+	response, err := s.client.do(request, func(r *http.Request, token string) {
+		r.Header.Set("X-Consul-Token", token)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer response.Body.Close()
+
+	index, _ := strconv.ParseUint(response.Header.Get("X-Consul-Index"), 10, 64)
+	values := make(map[string]string)
+	if response.StatusCode == http.StatusNotFound {
+		// none of the keys exist
+		return values, index, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		responseBody, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return nil, 0, fmt.Errorf("unexpected status %v: %v", response.Status, strings.TrimSpace(string(responseBody)))
+	}
+
+	var entries []*consulEntry
+	err = json.NewDecoder(response.Body).Decode(&entries)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not decode the Consul response: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.Value == nil {
+			// folder
+			continue
+		}
+		value, err := base64.StdEncoding.DecodeString(*entry.Value)
+		if err != nil {
+			return nil, 0, fmt.Errorf("could not decode the value of %v: %w", entry.Key, err)
+		}
+		values[strings.TrimPrefix(entry.Key, strings.TrimPrefix(prefix, "/"))] = string(value)
+	}
+	return values, index, nil
+}
+
+// consulCampaigner holds the leadership key with a lock of a Consul session.
+type consulCampaigner struct {
+	client    *endpointClient
+	key       string
+	value     string
+	ttl       time.Duration
+	sessionId string
+}
+
+func (c *consulCampaigner) campaign(ctx context.Context) (bool, error) {
+	if c.sessionId != "" {
+		found, err := c.renewSession(ctx)
+		if err != nil {
+			return false, err
+		}
+		if !found {
+			// the session expired and its lock was released
+			c.sessionId = ""
+		}
+	}
+	if c.sessionId == "" {
+		err := c.createSession(ctx)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	var acquired bool
+	err := consulPut(ctx, c.client, "/v1/kv/"+strings.TrimPrefix(c.key, "/"),
+		url.Values{"acquire": {c.sessionId}}, c.value, &acquired)
+	if err != nil {
+		return false, err
+	}
+	return acquired, nil
+}
+
+func (c *consulCampaigner) resign(ctx context.Context) error {
+	if c.sessionId == "" {
+		return nil
+	}
+	sessionId := c.sessionId
+	c.sessionId = ""
+	// destroying the session releases the lock
+	return consulPut(ctx, c.client, "/v1/session/destroy/"+sessionId, nil, "", nil)
+}
+
+func (c *consulCampaigner) createSession(ctx context.Context) error {
+	session := &struct {
+		ID string
+	}{}
+	body := fmt.Sprintf(`{"Name":"zdm-proxy %v","TTL":"%ds","LockDelay":"0s","Behavior":"release"}`,
+		c.value, int(c.ttl.Seconds()))
+	err := consulPut(ctx, c.client, "/v1/session/create", nil, body, session)
+	if err != nil {
+		return err
+	}
+	if session.ID == "" {
+		return errors.New("Consul didn't return the id of the session")
+	}
+	c.sessionId = session.ID
+	return nil
+}
+
+func (c *consulCampaigner) renewSession(ctx context.Context) (bool, error) {
+	err := consulPut(ctx, c.client, "/v1/session/renew/"+c.sessionId, nil, "", nil)
+	if errors.Is(err, errConsulNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+var errConsulNotFound = errors.New("not found")
+
+// consulPut sends a PUT request to the Consul HTTP API and decodes the response into result if it isn't nil.
+func consulPut(
+	ctx context.Context, client *endpointClient, path string, query url.Values, body string, result interface{}) error {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPut, path, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.URL.RawQuery = query.Encode()
+	response, err := client.do(request, func(r *http.Request, token string) {
+		r.Header.Set("X-Consul-Token", token)
+	})
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return errConsulNotFound
+	}
+	if response.StatusCode != http.StatusOK {
+		responseBody, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return fmt.Errorf("unexpected status %v: %v", response.Status, strings.TrimSpace(string(responseBody)))
+	}
+	if result == nil {
+		return nil
+	}
+	err = json.NewDecoder(response.Body).Decode(result)
+	if err != nil {
+		return fmt.Errorf("could not decode the Consul response: %w", err)
+	}
+	return nil
+}
+
+// consulStore updates a single key with check-and-set operations of the Consul KV store.
+type consulStore struct {
+	client *endpointClient
+}
+
+func (s *consulStore) read(ctx context.Context, key string) ([]byte, uint64, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "/v1/kv/"+strings.TrimPrefix(key, "/"), nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	response, err := s.client.do(request, func(r *http.Request, token string) {
+		r.Header.Set("X-Consul-Token", token)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode == http.StatusNotFound {
+		return nil, 0, nil
+	}
+	if response.StatusCode != http.StatusOK {
+		responseBody, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return nil, 0, fmt.Errorf("unexpected status %v: %v", response.Status, strings.TrimSpace(string(responseBody)))
+	}
+
+	var entries []*consulEntry
+	err = json.NewDecoder(response.Body).Decode(&entries)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not decode the Consul response: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil, 0, nil
+	}
+	var value []byte
+	if entries[0].Value != nil {
+		value, err = base64.StdEncoding.DecodeString(*entries[0].Value)
+		if err != nil {
+			return nil, 0, fmt.Errorf("could not decode the value of %v: %w", key, err)
+		}
+	}
+	return value, entries[0].ModifyIndex, nil
+}
+
+func (s *consulStore) compareAndSwap(ctx context.Context, key string, value []byte, version uint64) (bool, error) {
+	var swapped bool
+	err := consulPut(ctx, s.client, "/v1/kv/"+strings.TrimPrefix(key, "/"),
+		url.Values{"cas": {strconv.FormatUint(version, 10)}}, string(value), &swapped)
+	if err != nil {
+		return false, err
+	}
+	return swapped, nil
+}
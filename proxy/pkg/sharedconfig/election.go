@@ -0,0 +1,180 @@
+package sharedconfig
+
+import (
+	"context"
+	"fmt"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+// KeyLeader is the key, relative to ZDM_SHARED_CONFIG_PREFIX, that the leader of the fleet holds.
+const KeyLeader = "leader"
+
+// campaigner holds the leadership key of the fleet with a session (Consul) or a lease (etcd) that expires after the
+// TTL if the instance stops renewing it.
+type campaigner interface {
+	// campaign renews the session of this instance, creating it if it expired, and tries to acquire the key. It returns
+	// true if this instance holds the key.
+	campaign(ctx context.Context) (bool, error)
+
+	// resign releases the key, if this instance holds it, and ends the session.
+	resign(ctx context.Context) error
+}
+
+// LeaderElector elects a single instance of the proxy fleet with the key-value store of the shared configuration, so
+// that the tasks that must run once per fleet run on a single instance. The tasks run while the instance is the leader
+// and their context is canceled when it loses the leadership (e.g. because the store is unreachable for longer than
+// the TTL, after which another instance can be elected).
+type LeaderElector struct {
+	backendName string
+	campaigner  campaigner
+	interval    time.Duration
+	ttl         time.Duration
+
+	lock         *sync.Mutex
+	leader       bool
+	tasks        []func(context.Context)
+	tasksCancel  context.CancelFunc
+	tasksWg      *sync.WaitGroup
+	onLeadership func(leader bool)
+}
+
+// NewLeaderElector returns the LeaderElector of the backend configured with ZDM_SHARED_CONFIG_BACKEND or nil if shared
+// configuration is disabled. The instance id identifies this instance in the leadership key.
+func NewLeaderElector(conf *config.Config, instanceId string) (*LeaderElector, error) {
+	backend, err := conf.ParseSharedConfigBackend()
+	if err != nil {
+		return nil, err
+	}
+	endpoints, err := conf.ParseSharedConfigEndpoints()
+	if err != nil {
+		return nil, err
+	}
+	if endpoints == nil {
+		return nil, nil
+	}
+	ttl, err := conf.ParseSharedConfigLeaderTtl()
+	if err != nil {
+		return nil, err
+	}
+
+	client := newEndpointClient(endpoints, conf.SharedConfigToken)
+	key := conf.SharedConfigPrefix + KeyLeader
+	var c campaigner
+	switch backend {
+	case common.SharedConfigBackendEtcd:
+		c = &etcdCampaigner{source: &etcdSource{client: client}, key: key, value: instanceId, ttl: ttl}
+	case common.SharedConfigBackendConsul:
+		c = &consulCampaigner{client: client, key: key, value: instanceId, ttl: ttl}
+	default:
+		return nil, fmt.Errorf("unsupported shared configuration backend %v", backend)
+	}
+	return newLeaderElector(backend.String(), c, ttl), nil
+}
+
+func newLeaderElector(backendName string, c campaigner, ttl time.Duration) *LeaderElector {
+	return &LeaderElector{
+		backendName:  backendName,
+		campaigner:   c,
+		interval:     ttl / 3,
+		ttl:          ttl,
+		lock:         &sync.Mutex{},
+		tasksCancel:  func() {},
+		tasksWg:      &sync.WaitGroup{},
+		onLeadership: func(bool) {},
+	}
+}
+
+// AddTask registers a task that runs while this instance is the leader, it must return when its context is canceled.
+// Tasks must be added before Run.
+func (e *LeaderElector) AddTask(task func(context.Context)) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.tasks = append(e.tasks, task)
+}
+
+// OnLeadershipChange registers a function that is called when this instance becomes or stops being the leader, it must
+// be called before Run.
+func (e *LeaderElector) OnLeadershipChange(onLeadership func(leader bool)) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.onLeadership = onLeadership
+}
+
+// IsLeader returns true if this instance is the leader of the fleet.
+func (e *LeaderElector) IsLeader() bool {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return e.leader
+}
+
+// Run campaigns for the leadership until ctx is done, then stops the tasks and resigns.
+func (e *LeaderElector) Run(ctx context.Context) {
+	lastSuccess := time.Now()
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+	for {
+		callCtx, cancelFn := context.WithTimeout(ctx, e.interval)
+		leader, err := e.campaigner.campaign(callCtx)
+		cancelFn()
+		if ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			log.Warnf("Leader election in %v failed: %v", e.backendName, err)
+			// the session expires in the store after the TTL, another instance can be elected after that
+			leader = e.IsLeader() && time.Since(lastSuccess) < e.ttl
+		} else {
+			lastSuccess = time.Now()
+		}
+		e.setLeader(leader)
+
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	e.setLeader(false)
+	resignCtx, cancelFn := context.WithTimeout(context.Background(), requestTimeout)
+	defer cancelFn()
+	err := e.campaigner.resign(resignCtx)
+	if err != nil {
+		log.Debugf("Could not resign the leadership in %v: %v", e.backendName, err)
+	}
+}
+
+func (e *LeaderElector) setLeader(leader bool) {
+	e.lock.Lock()
+	if leader == e.leader {
+		e.lock.Unlock()
+		return
+	}
+	e.leader = leader
+	onLeadership := e.onLeadership
+	if leader {
+		log.Infof("This proxy instance is now the leader of the fleet, starting %d fleet tasks.", len(e.tasks))
+		tasksCtx, cancelFn := context.WithCancel(context.Background())
+		e.tasksCancel = cancelFn
+		for _, task := range e.tasks {
+			e.tasksWg.Add(1)
+			go func(task func(context.Context)) {
+				defer e.tasksWg.Done()
+				task(tasksCtx)
+			}(task)
+		}
+		e.lock.Unlock()
+	} else {
+		log.Infof("This proxy instance is no longer the leader of the fleet, stopping the fleet tasks.")
+		e.tasksCancel()
+		e.lock.Unlock()
+		e.tasksWg.Wait()
+	}
+	onLeadership(leader)
+}
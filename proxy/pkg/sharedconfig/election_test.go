@@ -0,0 +1,264 @@
+package sharedconfig
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeCampaigner struct {
+	lock     *sync.Mutex
+	leader   bool
+	err      error
+	resigned bool
+}
+
+func (c *fakeCampaigner) campaign(_ context.Context) (bool, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.leader, c.err
+}
+
+func (c *fakeCampaigner) resign(_ context.Context) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.resigned = true
+	return nil
+}
+
+func (c *fakeCampaigner) set(leader bool, err error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.leader, c.err = leader, err
+}
+
+func TestLeaderElector_Run(t *testing.T) {
+	c := &fakeCampaigner{lock: &sync.Mutex{}}
+	elector := newLeaderElector("TEST", c, 300*time.Millisecond)
+	tasks := make(chan string, 10)
+	elector.AddTask(func(ctx context.Context) {
+		tasks <- "started"
+		<-ctx.Done()
+		tasks <- "stopped"
+	})
+	changes := make(chan bool, 10)
+	elector.OnLeadershipChange(func(leader bool) {
+		changes <- leader
+	})
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		elector.Run(ctx)
+	}()
+
+	receiveTask := func() string {
+		select {
+		case v := <-tasks:
+			return v
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "timed out waiting for the task")
+			return ""
+		}
+	}
+	receiveChange := func() bool {
+		select {
+		case v := <-changes:
+			return v
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "timed out waiting for the leadership change")
+			return false
+		}
+	}
+
+	require.False(t, elector.IsLeader())
+	c.set(true, nil)
+	require.True(t, receiveChange())
+	require.Equal(t, "started", receiveTask())
+	require.True(t, elector.IsLeader())
+
+	// the store is unreachable, the leadership is kept until the TTL expires
+	c.set(false, errors.New("connection refused"))
+	erroredAt := time.Now()
+	require.Equal(t, "stopped", receiveTask())
+	require.False(t, receiveChange())
+	require.GreaterOrEqual(t, time.Since(erroredAt), 200*time.Millisecond)
+	require.False(t, elector.IsLeader())
+
+	c.set(true, nil)
+	require.True(t, receiveChange())
+	require.Equal(t, "started", receiveTask())
+
+	cancelFn()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "Run didn't return after the context was canceled")
+	}
+	require.Equal(t, "stopped", receiveTask())
+	require.False(t, receiveChange())
+	require.False(t, elector.IsLeader())
+	require.True(t, c.resigned)
+}
+
+func TestNewLeaderElector_Disabled(t *testing.T) {
+	elector, err := NewLeaderElector(&config.Config{SharedConfigBackend: "NONE"}, "proxy-1")
+	require.Nil(t, err)
+	require.Nil(t, elector)
+}
+
+func TestConsulCampaigner(t *testing.T) {
+	lock := &sync.Mutex{}
+	var requests []string
+	sessions := map[string]bool{}
+	holder := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPut, r.Method)
+		require.Equal(t, "secret", r.Header.Get("X-Consul-Token"))
+		body, err := io.ReadAll(r.Body)
+		require.Nil(t, err)
+		lock.Lock()
+		defer lock.Unlock()
+		requests = append(requests, r.URL.Path)
+		switch r.URL.Path {
+		case "/v1/session/create":
+			require.JSONEq(t,
+				`{"Name":"zdm-proxy proxy-1","TTL":"15s","LockDelay":"0s","Behavior":"release"}`, string(body))
+			sessions["s1"] = true
+			_, _ = w.Write([]byte(`{"ID":"s1"}`))
+		case "/v1/session/renew/s1":
+			if !sessions["s1"] {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write([]byte(`[{"ID":"s1"}]`))
+		case "/v1/session/destroy/s1":
+			delete(sessions, "s1")
+			holder = ""
+			_, _ = w.Write([]byte(`true`))
+		case "/v1/kv/zdm-proxy/leader":
+			require.Equal(t, "proxy-1", string(body))
+			session := r.URL.Query().Get("acquire")
+			if holder == "" || holder == session {
+				holder = session
+				_, _ = w.Write([]byte(`true`))
+			} else {
+				_, _ = w.Write([]byte(`false`))
+			}
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	elector, err := NewLeaderElector(newTestConfig("CONSUL", server.URL), "proxy-1")
+	require.Nil(t, err)
+	c := elector.campaigner
+
+	leader, err := c.campaign(context.Background())
+	require.Nil(t, err)
+	require.True(t, leader)
+	leader, err = c.campaign(context.Background())
+	require.Nil(t, err)
+	require.True(t, leader)
+	require.Equal(t, []string{
+		"/v1/session/create", "/v1/kv/zdm-proxy/leader", "/v1/session/renew/s1", "/v1/kv/zdm-proxy/leader"}, requests)
+
+	// another instance holds the lock
+	lock.Lock()
+	holder = "s2"
+	lock.Unlock()
+	leader, err = c.campaign(context.Background())
+	require.Nil(t, err)
+	require.False(t, leader)
+
+	// the session expired, a new one is created
+	lock.Lock()
+	delete(sessions, "s1")
+	holder = ""
+	requests = nil
+	lock.Unlock()
+	leader, err = c.campaign(context.Background())
+	require.Nil(t, err)
+	require.True(t, leader)
+	require.Equal(t, []string{"/v1/session/renew/s1", "/v1/session/create", "/v1/kv/zdm-proxy/leader"}, requests)
+
+	require.Nil(t, c.resign(context.Background()))
+	require.Equal(t, "", holder)
+}
+
+func TestEtcdCampaigner(t *testing.T) {
+	encode := func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	}
+	lock := &sync.Mutex{}
+	leases := map[string]bool{}
+	holderLease := ""
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&body))
+		lock.Lock()
+		defer lock.Unlock()
+		switch r.URL.Path {
+		case "/v3/lease/grant":
+			require.Equal(t, "15", body["TTL"])
+			leases["42"] = true
+			_, _ = w.Write([]byte(`{"ID":"42","TTL":"15"}`))
+		case "/v3/lease/keepalive":
+			if leases[body["ID"].(string)] {
+				_, _ = w.Write([]byte(`{"result":{"ID":"42","TTL":"15"}}`))
+			} else {
+				_, _ = w.Write([]byte(`{"result":{"ID":"42"}}`))
+			}
+		case "/v3/lease/revoke":
+			delete(leases, body["ID"].(string))
+			holderLease = ""
+			_, _ = w.Write([]byte(`{}`))
+		case "/v3/kv/txn":
+			put := body["success"].([]interface{})[0].(map[string]interface{})["request_put"].(map[string]interface{})
+			require.Equal(t, encode("zdm-proxy/leader"), put["key"])
+			require.Equal(t, encode("proxy-1"), put["value"])
+			if holderLease == "" {
+				holderLease = put["lease"].(string)
+				_, _ = w.Write([]byte(`{"succeeded":true}`))
+			} else {
+				_, _ = w.Write([]byte(`{"succeeded":false,"responses":[{"response_range":{"kvs":[{"lease":"` +
+					holderLease + `"}]}}]}`))
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	elector, err := NewLeaderElector(newTestConfig("ETCD", server.URL), "proxy-1")
+	require.Nil(t, err)
+	c := elector.campaigner
+
+	// the key is created, then the instance recognizes it holds it with its lease
+	for i := 0; i < 2; i++ {
+		leader, err := c.campaign(context.Background())
+		require.Nil(t, err)
+		require.True(t, leader)
+	}
+
+	lock.Lock()
+	holderLease = "43"
+	lock.Unlock()
+	leader, err := c.campaign(context.Background())
+	require.Nil(t, err)
+	require.False(t, leader)
+
+	require.Nil(t, c.resign(context.Background()))
+	require.False(t, leases["42"])
+}
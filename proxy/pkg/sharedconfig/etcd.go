@@ -0,0 +1,317 @@
+package sharedconfig
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// etcdSource reads the keys from etcd with the JSON gateway of the v3 API, changes are watched with a watch stream
+// that starts after the revision of the last read.
+type etcdSource struct {
+	client *endpointClient
+}
+
+type etcdHeader struct {
+	Revision string `json:"revision"`
+}
+
+type etcdKeyValue struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	ModRevision string `json:"mod_revision"`
+}
+
+type etcdRangeResponse struct {
+	Header *etcdHeader     `json:"header"`
+	Kvs    []*etcdKeyValue `json:"kvs"`
+}
+
+type etcdWatchResponse struct {
+	Result *struct {
+		Events   []json.RawMessage `json:"events"`
+		Canceled bool              `json:"canceled"`
+	} `json:"result"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (s *etcdSource) get(ctx context.Context, prefix string) (map[string]string, uint64, error) {
+	ctx, cancelFn := context.WithTimeout(ctx, requestTimeout)
+	defer cancelFn()
+	response, err := s.post(ctx, "/v3/kv/range", map[string]interface{}{
+		"key":       base64.StdEncoding.EncodeToString([]byte(prefix)),
+		"range_end": base64.StdEncoding.EncodeToString(etcdPrefixEnd(prefix)),
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	defer response.Body.Close()
+
+	rangeResponse := &etcdRangeResponse{}
+	err = json.NewDecoder(response.Body).Decode(rangeResponse)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not decode the etcd response: %w", err)
+	}
+	var revision uint64
+	if rangeResponse.Header != nil {
+		revision, _ = strconv.ParseUint(rangeResponse.Header.Revision, 10, 64)
+	}
+	values := make(map[string]string)
+	for _, kv := range rangeResponse.Kvs {
+		key, err := base64.StdEncoding.DecodeString(kv.Key)
+		if err != nil {
+			return nil, 0, fmt.Errorf("could not decode an etcd key: %w", err)
+		}
+		value, err := base64.StdEncoding.DecodeString(kv.Value)
+		if err != nil {
+			return nil, 0, fmt.Errorf("could not decode the value of %v: %w", string(key), err)
+		}
+		values[strings.TrimPrefix(string(key), prefix)] = string(value)
+	}
+	return values, revision, nil
+}
+
+func (s *etcdSource) wait(ctx context.Context, prefix string, index uint64) (map[string]string, uint64, error) {
+	err := s.watch(ctx, prefix, index)
+	if err != nil {
+		return nil, 0, err
+	}
+	// the watch events only contain the changed keys, reading all of them again is simpler and also handles
+	// compacted revisions
+	return s.get(ctx, prefix)
+}
+
+// watch returns when a key changes after index or after the watch timeout.
+func (s *etcdSource) watch(ctx context.Context, prefix string, index uint64) error {
+	watchCtx, cancelFn := context.WithTimeout(ctx, watchTimeout)
+	defer cancelFn()
+	response, err := s.post(watchCtx, "/v3/watch", map[string]interface{}{
+		"create_request": map[string]interface{}{
+			"key":            base64.StdEncoding.EncodeToString([]byte(prefix)),
+			"range_end":      base64.StdEncoding.EncodeToString(etcdPrefixEnd(prefix)),
+			"start_revision": strconv.FormatUint(index+1, 10),
+		},
+	})
+	if err != nil {
+		if errors.Is(watchCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+			return nil
+		}
+		return err
+	}
+	defer response.Body.Close()
+
+	decoder := json.NewDecoder(response.Body)
+	for {
+		watchResponse := &etcdWatchResponse{}
+		err = decoder.Decode(watchResponse)
+		if err != nil {
+			if errors.Is(watchCtx.Err(), context.DeadlineExceeded) && ctx.Err() == nil {
+				return nil
+			}
+			return fmt.Errorf("could not decode the etcd watch response: %w", err)
+		}
+		if watchResponse.Error != nil {
+			return fmt.Errorf("etcd watch failed: %v", watchResponse.Error.Message)
+		}
+		if watchResponse.Result != nil && (len(watchResponse.Result.Events) > 0 || watchResponse.Result.Canceled) {
+			return nil
+		}
+	}
+}
+
+func (s *etcdSource) post(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := s.client.do(request, func(r *http.Request, token string) {
+		r.Header.Set("Authorization", token)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode != http.StatusOK {
+		defer response.Body.Close()
+		responseBody, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return nil, fmt.Errorf("unexpected status %v: %v", response.Status, strings.TrimSpace(string(responseBody)))
+	}
+	return response, nil
+}
+
+// etcdPrefixEnd returns the end of the range of the keys that start with prefix.
+func etcdPrefixEnd(prefix string) []byte {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	// every byte is 0xff, the range ends with the last key
+	return []byte{0}
+}
+
+// etcdCampaigner holds the leadership key with an etcd lease, the key is created in a transaction that fails if it
+// already exists.
+type etcdCampaigner struct {
+	source  *etcdSource
+	key     string
+	value   string
+	ttl     time.Duration
+	leaseId string
+}
+
+type etcdLeaseResponse struct {
+	ID  string `json:"ID"`
+	TTL string `json:"TTL"`
+}
+
+type etcdTxnResponse struct {
+	Succeeded bool `json:"succeeded"`
+	Responses []*struct {
+		ResponseRange *struct {
+			Kvs []*struct {
+				Lease string `json:"lease"`
+			} `json:"kvs"`
+		} `json:"response_range"`
+	} `json:"responses"`
+}
+
+func (c *etcdCampaigner) campaign(ctx context.Context) (bool, error) {
+	if c.leaseId != "" {
+		keepAlive := &struct {
+			Result *etcdLeaseResponse `json:"result"`
+		}{}
+		err := c.source.call(ctx, "/v3/lease/keepalive", map[string]interface{}{"ID": c.leaseId}, keepAlive)
+		if err != nil {
+			return false, err
+		}
+		if keepAlive.Result == nil || keepAlive.Result.TTL == "" || keepAlive.Result.TTL == "0" {
+			// the lease expired and the key was deleted
+			c.leaseId = ""
+		}
+	}
+	if c.leaseId == "" {
+		grant := &etcdLeaseResponse{}
+		err := c.source.call(ctx, "/v3/lease/grant",
+			map[string]interface{}{"TTL": strconv.Itoa(int(c.ttl.Seconds()))}, grant)
+		if err != nil {
+			return false, err
+		}
+		if grant.ID == "" {
+			return false, errors.New("etcd didn't return the id of the lease")
+		}
+		c.leaseId = grant.ID
+	}
+
+	key := base64.StdEncoding.EncodeToString([]byte(c.key))
+	txn := &etcdTxnResponse{}
+	err := c.source.call(ctx, "/v3/kv/txn", map[string]interface{}{
+		"compare": []map[string]interface{}{{"key": key, "result": "EQUAL", "target": "CREATE", "create_revision": "0"}},
+		"success": []map[string]interface{}{{"request_put": map[string]interface{}{
+			"key": key, "value": base64.StdEncoding.EncodeToString([]byte(c.value)), "lease": c.leaseId}}},
+		"failure": []map[string]interface{}{{"request_range": map[string]interface{}{"key": key}}},
+	}, txn)
+	if err != nil {
+		return false, err
+	}
+	if txn.Succeeded {
+		return true, nil
+	}
+	for _, response := range txn.Responses {
+		if response.ResponseRange == nil {
+			continue
+		}
+		for _, kv := range response.ResponseRange.Kvs {
+			if kv.Lease == c.leaseId {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func (c *etcdCampaigner) resign(ctx context.Context) error {
+	if c.leaseId == "" {
+		return nil
+	}
+	leaseId := c.leaseId
+	c.leaseId = ""
+	// revoking the lease deletes the key
+	return c.source.call(ctx, "/v3/lease/revoke", map[string]interface{}{"ID": leaseId}, nil)
+}
+
+// call sends a request to the etcd JSON gateway and decodes the response into result if it isn't nil.
+func (s *etcdSource) call(ctx context.Context, path string, body interface{}, result interface{}) error {
+	response, err := s.post(ctx, path, body)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if result == nil {
+		return nil
+	}
+	// keepalive is a stream, only the first message is read
+	err = json.NewDecoder(response.Body).Decode(result)
+	if err != nil {
+		return fmt.Errorf("could not decode the etcd response: %w", err)
+	}
+	return nil
+}
+
+// etcdStore updates a single key with transactions that compare its modification revision.
+type etcdStore struct {
+	source *etcdSource
+}
+
+func (s *etcdStore) read(ctx context.Context, key string) ([]byte, uint64, error) {
+	rangeResponse := &etcdRangeResponse{}
+	err := s.source.call(ctx, "/v3/kv/range",
+		map[string]interface{}{"key": base64.StdEncoding.EncodeToString([]byte(key))}, rangeResponse)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(rangeResponse.Kvs) == 0 {
+		return nil, 0, nil
+	}
+	value, err := base64.StdEncoding.DecodeString(rangeResponse.Kvs[0].Value)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not decode the value of %v: %w", key, err)
+	}
+	version, err := strconv.ParseUint(rangeResponse.Kvs[0].ModRevision, 10, 64)
+	if err != nil {
+		return nil, 0, fmt.Errorf("could not parse the revision of %v: %w", key, err)
+	}
+	return value, version, nil
+}
+
+func (s *etcdStore) compareAndSwap(ctx context.Context, key string, value []byte, version uint64) (bool, error) {
+	encodedKey := base64.StdEncoding.EncodeToString([]byte(key))
+	txn := &etcdTxnResponse{}
+	// the modification revision of a key that doesn't exist is 0
+	err := s.source.call(ctx, "/v3/kv/txn", map[string]interface{}{
+		"compare": []map[string]interface{}{{
+			"key": encodedKey, "result": "EQUAL", "target": "MOD", "mod_revision": strconv.FormatUint(version, 10)}},
+		"success": []map[string]interface{}{{"request_put": map[string]interface{}{
+			"key": encodedKey, "value": base64.StdEncoding.EncodeToString(value)}}},
+	}, txn)
+	if err != nil {
+		return false, err
+	}
+	return txn.Succeeded, nil
+}
@@ -0,0 +1,65 @@
+package sharedconfig
+
+import (
+	"context"
+	"fmt"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+)
+
+// Publisher writes shared settings so that a decision taken by one instance of the fleet, usually the leader, is
+// applied by every instance through their Watcher.
+type Publisher struct {
+	backendName string
+	prefix      string
+	store       casStore
+}
+
+// NewPublisher returns the Publisher of the backend configured with ZDM_SHARED_CONFIG_BACKEND or nil if shared
+// configuration is disabled.
+func NewPublisher(conf *config.Config) (*Publisher, error) {
+	backend, err := conf.ParseSharedConfigBackend()
+	if err != nil {
+		return nil, err
+	}
+	endpoints, err := conf.ParseSharedConfigEndpoints()
+	if err != nil {
+		return nil, err
+	}
+	if endpoints == nil {
+		return nil, nil
+	}
+	store, err := newCasStore(backend, newEndpointClient(endpoints, conf.SharedConfigToken))
+	if err != nil {
+		return nil, err
+	}
+	return newPublisher(backend.String(), conf.SharedConfigPrefix, store), nil
+}
+
+func newPublisher(backendName string, prefix string, store casStore) *Publisher {
+	return &Publisher{
+		backendName: backendName,
+		prefix:      prefix,
+		store:       store,
+	}
+}
+
+// Publish sets the value of a key relative to ZDM_SHARED_CONFIG_PREFIX, overwriting the value written by an operator
+// or by another instance.
+func (p *Publisher) Publish(ctx context.Context, key string, value string) error {
+	ctx, cancelFn := context.WithTimeout(ctx, requestTimeout)
+	defer cancelFn()
+	for attempt := 0; attempt < maxCasAttempts; attempt++ {
+		_, version, err := p.store.read(ctx, p.prefix+key)
+		if err != nil {
+			return fmt.Errorf("could not read %v from %v: %w", key, p.backendName, err)
+		}
+		swapped, err := p.store.compareAndSwap(ctx, p.prefix+key, []byte(value), version)
+		if err != nil {
+			return fmt.Errorf("could not write %v to %v: %w", key, p.backendName, err)
+		}
+		if swapped {
+			return nil
+		}
+	}
+	return fmt.Errorf("could not write %v, too many concurrent updates", key)
+}
@@ -0,0 +1,27 @@
+package sharedconfig
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+func TestPublisher_Publish(t *testing.T) {
+	store := &fakeCasStore{lock: &sync.Mutex{}, value: []byte("ORIGIN"), version: 7}
+	publisher := newPublisher("TEST", "zdm-proxy/", store)
+
+	require.Nil(t, publisher.Publish(context.Background(), KeyPrimaryCluster, "TARGET"))
+	require.Equal(t, "zdm-proxy/primary_cluster", store.key)
+	require.Equal(t, "TARGET", string(store.value))
+
+	// an operator changed the key in the meantime
+	store.conflicts = 2
+	require.Nil(t, publisher.Publish(context.Background(), KeyPrimaryCluster, "ORIGIN"))
+	require.Equal(t, "ORIGIN", string(store.value))
+
+	store.conflicts = maxCasAttempts
+	err := publisher.Publish(context.Background(), KeyPrimaryCluster, "TARGET")
+	require.NotNil(t, err)
+	require.Equal(t, "could not write primary_cluster, too many concurrent updates", err.Error())
+}
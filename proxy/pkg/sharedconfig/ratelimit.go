@@ -0,0 +1,133 @@
+package sharedconfig
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"math"
+	"time"
+)
+
+// casStore reads and updates a single key with optimistic concurrency control.
+type casStore interface {
+	// read returns the value of the key and its version, the version is 0 if the key doesn't exist.
+	read(ctx context.Context, key string) ([]byte, uint64, error)
+
+	// compareAndSwap writes the value if the version of the key is still the given version (0 if the key must not
+	// exist yet), it returns false if another instance updated the key in the meantime.
+	compareAndSwap(ctx context.Context, key string, value []byte, version uint64) (bool, error)
+}
+
+// maxCasAttempts is the number of times Take tries to update the bucket when other instances update it concurrently.
+const maxCasAttempts = 10
+
+// bucketState is the value of the key of the global token bucket.
+type bucketState struct {
+	Tokens      float64 `json:"tokens"`
+	UpdatedAtMs int64   `json:"updated_at_ms"`
+}
+
+// GlobalRateLimiter is a token bucket shared by the proxy fleet in the key-value store of the shared configuration.
+// Like the limit of a single instance, the bucket holds up to one second of requests.
+type GlobalRateLimiter struct {
+	backendName string
+	key         string
+	store       casStore
+	now         func() time.Time
+}
+
+// NewGlobalRateLimiter returns the GlobalRateLimiter of the backend configured with ZDM_SHARED_CONFIG_BACKEND or nil
+// if shared configuration is disabled.
+func NewGlobalRateLimiter(conf *config.Config) (*GlobalRateLimiter, error) {
+	backend, err := conf.ParseSharedConfigBackend()
+	if err != nil {
+		return nil, err
+	}
+	endpoints, err := conf.ParseSharedConfigEndpoints()
+	if err != nil {
+		return nil, err
+	}
+	if endpoints == nil {
+		return nil, nil
+	}
+	key, err := conf.ParseProxyGlobalRequestRateLimitKey()
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := newCasStore(backend, newEndpointClient(endpoints, conf.SharedConfigToken))
+	if err != nil {
+		return nil, err
+	}
+	return newGlobalRateLimiter(backend.String(), key, store, time.Now), nil
+}
+
+func newCasStore(backend common.SharedConfigBackend, client *endpointClient) (casStore, error) {
+	switch backend {
+	case common.SharedConfigBackendEtcd:
+		return &etcdStore{source: &etcdSource{client: client}}, nil
+	case common.SharedConfigBackendConsul:
+		return &consulStore{client: client}, nil
+	default:
+		return nil, fmt.Errorf("unsupported shared configuration backend %v", backend)
+	}
+}
+
+func newGlobalRateLimiter(backendName string, key string, store casStore, now func() time.Time) *GlobalRateLimiter {
+	return &GlobalRateLimiter{
+		backendName: backendName,
+		key:         key,
+		store:       store,
+		now:         now,
+	}
+}
+
+// Take refills the bucket at rate tokens per second since its last update and removes up to n tokens from it, it
+// returns the number of tokens that were removed.
+func (l *GlobalRateLimiter) Take(ctx context.Context, rate int, n int) (int, error) {
+	if rate <= 0 || n <= 0 {
+		return 0, nil
+	}
+	ctx, cancelFn := context.WithTimeout(ctx, requestTimeout)
+	defer cancelFn()
+	for attempt := 0; attempt < maxCasAttempts; attempt++ {
+		value, version, err := l.store.read(ctx, l.key)
+		if err != nil {
+			return 0, fmt.Errorf("could not read the global rate limit bucket from %v: %w", l.backendName, err)
+		}
+		now := l.now().UnixMilli()
+		state := &bucketState{Tokens: float64(rate), UpdatedAtMs: now}
+		if version != 0 && json.Unmarshal(value, state) != nil {
+			// the value was not written by the proxy, the bucket starts over
+			state = &bucketState{Tokens: float64(rate), UpdatedAtMs: now}
+		}
+
+		// the clocks of the instances can differ slightly, the bucket is never refilled backwards
+		if elapsedMs := now - state.UpdatedAtMs; elapsedMs > 0 {
+			state.Tokens += float64(elapsedMs) / 1000 * float64(rate)
+			state.UpdatedAtMs = now
+		}
+		state.Tokens = math.Min(state.Tokens, float64(rate))
+		taken := int(math.Min(math.Floor(state.Tokens), float64(n)))
+		if taken < 0 {
+			taken = 0
+		}
+		state.Tokens -= float64(taken)
+
+		newValue, err := json.Marshal(state)
+		if err != nil {
+			return 0, err
+		}
+		swapped, err := l.store.compareAndSwap(ctx, l.key, newValue, version)
+		if err != nil {
+			return 0, fmt.Errorf("could not update the global rate limit bucket in %v: %w", l.backendName, err)
+		}
+		if swapped {
+			return taken, nil
+		}
+	}
+	return 0, errors.New("could not update the global rate limit bucket, too many concurrent updates")
+}
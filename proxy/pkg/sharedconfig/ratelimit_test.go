@@ -0,0 +1,209 @@
+package sharedconfig
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeCasStore struct {
+	lock      *sync.Mutex
+	key       string
+	value     []byte
+	version   uint64
+	conflicts int
+}
+
+func (s *fakeCasStore) read(_ context.Context, _ string) ([]byte, uint64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.value, s.version, nil
+}
+
+func (s *fakeCasStore) compareAndSwap(_ context.Context, key string, value []byte, version uint64) (bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.conflicts > 0 {
+		// another instance updated the bucket
+		s.conflicts--
+		s.version++
+		return false, nil
+	}
+	if version != s.version {
+		return false, nil
+	}
+	s.key = key
+	s.value = value
+	s.version++
+	return true, nil
+}
+
+func TestGlobalRateLimiter_Take(t *testing.T) {
+	store := &fakeCasStore{lock: &sync.Mutex{}}
+	now := time.Unix(1000, 0)
+	limiter := newGlobalRateLimiter("TEST", "zdm-proxy-rate-limit/bucket", store, func() time.Time {
+		return now
+	})
+	ctx := context.Background()
+
+	// the bucket starts full
+	taken, err := limiter.Take(ctx, 100, 60)
+	require.Nil(t, err)
+	require.Equal(t, 60, taken)
+	taken, err = limiter.Take(ctx, 100, 60)
+	require.Nil(t, err)
+	require.Equal(t, 40, taken)
+	taken, err = limiter.Take(ctx, 100, 1)
+	require.Nil(t, err)
+	require.Equal(t, 0, taken)
+
+	// refilled at the rate, up to one second of tokens
+	now = now.Add(250 * time.Millisecond)
+	taken, err = limiter.Take(ctx, 100, 60)
+	require.Nil(t, err)
+	require.Equal(t, 25, taken)
+	now = now.Add(time.Hour)
+	taken, err = limiter.Take(ctx, 100, 1000)
+	require.Nil(t, err)
+	require.Equal(t, 100, taken)
+
+	// the clock of another instance is behind
+	state := &bucketState{}
+	require.Nil(t, json.Unmarshal(store.value, state))
+	require.Equal(t, now.UnixMilli(), state.UpdatedAtMs)
+	now = now.Add(-time.Second)
+	taken, err = limiter.Take(ctx, 100, 1)
+	require.Nil(t, err)
+	require.Equal(t, 0, taken)
+
+	// concurrent updates are retried
+	now = now.Add(2 * time.Second)
+	store.conflicts = 3
+	taken, err = limiter.Take(ctx, 100, 10)
+	require.Nil(t, err)
+	require.Equal(t, 10, taken)
+
+	store.conflicts = maxCasAttempts
+	_, err = limiter.Take(ctx, 100, 10)
+	require.NotNil(t, err)
+	require.Equal(t, "could not update the global rate limit bucket, too many concurrent updates", err.Error())
+}
+
+func TestConsulStore(t *testing.T) {
+	lock := &sync.Mutex{}
+	var value []byte
+	var index uint64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/v1/kv/zdm-proxy-rate-limit/bucket", r.URL.Path)
+		require.Equal(t, "secret", r.Header.Get("X-Consul-Token"))
+		lock.Lock()
+		defer lock.Unlock()
+		switch r.Method {
+		case http.MethodGet:
+			if index == 0 {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			require.Nil(t, json.NewEncoder(w).Encode([]map[string]interface{}{{
+				"Key": "zdm-proxy-rate-limit/bucket", "Value": base64.StdEncoding.EncodeToString(value), "ModifyIndex": index}}))
+		case http.MethodPut:
+			cas, err := strconv.ParseUint(r.URL.Query().Get("cas"), 10, 64)
+			require.Nil(t, err)
+			if cas != index {
+				_, _ = w.Write([]byte("false"))
+				return
+			}
+			value, err = io.ReadAll(r.Body)
+			require.Nil(t, err)
+			index += 10
+			_, _ = w.Write([]byte("true"))
+		}
+	}))
+	defer server.Close()
+
+	limiter, err := NewGlobalRateLimiter(newTestConfig("CONSUL", server.URL))
+	require.Nil(t, err)
+	store := limiter.store
+
+	read, version, err := store.read(context.Background(), limiter.key)
+	require.Nil(t, err)
+	require.Nil(t, read)
+	require.Equal(t, uint64(0), version)
+
+	swapped, err := store.compareAndSwap(context.Background(), limiter.key, []byte(`{"tokens":1}`), 0)
+	require.Nil(t, err)
+	require.True(t, swapped)
+	swapped, err = store.compareAndSwap(context.Background(), limiter.key, []byte(`{"tokens":2}`), 0)
+	require.Nil(t, err)
+	require.False(t, swapped)
+
+	read, version, err = store.read(context.Background(), limiter.key)
+	require.Nil(t, err)
+	require.Equal(t, `{"tokens":1}`, string(read))
+	require.Equal(t, uint64(10), version)
+}
+
+func TestEtcdStore(t *testing.T) {
+	encode := func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	}
+	lock := &sync.Mutex{}
+	value := ""
+	var revision uint64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&body))
+		lock.Lock()
+		defer lock.Unlock()
+		switch r.URL.Path {
+		case "/v3/kv/range":
+			require.Equal(t, encode("zdm-proxy-rate-limit/bucket"), body["key"])
+			if revision == 0 {
+				_, _ = w.Write([]byte(`{"header":{"revision":"3"}}`))
+				return
+			}
+			require.Nil(t, json.NewEncoder(w).Encode(map[string]interface{}{"kvs": []map[string]string{{
+				"key": encode("zdm-proxy-rate-limit/bucket"), "value": value,
+				"mod_revision": strconv.FormatUint(revision, 10)}}}))
+		case "/v3/kv/txn":
+			compare := body["compare"].([]interface{})[0].(map[string]interface{})
+			require.Equal(t, "MOD", compare["target"])
+			if compare["mod_revision"] != strconv.FormatUint(revision, 10) {
+				_, _ = w.Write([]byte(`{"succeeded":false}`))
+				return
+			}
+			put := body["success"].([]interface{})[0].(map[string]interface{})["request_put"].(map[string]interface{})
+			value = put["value"].(string)
+			revision = 7
+			_, _ = w.Write([]byte(`{"succeeded":true}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	limiter, err := NewGlobalRateLimiter(newTestConfig("ETCD", server.URL))
+	require.Nil(t, err)
+	taken, err := limiter.Take(context.Background(), 10, 4)
+	require.Nil(t, err)
+	require.Equal(t, 4, taken)
+
+	read, version, err := limiter.store.read(context.Background(), limiter.key)
+	require.Nil(t, err)
+	require.Equal(t, uint64(7), version)
+	state := &bucketState{}
+	require.Nil(t, json.Unmarshal(read, state))
+	require.Equal(t, float64(6), state.Tokens)
+
+	swapped, err := limiter.store.compareAndSwap(context.Background(), limiter.key, read, 3)
+	require.Nil(t, err)
+	require.False(t, swapped)
+}
@@ -0,0 +1,238 @@
+package sharedconfig
+
+import (
+	"context"
+	"fmt"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"github.com/jpillora/backoff"
+	log "github.com/sirupsen/logrus"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Keys of the shared settings, relative to ZDM_SHARED_CONFIG_PREFIX.
+const (
+	KeyPrimaryCluster   = "primary_cluster"
+	KeyReadMode         = "read_mode"
+	KeyRequestRateLimit = "request_rate_limit"
+
+	KeyGlobalRequestRateLimit = "global_request_rate_limit"
+
+	// KeyAutoCutover holds the record of the automatic cutover of reads to Target, it's written by the proxy and it
+	// isn't a setting.
+	KeyAutoCutover = "auto_cutover"
+
+	// KeyClusterRolesSwappedAt is written after the primary cluster when the cluster roles are swapped with the admin
+	// API, every new value makes the instances drain their client connections so that the swap applies to them too.
+	KeyClusterRolesSwappedAt = "cluster_roles_swapped_at"
+)
+
+const (
+	requestTimeout = 10 * time.Second
+	watchTimeout   = 5 * time.Minute
+)
+
+// Settings are the values of the shared keys, a setting is empty if its key is not in the store in which case the
+// proxy uses its own configuration (i.e. the environment variables) for it.
+type Settings struct {
+	PrimaryCluster   string
+	ReadMode         string
+	RequestRateLimit string
+
+	GlobalRequestRateLimit string
+
+	ClusterRolesSwappedAt string
+}
+
+func newSettings(values map[string]string) *Settings {
+	return &Settings{
+		PrimaryCluster:   strings.TrimSpace(values[KeyPrimaryCluster]),
+		ReadMode:         strings.TrimSpace(values[KeyReadMode]),
+		RequestRateLimit: strings.TrimSpace(values[KeyRequestRateLimit]),
+
+		GlobalRequestRateLimit: strings.TrimSpace(values[KeyGlobalRequestRateLimit]),
+
+		ClusterRolesSwappedAt: strings.TrimSpace(values[KeyClusterRolesSwappedAt]),
+	}
+}
+
+// Apply returns a copy of conf with the settings that are set and validates them.
+func (s *Settings) Apply(conf *config.Config) (*config.Config, error) {
+	applied := *conf
+	if s.PrimaryCluster != "" {
+		applied.PrimaryCluster = s.PrimaryCluster
+	}
+	if s.ReadMode != "" {
+		applied.ReadMode = s.ReadMode
+	}
+	if s.RequestRateLimit != "" {
+		rateLimit, err := strconv.Atoi(s.RequestRateLimit)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for the %v key: %v, it must be an integer", KeyRequestRateLimit, s.RequestRateLimit)
+		}
+		applied.ProxyRequestRateLimit = rateLimit
+	}
+	if s.GlobalRequestRateLimit != "" {
+		rateLimit, err := strconv.Atoi(s.GlobalRequestRateLimit)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for the %v key: %v, it must be an integer",
+				KeyGlobalRequestRateLimit, s.GlobalRequestRateLimit)
+		}
+		applied.ProxyGlobalRequestRateLimit = rateLimit
+	}
+
+	if _, err := applied.ParsePrimaryCluster(); err != nil {
+		return nil, err
+	}
+	if _, err := applied.ParseReadMode(); err != nil {
+		return nil, err
+	}
+	if _, err := applied.ParseProxyRequestRateLimit(); err != nil {
+		return nil, err
+	}
+	if _, err := applied.ParseProxyGlobalRequestRateLimit(); err != nil {
+		return nil, err
+	}
+	return &applied, nil
+}
+
+// source reads the keys with a prefix from a key-value store, the returned maps don't include the prefix in the keys.
+type source interface {
+	// get returns the current values and the index of the store that wait can be called with.
+	get(ctx context.Context, prefix string) (map[string]string, uint64, error)
+
+	// wait returns the values once they change after index, or when the store returns because of a timeout in which
+	// case they can be the same.
+	wait(ctx context.Context, prefix string, index uint64) (map[string]string, uint64, error)
+}
+
+// Watcher reads the shared settings of the proxy fleet from etcd or Consul and watches them, so that a single change in
+// the store is applied by every proxy instance.
+type Watcher struct {
+	backendName string
+	prefix      string
+	source      source
+
+	values map[string]string
+	index  uint64
+}
+
+// NewWatcher returns the Watcher of the backend configured with ZDM_SHARED_CONFIG_BACKEND or nil if shared
+// configuration is disabled.
+func NewWatcher(conf *config.Config) (*Watcher, error) {
+	backend, err := conf.ParseSharedConfigBackend()
+	if err != nil {
+		return nil, err
+	}
+	endpoints, err := conf.ParseSharedConfigEndpoints()
+	if err != nil {
+		return nil, err
+	}
+	if endpoints == nil {
+		return nil, nil
+	}
+
+	client := newEndpointClient(endpoints, conf.SharedConfigToken)
+	var src source
+	switch backend {
+	case common.SharedConfigBackendEtcd:
+		src = &etcdSource{client: client}
+	case common.SharedConfigBackendConsul:
+		src = &consulSource{client: client}
+	default:
+		return nil, fmt.Errorf("unsupported shared configuration backend %v", backend)
+	}
+	return newWatcher(backend.String(), conf.SharedConfigPrefix, src), nil
+}
+
+func newWatcher(backendName string, prefix string, src source) *Watcher {
+	return &Watcher{
+		backendName: backendName,
+		prefix:      prefix,
+		source:      src,
+	}
+}
+
+// Load reads the current settings, it must be called before Watch.
+func (w *Watcher) Load(ctx context.Context) (*Settings, error) {
+	values, index, err := w.source.get(ctx, w.prefix)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the shared configuration from %v: %w", w.backendName, err)
+	}
+	w.values, w.index = values, index
+	return newSettings(values), nil
+}
+
+// Watch calls onChange with the new settings every time the keys change in the store, until ctx is done. Errors are
+// logged and the store is polled again with a backoff, the proxy keeps the last settings in the meantime.
+func (w *Watcher) Watch(ctx context.Context, onChange func(*Settings)) {
+	retryBackoff := &backoff.Backoff{Min: 500 * time.Millisecond, Max: 30 * time.Second, Factor: 2, Jitter: true}
+	for ctx.Err() == nil {
+		values, index, err := w.source.wait(ctx, w.prefix, w.index)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			delay := retryBackoff.Duration()
+			log.Warnf("Could not watch the shared configuration in %v, retrying in %v: %v", w.backendName, delay, err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(delay):
+			}
+			continue
+		}
+		retryBackoff.Reset()
+		w.index = index
+		if reflect.DeepEqual(values, w.values) {
+			continue
+		}
+		w.values = values
+		onChange(newSettings(values))
+	}
+}
+
+// endpointClient sends the requests to one of the endpoints and moves to the next endpoint when a request fails, the
+// failed request is not retried (the Watcher polls again).
+type endpointClient struct {
+	endpoints  []*url.URL
+	current    *int32
+	token      string
+	httpClient *http.Client
+}
+
+func newEndpointClient(endpoints []*url.URL, token string) *endpointClient {
+	return &endpointClient{
+		endpoints:  endpoints,
+		current:    new(int32),
+		token:      token,
+		httpClient: &http.Client{},
+	}
+}
+
+func (c *endpointClient) do(request *http.Request, setAuth func(*http.Request, string)) (*http.Response, error) {
+	index := atomic.LoadInt32(c.current)
+	endpoint := c.endpoints[int(index)%len(c.endpoints)]
+	request.URL.Scheme = endpoint.Scheme
+	request.URL.Host = endpoint.Host
+	request.URL.Path = strings.TrimSuffix(endpoint.Path, "/") + request.URL.Path
+	request.Host = endpoint.Host
+	if c.token != "" {
+		setAuth(request, c.token)
+	}
+	response, err := c.httpClient.Do(request)
+	if err == nil && response.StatusCode/100 == 5 {
+		response.Body.Close()
+		err = fmt.Errorf("unexpected status %v", response.Status)
+	}
+	if err != nil {
+		atomic.CompareAndSwapInt32(c.current, index, index+1)
+		return nil, fmt.Errorf("request to %v failed: %w", endpoint.Host, err)
+	}
+	return response, nil
+}
@@ -0,0 +1,283 @@
+package sharedconfig
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSettings_Apply(t *testing.T) {
+	conf := &config.Config{
+		PrimaryCluster: "ORIGIN", ReadMode: "PRIMARY_ONLY", ProxyRequestRateLimit: 100,
+		SharedConfigBackend: "CONSUL", SharedConfigPrefix: "zdm-proxy/",
+		ProxyGlobalRequestRateLimitKey: "zdm-proxy-rate-limit/bucket",
+	}
+
+	tests := []struct {
+		name                string
+		values              map[string]string
+		expectedPrimary     string
+		expectedReadMode    string
+		expectedLimit       int
+		expectedGlobalLimit int
+		expectedErr         string
+	}{
+		{"no keys", map[string]string{}, "ORIGIN", "PRIMARY_ONLY", 100, 0, ""},
+		{"all keys", map[string]string{
+			KeyPrimaryCluster: "TARGET", KeyReadMode: " DUAL_ASYNC_ON_SECONDARY\n", KeyRequestRateLimit: "0",
+			KeyGlobalRequestRateLimit: "5000", "other": "ignored"},
+			"TARGET", "DUAL_ASYNC_ON_SECONDARY", 0, 5000, ""},
+		{"invalid primary cluster", map[string]string{KeyPrimaryCluster: "BOTH"}, "", "", 0, 0,
+			"invalid value for ZDM_PRIMARY_CLUSTER; possible values are: ORIGIN and TARGET"},
+		{"invalid rate limit", map[string]string{KeyRequestRateLimit: "fast"}, "", "", 0, 0,
+			"invalid value for the request_rate_limit key: fast, it must be an integer"},
+		{"negative rate limit", map[string]string{KeyRequestRateLimit: "-1"}, "", "", 0, 0,
+			"invalid value for ZDM_PROXY_REQUEST_RATE_LIMIT: -1, it must not be negative"},
+		{"negative global rate limit", map[string]string{KeyGlobalRequestRateLimit: "-1"}, "", "", 0, 0,
+			"invalid value for ZDM_PROXY_GLOBAL_REQUEST_RATE_LIMIT: -1, it must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			applied, err := newSettings(tt.values).Apply(conf)
+			if tt.expectedErr != "" {
+				require.NotNil(t, err)
+				require.Equal(t, tt.expectedErr, err.Error())
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedPrimary, applied.PrimaryCluster)
+			require.Equal(t, tt.expectedReadMode, applied.ReadMode)
+			require.Equal(t, tt.expectedLimit, applied.ProxyRequestRateLimit)
+			require.Equal(t, tt.expectedGlobalLimit, applied.ProxyGlobalRequestRateLimit)
+			// the configuration of the proxy is not modified
+			require.Equal(t, "ORIGIN", conf.PrimaryCluster)
+		})
+	}
+}
+
+type fakeSource struct {
+	lock    *sync.Mutex
+	updates chan map[string]string
+	errors  int
+}
+
+func (s *fakeSource) get(_ context.Context, _ string) (map[string]string, uint64, error) {
+	return map[string]string{KeyReadMode: "PRIMARY_ONLY"}, 1, nil
+}
+
+func (s *fakeSource) wait(ctx context.Context, _ string, index uint64) (map[string]string, uint64, error) {
+	s.lock.Lock()
+	if s.errors > 0 {
+		s.errors--
+		s.lock.Unlock()
+		return nil, 0, errors.New("connection refused")
+	}
+	s.lock.Unlock()
+	select {
+	case <-ctx.Done():
+		return nil, 0, ctx.Err()
+	case values := <-s.updates:
+		return values, index + 1, nil
+	}
+}
+
+func TestWatcher_Watch(t *testing.T) {
+	src := &fakeSource{lock: &sync.Mutex{}, updates: make(chan map[string]string), errors: 1}
+	watcher := newWatcher("TEST", "zdm-proxy/", src)
+	settings, err := watcher.Load(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, &Settings{ReadMode: "PRIMARY_ONLY"}, settings)
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+	changes := make(chan *Settings, 10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		watcher.Watch(ctx, func(settings *Settings) {
+			changes <- settings
+		})
+	}()
+
+	// a timeout of the store without any change doesn't call onChange
+	src.updates <- map[string]string{KeyReadMode: "PRIMARY_ONLY"}
+	src.updates <- map[string]string{KeyReadMode: "PRIMARY_ONLY", KeyPrimaryCluster: "TARGET"}
+	select {
+	case settings = <-changes:
+		require.Equal(t, &Settings{ReadMode: "PRIMARY_ONLY", PrimaryCluster: "TARGET"}, settings)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "timed out waiting for the change")
+	}
+	require.Equal(t, uint64(3), watcher.index)
+
+	cancelFn()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "Watch didn't return after the context was canceled")
+	}
+	require.Len(t, changes, 0)
+}
+
+func newTestConfig(backend string, endpoints ...string) *config.Config {
+	conf := &config.Config{
+		SharedConfigBackend: backend,
+		SharedConfigPrefix:  "zdm-proxy/",
+		SharedConfigToken:   "secret",
+
+		SharedConfigLeaderTtlMs:        15000,
+		ProxyGlobalRequestRateLimitKey: "zdm-proxy-rate-limit/bucket",
+	}
+	for i, endpoint := range endpoints {
+		if i > 0 {
+			conf.SharedConfigEndpoints += ","
+		}
+		conf.SharedConfigEndpoints += endpoint
+	}
+	return conf
+}
+
+func TestNewWatcher_Disabled(t *testing.T) {
+	watcher, err := NewWatcher(&config.Config{SharedConfigBackend: "NONE"})
+	require.Nil(t, err)
+	require.Nil(t, watcher)
+}
+
+func TestConsulSource(t *testing.T) {
+	var requests []*http.Request
+	lock := &sync.Mutex{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		requests = append(requests, r)
+		lock.Unlock()
+		require.Equal(t, "/v1/kv/zdm-proxy/", r.URL.Path)
+		require.Equal(t, "secret", r.Header.Get("X-Consul-Token"))
+		if r.URL.Query().Get("index") == "" {
+			w.Header().Set("X-Consul-Index", "7")
+			_, _ = fmt.Fprintf(w, `[{"Key":"zdm-proxy/","Value":null},{"Key":"zdm-proxy/read_mode","Value":%q}]`,
+				base64.StdEncoding.EncodeToString([]byte("HEDGED")))
+			return
+		}
+		w.Header().Set("X-Consul-Index", "9")
+		_, _ = fmt.Fprintf(w, `[{"Key":"zdm-proxy/read_mode","Value":%q},{"Key":"zdm-proxy/primary_cluster","Value":%q}]`,
+			base64.StdEncoding.EncodeToString([]byte("HEDGED")), base64.StdEncoding.EncodeToString([]byte("TARGET")))
+	}))
+	defer server.Close()
+
+	// the first endpoint is down, the watcher moves to the next one
+	watcher, err := NewWatcher(newTestConfig("CONSUL", "http://127.0.0.1:1", server.URL))
+	require.Nil(t, err)
+	_, err = watcher.Load(context.Background())
+	require.NotNil(t, err)
+
+	settings, err := watcher.Load(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, &Settings{ReadMode: "HEDGED"}, settings)
+	require.Equal(t, uint64(7), watcher.index)
+
+	values, index, err := watcher.source.wait(context.Background(), watcher.prefix, watcher.index)
+	require.Nil(t, err)
+	require.Equal(t, map[string]string{KeyReadMode: "HEDGED", KeyPrimaryCluster: "TARGET"}, values)
+	require.Equal(t, uint64(9), index)
+	require.Len(t, requests, 2)
+	require.Equal(t, url.Values{"index": {"7"}, "wait": {"300s"}, "recurse": {"true"}}, requests[1].URL.Query())
+}
+
+func TestConsulSource_NoKeys(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Consul-Index", "3")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	watcher, err := NewWatcher(newTestConfig("CONSUL", server.URL))
+	require.Nil(t, err)
+	settings, err := watcher.Load(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, &Settings{}, settings)
+	require.Equal(t, uint64(3), watcher.index)
+}
+
+func TestEtcdSource(t *testing.T) {
+	encode := func(s string) string {
+		return base64.StdEncoding.EncodeToString([]byte(s))
+	}
+	lock := &sync.Mutex{}
+	revision := 5
+	values := map[string]string{"zdm-proxy/request_rate_limit": "500"}
+	watchStarted := make(chan map[string]interface{}, 1)
+	changed := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "secret", r.Header.Get("Authorization"))
+		var body map[string]interface{}
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&body))
+		switch r.URL.Path {
+		case "/v3/kv/range":
+			require.Equal(t, encode("zdm-proxy/"), body["key"])
+			require.Equal(t, encode("zdm-proxy0"), body["range_end"])
+			lock.Lock()
+			var kvs []map[string]string
+			for key, value := range values {
+				kvs = append(kvs, map[string]string{"key": encode(key), "value": encode(value)})
+			}
+			response := map[string]interface{}{"header": map[string]string{"revision": strconv.Itoa(revision)}, "kvs": kvs}
+			lock.Unlock()
+			require.Nil(t, json.NewEncoder(w).Encode(response))
+		case "/v3/watch":
+			_, _ = fmt.Fprintln(w, `{"result":{"header":{"revision":"5"},"created":true}}`)
+			w.(http.Flusher).Flush()
+			watchStarted <- body["create_request"].(map[string]interface{})
+			<-changed
+			_, _ = fmt.Fprintf(w, `{"result":{"header":{"revision":"6"},"events":[{"kv":{"key":%q}}]}}`+"\n",
+				encode("zdm-proxy/primary_cluster"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	watcher, err := NewWatcher(newTestConfig("ETCD", server.URL))
+	require.Nil(t, err)
+	settings, err := watcher.Load(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, &Settings{RequestRateLimit: "500"}, settings)
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+	changes := make(chan *Settings, 1)
+	go watcher.Watch(ctx, func(settings *Settings) {
+		changes <- settings
+	})
+
+	createRequest := <-watchStarted
+	require.Equal(t, "6", createRequest["start_revision"])
+	lock.Lock()
+	revision = 6
+	values["zdm-proxy/primary_cluster"] = "TARGET"
+	lock.Unlock()
+	close(changed)
+
+	select {
+	case settings = <-changes:
+		require.Equal(t, &Settings{RequestRateLimit: "500", PrimaryCluster: "TARGET"}, settings)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "timed out waiting for the change")
+	}
+}
+
+func TestEtcdPrefixEnd(t *testing.T) {
+	require.Equal(t, []byte("zdm-proxy0"), etcdPrefixEnd("zdm-proxy/"))
+	require.Equal(t, []byte("b"), etcdPrefixEnd("a\xff"))
+	require.Equal(t, []byte{0}, etcdPrefixEnd("\xff"))
+}
@@ -0,0 +1,172 @@
+package zdmproxy
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	"sync"
+)
+
+// asyncReadComparison compares the response of the primary cluster with the response of the async connector for a read
+// that is also sent to the secondary cluster in the DUAL_ASYNC_ON_SECONDARY read mode, which is enabled by
+// ZDM_ASYNC_READ_COMPARISON_ENABLED. Only cheap attributes are compared, the outcome (success or error code), the
+// number of rows and the size of their values, so the divergence counters are a consistency signal and not a
+// verification of the results (two different rows of the same size are not detected).
+//
+// The responses are compared when the second one is received, the reads for which a cluster didn't respond (a timeout
+// for example) are not compared. Only the first page of a paginated read is compared.
+type asyncReadComparison struct {
+	lock            *sync.Mutex
+	primaryResponse *frame.RawFrame
+	asyncResponse   *frame.RawFrame
+	proxyMetrics    *metrics.ProxyMetrics
+}
+
+func newAsyncReadComparison(proxyMetrics *metrics.ProxyMetrics) *asyncReadComparison {
+	return &asyncReadComparison{
+		lock:         &sync.Mutex{},
+		proxyMetrics: proxyMetrics,
+	}
+}
+
+// readResponseSummary holds the attributes of a read response that are compared.
+type readResponseSummary struct {
+	errorCode  primitive.ErrorCode // only set if isError is true
+	isError    bool
+	isRows     bool
+	rowCount   int
+	resultSize int
+}
+
+type asyncReadDivergence string
+
+const (
+	asyncReadDivergenceNone       = asyncReadDivergence("")
+	asyncReadDivergenceOutcome    = asyncReadDivergence("outcome")
+	asyncReadDivergenceRowCount   = asyncReadDivergence("row count")
+	asyncReadDivergenceResultSize = asyncReadDivergence("result size")
+)
+
+// setPrimaryResponse and setAsyncResponse are nil safe. The response must not be modified afterwards, the header of a
+// response that is written to the client connection should be copied before.
+func (c *asyncReadComparison) setPrimaryResponse(response *frame.RawFrame) {
+	if c == nil {
+		return
+	}
+	c.set(response, false)
+}
+
+func (c *asyncReadComparison) setAsyncResponse(response *frame.RawFrame) {
+	if c == nil {
+		return
+	}
+	c.set(response, true)
+}
+
+func (c *asyncReadComparison) set(response *frame.RawFrame, async bool) {
+	c.lock.Lock()
+	if async {
+		c.asyncResponse = response
+	} else {
+		c.primaryResponse = response
+	}
+	primaryResponse, asyncResponse := c.primaryResponse, c.asyncResponse
+	c.lock.Unlock()
+	if primaryResponse == nil || asyncResponse == nil {
+		return
+	}
+
+	divergence, err := compareReadResponses(primaryResponse, asyncResponse)
+	if err != nil {
+		log.Debugf("Could not compare the response of the async read with the response of the primary cluster: %v", err)
+		return
+	}
+	c.proxyMetrics.AsyncReadComparisons.Add(1)
+	switch divergence {
+	case asyncReadDivergenceOutcome:
+		c.proxyMetrics.AsyncReadDivergencesOutcome.Add(1)
+	case asyncReadDivergenceRowCount:
+		c.proxyMetrics.AsyncReadDivergencesRowCount.Add(1)
+	case asyncReadDivergenceResultSize:
+		c.proxyMetrics.AsyncReadDivergencesResultSize.Add(1)
+	}
+	if divergence != asyncReadDivergenceNone {
+		log.Tracef("Async read response differs from the response of the primary cluster (%v).", divergence)
+	}
+}
+
+// compareReadResponses returns the first attribute that differs between both responses, in the order outcome, row
+// count and result size.
+func compareReadResponses(primaryResponse *frame.RawFrame, asyncResponse *frame.RawFrame) (asyncReadDivergence, error) {
+	primarySummary, err := summarizeReadResponse(primaryResponse)
+	if err != nil {
+		return asyncReadDivergenceNone, fmt.Errorf("could not decode the response of the primary cluster: %w", err)
+	}
+	asyncSummary, err := summarizeReadResponse(asyncResponse)
+	if err != nil {
+		return asyncReadDivergenceNone, fmt.Errorf("could not decode the async response: %w", err)
+	}
+	switch {
+	case primarySummary.isError != asyncSummary.isError || primarySummary.errorCode != asyncSummary.errorCode ||
+		primarySummary.isRows != asyncSummary.isRows:
+		return asyncReadDivergenceOutcome, nil
+	case primarySummary.rowCount != asyncSummary.rowCount:
+		return asyncReadDivergenceRowCount, nil
+	case primarySummary.resultSize != asyncSummary.resultSize:
+		return asyncReadDivergenceResultSize, nil
+	default:
+		return asyncReadDivergenceNone, nil
+	}
+}
+
+func summarizeReadResponse(response *frame.RawFrame) (*readResponseSummary, error) {
+	switch response.Header.OpCode {
+	case primitive.OpCodeError:
+		errMsg, err := decodeError(response)
+		if err != nil {
+			return nil, err
+		}
+		return &readResponseSummary{isError: true, errorCode: errMsg.GetErrorCode()}, nil
+	case primitive.OpCodeResult:
+		body, err := defaultCodec.DecodeBody(response.Header, bytes.NewReader(response.Body))
+		if err != nil {
+			return nil, err
+		}
+		rowsResult, ok := body.Message.(*message.RowsResult)
+		if !ok {
+			return &readResponseSummary{}, nil
+		}
+		summary := &readResponseSummary{isRows: true, rowCount: len(rowsResult.Data)}
+		for _, row := range rowsResult.Data {
+			for _, column := range row {
+				summary.resultSize += len(column)
+			}
+		}
+		return summary, nil
+	default:
+		return nil, fmt.Errorf("unexpected opcode %v", response.Header.OpCode)
+	}
+}
+
+// shouldCompareAsyncRead returns true for the client reads that are sent to the primary cluster and, as fire and
+// forget, to the async connector.
+func (ch *ClientHandler) shouldCompareAsyncRead(
+	requestInfo RequestInfo, hedged bool, customResponseChannel chan *customResponse) bool {
+	if !ch.conf.AsyncReadComparisonEnabled || ch.asyncConnector == nil || hedged || customResponseChannel != nil ||
+		!requestInfo.ShouldAlsoBeSentAsync() || !requestInfo.ShouldBeTrackedInMetrics() {
+		return false
+	}
+	switch requestInfo.GetForwardDecision() {
+	case forwardToOrigin:
+		return ch.primaryCluster == common.ClusterTypeOrigin
+	case forwardToTarget:
+		return ch.primaryCluster == common.ClusterTypeTarget
+	default:
+		return false
+	}
+}
@@ -0,0 +1,101 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestCompareReadResponses(t *testing.T) {
+	toRawFrame := func(msg message.Message) *frame.RawFrame {
+		f, err := defaultCodec.ConvertToRawFrame(frame.NewFrame(primitive.ProtocolVersion4, 1, msg))
+		require.Nil(t, err)
+		return f
+	}
+	rows := func(values ...string) *frame.RawFrame {
+		rowSet := message.RowSet{}
+		for _, value := range values {
+			rowSet = append(rowSet, message.Row{[]byte(value)})
+		}
+		return toRawFrame(&message.RowsResult{
+			Metadata: &message.RowsMetadata{ColumnCount: 1},
+			Data:     rowSet,
+		})
+	}
+	readTimeout := toRawFrame(&message.ReadTimeout{ErrorMessage: "timeout", Consistency: primitive.ConsistencyLevelOne})
+	overloaded := toRawFrame(&message.Overloaded{ErrorMessage: "overloaded"})
+
+	tests := []struct {
+		name       string
+		primary    *frame.RawFrame
+		async      *frame.RawFrame
+		divergence asyncReadDivergence
+	}{
+		{name: "same rows", primary: rows("a", "bc"), async: rows("a", "bc")},
+		{name: "different rows of the same size", primary: rows("a", "bc"), async: rows("b", "cd")},
+		{name: "no rows", primary: rows(), async: rows()},
+		{name: "same error", primary: readTimeout, async: readTimeout},
+		{name: "void results", primary: toRawFrame(&message.VoidResult{}), async: toRawFrame(&message.VoidResult{})},
+		{name: "error and rows", primary: rows("a"), async: readTimeout, divergence: asyncReadDivergenceOutcome},
+		{name: "different errors", primary: overloaded, async: readTimeout, divergence: asyncReadDivergenceOutcome},
+		{name: "void and rows", primary: rows(), async: toRawFrame(&message.VoidResult{}), divergence: asyncReadDivergenceOutcome},
+		{name: "different row count", primary: rows("a", "b"), async: rows("ab"), divergence: asyncReadDivergenceRowCount},
+		{name: "different result size", primary: rows("a", "b"), async: rows("a", "bc"), divergence: asyncReadDivergenceResultSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			divergence, err := compareReadResponses(tt.primary, tt.async)
+			require.Nil(t, err)
+			require.Equal(t, tt.divergence, divergence)
+		})
+	}
+
+	_, err := compareReadResponses(rows("a"), toRawFrame(&message.Ready{}))
+	require.NotNil(t, err)
+}
+
+func TestAsyncReadComparison(t *testing.T) {
+	toRawFrame := func(msg message.Message) *frame.RawFrame {
+		f, err := defaultCodec.ConvertToRawFrame(frame.NewFrame(primitive.ProtocolVersion4, 1, msg))
+		require.Nil(t, err)
+		return f
+	}
+	rowsResult := toRawFrame(&message.RowsResult{
+		Metadata: &message.RowsMetadata{ColumnCount: 1},
+		Data:     message.RowSet{message.Row{[]byte("a")}},
+	})
+	overloaded := toRawFrame(&message.Overloaded{ErrorMessage: "overloaded"})
+
+	comparisons, outcomeDivergences, rowCountDivergences := &countingGauge{}, &countingGauge{}, &countingGauge{}
+	proxyMetrics := newFakeProxyMetrics()
+	proxyMetrics.AsyncReadComparisons = comparisons
+	proxyMetrics.AsyncReadDivergencesOutcome = outcomeDivergences
+	proxyMetrics.AsyncReadDivergencesRowCount = rowCountDivergences
+
+	// the responses are compared once both are set, whatever the order
+	comparison := newAsyncReadComparison(proxyMetrics)
+	comparison.setAsyncResponse(rowsResult)
+	require.Equal(t, 0, comparisons.value)
+	comparison.setPrimaryResponse(rowsResult)
+	require.Equal(t, 1, comparisons.value)
+	require.Equal(t, 0, outcomeDivergences.value)
+
+	comparison = newAsyncReadComparison(proxyMetrics)
+	comparison.setPrimaryResponse(rowsResult)
+	comparison.setAsyncResponse(overloaded)
+	require.Equal(t, 2, comparisons.value)
+	require.Equal(t, 1, outcomeDivergences.value)
+	require.Equal(t, 0, rowCountDivergences.value)
+
+	// a read without an async response is not compared
+	comparison = newAsyncReadComparison(proxyMetrics)
+	comparison.setPrimaryResponse(rowsResult)
+	require.Equal(t, 2, comparisons.value)
+
+	var disabled *asyncReadComparison
+	disabled.setPrimaryResponse(rowsResult)
+	disabled.setAsyncResponse(rowsResult)
+}
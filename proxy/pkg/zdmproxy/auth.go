@@ -6,12 +6,20 @@ import (
 	"github.com/datastax/go-cassandra-native-protocol/frame"
 	"github.com/datastax/go-cassandra-native-protocol/message"
 	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
 )
 
+// Authenticator computes the tokens that are sent to a cluster in AUTH_RESPONSE messages during a SASL exchange.
+type Authenticator interface {
+	InitialResponse(authenticator string) ([]byte, error)
+	EvaluateChallenge(challenge []byte) ([]byte, error)
+}
+
 // Returns a proper response frame to authenticate using passed in username and password
 // Utilizes the users request frame to maintain the correct version & stream id.
 func performHandshakeStep(
-	authenticator *DsePlainTextAuthenticator,
+	authenticator Authenticator,
 	version primitive.ProtocolVersion,
 	streamId int16,
 	lastResponse *frame.Frame) (*frame.Frame, error) {
@@ -112,6 +120,25 @@ func (a *DsePlainTextAuthenticator) EvaluateChallenge(challenge []byte) ([]byte,
 	return a.Credentials.Marshal(), nil
 }
 
+// newClusterAuthenticator returns the authenticator that the proxy uses for handshakes that it performs on its own
+// with the provided cluster. If SigV4 is configured for the cluster then the username is used as the AWS access key id
+// and the password as the AWS secret access key.
+func newClusterAuthenticator(conf *config.Config, clusterType common.ClusterType, credentials *AuthCredentials) Authenticator {
+	var sigV4Region, sigV4SessionToken string
+	switch clusterType {
+	case common.ClusterTypeOrigin:
+		sigV4Region, sigV4SessionToken = conf.OriginSigv4Region, conf.OriginSigv4SessionToken
+	case common.ClusterTypeTarget:
+		sigV4Region, sigV4SessionToken = conf.TargetSigv4Region, conf.TargetSigv4SessionToken
+	}
+
+	if sigV4Region != "" {
+		return NewSigV4Authenticator(sigV4Region, credentials.Username, credentials.Password, sigV4SessionToken)
+	}
+
+	return &DsePlainTextAuthenticator{Credentials: credentials}
+}
+
 // ParseCredentialsFromRequest can return nil in both credsInToken and err in case the request does not contain credentials
 func ParseCredentialsFromRequest(token []byte) (credsInToken *AuthCredentials, err error) {
 	if token == nil || bytes.Compare(token, mechanism) == 0 {
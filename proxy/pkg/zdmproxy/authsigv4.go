@@ -0,0 +1,123 @@
+package zdmproxy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	sigV4ServiceName     = "cassandra"
+	sigV4NoncePrefix     = "nonce="
+	sigV4DateFormat      = "2006-01-02T15:04:05.000Z"
+	sigV4ScopeDateFormat = "20060102"
+)
+
+var sigV4InitialResponse = []byte("SigV4\000\000")
+
+// SigV4Authenticator performs the SigV4 SASL exchange that Amazon Keyspaces uses to authenticate
+// with AWS credentials (access key id, secret access key and an optional session token).
+//
+// The server sends a challenge with a nonce and the client responds with a signature of that nonce
+// computed with the AWS SigV4 algorithm for the "cassandra" service.
+type SigV4Authenticator struct {
+	Region          string
+	AccessKeyId     string
+	SecretAccessKey string
+	SessionToken    string
+
+	currentTime func() time.Time
+}
+
+func NewSigV4Authenticator(region string, accessKeyId string, secretAccessKey string, sessionToken string) *SigV4Authenticator {
+	return &SigV4Authenticator{
+		Region:          region,
+		AccessKeyId:     accessKeyId,
+		SecretAccessKey: secretAccessKey,
+		SessionToken:    sessionToken,
+		currentTime:     time.Now,
+	}
+}
+
+func (a *SigV4Authenticator) InitialResponse(authenticator string) ([]byte, error) {
+	return sigV4InitialResponse, nil
+}
+
+func (a *SigV4Authenticator) EvaluateChallenge(challenge []byte) ([]byte, error) {
+	nonce, err := extractSigV4Nonce(challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(buildSigV4Response(
+		a.Region, nonce, a.AccessKeyId, a.SecretAccessKey, a.SessionToken, a.currentTime().UTC())), nil
+}
+
+func extractSigV4Nonce(challenge []byte) (string, error) {
+	challengeStr := string(challenge)
+	startIdx := strings.Index(challengeStr, sigV4NoncePrefix)
+	if startIdx < 0 {
+		return "", fmt.Errorf("incorrect SASL challenge from server, expecting a SigV4 nonce, got: %v", challengeStr)
+	}
+	startIdx += len(sigV4NoncePrefix)
+
+	endIdx := strings.Index(challengeStr[startIdx:], ",")
+	if endIdx < 0 {
+		return challengeStr[startIdx:], nil
+	}
+	return challengeStr[startIdx : startIdx+endIdx], nil
+}
+
+func buildSigV4Response(
+	region string, nonce string, accessKeyId string, secretAccessKey string, sessionToken string, t time.Time) string {
+	scope := computeSigV4Scope(region, t)
+	canonicalRequest := buildSigV4CanonicalRequest(accessKeyId, scope, nonce, t)
+	signingKey := deriveSigV4SigningKey(secretAccessKey, region, t)
+
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := fmt.Sprintf("AWS4-HMAC-SHA256\n%s\n%s\n%s",
+		t.Format(sigV4DateFormat), scope, hex.EncodeToString(canonicalRequestHash[:]))
+	signature := hmacSha256(signingKey, []byte(stringToSign))
+
+	response := fmt.Sprintf("signature=%s,access_key=%s,amzdate=%s",
+		hex.EncodeToString(signature), accessKeyId, t.Format(sigV4DateFormat))
+	if sessionToken != "" {
+		response += fmt.Sprintf(",session_token=%s", sessionToken)
+	}
+	return response
+}
+
+func computeSigV4Scope(region string, t time.Time) string {
+	return strings.Join([]string{t.Format(sigV4ScopeDateFormat), region, sigV4ServiceName, "aws4_request"}, "/")
+}
+
+func buildSigV4CanonicalRequest(accessKeyId string, scope string, nonce string, t time.Time) string {
+	nonceHash := sha256.Sum256([]byte(nonce))
+	queryParams := []string{
+		"X-Amz-Algorithm=AWS4-HMAC-SHA256",
+		fmt.Sprintf("X-Amz-Credential=%s%%2F%s", accessKeyId, url.QueryEscape(scope)),
+		fmt.Sprintf("X-Amz-Date=%s", url.QueryEscape(t.Format(sigV4DateFormat))),
+		"X-Amz-Expires=900",
+	}
+	sort.Strings(queryParams)
+	return fmt.Sprintf("PUT\n/authenticate\n%s\nhost:%s\n\nhost\n%s",
+		strings.Join(queryParams, "&"), sigV4ServiceName, hex.EncodeToString(nonceHash[:]))
+}
+
+func deriveSigV4SigningKey(secretAccessKey string, region string, t time.Time) []byte {
+	key := hmacSha256([]byte("AWS4"+secretAccessKey), []byte(t.Format(sigV4ScopeDateFormat)))
+	key = hmacSha256(key, []byte(region))
+	key = hmacSha256(key, []byte(sigV4ServiceName))
+	return hmacSha256(key, []byte("aws4_request"))
+}
+
+func hmacSha256(key []byte, data []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(data)
+	return h.Sum(nil)
+}
@@ -0,0 +1,67 @@
+package zdmproxy
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestSigV4Authenticator(t *testing.T) {
+	authenticator := NewSigV4Authenticator("us-west-2", "UserID-1", "UserSecretKey-1", "")
+	authenticator.currentTime = func() time.Time {
+		return time.Date(2020, 6, 9, 22, 41, 51, 0, time.UTC)
+	}
+
+	initialResponse, err := authenticator.InitialResponse("com.amazonaws.cassandra.DefaultPasswordAuthenticator")
+	require.Nil(t, err)
+	require.Equal(t, []byte("SigV4\000\000"), initialResponse)
+
+	response, err := authenticator.EvaluateChallenge([]byte("nonce=91703fdc2ef562e19fbdab0f58e42fe5"))
+	require.Nil(t, err)
+	require.Equal(t,
+		"signature=7f3691c18a81b8ce7457699effbfae5b09b4e0714ab38c1292dbdf082c9ddd87,"+
+			"access_key=UserID-1,amzdate=2020-06-09T22:41:51.000Z",
+		string(response))
+
+	_, err = authenticator.EvaluateChallenge([]byte("PLAIN-START"))
+	require.NotNil(t, err)
+}
+
+func TestSigV4Authenticator_SessionToken(t *testing.T) {
+	authenticator := NewSigV4Authenticator("us-west-2", "UserID-1", "UserSecretKey-1", "SessionToken-1")
+	authenticator.currentTime = func() time.Time {
+		return time.Date(2020, 6, 9, 22, 41, 51, 0, time.UTC)
+	}
+
+	response, err := authenticator.EvaluateChallenge([]byte("nonce=91703fdc2ef562e19fbdab0f58e42fe5"))
+	require.Nil(t, err)
+	require.Equal(t,
+		"signature=7f3691c18a81b8ce7457699effbfae5b09b4e0714ab38c1292dbdf082c9ddd87,"+
+			"access_key=UserID-1,amzdate=2020-06-09T22:41:51.000Z,session_token=SessionToken-1",
+		string(response))
+}
+
+func TestExtractSigV4Nonce(t *testing.T) {
+	tests := []struct {
+		name          string
+		challenge     string
+		expectedNonce string
+		errExpected   bool
+	}{
+		{"nonce only", "nonce=abc", "abc", false},
+		{"nonce with other fields", "nonce=abc,foo=bar", "abc", false},
+		{"nonce after other fields", "foo=bar,nonce=abc", "abc", false},
+		{"no nonce", "PLAIN-START", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nonce, err := extractSigV4Nonce([]byte(tt.challenge))
+			if tt.errExpected {
+				require.NotNil(t, err)
+			} else {
+				require.Nil(t, err)
+				require.Equal(t, tt.expectedNonce, nonce)
+			}
+		})
+	}
+}
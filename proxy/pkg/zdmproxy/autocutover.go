@@ -0,0 +1,190 @@
+package zdmproxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/sharedconfig"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// ReadVerifier compares the results of the reads that are sent to both clusters, see Extensions.ReadVerifier.
+type ReadVerifier interface {
+	// ReadVerificationStats returns the number of reads whose results were compared and the number of them whose
+	// results differed, both since the proxy started.
+	ReadVerificationStats() (compared uint64, mismatched uint64)
+}
+
+// autoCutoverRecord is the audit record of an automatic cutover, it's logged and written to the shared configuration.
+type autoCutoverRecord struct {
+	Time            time.Time `json:"time"`
+	Instance        string    `json:"instance"`
+	VerifiedSince   time.Time `json:"verified_since"`
+	ComparedReads   uint64    `json:"compared_reads"`
+	MismatchedReads uint64    `json:"mismatched_reads"`
+	MaxMismatchRate float64   `json:"max_mismatch_rate"`
+}
+
+// autoCutoverController switches the reads to Target once the mismatch rate reported by the read verifier stays at or
+// below the threshold for the configured duration. The rate is computed for every check interval, an interval with a
+// rate above the threshold or with too few compared reads restarts the verification period.
+type autoCutoverController struct {
+	conf      *common.AutoCutoverConfig
+	verifier  ReadVerifier
+	isCutOver func() bool
+	cutOver   func(ctx context.Context, record *autoCutoverRecord) error
+	instance  string
+	now       func() time.Time
+
+	lastCompared   uint64
+	lastMismatched uint64
+	verifiedSince  time.Time // zero while the verification is failing
+	verifiedReads  uint64
+	verifiedMisses uint64
+}
+
+func newAutoCutoverController(
+	conf *common.AutoCutoverConfig, verifier ReadVerifier, instance string, isCutOver func() bool,
+	cutOver func(ctx context.Context, record *autoCutoverRecord) error) *autoCutoverController {
+	return &autoCutoverController{
+		conf:      conf,
+		verifier:  verifier,
+		isCutOver: isCutOver,
+		cutOver:   cutOver,
+		instance:  instance,
+		now:       time.Now,
+	}
+}
+
+// run checks the mismatch rate every check interval until the reads are switched or ctx is done.
+func (c *autoCutoverController) run(ctx context.Context) {
+	if c.isCutOver() {
+		log.Infof("Auto cutover: reads are already sent to %v.", common.ClusterTypeTarget)
+		return
+	}
+	c.lastCompared, c.lastMismatched = c.verifier.ReadVerificationStats()
+	c.verifiedSince = time.Time{}
+	log.Infof("Auto cutover: waiting for a mismatch rate of at most %v for %v (%v).",
+		c.conf.MaxMismatchRate, c.conf.StableDuration, c.conf)
+
+	ticker := time.NewTicker(c.conf.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if c.check(ctx) {
+				return
+			}
+		}
+	}
+}
+
+// check evaluates the reads verified since the previous check, it returns true once the reads were switched.
+func (c *autoCutoverController) check(ctx context.Context) bool {
+	if c.isCutOver() {
+		log.Infof("Auto cutover: reads were switched to %v by someone else, stopping.", common.ClusterTypeTarget)
+		return true
+	}
+
+	compared, mismatched := c.verifier.ReadVerificationStats()
+	intervalCompared, intervalMismatched := compared-c.lastCompared, mismatched-c.lastMismatched
+	c.lastCompared, c.lastMismatched = compared, mismatched
+	now := c.now()
+
+	if intervalCompared < uint64(c.conf.MinComparedReads) {
+		if !c.verifiedSince.IsZero() {
+			log.Infof("Auto cutover: only %d reads were compared in the last %v (at least %d are required), "+
+				"restarting the verification period.", intervalCompared, c.conf.CheckInterval, c.conf.MinComparedReads)
+		}
+		c.verifiedSince = time.Time{}
+		return false
+	}
+	mismatchRate := float64(intervalMismatched) / float64(intervalCompared)
+	if mismatchRate > c.conf.MaxMismatchRate {
+		if !c.verifiedSince.IsZero() {
+			log.Infof("Auto cutover: mismatch rate of %v in the last %v is above %v, restarting the verification period.",
+				mismatchRate, c.conf.CheckInterval, c.conf.MaxMismatchRate)
+		}
+		c.verifiedSince = time.Time{}
+		return false
+	}
+
+	if c.verifiedSince.IsZero() {
+		// the reads of the interval that just ended were verified
+		c.verifiedSince = now.Add(-c.conf.CheckInterval)
+		c.verifiedReads, c.verifiedMisses = 0, 0
+		log.Infof("Auto cutover: mismatch rate of %v is at most %v, reads will be switched to %v at %v "+
+			"if it stays below the threshold.", mismatchRate, c.conf.MaxMismatchRate, common.ClusterTypeTarget,
+			c.verifiedSince.Add(c.conf.StableDuration).Format(time.RFC3339))
+	}
+	c.verifiedReads += intervalCompared
+	c.verifiedMisses += intervalMismatched
+	if now.Sub(c.verifiedSince) < c.conf.StableDuration {
+		return false
+	}
+
+	record := &autoCutoverRecord{
+		Time:            now,
+		Instance:        c.instance,
+		VerifiedSince:   c.verifiedSince,
+		ComparedReads:   c.verifiedReads,
+		MismatchedReads: c.verifiedMisses,
+		MaxMismatchRate: c.conf.MaxMismatchRate,
+	}
+	err := c.cutOver(ctx, record)
+	if err != nil {
+		log.Errorf("Auto cutover: could not switch the reads to %v, retrying at the next check: %v",
+			common.ClusterTypeTarget, err)
+		return false
+	}
+	log.Infof("Auto cutover: reads switched to %v after %v with %d compared reads and %d mismatches (instance %v).",
+		common.ClusterTypeTarget, now.Sub(c.verifiedSince), record.ComparedReads, record.MismatchedReads, c.instance)
+	return true
+}
+
+// initializeAutoCutover creates the controller of ZDM_AUTO_CUTOVER_ENABLED, it runs as a fleet task so that a single
+// instance switches the whole fleet when shared configuration is enabled.
+func (p *ZdmProxy) initializeAutoCutover() error {
+	autoCutoverConf, err := p.Conf.ParseAutoCutoverConfig()
+	if err != nil {
+		return err
+	}
+	if autoCutoverConf == nil {
+		return nil
+	}
+	if p.extensions.ReadVerifier == nil {
+		return errors.New("ZDM_AUTO_CUTOVER_ENABLED is true but there is no read verifier (Extensions.ReadVerifier) " +
+			"to compute the mismatch rate")
+	}
+	publisher := p.sharedConfigPublisher
+	isCutOver := func() bool {
+		p.lock.Lock()
+		defer p.lock.Unlock()
+		return p.primaryCluster == common.ClusterTypeTarget
+	}
+	cutOver := func(ctx context.Context, record *autoCutoverRecord) error {
+		if publisher == nil {
+			p.lock.Lock()
+			p.primaryCluster = common.ClusterTypeTarget
+			p.lock.Unlock()
+			return nil
+		}
+		// the record is written first so that the change of the primary cluster is never applied without it
+		value, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		err = publisher.Publish(ctx, sharedconfig.KeyAutoCutover, string(value))
+		if err != nil {
+			return err
+		}
+		return publisher.Publish(ctx, sharedconfig.KeyPrimaryCluster, string(common.ClusterTypeTarget))
+	}
+	p.autoCutover = newAutoCutoverController(autoCutoverConf, p.extensions.ReadVerifier, p.fleetInstanceId(),
+		isCutOver, cutOver)
+	return nil
+}
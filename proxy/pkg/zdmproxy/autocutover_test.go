@@ -0,0 +1,110 @@
+package zdmproxy
+
+import (
+	"context"
+	"errors"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type fakeReadVerifier struct {
+	compared   uint64
+	mismatched uint64
+}
+
+func (v *fakeReadVerifier) ReadVerificationStats() (uint64, uint64) {
+	return v.compared, v.mismatched
+}
+
+func TestAutoCutoverController_Check(t *testing.T) {
+	verifier := &fakeReadVerifier{}
+	now := time.Unix(1000, 0)
+	cutOver := false
+	var cutOverErr error
+	var records []*autoCutoverRecord
+	controller := newAutoCutoverController(&common.AutoCutoverConfig{
+		MaxMismatchRate:  0.01,
+		MinComparedReads: 100,
+		StableDuration:   3 * time.Minute,
+		CheckInterval:    time.Minute,
+	}, verifier, "proxy-1", func() bool {
+		return cutOver
+	}, func(_ context.Context, record *autoCutoverRecord) error {
+		records = append(records, record)
+		if cutOverErr != nil {
+			return cutOverErr
+		}
+		cutOver = true
+		return nil
+	})
+	controller.now = func() time.Time {
+		return now
+	}
+	nextInterval := func(compared uint64, mismatched uint64) bool {
+		now = now.Add(time.Minute)
+		verifier.compared += compared
+		verifier.mismatched += mismatched
+		return controller.check(context.Background())
+	}
+
+	// not enough reads to decide
+	require.False(t, nextInterval(50, 0))
+	require.True(t, controller.verifiedSince.IsZero())
+
+	// the mismatch rate goes above the threshold in the middle of the verification period
+	require.False(t, nextInterval(1000, 5))
+	require.Equal(t, now.Add(-time.Minute), controller.verifiedSince)
+	require.False(t, nextInterval(1000, 20))
+	require.True(t, controller.verifiedSince.IsZero())
+
+	require.False(t, nextInterval(1000, 10))
+	require.False(t, nextInterval(1000, 0))
+	verifiedSince := controller.verifiedSince
+	cutOverErr = errors.New("connection refused")
+	require.False(t, nextInterval(1000, 1))
+	require.Len(t, records, 1)
+	require.False(t, cutOver)
+
+	// the switch is retried at the next check
+	cutOverErr = nil
+	require.True(t, nextInterval(1000, 1))
+	require.True(t, cutOver)
+	require.Len(t, records, 2)
+	require.Equal(t, &autoCutoverRecord{
+		Time:            now,
+		Instance:        "proxy-1",
+		VerifiedSince:   verifiedSince,
+		ComparedReads:   4000,
+		MismatchedReads: 12,
+		MaxMismatchRate: 0.01,
+	}, records[1])
+}
+
+func TestAutoCutoverController_SwitchedByOperator(t *testing.T) {
+	verifier := &fakeReadVerifier{}
+	cutOver := false
+	controller := newAutoCutoverController(&common.AutoCutoverConfig{
+		MaxMismatchRate:  0,
+		MinComparedReads: 1,
+		StableDuration:   time.Hour,
+		CheckInterval:    time.Minute,
+	}, verifier, "proxy-1", func() bool {
+		return cutOver
+	}, func(context.Context, *autoCutoverRecord) error {
+		t.Fatal("the reads were already switched")
+		return nil
+	})
+
+	verifier.compared = 10
+	require.False(t, controller.check(context.Background()))
+	cutOver = true
+	require.True(t, controller.check(context.Background()))
+
+	ctx, cancelFn := context.WithTimeout(context.Background(), time.Second)
+	defer cancelFn()
+	// returns immediately
+	controller.run(ctx)
+	require.Nil(t, ctx.Err())
+}
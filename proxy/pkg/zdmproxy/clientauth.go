@@ -0,0 +1,390 @@
+package zdmproxy
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ClientCredentialStore authenticates the clients of the proxy when proxy-level client authentication is enabled.
+//
+// In that mode the proxy asks every client for credentials and checks them with the store instead of forwarding them
+// to a cluster, then it authenticates with both clusters using the credentials of its own configuration
+// (ZDM_ORIGIN_USERNAME, ZDM_TARGET_USERNAME, etc.). Applications never hold the cluster credentials.
+//
+// Implementations must be safe for concurrent use because they are shared by all client connections.
+type ClientCredentialStore interface {
+	// Authenticate returns false if the credentials are invalid and an error if they could not be checked.
+	Authenticate(username string, password string) (bool, error)
+}
+
+// ClusterCredentialMapper can be implemented by a ClientCredentialStore to choose the credentials that the proxy uses
+// with the clusters based on the identity of the client. It is called after Authenticate accepted the credentials.
+type ClusterCredentialMapper interface {
+	// MapClusterCredentials returns an error if no cluster credentials can be used for the client. A nil Origin or
+	// Target means that the configured credentials of that cluster are used.
+	MapClusterCredentials(username string, password string) (*ClusterCredentials, error)
+}
+
+// ClusterCredentials are the credentials used by the proxy with each cluster on behalf of a client.
+type ClusterCredentials struct {
+	Origin *AuthCredentials `json:"origin"`
+	Target *AuthCredentials `json:"target"`
+}
+
+const (
+	proxyClientAuthenticator     = "org.apache.cassandra.auth.PasswordAuthenticator"
+	sha256PasswordPrefix         = "sha256:"
+	pbkdf2PasswordPrefix         = "pbkdf2-sha256:"
+	minPbkdf2Iterations          = 10000
+	invalidClientCredentialsText = "Provided username %v and/or password are incorrect"
+)
+
+// fileCredentialStore is the ClientCredentialStore of ZDM_PROXY_CLIENT_CREDENTIALS_FILE. Each line of the file has the
+// format username:password where the password is either in plain text, a salted PBKDF2-HMAC-SHA256 hash with the
+// format pbkdf2-sha256:<iterations>:<hex salt>:<hex hash> or a hex encoded SHA-256 hash prefixed by "sha256:". The
+// SHA-256 hashes are not salted so they are only supported for compatibility, a leaked file can be brute forced
+// quickly. Empty lines and lines starting with # are ignored.
+type fileCredentialStore struct {
+	passwords map[string]string
+}
+
+func newFileCredentialStore(path string) (*fileCredentialStore, error) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read client credentials file: %w", err)
+	}
+
+	passwords := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(file))
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		username, password, found := strings.Cut(line, ":")
+		if !found || username == "" {
+			return nil, fmt.Errorf("invalid line %d in client credentials file, expected username:password", lineNumber)
+		}
+		if strings.HasPrefix(password, sha256PasswordPrefix) {
+			hash, err := hex.DecodeString(strings.TrimPrefix(password, sha256PasswordPrefix))
+			if err != nil || len(hash) != sha256.Size {
+				return nil, fmt.Errorf("invalid SHA-256 hash on line %d in client credentials file", lineNumber)
+			}
+		}
+		if strings.HasPrefix(password, pbkdf2PasswordPrefix) {
+			if _, _, _, err := parsePbkdf2Password(password); err != nil {
+				return nil, fmt.Errorf("invalid PBKDF2 hash on line %d in client credentials file: %w", lineNumber, err)
+			}
+		}
+		if _, exists := passwords[username]; exists {
+			return nil, fmt.Errorf("duplicate username %v in client credentials file", username)
+		}
+		passwords[username] = password
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read client credentials file: %w", err)
+	}
+	if len(passwords) == 0 {
+		return nil, errors.New("client credentials file doesn't contain any credentials")
+	}
+	return &fileCredentialStore{passwords: passwords}, nil
+}
+
+func (s *fileCredentialStore) Authenticate(username string, password string) (bool, error) {
+	expectedPassword, ok := s.passwords[username]
+	if !ok {
+		return false, nil
+	}
+	if strings.HasPrefix(expectedPassword, pbkdf2PasswordPrefix) {
+		iterations, salt, expectedHash, _ := parsePbkdf2Password(expectedPassword)
+		hash := pbkdf2Sha256([]byte(password), salt, iterations, len(expectedHash))
+		return subtle.ConstantTimeCompare(expectedHash, hash) == 1, nil
+	}
+	if strings.HasPrefix(expectedPassword, sha256PasswordPrefix) {
+		expectedHash, _ := hex.DecodeString(strings.TrimPrefix(expectedPassword, sha256PasswordPrefix))
+		hash := sha256.Sum256([]byte(password))
+		return subtle.ConstantTimeCompare(expectedHash, hash[:]) == 1, nil
+	}
+	return subtle.ConstantTimeCompare([]byte(expectedPassword), []byte(password)) == 1, nil
+}
+
+// parsePbkdf2Password parses a password with the format pbkdf2-sha256:<iterations>:<hex salt>:<hex hash>, e.g. the
+// output of hashlib.pbkdf2_hmac('sha256', password, salt, iterations) in Python.
+func parsePbkdf2Password(password string) (iterations int, salt []byte, hash []byte, err error) {
+	parts := strings.Split(strings.TrimPrefix(password, pbkdf2PasswordPrefix), ":")
+	if len(parts) != 3 {
+		return 0, nil, nil, errors.New("expected pbkdf2-sha256:<iterations>:<hex salt>:<hex hash>")
+	}
+	iterations, err = strconv.Atoi(parts[0])
+	if err != nil || iterations < minPbkdf2Iterations {
+		return 0, nil, nil, fmt.Errorf("the number of iterations must be at least %d", minPbkdf2Iterations)
+	}
+	salt, err = hex.DecodeString(parts[1])
+	if err != nil || len(salt) < 8 {
+		return 0, nil, nil, errors.New("the salt must be hex encoded and at least 8 bytes long")
+	}
+	hash, err = hex.DecodeString(parts[2])
+	if err != nil || len(hash) < sha256.Size {
+		return 0, nil, nil, fmt.Errorf("the hash must be hex encoded and at least %d bytes long", sha256.Size)
+	}
+	return iterations, salt, hash, nil
+}
+
+// pbkdf2Sha256 is PBKDF2 (RFC 8018) with HMAC-SHA256 as the pseudorandom function.
+func pbkdf2Sha256(password []byte, salt []byte, iterations int, keyLength int) []byte {
+	prf := hmac.New(sha256.New, password)
+	key := make([]byte, 0, keyLength+sha256.Size)
+	block := make([]byte, 4)
+	for blockIndex := uint32(1); len(key) < keyLength; blockIndex++ {
+		block[0], block[1], block[2], block[3] =
+			byte(blockIndex>>24), byte(blockIndex>>16), byte(blockIndex>>8), byte(blockIndex)
+		prf.Reset()
+		prf.Write(salt)
+		prf.Write(block)
+		u := prf.Sum(nil)
+		t := append([]byte(nil), u...)
+		for i := 1; i < iterations; i++ {
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLength]
+}
+
+// requestProxyAuthentication is called when both clusters replied to the STARTUP request of the client. The responses
+// are kept for the cluster handshakes and the client is asked to authenticate with the proxy.
+func (ch *ClientHandler) requestProxyAuthentication(
+	request *frame.RawFrame, primaryStartupResponse *frame.RawFrame, wg *sync.WaitGroup) (bool, error) {
+	ch.primaryStartupResponse = primaryStartupResponse
+	authenticate, err := ch.buildHandshakeResponse(request, &message.Authenticate{Authenticator: proxyClientAuthenticator})
+	if err != nil {
+		return false, fmt.Errorf("could not create AUTHENTICATE response for proxy client authentication: %w", err)
+	}
+	ch.sendHandshakeResponseToClient(authenticate, wg)
+	return false, nil
+}
+
+// handleProxyAuthResponse checks the credentials of the client with the credential store and, if they are valid,
+// completes the handshakes with both clusters before replying with AUTH_SUCCESS.
+func (ch *ClientHandler) handleProxyAuthResponse(request *frame.RawFrame, wg *sync.WaitGroup) (bool, error) {
+	if ch.authErrorMessage != nil {
+		return false, ch.sendProxyAuthErrorToClient(request, ch.authErrorMessage, wg)
+	}
+
+	startupRequestInterface := ch.startupRequest.Load()
+	if startupRequestInterface == nil || ch.primaryStartupResponse == nil {
+		return false, errors.New("can not authenticate a client before a STARTUP request was received")
+	}
+	startupRequest := startupRequestInterface.(*frame.RawFrame)
+
+	parsedAuthFrame, err := defaultCodec.ConvertFromRawFrame(request)
+	if err != nil {
+		return false, fmt.Errorf("could not decode auth response of client: %w", err)
+	}
+	authResponse, ok := parsedAuthFrame.Body.Message.(*message.AuthResponse)
+	if !ok {
+		return false, fmt.Errorf("expected AuthResponse but got %v", parsedAuthFrame.Body.Message)
+	}
+	clientCreds, err := ParseCredentialsFromRequest(authResponse.Token)
+	if err != nil {
+		return false, err
+	}
+	if clientCreds == nil {
+		return false, ch.sendProxyAuthErrorToClient(request, &message.AuthenticationError{
+			ErrorMessage: "Credentials are required by the proxy"}, wg)
+	}
+
+	valid, err := ch.clientCredentialStore.Authenticate(clientCreds.Username, clientCreds.Password)
+	if err != nil {
+		log.Errorf("Could not check the credentials of client %v: %v",
+			ch.clientConnector.connection.RemoteAddr().String(), err)
+		return false, ch.sendProxyAuthErrorToClient(request, &message.AuthenticationError{
+			ErrorMessage: "Could not check the provided credentials"}, wg)
+	}
+	if !valid {
+		log.Warnf("Client %v provided invalid credentials for username %v.",
+			ch.clientConnector.connection.RemoteAddr().String(), clientCreds.Username)
+		return false, ch.sendProxyAuthErrorToClient(request, &message.AuthenticationError{
+			ErrorMessage: fmt.Sprintf(invalidClientCredentialsText, clientCreds.Username)}, wg)
+	}
+	clusterCredentials := &ClusterCredentials{}
+	if mapper, ok := ch.clientCredentialStore.(ClusterCredentialMapper); ok {
+		clusterCredentials, err = mapper.MapClusterCredentials(clientCreds.Username, clientCreds.Password)
+		if err != nil {
+			log.Warnf("Could not find cluster credentials for client %v (username %v): %v",
+				ch.clientConnector.connection.RemoteAddr().String(), clientCreds.Username, err)
+			return false, ch.sendProxyAuthErrorToClient(request, &message.AuthenticationError{
+				ErrorMessage: fmt.Sprintf(invalidClientCredentialsText, clientCreds.Username)}, wg)
+		}
+	}
+	log.Debugf("Client %v authenticated with the proxy as %v.",
+		ch.clientConnector.connection.RemoteAddr().String(), clientCreds.Username)
+
+	err = ch.performProxyAuthClusterHandshakes(startupRequest, clusterCredentials)
+	if err != nil {
+		var authError *AuthError
+		if errors.As(err, &authError) {
+			ch.authErrorMessage = authError.errMsg
+			return false, ch.sendProxyAuthErrorToClient(request, ch.authErrorMessage, wg)
+		}
+		log.Errorf("Handshake with the clusters failed (client: %v), shutting down the client handler and connectors: %v",
+			ch.clientConnector.connection.RemoteAddr().String(), err)
+		ch.clientHandlerCancelFunc()
+		return false, fmt.Errorf("handshake failed: %w", ShutdownErr)
+	}
+
+	authSuccess, err := ch.buildHandshakeResponse(request, &message.AuthSuccess{})
+	if err != nil {
+		return false, fmt.Errorf("could not create AUTH_SUCCESS response for proxy client authentication: %w", err)
+	}
+	ch.sendHandshakeResponseToClient(authSuccess, wg)
+	return true, nil
+}
+
+// performProxyAuthClusterHandshakes authenticates with the primary cluster and then with the secondary cluster, one
+// at a time because both handshakes use the stream id of the client request. The async connector handshake is
+// started afterwards like it is when the credentials of the client are forwarded.
+func (ch *ClientHandler) performProxyAuthClusterHandshakes(
+	startupRequest *frame.RawFrame, clusterCredentials *ClusterCredentials) error {
+	originCredentials := &AuthCredentials{Username: ch.originUsername, Password: ch.originPassword}
+	if clusterCredentials.Origin != nil {
+		originCredentials = clusterCredentials.Origin
+	}
+	targetCredentials := &AuthCredentials{Username: ch.targetUsername, Password: ch.targetPassword}
+	if clusterCredentials.Target != nil {
+		targetCredentials = clusterCredentials.Target
+	}
+
+	type clusterHandshake struct {
+		clusterType     common.ClusterType
+		connector       *ClusterConnector
+		startupResponse *frame.RawFrame
+		forwardDecision forwardDecision
+		credentials     *AuthCredentials
+	}
+	originHandshake := clusterHandshake{common.ClusterTypeOrigin, ch.originCassandraConnector,
+		ch.primaryStartupResponse, forwardToOrigin, originCredentials}
+	targetHandshake := clusterHandshake{common.ClusterTypeTarget, ch.targetCassandraConnector,
+		ch.secondaryStartupResponse, forwardToTarget, targetCredentials}
+	handshakes := []clusterHandshake{originHandshake, targetHandshake}
+	if ch.forwardAuthToTarget {
+		// primary is TARGET
+		targetHandshake.startupResponse, originHandshake.startupResponse = ch.primaryStartupResponse, ch.secondaryStartupResponse
+		handshakes = []clusterHandshake{targetHandshake, originHandshake}
+	}
+
+	requestTimeout := time.Duration(ch.conf.ProxyRequestTimeoutMs) * time.Millisecond
+	for _, handshake := range handshakes {
+		err := ch.runClusterHandshake(func() error {
+			return ch.performClusterHandshake(
+				startupRequest, handshake.startupResponse,
+				newClusterAuthenticator(ch.conf, handshake.clusterType, handshake.credentials),
+				handshake.forwardDecision, false, handshake.connector.connection.RemoteAddr(),
+				string(handshake.clusterType), requestTimeout)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if ch.asyncConnector == nil {
+		return nil
+	}
+	ch.asyncHandshakeCreds = originCredentials
+	if ch.asyncConnector.clusterType == common.ClusterTypeTarget {
+		ch.asyncHandshakeCreds = targetCredentials
+	}
+	err := ch.runClusterHandshake(func() error {
+		return ch.handleSecondaryHandshakeStartup(startupRequest, nil, true)
+	})
+	if err != nil {
+		log.Errorf("Async connector (%v) handshake failed, async requests will not be forwarded: %v",
+			ch.asyncConnector.clusterType, err)
+		ch.asyncConnector.Shutdown()
+	}
+	return nil
+}
+
+// runClusterHandshake runs the handshake in the background like startSecondaryHandshake does and waits for it.
+func (ch *ClientHandler) runClusterHandshake(handshake func() error) error {
+	channel := make(chan error, 1)
+	ch.clientHandlerRequestWaitGroup.Add(1)
+	go func() {
+		defer ch.clientHandlerRequestWaitGroup.Done()
+		channel <- handshake()
+	}()
+
+	select {
+	case err := <-channel:
+		return err
+	case <-ch.clientHandlerContext.Done():
+		return ShutdownErr
+	}
+}
+
+// rejectUnauthenticatedRequest replies with a protocol error to a request that the client sent before authenticating
+// with the proxy, the request is not forwarded to the clusters.
+func (ch *ClientHandler) rejectUnauthenticatedRequest(request *frame.RawFrame, wg *sync.WaitGroup) error {
+	log.Warnf("Client %v sent a %v request before authenticating with the proxy, rejecting it.",
+		ch.clientConnector.connection.RemoteAddr().String(), request.Header.OpCode)
+	response, err := ch.buildHandshakeResponse(request, &message.ProtocolError{
+		ErrorMessage: fmt.Sprintf("%v requests are not allowed before the client authenticated with the proxy",
+			request.Header.OpCode)})
+	if err != nil {
+		return fmt.Errorf("could not create protocol error response for unauthenticated request: %w", err)
+	}
+	ch.sendHandshakeResponseToClient(response, wg)
+	return nil
+}
+
+func (ch *ClientHandler) sendProxyAuthErrorToClient(
+	request *frame.RawFrame, authError *message.AuthenticationError, wg *sync.WaitGroup) error {
+	response, err := ch.buildAuthErrorResponse(request, authError)
+	if err != nil {
+		return fmt.Errorf("proxy client authentication failed but could not create response frame: %w", err)
+	}
+	ch.sendHandshakeResponseToClient(response, wg)
+	return nil
+}
+
+// sendHandshakeResponseToClient sends the response on the request-response scheduler like the other handshake
+// responses and waits until it was queued.
+func (ch *ClientHandler) sendHandshakeResponseToClient(response *frame.RawFrame, wg *sync.WaitGroup) {
+	done := make(chan struct{})
+	wg.Add(1)
+	ch.requestResponseScheduler.SchedulePriority(func() {
+		defer wg.Done()
+		defer close(done)
+		ch.clientConnector.sendResponseToClient(response)
+	})
+	<-done
+}
+
+// isProxyAuthStartupResponse returns true if the STARTUP response of the cluster allows the proxy to continue the
+// handshake on its own, other responses (e.g. protocol errors) are returned to the client.
+func isProxyAuthStartupResponse(response *frame.RawFrame) bool {
+	return response.Header.OpCode == primitive.OpCodeReady || response.Header.OpCode == primitive.OpCodeAuthenticate
+}
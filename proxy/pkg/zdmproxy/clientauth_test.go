@@ -0,0 +1,132 @@
+package zdmproxy
+
+import (
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileCredentialStore(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		errMsg   string
+		username string
+		password string
+		valid    bool
+	}{
+		{
+			name:     "plain text password",
+			file:     "# comment\n\napp:appPassword\nother:otherPassword\n",
+			username: "app",
+			password: "appPassword",
+			valid:    true,
+		},
+		{
+			name:     "plain text password with colon",
+			file:     "app:app:Password",
+			username: "app",
+			password: "app:Password",
+			valid:    true,
+		},
+		{
+			name:     "wrong password",
+			file:     "app:appPassword",
+			username: "app",
+			password: "otherPassword",
+			valid:    false,
+		},
+		{
+			name:     "unknown username",
+			file:     "app:appPassword",
+			username: "other",
+			password: "appPassword",
+			valid:    false,
+		},
+		{
+			name:     "sha256 password",
+			file:     "app:sha256:e2794d1158af4b21fbdd0e742cfb74fa5672b4f0c82f18547c3f5da43d816be8",
+			username: "app",
+			password: "hashedPassword",
+			valid:    true,
+		},
+		{
+			name:     "sha256 password used as plain text",
+			file:     "app:sha256:e2794d1158af4b21fbdd0e742cfb74fa5672b4f0c82f18547c3f5da43d816be8",
+			username: "app",
+			password: "sha256:e2794d1158af4b21fbdd0e742cfb74fa5672b4f0c82f18547c3f5da43d816be8",
+			valid:    false,
+		},
+		{
+			name:     "pbkdf2 password",
+			file:     "app:pbkdf2-sha256:10000:73616c7473616c74:0ec38a892d50985dbb44488619820a68919ad3a12297cf746ca613ac8c528387",
+			username: "app",
+			password: "hashedPassword",
+			valid:    true,
+		},
+		{
+			name:     "wrong pbkdf2 password",
+			file:     "app:pbkdf2-sha256:10000:73616c7473616c74:0ec38a892d50985dbb44488619820a68919ad3a12297cf746ca613ac8c528387",
+			username: "app",
+			password: "appPassword",
+			valid:    false,
+		},
+		{
+			name: "pbkdf2 hash with too few iterations",
+			file: "app:pbkdf2-sha256:1000:73616c7473616c74:0ec38a892d50985dbb44488619820a68919ad3a12297cf746ca613ac8c528387",
+			errMsg: "invalid PBKDF2 hash on line 1 in client credentials file: " +
+				"the number of iterations must be at least 10000",
+		},
+		{
+			name: "pbkdf2 hash without salt",
+			file: "app:pbkdf2-sha256:10000:0ec38a892d50985dbb44488619820a68919ad3a12297cf746ca613ac8c528387",
+			errMsg: "invalid PBKDF2 hash on line 1 in client credentials file: " +
+				"expected pbkdf2-sha256:<iterations>:<hex salt>:<hex hash>",
+		},
+		{
+			name:   "invalid sha256 hash",
+			file:   "app:sha256:abc",
+			errMsg: "invalid SHA-256 hash on line 1 in client credentials file",
+		},
+		{
+			name:   "missing password",
+			file:   "# comment\napp",
+			errMsg: "invalid line 2 in client credentials file, expected username:password",
+		},
+		{
+			name:   "missing username",
+			file:   ":appPassword",
+			errMsg: "invalid line 1 in client credentials file, expected username:password",
+		},
+		{
+			name:   "duplicate username",
+			file:   "app:appPassword\napp:otherPassword",
+			errMsg: "duplicate username app in client credentials file",
+		},
+		{
+			name:   "no credentials",
+			file:   "# comment\n",
+			errMsg: "client credentials file doesn't contain any credentials",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "credentials")
+			require.Nil(t, os.WriteFile(path, []byte(tt.file), 0600))
+
+			store, err := newFileCredentialStore(path)
+			if tt.errMsg != "" {
+				require.NotNil(t, err)
+				require.Equal(t, tt.errMsg, err.Error())
+				return
+			}
+			require.Nil(t, err)
+
+			valid, err := store.Authenticate(tt.username, tt.password)
+			require.Nil(t, err)
+			require.Equal(t, tt.valid, valid)
+		})
+	}
+}
@@ -0,0 +1,428 @@
+package zdmproxy
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	jwtClockSkewLeeway     = 30 * time.Second
+	jwksMinRefreshInterval = time.Minute
+	jwksRequestTimeout     = 10 * time.Second
+)
+
+// jwtCredentialStore is the ClientCredentialStore of ZDM_PROXY_CLIENT_JWT_JWKS_URL. Clients present a JWT as the
+// password of the SASL PLAIN exchange (the username is only used in log messages), the token is accepted if it is
+// signed by one of the keys of the JWKS, was issued by ZDM_PROXY_CLIENT_JWT_ISSUER for ZDM_PROXY_CLIENT_JWT_AUDIENCE
+// (if set) and is not expired.
+//
+// When ZDM_PROXY_CLIENT_JWT_CREDENTIALS_CLAIM is set, the value of that claim (a string or an array of strings) selects
+// the cluster credentials of ZDM_PROXY_CLIENT_JWT_CREDENTIALS_FILE, a JSON object like
+// {"reader": {"origin": {"username": "...", "password": "..."}, "target": {...}}}.
+type jwtCredentialStore struct {
+	jwksUrl            string
+	issuer             string
+	audience           string
+	credentialsClaim   string
+	clusterCredentials map[string]*ClusterCredentials
+	httpClient         *http.Client
+
+	keysLock      *sync.RWMutex
+	keys          []*jsonWebKey
+	keysFetchedAt time.Time
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+
+	publicKey crypto.PublicKey
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+func newJwtCredentialStore(conf *config.Config) (*jwtCredentialStore, error) {
+	store := &jwtCredentialStore{
+		jwksUrl:          conf.ProxyClientJwtJwksUrl,
+		issuer:           conf.ProxyClientJwtIssuer,
+		audience:         conf.ProxyClientJwtAudience,
+		credentialsClaim: conf.ProxyClientJwtCredentialsClaim,
+		httpClient:       &http.Client{Timeout: jwksRequestTimeout},
+		keysLock:         &sync.RWMutex{},
+	}
+	if conf.ProxyClientJwtCredentialsFile != "" {
+		file, err := os.ReadFile(conf.ProxyClientJwtCredentialsFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read JWT credentials file: %w", err)
+		}
+		err = json.Unmarshal(file, &store.clusterCredentials)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse JWT credentials file: %w", err)
+		}
+		if len(store.clusterCredentials) == 0 {
+			return nil, errors.New("JWT credentials file doesn't contain any credentials")
+		}
+	}
+	if err := store.refreshKeys(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+func (s *jwtCredentialStore) Authenticate(username string, password string) (bool, error) {
+	_, err := s.verifyToken(password)
+	if err != nil {
+		var keysErr *jwksError
+		if errors.As(err, &keysErr) {
+			return false, err
+		}
+		log.Debugf("Invalid JWT provided by %v: %v", username, err)
+		return false, nil
+	}
+	return true, nil
+}
+
+func (s *jwtCredentialStore) MapClusterCredentials(username string, password string) (*ClusterCredentials, error) {
+	if s.credentialsClaim == "" {
+		return &ClusterCredentials{}, nil
+	}
+	claims, err := s.verifyToken(password)
+	if err != nil {
+		return nil, err
+	}
+
+	var values []interface{}
+	switch claim := claims[s.credentialsClaim].(type) {
+	case string:
+		values = []interface{}{claim}
+	case []interface{}:
+		values = claim
+	}
+	for _, value := range values {
+		if valueStr, ok := value.(string); ok {
+			if clusterCredentials, ok := s.clusterCredentials[valueStr]; ok {
+				return clusterCredentials, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no cluster credentials are mapped to the %v claim of the token", s.credentialsClaim)
+}
+
+// jwksError is returned when the keys could not be fetched, unlike the other verification errors it doesn't mean that
+// the token is invalid.
+type jwksError struct {
+	url string
+	err error
+}
+
+func (e *jwksError) Error() string {
+	return fmt.Sprintf("could not load JWKS from %v: %v", e.url, e.err)
+}
+
+func (e *jwksError) Unwrap() error {
+	return e.err
+}
+
+// verifyToken checks the signature and the registered claims of the token and returns its claims.
+func (s *jwtCredentialStore) verifyToken(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("token is not a JWS compact serialization")
+	}
+
+	var header jwtHeader
+	if err := decodeJwtPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid header: %w", err)
+	}
+	hash, err := jwtAlgorithmHash(header.Alg)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	hasher := hash.New()
+	hasher.Write([]byte(parts[0] + "." + parts[1]))
+	digest := hasher.Sum(nil)
+
+	keys, err := s.lookupKeys(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	verified := false
+	for _, key := range keys {
+		if verifyJwtSignature(header.Alg, key.publicKey, hash, digest, signature) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, errors.New("signature doesn't match any key of the JWKS")
+	}
+
+	var claims map[string]interface{}
+	if err = decodeJwtPart(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("invalid claims: %w", err)
+	}
+	if err = s.verifyClaims(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func (s *jwtCredentialStore) verifyClaims(claims map[string]interface{}) error {
+	now := time.Now()
+	if issuer, _ := claims["iss"].(string); issuer != s.issuer {
+		return fmt.Errorf("unexpected issuer %v", claims["iss"])
+	}
+	expiration, ok := claims["exp"].(float64)
+	if !ok {
+		return errors.New("token doesn't have an expiration time")
+	}
+	if now.After(time.Unix(int64(expiration), 0).Add(jwtClockSkewLeeway)) {
+		return errors.New("token is expired")
+	}
+	if notBefore, ok := claims["nbf"].(float64); ok && now.Add(jwtClockSkewLeeway).Before(time.Unix(int64(notBefore), 0)) {
+		return errors.New("token is not valid yet")
+	}
+	if s.audience == "" {
+		return nil
+	}
+	switch audience := claims["aud"].(type) {
+	case string:
+		if audience == s.audience {
+			return nil
+		}
+	case []interface{}:
+		for _, value := range audience {
+			if value == s.audience {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("token audience %v doesn't contain %v", claims["aud"], s.audience)
+}
+
+// lookupKeys returns the keys with the given key id (or all keys if the token doesn't have one). An unknown key id
+// triggers a refresh of the JWKS so that rotated keys are picked up, at most once per jwksMinRefreshInterval.
+func (s *jwtCredentialStore) lookupKeys(kid string) ([]*jsonWebKey, error) {
+	keys, fetchedAt := s.findKeys(kid)
+	if len(keys) == 0 && time.Since(fetchedAt) >= jwksMinRefreshInterval {
+		if err := s.refreshKeys(); err != nil {
+			return nil, err
+		}
+		keys, _ = s.findKeys(kid)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no key with id %v in the JWKS", kid)
+	}
+	return keys, nil
+}
+
+func (s *jwtCredentialStore) findKeys(kid string) ([]*jsonWebKey, time.Time) {
+	s.keysLock.RLock()
+	defer s.keysLock.RUnlock()
+	if kid == "" {
+		return s.keys, s.keysFetchedAt
+	}
+	for _, key := range s.keys {
+		if key.Kid == kid {
+			return []*jsonWebKey{key}, s.keysFetchedAt
+		}
+	}
+	return nil, s.keysFetchedAt
+}
+
+func (s *jwtCredentialStore) refreshKeys() error {
+	s.keysLock.Lock()
+	defer s.keysLock.Unlock()
+	if !s.keysFetchedAt.IsZero() && time.Since(s.keysFetchedAt) < jwksMinRefreshInterval {
+		// another client refreshed the keys in the meantime
+		return nil
+	}
+	s.keysFetchedAt = time.Now()
+
+	jwks, err := s.readJwks()
+	if err != nil {
+		return &jwksError{url: s.jwksUrl, err: err}
+	}
+	keys, err := parseJwks(jwks)
+	if err != nil {
+		return &jwksError{url: s.jwksUrl, err: err}
+	}
+	s.keys = keys
+	log.Debugf("Loaded %d keys from JWKS %v.", len(keys), s.jwksUrl)
+	return nil
+}
+
+// readJwks fetches the JWKS from an http(s) URL or reads it from the file system.
+func (s *jwtCredentialStore) readJwks() ([]byte, error) {
+	if !strings.HasPrefix(s.jwksUrl, "http://") && !strings.HasPrefix(s.jwksUrl, "https://") {
+		return os.ReadFile(strings.TrimPrefix(s.jwksUrl, "file://"))
+	}
+	response, err := s.httpClient.Get(s.jwksUrl)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %v", response.StatusCode)
+	}
+	return io.ReadAll(response.Body)
+}
+
+func parseJwks(jwks []byte) ([]*jsonWebKey, error) {
+	var keySet struct {
+		Keys []*jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(jwks, &keySet); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+	var keys []*jsonWebKey
+	for _, key := range keySet.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		publicKey, err := parseJsonWebKey(key)
+		if err != nil {
+			log.Warnf("Ignoring key %v of the JWKS: %v", key.Kid, err)
+			continue
+		}
+		key.publicKey = publicKey
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS doesn't contain any supported signing key")
+	}
+	return keys, nil
+}
+
+func parseJsonWebKey(key *jsonWebKey) (crypto.PublicKey, error) {
+	switch key.Kty {
+	case "RSA":
+		n, err := decodeJwkInt(key.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid modulus: %w", err)
+		}
+		e, err := decodeJwkInt(key.E)
+		if err != nil || !e.IsInt64() {
+			return nil, fmt.Errorf("invalid exponent: %v", key.E)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch key.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %v", key.Crv)
+		}
+		x, err := decodeJwkInt(key.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x coordinate: %w", err)
+		}
+		y, err := decodeJwkInt(key.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y coordinate: %w", err)
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point is not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %v", key.Kty)
+	}
+}
+
+func decodeJwkInt(value string) (*big.Int, error) {
+	bytes, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(bytes), nil
+}
+
+func decodeJwtPart(part string, value interface{}) error {
+	decoded, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(decoded, value)
+}
+
+// jwtAlgorithmHash only accepts the asymmetric algorithms, "none" and the HMAC algorithms are rejected because the
+// proxy only holds public keys.
+func jwtAlgorithmHash(alg string) (crypto.Hash, error) {
+	switch alg {
+	case "RS256", "ES256":
+		return crypto.SHA256, nil
+	case "RS384", "ES384":
+		return crypto.SHA384, nil
+	case "RS512", "ES512":
+		return crypto.SHA512, nil
+	default:
+		return 0, fmt.Errorf("unsupported algorithm %v", alg)
+	}
+}
+
+// curves of the ECDSA algorithms, a key of another curve must not be used with the algorithm (RFC 7518 section 3.4)
+var jwtAlgorithmCurves = map[string]string{
+	"ES256": "P-256",
+	"ES384": "P-384",
+	"ES512": "P-521",
+}
+
+func verifyJwtSignature(alg string, publicKey crypto.PublicKey, hash crypto.Hash, digest []byte, signature []byte) bool {
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return strings.HasPrefix(alg, "RS") && rsa.VerifyPKCS1v15(key, hash, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		if jwtAlgorithmCurves[alg] != key.Curve.Params().Name {
+			return false
+		}
+		// the signature is the concatenation of R and S, each padded to the size of the curve
+		keySize := (key.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*keySize {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:keySize])
+		sig := new(big.Int).SetBytes(signature[keySize:])
+		return ecdsa.Verify(key, digest, r, sig)
+	default:
+		return false
+	}
+}
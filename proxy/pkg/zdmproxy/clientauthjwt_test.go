@@ -0,0 +1,285 @@
+package zdmproxy
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestJwtCredentialStore(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+
+	jwksServer := newTestJwksServer(t, testJsonWebKey("rsa", rsaKey.Public()), testJsonWebKey("ec", ecKey.Public()))
+	defer jwksServer.Close()
+
+	credentialsPath := filepath.Join(t.TempDir(), "credentials.json")
+	require.Nil(t, os.WriteFile(credentialsPath, []byte(`{
+		"reader": {"origin": {"username": "originReader", "password": "originPassword"}},
+		"writer": {"origin": {"username": "originWriter", "password": "originPassword"},
+		           "target": {"username": "targetWriter", "password": "targetPassword"}}
+	}`), 0600))
+
+	store, err := newJwtCredentialStore(&config.Config{
+		ProxyClientJwtJwksUrl:          jwksServer.URL,
+		ProxyClientJwtIssuer:           "https://issuer.example.com",
+		ProxyClientJwtAudience:         "zdm-proxy",
+		ProxyClientJwtCredentialsClaim: "roles",
+		ProxyClientJwtCredentialsFile:  credentialsPath,
+	})
+	require.Nil(t, err)
+
+	validClaims := func(overrides map[string]interface{}) map[string]interface{} {
+		claims := map[string]interface{}{
+			"iss":   "https://issuer.example.com",
+			"aud":   []string{"other", "zdm-proxy"},
+			"sub":   "app",
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"roles": []string{"unknown", "writer"},
+		}
+		for name, value := range overrides {
+			if value == nil {
+				delete(claims, name)
+			} else {
+				claims[name] = value
+			}
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name                string
+		token               string
+		valid               bool
+		expectedCredentials *ClusterCredentials
+	}{
+		{
+			name:  "RS256",
+			token: signTestJwt(t, "RS256", "rsa", rsaKey, validClaims(nil)),
+			valid: true,
+			expectedCredentials: &ClusterCredentials{
+				Origin: &AuthCredentials{Username: "originWriter", Password: "originPassword"},
+				Target: &AuthCredentials{Username: "targetWriter", Password: "targetPassword"},
+			},
+		},
+		{
+			name:  "ES256 without key id",
+			token: signTestJwt(t, "ES256", "", ecKey, validClaims(map[string]interface{}{"roles": "reader"})),
+			valid: true,
+			expectedCredentials: &ClusterCredentials{
+				Origin: &AuthCredentials{Username: "originReader", Password: "originPassword"},
+			},
+		},
+		{
+			name:  "ES384 with a P-256 key",
+			token: signTestJwt(t, "ES384", "", ecKey, validClaims(map[string]interface{}{"roles": "reader"})),
+		},
+		{
+			name: "ES256 with a padded signature",
+			token: padTestJwtSignature(t,
+				signTestJwt(t, "ES256", "", ecKey, validClaims(map[string]interface{}{"roles": "reader"}))),
+		},
+		{
+			name:  "no mapped role",
+			token: signTestJwt(t, "RS256", "rsa", rsaKey, validClaims(map[string]interface{}{"roles": nil})),
+			valid: true,
+		},
+		{
+			name:  "expired",
+			token: signTestJwt(t, "RS256", "rsa", rsaKey, validClaims(map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()})),
+		},
+		{
+			name:  "without expiration",
+			token: signTestJwt(t, "RS256", "rsa", rsaKey, validClaims(map[string]interface{}{"exp": nil})),
+		},
+		{
+			name:  "not valid yet",
+			token: signTestJwt(t, "RS256", "rsa", rsaKey, validClaims(map[string]interface{}{"nbf": time.Now().Add(time.Hour).Unix()})),
+		},
+		{
+			name:  "wrong issuer",
+			token: signTestJwt(t, "RS256", "rsa", rsaKey, validClaims(map[string]interface{}{"iss": "https://other.example.com"})),
+		},
+		{
+			name:  "wrong audience",
+			token: signTestJwt(t, "RS256", "rsa", rsaKey, validClaims(map[string]interface{}{"aud": "other"})),
+		},
+		{
+			name:  "signed by another key",
+			token: signTestJwt(t, "RS256", "rsa", otherKey, validClaims(nil)),
+		},
+		{
+			name:  "algorithm of another key type",
+			token: signTestJwt(t, "RS256", "ec", rsaKey, validClaims(nil)),
+		},
+		{
+			name:  "unsigned",
+			token: signTestJwt(t, "none", "rsa", nil, validClaims(nil)),
+		},
+		{
+			name:  "not a jwt",
+			token: "password",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			valid, err := store.Authenticate("app", tt.token)
+			require.Nil(t, err)
+			require.Equal(t, tt.valid, valid)
+			if !tt.valid {
+				return
+			}
+
+			clusterCredentials, err := store.MapClusterCredentials("app", tt.token)
+			if tt.expectedCredentials == nil {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedCredentials, clusterCredentials)
+		})
+	}
+}
+
+func TestJwtCredentialStore_KeyRotation(t *testing.T) {
+	oldKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err)
+
+	jwksServer := newTestJwksServer(t, testJsonWebKey("old", oldKey.Public()))
+	defer jwksServer.Close()
+
+	store, err := newJwtCredentialStore(&config.Config{
+		ProxyClientJwtJwksUrl: jwksServer.URL,
+		ProxyClientJwtIssuer:  "https://issuer.example.com",
+	})
+	require.Nil(t, err)
+
+	token := signTestJwt(t, "RS256", "new", newKey, map[string]interface{}{
+		"iss": "https://issuer.example.com",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	})
+	jwksServer.setKeys(testJsonWebKey("old", oldKey.Public()), testJsonWebKey("new", newKey.Public()))
+
+	// the keys were fetched recently so the JWKS is not refreshed yet
+	valid, err := store.Authenticate("app", token)
+	require.Nil(t, err)
+	require.False(t, valid)
+	require.Equal(t, 1, jwksServer.getRequests())
+
+	store.keysFetchedAt = store.keysFetchedAt.Add(-jwksMinRefreshInterval)
+	valid, err = store.Authenticate("app", token)
+	require.Nil(t, err)
+	require.True(t, valid)
+	require.Equal(t, 2, jwksServer.getRequests())
+}
+
+type testJwksServer struct {
+	*httptest.Server
+	lock     *sync.Mutex
+	keys     []map[string]string
+	requests int
+}
+
+func newTestJwksServer(t *testing.T, keys ...map[string]string) *testJwksServer {
+	server := &testJwksServer{lock: &sync.Mutex{}, keys: keys}
+	server.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		server.lock.Lock()
+		defer server.lock.Unlock()
+		server.requests++
+		require.Nil(t, json.NewEncoder(w).Encode(map[string]interface{}{"keys": server.keys}))
+	}))
+	return server
+}
+
+func (s *testJwksServer) setKeys(keys ...map[string]string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.keys = keys
+}
+
+func (s *testJwksServer) getRequests() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.requests
+}
+
+func testJsonWebKey(kid string, publicKey crypto.PublicKey) map[string]string {
+	encode := func(value *big.Int) string {
+		return base64.RawURLEncoding.EncodeToString(value.Bytes())
+	}
+	switch key := publicKey.(type) {
+	case *rsa.PublicKey:
+		return map[string]string{"kty": "RSA", "kid": kid, "use": "sig",
+			"n": encode(key.N), "e": encode(big.NewInt(int64(key.E)))}
+	case *ecdsa.PublicKey:
+		return map[string]string{"kty": "EC", "kid": kid, "crv": key.Curve.Params().Name,
+			"x": encode(key.X), "y": encode(key.Y)}
+	}
+	return nil
+}
+
+func signTestJwt(t *testing.T, alg string, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	encodedHeader, err := json.Marshal(header)
+	require.Nil(t, err)
+	encodedClaims, err := json.Marshal(claims)
+	require.Nil(t, err)
+	signingInput := base64.RawURLEncoding.EncodeToString(encodedHeader) + "." +
+		base64.RawURLEncoding.EncodeToString(encodedClaims)
+
+	hash, err := jwtAlgorithmHash(alg)
+	if err != nil {
+		// e.g. "none", signed like HS256 tokens
+		hash = crypto.SHA256
+	}
+	hasher := hash.New()
+	hasher.Write([]byte(signingInput))
+	digest := hasher.Sum(nil)
+	var signature []byte
+	switch signer := key.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, signer, hash, digest)
+		require.Nil(t, err)
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, signer, digest)
+		require.Nil(t, err)
+		keySize := (signer.Curve.Params().BitSize + 7) / 8
+		signature = make([]byte, 2*keySize)
+		r.FillBytes(signature[:keySize])
+		s.FillBytes(signature[keySize:])
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// padTestJwtSignature adds a leading zero byte to the signature, which doesn't change the values of R and S
+func padTestJwtSignature(t *testing.T, token string) string {
+	separator := strings.LastIndex(token, ".")
+	signature, err := base64.RawURLEncoding.DecodeString(token[separator+1:])
+	require.Nil(t, err)
+	return token[:separator+1] + base64.RawURLEncoding.EncodeToString(append([]byte{0}, signature...))
+}
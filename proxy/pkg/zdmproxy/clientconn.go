@@ -8,6 +8,7 @@ import (
 	"github.com/datastax/go-cassandra-native-protocol/message"
 	"github.com/datastax/go-cassandra-native-protocol/primitive"
 	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"github.com/datastax/zdm-proxy/proxy/pkg/errorreporting"
 	log "github.com/sirupsen/logrus"
 	"net"
 	"sync"
@@ -56,6 +57,17 @@ type ClientConnector struct {
 	readScheduler *Scheduler
 
 	shutdownRequestCtx context.Context
+
+	frameCapture *frameCapture
+	frameExport  *frameExportStream
+
+	// the requests with a higher protocol version get a PROTOCOL_ERROR, 0 if ZDM_MAX_CLIENT_PROTOCOL_VERSION is not set
+	maxProtocolVersion primitive.ProtocolVersion
+
+	betaProtocolSupport *betaProtocolSupport
+
+	// counts the goroutines of this connector, see goroutineaudit.go
+	goroutines *handlerGoroutines
 }
 
 func NewClientConnector(
@@ -71,7 +83,12 @@ func NewClientConnector(
 	readScheduler *Scheduler,
 	writeScheduler *Scheduler,
 	shutdownRequestCtx context.Context,
-	clientHandlerShutdownRequestCancelFn context.CancelFunc) *ClientConnector {
+	clientHandlerShutdownRequestCancelFn context.CancelFunc,
+	frameCapture *frameCapture,
+	frameExport *frameExportStream,
+	maxProtocolVersion primitive.ProtocolVersion,
+	betaProtocolSupport *betaProtocolSupport,
+	goroutines *handlerGoroutines) *ClientConnector {
 
 	return &ClientConnector{
 		connection:              connection,
@@ -89,7 +106,8 @@ func NewClientConnector(
 			ClientConnectorLogPrefix,
 			false,
 			false,
-			writeScheduler),
+			writeScheduler,
+			goroutines),
 		responsesDoneChan:                    responsesDoneChan,
 		requestsDoneCtx:                      requestsDoneCtx,
 		eventsDoneChan:                       eventsDoneChan,
@@ -97,6 +115,11 @@ func NewClientConnector(
 		readScheduler:                        readScheduler,
 		shutdownRequestCtx:                   shutdownRequestCtx,
 		clientHandlerShutdownRequestCancelFn: clientHandlerShutdownRequestCancelFn,
+		frameCapture:                         frameCapture,
+		frameExport:                          frameExport,
+		maxProtocolVersion:                   maxProtocolVersion,
+		betaProtocolSupport:                  betaProtocolSupport,
+		goroutines:                           goroutines,
 	}
 }
 
@@ -107,7 +130,7 @@ func (cc *ClientConnector) run(activeClients *int32) {
 	cc.listenForRequests()
 	cc.writeCoalescer.RunWriteQueueLoop()
 	cc.clientHandlerWg.Add(1)
-	go func() {
+	cc.goroutines.start(func() {
 		defer cc.clientHandlerWg.Done()
 		<-cc.responsesDoneChan
 		<-cc.requestsDoneCtx.Done()
@@ -117,6 +140,9 @@ func (cc *ClientConnector) run(activeClients *int32) {
 			"to be terminated.", ClientConnectorLogPrefix, cc.connection.RemoteAddr())
 		cc.clientHandlerCancelFunc()
 
+		// the errors sent for the requests that were in flight when a cluster connection was lost must reach the client
+		cc.writeCoalescer.Flush(clientConnectionFlushTimeout)
+
 		log.Infof("[%s] Shutting down client connection to %v", ClientConnectorLogPrefix, cc.connection.RemoteAddr())
 		err := cc.connection.Close()
 		if err != nil {
@@ -127,9 +153,11 @@ func (cc *ClientConnector) run(activeClients *int32) {
 		<-cc.clientConnectorRequestsDoneChan
 		log.Debugf("[%s] Shutting down write coalescer.", ClientConnectorLogPrefix)
 		cc.writeCoalescer.Close()
+		cc.frameCapture.close()
+		cc.frameExport.close()
 
 		atomic.AddInt32(activeClients, -1)
-	}()
+	})
 }
 
 func (cc *ClientConnector) listenForRequests() {
@@ -137,9 +165,10 @@ func (cc *ClientConnector) listenForRequests() {
 	log.Tracef("[%s] listenForRequests for client %v", ClientConnectorLogPrefix, cc.connection.RemoteAddr())
 
 	cc.clientHandlerWg.Add(1)
-	go func() {
+	cc.goroutines.start(func() {
 		defer cc.clientHandlerWg.Done()
 		defer close(cc.clientConnectorRequestsDoneChan)
+		defer errorreporting.ReportPanic()
 
 		wg := &sync.WaitGroup{}
 		defer wg.Wait()
@@ -158,7 +187,7 @@ func (cc *ClientConnector) listenForRequests() {
 		}
 
 		cc.clientHandlerWg.Add(1)
-		go func() {
+		cc.goroutines.start(func() {
 			defer cc.clientHandlerWg.Done()
 			select {
 			case <-cc.clientHandlerContext.Done():
@@ -167,7 +196,7 @@ func (cc *ClientConnector) listenForRequests() {
 			}
 
 			setDrainModeNowFunc()
-		}()
+		})
 
 		bufferedReader := bufio.NewReaderSize(cc.connection, cc.conf.RequestWriteBufferSizeBytes)
 		connectionAddr := cc.connection.RemoteAddr().String()
@@ -175,8 +204,19 @@ func (cc *ClientConnector) listenForRequests() {
 		var alreadySentProtocolErr *frame.RawFrame
 		for cc.clientHandlerContext.Err() == nil {
 			f, err := readRawFrame(bufferedReader, connectionAddr, cc.clientHandlerContext)
+			if err == nil {
+				cc.frameCapture.record(FrameDirectionClientRequest, f)
+				cc.frameExport.record(FrameDirectionClientRequest, f)
+			}
 
-			protocolErrResponseFrame, err, _ := checkProtocolError(f, err, protocolErrOccurred, ClientConnectorLogPrefix)
+			var protocolErrResponseFrame *frame.RawFrame
+			if err == nil {
+				protocolErrResponseFrame, err = cc.betaProtocolSupport.checkRequest(f)
+			}
+			if protocolErrResponseFrame == nil {
+				protocolErrResponseFrame, err, _ = checkProtocolError(
+					f, err, protocolErrOccurred, cc.maxProtocolVersion, ClientConnectorLogPrefix)
+			}
 			if err != nil {
 				handleConnectionError(
 					err, cc.clientHandlerContext, cc.clientHandlerCancelFunc, ClientConnectorLogPrefix, "reading", connectionAddr)
@@ -194,7 +234,7 @@ func (cc *ClientConnector) listenForRequests() {
 			}
 
 			wg.Add(1)
-			cc.readScheduler.Schedule(func() {
+			scheduleFrameTask(cc.readScheduler, f.Header, func() {
 				defer wg.Done()
 				log.Tracef("[%s] Received request on client connector: %v", ClientConnectorLogPrefix, f.Header)
 				lock.RLock()
@@ -208,12 +248,16 @@ func (cc *ClientConnector) listenForRequests() {
 				log.Tracef("[%s] Request sent to client connector's request channel: %v", ClientConnectorLogPrefix, f.Header)
 			})
 		}
-	}()
+	})
 }
 
 func (cc *ClientConnector) sendOverloadedToClient(request *frame.RawFrame) {
+	cc.sendOverloadedMessageToClient(request, "Shutting down, please retry on next host.")
+}
+
+func (cc *ClientConnector) sendOverloadedMessageToClient(request *frame.RawFrame, errMsg string) {
 	msg := &message.Overloaded{
-		ErrorMessage: "Shutting down, please retry on next host.",
+		ErrorMessage: errMsg,
 	}
 	response := frame.NewFrame(request.Header.Version, request.Header.StreamId, msg)
 	rawResponse, err := defaultCodec.ConvertToRawFrame(response)
@@ -224,7 +268,7 @@ func (cc *ClientConnector) sendOverloadedToClient(request *frame.RawFrame) {
 	}
 }
 
-func checkProtocolError(f *frame.RawFrame, connErr error, protocolErrorOccurred bool, prefix string) (protocolErrResponse *frame.RawFrame, fatalErr error, errorCode int8) {
+func checkProtocolError(f *frame.RawFrame, connErr error, protocolErrorOccurred bool, maxProtocolVersion primitive.ProtocolVersion, prefix string) (protocolErrResponse *frame.RawFrame, fatalErr error, errorCode int8) {
 	var protocolErrMsg *message.ProtocolError
 	var streamId int16
 	var logMsg string
@@ -236,6 +280,11 @@ func checkProtocolError(f *frame.RawFrame, connErr error, protocolErrorOccurred
 	} else {
 		protocolErrMsg = checkProtocolVersion(f.Header.Version)
 		logMsg = "Protocol v5 detected while decoding a frame."
+		if protocolErrMsg == nil && exceedsMaxProtocolVersion(f.Header.Version, maxProtocolVersion) {
+			protocolErrMsg = &message.ProtocolError{
+				ErrorMessage: fmt.Sprintf("Invalid or unsupported protocol version (%d)", f.Header.Version)}
+			logMsg = fmt.Sprintf("%v is higher than ZDM_MAX_CLIENT_PROTOCOL_VERSION (%v).", f.Header.Version, maxProtocolVersion)
+		}
 		streamId = f.Header.StreamId
 		errorCode = ProtocolErrorUnsupportedVersion
 	}
@@ -268,5 +317,7 @@ func generateProtocolErrorResponseFrame(streamId int16, protocolErrMsg *message.
 }
 
 func (cc *ClientConnector) sendResponseToClient(frame *frame.RawFrame) {
+	cc.frameCapture.record(FrameDirectionClientResponse, frame)
+	cc.frameExport.record(FrameDirectionClientResponse, frame)
 	cc.writeCoalescer.Enqueue(frame)
 }
@@ -0,0 +1,55 @@
+package zdmproxy
+
+import (
+	"net"
+)
+
+// clientAddressFilter decides which client connections are accepted based on ZDM_PROXY_CLIENT_ALLOWED_CIDRS and
+// ZDM_PROXY_CLIENT_DENIED_CIDRS. A denied address is rejected even if it is also allowed.
+type clientAddressFilter struct {
+	allowed []*net.IPNet
+	denied  []*net.IPNet
+}
+
+// newClientAddressFilter returns nil if there are no allowed or denied CIDR blocks, a nil filter allows every address.
+func newClientAddressFilter(allowed []*net.IPNet, denied []*net.IPNet) *clientAddressFilter {
+	if len(allowed) == 0 && len(denied) == 0 {
+		return nil
+	}
+	return &clientAddressFilter{allowed: allowed, denied: denied}
+}
+
+func (f *clientAddressFilter) isAllowed(addr net.Addr) bool {
+	if f == nil {
+		return true
+	}
+	var ip net.IP
+	switch tcpAddr := addr.(type) {
+	case *net.TCPAddr:
+		ip = tcpAddr.IP
+	default:
+		host, _, err := net.SplitHostPort(addr.String())
+		if err != nil {
+			return false
+		}
+		ip = net.ParseIP(host)
+	}
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range f.denied {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	if len(f.allowed) == 0 {
+		return true
+	}
+	for _, network := range f.allowed {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
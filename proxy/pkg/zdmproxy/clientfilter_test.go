@@ -0,0 +1,44 @@
+package zdmproxy
+
+import (
+	"github.com/stretchr/testify/require"
+	"net"
+	"testing"
+)
+
+func TestClientAddressFilter(t *testing.T) {
+	parseCidrs := func(cidrs ...string) []*net.IPNet {
+		var networks []*net.IPNet
+		for _, cidr := range cidrs {
+			_, network, err := net.ParseCIDR(cidr)
+			require.Nil(t, err)
+			networks = append(networks, network)
+		}
+		return networks
+	}
+
+	tests := []struct {
+		name    string
+		allowed []*net.IPNet
+		denied  []*net.IPNet
+		addr    net.Addr
+		result  bool
+	}{
+		{"no filter", nil, nil, &net.TCPAddr{IP: net.ParseIP("10.0.0.1")}, true},
+		{"allowed", parseCidrs("10.0.0.0/8"), nil, &net.TCPAddr{IP: net.ParseIP("10.1.2.3")}, true},
+		{"not allowed", parseCidrs("10.0.0.0/8"), nil, &net.TCPAddr{IP: net.ParseIP("192.168.0.1")}, false},
+		{"denied", nil, parseCidrs("192.168.0.0/16"), &net.TCPAddr{IP: net.ParseIP("192.168.0.1")}, false},
+		{"not denied", nil, parseCidrs("192.168.0.0/16"), &net.TCPAddr{IP: net.ParseIP("10.0.0.1")}, true},
+		{"allowed and denied", parseCidrs("10.0.0.0/8"), parseCidrs("10.0.0.0/24"),
+			&net.TCPAddr{IP: net.ParseIP("10.0.0.5")}, false},
+		{"ipv6 allowed", parseCidrs("fd00::/8"), nil, &net.TCPAddr{IP: net.ParseIP("fd00::1")}, true},
+		{"ipv4 mapped ipv6", parseCidrs("10.0.0.0/8"), nil, &net.TCPAddr{IP: net.ParseIP("::ffff:10.0.0.1")}, true},
+		{"non tcp address", parseCidrs("10.0.0.0/8"), nil, &net.UDPAddr{IP: net.ParseIP("10.0.0.1"), Port: 9042}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.result, newClientAddressFilter(tt.allowed, tt.denied).isAllowed(tt.addr))
+		})
+	}
+}
@@ -11,7 +11,9 @@ import (
 	"github.com/datastax/go-cassandra-native-protocol/primitive"
 	"github.com/datastax/zdm-proxy/proxy/pkg/common"
 	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"github.com/datastax/zdm-proxy/proxy/pkg/errorreporting"
 	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	"github.com/datastax/zdm-proxy/proxy/pkg/mutationexport"
 	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
 	"net"
@@ -37,6 +39,13 @@ import (
 type ClientHandler struct {
 	clientConnector *ClientConnector
 
+	// the inspection of the client sessions reads these, see clientsessions.go
+	clientConnection      *countingConn
+	connectedAt           time.Time
+	sessionId             uint64
+	clientProtocolVersion int32
+	queryStats            *sessionQueryStats
+
 	originCassandraConnector *ClusterConnector
 	targetCassandraConnector *ClusterConnector
 	asyncConnector           *ClusterConnector
@@ -58,6 +67,7 @@ type ClientHandler struct {
 	authErrorMessage *message.AuthenticationError
 
 	startupRequest           *atomic.Value
+	primaryStartupResponse   *frame.RawFrame
 	secondaryStartupResponse *frame.RawFrame
 	secondaryHandshakeCreds  *AuthCredentials
 	asyncHandshakeCreds      *AuthCredentials
@@ -74,6 +84,10 @@ type ClientHandler struct {
 	// map of request context holders that store the contexts for the active requests that are sent to async connector, keyed on streamID
 	asyncRequestContextHolders *sync.Map
 
+	// map of request context holders that store the contexts for the active hedged reads, keyed on the hedged read stream id
+	hedgedRequestContextHolders *sync.Map
+	hedgedReadStreamIds         StreamIdMapper
+
 	// pending requests map of "fire and forget" requests (kept here so that they can be timed out)
 	asyncPendingRequests *pendingRequests
 
@@ -106,14 +120,84 @@ type ClientHandler struct {
 	targetObserver *protocolEventObserverImpl
 
 	primaryCluster               common.ClusterType
+	hedgedReads                  bool
 	forwardSystemQueriesToTarget bool
 	forwardAuthToTarget          bool
 	targetCredsOnClientRequest   bool
 
+	originQuirks *clusterQuirks
+	targetQuirks *clusterQuirks
+
 	queryModifier     *QueryModifier
 	parameterModifier *ParameterModifier
 	timeUuidGenerator TimeUuidGenerator
 
+	requestInterceptors   []RequestInterceptor
+	wasmQueryHook         *wasmQueryHook
+	mutationPublisher     mutationexport.Publisher
+	interceptedConnection *InterceptedConnection
+
+	startupStrippedOptions []string
+	maxProtocolVersion     primitive.ProtocolVersion
+	eventsSource           common.EventsSource
+	scrubbedErrorDetails   []common.ScrubbedErrorDetail
+	memoryPressureMonitor  *memoryPressureMonitor
+	requestRateLimiter     *requestRateLimiter
+
+	globalRequestRateLimiter *globalRequestRateLimiter
+
+	tokenRangeRouter      *tokenRangeRouter
+	migrationStatusRouter *migrationStatusRouter
+	viewIndexTracker      *viewIndexTracker
+	targetWriteSampler    *targetWriteSampler
+
+	// nil unless ZDM_PREPARE_COALESCING_ENABLED is true, the PREPAREs are only coalesced with the ones sent to the
+	// same nodes (prepareCoalescingEndpoints holds the origin, target and async endpoint ids)
+	prepareCoalescer           *prepareCoalescer
+	prepareCoalescingEndpoints string
+
+	// nil unless ZDM_FAULT_INJECTION_ENABLED is true
+	faultInjector *FaultInjector
+
+	// nil unless ZDM_FRAME_CAPTURE_SIZE is set, see framecapture.go
+	frameCapture *frameCapture
+
+	// nil unless ZDM_HEAVY_HITTERS_SIZE is set, see heavyhitters.go
+	heavyHitters *heavyHitterTracker
+
+	// nil unless ZDM_STATEMENT_METRICS_MAX_FINGERPRINTS is set, see statementmetrics.go
+	statementMetrics *statementMetricsTracker
+
+	// nil unless ZDM_PAYLOAD_SIZE_METRICS_ENABLED is set, see payloadsizemetrics.go
+	payloadSizeMetrics *payloadSizeMetrics
+
+	// nil unless ZDM_PROXY_MAX_CONCURRENT_HANDSHAKES is set, released when the handshake is done or the connection closed
+	handshakeSlot *handshakeSlot
+
+	// counts the goroutines of this client handler and of its connectors, see goroutineaudit.go
+	goroutines *handlerGoroutines
+
+	// non nil only for the client connections that were created in origin-only mode
+	originOnlyFallback *originOnlyFallback
+
+	targetWritePipeline *targetWritePipeline
+
+	guardrails *guardrails
+
+	pageSizeOverride *pageSizeOverride
+
+	secondaryErrorPolicy *secondaryErrorPolicy
+
+	dualWriteResponsePolicy *dualWriteResponsePolicy
+
+	lwtHandler *lwtHandler
+
+	requestWriteQueueOverflowPolicy common.QueueOverflowPolicy
+
+	// nil unless proxy-level client authentication is enabled
+	clientCredentialStore ClientCredentialStore
+	roleMapping           roleMapping
+
 	// not used atm but should be used when a protocol error occurs after #68 has been addressed
 	clientHandlerShutdownRequestCancelFn context.CancelFunc
 
@@ -145,7 +229,44 @@ func NewClientHandler(
 	timeUuidGenerator TimeUuidGenerator,
 	readMode common.ReadMode,
 	primaryCluster common.ClusterType,
-	systemQueriesMode common.SystemQueriesMode) (*ClientHandler, error) {
+	systemQueriesMode common.SystemQueriesMode,
+	originCompatibilityProfile common.CompatibilityProfile,
+	targetCompatibilityProfile common.CompatibilityProfile,
+	requestInterceptors []RequestInterceptor,
+	wasmQueryHook *wasmQueryHook,
+	mutationPublisher mutationexport.Publisher,
+	startupStrippedOptions []string,
+	maxProtocolVersion primitive.ProtocolVersion,
+	eventsSource common.EventsSource,
+	scrubbedErrorDetails []common.ScrubbedErrorDetail,
+	memoryPressureMonitor *memoryPressureMonitor,
+	requestRateLimiter *requestRateLimiter,
+	globalRequestRateLimiter *globalRequestRateLimiter,
+	tokenRangeRouter *tokenRangeRouter,
+	migrationStatusRouter *migrationStatusRouter,
+	viewIndexTracker *viewIndexTracker,
+	prepareCoalescer *prepareCoalescer,
+	targetWriteSampler *targetWriteSampler,
+	faultInjector *FaultInjector,
+	frameCapture *frameCapture,
+	frameExport *frameExportStream,
+	heavyHitters *heavyHitterTracker,
+	statementMetrics *statementMetricsTracker,
+	payloadSizeMetrics *payloadSizeMetrics,
+	handshakeSlot *handshakeSlot,
+	goroutines *handlerGoroutines,
+	originOnlyFallback *originOnlyFallback,
+	targetWritePipeline *targetWritePipeline,
+	guardrails *guardrails,
+	pageSizeOverride *pageSizeOverride,
+	secondaryErrorPolicy *secondaryErrorPolicy,
+	dualWriteResponsePolicy *dualWriteResponsePolicy,
+	lwtHandler *lwtHandler,
+	requestWriteQueueOverflowPolicy common.QueueOverflowPolicy,
+	originConnectionCompression common.ConnectionCompression,
+	targetConnectionCompression common.ConnectionCompression,
+	clientCredentialStore ClientCredentialStore,
+	roleMapping roleMapping) (*ClientHandler, error) {
 
 	originEndpointId := originCassandraConnInfo.endpoint.GetEndpointIdentifier()
 	targetEndpointId := targetCassandraConnInfo.endpoint.GetEndpointIdentifier()
@@ -180,15 +301,17 @@ func NewClientHandler(
 
 	localClientHandlerWg := &sync.WaitGroup{}
 	globalClientHandlersWg.Add(1)
-	go func() {
+	goroutines.start(func() {
 		defer globalClientHandlersWg.Done()
 		<-clientHandlerContext.Done()
+		// the goroutines of the client handler are expected to exit from now on
+		goroutines.close()
 		clientHandlerShutdownRequestCancelFn()
 		localClientHandlerWg.Wait()
 		closeFrameProcessors()
 		requestsDoneCancelFn() // make sure this ctx is not leaked but it should be canceled before this
 		log.Debugf("Client Handler is shutdown.")
-	}()
+	})
 
 	respChannel := make(chan *Response, numWorkers)
 	clientHandlerRequestWg := &sync.WaitGroup{}
@@ -197,7 +320,7 @@ func NewClientHandler(
 	originConnector, err := NewClusterConnector(
 		originCassandraConnInfo, conf, psCache, nodeMetrics, localClientHandlerWg, clientHandlerRequestWg,
 		clientHandlerContext, clientHandlerCancelFunc, respChannel, readScheduler, writeScheduler, requestsDoneCtx,
-		false, nil, handshakeDone, originFrameProcessor)
+		false, nil, handshakeDone, originFrameProcessor, originConnectionCompression, goroutines)
 	if err != nil {
 		clientHandlerCancelFunc()
 		return nil, err
@@ -206,7 +329,7 @@ func NewClientHandler(
 	targetConnector, err := NewClusterConnector(
 		targetCassandraConnInfo, conf, psCache, nodeMetrics, localClientHandlerWg, clientHandlerRequestWg,
 		clientHandlerContext, clientHandlerCancelFunc, respChannel, readScheduler, writeScheduler, requestsDoneCtx,
-		false, nil, handshakeDone, targetFrameProcessor)
+		false, nil, handshakeDone, targetFrameProcessor, targetConnectionCompression, goroutines)
 	if err != nil {
 		clientHandlerCancelFunc()
 		return nil, err
@@ -216,21 +339,29 @@ func NewClientHandler(
 	var asyncConnector *ClusterConnector
 	if readMode == common.ReadModeDualAsyncOnSecondary {
 		var asyncConnInfo *ClusterConnectionInfo
+		var asyncConnectionCompression common.ConnectionCompression
 		if primaryCluster == common.ClusterTypeTarget {
 			asyncConnInfo = originCassandraConnInfo
+			asyncConnectionCompression = originConnectionCompression
 		} else {
 			asyncConnInfo = targetCassandraConnInfo
+			asyncConnectionCompression = targetConnectionCompression
 		}
 		asyncConnector, err = NewClusterConnector(
 			asyncConnInfo, conf, psCache, nodeMetrics, localClientHandlerWg, clientHandlerRequestWg,
 			clientHandlerContext, clientHandlerCancelFunc, respChannel, readScheduler, writeScheduler, requestsDoneCtx,
-			true, asyncPendingRequests, handshakeDone, asyncFrameProcessor)
+			true, asyncPendingRequests, handshakeDone, asyncFrameProcessor, asyncConnectionCompression, goroutines)
 		if err != nil {
 			log.Errorf("Could not create async cluster connector to %s, async requests will not be forwarded: %s", asyncConnInfo.connConfig.GetClusterType(), err.Error())
 			asyncConnector = nil
 		}
 	}
 
+	var hedgedReadStreamIds StreamIdMapper
+	if readMode == common.ReadModeHedged {
+		hedgedReadStreamIds = NewInternalStreamIdMapper(conf.ProxyMaxStreamIds, nil)
+	}
+
 	responsesDoneChan := make(chan bool, 1)
 	eventsDoneChan := make(chan bool, 1)
 	requestsChannel := make(chan *frame.RawFrame, numWorkers)
@@ -244,11 +375,15 @@ func NewClientHandler(
 	}
 
 	forwardAuthToTarget, targetCredsOnClientRequest := forwardAuthToTarget(
-		originControlConn, targetControlConn, conf.ForwardClientCredentialsToOrigin)
+		originControlConn, targetControlConn, conf.ForwardClientCredentialsToOrigin, conf.OriginAuthPassthroughEnabled)
 
-	return &ClientHandler{
+	clientConnection := newCountingConn(clientTcpConn)
+	ch := &ClientHandler{
+		clientConnection: clientConnection,
+		connectedAt:      time.Now(),
+		queryStats:       &sessionQueryStats{},
 		clientConnector: NewClientConnector(
-			clientTcpConn,
+			clientConnection,
 			conf,
 			localClientHandlerWg,
 			requestsChannel,
@@ -260,7 +395,12 @@ func NewClientHandler(
 			readScheduler,
 			writeScheduler,
 			clientHandlerShutdownRequestContext,
-			clientHandlerShutdownRequestCancelFn),
+			clientHandlerShutdownRequestCancelFn,
+			frameCapture,
+			frameExport,
+			maxProtocolVersion,
+			newBetaProtocolSupport(originControlConn.GetSupportedOptions(), targetControlConn.GetSupportedOptions()),
+			goroutines),
 
 		asyncConnector:                       asyncConnector,
 		originCassandraConnector:             originConnector,
@@ -282,6 +422,8 @@ func NewClientHandler(
 		originPassword:                       originPassword,
 		requestContextHolders:                &sync.Map{},
 		asyncRequestContextHolders:           &sync.Map{},
+		hedgedRequestContextHolders:          &sync.Map{},
+		hedgedReadStreamIds:                  hedgedReadStreamIds,
 		asyncPendingRequests:                 asyncPendingRequests,
 		reqChannel:                           requestsChannel,
 		respChannel:                          respChannel,
@@ -300,7 +442,10 @@ func NewClientHandler(
 		originObserver:                       originObserver,
 		targetObserver:                       targetObserver,
 		primaryCluster:                       primaryCluster,
+		hedgedReads:                          readMode == common.ReadModeHedged,
 		forwardSystemQueriesToTarget:         systemQueriesMode == common.SystemQueriesModeTarget,
+		originQuirks:                         getClusterQuirks(originCompatibilityProfile),
+		targetQuirks:                         getClusterQuirks(targetCompatibilityProfile),
 		forwardAuthToTarget:                  forwardAuthToTarget,
 		targetCredsOnClientRequest:           targetCredsOnClientRequest,
 		queryModifier:                        NewQueryModifier(timeUuidGenerator),
@@ -308,7 +453,56 @@ func NewClientHandler(
 		timeUuidGenerator:                    timeUuidGenerator,
 		clientHandlerShutdownRequestCancelFn: clientHandlerShutdownRequestCancelFn,
 		clientHandlerShutdownRequestContext:  clientHandlerShutdownRequestContext,
-	}, nil
+		requestInterceptors:                  requestInterceptors,
+		wasmQueryHook:                        wasmQueryHook,
+		mutationPublisher:                    mutationPublisher,
+		startupStrippedOptions:               startupStrippedOptions,
+		maxProtocolVersion:                   maxProtocolVersion,
+		eventsSource:                         eventsSource,
+		scrubbedErrorDetails:                 scrubbedErrorDetails,
+		memoryPressureMonitor:                memoryPressureMonitor,
+		requestRateLimiter:                   requestRateLimiter,
+		globalRequestRateLimiter:             globalRequestRateLimiter,
+		tokenRangeRouter:                     tokenRangeRouter,
+		migrationStatusRouter:                migrationStatusRouter,
+		viewIndexTracker:                     viewIndexTracker,
+		prepareCoalescer:                     prepareCoalescer,
+		prepareCoalescingEndpoints:           fmt.Sprintf("%v/%v/%v", originEndpointId, targetEndpointId, asyncEndpointId),
+		targetWriteSampler:                   targetWriteSampler,
+		faultInjector:                        faultInjector,
+		frameCapture:                         frameCapture,
+		heavyHitters:                         heavyHitters,
+		statementMetrics:                     statementMetrics,
+		payloadSizeMetrics:                   payloadSizeMetrics,
+		handshakeSlot:                        handshakeSlot,
+		goroutines:                           goroutines,
+		originOnlyFallback:                   originOnlyFallback,
+		targetWritePipeline:                  targetWritePipeline,
+		guardrails:                           guardrails,
+		pageSizeOverride:                     pageSizeOverride,
+		secondaryErrorPolicy:                 secondaryErrorPolicy,
+		dualWriteResponsePolicy:              dualWriteResponsePolicy,
+		lwtHandler:                           lwtHandler,
+		requestWriteQueueOverflowPolicy:      requestWriteQueueOverflowPolicy,
+		clientCredentialStore:                clientCredentialStore,
+		roleMapping:                          roleMapping,
+	}
+	if conf.OriginAuthPassthroughEnabled {
+		// the tokens of the client are only meant for ORIGIN (see handleClientCredentials), with the origin-only
+		// fallback the target credentials are the ones of ORIGIN
+		ch.secondaryHandshakeCreds = &AuthCredentials{Username: targetUsername, Password: targetPassword}
+		if asyncConnector != nil && asyncConnector.clusterType == common.ClusterTypeTarget {
+			ch.asyncHandshakeCreds = ch.secondaryHandshakeCreds
+		}
+	}
+	if len(requestInterceptors) > 0 {
+		ch.interceptedConnection = newInterceptedConnection(ch)
+	} else if conf.ResponseStreamingThresholdBytes > 0 {
+		// interceptors can modify responses so they have to be buffered
+		originConnector.responseStreamer = ch.streamResponse
+		targetConnector.responseStreamer = ch.streamResponse
+	}
+	return ch, nil
 }
 
 /**
@@ -328,7 +522,7 @@ func (ch *ClientHandler) run(activeClients *int32) {
 	addObserver(ch.originObserver, ch.originControlConn)
 	addObserver(ch.targetObserver, ch.targetControlConn)
 
-	go func() {
+	ch.goroutines.start(func() {
 		<-ch.originCassandraConnector.doneChan
 		<-ch.targetCassandraConnector.doneChan
 		if ch.asyncConnector != nil {
@@ -341,7 +535,7 @@ func (ch *ClientHandler) run(activeClients *int32) {
 
 		removeObserver(ch.originObserver, ch.originControlConn)
 		removeObserver(ch.targetObserver, ch.targetControlConn)
-	}()
+	})
 }
 
 func addObserver(observer *protocolEventObserverImpl, controlConn *ControlConn) {
@@ -370,11 +564,13 @@ func (ch *ClientHandler) requestLoop() {
 	var err error
 	ch.localClientHandlerWg.Add(1)
 	log.Debugf("requestLoop starting now")
-	go func() {
+	ch.goroutines.start(func() {
 		defer ch.localClientHandlerWg.Done()
 		connectionAddr := ch.clientConnector.connection.RemoteAddr().String()
+		defer errorreporting.ReportPanic()
 		defer log.Debugf("Client Handler request loop %v shutdown.", connectionAddr)
 		defer ch.requestsDoneCancelFn()
+		defer ch.handshakeSlot.release()
 		defer ch.originCassandraConnector.writeCoalescer.Close()
 		defer log.Debugf("Waiting for origin write coalescer to finish...")
 		defer ch.targetCassandraConnector.writeCoalescer.Close()
@@ -405,14 +601,26 @@ func (ch *ClientHandler) requestLoop() {
 					log.Error(err)
 				}
 				if ready {
+					atomic.StoreInt32(&ch.clientProtocolVersion, int32(f.Header.Version))
 					ch.handshakeDone.Store(true)
+					ch.handshakeSlot.release()
 					log.Infof(
 						"Handshake successful with client %s", connectionAddr)
 				}
 				log.Tracef("ready? %t", ready)
+			} else if f.Header.OpCode != primitive.OpCodeOptions && ch.memoryPressureMonitor.IsUnderPressure() {
+				// heartbeats are still answered, drivers could consider the connection defunct otherwise
+				ch.clientConnector.sendOverloadedMessageToClient(f, "Memory usage of the proxy is too high, please retry on next host.")
+				ch.metricHandler.GetProxyMetrics().MemoryPressureRejectedRequests.Add(1)
+			} else if f.Header.OpCode != primitive.OpCodeOptions && !ch.requestRateLimiter.Allow() {
+				ch.clientConnector.sendOverloadedMessageToClient(f, "Request rate limit of the proxy exceeded, please retry on next host.")
+				ch.metricHandler.GetProxyMetrics().RateLimitedRequests.Add(1)
+			} else if f.Header.OpCode != primitive.OpCodeOptions && !ch.globalRequestRateLimiter.Allow() {
+				ch.clientConnector.sendOverloadedMessageToClient(f, "Global request rate limit of the proxy fleet exceeded, please retry later.")
+				ch.metricHandler.GetProxyMetrics().GlobalRateLimitedRequests.Add(1)
 			} else {
 				wg.Add(1)
-				ch.requestResponseScheduler.Schedule(func() {
+				scheduleFrameTask(ch.requestResponseScheduler, f.Header, func() {
 					defer wg.Done()
 					ch.handleRequest(f)
 				})
@@ -423,10 +631,11 @@ func (ch *ClientHandler) requestLoop() {
 
 		wg.Wait()
 
-		go func() {
+		ch.goroutines.start(func() {
 			<-ch.clientHandlerContext.Done()
 			ch.clearRequestContexts(ch.requestContextHolders)
 			ch.clearRequestContexts(ch.asyncRequestContextHolders)
+			ch.clearRequestContexts(ch.hedgedRequestContextHolders)
 			if ch.asyncPendingRequests != nil {
 				ch.asyncPendingRequests.clear(func(ctx RequestContext) {
 					typedReqCtx, ok := ctx.(*asyncRequestContextImpl)
@@ -440,11 +649,11 @@ func (ch *ClientHandler) requestLoop() {
 					}
 				})
 			}
-		}()
+		})
 
 		log.Debugf("Waiting for all in flight requests from %v to finish.", connectionAddr)
 		ch.clientHandlerRequestWaitGroup.Wait()
-	}()
+	})
 }
 
 func (ch *ClientHandler) clearRequestContexts(contextHoldersMap *sync.Map) {
@@ -461,8 +670,14 @@ func (ch *ClientHandler) clearRequestContexts(contextHoldersMap *sync.Map) {
 				log.Errorf("Failed to cancel request because request context conversion failed. "+
 					"This is most likely a bug, please report. RequestContext: %v", reqCtx)
 			} else {
+				if lostConnector := ch.getLostClusterConnector(); lostConnector != nil {
+					ch.sendConnectionLostError(typedReqCtx, lostConnector)
+				}
 				ch.cancelRequest(reqCtxHolder, typedReqCtx)
 			}
+		} else if typedReqCtx, ok := reqCtx.(*requestContextImpl); ok && typedReqCtx.hedged {
+			// hedged read that is done but is still waiting for the response of the slowest cluster
+			ch.releaseHedgedRead(reqCtxHolder, typedReqCtx, true)
 		}
 		return true
 	})
@@ -470,14 +685,15 @@ func (ch *ClientHandler) clearRequestContexts(contextHoldersMap *sync.Map) {
 
 // Infinite loop that blocks on receiving from both cluster connector event channels.
 //
-// Event messages that come through will only be routed if
-//   - it's a schema change from origin
+// Event messages that come through will only be routed if they come from the cluster configured with
+// ZDM_EVENTS_SOURCE, see shouldForwardEvent.
 func (ch *ClientHandler) listenForEventMessages() {
 	ch.localClientHandlerWg.Add(1)
 	log.Debugf("listenForEventMessages loop starting now")
-	go func() {
+	ch.goroutines.start(func() {
 		defer ch.localClientHandlerWg.Done()
 		defer close(ch.eventsDoneChan)
+		defer errorreporting.ReportPanic()
 		shutDownChannels := 0
 		targetChannel := ch.targetCassandraConnector.clusterConnEventsChan
 		originChannel := ch.originCassandraConnector.clusterConnEventsChan
@@ -518,34 +734,7 @@ func (ch *ClientHandler) listenForEventMessages() {
 				continue
 			}
 
-			switch msgType := body.Message.(type) {
-			case *message.ProtocolError:
-				log.Debug("Received protocol error on event body listener, forwarding to client: ", body.Message)
-			case *message.SchemaChangeEvent:
-				if fromTarget {
-					log.Infof("Received schema change event from target, skipping: %v", msgType)
-					continue
-				}
-			case *message.StatusChangeEvent:
-				if ch.topologyConfig.VirtualizationEnabled {
-					log.Infof("Received status change event (fromTarget=%v) but virtualization is enabled, skipping: %v", fromTarget, msgType)
-					continue
-				}
-				if !fromTarget {
-					log.Infof("Received status change event from origin, skipping: %v", msgType)
-					continue
-				}
-			case *message.TopologyChangeEvent:
-				if ch.topologyConfig.VirtualizationEnabled {
-					log.Infof("Received topology change event (fromTarget=%v) but virtualization is enabled, skipping: %v", fromTarget, msgType)
-					continue
-				}
-				if !fromTarget {
-					log.Infof("Received topology change event from origin, skipping: %v", msgType)
-					continue
-				}
-			default:
-				log.Infof("Expected event body (fromTarget: %v) but got: %v", fromTarget, msgType)
+			if !ch.shouldForwardEvent(body.Message, fromTarget) {
 				continue
 			}
 
@@ -553,7 +742,7 @@ func (ch *ClientHandler) listenForEventMessages() {
 		}
 
 		log.Debugf("Shutting down client event messages listener.")
-	}()
+	})
 }
 
 // Infinite loop that blocks on receiving from the response channel
@@ -561,9 +750,10 @@ func (ch *ClientHandler) listenForEventMessages() {
 func (ch *ClientHandler) responseLoop() {
 	ch.localClientHandlerWg.Add(1)
 	log.Debugf("responseLoop starting now")
-	go func() {
+	ch.goroutines.start(func() {
 		defer ch.localClientHandlerWg.Done()
 		defer close(ch.responsesDoneChan)
+		defer errorreporting.ReportPanic()
 
 		wg := &sync.WaitGroup{}
 		defer wg.Wait()
@@ -576,8 +766,17 @@ func (ch *ClientHandler) responseLoop() {
 				break
 			}
 
+			schedule := ch.requestResponseScheduler.Schedule
+			if response.responseFrame != nil && isControlPlaneFrame(response.responseFrame.Header) {
+				schedule = ch.requestResponseScheduler.SchedulePriority
+			}
+
+			ch.frameCapture.record(getFrameCaptureResponseDirection(response.connectorType), response.responseFrame)
+			var injectedLatency time.Duration
+			response, injectedLatency = ch.faultInjector.apply(response)
+
 			wg.Add(1)
-			ch.requestResponseScheduler.Schedule(func() {
+			task := func() {
 				defer wg.Done()
 
 				var responseClusterType common.ClusterType
@@ -600,6 +799,8 @@ func (ch *ClientHandler) responseLoop() {
 				var contextHoldersMap *sync.Map
 				if response.connectorType == ClusterConnectorTypeAsync {
 					contextHoldersMap = ch.asyncRequestContextHolders
+				} else if isHedgedReadStreamId(streamId) {
+					contextHoldersMap = ch.hedgedRequestContextHolders
 				} else {
 					contextHoldersMap = ch.requestContextHolders
 				}
@@ -613,6 +814,16 @@ func (ch *ClientHandler) responseLoop() {
 					return
 				}
 
+				if response.connectorType != ClusterConnectorTypeAsync && response.responseFrame != nil &&
+					ch.conf.ConsistencyDowngradeRetryEnabled {
+					if ch.tryRetryWithDowngradedConsistency(response, responseClusterType, reqCtx) {
+						if reqCtx.GetRequestInfo().ShouldBeTrackedInMetrics() {
+							trackClusterErrorMetrics(response.responseFrame, response.connectorType, ch.nodeMetrics)
+						}
+						return
+					}
+				}
+
 				finished := false
 				if response.responseFrame == nil {
 					finished = reqCtx.SetTimeout(ch.nodeMetrics, response.requestFrame)
@@ -631,12 +842,22 @@ func (ch *ClientHandler) responseLoop() {
 					} else {
 						ch.finishRequest(holder, typedReqCtx)
 					}
+				} else if typedReqCtx, ok := reqCtx.(*requestContextImpl); ok && typedReqCtx.hedged {
+					ch.releaseHedgedRead(holder, typedReqCtx, false)
 				}
-			})
+			}
+			if injectedLatency > 0 {
+				// the response is delayed without holding a worker, the wait group keeps the loop running until then
+				time.AfterFunc(injectedLatency, func() {
+					schedule(task)
+				})
+			} else {
+				schedule(task)
+			}
 		}
 
 		log.Debugf("Shutting down responseLoop.")
-	}()
+	})
 }
 
 // Checks if response is a protocol error. Returns true if it processes this response. If it returns false,
@@ -655,6 +876,10 @@ func (ch *ClientHandler) tryProcessProtocolError(response *Response, protocolErr
 			} else {
 				log.Debugf("[ClientHandler] Protocol version downgrade detected (%v) on %v, forwarding it to the client.",
 					errMsg, response.connectorType)
+				if protocolErrResponse := ch.createUnsupportedProtocolVersionResponse(response.responseFrame, errMsg); protocolErrResponse != nil {
+					ch.clientConnector.sendResponseToClient(protocolErrResponse)
+					return true
+				}
 			}
 			ch.clientConnector.sendResponseToClient(response.responseFrame)
 		}
@@ -688,37 +913,73 @@ func decodeError(responseFrame *frame.RawFrame) (message.Error, error) {
 func (ch *ClientHandler) finishRequest(holder *requestContextHolder, reqCtx *requestContextImpl) {
 	defer ch.clientHandlerRequestWaitGroup.Done()
 
-	err := holder.Clear(reqCtx)
-	if err != nil {
-		log.Debugf("Could not free stream id: %v", err)
-	}
-
-	if reqCtx.requestInfo.ShouldBeTrackedInMetrics() {
-		proxyMetrics := ch.metricHandler.GetProxyMetrics()
-		switch reqCtx.requestInfo.GetForwardDecision() {
-		case forwardToBoth:
-			proxyMetrics.ProxyWritesDuration.Track(reqCtx.startTime)
-			proxyMetrics.InFlightWrites.Subtract(1)
-		case forwardToOrigin:
-			proxyMetrics.ProxyReadsOriginDuration.Track(reqCtx.startTime)
-			proxyMetrics.InFlightReadsOrigin.Subtract(1)
-		case forwardToTarget:
-			proxyMetrics.ProxyReadsTargetDuration.Track(reqCtx.startTime)
-			proxyMetrics.InFlightReadsTarget.Subtract(1)
-		case forwardToAsyncOnly, forwardToNone:
-		default:
-			log.Errorf("unexpected forwardDecision %v, unable to track proxy level metrics", reqCtx.requestInfo.GetForwardDecision())
+	if reqCtx.hedged {
+		defer ch.releaseHedgedRead(holder, reqCtx, false)
+	} else {
+		err := holder.Clear(reqCtx)
+		if err != nil {
+			log.Debugf("Could not free stream id: %v", err)
 		}
 	}
 
+	ch.trackFinishedRequestMetrics(reqCtx)
+
 	aggregatedResponse, responseClusterType, err := ch.computeClientResponse(reqCtx)
+	secondaryErrorAction := common.SecondaryErrorActionError
+	dualWritePolicyApplied := false
+	dualWritePolicyWarning := ""
+	if err == nil && reqCtx.requestInfo.GetForwardDecision() == forwardToBoth && reqCtx.requestInfo.ShouldBeTrackedInMetrics() {
+		policyResponse, policyClusterType, warning := ch.dualWriteResponsePolicy.aggregate(
+			ch.primaryCluster, reqCtx.statementType, reqCtx.request, reqCtx.originResponse, reqCtx.targetResponse)
+		if policyResponse != nil {
+			aggregatedResponse, responseClusterType = policyResponse, policyClusterType
+			dualWritePolicyApplied, dualWritePolicyWarning = true, warning
+		} else {
+			translatedResponse, translatedClusterType, action := ch.secondaryErrorPolicy.translate(
+				ch.primaryCluster, reqCtx.request, reqCtx.originResponse, reqCtx.targetResponse)
+			if action != common.SecondaryErrorActionError {
+				aggregatedResponse, responseClusterType, secondaryErrorAction = translatedResponse, translatedClusterType, action
+			}
+		}
+	}
 	finalResponse := aggregatedResponse
 	if err == nil && reqCtx.requestInfo.GetForwardDecision() != forwardToAsyncOnly {
 		// async only requests can't have "PREPARED", "SETKEYSPACE" or "UNPREPARED" responses so skip this
 		finalResponse, err = ch.processClientResponse(aggregatedResponse, responseClusterType, reqCtx)
 	}
 
+	if err == nil {
+		finalResponse, err = addConsistencyDowngradeWarnings(finalResponse, reqCtx.GetConsistencyDowngrades())
+	}
+
+	if err == nil && ch.conf.ClusterWarningsAggregationEnabled &&
+		reqCtx.requestInfo.GetForwardDecision() == forwardToBoth {
+		finalResponse, err = aggregateClusterWarnings(
+			finalResponse, reqCtx.originResponse, reqCtx.targetResponse, ch.metricHandler.GetProxyMetrics())
+	}
+
+	if err == nil && dualWritePolicyWarning != "" {
+		finalResponse, err = addResponseWarnings(finalResponse, dualWritePolicyWarning)
+	}
+
+	// the dual write response policy already decided how the failure of one cluster is surfaced
+	secondaryWriteFailureWarning := !dualWritePolicyApplied && (secondaryErrorAction == common.SecondaryErrorActionWarning ||
+		(ch.conf.SecondaryWriteFailureWarningEnabled && secondaryErrorAction != common.SecondaryErrorActionMetric))
+	if err == nil && secondaryWriteFailureWarning &&
+		reqCtx.requestInfo.GetForwardDecision() == forwardToBoth && reqCtx.requestInfo.ShouldBeTrackedInMetrics() {
+		var warning string
+		warning, err = getSecondaryWriteFailureWarning(ch.primaryCluster, reqCtx.originResponse, reqCtx.targetResponse)
+		if err == nil && warning != "" {
+			finalResponse, err = addResponseWarnings(finalResponse, warning)
+		}
+	}
+
+	if err == nil && len(reqCtx.responseWarnings) > 0 {
+		finalResponse, err = addResponseWarnings(finalResponse, reqCtx.responseWarnings...)
+	}
+
 	if err != nil {
+		ch.prepareCoalescer.complete(reqCtx.prepareCall, nil)
 		if reqCtx.customResponseChannel != nil {
 			close(reqCtx.customResponseChannel)
 		}
@@ -726,12 +987,34 @@ func (ch *ClientHandler) finishRequest(holder *requestContextHolder, reqCtx *req
 		return
 	}
 
+	request := reqCtx.request
 	reqCtx.request = nil
 	originResponse := reqCtx.originResponse
 	reqCtx.originResponse = nil
 	targetResponse := reqCtx.targetResponse
 	reqCtx.targetResponse = nil
 
+	if reqCtx.lwt {
+		ch.lwtHandler.trackResponses(originResponse, targetResponse)
+	}
+
+	if reqCtx.asyncReadComparison != nil {
+		primaryResponse := originResponse
+		if ch.primaryCluster == common.ClusterTypeTarget {
+			primaryResponse = targetResponse
+		}
+		if primaryResponse != nil {
+			// compared after the response is sent to the client, the header is modified when it's written
+			defer reqCtx.asyncReadComparison.setPrimaryResponse(
+				&frame.RawFrame{Header: primaryResponse.Header.Clone(), Body: primaryResponse.Body})
+		}
+	}
+
+	if reqCtx.exportedMutations != nil {
+		ch.exportMutations(reqCtx.exportedMutations, originResponse, targetResponse)
+		reqCtx.exportedMutations = nil
+	}
+
 	if reqCtx.customResponseChannel != nil {
 		reqCtx.customResponseChannel <- &customResponse{
 			originResponse:     originResponse,
@@ -739,17 +1022,45 @@ func (ch *ClientHandler) finishRequest(holder *requestContextHolder, reqCtx *req
 			aggregatedResponse: finalResponse,
 		}
 	} else {
-		ch.clientConnector.sendResponseToClient(finalResponse)
+		ch.queryStats.track(reqCtx, finalResponse)
+		ch.prepareCoalescer.complete(reqCtx.prepareCall, finalResponse)
+		ch.sendInterceptedResponseToClient(request, finalResponse)
+	}
+}
+
+func (ch *ClientHandler) trackFinishedRequestMetrics(reqCtx *requestContextImpl) {
+	if !reqCtx.requestInfo.ShouldBeTrackedInMetrics() {
+		return
+	}
+	proxyMetrics := ch.metricHandler.GetProxyMetrics()
+	switch reqCtx.requestInfo.GetForwardDecision() {
+	case forwardToBoth:
+		proxyMetrics.ProxyWritesDuration.Track(reqCtx.startTime)
+		proxyMetrics.InFlightWrites.Subtract(1)
+	case forwardToOrigin:
+		proxyMetrics.ProxyReadsOriginDuration.Track(reqCtx.startTime)
+		proxyMetrics.InFlightReadsOrigin.Subtract(1)
+	case forwardToTarget:
+		proxyMetrics.ProxyReadsTargetDuration.Track(reqCtx.startTime)
+		proxyMetrics.InFlightReadsTarget.Subtract(1)
+	case forwardToAsyncOnly, forwardToNone:
+	default:
+		log.Errorf("unexpected forwardDecision %v, unable to track proxy level metrics", reqCtx.requestInfo.GetForwardDecision())
 	}
 }
 
 // should only be called after Cancel returns true
 func (ch *ClientHandler) cancelRequest(holder *requestContextHolder, reqCtx *requestContextImpl) {
 	defer ch.clientHandlerRequestWaitGroup.Done()
+	ch.prepareCoalescer.complete(reqCtx.prepareCall, nil)
 
-	err := holder.Clear(reqCtx)
-	if err != nil {
-		log.Debugf("Could not free stream id: %v", err)
+	if reqCtx.hedged {
+		ch.releaseHedgedRead(holder, reqCtx, true)
+	} else {
+		err := holder.Clear(reqCtx)
+		if err != nil {
+			log.Debugf("Could not free stream id: %v", err)
+		}
 	}
 
 	if reqCtx.requestInfo.ShouldBeTrackedInMetrics() {
@@ -769,6 +1080,8 @@ func (ch *ClientHandler) cancelRequest(holder *requestContextHolder, reqCtx *req
 
 	if reqCtx.customResponseChannel != nil {
 		close(reqCtx.customResponseChannel)
+	} else {
+		ch.queryStats.track(reqCtx, nil)
 	}
 
 	log.Tracef("Canceled request %v.", reqCtx.request.Header)
@@ -776,6 +1089,10 @@ func (ch *ClientHandler) cancelRequest(holder *requestContextHolder, reqCtx *req
 
 // Computes the response to be sent to the client based on the forward decision of the request.
 func (ch *ClientHandler) computeClientResponse(requestContext *requestContextImpl) (*frame.RawFrame, common.ClusterType, error) {
+	if requestContext.hedged {
+		return ch.computeHedgedClientResponse(requestContext)
+	}
+
 	fwdDecision := requestContext.requestInfo.GetForwardDecision()
 	switch fwdDecision {
 	case forwardToOrigin:
@@ -864,6 +1181,23 @@ func (ch *ClientHandler) processClientResponse(
 			return nil, fmt.Errorf("error decoding response: %w", err)
 		}
 
+		if errMsg, ok := decodedFrame.Body.Message.(message.Error); ok {
+			if translatedErr := ch.getClusterQuirks(responseClusterType).translateError(errMsg); translatedErr != nil {
+				log.Debugf("Translating %v error from %v to %v according to its compatibility profile.",
+					errMsg.GetErrorCode(), responseClusterType, translatedErr.GetErrorCode())
+				newFrame = decodedFrame.Clone()
+				newFrame.Body.Message = translatedErr
+				errMsg = translatedErr
+			}
+			if scrubbedErr := ch.scrubErrorMessage(errMsg); scrubbedErr != nil {
+				log.Debugf("Removing backend details from %v error message from %v.", errMsg.GetErrorCode(), responseClusterType)
+				if newFrame == nil {
+					newFrame = decodedFrame.Clone()
+				}
+				newFrame.Body.Message = scrubbedErr
+			}
+		}
+
 		switch bodyMsg := decodedFrame.Body.Message.(type) {
 		case *message.PreparedResult:
 			newFrame, err = ch.processPreparedResponse(decodedFrame, bodyMsg, reqCtx)
@@ -905,6 +1239,19 @@ func (ch *ClientHandler) processClientResponse(
 				responseClusterType, hex.EncodeToString(unpreparedId),
 				responseClusterType, hex.EncodeToString(bodyMsg.Id), bodyMsg.ErrorMessage)
 		}
+	case primitive.OpCodeSupported:
+		decodedFrame, err := defaultCodec.ConvertFromRawFrame(response)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding response: %w", err)
+		}
+
+		if bodyMsg, ok := decodedFrame.Body.Message.(*message.Supported); ok {
+			if newOptions, stripped := stripScyllaOptions(bodyMsg.Options); stripped {
+				log.Debugf("Removing ScyllaDB protocol extensions from SUPPORTED response from %v.", responseClusterType)
+				newFrame = decodedFrame.Clone()
+				newFrame.Body.Message = &message.Supported{Options: newOptions}
+			}
+		}
 	}
 
 	if newFrame == nil {
@@ -918,6 +1265,17 @@ func (ch *ClientHandler) processClientResponse(
 	return newRawFrame, nil
 }
 
+func (ch *ClientHandler) getClusterQuirks(clusterType common.ClusterType) *clusterQuirks {
+	switch clusterType {
+	case common.ClusterTypeOrigin:
+		return ch.originQuirks
+	case common.ClusterTypeTarget:
+		return ch.targetQuirks
+	default:
+		return nil
+	}
+}
+
 func (ch *ClientHandler) processPreparedResponse(
 	response *frame.Frame, bodyMsg *message.PreparedResult, reqCtx *requestContextImpl) (*frame.Frame, error) {
 	if bodyMsg.PreparedQueryId == nil {
@@ -1028,6 +1386,10 @@ func (ch *ClientHandler) processPreparedResponse(
 			}
 		}
 
+		if _, alreadyPrepared := ch.preparedStatementCache.Get(bodyMsg.PreparedQueryId); !alreadyPrepared {
+			checkPreparedMetadata(
+				bodyMsg, targetPreparedResult, prepareRequestInfo.GetQuery(), ch.metricHandler.GetProxyMetrics())
+		}
 		ch.preparedStatementCache.Store(bodyMsg, targetPreparedResult, prepareRequestInfo)
 		return newResponse, nil
 	}
@@ -1052,9 +1414,21 @@ type startHandshakeResult struct {
 // When the Origin handshake ends, this function blocks, waiting until Target handshake is done.
 // This ensures that the client connection is Ready only when both Cluster Connector connections are ready.
 func (ch *ClientHandler) handleHandshakeRequest(request *frame.RawFrame, wg *sync.WaitGroup) (bool, error) {
+	if ch.clientCredentialStore != nil {
+		switch request.Header.OpCode {
+		case primitive.OpCodeAuthResponse:
+			return ch.handleProxyAuthResponse(request, wg)
+		case primitive.OpCodeStartup, primitive.OpCodeOptions:
+		default:
+			// the clusters may have accepted the handshake of the proxy already so nothing else can be forwarded
+			// until the client authenticated with the proxy
+			return false, ch.rejectUnauthenticatedRequest(request, wg)
+		}
+	}
+
 	scheduledTaskChannel := make(chan *handshakeRequestResult, 1)
 	wg.Add(1)
-	ch.requestResponseScheduler.Schedule(func() {
+	ch.requestResponseScheduler.SchedulePriority(func() {
 		defer wg.Done()
 		defer close(scheduledTaskChannel)
 		if ch.authErrorMessage != nil {
@@ -1069,6 +1443,23 @@ func (ch *ClientHandler) handleHandshakeRequest(request *frame.RawFrame, wg *syn
 			return
 		}
 
+		if request.Header.OpCode == primitive.OpCodeStartup && len(ch.startupStrippedOptions) > 0 {
+			newStartupFrame, removedOptions, err := sanitizeStartupRequest(request, ch.startupStrippedOptions)
+			if err != nil {
+				scheduledTaskChannel <- &handshakeRequestResult{
+					authSuccess: false,
+					err:         err,
+				}
+				return
+			}
+
+			if len(removedOptions) > 0 {
+				log.Infof("Removed STARTUP options %v of client %v before forwarding the STARTUP request.",
+					removedOptions, ch.clientConnector.connection.RemoteAddr().String())
+				request = newStartupFrame
+			}
+		}
+
 		if request.Header.OpCode == primitive.OpCodeAuthResponse {
 			newAuthFrame, err := ch.handleClientCredentials(request)
 			if err != nil {
@@ -1153,11 +1544,15 @@ func (ch *ClientHandler) handleHandshakeRequest(request *frame.RawFrame, wg *syn
 		if err != nil {
 			return false, fmt.Errorf("unsuccessful startup on %v: %w", secondaryCluster, err)
 		}
+
+		if ch.clientCredentialStore != nil && isProxyAuthStartupResponse(aggregatedResponse) {
+			return ch.requestProxyAuthentication(request, aggregatedResponse, wg)
+		}
 	}
 
 	startHandshakeCh := make(chan *startHandshakeResult, 1)
 	wg.Add(1)
-	ch.requestResponseScheduler.Schedule(func() {
+	ch.requestResponseScheduler.SchedulePriority(func() {
 		defer wg.Done()
 		defer close(startHandshakeCh)
 		tempResult := &startHandshakeResult{
@@ -1217,7 +1612,7 @@ func (ch *ClientHandler) handleHandshakeRequest(request *frame.RawFrame, wg *syn
 
 	scheduledTaskChannel = make(chan *handshakeRequestResult, 1)
 	wg.Add(1)
-	ch.requestResponseScheduler.Schedule(func() {
+	ch.requestResponseScheduler.SchedulePriority(func() {
 		defer wg.Done()
 		defer close(scheduledTaskChannel)
 		tempResult := &handshakeRequestResult{
@@ -1286,7 +1681,11 @@ func (ch *ClientHandler) sendAuthErrorToClient(requestFrame *frame.RawFrame, sec
 // Build authentication error response to return to client
 func (ch *ClientHandler) buildAuthErrorResponse(
 	requestFrame *frame.RawFrame, authenticationError *message.AuthenticationError) (*frame.RawFrame, error) {
-	f := frame.NewFrame(requestFrame.Header.Version, requestFrame.Header.StreamId, authenticationError)
+	return ch.buildHandshakeResponse(requestFrame, authenticationError)
+}
+
+func (ch *ClientHandler) buildHandshakeResponse(requestFrame *frame.RawFrame, msg message.Message) (*frame.RawFrame, error) {
+	f := frame.NewFrame(requestFrame.Header.Version, requestFrame.Header.StreamId, msg)
 	if requestFrame.Header.Flags.Contains(primitive.HeaderFlagCompressed) {
 		f.SetCompress(true)
 	}
@@ -1316,19 +1715,30 @@ func (ch *ClientHandler) startSecondaryHandshake(asyncConnector bool) (chan erro
 
 	channel := make(chan error)
 	ch.clientHandlerRequestWaitGroup.Add(1)
-	go func() {
+	ch.goroutines.start(func() {
 		defer ch.clientHandlerRequestWaitGroup.Done()
 		defer close(channel)
 		var err error
 		err = ch.handleSecondaryHandshakeStartup(startupFrame, startupResponse, asyncConnector)
 		channel <- err
-	}()
+	})
 	return channel, nil
 }
 
 // Handles a request, see the docs for the forwardRequest() function, as handleRequest is pretty much a wrapper
 // around forwardRequest.
 func (ch *ClientHandler) handleRequest(f *frame.RawFrame) {
+	if len(ch.requestInterceptors) > 0 {
+		var response *frame.RawFrame
+		f, response = ch.interceptRequest(f)
+		if f == nil {
+			if response != nil {
+				ch.clientConnector.sendResponseToClient(response)
+			}
+			return
+		}
+	}
+
 	err := ch.forwardRequest(f, nil)
 
 	if err != nil {
@@ -1341,12 +1751,45 @@ func (ch *ClientHandler) handleRequest(f *frame.RawFrame) {
 func (ch *ClientHandler) forwardRequest(request *frame.RawFrame, customResponseChannel chan *customResponse) error {
 	overallRequestStartTime := time.Now()
 
-	log.Tracef("Request frame: %v", request)
+	log.Tracef("Request frame: %v", redactedRawFrame{request})
+
+	// OPTIONS is answered by the proxy during the handshake so that the client only negotiates
+	// the protocol versions and compression algorithms that both clusters support, after the handshake
+	// it is a heartbeat which is only answered by the proxy if HeartbeatLocalResponseEnabled is set
+	if request.Header.OpCode == primitive.OpCodeOptions && (customResponseChannel != nil || ch.conf.HeartbeatLocalResponseEnabled) {
+		supportedResponse, err := ch.createLocalSupportedResponse(request)
+		if err != nil {
+			return err
+		}
+		if supportedResponse != nil {
+			if customResponseChannel != nil {
+				customResponseChannel <- &customResponse{aggregatedResponse: supportedResponse}
+			} else {
+				ch.sendInterceptedResponseToClient(request, supportedResponse)
+			}
+			return nil
+		}
+	}
 
 	currentKeyspace := ch.LoadCurrentKeyspace()
 	context := NewFrameDecodeContext(request)
 	var replacedTerms []*statementReplacedTerms
+	var hookDecision forwardDecision
 	var err error
+	if ch.wasmQueryHook != nil {
+		context, hookDecision, err = ch.wasmQueryHook.transformRequest(currentKeyspace, context, ch.timeUuidGenerator)
+		if err != nil {
+			if customResponseChannel != nil {
+				return err
+			}
+			log.Debugf("WASM hook rejected request with opcode %v and stream id %d: %v",
+				request.Header.OpCode, request.Header.StreamId, err)
+			if errResponse := createInterceptorErrorResponse(request.Header, err); errResponse != nil {
+				ch.sendInterceptedResponseToClient(request, errResponse)
+			}
+			return nil
+		}
+	}
 	if ch.conf.ReplaceCqlFunctions {
 		context, replacedTerms, err = ch.queryModifier.replaceQueryString(currentKeyspace, context)
 	}
@@ -1356,7 +1799,8 @@ func (ch *ClientHandler) forwardRequest(request *frame.RawFrame, customResponseC
 	}
 	requestInfo, err := buildRequestInfo(
 		context, replacedTerms, ch.preparedStatementCache, ch.metricHandler, currentKeyspace, ch.primaryCluster,
-		ch.forwardSystemQueriesToTarget, ch.topologyConfig.VirtualizationEnabled, ch.forwardAuthToTarget, ch.timeUuidGenerator)
+		ch.forwardSystemQueriesToTarget, ch.topologyConfig.VirtualizationEnabled, ch.forwardAuthToTarget, ch.conf.RoutingHintsEnabled,
+		ch.originQuirks, ch.targetQuirks, ch.timeUuidGenerator)
 	if err != nil {
 		if errVal, ok := err.(*UnpreparedExecuteError); ok {
 			unpreparedFrame, err := createUnpreparedFrame(errVal)
@@ -1368,15 +1812,70 @@ func (ch *ClientHandler) forwardRequest(request *frame.RawFrame, customResponseC
 				errVal.Header.Version, errVal.Header.StreamId, errVal.preparedId)
 
 			// send it back to client
-			ch.clientConnector.sendResponseToClient(unpreparedFrame)
+			ch.sendInterceptedResponseToClient(request, unpreparedFrame)
 			log.Debugf("Unprepared Response sent, exiting handleRequest now")
 			return nil
 		}
 		return err
 	}
+	if hookDecision != "" {
+		requestInfo = overrideForwardDecision(requestInfo, hookDecision)
+	}
+	context, err = ch.pageSizeOverride.apply(requestInfo, context)
+	if err != nil {
+		return err
+	}
+	responseWarnings, err := ch.guardrails.check(requestInfo, context, currentKeyspace, ch.timeUuidGenerator)
+	if err != nil {
+		guardrailErr, ok := err.(*guardrailError)
+		if !ok || customResponseChannel != nil {
+			return err
+		}
+		errResponse, err := createGuardrailErrorResponse(request.Header, guardrailErr)
+		if err != nil {
+			return err
+		}
+		ch.sendInterceptedResponseToClient(request, errResponse)
+		return nil
+	}
+	requestInfo = ch.tokenRangeRouter.route(requestInfo, context)
+	requestInfo = ch.migrationStatusRouter.route(requestInfo, context, currentKeyspace, ch.timeUuidGenerator)
+	requestInfo = ch.targetWriteSampler.route(requestInfo, context, currentKeyspace, ch.timeUuidGenerator)
+	clientAddress := ch.clientConnector.connection.RemoteAddr().String()
+	requestInfo, err = ch.targetWritePipeline.route(
+		requestInfo, context, currentKeyspace, clientAddress, ch.timeUuidGenerator)
+	if err == nil && ch.originOnlyFallback != nil {
+		var originOnlyWarnings []string
+		requestInfo, originOnlyWarnings, err = ch.originOnlyFallback.route(
+			requestInfo, context, currentKeyspace, clientAddress, ch.timeUuidGenerator)
+		responseWarnings = append(responseWarnings, originOnlyWarnings...)
+	}
+	if err != nil {
+		if customResponseChannel != nil {
+			return err
+		}
+		log.Warnf("Rejecting request with opcode %v and stream id %d: %v",
+			request.Header.OpCode, request.Header.StreamId, err)
+		if errResponse := createInterceptorErrorResponse(request.Header, err); errResponse != nil {
+			ch.sendInterceptedResponseToClient(request, errResponse)
+		}
+		return nil
+	}
+	ch.viewIndexTracker.track(requestInfo, context, currentKeyspace, ch.timeUuidGenerator)
 
 	requestTimeout := time.Duration(ch.conf.ProxyRequestTimeoutMs) * time.Millisecond
-	err = ch.executeRequest(context, requestInfo, currentKeyspace, overallRequestStartTime, customResponseChannel, requestTimeout)
+	var prepareCall *prepareCall
+	if customResponseChannel == nil {
+		var coalesced bool
+		prepareCall, coalesced = ch.coalescePrepare(
+			context, requestInfo, currentKeyspace, overallRequestStartTime, requestTimeout, responseWarnings)
+		if coalesced {
+			return nil
+		}
+	}
+	err = ch.executeRequest(
+		context, requestInfo, currentKeyspace, overallRequestStartTime, customResponseChannel, requestTimeout, responseWarnings,
+		prepareCall)
 	if err != nil {
 		return err
 	}
@@ -1384,10 +1883,17 @@ func (ch *ClientHandler) forwardRequest(request *frame.RawFrame, customResponseC
 }
 
 // executeRequest executes the forward decision and waits for one or two responses, then returns the response
-// that should be sent back to the client.
+// that should be sent back to the client. The responseWarnings are added to the client response. The prepareCall
+// (see preparecoalescing.go) is completed when the request finishes, it's nil if no other request waits for it.
 func (ch *ClientHandler) executeRequest(
 	frameContext *frameDecodeContext, requestInfo RequestInfo, currentKeyspace string,
-	overallRequestStartTime time.Time, customResponseChannel chan *customResponse, requestTimeout time.Duration) error {
+	overallRequestStartTime time.Time, customResponseChannel chan *customResponse, requestTimeout time.Duration,
+	responseWarnings []string, prepareCall *prepareCall) error {
+	// the waiting PREPAREs are sent to the clusters if this request is not sent
+	defer func() {
+		ch.prepareCoalescer.complete(prepareCall, nil)
+	}()
+
 	fwdDecision := requestInfo.GetForwardDecision()
 	log.Tracef("Opcode: %v, Forward decision: %v", frameContext.GetRawFrame().Header.OpCode, fwdDecision)
 
@@ -1420,23 +1926,120 @@ func (ch *ClientHandler) executeRequest(
 		if customResponseChannel != nil {
 			customResponseChannel <- &customResponse{aggregatedResponse: clientResponse}
 		} else {
-			ch.clientConnector.sendResponseToClient(clientResponse)
+			ch.sendInterceptedResponseToClient(f, clientResponse)
+		}
+
+		return nil
+	}
+
+	if customResponseChannel == nil {
+		releaseWriteQueues, reserved := ch.reserveWriteQueues(fwdDecision)
+		if !reserved {
+			log.Debugf("Rejecting request with opcode %v for stream %v because the write queue of a cluster connection is full.",
+				f.Header.OpCode, f.Header.StreamId)
+			ch.metricHandler.GetProxyMetrics().WriteQueueOverflowRejectedRequests.Add(1)
+			ch.clientConnector.sendOverloadedMessageToClient(f, "Proxy is overloaded, please retry on next host.")
+			return nil
 		}
+		// the request is enqueued (or rejected) before executeRequest returns
+		defer releaseWriteQueues()
+	}
 
+	// the internal requests (handshakes) have their own timeout, only the client requests have a deadline
+	budget, limitedByDeadline := requestTimeout, false
+	if customResponseChannel == nil {
+		budget, limitedByDeadline = ch.getRequestBudget(overallRequestStartTime, requestTimeout)
+	}
+	if budget <= 0 {
+		log.Debugf("Rejecting request with opcode %v for stream %v because its deadline elapsed before it could be sent.",
+			f.Header.OpCode, f.Header.StreamId)
+		ch.metricHandler.GetProxyMetrics().RequestDeadlineExceeded.Add(1)
+		ch.clientConnector.sendOverloadedMessageToClient(f,
+			"Request deadline of the proxy elapsed before the request could be sent, please retry on next host.")
 		return nil
 	}
 
-	reqCtx := NewRequestContext(f, requestInfo, overallRequestStartTime, customResponseChannel)
+	lwt, lwtErr := ch.lwtHandler.isLwt(frameContext, requestInfo, currentKeyspace, ch.timeUuidGenerator)
+	if lwtErr != nil {
+		log.Warnf("Could not check if request with stream id %v is a lightweight transaction: %v",
+			f.Header.StreamId, lwtErr)
+	}
+	if lwt {
+		originRequest, targetRequest, err = ch.lwtHandler.replaceSerialConsistencies(originRequest, targetRequest)
+		if err != nil {
+			return err
+		}
+	}
+
+	requestFrame := f
+	var hedged bool
+	var hedgedStreamId int16
+	if ch.shouldHedgeRead(requestInfo) {
+		hedgedStreamId, hedged = ch.acquireHedgedReadStreamId()
+	}
+	if hedged {
+		requestFrame = setRawFrameStreamId(f, hedgedStreamId)
+		originRequest = setRawFrameStreamId(originRequest, hedgedStreamId)
+		targetRequest = setRawFrameStreamId(targetRequest, hedgedStreamId)
+	}
+
+	reqCtx := NewRequestContext(requestFrame, requestInfo, overallRequestStartTime, customResponseChannel)
+	reqCtx.SetClusterRequests(originRequest, targetRequest)
+	reqCtx.SetResponseWarnings(responseWarnings)
+	reqCtx.lwt = lwt
+	if customResponseChannel == nil {
+		reqCtx.deadline = ch.getRequestDeadline(overallRequestStartTime)
+	}
+	if ch.shouldCompareAsyncRead(requestInfo, hedged, customResponseChannel) {
+		reqCtx.asyncReadComparison = newAsyncReadComparison(ch.metricHandler.GetProxyMetrics())
+	}
+	if ch.mutationPublisher != nil {
+		exportedMutations, err := buildExportedMutations(
+			frameContext, requestInfo, currentKeyspace, ch.timeUuidGenerator, overallRequestStartTime)
+		if err != nil {
+			log.Warnf("Could not build exported mutations of request with stream id %v: %v", f.Header.StreamId, err)
+		}
+		reqCtx.SetExportedMutations(exportedMutations)
+	}
+	if (ch.heavyHitters != nil || ch.statementMetrics != nil) && requestInfo.ShouldBeTrackedInMetrics() {
+		statement, err := getRequestStatement(frameContext, requestInfo)
+		if err != nil {
+			log.Warnf("Could not get the statement of request with stream id %v for the statement metrics: %v",
+				f.Header.StreamId, err)
+		}
+		reqCtx.SetStatement(statement, ch.heavyHitters, ch.statementMetrics)
+	}
+	if (ch.payloadSizeMetrics != nil || (ch.dualWriteResponsePolicy != nil && fwdDecision == forwardToBoth)) &&
+		requestInfo.ShouldBeTrackedInMetrics() && fwdDecision != forwardToAsyncOnly {
+		stmtType, err := getRequestStatementType(frameContext, requestInfo, currentKeyspace, ch.timeUuidGenerator)
+		if err != nil {
+			log.Warnf("Could not get the statement type of request with stream id %v: %v", f.Header.StreamId, err)
+		}
+		reqCtx.SetStatementType(stmtType, ch.payloadSizeMetrics)
+		if ch.payloadSizeMetrics != nil && (hedged || fwdDecision == forwardToBoth || fwdDecision == forwardToOrigin) {
+			ch.payloadSizeMetrics.recordRequest(common.ClusterTypeOrigin, stmtType, originRequest)
+		}
+		if ch.payloadSizeMetrics != nil && (hedged || fwdDecision == forwardToBoth || fwdDecision == forwardToTarget) {
+			ch.payloadSizeMetrics.recordRequest(common.ClusterTypeTarget, stmtType, targetRequest)
+		}
+	}
 	var contextHoldersMap *sync.Map
 	if fwdDecision == forwardToAsyncOnly {
 		contextHoldersMap = ch.asyncRequestContextHolders // different map because of stream id collision
+	} else if hedged {
+		reqCtx.SetHedged(f.Header.StreamId, hedgedStreamId)
+		contextHoldersMap = ch.hedgedRequestContextHolders
 	} else {
 		contextHoldersMap = ch.requestContextHolders
 	}
 	holder, err := storeRequestContext(contextHoldersMap, reqCtx)
 	if err != nil {
+		if hedged {
+			_, _ = ch.hedgedReadStreamIds.ReleaseId(fromHedgedReadStreamId(hedgedStreamId))
+		}
 		return err
 	}
+	reqCtx.prepareCall, prepareCall = prepareCall, nil
 
 	if requestInfo.ShouldBeTrackedInMetrics() {
 		proxyMetrics := ch.metricHandler.GetProxyMetrics()
@@ -1451,21 +2054,32 @@ func (ch *ClientHandler) executeRequest(
 		default:
 			log.Errorf("unexpected forwardDecision %v, unable to track proxy level metrics", fwdDecision)
 		}
+		if fwdDecision != forwardToAsyncOnly {
+			reqCtx.SetInFlight(ch.nodeMetrics,
+				hedged || fwdDecision == forwardToBoth || fwdDecision == forwardToOrigin,
+				hedged || fwdDecision == forwardToBoth || fwdDecision == forwardToTarget)
+		}
 	}
 
 	ch.clientHandlerRequestWaitGroup.Add(1)
+	if hedged {
+		ch.clientHandlerRequestWaitGroup.Add(1) // released when the stream id of the hedged read is freed
+	}
 	if fwdDecision != forwardToAsyncOnly {
-		timer := time.AfterFunc(requestTimeout, func() {
+		timer := time.AfterFunc(budget, func() {
+			if limitedByDeadline {
+				ch.metricHandler.GetProxyMetrics().RequestDeadlineExceeded.Add(1)
+			}
 			ch.closedRespChannelLock.RLock()
 			defer ch.closedRespChannelLock.RUnlock()
 			if ch.closedRespChannel {
-				finished := reqCtx.SetTimeout(ch.nodeMetrics, f)
+				finished := reqCtx.SetTimeout(ch.nodeMetrics, requestFrame)
 				if finished {
 					ch.finishRequest(holder, reqCtx)
 				}
 				return
 			}
-			ch.respChannel <- NewTimeoutResponse(f, false)
+			ch.respChannel <- NewTimeoutResponse(requestFrame, false)
 		})
 		reqCtx.SetTimer(timer)
 	}
@@ -1477,6 +2091,14 @@ func (ch *ClientHandler) executeRequest(
 		startupFrameVersion = startupFrameInterface.(*frame.RawFrame).Header.Version
 	}
 
+	if hedged {
+		log.Tracef("Forwarding hedged read with opcode %v for stream %v to %v and %v",
+			f.Header.OpCode, f.Header.StreamId, common.ClusterTypeOrigin, common.ClusterTypeTarget)
+		ch.originCassandraConnector.sendRequestToCluster(originRequest)
+		ch.targetCassandraConnector.sendRequestToCluster(targetRequest)
+		return nil
+	}
+
 	sendAlsoToAsync := requestInfo.ShouldAlsoBeSentAsync() && ch.asyncConnector != nil
 	switch fwdDecision {
 	case forwardToBoth:
@@ -1645,10 +2267,11 @@ func (ch *ClientHandler) handleExecuteRequest(
 	}
 
 	sendToAsyncConnector := (castedRequestInfo.ShouldAlsoBeSentAsync() || fwdDecision == forwardToAsyncOnly) && ch.asyncConnector != nil
+	hedged := ch.shouldHedgeRead(castedRequestInfo)
 	replacedTerms := prepareRequestInfo.GetReplacedTerms()
 	asyncConnectorIsOrigin := ch.asyncConnector != nil && ch.asyncConnector.clusterType == common.ClusterTypeOrigin
 	var replacementTimeUuids []*uuid.UUID
-	if len(replacedTerms) > 0 && (fwdDecision == forwardToBoth || fwdDecision == forwardToOrigin || (sendToAsyncConnector && asyncConnectorIsOrigin)) {
+	if len(replacedTerms) > 0 && (fwdDecision == forwardToBoth || fwdDecision == forwardToOrigin || hedged || (sendToAsyncConnector && asyncConnectorIsOrigin)) {
 		clientRequest, err := frameContext.GetOrDecodeFrame()
 		if err != nil {
 			return nil, nil, nil, fmt.Errorf("could not decode execute raw frame: %w", err)
@@ -1671,7 +2294,7 @@ func (ch *ClientHandler) handleExecuteRequest(
 	}
 
 	asyncConnectorIsTarget := ch.asyncConnector != nil && ch.asyncConnector.clusterType == common.ClusterTypeTarget
-	if fwdDecision == forwardToBoth || fwdDecision == forwardToTarget || (sendToAsyncConnector && asyncConnectorIsTarget) {
+	if fwdDecision == forwardToBoth || fwdDecision == forwardToTarget || hedged || (sendToAsyncConnector && asyncConnectorIsTarget) {
 		clientRequest, err := frameContext.GetOrDecodeFrame()
 		if err != nil {
 			return nil, nil, nil, fmt.Errorf("could not decode execute raw frame: %w", err)
@@ -1812,8 +2435,12 @@ func (ch *ClientHandler) sendToAsyncConnector(
 
 	f := frameContext.GetRawFrame()
 
+	var readComparison *asyncReadComparison
+	if isFireAndForget {
+		readComparison = reqCtx.asyncReadComparison
+	}
 	sent := ch.asyncConnector.sendAsyncRequestToCluster(
-		reqCtx.GetRequestInfo(), asyncRequest, !isFireAndForget, overallRequestStartTime, requestTimeout, func() {
+		reqCtx.GetRequestInfo(), asyncRequest, !isFireAndForget, overallRequestStartTime, requestTimeout, readComparison, func() {
 			if !isFireAndForget {
 				ch.closedRespChannelLock.RLock()
 				defer ch.closedRespChannelLock.RUnlock()
@@ -1910,7 +2537,13 @@ func (ch *ClientHandler) aggregateAndTrackResponses(
 
 // Replaces the credentials in the provided auth frame (which are the Target credentials) with
 // the Origin credentials that are provided to the proxy in the configuration.
+// The credentials of each cluster are replaced with the ones of the role mapping if the role of the client is mapped.
 func (ch *ClientHandler) handleClientCredentials(f *frame.RawFrame) (*frame.RawFrame, error) {
+	if ch.conf.OriginAuthPassthroughEnabled {
+		// any SASL mechanism can be used with ORIGIN, the tokens are never parsed
+		return f, nil
+	}
+
 	parsedAuthFrame, err := defaultCodec.ConvertFromRawFrame(f)
 	if err != nil {
 		return nil, fmt.Errorf("could not extract auth credentials from frame to start the secondary handshake: %w", err)
@@ -1985,6 +2618,25 @@ func (ch *ClientHandler) handleClientCredentials(f *frame.RawFrame) (*frame.RawF
 		}
 	}
 
+	primaryCluster, secondaryCluster := common.ClusterTypeOrigin, common.ClusterTypeTarget
+	if ch.forwardAuthToTarget {
+		primaryCluster, secondaryCluster = common.ClusterTypeTarget, common.ClusterTypeOrigin
+	}
+	primaryHandshakeCreds = ch.roleMapping.clusterCredentials(clientCreds, primaryCluster, primaryHandshakeCreds)
+	ch.secondaryHandshakeCreds = ch.roleMapping.clusterCredentials(clientCreds, secondaryCluster, ch.secondaryHandshakeCreds)
+	if ch.asyncConnector != nil {
+		ch.asyncHandshakeCreds = ch.roleMapping.clusterCredentials(
+			clientCreds, ch.asyncConnector.clusterType, ch.asyncHandshakeCreds)
+	}
+
+	if ch.originOnlyFallback != nil {
+		// the secondary connection is opened to Origin so it uses the credentials of the primary handshake
+		ch.secondaryHandshakeCreds = primaryHandshakeCreds
+		if ch.secondaryHandshakeCreds == nil {
+			ch.secondaryHandshakeCreds = clientCreds
+		}
+	}
+
 	if primaryHandshakeCreds == nil {
 		// client credentials don't need to be replaced
 		return f, nil
@@ -2128,7 +2780,12 @@ func trackClusterErrorMetricsFromErrorMessage(
 func forwardAuthToTarget(
 	originControlConn *ControlConn,
 	targetControlConn *ControlConn,
-	forwardClientCredsToOrigin bool) (forwardAuthToTarget bool, targetCredsOnClientRequest bool) {
+	forwardClientCredsToOrigin bool,
+	originAuthPassthrough bool) (forwardAuthToTarget bool, targetCredsOnClientRequest bool) {
+	if originAuthPassthrough {
+		// TARGET gets the configured credentials even if ORIGIN doesn't require authentication
+		return false, false
+	}
 	authEnabledOnOrigin, err := originControlConn.IsAuthEnabled()
 	clusterType := common.ClusterTypeOrigin
 	var authEnabledOnTarget bool
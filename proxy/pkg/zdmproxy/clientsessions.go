@@ -0,0 +1,205 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ClientSession describes an active client connection, see ZdmProxy.GetClientSessions.
+type ClientSession struct {
+	Id     uint64 `json:"id"`
+	Client string `json:"client"`
+	// ProtocolVersion is empty until the handshake is done
+	ProtocolVersion  string    `json:"protocol_version,omitempty"`
+	Keyspace         string    `json:"keyspace,omitempty"`
+	ConnectedAt      time.Time `json:"connected_at"`
+	InFlightRequests int       `json:"in_flight_requests"`
+	BytesReceived    uint64    `json:"bytes_received"`
+	BytesSent        uint64    `json:"bytes_sent"`
+	// the requests that are answered by the proxy itself (e.g. system queries) and PREPARE requests aren't counted
+	Reads          uint64  `json:"reads"`
+	Writes         uint64  `json:"writes"`
+	Errors         uint64  `json:"errors"`
+	TotalLatencyMs float64 `json:"total_latency_ms"`
+	// Closing is true while the connection is drained, see ZdmProxy.CloseClientSessions
+	Closing bool `json:"closing,omitempty"`
+}
+
+// clientSessionRegistry keeps the client handlers of the open client connections so that operators can see from the
+// admin API which applications are still connected to the proxy, e.g. before decommissioning it. A client handler is
+// removed as soon as it starts shutting down.
+type clientSessionRegistry struct {
+	lock     *sync.Mutex
+	handlers map[uint64]*ClientHandler
+	nextId   uint64
+}
+
+func newClientSessionRegistry() *clientSessionRegistry {
+	return &clientSessionRegistry{
+		lock:     &sync.Mutex{},
+		handlers: map[uint64]*ClientHandler{},
+	}
+}
+
+func (r *clientSessionRegistry) register(ch *ClientHandler) {
+	r.lock.Lock()
+	r.nextId++
+	ch.sessionId = r.nextId
+	r.handlers[ch.sessionId] = ch
+	r.lock.Unlock()
+
+	go func() {
+		<-ch.clientHandlerContext.Done()
+		r.lock.Lock()
+		delete(r.handlers, ch.sessionId)
+		r.lock.Unlock()
+	}()
+}
+
+// list returns the sessions sorted by the time the connections were opened.
+func (r *clientSessionRegistry) list() []*ClientSession {
+	r.lock.Lock()
+	handlers := make([]*ClientHandler, 0, len(r.handlers))
+	for _, ch := range r.handlers {
+		handlers = append(handlers, ch)
+	}
+	r.lock.Unlock()
+
+	sessions := make([]*ClientSession, 0, len(handlers))
+	for _, ch := range handlers {
+		sessions = append(sessions, ch.getSession())
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		if sessions[i].ConnectedAt.Equal(sessions[j].ConnectedAt) {
+			return sessions[i].Id < sessions[j].Id
+		}
+		return sessions[i].ConnectedAt.Before(sessions[j].ConnectedAt)
+	})
+	return sessions
+}
+
+// close drains the client connection of the session with the given id or the connections of the given client IP and
+// returns the sessions that were affected.
+func (r *clientSessionRegistry) close(idOrIp string) []*ClientSession {
+	id, err := strconv.ParseUint(idOrIp, 10, 64)
+	if err != nil {
+		id = 0
+	}
+	ip := net.ParseIP(idOrIp)
+	r.lock.Lock()
+	var handlers []*ClientHandler
+	for _, ch := range r.handlers {
+		if ch.sessionId == id || (ip != nil && ip.Equal(getRemoteIp(ch.clientConnection))) {
+			handlers = append(handlers, ch)
+		}
+	}
+	r.lock.Unlock()
+
+	sessions := make([]*ClientSession, 0, len(handlers))
+	for _, ch := range handlers {
+		log.Infof("Closing client connection %v (session %d) as requested by the admin API.",
+			ch.clientConnection.RemoteAddr(), ch.sessionId)
+		// same drain as the one of the proxy shutdown, see ClientConnector.listenForRequests
+		ch.clientHandlerShutdownRequestCancelFn()
+		sessions = append(sessions, ch.getSession())
+	}
+	return sessions
+}
+
+func (ch *ClientHandler) getSession() *ClientSession {
+	session := &ClientSession{
+		Id:               ch.sessionId,
+		Client:           ch.clientConnection.RemoteAddr().String(),
+		Keyspace:         ch.LoadCurrentKeyspace(),
+		ConnectedAt:      ch.connectedAt,
+		InFlightRequests: countPendingRequests(ch.requestContextHolders) + countPendingRequests(ch.hedgedRequestContextHolders),
+		BytesReceived:    atomic.LoadUint64(&ch.clientConnection.bytesRead),
+		BytesSent:        atomic.LoadUint64(&ch.clientConnection.bytesWritten),
+		Closing:          ch.clientHandlerShutdownRequestContext.Err() != nil,
+		Reads:            atomic.LoadUint64(&ch.queryStats.reads),
+		Writes:           atomic.LoadUint64(&ch.queryStats.writes),
+		Errors:           atomic.LoadUint64(&ch.queryStats.errors),
+		TotalLatencyMs:   float64(atomic.LoadInt64(&ch.queryStats.totalLatencyNanos)) / float64(time.Millisecond),
+	}
+	if version := atomic.LoadInt32(&ch.clientProtocolVersion); version != 0 {
+		session.ProtocolVersion = primitive.ProtocolVersion(version).String()
+	}
+	return session
+}
+
+func getRemoteIp(conn net.Conn) net.IP {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return nil
+	}
+	return net.ParseIP(host)
+}
+
+func countPendingRequests(contextHoldersMap *sync.Map) int {
+	count := 0
+	contextHoldersMap.Range(func(_, value interface{}) bool {
+		if value.(*requestContextHolder).Get() != nil {
+			count++
+		}
+		return true
+	})
+	return count
+}
+
+// sessionQueryStats counts the reads and writes of a client connection like the proxy level metrics do, so that the
+// application instances that fail or slow down the most can be found in the session inspection.
+type sessionQueryStats struct {
+	reads             uint64
+	writes            uint64
+	errors            uint64
+	totalLatencyNanos int64
+}
+
+// track records a request that was answered with the given response, nil if it was canceled (e.g. it timed out).
+func (recv *sessionQueryStats) track(reqCtx *requestContextImpl, response *frame.RawFrame) {
+	if !reqCtx.requestInfo.ShouldBeTrackedInMetrics() {
+		return
+	}
+	switch reqCtx.requestInfo.GetForwardDecision() {
+	case forwardToBoth:
+		atomic.AddUint64(&recv.writes, 1)
+	case forwardToOrigin, forwardToTarget:
+		atomic.AddUint64(&recv.reads, 1)
+	default:
+		return
+	}
+	if response == nil || !isResponseSuccessful(response) {
+		atomic.AddUint64(&recv.errors, 1)
+	}
+	atomic.AddInt64(&recv.totalLatencyNanos, int64(time.Since(reqCtx.startTime)))
+}
+
+// countingConn counts the bytes that are read from and written to a client connection.
+type countingConn struct {
+	net.Conn
+	bytesRead    uint64
+	bytesWritten uint64
+}
+
+func newCountingConn(conn net.Conn) *countingConn {
+	return &countingConn{Conn: conn}
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	atomic.AddUint64(&c.bytesRead, uint64(n))
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddUint64(&c.bytesWritten, uint64(n))
+	return n, err
+}
@@ -0,0 +1,167 @@
+package zdmproxy
+
+import (
+	"context"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCountingConn(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	conn := newCountingConn(server)
+
+	go func() {
+		_, _ = client.Write([]byte("hello"))
+	}()
+	buf := make([]byte, 16)
+	n, err := conn.Read(buf)
+	require.Nil(t, err)
+	require.Equal(t, 5, n)
+
+	go func() {
+		_, _ = client.Read(buf)
+	}()
+	_, err = conn.Write([]byte("hi"))
+	require.Nil(t, err)
+
+	require.Equal(t, uint64(5), atomic.LoadUint64(&conn.bytesRead))
+	require.Equal(t, uint64(2), atomic.LoadUint64(&conn.bytesWritten))
+}
+
+func TestClientSessionRegistry(t *testing.T) {
+	registry := newClientSessionRegistry()
+	now := time.Now()
+
+	second, cancelSecond := newTestSessionHandler(t, now, nil)
+	first, cancelFirst := newTestSessionHandler(t, now.Add(-time.Minute), nil)
+	defer cancelFirst()
+	registry.register(second)
+	registry.register(first)
+
+	first.currentKeyspaceName.Store("ks1")
+	atomic.StoreInt32(&first.clientProtocolVersion, 4)
+	holder := getOrCreateRequestContextHolder(first.requestContextHolders, 1)
+	require.Nil(t, holder.SetIfEmpty(NewRequestContext(nil, nil, time.Now(), nil)))
+	getOrCreateRequestContextHolder(first.requestContextHolders, 2)
+
+	sessions := registry.list()
+	require.Equal(t, 2, len(sessions))
+	require.Equal(t, first.sessionId, sessions[0].Id)
+	require.Equal(t, "ks1", sessions[0].Keyspace)
+	require.Equal(t, "ProtocolVersion OSS 4", sessions[0].ProtocolVersion)
+	require.Equal(t, 1, sessions[0].InFlightRequests)
+	require.Equal(t, second.sessionId, sessions[1].Id)
+	require.Equal(t, "", sessions[1].ProtocolVersion)
+	require.Equal(t, 0, sessions[1].InFlightRequests)
+
+	cancelSecond()
+	require.Eventually(t, func() bool {
+		return len(registry.list()) == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestClientSessionRegistry_Close(t *testing.T) {
+	registry := newClientSessionRegistry()
+	now := time.Now()
+
+	handlers := []*ClientHandler{
+		newTestSessionHandlerWithAddr(t, now, "10.0.0.1:1000"),
+		newTestSessionHandlerWithAddr(t, now, "10.0.0.1:1001"),
+		newTestSessionHandlerWithAddr(t, now, "10.0.0.2:1000"),
+	}
+	for _, ch := range handlers {
+		registry.register(ch)
+	}
+
+	require.Empty(t, registry.close("10.0.0.3"))
+	require.Empty(t, registry.close("42"))
+	for _, ch := range handlers {
+		require.Nil(t, ch.clientHandlerShutdownRequestContext.Err())
+	}
+
+	sessions := registry.close(strconv.FormatUint(handlers[2].sessionId, 10))
+	require.Len(t, sessions, 1)
+	require.Equal(t, "10.0.0.2:1000", sessions[0].Client)
+	require.True(t, sessions[0].Closing)
+	require.NotNil(t, handlers[2].clientHandlerShutdownRequestContext.Err())
+	require.Nil(t, handlers[0].clientHandlerShutdownRequestContext.Err())
+
+	sessions = registry.close("10.0.0.1")
+	require.Len(t, sessions, 2)
+	require.NotNil(t, handlers[0].clientHandlerShutdownRequestContext.Err())
+	require.NotNil(t, handlers[1].clientHandlerShutdownRequestContext.Err())
+}
+
+func TestSessionQueryStats(t *testing.T) {
+	success := mustEncodeResponse(t, primitive.ProtocolVersion4, &message.VoidResult{})
+	failure := mustEncodeResponse(t, primitive.ProtocolVersion4, &message.Overloaded{ErrorMessage: "overloaded"})
+	startTime := time.Now().Add(-10 * time.Millisecond)
+	newRequest := func(decision forwardDecision, trackMetrics bool) *requestContextImpl {
+		return NewRequestContext(nil, NewGenericRequestInfo(decision, false, trackMetrics), startTime, nil)
+	}
+
+	stats := &sessionQueryStats{}
+	stats.track(newRequest(forwardToOrigin, true), success)
+	stats.track(newRequest(forwardToTarget, true), failure)
+	stats.track(newRequest(forwardToBoth, true), success)
+	stats.track(newRequest(forwardToBoth, true), nil)
+	stats.track(newRequest(forwardToOrigin, false), failure)
+	stats.track(newRequest(forwardToNone, true), failure)
+
+	require.Equal(t, uint64(2), stats.reads)
+	require.Equal(t, uint64(2), stats.writes)
+	require.Equal(t, uint64(2), stats.errors)
+	require.GreaterOrEqual(t, stats.totalLatencyNanos, int64(40*time.Millisecond))
+}
+
+func newTestSessionHandlerWithAddr(t *testing.T, connectedAt time.Time, remoteAddr string) *ClientHandler {
+	addr, err := net.ResolveTCPAddr("tcp", remoteAddr)
+	require.Nil(t, err)
+	ch, cancel := newTestSessionHandler(t, connectedAt, addr)
+	t.Cleanup(cancel)
+	return ch
+}
+
+func newTestSessionHandler(t *testing.T, connectedAt time.Time, remoteAddr net.Addr) (*ClientHandler, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	shutdownRequestCtx, shutdownRequestCancelFn := context.WithCancel(ctx)
+	client, server := net.Pipe()
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	var conn net.Conn = server
+	if remoteAddr != nil {
+		conn = &remoteAddrConn{Conn: server, remoteAddr: remoteAddr}
+	}
+	ch := &ClientHandler{
+		clientHandlerContext:                 ctx,
+		clientHandlerShutdownRequestContext:  shutdownRequestCtx,
+		clientHandlerShutdownRequestCancelFn: shutdownRequestCancelFn,
+		clientConnection:                     newCountingConn(conn),
+		connectedAt:                          connectedAt,
+		currentKeyspaceName:                  &atomic.Value{},
+		requestContextHolders:                &sync.Map{},
+		hedgedRequestContextHolders:          &sync.Map{},
+		queryStats:                           &sessionQueryStats{},
+	}
+	return ch, cancel
+}
+
+type remoteAddrConn struct {
+	net.Conn
+	remoteAddr net.Addr
+}
+
+func (c *remoteAddrConn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
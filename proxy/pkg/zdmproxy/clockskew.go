@@ -0,0 +1,134 @@
+package zdmproxy
+
+import (
+	"context"
+	"fmt"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+// the now() TIMEUUID is generated from the clock of the coordinator, which is also the clock of the server side write
+// timestamps
+const clockProbeQuery = "SELECT now() AS probe_time FROM system.local"
+
+// clockSkewMonitor compares the clocks of the clusters every ZDM_CLOCK_SKEW_CHECK_INTERVAL_MS when
+// ZDM_CLOCK_SKEW_CHECK_ENABLED is set. The dual writes rely on last-write-wins: a write that carries a server side
+// timestamp can be applied on one cluster and silently lose against an older write on the other one if their clocks
+// are skewed, so the data never converges.
+//
+// Each probe reads the time of the node of the control connection of both clusters. The offset of a node is relative to
+// the middle of the round trip of the probe, half of the round trip is its uncertainty. A skew is only reported when it
+// exceeds ZDM_CLOCK_SKEW_THRESHOLD_MS by more than the uncertainty of both probes.
+type clockSkewMonitor struct {
+	originQueryFn func(cql string, ctx context.Context) (*ParsedRowSet, error)
+	targetQueryFn func(cql string, ctx context.Context) (*ParsedRowSet, error)
+	config        *common.ClockSkewCheckConfig
+	proxyMetrics  *metrics.ProxyMetrics
+	now           func() time.Time
+
+	cancelFn context.CancelFunc
+	wg       *sync.WaitGroup
+}
+
+func newClockSkewMonitor(
+	originQueryFn func(cql string, ctx context.Context) (*ParsedRowSet, error),
+	targetQueryFn func(cql string, ctx context.Context) (*ParsedRowSet, error),
+	config *common.ClockSkewCheckConfig, proxyMetrics *metrics.ProxyMetrics) *clockSkewMonitor {
+	return &clockSkewMonitor{
+		originQueryFn: originQueryFn,
+		targetQueryFn: targetQueryFn,
+		config:        config,
+		proxyMetrics:  proxyMetrics,
+		now:           time.Now,
+		cancelFn:      func() {},
+		wg:            &sync.WaitGroup{},
+	}
+}
+
+func (m *clockSkewMonitor) Start() {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	m.cancelFn = cancelFn
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(m.config.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := m.probe(ctx)
+				if err != nil && ctx.Err() == nil {
+					log.Warnf("Could not compare the clocks of the clusters: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+func (m *clockSkewMonitor) Close() {
+	m.cancelFn()
+	m.wg.Wait()
+}
+
+// probe measures the clock offset of both clusters and records the skew of TARGET relative to ORIGIN.
+func (m *clockSkewMonitor) probe(ctx context.Context) error {
+	originOffset, originUncertainty, err := m.measureOffset(m.originQueryFn, ctx)
+	if err != nil {
+		return fmt.Errorf("could not probe the clock of %v: %w", common.ClusterTypeOrigin, err)
+	}
+	targetOffset, targetUncertainty, err := m.measureOffset(m.targetQueryFn, ctx)
+	if err != nil {
+		return fmt.Errorf("could not probe the clock of %v: %w", common.ClusterTypeTarget, err)
+	}
+	skew := targetOffset - originOffset
+	m.proxyMetrics.ClusterClockOffsetOrigin.Set(int(originOffset.Milliseconds()))
+	m.proxyMetrics.ClusterClockOffsetTarget.Set(int(targetOffset.Milliseconds()))
+	m.proxyMetrics.ClusterClockSkew.Set(int(skew.Milliseconds()))
+
+	absSkew := skew
+	if absSkew < 0 {
+		absSkew = -absSkew
+	}
+	uncertainty := originUncertainty + targetUncertainty
+	if absSkew-uncertainty > m.config.Threshold {
+		m.proxyMetrics.ClusterClockSkewExceeded.Add(1)
+		log.Warnf("The clock of %v is %v ahead of the clock of %v (+/- %v), which exceeds the threshold of %v. "+
+			"The dual writes that rely on server side timestamps may not converge.",
+			common.ClusterTypeTarget, skew, common.ClusterTypeOrigin, uncertainty, m.config.Threshold)
+	} else {
+		log.Debugf("The clock of %v is %v ahead of the clock of %v (+/- %v).",
+			common.ClusterTypeTarget, skew, common.ClusterTypeOrigin, uncertainty)
+	}
+	return nil
+}
+
+// measureOffset returns the offset of the clock of a cluster node relative to the clock of the proxy and its
+// uncertainty.
+func (m *clockSkewMonitor) measureOffset(
+	queryFn func(cql string, ctx context.Context) (*ParsedRowSet, error),
+	ctx context.Context) (time.Duration, time.Duration, error) {
+	sentAt := m.now()
+	rowSet, err := queryFn(clockProbeQuery, ctx)
+	receivedAt := m.now()
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(rowSet.Rows) == 0 {
+		return 0, 0, fmt.Errorf("system.local returned no rows")
+	}
+	probeTime, _, err := parseNillableUuid(rowSet.Rows[0], "probe_time")
+	if err != nil {
+		return 0, 0, err
+	}
+	if probeTime == nil || probeTime.Version() != 1 {
+		return 0, 0, fmt.Errorf("expected a TIMEUUID but got %v", probeTime)
+	}
+	sec, nsec := probeTime.Time().UnixTime()
+	halfRoundTrip := receivedAt.Sub(sentAt) / 2
+	return time.Unix(sec, nsec).Sub(sentAt.Add(halfRoundTrip)), halfRoundTrip, nil
+}
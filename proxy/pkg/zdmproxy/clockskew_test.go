@@ -0,0 +1,122 @@
+package zdmproxy
+
+import (
+	"context"
+	"errors"
+	"github.com/datastax/go-cassandra-native-protocol/datatype"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func newClockProbeRowSet(nodeTime time.Time) *ParsedRowSet {
+	columnIndexes := map[string]int{"probe_time": 0}
+	columns := []*message.ColumnMetadata{{Name: "probe_time", Type: datatype.Timeuuid}}
+	probeTime := newTimeUuid((&timeUuidGeneratorImpl{}).getTime(nodeTime.UTC()), 0, [6]byte{})
+	return &ParsedRowSet{
+		ColumnIndexes: columnIndexes,
+		Columns:       columns,
+		Rows:          []*ParsedRow{NewParsedRow(columnIndexes, columns, []interface{}{primitive.UUID(probeTime)})},
+	}
+}
+
+func TestClockSkewMonitor_Probe(t *testing.T) {
+	base := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name             string
+		skew             time.Duration
+		expectedSkewMs   int
+		expectedExceeded int
+	}{
+		{name: "below threshold", skew: 50 * time.Millisecond, expectedSkewMs: 50, expectedExceeded: 0},
+		{name: "target ahead", skew: 200 * time.Millisecond, expectedSkewMs: 200, expectedExceeded: 1},
+		{name: "target behind", skew: -200 * time.Millisecond, expectedSkewMs: -200, expectedExceeded: 1},
+		// the round trips of both probes add 10ms of uncertainty
+		{name: "within uncertainty", skew: 105 * time.Millisecond, expectedSkewMs: 105, expectedExceeded: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxyMetrics := newFakeProxyMetrics()
+			originOffset, targetOffset, skew, exceeded := &countingGauge{}, &countingGauge{}, &countingGauge{}, &countingGauge{}
+			proxyMetrics.ClusterClockOffsetOrigin, proxyMetrics.ClusterClockOffsetTarget = originOffset, targetOffset
+			proxyMetrics.ClusterClockSkew, proxyMetrics.ClusterClockSkewExceeded = skew, exceeded
+
+			// every round trip takes 10ms, ORIGIN is probed first and its clock matches the clock of the proxy
+			var queries []string
+			monitor := newClockSkewMonitor(
+				func(cql string, _ context.Context) (*ParsedRowSet, error) {
+					queries = append(queries, cql)
+					return newClockProbeRowSet(base.Add(5 * time.Millisecond)), nil
+				},
+				func(cql string, _ context.Context) (*ParsedRowSet, error) {
+					queries = append(queries, cql)
+					return newClockProbeRowSet(base.Add(25 * time.Millisecond).Add(tt.skew)), nil
+				},
+				&common.ClockSkewCheckConfig{Interval: time.Minute, Threshold: 100 * time.Millisecond}, proxyMetrics)
+			calls := 0
+			monitor.now = func() time.Time {
+				now := base.Add(time.Duration(calls) * 10 * time.Millisecond)
+				calls++
+				return now
+			}
+
+			require.Nil(t, monitor.probe(context.Background()))
+			require.Equal(t, []string{clockProbeQuery, clockProbeQuery}, queries)
+			require.Equal(t, 0, originOffset.value)
+			require.Equal(t, tt.expectedSkewMs, targetOffset.value)
+			require.Equal(t, tt.expectedSkewMs, skew.value)
+			require.Equal(t, tt.expectedExceeded, exceeded.value)
+		})
+	}
+}
+
+func TestClockSkewMonitor_ProbeErrors(t *testing.T) {
+	validQueryFn := func(cql string, _ context.Context) (*ParsedRowSet, error) {
+		return newClockProbeRowSet(time.Now()), nil
+	}
+	tests := []struct {
+		name          string
+		targetQueryFn func(cql string, ctx context.Context) (*ParsedRowSet, error)
+		expectedError string
+	}{
+		{
+			name: "query error",
+			targetQueryFn: func(cql string, _ context.Context) (*ParsedRowSet, error) {
+				return nil, errors.New("connection closed")
+			},
+			expectedError: "could not probe the clock of TARGET: connection closed",
+		},
+		{
+			name: "no rows",
+			targetQueryFn: func(cql string, _ context.Context) (*ParsedRowSet, error) {
+				return &ParsedRowSet{}, nil
+			},
+			expectedError: "could not probe the clock of TARGET: system.local returned no rows",
+		},
+		{
+			name: "random uuid",
+			targetQueryFn: func(cql string, _ context.Context) (*ParsedRowSet, error) {
+				rowSet := newClockProbeRowSet(time.Now())
+				rowSet.Rows[0].Values[0] = primitive.UUID{0, 0, 0, 0, 0, 0, 0x40}
+				return rowSet, nil
+			},
+			expectedError: "could not probe the clock of TARGET: expected a TIMEUUID but got 00000000-0000-4000-0000-000000000000",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proxyMetrics := newFakeProxyMetrics()
+			skew := &countingGauge{value: -1}
+			proxyMetrics.ClusterClockSkew = skew
+			monitor := newClockSkewMonitor(validQueryFn, tt.targetQueryFn,
+				&common.ClockSkewCheckConfig{Interval: time.Minute, Threshold: 100 * time.Millisecond}, proxyMetrics)
+			err := monitor.probe(context.Background())
+			require.NotNil(t, err)
+			require.Equal(t, tt.expectedError, err.Error())
+			require.Equal(t, -1, skew.value)
+		})
+	}
+}
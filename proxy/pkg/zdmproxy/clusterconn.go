@@ -11,6 +11,7 @@ import (
 	"github.com/datastax/go-cassandra-native-protocol/primitive"
 	"github.com/datastax/zdm-proxy/proxy/pkg/common"
 	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"github.com/datastax/zdm-proxy/proxy/pkg/errorreporting"
 	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
 	log "github.com/sirupsen/logrus"
 	"io"
@@ -75,6 +76,23 @@ type ClusterConnector struct {
 
 	lastHeartbeatTime *atomic.Value
 	lastHeartbeatLock sync.Mutex
+
+	// used to detect connections that stopped receiving responses, see watchdog.go
+	pendingResponses int32
+	lastProgressTime *atomic.Value
+
+	// forwards large responses to the client while they are read from the connection, see responsestreaming.go.
+	// It's nil when streaming is disabled.
+	responseStreamer responseStreamer
+
+	// nil unless the proxy compresses the frames of this connection, see connectioncompression.go
+	compression *connectionCompression
+
+	// counts the goroutines of this connector, see goroutineaudit.go
+	goroutines *handlerGoroutines
+
+	// set to 1 when the connection was lost while the client handler was running, see connectionloss.go
+	connectionLost int32
 }
 
 func NewClusterConnectionInfo(connConfig ConnectionConfig, endpointConfig Endpoint, isOriginCassandra bool) *ClusterConnectionInfo {
@@ -101,7 +119,9 @@ func NewClusterConnector(
 	asyncConnector bool,
 	asyncPendingRequests *pendingRequests,
 	handshakeDone *atomic.Value,
-	frameProcessor FrameProcessor) (*ClusterConnector, error) {
+	frameProcessor FrameProcessor,
+	compression common.ConnectionCompression,
+	goroutines *handlerGoroutines) (*ClusterConnector, error) {
 
 	var connectorType ClusterConnectorType
 	var clusterType common.ClusterType
@@ -129,14 +149,14 @@ func NewClusterConnector(
 
 	clusterConnCtx, clusterConnCancelFn := context.WithCancel(clientHandlerContext)
 
-	go func() {
+	goroutines.start(func() {
 		select {
 		case <-requestsDoneCtx.Done():
 			clusterConnCancelFn()
 		case <-clusterConnCtx.Done():
 		}
 		closeConnectionToCluster(conn, clusterType, connectorType, nodeMetrics)
-	}()
+	})
 
 	cancelFn := clusterConnCancelFn
 	var clusterConnEventsChan chan *frame.RawFrame
@@ -149,6 +169,9 @@ func NewClusterConnector(
 	lastHeartbeatTime := &atomic.Value{}
 	lastHeartbeatTime.Store(time.Now())
 
+	lastProgressTime := &atomic.Value{}
+	lastProgressTime.Store(time.Now())
+
 	return &ClusterConnector{
 		conf:                   conf,
 		connection:             conn,
@@ -170,7 +193,8 @@ func NewClusterConnector(
 			string(connectorType),
 			true,
 			asyncConnector,
-			writeScheduler),
+			writeScheduler,
+			goroutines),
 		responseChan:                responseChan,
 		frameProcessor:              frameProcessor,
 		responseReadBufferSizeBytes: conf.ResponseReadBufferSizeBytes,
@@ -181,12 +205,18 @@ func NewClusterConnector(
 		asyncPendingRequests:        asyncPendingRequests,
 		handshakeDone:               handshakeDone,
 		lastHeartbeatTime:           lastHeartbeatTime,
+		lastProgressTime:            lastProgressTime,
+		compression:                 newConnectionCompression(compression),
+		goroutines:                  goroutines,
 	}, nil
 }
 
 func (cc *ClusterConnector) run() {
 	cc.runResponseListeningLoop()
 	cc.writeCoalescer.RunWriteQueueLoop()
+	if cc.conf.ProxyStuckConnectionTimeoutMs > 0 {
+		cc.runWatchdog(time.Duration(cc.conf.ProxyStuckConnectionTimeoutMs) * time.Millisecond)
+	}
 }
 
 func openConnectionToCluster(connInfo *ClusterConnectionInfo, context context.Context, connectorType ClusterConnectorType, nodeMetrics *metrics.NodeMetrics) (net.Conn, context.Context, error) {
@@ -232,8 +262,9 @@ func (cc *ClusterConnector) runResponseListeningLoop() {
 
 	cc.clientHandlerWg.Add(1)
 	log.Debugf("[%s] Listening to replies sent by node %v", cc.connectorType, cc.connection.RemoteAddr())
-	go func() {
+	cc.goroutines.start(func() {
 		defer cc.clientHandlerWg.Done()
+		defer errorreporting.ReportPanic()
 		if cc.clusterConnEventsChan != nil {
 			defer close(cc.clusterConnEventsChan)
 		}
@@ -246,10 +277,21 @@ func (cc *ClusterConnector) runResponseListeningLoop() {
 		defer wg.Wait()
 		protocolErrOccurred := false
 		for {
-			response, err := readRawFrame(bufferedReader, connectionAddr, cc.clusterConnContext)
-			protocolErrResponseFrame, err, errCode := checkProtocolError(response, err, protocolErrOccurred, string(cc.connectorType))
+			response, idReleased, err := cc.readOrStreamResponse(bufferedReader, connectionAddr, !protocolErrOccurred)
+			if err == nil && response == nil {
+				// the response was streamed to the client
+				continue
+			}
+			if err == nil {
+				response, err = cc.compression.decompressResponse(response)
+			}
+			protocolErrResponseFrame, err, errCode := checkProtocolError(
+				response, err, protocolErrOccurred, 0, string(cc.connectorType))
 
 			if err != nil {
+				if !errors.Is(err, ShutdownErr) {
+					cc.markConnectionLost()
+				}
 				handleConnectionError(
 					err, cc.clusterConnContext, cc.cancelFunc, string(cc.connectorType), "reading", connectionAddr)
 				break
@@ -262,11 +304,14 @@ func (cc *ClusterConnector) runResponseListeningLoop() {
 					protocolErrOccurred = true
 				}
 			}
+			if response != nil {
+				cc.trackReceivedFrame(response.Header.OpCode != primitive.OpCodeEvent)
+			}
 
 			// when there's a protocol error, we cannot rely on the returned stream id, the only exception is
 			// when it's a UnsupportedVersion error, which means the Frame was properly parsed by the native protocol library
 			// but the proxy doesn't support the protocol version and in that case we can proceed with releasing the stream id in the mapper
-			if response != nil && response.Header.StreamId >= 0 && (err == nil || errCode == ProtocolErrorUnsupportedVersion) {
+			if !idReleased && response != nil && response.Header.StreamId >= 0 && (err == nil || errCode == ProtocolErrorUnsupportedVersion) {
 				var releaseErr error
 				response, releaseErr = cc.frameProcessor.ReleaseId(response)
 				if releaseErr != nil {
@@ -287,7 +332,7 @@ func (cc *ClusterConnector) runResponseListeningLoop() {
 			}
 
 			wg.Add(1)
-			cc.readScheduler.Schedule(func() {
+			scheduleFrameTask(cc.readScheduler, response.Header, func() {
 				defer wg.Done()
 				log.Tracef("[%s] Received response from %v (%v): %v",
 					cc.connectorType, cc.clusterType, connectionAddr, response.Header)
@@ -308,7 +353,7 @@ func (cc *ClusterConnector) runResponseListeningLoop() {
 			})
 		}
 		log.Debugf("[%s] Shutting down response listening loop from %v", cc.connectorType, connectionAddr)
-	}()
+	})
 }
 
 func (cc *ClusterConnector) handleAsyncResponse(response *frame.RawFrame) *frame.RawFrame {
@@ -343,6 +388,9 @@ func (cc *ClusterConnector) handleAsyncResponse(response *frame.RawFrame) *frame
 			response.Header.StreamId = typedReqCtx.requestStreamId
 			return response
 		} else {
+			if _, unprepared := errMsg.(*message.Unprepared); !unprepared {
+				typedReqCtx.readComparison.setAsyncResponse(response)
+			}
 			callDone := true
 			if errMsg != nil {
 				if reqCtx.GetRequestInfo().ShouldBeTrackedInMetrics() {
@@ -373,6 +421,7 @@ func (cc *ClusterConnector) handleAsyncResponse(response *frame.RawFrame) *frame
 							sent := cc.sendAsyncRequestToCluster(
 								preparedData.GetPrepareRequestInfo(), prepareRawFrame, false, time.Now(),
 								time.Duration(cc.conf.ProxyRequestTimeoutMs)*time.Millisecond,
+								nil,
 								func() {
 									cc.clientHandlerRequestWg.Done()
 								})
@@ -403,7 +452,8 @@ func (cc *ClusterConnector) sendRequestToCluster(frame *frame.RawFrame) {
 		log.Errorf("[%v] Couldn't assign stream id to frame %v: %v", string(cc.connectorType), frame.Header.OpCode, err)
 		return
 	} else {
-		cc.writeCoalescer.Enqueue(frame)
+		cc.trackPendingResponse()
+		cc.writeCoalescer.Enqueue(cc.compressRequest(frame))
 	}
 }
 
@@ -466,12 +516,14 @@ func (cc *ClusterConnector) sendAsyncRequestToCluster(
 	expectedResponse bool,
 	overallRequestStartTime time.Time,
 	requestTimeout time.Duration,
+	readComparison *asyncReadComparison,
 	onTimeout func()) bool {
 
 	if !cc.validateAsyncStateForRequest(asyncRequest) {
 		return false
 	}
 	asyncReqCtx := NewAsyncRequestContext(requestInfo, asyncRequest.Header.StreamId, expectedResponse, overallRequestStartTime)
+	asyncReqCtx.readComparison = readComparison
 
 	var err error
 	asyncRequest, err = cc.frameProcessor.AssignUniqueId(asyncRequest)
@@ -501,7 +553,9 @@ func (cc *ClusterConnector) sendAsyncRequestToCluster(
 	if err == nil {
 		log.Tracef("Forwarding ASYNC request with opcode %v for stream %v to %v",
 			asyncRequest.Header.OpCode, asyncRequest.Header.StreamId, cc.clusterType)
-		if !cc.writeCoalescer.EnqueueAsync(asyncRequest) {
+		cc.trackPendingResponse()
+		if !cc.writeCoalescer.EnqueueAsync(cc.compressRequest(asyncRequest)) {
+			cc.releasePendingResponse()
 			err = errors.New("async request was not sent")
 		}
 	}
@@ -0,0 +1,130 @@
+package zdmproxy
+
+import (
+	"errors"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+type ClusterHealthState string
+
+const (
+	// ClusterHealthUp means that the control connection is open and the last heartbeat succeeded.
+	ClusterHealthUp = ClusterHealthState("UP")
+	// ClusterHealthDegraded means that the control connection failed less than ZDM_HEARTBEAT_FAILURE_THRESHOLD times
+	// in a row.
+	ClusterHealthDegraded = ClusterHealthState("DEGRADED")
+	// ClusterHealthDown means that the control connection failed at least ZDM_HEARTBEAT_FAILURE_THRESHOLD times in a
+	// row or that it was never opened.
+	ClusterHealthDown = ClusterHealthState("DOWN")
+	// ClusterHealthAuthFailing means that the cluster rejected the credentials of the proxy the last time the control
+	// connection was opened.
+	ClusterHealthAuthFailing = ClusterHealthState("AUTH_FAILING")
+)
+
+// The reasons of the transitions of the health state of a cluster, they are the reason label of
+// cluster_health_transitions_total.
+const (
+	clusterHealthReasonNotConnected       = "not_connected"
+	clusterHealthReasonConnected          = "connected"
+	clusterHealthReasonHeartbeatSucceeded = "heartbeat_succeeded"
+	clusterHealthReasonConnectionFailed   = "connection_failed"
+	clusterHealthReasonHeartbeatFailed    = "heartbeat_failed"
+	clusterHealthReasonAuthFailed         = "auth_failed"
+)
+
+func clusterHealthStateNames() []string {
+	return []string{
+		string(ClusterHealthUp), string(ClusterHealthDegraded), string(ClusterHealthDown), string(ClusterHealthAuthFailing)}
+}
+
+// ClusterHealth is the health state of a cluster and the reason of the last transition.
+type ClusterHealth struct {
+	State  ClusterHealthState
+	Reason string
+	Since  time.Time
+}
+
+// clusterHealth is the health state machine of a cluster, it's driven by the outcome of the attempts to open the
+// control connection and of its heartbeats.
+type clusterHealth struct {
+	clusterType      common.ClusterType
+	failureThreshold int
+	lock             *sync.RWMutex
+	current          ClusterHealth
+	metrics          *metrics.ClusterHealthMetrics
+	listener         func(health ClusterHealth)
+}
+
+func newClusterHealth(
+	clusterType common.ClusterType, failureThreshold int,
+	healthMetrics *metrics.ClusterHealthMetrics) *clusterHealth {
+	return &clusterHealth{
+		clusterType:      clusterType,
+		failureThreshold: failureThreshold,
+		lock:             &sync.RWMutex{},
+		current:          ClusterHealth{State: ClusterHealthDown, Reason: clusterHealthReasonNotConnected, Since: time.Now()},
+		metrics:          healthMetrics,
+	}
+}
+
+func (h *clusterHealth) get() ClusterHealth {
+	h.lock.RLock()
+	defer h.lock.RUnlock()
+	return h.current
+}
+
+// setListener sets the function that is called after each state change, it's called while the failure counter lock of
+// the control connection is held so it must not block.
+func (h *clusterHealth) setListener(listener func(health ClusterHealth)) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.listener = listener
+}
+
+func (h *clusterHealth) succeeded(reason string) {
+	h.transition(ClusterHealthUp, reason)
+}
+
+// failed moves the cluster to AUTH_FAILING if the error is an authentication error, otherwise to DEGRADED or DOWN
+// depending on the number of consecutive failures (including this one).
+func (h *clusterHealth) failed(reason string, consecutiveFailures int, err error) {
+	var authError *AuthError
+	switch {
+	case errors.As(err, &authError):
+		h.transition(ClusterHealthAuthFailing, clusterHealthReasonAuthFailed)
+	case consecutiveFailures >= h.failureThreshold:
+		h.transition(ClusterHealthDown, reason)
+	default:
+		h.transition(ClusterHealthDegraded, reason)
+	}
+}
+
+// transition changes the state, a failure with a different reason while in the same state only updates the reason.
+func (h *clusterHealth) transition(state ClusterHealthState, reason string) {
+	h.lock.Lock()
+	previous := h.current
+	if previous.State == state {
+		h.current.Reason = reason
+		h.lock.Unlock()
+		return
+	}
+	h.current = ClusterHealth{State: state, Reason: reason, Since: time.Now()}
+	current, listener := h.current, h.listener
+	h.lock.Unlock()
+
+	if state == ClusterHealthUp {
+		log.Infof("Health of %v changed from %v to %v (%v).", h.clusterType, previous.State, state, reason)
+	} else {
+		log.Warnf("Health of %v changed from %v to %v (%v).", h.clusterType, previous.State, state, reason)
+	}
+	if err := h.metrics.RecordTransition(string(state), reason); err != nil {
+		log.Warnf("Could not record the health transition of %v: %v", h.clusterType, err)
+	}
+	if listener != nil {
+		listener(current)
+	}
+}
@@ -0,0 +1,73 @@
+package zdmproxy
+
+import (
+	"errors"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics/prommetrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestClusterHealth_Transitions(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	healthMetrics, err := metrics.CreateClusterHealthMetrics(
+		prommetrics.NewPrometheusMetricFactory(registry, "zdm"), metrics.ClusterHealthOrigin, clusterHealthStateNames())
+	require.Nil(t, err)
+	health := newClusterHealth(common.ClusterTypeOrigin, 2, healthMetrics)
+	require.Equal(t, ClusterHealthDown, health.get().State)
+	require.Equal(t, clusterHealthReasonNotConnected, health.get().Reason)
+
+	health.succeeded(clusterHealthReasonConnected)
+	require.Equal(t, ClusterHealthUp, health.get().State)
+
+	health.failed(clusterHealthReasonHeartbeatFailed, 1, errors.New("timeout"))
+	require.Equal(t, ClusterHealth{ClusterHealthDegraded, clusterHealthReasonHeartbeatFailed, health.get().Since}, health.get())
+	since := health.get().Since
+	// same state, only the reason changes
+	health.failed(clusterHealthReasonConnectionFailed, 1, errors.New("connection refused"))
+	require.Equal(t, ClusterHealth{ClusterHealthDegraded, clusterHealthReasonConnectionFailed, since}, health.get())
+
+	health.failed(clusterHealthReasonConnectionFailed, 2, errors.New("connection refused"))
+	require.Equal(t, ClusterHealthDown, health.get().State)
+
+	authError := fmt.Errorf("failed to perform handshake: %w", &AuthError{errMsg: &message.AuthenticationError{}})
+	health.failed(clusterHealthReasonConnectionFailed, 3, authError)
+	require.Equal(t, ClusterHealth{ClusterHealthAuthFailing, clusterHealthReasonAuthFailed, health.get().Since}, health.get())
+
+	health.succeeded(clusterHealthReasonConnected)
+	require.Equal(t, ClusterHealthUp, health.get().State)
+
+	families, err := registry.Gather()
+	require.Nil(t, err)
+	states := map[string]float64{}
+	transitions := map[string]float64{}
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range m.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			require.Equal(t, "origin", labels["cluster"])
+			switch family.GetName() {
+			case "zdm_cluster_health_state":
+				states[labels["state"]] = m.GetGauge().GetValue()
+			case "zdm_cluster_health_transitions_total":
+				transitions[labels["state"]+"/"+labels["reason"]] = m.GetCounter().GetValue()
+			}
+		}
+	}
+	require.Equal(t, map[string]float64{"UP": 1, "DEGRADED": 0, "DOWN": 0, "AUTH_FAILING": 0}, states)
+	require.Equal(t, map[string]float64{
+		"UP/connected":              2,
+		"DEGRADED/heartbeat_failed": 1,
+		"DOWN/connection_failed":    1,
+		"AUTH_FAILING/auth_failed":  1,
+	}, transitions)
+
+	// the metrics are optional, e.g. when validating the configuration
+	newClusterHealth(common.ClusterTypeTarget, 1, nil).failed(clusterHealthReasonHeartbeatFailed, 1, nil)
+}
@@ -0,0 +1,66 @@
+package zdmproxy
+
+import (
+	"context"
+	"fmt"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/sharedconfig"
+	log "github.com/sirupsen/logrus"
+	"sync/atomic"
+	"time"
+)
+
+// SwapClusterRoles makes the secondary cluster the primary one, to roll back a cutover to Target for example, and
+// returns the new primary cluster. The existing client connections are drained so that drivers reconnect with the new
+// roles within seconds. With shared configuration the primary cluster is written to the store and every instance of
+// the fleet applies the swap, otherwise only this instance does.
+//
+// Prepared statements don't have to be prepared again: the proxy always returns the prepared id of Origin to the
+// clients and the cache maps it to the ids of both clusters, whichever is primary.
+func (p *ZdmProxy) SwapClusterRoles(ctx context.Context) (common.ClusterType, error) {
+	p.lock.RLock()
+	primaryCluster := p.primaryCluster
+	p.lock.RUnlock()
+	newPrimaryCluster := common.ClusterTypeTarget
+	if primaryCluster == common.ClusterTypeTarget {
+		newPrimaryCluster = common.ClusterTypeOrigin
+	}
+
+	if p.sharedConfigPublisher != nil {
+		// the drain is triggered by the second key so that the reconnected clients get the new primary cluster
+		err := p.sharedConfigPublisher.Publish(ctx, sharedconfig.KeyPrimaryCluster, string(newPrimaryCluster))
+		if err != nil {
+			return "", fmt.Errorf("could not swap the cluster roles: %w", err)
+		}
+		err = p.sharedConfigPublisher.Publish(
+			ctx, sharedconfig.KeyClusterRolesSwappedAt, time.Now().UTC().Format(time.RFC3339Nano))
+		if err != nil {
+			return "", fmt.Errorf("primary cluster changed to %v but the client connections could not be drained: %w",
+				newPrimaryCluster, err)
+		}
+		log.Infof("Cluster roles swapped in the shared configuration, %v is the primary cluster of the fleet.",
+			newPrimaryCluster)
+		return newPrimaryCluster, nil
+	}
+
+	p.lock.Lock()
+	p.primaryCluster = newPrimaryCluster
+	p.lock.Unlock()
+	p.drainClientConnections(fmt.Sprintf("cluster roles swapped, %v is the primary cluster", newPrimaryCluster))
+	return newPrimaryCluster, nil
+}
+
+// drainClientConnections makes the existing client handlers finish their pending requests and close their connection,
+// the client handlers that are created afterwards aren't affected.
+func (p *ZdmProxy) drainClientConnections(reason string) {
+	p.lock.Lock()
+	drainRequestCancelFn := p.clientHandlersDrainRequestCancelFn
+	p.clientHandlersDrainRequestCtx, p.clientHandlersDrainRequestCancelFn = context.WithCancel(
+		p.clientHandlersShutdownRequestCtx)
+	p.lock.Unlock()
+
+	if activeClients := atomic.LoadInt32(&p.activeClients); activeClients > 0 {
+		log.Infof("Draining %d client connections (%v).", activeClients, reason)
+	}
+	drainRequestCancelFn()
+}
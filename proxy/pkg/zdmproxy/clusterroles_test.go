@@ -0,0 +1,38 @@
+package zdmproxy
+
+import (
+	"context"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+func TestSwapClusterRoles_WithoutSharedConfig(t *testing.T) {
+	p := &ZdmProxy{lock: &sync.RWMutex{}, primaryCluster: common.ClusterTypeOrigin}
+	p.clientHandlersShutdownRequestCtx, p.clientHandlersShutdownRequestCancelFn = context.WithCancel(context.Background())
+	defer p.clientHandlersShutdownRequestCancelFn()
+	p.clientHandlersDrainRequestCtx, p.clientHandlersDrainRequestCancelFn = context.WithCancel(
+		p.clientHandlersShutdownRequestCtx)
+
+	drainRequestCtx := p.clientHandlersDrainRequestCtx
+	newPrimaryCluster, err := p.SwapClusterRoles(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, common.ClusterTypeTarget, newPrimaryCluster)
+	require.Equal(t, common.ClusterTypeTarget, p.primaryCluster)
+	// the existing connections are drained but not the ones that are created afterwards
+	require.NotNil(t, drainRequestCtx.Err())
+	require.Nil(t, p.clientHandlersDrainRequestCtx.Err())
+
+	// rollback
+	drainRequestCtx = p.clientHandlersDrainRequestCtx
+	newPrimaryCluster, err = p.SwapClusterRoles(context.Background())
+	require.Nil(t, err)
+	require.Equal(t, common.ClusterTypeOrigin, newPrimaryCluster)
+	require.Equal(t, common.ClusterTypeOrigin, p.primaryCluster)
+	require.NotNil(t, drainRequestCtx.Err())
+
+	// the drain contexts are canceled when the proxy shuts down
+	p.clientHandlersShutdownRequestCancelFn()
+	require.NotNil(t, p.clientHandlersDrainRequestCtx.Err())
+}
@@ -0,0 +1,93 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	"strings"
+)
+
+// aggregateClusterWarnings replaces the warnings of the client response with the warnings that ORIGIN and TARGET
+// returned (e.g. tombstone or aggregation warnings), prefixed with the cluster that returned them. A warning returned
+// by both clusters is only added once, e.g. "[ORIGIN, TARGET] Aggregation query used without partition key". Without
+// it, the client would only see the warnings of the cluster whose response was forwarded so a data issue of TARGET
+// would go unnoticed until the cutover.
+func aggregateClusterWarnings(
+	response *frame.RawFrame, originResponse *frame.RawFrame, targetResponse *frame.RawFrame,
+	proxyMetrics *metrics.ProxyMetrics) (*frame.RawFrame, error) {
+	if response == nil || response.Header.Version < primitive.ProtocolVersion4 {
+		return response, nil
+	}
+
+	originWarnings, err := getDistinctResponseWarnings(originResponse)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode %v response to aggregate warnings: %w", common.ClusterTypeOrigin, err)
+	}
+	targetWarnings, err := getDistinctResponseWarnings(targetResponse)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode %v response to aggregate warnings: %w", common.ClusterTypeTarget, err)
+	}
+	if len(originWarnings) == 0 && len(targetWarnings) == 0 {
+		return response, nil
+	}
+	proxyMetrics.ClusterWarningsOrigin.Add(len(originWarnings))
+	proxyMetrics.ClusterWarningsTarget.Add(len(targetWarnings))
+
+	decodedFrame, err := defaultCodec.ConvertFromRawFrame(response)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode response to aggregate warnings: %w", err)
+	}
+	decodedFrame.SetWarnings(mergeClusterWarnings(originWarnings, targetWarnings))
+
+	newResponse, err := defaultCodec.ConvertToRawFrame(decodedFrame)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode response with aggregated warnings: %w", err)
+	}
+	return newResponse, nil
+}
+
+// getDistinctResponseWarnings returns the warnings of the response in order, without duplicates. Only the responses
+// with the warning flag are decoded.
+func getDistinctResponseWarnings(response *frame.RawFrame) ([]string, error) {
+	if response == nil || !response.Header.Flags.Contains(primitive.HeaderFlagWarning) {
+		return nil, nil
+	}
+	decodedFrame, err := defaultCodec.ConvertFromRawFrame(response)
+	if err != nil {
+		return nil, err
+	}
+	var warnings []string
+	seen := make(map[string]bool, len(decodedFrame.Body.Warnings))
+	for _, warning := range decodedFrame.Body.Warnings {
+		if !seen[warning] {
+			seen[warning] = true
+			warnings = append(warnings, warning)
+		}
+	}
+	return warnings, nil
+}
+
+func mergeClusterWarnings(originWarnings []string, targetWarnings []string) []string {
+	inTarget := make(map[string]bool, len(targetWarnings))
+	for _, warning := range targetWarnings {
+		inTarget[warning] = true
+	}
+	merged := make([]string, 0, len(originWarnings)+len(targetWarnings))
+	inOrigin := make(map[string]bool, len(originWarnings))
+	for _, warning := range originWarnings {
+		inOrigin[warning] = true
+		clusters := []string{string(common.ClusterTypeOrigin)}
+		if inTarget[warning] {
+			clusters = append(clusters, string(common.ClusterTypeTarget))
+		}
+		merged = append(merged, fmt.Sprintf("[%v] %v", strings.Join(clusters, ", "), warning))
+	}
+	for _, warning := range targetWarnings {
+		if !inOrigin[warning] {
+			merged = append(merged, fmt.Sprintf("[%v] %v", common.ClusterTypeTarget, warning))
+		}
+	}
+	return merged
+}
@@ -0,0 +1,66 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestAggregateClusterWarnings(t *testing.T) {
+	tombstones := "Read 1001 live rows and 100001 tombstone cells for query SELECT * FROM ks.tbl"
+	aggregation := "Aggregation query used without partition key"
+
+	tests := []struct {
+		name             string
+		originWarnings   []string
+		targetWarnings   []string
+		expectedWarnings []string
+	}{
+		{
+			name:             "no warnings",
+			expectedWarnings: nil,
+		},
+		{
+			name:             "warnings of target only",
+			targetWarnings:   []string{tombstones},
+			expectedWarnings: []string{"[TARGET] " + tombstones},
+		},
+		{
+			name:             "warnings of both clusters",
+			originWarnings:   []string{aggregation, aggregation},
+			targetWarnings:   []string{tombstones, aggregation},
+			expectedWarnings: []string{"[ORIGIN, TARGET] " + aggregation, "[TARGET] " + tombstones},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originResponse := mustEncodeResponseWithWarnings(t, primitive.ProtocolVersion4, tt.originWarnings)
+			targetResponse := mustEncodeResponseWithWarnings(t, primitive.ProtocolVersion4, tt.targetWarnings)
+			response, err := aggregateClusterWarnings(
+				originResponse, originResponse, targetResponse, newFakeProxyMetrics())
+			require.Nil(t, err)
+			decodedResponse, err := defaultCodec.ConvertFromRawFrame(response)
+			require.Nil(t, err)
+			if tt.expectedWarnings == nil {
+				require.Equal(t, tt.originWarnings, decodedResponse.Body.Warnings)
+			} else {
+				require.Equal(t, tt.expectedWarnings, decodedResponse.Body.Warnings)
+			}
+			require.IsType(t, &message.VoidResult{}, decodedResponse.Body.Message)
+		})
+	}
+}
+
+func mustEncodeResponseWithWarnings(
+	t *testing.T, version primitive.ProtocolVersion, warnings []string) *frame.RawFrame {
+	response := frame.NewFrame(version, 1, &message.VoidResult{})
+	if len(warnings) > 0 {
+		response.SetWarnings(warnings)
+	}
+	rawFrame, err := defaultCodec.ConvertToRawFrame(response)
+	require.Nil(t, err)
+	return rawFrame
+}
@@ -6,8 +6,10 @@ import (
 	"github.com/datastax/go-cassandra-native-protocol/frame"
 	"github.com/datastax/zdm-proxy/proxy/pkg/config"
 	log "github.com/sirupsen/logrus"
+	"io"
 	"net"
 	"sync"
+	"time"
 )
 
 const (
@@ -25,6 +27,13 @@ type writeCoalescer struct {
 
 	writeQueue chan *frame.RawFrame
 
+	// room of the write queue that is reserved by the requests that will be enqueued, see tryReserveWriteQueues
+	reservationLock *sync.Mutex
+	reserved        int
+
+	// frames of the write queue whose body is copied from a reader, see EnqueueStreamed
+	streamedFrames *sync.Map
+
 	logPrefix string
 
 	waitGroup *sync.WaitGroup
@@ -32,6 +41,9 @@ type writeCoalescer struct {
 	writeBufferSizeBytes int
 
 	scheduler *Scheduler
+
+	// counts the goroutine of the write queue loop, see goroutineaudit.go
+	goroutines *handlerGoroutines
 }
 
 func NewWriteCoalescer(
@@ -43,7 +55,8 @@ func NewWriteCoalescer(
 	logPrefix string,
 	isRequest bool,
 	isAsync bool,
-	scheduler *Scheduler) *writeCoalescer {
+	scheduler *Scheduler,
+	goroutines *handlerGoroutines) *writeCoalescer {
 
 	writeQueueSizeFrames := conf.RequestWriteQueueSizeFrames
 	if !isRequest {
@@ -67,10 +80,13 @@ func NewWriteCoalescer(
 		shutdownContext:        shutdownContext,
 		cancelFunc:             clientHandlerCancelFunc,
 		writeQueue:             make(chan *frame.RawFrame, writeQueueSizeFrames),
+		reservationLock:        &sync.Mutex{},
+		streamedFrames:         &sync.Map{},
 		logPrefix:              logPrefix,
 		waitGroup:              &sync.WaitGroup{},
 		writeBufferSizeBytes:   writeBufferSizeBytes,
 		scheduler:              scheduler,
+		goroutines:             goroutines,
 	}
 }
 
@@ -80,7 +96,7 @@ func (recv *writeCoalescer) RunWriteQueueLoop() {
 
 	recv.clientHandlerWaitGroup.Add(1)
 	recv.waitGroup.Add(1)
-	go func() {
+	recv.goroutines.start(func() {
 		defer recv.clientHandlerWaitGroup.Done()
 		defer recv.waitGroup.Done()
 
@@ -128,6 +144,7 @@ func (recv *writeCoalescer) RunWriteQueueLoop() {
 						if tempDraining {
 							// continue draining the write queue without writing on connection until it is closed
 							log.Tracef("[%v] Discarding frame from write queue because shutdown was requested: %v", recv.logPrefix, f.Header)
+							recv.discardStreamedFrame(f)
 							continue
 						}
 					} else {
@@ -136,6 +153,32 @@ func (recv *writeCoalescer) RunWriteQueueLoop() {
 						ok = true
 					}
 
+					if streamed := recv.loadStreamedFrame(f); streamed != nil {
+						if tempDraining {
+							close(streamed.done)
+							continue
+						}
+						if streamed.body != nil {
+							// the header is written with the buffered frames, the body is copied after the buffer is flushed
+							log.Tracef("[%v] Writing streamed %v on %v", recv.logPrefix, f.Header, connectionAddr)
+							err := adaptConnErr(connectionAddr, recv.shutdownContext, defaultCodec.EncodeHeader(f.Header, tempBuffer))
+							if err != nil {
+								close(streamed.done)
+								tempDraining = true
+								handleConnectionError(err, recv.shutdownContext, recv.cancelFunc, recv.logPrefix, "writing", connectionAddr)
+								continue
+							}
+						}
+						t := &coalescerIterationResult{
+							buffer:        tempBuffer,
+							draining:      tempDraining,
+							streamedFrame: streamed,
+						}
+						resultChannel <- t
+						close(resultChannel)
+						return
+					}
+
 					log.Tracef("[%v] Writing %v on %v", recv.logPrefix, f.Header, connectionAddr)
 					err := writeRawFrame(tempBuffer, connectionAddr, recv.shutdownContext, f)
 					if err != nil {
@@ -170,8 +213,20 @@ func (recv *writeCoalescer) RunWriteQueueLoop() {
 					draining = true
 				}
 			}
+			if result.streamedFrame != nil {
+				if !draining && result.streamedFrame.body != nil {
+					writer := &writeErrorTracker{writer: recv.connection}
+					_, _ = io.Copy(writer, result.streamedFrame.body)
+					// read errors are handled by the owner of the body reader
+					if writer.err != nil {
+						handleConnectionError(writer.err, recv.shutdownContext, recv.cancelFunc, recv.logPrefix, "writing", connectionAddr)
+						draining = true
+					}
+				}
+				close(result.streamedFrame.done)
+			}
 		}
-	}()
+	})
 }
 
 func (recv *writeCoalescer) Enqueue(frame *frame.RawFrame) {
@@ -192,12 +247,107 @@ func (recv *writeCoalescer) EnqueueAsync(frame *frame.RawFrame) bool {
 	}
 }
 
+// EnqueueStreamed adds a frame whose body is copied from the provided reader (instead of being held in memory) to the
+// write queue. The returned channel is closed once the coalescer is done with the reader, the body might not have been
+// fully read at that point (e.g. the connection was closed) so the caller is responsible for discarding the rest.
+func (recv *writeCoalescer) EnqueueStreamed(header *frame.Header, body io.Reader) <-chan struct{} {
+	placeholder := &frame.RawFrame{Header: header}
+	streamed := &streamedFrame{body: body, done: make(chan struct{})}
+	recv.streamedFrames.Store(placeholder, streamed)
+	recv.Enqueue(placeholder)
+	return streamed.done
+}
+
+// Flush waits until the frames that are already in the write queue are written on the connection or until the
+// timeout elapses. It doesn't wait at all if the write queue is full since the connection is most likely stuck.
+func (recv *writeCoalescer) Flush(timeout time.Duration) {
+	// a streamed frame without body is only a marker, nothing is written for it
+	placeholder := &frame.RawFrame{Header: &frame.Header{}}
+	streamed := &streamedFrame{done: make(chan struct{})}
+	recv.streamedFrames.Store(placeholder, streamed)
+	if !recv.EnqueueAsync(placeholder) {
+		recv.streamedFrames.Delete(placeholder)
+		return
+	}
+	select {
+	case <-streamed.done:
+	case <-time.After(timeout):
+		log.Debugf("[%v] Timed out waiting for the write queue to be flushed on %v", recv.logPrefix, recv.connection.RemoteAddr())
+	}
+}
+
+func (recv *writeCoalescer) loadStreamedFrame(f *frame.RawFrame) *streamedFrame {
+	streamed, ok := recv.streamedFrames.LoadAndDelete(f)
+	if !ok {
+		return nil
+	}
+	return streamed.(*streamedFrame)
+}
+
+func (recv *writeCoalescer) discardStreamedFrame(f *frame.RawFrame) {
+	if streamed := recv.loadStreamedFrame(f); streamed != nil {
+		close(streamed.done)
+	}
+}
+
+// tryReserveWriteQueues reserves room for a frame in the write queue of each coalescer, in all of them or in none if
+// one of them is full (counting the room reserved by other requests). The queues are checked and reserved while holding
+// their locks so that a concurrent request can't take the room of a queue between the check of the queues. The
+// reservations are freed by releaseWriteQueues, after the frames were enqueued. The coalescers must always be passed
+// in the same order (origin then target).
+//
+// Only the requests that are subject to the overflow policy reserve room, the other frames (e.g. heartbeats) can
+// still fill a queue in which room was reserved, in which case Enqueue blocks like without the policy.
+func tryReserveWriteQueues(coalescers ...*writeCoalescer) bool {
+	for _, coalescer := range coalescers {
+		coalescer.reservationLock.Lock()
+		defer coalescer.reservationLock.Unlock()
+	}
+	for _, coalescer := range coalescers {
+		if len(coalescer.writeQueue)+coalescer.reserved >= cap(coalescer.writeQueue) {
+			return false
+		}
+	}
+	for _, coalescer := range coalescers {
+		coalescer.reserved++
+	}
+	return true
+}
+
+func releaseWriteQueues(coalescers ...*writeCoalescer) {
+	for _, coalescer := range coalescers {
+		coalescer.reservationLock.Lock()
+		coalescer.reserved--
+		coalescer.reservationLock.Unlock()
+	}
+}
+
 func (recv *writeCoalescer) Close() {
 	close(recv.writeQueue)
 	recv.waitGroup.Wait()
 }
 
 type coalescerIterationResult struct {
-	buffer   *bytes.Buffer
-	draining bool
+	buffer        *bytes.Buffer
+	draining      bool
+	streamedFrame *streamedFrame
+}
+
+type streamedFrame struct {
+	body io.Reader
+	done chan struct{}
+}
+
+// writeErrorTracker is used to tell write errors apart from read errors when copying a streamed frame body
+type writeErrorTracker struct {
+	writer io.Writer
+	err    error
+}
+
+func (w *writeErrorTracker) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	if err != nil {
+		w.err = err
+	}
+	return n, err
 }
@@ -0,0 +1,113 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"strings"
+)
+
+// clusterQuirks describes the known differences between a Cassandra compatible service and Apache Cassandra
+// that the proxy can work around.
+type clusterQuirks struct {
+	profile common.CompatibilityProfile
+
+	// System keyspaces (or keyspace prefixes if the entry ends with '_') that don't exist on this cluster.
+	// System queries on these keyspaces are sent to the other cluster instead.
+	unavailableSystemKeyspaces []string
+
+	// Some services return SERVER_ERROR for features that they don't support. Drivers retry SERVER_ERROR on the next
+	// node so these are translated to INVALID which is returned to the application right away.
+	translateUnsupportedServerErrors bool
+}
+
+var (
+	noQuirks = &clusterQuirks{
+		profile: common.CompatibilityProfileNone,
+	}
+
+	astraQuirks = &clusterQuirks{
+		profile:                          common.CompatibilityProfileAstra,
+		unavailableSystemKeyspaces:       []string{"system_distributed", "dse_"},
+		translateUnsupportedServerErrors: false,
+	}
+
+	keyspacesQuirks = &clusterQuirks{
+		profile: common.CompatibilityProfileKeyspaces,
+		unavailableSystemKeyspaces: []string{
+			"system_auth", "system_distributed", "system_traces", "system_views", "system_virtual_schema", "dse_"},
+		translateUnsupportedServerErrors: true,
+	}
+
+	cosmosQuirks = &clusterQuirks{
+		profile: common.CompatibilityProfileCosmos,
+		unavailableSystemKeyspaces: []string{
+			"system_auth", "system_distributed", "system_traces", "system_views", "system_virtual_schema", "dse_"},
+		translateUnsupportedServerErrors: true,
+	}
+)
+
+func getClusterQuirks(profile common.CompatibilityProfile) *clusterQuirks {
+	switch profile {
+	case common.CompatibilityProfileAstra:
+		return astraQuirks
+	case common.CompatibilityProfileKeyspaces:
+		return keyspacesQuirks
+	case common.CompatibilityProfileCosmos:
+		return cosmosQuirks
+	default:
+		return noQuirks
+	}
+}
+
+func (recv *clusterQuirks) isSystemKeyspaceAvailable(keyspace string) bool {
+	if recv == nil {
+		return true
+	}
+	for _, unavailableKeyspace := range recv.unavailableSystemKeyspaces {
+		if strings.HasSuffix(unavailableKeyspace, "_") {
+			if strings.HasPrefix(keyspace, unavailableKeyspace) {
+				return false
+			}
+		} else if keyspace == unavailableKeyspace {
+			return false
+		}
+	}
+	return true
+}
+
+// translateError returns the error that should be sent to the client instead of the provided one or nil
+// if the error doesn't need to be translated.
+func (recv *clusterQuirks) translateError(errMsg message.Error) message.Error {
+	if recv == nil || !recv.translateUnsupportedServerErrors {
+		return nil
+	}
+
+	serverError, ok := errMsg.(*message.ServerError)
+	if !ok {
+		return nil
+	}
+
+	lowerCaseMsg := strings.ToLower(serverError.ErrorMessage)
+	if strings.Contains(lowerCaseMsg, "not supported") || strings.Contains(lowerCaseMsg, "unsupported") {
+		return &message.Invalid{ErrorMessage: serverError.ErrorMessage}
+	}
+
+	return nil
+}
+
+// systemQueryForwardDecision returns the cluster that should receive a system query on the provided keyspace,
+// falling back to the other cluster if the keyspace is not available on the preferred one.
+func systemQueryForwardDecision(
+	keyspace string, preferred forwardDecision, originQuirks *clusterQuirks, targetQuirks *clusterQuirks) forwardDecision {
+	switch preferred {
+	case forwardToTarget:
+		if !targetQuirks.isSystemKeyspaceAvailable(keyspace) && originQuirks.isSystemKeyspaceAvailable(keyspace) {
+			return forwardToOrigin
+		}
+	case forwardToOrigin:
+		if !originQuirks.isSystemKeyspaceAvailable(keyspace) && targetQuirks.isSystemKeyspaceAvailable(keyspace) {
+			return forwardToTarget
+		}
+	}
+	return preferred
+}
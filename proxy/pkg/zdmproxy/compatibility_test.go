@@ -0,0 +1,66 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSystemQueryForwardDecision(t *testing.T) {
+	none := getClusterQuirks(common.CompatibilityProfileNone)
+	keyspaces := getClusterQuirks(common.CompatibilityProfileKeyspaces)
+	cosmos := getClusterQuirks(common.CompatibilityProfileCosmos)
+	astra := getClusterQuirks(common.CompatibilityProfileAstra)
+	tests := []struct {
+		name         string
+		keyspace     string
+		preferred    forwardDecision
+		originQuirks *clusterQuirks
+		targetQuirks *clusterQuirks
+		expected     forwardDecision
+	}{
+		{"no quirks", "system_auth", forwardToTarget, none, none, forwardToTarget},
+		{"nil quirks", "system_auth", forwardToTarget, nil, nil, forwardToTarget},
+		{"keyspaces target system_auth", "system_auth", forwardToTarget, none, keyspaces, forwardToOrigin},
+		{"keyspaces target system_schema", "system_schema", forwardToTarget, none, keyspaces, forwardToTarget},
+		{"keyspaces target dse prefix", "dse_insights", forwardToTarget, none, keyspaces, forwardToOrigin},
+		{"keyspaces origin", "system_traces", forwardToOrigin, keyspaces, none, forwardToTarget},
+		{"unavailable on both", "system_traces", forwardToOrigin, keyspaces, cosmos, forwardToOrigin},
+		{"astra target system_distributed", "system_distributed", forwardToTarget, none, astra, forwardToOrigin},
+		{"astra target system_auth", "system_auth", forwardToTarget, none, astra, forwardToTarget},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, systemQueryForwardDecision(tt.keyspace, tt.preferred, tt.originQuirks, tt.targetQuirks))
+		})
+	}
+}
+
+func TestClusterQuirks_TranslateError(t *testing.T) {
+	tests := []struct {
+		name     string
+		quirks   *clusterQuirks
+		err      message.Error
+		expected message.Error
+	}{
+		{"none", getClusterQuirks(common.CompatibilityProfileNone),
+			&message.ServerError{ErrorMessage: "LOGGED batches are not supported"}, nil},
+		{"keyspaces unsupported", getClusterQuirks(common.CompatibilityProfileKeyspaces),
+			&message.ServerError{ErrorMessage: "LOGGED batches are not supported"},
+			&message.Invalid{ErrorMessage: "LOGGED batches are not supported"}},
+		{"cosmos unsupported", getClusterQuirks(common.CompatibilityProfileCosmos),
+			&message.ServerError{ErrorMessage: "Unsupported operation"},
+			&message.Invalid{ErrorMessage: "Unsupported operation"}},
+		{"keyspaces other server error", getClusterQuirks(common.CompatibilityProfileKeyspaces),
+			&message.ServerError{ErrorMessage: "internal failure"}, nil},
+		{"keyspaces other error", getClusterQuirks(common.CompatibilityProfileKeyspaces),
+			&message.Overloaded{ErrorMessage: "not supported"}, nil},
+		{"nil quirks", nil, &message.ServerError{ErrorMessage: "not supported"}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, tt.quirks.translateError(tt.err))
+		})
+	}
+}
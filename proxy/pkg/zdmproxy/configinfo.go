@@ -0,0 +1,70 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// configInfoLabels returns the labels of the config_info metric: the settings that every instance of a proxy fleet is
+// expected to share, so that the dashboards can detect an instance that was deployed with a different configuration.
+// The values are the effective ones (e.g. the number of workers derived from GOMAXPROCS), spelled the same way
+// regardless of how the setting was written.
+func (p *ZdmProxy) configInfoLabels() (map[string]string, error) {
+	dualWriteResponsePolicy, err := formatDualWriteResponsePolicy(p.Conf)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		"primary_cluster":             string(p.primaryCluster),
+		"read_mode":                   p.readMode.String(),
+		"dual_write_response_policy":  dualWriteResponsePolicy,
+		"max_client_protocol_version": formatMaxProtocolVersion(p.maxProtocolVersion),
+		"max_client_connections":      strconv.Itoa(p.Conf.ProxyMaxClientConnections),
+		"max_stream_ids":              strconv.Itoa(p.Conf.ProxyMaxStreamIds),
+		"request_response_workers":    strconv.Itoa(p.requestResponseNumWorkers),
+		"read_workers":                strconv.Itoa(p.readNumWorkers),
+		"write_workers":               strconv.Itoa(p.writeNumWorkers),
+		"listener_workers":            strconv.Itoa(p.listenerNumWorkers),
+	}, nil
+}
+
+// formatDualWriteResponsePolicy returns the policies of ZDM_DUAL_WRITE_RESPONSE_POLICY sorted by statement type, e.g.
+// DEFAULT=BOTH,INSERT=PRIMARY. The DEFAULT policy is always included.
+func formatDualWriteResponsePolicy(conf *config.Config) (string, error) {
+	policies, err := conf.ParseDualWriteResponsePolicy()
+	if err != nil {
+		return "", err
+	}
+	if _, ok := policies[config.DualWriteStatementTypeDefault]; !ok {
+		if policies == nil {
+			policies = make(map[string]common.DualWriteResponsePolicy, 1)
+		}
+		policies[config.DualWriteStatementTypeDefault] = common.DualWriteResponsePolicyBoth
+	}
+	entries := make([]string, 0, len(policies))
+	for stmtType, policy := range policies {
+		entries = append(entries, fmt.Sprintf("%v=%v", stmtType, policy))
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ","), nil
+}
+
+// formatMaxProtocolVersion returns a version in the format of ZDM_MAX_CLIENT_PROTOCOL_VERSION, or NONE if it is not
+// pinned.
+func formatMaxProtocolVersion(version primitive.ProtocolVersion) string {
+	switch version {
+	case 0:
+		return "NONE"
+	case primitive.ProtocolVersionDse1:
+		return "DSE_V1"
+	case primitive.ProtocolVersionDse2:
+		return "DSE_V2"
+	default:
+		return strconv.Itoa(int(version))
+	}
+}
@@ -0,0 +1,59 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConfigInfoLabels(t *testing.T) {
+	newProxy := func(dualWriteResponsePolicy string, maxProtocolVersion primitive.ProtocolVersion) *ZdmProxy {
+		conf := config.New()
+		conf.DualWriteResponsePolicy = dualWriteResponsePolicy
+		conf.ProxyMaxClientConnections = 1000
+		conf.ProxyMaxStreamIds = 2048
+		return &ZdmProxy{
+			Conf:                      conf,
+			primaryCluster:            common.ClusterTypeTarget,
+			readMode:                  common.ReadModeDualAsyncOnSecondary,
+			maxProtocolVersion:        maxProtocolVersion,
+			requestResponseNumWorkers: 16,
+			readNumWorkers:            48,
+			writeNumWorkers:           24,
+			listenerNumWorkers:        4,
+		}
+	}
+
+	labels, err := newProxy("", 0).configInfoLabels()
+	require.Nil(t, err)
+	require.Equal(t, map[string]string{
+		"primary_cluster":             "TARGET",
+		"read_mode":                   "DUAL_ASYNC_ON_SECONDARY",
+		"dual_write_response_policy":  "DEFAULT=BOTH",
+		"max_client_protocol_version": "NONE",
+		"max_client_connections":      "1000",
+		"max_stream_ids":              "2048",
+		"request_response_workers":    "16",
+		"read_workers":                "48",
+		"write_workers":               "24",
+		"listener_workers":            "4",
+	}, labels)
+
+	// the same settings spelled differently have the same labels
+	for _, policy := range []string{"update=either, insert=PRIMARY", "INSERT=PRIMARY,UPDATE=EITHER,DEFAULT=BOTH"} {
+		labels, err = newProxy(policy, primitive.ProtocolVersion4).configInfoLabels()
+		require.Nil(t, err)
+		require.Equal(t, "DEFAULT=BOTH,INSERT=PRIMARY,UPDATE=EITHER", labels["dual_write_response_policy"])
+		require.Equal(t, "4", labels["max_client_protocol_version"])
+	}
+
+	labels, err = newProxy("DEFAULT=QUORUM", primitive.ProtocolVersionDse2).configInfoLabels()
+	require.Nil(t, err)
+	require.Equal(t, "DEFAULT=QUORUM", labels["dual_write_response_policy"])
+	require.Equal(t, "DSE_V2", labels["max_client_protocol_version"])
+
+	_, err = newProxy("INSERT", 0).configInfoLabels()
+	require.NotNil(t, err)
+}
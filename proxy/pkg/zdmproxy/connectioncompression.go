@@ -0,0 +1,138 @@
+package zdmproxy
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/compression/lz4"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	log "github.com/sirupsen/logrus"
+	"sync/atomic"
+)
+
+// connectionCompression compresses the frames of a cluster connection when the client didn't negotiate compression
+// itself. The proxy adds the COMPRESSION option to the STARTUP request that it forwards to the cluster, then it
+// compresses the requests that it sends and decompresses the responses before they reach the client handler.
+//
+// If the client negotiated compression then its frames are forwarded untouched like before.
+type connectionCompression struct {
+	algorithm  primitive.Compression
+	compressor frame.BodyCompressor
+
+	// set to true once a STARTUP request with the compression option was sent on the connection
+	active *atomic.Value
+}
+
+func newConnectionCompression(compression common.ConnectionCompression) *connectionCompression {
+	if compression != common.ConnectionCompressionLz4 {
+		return nil
+	}
+	active := &atomic.Value{}
+	active.Store(false)
+	return &connectionCompression{
+		algorithm:  primitive.CompressionLz4,
+		compressor: lz4.Compressor{},
+		active:     active,
+	}
+}
+
+// compressRequest returns the frame that should be written on the connection. Uncompressed frames are always valid so
+// the original frame is returned if it can't be compressed.
+func (cc *ClusterConnector) compressRequest(f *frame.RawFrame) *frame.RawFrame {
+	compressedFrame, err := cc.compression.compressRequest(f)
+	if err != nil {
+		log.Warnf("[%v] Could not compress %v request, sending it uncompressed: %v",
+			string(cc.connectorType), f.Header.OpCode, err)
+		return f
+	}
+	return compressedFrame
+}
+
+func (c *connectionCompression) isActive() bool {
+	return c != nil && c.active.Load().(bool)
+}
+
+// compressRequest is safe to call on a nil connectionCompression (compression disabled).
+func (c *connectionCompression) compressRequest(f *frame.RawFrame) (*frame.RawFrame, error) {
+	if c == nil {
+		return f, nil
+	}
+	if f.Header.OpCode == primitive.OpCodeStartup {
+		return c.enableCompression(f)
+	}
+	if !c.isActive() || f.Header.Flags.Contains(primitive.HeaderFlagCompressed) {
+		return f, nil
+	}
+
+	body := &bytes.Buffer{}
+	err := c.compressor.CompressWithLength(bytes.NewReader(f.Body), body)
+	if err != nil {
+		return nil, fmt.Errorf("could not compress %v request: %w", f.Header.OpCode, err)
+	}
+	newHeader := f.Header.Clone()
+	newHeader.Flags = newHeader.Flags.Add(primitive.HeaderFlagCompressed)
+	newHeader.BodyLength = int32(body.Len())
+	return &frame.RawFrame{Header: newHeader, Body: body.Bytes()}, nil
+}
+
+// decompressResponse returns the response with an uncompressed body if compression was enabled by the proxy, the
+// client handler and the client expect uncompressed frames in that case.
+func (c *connectionCompression) decompressResponse(f *frame.RawFrame) (*frame.RawFrame, error) {
+	if !c.isActive() || !f.Header.Flags.Contains(primitive.HeaderFlagCompressed) {
+		return f, nil
+	}
+
+	body := &bytes.Buffer{}
+	err := c.compressor.DecompressWithLength(bytes.NewReader(f.Body), body)
+	if err != nil {
+		return nil, fmt.Errorf("could not decompress %v response: %w", f.Header.OpCode, err)
+	}
+	newHeader := f.Header.Clone()
+	newHeader.Flags = newHeader.Flags.Remove(primitive.HeaderFlagCompressed)
+	newHeader.BodyLength = int32(body.Len())
+	return &frame.RawFrame{Header: newHeader, Body: body.Bytes()}, nil
+}
+
+func (c *connectionCompression) enableCompression(f *frame.RawFrame) (*frame.RawFrame, error) {
+	decodedFrame, err := defaultCodec.ConvertFromRawFrame(f)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode STARTUP frame: %w", err)
+	}
+
+	startup, ok := decodedFrame.Body.Message.(*message.Startup)
+	if !ok {
+		return nil, fmt.Errorf("expected STARTUP but got %v", decodedFrame.Body.Message)
+	}
+
+	if startup.GetCompression() != primitive.CompressionNone {
+		log.Debugf("Client negotiated %v compression, forwarding its frames without compressing them again.",
+			startup.GetCompression())
+		c.active.Store(false)
+		return f, nil
+	}
+	if checkProtocolVersion(f.Header.Version) != nil {
+		// the handshake is going to fail with a protocol error and the client will downgrade
+		log.Debugf("Protocol version %v is not supported by the proxy, the connection will not be compressed.",
+			f.Header.Version)
+		c.active.Store(false)
+		return f, nil
+	}
+
+	newOptions := make(map[string]string, len(startup.Options)+1)
+	for key, value := range startup.Options {
+		newOptions[key] = value
+	}
+	newStartup := &message.Startup{Options: newOptions}
+	newStartup.SetCompression(c.algorithm)
+
+	newFrame := decodedFrame.Clone()
+	newFrame.Body.Message = newStartup
+	newRawFrame, err := defaultCodec.ConvertToRawFrame(newFrame)
+	if err != nil {
+		return nil, fmt.Errorf("could not convert STARTUP frame with compression to raw frame: %w", err)
+	}
+	c.active.Store(true)
+	return newRawFrame, nil
+}
@@ -0,0 +1,82 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestConnectionCompression_Startup(t *testing.T) {
+	tests := []struct {
+		name                string
+		startup             *message.Startup
+		expectedCompression primitive.Compression
+		expectedActive      bool
+	}{
+		{"client without compression", message.NewStartup(), primitive.CompressionLz4, true},
+		{"client with lz4", message.NewStartup(message.StartupOptionCompression, "lz4"), "lz4", false},
+		{"client with snappy", message.NewStartup(message.StartupOptionCompression, "snappy"), "snappy", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			compression := newConnectionCompression(common.ConnectionCompressionLz4)
+			startup := mustConvertToRawFrame(t, frame.NewFrame(primitive.ProtocolVersion4, 1, tt.startup))
+
+			sentStartup, err := compression.compressRequest(startup)
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedActive, compression.isActive())
+			require.False(t, sentStartup.Header.Flags.Contains(primitive.HeaderFlagCompressed))
+
+			decodedStartup, err := defaultCodec.ConvertFromRawFrame(sentStartup)
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedCompression, decodedStartup.Body.Message.(*message.Startup).GetCompression())
+			require.Equal(t, "3.0.0", decodedStartup.Body.Message.(*message.Startup).Options[message.StartupOptionCqlVersion])
+		})
+	}
+}
+
+func TestConnectionCompression_RoundTrip(t *testing.T) {
+	compression := newConnectionCompression(common.ConnectionCompressionLz4)
+	query := mustConvertToRawFrame(t, frame.NewFrame(
+		primitive.ProtocolVersion4, 1, &message.Query{Query: "SELECT * FROM ks.tb"}))
+
+	// frames sent before the STARTUP request are not compressed
+	sentQuery, err := compression.compressRequest(query)
+	require.Nil(t, err)
+	require.Equal(t, query, sentQuery)
+
+	_, err = compression.compressRequest(mustConvertToRawFrame(t, frame.NewFrame(
+		primitive.ProtocolVersion4, 0, message.NewStartup())))
+	require.Nil(t, err)
+
+	sentQuery, err = compression.compressRequest(query)
+	require.Nil(t, err)
+	require.True(t, sentQuery.Header.Flags.Contains(primitive.HeaderFlagCompressed))
+	require.Equal(t, int32(len(sentQuery.Body)), sentQuery.Header.BodyLength)
+	require.NotEqual(t, query.Body, sentQuery.Body)
+
+	// the cluster compresses its responses in the same way
+	receivedQuery, err := compression.decompressResponse(sentQuery)
+	require.Nil(t, err)
+	require.Equal(t, query, receivedQuery)
+
+	uncompressedResponse := mustConvertToRawFrame(t, frame.NewFrame(primitive.ProtocolVersion4, 1, &message.VoidResult{}))
+	receivedResponse, err := compression.decompressResponse(uncompressedResponse)
+	require.Nil(t, err)
+	require.Equal(t, uncompressedResponse, receivedResponse)
+}
+
+func TestConnectionCompression_Disabled(t *testing.T) {
+	compression := newConnectionCompression(common.ConnectionCompressionNone)
+	require.Nil(t, compression)
+
+	startup := mustConvertToRawFrame(t, frame.NewFrame(primitive.ProtocolVersion4, 0, message.NewStartup()))
+	sentStartup, err := compression.compressRequest(startup)
+	require.Nil(t, err)
+	require.Equal(t, startup, sentStartup)
+	require.False(t, compression.isActive())
+}
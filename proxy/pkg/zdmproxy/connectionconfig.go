@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"fmt"
 	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
 	log "github.com/sirupsen/logrus"
 	"net"
 	"sync"
@@ -22,13 +23,16 @@ type ConnectionConfig interface {
 }
 
 func InitializeConnectionConfig(clusterTlsConfig *common.ClusterTlsConfig, contactPointsFromConfig []string, port int,
-	connTimeoutInMs int, clusterType common.ClusterType, datacenterFromConfig string, ctx context.Context) (ConnectionConfig, error) {
+	peerPortMapping map[string]int, connTimeoutInMs int, clusterType common.ClusterType, datacenterFromConfig string, ctx context.Context,
+	tlsReloader *tlsConfigReloader) (ConnectionConfig, error) {
 
 	var tlsConfig *tls.Config
 	var err error
 	if clusterTlsConfig.TlsEnabled {
 		if clusterTlsConfig.SecureConnectBundlePath != "" {
-			return initializeAstraConnectionConfig(connTimeoutInMs, clusterType, clusterTlsConfig.SecureConnectBundlePath, ctx)
+			return initializeAstraConnectionConfig(connTimeoutInMs, clusterType, clusterTlsConfig, ctx)
+		} else if tlsReloader != nil {
+			tlsConfig = tlsReloader.clientSideTlsConfig()
 		} else {
 			tlsConfig, err = getClientSideTlsConfigFromProxyClusterTlsConfig(clusterTlsConfig, clusterType)
 			if err != nil {
@@ -39,9 +43,14 @@ func InitializeConnectionConfig(clusterTlsConfig *common.ClusterTlsConfig, conta
 
 	contactPoints := make([]Endpoint, 0)
 	for _, contactPoint := range contactPointsFromConfig {
-		contactPoints = append(contactPoints, NewDefaultEndpoint(contactPoint, port, tlsConfig))
+		host, contactPointPort, err := config.SplitContactPoint(contactPoint, port)
+		if err != nil {
+			return nil, err
+		}
+		contactPoints = append(contactPoints, NewDefaultEndpoint(host, contactPointPort, tlsConfig))
 	}
-	return newGenericConnectionConfig(tlsConfig, connTimeoutInMs, clusterType, datacenterFromConfig, contactPoints), nil
+	return newGenericConnectionConfig(
+		tlsConfig, connTimeoutInMs, clusterType, datacenterFromConfig, contactPoints, peerPortMapping), nil
 
 }
 
@@ -76,14 +85,18 @@ type genericConnectionConfig struct {
 	*baseConnectionConfig
 	datacenter    string
 	contactPoints []Endpoint
+	// native ports of the nodes that can't be reached on the port reported by the cluster, keyed by RPC address
+	peerPortMapping map[string]int
 }
 
 func newGenericConnectionConfig(
-	tlsConfig *tls.Config, connectionTimeoutMs int, clusterType common.ClusterType, datacenter string, contactPoints []Endpoint) *genericConnectionConfig {
+	tlsConfig *tls.Config, connectionTimeoutMs int, clusterType common.ClusterType, datacenter string,
+	contactPoints []Endpoint, peerPortMapping map[string]int) *genericConnectionConfig {
 	return &genericConnectionConfig{
 		baseConnectionConfig: newBaseConnectionConfig(tlsConfig, connectionTimeoutMs, clusterType),
 		datacenter:           datacenter,
 		contactPoints:        contactPoints,
+		peerPortMapping:      peerPortMapping,
 	}
 }
 
@@ -105,7 +118,11 @@ func (cc *genericConnectionConfig) RefreshContactPoints(ctx context.Context) ([]
 }
 
 func (cc *genericConnectionConfig) CreateEndpoint(h *Host) Endpoint {
-	return NewDefaultEndpoint(h.Address.String(), h.Port, cc.tlsConfig)
+	port := h.Port
+	if mappedPort, ok := cc.peerPortMapping[h.Address.String()]; ok {
+		port = mappedPort
+	}
+	return NewDefaultEndpoint(h.Address.String(), port, cc.tlsConfig)
 }
 
 type AstraConnectionConfig interface {
@@ -127,8 +144,8 @@ type astraConnectionConfigImpl struct {
 }
 
 func initializeAstraConnectionConfig(
-	connectionTimeoutMs int, clusterType common.ClusterType, secureConnectBundlePath string, ctx context.Context) (*astraConnectionConfigImpl, error) {
-	fileMap, err := extractFilesFromZipArchive(secureConnectBundlePath)
+	connectionTimeoutMs int, clusterType common.ClusterType, clusterTlsConfig *common.ClusterTlsConfig, ctx context.Context) (*astraConnectionConfigImpl, error) {
+	fileMap, err := extractFilesFromZipArchive(clusterTlsConfig.SecureConnectBundlePath)
 	if err != nil {
 		return nil, err
 	}
@@ -146,6 +163,7 @@ func initializeAstraConnectionConfig(
 	if err != nil {
 		return nil, err
 	}
+	tlsConfig = applyTlsProtocolOptions(tlsConfig, clusterTlsConfig.MinVersion, clusterTlsConfig.CipherSuites)
 
 	connConfig := &astraConnectionConfigImpl{
 		baseConnectionConfig: newBaseConnectionConfig(tlsConfig, connectionTimeoutMs, clusterType),
@@ -0,0 +1,30 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+	"net"
+	"testing"
+)
+
+func TestGenericConnectionConfig_CreateEndpoint(t *testing.T) {
+	clusterTlsConfig := &common.ClusterTlsConfig{}
+	connConfig, err := InitializeConnectionConfig(clusterTlsConfig, []string{"10.0.0.1:19042", "10.0.0.2", "[::1]:19043"},
+		9042, map[string]int{"10.0.0.1": 19042, "10.0.0.3": 19044}, 1000, common.ClusterTypeOrigin, "", nil, nil)
+	require.Nil(t, err)
+
+	var contactPoints []string
+	for _, endpoint := range connConfig.GetContactPoints() {
+		contactPoints = append(contactPoints, endpoint.GetSocketEndpoint())
+	}
+	require.Equal(t, []string{"10.0.0.1:19042", "10.0.0.2:9042", "[::1]:19043"}, contactPoints)
+
+	newHost := func(addr string) *Host {
+		return NewHost(net.ParseIP(addr), 9042, uuid.New(), "dc1", "rack1", nil, nil, nil)
+	}
+	require.Equal(t, "10.0.0.1:19042", connConfig.CreateEndpoint(newHost("10.0.0.1")).GetSocketEndpoint())
+	require.Equal(t, "10.0.0.2:9042", connConfig.CreateEndpoint(newHost("10.0.0.2")).GetSocketEndpoint())
+	require.Equal(t, "10.0.0.3:19044", connConfig.CreateEndpoint(newHost("10.0.0.3")).GetSocketEndpoint())
+	require.Equal(t, "[::2]:9042", connConfig.CreateEndpoint(newHost("::2")).GetSocketEndpoint())
+}
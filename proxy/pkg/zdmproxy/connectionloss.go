@@ -0,0 +1,66 @@
+package zdmproxy
+
+import (
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"sync/atomic"
+	"time"
+)
+
+// clientConnectionFlushTimeout bounds the time spent writing the pending responses before closing a client connection
+const clientConnectionFlushTimeout = 2 * time.Second
+
+// markConnectionLost records that the connection to the node was closed while the client handler was still running
+// (the node went down, the connection was reset or the watchdog closed it). The requests that are in flight when the
+// client handler shuts down as a consequence fail with an OVERLOADED error instead of never getting a response.
+//
+// The async connector is never marked, losing it doesn't shut down the client handler.
+func (cc *ClusterConnector) markConnectionLost() {
+	if cc.asyncConnector || cc.clusterConnContext.Err() != nil {
+		return
+	}
+	atomic.StoreInt32(&cc.connectionLost, 1)
+}
+
+func (cc *ClusterConnector) isConnectionLost() bool {
+	return atomic.LoadInt32(&cc.connectionLost) == 1
+}
+
+// getLostClusterConnector returns the origin or target connector whose connection was lost, nil if the client handler
+// is being shut down for another reason (client disconnected, proxy shutdown, etc.).
+func (ch *ClientHandler) getLostClusterConnector() *ClusterConnector {
+	if ch.originCassandraConnector.isConnectionLost() {
+		return ch.originCassandraConnector
+	}
+	if ch.targetCassandraConnector.isConnectionLost() {
+		return ch.targetCassandraConnector
+	}
+	return nil
+}
+
+// sendConnectionLostError answers an in flight request that is about to be canceled with an OVERLOADED error so that
+// the driver retries it on another proxy instance right away instead of waiting for its own timeout.
+func (ch *ClientHandler) sendConnectionLostError(reqCtx *requestContextImpl, lostConnector *ClusterConnector) {
+	if reqCtx.customResponseChannel != nil || reqCtx.request == nil {
+		// internal request, the client didn't send it
+		return
+	}
+	request := reqCtx.request
+	if reqCtx.hedged {
+		request = setRawFrameStreamId(request, reqCtx.clientStreamId)
+	}
+	log.Debugf("Failing in flight request %v because the connection to %v was lost.",
+		request.Header, lostConnector.clusterType)
+	ch.clientConnector.sendOverloadedMessageToClient(request, getConnectionLostErrorMessage(lostConnector))
+
+	nodeMetricsInstance, err := GetNodeMetricsByClusterConnector(ch.nodeMetrics, lostConnector.connectorType)
+	if err != nil {
+		log.Errorf("Failed to track connection loss metrics: %v.", err)
+	} else if nodeMetricsInstance.ConnectionLossFailedRequests != nil {
+		nodeMetricsInstance.ConnectionLossFailedRequests.Add(1)
+	}
+}
+
+func getConnectionLostErrorMessage(lostConnector *ClusterConnector) string {
+	return fmt.Sprintf("Connection to the %v cluster was lost, please retry on next host.", lostConnector.clusterType)
+}
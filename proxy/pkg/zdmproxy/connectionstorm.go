@@ -0,0 +1,97 @@
+package zdmproxy
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// connectionStormLimiter protects the STARTUP and authentication path when thousands of clients reconnect at the same
+// time, e.g. after a load balancer switched to this proxy. The listener accepts at most
+// ZDM_PROXY_CONNECTION_ACCEPT_RATE_LIMIT connections per second, the other connections wait in the accept backlog, and
+// at most ZDM_PROXY_MAX_CONCURRENT_HANDSHAKES connections are in the handshake phase (from the moment they are accepted
+// until the handshake succeeds or the connection is closed). The accepted connections wait for a handshake slot in
+// FIFO order for up to ZDM_PROXY_HANDSHAKE_QUEUE_TIMEOUT_MS, they are closed if they don't get one.
+type connectionStormLimiter struct {
+	acceptRate   *requestRateLimiter
+	handshakes   chan struct{}
+	queueTimeout time.Duration
+}
+
+// newConnectionStormLimiter returns nil if neither the accept rate nor the concurrent handshakes are limited.
+func newConnectionStormLimiter(acceptRate int, maxHandshakes int, queueTimeout time.Duration) *connectionStormLimiter {
+	if acceptRate <= 0 && maxHandshakes <= 0 {
+		return nil
+	}
+	l := &connectionStormLimiter{queueTimeout: queueTimeout}
+	if acceptRate > 0 {
+		l.acceptRate = newRequestRateLimiter(acceptRate, time.Now)
+	}
+	if maxHandshakes > 0 {
+		l.handshakes = make(chan struct{}, maxHandshakes)
+	}
+	return l
+}
+
+// waitToAccept blocks until the accept rate allows a new connection, it returns false if stopped returns true while
+// waiting (the listener is closed).
+func (l *connectionStormLimiter) waitToAccept(stopped func() bool) bool {
+	if l == nil || l.acceptRate == nil {
+		return true
+	}
+	interval := time.Second / time.Duration(l.acceptRate.GetRate())
+	for !l.acceptRate.Allow() {
+		if stopped() {
+			return false
+		}
+		time.Sleep(interval)
+	}
+	return true
+}
+
+// acquireHandshake waits for a handshake slot, it returns false if the wait exceeded the queue timeout or if ctx is
+// done. The slot is nil if the concurrent handshakes are not limited.
+func (l *connectionStormLimiter) acquireHandshake(ctx context.Context) (*handshakeSlot, bool) {
+	if l == nil || l.handshakes == nil {
+		return nil, true
+	}
+	select {
+	case l.handshakes <- struct{}{}:
+		return &handshakeSlot{handshakes: l.handshakes, once: &sync.Once{}}, true
+	default:
+	}
+
+	timer := time.NewTimer(l.queueTimeout)
+	defer timer.Stop()
+	select {
+	case l.handshakes <- struct{}{}:
+		return &handshakeSlot{handshakes: l.handshakes, once: &sync.Once{}}, true
+	case <-timer.C:
+		return nil, false
+	case <-ctx.Done():
+		return nil, false
+	}
+}
+
+func (l *connectionStormLimiter) getHandshakesInProgress() float64 {
+	if l == nil || l.handshakes == nil {
+		return 0
+	}
+	return float64(len(l.handshakes))
+}
+
+// handshakeSlot is held by a client connection during its handshake.
+type handshakeSlot struct {
+	handshakes chan struct{}
+	once       *sync.Once
+}
+
+// release frees the slot, it can be called more than once and on a nil slot.
+func (s *handshakeSlot) release() {
+	if s == nil {
+		return
+	}
+	s.once.Do(func() {
+		<-s.handshakes
+	})
+}
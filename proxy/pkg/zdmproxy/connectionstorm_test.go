@@ -0,0 +1,72 @@
+package zdmproxy
+
+import (
+	"context"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestConnectionStormLimiter_Handshakes(t *testing.T) {
+	require.Nil(t, newConnectionStormLimiter(0, 0, time.Second))
+	var disabled *connectionStormLimiter
+	slot, ok := disabled.acquireHandshake(context.Background())
+	require.True(t, ok)
+	slot.release()
+	require.True(t, disabled.waitToAccept(func() bool { return true }))
+
+	limiter := newConnectionStormLimiter(0, 2, 50*time.Millisecond)
+	first, ok := limiter.acquireHandshake(context.Background())
+	require.True(t, ok)
+	second, ok := limiter.acquireHandshake(context.Background())
+	require.True(t, ok)
+	require.Equal(t, 2.0, limiter.getHandshakesInProgress())
+
+	// the queue timeout elapses
+	before := time.Now()
+	_, ok = limiter.acquireHandshake(context.Background())
+	require.False(t, ok)
+	require.GreaterOrEqual(t, time.Since(before), 50*time.Millisecond)
+
+	// a queued connection gets the slot that is released
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		first.release()
+	}()
+	third, ok := limiter.acquireHandshake(context.Background())
+	require.True(t, ok)
+	// releasing twice doesn't free another slot
+	first.release()
+	require.Equal(t, 2.0, limiter.getHandshakesInProgress())
+
+	ctx, cancelFn := context.WithCancel(context.Background())
+	cancelFn()
+	_, ok = limiter.acquireHandshake(ctx)
+	require.False(t, ok)
+
+	second.release()
+	third.release()
+	require.Equal(t, 0.0, limiter.getHandshakesInProgress())
+}
+
+func TestConnectionStormLimiter_AcceptRate(t *testing.T) {
+	limiter := newConnectionStormLimiter(20, 0, 0)
+	notStopped := func() bool { return false }
+	before := time.Now()
+	// the bucket holds one second of connections
+	for i := 0; i < 20; i++ {
+		require.True(t, limiter.waitToAccept(notStopped))
+	}
+	require.Less(t, time.Since(before), 40*time.Millisecond)
+	require.True(t, limiter.waitToAccept(notStopped))
+	require.GreaterOrEqual(t, time.Since(before), 40*time.Millisecond)
+
+	for i := 0; i < 20; i++ {
+		limiter.acceptRate.Allow()
+	}
+	require.False(t, limiter.waitToAccept(func() bool { return true }))
+
+	slot, ok := limiter.acquireHandshake(context.Background())
+	require.True(t, ok)
+	require.Nil(t, slot)
+}
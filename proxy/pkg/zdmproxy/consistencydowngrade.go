@@ -0,0 +1,236 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+type consistencyDowngrade struct {
+	from  primitive.ConsistencyLevel
+	to    primitive.ConsistencyLevel
+	cause string
+}
+
+func (recv *consistencyDowngrade) warning(cluster common.ClusterType) string {
+	return fmt.Sprintf("Request was retried on %v with consistency level %v instead of %v after a %v error.",
+		cluster, consistencyLevelNames[recv.to], consistencyLevelNames[recv.from], recv.cause)
+}
+
+var consistencyLevelNames = map[primitive.ConsistencyLevel]string{
+	primitive.ConsistencyLevelAny:         "ANY",
+	primitive.ConsistencyLevelOne:         "ONE",
+	primitive.ConsistencyLevelTwo:         "TWO",
+	primitive.ConsistencyLevelThree:       "THREE",
+	primitive.ConsistencyLevelQuorum:      "QUORUM",
+	primitive.ConsistencyLevelAll:         "ALL",
+	primitive.ConsistencyLevelLocalQuorum: "LOCAL_QUORUM",
+	primitive.ConsistencyLevelEachQuorum:  "EACH_QUORUM",
+	primitive.ConsistencyLevelSerial:      "SERIAL",
+	primitive.ConsistencyLevelLocalSerial: "LOCAL_SERIAL",
+	primitive.ConsistencyLevelLocalOne:    "LOCAL_ONE",
+}
+
+// number of replicas required by the consistency levels that don't depend on the replication factor
+var fixedConsistencyLevelReplicas = map[primitive.ConsistencyLevel]int{
+	primitive.ConsistencyLevelOne:   1,
+	primitive.ConsistencyLevelTwo:   2,
+	primitive.ConsistencyLevelThree: 3,
+}
+
+// computeConsistencyDowngrade returns the consistency level that the request should be retried with after the provided
+// error or nil if the request should not be retried. It follows the same rules as the "downgrading consistency"
+// retry policy of the DataStax drivers: the new consistency level is based on the number of replicas that were known
+// to be available or that acknowledged the request.
+func computeConsistencyDowngrade(current primitive.ConsistencyLevel, errMsg message.Error) *consistencyDowngrade {
+	var knownOk int32
+	var cause string
+	switch typedErr := errMsg.(type) {
+	case *message.Unavailable:
+		knownOk = typedErr.Alive
+		cause = "UNAVAILABLE"
+	case *message.ReadTimeout:
+		if typedErr.Received >= typedErr.BlockFor {
+			// enough replicas responded, a retry at a lower consistency level would not help
+			return nil
+		}
+		knownOk = typedErr.Received
+		cause = "READ_TIMEOUT"
+	case *message.WriteTimeout:
+		// retrying other write types could apply the mutation twice (e.g. counters) or break batch atomicity
+		if typedErr.WriteType != primitive.WriteTypeUnloggedBatch {
+			return nil
+		}
+		knownOk = typedErr.Received
+		cause = "WRITE_TIMEOUT"
+	default:
+		return nil
+	}
+
+	var downgraded primitive.ConsistencyLevel
+	switch current {
+	case primitive.ConsistencyLevelSerial, primitive.ConsistencyLevelLocalSerial,
+		primitive.ConsistencyLevelAny, primitive.ConsistencyLevelOne, primitive.ConsistencyLevelLocalOne:
+		return nil
+	case primitive.ConsistencyLevelLocalQuorum, primitive.ConsistencyLevelEachQuorum:
+		if knownOk <= 0 {
+			return nil
+		}
+		downgraded = primitive.ConsistencyLevelLocalOne
+	default:
+		switch {
+		case knownOk >= 3:
+			downgraded = primitive.ConsistencyLevelThree
+		case knownOk == 2:
+			downgraded = primitive.ConsistencyLevelTwo
+		case knownOk == 1:
+			downgraded = primitive.ConsistencyLevelOne
+		default:
+			return nil
+		}
+	}
+
+	if currentReplicas, ok := fixedConsistencyLevelReplicas[current]; ok {
+		if fixedConsistencyLevelReplicas[downgraded] >= currentReplicas {
+			return nil
+		}
+	}
+
+	return &consistencyDowngrade{from: current, to: downgraded, cause: cause}
+}
+
+// getRequestConsistency returns the consistency level of QUERY, EXECUTE and BATCH requests.
+func getRequestConsistency(msg message.Message) (primitive.ConsistencyLevel, bool) {
+	switch typedMsg := msg.(type) {
+	case *message.Query:
+		if typedMsg.Options == nil {
+			return primitive.ConsistencyLevelOne, true
+		}
+		return typedMsg.Options.Consistency, true
+	case *message.Execute:
+		if typedMsg.Options == nil {
+			return primitive.ConsistencyLevelOne, true
+		}
+		return typedMsg.Options.Consistency, true
+	case *message.Batch:
+		return typedMsg.Consistency, true
+	default:
+		return 0, false
+	}
+}
+
+func setRequestConsistency(msg message.Message, consistency primitive.ConsistencyLevel) bool {
+	switch typedMsg := msg.(type) {
+	case *message.Query:
+		if typedMsg.Options == nil {
+			typedMsg.Options = &message.QueryOptions{}
+		}
+		typedMsg.Options.Consistency = consistency
+	case *message.Execute:
+		if typedMsg.Options == nil {
+			typedMsg.Options = &message.QueryOptions{}
+		}
+		typedMsg.Options.Consistency = consistency
+	case *message.Batch:
+		typedMsg.Consistency = consistency
+	default:
+		return false
+	}
+	return true
+}
+
+// tryRetryWithDowngradedConsistency checks if the response is an UNAVAILABLE, READ_TIMEOUT or WRITE_TIMEOUT error that
+// can be retried with a lower consistency level and, if so, sends the request again to the same cluster.
+//
+// Returns true if the request was retried in which case the response should be discarded.
+func (ch *ClientHandler) tryRetryWithDowngradedConsistency(
+	response *Response, clusterType common.ClusterType, reqCtx RequestContext) bool {
+	if response.responseFrame.Header.OpCode != primitive.OpCodeError {
+		return false
+	}
+
+	typedReqCtx, ok := reqCtx.(*requestContextImpl)
+	if !ok {
+		return false
+	}
+
+	request := typedReqCtx.GetClusterRequest(clusterType)
+	if request == nil {
+		return false
+	}
+
+	switch request.Header.OpCode {
+	case primitive.OpCodeQuery, primitive.OpCodeExecute, primitive.OpCodeBatch:
+	default:
+		return false
+	}
+
+	if !typedReqCtx.deadline.IsZero() && !time.Now().Before(typedReqCtx.deadline) {
+		log.Debugf("Not retrying %v request (stream id %d) on %v with a lower consistency level because its "+
+			"deadline elapsed.", request.Header.OpCode, request.Header.StreamId, clusterType)
+		return false
+	}
+
+	errMsg, err := decodeError(response.responseFrame)
+	if err != nil || errMsg == nil {
+		return false
+	}
+
+	decodedRequest, err := defaultCodec.ConvertFromRawFrame(request)
+	if err != nil {
+		log.Warnf("Could not decode %v request to retry it with a lower consistency level: %v",
+			request.Header.OpCode, err)
+		return false
+	}
+
+	currentConsistency, ok := getRequestConsistency(decodedRequest.Body.Message)
+	if !ok {
+		return false
+	}
+
+	downgrade := computeConsistencyDowngrade(currentConsistency, errMsg)
+	if downgrade == nil {
+		return false
+	}
+
+	setRequestConsistency(decodedRequest.Body.Message, downgrade.to)
+	newRequest, err := defaultCodec.ConvertToRawFrame(decodedRequest)
+	if err != nil {
+		log.Warnf("Could not encode %v request to retry it with a lower consistency level: %v",
+			request.Header.OpCode, err)
+		return false
+	}
+
+	if !typedReqCtx.SetConsistencyDowngrade(clusterType, downgrade) {
+		return false
+	}
+
+	log.Debugf("Retrying %v request (stream id %d) on %v with consistency level %v instead of %v after error: %v",
+		request.Header.OpCode, request.Header.StreamId, clusterType, downgrade.to, downgrade.from, errMsg)
+
+	switch response.connectorType {
+	case ClusterConnectorTypeOrigin:
+		ch.originCassandraConnector.sendRequestToCluster(newRequest)
+	case ClusterConnectorTypeTarget:
+		ch.targetCassandraConnector.sendRequestToCluster(newRequest)
+	}
+	return true
+}
+
+// addConsistencyDowngradeWarnings appends a warning to the client response for each cluster on which the request was
+// retried with a lower consistency level. Warnings are not supported by protocol versions older than v4 so the
+// response is returned as is in that case.
+func addConsistencyDowngradeWarnings(
+	response *frame.RawFrame, downgrades map[common.ClusterType]*consistencyDowngrade) (*frame.RawFrame, error) {
+	var warnings []string
+	for _, cluster := range []common.ClusterType{common.ClusterTypeOrigin, common.ClusterTypeTarget} {
+		if downgrade, ok := downgrades[cluster]; ok {
+			warnings = append(warnings, downgrade.warning(cluster))
+		}
+	}
+	return addResponseWarnings(response, warnings...)
+}
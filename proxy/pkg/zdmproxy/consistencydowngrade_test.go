@@ -0,0 +1,144 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestComputeConsistencyDowngrade(t *testing.T) {
+	tests := []struct {
+		name     string
+		current  primitive.ConsistencyLevel
+		errMsg   message.Error
+		expected primitive.ConsistencyLevel
+		retry    bool
+	}{
+		{"unavailable quorum 1 alive", primitive.ConsistencyLevelQuorum,
+			&message.Unavailable{Required: 2, Alive: 1}, primitive.ConsistencyLevelOne, true},
+		{"unavailable all 2 alive", primitive.ConsistencyLevelAll,
+			&message.Unavailable{Required: 3, Alive: 2}, primitive.ConsistencyLevelTwo, true},
+		{"unavailable all 3 alive", primitive.ConsistencyLevelAll,
+			&message.Unavailable{Required: 5, Alive: 3}, primitive.ConsistencyLevelThree, true},
+		{"unavailable all 4 alive", primitive.ConsistencyLevelAll,
+			&message.Unavailable{Required: 5, Alive: 4}, primitive.ConsistencyLevelThree, true},
+		{"unavailable none alive", primitive.ConsistencyLevelQuorum,
+			&message.Unavailable{Required: 2, Alive: 0}, 0, false},
+		{"unavailable local quorum", primitive.ConsistencyLevelLocalQuorum,
+			&message.Unavailable{Required: 2, Alive: 1}, primitive.ConsistencyLevelLocalOne, true},
+		{"unavailable each quorum", primitive.ConsistencyLevelEachQuorum,
+			&message.Unavailable{Required: 4, Alive: 3}, primitive.ConsistencyLevelLocalOne, true},
+		{"unavailable one", primitive.ConsistencyLevelOne,
+			&message.Unavailable{Required: 1, Alive: 0}, 0, false},
+		{"unavailable serial", primitive.ConsistencyLevelSerial,
+			&message.Unavailable{Required: 2, Alive: 1}, 0, false},
+		{"unavailable local serial", primitive.ConsistencyLevelLocalSerial,
+			&message.Unavailable{Required: 2, Alive: 1}, 0, false},
+		{"unavailable three with 2 alive", primitive.ConsistencyLevelThree,
+			&message.Unavailable{Required: 3, Alive: 2}, primitive.ConsistencyLevelTwo, true},
+		{"unavailable two with 3 alive is not a downgrade", primitive.ConsistencyLevelTwo,
+			&message.Unavailable{Required: 2, Alive: 3}, 0, false},
+		{"read timeout not enough received", primitive.ConsistencyLevelQuorum,
+			&message.ReadTimeout{Received: 1, BlockFor: 2}, primitive.ConsistencyLevelOne, true},
+		{"read timeout enough received", primitive.ConsistencyLevelQuorum,
+			&message.ReadTimeout{Received: 2, BlockFor: 2, DataPresent: false}, 0, false},
+		{"read timeout none received", primitive.ConsistencyLevelQuorum,
+			&message.ReadTimeout{Received: 0, BlockFor: 2}, 0, false},
+		{"write timeout unlogged batch", primitive.ConsistencyLevelQuorum,
+			&message.WriteTimeout{Received: 1, BlockFor: 2, WriteType: primitive.WriteTypeUnloggedBatch},
+			primitive.ConsistencyLevelOne, true},
+		{"write timeout simple", primitive.ConsistencyLevelQuorum,
+			&message.WriteTimeout{Received: 1, BlockFor: 2, WriteType: primitive.WriteTypeSimple}, 0, false},
+		{"write timeout counter", primitive.ConsistencyLevelQuorum,
+			&message.WriteTimeout{Received: 1, BlockFor: 2, WriteType: primitive.WriteTypeCounter}, 0, false},
+		{"server error", primitive.ConsistencyLevelQuorum,
+			&message.ServerError{ErrorMessage: "test"}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			downgrade := computeConsistencyDowngrade(tt.current, tt.errMsg)
+			if !tt.retry {
+				require.Nil(t, downgrade)
+				return
+			}
+			require.NotNil(t, downgrade)
+			require.Equal(t, tt.current, downgrade.from)
+			require.Equal(t, tt.expected, downgrade.to)
+		})
+	}
+}
+
+func TestRequestConsistency(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  message.Message
+	}{
+		{"query", &message.Query{Query: "SELECT * FROM ks.tb", Options: &message.QueryOptions{
+			Consistency: primitive.ConsistencyLevelQuorum}}},
+		{"execute", &message.Execute{QueryId: []byte{1}, Options: &message.QueryOptions{
+			Consistency: primitive.ConsistencyLevelQuorum}}},
+		{"batch", &message.Batch{Consistency: primitive.ConsistencyLevelQuorum}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			consistency, ok := getRequestConsistency(tt.msg)
+			require.True(t, ok)
+			require.Equal(t, primitive.ConsistencyLevelQuorum, consistency)
+
+			require.True(t, setRequestConsistency(tt.msg, primitive.ConsistencyLevelOne))
+			consistency, ok = getRequestConsistency(tt.msg)
+			require.True(t, ok)
+			require.Equal(t, primitive.ConsistencyLevelOne, consistency)
+		})
+	}
+
+	_, ok := getRequestConsistency(&message.Prepare{Query: "SELECT * FROM ks.tb"})
+	require.False(t, ok)
+	require.False(t, setRequestConsistency(&message.Prepare{Query: "SELECT * FROM ks.tb"}, primitive.ConsistencyLevelOne))
+
+	consistency, ok := getRequestConsistency(&message.Query{Query: "SELECT * FROM ks.tb"})
+	require.True(t, ok)
+	require.Equal(t, primitive.ConsistencyLevelOne, consistency)
+}
+
+func TestAddConsistencyDowngradeWarnings(t *testing.T) {
+	downgrades := map[common.ClusterType]*consistencyDowngrade{
+		common.ClusterTypeTarget: {
+			from:  primitive.ConsistencyLevelQuorum,
+			to:    primitive.ConsistencyLevelOne,
+			cause: "UNAVAILABLE",
+		},
+	}
+
+	responseFrame := frame.NewFrame(primitive.ProtocolVersion4, 1, &message.VoidResult{})
+	responseFrame.SetWarnings([]string{"existing warning"})
+	rawResponse, err := defaultCodec.ConvertToRawFrame(responseFrame)
+	require.Nil(t, err)
+
+	newRawResponse, err := addConsistencyDowngradeWarnings(rawResponse, downgrades)
+	require.Nil(t, err)
+	newResponse, err := defaultCodec.ConvertFromRawFrame(newRawResponse)
+	require.Nil(t, err)
+	require.Equal(t, int16(1), newResponse.Header.StreamId)
+	require.Equal(t, &message.VoidResult{}, newResponse.Body.Message)
+	require.Equal(t, []string{
+		"existing warning",
+		"Request was retried on TARGET with consistency level ONE instead of QUORUM after a UNAVAILABLE error.",
+	}, newResponse.Body.Warnings)
+
+	v3Response, err := defaultCodec.ConvertToRawFrame(
+		frame.NewFrame(primitive.ProtocolVersion3, 1, &message.VoidResult{}))
+	require.Nil(t, err)
+	newRawResponse, err = addConsistencyDowngradeWarnings(v3Response, downgrades)
+	require.Nil(t, err)
+	require.Same(t, v3Response, newRawResponse)
+
+	newRawResponse, err = addConsistencyDowngradeWarnings(rawResponse, nil)
+	require.Nil(t, err)
+	require.Same(t, rawResponse, newRawResponse)
+}
@@ -49,11 +49,13 @@ type ControlConn struct {
 	systemLocalColumnData    map[string]*optionalColumn
 	systemPeersColumnNames   map[string]bool
 	virtualHosts             []*VirtualHost
+	supportedOptions         map[string][]string
 	proxyRand                *rand.Rand
 	reconnectCh              chan bool
 	protocolEventSubscribers map[ProtocolEventObserver]interface{}
 	authEnabled              *atomic.Value
 	metricsHandler           *metrics.MetricHandler
+	health                   *clusterHealth
 }
 
 const ProxyVirtualRack = "rack0"
@@ -67,6 +69,10 @@ func NewControlConn(ctx context.Context, defaultPort int, connConfig ConnectionC
 	metricsHandler *metrics.MetricHandler) *ControlConn {
 	authEnabled := &atomic.Value{}
 	authEnabled.Store(true)
+	healthMetrics := metricsHandler.GetProxyMetrics().OriginClusterHealth
+	if connConfig.GetClusterType() == common.ClusterTypeTarget {
+		healthMetrics = metricsHandler.GetProxyMetrics().TargetClusterHealth
+	}
 	return &ControlConn{
 		conf:           conf,
 		topologyConfig: topologyConfig,
@@ -97,19 +103,23 @@ func NewControlConn(ctx context.Context, defaultPort int, connConfig ConnectionC
 		systemLocalColumnData:    nil,
 		systemPeersColumnNames:   nil,
 		virtualHosts:             nil,
+		supportedOptions:         nil,
 		proxyRand:                proxyRand,
 		reconnectCh:              make(chan bool, 1),
 		protocolEventSubscribers: map[ProtocolEventObserver]interface{}{},
 		authEnabled:              authEnabled,
 		metricsHandler:           metricsHandler,
+		health:                   newClusterHealth(connConfig.GetClusterType(), conf.HeartbeatFailureThreshold, healthMetrics),
 	}
 }
 
 func (cc *ControlConn) Start(wg *sync.WaitGroup, ctx context.Context) error {
 	_, err := cc.Open(true, ctx)
 	if err != nil {
+		cc.health.failed(clusterHealthReasonConnectionFailed, cc.conf.HeartbeatFailureThreshold, err)
 		return err
 	}
+	cc.health.succeeded(clusterHealthReasonConnected)
 
 	wg.Add(1)
 	go func() {
@@ -185,13 +195,13 @@ func (cc *ControlConn) Start(wg *sync.WaitGroup, ctx context.Context) error {
 					timeUntilRetry := cc.retryBackoffPolicy.Duration()
 					log.Errorf("Failed to open control connection to %v, retrying in %v: %v",
 						cc.connConfig.GetClusterType(), timeUntilRetry, err)
-					cc.IncrementFailureCounter()
+					cc.recordFailure(clusterHealthReasonConnectionFailed, err)
 					sleepWithContext(timeUntilRetry, cc.context, nil)
 					continue
 				} else {
 					lastOpenSuccessful = true
 					conn = newConn
-					cc.ResetFailureCounter()
+					cc.recordSuccess(clusterHealthReasonConnected)
 					cc.retryBackoffPolicy.Reset()
 				}
 			}
@@ -203,13 +213,13 @@ func (cc *ControlConn) Start(wg *sync.WaitGroup, ctx context.Context) error {
 
 			if err != nil {
 				log.Warnf("Heartbeat failed on %v. Closing and opening a new connection: %v.", conn, err)
-				cc.IncrementFailureCounter()
+				cc.recordFailure(clusterHealthReasonHeartbeatFailed, err)
 				cc.Close()
 			} else {
 				logMsg := "Heartbeat successful on %v, waiting %v until next heartbeat."
 				if cc.ReadFailureCounter() != 0 {
 					log.Infof(logMsg, conn, cc.heartbeatPeriod)
-					cc.ResetFailureCounter()
+					cc.recordSuccess(clusterHealthReasonHeartbeatSucceeded)
 				} else {
 					log.Debugf(logMsg, conn, cc.heartbeatPeriod)
 				}
@@ -232,18 +242,49 @@ func (cc *ControlConn) IsAuthEnabled() (bool, error) {
 func (cc *ControlConn) IncrementFailureCounter() {
 	cc.counterLock.Lock()
 	defer cc.counterLock.Unlock()
+	cc.incrementFailureCounterLocked()
+}
+
+func (cc *ControlConn) incrementFailureCounterLocked() {
 	cc.consecutiveFailures++
 	if cc.consecutiveFailures < 0 {
 		cc.consecutiveFailures = math.MaxInt32
 	}
 }
 
+// recordFailure increments the failure counter and updates the health state while holding the counter lock so that
+// the readiness report never has a failure count that disagrees with the state.
+func (cc *ControlConn) recordFailure(reason string, err error) {
+	cc.counterLock.Lock()
+	defer cc.counterLock.Unlock()
+	cc.incrementFailureCounterLocked()
+	cc.health.failed(reason, cc.consecutiveFailures, err)
+}
+
+func (cc *ControlConn) recordSuccess(reason string) {
+	cc.counterLock.Lock()
+	defer cc.counterLock.Unlock()
+	cc.consecutiveFailures = 0
+	cc.health.succeeded(reason)
+}
+
 func (cc *ControlConn) ResetFailureCounter() {
 	cc.counterLock.Lock()
 	defer cc.counterLock.Unlock()
 	cc.consecutiveFailures = 0
 }
 
+// OnHealthChanged sets the function that is called after each change of the health state of the cluster, it must not
+// block.
+func (cc *ControlConn) OnHealthChanged(listener func(health ClusterHealth)) {
+	cc.health.setListener(listener)
+}
+
+// GetHealth returns the health state of the cluster, see ClusterHealthState.
+func (cc *ControlConn) GetHealth() ClusterHealth {
+	return cc.health.get()
+}
+
 func (cc *ControlConn) ReadFailureCounter() int {
 	cc.counterLock.RLock()
 	defer cc.counterLock.RUnlock()
@@ -260,10 +301,11 @@ func (cc *ControlConn) Open(contactPointsOnly bool, ctx context.Context) (CqlCon
 	var conn CqlConnection
 	var endpoint Endpoint
 	var triedEndpoints []Endpoint
+	var lastErr error
 
 	if contactPointsOnly {
 		contactPoints := cc.connConfig.GetContactPoints()
-		conn, endpoint = cc.openInternal(contactPoints, ctx)
+		conn, endpoint, lastErr = cc.openInternal(contactPoints, ctx)
 		triedEndpoints = contactPoints
 	} else {
 		allEndpointsById := make(map[string]Endpoint)
@@ -285,32 +327,38 @@ func (cc *ControlConn) Open(contactPointsOnly bool, ctx context.Context) (CqlCon
 		}
 
 		if len(hostEndpoints) > 0 {
-			conn, endpoint = cc.openInternal(hostEndpoints, ctx)
+			conn, endpoint, lastErr = cc.openInternal(hostEndpoints, ctx)
 			triedEndpoints = hostEndpoints
 		}
 
 		if conn == nil && len(contactPointsNotInHosts) > 0 {
-			conn, endpoint = cc.openInternal(contactPointsNotInHosts, ctx)
+			conn, endpoint, lastErr = cc.openInternal(contactPointsNotInHosts, ctx)
 			triedEndpoints = append(triedEndpoints, contactPointsNotInHosts...)
 		}
 	}
 
 	if conn == nil {
-		return nil, fmt.Errorf("could not open control connection to %v, tried endpoints: %v",
-			cc.connConfig.GetClusterType(), triedEndpoints)
+		if lastErr == nil {
+			return nil, fmt.Errorf("could not open control connection to %v, tried endpoints: %v",
+				cc.connConfig.GetClusterType(), triedEndpoints)
+		}
+		return nil, fmt.Errorf("could not open control connection to %v, tried endpoints: %v, last error: %w",
+			cc.connConfig.GetClusterType(), triedEndpoints, lastErr)
 	}
 
 	conn, endpoint = cc.setConn(oldConn, conn, endpoint)
 	return conn, nil
 }
 
-func (cc *ControlConn) openInternal(endpoints []Endpoint, ctx context.Context) (CqlConnection, Endpoint) {
+// openInternal returns the error of the last endpoint that was tried if none of them could be used.
+func (cc *ControlConn) openInternal(endpoints []Endpoint, ctx context.Context) (CqlConnection, Endpoint, error) {
 	if ctx == nil {
 		ctx = cc.context
 	}
 
 	var conn CqlConnection
 	var endpoint Endpoint
+	var lastErr error
 
 	firstEndpointIndex := cc.proxyRand.Intn(len(endpoints))
 	for i := 0; i < len(endpoints); i++ {
@@ -324,10 +372,11 @@ func (cc *ControlConn) openInternal(endpoints []Endpoint, ctx context.Context) (
 		if err != nil {
 			log.Warnf("Failed to open control connection to %v using endpoint %v: %v",
 				cc.connConfig.GetClusterType(), endpoint.GetEndpointIdentifier(), err)
+			lastErr = err
 			continue
 		}
 
-		newConn := NewCqlConnection(tcpConn, cc.username, cc.password, ccReadTimeout, ccWriteTimeout, cc.conf)
+		newConn := NewCqlConnection(tcpConn, cc.connConfig.GetClusterType(), cc.username, cc.password, ccReadTimeout, ccWriteTimeout, cc.conf)
 		err = newConn.InitializeContext(ccProtocolVersion, ctx)
 		if err == nil {
 			newConn.SetEventHandler(func(f *frame.Frame, c CqlConnection) {
@@ -348,6 +397,9 @@ func (cc *ControlConn) openInternal(endpoints []Endpoint, ctx context.Context) (
 			if err == nil {
 				_, err = cc.RefreshHosts(newConn, ctx)
 			}
+			if err == nil {
+				cc.refreshSupportedOptions(newConn, ctx)
+			}
 		}
 
 		if err != nil {
@@ -360,16 +412,18 @@ func (cc *ControlConn) openInternal(endpoints []Endpoint, ctx context.Context) (
 				log.Errorf("Failed to close cql connection: %v", err2)
 			}
 
+			lastErr = err
 			continue
 		}
 
 		conn = newConn
 		log.Infof("Successfully opened control connection to %v using endpoint %v.",
 			cc.connConfig.GetClusterType(), endpoint.String())
+		lastErr = nil
 		break
 	}
 
-	return conn, endpoint
+	return conn, endpoint, lastErr
 }
 
 func (cc *ControlConn) Close() {
@@ -379,6 +433,7 @@ func (cc *ControlConn) Close() {
 	cc.cqlConnLock.Unlock()
 
 	if conn != nil {
+		cc.getOpenConnectionsGauge().Subtract(1)
 		err := conn.Close()
 		if err != nil {
 			log.Warnf("Failed to close connection (possible leaked connection): %v", err)
@@ -386,6 +441,13 @@ func (cc *ControlConn) Close() {
 	}
 }
 
+func (cc *ControlConn) getOpenConnectionsGauge() metrics.Gauge {
+	if cc.connConfig.GetClusterType() == common.ClusterTypeTarget {
+		return cc.metricsHandler.GetProxyMetrics().OpenTargetControlConnections
+	}
+	return cc.metricsHandler.GetProxyMetrics().OpenOriginControlConnections
+}
+
 func (cc *ControlConn) RefreshHosts(conn CqlConnection, ctx context.Context) ([]*Host, error) {
 	localQueryResult, err := conn.Query("SELECT * FROM system.local", GetDefaultGenericTypeCodec(), ccProtocolVersion, ctx)
 	if err != nil {
@@ -594,6 +656,33 @@ func (cc *ControlConn) GetSystemLocalColumnData() map[string]*optionalColumn {
 	return cc.systemLocalColumnData
 }
 
+// GetSupportedOptions returns the options of the last SUPPORTED response received on the control connection or nil
+// if the cluster didn't reply to the OPTIONS request yet.
+func (cc *ControlConn) GetSupportedOptions() map[string][]string {
+	cc.topologyLock.RLock()
+	defer cc.topologyLock.RUnlock()
+
+	return cc.supportedOptions
+}
+
+func (cc *ControlConn) refreshSupportedOptions(conn CqlConnection, ctx context.Context) {
+	response, err := conn.Execute(&message.Options{}, ctx)
+	if err != nil {
+		log.Warnf("Could not retrieve SUPPORTED options of %v: %v", cc.connConfig.GetClusterType(), err)
+		return
+	}
+
+	supported, ok := response.(*message.Supported)
+	if !ok {
+		log.Warnf("Expected SUPPORTED response from %v but got %v.", cc.connConfig.GetClusterType(), response)
+		return
+	}
+
+	cc.topologyLock.Lock()
+	cc.supportedOptions = supported.Options
+	cc.topologyLock.Unlock()
+}
+
 func (cc *ControlConn) GetSystemPeersColumnNames() map[string]bool {
 	cc.topologyLock.RLock()
 	defer cc.topologyLock.RUnlock()
@@ -612,6 +701,9 @@ func (cc *ControlConn) setConn(oldConn CqlConnection, newConn CqlConnection, new
 	cc.cqlConnLock.Lock()
 	defer cc.cqlConnLock.Unlock()
 	if cc.cqlConn == oldConn || oldConn == nil {
+		if cc.cqlConn == nil {
+			cc.getOpenConnectionsGauge().Add(1)
+		}
 		cc.cqlConn = newConn
 		cc.currentContactPoint = newContactPoint
 		authEnabled, err := newConn.IsAuthEnabled()
@@ -636,6 +728,26 @@ func (cc *ControlConn) setConn(oldConn CqlConnection, newConn CqlConnection, new
 	return cc.cqlConn, cc.currentContactPoint
 }
 
+// Query runs a statement on the current connection of the control connection, it fails if the control connection is
+// reconnecting.
+func (cc *ControlConn) Query(cql string, ctx context.Context) (*ParsedRowSet, error) {
+	conn, _ := cc.getConnAndContactPoint()
+	if conn == nil {
+		return nil, fmt.Errorf("control connection to %v is not open", cc.connConfig.GetClusterType())
+	}
+	return conn.Query(cql, GetDefaultGenericTypeCodec(), ccProtocolVersion, ctx)
+}
+
+// Execute sends a request on the current connection of the control connection and returns the response message, it
+// fails if the control connection is reconnecting.
+func (cc *ControlConn) Execute(msg message.Message, ctx context.Context) (message.Message, error) {
+	conn, _ := cc.getConnAndContactPoint()
+	if conn == nil {
+		return nil, fmt.Errorf("control connection to %v is not open", cc.connConfig.GetClusterType())
+	}
+	return conn.Execute(msg, ctx)
+}
+
 func (cc *ControlConn) getConnAndContactPoint() (CqlConnection, Endpoint) {
 	cc.cqlConnLock.Lock()
 	conn := cc.cqlConn
@@ -7,6 +7,7 @@ import (
 	"github.com/datastax/go-cassandra-native-protocol/frame"
 	"github.com/datastax/go-cassandra-native-protocol/message"
 	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
 	"github.com/datastax/zdm-proxy/proxy/pkg/config"
 	log "github.com/sirupsen/logrus"
 	"io"
@@ -44,7 +45,7 @@ type cqlConn struct {
 	readTimeout           time.Duration
 	writeTimeout          time.Duration
 	conn                  net.Conn
-	credentials           *AuthCredentials
+	authenticator         Authenticator
 	initialized           bool
 	cancelFn              context.CancelFunc
 	ctx                   context.Context
@@ -71,6 +72,7 @@ func (c *cqlConn) String() string {
 
 func NewCqlConnection(
 	conn net.Conn,
+	clusterType common.ClusterType,
 	username string, password string,
 	readTimeout time.Duration, writeTimeout time.Duration,
 	conf *config.Config) CqlConnection {
@@ -79,10 +81,10 @@ func NewCqlConnection(
 		readTimeout:  readTimeout,
 		writeTimeout: writeTimeout,
 		conn:         conn,
-		credentials: &AuthCredentials{
+		authenticator: newClusterAuthenticator(conf, clusterType, &AuthCredentials{
 			Username: username,
 			Password: password,
-		},
+		}),
 		initialized:           false,
 		ctx:                   ctx,
 		cancelFn:              cFn,
@@ -328,7 +330,7 @@ func (c *cqlConn) PerformHandshake(version primitive.ProtocolVersion, ctx contex
 	log.Debug("performing handshake")
 	startup := frame.NewFrame(version, -1, message.NewStartup())
 	var response *frame.Frame
-	authenticator := &DsePlainTextAuthenticator{c.credentials}
+	authenticator := c.authenticator
 	authEnabled := false
 	if response, err = c.SendAndReceive(startup, ctx); err == nil {
 		switch response.Body.Message.(type) {
@@ -342,11 +344,15 @@ func (c *cqlConn) PerformHandshake(version primitive.ProtocolVersion, ctx contex
 			if err == nil {
 				if response, err = c.SendAndReceive(authResponse, ctx); err != nil {
 					err = fmt.Errorf("could not send AUTH RESPONSE: %w", err)
+				} else if authErr, ok := response.Body.Message.(*message.AuthenticationError); ok {
+					err = &AuthError{errMsg: authErr}
 				} else if _, authSuccess := response.Body.Message.(*message.AuthSuccess); !authSuccess {
 					authResponse, err = performHandshakeStep(authenticator, version, -1, response)
 					if err == nil {
 						if response, err = c.SendAndReceive(authResponse, ctx); err != nil {
 							err = fmt.Errorf("could not send AUTH RESPONSE: %w", err)
+						} else if authErr, ok := response.Body.Message.(*message.AuthenticationError); ok {
+							err = &AuthError{errMsg: authErr}
 						} else if _, authSuccess := response.Body.Message.(*message.AuthSuccess); !authSuccess {
 							err = fmt.Errorf("expected AUTH_SUCCESS, got %v", response.Body.Message)
 						}
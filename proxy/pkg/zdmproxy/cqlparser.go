@@ -69,6 +69,9 @@ func buildRequestInfo(
 	forwardSystemQueriesToTarget bool,
 	virtualizationEnabled bool,
 	forwardAuthToTarget bool,
+	routingHintsEnabled bool,
+	originQuirks *clusterQuirks,
+	targetQuirks *clusterQuirks,
 	timeUuidGenerator TimeUuidGenerator) (RequestInfo, error) {
 
 	f := frameContext.GetRawFrame()
@@ -80,7 +83,7 @@ func buildRequestInfo(
 		}
 		return getRequestInfoFromQueryInfo(
 			frameContext.GetRawFrame(), primaryCluster,
-			forwardSystemQueriesToTarget, virtualizationEnabled, stmtQueryData.queryData), nil
+			forwardSystemQueriesToTarget, virtualizationEnabled, routingHintsEnabled, originQuirks, targetQuirks, stmtQueryData.queryData), nil
 	case primitive.OpCodePrepare:
 		stmtQueryData, err := frameContext.GetOrInspectStatement(currentKeyspaceName, timeUuidGenerator)
 		if err != nil {
@@ -96,7 +99,7 @@ func buildRequestInfo(
 		}
 		baseRequestInfo := getRequestInfoFromQueryInfo(
 			frameContext.GetRawFrame(), primaryCluster,
-			forwardSystemQueriesToTarget, virtualizationEnabled, stmtQueryData.queryData)
+			forwardSystemQueriesToTarget, virtualizationEnabled, routingHintsEnabled, originQuirks, targetQuirks, stmtQueryData.queryData)
 		replacedTerms := make([]*term, 0)
 		if len(stmtsReplacedTerms) > 1 {
 			return nil, fmt.Errorf("expected single list of replaced terms for prepare message but got %v", len(stmtsReplacedTerms))
@@ -164,6 +167,7 @@ func getPreparedData(
 	if preparedData, ok := psCache.Get(preparedId); ok {
 		log.Tracef("%v with prepared-id = '%s' has prepared-data = %v", code.String(), hex.EncodeToString(preparedId), preparedData)
 		// The forward decision was set in the cache when handling the corresponding PREPARE request
+		recordPreparedStatementExecution(preparedData)
 		return preparedData, nil
 	} else {
 		log.Warnf("No cached entry for prepared-id = '%s' for %v.", hex.EncodeToString(preparedId), code.String())
@@ -178,6 +182,9 @@ func getRequestInfoFromQueryInfo(
 	primaryCluster common.ClusterType,
 	forwardSystemQueriesToTarget bool,
 	virtualizationEnabled bool,
+	routingHintsEnabled bool,
+	originQuirks *clusterQuirks,
+	targetQuirks *clusterQuirks,
 	queryInfo QueryInfo) RequestInfo {
 
 	var sendAlsoToAsync bool
@@ -186,25 +193,27 @@ func getRequestInfoFromQueryInfo(
 		if virtualizationEnabled {
 			parsedSelectClause := queryInfo.getParsedSelectClause()
 			if isSystemLocal(queryInfo) {
-				log.Debugf("Detected system local query: %v with stream id: %v", queryInfo.getQuery(), f.Header.StreamId)
+				log.Debugf("Detected system local query: %v with stream id: %v", redactedQuery(queryInfo.getQuery()), f.Header.StreamId)
 				return NewInterceptedRequestInfo(local, parsedSelectClause)
 			} else if isSystemPeersV1(queryInfo) {
-				log.Debugf("Detected system peers query: %v with stream id: %v", queryInfo.getQuery(), f.Header.StreamId)
+				log.Debugf("Detected system peers query: %v with stream id: %v", redactedQuery(queryInfo.getQuery()), f.Header.StreamId)
 				return NewInterceptedRequestInfo(peersV1, parsedSelectClause)
 			} else if isSystemPeersV2(queryInfo) {
-				log.Debugf("Detected system peers_v2 query: %v with stream id: %v", queryInfo.getQuery(), f.Header.StreamId)
+				log.Debugf("Detected system peers_v2 query: %v with stream id: %v", redactedQuery(queryInfo.getQuery()), f.Header.StreamId)
 				return NewInterceptedRequestInfo(peersV2, parsedSelectClause)
 			}
 		}
 
 		if isSystemQuery(queryInfo) {
 			sendAlsoToAsync = false
-			log.Debugf("Detected system query: %v with stream id: %v", queryInfo.getQuery(), f.Header.StreamId)
+			log.Debugf("Detected system query: %v with stream id: %v", redactedQuery(queryInfo.getQuery()), f.Header.StreamId)
 			if forwardSystemQueriesToTarget {
 				forwardDecision = forwardToTarget
 			} else {
 				forwardDecision = forwardToOrigin
 			}
+			forwardDecision = systemQueryForwardDecision(
+				queryInfo.getApplicableKeyspace(), forwardDecision, originQuirks, targetQuirks)
 		} else {
 			sendAlsoToAsync = true
 			if primaryCluster == common.ClusterTypeTarget {
@@ -219,6 +228,15 @@ func getRequestInfoFromQueryInfo(
 		sendAlsoToAsync = false
 	}
 
+	if routingHintsEnabled {
+		if hintDecision, ok := parseRoutingHint(queryInfo.getQuery()); ok {
+			log.Debugf("Routing hint found in query with stream id %v, overriding forward decision %v with %v",
+				f.Header.StreamId, forwardDecision, hintDecision)
+			forwardDecision = hintDecision
+			sendAlsoToAsync = false
+		}
+	}
+
 	log.Tracef("Forward decision: %s", forwardDecision)
 
 	return NewGenericRequestInfo(forwardDecision, sendAlsoToAsync, true)
@@ -331,7 +349,7 @@ func (recv *frameDecodeContext) inspectStatements(currentKeyspace string, timeUu
 	var statementsQueryData []*statementQueryData
 	switch typedMsg := decodedFrame.Body.Message.(type) {
 	case *message.Query:
-		log.Tracef("Decoded frame %v", decodedFrame)
+		log.Tracef("Decoded frame %v", redactedFrame{decodedFrame})
 		if protocolSupportsKeyspaceInRequest(decodedFrame.Header.Version) &&
 			typedMsg.Options != nil &&
 			typedMsg.Options.Flags().Contains(primitive.QueryFlagWithKeyspace) {
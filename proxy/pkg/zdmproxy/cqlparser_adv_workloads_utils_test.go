@@ -19,6 +19,7 @@ type params struct {
 	forwardSystemQueriesToTarget bool
 	forwardAuthToTarget          bool
 	virtualizationEnabled        bool
+	routingHintsEnabled          bool
 	timeUuidGenerator            TimeUuidGenerator
 }
 
@@ -34,6 +35,7 @@ func getGeneralParamsForTests(t *testing.T) params {
 		forwardSystemQueriesToTarget: false,
 		forwardAuthToTarget:          false,
 		virtualizationEnabled:        false,
+		routingHintsEnabled:          false,
 		timeUuidGenerator:            timeUuidGen,
 	}
 }
@@ -81,6 +83,9 @@ func parseEncodedRequestForTests(queryRawFrame *frame.RawFrame, t *testing.T) (R
 		generalParams.forwardSystemQueriesToTarget,
 		generalParams.virtualizationEnabled,
 		generalParams.forwardAuthToTarget,
+		generalParams.routingHintsEnabled,
+		nil,
+		nil,
 		generalParams.timeUuidGenerator)
 }
 
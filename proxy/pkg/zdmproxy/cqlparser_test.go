@@ -138,7 +138,7 @@ func TestInspectFrame(t *testing.T) {
 			actual, err := buildRequestInfo(&frameDecodeContext{frame: tt.args.f}, []*statementReplacedTerms{{
 				statementIndex: 0,
 				replacedTerms:  tt.args.replacedTerms,
-			}}, psCache, mh, km, tt.args.primaryCluster, tt.args.forwardSystemQueriesToTarget, true, tt.args.forwardAuthToTarget, timeUuidGenerator)
+			}}, psCache, mh, km, tt.args.primaryCluster, tt.args.forwardSystemQueriesToTarget, true, tt.args.forwardAuthToTarget, false, nil, nil, timeUuidGenerator)
 			if err != nil {
 				if !reflect.DeepEqual(err.Error(), tt.expected) {
 					t.Errorf("buildRequestInfo() actual = %v, expected %v", err, tt.expected)
@@ -150,6 +150,42 @@ func TestInspectFrame(t *testing.T) {
 	}
 }
 
+func TestInspectFrame_RoutingHints(t *testing.T) {
+	type args struct {
+		f                   *frame.RawFrame
+		routingHintsEnabled bool
+	}
+	tests := []struct {
+		name     string
+		args     args
+		expected interface{}
+	}{
+		{"SELECT target-only", args{mockQueryFrame(t, "/* zdm:target-only */ SELECT blah FROM ks1.t1"), true}, NewGenericRequestInfo(forwardToTarget, false, true)},
+		{"SELECT both", args{mockQueryFrame(t, "/*zdm:both*/SELECT blah FROM ks1.t1"), true}, NewGenericRequestInfo(forwardToBoth, false, true)},
+		{"SELECT hints disabled", args{mockQueryFrame(t, "/* zdm:target-only */ SELECT blah FROM ks1.t1"), false}, NewGenericRequestInfo(forwardToOrigin, true, true)},
+		{"SELECT unknown hint", args{mockQueryFrame(t, "/* zdm:secondary */ SELECT blah FROM ks1.t1"), true}, NewGenericRequestInfo(forwardToOrigin, true, true)},
+		{"INSERT origin-only", args{mockQueryFrame(t, "  /* ZDM:Origin-Only */ INSERT INTO ks1.t1 (a, b) VALUES (1, 2)"), true}, NewGenericRequestInfo(forwardToOrigin, false, true)},
+		{"INSERT target-only", args{mockQueryFrame(t, "/* zdm:target-only */ INSERT INTO ks1.t1 (a, b) VALUES (1, 2)"), true}, NewGenericRequestInfo(forwardToTarget, false, true)},
+		{"INSERT hint not at the start", args{mockQueryFrame(t, "INSERT INTO ks1.t1 (a, b) VALUES (1, 2) /* zdm:target-only */"), true}, NewGenericRequestInfo(forwardToBoth, false, true)},
+		{"SELECT system.local intercepted", args{mockQueryFrame(t, "/* zdm:origin-only */ SELECT * FROM system.local"), true}, NewInterceptedRequestInfo(local, newStarSelectClause())},
+		{"PREPARE target-only", args{mockPrepareFrame(t, "/* zdm:target-only */ INSERT INTO ks1.t1 (a, b) VALUES (1, 2)"), true},
+			NewPrepareRequestInfo(NewGenericRequestInfo(forwardToTarget, false, true), []*term{}, false, "/* zdm:target-only */ INSERT INTO ks1.t1 (a, b) VALUES (1, 2)", "")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			timeUuidGenerator, err := GetDefaultTimeUuidGenerator()
+			require.Nil(t, err)
+			actual, err := buildRequestInfo(&frameDecodeContext{frame: tt.args.f}, []*statementReplacedTerms{{
+				statementIndex: 0,
+				replacedTerms:  []*term{},
+			}}, NewPreparedStatementCache(), newFakeMetricHandler(), "", common.ClusterTypeOrigin, false, true, false,
+				tt.args.routingHintsEnabled, nil, nil, timeUuidGenerator)
+			require.Nil(t, err)
+			require.Equal(t, tt.expected, actual)
+		})
+	}
+}
+
 func mockPrepareFrame(t *testing.T, query string) *frame.RawFrame {
 	prepareMsg := &message.Prepare{
 		Query:    query,
@@ -214,20 +250,82 @@ func newFakeMetricHandler() *metrics.MetricHandler {
 
 func newFakeProxyMetrics() *metrics.ProxyMetrics {
 	return &metrics.ProxyMetrics{
-		FailedReadsOrigin:        newFakeCounter(),
-		FailedReadsTarget:        newFakeCounter(),
-		FailedWritesOnOrigin:     newFakeCounter(),
-		FailedWritesOnTarget:     newFakeCounter(),
-		FailedWritesOnBoth:       newFakeCounter(),
-		PSCacheSize:              newFakeGaugeFunc(),
-		PSCacheMissCount:         newFakeCounter(),
-		ProxyReadsOriginDuration: newFakeHistogram(),
-		ProxyReadsTargetDuration: newFakeHistogram(),
-		ProxyWritesDuration:      newFakeHistogram(),
-		InFlightReadsOrigin:      newFakeGauge(),
-		InFlightReadsTarget:      newFakeGauge(),
-		InFlightWrites:           newFakeGauge(),
-		OpenClientConnections:    newFakeGaugeFunc(),
+		FailedReadsOrigin:                    newFakeCounter(),
+		FailedReadsTarget:                    newFakeCounter(),
+		FailedWritesOnOrigin:                 newFakeCounter(),
+		FailedWritesOnTarget:                 newFakeCounter(),
+		FailedWritesOnBoth:                   newFakeCounter(),
+		PSCacheSize:                          newFakeGaugeFunc(),
+		PSCacheMissCount:                     newFakeCounter(),
+		ProxyReadsOriginDuration:             newFakeHistogram(),
+		ProxyReadsTargetDuration:             newFakeHistogram(),
+		ProxyWritesDuration:                  newFakeHistogram(),
+		InFlightReadsOrigin:                  newFakeGauge(),
+		InFlightReadsTarget:                  newFakeGauge(),
+		InFlightWrites:                       newFakeGauge(),
+		OpenClientConnections:                newFakeGaugeFunc(),
+		OpenOriginControlConnections:         newFakeGauge(),
+		OpenTargetControlConnections:         newFakeGauge(),
+		MemoryPressureRejectedConnections:    newFakeCounter(),
+		MemoryPressureRejectedRequests:       newFakeCounter(),
+		Goroutines:                           newFakeGauge(),
+		OpenClientHandlerGoroutines:          newFakeGauge(),
+		ClosedClientHandlerGoroutines:        newFakeGauge(),
+		GoroutineLeakSuspects:                newFakeCounter(),
+		ClientAddressRejectedConnections:     newFakeCounter(),
+		WriteQueueOverflowRejectedRequests:   newFakeCounter(),
+		StreamedResponses:                    newFakeCounter(),
+		RateLimitedRequests:                  newFakeCounter(),
+		GlobalRateLimitedRequests:            newFakeCounter(),
+		MutationExportDropped:                newFakeCounter(),
+		TargetWriteSamplingSkippedWrites:     newFakeCounter(),
+		OriginOnlyJournaledRequests:          newFakeCounter(),
+		OriginOnlyJournalFailures:            newFakeCounter(),
+		OriginOnlyJournalDroppedEntriesAge:   newFakeCounter(),
+		OriginOnlyJournalDroppedEntriesSize:  newFakeCounter(),
+		TargetWritePipelinePublishedRequests: newFakeCounter(),
+		TargetWritePipelinePublishFailures:   newFakeCounter(),
+		ReadRepairs:                          newFakeCounter(),
+		ReadRepairFailures:                   newFakeCounter(),
+		AsymmetricTables:                     newFakeGauge(),
+		AsymmetricTableWrites:                newFakeCounter(),
+		PreparedMetadataMismatches:           newFakeCounter(),
+		CoalescedPrepares:                    newFakeCounter(),
+		RequestDeadlineExceeded:              newFakeCounter(),
+		AsyncReadComparisons:                 newFakeCounter(),
+		AsyncReadDivergencesOutcome:          newFakeCounter(),
+		AsyncReadDivergencesRowCount:         newFakeCounter(),
+		AsyncReadDivergencesResultSize:       newFakeCounter(),
+		GuardrailWarningsBatchSize:           newFakeCounter(),
+		GuardrailWarningsBatchStatements:     newFakeCounter(),
+		GuardrailWarningsMutationSize:        newFakeCounter(),
+		GuardrailRejectionsBatchSize:         newFakeCounter(),
+		GuardrailRejectionsBatchStatements:   newFakeCounter(),
+		GuardrailRejectionsMutationSize:      newFakeCounter(),
+		GuardrailRejectionsQueryLength:       newFakeCounter(),
+		GuardrailRejectionsValuesSize:        newFakeCounter(),
+		PageSizeOverriddenRequests:           newFakeCounter(),
+		ClusterWarningsOrigin:                newFakeCounter(),
+		ClusterWarningsTarget:                newFakeCounter(),
+		TranslatedSecondaryErrorsWarning:     newFakeCounter(),
+		TranslatedSecondaryErrorsMetric:      newFakeCounter(),
+		DualWriteResponsePolicyPrimary:       newFakeCounter(),
+		DualWriteResponsePolicyEither:        newFakeCounter(),
+		DualWriteResponsePolicyQuorum:        newFakeCounter(),
+		LwtRequestsOrigin:                    newFakeCounter(),
+		LwtRequestsTarget:                    newFakeCounter(),
+		LwtNotAppliedOrigin:                  newFakeCounter(),
+		LwtNotAppliedTarget:                  newFakeCounter(),
+		LwtSerialConsistencyRemappedOrigin:   newFakeCounter(),
+		LwtSerialConsistencyRemappedTarget:   newFakeCounter(),
+		LwtAppliedDivergences:                newFakeCounter(),
+		ClusterClockOffsetOrigin:             newFakeGauge(),
+		ClusterClockOffsetTarget:             newFakeGauge(),
+		ClusterClockSkew:                     newFakeGauge(),
+		ClusterClockSkewExceeded:             newFakeCounter(),
+		FleetLeader:                          newFakeGauge(),
+		BuildInfo:                            newFakeGauge(),
+		ConfigInfo:                           newFakeGauge(),
 	}
 }
 
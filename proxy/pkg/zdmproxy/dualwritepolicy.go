@@ -0,0 +1,128 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	"strings"
+)
+
+// dualWriteResponsePolicy decides which response the client sees when a write (INSERT, UPDATE, DELETE or BATCH sent to
+// both clusters) only failed on one of them, per statement type (ZDM_DUAL_WRITE_RESPONSE_POLICY):
+//   - BOTH: the error is returned, like without the policy (ZDM_SECONDARY_ERROR_POLICY still applies)
+//   - PRIMARY: the response of the primary cluster is returned, the failures of the secondary cluster are only
+//     visible in the metrics
+//   - EITHER: the successful response is returned
+//   - QUORUM: like EITHER, the successful response is returned, but with a warning that describes the failure on the
+//     other cluster. There are only two clusters so a single successful cluster is enough, QUORUM gives no additional
+//     guarantee over EITHER.
+//
+// The writes that succeeded or failed on both clusters are never affected, neither are the UNPREPARED errors which
+// must reach the client so that it prepares the statement again on both clusters.
+type dualWriteResponsePolicy struct {
+	policies      map[statementType]common.DualWriteResponsePolicy
+	defaultPolicy common.DualWriteResponsePolicy
+
+	primaryResponses metrics.Counter
+	eitherResponses  metrics.Counter
+	quorumResponses  metrics.Counter
+}
+
+func newDualWriteResponsePolicy(
+	policies map[string]common.DualWriteResponsePolicy, proxyMetrics *metrics.ProxyMetrics) *dualWriteResponsePolicy {
+	defaultPolicy, ok := policies[config.DualWriteStatementTypeDefault]
+	if !ok {
+		defaultPolicy = common.DualWriteResponsePolicyBoth
+	}
+	policiesByType := make(map[statementType]common.DualWriteResponsePolicy, len(policies))
+	for stmtType, policy := range policies {
+		if stmtType != config.DualWriteStatementTypeDefault {
+			policiesByType[statementType(strings.ToLower(stmtType))] = policy
+		}
+	}
+	return &dualWriteResponsePolicy{
+		policies:         policiesByType,
+		defaultPolicy:    defaultPolicy,
+		primaryResponses: proxyMetrics.DualWriteResponsePolicyPrimary,
+		eitherResponses:  proxyMetrics.DualWriteResponsePolicyEither,
+		quorumResponses:  proxyMetrics.DualWriteResponsePolicyQuorum,
+	}
+}
+
+// aggregate returns the response that must be sent to the client instead of the aggregated one (along with its
+// cluster) and the warning that must be added to it. The returned response is nil when the aggregated response is
+// kept. It's nil safe.
+func (recv *dualWriteResponsePolicy) aggregate(
+	primaryCluster common.ClusterType, stmtType statementType, request *frame.RawFrame, originResponse *frame.RawFrame,
+	targetResponse *frame.RawFrame) (*frame.RawFrame, common.ClusterType, string) {
+	if recv == nil {
+		return nil, common.ClusterTypeNone, ""
+	}
+	switch request.Header.OpCode {
+	case primitive.OpCodeQuery, primitive.OpCodeExecute, primitive.OpCodeBatch:
+	default:
+		return nil, common.ClusterTypeNone, ""
+	}
+	if isResponseSuccessful(originResponse) == isResponseSuccessful(targetResponse) {
+		return nil, common.ClusterTypeNone, ""
+	}
+
+	successfulCluster, successfulResponse := common.ClusterTypeOrigin, originResponse
+	failedCluster, failedResponse := common.ClusterTypeTarget, targetResponse
+	if !isResponseSuccessful(originResponse) {
+		successfulCluster, successfulResponse = common.ClusterTypeTarget, targetResponse
+		failedCluster, failedResponse = common.ClusterTypeOrigin, originResponse
+	}
+
+	errorMsg, decodeErr := decodeErrorResult(failedResponse)
+	if decodeErr == nil && errorMsg.GetErrorCode() == primitive.ErrorCodeUnprepared {
+		return nil, common.ClusterTypeNone, ""
+	}
+
+	policy := recv.getPolicy(stmtType)
+	switch policy {
+	case common.DualWriteResponsePolicyPrimary:
+		if successfulCluster != primaryCluster {
+			// the error of the primary cluster is returned, like with BOTH
+			return nil, common.ClusterTypeNone, ""
+		}
+		recv.primaryResponses.Add(1)
+	case common.DualWriteResponsePolicyEither:
+		recv.eitherResponses.Add(1)
+	case common.DualWriteResponsePolicyQuorum:
+		recv.quorumResponses.Add(1)
+	default:
+		return nil, common.ClusterTypeNone, ""
+	}
+	log.Debugf("Returning the response of %v instead of the error of %v for a %v statement (%v).",
+		successfulCluster, failedCluster, stmtType, policy)
+	if policy != common.DualWriteResponsePolicyQuorum {
+		return successfulResponse, successfulCluster, ""
+	}
+
+	errorMessage := "unknown error"
+	if decodeErr != nil {
+		log.Warnf("Could not decode the error of %v to describe the write failure: %v", failedCluster, decodeErr)
+	} else {
+		errorMessage = errorMsg.GetErrorMessage()
+	}
+	return successfulResponse, successfulCluster, fmt.Sprintf("Write was only applied on %v, it failed on %v: %v. "+
+		"The data of both clusters may have diverged.", successfulCluster, failedCluster, errorMessage)
+}
+
+func (recv *dualWriteResponsePolicy) getPolicy(stmtType statementType) common.DualWriteResponsePolicy {
+	switch stmtType {
+	case statementTypeInsert, statementTypeUpdate, statementTypeDelete, statementTypeBatch:
+	default:
+		// USE, schema changes and the statements that could not be inspected
+		return common.DualWriteResponsePolicyBoth
+	}
+	if policy, ok := recv.policies[stmtType]; ok {
+		return policy
+	}
+	return recv.defaultPolicy
+}
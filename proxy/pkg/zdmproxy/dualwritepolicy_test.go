@@ -0,0 +1,181 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestDualWriteResponsePolicy_Aggregate(t *testing.T) {
+	success := mustEncodeResponse(t, primitive.ProtocolVersion4, &message.VoidResult{})
+	writeTimeout := mustEncodeResponse(t, primitive.ProtocolVersion4, &message.WriteTimeout{
+		ErrorMessage: "Operation timed out",
+		Consistency:  primitive.ConsistencyLevelQuorum,
+		Received:     1,
+		BlockFor:     2,
+		WriteType:    primitive.WriteTypeSimple,
+	})
+	unprepared := mustEncodeResponse(t, primitive.ProtocolVersion4, &message.Unprepared{
+		ErrorMessage: "Prepared query with ID 0102 not found",
+		Id:           []byte{0x01, 0x02},
+	})
+	insert := mockQueryFrame(t, "INSERT INTO ks.tbl (a) VALUES (1)")
+
+	proxyMetrics := newFakeProxyMetrics()
+	primaryResponses, eitherResponses, quorumResponses := &countingGauge{}, &countingGauge{}, &countingGauge{}
+	proxyMetrics.DualWriteResponsePolicyPrimary = primaryResponses
+	proxyMetrics.DualWriteResponsePolicyEither = eitherResponses
+	proxyMetrics.DualWriteResponsePolicyQuorum = quorumResponses
+	policy := newDualWriteResponsePolicy(map[string]common.DualWriteResponsePolicy{
+		"INSERT":  common.DualWriteResponsePolicyPrimary,
+		"UPDATE":  common.DualWriteResponsePolicyEither,
+		"BATCH":   common.DualWriteResponsePolicyBoth,
+		"DEFAULT": common.DualWriteResponsePolicyQuorum,
+	}, proxyMetrics)
+
+	tests := []struct {
+		name                string
+		policy              *dualWriteResponsePolicy
+		primaryCluster      common.ClusterType
+		statementType       statementType
+		request             *frame.RawFrame
+		originResponse      *frame.RawFrame
+		targetResponse      *frame.RawFrame
+		expectedResponse    *frame.RawFrame
+		expectedClusterType common.ClusterType
+		expectedWarning     string
+	}{
+		{
+			name:                "primary with failure on secondary",
+			policy:              policy,
+			primaryCluster:      common.ClusterTypeOrigin,
+			statementType:       statementTypeInsert,
+			request:             insert,
+			originResponse:      success,
+			targetResponse:      writeTimeout,
+			expectedResponse:    success,
+			expectedClusterType: common.ClusterTypeOrigin,
+		},
+		{
+			name:           "primary with failure on primary",
+			policy:         policy,
+			primaryCluster: common.ClusterTypeTarget,
+			statementType:  statementTypeInsert,
+			request:        insert,
+			originResponse: success,
+			targetResponse: writeTimeout,
+		},
+		{
+			name:                "either with failure on primary",
+			policy:              policy,
+			primaryCluster:      common.ClusterTypeOrigin,
+			statementType:       statementTypeUpdate,
+			request:             insert,
+			originResponse:      writeTimeout,
+			targetResponse:      success,
+			expectedResponse:    success,
+			expectedClusterType: common.ClusterTypeTarget,
+		},
+		{
+			name:                "quorum by default",
+			policy:              policy,
+			primaryCluster:      common.ClusterTypeOrigin,
+			statementType:       statementTypeDelete,
+			request:             insert,
+			originResponse:      success,
+			targetResponse:      writeTimeout,
+			expectedResponse:    success,
+			expectedClusterType: common.ClusterTypeOrigin,
+			expectedWarning: "Write was only applied on ORIGIN, it failed on TARGET: Operation timed out. " +
+				"The data of both clusters may have diverged.",
+		},
+		{
+			name:           "both",
+			policy:         policy,
+			primaryCluster: common.ClusterTypeOrigin,
+			statementType:  statementTypeBatch,
+			request:        insert,
+			originResponse: success,
+			targetResponse: writeTimeout,
+		},
+		{
+			name:           "schema changes always need both responses",
+			policy:         policy,
+			primaryCluster: common.ClusterTypeOrigin,
+			statementType:  statementTypeOther,
+			request:        mockQueryFrame(t, "CREATE TABLE ks.tbl (a int PRIMARY KEY)"),
+			originResponse: success,
+			targetResponse: writeTimeout,
+		},
+		{
+			name:           "failure on both",
+			policy:         policy,
+			primaryCluster: common.ClusterTypeOrigin,
+			statementType:  statementTypeUpdate,
+			request:        insert,
+			originResponse: writeTimeout,
+			targetResponse: writeTimeout,
+		},
+		{
+			name:           "unprepared on secondary with primary",
+			policy:         policy,
+			primaryCluster: common.ClusterTypeOrigin,
+			statementType:  statementTypeInsert,
+			request:        insert,
+			originResponse: success,
+			targetResponse: unprepared,
+		},
+		{
+			name:           "unprepared with either",
+			policy:         policy,
+			primaryCluster: common.ClusterTypeOrigin,
+			statementType:  statementTypeUpdate,
+			request:        insert,
+			originResponse: unprepared,
+			targetResponse: success,
+		},
+		{
+			name:           "unprepared with quorum",
+			policy:         policy,
+			primaryCluster: common.ClusterTypeOrigin,
+			statementType:  statementTypeDelete,
+			request:        insert,
+			originResponse: success,
+			targetResponse: unprepared,
+		},
+		{
+			name:           "prepare",
+			policy:         policy,
+			primaryCluster: common.ClusterTypeOrigin,
+			statementType:  statementTypeUpdate,
+			request:        mockPrepareFrame(t, "UPDATE ks.tbl SET b = ? WHERE a = ?"),
+			originResponse: success,
+			targetResponse: writeTimeout,
+		},
+		{
+			name:           "disabled",
+			policy:         nil,
+			primaryCluster: common.ClusterTypeOrigin,
+			statementType:  statementTypeUpdate,
+			request:        insert,
+			originResponse: success,
+			targetResponse: writeTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, clusterType, warning := tt.policy.aggregate(
+				tt.primaryCluster, tt.statementType, tt.request, tt.originResponse, tt.targetResponse)
+			require.Equal(t, tt.expectedResponse, response)
+			require.Equal(t, tt.expectedClusterType, clusterType)
+			require.Equal(t, tt.expectedWarning, warning)
+		})
+	}
+	require.Equal(t, 1, primaryResponses.value)
+	require.Equal(t, 1, eitherResponses.value)
+	require.Equal(t, 1, quorumResponses.value)
+}
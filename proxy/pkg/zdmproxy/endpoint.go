@@ -3,6 +3,8 @@ package zdmproxy
 import (
 	"crypto/tls"
 	"fmt"
+	"net"
+	"strconv"
 )
 
 type Endpoint interface {
@@ -19,7 +21,7 @@ type DefaultEndpoint struct {
 
 func NewDefaultEndpoint(addr string, port int, tlsConfig *tls.Config) *DefaultEndpoint {
 	return &DefaultEndpoint{
-		socketEndpoint: fmt.Sprintf("%s:%d", addr, port),
+		socketEndpoint: net.JoinHostPort(addr, strconv.Itoa(port)),
 		tlsConfig:      tlsConfig,
 	}
 }
@@ -59,8 +61,9 @@ func (recv *AstraEndpoint) GetSocketEndpoint() string {
 }
 
 func (recv *AstraEndpoint) GetTlsConfig() *tls.Config {
-	return getClientSideTlsConfigFromParsedCerts(
+	tlsConfig := getClientSideTlsConfigFromParsedCerts(
 		recv.baseTlsConfig.RootCAs, recv.baseTlsConfig.Certificates, recv.hostId, recv.astraConnConfig.GetSniProxyAddr())
+	return applyTlsProtocolOptions(tlsConfig, recv.baseTlsConfig.MinVersion, recv.baseTlsConfig.CipherSuites)
 }
 
 func (recv *AstraEndpoint) GetEndpointIdentifier() string {
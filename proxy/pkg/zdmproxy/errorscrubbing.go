@@ -0,0 +1,112 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"regexp"
+	"strings"
+)
+
+const (
+	scrubbedNodeAddress = "<node address>"
+	scrubbedClusterName = "<cluster name>"
+)
+
+// Matches IPv4 addresses with an optional port, Cassandra often prefixes them with '/' (e.g. /10.0.0.1:7000).
+var ipv4AddressRegex = regexp.MustCompile(`/?\b(?:\d{1,3}\.){3}\d{1,3}(?::\d{1,5})?\b`)
+
+// scrubErrorMessage returns a copy of the provided error with the configured backend details (node addresses and
+// cluster names) removed from its message or nil if the message doesn't contain any of them.
+func (ch *ClientHandler) scrubErrorMessage(errMsg message.Error) message.Error {
+	if len(ch.scrubbedErrorDetails) == 0 {
+		return nil
+	}
+
+	var nodeAddresses []string
+	var clusterNames []string
+	for _, controlConn := range []*ControlConn{ch.originControlConn, ch.targetControlConn} {
+		if hosts, err := controlConn.GetHostsInLocalDatacenter(); err == nil {
+			for _, host := range hosts {
+				nodeAddresses = append(nodeAddresses, host.Address.String())
+			}
+		}
+		clusterNames = append(clusterNames, controlConn.GetClusterName())
+	}
+
+	scrubbedMessage := scrubErrorMessageDetails(
+		errMsg.GetErrorMessage(), ch.scrubbedErrorDetails, nodeAddresses, clusterNames)
+	if scrubbedMessage == errMsg.GetErrorMessage() {
+		return nil
+	}
+	return setErrorMessage(errMsg, scrubbedMessage)
+}
+
+// scrubErrorMessageDetails replaces the IPv4 addresses and the provided node addresses (which is how IPv6 addresses
+// are found) and cluster names of the error message with placeholders.
+func scrubErrorMessageDetails(
+	errorMessage string, details []common.ScrubbedErrorDetail, nodeAddresses []string, clusterNames []string) string {
+	for _, detail := range details {
+		switch detail {
+		case common.ScrubbedErrorDetailNodeAddresses:
+			errorMessage = ipv4AddressRegex.ReplaceAllString(errorMessage, scrubbedNodeAddress)
+			for _, address := range nodeAddresses {
+				if address != "" {
+					errorMessage = strings.ReplaceAll(errorMessage, "/"+address, scrubbedNodeAddress)
+					errorMessage = strings.ReplaceAll(errorMessage, address, scrubbedNodeAddress)
+				}
+			}
+		case common.ScrubbedErrorDetailClusterNames:
+			for _, clusterName := range clusterNames {
+				if clusterName != "" {
+					errorMessage = strings.ReplaceAll(errorMessage, clusterName, scrubbedClusterName)
+				}
+			}
+		}
+	}
+	return errorMessage
+}
+
+// setErrorMessage returns a copy of the provided error with a different message or nil for errors that are generated
+// by the proxy (UNPREPARED).
+func setErrorMessage(errMsg message.Error, errorMessage string) message.Error {
+	newErrMsg := errMsg.Clone().(message.Error)
+	switch typedErr := newErrMsg.(type) {
+	case *message.ServerError:
+		typedErr.ErrorMessage = errorMessage
+	case *message.ProtocolError:
+		typedErr.ErrorMessage = errorMessage
+	case *message.AuthenticationError:
+		typedErr.ErrorMessage = errorMessage
+	case *message.Overloaded:
+		typedErr.ErrorMessage = errorMessage
+	case *message.IsBootstrapping:
+		typedErr.ErrorMessage = errorMessage
+	case *message.TruncateError:
+		typedErr.ErrorMessage = errorMessage
+	case *message.SyntaxError:
+		typedErr.ErrorMessage = errorMessage
+	case *message.Unauthorized:
+		typedErr.ErrorMessage = errorMessage
+	case *message.Invalid:
+		typedErr.ErrorMessage = errorMessage
+	case *message.ConfigError:
+		typedErr.ErrorMessage = errorMessage
+	case *message.Unavailable:
+		typedErr.ErrorMessage = errorMessage
+	case *message.ReadTimeout:
+		typedErr.ErrorMessage = errorMessage
+	case *message.WriteTimeout:
+		typedErr.ErrorMessage = errorMessage
+	case *message.ReadFailure:
+		typedErr.ErrorMessage = errorMessage
+	case *message.WriteFailure:
+		typedErr.ErrorMessage = errorMessage
+	case *message.FunctionFailure:
+		typedErr.ErrorMessage = errorMessage
+	case *message.AlreadyExists:
+		typedErr.ErrorMessage = errorMessage
+	default:
+		return nil
+	}
+	return newErrMsg
+}
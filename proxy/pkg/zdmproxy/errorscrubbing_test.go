@@ -0,0 +1,79 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestScrubErrorMessageDetails(t *testing.T) {
+	allDetails := []common.ScrubbedErrorDetail{
+		common.ScrubbedErrorDetailNodeAddresses, common.ScrubbedErrorDetailClusterNames}
+
+	tests := []struct {
+		name     string
+		message  string
+		details  []common.ScrubbedErrorDetail
+		expected string
+	}{
+		{
+			name:     "ipv4 address with port",
+			message:  "Operation timed out - received only 1 responses from /10.0.0.1:7000.",
+			details:  allDetails,
+			expected: "Operation timed out - received only 1 responses from <node address>.",
+		},
+		{
+			name:     "ipv4 addresses without port",
+			message:  "Nodes 10.0.0.1 and 10.0.0.2 are down",
+			details:  allDetails,
+			expected: "Nodes <node address> and <node address> are down",
+		},
+		{
+			name:     "known ipv6 address",
+			message:  "Node /2001:db8::1 is bootstrapping",
+			details:  allDetails,
+			expected: "Node <node address> is bootstrapping",
+		},
+		{
+			name:     "cluster name",
+			message:  "Keyspace ks does not exist in cluster origin_cluster",
+			details:  allDetails,
+			expected: "Keyspace ks does not exist in cluster <cluster name>",
+		},
+		{
+			name:     "only cluster names",
+			message:  "Node 10.0.0.1 of cluster target_cluster is down",
+			details:  []common.ScrubbedErrorDetail{common.ScrubbedErrorDetailClusterNames},
+			expected: "Node 10.0.0.1 of cluster <cluster name> is down",
+		},
+		{
+			name:     "version numbers are not addresses",
+			message:  "Invalid or unsupported protocol version (5); CQL version 3.4.5 is required",
+			details:  allDetails,
+			expected: "Invalid or unsupported protocol version (5); CQL version 3.4.5 is required",
+		},
+		{
+			name:     "disabled",
+			message:  "Node 10.0.0.1 of cluster origin_cluster is down",
+			details:  nil,
+			expected: "Node 10.0.0.1 of cluster origin_cluster is down",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, scrubErrorMessageDetails(
+				tt.message, tt.details, []string{"10.0.0.3", "2001:db8::1"}, []string{"origin_cluster", "target_cluster", ""}))
+		})
+	}
+}
+
+func TestSetErrorMessage(t *testing.T) {
+	original := &message.WriteTimeout{ErrorMessage: "timeout on /10.0.0.1", BlockFor: 2, Received: 1}
+	newErr := setErrorMessage(original, "timeout on <node address>")
+	require.Equal(t, &message.WriteTimeout{ErrorMessage: "timeout on <node address>", BlockFor: 2, Received: 1}, newErr)
+	require.Equal(t, "timeout on /10.0.0.1", original.ErrorMessage)
+
+	require.Nil(t, setErrorMessage(&message.Unprepared{ErrorMessage: "unprepared"}, "new message"))
+}
@@ -0,0 +1,56 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	log "github.com/sirupsen/logrus"
+)
+
+// shouldForwardEvent returns true if the provided message that was received on the event channel of a cluster
+// connector should be sent to the client. With the DEFAULT events source, schema change events are only routed if they
+// come from origin and status and topology change events are only routed if they come from target. Status and
+// topology change events are never routed when virtualization is enabled because they refer to the cluster nodes
+// instead of the proxy instances.
+func (ch *ClientHandler) shouldForwardEvent(msg message.Message, fromTarget bool) bool {
+	switch msgType := msg.(type) {
+	case *message.ProtocolError:
+		log.Debug("Received protocol error on event body listener, forwarding to client: ", msg)
+		return true
+	case *message.SchemaChangeEvent, *message.StatusChangeEvent, *message.TopologyChangeEvent:
+	default:
+		log.Infof("Expected event body (fromTarget: %v) but got: %v", fromTarget, msgType)
+		return false
+	}
+
+	_, isSchemaChange := msg.(*message.SchemaChangeEvent)
+	if !isSchemaChange && ch.topologyConfig.VirtualizationEnabled {
+		log.Infof("Received status or topology change event (fromTarget=%v) but virtualization is enabled, skipping: %v",
+			fromTarget, msg)
+		return false
+	}
+
+	var forwardFromTarget bool
+	switch ch.eventsSource {
+	case common.EventsSourceNone:
+		log.Debugf("Received event (fromTarget=%v) but events source is %v, skipping: %v", fromTarget, ch.eventsSource, msg)
+		return false
+	case common.EventsSourceOrigin:
+		forwardFromTarget = false
+	case common.EventsSourceTarget:
+		forwardFromTarget = true
+	case common.EventsSourcePrimary:
+		forwardFromTarget = ch.primaryCluster == common.ClusterTypeTarget
+	default:
+		forwardFromTarget = !isSchemaChange
+	}
+
+	if fromTarget != forwardFromTarget {
+		eventCluster := common.ClusterTypeOrigin
+		if fromTarget {
+			eventCluster = common.ClusterTypeTarget
+		}
+		log.Infof("Received event from %v (events source is %v), skipping: %v", eventCluster, ch.eventsSource, msg)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,66 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestShouldForwardEvent(t *testing.T) {
+	schemaChange := &message.SchemaChangeEvent{
+		ChangeType: primitive.SchemaChangeTypeCreated, Target: primitive.SchemaChangeTargetKeyspace, Keyspace: "ks"}
+	statusChange := &message.StatusChangeEvent{ChangeType: primitive.StatusChangeTypeUp}
+	topologyChange := &message.TopologyChangeEvent{ChangeType: primitive.TopologyChangeTypeNewNode}
+
+	type event struct {
+		msg        message.Message
+		fromTarget bool
+	}
+	allEvents := []event{
+		{schemaChange, false}, {schemaChange, true},
+		{statusChange, false}, {statusChange, true},
+		{topologyChange, false}, {topologyChange, true},
+		{&message.ProtocolError{ErrorMessage: "error"}, true},
+		{&message.Ready{}, false},
+	}
+
+	tests := []struct {
+		name                  string
+		eventsSource          common.EventsSource
+		primaryCluster        common.ClusterType
+		virtualizationEnabled bool
+		expectedForwarded     []bool
+	}{
+		{"default", common.EventsSourceDefault, common.ClusterTypeOrigin, false,
+			[]bool{true, false, false, true, false, true, true, false}},
+		{"default with virtualization", common.EventsSourceDefault, common.ClusterTypeOrigin, true,
+			[]bool{true, false, false, false, false, false, true, false}},
+		{"origin", common.EventsSourceOrigin, common.ClusterTypeTarget, false,
+			[]bool{true, false, true, false, true, false, true, false}},
+		{"target", common.EventsSourceTarget, common.ClusterTypeOrigin, false,
+			[]bool{false, true, false, true, false, true, true, false}},
+		{"primary is origin", common.EventsSourcePrimary, common.ClusterTypeOrigin, false,
+			[]bool{true, false, true, false, true, false, true, false}},
+		{"primary is target", common.EventsSourcePrimary, common.ClusterTypeTarget, true,
+			[]bool{false, true, false, false, false, false, true, false}},
+		{"none", common.EventsSourceNone, common.ClusterTypeOrigin, false,
+			[]bool{false, false, false, false, false, false, true, false}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := &ClientHandler{
+				eventsSource:   tt.eventsSource,
+				primaryCluster: tt.primaryCluster,
+				topologyConfig: &common.TopologyConfig{VirtualizationEnabled: tt.virtualizationEnabled},
+			}
+			var forwarded []bool
+			for _, e := range allEvents {
+				forwarded = append(forwarded, ch.shouldForwardEvent(e.msg, e.fromTarget))
+			}
+			require.Equal(t, tt.expectedForwarded, forwarded)
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package zdmproxy
+
+import (
+	"context"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+)
+
+// Extensions holds the custom components that can be plugged into the proxy when it is embedded in another program.
+type Extensions struct {
+	// RequestInterceptors are called for every request, see RequestInterceptor.
+	RequestInterceptors []RequestInterceptor
+
+	// MetricsSinks receive all the proxy metrics in addition to the prometheus registry (or instead of it if
+	// ZDM_METRICS_ENABLED is false), e.g. to forward them to an in-house telemetry agent.
+	MetricsSinks []metrics.MetricsSink
+
+	// ClientCredentialStore enables proxy-level client authentication with an external user store, it takes
+	// precedence over ZDM_PROXY_CLIENT_CREDENTIALS_FILE.
+	ClientCredentialStore ClientCredentialStore
+
+	// FleetTasks run once per proxy fleet: when ZDM_SHARED_CONFIG_BACKEND is set they only run on the instance that was
+	// elected leader and their context is canceled when it loses the leadership, otherwise they run on every instance
+	// until shutdown. A task must return when its context is canceled.
+	FleetTasks []func(ctx context.Context)
+
+	// ReadVerifier reports how many of the reads that were compared between Origin and Target returned different
+	// results, ZDM_AUTO_CUTOVER_ENABLED requires it. ZDM_READ_REPAIR_TO_TARGET_ENABLED requires a verifier that also
+	// implements ReadRepairSource.
+	ReadVerifier ReadVerifier
+}
@@ -0,0 +1,175 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	log "github.com/sirupsen/logrus"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const injectedErrorMessage = "Error injected by the proxy (fault injection)"
+
+// errors that can be injected, the names match the ones of the protocol specification
+var injectedErrors = map[string]func() message.Error{
+	"SERVER_ERROR": func() message.Error {
+		return &message.ServerError{ErrorMessage: injectedErrorMessage}
+	},
+	"OVERLOADED": func() message.Error {
+		return &message.Overloaded{ErrorMessage: injectedErrorMessage}
+	},
+	"IS_BOOTSTRAPPING": func() message.Error {
+		return &message.IsBootstrapping{ErrorMessage: injectedErrorMessage}
+	},
+	"UNAVAILABLE": func() message.Error {
+		return &message.Unavailable{
+			ErrorMessage: injectedErrorMessage, Consistency: primitive.ConsistencyLevelLocalQuorum, Required: 2, Alive: 1}
+	},
+	"READ_TIMEOUT": func() message.Error {
+		return &message.ReadTimeout{
+			ErrorMessage: injectedErrorMessage, Consistency: primitive.ConsistencyLevelLocalQuorum, Received: 1, BlockFor: 2}
+	},
+	"WRITE_TIMEOUT": func() message.Error {
+		return &message.WriteTimeout{
+			ErrorMessage: injectedErrorMessage, Consistency: primitive.ConsistencyLevelLocalQuorum, Received: 1, BlockFor: 2,
+			WriteType: primitive.WriteTypeSimple}
+	},
+}
+
+// Fault is an artificial failure of the responses of a cluster, see FaultInjector.
+type Fault struct {
+	// LatencyMs delays the affected responses.
+	LatencyMs int `json:"latency_ms,omitempty"`
+	// Error replaces the affected responses with an error, e.g. OVERLOADED or WRITE_TIMEOUT.
+	Error string `json:"error,omitempty"`
+	// Percent is the percentage of the responses that are affected.
+	Percent float64 `json:"percent"`
+}
+
+func (f *Fault) validate() error {
+	if f.LatencyMs < 0 {
+		return fmt.Errorf("invalid latency_ms %v, it must not be negative", f.LatencyMs)
+	}
+	if f.Error != "" && injectedErrors[f.Error] == nil {
+		names := make([]string, 0, len(injectedErrors))
+		for name := range injectedErrors {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return fmt.Errorf("invalid error %v, valid errors: %v", f.Error, strings.Join(names, ", "))
+	}
+	if f.LatencyMs == 0 && f.Error == "" {
+		return fmt.Errorf("a fault needs a latency_ms or an error")
+	}
+	if f.Percent <= 0 || f.Percent > 100 {
+		return fmt.Errorf("invalid percent %v, it must be greater than 0 and at most 100", f.Percent)
+	}
+	return nil
+}
+
+// FaultInjector injects latency and errors in the responses of Origin or Target so that application teams can
+// rehearse their handling of cluster failures through the proxy. It's only created when ZDM_FAULT_INJECTION_ENABLED is
+// true, which must never be the case in production, and the faults are set through the admin API.
+//
+// Only the responses of the client requests are affected (RESULT and ERROR), the handshakes, heartbeats and the
+// control connections are not. An error is injected after the request was executed by the cluster, so writes are
+// applied even if the client receives an error, like a real write timeout.
+type FaultInjector struct {
+	lock   *sync.RWMutex
+	faults map[common.ClusterType]*Fault
+	rand   *rand.Rand
+}
+
+func NewFaultInjector() *FaultInjector {
+	return &FaultInjector{
+		lock:   &sync.RWMutex{},
+		faults: map[common.ClusterType]*Fault{},
+		rand:   NewThreadSafeRand(),
+	}
+}
+
+// SetFault replaces the fault of the responses of a cluster.
+func (f *FaultInjector) SetFault(cluster common.ClusterType, fault *Fault) error {
+	if cluster != common.ClusterTypeOrigin && cluster != common.ClusterTypeTarget {
+		return fmt.Errorf("invalid cluster %v, faults can be injected on %v or %v",
+			cluster, common.ClusterTypeOrigin, common.ClusterTypeTarget)
+	}
+	if err := fault.validate(); err != nil {
+		return err
+	}
+	faultCopy := *fault
+	f.lock.Lock()
+	f.faults[cluster] = &faultCopy
+	f.lock.Unlock()
+	log.Warnf("Fault injection: %v%% of the responses of %v are affected (latency: %vms, error: %v).",
+		fault.Percent, cluster, fault.LatencyMs, fault.Error)
+	return nil
+}
+
+// ClearFault removes the fault of the responses of a cluster.
+func (f *FaultInjector) ClearFault(cluster common.ClusterType) {
+	f.lock.Lock()
+	_, ok := f.faults[cluster]
+	delete(f.faults, cluster)
+	f.lock.Unlock()
+	if ok {
+		log.Infof("Fault injection: responses of %v are no longer affected.", cluster)
+	}
+}
+
+// GetFaults returns a copy of the faults that are currently injected.
+func (f *FaultInjector) GetFaults() map[common.ClusterType]Fault {
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	faults := make(map[common.ClusterType]Fault, len(f.faults))
+	for cluster, fault := range f.faults {
+		faults[cluster] = *fault
+	}
+	return faults
+}
+
+// apply returns the response to process instead of the one received from a cluster connector and how long its
+// processing must be delayed.
+func (f *FaultInjector) apply(response *Response) (*Response, time.Duration) {
+	if f == nil || response.responseFrame == nil {
+		return response, 0
+	}
+	var cluster common.ClusterType
+	switch response.connectorType {
+	case ClusterConnectorTypeOrigin:
+		cluster = common.ClusterTypeOrigin
+	case ClusterConnectorTypeTarget:
+		cluster = common.ClusterTypeTarget
+	default:
+		return response, 0
+	}
+	header := response.responseFrame.Header
+	if header.OpCode != primitive.OpCodeResult && header.OpCode != primitive.OpCodeError {
+		return response, 0
+	}
+
+	f.lock.RLock()
+	fault := f.faults[cluster]
+	f.lock.RUnlock()
+	if fault == nil || f.rand.Float64()*100 >= fault.Percent {
+		return response, 0
+	}
+
+	latency := time.Duration(fault.LatencyMs) * time.Millisecond
+	if fault.Error == "" {
+		return response, latency
+	}
+	errorFrame, err := defaultCodec.ConvertToRawFrame(
+		frame.NewFrame(header.Version, header.StreamId, injectedErrors[fault.Error]()))
+	if err != nil {
+		log.Errorf("Fault injection: could not encode %v error: %v", fault.Error, err)
+		return response, latency
+	}
+	return NewResponse(errorFrame, response.connectorType), latency
+}
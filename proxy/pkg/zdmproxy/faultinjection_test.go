@@ -0,0 +1,92 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestFaultInjector_SetFault(t *testing.T) {
+	tests := []struct {
+		name        string
+		cluster     common.ClusterType
+		fault       *Fault
+		expectedErr string
+	}{
+		{"latency", common.ClusterTypeOrigin, &Fault{LatencyMs: 100, Percent: 100}, ""},
+		{"error", common.ClusterTypeTarget, &Fault{Error: "WRITE_TIMEOUT", Percent: 0.1}, ""},
+		{"latency and error", common.ClusterTypeTarget, &Fault{LatencyMs: 10, Error: "OVERLOADED", Percent: 50}, ""},
+		{"no fault", common.ClusterTypeOrigin, &Fault{Percent: 100}, "a fault needs a latency_ms or an error"},
+		{"unknown error", common.ClusterTypeOrigin, &Fault{Error: "TIMEOUT", Percent: 100},
+			"invalid error TIMEOUT, valid errors: IS_BOOTSTRAPPING, OVERLOADED, READ_TIMEOUT, SERVER_ERROR, " +
+				"UNAVAILABLE, WRITE_TIMEOUT"},
+		{"negative latency", common.ClusterTypeOrigin, &Fault{LatencyMs: -1, Percent: 100},
+			"invalid latency_ms -1, it must not be negative"},
+		{"no responses", common.ClusterTypeOrigin, &Fault{LatencyMs: 10, Percent: 0},
+			"invalid percent 0, it must be greater than 0 and at most 100"},
+		{"invalid cluster", common.ClusterType("BOTH"), &Fault{LatencyMs: 10, Percent: 100},
+			"invalid cluster BOTH, faults can be injected on ORIGIN or TARGET"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			faultInjector := NewFaultInjector()
+			err := faultInjector.SetFault(tt.cluster, tt.fault)
+			if tt.expectedErr != "" {
+				require.NotNil(t, err)
+				require.Equal(t, tt.expectedErr, err.Error())
+				require.Empty(t, faultInjector.GetFaults())
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, map[common.ClusterType]Fault{tt.cluster: *tt.fault}, faultInjector.GetFaults())
+		})
+	}
+}
+
+func TestFaultInjector_Apply(t *testing.T) {
+	faultInjector := NewFaultInjector()
+	require.Nil(t, faultInjector.SetFault(common.ClusterTypeTarget, &Fault{LatencyMs: 200, Error: "READ_TIMEOUT", Percent: 100}))
+	require.Nil(t, faultInjector.SetFault(common.ClusterTypeOrigin, &Fault{LatencyMs: 50, Percent: 100}))
+
+	result := mockFrame(t, &message.VoidResult{}, primitive.ProtocolVersion4)
+
+	// the error keeps the stream id and version of the response
+	response, latency := faultInjector.apply(NewResponse(result, ClusterConnectorTypeTarget))
+	require.Equal(t, 200*time.Millisecond, latency)
+	require.Equal(t, ClusterConnectorTypeTarget, response.connectorType)
+	require.Equal(t, result.Header.StreamId, response.responseFrame.Header.StreamId)
+	decoded, err := defaultCodec.ConvertFromRawFrame(response.responseFrame)
+	require.Nil(t, err)
+	require.Equal(t, primitive.ProtocolVersion4, decoded.Header.Version)
+	readTimeout, ok := decoded.Body.Message.(*message.ReadTimeout)
+	require.True(t, ok)
+	require.Equal(t, injectedErrorMessage, readTimeout.ErrorMessage)
+
+	response, latency = faultInjector.apply(NewResponse(result, ClusterConnectorTypeOrigin))
+	require.Equal(t, 50*time.Millisecond, latency)
+	require.Equal(t, result, response.responseFrame)
+
+	// the async connector, the handshakes and the timeouts are not affected
+	for _, unaffected := range []*Response{
+		NewResponse(result, ClusterConnectorTypeAsync),
+		NewResponse(mockFrame(t, &message.Ready{}, primitive.ProtocolVersion4), ClusterConnectorTypeTarget),
+		NewTimeoutResponse(mockQueryFrame(t, "SELECT * FROM ks.tbl"), false),
+	} {
+		response, latency = faultInjector.apply(unaffected)
+		require.Equal(t, unaffected, response)
+		require.Equal(t, time.Duration(0), latency)
+	}
+
+	faultInjector.ClearFault(common.ClusterTypeTarget)
+	response, latency = faultInjector.apply(NewResponse(result, ClusterConnectorTypeTarget))
+	require.Equal(t, result, response.responseFrame)
+	require.Equal(t, time.Duration(0), latency)
+
+	var disabled *FaultInjector
+	response, latency = disabled.apply(NewResponse(result, ClusterConnectorTypeOrigin))
+	require.Equal(t, result, response.responseFrame)
+	require.Equal(t, time.Duration(0), latency)
+}
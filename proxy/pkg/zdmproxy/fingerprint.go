@@ -0,0 +1,133 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/mutationexport"
+	"strings"
+)
+
+// normalized statements longer than this are truncated when they are reported, the fingerprint is computed on the
+// full text
+const maxNormalizedStatementLength = 1024
+
+// statementFingerprint is the statement of a request and its fingerprint, the statements that only differ by their
+// literals, whitespace or the letter case of their keywords and unquoted identifiers have the same fingerprint.
+type statementFingerprint struct {
+	query       string
+	fingerprint string
+}
+
+func newStatementFingerprint(query string) *statementFingerprint {
+	return &statementFingerprint{query: query, fingerprint: mutationexport.Fingerprint(normalizeCqlStatement(query))}
+}
+
+// normalized returns the normalized statement, it's computed again on each call so it's only meant for reports.
+func (s *statementFingerprint) normalized() string {
+	normalized := normalizeCqlStatement(s.query)
+	if len(normalized) > maxNormalizedStatementLength {
+		normalized = normalized[:maxNormalizedStatementLength] + "..."
+	}
+	return normalized
+}
+
+// normalizeCqlStatement replaces the literals of a statement with ?, lowercases its keywords and unquoted identifiers
+// (which are case-insensitive), collapses its whitespace and removes the trailing semicolon. Quoted identifiers are
+// kept as they are.
+func normalizeCqlStatement(query string) string {
+	redacted := redactCqlLiterals(query)
+	sb := &strings.Builder{}
+	sb.Grow(len(redacted))
+	pendingSpace := false
+	for i := 0; i < len(redacted); i++ {
+		c := redacted[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			pendingSpace = sb.Len() > 0
+			continue
+		case c == '"':
+			j := i + 1
+			for j < len(redacted) && (redacted[j] != '"' || (j+1 < len(redacted) && redacted[j+1] == '"')) {
+				if redacted[j] == '"' {
+					j++
+				}
+				j++
+			}
+			if j >= len(redacted) {
+				j = len(redacted) - 1
+			}
+			if pendingSpace {
+				sb.WriteByte(' ')
+				pendingSpace = false
+			}
+			sb.WriteString(redacted[i : j+1])
+			i = j
+			continue
+		case c >= 'A' && c <= 'Z':
+			c += 'a' - 'A'
+		}
+		if pendingSpace {
+			sb.WriteByte(' ')
+			pendingSpace = false
+		}
+		sb.WriteByte(c)
+	}
+	return strings.TrimRight(sb.String(), "; ")
+}
+
+// getRequestStatement returns the statement of a QUERY, EXECUTE or BATCH request, nil for other requests. The statement
+// of a BATCH is made of the statements of its children.
+func getRequestStatement(frameContext *frameDecodeContext, requestInfo RequestInfo) (*statementFingerprint, error) {
+	switch castedRequestInfo := requestInfo.(type) {
+	case *GenericRequestInfo:
+		if frameContext.GetRawFrame().Header.OpCode != primitive.OpCodeQuery {
+			return nil, nil
+		}
+		decodedFrame, err := frameContext.GetOrDecodeFrame()
+		if err != nil {
+			return nil, fmt.Errorf("could not decode QUERY frame: %w", err)
+		}
+		queryMsg, ok := decodedFrame.Body.Message.(*message.Query)
+		if !ok {
+			return nil, fmt.Errorf("expected Query but got %v instead", decodedFrame.Body.Message.GetOpCode())
+		}
+		return newStatementFingerprint(queryMsg.Query), nil
+	case *ExecuteRequestInfo:
+		return newStatementFingerprint(castedRequestInfo.GetPreparedData().GetPrepareRequestInfo().GetQuery()), nil
+	case *BatchRequestInfo:
+		decodedFrame, err := frameContext.GetOrDecodeFrame()
+		if err != nil {
+			return nil, fmt.Errorf("could not decode BATCH frame: %w", err)
+		}
+		batchMsg, ok := decodedFrame.Body.Message.(*message.Batch)
+		if !ok {
+			return nil, fmt.Errorf("expected Batch but got %v instead", decodedFrame.Body.Message.GetOpCode())
+		}
+		children := make([]string, 0, len(batchMsg.Children))
+		for idx, child := range batchMsg.Children {
+			if preparedData, ok := castedRequestInfo.GetPreparedDataByStmtIdx()[idx]; ok {
+				children = append(children, preparedData.GetPrepareRequestInfo().GetQuery())
+			} else if query, ok := child.QueryOrId.(string); ok {
+				children = append(children, query)
+			}
+		}
+		return newStatementFingerprint(
+			fmt.Sprintf("BATCH %v [%v]", getBatchTypeName(batchMsg.Type), strings.Join(children, "; "))), nil
+	default:
+		return nil, nil
+	}
+}
+
+func getBatchTypeName(batchType primitive.BatchType) string {
+	switch batchType {
+	case primitive.BatchTypeLogged:
+		return "LOGGED"
+	case primitive.BatchTypeUnlogged:
+		return "UNLOGGED"
+	case primitive.BatchTypeCounter:
+		return "COUNTER"
+	default:
+		return batchType.String()
+	}
+}
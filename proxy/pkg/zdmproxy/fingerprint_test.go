@@ -0,0 +1,32 @@
+package zdmproxy
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestNormalizeCqlStatement(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{"literals", "SELECT * FROM ks.tbl WHERE id = 5 AND name = 'it''s'", "select * from ks.tbl where id = ? and name = ?"},
+		{"whitespace", "  INSERT INTO ks.tbl\n\t(id, name)   VALUES (?, ?) ; ", "insert into ks.tbl (id, name) values (?, ?)"},
+		{"quoted identifiers", `SELECT "Name" FROM "KS"."My  Table"`, `select "Name" from "KS"."My  Table"`},
+		{"uuid and blob", "DELETE FROM ks.tbl WHERE id = 123e4567-e89b-12d3-a456-426614174000 AND b = 0xCAFE",
+			"delete from ks.tbl where id = ? and b = ?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, normalizeCqlStatement(tt.query))
+		})
+	}
+
+	require.Equal(t,
+		newStatementFingerprint("select * from KS.TBL where id = 1").fingerprint,
+		newStatementFingerprint("SELECT *   FROM ks.tbl WHERE id = 2;").fingerprint)
+	require.NotEqual(t,
+		newStatementFingerprint("SELECT * FROM ks.tbl WHERE id = 1").fingerprint,
+		newStatementFingerprint("SELECT * FROM ks.tbl2 WHERE id = 1").fingerprint)
+}
@@ -1,12 +1,22 @@
 package zdmproxy
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
 	"io"
 )
 
+const (
+	// maxFrameBodyLength matches the largest frame size that Cassandra accepts (native_transport_max_frame_size)
+	maxFrameBodyLength = 256 * 1024 * 1024
+
+	// frameBodyInitialBufferSize limits the memory that is allocated before the frame body is actually received
+	frameBodyInitialBufferSize = 64 * 1024
+)
+
 type shutdownError struct {
 	err string
 }
@@ -39,10 +49,70 @@ func writeRawFrame(writer io.Writer, connectionAddr string, clientHandlerContext
 
 // Simple function that reads data from a connection and builds a frame
 func readRawFrame(reader io.Reader, connectionAddr string, clientHandlerContext context.Context) (*frame.RawFrame, error) {
-	rawFrame, err := defaultCodec.DecodeRawFrame(reader)
+	header, err := readRawFrameHeader(reader, connectionAddr, clientHandlerContext)
+	if err != nil {
+		return nil, err
+	}
+	return readRawFrameWithHeader(header, reader, connectionAddr, clientHandlerContext)
+}
+
+func readRawFrameHeader(reader io.Reader, connectionAddr string, clientHandlerContext context.Context) (*frame.Header, error) {
+	header, err := defaultCodec.DecodeHeader(reader)
+	if err != nil {
+		return nil, adaptConnErr(connectionAddr, clientHandlerContext, fmt.Errorf("cannot decode frame header: %w", err))
+	}
+	return header, nil
+}
+
+func readRawFrameWithHeader(
+	header *frame.Header, reader io.Reader, connectionAddr string, clientHandlerContext context.Context) (*frame.RawFrame, error) {
+	body, err := readRawFrameBody(header, reader)
 	if err != nil {
-		return nil, adaptConnErr(connectionAddr, clientHandlerContext, err)
+		return nil, adaptConnErr(connectionAddr, clientHandlerContext, fmt.Errorf("cannot read frame body: %w", err))
 	}
 
-	return rawFrame, nil
+	return &frame.RawFrame{Header: header, Body: body}, nil
+}
+
+// readRawFrameBody is similar to the codec's DecodeRawBody but it doesn't allocate a buffer with the length
+// declared in the header upfront so a malformed (or malicious) header can't make the proxy allocate a lot of memory.
+func readRawFrameBody(header *frame.Header, reader io.Reader) ([]byte, error) {
+	if header.BodyLength < 0 || header.BodyLength > maxFrameBodyLength {
+		return nil, fmt.Errorf("invalid body length: %d", header.BodyLength)
+	} else if header.BodyLength == 0 {
+		return []byte{}, nil
+	}
+
+	count := int64(header.BodyLength)
+	initialSize := count
+	if initialSize > frameBodyInitialBufferSize {
+		initialSize = frameBodyInitialBufferSize
+	}
+	buf := bytes.NewBuffer(make([]byte, 0, initialSize))
+	if _, err := io.CopyN(buf, reader, count); err != nil {
+		return nil, fmt.Errorf("cannot decode raw body: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// isControlPlaneFrame returns true for the requests and responses of handshakes, heartbeats and event registrations.
+func isControlPlaneFrame(header *frame.Header) bool {
+	switch header.OpCode {
+	case primitive.OpCodeStartup, primitive.OpCodeOptions, primitive.OpCodeRegister, primitive.OpCodeAuthResponse,
+		primitive.OpCodeReady, primitive.OpCodeAuthenticate, primitive.OpCodeSupported, primitive.OpCodeAuthChallenge,
+		primitive.OpCodeAuthSuccess:
+		return true
+	default:
+		return false
+	}
+}
+
+// scheduleFrameTask schedules the processing of a frame, control plane frames skip the tasks that are waiting for a
+// worker so that new client handshakes don't time out when the proxy is saturated with queries.
+func scheduleFrameTask(scheduler *Scheduler, header *frame.Header, task func()) {
+	if isControlPlaneFrame(header) {
+		scheduler.SchedulePriority(task)
+	} else {
+		scheduler.Schedule(task)
+	}
 }
@@ -0,0 +1,159 @@
+package zdmproxy
+
+import (
+	"bytes"
+	"context"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"runtime"
+	"runtime/debug"
+	"testing"
+)
+
+func TestReadRawFrame(t *testing.T) {
+	queryFrame := frame.NewFrame(primitive.ProtocolVersion4, 1, &message.Query{Query: "SELECT * FROM ks.tb"})
+	buf := &bytes.Buffer{}
+	require.Nil(t, frame.NewCodec().EncodeFrame(queryFrame, buf))
+	encoded := buf.Bytes()
+
+	tests := []struct {
+		name        string
+		data        []byte
+		expectedErr string
+	}{
+		{"valid", encoded, ""},
+		{"truncated body", encoded[:len(encoded)-1], "cannot read frame body"},
+		{"truncated header", encoded[:5], "cannot decode frame header"},
+		{"body length above max frame size",
+			[]byte{0x04, 0x00, 0x00, 0x01, 0x07, 0x10, 0x00, 0x00, 0x01}, "invalid body length: 268435457"},
+		{"negative body length",
+			[]byte{0x04, 0x00, 0x00, 0x01, 0x07, 0xff, 0xff, 0xff, 0xff}, "invalid body length: -1"},
+		{"body length larger than data",
+			[]byte{0x04, 0x00, 0x00, 0x01, 0x07, 0x0f, 0xff, 0xff, 0xff, 0x00}, "cannot read frame body"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rawFrame, err := readRawFrame(bytes.NewReader(tt.data), "test", context.Background())
+			if tt.expectedErr != "" {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.expectedErr)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, queryFrame.Header.StreamId, rawFrame.Header.StreamId)
+			require.Equal(t, encoded[primitive.ProtocolVersion4.FrameHeaderLengthInBytes():], rawFrame.Body)
+		})
+	}
+}
+
+// FuzzReadRawFrame decodes arbitrary bytes the same way the proxy decodes requests sent by clients: the raw frame is
+// read from the connection and, for QUERY, PREPARE and BATCH requests, the CQL statements are parsed.
+//
+// Run it with: go test ./proxy/pkg/zdmproxy -run '^$' -fuzz FuzzReadRawFrame
+//
+// The protocol library allocates [bytes] and [long string] values with the length that is declared in the body
+// before reading them so a small frame can allocate up to 2GB. This is not a crash but the fuzzing process gets
+// killed if that memory is not returned to the OS quickly enough.
+func FuzzReadRawFrame(f *testing.F) {
+	for _, seed := range newFuzzSeedFrames(f) {
+		f.Add(seed)
+	}
+
+	timeUuidGenerator, err := GetDefaultTimeUuidGenerator()
+	require.Nil(f, err)
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		rawFrame, err := readRawFrame(bytes.NewReader(data), "fuzz", context.Background())
+		if err != nil {
+			return
+		}
+
+		defer freeMemoryAfterLargeAllocations()
+		decodeContext := NewFrameDecodeContext(rawFrame)
+		_, _, _ = decodeContext.GetOrDecodeAndInspect("ks", timeUuidGenerator)
+		_, _ = decodeError(rawFrame)
+	})
+}
+
+func freeMemoryAfterLargeAllocations() {
+	memStats := &runtime.MemStats{}
+	runtime.ReadMemStats(memStats)
+	if memStats.HeapSys-memStats.HeapReleased > 512*1024*1024 {
+		debug.FreeOSMemory()
+	}
+}
+
+func newFuzzSeedFrames(f *testing.F) [][]byte {
+	frames := []*frame.Frame{
+		frame.NewFrame(primitive.ProtocolVersion4, 0, &message.Options{}),
+		frame.NewFrame(primitive.ProtocolVersion4, 1, &message.Startup{Options: map[string]string{"CQL_VERSION": "3.0.0"}}),
+		frame.NewFrame(primitive.ProtocolVersion4, 2, &message.AuthResponse{Token: []byte("\x00cassandra\x00cassandra")}),
+		frame.NewFrame(primitive.ProtocolVersion4, 3, &message.Query{
+			Query: "SELECT * FROM ks.tb WHERE id = ? AND ts = now()",
+			Options: &message.QueryOptions{
+				Consistency:      primitive.ConsistencyLevelQuorum,
+				PositionalValues: []*primitive.Value{primitive.NewValue([]byte{0, 0, 0, 1})},
+			}}),
+		frame.NewFrame(primitive.ProtocolVersion5, 4, &message.Query{
+			Query:   "INSERT INTO tb (id, ts) VALUES (:id, now()) USING TTL 10",
+			Options: &message.QueryOptions{Keyspace: "ks2"}}),
+		frame.NewFrame(primitive.ProtocolVersion4, 5, &message.Prepare{Query: "UPDATE ks.tb SET a = now() WHERE id = ?"}),
+		frame.NewFrame(primitive.ProtocolVersion4, 6, &message.Execute{QueryId: []byte{1, 2, 3, 4}}),
+		frame.NewFrame(primitive.ProtocolVersion4, 7, &message.Batch{
+			Type: primitive.BatchTypeLogged,
+			Children: []*message.BatchChild{
+				{QueryOrId: "INSERT INTO ks.tb (id) VALUES (now())"},
+				{QueryOrId: []byte{1, 2, 3, 4}},
+			}}),
+		frame.NewFrame(primitive.ProtocolVersion4, 8, &message.Register{
+			EventTypes: []primitive.EventType{primitive.EventTypeSchemaChange}}),
+		frame.NewFrame(primitive.ProtocolVersion4, 9, &message.Unavailable{
+			ErrorMessage: "unavailable", Consistency: primitive.ConsistencyLevelQuorum, Required: 2, Alive: 1}),
+	}
+
+	var seeds [][]byte
+	for _, fr := range frames {
+		buf := &bytes.Buffer{}
+		require.Nil(f, frame.NewCodec().EncodeFrame(fr, buf))
+		encoded := buf.Bytes()
+		seeds = append(seeds, encoded)
+		// truncated body
+		seeds = append(seeds, encoded[:len(encoded)-1])
+	}
+
+	// header only with a body length that doesn't match the actual body
+	seeds = append(seeds, []byte{0x04, 0x00, 0x00, 0x01, 0x07, 0x7f, 0xff, 0xff, 0xff})
+	// unsupported protocol version
+	seeds = append(seeds, []byte{0x63, 0x00, 0x00, 0x01, 0x05, 0x00, 0x00, 0x00, 0x00})
+	// QUERY with a long string length that exceeds the body length
+	seeds = append(seeds, []byte{0x04, 0x00, 0x00, 0x01, 0x07, 0x00, 0x00, 0x00, 0x04, 0x7f, 0xff, 0xff, 0xff})
+	return seeds
+}
+
+func TestIsControlPlaneFrame(t *testing.T) {
+	tests := []struct {
+		opCode   primitive.OpCode
+		expected bool
+	}{
+		{primitive.OpCodeStartup, true},
+		{primitive.OpCodeAuthResponse, true},
+		{primitive.OpCodeOptions, true},
+		{primitive.OpCodeRegister, true},
+		{primitive.OpCodeReady, true},
+		{primitive.OpCodeAuthSuccess, true},
+		{primitive.OpCodeSupported, true},
+		{primitive.OpCodeQuery, false},
+		{primitive.OpCodeExecute, false},
+		{primitive.OpCodeResult, false},
+		{primitive.OpCodeError, false},
+		{primitive.OpCodeEvent, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.opCode.String(), func(t *testing.T) {
+			require.Equal(t, tt.expected, isControlPlaneFrame(&frame.Header{OpCode: tt.opCode}))
+		})
+	}
+}
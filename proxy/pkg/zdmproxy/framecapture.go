@@ -0,0 +1,317 @@
+package zdmproxy
+
+import (
+	"encoding/hex"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	FrameDirectionClientRequest  = "CLIENT_REQUEST"
+	FrameDirectionClientResponse = "CLIENT_RESPONSE"
+	FrameDirectionOriginResponse = "ORIGIN_RESPONSE"
+	FrameDirectionTargetResponse = "TARGET_RESPONSE"
+	FrameDirectionAsyncResponse  = "ASYNC_RESPONSE"
+)
+
+// number of closed connections whose frames are kept, the connection is often closed by the issue being diagnosed
+const closedFrameCapturesKept = 16
+
+// CapturedFrame is a frame of a client connection as recorded by the frame capture.
+type CapturedFrame struct {
+	Time       time.Time `json:"time"`
+	Direction  string    `json:"direction"`
+	Version    string    `json:"version"`
+	Flags      string    `json:"flags"`
+	StreamId   int16     `json:"stream_id"`
+	OpCode     string    `json:"opcode"`
+	BodyLength int32     `json:"body_length"`
+	// Body is a description of the message with literals, bound values, rows and credentials redacted, it's only set
+	// when ZDM_FRAME_CAPTURE_BODIES_ENABLED is true.
+	Body string `json:"body,omitempty"`
+}
+
+// FrameCaptureDump is the content of the frame capture of a client connection, the frames are sorted from the oldest
+// to the most recent one.
+type FrameCaptureDump struct {
+	Client   string           `json:"client"`
+	OpenedAt time.Time        `json:"opened_at"`
+	ClosedAt *time.Time       `json:"closed_at,omitempty"`
+	Dropped  uint64           `json:"dropped"`
+	Frames   []*CapturedFrame `json:"frames"`
+}
+
+// frameCaptureRegistry keeps a ring buffer of the recent frames of each client connection when ZDM_FRAME_CAPTURE_SIZE
+// is set so that intermittent protocol issues can be diagnosed from the admin API, without packet captures. The
+// buffers of the last closed connections are also kept.
+type frameCaptureRegistry struct {
+	size          int
+	captureBodies bool
+
+	lock   *sync.Mutex
+	open   map[*frameCapture]bool
+	closed []*frameCapture
+}
+
+func newFrameCaptureRegistry(size int, captureBodies bool) *frameCaptureRegistry {
+	return &frameCaptureRegistry{
+		size:          size,
+		captureBodies: captureBodies,
+		lock:          &sync.Mutex{},
+		open:          map[*frameCapture]bool{},
+	}
+}
+
+// start returns the frame capture of a new client connection, nil if the frame capture is disabled.
+func (r *frameCaptureRegistry) start(client string) *frameCapture {
+	if r == nil {
+		return nil
+	}
+	c := &frameCapture{
+		registry: r,
+		client:   client,
+		openedAt: time.Now(),
+		lock:     &sync.Mutex{},
+		frames:   make([]*CapturedFrame, 0, r.size),
+	}
+	r.lock.Lock()
+	r.open[c] = true
+	r.lock.Unlock()
+	return c
+}
+
+func (r *frameCaptureRegistry) finish(c *frameCapture) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if !r.open[c] {
+		return
+	}
+	delete(r.open, c)
+	r.closed = append(r.closed, c)
+	if len(r.closed) > closedFrameCapturesKept {
+		r.closed = r.closed[len(r.closed)-closedFrameCapturesKept:]
+	}
+}
+
+// dump returns the frames of the connections of a client address ("host:port" or only the host), of all the
+// connections if client is empty. The dumps are sorted by the time the connections were opened.
+func (r *frameCaptureRegistry) dump(client string) []*FrameCaptureDump {
+	r.lock.Lock()
+	captures := make([]*frameCapture, 0, len(r.open)+len(r.closed))
+	for c := range r.open {
+		captures = append(captures, c)
+	}
+	captures = append(captures, r.closed...)
+	r.lock.Unlock()
+
+	dumps := make([]*FrameCaptureDump, 0, len(captures))
+	for _, c := range captures {
+		if client == "" || c.client == client || strings.HasPrefix(c.client, client+":") {
+			dumps = append(dumps, c.dump())
+		}
+	}
+	sort.Slice(dumps, func(i, j int) bool {
+		return dumps[i].OpenedAt.Before(dumps[j].OpenedAt)
+	})
+	return dumps
+}
+
+// frameCapture is the ring buffer of a client connection, its methods are nil-safe.
+type frameCapture struct {
+	registry *frameCaptureRegistry
+	client   string
+	openedAt time.Time
+
+	lock     *sync.Mutex
+	frames   []*CapturedFrame
+	next     int // index of the next frame to overwrite once the buffer is full
+	dropped  uint64
+	closedAt *time.Time
+}
+
+func (c *frameCapture) record(direction string, f *frame.RawFrame) {
+	if c == nil || f == nil {
+		return
+	}
+	captured := &CapturedFrame{
+		Time:       time.Now(),
+		Direction:  direction,
+		Version:    f.Header.Version.String(),
+		Flags:      f.Header.Flags.String(),
+		StreamId:   f.Header.StreamId,
+		OpCode:     f.Header.OpCode.String(),
+		BodyLength: f.Header.BodyLength,
+	}
+	if c.registry.captureBodies {
+		captured.Body = describeFrameBody(f)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if len(c.frames) < c.registry.size {
+		c.frames = append(c.frames, captured)
+		return
+	}
+	c.frames[c.next] = captured
+	c.next = (c.next + 1) % len(c.frames)
+	c.dropped++
+}
+
+func getFrameCaptureResponseDirection(connectorType ClusterConnectorType) string {
+	switch connectorType {
+	case ClusterConnectorTypeOrigin:
+		return FrameDirectionOriginResponse
+	case ClusterConnectorTypeTarget:
+		return FrameDirectionTargetResponse
+	default:
+		return FrameDirectionAsyncResponse
+	}
+}
+
+func (c *frameCapture) close() {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	now := time.Now()
+	c.closedAt = &now
+	c.lock.Unlock()
+	c.registry.finish(c)
+}
+
+func (c *frameCapture) dump() *FrameCaptureDump {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	frames := make([]*CapturedFrame, 0, len(c.frames))
+	frames = append(frames, c.frames[c.next:]...)
+	frames = append(frames, c.frames[:c.next]...)
+	return &FrameCaptureDump{
+		Client:   c.client,
+		OpenedAt: c.openedAt,
+		ClosedAt: c.closedAt,
+		Dropped:  c.dropped,
+		Frames:   frames,
+	}
+}
+
+// describeFrameBody returns a description of the message of a frame that doesn't contain user data: the literals of
+// the CQL statements are replaced with ?, and bound values, rows and authentication tokens are omitted.
+func describeFrameBody(f *frame.RawFrame) string {
+	decoded, err := defaultCodec.ConvertFromRawFrame(f)
+	if err != nil {
+		return fmt.Sprintf("<could not decode body: %v>", err)
+	}
+	return describeMessage(decoded.Body.Message)
+}
+
+func describeMessage(msg message.Message) string {
+	switch msg := msg.(type) {
+	case *message.Query:
+		return fmt.Sprintf("QUERY %v (%d values)", redactCqlLiterals(msg.Query), countQueryValues(msg.Options))
+	case *message.Prepare:
+		return fmt.Sprintf("PREPARE %v", redactCqlLiterals(msg.Query))
+	case *message.Execute:
+		return fmt.Sprintf("EXECUTE %v (%d values)", hex.EncodeToString(msg.QueryId), countQueryValues(msg.Options))
+	case *message.Batch:
+		children := make([]string, 0, len(msg.Children))
+		for _, child := range msg.Children {
+			switch queryOrId := child.QueryOrId.(type) {
+			case string:
+				children = append(children, redactCqlLiterals(queryOrId))
+			case []byte:
+				children = append(children, hex.EncodeToString(queryOrId))
+			}
+		}
+		return fmt.Sprintf("BATCH %v [%v]", msg.Type, strings.Join(children, "; "))
+	case *message.AuthResponse:
+		return "AUTH_RESPONSE <redacted>"
+	case *message.AuthChallenge:
+		return "AUTH_CHALLENGE <redacted>"
+	case *message.AuthSuccess:
+		return "AUTH_SUCCESS <redacted>"
+	case *message.RowsResult:
+		return fmt.Sprintf("RESULT ROWS (%d rows)", len(msg.Data))
+	case *message.PreparedResult:
+		return fmt.Sprintf("RESULT PREPARED %v", hex.EncodeToString(msg.PreparedQueryId))
+	default:
+		return fmt.Sprintf("%v", msg)
+	}
+}
+
+func countQueryValues(options *message.QueryOptions) int {
+	if options == nil {
+		return 0
+	}
+	return len(options.PositionalValues) + len(options.NamedValues)
+}
+
+var uuidLiteralPattern = regexp.MustCompile(`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`)
+
+// redactCqlLiterals replaces the string, numeric, blob and uuid literals of a CQL statement with ?, the identifiers
+// (including quoted ones) and keywords are kept.
+func redactCqlLiterals(query string) string {
+	query = uuidLiteralPattern.ReplaceAllString(query, "?")
+	sb := &strings.Builder{}
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'':
+			// '' is an escaped quote inside of a string literal
+			j := i + 1
+			for j < len(query) && (query[j] != '\'' || (j+1 < len(query) && query[j+1] == '\'')) {
+				if query[j] == '\'' {
+					j++
+				}
+				j++
+			}
+			sb.WriteByte('?')
+			i = j + 1
+		case c == '$' && strings.HasPrefix(query[i:], "$$"):
+			end := strings.Index(query[i+2:], "$$")
+			sb.WriteByte('?')
+			if end < 0 {
+				i = len(query)
+			} else {
+				i += end + 4
+			}
+		case c == '"':
+			j := i + 1
+			for j < len(query) && (query[j] != '"' || (j+1 < len(query) && query[j+1] == '"')) {
+				if query[j] == '"' {
+					j++
+				}
+				j++
+			}
+			if j >= len(query) {
+				j = len(query) - 1
+			}
+			sb.WriteString(query[i : j+1])
+			i = j + 1
+		case isCqlIdentifierChar(c):
+			j := i
+			for j < len(query) && (isCqlIdentifierChar(query[j]) || query[j] == '.' && c >= '0' && c <= '9') {
+				j++
+			}
+			if c >= '0' && c <= '9' {
+				sb.WriteByte('?')
+			} else {
+				sb.WriteString(query[i:j])
+			}
+			i = j
+		default:
+			sb.WriteByte(c)
+			i++
+		}
+	}
+	return sb.String()
+}
+
+func isCqlIdentifierChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_'
+}
@@ -0,0 +1,105 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestFrameCapture_RingBuffer(t *testing.T) {
+	registry := newFrameCaptureRegistry(3, false)
+	capture := registry.start("127.0.0.1:5000")
+	other := registry.start("127.0.0.2:5000")
+	for i := 0; i < 5; i++ {
+		f := mockQueryFrame(t, fmt.Sprintf("SELECT * FROM ks.tbl%d", i))
+		f.Header.StreamId = int16(i)
+		capture.record(FrameDirectionClientRequest, f)
+	}
+	other.record(FrameDirectionClientRequest, mockQueryFrame(t, "SELECT * FROM ks.tbl"))
+
+	dumps := registry.dump("127.0.0.1")
+	require.Len(t, dumps, 1)
+	require.Equal(t, "127.0.0.1:5000", dumps[0].Client)
+	require.Nil(t, dumps[0].ClosedAt)
+	require.Equal(t, uint64(2), dumps[0].Dropped)
+	require.Len(t, dumps[0].Frames, 3)
+	for i, f := range dumps[0].Frames {
+		require.Equal(t, int16(i+2), f.StreamId)
+		require.Equal(t, FrameDirectionClientRequest, f.Direction)
+		require.Equal(t, "OpCode QUERY [0x07]", f.OpCode)
+		require.Empty(t, f.Body)
+	}
+	require.Len(t, registry.dump(""), 2)
+	require.Len(t, registry.dump("127.0.0.2:5000"), 1)
+	require.Empty(t, registry.dump("127.0.0.3"))
+
+	// the frames of closed connections are kept
+	capture.close()
+	dumps = registry.dump("127.0.0.1:5000")
+	require.Len(t, dumps, 1)
+	require.NotNil(t, dumps[0].ClosedAt)
+	for i := 0; i < closedFrameCapturesKept; i++ {
+		registry.start(fmt.Sprintf("127.0.1.1:%d", i)).close()
+	}
+	require.Empty(t, registry.dump("127.0.0.1:5000"))
+	require.Len(t, registry.dump(""), closedFrameCapturesKept+1)
+
+	var disabled *frameCaptureRegistry
+	require.Nil(t, disabled.start("127.0.0.1:5000"))
+	var disabledCapture *frameCapture
+	disabledCapture.record(FrameDirectionClientRequest, mockQueryFrame(t, "SELECT * FROM ks.tbl"))
+	disabledCapture.close()
+}
+
+func TestFrameCapture_Bodies(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  message.Message
+		expected string
+	}{
+		{"query", &message.Query{
+			Query:   "SELECT * FROM ks.tbl WHERE pk = 'secret' AND ck = ?",
+			Options: &message.QueryOptions{PositionalValues: []*primitive.Value{primitive.NewValue([]byte{1})}},
+		}, "QUERY SELECT * FROM ks.tbl WHERE pk = ? AND ck = ? (1 values)"},
+		{"prepare", &message.Prepare{Query: "INSERT INTO ks.tbl (pk, v) VALUES (?, 42)"},
+			"PREPARE INSERT INTO ks.tbl (pk, v) VALUES (?, ?)"},
+		{"execute", &message.Execute{QueryId: []byte{0xca, 0xfe}, Options: &message.QueryOptions{}},
+			"EXECUTE cafe (0 values)"},
+		{"auth response", &message.AuthResponse{Token: []byte("\x00user\x00password")}, "AUTH_RESPONSE <redacted>"},
+		{"rows", &message.RowsResult{Metadata: &message.RowsMetadata{ColumnCount: 1}, Data: message.RowSet{
+			message.Row{[]byte("secret")}, message.Row{[]byte("secret")}}}, "RESULT ROWS (2 rows)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, describeFrameBody(mockFrame(t, tt.message, primitive.ProtocolVersion4)))
+		})
+	}
+
+	registry := newFrameCaptureRegistry(10, true)
+	capture := registry.start("127.0.0.1:5000")
+	capture.record(FrameDirectionOriginResponse, mockFrame(t, &message.Overloaded{ErrorMessage: "busy"}, primitive.ProtocolVersion4))
+	require.Equal(t, "ERROR OVERLOADED (code=ErrorCode Overloaded [0x00001001], msg=busy)",
+		registry.dump("")[0].Frames[0].Body)
+}
+
+func TestRedactCqlLiterals(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"SELECT * FROM ks.tbl1 WHERE pk = 1", "SELECT * FROM ks.tbl1 WHERE pk = ?"},
+		{"INSERT INTO t (a, b, c) VALUES ('it''s', -1.5e3, 0xcafe)", "INSERT INTO t (a, b, c) VALUES (?, -?, ?)"},
+		{"UPDATE t SET v = $$body$$ WHERE id = 123e4567-e89b-12d3-a456-426614174000",
+			"UPDATE t SET v = ? WHERE id = ?"},
+		{"DELETE FROM t WHERE id = f81d4fae-7dec-11d0-a765-00a0c91e6bf6", "DELETE FROM t WHERE id = ?"},
+		{`SELECT "Quoted""1" FROM t WHERE k IN (1, 2) AND v = true`, `SELECT "Quoted""1" FROM t WHERE k IN (?, ?) AND v = true`},
+		{"SELECT * FROM t WHERE v = 'unterminated", "SELECT * FROM t WHERE v = ?"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			require.Equal(t, tt.expected, redactCqlLiterals(tt.query))
+		})
+	}
+}
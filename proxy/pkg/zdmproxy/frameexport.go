@@ -0,0 +1,427 @@
+package zdmproxy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	pcapMagicNumber  = 0xa1b2c3d4
+	pcapSnapLen      = 262144
+	pcapLinkTypeRaw  = 101 // the packets start with the IP header
+	pcapTcpFlagSyn   = 0x02
+	pcapTcpFlagPsh   = 0x08
+	pcapTcpFlagAck   = 0x10
+	pcapTcpHeaderLen = 20
+
+	// frames larger than this are split in several TCP segments so that the IP packets stay below 64KiB
+	pcapMaxSegmentPayload = 65000
+)
+
+// FrameExporter writes the frames of the connections of selected client addresses to files that can be analyzed
+// offline, a pcap file that Wireshark's CQL dissector can decode (use "Decode As..." if the proxy doesn't listen on
+// 9042) or a hex dump. It's created when ZDM_FRAME_EXPORT_DIR is set and the client addresses are selected at runtime
+// through the admin API.
+//
+// The pcap files contain synthetic IP and TCP headers built from the addresses of the client connection, one TCP
+// segment per frame (unless the frame is too large), they don't reflect the actual segmentation of the stream. Only the
+// frames exchanged with the client are exported, the frames are written as they are so they contain user data.
+type FrameExporter struct {
+	dir    string
+	format common.FrameExportFormat
+
+	lock    *sync.RWMutex
+	clients map[string]bool
+	streams map[*frameExportStream]bool
+}
+
+func NewFrameExporter(dir string, format common.FrameExportFormat) *FrameExporter {
+	return &FrameExporter{
+		dir:     dir,
+		format:  format,
+		lock:    &sync.RWMutex{},
+		clients: map[string]bool{},
+		streams: map[*frameExportStream]bool{},
+	}
+}
+
+func (e *FrameExporter) GetDir() string {
+	return e.dir
+}
+
+func (e *FrameExporter) GetFormat() common.FrameExportFormat {
+	return e.format
+}
+
+// Enable starts the export of the frames of the connections of a client IP, including the existing ones.
+func (e *FrameExporter) Enable(client string) error {
+	ip, err := parseFrameExportClient(client)
+	if err != nil {
+		return err
+	}
+	e.lock.Lock()
+	e.clients[ip] = true
+	e.lock.Unlock()
+	log.Infof("Frame export enabled for the connections of %v, files are written to %v.", ip, e.dir)
+	return nil
+}
+
+// Disable stops the export of the frames of the connections of a client IP and closes their files.
+func (e *FrameExporter) Disable(client string) error {
+	ip, err := parseFrameExportClient(client)
+	if err != nil {
+		return err
+	}
+	e.lock.Lock()
+	_, ok := e.clients[ip]
+	delete(e.clients, ip)
+	streams := make([]*frameExportStream, 0)
+	for s := range e.streams {
+		if s.clientIp == ip {
+			streams = append(streams, s)
+		}
+	}
+	e.lock.Unlock()
+	for _, s := range streams {
+		s.closeFile()
+	}
+	if ok {
+		log.Infof("Frame export disabled for the connections of %v.", ip)
+	}
+	return nil
+}
+
+// GetEnabledClients returns the client IPs whose frames are exported, sorted.
+func (e *FrameExporter) GetEnabledClients() []string {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	clients := make([]string, 0, len(e.clients))
+	for ip := range e.clients {
+		clients = append(clients, ip)
+	}
+	sort.Strings(clients)
+	return clients
+}
+
+func (e *FrameExporter) isEnabled(ip string) bool {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	return e.clients[ip]
+}
+
+func parseFrameExportClient(client string) (string, error) {
+	ip := net.ParseIP(strings.TrimSpace(client))
+	if ip == nil {
+		return "", fmt.Errorf("invalid client %v, it must be an IP address", client)
+	}
+	return ip.String(), nil
+}
+
+// start returns the export stream of a new client connection, nil if the frame export is disabled.
+func (e *FrameExporter) start(clientAddr net.Addr, proxyAddr net.Addr) *frameExportStream {
+	if e == nil {
+		return nil
+	}
+	client, proxy := toFrameExportTcpAddr(clientAddr), toFrameExportTcpAddr(proxyAddr)
+	if client.IP.To4() != nil && proxy.IP.To4() != nil {
+		client.IP, proxy.IP = client.IP.To4(), proxy.IP.To4()
+	} else {
+		client.IP, proxy.IP = client.IP.To16(), proxy.IP.To16()
+	}
+	s := &frameExportStream{
+		exporter:   e,
+		clientIp:   client.IP.String(),
+		clientAddr: client,
+		proxyAddr:  proxy,
+		lock:       &sync.Mutex{},
+	}
+	e.lock.Lock()
+	e.streams[s] = true
+	e.lock.Unlock()
+	return s
+}
+
+func (e *FrameExporter) finish(s *frameExportStream) {
+	e.lock.Lock()
+	delete(e.streams, s)
+	e.lock.Unlock()
+}
+
+func toFrameExportTcpAddr(addr net.Addr) *net.TCPAddr {
+	if tcpAddr, ok := addr.(*net.TCPAddr); ok && tcpAddr.IP != nil {
+		return &net.TCPAddr{IP: tcpAddr.IP, Port: tcpAddr.Port}
+	}
+	// the connections of the tests are not always TCP connections
+	return &net.TCPAddr{IP: net.IPv4zero.To4(), Port: 0}
+}
+
+// frameExportStream exports the frames of a client connection while its client IP is enabled, the file is opened by
+// the first frame after the IP is enabled. Its methods are nil-safe.
+type frameExportStream struct {
+	exporter   *FrameExporter
+	clientIp   string
+	clientAddr *net.TCPAddr
+	proxyAddr  *net.TCPAddr
+
+	lock       *sync.Mutex
+	file       *os.File
+	writer     *bufio.Writer
+	openFailed bool
+	closed     bool
+	clientSeq  uint32 // sequence numbers of the synthetic TCP segments of the pcap format
+	proxySeq   uint32
+	buf        *bytes.Buffer
+}
+
+func (s *frameExportStream) record(direction string, f *frame.RawFrame) {
+	if s == nil || f == nil {
+		return
+	}
+	enabled := s.exporter.isEnabled(s.clientIp)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.closed {
+		return
+	}
+	if !enabled {
+		s.openFailed = false
+		s.closeFileLocked()
+		return
+	}
+	if s.file == nil {
+		if s.openFailed {
+			return
+		}
+		if err := s.openFile(); err != nil {
+			s.openFailed = true
+			log.Warnf("Could not open the frame export file of client connection %v: %v", s.clientAddr, err)
+			return
+		}
+	}
+
+	s.buf.Reset()
+	if err := defaultCodec.EncodeRawFrame(f, s.buf); err != nil {
+		log.Warnf("Could not encode frame of client connection %v for the frame export: %v", s.clientAddr, err)
+		return
+	}
+	fromClient := direction == FrameDirectionClientRequest
+	var err error
+	switch s.exporter.format {
+	case common.FrameExportFormatHex:
+		err = s.writeHexDump(time.Now(), direction, fromClient, s.buf.Bytes())
+	default:
+		err = s.writePcapSegments(time.Now(), fromClient, pcapTcpFlagPsh|pcapTcpFlagAck, s.buf.Bytes())
+	}
+	if err == nil {
+		// flushed after every frame so that the file can be analyzed while the connection is still open
+		err = s.writer.Flush()
+	}
+	if err != nil {
+		log.Warnf("Could not write to the frame export file %v, closing it: %v", s.file.Name(), err)
+		s.openFailed = true
+		s.closeFileLocked()
+	}
+}
+
+func (s *frameExportStream) close() {
+	if s == nil {
+		return
+	}
+	s.lock.Lock()
+	s.closed = true
+	s.closeFileLocked()
+	s.lock.Unlock()
+	s.exporter.finish(s)
+}
+
+func (s *frameExportStream) openFile() error {
+	extension := "pcap"
+	if s.exporter.format == common.FrameExportFormatHex {
+		extension = "txt"
+	}
+	name := fmt.Sprintf("zdm-frames-%v_%d-%v.%v", strings.ReplaceAll(s.clientIp, ":", "-"), s.clientAddr.Port,
+		time.Now().UTC().Format("20060102T150405.000000000"), extension)
+	file, err := os.OpenFile(filepath.Join(s.exporter.dir, name), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	s.file = file
+	s.writer = bufio.NewWriter(file)
+	s.buf = &bytes.Buffer{}
+
+	now := time.Now()
+	switch s.exporter.format {
+	case common.FrameExportFormatHex:
+		_, err = fmt.Fprintf(s.writer, "# zdm-proxy frames of client connection %v to %v\n", s.clientAddr, s.proxyAddr)
+	default:
+		err = writePcapGlobalHeader(s.writer)
+		if err == nil {
+			err = s.writePcapHandshake(now)
+		}
+	}
+	if err == nil {
+		err = s.writer.Flush()
+	}
+	if err != nil {
+		s.closeFileLocked()
+		return err
+	}
+	log.Infof("Exporting the frames of client connection %v to %v.", s.clientAddr, file.Name())
+	return nil
+}
+
+func (s *frameExportStream) closeFile() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.closeFileLocked()
+}
+
+func (s *frameExportStream) closeFileLocked() {
+	if s.file == nil {
+		return
+	}
+	if err := s.writer.Flush(); err != nil {
+		log.Warnf("Could not write to the frame export file %v: %v", s.file.Name(), err)
+	}
+	if err := s.file.Close(); err != nil {
+		log.Warnf("Could not close the frame export file %v: %v", s.file.Name(), err)
+	}
+	s.file = nil
+	s.writer = nil
+}
+
+func (s *frameExportStream) writeHexDump(t time.Time, direction string, fromClient bool, data []byte) error {
+	src, dst := s.proxyAddr, s.clientAddr
+	if fromClient {
+		src, dst = s.clientAddr, s.proxyAddr
+	}
+	_, err := fmt.Fprintf(s.writer, "# %v %v %v > %v (%d bytes)\n%v\n",
+		t.UTC().Format(time.RFC3339Nano), direction, src, dst, len(data), hex.Dump(data))
+	return err
+}
+
+// writePcapHandshake writes a synthetic TCP handshake so that the analyzers see the beginning of a TCP stream, the
+// export of an existing connection starts at a frame boundary so the frames can be decoded from there.
+func (s *frameExportStream) writePcapHandshake(t time.Time) error {
+	s.clientSeq, s.proxySeq = 0, 0
+	if err := s.writePcapSegment(t, true, pcapTcpFlagSyn, nil); err != nil {
+		return err
+	}
+	s.clientSeq++
+	if err := s.writePcapSegment(t, false, pcapTcpFlagSyn|pcapTcpFlagAck, nil); err != nil {
+		return err
+	}
+	s.proxySeq++
+	return s.writePcapSegment(t, true, pcapTcpFlagAck, nil)
+}
+
+func (s *frameExportStream) writePcapSegments(t time.Time, fromClient bool, flags byte, data []byte) error {
+	for len(data) > 0 {
+		segment := data
+		if len(segment) > pcapMaxSegmentPayload {
+			segment = segment[:pcapMaxSegmentPayload]
+		}
+		if err := s.writePcapSegment(t, fromClient, flags, segment); err != nil {
+			return err
+		}
+		if fromClient {
+			s.clientSeq += uint32(len(segment))
+		} else {
+			s.proxySeq += uint32(len(segment))
+		}
+		data = data[len(segment):]
+	}
+	return nil
+}
+
+func (s *frameExportStream) writePcapSegment(t time.Time, fromClient bool, flags byte, payload []byte) error {
+	src, dst, seq, ack := s.proxyAddr, s.clientAddr, s.proxySeq, s.clientSeq
+	if fromClient {
+		src, dst, seq, ack = s.clientAddr, s.proxyAddr, s.clientSeq, s.proxySeq
+	}
+	if flags&pcapTcpFlagAck == 0 {
+		ack = 0
+	}
+	packet := buildPcapPacket(src, dst, seq, ack, flags, payload)
+
+	record := make([]byte, 16)
+	binary.LittleEndian.PutUint32(record[0:], uint32(t.Unix()))
+	binary.LittleEndian.PutUint32(record[4:], uint32(t.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(record[8:], uint32(len(packet)))
+	binary.LittleEndian.PutUint32(record[12:], uint32(len(packet)))
+	if _, err := s.writer.Write(record); err != nil {
+		return err
+	}
+	_, err := s.writer.Write(packet)
+	return err
+}
+
+func writePcapGlobalHeader(w *bufio.Writer) error {
+	header := make([]byte, 24)
+	binary.LittleEndian.PutUint32(header[0:], pcapMagicNumber)
+	binary.LittleEndian.PutUint16(header[4:], 2) // version 2.4
+	binary.LittleEndian.PutUint16(header[6:], 4)
+	binary.LittleEndian.PutUint32(header[16:], pcapSnapLen)
+	binary.LittleEndian.PutUint32(header[20:], pcapLinkTypeRaw)
+	_, err := w.Write(header)
+	return err
+}
+
+// buildPcapPacket returns an IPv4 or IPv6 packet with a TCP segment, the TCP checksum is left empty.
+func buildPcapPacket(src *net.TCPAddr, dst *net.TCPAddr, seq uint32, ack uint32, flags byte, payload []byte) []byte {
+	tcp := make([]byte, pcapTcpHeaderLen, pcapTcpHeaderLen+len(payload))
+	binary.BigEndian.PutUint16(tcp[0:], uint16(src.Port))
+	binary.BigEndian.PutUint16(tcp[2:], uint16(dst.Port))
+	binary.BigEndian.PutUint32(tcp[4:], seq)
+	binary.BigEndian.PutUint32(tcp[8:], ack)
+	tcp[12] = (pcapTcpHeaderLen / 4) << 4
+	tcp[13] = flags
+	binary.BigEndian.PutUint16(tcp[14:], 0xffff) // window
+	tcp = append(tcp, payload...)
+
+	if src4, dst4 := src.IP.To4(), dst.IP.To4(); src4 != nil && dst4 != nil {
+		ip := make([]byte, 20, 20+len(tcp))
+		ip[0] = 0x45 // version 4, 5 words
+		binary.BigEndian.PutUint16(ip[2:], uint16(20+len(tcp)))
+		binary.BigEndian.PutUint16(ip[6:], 0x4000) // don't fragment
+		ip[8] = 64                                 // ttl
+		ip[9] = 6                                  // tcp
+		copy(ip[12:16], src4)
+		copy(ip[16:20], dst4)
+		binary.BigEndian.PutUint16(ip[10:], ipv4HeaderChecksum(ip))
+		return append(ip, tcp...)
+	}
+
+	ip := make([]byte, 40, 40+len(tcp))
+	ip[0] = 0x60 // version 6
+	binary.BigEndian.PutUint16(ip[4:], uint16(len(tcp)))
+	ip[6] = 6  // tcp
+	ip[7] = 64 // hop limit
+	copy(ip[8:24], src.IP.To16())
+	copy(ip[24:40], dst.IP.To16())
+	return append(ip, tcp...)
+}
+
+func ipv4HeaderChecksum(header []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(header); i += 2 {
+		sum += uint32(binary.BigEndian.Uint16(header[i:]))
+	}
+	for sum > 0xffff {
+		sum = (sum >> 16) + (sum & 0xffff)
+	}
+	return ^uint16(sum)
+}
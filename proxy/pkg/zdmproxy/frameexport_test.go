@@ -0,0 +1,120 @@
+package zdmproxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFrameExport_Pcap(t *testing.T) {
+	dir := t.TempDir()
+	exporter := NewFrameExporter(dir, common.FrameExportFormatPcap)
+	clientAddr := &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 50000}
+	proxyAddr := &net.TCPAddr{IP: net.ParseIP("10.0.0.2"), Port: 9042}
+	stream := exporter.start(clientAddr, proxyAddr)
+
+	request := mockQueryFrame(t, "SELECT * FROM ks.tbl")
+	stream.record(FrameDirectionClientRequest, request)
+	requireFrameExportFiles(t, dir, 0)
+
+	require.Nil(t, exporter.Enable("10.0.0.1"))
+	require.Equal(t, []string{"10.0.0.1"}, exporter.GetEnabledClients())
+	stream.record(FrameDirectionClientRequest, request)
+	stream.record(FrameDirectionClientResponse, request)
+	stream.close()
+
+	files := requireFrameExportFiles(t, dir, 1)
+	require.True(t, strings.HasPrefix(filepath.Base(files[0]), "zdm-frames-10.0.0.1_50000-"))
+	require.True(t, strings.HasSuffix(files[0], ".pcap"))
+	content, err := os.ReadFile(files[0])
+	require.Nil(t, err)
+
+	require.Equal(t, uint32(pcapMagicNumber), binary.LittleEndian.Uint32(content[0:]))
+	require.Equal(t, uint32(pcapLinkTypeRaw), binary.LittleEndian.Uint32(content[20:]))
+	encodedRequest := &bytes.Buffer{}
+	require.Nil(t, defaultCodec.EncodeRawFrame(request, encodedRequest))
+
+	// synthetic handshake then the request and the response
+	packets := readPcapPackets(t, content[24:])
+	require.Len(t, packets, 5)
+	expectedFlags := []byte{pcapTcpFlagSyn, pcapTcpFlagSyn | pcapTcpFlagAck, pcapTcpFlagAck,
+		pcapTcpFlagPsh | pcapTcpFlagAck, pcapTcpFlagPsh | pcapTcpFlagAck}
+	for i, packet := range packets {
+		require.Equal(t, byte(0x45), packet[0])
+		require.Equal(t, uint16(0), ipv4HeaderChecksum(packet[:20]))
+		require.Equal(t, expectedFlags[i], packet[33])
+	}
+	require.Equal(t, []byte{10, 0, 0, 1}, packets[3][12:16])
+	require.Equal(t, uint16(50000), binary.BigEndian.Uint16(packets[3][20:]))
+	require.Equal(t, uint16(9042), binary.BigEndian.Uint16(packets[3][22:]))
+	require.Equal(t, uint32(1), binary.BigEndian.Uint32(packets[3][24:]))
+	require.Equal(t, encodedRequest.Bytes(), packets[3][40:])
+	require.Equal(t, []byte{10, 0, 0, 2}, packets[4][12:16])
+	require.Equal(t, uint32(1+encodedRequest.Len()), binary.BigEndian.Uint32(packets[4][28:]))
+
+	// the stream is closed so no other file is opened
+	stream.record(FrameDirectionClientRequest, request)
+	requireFrameExportFiles(t, dir, 1)
+}
+
+func TestFrameExport_HexAndToggle(t *testing.T) {
+	dir := t.TempDir()
+	exporter := NewFrameExporter(dir, common.FrameExportFormatHex)
+	stream := exporter.start(
+		&net.TCPAddr{IP: net.ParseIP("::1"), Port: 50000}, &net.TCPAddr{IP: net.ParseIP("::1"), Port: 9042})
+
+	require.NotNil(t, exporter.Enable("a.b.c.d"))
+	require.Nil(t, exporter.Enable("::1"))
+	stream.record(FrameDirectionClientRequest, mockQueryFrame(t, "SELECT * FROM ks.tbl"))
+	files := requireFrameExportFiles(t, dir, 1)
+	require.True(t, strings.HasPrefix(filepath.Base(files[0]), "zdm-frames---1_50000-"))
+
+	// disabling the client closes the file, enabling it again opens a new one
+	require.Nil(t, exporter.Disable("::1"))
+	require.Empty(t, exporter.GetEnabledClients())
+	stream.record(FrameDirectionClientRequest, mockQueryFrame(t, "SELECT * FROM ks.other"))
+	requireFrameExportFiles(t, dir, 1)
+	require.Nil(t, exporter.Enable("0:0:0:0:0:0:0:1"))
+	stream.record(FrameDirectionClientRequest, mockQueryFrame(t, "SELECT * FROM ks.tbl2"))
+	stream.close()
+	files = requireFrameExportFiles(t, dir, 2)
+
+	content, err := os.ReadFile(files[0])
+	require.Nil(t, err)
+	lines := strings.Split(string(content), "\n")
+	require.Equal(t, "# zdm-proxy frames of client connection [::1]:50000 to [::1]:9042", lines[0])
+	require.Contains(t, lines[1], " CLIENT_REQUEST [::1]:50000 > [::1]:9042 (")
+	require.Contains(t, lines[2], "04 00 00 01 07 00 00 00")
+	require.NotContains(t, string(content), "ks.other")
+
+	var disabled *FrameExporter
+	require.Nil(t, disabled.start(nil, nil))
+	var disabledStream *frameExportStream
+	disabledStream.record(FrameDirectionClientRequest, mockQueryFrame(t, "SELECT * FROM ks.tbl"))
+	disabledStream.close()
+}
+
+func requireFrameExportFiles(t *testing.T, dir string, expected int) []string {
+	files, err := filepath.Glob(filepath.Join(dir, "zdm-frames-*"))
+	require.Nil(t, err)
+	require.Len(t, files, expected)
+	return files
+}
+
+func readPcapPackets(t *testing.T, records []byte) [][]byte {
+	packets := make([][]byte, 0)
+	for len(records) > 0 {
+		require.GreaterOrEqual(t, len(records), 16)
+		length := binary.LittleEndian.Uint32(records[8:])
+		require.Equal(t, length, binary.LittleEndian.Uint32(records[12:]))
+		packets = append(packets, records[16:16+length])
+		records = records[16+length:]
+	}
+	return packets
+}
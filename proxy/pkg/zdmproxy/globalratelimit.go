@@ -0,0 +1,186 @@
+package zdmproxy
+
+import (
+	"context"
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+// globalRateLimitRefillInterval is how often an instance takes tokens from the bucket of the fleet.
+const globalRateLimitRefillInterval = 100 * time.Millisecond
+
+// globalTokenBucket is the token bucket shared by the instances of the fleet, see sharedconfig.GlobalRateLimiter.
+type globalTokenBucket interface {
+	Take(ctx context.Context, rate int, n int) (int, error)
+}
+
+// globalRequestRateLimiter enforces the request rate limit of the whole fleet. Requests consume tokens from a local
+// reserve that is refilled in the background with the number of requests this instance received during the previous
+// interval, so the key-value store is updated a few times per second instead of once per request and the busiest
+// instances get the largest share of the limit. A request that finds the reserve empty (e.g. at the start of a burst)
+// takes tokens from the bucket itself, the concurrent requests wait for the same update of the bucket, so requests
+// are only rejected when the bucket of the fleet is empty.
+//
+// The reserves are taken from the bucket in advance and an idle instance holds a single token. With a rate lower than
+// the number of instances the tokens held by the idle instances are not available to the busy ones, so fewer requests
+// than the limit may be allowed.
+//
+// Requests are allowed while the store can't be reached, the limit of the instance (ZDM_PROXY_REQUEST_RATE_LIMIT)
+// still applies in that case.
+type globalRequestRateLimiter struct {
+	lock        *sync.Mutex
+	rate        int
+	tokens      int
+	demand      int
+	unavailable bool
+	bucket      globalTokenBucket
+
+	// closed when the bucket update of the requests that found the reserve empty completes, nil if there is none
+	fetchDone chan struct{}
+	// set when the bucket was empty for these requests, they are rejected without updating it until the next refill
+	exhausted bool
+}
+
+func newGlobalRequestRateLimiter(rate int, bucket globalTokenBucket) *globalRequestRateLimiter {
+	return &globalRequestRateLimiter{
+		lock:   &sync.Mutex{},
+		rate:   rate,
+		bucket: bucket,
+	}
+}
+
+// Allow returns false if the request should be rejected, it's safe to call on a nil limiter (global rate limiting
+// disabled).
+func (l *globalRequestRateLimiter) Allow() bool {
+	if l == nil {
+		return true
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.rate <= 0 {
+		return true
+	}
+	l.demand++
+	if l.tokens == 0 && !l.unavailable && !l.exhausted {
+		l.fetch()
+	}
+	if l.tokens > 0 {
+		l.tokens--
+		return true
+	}
+	return l.unavailable
+}
+
+// fetch takes tokens from the bucket for the requests that find the reserve empty, or waits for the update started by
+// another request. It must be called with the lock held, which is released while the bucket is updated.
+func (l *globalRequestRateLimiter) fetch() {
+	if fetchDone := l.fetchDone; fetchDone != nil {
+		l.lock.Unlock()
+		<-fetchDone
+		l.lock.Lock()
+		return
+	}
+	fetchDone := make(chan struct{})
+	l.fetchDone = fetchDone
+	// at least the tokens of an interval at the full rate, the next requests of a burst would each update the bucket
+	// otherwise
+	demand := l.rate * int(globalRateLimitRefillInterval) / int(time.Second)
+	if l.demand > demand {
+		demand = l.demand
+	}
+	rate, requested := l.rate, getRequestedGlobalTokens(l.rate, demand, l.tokens)
+	l.lock.Unlock()
+	taken, err := l.bucket.Take(context.Background(), rate, requested)
+	l.lock.Lock()
+	l.addTokens(rate, taken, err)
+	l.exhausted = err == nil && taken == 0
+	l.fetchDone = nil
+	close(fetchDone)
+}
+
+func (l *globalRequestRateLimiter) SetRate(rate int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if rate == l.rate {
+		return
+	}
+	l.rate = rate
+	l.tokens = 0
+}
+
+func (l *globalRequestRateLimiter) GetRate() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.rate
+}
+
+// run refills the local reserve until ctx is done.
+func (l *globalRequestRateLimiter) run(ctx context.Context) {
+	ticker := time.NewTicker(globalRateLimitRefillInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			l.refill(ctx)
+		}
+	}
+}
+
+func (l *globalRequestRateLimiter) refill(ctx context.Context) {
+	l.lock.Lock()
+	rate, demand, tokens := l.rate, l.demand, l.tokens
+	l.demand = 0
+	l.exhausted = false
+	l.lock.Unlock()
+	if rate <= 0 {
+		return
+	}
+
+	requested := getRequestedGlobalTokens(rate, demand, tokens)
+	if requested <= 0 {
+		return
+	}
+
+	taken, err := l.bucket.Take(ctx, rate, requested)
+	if ctx.Err() != nil {
+		return
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.addTokens(rate, taken, err)
+}
+
+// getRequestedGlobalTokens returns the number of tokens that the reserve needs for the given demand, up to the rate.
+// An idle instance keeps a single token so that its next request doesn't wait for the bucket.
+func getRequestedGlobalTokens(rate int, demand int, tokens int) int {
+	requested := demand
+	if requested < 1 {
+		requested = 1
+	}
+	if requested > rate {
+		requested = rate
+	}
+	return requested - tokens
+}
+
+// addTokens adds the tokens taken from the bucket at the given rate to the reserve, it must be called with the lock
+// held.
+func (l *globalRequestRateLimiter) addTokens(rate int, taken int, err error) {
+	if err != nil {
+		if !l.unavailable {
+			log.Warnf("Global request rate limit not enforced until the bucket of the fleet can be updated: %v", err)
+			l.unavailable = true
+		}
+		return
+	}
+	if l.unavailable {
+		log.Infof("Global request rate limit enforced again.")
+		l.unavailable = false
+	}
+	if l.rate == rate {
+		l.tokens += taken
+	}
+}
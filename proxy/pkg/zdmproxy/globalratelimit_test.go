@@ -0,0 +1,118 @@
+package zdmproxy
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/require"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeGlobalTokenBucket struct {
+	tokens    int
+	requested []int
+	err       error
+}
+
+func (b *fakeGlobalTokenBucket) Take(_ context.Context, _ int, n int) (int, error) {
+	b.requested = append(b.requested, n)
+	if b.err != nil {
+		return 0, b.err
+	}
+	if n > b.tokens {
+		n = b.tokens
+	}
+	b.tokens -= n
+	return n, nil
+}
+
+func TestGlobalRequestRateLimiter_Allow(t *testing.T) {
+	bucket := &fakeGlobalTokenBucket{tokens: 100}
+	limiter := newGlobalRequestRateLimiter(50, bucket)
+
+	allowed := func(n int) int {
+		count := 0
+		for i := 0; i < n; i++ {
+			if limiter.Allow() {
+				count++
+			}
+		}
+		return count
+	}
+
+	// the first request takes the tokens of an interval at the full rate from the bucket
+	require.Equal(t, 1, allowed(1))
+	require.Equal(t, []int{5}, bucket.requested)
+	limiter.refill(context.Background())
+	require.Equal(t, []int{5}, bucket.requested)
+
+	// a burst takes the tokens it needs from the bucket when the reserve is empty
+	require.Equal(t, 20, allowed(20))
+	require.Equal(t, []int{5, 5, 10, 20}, bucket.requested)
+
+	// the reserve is refilled with the requests of the previous interval, up to the rate
+	limiter.refill(context.Background())
+	require.Equal(t, []int{5, 5, 10, 20, 1}, bucket.requested)
+	require.Equal(t, 20, allowed(20))
+	limiter.refill(context.Background())
+	require.Equal(t, []int{5, 5, 10, 20, 1, 20}, bucket.requested)
+
+	// the other instances used the tokens of the fleet, the bucket is only updated once until the next refill
+	bucket.tokens = 0
+	require.Equal(t, 20, allowed(30))
+	require.Equal(t, []int{5, 5, 10, 20, 1, 20, 21}, bucket.requested)
+	limiter.refill(context.Background())
+	require.Equal(t, []int{5, 5, 10, 20, 1, 20, 21, 30}, bucket.requested)
+
+	// requests are allowed while the store is unreachable
+	bucket.err = errors.New("connection refused")
+	require.Equal(t, 10, allowed(10))
+	bucket.err = nil
+	bucket.tokens = 100
+	limiter.refill(context.Background())
+	require.Equal(t, 10, allowed(10))
+
+	limiter.SetRate(0)
+	require.Equal(t, 20, allowed(20))
+	require.Equal(t, 0, limiter.GetRate())
+	requests := len(bucket.requested)
+	limiter.refill(context.Background())
+	require.Len(t, bucket.requested, requests)
+}
+
+type blockingGlobalTokenBucket struct {
+	takes   int32
+	release chan struct{}
+}
+
+func (b *blockingGlobalTokenBucket) Take(_ context.Context, _ int, n int) (int, error) {
+	atomic.AddInt32(&b.takes, 1)
+	<-b.release
+	return n, nil
+}
+
+func TestGlobalRequestRateLimiter_ConcurrentFetch(t *testing.T) {
+	bucket := &blockingGlobalTokenBucket{release: make(chan struct{})}
+	limiter := newGlobalRequestRateLimiter(100, bucket)
+
+	results := make(chan bool, 5)
+	for i := 0; i < 5; i++ {
+		go func() {
+			results <- limiter.Allow()
+		}()
+	}
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&bucket.takes) == 1
+	}, time.Second, time.Millisecond)
+	close(bucket.release)
+	for i := 0; i < 5; i++ {
+		require.True(t, <-results)
+	}
+	require.Equal(t, int32(1), atomic.LoadInt32(&bucket.takes))
+}
+
+func TestGlobalRequestRateLimiter_Disabled(t *testing.T) {
+	var limiter *globalRequestRateLimiter
+	require.True(t, limiter.Allow())
+}
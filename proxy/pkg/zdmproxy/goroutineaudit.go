@@ -0,0 +1,154 @@
+package zdmproxy
+
+import (
+	"context"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// handlerGoroutines counts the running goroutines of a client handler, i.e. the goroutines of the handler itself, of
+// its client and cluster connectors and of their write coalescers. All of them must exit once the client handler is
+// closed, a goroutine that is blocked forever (e.g. on a channel that is never closed after an abnormal disconnect)
+// keeps the memory of the connection alive.
+type handlerGoroutines struct {
+	clientAddr string
+
+	running          int32
+	closedAtUnixNano int64
+
+	// only accessed by the audit
+	leakReported bool
+}
+
+// start runs fn in a new goroutine that is counted until fn returns, it's safe to call on a nil handlerGoroutines
+// (audit disabled).
+func (g *handlerGoroutines) start(fn func()) {
+	if g == nil {
+		go fn()
+		return
+	}
+	atomic.AddInt32(&g.running, 1)
+	go func() {
+		defer atomic.AddInt32(&g.running, -1)
+		fn()
+	}()
+}
+
+// close records that the client handler is closed, its goroutines are expected to exit from now on.
+func (g *handlerGoroutines) close() {
+	if g == nil {
+		return
+	}
+	atomic.CompareAndSwapInt64(&g.closedAtUnixNano, 0, time.Now().UnixNano())
+}
+
+func (g *handlerGoroutines) getRunning() int {
+	return int(atomic.LoadInt32(&g.running))
+}
+
+// goroutineAudit periodically reconciles the goroutines of the client handlers (ZDM_PROXY_GOROUTINE_AUDIT_INTERVAL_MS):
+// the goroutines of the open client handlers are expected, those of the closed client handlers should be gone. A
+// closed client handler whose goroutines are still running after ZDM_PROXY_GOROUTINE_LEAK_GRACE_PERIOD_MS is reported
+// once as a leak suspect, it's forgotten when its last goroutine exits.
+type goroutineAudit struct {
+	config       *common.GoroutineAuditConfig
+	proxyMetrics *metrics.ProxyMetrics
+	now          func() time.Time
+
+	lock     *sync.Mutex
+	handlers map[*handlerGoroutines]bool
+
+	cancelFn context.CancelFunc
+	wg       *sync.WaitGroup
+}
+
+func newGoroutineAudit(config *common.GoroutineAuditConfig, proxyMetrics *metrics.ProxyMetrics) *goroutineAudit {
+	return &goroutineAudit{
+		config:       config,
+		proxyMetrics: proxyMetrics,
+		now:          time.Now,
+		lock:         &sync.Mutex{},
+		handlers:     map[*handlerGoroutines]bool{},
+		cancelFn:     func() {},
+		wg:           &sync.WaitGroup{},
+	}
+}
+
+// register returns the goroutine counter of a new client handler, nil if the audit is disabled.
+func (a *goroutineAudit) register(clientAddr string) *handlerGoroutines {
+	if a == nil {
+		return nil
+	}
+	g := &handlerGoroutines{clientAddr: clientAddr}
+	a.lock.Lock()
+	a.handlers[g] = true
+	a.lock.Unlock()
+	return g
+}
+
+func (a *goroutineAudit) Start() {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	a.cancelFn = cancelFn
+	a.wg.Add(1)
+	go func() {
+		defer a.wg.Done()
+		ticker := time.NewTicker(a.config.Interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				a.reconcile()
+			}
+		}
+	}()
+}
+
+func (a *goroutineAudit) Close() {
+	a.cancelFn()
+	a.wg.Wait()
+}
+
+func (a *goroutineAudit) reconcile() {
+	now := a.now()
+	openHandlers, openGoroutines, closedGoroutines := 0, 0, 0
+	var suspects []*handlerGoroutines
+
+	a.lock.Lock()
+	for g := range a.handlers {
+		running := g.getRunning()
+		closedAtUnixNano := atomic.LoadInt64(&g.closedAtUnixNano)
+		if closedAtUnixNano == 0 {
+			openHandlers++
+			openGoroutines += running
+			continue
+		}
+		if running == 0 {
+			delete(a.handlers, g)
+			continue
+		}
+		closedGoroutines += running
+		if !g.leakReported && now.Sub(time.Unix(0, closedAtUnixNano)) >= a.config.LeakGracePeriod {
+			g.leakReported = true
+			suspects = append(suspects, g)
+		}
+	}
+	a.lock.Unlock()
+
+	a.proxyMetrics.Goroutines.Set(runtime.NumGoroutine())
+	a.proxyMetrics.OpenClientHandlerGoroutines.Set(openGoroutines)
+	a.proxyMetrics.ClosedClientHandlerGoroutines.Set(closedGoroutines)
+	for _, g := range suspects {
+		a.proxyMetrics.GoroutineLeakSuspects.Add(1)
+		log.Warnf("%d goroutines of the client handler of %v are still running more than %v after it was closed, "+
+			"they are most likely leaked.", g.getRunning(), g.clientAddr, a.config.LeakGracePeriod)
+	}
+	log.Debugf("Goroutine audit: %d goroutines for %d open client handlers, %d goroutines of closed client handlers "+
+		"and %d goroutines in total.", openGoroutines, openHandlers, closedGoroutines, runtime.NumGoroutine())
+}
@@ -0,0 +1,67 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestGoroutineAudit(t *testing.T) {
+	proxyMetrics := newFakeProxyMetrics()
+	openGoroutines, closedGoroutines, leakSuspects := &countingGauge{}, &countingGauge{}, &countingGauge{}
+	proxyMetrics.OpenClientHandlerGoroutines = openGoroutines
+	proxyMetrics.ClosedClientHandlerGoroutines = closedGoroutines
+	proxyMetrics.GoroutineLeakSuspects = leakSuspects
+
+	now := time.Unix(1000, 0)
+	audit := newGoroutineAudit(
+		&common.GoroutineAuditConfig{Interval: time.Minute, LeakGracePeriod: 30 * time.Second}, proxyMetrics)
+	audit.now = func() time.Time { return now }
+
+	open := audit.register("127.0.0.1:1000")
+	closed := audit.register("127.0.0.1:2000")
+	blocked := make(chan struct{})
+	exited := make(chan struct{}, 3)
+	for _, g := range []*handlerGoroutines{open, closed, closed} {
+		g.start(func() {
+			<-blocked
+			exited <- struct{}{}
+		})
+	}
+	closed.closedAtUnixNano = now.UnixNano()
+
+	audit.reconcile()
+	require.Equal(t, 1, openGoroutines.value)
+	require.Equal(t, 2, closedGoroutines.value)
+	require.Equal(t, 0, leakSuspects.value)
+
+	// the closed client handler is reported once after the grace period
+	now = now.Add(30 * time.Second)
+	audit.reconcile()
+	audit.reconcile()
+	require.Equal(t, 1, leakSuspects.value)
+	require.Len(t, audit.handlers, 2)
+
+	// it's forgotten once its goroutines exit
+	close(blocked)
+	for i := 0; i < 3; i++ {
+		<-exited
+	}
+	require.Eventually(t, func() bool {
+		return open.getRunning() == 0 && closed.getRunning() == 0
+	}, time.Second, time.Millisecond)
+	audit.reconcile()
+	require.Equal(t, 0, openGoroutines.value)
+	require.Equal(t, 0, closedGoroutines.value)
+	require.Len(t, audit.handlers, 1)
+
+	// the audit is disabled
+	var disabled *goroutineAudit
+	goroutines := disabled.register("127.0.0.1:3000")
+	require.Nil(t, goroutines)
+	done := make(chan struct{})
+	goroutines.start(func() { close(done) })
+	<-done
+	goroutines.close()
+}
@@ -0,0 +1,273 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// guardrails mirrors the batch and mutation size guardrails of Cassandra at the proxy tier, so that the large writes
+// are detected (and optionally rejected) before they reach either cluster. It's enabled by the ZDM_GUARDRAIL_*
+// thresholds.
+//
+// The size of a write is the size of its request body, which includes the statements (or prepared ids) and the bound
+// values, so it's an upper bound of the mutation size that Cassandra computes. BATCH requests and QUERY requests with
+// a BATCH statement are checked against the batch thresholds (the number of statements of the latter is unknown), the
+// other QUERY and EXECUTE writes are checked against the mutation thresholds. Reads are not checked against these
+// thresholds but ZDM_GUARDRAIL_MAX_QUERY_LENGTH and ZDM_GUARDRAIL_MAX_VALUES_SIZE_BYTES apply to every QUERY, PREPARE,
+// EXECUTE and BATCH request so that an oversized statement is rejected before it's parsed by both clusters.
+type guardrails struct {
+	config       *common.GuardrailsConfig
+	proxyMetrics *metrics.ProxyMetrics
+}
+
+func newGuardrails(config *common.GuardrailsConfig, proxyMetrics *metrics.ProxyMetrics) *guardrails {
+	return &guardrails{config: config, proxyMetrics: proxyMetrics}
+}
+
+// guardrailError is returned by check when a request exceeds a fail threshold or a limit, the client receives an
+// INVALID error.
+type guardrailError struct {
+	msg string
+}
+
+func (e *guardrailError) Error() string {
+	return e.msg
+}
+
+type guardrailThreshold struct {
+	description string // e.g. "Batch of 6000 bytes"
+	value       int
+	unit        string
+	warn        int
+	warnName    string
+	fail        int
+	failName    string
+	warnings    metrics.Counter
+	rejections  metrics.Counter
+}
+
+// check returns the client warnings of a write that exceeds warn thresholds (nil unless
+// ZDM_GUARDRAIL_CLIENT_WARNINGS_ENABLED is set) or a *guardrailError if it exceeds a fail threshold. It's nil safe.
+func (g *guardrails) check(
+	requestInfo RequestInfo, frameContext *frameDecodeContext, currentKeyspace string,
+	timeUuidGenerator TimeUuidGenerator) ([]string, error) {
+	if g == nil {
+		return nil, nil
+	}
+	err := g.checkLimits(frameContext)
+	if err != nil {
+		return nil, err
+	}
+	if !requestInfo.ShouldBeTrackedInMetrics() {
+		return nil, nil
+	}
+
+	rawFrame := frameContext.GetRawFrame()
+	size := int(rawFrame.Header.BodyLength)
+	var thresholds []*guardrailThreshold
+	switch castedRequestInfo := requestInfo.(type) {
+	case *BatchRequestInfo:
+		thresholds = append(thresholds, g.batchSizeThreshold(size))
+		if g.config.BatchStatementsWarn > 0 || g.config.BatchStatementsFail > 0 {
+			decodedFrame, err := frameContext.GetOrDecodeFrame()
+			if err != nil {
+				return nil, err
+			}
+			batch, ok := decodedFrame.Body.Message.(*message.Batch)
+			if !ok {
+				return nil, fmt.Errorf("expected BATCH request but got %v", decodedFrame.Body.Message.GetOpCode())
+			}
+			thresholds = append(thresholds, &guardrailThreshold{
+				description: fmt.Sprintf("Batch of %d statements", len(batch.Children)),
+				value:       len(batch.Children),
+				unit:        "statements",
+				warn:        g.config.BatchStatementsWarn,
+				warnName:    "ZDM_GUARDRAIL_BATCH_STATEMENTS_WARN",
+				fail:        g.config.BatchStatementsFail,
+				failName:    "ZDM_GUARDRAIL_BATCH_STATEMENTS_FAIL",
+				warnings:    g.proxyMetrics.GuardrailWarningsBatchStatements,
+				rejections:  g.proxyMetrics.GuardrailRejectionsBatchStatements,
+			})
+		}
+	case *GenericRequestInfo:
+		if rawFrame.Header.OpCode != primitive.OpCodeQuery {
+			return nil, nil
+		}
+		stmtQueryData, err := frameContext.GetOrInspectStatement(currentKeyspace, timeUuidGenerator)
+		if err != nil || !isWriteStatement(stmtQueryData.queryData) {
+			return nil, nil
+		}
+		if stmtQueryData.queryData.getStatementType() == statementTypeBatch {
+			thresholds = append(thresholds, g.batchSizeThreshold(size))
+		} else {
+			thresholds = append(thresholds, g.mutationSizeThreshold(size))
+		}
+	case *ExecuteRequestInfo:
+		queryInfo := inspectPreparedQuery(castedRequestInfo.GetPreparedData(), currentKeyspace, timeUuidGenerator)
+		if !isWriteStatement(queryInfo) {
+			return nil, nil
+		}
+		if queryInfo.getStatementType() == statementTypeBatch {
+			thresholds = append(thresholds, g.batchSizeThreshold(size))
+		} else {
+			thresholds = append(thresholds, g.mutationSizeThreshold(size))
+		}
+	default:
+		return nil, nil
+	}
+
+	for _, threshold := range thresholds {
+		if threshold.fail > 0 && threshold.value > threshold.fail {
+			threshold.rejections.Add(1)
+			err := &guardrailError{msg: fmt.Sprintf("%v exceeds the fail threshold of %d %v (%v)",
+				threshold.description, threshold.fail, threshold.unit, threshold.failName)}
+			log.Debugf("Rejecting request with opcode %v and stream id %d: %v",
+				rawFrame.Header.OpCode, rawFrame.Header.StreamId, err)
+			return nil, err
+		}
+	}
+	var warnings []string
+	for _, threshold := range thresholds {
+		if threshold.warn > 0 && threshold.value > threshold.warn {
+			threshold.warnings.Add(1)
+			warning := fmt.Sprintf("%v exceeds the warn threshold of %d %v (%v)",
+				threshold.description, threshold.warn, threshold.unit, threshold.warnName)
+			log.Debugf("Request with opcode %v and stream id %d: %v",
+				rawFrame.Header.OpCode, rawFrame.Header.StreamId, warning)
+			if g.config.ClientWarningsEnabled {
+				warnings = append(warnings, warning)
+			}
+		}
+	}
+	return warnings, nil
+}
+
+// checkLimits returns a *guardrailError if a statement of the request is longer than ZDM_GUARDRAIL_MAX_QUERY_LENGTH or
+// if its bound values are larger than ZDM_GUARDRAIL_MAX_VALUES_SIZE_BYTES.
+func (g *guardrails) checkLimits(frameContext *frameDecodeContext) error {
+	maxQueryLength := g.config.MaxQueryLength
+	maxValuesSize := g.config.MaxValuesSizeBytes
+	if maxQueryLength <= 0 && maxValuesSize <= 0 {
+		return nil
+	}
+	rawFrame := frameContext.GetRawFrame()
+	switch rawFrame.Header.OpCode {
+	case primitive.OpCodeQuery, primitive.OpCodePrepare, primitive.OpCodeExecute, primitive.OpCodeBatch:
+	default:
+		return nil
+	}
+	// the statements and the values are part of the body so the smaller requests don't need to be decoded
+	bodyLength := int(rawFrame.Header.BodyLength)
+	if (maxQueryLength <= 0 || bodyLength <= maxQueryLength) && (maxValuesSize <= 0 || bodyLength <= maxValuesSize) {
+		return nil
+	}
+
+	decodedFrame, err := frameContext.GetOrDecodeFrame()
+	if err != nil {
+		return err
+	}
+	var queries []string
+	valuesSize := 0
+	switch msg := decodedFrame.Body.Message.(type) {
+	case *message.Query:
+		queries = append(queries, msg.Query)
+		valuesSize = getQueryOptionsValuesSize(msg.Options)
+	case *message.Prepare:
+		queries = append(queries, msg.Query)
+	case *message.Execute:
+		valuesSize = getQueryOptionsValuesSize(msg.Options)
+	case *message.Batch:
+		for _, child := range msg.Children {
+			if query, ok := child.QueryOrId.(string); ok {
+				queries = append(queries, query)
+			}
+			valuesSize += getValuesSize(child.Values)
+		}
+	}
+
+	var guardrailErr *guardrailError
+	if maxQueryLength > 0 {
+		for _, query := range queries {
+			if len(query) > maxQueryLength {
+				g.proxyMetrics.GuardrailRejectionsQueryLength.Add(1)
+				guardrailErr = &guardrailError{msg: fmt.Sprintf(
+					"Statement of %d bytes exceeds the maximum query length of %d bytes (ZDM_GUARDRAIL_MAX_QUERY_LENGTH)",
+					len(query), maxQueryLength)}
+				break
+			}
+		}
+	}
+	if guardrailErr == nil && maxValuesSize > 0 && valuesSize > maxValuesSize {
+		g.proxyMetrics.GuardrailRejectionsValuesSize.Add(1)
+		guardrailErr = &guardrailError{msg: fmt.Sprintf(
+			"Bound values of %d bytes exceed the maximum size of %d bytes (ZDM_GUARDRAIL_MAX_VALUES_SIZE_BYTES)",
+			valuesSize, maxValuesSize)}
+	}
+	if guardrailErr != nil {
+		log.Debugf("Rejecting request with opcode %v and stream id %d: %v",
+			rawFrame.Header.OpCode, rawFrame.Header.StreamId, guardrailErr)
+		return guardrailErr
+	}
+	return nil
+}
+
+func getQueryOptionsValuesSize(options *message.QueryOptions) int {
+	if options == nil {
+		return 0
+	}
+	size := getValuesSize(options.PositionalValues)
+	for _, value := range options.NamedValues {
+		if value != nil {
+			size += len(value.Contents)
+		}
+	}
+	return size
+}
+
+func getValuesSize(values []*primitive.Value) int {
+	size := 0
+	for _, value := range values {
+		if value != nil {
+			size += len(value.Contents)
+		}
+	}
+	return size
+}
+
+func (g *guardrails) batchSizeThreshold(size int) *guardrailThreshold {
+	return &guardrailThreshold{
+		description: fmt.Sprintf("Batch of %d bytes", size),
+		value:       size,
+		unit:        "bytes",
+		warn:        g.config.BatchSizeWarnBytes,
+		warnName:    "ZDM_GUARDRAIL_BATCH_SIZE_WARN_BYTES",
+		fail:        g.config.BatchSizeFailBytes,
+		failName:    "ZDM_GUARDRAIL_BATCH_SIZE_FAIL_BYTES",
+		warnings:    g.proxyMetrics.GuardrailWarningsBatchSize,
+		rejections:  g.proxyMetrics.GuardrailRejectionsBatchSize,
+	}
+}
+
+func (g *guardrails) mutationSizeThreshold(size int) *guardrailThreshold {
+	return &guardrailThreshold{
+		description: fmt.Sprintf("Mutation of %d bytes", size),
+		value:       size,
+		unit:        "bytes",
+		warn:        g.config.MutationSizeWarnBytes,
+		warnName:    "ZDM_GUARDRAIL_MUTATION_SIZE_WARN_BYTES",
+		fail:        g.config.MutationSizeFailBytes,
+		failName:    "ZDM_GUARDRAIL_MUTATION_SIZE_FAIL_BYTES",
+		warnings:    g.proxyMetrics.GuardrailWarningsMutationSize,
+		rejections:  g.proxyMetrics.GuardrailRejectionsMutationSize,
+	}
+}
+
+func createGuardrailErrorResponse(header *frame.Header, err *guardrailError) (*frame.RawFrame, error) {
+	response := frame.NewFrame(header.Version, header.StreamId, &message.Invalid{ErrorMessage: err.Error()})
+	return defaultCodec.ConvertToRawFrame(response)
+}
@@ -0,0 +1,196 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestGuardrails_Check(t *testing.T) {
+	insert := "INSERT INTO tbl (pk) VALUES (1)"
+	insertSize := int(mockQueryFrame(t, insert).Header.BodyLength)
+	twoInserts := mockBatchWithChildren(t, []*message.BatchChild{{QueryOrId: insert}, {QueryOrId: insert}})
+	twoInsertsSize := int(twoInserts.Header.BodyLength)
+
+	type test struct {
+		name             string
+		config           *common.GuardrailsConfig
+		requestInfo      RequestInfo
+		frameContext     *frameDecodeContext
+		expectedWarnings []string
+		expectedErr      string
+	}
+	tests := []test{
+		{
+			name:         "mutation under the thresholds",
+			config:       &common.GuardrailsConfig{MutationSizeWarnBytes: insertSize, ClientWarningsEnabled: true},
+			requestInfo:  NewGenericRequestInfo(forwardToBoth, false, true),
+			frameContext: NewFrameDecodeContext(mockQueryFrame(t, insert)),
+		},
+		{
+			name:         "mutation over the warn threshold",
+			config:       &common.GuardrailsConfig{MutationSizeWarnBytes: insertSize - 1, ClientWarningsEnabled: true},
+			requestInfo:  NewGenericRequestInfo(forwardToBoth, false, true),
+			frameContext: NewFrameDecodeContext(mockQueryFrame(t, insert)),
+			expectedWarnings: []string{
+				"Mutation of 38 bytes exceeds the warn threshold of 37 bytes (ZDM_GUARDRAIL_MUTATION_SIZE_WARN_BYTES)"},
+		},
+		{
+			name:         "mutation over the warn threshold without client warnings",
+			config:       &common.GuardrailsConfig{MutationSizeWarnBytes: insertSize - 1},
+			requestInfo:  NewGenericRequestInfo(forwardToBoth, false, true),
+			frameContext: NewFrameDecodeContext(mockQueryFrame(t, insert)),
+		},
+		{
+			name:         "mutation over the fail threshold",
+			config:       &common.GuardrailsConfig{MutationSizeWarnBytes: 10, MutationSizeFailBytes: 20},
+			requestInfo:  NewGenericRequestInfo(forwardToBoth, false, true),
+			frameContext: NewFrameDecodeContext(mockQueryFrame(t, insert)),
+			expectedErr:  "Mutation of 38 bytes exceeds the fail threshold of 20 bytes (ZDM_GUARDRAIL_MUTATION_SIZE_FAIL_BYTES)",
+		},
+		{
+			name:   "prepared mutation over the fail threshold",
+			config: &common.GuardrailsConfig{MutationSizeFailBytes: 1},
+			requestInfo: NewExecuteRequestInfo(NewPreparedData(
+				&message.PreparedResult{PreparedQueryId: []byte("origin")},
+				&message.PreparedResult{PreparedQueryId: []byte("target")},
+				NewPrepareRequestInfo(NewGenericRequestInfo(forwardToBoth, false, true), nil, false,
+					"UPDATE tbl SET v = ? WHERE pk = ?", ""))),
+			frameContext: NewFrameDecodeContext(mockExecuteFrame(t, "origin")),
+			expectedErr:  "exceeds the fail threshold of 1 bytes (ZDM_GUARDRAIL_MUTATION_SIZE_FAIL_BYTES)",
+		},
+		{
+			name:         "reads are not checked",
+			config:       &common.GuardrailsConfig{MutationSizeFailBytes: 1, BatchSizeFailBytes: 1},
+			requestInfo:  NewGenericRequestInfo(forwardToOrigin, false, true),
+			frameContext: NewFrameDecodeContext(mockQueryFrame(t, "SELECT * FROM tbl")),
+		},
+		{
+			name:         "batch statements are checked against the batch size",
+			config:       &common.GuardrailsConfig{MutationSizeFailBytes: 1, BatchSizeWarnBytes: 1, ClientWarningsEnabled: true},
+			requestInfo:  NewGenericRequestInfo(forwardToBoth, false, true),
+			frameContext: NewFrameDecodeContext(mockQueryFrame(t, "BEGIN BATCH "+insert+" APPLY BATCH")),
+			expectedWarnings: []string{
+				"Batch of 62 bytes exceeds the warn threshold of 1 bytes (ZDM_GUARDRAIL_BATCH_SIZE_WARN_BYTES)"},
+		},
+		{
+			name: "batch over the warn thresholds",
+			config: &common.GuardrailsConfig{
+				BatchSizeWarnBytes: twoInsertsSize - 1, BatchStatementsWarn: 1, BatchStatementsFail: 2,
+				MutationSizeFailBytes: 1, ClientWarningsEnabled: true},
+			requestInfo:  NewBatchRequestInfo(map[int]PreparedData{}),
+			frameContext: NewFrameDecodeContext(twoInserts),
+			expectedWarnings: []string{
+				"Batch of 82 bytes exceeds the warn threshold of 81 bytes (ZDM_GUARDRAIL_BATCH_SIZE_WARN_BYTES)",
+				"Batch of 2 statements exceeds the warn threshold of 1 statements (ZDM_GUARDRAIL_BATCH_STATEMENTS_WARN)"},
+		},
+		{
+			name:         "batch over the statements fail threshold",
+			config:       &common.GuardrailsConfig{BatchSizeWarnBytes: 1, BatchStatementsFail: 1, ClientWarningsEnabled: true},
+			requestInfo:  NewBatchRequestInfo(map[int]PreparedData{}),
+			frameContext: NewFrameDecodeContext(twoInserts),
+			expectedErr:  "Batch of 2 statements exceeds the fail threshold of 1 statements (ZDM_GUARDRAIL_BATCH_STATEMENTS_FAIL)",
+		},
+		{
+			name:         "query under the maximum length",
+			config:       &common.GuardrailsConfig{MaxQueryLength: len(insert)},
+			requestInfo:  NewGenericRequestInfo(forwardToBoth, false, true),
+			frameContext: NewFrameDecodeContext(mockQueryFrame(t, insert)),
+		},
+		{
+			name:         "query over the maximum length",
+			config:       &common.GuardrailsConfig{MaxQueryLength: len(insert) - 1},
+			requestInfo:  NewGenericRequestInfo(forwardToBoth, false, true),
+			frameContext: NewFrameDecodeContext(mockQueryFrame(t, insert)),
+			expectedErr:  "Statement of 31 bytes exceeds the maximum query length of 30 bytes (ZDM_GUARDRAIL_MAX_QUERY_LENGTH)",
+		},
+		{
+			name:         "read over the maximum length",
+			config:       &common.GuardrailsConfig{MaxQueryLength: 10},
+			requestInfo:  NewGenericRequestInfo(forwardToOrigin, false, true),
+			frameContext: NewFrameDecodeContext(mockQueryFrame(t, "SELECT * FROM tbl")),
+			expectedErr:  "Statement of 17 bytes exceeds the maximum query length of 10 bytes",
+		},
+		{
+			name:   "prepare over the maximum length",
+			config: &common.GuardrailsConfig{MaxQueryLength: 10},
+			requestInfo: NewPrepareRequestInfo(
+				NewGenericRequestInfo(forwardToBoth, false, false), nil, false, "SELECT * FROM tbl", ""),
+			frameContext: NewFrameDecodeContext(mockPrepareFrame(t, "SELECT * FROM tbl")),
+			expectedErr:  "Statement of 17 bytes exceeds the maximum query length of 10 bytes",
+		},
+		{
+			name:         "batch child over the maximum length",
+			config:       &common.GuardrailsConfig{MaxQueryLength: len(insert) - 1},
+			requestInfo:  NewBatchRequestInfo(map[int]PreparedData{}),
+			frameContext: NewFrameDecodeContext(twoInserts),
+			expectedErr:  "Statement of 31 bytes exceeds the maximum query length of 30 bytes",
+		},
+		{
+			name:        "values over the maximum size",
+			config:      &common.GuardrailsConfig{MaxValuesSizeBytes: 7},
+			requestInfo: NewGenericRequestInfo(forwardToBoth, false, true),
+			frameContext: NewFrameDecodeContext(mockFrame(t, &message.Query{
+				Query: "INSERT INTO tbl (pk, v) VALUES (?, ?)",
+				Options: &message.QueryOptions{PositionalValues: []*primitive.Value{
+					primitive.NewValue([]byte{0, 0, 0, 1}), primitive.NewValue([]byte("abcd"))}},
+			}, primitive.ProtocolVersion4)),
+			expectedErr: "Bound values of 8 bytes exceed the maximum size of 7 bytes (ZDM_GUARDRAIL_MAX_VALUES_SIZE_BYTES)",
+		},
+		{
+			name:        "batch values over the maximum size",
+			config:      &common.GuardrailsConfig{MaxValuesSizeBytes: 5},
+			requestInfo: NewBatchRequestInfo(map[int]PreparedData{}),
+			frameContext: NewFrameDecodeContext(mockBatchWithChildren(t, []*message.BatchChild{
+				{QueryOrId: "INSERT INTO tbl (pk) VALUES (?)", Values: []*primitive.Value{primitive.NewValue([]byte("abc"))}},
+				{QueryOrId: "INSERT INTO tbl (pk) VALUES (?)", Values: []*primitive.Value{primitive.NewValue([]byte("abc"))}},
+			})),
+			expectedErr: "Bound values of 6 bytes exceed the maximum size of 5 bytes",
+		},
+		{
+			name:        "values under the maximum size",
+			config:      &common.GuardrailsConfig{MaxValuesSizeBytes: 8},
+			requestInfo: NewGenericRequestInfo(forwardToBoth, false, true),
+			frameContext: NewFrameDecodeContext(mockFrame(t, &message.Query{
+				Query: "INSERT INTO tbl (pk, v) VALUES (?, ?)",
+				Options: &message.QueryOptions{PositionalValues: []*primitive.Value{
+					primitive.NewValue([]byte{0, 0, 0, 1}), primitive.NewValue([]byte("abcd"))}},
+			}, primitive.ProtocolVersion4)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newGuardrails(tt.config, newFakeProxyMetrics())
+			warnings, err := g.check(tt.requestInfo, tt.frameContext, "ks", &fakeTimeUuidGenerator{})
+			if tt.expectedErr != "" {
+				require.NotNil(t, err)
+				require.IsType(t, &guardrailError{}, err)
+				require.Contains(t, err.Error(), tt.expectedErr)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedWarnings, warnings)
+		})
+	}
+
+	var disabled *guardrails
+	warnings, err := disabled.check(
+		NewGenericRequestInfo(forwardToBoth, false, true), NewFrameDecodeContext(mockQueryFrame(t, insert)), "ks", nil)
+	require.Nil(t, err)
+	require.Nil(t, warnings)
+}
+
+func TestCreateGuardrailErrorResponse(t *testing.T) {
+	request := mockQueryFrame(t, "INSERT INTO tbl (pk) VALUES (1)")
+	request.Header.StreamId = 12
+	rawResponse, err := createGuardrailErrorResponse(request.Header, &guardrailError{msg: "Batch too large"})
+	require.Nil(t, err)
+	response, err := defaultCodec.ConvertFromRawFrame(rawResponse)
+	require.Nil(t, err)
+	require.Equal(t, int16(12), response.Header.StreamId)
+	require.Equal(t, primitive.ProtocolVersion4, response.Header.Version)
+	require.Equal(t, &message.Invalid{ErrorMessage: "Batch too large"}, response.Body.Message)
+}
@@ -0,0 +1,203 @@
+package zdmproxy
+
+import (
+	"container/heap"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"sort"
+	"sync"
+	"time"
+)
+
+// the sketches have more counters than the number of reported statements so that the reported counts are accurate
+const heavyHitterCountersPerStatement = 4
+
+// HeavyHitter is a statement that dominates the load of a cluster, the statement is normalized (see
+// normalizeCqlStatement) and the fingerprint is the one of the statement metrics.
+type HeavyHitter struct {
+	Fingerprint string `json:"fingerprint"`
+	Statement   string `json:"statement"`
+	// Count and TotalLatencyMs are accounted since the statement entered the sketch.
+	Count          uint64  `json:"count"`
+	TotalLatencyMs float64 `json:"total_latency_ms"`
+	// Overestimate is the weight (count or total latency in milliseconds) inherited from the statement that was evicted
+	// when this one entered the full sketch, the statement is ranked by the sum of the two.
+	Overestimate float64 `json:"overestimate"`
+}
+
+// HeavyHitters are the statements with the highest count and the ones with the highest total latency of a cluster.
+type HeavyHitters struct {
+	ByCount   []*HeavyHitter `json:"by_count"`
+	ByLatency []*HeavyHitter `json:"by_latency"`
+}
+
+// heavyHitterTracker keeps the top ZDM_HEAVY_HITTERS_SIZE statements by count and by total latency of the responses of
+// each cluster so that the statements that dominate the load can be found without audit logging. The statements are
+// tracked with the Space-Saving algorithm, the memory is bounded whatever the number of distinct statements.
+//
+// The QUERY, EXECUTE and BATCH requests are tracked, the latency is measured from the moment the proxy received the
+// request until the cluster responded.
+type heavyHitterTracker struct {
+	size    int
+	lock    *sync.Mutex
+	started time.Time
+	byCount map[common.ClusterType]*spaceSavingSketch
+	byTime  map[common.ClusterType]*spaceSavingSketch
+}
+
+func newHeavyHitterTracker(size int) *heavyHitterTracker {
+	t := &heavyHitterTracker{size: size, lock: &sync.Mutex{}}
+	t.resetLocked()
+	return t
+}
+
+func (t *heavyHitterTracker) resetLocked() {
+	t.started = time.Now()
+	t.byCount = map[common.ClusterType]*spaceSavingSketch{}
+	t.byTime = map[common.ClusterType]*spaceSavingSketch{}
+	for _, cluster := range []common.ClusterType{common.ClusterTypeOrigin, common.ClusterTypeTarget} {
+		t.byCount[cluster] = newSpaceSavingSketch(t.size * heavyHitterCountersPerStatement)
+		t.byTime[cluster] = newSpaceSavingSketch(t.size * heavyHitterCountersPerStatement)
+	}
+}
+
+// record accounts a response of a cluster to a statement, the statement is identified by the fingerprint that the
+// caller computed and the truncated text of the report is only built when the statement starts being tracked.
+func (t *heavyHitterTracker) record(
+	connectorType ClusterConnectorType, statement *statementFingerprint, latency time.Duration) {
+	if t == nil || statement == nil {
+		return
+	}
+	var cluster common.ClusterType
+	switch connectorType {
+	case ClusterConnectorTypeOrigin:
+		cluster = common.ClusterTypeOrigin
+	case ClusterConnectorTypeTarget:
+		cluster = common.ClusterTypeTarget
+	default:
+		return
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.byCount[cluster].add(statement, 1, latency)
+	t.byTime[cluster].add(statement, float64(latency)/float64(time.Millisecond), latency)
+}
+
+// get returns the heavy hitters of each cluster and the time since which they are tracked.
+func (t *heavyHitterTracker) get() (map[common.ClusterType]*HeavyHitters, time.Time) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	heavyHitters := make(map[common.ClusterType]*HeavyHitters, len(t.byCount))
+	for cluster := range t.byCount {
+		heavyHitters[cluster] = &HeavyHitters{
+			ByCount:   t.byCount[cluster].top(t.size),
+			ByLatency: t.byTime[cluster].top(t.size),
+		}
+	}
+	return heavyHitters, t.started
+}
+
+func (t *heavyHitterTracker) reset() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.resetLocked()
+}
+
+// spaceSavingSketch keeps the approximate top statements by weight with a fixed number of counters: when a statement
+// that is not tracked is added and the sketch is full, it replaces the statement with the lowest weight and inherits
+// its weight as overestimate.
+type spaceSavingSketch struct {
+	capacity int
+	entries  map[string]*spaceSavingEntry
+	heap     spaceSavingHeap
+}
+
+type spaceSavingEntry struct {
+	fingerprint  string
+	statement    string
+	weight       float64
+	overestimate float64
+	count        uint64
+	latency      time.Duration
+	index        int
+}
+
+func newSpaceSavingSketch(capacity int) *spaceSavingSketch {
+	return &spaceSavingSketch{
+		capacity: capacity,
+		entries:  make(map[string]*spaceSavingEntry, capacity),
+		heap:     make(spaceSavingHeap, 0, capacity),
+	}
+}
+
+func (s *spaceSavingSketch) add(statement *statementFingerprint, weight float64, latency time.Duration) {
+	entry, ok := s.entries[statement.fingerprint]
+	if !ok {
+		if len(s.heap) < s.capacity {
+			entry = &spaceSavingEntry{}
+			heap.Push(&s.heap, entry)
+		} else {
+			entry = s.heap[0]
+			delete(s.entries, entry.fingerprint)
+			*entry = spaceSavingEntry{overestimate: entry.weight, weight: entry.weight, index: entry.index}
+		}
+		entry.fingerprint = statement.fingerprint
+		entry.statement = statement.normalized()
+		s.entries[entry.fingerprint] = entry
+	}
+	entry.weight += weight
+	entry.count++
+	entry.latency += latency
+	heap.Fix(&s.heap, entry.index)
+}
+
+// top returns the n entries with the highest weight, sorted by decreasing weight.
+func (s *spaceSavingSketch) top(n int) []*HeavyHitter {
+	entries := make([]*spaceSavingEntry, len(s.heap))
+	copy(entries, s.heap)
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].weight != entries[j].weight {
+			return entries[i].weight > entries[j].weight
+		}
+		return entries[i].fingerprint < entries[j].fingerprint
+	})
+	if len(entries) > n {
+		entries = entries[:n]
+	}
+	heavyHitters := make([]*HeavyHitter, 0, len(entries))
+	for _, entry := range entries {
+		heavyHitters = append(heavyHitters, &HeavyHitter{
+			Fingerprint:    entry.fingerprint,
+			Statement:      entry.statement,
+			Count:          entry.count,
+			TotalLatencyMs: float64(entry.latency) / float64(time.Millisecond),
+			Overestimate:   entry.overestimate,
+		})
+	}
+	return heavyHitters
+}
+
+// spaceSavingHeap is a min-heap of the entries by weight.
+type spaceSavingHeap []*spaceSavingEntry
+
+func (h spaceSavingHeap) Len() int { return len(h) }
+
+func (h spaceSavingHeap) Less(i, j int) bool { return h[i].weight < h[j].weight }
+
+func (h spaceSavingHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *spaceSavingHeap) Push(x interface{}) {
+	entry := x.(*spaceSavingEntry)
+	entry.index = len(*h)
+	*h = append(*h, entry)
+}
+
+func (h *spaceSavingHeap) Pop() interface{} {
+	old := *h
+	entry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entry
+}
@@ -0,0 +1,102 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestHeavyHitters_Tracker(t *testing.T) {
+	tracker := newHeavyHitterTracker(2)
+	for i := 0; i < 10; i++ {
+		// the literals, whitespace and letter case are not part of the fingerprint
+		statement := newStatementFingerprint(fmt.Sprintf("SELECT * FROM ks.tbl  WHERE id = %d", i))
+		tracker.record(ClusterConnectorTypeOrigin, statement, time.Millisecond)
+		tracker.record(ClusterConnectorTypeTarget, statement, 2*time.Millisecond)
+	}
+	slow := newStatementFingerprint("insert into ks.tbl (id, name) values (1, 'a')")
+	tracker.record(ClusterConnectorTypeOrigin, slow, 100*time.Millisecond)
+	tracker.record(ClusterConnectorTypeOrigin, newStatementFingerprint("SELECT * FROM ks.other"), time.Millisecond)
+	tracker.record(ClusterConnectorTypeAsync, slow, time.Second)
+
+	heavyHitters, _ := tracker.get()
+	origin := heavyHitters[common.ClusterTypeOrigin]
+	require.Len(t, origin.ByCount, 2)
+	require.Equal(t, "select * from ks.tbl where id = ?", origin.ByCount[0].Statement)
+	require.Equal(t, uint64(10), origin.ByCount[0].Count)
+	require.Equal(t, 10.0, origin.ByCount[0].TotalLatencyMs)
+	require.Equal(t, 0.0, origin.ByCount[0].Overestimate)
+	require.Len(t, origin.ByLatency, 2)
+	require.Equal(t, "insert into ks.tbl (id, name) values (?, ?)", origin.ByLatency[0].Statement)
+	require.Equal(t, 100.0, origin.ByLatency[0].TotalLatencyMs)
+	require.Equal(t, origin.ByCount[0].Fingerprint, origin.ByLatency[1].Fingerprint)
+
+	target := heavyHitters[common.ClusterTypeTarget]
+	require.Len(t, target.ByCount, 1)
+	require.Equal(t, 20.0, target.ByCount[0].TotalLatencyMs)
+
+	tracker.reset()
+	heavyHitters, _ = tracker.get()
+	require.Empty(t, heavyHitters[common.ClusterTypeOrigin].ByCount)
+
+	var disabled *heavyHitterTracker
+	disabled.record(ClusterConnectorTypeOrigin, slow, time.Millisecond)
+}
+
+func TestHeavyHitters_SpaceSavingEviction(t *testing.T) {
+	sketch := newSpaceSavingSketch(2)
+	add := func(query string, n int) {
+		for i := 0; i < n; i++ {
+			sketch.add(newStatementFingerprint(query), 1, 0)
+		}
+	}
+	add("SELECT a FROM ks.tbl", 5)
+	add("SELECT b FROM ks.tbl", 2)
+	// replaces the statement with the lowest count and inherits it as overestimate
+	add("SELECT c FROM ks.tbl", 4)
+
+	top := sketch.top(3)
+	require.Len(t, top, 2)
+	require.Equal(t, "select c from ks.tbl", top[0].Statement)
+	require.Equal(t, uint64(4), top[0].Count)
+	require.Equal(t, 2.0, top[0].Overestimate)
+	require.Equal(t, "select a from ks.tbl", top[1].Statement)
+	require.Equal(t, uint64(5), top[1].Count)
+	require.Len(t, sketch.top(1), 1)
+}
+
+func TestHeavyHitters_RequestStatement(t *testing.T) {
+	preparedData := &preparedDataImpl{
+		prepareRequestInfo: NewPrepareRequestInfo(
+			NewGenericRequestInfo(forwardToBoth, false, true), nil, false, "UPDATE ks.tbl SET a = ? WHERE id = ?", ""),
+	}
+
+	statement, err := getRequestStatement(
+		NewFrameDecodeContext(mockQueryFrame(t, "SELECT * FROM ks.tbl WHERE id = 1")),
+		NewGenericRequestInfo(forwardToOrigin, false, true))
+	require.Nil(t, err)
+	require.Equal(t, newStatementFingerprint("select * from ks.tbl where id = 2").fingerprint, statement.fingerprint)
+
+	statement, err = getRequestStatement(
+		NewFrameDecodeContext(mockExecuteFrame(t, "BOTH")), NewExecuteRequestInfo(preparedData))
+	require.Nil(t, err)
+	require.Equal(t, "UPDATE ks.tbl SET a = ? WHERE id = ?", statement.query)
+
+	statement, err = getRequestStatement(
+		NewFrameDecodeContext(mockBatchWithChildren(t, []*message.BatchChild{
+			{QueryOrId: "INSERT INTO ks.tbl (id) VALUES (1)"}, {QueryOrId: []byte("BOTH")}})),
+		NewBatchRequestInfo(map[int]PreparedData{1: preparedData}))
+	require.Nil(t, err)
+	require.Equal(t, "batch logged [insert into ks.tbl (id) values (?); update ks.tbl set a = ? where id = ?]",
+		statement.normalized())
+
+	statement, err = getRequestStatement(
+		NewFrameDecodeContext(mockFrame(t, &message.Options{}, primitive.ProtocolVersion4)),
+		NewGenericRequestInfo(forwardToBoth, false, false))
+	require.Nil(t, err)
+	require.Nil(t, statement)
+}
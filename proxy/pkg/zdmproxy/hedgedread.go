@@ -0,0 +1,105 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	log "github.com/sirupsen/logrus"
+)
+
+// When the read mode is HEDGED, reads are sent to both clusters and the client gets the first successful response.
+//
+// The client is free to reuse its stream id as soon as it receives that response but the slowest cluster hasn't
+// replied yet so hedged reads are tracked with a separate, negative, stream id until both clusters respond.
+// If all hedged read stream ids are in use then reads are sent to the primary cluster only.
+
+func toHedgedReadStreamId(id int16) int16 {
+	return -1 - id
+}
+
+func fromHedgedReadStreamId(hedgedStreamId int16) int16 {
+	return -1 - hedgedStreamId
+}
+
+func isHedgedReadStreamId(streamId int16) bool {
+	return streamId < 0
+}
+
+// shouldHedgeRead returns true for reads that can be served by either cluster, i.e., the same reads that would be
+// sent to the async connector with DUAL_ASYNC_ON_SECONDARY. System queries and reads that have a routing hint are
+// not hedged.
+func (ch *ClientHandler) shouldHedgeRead(requestInfo RequestInfo) bool {
+	if !ch.hedgedReads || !requestInfo.ShouldAlsoBeSentAsync() || !requestInfo.ShouldBeTrackedInMetrics() {
+		return false
+	}
+
+	switch requestInfo.GetForwardDecision() {
+	case forwardToOrigin, forwardToTarget:
+		return true
+	default:
+		return false
+	}
+}
+
+func (ch *ClientHandler) acquireHedgedReadStreamId() (int16, bool) {
+	id, err := ch.hedgedReadStreamIds.GetNewIdFor(0)
+	if err != nil {
+		log.Tracef("Could not hedge read, falling back to primary cluster only: %v", err)
+		return 0, false
+	}
+	return toHedgedReadStreamId(id), true
+}
+
+// releaseHedgedRead frees the hedged stream id once it is no longer needed. The request context holder is not cleared
+// when a hedged read finishes because the response from the slowest cluster is still pending at that point.
+func (ch *ClientHandler) releaseHedgedRead(holder *requestContextHolder, reqCtx *requestContextImpl, force bool) {
+	if !reqCtx.TryReleaseHedgedRead(force) {
+		return
+	}
+
+	defer ch.clientHandlerRequestWaitGroup.Done()
+	err := holder.Clear(reqCtx)
+	if err != nil {
+		log.Debugf("Could not clear hedged read request context: %v", err)
+	}
+	_, err = ch.hedgedReadStreamIds.ReleaseId(fromHedgedReadStreamId(reqCtx.hedgedStreamId))
+	if err != nil {
+		log.Debugf("Could not free hedged read stream id: %v", err)
+	}
+}
+
+// computeHedgedClientResponse returns the first successful response or the primary cluster's response if
+// both clusters returned an error.
+func (ch *ClientHandler) computeHedgedClientResponse(
+	requestContext *requestContextImpl) (*frame.RawFrame, common.ClusterType, error) {
+	originResponse, targetResponse := requestContext.originResponse, requestContext.targetResponse
+	if originResponse == nil && targetResponse == nil {
+		return nil, common.ClusterTypeNone, fmt.Errorf(
+			"did not receive response from any cluster for hedged read, stream: %d", requestContext.clientStreamId)
+	}
+
+	var response *frame.RawFrame
+	var responseClusterType common.ClusterType
+	switch {
+	case originResponse != nil && isResponseSuccessful(originResponse):
+		response, responseClusterType = originResponse, common.ClusterTypeOrigin
+	case targetResponse != nil && isResponseSuccessful(targetResponse):
+		response, responseClusterType = targetResponse, common.ClusterTypeTarget
+	case targetResponse != nil && (originResponse == nil || requestContext.requestInfo.GetForwardDecision() == forwardToTarget):
+		response, responseClusterType = targetResponse, common.ClusterTypeTarget
+	default:
+		response, responseClusterType = originResponse, common.ClusterTypeOrigin
+	}
+
+	log.Tracef("Hedged read: returning the response received from %v: %d", responseClusterType, response.Header.OpCode)
+
+	if !isResponseSuccessful(response) {
+		switch requestContext.requestInfo.GetForwardDecision() {
+		case forwardToOrigin:
+			ch.metricHandler.GetProxyMetrics().FailedReadsOrigin.Add(1)
+		case forwardToTarget:
+			ch.metricHandler.GetProxyMetrics().FailedReadsTarget.Add(1)
+		}
+	}
+	return response, responseClusterType, nil
+}
@@ -0,0 +1,94 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestHedgedReadStreamId(t *testing.T) {
+	for _, id := range []int16{0, 1, 2047, 32767} {
+		hedgedStreamId := toHedgedReadStreamId(id)
+		require.True(t, isHedgedReadStreamId(hedgedStreamId))
+		require.Equal(t, id, fromHedgedReadStreamId(hedgedStreamId))
+	}
+	require.False(t, isHedgedReadStreamId(0))
+}
+
+func TestRequestContext_Hedged(t *testing.T) {
+	successResponse := newHedgedReadTestResponse(t, &message.VoidResult{})
+	errorResponse := newHedgedReadTestResponse(t, &message.Invalid{ErrorMessage: "test"})
+
+	tests := []struct {
+		name           string
+		first          *frame.RawFrame
+		firstCluster   common.ClusterType
+		second         *frame.RawFrame
+		secondCluster  common.ClusterType
+		doneAfterFirst bool
+		expectedOrigin bool
+		expectedTarget bool
+	}{
+		{"success from secondary", successResponse, common.ClusterTypeTarget,
+			successResponse, common.ClusterTypeOrigin, true, false, true},
+		{"success from primary", successResponse, common.ClusterTypeOrigin,
+			errorResponse, common.ClusterTypeTarget, true, true, false},
+		{"error then success", errorResponse, common.ClusterTypeOrigin,
+			successResponse, common.ClusterTypeTarget, false, true, true},
+		{"both errors", errorResponse, common.ClusterTypeTarget,
+			errorResponse, common.ClusterTypeOrigin, false, true, true},
+	}
+
+	nodeMetrics := &metrics.NodeMetrics{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := &frame.RawFrame{Header: &frame.Header{
+				Version: primitive.ProtocolVersion4, StreamId: toHedgedReadStreamId(5), OpCode: primitive.OpCodeQuery}}
+			reqCtx := NewRequestContext(
+				request, NewGenericRequestInfo(forwardToOrigin, true, false), time.Now(), nil)
+			reqCtx.SetHedged(10, toHedgedReadStreamId(5))
+
+			finished := reqCtx.SetResponse(nodeMetrics, tt.first, tt.firstCluster, ClusterConnectorTypeNone)
+			require.Equal(t, tt.doneAfterFirst, finished)
+			require.False(t, reqCtx.TryReleaseHedgedRead(false))
+
+			secondFinished := reqCtx.SetResponse(nodeMetrics, tt.second, tt.secondCluster, ClusterConnectorTypeNone)
+			require.Equal(t, !tt.doneAfterFirst, secondFinished)
+			require.Equal(t, tt.expectedOrigin, reqCtx.originResponse != nil)
+			require.Equal(t, tt.expectedTarget, reqCtx.targetResponse != nil)
+			for _, response := range []*frame.RawFrame{reqCtx.originResponse, reqCtx.targetResponse} {
+				if response != nil {
+					require.Equal(t, int16(10), response.Header.StreamId)
+				}
+			}
+
+			require.True(t, reqCtx.TryReleaseHedgedRead(false))
+			require.False(t, reqCtx.TryReleaseHedgedRead(true))
+		})
+	}
+}
+
+func TestRequestContext_HedgedReleaseBeforeSecondResponse(t *testing.T) {
+	nodeMetrics := &metrics.NodeMetrics{}
+	request := &frame.RawFrame{Header: &frame.Header{
+		Version: primitive.ProtocolVersion4, StreamId: toHedgedReadStreamId(0), OpCode: primitive.OpCodeQuery}}
+	reqCtx := NewRequestContext(request, NewGenericRequestInfo(forwardToTarget, true, false), time.Now(), nil)
+	reqCtx.SetHedged(1, toHedgedReadStreamId(0))
+	require.False(t, reqCtx.TryReleaseHedgedRead(true)) // pending
+
+	require.True(t, reqCtx.SetResponse(
+		nodeMetrics, newHedgedReadTestResponse(t, &message.VoidResult{}), common.ClusterTypeOrigin, ClusterConnectorTypeNone))
+	require.False(t, reqCtx.TryReleaseHedgedRead(false))
+	require.True(t, reqCtx.TryReleaseHedgedRead(true))
+}
+
+func newHedgedReadTestResponse(t *testing.T, msg message.Message) *frame.RawFrame {
+	rawFrame, err := defaultCodec.ConvertToRawFrame(frame.NewFrame(primitive.ProtocolVersion4, toHedgedReadStreamId(5), msg))
+	require.Nil(t, err)
+	return rawFrame
+}
@@ -0,0 +1,117 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	log "github.com/sirupsen/logrus"
+	"net"
+)
+
+// RequestInterceptor lets users implement custom validation, rewriting or routing logic without changing the proxy.
+// Interceptors are registered with Extensions and are called, in the order they were registered, for every request
+// that a client sends after the handshake (STARTUP, AUTH_RESPONSE, etc. are not intercepted).
+//
+// Implementations must be safe for concurrent use because they are shared by all client connections.
+type RequestInterceptor interface {
+	// OnRequest is called before the proxy parses and forwards a request.
+	//
+	// It returns the request that should be forwarded, which can be the provided frame or a new one. If it returns a
+	// response then the request is not forwarded and that response is sent to the client instead. If it returns an
+	// error then the client receives a SERVER_ERROR with the error message.
+	OnRequest(conn *InterceptedConnection, request *frame.RawFrame) (newRequest *frame.RawFrame, response *frame.RawFrame, err error)
+
+	// OnResponse is called with the response that is about to be sent to the client and returns the response that
+	// should be sent instead, which can be the provided frame. If it returns an error then the client receives a
+	// SERVER_ERROR with the error message.
+	//
+	// OnResponse is not called for responses that were returned by OnRequest.
+	OnResponse(conn *InterceptedConnection, request *frame.RawFrame, response *frame.RawFrame) (*frame.RawFrame, error)
+}
+
+// InterceptedConnection describes the client connection of an intercepted request.
+type InterceptedConnection struct {
+	ClientAddress net.Addr
+	OriginAddress string
+	TargetAddress string
+
+	ch *ClientHandler
+}
+
+// GetCurrentKeyspace returns the keyspace set by the last USE statement of this connection.
+func (recv *InterceptedConnection) GetCurrentKeyspace() string {
+	return recv.ch.LoadCurrentKeyspace()
+}
+
+func newInterceptedConnection(ch *ClientHandler) *InterceptedConnection {
+	return &InterceptedConnection{
+		ClientAddress: ch.clientConnector.connection.RemoteAddr(),
+		OriginAddress: ch.originCassandraConnector.connection.RemoteAddr().String(),
+		TargetAddress: ch.targetCassandraConnector.connection.RemoteAddr().String(),
+		ch:            ch,
+	}
+}
+
+// interceptRequest returns the request that should be forwarded or, if the request shouldn't be forwarded, the
+// response that should be sent to the client.
+func (ch *ClientHandler) interceptRequest(request *frame.RawFrame) (*frame.RawFrame, *frame.RawFrame) {
+	for _, interceptor := range ch.requestInterceptors {
+		newRequest, response, err := interceptor.OnRequest(ch.interceptedConnection, request)
+		if err != nil {
+			log.Debugf("Request interceptor rejected request with opcode %v and stream id %d: %v",
+				request.Header.OpCode, request.Header.StreamId, err)
+			return nil, createInterceptorErrorResponse(request.Header, err)
+		}
+		if response != nil {
+			response.Header.StreamId = request.Header.StreamId
+			response.Header.Version = request.Header.Version
+			return nil, response
+		}
+		if newRequest != nil {
+			newRequest.Header.StreamId = request.Header.StreamId
+			request = newRequest
+		}
+	}
+	return request, nil
+}
+
+// sendInterceptedResponseToClient sends the response to the client after the interceptors process it.
+func (ch *ClientHandler) sendInterceptedResponseToClient(request *frame.RawFrame, response *frame.RawFrame) {
+	if len(ch.requestInterceptors) > 0 {
+		response = ch.interceptResponse(request, response)
+		if response == nil {
+			return
+		}
+	}
+	ch.clientConnector.sendResponseToClient(response)
+}
+
+// interceptResponse calls the interceptors in the reverse order of interceptRequest.
+func (ch *ClientHandler) interceptResponse(request *frame.RawFrame, response *frame.RawFrame) *frame.RawFrame {
+	for i := len(ch.requestInterceptors) - 1; i >= 0; i-- {
+		newResponse, err := ch.requestInterceptors[i].OnResponse(ch.interceptedConnection, request, response)
+		if err != nil {
+			log.Debugf("Request interceptor rejected response with opcode %v and stream id %d: %v",
+				response.Header.OpCode, response.Header.StreamId, err)
+			return createInterceptorErrorResponse(response.Header, err)
+		}
+		if newResponse != nil {
+			newResponse.Header.StreamId = response.Header.StreamId
+			newResponse.Header.Version = response.Header.Version
+			response = newResponse
+		}
+	}
+	return response
+}
+
+func createInterceptorErrorResponse(header *frame.Header, err error) *frame.RawFrame {
+	response := frame.NewFrame(header.Version, header.StreamId, &message.ServerError{
+		ErrorMessage: fmt.Sprintf("Request rejected by the proxy: %v", err),
+	})
+	rawResponse, convertErr := defaultCodec.ConvertToRawFrame(response)
+	if convertErr != nil {
+		log.Errorf("Could not convert interceptor error response (%v) to raw frame: %v", response, convertErr)
+		return nil
+	}
+	return rawResponse
+}
@@ -0,0 +1,99 @@
+package zdmproxy
+
+import (
+	"errors"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+type fakeInterceptor struct {
+	name      string
+	onRequest func(request *frame.RawFrame) (*frame.RawFrame, *frame.RawFrame, error)
+	calls     *[]string
+}
+
+func (recv *fakeInterceptor) OnRequest(
+	_ *InterceptedConnection, request *frame.RawFrame) (*frame.RawFrame, *frame.RawFrame, error) {
+	*recv.calls = append(*recv.calls, recv.name+".OnRequest")
+	if recv.onRequest == nil {
+		return request, nil, nil
+	}
+	return recv.onRequest(request)
+}
+
+func (recv *fakeInterceptor) OnResponse(
+	_ *InterceptedConnection, _ *frame.RawFrame, response *frame.RawFrame) (*frame.RawFrame, error) {
+	*recv.calls = append(*recv.calls, recv.name+".OnResponse")
+	return response, nil
+}
+
+func TestInterceptRequest(t *testing.T) {
+	request := newInterceptorTestFrame(t, 5, &message.Query{Query: "SELECT * FROM ks.tb"})
+	rewrittenRequest := newInterceptorTestFrame(t, 0, &message.Query{Query: "SELECT * FROM ks.tb2"})
+	localResponse := newInterceptorTestFrame(t, 0, &message.VoidResult{})
+
+	tests := []struct {
+		name             string
+		onRequest        func(request *frame.RawFrame) (*frame.RawFrame, *frame.RawFrame, error)
+		expectedRequest  *frame.RawFrame
+		expectedResponse message.Message
+		expectedCalls    []string
+	}{
+		{"forward", nil, request, nil,
+			[]string{"first.OnRequest", "second.OnRequest"}},
+		{"rewrite", func(*frame.RawFrame) (*frame.RawFrame, *frame.RawFrame, error) {
+			return rewrittenRequest, nil, nil
+		}, rewrittenRequest, nil,
+			[]string{"first.OnRequest", "second.OnRequest"}},
+		{"local response", func(*frame.RawFrame) (*frame.RawFrame, *frame.RawFrame, error) {
+			return nil, localResponse, nil
+		}, nil, &message.VoidResult{},
+			[]string{"first.OnRequest"}},
+		{"error", func(*frame.RawFrame) (*frame.RawFrame, *frame.RawFrame, error) {
+			return nil, nil, errors.New("DELETE is not allowed")
+		}, nil, &message.ServerError{ErrorMessage: "Request rejected by the proxy: DELETE is not allowed"},
+			[]string{"first.OnRequest"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			ch := &ClientHandler{requestInterceptors: []RequestInterceptor{
+				&fakeInterceptor{name: "first", onRequest: tt.onRequest, calls: &calls},
+				&fakeInterceptor{name: "second", calls: &calls},
+			}}
+			newRequest, response := ch.interceptRequest(request)
+			require.Equal(t, tt.expectedCalls, calls)
+			require.Equal(t, tt.expectedRequest, newRequest)
+			if tt.expectedResponse == nil {
+				require.Nil(t, response)
+				return
+			}
+			require.Equal(t, request.Header.StreamId, response.Header.StreamId)
+			decodedResponse, err := defaultCodec.ConvertFromRawFrame(response)
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedResponse, decodedResponse.Body.Message)
+		})
+	}
+}
+
+func TestInterceptResponse(t *testing.T) {
+	var calls []string
+	ch := &ClientHandler{requestInterceptors: []RequestInterceptor{
+		&fakeInterceptor{name: "first", calls: &calls},
+		&fakeInterceptor{name: "second", calls: &calls},
+	}}
+	request := newInterceptorTestFrame(t, 5, &message.Query{Query: "SELECT * FROM ks.tb"})
+	response := newInterceptorTestFrame(t, 5, &message.VoidResult{})
+	require.Equal(t, response, ch.interceptResponse(request, response))
+	require.Equal(t, []string{"second.OnResponse", "first.OnResponse"}, calls)
+}
+
+func newInterceptorTestFrame(t *testing.T, streamId int16, msg message.Message) *frame.RawFrame {
+	rawFrame, err := defaultCodec.ConvertToRawFrame(frame.NewFrame(primitive.ProtocolVersion4, streamId, msg))
+	require.Nil(t, err)
+	return rawFrame
+}
@@ -0,0 +1,80 @@
+package zdmproxy
+
+import (
+	"context"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/journal"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+// journalCompactor enforces ZDM_ORIGIN_ONLY_JOURNAL_MAX_SIZE_MB and ZDM_ORIGIN_ONLY_JOURNAL_MAX_AGE_HOURS: the journal
+// file is compacted every ZDM_ORIGIN_ONLY_JOURNAL_COMPACTION_INTERVAL_MS in the background. Without these limits a
+// Target outage that lasts for days during a long migration fills the disk and then every write is rejected because
+// it can't be journaled. The dropped writes are counted and logged since they must be repaired on Target by other
+// means (e.g. a new historical data migration of their tables).
+type journalCompactor struct {
+	journal      *journal.FileJournal
+	retention    *common.JournalRetention
+	proxyMetrics *metrics.ProxyMetrics
+	now          func() time.Time
+
+	cancelFn context.CancelFunc
+	wg       *sync.WaitGroup
+}
+
+func newJournalCompactor(
+	fileJournal *journal.FileJournal, retention *common.JournalRetention,
+	proxyMetrics *metrics.ProxyMetrics) *journalCompactor {
+	return &journalCompactor{
+		journal:      fileJournal,
+		retention:    retention,
+		proxyMetrics: proxyMetrics,
+		now:          time.Now,
+		cancelFn:     func() {},
+		wg:           &sync.WaitGroup{},
+	}
+}
+
+func (c *journalCompactor) Start() {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	c.cancelFn = cancelFn
+	c.wg.Add(1)
+	go func() {
+		defer c.wg.Done()
+		ticker := time.NewTicker(c.retention.CompactionInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				c.compact()
+			}
+		}
+	}()
+}
+
+func (c *journalCompactor) Close() {
+	c.cancelFn()
+	c.wg.Wait()
+}
+
+func (c *journalCompactor) compact() {
+	result, err := c.journal.Compact(c.retention.MaxSizeBytes, c.retention.MaxAge, c.now())
+	if err != nil {
+		log.Warnf("Could not compact the origin-only journal %v: %v.", c.journal.GetPath(), err)
+		return
+	}
+	if result.ExpiredEntries == 0 && result.SizeExceededEntries == 0 {
+		return
+	}
+	c.proxyMetrics.OriginOnlyJournalDroppedEntriesAge.Add(result.ExpiredEntries)
+	c.proxyMetrics.OriginOnlyJournalDroppedEntriesSize.Add(result.SizeExceededEntries)
+	log.Warnf("Dropped %d journaled writes older than %v and %d journaled writes over the size limit of %d bytes "+
+		"from the origin-only journal %v, these writes will not be applied to %v (%d writes and %d bytes left).",
+		result.ExpiredEntries, c.retention.MaxAge, result.SizeExceededEntries, c.retention.MaxSizeBytes,
+		c.journal.GetPath(), common.ClusterTypeTarget, result.Entries, result.SizeBytes)
+}
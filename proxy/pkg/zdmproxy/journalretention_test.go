@@ -0,0 +1,45 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/journal"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestJournalCompactor(t *testing.T) {
+	fileJournal, err := journal.NewFileJournal(filepath.Join(t.TempDir(), "journal.jsonl"))
+	require.Nil(t, err)
+	defer fileJournal.Close()
+
+	now := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 4; i++ {
+		require.Nil(t, fileJournal.Append(&journal.Entry{OperationId: "op", Timestamp: now.Add(time.Duration(-i) * time.Hour)}))
+	}
+	size, err := fileJournal.GetSizeBytes()
+	require.Nil(t, err)
+
+	droppedByAge, droppedBySize := &countingGauge{}, &countingGauge{}
+	proxyMetrics := newFakeProxyMetrics()
+	proxyMetrics.OriginOnlyJournalDroppedEntriesAge = droppedByAge
+	proxyMetrics.OriginOnlyJournalDroppedEntriesSize = droppedBySize
+	compactor := newJournalCompactor(fileJournal, &common.JournalRetention{
+		MaxSizeBytes:       size / 2,
+		MaxAge:             150 * time.Minute,
+		CompactionInterval: time.Minute,
+	}, proxyMetrics)
+	compactor.now = func() time.Time { return now }
+
+	// the oldest entry is expired and one of the 3 others is over the size limit
+	compactor.compact()
+	require.Equal(t, 1, droppedByAge.value)
+	require.Equal(t, 1, droppedBySize.value)
+	compactor.compact()
+	require.Equal(t, 1, droppedByAge.value)
+	require.Equal(t, 1, droppedBySize.value)
+
+	compactor.Start()
+	compactor.Close()
+}
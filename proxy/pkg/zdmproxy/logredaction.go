@@ -0,0 +1,204 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"path"
+	"strings"
+	"sync/atomic"
+)
+
+// logRedaction holds the *logRedactor of the process, the logger is global so the redaction is too (like the log
+// level).
+var logRedaction = &atomic.Value{}
+
+// SetLogRedaction sets which statements have their literals and bound values masked when they are logged, a nil
+// config disables the redaction. The frame capture is always redacted and is not affected by it.
+func SetLogRedaction(config *common.LogRedactionConfig) {
+	logRedaction.Store(&logRedactor{config: config})
+}
+
+func getLogRedactor() *logRedactor {
+	redactor, _ := logRedaction.Load().(*logRedactor)
+	return redactor
+}
+
+type logRedactor struct {
+	config *common.LogRedactionConfig
+}
+
+// shouldRedactQuery is nil safe.
+func (r *logRedactor) shouldRedactQuery(query string) bool {
+	if r == nil || r.config == nil {
+		return false
+	}
+	if r.config.Mode != common.LogRedactionModeMatching {
+		return true
+	}
+	identifiers, qualifiedNames := getCqlIdentifiers(query)
+	for _, pattern := range r.config.Tables {
+		names := identifiers
+		if strings.Contains(pattern, ".") {
+			names = qualifiedNames
+		}
+		if matchesAnyLogRedactionPattern(pattern, names) {
+			return true
+		}
+	}
+	for _, pattern := range r.config.Columns {
+		if matchesAnyLogRedactionPattern(pattern, identifiers) {
+			return true
+		}
+	}
+	return false
+}
+
+// shouldRedactMessage returns true for the EXECUTE requests when the redaction is enabled since their statement is
+// not known when they are logged.
+func (r *logRedactor) shouldRedactMessage(msg message.Message) bool {
+	if r == nil || r.config == nil {
+		return false
+	}
+	switch typedMsg := msg.(type) {
+	case *message.Query:
+		return r.shouldRedactQuery(typedMsg.Query)
+	case *message.Prepare:
+		return r.shouldRedactQuery(typedMsg.Query)
+	case *message.Batch:
+		for _, child := range typedMsg.Children {
+			query, ok := child.QueryOrId.(string)
+			if !ok || r.shouldRedactQuery(query) {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+func matchesAnyLogRedactionPattern(pattern string, names []string) bool {
+	for _, name := range names {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// getCqlIdentifiers returns the (lower case) identifiers of a statement and its keyspace qualified names, e.g.
+// "ks.tbl". The literals are skipped. A keyword is returned as an identifier which only means that a pattern that
+// matches it redacts more statements than intended.
+func getCqlIdentifiers(query string) (identifiers []string, qualifiedNames []string) {
+	previous := ""
+	afterDot := false
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'':
+			j := i + 1
+			for j < len(query) && (query[j] != '\'' || (j+1 < len(query) && query[j+1] == '\'')) {
+				if query[j] == '\'' {
+					j++
+				}
+				j++
+			}
+			previous, afterDot = "", false
+			i = j + 1
+		case c == '$' && strings.HasPrefix(query[i:], "$$"):
+			end := strings.Index(query[i+2:], "$$")
+			if end < 0 {
+				end = len(query)
+			}
+			previous, afterDot = "", false
+			i += end + 4
+		case c >= '0' && c <= '9':
+			j := i + 1
+			for j < len(query) && (isCqlIdentifierChar(query[j]) || query[j] == '.') {
+				j++
+			}
+			previous, afterDot = "", false
+			i = j
+		case c == '"' || isCqlIdentifierChar(c):
+			var identifier string
+			j := i + 1
+			if c == '"' {
+				sb := &strings.Builder{}
+				for j < len(query) && (query[j] != '"' || (j+1 < len(query) && query[j+1] == '"')) {
+					if query[j] == '"' {
+						j++
+					}
+					sb.WriteByte(query[j])
+					j++
+				}
+				identifier = sb.String()
+				j++
+			} else {
+				for j < len(query) && isCqlIdentifierChar(query[j]) {
+					j++
+				}
+				identifier = query[i:j]
+			}
+			identifier = strings.ToLower(identifier)
+			identifiers = append(identifiers, identifier)
+			if afterDot && previous != "" {
+				qualifiedNames = append(qualifiedNames, previous+"."+identifier)
+			}
+			previous, afterDot = identifier, false
+			i = j
+		case c == '.':
+			afterDot = true
+			i++
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		default:
+			previous, afterDot = "", false
+			i++
+		}
+	}
+	return identifiers, qualifiedNames
+}
+
+// redactedQuery is passed to the logger instead of a statement so that it's only redacted if the log level is enabled.
+type redactedQuery string
+
+func (q redactedQuery) String() string {
+	if getLogRedactor().shouldRedactQuery(string(q)) {
+		return redactCqlLiterals(string(q))
+	}
+	return string(q)
+}
+
+// redactedRawFrame is passed to the logger instead of a request frame, the description of the frame capture replaces
+// the body when it's redacted.
+type redactedRawFrame struct {
+	f *frame.RawFrame
+}
+
+func (r redactedRawFrame) String() string {
+	redactor := getLogRedactor()
+	if redactor == nil || redactor.config == nil {
+		return r.f.String()
+	}
+	decoded, err := defaultCodec.ConvertFromRawFrame(r.f)
+	if err != nil {
+		return fmt.Sprintf("{header: %v, body: <could not decode body: %v>}", r.f.Header, err)
+	}
+	if !redactor.shouldRedactMessage(decoded.Body.Message) {
+		return r.f.String()
+	}
+	return fmt.Sprintf("{header: %v, body: %v}", r.f.Header, describeMessage(decoded.Body.Message))
+}
+
+type redactedFrame struct {
+	f *frame.Frame
+}
+
+func (r redactedFrame) String() string {
+	if !getLogRedactor().shouldRedactMessage(r.f.Body.Message) {
+		return r.f.String()
+	}
+	return fmt.Sprintf("{header: %v, body: %v}", r.f.Header, describeMessage(r.f.Body.Message))
+}
@@ -0,0 +1,81 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestLogRedactor_ShouldRedactQuery(t *testing.T) {
+	matching := &common.LogRedactionConfig{
+		Mode:    common.LogRedactionModeMatching,
+		Tables:  []string{"ks.users", "*.cards"},
+		Columns: []string{"*_ssn", "email"},
+	}
+
+	tests := []struct {
+		name     string
+		config   *common.LogRedactionConfig
+		query    string
+		expected bool
+	}{
+		{"disabled", nil, "INSERT INTO ks.users (id) VALUES (1)", false},
+		{"all", &common.LogRedactionConfig{Mode: common.LogRedactionModeAll}, "SELECT * FROM ks.tbl", true},
+		{"qualified table", matching, "INSERT INTO ks.users (id) VALUES (1)", true},
+		{"quoted table", matching, `SELECT * FROM "KS"."Users" WHERE id = 1`, true},
+		{"table of other keyspace", matching, "INSERT INTO other.users (id) VALUES (1)", false},
+		{"table pattern", matching, "UPDATE billing.cards SET v = 1 WHERE id = 2", true},
+		{"column pattern", matching, "UPDATE ks.tbl SET user_ssn = '123' WHERE id = 1", true},
+		{"column", matching, "SELECT Email FROM ks.tbl", true},
+		{"name in literal", matching, "INSERT INTO ks.tbl (id, v) VALUES (1, 'ks.users email')", false},
+		{"name in dollar literal", matching, "INSERT INTO ks.tbl (id, v) VALUES (1, $$email$$)", false},
+		{"no match", matching, "SELECT * FROM ks.tbl WHERE id = 1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var redactor *logRedactor
+			if tt.config != nil {
+				redactor = &logRedactor{config: tt.config}
+			}
+			require.Equal(t, tt.expected, redactor.shouldRedactQuery(tt.query))
+		})
+	}
+}
+
+func TestLogRedaction_Stringers(t *testing.T) {
+	t.Cleanup(func() {
+		SetLogRedaction(nil)
+	})
+	query := "INSERT INTO ks.users (id, email) VALUES (1, 'john@example.com')"
+	request := mockFrame(t, &message.Query{
+		Query: "INSERT INTO ks.users (id, email) VALUES (?, ?)",
+		Options: &message.QueryOptions{PositionalValues: []*primitive.Value{
+			primitive.NewValue([]byte{0, 0, 0, 1}), primitive.NewValue([]byte("john@example.com"))}},
+	}, primitive.ProtocolVersion4)
+	decodedRequest, err := defaultCodec.ConvertFromRawFrame(request)
+	require.Nil(t, err)
+
+	SetLogRedaction(nil)
+	require.Equal(t, query, fmt.Sprintf("%v", redactedQuery(query)))
+	require.Equal(t, request.String(), fmt.Sprintf("%v", redactedRawFrame{request}))
+	require.Equal(t, decodedRequest.String(), fmt.Sprintf("%v", redactedFrame{decodedRequest}))
+
+	SetLogRedaction(&common.LogRedactionConfig{Mode: common.LogRedactionModeAll})
+	require.Equal(t, "INSERT INTO ks.users (id, email) VALUES (?, ?)", fmt.Sprintf("%v", redactedQuery(query)))
+	expectedFrame := fmt.Sprintf(
+		"{header: %v, body: QUERY INSERT INTO ks.users (id, email) VALUES (?, ?) (2 values)}", request.Header)
+	require.Equal(t, expectedFrame, fmt.Sprintf("%v", redactedRawFrame{request}))
+	require.Equal(t, expectedFrame, fmt.Sprintf("%v", redactedFrame{decodedRequest}))
+	require.NotContains(t, fmt.Sprintf("%v", redactedRawFrame{request}), "john")
+
+	SetLogRedaction(&common.LogRedactionConfig{Mode: common.LogRedactionModeMatching, Tables: []string{"ks.other"}})
+	require.Equal(t, query, fmt.Sprintf("%v", redactedQuery(query)))
+	require.Equal(t, request.String(), fmt.Sprintf("%v", redactedRawFrame{request}))
+	execute := mockExecuteFrame(t, "id")
+	require.Equal(t, fmt.Sprintf("{header: %v, body: EXECUTE 6964 (0 values)}", execute.Header),
+		fmt.Sprintf("%v", redactedRawFrame{execute}))
+}
@@ -0,0 +1,204 @@
+package zdmproxy
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// lwtHandler handles the lightweight transactions, i.e. the conditional INSERT, UPDATE and DELETE statements and the
+// BATCHes that contain one. Their serial consistency can be replaced per cluster (ZDM_ORIGIN_LWT_SERIAL_CONSISTENCY and
+// ZDM_TARGET_LWT_SERIAL_CONSISTENCY), e.g. with LOCAL_SERIAL on a multi region TARGET so that the Paxos rounds don't
+// span every region while the applications keep using SERIAL on ORIGIN.
+//
+// With ZDM_LWT_METRICS_ENABLED the LWTs are also counted per cluster along with those whose condition was not met. The
+// outcome of an LWT depends on the data of the cluster it runs on, so an LWT that was only applied on one cluster
+// means that the data of the clusters already differed (lwt_applied_divergences_total).
+type lwtHandler struct {
+	// primitive.ConsistencyLevelAny if the serial consistency of the cluster is not replaced
+	originSerialConsistency primitive.ConsistencyLevel
+	targetSerialConsistency primitive.ConsistencyLevel
+	metricsEnabled          bool
+
+	proxyMetrics *metrics.ProxyMetrics
+}
+
+func newLwtHandler(
+	originSerialConsistency primitive.ConsistencyLevel, targetSerialConsistency primitive.ConsistencyLevel,
+	metricsEnabled bool, proxyMetrics *metrics.ProxyMetrics) *lwtHandler {
+	return &lwtHandler{
+		originSerialConsistency: originSerialConsistency,
+		targetSerialConsistency: targetSerialConsistency,
+		metricsEnabled:          metricsEnabled,
+		proxyMetrics:            proxyMetrics,
+	}
+}
+
+// isLwt returns whether the request is a lightweight transaction, the prepared statements are only inspected for their
+// first EXECUTE. It's nil safe.
+func (recv *lwtHandler) isLwt(
+	frameContext *frameDecodeContext, requestInfo RequestInfo, currentKeyspace string,
+	timeUuidGenerator TimeUuidGenerator) (bool, error) {
+	if recv == nil {
+		return false, nil
+	}
+	switch castedRequestInfo := requestInfo.(type) {
+	case *GenericRequestInfo:
+		if frameContext.GetRawFrame().Header.OpCode != primitive.OpCodeQuery {
+			return false, nil
+		}
+		stmtQueryData, err := frameContext.GetOrInspectStatement(currentKeyspace, timeUuidGenerator)
+		if err != nil {
+			return false, fmt.Errorf("could not inspect QUERY frame: %w", err)
+		}
+		return stmtQueryData.queryData.isLwt(), nil
+	case *ExecuteRequestInfo:
+		return getPreparedStatementInfo(castedRequestInfo.GetPreparedData(), currentKeyspace, timeUuidGenerator).lwt, nil
+	case *BatchRequestInfo:
+		decodedFrame, err := frameContext.GetOrDecodeFrame()
+		if err != nil {
+			return false, fmt.Errorf("could not decode BATCH frame: %w", err)
+		}
+		batchMsg, ok := decodedFrame.Body.Message.(*message.Batch)
+		if !ok {
+			return false, fmt.Errorf("expected Batch but got %T", decodedFrame.Body.Message)
+		}
+		for childIdx, child := range batchMsg.Children {
+			switch queryOrId := child.QueryOrId.(type) {
+			case string:
+				if inspectCqlQuery(queryOrId, currentKeyspace, timeUuidGenerator).isLwt() {
+					return true, nil
+				}
+			case []byte:
+				preparedData, ok := castedRequestInfo.preparedDataByStmtIdx[childIdx]
+				if ok && getPreparedStatementInfo(preparedData, currentKeyspace, timeUuidGenerator).lwt {
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	default:
+		return false, nil
+	}
+}
+
+// replaceSerialConsistencies returns the LWT requests that must be sent to each cluster with their serial consistency
+// replaced, the provided requests are returned for the clusters whose serial consistency is not replaced.
+func (recv *lwtHandler) replaceSerialConsistencies(
+	originRequest *frame.RawFrame, targetRequest *frame.RawFrame) (*frame.RawFrame, *frame.RawFrame, error) {
+	newOriginRequest, err := recv.replaceSerialConsistency(
+		common.ClusterTypeOrigin, originRequest, recv.originSerialConsistency, recv.proxyMetrics.LwtSerialConsistencyRemappedOrigin)
+	if err != nil {
+		return nil, nil, err
+	}
+	newTargetRequest, err := recv.replaceSerialConsistency(
+		common.ClusterTypeTarget, targetRequest, recv.targetSerialConsistency, recv.proxyMetrics.LwtSerialConsistencyRemappedTarget)
+	if err != nil {
+		return nil, nil, err
+	}
+	return newOriginRequest, newTargetRequest, nil
+}
+
+func (recv *lwtHandler) replaceSerialConsistency(
+	cluster common.ClusterType, request *frame.RawFrame, serialConsistency primitive.ConsistencyLevel,
+	remapped metrics.Counter) (*frame.RawFrame, error) {
+	if !serialConsistency.IsSerial() || request.Header.Version < primitive.ProtocolVersion3 {
+		// the serial consistency can't be set in v2 requests
+		return request, nil
+	}
+	decodedFrame, err := defaultCodec.ConvertFromRawFrame(request)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode request to replace its serial consistency for %v: %w", cluster, err)
+	}
+	var current *primitive.NillableConsistencyLevel
+	switch msg := decodedFrame.Body.Message.(type) {
+	case *message.Query:
+		if msg.Options == nil {
+			msg.Options = &message.QueryOptions{Consistency: primitive.ConsistencyLevelOne}
+		}
+		current = msg.Options.SerialConsistency
+		msg.Options.SerialConsistency = &primitive.NillableConsistencyLevel{Value: serialConsistency}
+	case *message.Execute:
+		if msg.Options == nil {
+			msg.Options = &message.QueryOptions{Consistency: primitive.ConsistencyLevelOne}
+		}
+		current = msg.Options.SerialConsistency
+		msg.Options.SerialConsistency = &primitive.NillableConsistencyLevel{Value: serialConsistency}
+	case *message.Batch:
+		current = msg.SerialConsistency
+		msg.SerialConsistency = &primitive.NillableConsistencyLevel{Value: serialConsistency}
+	default:
+		return request, nil
+	}
+	if current != nil && current.Value == serialConsistency {
+		return request, nil
+	}
+	newRequest, err := defaultCodec.ConvertToRawFrame(decodedFrame)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode request with the serial consistency of %v: %w", cluster, err)
+	}
+	remapped.Add(1)
+	log.Tracef("Replaced serial consistency of %v request with stream id %d on %v with %v",
+		request.Header.OpCode, request.Header.StreamId, cluster, consistencyLevelNames[serialConsistency])
+	return newRequest, nil
+}
+
+// trackResponses records the outcome of an LWT on the clusters that responded to it. It's a no-op unless
+// ZDM_LWT_METRICS_ENABLED is set.
+func (recv *lwtHandler) trackResponses(originResponse *frame.RawFrame, targetResponse *frame.RawFrame) {
+	if recv == nil || !recv.metricsEnabled {
+		return
+	}
+	originApplied, originOk := recv.trackResponse(
+		common.ClusterTypeOrigin, originResponse, recv.proxyMetrics.LwtRequestsOrigin, recv.proxyMetrics.LwtNotAppliedOrigin)
+	targetApplied, targetOk := recv.trackResponse(
+		common.ClusterTypeTarget, targetResponse, recv.proxyMetrics.LwtRequestsTarget, recv.proxyMetrics.LwtNotAppliedTarget)
+	if originOk && targetOk && originApplied != targetApplied {
+		recv.proxyMetrics.LwtAppliedDivergences.Add(1)
+		log.Debugf("Lightweight transaction was applied on %v (%v) and on %v (%v), the data of the clusters differs.",
+			common.ClusterTypeOrigin, originApplied, common.ClusterTypeTarget, targetApplied)
+	}
+}
+
+func (recv *lwtHandler) trackResponse(
+	cluster common.ClusterType, response *frame.RawFrame, requests metrics.Counter,
+	notApplied metrics.Counter) (bool, bool) {
+	if response == nil {
+		return false, false
+	}
+	requests.Add(1)
+	applied, ok, err := getLwtApplied(response)
+	if err != nil {
+		log.Warnf("Could not read the [applied] column of the lightweight transaction response of %v: %v", cluster, err)
+		return false, false
+	}
+	if ok && !applied {
+		notApplied.Add(1)
+	}
+	return applied, ok
+}
+
+// getLwtApplied returns the [applied] column of the response to an LWT, which is always its first column. The second
+// value is false if the response is not a ROWS result (e.g. an error).
+func getLwtApplied(response *frame.RawFrame) (bool, bool, error) {
+	if response.Header.OpCode != primitive.OpCodeResult {
+		return false, false, nil
+	}
+	body, err := defaultCodec.DecodeBody(response.Header, bytes.NewReader(response.Body))
+	if err != nil {
+		return false, false, fmt.Errorf("could not decode result: %w", err)
+	}
+	rows, ok := body.Message.(*message.RowsResult)
+	if !ok {
+		return false, false, nil
+	}
+	if len(rows.Data) == 0 || len(rows.Data[0]) == 0 || len(rows.Data[0][0]) != 1 {
+		return false, false, fmt.Errorf("expected a boolean [applied] column")
+	}
+	return rows.Data[0][0][0] != 0, true, nil
+}
@@ -0,0 +1,157 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestLwtHandler_IsLwt(t *testing.T) {
+	timeUuidGenerator, err := GetDefaultTimeUuidGenerator()
+	require.Nil(t, err)
+	handler := newLwtHandler(primitive.ConsistencyLevelAny, primitive.ConsistencyLevelAny, true, newFakeProxyMetrics())
+	newPreparedData := func(query string) PreparedData {
+		prepareRequestInfo := NewPrepareRequestInfo(NewGenericRequestInfo(forwardToBoth, false, true), nil, false, query, "")
+		return NewPreparedData(&message.PreparedResult{}, &message.PreparedResult{}, prepareRequestInfo)
+	}
+	lwtPreparedData := newPreparedData("UPDATE ks.tbl SET a = ? WHERE id = ? IF a = ?")
+	batch := func(children ...*message.BatchChild) *frame.RawFrame {
+		return mockFrame(t, &message.Batch{Children: children, Consistency: primitive.ConsistencyLevelQuorum},
+			primitive.ProtocolVersion4)
+	}
+
+	tests := []struct {
+		name        string
+		request     *frame.RawFrame
+		requestInfo RequestInfo
+		lwt         bool
+	}{
+		{name: "insert if not exists", request: mockQueryFrame(t, "INSERT INTO ks.tbl (id, a) VALUES (1, 2) IF NOT EXISTS"),
+			requestInfo: NewGenericRequestInfo(forwardToBoth, false, true), lwt: true},
+		{name: "update with condition", request: mockQueryFrame(t, "UPDATE ks.tbl SET a = 2 WHERE id = 1 IF a = 1"),
+			requestInfo: NewGenericRequestInfo(forwardToBoth, false, true), lwt: true},
+		{name: "delete if exists", request: mockQueryFrame(t, "DELETE FROM ks.tbl WHERE id = 1 IF EXISTS"),
+			requestInfo: NewGenericRequestInfo(forwardToBoth, false, true), lwt: true},
+		{name: "insert", request: mockQueryFrame(t, "INSERT INTO ks.tbl (id, a) VALUES (1, 2)"),
+			requestInfo: NewGenericRequestInfo(forwardToBoth, false, true), lwt: false},
+		{name: "select", request: mockQueryFrame(t, "SELECT * FROM ks.tbl WHERE id = 1"),
+			requestInfo: NewGenericRequestInfo(forwardToOrigin, false, true), lwt: false},
+		{name: "cql batch", request: mockQueryFrame(t, "BEGIN BATCH INSERT INTO ks.tbl (id, a) VALUES (1, 2); "+
+			"UPDATE ks.tbl SET a = 3 WHERE id = 1 IF a = 2; APPLY BATCH"),
+			requestInfo: NewGenericRequestInfo(forwardToBoth, false, true), lwt: true},
+		{name: "execute", request: mockFrame(t, &message.Execute{QueryId: []byte{1}}, primitive.ProtocolVersion4),
+			requestInfo: NewExecuteRequestInfo(lwtPreparedData), lwt: true},
+		{name: "batch with query child",
+			request: batch(&message.BatchChild{QueryOrId: "INSERT INTO ks.tbl (id, a) VALUES (1, 2)"},
+				&message.BatchChild{QueryOrId: "DELETE FROM ks.tbl WHERE id = 2 IF EXISTS"}),
+			requestInfo: NewBatchRequestInfo(nil), lwt: true},
+		{name: "batch with prepared child", request: batch(&message.BatchChild{QueryOrId: []byte{1}}),
+			requestInfo: NewBatchRequestInfo(map[int]PreparedData{0: lwtPreparedData}), lwt: true},
+		{name: "batch without condition",
+			request: batch(&message.BatchChild{QueryOrId: "INSERT INTO ks.tbl (id, a) VALUES (1, 2)"},
+				&message.BatchChild{QueryOrId: []byte{2}}),
+			requestInfo: NewBatchRequestInfo(map[int]PreparedData{1: newPreparedData("DELETE FROM ks.tbl WHERE id = ?")}),
+			lwt:         false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lwt, err := handler.isLwt(NewFrameDecodeContext(tt.request), tt.requestInfo, "ks", timeUuidGenerator)
+			require.Nil(t, err)
+			require.Equal(t, tt.lwt, lwt)
+		})
+	}
+
+	var disabled *lwtHandler
+	lwt, err := disabled.isLwt(NewFrameDecodeContext(tests[0].request), tests[0].requestInfo, "ks", timeUuidGenerator)
+	require.Nil(t, err)
+	require.False(t, lwt)
+}
+
+func TestLwtHandler_ReplaceSerialConsistencies(t *testing.T) {
+	proxyMetrics := newFakeProxyMetrics()
+	originRemapped, targetRemapped := &countingGauge{}, &countingGauge{}
+	proxyMetrics.LwtSerialConsistencyRemappedOrigin = originRemapped
+	proxyMetrics.LwtSerialConsistencyRemappedTarget = targetRemapped
+	handler := newLwtHandler(
+		primitive.ConsistencyLevelSerial, primitive.ConsistencyLevelLocalSerial, false, proxyMetrics)
+
+	getSerialConsistency := func(request *frame.RawFrame) *primitive.NillableConsistencyLevel {
+		decodedFrame, err := defaultCodec.ConvertFromRawFrame(request)
+		require.Nil(t, err)
+		switch msg := decodedFrame.Body.Message.(type) {
+		case *message.Query:
+			return msg.Options.SerialConsistency
+		case *message.Batch:
+			return msg.SerialConsistency
+		}
+		require.Fail(t, "unexpected message", "%T", decodedFrame.Body.Message)
+		return nil
+	}
+
+	query := mockFrame(t, &message.Query{
+		Query: "INSERT INTO ks.tbl (id, a) VALUES (1, 2) IF NOT EXISTS",
+		Options: &message.QueryOptions{
+			Consistency:       primitive.ConsistencyLevelQuorum,
+			SerialConsistency: &primitive.NillableConsistencyLevel{Value: primitive.ConsistencyLevelSerial},
+		},
+	}, primitive.ProtocolVersion4)
+	originRequest, targetRequest, err := handler.replaceSerialConsistencies(query, query)
+	require.Nil(t, err)
+	require.Same(t, query, originRequest)
+	require.Equal(t, primitive.ConsistencyLevelLocalSerial, getSerialConsistency(targetRequest).Value)
+	require.Equal(t, query.Header.StreamId, targetRequest.Header.StreamId)
+
+	// the serial consistency is set when the client relies on the default one
+	batch := mockFrame(t, &message.Batch{
+		Children:    []*message.BatchChild{{QueryOrId: "DELETE FROM ks.tbl WHERE id = 1 IF EXISTS"}},
+		Consistency: primitive.ConsistencyLevelQuorum,
+	}, primitive.ProtocolVersion4)
+	originRequest, targetRequest, err = handler.replaceSerialConsistencies(batch, batch)
+	require.Nil(t, err)
+	require.Equal(t, primitive.ConsistencyLevelSerial, getSerialConsistency(originRequest).Value)
+	require.Equal(t, primitive.ConsistencyLevelLocalSerial, getSerialConsistency(targetRequest).Value)
+
+	require.Equal(t, 1, originRemapped.value)
+	require.Equal(t, 2, targetRemapped.value)
+}
+
+func TestLwtHandler_TrackResponses(t *testing.T) {
+	rowsResult := func(applied byte) *frame.RawFrame {
+		return mockFrame(t, &message.RowsResult{
+			Metadata: &message.RowsMetadata{ColumnCount: 2},
+			Data:     message.RowSet{{[]byte{applied}, []byte{0, 0, 0, 1}}},
+		}, primitive.ProtocolVersion4)
+	}
+	applied, notApplied := rowsResult(1), rowsResult(0)
+	writeTimeout := mustEncodeResponse(t, primitive.ProtocolVersion4, &message.WriteTimeout{
+		ErrorMessage: "Operation timed out",
+		Consistency:  primitive.ConsistencyLevelSerial,
+		WriteType:    primitive.WriteTypeCas,
+	})
+
+	proxyMetrics := newFakeProxyMetrics()
+	originRequests, targetRequests := &countingGauge{}, &countingGauge{}
+	originNotApplied, targetNotApplied := &countingGauge{}, &countingGauge{}
+	divergences := &countingGauge{}
+	proxyMetrics.LwtRequestsOrigin, proxyMetrics.LwtRequestsTarget = originRequests, targetRequests
+	proxyMetrics.LwtNotAppliedOrigin, proxyMetrics.LwtNotAppliedTarget = originNotApplied, targetNotApplied
+	proxyMetrics.LwtAppliedDivergences = divergences
+	handler := newLwtHandler(primitive.ConsistencyLevelAny, primitive.ConsistencyLevelAny, true, proxyMetrics)
+
+	handler.trackResponses(applied, applied)
+	handler.trackResponses(applied, notApplied)
+	handler.trackResponses(notApplied, writeTimeout)
+	handler.trackResponses(nil, notApplied)
+	require.Equal(t, 3, originRequests.value)
+	require.Equal(t, 4, targetRequests.value)
+	require.Equal(t, 1, originNotApplied.value)
+	require.Equal(t, 2, targetNotApplied.value)
+	require.Equal(t, 1, divergences.value)
+
+	// the metrics are only recorded with ZDM_LWT_METRICS_ENABLED
+	newLwtHandler(primitive.ConsistencyLevelAny, primitive.ConsistencyLevelLocalSerial, false, proxyMetrics).
+		trackResponses(applied, notApplied)
+	require.Equal(t, 3, originRequests.value)
+}
@@ -0,0 +1,93 @@
+package zdmproxy
+
+import (
+	"context"
+	log "github.com/sirupsen/logrus"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The proxy stops shedding load when the heap usage goes below this fraction of the soft limit, this avoids flipping
+// between both states on every check when the usage hovers around the limit.
+const memoryPressureRecoveryRatio = 0.9
+
+// memoryPressureMonitor periodically compares the heap usage with a soft limit. While the limit is exceeded the proxy
+// rejects new client connections and fails new requests with OVERLOADED so that memory usage can go down before the
+// process is killed (which would drop every client connection at once).
+type memoryPressureMonitor struct {
+	softLimitBytes     uint64
+	recoveryLimitBytes uint64
+	readHeapBytes      func() uint64
+
+	underPressure *atomic.Value
+
+	cancelFn context.CancelFunc
+	wg       *sync.WaitGroup
+}
+
+func newMemoryPressureMonitor(softLimitBytes uint64, readHeapBytes func() uint64) *memoryPressureMonitor {
+	underPressure := &atomic.Value{}
+	underPressure.Store(false)
+	return &memoryPressureMonitor{
+		softLimitBytes:     softLimitBytes,
+		recoveryLimitBytes: uint64(float64(softLimitBytes) * memoryPressureRecoveryRatio),
+		readHeapBytes:      readHeapBytes,
+		underPressure:      underPressure,
+		cancelFn:           func() {},
+		wg:                 &sync.WaitGroup{},
+	}
+}
+
+func readHeapAllocBytes() uint64 {
+	memStats := &runtime.MemStats{}
+	runtime.ReadMemStats(memStats)
+	return memStats.HeapAlloc
+}
+
+func (m *memoryPressureMonitor) Start(interval time.Duration) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	m.cancelFn = cancelFn
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				m.check()
+			}
+		}
+	}()
+}
+
+func (m *memoryPressureMonitor) Close() {
+	m.cancelFn()
+	m.wg.Wait()
+}
+
+func (m *memoryPressureMonitor) check() {
+	heapBytes := m.readHeapBytes()
+	underPressure := m.IsUnderPressure()
+	if !underPressure && heapBytes > m.softLimitBytes {
+		log.Warnf("Heap usage (%d MB) exceeded the soft limit (%d MB), rejecting new client connections and requests "+
+			"until it goes below %d MB.", heapBytes/1024/1024, m.softLimitBytes/1024/1024, m.recoveryLimitBytes/1024/1024)
+		m.underPressure.Store(true)
+	} else if underPressure && heapBytes < m.recoveryLimitBytes {
+		log.Infof("Heap usage (%d MB) went below %d MB, accepting client connections and requests again.",
+			heapBytes/1024/1024, m.recoveryLimitBytes/1024/1024)
+		m.underPressure.Store(false)
+	}
+}
+
+// IsUnderPressure returns true if load should be shed, it's safe to call on a nil monitor (load shedding disabled).
+func (m *memoryPressureMonitor) IsUnderPressure() bool {
+	if m == nil {
+		return false
+	}
+	return m.underPressure.Load().(bool)
+}
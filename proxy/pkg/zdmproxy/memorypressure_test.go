@@ -0,0 +1,40 @@
+package zdmproxy
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestMemoryPressureMonitor_Check(t *testing.T) {
+	var heapBytes uint64
+	monitor := newMemoryPressureMonitor(1000, func() uint64 {
+		return heapBytes
+	})
+
+	tests := []struct {
+		name                  string
+		heapBytes             uint64
+		expectedUnderPressure bool
+	}{
+		{"below limit", 500, false},
+		{"equal to limit", 1000, false},
+		{"above limit", 1001, true},
+		{"below limit but above recovery limit", 950, true},
+		{"equal to recovery limit", 900, true},
+		{"below recovery limit", 899, false},
+		{"between recovery limit and limit", 950, false},
+		{"above limit again", 2000, true},
+	}
+
+	// cases depend on the previous ones
+	for _, tt := range tests {
+		heapBytes = tt.heapBytes
+		monitor.check()
+		require.Equal(t, tt.expectedUnderPressure, monitor.IsUnderPressure(), tt.name)
+	}
+}
+
+func TestMemoryPressureMonitor_Disabled(t *testing.T) {
+	var monitor *memoryPressureMonitor
+	require.False(t, monitor.IsUnderPressure())
+}
@@ -0,0 +1,187 @@
+package zdmproxy
+
+import (
+	"context"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const migrationStatusCompleted = "completed"
+
+// migrationStatusRouter sends the reads of the tables whose historical data was fully migrated to Target, according to
+// the table of Target set by ZDM_MIGRATION_STATUS_TABLE. The migration job (a cassandra-data-migrator or DSBulk
+// wrapper, for example) writes one row per table:
+//
+//	CREATE TABLE zdm.migration_status (keyspace_name text, table_name text, status text,
+//	    PRIMARY KEY (keyspace_name, table_name))
+//
+// A table is migrated when its status is "completed" (case insensitive), any other status keeps its reads on the
+// primary cluster. The table is read again every ZDM_MIGRATION_STATUS_REFRESH_INTERVAL_MS so reads follow the data
+// without restarting the proxy, and a failed refresh keeps the previous status.
+//
+// Reads with a routing hint keep the cluster of the hint. A routed read is not also sent to the async connector.
+type migrationStatusRouter struct {
+	statusQuery         string
+	queryFn             func(cql string, ctx context.Context) (*ParsedRowSet, error)
+	routingHintsEnabled bool
+	migratedTables      *atomic.Value // map[string]bool, the key is keyspace.table
+
+	cancelFn context.CancelFunc
+	wg       *sync.WaitGroup
+}
+
+func newMigrationStatusRouter(
+	statusTable string,
+	queryFn func(cql string, ctx context.Context) (*ParsedRowSet, error),
+	routingHintsEnabled bool) *migrationStatusRouter {
+	r := &migrationStatusRouter{
+		statusQuery:         fmt.Sprintf("SELECT keyspace_name, table_name, status FROM %v", statusTable),
+		queryFn:             queryFn,
+		routingHintsEnabled: routingHintsEnabled,
+		migratedTables:      &atomic.Value{},
+		cancelFn:            func() {},
+		wg:                  &sync.WaitGroup{},
+	}
+	r.migratedTables.Store(map[string]bool{})
+	return r
+}
+
+func (r *migrationStatusRouter) Start(interval time.Duration) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	r.cancelFn = cancelFn
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := r.refresh(ctx)
+				if err != nil && ctx.Err() == nil {
+					log.Warnf("Could not refresh the migration status, the previous status will be used until "+
+						"the next successful refresh: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+func (r *migrationStatusRouter) Close() {
+	r.cancelFn()
+	r.wg.Wait()
+}
+
+// refresh reads the status table and logs the tables whose migration status changed.
+func (r *migrationStatusRouter) refresh(ctx context.Context) error {
+	rowSet, err := r.queryFn(r.statusQuery, ctx)
+	if err != nil {
+		return fmt.Errorf("could not read migration status table: %w", err)
+	}
+	migratedTables := make(map[string]bool)
+	for _, row := range rowSet.Rows {
+		keyspace, keyspaceOk := getStringColumn(row, "keyspace_name")
+		table, tableOk := getStringColumn(row, "table_name")
+		status, _ := getStringColumn(row, "status")
+		if !keyspaceOk || !tableOk {
+			continue
+		}
+		if strings.EqualFold(strings.TrimSpace(status), migrationStatusCompleted) {
+			migratedTables[keyspace+"."+table] = true
+		}
+	}
+
+	previous := r.migratedTables.Load().(map[string]bool)
+	var added, removed []string
+	for name := range migratedTables {
+		if !previous[name] {
+			added = append(added, name)
+		}
+	}
+	for name := range previous {
+		if !migratedTables[name] {
+			removed = append(removed, name)
+		}
+	}
+	sort.Strings(added)
+	sort.Strings(removed)
+	for _, name := range added {
+		log.Infof("Historical migration of table %v is completed, its reads are now sent to %v.", name, forwardToTarget)
+	}
+	for _, name := range removed {
+		log.Infof("Table %v is no longer marked as migrated, its reads are sent to the primary cluster again.", name)
+	}
+	r.migratedTables.Store(migratedTables)
+	return nil
+}
+
+func getStringColumn(row *ParsedRow, column string) (string, bool) {
+	value, _ := parseNillableString(row, column)
+	if value == nil {
+		return "", false
+	}
+	return *value, true
+}
+
+// route returns the request info of a read of a migrated table with Target as forward decision, other requests are
+// returned as is.
+func (r *migrationStatusRouter) route(
+	requestInfo RequestInfo, frameContext *frameDecodeContext, currentKeyspace string,
+	timeUuidGenerator TimeUuidGenerator) RequestInfo {
+	if r == nil || requestInfo.GetForwardDecision() != forwardToOrigin {
+		return requestInfo
+	}
+	migratedTables := r.migratedTables.Load().(map[string]bool)
+	if len(migratedTables) == 0 {
+		return requestInfo
+	}
+
+	switch castedRequestInfo := requestInfo.(type) {
+	case *GenericRequestInfo:
+		if frameContext.GetRawFrame().Header.OpCode != primitive.OpCodeQuery {
+			return requestInfo
+		}
+		stmtQueryData, err := frameContext.GetOrInspectStatement(currentKeyspace, timeUuidGenerator)
+		if err != nil {
+			return requestInfo
+		}
+		queryInfo := stmtQueryData.queryData
+		if queryInfo.getStatementType() != statementTypeSelect || isSystemQuery(queryInfo) {
+			return requestInfo
+		}
+		if r.routingHintsEnabled {
+			if _, ok := parseRoutingHint(queryInfo.getQuery()); ok {
+				return requestInfo
+			}
+		}
+		if !migratedTables[queryInfo.getApplicableKeyspace()+"."+queryInfo.getTableName()] {
+			return requestInfo
+		}
+		return NewGenericRequestInfo(forwardToTarget, false, castedRequestInfo.ShouldBeTrackedInMetrics())
+	case *ExecuteRequestInfo:
+		if r.routingHintsEnabled {
+			if _, ok := parseRoutingHint(castedRequestInfo.GetPreparedData().GetPrepareRequestInfo().GetQuery()); ok {
+				return requestInfo
+			}
+		}
+		variablesMetadata := castedRequestInfo.GetPreparedData().GetOriginVariablesMetadata()
+		if variablesMetadata == nil || len(variablesMetadata.Columns) == 0 {
+			return requestInfo
+		}
+		column := variablesMetadata.Columns[0]
+		if !migratedTables[column.Keyspace+"."+column.Table] {
+			return requestInfo
+		}
+		return NewRoutedExecuteRequestInfo(castedRequestInfo.GetPreparedData(), forwardToTarget)
+	default:
+		return requestInfo
+	}
+}
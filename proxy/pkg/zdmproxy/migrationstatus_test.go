@@ -0,0 +1,104 @@
+package zdmproxy
+
+import (
+	"context"
+	"errors"
+	"github.com/datastax/go-cassandra-native-protocol/datatype"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func newMigrationStatusRowSet(rows ...[]string) *ParsedRowSet {
+	columnIndexes := map[string]int{"keyspace_name": 0, "table_name": 1, "status": 2}
+	columns := []*message.ColumnMetadata{
+		{Name: "keyspace_name", Type: datatype.Varchar},
+		{Name: "table_name", Type: datatype.Varchar},
+		{Name: "status", Type: datatype.Varchar},
+	}
+	rowSet := &ParsedRowSet{ColumnIndexes: columnIndexes, Columns: columns}
+	for _, row := range rows {
+		values := make([]interface{}, len(row))
+		for i := range row {
+			values[i] = row[i]
+		}
+		rowSet.Rows = append(rowSet.Rows, NewParsedRow(columnIndexes, columns, values))
+	}
+	return rowSet
+}
+
+func TestMigrationStatusRouter_Route(t *testing.T) {
+	var statusQuery string
+	rowSet := newMigrationStatusRowSet(
+		[]string{"ks", "migrated", "COMPLETED"},
+		[]string{"ks", "in_progress", "running"})
+	var queryErr error
+	router := newMigrationStatusRouter("zdm.migration_status", func(cql string, _ context.Context) (*ParsedRowSet, error) {
+		statusQuery = cql
+		return rowSet, queryErr
+	}, true)
+
+	routeQuery := func(query string, decision forwardDecision) RequestInfo {
+		frameContext := NewFrameDecodeContext(mockQueryFrame(t, query))
+		return router.route(NewGenericRequestInfo(decision, true, true), frameContext, "ks", nil)
+	}
+	routeExecute := func(table string, query string) RequestInfo {
+		variablesMetadata := &message.VariablesMetadata{
+			PkIndices: []uint16{0},
+			Columns:   []*message.ColumnMetadata{{Keyspace: "ks", Table: table, Name: "pk", Type: datatype.Int}},
+		}
+		preparedData := NewPreparedData(
+			&message.PreparedResult{PreparedQueryId: []byte("origin"), VariablesMetadata: variablesMetadata},
+			&message.PreparedResult{PreparedQueryId: []byte("target"), VariablesMetadata: variablesMetadata},
+			NewPrepareRequestInfo(NewGenericRequestInfo(forwardToOrigin, true, true), nil, false, query, ""))
+		frameContext := NewFrameDecodeContext(mockExecuteFrame(t, "origin"))
+		return router.route(NewExecuteRequestInfo(preparedData), frameContext, "ks", nil)
+	}
+
+	// nothing is routed before the first refresh
+	require.Equal(t, forwardToOrigin, routeQuery("SELECT * FROM migrated", forwardToOrigin).GetForwardDecision())
+
+	require.Nil(t, router.refresh(context.Background()))
+	require.Equal(t, "SELECT keyspace_name, table_name, status FROM zdm.migration_status", statusQuery)
+
+	routed := routeQuery("SELECT * FROM migrated", forwardToOrigin)
+	require.Equal(t, forwardToTarget, routed.GetForwardDecision())
+	require.False(t, routed.ShouldAlsoBeSentAsync())
+	require.Equal(t, forwardToTarget, routeQuery("SELECT * FROM ks.migrated WHERE pk = 1", forwardToOrigin).GetForwardDecision())
+	require.Equal(t, forwardToOrigin, routeQuery("SELECT * FROM in_progress", forwardToOrigin).GetForwardDecision())
+	require.Equal(t, forwardToOrigin, routeQuery("SELECT * FROM other.migrated", forwardToOrigin).GetForwardDecision())
+	require.Equal(t, forwardToOrigin, routeQuery("/* zdm:origin-only */ SELECT * FROM migrated", forwardToOrigin).GetForwardDecision())
+	require.Equal(t, forwardToBoth, routeQuery("INSERT INTO migrated (pk) VALUES (1)", forwardToBoth).GetForwardDecision())
+
+	require.Equal(t, forwardToTarget, routeExecute("migrated", "SELECT * FROM migrated WHERE pk = ?").GetForwardDecision())
+	require.Equal(t, forwardToOrigin, routeExecute("in_progress", "SELECT * FROM in_progress WHERE pk = ?").GetForwardDecision())
+	require.Equal(t, forwardToOrigin,
+		routeExecute("migrated", "/* zdm:origin-only */ SELECT * FROM migrated WHERE pk = ?").GetForwardDecision())
+
+	// a failed refresh keeps the previous status
+	queryErr = errors.New("control connection to TARGET is not open")
+	require.NotNil(t, router.refresh(context.Background()))
+	require.Equal(t, forwardToTarget, routeQuery("SELECT * FROM migrated", forwardToOrigin).GetForwardDecision())
+
+	// the migration of the table is restarted
+	queryErr = nil
+	rowSet = newMigrationStatusRowSet([]string{"ks", "migrated", "running"})
+	require.Nil(t, router.refresh(context.Background()))
+	require.Equal(t, forwardToOrigin, routeQuery("SELECT * FROM migrated", forwardToOrigin).GetForwardDecision())
+
+	var disabled *migrationStatusRouter
+	requestInfo := NewGenericRequestInfo(forwardToOrigin, true, true)
+	require.Equal(t, requestInfo, disabled.route(requestInfo, NewFrameDecodeContext(mockQueryFrame(t, "SELECT * FROM migrated")), "ks", nil))
+}
+
+func TestMigrationStatusRouter_NonQueryRequests(t *testing.T) {
+	router := newMigrationStatusRouter("zdm.migration_status", func(string, context.Context) (*ParsedRowSet, error) {
+		return newMigrationStatusRowSet([]string{"ks", "migrated", "completed"}), nil
+	}, false)
+	require.Nil(t, router.refresh(context.Background()))
+
+	frameContext := NewFrameDecodeContext(mockFrame(t, &message.AuthResponse{}, primitive.ProtocolVersion4))
+	requestInfo := NewGenericRequestInfo(forwardToOrigin, false, false)
+	require.Equal(t, requestInfo, router.route(requestInfo, frameContext, "ks", nil))
+}
@@ -0,0 +1,97 @@
+package zdmproxy
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+)
+
+// murmur3Token returns the token of a serialized partition key with the Murmur3Partitioner of Cassandra.
+func murmur3Token(partitionKey []byte) int64 {
+	if len(partitionKey) == 0 {
+		return math.MinInt64
+	}
+	token := murmur3H1(partitionKey)
+	if token == math.MinInt64 {
+		// the minimum token is reserved
+		return math.MaxInt64
+	}
+	return token
+}
+
+// murmur3H1 returns the first half of the 128 bit MurmurHash3 (x64 variant, seed 0) as computed by Cassandra, which
+// reads the bytes of the tail as signed values.
+func murmur3H1(data []byte) int64 {
+	const (
+		c1 = uint64(0x87c37b91114253d5)
+		c2 = uint64(0x4cf5ad432745937f)
+	)
+	var h1, h2 uint64
+	nBlocks := len(data) / 16
+	for i := 0; i < nBlocks; i++ {
+		k1 := binary.LittleEndian.Uint64(data[i*16:])
+		k2 := binary.LittleEndian.Uint64(data[i*16+8:])
+
+		k1 *= c1
+		k1 = bits.RotateLeft64(k1, 31)
+		k1 *= c2
+		h1 ^= k1
+		h1 = bits.RotateLeft64(h1, 27)
+		h1 += h2
+		h1 = h1*5 + 0x52dce729
+
+		k2 *= c2
+		k2 = bits.RotateLeft64(k2, 33)
+		k2 *= c1
+		h2 ^= k2
+		h2 = bits.RotateLeft64(h2, 31)
+		h2 += h1
+		h2 = h2*5 + 0x38495ab5
+	}
+
+	tail := data[nBlocks*16:]
+	signed := func(i int) uint64 {
+		return uint64(int64(int8(tail[i])))
+	}
+	var k1, k2 uint64
+	for i := len(tail) - 1; i >= 8; i-- {
+		k2 ^= signed(i) << (uint(i-8) * 8)
+	}
+	if len(tail) > 8 {
+		k2 *= c2
+		k2 = bits.RotateLeft64(k2, 33)
+		k2 *= c1
+		h2 ^= k2
+	}
+	k1Length := len(tail)
+	if k1Length > 8 {
+		k1Length = 8
+	}
+	for i := k1Length - 1; i >= 0; i-- {
+		k1 ^= signed(i) << (uint(i) * 8)
+	}
+	if len(tail) > 0 {
+		k1 *= c1
+		k1 = bits.RotateLeft64(k1, 31)
+		k1 *= c2
+		h1 ^= k1
+	}
+
+	h1 ^= uint64(len(data))
+	h2 ^= uint64(len(data))
+	h1 += h2
+	h2 += h1
+	h1 = murmur3Fmix(h1)
+	h2 = murmur3Fmix(h2)
+	h1 += h2
+	return int64(h1)
+}
+
+func murmur3Fmix(k uint64) uint64 {
+	k ^= k >> 33
+	k *= 0xff51afd7ed558ccd
+	k ^= k >> 33
+	k *= 0xc4ceb9fe1a85ec53
+	k ^= k >> 33
+	return k
+}
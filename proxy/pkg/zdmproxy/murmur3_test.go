@@ -0,0 +1,38 @@
+package zdmproxy
+
+import (
+	"encoding/hex"
+	"github.com/stretchr/testify/require"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestMurmur3H1(t *testing.T) {
+	// generated with the murmur3 implementation of the DataStax Java driver, every length of the tail is covered
+	expectedSeries := []uint64{
+		0x0000000000000000, 0x2ac9debed546a380, 0x649e4eaa7fc1708e, 0xce68f60d7c353bdb, 0x0f95757ce7f38254,
+		0x0f04e459497f3fc1, 0x88c0a92586be0a27, 0x13eb9fb82606f7a6, 0x8236039b7387354d, 0x4c1e87519fe738ba,
+		0x3f9652ac3effeb24, 0x3f33760ded9006c6, 0xaed70a6631854cb1, 0x8a299a8f8e0e2da7, 0x624b675c779249a6,
+		0xa4b203bb1d90b9a3, 0xa3293ad698ecb99a, 0xbc740023dbd50048, 0x3fe5ab9837d25cdd, 0x2d0338c1ca87d132,
+	}
+	sample := ""
+	for i, expected := range expectedSeries {
+		require.Equal(t, int64(expected), murmur3H1([]byte(sample)), "murmur3 of %q", sample)
+		sample += strconv.Itoa(i % 10)
+	}
+
+	hello, fox := uint64(0xcbd8a7b341bd9b02), uint64(0xcd99481f9ee902c9)
+	require.Equal(t, int64(hello), murmur3H1([]byte("hello")))
+	require.Equal(t, int64(fox), murmur3H1([]byte("The quick brown fox jumps over the lazy dog.")))
+
+	// bytes of the tail above 0x7f are signed
+	key, err := hex.DecodeString("00104327529fb645dd00b883ec39ae448bb800000400066a6b00")
+	require.Nil(t, err)
+	require.Equal(t, int64(-9223371632693506265), murmur3H1(key))
+}
+
+func TestMurmur3Token(t *testing.T) {
+	require.Equal(t, int64(math.MinInt64), murmur3Token(nil))
+	require.Equal(t, murmur3H1([]byte("hello")), murmur3Token([]byte("hello")))
+}
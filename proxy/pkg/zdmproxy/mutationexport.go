@@ -0,0 +1,120 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/mutationexport"
+	"strings"
+	"time"
+)
+
+// buildExportedMutations returns the mutations of a write request that is forwarded to both clusters, the outcomes
+// are filled out by exportMutations once both clusters respond. It returns nil if the request is not a write.
+func buildExportedMutations(
+	frameContext *frameDecodeContext, requestInfo RequestInfo, currentKeyspace string,
+	timeUuidGenerator TimeUuidGenerator, timestamp time.Time) ([]*mutationexport.Mutation, error) {
+	if requestInfo.GetForwardDecision() != forwardToBoth || !requestInfo.ShouldBeTrackedInMetrics() {
+		return nil, nil
+	}
+
+	var queriesInfo []QueryInfo
+	switch castedRequestInfo := requestInfo.(type) {
+	case *GenericRequestInfo:
+		if frameContext.GetRawFrame().Header.OpCode != primitive.OpCodeQuery {
+			return nil, nil
+		}
+		stmtQueryData, err := frameContext.GetOrInspectStatement(currentKeyspace, timeUuidGenerator)
+		if err != nil {
+			return nil, fmt.Errorf("could not inspect QUERY frame: %w", err)
+		}
+		queriesInfo = append(queriesInfo, stmtQueryData.queryData)
+	case *ExecuteRequestInfo:
+		queriesInfo = append(queriesInfo,
+			inspectPreparedQuery(castedRequestInfo.GetPreparedData(), currentKeyspace, timeUuidGenerator))
+	case *BatchRequestInfo:
+		decodedFrame, err := frameContext.GetOrDecodeFrame()
+		if err != nil {
+			return nil, fmt.Errorf("could not decode BATCH frame: %w", err)
+		}
+		batchMsg, ok := decodedFrame.Body.Message.(*message.Batch)
+		if !ok {
+			return nil, fmt.Errorf("expected Batch but got %v instead", decodedFrame.Body.Message.GetOpCode())
+		}
+		stmtsQueryData, err := frameContext.GetOrInspectAllStatements(currentKeyspace, timeUuidGenerator)
+		if err != nil {
+			return nil, fmt.Errorf("could not inspect BATCH frame: %w", err)
+		}
+		queriesInfoByIdx := make(map[int]QueryInfo, len(stmtsQueryData))
+		for _, stmtQueryData := range stmtsQueryData {
+			queriesInfoByIdx[stmtQueryData.statementIndex] = stmtQueryData.queryData
+		}
+		for idx := range batchMsg.Children {
+			if preparedData, ok := castedRequestInfo.GetPreparedDataByStmtIdx()[idx]; ok {
+				queriesInfo = append(queriesInfo, inspectPreparedQuery(preparedData, currentKeyspace, timeUuidGenerator))
+			} else if queryInfo, ok := queriesInfoByIdx[idx]; ok {
+				queriesInfo = append(queriesInfo, queryInfo)
+			}
+		}
+	default:
+		return nil, nil
+	}
+
+	var mutations []*mutationexport.Mutation
+	for _, queryInfo := range queriesInfo {
+		if !isWriteStatement(queryInfo) {
+			continue
+		}
+		mutations = append(mutations, &mutationexport.Mutation{
+			Fingerprint:   mutationexport.Fingerprint(queryInfo.getQuery()),
+			Keyspace:      queryInfo.getApplicableKeyspace(),
+			Table:         queryInfo.getTableName(),
+			StatementType: string(queryInfo.getStatementType()),
+			Timestamp:     timestamp,
+		})
+	}
+	return mutations, nil
+}
+
+func inspectPreparedQuery(preparedData PreparedData, currentKeyspace string, timeUuidGenerator TimeUuidGenerator) QueryInfo {
+	prepareRequestInfo := preparedData.GetPrepareRequestInfo()
+	keyspace := prepareRequestInfo.GetKeyspace()
+	if keyspace == "" {
+		keyspace = currentKeyspace
+	}
+	return inspectCqlQuery(prepareRequestInfo.GetQuery(), keyspace, timeUuidGenerator)
+}
+
+// exportMutations publishes the mutations of a request after both clusters responded to it.
+func (ch *ClientHandler) exportMutations(
+	mutations []*mutationexport.Mutation, originResponse *frame.RawFrame, targetResponse *frame.RawFrame) {
+	if len(mutations) == 0 || originResponse == nil || targetResponse == nil {
+		return
+	}
+	originOutcome := getMutationOutcome(originResponse)
+	targetOutcome := getMutationOutcome(targetResponse)
+	for _, mutation := range mutations {
+		mutation.OriginOutcome = originOutcome
+		mutation.TargetOutcome = targetOutcome
+	}
+	ch.mutationPublisher.Publish(mutations)
+}
+
+// getMutationOutcome returns mutationexport.OutcomeSuccess or the name of the error code, e.g. WriteTimeout.
+func getMutationOutcome(response *frame.RawFrame) string {
+	if isResponseSuccessful(response) {
+		return mutationexport.OutcomeSuccess
+	}
+	errorCode := primitive.ErrorCodeServerError
+	errMsg, err := decodeErrorResult(response)
+	if err == nil {
+		errorCode = errMsg.GetErrorCode()
+	}
+	// ErrorCode.String() returns "ErrorCode WriteTimeout [0x00001100]"
+	outcome := strings.TrimPrefix(errorCode.String(), "ErrorCode ")
+	if idx := strings.Index(outcome, " ["); idx >= 0 {
+		outcome = outcome[:idx]
+	}
+	return outcome
+}
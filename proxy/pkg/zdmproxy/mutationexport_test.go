@@ -0,0 +1,82 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/mutationexport"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestBuildExportedMutations(t *testing.T) {
+	timestamp := time.Now()
+	preparedInsert := NewPreparedData(&message.PreparedResult{PreparedQueryId: []byte{1}}, &message.PreparedResult{PreparedQueryId: []byte{2}},
+		NewPrepareRequestInfo(NewGenericRequestInfo(forwardToBoth, false, true), nil, false, "INSERT INTO tb2 (a) VALUES (?)", "ks2"))
+
+	type expectedMutation struct {
+		query         string
+		keyspace      string
+		table         string
+		statementType string
+	}
+	tests := []struct {
+		name        string
+		msg         message.Message
+		requestInfo RequestInfo
+		expected    []expectedMutation
+	}{
+		{"insert", &message.Query{Query: "INSERT INTO ks.tb (a) VALUES (1)"},
+			NewGenericRequestInfo(forwardToBoth, false, true),
+			[]expectedMutation{{"INSERT INTO ks.tb (a) VALUES (1)", "ks", "tb", "insert"}}},
+		{"delete in current keyspace", &message.Query{Query: "DELETE FROM tb WHERE a = 1"},
+			NewGenericRequestInfo(forwardToBoth, false, true),
+			[]expectedMutation{{"DELETE FROM tb WHERE a = 1", "current", "tb", "delete"}}},
+		{"select", &message.Query{Query: "SELECT * FROM ks.tb"},
+			NewGenericRequestInfo(forwardToOrigin, true, true), nil},
+		{"ddl", &message.Query{Query: "CREATE TABLE ks.tb (a int PRIMARY KEY)"},
+			NewGenericRequestInfo(forwardToBoth, false, true), nil},
+		{"execute", &message.Execute{QueryId: []byte{1}},
+			NewExecuteRequestInfo(preparedInsert),
+			[]expectedMutation{{"INSERT INTO tb2 (a) VALUES (?)", "ks2", "tb2", "insert"}}},
+		{"batch", &message.Batch{Children: []*message.BatchChild{
+			{QueryOrId: "UPDATE ks.tb SET b = 1 WHERE a = 1"},
+			{QueryOrId: []byte{1}},
+		}}, NewBatchRequestInfo(map[int]PreparedData{1: preparedInsert}),
+			[]expectedMutation{
+				{"UPDATE ks.tb SET b = 1 WHERE a = 1", "ks", "tb", "update"},
+				{"INSERT INTO tb2 (a) VALUES (?)", "ks2", "tb2", "insert"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rawFrame, err := defaultCodec.ConvertToRawFrame(frame.NewFrame(primitive.ProtocolVersion4, 1, tt.msg))
+			require.Nil(t, err)
+			mutations, err := buildExportedMutations(
+				NewFrameDecodeContext(rawFrame), tt.requestInfo, "current", nil, timestamp)
+			require.Nil(t, err)
+			require.Len(t, mutations, len(tt.expected))
+			for i, expected := range tt.expected {
+				require.Equal(t, &mutationexport.Mutation{
+					Fingerprint:   mutationexport.Fingerprint(expected.query),
+					Keyspace:      expected.keyspace,
+					Table:         expected.table,
+					StatementType: expected.statementType,
+					Timestamp:     timestamp,
+				}, mutations[i])
+			}
+		})
+	}
+}
+
+func TestGetMutationOutcome(t *testing.T) {
+	success, err := defaultCodec.ConvertToRawFrame(frame.NewFrame(primitive.ProtocolVersion4, 1, &message.VoidResult{}))
+	require.Nil(t, err)
+	require.Equal(t, mutationexport.OutcomeSuccess, getMutationOutcome(success))
+
+	writeTimeout, err := defaultCodec.ConvertToRawFrame(frame.NewFrame(primitive.ProtocolVersion4, 1, &message.WriteTimeout{
+		ErrorMessage: "timeout", Consistency: primitive.ConsistencyLevelQuorum, WriteType: primitive.WriteTypeSimple}))
+	require.Nil(t, err)
+	require.Equal(t, "WriteTimeout", getMutationOutcome(writeTimeout))
+}
@@ -0,0 +1,225 @@
+package zdmproxy
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/journal"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+const originOnlyWriteWarning = "TARGET is unavailable, this request was only applied to ORIGIN and journaled for TARGET."
+
+// originOnlyFallback keeps serving the clients with Origin only while Target is DOWN instead of failing the writes,
+// which is enabled by ZDM_TARGET_DOWN_POLICY=ORIGIN_ONLY. It's driven by the health of the Target control connection:
+// when Target becomes DOWN the client connections are drained and the drivers reconnect in origin-only mode, when
+// Target is UP again they are drained once more and reconnect to both clusters.
+//
+// The connections that are created in origin-only mode don't connect to Target at all, their secondary connection is
+// opened to Origin (with the Origin credentials) so that the handshake and the requests that must reach both
+// connections (USE, PREPARE, REGISTER, etc.) work as usual. The statements that would be sent to both clusters are
+// only sent to Origin, they are journaled to ZDM_ORIGIN_ONLY_JOURNAL_FILE so that they can be applied to Target later
+// and their responses carry a warning. A request that can't be journaled is rejected.
+//
+// The prepared statements that are prepared in origin-only mode are cached with the prepared id of Origin for both
+// clusters, Target returns UNPREPARED for them once it's back and the drivers prepare them again.
+type originOnlyFallback struct {
+	journal       journal.Journal
+	metricHandler *metrics.MetricHandler
+	drain         func(reason string)
+
+	lock   *sync.RWMutex
+	active bool
+}
+
+func newOriginOnlyFallback(
+	journal journal.Journal, metricHandler *metrics.MetricHandler, drain func(reason string)) *originOnlyFallback {
+	return &originOnlyFallback{
+		journal:       journal,
+		metricHandler: metricHandler,
+		drain:         drain,
+		lock:          &sync.RWMutex{},
+	}
+}
+
+// isActive returns true if the new client connections are created in origin-only mode, it's nil safe.
+func (f *originOnlyFallback) isActive() bool {
+	if f == nil {
+		return false
+	}
+	f.lock.RLock()
+	defer f.lock.RUnlock()
+	return f.active
+}
+
+// onTargetHealthChanged switches to origin-only mode when Target becomes DOWN and back to dual mode once it's UP,
+// a DEGRADED or AUTH_FAILING Target doesn't change the mode.
+func (f *originOnlyFallback) onTargetHealthChanged(health ClusterHealth) {
+	f.lock.Lock()
+	switch {
+	case health.State == ClusterHealthDown && !f.active:
+		f.active = true
+	case health.State == ClusterHealthUp && f.active:
+		f.active = false
+	default:
+		f.lock.Unlock()
+		return
+	}
+	active := f.active
+	f.lock.Unlock()
+
+	if active {
+		log.Warnf("%v is %v (%v), serving the clients with %v only and journaling the writes for %v.",
+			common.ClusterTypeTarget, health.State, health.Reason, common.ClusterTypeOrigin, common.ClusterTypeTarget)
+		f.drain(fmt.Sprintf("%v is %v, switching to origin-only mode", common.ClusterTypeTarget, health.State))
+	} else {
+		log.Infof("%v is %v again, switching back to dual mode. The journaled writes must be applied to %v.",
+			common.ClusterTypeTarget, health.State, common.ClusterTypeTarget)
+		f.drain(fmt.Sprintf("%v is %v, switching back to dual mode", common.ClusterTypeTarget, health.State))
+	}
+}
+
+// route returns the request info of a request of an origin-only client connection: the statements that would be sent
+// to both clusters are journaled and only sent to Origin, the reads that would be sent to Target are sent to Origin
+// and the other requests are returned as is. It returns the warnings of the client response.
+func (f *originOnlyFallback) route(
+	requestInfo RequestInfo, frameContext *frameDecodeContext, currentKeyspace string, client string,
+	timeUuidGenerator TimeUuidGenerator) (RequestInfo, []string, error) {
+	if !requestInfo.ShouldBeTrackedInMetrics() {
+		return requestInfo, nil, nil
+	}
+	switch requestInfo.GetForwardDecision() {
+	case forwardToTarget:
+		return routeToOrigin(requestInfo), nil, nil
+	case forwardToBoth:
+		originOnlyRequestInfo, journaled, err := journalTargetWrite(
+			f.journal, requestInfo, frameContext, currentKeyspace, client, timeUuidGenerator)
+		if err != nil {
+			f.metricHandler.GetProxyMetrics().OriginOnlyJournalFailures.Add(1)
+			return nil, nil, err
+		}
+		if !journaled {
+			return requestInfo, nil, nil
+		}
+		f.metricHandler.GetProxyMetrics().OriginOnlyJournaledRequests.Add(1)
+		return originOnlyRequestInfo, []string{originOnlyWriteWarning}, nil
+	default:
+		return requestInfo, nil, nil
+	}
+}
+
+// journalTargetWrite journals a QUERY (except USE), EXECUTE or BATCH request that would be sent to both clusters and
+// returns its request info with Origin as forward decision. It returns false if the request isn't journaled.
+func journalTargetWrite(
+	j journal.Journal, requestInfo RequestInfo, frameContext *frameDecodeContext, currentKeyspace string,
+	client string, timeUuidGenerator TimeUuidGenerator) (RequestInfo, bool, error) {
+	if requestInfo.GetForwardDecision() != forwardToBoth || !requestInfo.ShouldBeTrackedInMetrics() {
+		return requestInfo, false, nil
+	}
+	if frameContext.GetRawFrame().Header.OpCode == primitive.OpCodeQuery {
+		stmtQueryData, err := frameContext.GetOrInspectStatement(currentKeyspace, timeUuidGenerator)
+		if err == nil && stmtQueryData.queryData.getStatementType() == statementTypeUse {
+			return requestInfo, false, nil
+		}
+	}
+	originOnlyRequestInfo := routeToOrigin(requestInfo)
+	if originOnlyRequestInfo == requestInfo {
+		return requestInfo, false, nil
+	}
+
+	entry, err := buildJournalEntry(frameContext, requestInfo, currentKeyspace, client)
+	if err == nil {
+		entry.OperationId = timeUuidGenerator.GetTimeUuid().String()
+		err = j.Append(entry)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("could not journal the request for %v: %w", common.ClusterTypeTarget, err)
+	}
+	return originOnlyRequestInfo, true, nil
+}
+
+// routeToOrigin returns the request info of a QUERY, EXECUTE or BATCH request with Origin as forward decision, other
+// requests are returned as is.
+func routeToOrigin(requestInfo RequestInfo) RequestInfo {
+	switch castedRequestInfo := requestInfo.(type) {
+	case *GenericRequestInfo:
+		return NewGenericRequestInfo(forwardToOrigin, false, castedRequestInfo.ShouldBeTrackedInMetrics())
+	case *ExecuteRequestInfo:
+		return NewRoutedExecuteRequestInfo(castedRequestInfo.GetPreparedData(), forwardToOrigin)
+	case *BatchRequestInfo:
+		return NewRoutedBatchRequestInfo(castedRequestInfo.GetPreparedDataByStmtIdx(), forwardToOrigin)
+	default:
+		return requestInfo
+	}
+}
+
+func buildJournalEntry(
+	frameContext *frameDecodeContext, requestInfo RequestInfo, currentKeyspace string,
+	client string) (*journal.Entry, error) {
+	rawFrame := frameContext.GetRawFrame()
+	encodedFrame := &bytes.Buffer{}
+	err := defaultCodec.EncodeRawFrame(rawFrame, encodedFrame)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode %v frame: %w", rawFrame.Header.OpCode, err)
+	}
+
+	keyspace := currentKeyspace
+	var statements []string
+	switch castedRequestInfo := requestInfo.(type) {
+	case *ExecuteRequestInfo:
+		prepareRequestInfo := castedRequestInfo.GetPreparedData().GetPrepareRequestInfo()
+		if prepareRequestInfo.GetKeyspace() != "" {
+			keyspace = prepareRequestInfo.GetKeyspace()
+		}
+		statements = append(statements, prepareRequestInfo.GetQuery())
+	default:
+		decodedFrame, err := frameContext.GetOrDecodeFrame()
+		if err != nil {
+			return nil, err
+		}
+		switch msg := decodedFrame.Body.Message.(type) {
+		case *message.Query:
+			if msg.Options != nil && msg.Options.Keyspace != "" {
+				keyspace = msg.Options.Keyspace
+			}
+			statements = append(statements, msg.Query)
+		case *message.Batch:
+			if msg.Keyspace != "" {
+				keyspace = msg.Keyspace
+			}
+			batchRequestInfo, _ := requestInfo.(*BatchRequestInfo)
+			for idx, child := range msg.Children {
+				statement := ""
+				switch queryOrId := child.QueryOrId.(type) {
+				case string:
+					statement = queryOrId
+				default:
+					if batchRequestInfo == nil {
+						break
+					}
+					if preparedData, ok := batchRequestInfo.GetPreparedDataByStmtIdx()[idx]; ok {
+						statement = preparedData.GetPrepareRequestInfo().GetQuery()
+					}
+				}
+				statements = append(statements, statement)
+			}
+		default:
+			return nil, fmt.Errorf("unexpected %v request", decodedFrame.Body.Message.GetOpCode())
+		}
+	}
+
+	return &journal.Entry{
+		Timestamp:       time.Now().UTC(),
+		Client:          client,
+		Keyspace:        keyspace,
+		OpCode:          rawFrame.Header.OpCode.String(),
+		ProtocolVersion: int(rawFrame.Header.Version),
+		Statements:      statements,
+		Frame:           encodedFrame.Bytes(),
+	}, nil
+}
@@ -0,0 +1,123 @@
+package zdmproxy
+
+import (
+	"errors"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/zdm-proxy/proxy/pkg/journal"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type fakeJournal struct {
+	entries []*journal.Entry
+	err     error
+}
+
+func (j *fakeJournal) Append(entry *journal.Entry) error {
+	if j.err != nil {
+		return j.err
+	}
+	j.entries = append(j.entries, entry)
+	return nil
+}
+
+func (j *fakeJournal) Close() error {
+	return nil
+}
+
+func TestOriginOnlyFallback_Route(t *testing.T) {
+	fakeJournal := &fakeJournal{}
+	timeUuidGenerator, err := GetDefaultTimeUuidGenerator()
+	require.Nil(t, err)
+	fallback := newOriginOnlyFallback(fakeJournal, newFakeMetricHandler(), func(string) {})
+	routeQuery := func(query string, decision forwardDecision) (RequestInfo, []string) {
+		frameContext := NewFrameDecodeContext(mockQueryFrame(t, query))
+		requestInfo, warnings, err := fallback.route(
+			NewGenericRequestInfo(decision, false, true), frameContext, "ks", "127.0.0.1:9000", timeUuidGenerator)
+		require.Nil(t, err)
+		return requestInfo, warnings
+	}
+
+	requestInfo, warnings := routeQuery("INSERT INTO tbl (pk) VALUES (1)", forwardToBoth)
+	require.Equal(t, forwardToOrigin, requestInfo.GetForwardDecision())
+	require.Equal(t, []string{originOnlyWriteWarning}, warnings)
+	require.Len(t, fakeJournal.entries, 1)
+	entry := fakeJournal.entries[0]
+	require.Equal(t, "127.0.0.1:9000", entry.Client)
+	require.Equal(t, "ks", entry.Keyspace)
+	require.Equal(t, []string{"INSERT INTO tbl (pk) VALUES (1)"}, entry.Statements)
+	require.NotEmpty(t, entry.Frame)
+	require.NotEmpty(t, entry.OperationId)
+	require.WithinDuration(t, time.Now(), entry.Timestamp, time.Minute)
+
+	preparedData := NewPreparedData(
+		&message.PreparedResult{PreparedQueryId: []byte("origin")},
+		&message.PreparedResult{PreparedQueryId: []byte("origin")},
+		NewPrepareRequestInfo(NewGenericRequestInfo(forwardToBoth, false, true), nil, false,
+			"UPDATE tbl SET v = ? WHERE pk = ?", "ks2"))
+	requestInfo, warnings, err = fallback.route(
+		NewExecuteRequestInfo(preparedData), NewFrameDecodeContext(mockExecuteFrame(t, "origin")), "ks", "client", timeUuidGenerator)
+	require.Nil(t, err)
+	require.Equal(t, forwardToOrigin, requestInfo.GetForwardDecision())
+	require.Equal(t, []string{originOnlyWriteWarning}, warnings)
+	require.Len(t, fakeJournal.entries, 2)
+	require.Equal(t, "ks2", fakeJournal.entries[1].Keyspace)
+	require.Equal(t, []string{"UPDATE tbl SET v = ? WHERE pk = ?"}, fakeJournal.entries[1].Statements)
+	require.NotEmpty(t, fakeJournal.entries[1].OperationId)
+	require.NotEqual(t, entry.OperationId, fakeJournal.entries[1].OperationId)
+
+	requestInfo, warnings, err = fallback.route(
+		NewBatchRequestInfo(map[int]PreparedData{}),
+		NewFrameDecodeContext(mockBatch(t, "INSERT INTO ks.tbl (pk) VALUES (1)")), "ks", "client", timeUuidGenerator)
+	require.Nil(t, err)
+	require.Equal(t, forwardToOrigin, requestInfo.GetForwardDecision())
+	require.Equal(t, []string{originOnlyWriteWarning}, warnings)
+	require.Len(t, fakeJournal.entries, 3)
+	require.Equal(t, []string{"INSERT INTO ks.tbl (pk) VALUES (1)"}, fakeJournal.entries[2].Statements)
+
+	// reads are sent to origin and aren't journaled
+	requestInfo, warnings = routeQuery("SELECT * FROM tbl", forwardToTarget)
+	require.Equal(t, forwardToOrigin, requestInfo.GetForwardDecision())
+	require.Nil(t, warnings)
+
+	// USE must reach both connections
+	requestInfo, warnings = routeQuery("USE ks", forwardToBoth)
+	require.Equal(t, forwardToBoth, requestInfo.GetForwardDecision())
+	require.Nil(t, warnings)
+	require.Len(t, fakeJournal.entries, 3)
+
+	// the writes are rejected if they can't be journaled
+	fakeJournal.err = errors.New("disk full")
+	_, _, err = fallback.route(
+		NewGenericRequestInfo(forwardToBoth, false, true),
+		NewFrameDecodeContext(mockQueryFrame(t, "INSERT INTO tbl (pk) VALUES (1)")), "ks", "client", timeUuidGenerator)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "disk full")
+}
+
+func TestOriginOnlyFallback_OnTargetHealthChanged(t *testing.T) {
+	var drains []string
+	fallback := newOriginOnlyFallback(&fakeJournal{}, newFakeMetricHandler(), func(reason string) {
+		drains = append(drains, reason)
+	})
+	require.False(t, fallback.isActive())
+
+	fallback.onTargetHealthChanged(ClusterHealth{State: ClusterHealthDegraded})
+	require.False(t, fallback.isActive())
+	require.Empty(t, drains)
+
+	fallback.onTargetHealthChanged(ClusterHealth{State: ClusterHealthDown})
+	require.True(t, fallback.isActive())
+	fallback.onTargetHealthChanged(ClusterHealth{State: ClusterHealthAuthFailing})
+	require.True(t, fallback.isActive())
+	require.Equal(t, []string{"TARGET is DOWN, switching to origin-only mode"}, drains)
+
+	fallback.onTargetHealthChanged(ClusterHealth{State: ClusterHealthUp})
+	require.False(t, fallback.isActive())
+	require.Equal(t, []string{
+		"TARGET is DOWN, switching to origin-only mode", "TARGET is UP, switching back to dual mode"}, drains)
+
+	var disabled *originOnlyFallback
+	require.False(t, disabled.isActive())
+}
@@ -0,0 +1,96 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// pageSizeOverride rewrites the page size of the QUERY and EXECUTE reads before they are sent to the clusters. A page
+// size greater than ZDM_PAGE_SIZE_MAX is lowered to it and the requests without a page size (which would return every
+// row in a single page) get ZDM_PAGE_SIZE_DEFAULT, or ZDM_PAGE_SIZE_MAX if that's not set. It protects an undersized
+// TARGET from the clients that request unbounded pages.
+//
+// DSE page sizes in bytes are left as is since they can't be compared with a number of rows.
+type pageSizeOverride struct {
+	maxPageSize       int32
+	defaultPageSize   int32
+	rewrittenRequests metrics.Counter
+}
+
+func newPageSizeOverride(maxPageSize int, defaultPageSize int, proxyMetrics *metrics.ProxyMetrics) *pageSizeOverride {
+	if defaultPageSize <= 0 {
+		defaultPageSize = maxPageSize
+	}
+	return &pageSizeOverride{
+		maxPageSize:       int32(maxPageSize),
+		defaultPageSize:   int32(defaultPageSize),
+		rewrittenRequests: proxyMetrics.PageSizeOverriddenRequests,
+	}
+}
+
+// apply returns the read request with the page size that the proxy enforces, the provided context is returned if the
+// page size is already valid or if the request is not a read. It's nil safe.
+func (recv *pageSizeOverride) apply(requestInfo RequestInfo, context *frameDecodeContext) (*frameDecodeContext, error) {
+	if recv == nil {
+		return context, nil
+	}
+	opCode := context.GetRawFrame().Header.OpCode
+	if opCode != primitive.OpCodeQuery && opCode != primitive.OpCodeExecute {
+		return context, nil
+	}
+	// the writes are not paged and the intercepted requests are answered by the proxy
+	switch requestInfo.GetForwardDecision() {
+	case forwardToBoth, forwardToNone:
+		return context, nil
+	}
+
+	decodedFrame, err := context.GetOrDecodeFrame()
+	if err != nil {
+		return nil, fmt.Errorf("could not decode %v request to override its page size: %w", opCode, err)
+	}
+	var options *message.QueryOptions
+	switch msg := decodedFrame.Body.Message.(type) {
+	case *message.Query:
+		options = msg.Options
+	case *message.Execute:
+		options = msg.Options
+	default:
+		return nil, fmt.Errorf("unexpected message type for %v request: %T", opCode, msg)
+	}
+	if options == nil || options.PageSizeInBytes {
+		return context, nil
+	}
+	pageSize := recv.getPageSize(options.PageSize)
+	if pageSize == options.PageSize {
+		return context, nil
+	}
+
+	newFrame := decodedFrame.Clone()
+	switch msg := newFrame.Body.Message.(type) {
+	case *message.Query:
+		msg.Options.PageSize = pageSize
+	case *message.Execute:
+		msg.Options.PageSize = pageSize
+	}
+	newRawFrame, err := defaultCodec.ConvertToRawFrame(newFrame)
+	if err != nil {
+		return nil, fmt.Errorf("could not convert frame with overridden page size to raw frame: %w", err)
+	}
+	recv.rewrittenRequests.Add(1)
+	log.Tracef("Overrode page size of %v request with stream id %d from %d to %d",
+		opCode, newRawFrame.Header.StreamId, options.PageSize, pageSize)
+	return NewInitializedFrameDecodeContext(newRawFrame, newFrame, context.statementsQueryData), nil
+}
+
+func (recv *pageSizeOverride) getPageSize(pageSize int32) int32 {
+	if pageSize <= 0 {
+		return recv.defaultPageSize
+	}
+	if recv.maxPageSize > 0 && pageSize > recv.maxPageSize {
+		return recv.maxPageSize
+	}
+	return pageSize
+}
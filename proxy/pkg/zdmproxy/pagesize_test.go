@@ -0,0 +1,120 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestPageSizeOverride_Apply(t *testing.T) {
+	read := NewGenericRequestInfo(forwardToOrigin, false, true)
+	write := NewGenericRequestInfo(forwardToBoth, false, true)
+	selectQuery := func(options *message.QueryOptions) *frameDecodeContext {
+		return NewFrameDecodeContext(mockFrame(t, &message.Query{Query: "SELECT * FROM ks.tbl", Options: options}, primitive.ProtocolVersion4))
+	}
+
+	tests := []struct {
+		name             string
+		maxPageSize      int
+		defaultPageSize  int
+		requestInfo      RequestInfo
+		context          *frameDecodeContext
+		expectedPageSize int32
+		expectedRewrite  bool
+	}{
+		{
+			name:             "page size over the maximum",
+			maxPageSize:      1000,
+			requestInfo:      read,
+			context:          selectQuery(&message.QueryOptions{PageSize: 5000}),
+			expectedPageSize: 1000,
+			expectedRewrite:  true,
+		},
+		{
+			name:             "page size under the maximum",
+			maxPageSize:      1000,
+			requestInfo:      read,
+			context:          selectQuery(&message.QueryOptions{PageSize: 100}),
+			expectedPageSize: 100,
+		},
+		{
+			name:             "unbounded page with maximum only",
+			maxPageSize:      1000,
+			requestInfo:      read,
+			context:          selectQuery(&message.QueryOptions{}),
+			expectedPageSize: 1000,
+			expectedRewrite:  true,
+		},
+		{
+			name:             "unbounded page with default",
+			maxPageSize:      1000,
+			defaultPageSize:  500,
+			requestInfo:      read,
+			context:          selectQuery(&message.QueryOptions{PageSize: -1}),
+			expectedPageSize: 500,
+			expectedRewrite:  true,
+		},
+		{
+			name:             "page size kept with default only",
+			defaultPageSize:  500,
+			requestInfo:      read,
+			context:          selectQuery(&message.QueryOptions{PageSize: 5000}),
+			expectedPageSize: 5000,
+		},
+		{
+			name:        "page size in bytes",
+			maxPageSize: 1000,
+			requestInfo: read,
+			context: NewFrameDecodeContext(mockFrame(t, &message.Query{
+				Query:   "SELECT * FROM ks.tbl",
+				Options: &message.QueryOptions{PageSize: 65536, PageSizeInBytes: true}}, primitive.ProtocolVersionDse2)),
+			expectedPageSize: 65536,
+		},
+		{
+			name:             "write",
+			maxPageSize:      1000,
+			requestInfo:      write,
+			context:          NewFrameDecodeContext(mockQueryFrame(t, "INSERT INTO ks.tbl (pk) VALUES (1)")),
+			expectedPageSize: 0,
+		},
+		{
+			name:        "execute",
+			maxPageSize: 1000,
+			requestInfo: read,
+			context: NewFrameDecodeContext(mockFrame(t, &message.Execute{
+				QueryId: []byte("id"), Options: &message.QueryOptions{PageSize: 5000}}, primitive.ProtocolVersion4)),
+			expectedPageSize: 1000,
+			expectedRewrite:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			override := newPageSizeOverride(tt.maxPageSize, tt.defaultPageSize, newFakeProxyMetrics())
+			context, err := override.apply(tt.requestInfo, tt.context)
+			require.Nil(t, err)
+			if !tt.expectedRewrite {
+				require.Same(t, tt.context, context)
+			}
+
+			// decode the raw frame again to check what is sent to the clusters
+			decodedFrame, err := NewFrameDecodeContext(context.GetRawFrame()).GetOrDecodeFrame()
+			require.Nil(t, err)
+			switch msg := decodedFrame.Body.Message.(type) {
+			case *message.Query:
+				require.Equal(t, tt.expectedPageSize, msg.Options.PageSize)
+			case *message.Execute:
+				require.Equal(t, tt.expectedPageSize, msg.Options.PageSize)
+			default:
+				require.Fail(t, "unexpected message", "%T", msg)
+			}
+		})
+	}
+
+	context := selectQuery(&message.QueryOptions{PageSize: 5000})
+	var nilOverride *pageSizeOverride
+	newContext, err := nilOverride.apply(read, context)
+	require.Nil(t, err)
+	require.Same(t, context, newContext)
+}
@@ -0,0 +1,115 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+)
+
+var payloadSizeStatementTypes = []statementType{
+	statementTypeSelect, statementTypeInsert, statementTypeUpdate, statementTypeDelete, statementTypeBatch,
+	statementTypeUse, statementTypeOther,
+}
+
+// payloadSizeMetrics records the size of the request and response bodies of each cluster per statement type
+// (request_size_bytes and response_size_bytes) when ZDM_PAYLOAD_SIZE_METRICS_ENABLED is set. A large response of one
+// cluster often explains a CPU or memory increase of the proxy that the latency metrics don't account for.
+//
+// The histograms of every statement type are created upfront so recording a size doesn't need a lock.
+type payloadSizeMetrics struct {
+	originMetrics map[statementType]*metrics.PayloadSizeMetrics
+	targetMetrics map[statementType]*metrics.PayloadSizeMetrics
+}
+
+func newPayloadSizeMetrics(metricFactory metrics.MetricFactory, buckets []float64) (*payloadSizeMetrics, error) {
+	m := &payloadSizeMetrics{
+		originMetrics: make(map[statementType]*metrics.PayloadSizeMetrics, len(payloadSizeStatementTypes)),
+		targetMetrics: make(map[statementType]*metrics.PayloadSizeMetrics, len(payloadSizeStatementTypes)),
+	}
+	for _, stmtType := range payloadSizeStatementTypes {
+		originMetrics, err := metrics.CreatePayloadSizeMetrics(
+			metricFactory, metrics.StatementClusterOrigin, string(stmtType), buckets)
+		if err != nil {
+			return nil, fmt.Errorf("could not create the origin payload size metrics of %v statements: %w", stmtType, err)
+		}
+		targetMetrics, err := metrics.CreatePayloadSizeMetrics(
+			metricFactory, metrics.StatementClusterTarget, string(stmtType), buckets)
+		if err != nil {
+			return nil, fmt.Errorf("could not create the target payload size metrics of %v statements: %w", stmtType, err)
+		}
+		m.originMetrics[stmtType] = originMetrics
+		m.targetMetrics[stmtType] = targetMetrics
+	}
+	return m, nil
+}
+
+func (m *payloadSizeMetrics) get(cluster common.ClusterType, stmtType statementType) *metrics.PayloadSizeMetrics {
+	var clusterMetrics map[statementType]*metrics.PayloadSizeMetrics
+	switch cluster {
+	case common.ClusterTypeOrigin:
+		clusterMetrics = m.originMetrics
+	case common.ClusterTypeTarget:
+		clusterMetrics = m.targetMetrics
+	default:
+		return nil
+	}
+	if payloadMetrics, ok := clusterMetrics[stmtType]; ok {
+		return payloadMetrics
+	}
+	return clusterMetrics[statementTypeOther]
+}
+
+// recordRequest accounts a request that is sent to a cluster.
+func (m *payloadSizeMetrics) recordRequest(cluster common.ClusterType, stmtType statementType, request *frame.RawFrame) {
+	if m == nil || request == nil {
+		return
+	}
+	if payloadMetrics := m.get(cluster, stmtType); payloadMetrics != nil {
+		payloadMetrics.RequestSize.Observe(float64(len(request.Body)))
+	}
+}
+
+// recordResponse accounts a response of a cluster, the responses of the async connector are not recorded.
+func (m *payloadSizeMetrics) recordResponse(
+	connectorType ClusterConnectorType, stmtType statementType, response *frame.RawFrame) {
+	if m == nil || response == nil {
+		return
+	}
+	cluster := common.ClusterTypeNone
+	switch connectorType {
+	case ClusterConnectorTypeOrigin:
+		cluster = common.ClusterTypeOrigin
+	case ClusterConnectorTypeTarget:
+		cluster = common.ClusterTypeTarget
+	}
+	if payloadMetrics := m.get(cluster, stmtType); payloadMetrics != nil {
+		payloadMetrics.ResponseSize.Observe(float64(len(response.Body)))
+	}
+}
+
+// getRequestStatementType returns the statement type of a request for the payload size metrics, statementTypeOther
+// if it's not a QUERY, EXECUTE or BATCH.
+func getRequestStatementType(
+	frameContext *frameDecodeContext, requestInfo RequestInfo, currentKeyspace string,
+	timeUuidGenerator TimeUuidGenerator) (statementType, error) {
+	switch castedRequestInfo := requestInfo.(type) {
+	case *GenericRequestInfo:
+		if frameContext.GetRawFrame().Header.OpCode != primitive.OpCodeQuery {
+			return statementTypeOther, nil
+		}
+		stmtQueryData, err := frameContext.GetOrInspectStatement(currentKeyspace, timeUuidGenerator)
+		if err != nil {
+			return statementTypeOther, fmt.Errorf("could not inspect QUERY frame: %w", err)
+		}
+		return stmtQueryData.queryData.getStatementType(), nil
+	case *ExecuteRequestInfo:
+		return getPreparedStatementInfo(
+			castedRequestInfo.GetPreparedData(), currentKeyspace, timeUuidGenerator).statementType, nil
+	case *BatchRequestInfo:
+		return statementTypeBatch, nil
+	default:
+		return statementTypeOther, nil
+	}
+}
@@ -0,0 +1,94 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics/prommetrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestPayloadSizeMetrics(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	payloadMetrics, err := newPayloadSizeMetrics(
+		prommetrics.NewPrometheusMetricFactory(registry, "zdm"), []float64{64, 1024, 65536})
+	require.Nil(t, err)
+
+	request := mockQueryFrame(t, "SELECT * FROM ks.tbl")
+	response := mockFrame(t, &message.RowsResult{
+		Metadata: &message.RowsMetadata{ColumnCount: 1},
+		Data:     message.RowSet{{make([]byte, 2000)}},
+	}, primitive.ProtocolVersion4)
+	payloadMetrics.recordRequest(common.ClusterTypeOrigin, statementTypeSelect, request)
+	payloadMetrics.recordRequest(common.ClusterTypeTarget, statementTypeSelect, request)
+	payloadMetrics.recordResponse(ClusterConnectorTypeOrigin, statementTypeSelect, response)
+	payloadMetrics.recordResponse(ClusterConnectorTypeTarget, statementTypeInsert, response)
+	payloadMetrics.recordResponse(ClusterConnectorTypeTarget, statementType("create"), response)
+	// the responses of the async connector are not recorded
+	payloadMetrics.recordResponse(ClusterConnectorTypeAsync, statementTypeSelect, response)
+
+	families, err := registry.Gather()
+	require.Nil(t, err)
+	counts := map[string]uint64{}
+	sums := map[string]float64{}
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range m.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			key := family.GetName() + "/" + labels["cluster"] + "/" + labels["statement_type"]
+			counts[key] = m.GetHistogram().GetSampleCount()
+			sums[key] = m.GetHistogram().GetSampleSum()
+		}
+	}
+	require.Equal(t, uint64(1), counts["zdm_request_size_bytes/origin/select"])
+	require.Equal(t, float64(len(request.Body)), sums["zdm_request_size_bytes/origin/select"])
+	require.Equal(t, uint64(1), counts["zdm_request_size_bytes/target/select"])
+	require.Equal(t, uint64(1), counts["zdm_response_size_bytes/origin/select"])
+	require.Equal(t, float64(len(response.Body)), sums["zdm_response_size_bytes/origin/select"])
+	require.Equal(t, uint64(0), counts["zdm_response_size_bytes/target/select"])
+	require.Equal(t, uint64(1), counts["zdm_response_size_bytes/target/insert"])
+	require.Equal(t, uint64(1), counts["zdm_response_size_bytes/target/other"])
+	require.Equal(t, uint64(0), counts["zdm_request_size_bytes/origin/batch"])
+	require.Len(t, counts, 2*2*len(payloadSizeStatementTypes))
+
+	var disabled *payloadSizeMetrics
+	disabled.recordRequest(common.ClusterTypeOrigin, statementTypeSelect, request)
+	disabled.recordResponse(ClusterConnectorTypeOrigin, statementTypeSelect, response)
+}
+
+func TestGetRequestStatementType(t *testing.T) {
+	timeUuidGenerator, err := GetDefaultTimeUuidGenerator()
+	require.Nil(t, err)
+	prepareRequestInfo := NewPrepareRequestInfo(
+		NewGenericRequestInfo(forwardToBoth, false, true), nil, false, "DELETE FROM ks.tbl WHERE id = ?", "")
+	preparedData := NewPreparedData(&message.PreparedResult{}, &message.PreparedResult{}, prepareRequestInfo)
+
+	tests := []struct {
+		name          string
+		frameContext  *frameDecodeContext
+		requestInfo   RequestInfo
+		statementType statementType
+	}{
+		{name: "query", frameContext: NewFrameDecodeContext(mockQueryFrame(t, "UPDATE ks.tbl SET a = 1 WHERE id = 1")),
+			requestInfo: NewGenericRequestInfo(forwardToBoth, false, true), statementType: statementTypeUpdate},
+		{name: "execute", requestInfo: NewExecuteRequestInfo(preparedData), statementType: statementTypeDelete},
+		{name: "batch", requestInfo: NewBatchRequestInfo(nil), statementType: statementTypeBatch},
+		{name: "options", frameContext: NewFrameDecodeContext(mockFrame(t, &message.Options{}, primitive.ProtocolVersion4)),
+			requestInfo: NewGenericRequestInfo(forwardToBoth, false, false), statementType: statementTypeOther},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stmtType, err := getRequestStatementType(tt.frameContext, tt.requestInfo, "ks", timeUuidGenerator)
+			require.Nil(t, err)
+			require.Equal(t, tt.statementType, stmtType)
+		})
+	}
+
+	// the statement type of the prepared statement is cached for the next EXECUTEs
+	require.Equal(t, &preparedStatementInfo{statementType: statementTypeDelete},
+		preparedData.(*preparedDataImpl).statementInfo.Load())
+}
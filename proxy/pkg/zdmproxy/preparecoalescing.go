@@ -0,0 +1,141 @@
+package zdmproxy
+
+import (
+	"context"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	"sync"
+	"time"
+)
+
+// prepareCoalescer merges the identical PREPARE requests that are in flight at the same time into a single request
+// per set of cluster connections, which is enabled by ZDM_PREPARE_COALESCING_ENABLED. After a restart of the proxy
+// (or of a cluster) every client connection prepares the same statements again at the same time, without coalescing
+// each of these PREPAREs is sent to both clusters.
+//
+// The first PREPARE of a statement is sent to the clusters and the identical PREPAREs that are received before its
+// response wait for it and are answered with the same PREPARED result. A PREPARE is only coalesced with another one
+// that is sent to the same Origin, Target and async nodes with the same protocol version and current keyspace. When
+// the first PREPARE doesn't return a PREPARED result (an error or a timeout for example) the waiting PREPAREs are
+// sent to the clusters as usual.
+type prepareCoalescer struct {
+	lock         *sync.Mutex
+	calls        map[string]*prepareCall
+	proxyMetrics *metrics.ProxyMetrics
+}
+
+// prepareCall is a PREPARE that is in flight, response is set before done is closed and it's nil if the response
+// can't be shared.
+type prepareCall struct {
+	key      string
+	done     chan struct{}
+	response *frame.RawFrame
+}
+
+func newPrepareCoalescer(proxyMetrics *metrics.ProxyMetrics) *prepareCoalescer {
+	return &prepareCoalescer{
+		lock:         &sync.Mutex{},
+		calls:        map[string]*prepareCall{},
+		proxyMetrics: proxyMetrics,
+	}
+}
+
+func newPrepareCoalescingKey(endpoints string, request *frame.RawFrame, currentKeyspace string) string {
+	// the body contains the query and, with protocol v5, the keyspace of the PREPARE
+	return fmt.Sprintf("%v|%v|%v|%s", endpoints, request.Header.Version, currentKeyspace, request.Body)
+}
+
+// join returns the in flight PREPARE with the same key or a new one if there isn't any, leader is true when the caller
+// must send the PREPARE to the clusters and then complete the returned call.
+func (c *prepareCoalescer) join(key string) (call *prepareCall, leader bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if call, ok := c.calls[key]; ok {
+		return call, false
+	}
+	call = &prepareCall{key: key, done: make(chan struct{})}
+	c.calls[key] = call
+	return call, true
+}
+
+// complete shares the response of the leader with the PREPAREs that are waiting for it, only a PREPARED result is
+// shared. It must be called before the response is sent to the client of the leader. It can be called with a nil call
+// and more than once, only the first call has an effect.
+func (c *prepareCoalescer) complete(call *prepareCall, response *frame.RawFrame) {
+	if c == nil || call == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.calls[call.key] != call {
+		return
+	}
+	delete(c.calls, call.key)
+	if response != nil && response.Header.OpCode == primitive.OpCodeResult {
+		// the header is modified when the response is written to a client connection so each of them gets a copy
+		call.response = &frame.RawFrame{Header: response.Header.Clone(), Body: response.Body}
+	}
+	close(call.done)
+}
+
+// wait returns the response of the leader or nil if it can't be shared or if it's not received before the timeout.
+func (call *prepareCall) wait(ctx context.Context, timeout time.Duration) *frame.RawFrame {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-call.done:
+		return call.response
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+// coalescePrepare returns true if the request is a PREPARE that will be answered with the response of an identical
+// PREPARE that is already in flight. Otherwise, it returns the call that must be completed when the request finishes
+// if the request is a PREPARE that other requests can wait for.
+func (ch *ClientHandler) coalescePrepare(
+	frameContext *frameDecodeContext, requestInfo RequestInfo, currentKeyspace string, overallRequestStartTime time.Time,
+	requestTimeout time.Duration, responseWarnings []string) (*prepareCall, bool) {
+	if ch.prepareCoalescer == nil || requestInfo.GetForwardDecision() == forwardToNone {
+		return nil, false
+	}
+	if _, ok := requestInfo.(*PrepareRequestInfo); !ok {
+		return nil, false
+	}
+	request := frameContext.GetRawFrame()
+	call, leader := ch.prepareCoalescer.join(newPrepareCoalescingKey(ch.prepareCoalescingEndpoints, request, currentKeyspace))
+	if leader {
+		return call, false
+	}
+
+	ch.clientHandlerRequestWaitGroup.Add(1)
+	go func() {
+		defer ch.clientHandlerRequestWaitGroup.Done()
+		budget, _ := ch.getRequestBudget(overallRequestStartTime, requestTimeout)
+		response := call.wait(ch.clientHandlerContext, budget)
+		if response != nil {
+			ch.prepareCoalescer.proxyMetrics.CoalescedPrepares.Add(1)
+			header := response.Header.Clone()
+			header.StreamId = request.Header.StreamId
+			ch.sendInterceptedResponseToClient(request, &frame.RawFrame{Header: header, Body: response.Body})
+			return
+		}
+		if ch.clientHandlerContext.Err() != nil {
+			return
+		}
+		log.Debugf("Identical PREPARE in flight did not return a PREPARED result, sending PREPARE with stream id %d "+
+			"to the clusters.", request.Header.StreamId)
+		err := ch.executeRequest(
+			frameContext, requestInfo, currentKeyspace, overallRequestStartTime, nil, requestTimeout, responseWarnings, nil)
+		if err != nil {
+			log.Warnf("error sending request with opcode %02x and streamid %d: %s",
+				request.Header.OpCode, request.Header.StreamId, err.Error())
+		}
+	}()
+	return nil, true
+}
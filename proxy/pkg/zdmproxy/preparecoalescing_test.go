@@ -0,0 +1,72 @@
+package zdmproxy
+
+import (
+	"context"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestPrepareCoalescer(t *testing.T) {
+	coalescer := newPrepareCoalescer(newFakeProxyMetrics())
+	prepare := func(query string, version primitive.ProtocolVersion) *frame.RawFrame {
+		f, err := defaultCodec.ConvertToRawFrame(frame.NewFrame(version, 1, &message.Prepare{Query: query}))
+		require.Nil(t, err)
+		return f
+	}
+	key := newPrepareCoalescingKey("origin/target/", prepare("SELECT * FROM tbl", primitive.ProtocolVersion4), "ks")
+
+	leaderCall, leader := coalescer.join(key)
+	require.True(t, leader)
+	followerCall, leader := coalescer.join(key)
+	require.False(t, leader)
+	require.Same(t, leaderCall, followerCall)
+
+	// a different query, protocol version, keyspace or set of nodes is not coalesced
+	for _, otherKey := range []string{
+		newPrepareCoalescingKey("origin/target/", prepare("SELECT * FROM tbl2", primitive.ProtocolVersion4), "ks"),
+		newPrepareCoalescingKey("origin/target/", prepare("SELECT * FROM tbl", primitive.ProtocolVersion3), "ks"),
+		newPrepareCoalescingKey("origin/target/", prepare("SELECT * FROM tbl", primitive.ProtocolVersion4), "ks2"),
+		newPrepareCoalescingKey("origin2/target/", prepare("SELECT * FROM tbl", primitive.ProtocolVersion4), "ks"),
+	} {
+		otherCall, leader := coalescer.join(otherKey)
+		require.True(t, leader)
+		coalescer.complete(otherCall, nil)
+	}
+
+	response, err := defaultCodec.ConvertToRawFrame(frame.NewFrame(
+		primitive.ProtocolVersion4, 1, &message.PreparedResult{PreparedQueryId: []byte("id")}))
+	require.Nil(t, err)
+	waitResult := make(chan *frame.RawFrame, 1)
+	go func() {
+		waitResult <- followerCall.wait(context.Background(), time.Minute)
+	}()
+	coalescer.complete(leaderCall, response)
+	require.Equal(t, response, <-waitResult)
+
+	// the completed call is not joined anymore and completing it again has no effect
+	newCall, leader := coalescer.join(key)
+	require.True(t, leader)
+	coalescer.complete(leaderCall, nil)
+	sameCall, leader := coalescer.join(key)
+	require.False(t, leader)
+	require.Same(t, newCall, sameCall)
+
+	// only PREPARED results are shared
+	errorResponse, err := defaultCodec.ConvertToRawFrame(frame.NewFrame(
+		primitive.ProtocolVersion4, 1, &message.Overloaded{ErrorMessage: "overloaded"}))
+	require.Nil(t, err)
+	coalescer.complete(newCall, errorResponse)
+	require.Nil(t, newCall.wait(context.Background(), time.Minute))
+
+	timedOutCall, _ := coalescer.join(key)
+	require.Nil(t, timedOutCall.wait(context.Background(), 10*time.Millisecond))
+	coalescer.complete(timedOutCall, response)
+
+	var disabled *prepareCoalescer
+	disabled.complete(leaderCall, response)
+	coalescer.complete(nil, response)
+}
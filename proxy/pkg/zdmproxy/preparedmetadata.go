@@ -0,0 +1,111 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	"strings"
+)
+
+const (
+	preparedMetadataVariable     = "bound variable"
+	preparedMetadataResultColumn = "result column"
+)
+
+// preparedMetadataMismatch is a column of a prepared statement whose type is not the same on Origin and Target, e.g.
+// a UDT with a field that only exists on one cluster or a list<int> that is a set<int> on the other cluster. A column
+// that only exists on one cluster has an empty type for the other cluster.
+type preparedMetadataMismatch struct {
+	kind       string // preparedMetadataVariable or preparedMetadataResultColumn
+	index      int
+	name       string
+	originType string
+	targetType string
+}
+
+func (recv *preparedMetadataMismatch) String() string {
+	describe := func(cqlType string) string {
+		if cqlType == "" {
+			return "missing"
+		}
+		return cqlType
+	}
+	return fmt.Sprintf("%v %d (%v): %v on %v, %v on %v", recv.kind, recv.index, recv.name,
+		describe(recv.originType), common.ClusterTypeOrigin, describe(recv.targetType), common.ClusterTypeTarget)
+}
+
+// comparePreparedMetadata compares the bound variables and the result columns that Origin and Target returned for the
+// same PREPARE. The writes (or reads) of a column with a mismatch would fail or return different values on one of the
+// clusters, so the schema drift is reported when the statement is prepared rather than when it's executed.
+func comparePreparedMetadata(
+	originResult *message.PreparedResult, targetResult *message.PreparedResult) []*preparedMetadataMismatch {
+	var originVariables, targetVariables, originColumns, targetColumns []*message.ColumnMetadata
+	if originResult.VariablesMetadata != nil {
+		originVariables = originResult.VariablesMetadata.Columns
+	}
+	if targetResult.VariablesMetadata != nil {
+		targetVariables = targetResult.VariablesMetadata.Columns
+	}
+	if originResult.ResultMetadata != nil {
+		originColumns = originResult.ResultMetadata.Columns
+	}
+	if targetResult.ResultMetadata != nil {
+		targetColumns = targetResult.ResultMetadata.Columns
+	}
+	mismatches := compareColumnsMetadata(preparedMetadataVariable, originVariables, targetVariables)
+	return append(mismatches, compareColumnsMetadata(preparedMetadataResultColumn, originColumns, targetColumns)...)
+}
+
+func compareColumnsMetadata(
+	kind string, originColumns []*message.ColumnMetadata, targetColumns []*message.ColumnMetadata) []*preparedMetadataMismatch {
+	count := len(originColumns)
+	if len(targetColumns) > count {
+		count = len(targetColumns)
+	}
+	var mismatches []*preparedMetadataMismatch
+	for i := 0; i < count; i++ {
+		mismatch := &preparedMetadataMismatch{kind: kind, index: i}
+		if i < len(originColumns) {
+			mismatch.name = originColumns[i].Name
+			mismatch.originType = columnTypeString(originColumns[i])
+		}
+		if i < len(targetColumns) {
+			if mismatch.name == "" {
+				mismatch.name = targetColumns[i].Name
+			}
+			mismatch.targetType = columnTypeString(targetColumns[i])
+		}
+		if !strings.EqualFold(mismatch.originType, mismatch.targetType) {
+			mismatches = append(mismatches, mismatch)
+		}
+	}
+	return mismatches
+}
+
+func columnTypeString(column *message.ColumnMetadata) string {
+	if column.Type == nil {
+		return ""
+	}
+	return column.Type.String()
+}
+
+// checkPreparedMetadata logs and counts the mismatches of a statement that is prepared for the first time, the
+// following PREPAREs of the same statement (by the other client connections for example) are not checked again.
+func checkPreparedMetadata(
+	originResult *message.PreparedResult, targetResult *message.PreparedResult, query string,
+	proxyMetrics *metrics.ProxyMetrics) {
+	mismatches := comparePreparedMetadata(originResult, targetResult)
+	if len(mismatches) == 0 {
+		return
+	}
+	proxyMetrics.PreparedMetadataMismatches.Add(len(mismatches))
+	descriptions := make([]string, 0, len(mismatches))
+	for _, mismatch := range mismatches {
+		descriptions = append(descriptions, mismatch.String())
+	}
+	log.Warnf("The metadata of the prepared statement %v is not the same on %v and %v, its executions are likely to "+
+		"fail or to return different results on one of the clusters: %v.", redactedQuery(query),
+		common.ClusterTypeOrigin, common.ClusterTypeTarget, strings.Join(descriptions, "; "))
+}
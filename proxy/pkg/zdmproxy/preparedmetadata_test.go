@@ -0,0 +1,89 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/datatype"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestComparePreparedMetadata(t *testing.T) {
+	newAddress := func(fieldTypes ...datatype.DataType) datatype.DataType {
+		udt, err := datatype.NewUserDefinedType("ks", "address", []string{"street", "zip"}[:len(fieldTypes)], fieldTypes)
+		require.Nil(t, err)
+		return udt
+	}
+	newResult := func(variables []*message.ColumnMetadata, columns []*message.ColumnMetadata) *message.PreparedResult {
+		return &message.PreparedResult{
+			PreparedQueryId:   []byte("id"),
+			VariablesMetadata: &message.VariablesMetadata{Columns: variables},
+			ResultMetadata:    &message.RowsMetadata{ColumnCount: int32(len(columns)), Columns: columns},
+		}
+	}
+	column := func(name string, dataType datatype.DataType) *message.ColumnMetadata {
+		return &message.ColumnMetadata{Keyspace: "ks", Table: "tbl", Name: name, Type: dataType}
+	}
+
+	tests := []struct {
+		name       string
+		origin     *message.PreparedResult
+		target     *message.PreparedResult
+		mismatches []string
+	}{
+		{
+			name: "same metadata",
+			origin: newResult(
+				[]*message.ColumnMetadata{column("pk", datatype.Int), column("v", newAddress(datatype.Varchar, datatype.Int))},
+				[]*message.ColumnMetadata{column("v", datatype.NewListType(datatype.Int))}),
+			target: newResult(
+				[]*message.ColumnMetadata{column("pk", datatype.Int), column("v", newAddress(datatype.Varchar, datatype.Int))},
+				[]*message.ColumnMetadata{column("v", datatype.NewListType(datatype.Int))}),
+		},
+		{
+			name:   "no metadata",
+			origin: &message.PreparedResult{PreparedQueryId: []byte("id")},
+			target: &message.PreparedResult{PreparedQueryId: []byte("id")},
+		},
+		{
+			name: "udt field missing on target",
+			origin: newResult(
+				[]*message.ColumnMetadata{column("pk", datatype.Int), column("v", newAddress(datatype.Varchar, datatype.Int))}, nil),
+			target: newResult(
+				[]*message.ColumnMetadata{column("pk", datatype.Int), column("v", newAddress(datatype.Varchar))}, nil),
+			mismatches: []string{
+				"bound variable 1 (v): ks.address<street:varchar,zip:int> on ORIGIN, ks.address<street:varchar> on TARGET"},
+		},
+		{
+			name: "collection and primitive types",
+			origin: newResult(
+				[]*message.ColumnMetadata{column("pk", datatype.Int)},
+				[]*message.ColumnMetadata{column("l", datatype.NewListType(datatype.Int)), column("n", datatype.Bigint)}),
+			target: newResult(
+				[]*message.ColumnMetadata{column("pk", datatype.Int)},
+				[]*message.ColumnMetadata{column("l", datatype.NewSetType(datatype.Int)), column("n", datatype.Int)}),
+			mismatches: []string{
+				"result column 0 (l): list<int> on ORIGIN, set<int> on TARGET",
+				"result column 1 (n): bigint on ORIGIN, int on TARGET"},
+		},
+		{
+			name:   "columns missing on one cluster",
+			origin: newResult([]*message.ColumnMetadata{column("pk", datatype.Int)}, nil),
+			target: newResult(
+				[]*message.ColumnMetadata{column("pk", datatype.Int), column("ck", datatype.Int)},
+				[]*message.ColumnMetadata{column("v", datatype.Int)}),
+			mismatches: []string{
+				"bound variable 1 (ck): missing on ORIGIN, int on TARGET",
+				"result column 0 (v): missing on ORIGIN, int on TARGET"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mismatches []string
+			for _, mismatch := range comparePreparedMetadata(tt.origin, tt.target) {
+				mismatches = append(mismatches, mismatch.String())
+			}
+			require.Equal(t, tt.mismatches, mismatches)
+		})
+	}
+}
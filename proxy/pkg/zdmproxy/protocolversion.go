@@ -0,0 +1,190 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+)
+
+const protocolVersionsOption = "PROTOCOL_VERSIONS"
+
+// createUnsupportedProtocolVersionResponse returns a copy of the PROTOCOL_ERROR that a cluster returned because it
+// doesn't support the protocol version requested by the client, with an error message that has the same format as the
+// one of Apache Cassandra and lists the protocol versions that both clusters and the proxy support. Not every cluster
+// includes the supported versions in the error (and they could differ between the clusters) so clients would
+// otherwise downgrade one version at a time or pick a version that the other cluster doesn't support.
+//
+// It returns nil if the error is not about the protocol version or if the supported versions are not known.
+func (ch *ClientHandler) createUnsupportedProtocolVersionResponse(response *frame.RawFrame, errMsg message.Error) *frame.RawFrame {
+	protocolErr, ok := errMsg.(*message.ProtocolError)
+	if !ok || !strings.Contains(strings.ToLower(protocolErr.ErrorMessage), "unsupported protocol version") {
+		return nil
+	}
+
+	supportedVersions := getSupportedProtocolVersions(limitProtocolVersions(mergeSupportedOptions(
+		ch.originControlConn.GetSupportedOptions(), ch.targetControlConn.GetSupportedOptions()), ch.maxProtocolVersion))
+	if len(supportedVersions) == 0 {
+		return nil
+	}
+
+	errorMessage := "Invalid or unsupported protocol version"
+	if requestedVersion := parseRequestedProtocolVersion(protocolErr.ErrorMessage); requestedVersion != "" {
+		errorMessage = fmt.Sprintf("%v (%v)", errorMessage, requestedVersion)
+	}
+	errorMessage = fmt.Sprintf("%v; supported versions are (%v)", errorMessage, strings.Join(supportedVersions, ", "))
+	newResponse, err := defaultCodec.ConvertToRawFrame(frame.NewFrame(
+		response.Header.Version, response.Header.StreamId, &message.ProtocolError{ErrorMessage: errorMessage}))
+	if err != nil {
+		log.Errorf("Could not convert protocol error response to raw frame, forwarding the original error: %v", err)
+		return nil
+	}
+	return newResponse
+}
+
+// parseRequestedProtocolVersion extracts the protocol version from the error message of the cluster, e.g.
+// "Invalid or unsupported protocol version (5); supported versions are (3/v3, 4/v4)" or
+// "Invalid or unsupported protocol version: 5". It returns an empty string if the message doesn't contain it.
+func parseRequestedProtocolVersion(errorMessage string) string {
+	start := strings.Index(strings.ToLower(errorMessage), "protocol version") + len("protocol version")
+	end := start
+	for end < len(errorMessage) && strings.ContainsRune(" :(", rune(errorMessage[end])) {
+		end++
+	}
+	start = end
+	for end < len(errorMessage) && errorMessage[end] >= '0' && errorMessage[end] <= '9' {
+		end++
+	}
+	return errorMessage[start:end]
+}
+
+// getSupportedProtocolVersions returns the PROTOCOL_VERSIONS values of the provided SUPPORTED options (e.g. 4/v4) of
+// the protocol versions that the proxy also supports.
+func getSupportedProtocolVersions(options map[string][]string) []string {
+	var versions []string
+	for _, value := range options[protocolVersionsOption] {
+		version, err := strconv.Atoi(strings.SplitN(value, "/", 2)[0])
+		if err != nil || version < 0 || version > 0xFF {
+			continue
+		}
+		protocolVersion := primitive.ProtocolVersion(version)
+		if !protocolVersion.IsSupported() || checkProtocolVersion(protocolVersion) != nil {
+			continue
+		}
+		versions = append(versions, value)
+	}
+	return versions
+}
+
+// protocolVersionRanks orders the protocol versions that ZDM_MAX_CLIENT_PROTOCOL_VERSION accepts, the DSE versions
+// are extensions of v4.
+var protocolVersionRanks = map[primitive.ProtocolVersion]int{
+	primitive.ProtocolVersion2:    2,
+	primitive.ProtocolVersion3:    3,
+	primitive.ProtocolVersion4:    4,
+	primitive.ProtocolVersionDse1: 5,
+	primitive.ProtocolVersionDse2: 6,
+}
+
+// exceedsMaxProtocolVersion returns true if version is higher than maxVersion, false if maxVersion is 0.
+func exceedsMaxProtocolVersion(version primitive.ProtocolVersion, maxVersion primitive.ProtocolVersion) bool {
+	if maxVersion == 0 {
+		return false
+	}
+	rank, ok := protocolVersionRanks[version]
+	if !ok {
+		// v1, v5 and the next versions
+		return version >= primitive.ProtocolVersion5 && !version.IsDse()
+	}
+	return rank > protocolVersionRanks[maxVersion]
+}
+
+// limitProtocolVersions removes the PROTOCOL_VERSIONS values (e.g. 66/dse-v2) that exceed maxVersion from the
+// SUPPORTED options so that drivers don't try to negotiate them.
+func limitProtocolVersions(options map[string][]string, maxVersion primitive.ProtocolVersion) map[string][]string {
+	values, ok := options[protocolVersionsOption]
+	if !ok || maxVersion == 0 {
+		return options
+	}
+	allowedValues := make([]string, 0, len(values))
+	for _, value := range values {
+		version, err := strconv.Atoi(strings.SplitN(value, "/", 2)[0])
+		if err == nil && version >= 0 && version <= 0xFF &&
+			exceedsMaxProtocolVersion(primitive.ProtocolVersion(version), maxVersion) {
+			continue
+		}
+		allowedValues = append(allowedValues, value)
+	}
+	options[protocolVersionsOption] = allowedValues
+	return options
+}
+
+// betaProtocolSupport holds the protocol versions that each cluster only supports as beta versions, i.e. with the
+// USE_BETA header flag, according to the PROTOCOL_VERSIONS of their SUPPORTED options (e.g. 5/v5-beta).
+//
+// Drivers set USE_BETA on every frame when they are configured to allow beta versions, whatever the version they
+// negotiated. The clusters don't handle the flag of a version that is not beta the same way (Apache Cassandra ignores
+// it while other implementations reject the frame), so the flag is only forwarded with a beta version of both
+// clusters. A beta version of a single cluster gets a PROTOCOL_ERROR that names the cluster so that the driver
+// negotiates another version, instead of a failure of the other cluster in the middle of the handshake.
+type betaProtocolSupport struct {
+	origin map[primitive.ProtocolVersion]bool
+	target map[primitive.ProtocolVersion]bool
+}
+
+func newBetaProtocolSupport(originOptions map[string][]string, targetOptions map[string][]string) *betaProtocolSupport {
+	return &betaProtocolSupport{
+		origin: getBetaProtocolVersions(originOptions),
+		target: getBetaProtocolVersions(targetOptions),
+	}
+}
+
+func getBetaProtocolVersions(options map[string][]string) map[primitive.ProtocolVersion]bool {
+	versions := map[primitive.ProtocolVersion]bool{}
+	for _, value := range options[protocolVersionsOption] {
+		parts := strings.SplitN(value, "/", 2)
+		if len(parts) != 2 || !strings.HasSuffix(strings.ToLower(parts[1]), "-beta") {
+			continue
+		}
+		version, err := strconv.Atoi(parts[0])
+		if err == nil && version >= 0 && version <= 0xFF {
+			versions[primitive.ProtocolVersion(version)] = true
+		}
+	}
+	return versions
+}
+
+// checkRequest removes the USE_BETA flag of a request whose protocol version is not a beta version of both clusters,
+// or returns a PROTOCOL_ERROR response if it's a beta version of only one cluster.
+func (s *betaProtocolSupport) checkRequest(f *frame.RawFrame) (*frame.RawFrame, error) {
+	if s == nil || !f.Header.Flags.Contains(primitive.HeaderFlagUseBeta) {
+		return nil, nil
+	}
+	version := f.Header.Version
+	betaOnOrigin, betaOnTarget := s.origin[version], s.target[version]
+	if betaOnOrigin && betaOnTarget {
+		return nil, nil
+	}
+	if !betaOnOrigin && !betaOnTarget {
+		if f.Header.OpCode == primitive.OpCodeStartup {
+			log.Debugf("Removing the USE_BETA flag of the requests with %v because it's not a beta version of %v "+
+				"and %v.", version, common.ClusterTypeOrigin, common.ClusterTypeTarget)
+		}
+		f.Header.Flags = f.Header.Flags.Remove(primitive.HeaderFlagUseBeta)
+		return nil, nil
+	}
+
+	betaCluster, otherCluster := common.ClusterTypeOrigin, common.ClusterTypeTarget
+	if betaOnTarget {
+		betaCluster, otherCluster = common.ClusterTypeTarget, common.ClusterTypeOrigin
+	}
+	log.Debugf("%v is a beta version of %v only, returning a protocol error to the client to force a downgrade.",
+		version, betaCluster)
+	return generateProtocolErrorResponseFrame(f.Header.StreamId, &message.ProtocolError{ErrorMessage: fmt.Sprintf(
+		"Beta version of the protocol used (%d/v%d-beta) is only supported by %v, %v doesn't support it",
+		version, version, betaCluster, otherCluster)})
+}
@@ -0,0 +1,175 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+func TestGetSupportedProtocolVersions(t *testing.T) {
+	require.Nil(t, getSupportedProtocolVersions(map[string][]string{}))
+	require.Equal(t, []string{"3/v3", "4/v4"}, getSupportedProtocolVersions(map[string][]string{
+		protocolVersionsOption: {"3/v3", "4/v4", "5/v5", "6/v6-beta", "invalid"},
+	}))
+}
+
+func TestParseRequestedProtocolVersion(t *testing.T) {
+	require.Equal(t, "5", parseRequestedProtocolVersion("Invalid or unsupported protocol version (5); supported versions are (3/v3, 4/v4)"))
+	require.Equal(t, "4", parseRequestedProtocolVersion("Invalid or unsupported protocol version: 4"))
+	require.Equal(t, "", parseRequestedProtocolVersion("Invalid or unsupported protocol version"))
+}
+
+func TestCreateUnsupportedProtocolVersionResponse(t *testing.T) {
+	newControlConn := func(options map[string][]string) *ControlConn {
+		return &ControlConn{topologyLock: &sync.RWMutex{}, supportedOptions: options}
+	}
+	originOptions := map[string][]string{protocolVersionsOption: {"3/v3", "4/v4", "5/v5"}}
+	targetOptions := map[string][]string{protocolVersionsOption: {"3/v3"}}
+
+	tests := []struct {
+		name            string
+		originOptions   map[string][]string
+		targetOptions   map[string][]string
+		errMsg          message.Error
+		expectedMessage string
+	}{
+		{"supported versions known", originOptions, targetOptions,
+			&message.ProtocolError{ErrorMessage: "Invalid or unsupported protocol version: 4"},
+			"Invalid or unsupported protocol version (4); supported versions are (3/v3)"},
+		{"supported versions unknown", nil, targetOptions,
+			&message.ProtocolError{ErrorMessage: "Invalid or unsupported protocol version: 4"}, ""},
+		{"other protocol error", originOptions, targetOptions,
+			&message.ProtocolError{ErrorMessage: "Unknown compression algorithm"}, ""},
+		{"server error", originOptions, targetOptions,
+			&message.ServerError{ErrorMessage: "Invalid or unsupported protocol version: 4"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := &ClientHandler{originControlConn: newControlConn(tt.originOptions), targetControlConn: newControlConn(tt.targetOptions)}
+			clusterResponse, err := defaultCodec.ConvertToRawFrame(frame.NewFrame(primitive.ProtocolVersion3, 3, tt.errMsg))
+			require.Nil(t, err)
+			response := ch.createUnsupportedProtocolVersionResponse(clusterResponse, tt.errMsg)
+			if tt.expectedMessage == "" {
+				require.Nil(t, response)
+				return
+			}
+			decodedResponse, err := defaultCodec.ConvertFromRawFrame(response)
+			require.Nil(t, err)
+			require.Equal(t, primitive.ProtocolVersion3, decodedResponse.Header.Version)
+			require.Equal(t, int16(3), decodedResponse.Header.StreamId)
+			require.Equal(t, &message.ProtocolError{ErrorMessage: tt.expectedMessage}, decodedResponse.Body.Message)
+		})
+	}
+}
+
+func TestExceedsMaxProtocolVersion(t *testing.T) {
+	tests := []struct {
+		name       string
+		version    primitive.ProtocolVersion
+		maxVersion primitive.ProtocolVersion
+		expected   bool
+	}{
+		{"not pinned", primitive.ProtocolVersionDse2, 0, false},
+		{"same version", primitive.ProtocolVersion4, primitive.ProtocolVersion4, false},
+		{"lower version", primitive.ProtocolVersion3, primitive.ProtocolVersion4, false},
+		{"higher version", primitive.ProtocolVersion4, primitive.ProtocolVersion3, true},
+		{"dse version above v4", primitive.ProtocolVersionDse1, primitive.ProtocolVersion4, true},
+		{"v4 below dse version", primitive.ProtocolVersion4, primitive.ProtocolVersionDse1, false},
+		{"higher dse version", primitive.ProtocolVersionDse2, primitive.ProtocolVersionDse1, true},
+		{"v5", primitive.ProtocolVersion5, primitive.ProtocolVersionDse2, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, exceedsMaxProtocolVersion(tt.version, tt.maxVersion))
+		})
+	}
+}
+
+func TestLimitProtocolVersions(t *testing.T) {
+	newOptions := func() map[string][]string {
+		return map[string][]string{
+			protocolVersionsOption: {"3/v3", "4/v4", "5/v5-beta", "65/dse-v1", "66/dse-v2"},
+			"COMPRESSION":          {"lz4"},
+		}
+	}
+	require.Equal(t, newOptions(), limitProtocolVersions(newOptions(), 0))
+	require.Equal(t, map[string][]string{
+		protocolVersionsOption: {"3/v3", "4/v4"},
+		"COMPRESSION":          {"lz4"},
+	}, limitProtocolVersions(newOptions(), primitive.ProtocolVersion4))
+	require.Equal(t, map[string][]string{
+		protocolVersionsOption: {"3/v3", "4/v4", "65/dse-v1"},
+		"COMPRESSION":          {"lz4"},
+	}, limitProtocolVersions(newOptions(), primitive.ProtocolVersionDse1))
+}
+
+func TestCheckProtocolError_MaxProtocolVersion(t *testing.T) {
+	request := &frame.RawFrame{Header: &frame.Header{
+		Version: primitive.ProtocolVersion4, StreamId: 7, OpCode: primitive.OpCodeStartup}}
+
+	response, err, _ := checkProtocolError(request, nil, false, 0, ClientConnectorLogPrefix)
+	require.Nil(t, err)
+	require.Nil(t, response)
+	response, err, _ = checkProtocolError(request, nil, false, primitive.ProtocolVersion4, ClientConnectorLogPrefix)
+	require.Nil(t, err)
+	require.Nil(t, response)
+
+	response, err, errCode := checkProtocolError(request, nil, false, primitive.ProtocolVersion3, ClientConnectorLogPrefix)
+	require.Nil(t, err)
+	require.Equal(t, int8(ProtocolErrorUnsupportedVersion), errCode)
+	decodedResponse, err := defaultCodec.ConvertFromRawFrame(response)
+	require.Nil(t, err)
+	require.Equal(t, int16(7), decodedResponse.Header.StreamId)
+	require.Equal(t, &message.ProtocolError{ErrorMessage: "Invalid or unsupported protocol version (4)"},
+		decodedResponse.Body.Message)
+}
+
+func TestBetaProtocolSupport_CheckRequest(t *testing.T) {
+	newRequest := func(version primitive.ProtocolVersion, flags primitive.HeaderFlag) *frame.RawFrame {
+		return &frame.RawFrame{Header: &frame.Header{
+			Version: version, Flags: flags, StreamId: 3, OpCode: primitive.OpCodeStartup}}
+	}
+	support := newBetaProtocolSupport(
+		map[string][]string{protocolVersionsOption: {"3/v3", "4/v4", "5/v5-beta", "65/dse-v1", "invalid/v6-beta"}},
+		map[string][]string{protocolVersionsOption: {"3/v3", "4/v4", "5/v5-BETA", "6/v6-beta"}})
+	require.Equal(t, map[primitive.ProtocolVersion]bool{primitive.ProtocolVersion5: true}, support.origin)
+
+	// the flag of a version that isn't beta on any cluster is removed
+	request := newRequest(primitive.ProtocolVersion4, primitive.HeaderFlagUseBeta|primitive.HeaderFlagTracing)
+	response, err := support.checkRequest(request)
+	require.Nil(t, err)
+	require.Nil(t, response)
+	require.Equal(t, primitive.HeaderFlagTracing, request.Header.Flags)
+
+	// the flag of a beta version of both clusters is kept
+	request = newRequest(primitive.ProtocolVersion5, primitive.HeaderFlagUseBeta)
+	response, err = support.checkRequest(request)
+	require.Nil(t, err)
+	require.Nil(t, response)
+	require.Equal(t, primitive.HeaderFlagUseBeta, request.Header.Flags)
+
+	// a beta version of a single cluster is rejected
+	response, err = support.checkRequest(newRequest(primitive.ProtocolVersion(6), primitive.HeaderFlagUseBeta))
+	require.Nil(t, err)
+	require.NotNil(t, response)
+	require.Equal(t, int16(3), response.Header.StreamId)
+	decoded, err := defaultCodec.ConvertFromRawFrame(response)
+	require.Nil(t, err)
+	require.Equal(t, &message.ProtocolError{
+		ErrorMessage: "Beta version of the protocol used (6/v6-beta) is only supported by TARGET, ORIGIN doesn't support it"},
+		decoded.Body.Message)
+
+	// requests without the flag are not changed
+	request = newRequest(primitive.ProtocolVersion4, primitive.HeaderFlagTracing)
+	response, err = support.checkRequest(request)
+	require.Nil(t, err)
+	require.Nil(t, response)
+	require.Equal(t, primitive.HeaderFlagTracing, request.Header.Flags)
+	response, err = support.checkRequest(newRequest(primitive.ProtocolVersion(6), 0))
+	require.Nil(t, err)
+	require.Nil(t, response)
+}
@@ -5,16 +5,21 @@ import (
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
 	"github.com/datastax/zdm-proxy/proxy/pkg/common"
 	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"github.com/datastax/zdm-proxy/proxy/pkg/journal"
 	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
 	"github.com/datastax/zdm-proxy/proxy/pkg/metrics/noopmetrics"
 	"github.com/datastax/zdm-proxy/proxy/pkg/metrics/prommetrics"
+	"github.com/datastax/zdm-proxy/proxy/pkg/mutationexport"
+	"github.com/datastax/zdm-proxy/proxy/pkg/sharedconfig"
 	"github.com/jpillora/backoff"
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 	"math/rand"
 	"net"
+	"os"
 	"runtime"
 	"sync"
 	"sync/atomic"
@@ -36,6 +41,89 @@ type ZdmProxy struct {
 	readMode          common.ReadMode
 	systemQueriesMode common.SystemQueriesMode
 
+	originCompatibilityProfile common.CompatibilityProfile
+	targetCompatibilityProfile common.CompatibilityProfile
+
+	originCredentials *AuthCredentials
+	targetCredentials *AuthCredentials
+
+	clientCredentialStore ClientCredentialStore
+	roleMapping           roleMapping
+	clientAddressFilter   *clientAddressFilter
+
+	requestInterceptors []RequestInterceptor
+	statementRules      *statementRules
+
+	startupStrippedOptions []string
+	maxProtocolVersion     primitive.ProtocolVersion
+	eventsSource           common.EventsSource
+	scrubbedErrorDetails   []common.ScrubbedErrorDetail
+
+	memoryPressureMonitor *memoryPressureMonitor
+	requestRateLimiter    *requestRateLimiter
+
+	// nil if ZDM_PROXY_GOROUTINE_AUDIT_INTERVAL_MS is 0
+	goroutineAudit *goroutineAudit
+
+	globalRequestRateLimiter *globalRequestRateLimiter
+
+	connectionStormLimiter *connectionStormLimiter
+
+	// nil unless ZDM_STARTUP_POLICY is REFUSE_CONNECTIONS, see RunWithRetriesAndExtensions
+	startupGate *startupGate
+
+	// nil unless ZDM_TARGET_DOWN_POLICY is ORIGIN_ONLY
+	originOnlyFallback *originOnlyFallback
+	// nil unless the origin-only journal has a maximum size or age
+	journalCompactor *journalCompactor
+
+	// nil unless ZDM_TARGET_WRITE_PIPELINE is KAFKA
+	targetWritePipeline *targetWritePipeline
+
+	// nil unless a ZDM_GUARDRAIL_* threshold is set
+	guardrails *guardrails
+
+	// nil unless ZDM_PAGE_SIZE_MAX or ZDM_PAGE_SIZE_DEFAULT is set
+	pageSizeOverride *pageSizeOverride
+
+	// nil unless ZDM_SECONDARY_ERROR_POLICY is set
+	secondaryErrorPolicy *secondaryErrorPolicy
+
+	// nil unless ZDM_DUAL_WRITE_RESPONSE_POLICY is set
+	dualWriteResponsePolicy *dualWriteResponsePolicy
+
+	// nil unless ZDM_LWT_METRICS_ENABLED or a ZDM_*_LWT_SERIAL_CONSISTENCY is set
+	lwtHandler *lwtHandler
+
+	sharedConfigWatcher   *sharedconfig.Watcher
+	sharedConfigPublisher *sharedconfig.Publisher
+	leaderElector         *sharedconfig.LeaderElector
+	autoCutover           *autoCutoverController
+	sharedConfigCtx       context.Context
+	sharedConfigCancelFn  context.CancelFunc
+	sharedConfigWg        *sync.WaitGroup
+
+	tlsConfigReloaders []*tlsConfigReloader
+
+	tokenRangeRouter      *tokenRangeRouter
+	migrationStatusRouter *migrationStatusRouter
+	viewIndexTracker      *viewIndexTracker
+	clockSkewMonitor      *clockSkewMonitor
+	prepareCoalescer      *prepareCoalescer
+	targetWriteSampler    *targetWriteSampler
+	faultInjector         *FaultInjector
+	frameCaptures         *frameCaptureRegistry
+	clientSessions        *clientSessionRegistry
+	frameExporter         *FrameExporter
+	heavyHitters          *heavyHitterTracker
+	statementMetrics      *statementMetricsTracker
+	payloadSizeMetrics    *payloadSizeMetrics
+
+	requestWriteQueueOverflowPolicy common.QueueOverflowPolicy
+
+	originConnectionCompression common.ConnectionCompression
+	targetConnectionCompression common.ConnectionCompression
+
 	proxyRand *rand.Rand
 
 	lock *sync.RWMutex
@@ -76,12 +164,31 @@ type ZdmProxy struct {
 	clientHandlersShutdownRequestCancelFn context.CancelFunc
 	globalClientHandlersWg                *sync.WaitGroup
 
+	// canceled to drain the client connections that were created with the previous routing, see drainClientConnections
+	clientHandlersDrainRequestCtx      context.Context
+	clientHandlersDrainRequestCancelFn context.CancelFunc
+	clusterRolesSwappedAt              string
+
 	metricHandler *metrics.MetricHandler
+
+	extensions    *Extensions
+	wasmQueryHook *wasmQueryHook
+
+	mutationPublisher mutationexport.Publisher
 }
 
 func NewZdmProxy(conf *config.Config) (*ZdmProxy, error) {
+	return NewZdmProxyWithExtensions(conf, nil)
+}
+
+// NewZdmProxyWithExtensions creates a proxy that uses the provided custom components, extensions can be nil.
+func NewZdmProxyWithExtensions(conf *config.Config, extensions *Extensions) (*ZdmProxy, error) {
+	if extensions == nil {
+		extensions = &Extensions{}
+	}
 	zdmProxy := &ZdmProxy{
-		Conf: conf,
+		Conf:       conf,
+		extensions: extensions,
 	}
 	err := zdmProxy.initializeGlobalStructures()
 	if err != nil {
@@ -119,8 +226,39 @@ func (p *ZdmProxy) Start(ctx context.Context) error {
 		return fmt.Errorf("could not initialize proxy TLS configuration: %w", err)
 	}
 
+	if p.Conf.WasmHookPath != "" {
+		wasmQueryHook, err := newWasmQueryHook(ctx, p.Conf.WasmHookPath)
+		if err != nil {
+			return err
+		}
+		p.lock.Lock()
+		p.wasmQueryHook = wasmQueryHook
+		p.lock.Unlock()
+	}
+
+	if p.sharedConfigWatcher != nil {
+		err = p.startSharedConfigWatcher(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
 	var serverSideTlsConfig *tls.Config
-	if p.proxyTlsConfig.TlsEnabled {
+	if p.proxyTlsConfig.TlsEnabled && p.Conf.TlsReloadIntervalMs > 0 {
+		var tlsReloader *tlsConfigReloader
+		tlsReloader, err = newTlsConfigReloader(
+			"client connections",
+			[]string{p.proxyTlsConfig.ProxyCaPath, p.proxyTlsConfig.ProxyCertPath, p.proxyTlsConfig.ProxyKeyPath},
+			func() (*tls.Config, error) {
+				return getServerSideTlsConfigFromProxyClusterTlsConfig(p.proxyTlsConfig)
+			})
+
+		if err != nil {
+			return fmt.Errorf("could not create server side tls.Config object: %w", err)
+		}
+		p.startTlsConfigReloader(tlsReloader)
+		serverSideTlsConfig = tlsReloader.serverSideTlsConfig()
+	} else if p.proxyTlsConfig.TlsEnabled {
 		serverSideTlsConfig, err = getServerSideTlsConfigFromProxyClusterTlsConfig(p.proxyTlsConfig)
 
 		if err != nil {
@@ -135,11 +273,65 @@ func (p *ZdmProxy) Start(ctx context.Context) error {
 		return err
 	}
 
+	err = p.initializeMutationPublisher()
+	if err != nil {
+		return err
+	}
+
+	err = p.initializeGuardrails()
+	if err != nil {
+		return err
+	}
+
+	err = p.initializePageSizeOverride()
+	if err != nil {
+		return err
+	}
+
+	err = p.initializeSecondaryErrorPolicy()
+	if err != nil {
+		return err
+	}
+
+	err = p.initializeDualWriteResponsePolicy()
+	if err != nil {
+		return err
+	}
+
+	err = p.initializeLwtHandler()
+	if err != nil {
+		return err
+	}
+
+	err = p.initializeTargetWriteSampler()
+	if err != nil {
+		return err
+	}
+
+	if p.Conf.PrepareCoalescingEnabled {
+		p.prepareCoalescer = newPrepareCoalescer(p.metricHandler.GetProxyMetrics())
+	}
+
 	err = p.initializeControlConnections(ctx)
 	if err != nil {
 		return err
 	}
 
+	err = p.initializeOriginOnlyFallback()
+	if err != nil {
+		return err
+	}
+
+	err = p.initializeTargetWritePipeline()
+	if err != nil {
+		return err
+	}
+
+	err = p.initializeReadRepair()
+	if err != nil {
+		return err
+	}
+
 	originHosts, err := p.originControlConn.GetHostsInLocalDatacenter()
 	if err != nil {
 		return fmt.Errorf("failed to initialize proxy, could not get origin orderedHostsInLocalDc: %w", err)
@@ -166,6 +358,89 @@ func (p *ZdmProxy) Start(ctx context.Context) error {
 	log.Infof("Initialized target control connection. Cluster Name: %v, Hosts: %v, Assigned Hosts: %v.",
 		p.targetControlConn.GetClusterName(), targetHosts, targetAssignedHosts)
 
+	p.runStartupSchemaCheck(ctx)
+
+	migrationStatusTable, err := p.Conf.ParseMigrationStatusTable()
+	if err != nil {
+		return err
+	}
+	if migrationStatusTable != "" {
+		migrationStatusRouter := newMigrationStatusRouter(
+			migrationStatusTable, p.targetControlConn.Query, p.Conf.RoutingHintsEnabled)
+		err = migrationStatusRouter.refresh(ctx)
+		if err != nil {
+			log.Warnf("Could not read the migration status, reads are sent to the primary cluster until "+
+				"the next successful refresh: %v", err)
+		}
+		migrationStatusRouter.Start(time.Duration(p.Conf.MigrationStatusRefreshIntervalMs) * time.Millisecond)
+		p.lock.Lock()
+		p.migrationStatusRouter = migrationStatusRouter
+		p.lock.Unlock()
+	}
+
+	viewIndexCheckInterval, err := p.Conf.ParseViewIndexCheckRefreshInterval()
+	if err != nil {
+		return err
+	}
+	if viewIndexCheckInterval > 0 {
+		viewIndexTracker := newViewIndexTracker(
+			p.originControlConn.Query, p.targetControlConn.Query, p.metricHandler.GetProxyMetrics())
+		err = viewIndexTracker.refresh(ctx)
+		if err != nil {
+			log.Warnf("Could not compare the materialized views and secondary indexes of the clusters, the writes "+
+				"are not checked until the next successful refresh: %v", err)
+		}
+		viewIndexTracker.Start(viewIndexCheckInterval)
+		p.lock.Lock()
+		p.viewIndexTracker = viewIndexTracker
+		p.lock.Unlock()
+	}
+
+	clockSkewCheckConfig, err := p.Conf.ParseClockSkewCheckConfig()
+	if err != nil {
+		return err
+	}
+	if clockSkewCheckConfig.Enabled() {
+		clockSkewMonitor := newClockSkewMonitor(
+			p.originControlConn.Query, p.targetControlConn.Query, clockSkewCheckConfig, p.metricHandler.GetProxyMetrics())
+		err = clockSkewMonitor.probe(ctx)
+		if err != nil {
+			log.Warnf("Could not compare the clocks of the clusters, retrying in %v: %v", clockSkewCheckConfig.Interval, err)
+		}
+		clockSkewMonitor.Start()
+		p.lock.Lock()
+		p.clockSkewMonitor = clockSkewMonitor
+		p.lock.Unlock()
+	}
+
+	if p.memoryPressureMonitor != nil {
+		p.memoryPressureMonitor.Start(time.Duration(p.Conf.ProxyMemoryCheckIntervalMs) * time.Millisecond)
+	}
+
+	goroutineAuditConfig, err := p.Conf.ParseGoroutineAuditConfig()
+	if err != nil {
+		return err
+	}
+	if goroutineAuditConfig.Enabled() {
+		goroutineAudit := newGoroutineAudit(goroutineAuditConfig, p.metricHandler.GetProxyMetrics())
+		goroutineAudit.Start()
+		p.lock.Lock()
+		p.goroutineAudit = goroutineAudit
+		p.lock.Unlock()
+	}
+
+	p.startFleetTasks()
+
+	if p.globalRequestRateLimiter != nil {
+		// the reserve is filled before the first client connects
+		p.globalRequestRateLimiter.refill(p.sharedConfigCtx)
+		p.sharedConfigWg.Add(1)
+		go func() {
+			defer p.sharedConfigWg.Done()
+			p.globalRequestRateLimiter.run(p.sharedConfigCtx)
+		}()
+	}
+
 	err = p.acceptConnectionsFromClients(p.Conf.ProxyListenAddress, p.Conf.ProxyListenPort, serverSideTlsConfig)
 	if err != nil {
 		return err
@@ -197,6 +472,15 @@ func (p *ZdmProxy) initializeControlConnections(ctx context.Context) error {
 		log.Infof("Parsed Origin contact points: %v", parsedOriginContactPoints)
 	}
 
+	originPeerPortMapping, err := p.Conf.ParseOriginPeerPortMapping()
+	if err != nil {
+		return err
+	}
+
+	if originPeerPortMapping != nil {
+		log.Infof("Parsed Origin peer port mapping: %v", originPeerPortMapping)
+	}
+
 	parsedTargetContactPoints, err := p.Conf.ParseTargetContactPoints()
 	if err != nil {
 		return err
@@ -206,19 +490,35 @@ func (p *ZdmProxy) initializeControlConnections(ctx context.Context) error {
 		log.Infof("Parsed Target contact points: %v", parsedTargetContactPoints)
 	}
 
+	targetPeerPortMapping, err := p.Conf.ParseTargetPeerPortMapping()
+	if err != nil {
+		return err
+	}
+
+	if targetPeerPortMapping != nil {
+		log.Infof("Parsed Target peer port mapping: %v", targetPeerPortMapping)
+	}
+
 	originTlsConfig, err := p.Conf.ParseOriginTlsConfig(true)
 	if err != nil {
 		return err
 	}
 
+	originTlsReloader, err := p.newClusterTlsConfigReloader(originTlsConfig, common.ClusterTypeOrigin)
+	if err != nil {
+		return fmt.Errorf("error initializing the TLS configuration for Origin: %w", err)
+	}
+
 	// Initialize origin connection configuration and control connection endpoint configuration
 	originConnectionConfig, err := InitializeConnectionConfig(originTlsConfig,
 		parsedOriginContactPoints,
 		p.Conf.OriginPort,
+		originPeerPortMapping,
 		p.Conf.OriginConnectionTimeoutMs,
 		common.ClusterTypeOrigin,
 		p.Conf.OriginLocalDatacenter,
-		ctx)
+		ctx,
+		originTlsReloader)
 	if err != nil {
 		return fmt.Errorf("error initializing the connection configuration or control connection for Origin: %w", err)
 	}
@@ -232,14 +532,21 @@ func (p *ZdmProxy) initializeControlConnections(ctx context.Context) error {
 		return err
 	}
 
+	targetTlsReloader, err := p.newClusterTlsConfigReloader(targetTlsConfig, common.ClusterTypeTarget)
+	if err != nil {
+		return fmt.Errorf("error initializing the TLS configuration for Target: %w", err)
+	}
+
 	// Initialize target connection configuration and control connection endpoint configuration
 	targetConnectionConfig, err := InitializeConnectionConfig(targetTlsConfig,
 		parsedTargetContactPoints,
 		p.Conf.TargetPort,
+		targetPeerPortMapping,
 		p.Conf.TargetConnectionTimeoutMs,
 		common.ClusterTypeTarget,
 		p.Conf.TargetLocalDatacenter,
-		ctx)
+		ctx,
+		targetTlsReloader)
 	if err != nil {
 		return fmt.Errorf("error initializing the connection configuration or control connection for Target: %w", err)
 	}
@@ -249,7 +556,7 @@ func (p *ZdmProxy) initializeControlConnections(ctx context.Context) error {
 
 	originControlConn := NewControlConn(
 		p.controlConnShutdownCtx, p.Conf.OriginPort, p.originConnectionConfig,
-		p.Conf.OriginUsername, p.Conf.OriginPassword, p.Conf, topologyConfig, p.proxyRand, p.metricHandler)
+		p.originCredentials.Username, p.originCredentials.Password, p.Conf, topologyConfig, p.proxyRand, p.metricHandler)
 
 	if err := originControlConn.Start(p.controlConnShutdownWg, ctx); err != nil {
 		return fmt.Errorf("failed to initialize origin control connection: %w", err)
@@ -261,7 +568,7 @@ func (p *ZdmProxy) initializeControlConnections(ctx context.Context) error {
 
 	targetControlConn := NewControlConn(
 		p.controlConnShutdownCtx, p.Conf.TargetPort, p.targetConnectionConfig,
-		p.Conf.TargetUsername, p.Conf.TargetPassword, p.Conf, topologyConfig, p.proxyRand, p.metricHandler)
+		p.targetCredentials.Username, p.targetCredentials.Password, p.Conf, topologyConfig, p.proxyRand, p.metricHandler)
 
 	if err := targetControlConn.Start(p.controlConnShutdownWg, ctx); err != nil {
 		return fmt.Errorf("failed to initialize target control connection: %w", err)
@@ -282,6 +589,7 @@ func (p *ZdmProxy) initializeMetricHandler() error {
 	// To switch to a different implementation, change the type instantiated here to another one that implements
 	// metrics.MetricFactory.
 	// You will also need to change the HTTP handler, see runner.go.
+	// Additional backends that don't serve the metrics endpoint can be registered with Extensions.MetricsSinks.
 
 	var metricFactory metrics.MetricFactory
 	if p.Conf.MetricsEnabled {
@@ -289,6 +597,7 @@ func (p *ZdmProxy) initializeMetricHandler() error {
 	} else {
 		metricFactory = noopmetrics.NewNoopMetricFactory()
 	}
+	metricFactory = metrics.NewMultiSinkMetricFactory(metricFactory, p.extensions.MetricsSinks...)
 
 	proxyMetrics, err := p.CreateProxyMetrics(metricFactory)
 	if err != nil {
@@ -299,481 +608,1672 @@ func (p *ZdmProxy) initializeMetricHandler() error {
 		metricFactory, p.originBuckets, p.targetBuckets, p.asyncBuckets, proxyMetrics,
 		p.CreateOriginNodeMetrics, p.CreateTargetNodeMetrics, p.CreateAsyncNodeMetrics)
 
-	return nil
-}
-
-func (p *ZdmProxy) initializeGlobalStructures() error {
-	p.lock = &sync.RWMutex{}
+	maxFingerprints, err := p.Conf.ParseStatementMetricsMaxFingerprints()
+	if err != nil {
+		return err
+	}
+	if maxFingerprints > 0 {
+		p.statementMetrics, err = newStatementMetricsTracker(
+			metricFactory, maxFingerprints, p.originBuckets, p.targetBuckets)
+		if err != nil {
+			return err
+		}
+		log.Infof("Statement metrics enabled for up to %d statement fingerprints.", maxFingerprints)
+	}
 
-	p.listenerLock = &sync.Mutex{}
-	p.listenerClosed = false
-	p.proxyRand = NewThreadSafeRand()
+	payloadSizeBuckets, err := p.Conf.ParsePayloadSizeMetricsBuckets()
+	if err != nil {
+		return err
+	}
+	if payloadSizeBuckets != nil {
+		p.payloadSizeMetrics, err = newPayloadSizeMetrics(metricFactory, payloadSizeBuckets)
+		if err != nil {
+			return err
+		}
+		log.Infof("Payload size metrics enabled.")
+	}
 
-	maxProcs := runtime.GOMAXPROCS(0)
+	return nil
+}
 
-	var err error
-	p.readMode, err = p.Conf.ParseReadMode()
+func (p *ZdmProxy) initializeGuardrails() error {
+	guardrailsConfig, err := p.Conf.ParseGuardrailsConfig()
 	if err != nil {
 		return err
 	}
+	if guardrailsConfig != nil {
+		log.Infof("Write guardrails enabled: %v.", guardrailsConfig)
+		p.guardrails = newGuardrails(guardrailsConfig, p.metricHandler.GetProxyMetrics())
+	}
+	return nil
+}
 
-	p.primaryCluster, err = p.Conf.ParsePrimaryCluster()
+func (p *ZdmProxy) initializePageSizeOverride() error {
+	maxPageSize, defaultPageSize, err := p.Conf.ParsePageSizeOverride()
 	if err != nil {
 		return err
 	}
+	if maxPageSize > 0 || defaultPageSize > 0 {
+		log.Infof("Page size override enabled with maximum page size %d and default page size %d.",
+			maxPageSize, defaultPageSize)
+		p.pageSizeOverride = newPageSizeOverride(maxPageSize, defaultPageSize, p.metricHandler.GetProxyMetrics())
+	}
+	return nil
+}
 
-	p.systemQueriesMode, err = p.Conf.ParseSystemQueriesMode()
+func (p *ZdmProxy) initializeSecondaryErrorPolicy() error {
+	actions, err := p.Conf.ParseSecondaryErrorPolicy()
 	if err != nil {
 		return err
 	}
-
-	defaultReadWorkers := maxProcs * 8
-	defaultWriteWorkers := maxProcs * 4
-	if p.readMode == common.ReadModeDualAsyncOnSecondary {
-		defaultReadWorkers = maxProcs * 12
-		defaultWriteWorkers = maxProcs * 6
+	if actions != nil {
+		log.Infof("Secondary error policy enabled: %v.", p.Conf.SecondaryErrorPolicy)
+		p.secondaryErrorPolicy = newSecondaryErrorPolicy(actions, p.metricHandler.GetProxyMetrics())
 	}
+	return nil
+}
 
-	p.requestResponseNumWorkers = p.Conf.RequestResponseMaxWorkers
-	if p.requestResponseNumWorkers == -1 {
-		p.requestResponseNumWorkers = maxProcs * 4 // default
-	} else if p.requestResponseNumWorkers <= 0 {
-		log.Warnf("Invalid number of request / response workers %d, using GOMAXPROCS * 4 (%d).", p.requestResponseNumWorkers, maxProcs*4)
-		p.requestResponseNumWorkers = maxProcs * 4
+func (p *ZdmProxy) initializeLwtHandler() error {
+	originSerialConsistency, targetSerialConsistency, err := p.Conf.ParseLwtSerialConsistencies()
+	if err != nil {
+		return err
 	}
-	log.Infof("Using %d request / response workers.", p.requestResponseNumWorkers)
-
-	p.writeNumWorkers = p.Conf.WriteMaxWorkers
-	if p.writeNumWorkers == -1 {
-		p.writeNumWorkers = defaultWriteWorkers // default
-	} else if p.writeNumWorkers <= 0 {
-		log.Warnf("Invalid number of write workers %d, using default (%d).", p.writeNumWorkers, defaultWriteWorkers)
-		p.writeNumWorkers = defaultWriteWorkers
+	if p.Conf.LwtMetricsEnabled || originSerialConsistency.IsSerial() || targetSerialConsistency.IsSerial() {
+		log.Infof("Lightweight transaction handling enabled (metrics: %v, %v serial consistency: %v, "+
+			"%v serial consistency: %v).", p.Conf.LwtMetricsEnabled,
+			common.ClusterTypeOrigin, p.Conf.OriginLwtSerialConsistency,
+			common.ClusterTypeTarget, p.Conf.TargetLwtSerialConsistency)
+		p.lwtHandler = newLwtHandler(originSerialConsistency, targetSerialConsistency, p.Conf.LwtMetricsEnabled,
+			p.metricHandler.GetProxyMetrics())
 	}
-	log.Infof("Using %d write workers.", p.writeNumWorkers)
+	return nil
+}
 
-	p.readNumWorkers = p.Conf.ReadMaxWorkers
-	if p.readNumWorkers == -1 {
-		p.readNumWorkers = defaultReadWorkers // default
-	} else if p.readNumWorkers <= 0 {
-		log.Warnf("Invalid number of read workers %d, using default (%d).", p.readNumWorkers, defaultReadWorkers)
-		p.readNumWorkers = defaultReadWorkers
+func (p *ZdmProxy) initializeDualWriteResponsePolicy() error {
+	policies, err := p.Conf.ParseDualWriteResponsePolicy()
+	if err != nil {
+		return err
 	}
-	log.Infof("Using %d read workers.", p.readNumWorkers)
+	if policies != nil {
+		log.Infof("Dual write response policy enabled: %v.", p.Conf.DualWriteResponsePolicy)
+		p.dualWriteResponsePolicy = newDualWriteResponsePolicy(policies, p.metricHandler.GetProxyMetrics())
+	}
+	return nil
+}
 
-	p.listenerNumWorkers = p.Conf.ListenerMaxWorkers
-	if p.listenerNumWorkers == -1 {
-		p.listenerNumWorkers = maxProcs // default
-	} else if p.listenerNumWorkers <= 0 {
-		log.Warnf("Invalid number of connection listener workers %d, using GOMAXPROCS (%d).", p.listenerNumWorkers, maxProcs)
-		p.listenerNumWorkers = maxProcs
+// initializeTargetWriteSampler must be called after initializeMetricHandler because the sampler counts the writes
+// that are not sampled.
+func (p *ZdmProxy) initializeTargetWriteSampler() error {
+	targetWriteSamplingPercent, err := p.Conf.ParseTargetWriteSamplingPercent()
+	if err != nil {
+		return err
 	}
-	log.Infof("Using %d listener workers.", p.listenerNumWorkers)
+	if targetWriteSamplingPercent < 100 {
+		log.Infof("Target write sampling enabled, %v%% of the writes will also be sent to %v.",
+			targetWriteSamplingPercent, common.ClusterTypeTarget)
+		p.targetWriteSampler = newTargetWriteSampler(targetWriteSamplingPercent, p.metricHandler.GetProxyMetrics())
+	}
+	return nil
+}
 
-	p.requestResponseScheduler = NewScheduler(p.requestResponseNumWorkers)
-	p.writeScheduler = NewScheduler(p.writeNumWorkers)
-	p.readScheduler = NewScheduler(p.readNumWorkers)
-	p.listenerScheduler = NewScheduler(p.listenerNumWorkers)
+func (p *ZdmProxy) initializeMutationPublisher() error {
+	brokers, err := p.Conf.ParseMutationExportKafkaBrokers()
+	if err != nil {
+		return err
+	}
+	if brokers == nil {
+		return nil
+	}
 
 	p.lock.Lock()
 	defer p.lock.Unlock()
+	p.mutationPublisher = mutationexport.NewKafkaPublisher(
+		brokers, p.Conf.MutationExportKafkaTopic, p.Conf.MutationExportQueueSize,
+		p.metricHandler.GetProxyMetrics().MutationExportDropped)
+	log.Infof("Exporting dual-written mutations to Kafka topic %v (brokers: %v).", p.Conf.MutationExportKafkaTopic, brokers)
+	return nil
+}
 
-	p.globalClientHandlersWg = &sync.WaitGroup{}
-	p.clientHandlersShutdownRequestCtx, p.clientHandlersShutdownRequestCancelFn = context.WithCancel(context.Background())
-
-	p.PreparedStatementCache = NewPreparedStatementCache()
-
-	p.controlConnShutdownCtx, p.controlConnCancelFn = context.WithCancel(context.Background())
-	p.controlConnShutdownWg = &sync.WaitGroup{}
-	p.listenerShutdownWg = &sync.WaitGroup{}
-	p.shutdownClientListenerChan = make(chan bool)
-
-	p.originBuckets, err = p.Conf.ParseOriginBuckets()
+func (p *ZdmProxy) initializeOriginOnlyFallback() error {
+	targetDownPolicy, err := p.Conf.ParseTargetDownPolicy()
 	if err != nil {
-		return fmt.Errorf("failed to parse origin latency buckets: %w", err)
-	} else {
-		log.Infof("Parsed Origin latency buckets: %v", p.originBuckets)
+		return err
+	}
+	if targetDownPolicy != common.TargetDownPolicyOriginOnly {
+		return nil
 	}
 
-	p.targetBuckets, err = p.Conf.ParseTargetBuckets()
+	fileJournal, err := journal.NewFileJournal(p.Conf.OriginOnlyJournalFile)
 	if err != nil {
-		return fmt.Errorf("failed to parse target latency buckets: %w", err)
-	} else {
-		log.Infof("Parsed Target latency buckets: %v", p.targetBuckets)
+		return err
 	}
-
-	p.asyncBuckets, err = p.Conf.ParseAsyncBuckets()
+	retention, err := p.Conf.ParseOriginOnlyJournalRetention()
 	if err != nil {
-		return fmt.Errorf("failed to parse async latency buckets: %w", err)
-	} else {
-		log.Infof("Parsed Async latency buckets: %v", p.asyncBuckets)
+		_ = fileJournal.Close()
+		return err
 	}
-
-	p.activeClients = 0
+	if retention.Enabled() {
+		p.journalCompactor = newJournalCompactor(fileJournal, retention, p.metricHandler.GetProxyMetrics())
+		p.journalCompactor.Start()
+		log.Infof("The origin-only journal is compacted every %v (maximum size: %d bytes, maximum age: %v).",
+			retention.CompactionInterval, retention.MaxSizeBytes, retention.MaxAge)
+	}
+	fallback := newOriginOnlyFallback(fileJournal, p.metricHandler, p.drainClientConnections)
+	p.lock.Lock()
+	p.originOnlyFallback = fallback
+	p.lock.Unlock()
+	p.targetControlConn.OnHealthChanged(fallback.onTargetHealthChanged)
+	log.Infof("Clients will be served by %v only while %v is %v, the writes will be journaled to %v.",
+		common.ClusterTypeOrigin, common.ClusterTypeTarget, ClusterHealthDown, fileJournal.GetPath())
 	return nil
 }
 
-// acceptConnectionsFromClients creates a listener on the passed in port argument, and every connection
-// that is received over that port instantiates a ClientHandler that then takes over managing that connection
-func (p *ZdmProxy) acceptConnectionsFromClients(address string, port int, serverSideTlsConfig *tls.Config) error {
+func (p *ZdmProxy) initializeTargetWritePipeline() error {
+	targetWritePipeline, err := p.Conf.ParseTargetWritePipeline()
+	if err != nil {
+		return err
+	}
+	if targetWritePipeline != common.TargetWritePipelineKafka {
+		return nil
+	}
 
-	protocol := "tcp"
-	listenAddr := fmt.Sprintf("%s:%d", address, port)
+	brokers := p.Conf.ParseTargetWritePipelineKafkaBrokers()
+	kafkaJournal := journal.NewKafkaJournal(brokers, p.Conf.TargetWritePipelineKafkaTopic)
+	consumer := journal.NewKafkaConsumer(
+		brokers, p.Conf.TargetWritePipelineKafkaTopic, p.Conf.TargetWritePipelineKafkaConsumerGroup,
+		journal.NewDedupWindow(p.Conf.TargetWritePipelineDedupWindowSize),
+		p.metricHandler.GetProxyMetrics().TargetWritePipelineSkippedRequests,
+		p.metricHandler.GetProxyMetrics().TargetWritePipelineDuplicateRequests)
+	p.targetWritePipeline = newTargetWritePipeline(kafkaJournal, consumer, p.targetControlConn, p.metricHandler)
+
+	// the consumer uses the Target control connection so it's stopped with the control connections
+	p.controlConnShutdownWg.Add(1)
+	go func() {
+		defer p.controlConnShutdownWg.Done()
+		p.targetWritePipeline.run(p.controlConnShutdownCtx)
+	}()
+	log.Infof("The writes will be published to Kafka topic %v (brokers: %v) and applied to %v by consumer group %v.",
+		p.Conf.TargetWritePipelineKafkaTopic, brokers, common.ClusterTypeTarget, p.Conf.TargetWritePipelineKafkaConsumerGroup)
+	return nil
+}
+
+func (p *ZdmProxy) initializeGlobalStructures() error {
+	p.lock = &sync.RWMutex{}
+	p.sharedConfigCtx, p.sharedConfigCancelFn = context.WithCancel(context.Background())
+	p.sharedConfigWg = &sync.WaitGroup{}
+
+	p.listenerLock = &sync.Mutex{}
+	p.listenerClosed = false
+	p.proxyRand = NewThreadSafeRand()
+
+	maxProcs := runtime.GOMAXPROCS(0)
 
-	var l net.Listener
 	var err error
-	if serverSideTlsConfig == nil {
-		l, err = net.Listen(protocol, listenAddr)
-	} else {
-		l, err = tls.Listen(protocol, listenAddr, serverSideTlsConfig)
+	p.readMode, err = p.Conf.ParseReadMode()
+	if err != nil {
+		return err
 	}
 
+	originUsername, originPassword, err := p.Conf.ParseOriginCredentials()
 	if err != nil {
 		return err
 	}
+	p.originCredentials = &AuthCredentials{Username: originUsername, Password: originPassword}
 
-	p.listenerLock.Lock()
-	p.clientListener = l
-	p.listenerLock.Unlock()
+	targetUsername, targetPassword, err := p.Conf.ParseTargetCredentials()
+	if err != nil {
+		return err
+	}
+	p.targetCredentials = &AuthCredentials{Username: targetUsername, Password: targetPassword}
 
-	p.listenerShutdownWg.Add(1)
+	if p.extensions.ClientCredentialStore != nil {
+		p.clientCredentialStore = p.extensions.ClientCredentialStore
+	} else if p.Conf.ProxyClientCredentialsFile != "" {
+		p.clientCredentialStore, err = newFileCredentialStore(p.Conf.ProxyClientCredentialsFile)
+		if err != nil {
+			return err
+		}
+	} else if p.Conf.ProxyClientJwtJwksUrl != "" {
+		p.clientCredentialStore, err = newJwtCredentialStore(p.Conf)
+		if err != nil {
+			return err
+		}
+	}
+	if p.clientCredentialStore != nil {
+		log.Info("Proxy-level client authentication is enabled, clients are authenticated by the proxy instead of " +
+			"the clusters.")
+	}
 
-	go func() {
-		defer p.listenerShutdownWg.Done()
-		defer func() {
-			p.listenerLock.Lock()
-			defer p.listenerLock.Unlock()
-			if !p.listenerClosed {
-				p.listenerClosed = true
-				_ = l.Close()
-			}
-		}()
-		wg := &sync.WaitGroup{}
-		defer wg.Wait()
-		for {
-			conn, err := l.Accept()
-			if err != nil {
-				p.listenerLock.Lock()
-				listenerClosed := p.listenerClosed
-				p.listenerLock.Unlock()
+	p.requestInterceptors = append([]RequestInterceptor{}, p.extensions.RequestInterceptors...)
+	if p.Conf.StatementRulesFile != "" {
+		p.statementRules, err = newStatementRules(p.Conf.StatementRulesFile)
+		if err != nil {
+			return err
+		}
+		ruleSet := p.statementRules.get()
+		log.Infof("Loaded %d statement rules (default action: %v).", len(ruleSet.Rules), ruleSet.DefaultAction)
+	} else if p.Conf.AdminApiEnabled {
+		// so that rules can be added through the admin API
+		p.statementRules = newEmptyStatementRules()
+	}
+	if p.statementRules != nil {
+		// the rules are checked before the custom interceptors so that they can't be bypassed by a rewrite
+		p.requestInterceptors = append([]RequestInterceptor{p.statementRules}, p.requestInterceptors...)
+	}
+	if p.Conf.TokenRangeRoutingFile != "" {
+		tokenRangeRouter, err := newTokenRangeRouter(p.Conf.TokenRangeRoutingFile)
+		if err != nil {
+			return err
+		}
+		log.Infof("Loaded token range routing file %v (%d tables).",
+			p.Conf.TokenRangeRoutingFile, len(tokenRangeRouter.tables.Load().(map[string][]*tokenRange)))
+		if p.Conf.TokenRangeRoutingReloadIntervalMs > 0 {
+			tokenRangeRouter.Start(time.Duration(p.Conf.TokenRangeRoutingReloadIntervalMs) * time.Millisecond)
+		}
+		p.tokenRangeRouter = tokenRangeRouter
+	}
+	if p.Conf.FaultInjectionEnabled {
+		log.Warnf("Fault injection is enabled, latency and errors can be injected in the responses of the clusters. " +
+			"This must never be enabled in production.")
+		p.faultInjector = NewFaultInjector()
+	}
+	p.clientSessions = newClientSessionRegistry()
+	frameCaptureSize, err := p.Conf.ParseFrameCaptureSize()
+	if err != nil {
+		return err
+	}
+	if frameCaptureSize > 0 {
+		log.Infof("Frame capture enabled, the last %d frames of each client connection are kept (bodies: %v).",
+			frameCaptureSize, p.Conf.FrameCaptureBodiesEnabled)
+		p.frameCaptures = newFrameCaptureRegistry(frameCaptureSize, p.Conf.FrameCaptureBodiesEnabled)
+	}
+	if p.Conf.FrameExportDir != "" {
+		frameExportFormat, err := p.Conf.ParseFrameExportFormat()
+		if err != nil {
+			return err
+		}
+		dirInfo, err := os.Stat(p.Conf.FrameExportDir)
+		if err != nil {
+			return fmt.Errorf("invalid value for ZDM_FRAME_EXPORT_DIR: %w", err)
+		}
+		if !dirInfo.IsDir() {
+			return fmt.Errorf("invalid value for ZDM_FRAME_EXPORT_DIR: %v is not a directory", p.Conf.FrameExportDir)
+		}
+		log.Infof("Frame export enabled, the frames of the selected client connections are written to %v as %v files.",
+			p.Conf.FrameExportDir, frameExportFormat)
+		p.frameExporter = NewFrameExporter(p.Conf.FrameExportDir, frameExportFormat)
+	}
+	heavyHittersSize, err := p.Conf.ParseHeavyHittersSize()
+	if err != nil {
+		return err
+	}
+	if heavyHittersSize > 0 {
+		log.Infof("Heavy hitter tracking enabled, the top %d statements of each cluster are tracked.", heavyHittersSize)
+		p.heavyHitters = newHeavyHitterTracker(heavyHittersSize)
+	}
+	if p.Conf.TraceContextPropagation {
+		// added last so that the trace context is attached to the request that is actually forwarded
+		p.requestInterceptors = append(p.requestInterceptors, &traceContextPropagator{})
+	}
 
-				if listenerClosed {
-					log.Debugf("Shutting down client listener on port %d", port)
-					return
-				}
+	allowedCidrs, err := p.Conf.ParseProxyClientAllowedCidrs()
+	if err != nil {
+		return err
+	}
+	deniedCidrs, err := p.Conf.ParseProxyClientDeniedCidrs()
+	if err != nil {
+		return err
+	}
+	p.clientAddressFilter = newClientAddressFilter(allowedCidrs, deniedCidrs)
 
-				log.Errorf("Error while listening for new connections: %v", err)
-				continue
-			}
+	if p.Conf.RoleMappingFile != "" {
+		p.roleMapping, err = newRoleMapping(p.Conf.RoleMappingFile)
+		if err != nil {
+			return err
+		}
+		log.Infof("Loaded %d roles from the role mapping file.", len(p.roleMapping))
+	}
 
-			currentClients := atomic.LoadInt32(&p.activeClients)
-			if int(currentClients) >= p.Conf.ProxyMaxClientConnections {
-				log.Warnf(
-					"Refusing client connection from %v because max clients threshold has been hit (%v).",
-					conn.RemoteAddr(), p.Conf.ProxyMaxClientConnections)
-				err = conn.Close()
-				if err != nil {
-					log.Warnf("Error closing client connection from %v: %v", conn.RemoteAddr(), err)
-				}
-				continue
-			}
+	p.primaryCluster, err = p.Conf.ParsePrimaryCluster()
+	if err != nil {
+		return err
+	}
 
-			atomic.AddInt32(&p.activeClients, 1)
-			log.Infof("Accepted connection from %v", conn.RemoteAddr())
+	p.systemQueriesMode, err = p.Conf.ParseSystemQueriesMode()
+	if err != nil {
+		return err
+	}
 
-			wg.Add(1)
-			p.listenerScheduler.Schedule(func() {
-				defer wg.Done()
-				p.handleNewConnection(conn)
-			})
-		}
-	}()
+	p.originCompatibilityProfile, err = p.Conf.ParseOriginCompatibilityProfile()
+	if err != nil {
+		return err
+	}
+
+	p.targetCompatibilityProfile, err = p.Conf.ParseTargetCompatibilityProfile()
+	if err != nil {
+		return err
+	}
+	if p.originCompatibilityProfile != common.CompatibilityProfileNone || p.targetCompatibilityProfile != common.CompatibilityProfileNone {
+		log.Infof("Using compatibility profiles %v for ORIGIN and %v for TARGET.",
+			p.originCompatibilityProfile, p.targetCompatibilityProfile)
+	}
+
+	p.startupStrippedOptions, err = p.Conf.ParseStartupStrippedOptions()
+	if err != nil {
+		return err
+	}
+
+	p.maxProtocolVersion, err = p.Conf.ParseMaxClientProtocolVersion()
+	if err != nil {
+		return err
+	}
+	if p.maxProtocolVersion != 0 {
+		log.Infof("Clients can't use a protocol version higher than %v.", p.maxProtocolVersion)
+	}
+
+	p.eventsSource, err = p.Conf.ParseEventsSource()
+	if err != nil {
+		return err
+	}
+
+	p.scrubbedErrorDetails, err = p.Conf.ParseErrorMessageScrubbing()
+	if err != nil {
+		return err
+	}
+
+	memorySoftLimitBytes, err := p.Conf.ParseMemorySoftLimitBytes()
+	if err != nil {
+		return err
+	}
+	if memorySoftLimitBytes > 0 {
+		p.memoryPressureMonitor = newMemoryPressureMonitor(memorySoftLimitBytes, readHeapAllocBytes)
+	}
+
+	p.sharedConfigWatcher, err = sharedconfig.NewWatcher(p.Conf)
+	if err != nil {
+		return err
+	}
+	p.sharedConfigPublisher, err = sharedconfig.NewPublisher(p.Conf)
+	if err != nil {
+		return err
+	}
+	p.leaderElector, err = sharedconfig.NewLeaderElector(p.Conf, p.fleetInstanceId())
+	if err != nil {
+		return err
+	}
+	requestRateLimit, err := p.Conf.ParseProxyRequestRateLimit()
+	if err != nil {
+		return err
+	}
+	// the limit can be enabled at runtime by the shared configuration
+	if requestRateLimit > 0 || p.sharedConfigWatcher != nil {
+		p.requestRateLimiter = newRequestRateLimiter(requestRateLimit, time.Now)
+	}
+	globalRequestRateLimit, err := p.Conf.ParseProxyGlobalRequestRateLimit()
+	if err != nil {
+		return err
+	}
+	// created whenever shared configuration is enabled, the global limit can also be enabled at runtime
+	globalRateLimitBucket, err := sharedconfig.NewGlobalRateLimiter(p.Conf)
+	if err != nil {
+		return err
+	}
+	if globalRateLimitBucket != nil {
+		p.globalRequestRateLimiter = newGlobalRequestRateLimiter(globalRequestRateLimit, globalRateLimitBucket)
+	}
+	connectionAcceptRateLimit, err := p.Conf.ParseProxyConnectionAcceptRateLimit()
+	if err != nil {
+		return err
+	}
+	maxConcurrentHandshakes, handshakeQueueTimeout, err := p.Conf.ParseProxyMaxConcurrentHandshakes()
+	if err != nil {
+		return err
+	}
+	p.connectionStormLimiter = newConnectionStormLimiter(
+		connectionAcceptRateLimit, maxConcurrentHandshakes, handshakeQueueTimeout)
+	err = p.initializeAutoCutover()
+	if err != nil {
+		return err
+	}
+
+	p.requestWriteQueueOverflowPolicy, err = p.Conf.ParseRequestWriteQueueOverflowPolicy()
+	if err != nil {
+		return err
+	}
+
+	p.originConnectionCompression, err = p.Conf.ParseOriginConnectionCompression()
+	if err != nil {
+		return err
+	}
+
+	p.targetConnectionCompression, err = p.Conf.ParseTargetConnectionCompression()
+	if err != nil {
+		return err
+	}
+	if p.originConnectionCompression != common.ConnectionCompressionNone || p.targetConnectionCompression != common.ConnectionCompressionNone {
+		log.Infof("Using %v compression for connections to ORIGIN and %v for TARGET when clients don't negotiate compression.",
+			p.originConnectionCompression, p.targetConnectionCompression)
+	}
+
+	defaultReadWorkers := maxProcs * 8
+	defaultWriteWorkers := maxProcs * 4
+	if p.readMode == common.ReadModeDualAsyncOnSecondary || p.readMode == common.ReadModeHedged {
+		defaultReadWorkers = maxProcs * 12
+		defaultWriteWorkers = maxProcs * 6
+	}
+
+	p.requestResponseNumWorkers = p.Conf.RequestResponseMaxWorkers
+	if p.requestResponseNumWorkers == -1 {
+		p.requestResponseNumWorkers = maxProcs * 4 // default
+	} else if p.requestResponseNumWorkers <= 0 {
+		log.Warnf("Invalid number of request / response workers %d, using GOMAXPROCS * 4 (%d).", p.requestResponseNumWorkers, maxProcs*4)
+		p.requestResponseNumWorkers = maxProcs * 4
+	}
+	log.Infof("Using %d request / response workers.", p.requestResponseNumWorkers)
+
+	p.writeNumWorkers = p.Conf.WriteMaxWorkers
+	if p.writeNumWorkers == -1 {
+		p.writeNumWorkers = defaultWriteWorkers // default
+	} else if p.writeNumWorkers <= 0 {
+		log.Warnf("Invalid number of write workers %d, using default (%d).", p.writeNumWorkers, defaultWriteWorkers)
+		p.writeNumWorkers = defaultWriteWorkers
+	}
+	log.Infof("Using %d write workers.", p.writeNumWorkers)
+
+	p.readNumWorkers = p.Conf.ReadMaxWorkers
+	if p.readNumWorkers == -1 {
+		p.readNumWorkers = defaultReadWorkers // default
+	} else if p.readNumWorkers <= 0 {
+		log.Warnf("Invalid number of read workers %d, using default (%d).", p.readNumWorkers, defaultReadWorkers)
+		p.readNumWorkers = defaultReadWorkers
+	}
+	log.Infof("Using %d read workers.", p.readNumWorkers)
+
+	p.listenerNumWorkers = p.Conf.ListenerMaxWorkers
+	if p.listenerNumWorkers == -1 {
+		p.listenerNumWorkers = maxProcs // default
+	} else if p.listenerNumWorkers <= 0 {
+		log.Warnf("Invalid number of connection listener workers %d, using GOMAXPROCS (%d).", p.listenerNumWorkers, maxProcs)
+		p.listenerNumWorkers = maxProcs
+	}
+	log.Infof("Using %d listener workers.", p.listenerNumWorkers)
+
+	p.requestResponseScheduler = NewScheduler(p.requestResponseNumWorkers)
+	p.writeScheduler = NewScheduler(p.writeNumWorkers)
+	p.readScheduler = NewScheduler(p.readNumWorkers)
+	p.listenerScheduler = NewScheduler(p.listenerNumWorkers)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.globalClientHandlersWg = &sync.WaitGroup{}
+	p.clientHandlersShutdownRequestCtx, p.clientHandlersShutdownRequestCancelFn = context.WithCancel(context.Background())
+	p.clientHandlersDrainRequestCtx, p.clientHandlersDrainRequestCancelFn = context.WithCancel(
+		p.clientHandlersShutdownRequestCtx)
+
+	p.PreparedStatementCache = NewPreparedStatementCache()
+
+	p.controlConnShutdownCtx, p.controlConnCancelFn = context.WithCancel(context.Background())
+	p.controlConnShutdownWg = &sync.WaitGroup{}
+	p.listenerShutdownWg = &sync.WaitGroup{}
+	p.shutdownClientListenerChan = make(chan bool)
+
+	p.originBuckets, err = p.Conf.ParseOriginBuckets()
+	if err != nil {
+		return fmt.Errorf("failed to parse origin latency buckets: %w", err)
+	} else {
+		log.Infof("Parsed Origin latency buckets: %v", p.originBuckets)
+	}
+
+	p.targetBuckets, err = p.Conf.ParseTargetBuckets()
+	if err != nil {
+		return fmt.Errorf("failed to parse target latency buckets: %w", err)
+	} else {
+		log.Infof("Parsed Target latency buckets: %v", p.targetBuckets)
+	}
+
+	p.asyncBuckets, err = p.Conf.ParseAsyncBuckets()
+	if err != nil {
+		return fmt.Errorf("failed to parse async latency buckets: %w", err)
+	} else {
+		log.Infof("Parsed Async latency buckets: %v", p.asyncBuckets)
+	}
+
+	p.activeClients = 0
+	return nil
+}
+
+// newClusterTlsConfigReloader returns nil if the TLS configuration of the cluster doesn't have to be reloaded, i.e.
+// reloading is disabled, TLS is disabled or a secure connect bundle is used.
+func (p *ZdmProxy) newClusterTlsConfigReloader(
+	clusterTlsConfig *common.ClusterTlsConfig, clusterType common.ClusterType) (*tlsConfigReloader, error) {
+	if p.Conf.TlsReloadIntervalMs <= 0 || !clusterTlsConfig.TlsEnabled || clusterTlsConfig.SecureConnectBundlePath != "" {
+		return nil, nil
+	}
+	tlsReloader, err := newTlsConfigReloader(
+		string(clusterType),
+		[]string{clusterTlsConfig.ServerCaPath, clusterTlsConfig.ClientCertPath, clusterTlsConfig.ClientKeyPath,
+			clusterTlsConfig.CrlPath},
+		func() (*tls.Config, error) {
+			return getClientSideTlsConfigFromProxyClusterTlsConfig(clusterTlsConfig, clusterType)
+		})
+	if err != nil {
+		return nil, err
+	}
+	p.startTlsConfigReloader(tlsReloader)
+	return tlsReloader, nil
+}
+
+func (p *ZdmProxy) startTlsConfigReloader(tlsReloader *tlsConfigReloader) {
+	tlsReloader.Start(time.Duration(p.Conf.TlsReloadIntervalMs) * time.Millisecond)
+	p.lock.Lock()
+	p.tlsConfigReloaders = append(p.tlsConfigReloaders, tlsReloader)
+	p.lock.Unlock()
+}
+
+// acceptConnectionsFromClients creates a listener on the passed in port argument, and every connection
+// that is received over that port instantiates a ClientHandler that then takes over managing that connection
+func (p *ZdmProxy) acceptConnectionsFromClients(address string, port int, serverSideTlsConfig *tls.Config) error {
+
+	protocol := "tcp"
+	listenAddr := fmt.Sprintf("%s:%d", address, port)
+
+	var l net.Listener
+	var err error
+	if gateListener := p.startupGate.handOver(); gateListener != nil {
+		l = gateListener
+		if serverSideTlsConfig != nil {
+			l = tls.NewListener(l, serverSideTlsConfig)
+		}
+	} else if serverSideTlsConfig == nil {
+		l, err = net.Listen(protocol, listenAddr)
+	} else {
+		l, err = tls.Listen(protocol, listenAddr, serverSideTlsConfig)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	p.listenerLock.Lock()
+	p.clientListener = l
+	p.listenerLock.Unlock()
+
+	p.listenerShutdownWg.Add(1)
+
+	go func() {
+		defer p.listenerShutdownWg.Done()
+		defer func() {
+			p.listenerLock.Lock()
+			defer p.listenerLock.Unlock()
+			if !p.listenerClosed {
+				p.listenerClosed = true
+				_ = l.Close()
+			}
+		}()
+		wg := &sync.WaitGroup{}
+		defer wg.Wait()
+		// stops the connections waiting for a handshake slot when the listener is closed
+		handshakeQueueCtx, handshakeQueueCancelFn := context.WithCancel(context.Background())
+		defer handshakeQueueCancelFn()
+		listenerClosed := func() bool {
+			p.listenerLock.Lock()
+			defer p.listenerLock.Unlock()
+			return p.listenerClosed
+		}
+		for {
+			if !p.connectionStormLimiter.waitToAccept(listenerClosed) {
+				log.Debugf("Shutting down client listener on port %d", port)
+				return
+			}
+			conn, err := l.Accept()
+			if err != nil {
+				if listenerClosed() {
+					log.Debugf("Shutting down client listener on port %d", port)
+					return
+				}
+
+				log.Errorf("Error while listening for new connections: %v", err)
+				continue
+			}
+
+			if !p.clientAddressFilter.isAllowed(conn.RemoteAddr()) {
+				log.Warnf("Refusing client connection from %v because its address is not allowed.", conn.RemoteAddr())
+				p.metricHandler.GetProxyMetrics().ClientAddressRejectedConnections.Add(1)
+				err = conn.Close()
+				if err != nil {
+					log.Warnf("Error closing client connection from %v: %v", conn.RemoteAddr(), err)
+				}
+				continue
+			}
+
+			currentClients := atomic.LoadInt32(&p.activeClients)
+			if int(currentClients) >= p.Conf.ProxyMaxClientConnections {
+				log.Warnf(
+					"Refusing client connection from %v because max clients threshold has been hit (%v).",
+					conn.RemoteAddr(), p.Conf.ProxyMaxClientConnections)
+				err = conn.Close()
+				if err != nil {
+					log.Warnf("Error closing client connection from %v: %v", conn.RemoteAddr(), err)
+				}
+				continue
+			}
+
+			if p.memoryPressureMonitor.IsUnderPressure() {
+				log.Warnf(
+					"Refusing client connection from %v because the heap usage exceeded the soft limit (%v MB).",
+					conn.RemoteAddr(), p.Conf.ProxyMemorySoftLimitMb)
+				p.metricHandler.GetProxyMetrics().MemoryPressureRejectedConnections.Add(1)
+				err = conn.Close()
+				if err != nil {
+					log.Warnf("Error closing client connection from %v: %v", conn.RemoteAddr(), err)
+				}
+				continue
+			}
+
+			atomic.AddInt32(&p.activeClients, 1)
+			log.Infof("Accepted connection from %v", conn.RemoteAddr())
+
+			wg.Add(1)
+			p.listenerScheduler.Schedule(func() {
+				defer wg.Done()
+				slot, ok := p.connectionStormLimiter.acquireHandshake(handshakeQueueCtx)
+				if !ok {
+					log.Warnf("Closing client connection from %v because it waited more than %v for a handshake slot "+
+						"(ZDM_PROXY_MAX_CONCURRENT_HANDSHAKES).", conn.RemoteAddr(), p.connectionStormLimiter.queueTimeout)
+					p.metricHandler.GetProxyMetrics().HandshakeQueueTimeouts.Add(1)
+					atomic.AddInt32(&p.activeClients, -1)
+					err := conn.Close()
+					if err != nil {
+						log.Warnf("Error closing client connection from %v: %v", conn.RemoteAddr(), err)
+					}
+					return
+				}
+				p.handleNewConnection(conn, slot)
+			})
+		}
+	}()
+
+	return nil
+}
+
+// handleNewConnection creates the client handler and connectors for the new client connection
+func (p *ZdmProxy) handleNewConnection(clientConn net.Conn, handshakeSlot *handshakeSlot) {
+
+	p.lock.Lock()
+	goroutines := p.goroutineAudit.register(clientConn.RemoteAddr().String())
+	p.lock.Unlock()
+
+	errFunc := func(e error) {
+		log.Errorf("Client Handler could not be created: %v", e)
+		clientConn.Close()
+		atomic.AddInt32(&p.activeClients, -1)
+		handshakeSlot.release()
+		goroutines.close()
+	}
+
+	// there is a ClientHandler for each connection made by a client
+
+	var originEndpoint Endpoint
+	var originHost *Host
+	var err error
+	if p.Conf.OriginEnableHostAssignment {
+		originHost, err = p.originControlConn.NextAssignedHost()
+		if err != nil {
+			errFunc(err)
+			return
+		}
+		originEndpoint = p.originConnectionConfig.CreateEndpoint(originHost)
+	} else {
+		originEndpoint = p.originControlConn.GetCurrentContactPoint()
+		if originEndpoint == nil {
+			log.Warnf("Origin ControlConnection current endpoint is nil, "+
+				"falling back to first origin contact point (%v) for client connection %v.",
+				p.originConnectionConfig.GetContactPoints()[0].String(), clientConn.RemoteAddr().String())
+		}
+	}
+
+	// the routing can be changed by the shared configuration, it applies to new client connections
+	p.lock.RLock()
+	readMode, primaryCluster := p.readMode, p.primaryCluster
+	drainRequestCtx := p.clientHandlersDrainRequestCtx
+	originOnlyFallback := p.originOnlyFallback
+	p.lock.RUnlock()
+
+	targetConnectionConfig, targetControlConn, targetCredentials := p.targetConnectionConfig, p.targetControlConn, p.targetCredentials
+	targetCompatibilityProfile, targetConnectionCompression := p.targetCompatibilityProfile, p.targetConnectionCompression
+	var targetEndpoint Endpoint
+	var targetHost *Host
+	if originOnlyFallback.isActive() {
+		// the secondary connection is opened to Origin, see originOnlyFallback
+		log.Debugf("Creating client connection %v in origin-only mode.", clientConn.RemoteAddr())
+		targetConnectionConfig, targetControlConn, targetCredentials = p.originConnectionConfig, p.originControlConn, p.originCredentials
+		targetCompatibilityProfile, targetConnectionCompression = p.originCompatibilityProfile, p.originConnectionCompression
+		targetEndpoint = originEndpoint
+		readMode, primaryCluster = common.ReadModePrimaryOnly, common.ClusterTypeOrigin
+	} else if p.Conf.TargetEnableHostAssignment {
+		originOnlyFallback = nil
+		targetHost, err = p.targetControlConn.NextAssignedHost()
+		if err != nil {
+			errFunc(err)
+			return
+		}
+		targetEndpoint = p.targetConnectionConfig.CreateEndpoint(targetHost)
+	} else {
+		originOnlyFallback = nil
+		targetEndpoint = p.targetControlConn.GetCurrentContactPoint()
+		if targetEndpoint == nil {
+			log.Warnf("Target ControlConnection current endpoint is nil, "+
+				"falling back to first target contact point (%v) for client connection %v.",
+				p.targetConnectionConfig.GetContactPoints()[0].String(), clientConn.RemoteAddr().String())
+		}
+	}
+
+	originCassandraConnInfo := NewClusterConnectionInfo(p.originConnectionConfig, originEndpoint, true)
+	targetCassandraConnInfo := NewClusterConnectionInfo(targetConnectionConfig, targetEndpoint, false)
+	clientHandler, err := NewClientHandler(
+		clientConn,
+		originCassandraConnInfo,
+		targetCassandraConnInfo,
+		p.originControlConn,
+		targetControlConn,
+		p.Conf,
+		p.TopologyConfig,
+		targetCredentials.Username,
+		targetCredentials.Password,
+		p.originCredentials.Username,
+		p.originCredentials.Password,
+		p.PreparedStatementCache,
+		p.metricHandler,
+		p.globalClientHandlersWg,
+		p.requestResponseScheduler,
+		p.readScheduler,
+		p.writeScheduler,
+		p.requestResponseNumWorkers,
+		drainRequestCtx,
+		originHost,
+		targetHost,
+		p.timeUuidGenerator,
+		readMode,
+		primaryCluster,
+		p.systemQueriesMode,
+		p.originCompatibilityProfile,
+		targetCompatibilityProfile,
+		p.requestInterceptors,
+		p.wasmQueryHook,
+		p.mutationPublisher,
+		p.startupStrippedOptions,
+		p.maxProtocolVersion,
+		p.eventsSource,
+		p.scrubbedErrorDetails,
+		p.memoryPressureMonitor,
+		p.requestRateLimiter,
+		p.globalRequestRateLimiter,
+		p.tokenRangeRouter,
+		p.migrationStatusRouter,
+		p.viewIndexTracker,
+		p.prepareCoalescer,
+		p.targetWriteSampler,
+		p.faultInjector,
+		p.frameCaptures.start(clientConn.RemoteAddr().String()),
+		p.frameExporter.start(clientConn.RemoteAddr(), clientConn.LocalAddr()),
+		p.heavyHitters,
+		p.statementMetrics,
+		p.payloadSizeMetrics,
+		handshakeSlot,
+		goroutines,
+		originOnlyFallback,
+		p.targetWritePipeline,
+		p.guardrails,
+		p.pageSizeOverride,
+		p.secondaryErrorPolicy,
+		p.dualWriteResponsePolicy,
+		p.lwtHandler,
+		p.requestWriteQueueOverflowPolicy,
+		p.originConnectionCompression,
+		targetConnectionCompression,
+		p.clientCredentialStore,
+		p.roleMapping)
+
+	if err != nil {
+		errFunc(err)
+		return
+	}
+
+	log.Tracef("ClientHandler created")
+	p.clientSessions.register(clientHandler)
+	clientHandler.run(&p.activeClients)
+}
+
+func (p *ZdmProxy) Shutdown() {
+	log.Info("Initiating proxy shutdown...")
+
+	log.Debug("Requesting shutdown of the client listener...")
+	p.listenerLock.Lock()
+	if !p.listenerClosed {
+		p.listenerClosed = true
+		if p.clientListener != nil {
+			p.clientListener.Close()
+		}
+	}
+	p.listenerLock.Unlock()
+
+	p.listenerShutdownWg.Wait()
+
+	log.Debug("Requesting shutdown of the client handlers...")
+	p.clientHandlersShutdownRequestCancelFn()
+
+	log.Debug("Waiting until all client handlers are done...")
+	p.globalClientHandlersWg.Wait()
+
+	log.Debug("Requesting shutdown of the control connections...")
+	p.controlConnCancelFn()
+
+	log.Debug("Waiting until control connections done...")
+	p.controlConnShutdownWg.Wait()
+
+	if p.memoryPressureMonitor != nil {
+		p.memoryPressureMonitor.Close()
+	}
+
+	p.lock.Lock()
+	goroutineAudit := p.goroutineAudit
+	p.lock.Unlock()
+	if goroutineAudit != nil {
+		goroutineAudit.Close()
+	}
+
+	p.sharedConfigCancelFn()
+	p.sharedConfigWg.Wait()
+
+	p.lock.Lock()
+	tlsConfigReloaders := p.tlsConfigReloaders
+	p.tlsConfigReloaders = nil
+	p.lock.Unlock()
+	for _, tlsReloader := range tlsConfigReloaders {
+		tlsReloader.Close()
+	}
+	if p.tokenRangeRouter != nil {
+		p.tokenRangeRouter.Close()
+	}
+	if p.migrationStatusRouter != nil {
+		p.migrationStatusRouter.Close()
+	}
+
+	if p.viewIndexTracker != nil {
+		p.viewIndexTracker.Close()
+	}
+
+	if p.clockSkewMonitor != nil {
+		p.clockSkewMonitor.Close()
+	}
+
+	log.Debug("Shutting down the schedulers and metrics handler...")
+	p.requestResponseScheduler.Shutdown()
+	p.writeScheduler.Shutdown()
+	p.readScheduler.Shutdown()
+	p.listenerScheduler.Shutdown()
+
+	p.lock.Lock()
+	if p.metricHandler != nil {
+		err := p.metricHandler.UnregisterAllMetrics()
+		if err != nil {
+			log.Warnf("Failed to unregister metrics: %v.", err)
+		}
+	}
+	if p.wasmQueryHook != nil {
+		p.wasmQueryHook.Close()
+	}
+	if p.mutationPublisher != nil {
+		err := p.mutationPublisher.Close()
+		if err != nil {
+			log.Warnf("Failed to close the mutation publisher: %v.", err)
+		}
+	}
+	if p.journalCompactor != nil {
+		p.journalCompactor.Close()
+	}
+	if p.originOnlyFallback != nil {
+		err := p.originOnlyFallback.journal.Close()
+		if err != nil {
+			log.Warnf("Failed to close the origin-only journal: %v.", err)
+		}
+	}
+	if p.targetWritePipeline != nil {
+		err := p.targetWritePipeline.journal.Close()
+		if err != nil {
+			log.Warnf("Failed to close the Kafka writer of the target write pipeline: %v.", err)
+		}
+		err = p.targetWritePipeline.consumer.Close()
+		if err != nil {
+			log.Warnf("Failed to close the Kafka reader of the target write pipeline: %v.", err)
+		}
+	}
+	p.lock.Unlock()
+
+	log.Info("Proxy shutdown complete.")
+}
+
+// GetListenAddress returns the address of the client listener or nil if the proxy is not listening for client
+// connections. It is useful when the proxy is configured with port 0.
+func (p *ZdmProxy) GetListenAddress() net.Addr {
+	p.listenerLock.Lock()
+	defer p.listenerLock.Unlock()
+
+	if p.clientListener == nil || p.listenerClosed {
+		return nil
+	}
+	return p.clientListener.Addr()
+}
+
+// GetClientSessions returns the active client connections sorted by the time they were opened.
+func (p *ZdmProxy) GetClientSessions() []*ClientSession {
+	if p.clientSessions == nil {
+		return []*ClientSession{}
+	}
+	return p.clientSessions.list()
+}
+
+// CloseClientSessions gracefully closes the client connection of the session with the given id, or all the
+// connections of a client if an IP is given, e.g. to evict the applications that didn't move to the new cluster during
+// the cutover. The pending requests are answered first and the requests received in the meantime fail with an
+// OVERLOADED error. It returns the sessions that are being closed.
+func (p *ZdmProxy) CloseClientSessions(idOrIp string) []*ClientSession {
+	if p.clientSessions == nil {
+		return []*ClientSession{}
+	}
+	return p.clientSessions.close(idOrIp)
+}
+
+// DumpFrameCaptures returns the recent frames of the connections of a client address ("host:port" or only the host),
+// of every connection if client is empty. It returns false if ZDM_FRAME_CAPTURE_SIZE is not set.
+func (p *ZdmProxy) DumpFrameCaptures(client string) ([]*FrameCaptureDump, bool) {
+	if p.frameCaptures == nil {
+		return nil, false
+	}
+	return p.frameCaptures.dump(client), true
+}
+
+// GetHeavyHitters returns the statements with the highest count and total latency of each cluster and the time since
+// which they are tracked. It returns false if ZDM_HEAVY_HITTERS_SIZE is not set.
+func (p *ZdmProxy) GetHeavyHitters() (map[common.ClusterType]*HeavyHitters, time.Time, bool) {
+	if p.heavyHitters == nil {
+		return nil, time.Time{}, false
+	}
+	heavyHitters, since := p.heavyHitters.get()
+	return heavyHitters, since, true
+}
+
+// GetStatementFingerprints returns the statements of the fingerprints of the statement metrics, it returns false if
+// ZDM_STATEMENT_METRICS_MAX_FINGERPRINTS is not set.
+func (p *ZdmProxy) GetStatementFingerprints() ([]StatementFingerprint, bool) {
+	if p.statementMetrics == nil {
+		return nil, false
+	}
+	return p.statementMetrics.getFingerprints(), true
+}
+
+// ResetHeavyHitters clears the tracked statements, it returns false if ZDM_HEAVY_HITTERS_SIZE is not set.
+func (p *ZdmProxy) ResetHeavyHitters() bool {
+	if p.heavyHitters == nil {
+		return false
+	}
+	p.heavyHitters.reset()
+	return true
+}
+
+// GetFrameExporter returns nil unless ZDM_FRAME_EXPORT_DIR is set.
+func (p *ZdmProxy) GetFrameExporter() *FrameExporter {
+	return p.frameExporter
+}
+
+// GetFaultInjector returns nil unless ZDM_FAULT_INJECTION_ENABLED is true.
+func (p *ZdmProxy) GetFaultInjector() *FaultInjector {
+	return p.faultInjector
+}
+
+func (p *ZdmProxy) GetOriginControlConn() *ControlConn {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.originControlConn
+}
+
+func (p *ZdmProxy) GetTargetControlConn() *ControlConn {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.targetControlConn
+}
+
+func Run(conf *config.Config, ctx context.Context) (*ZdmProxy, error) {
+	return RunWithExtensions(conf, ctx, nil)
+}
+
+func RunWithExtensions(conf *config.Config, ctx context.Context, extensions *Extensions) (*ZdmProxy, error) {
+	return runWithStartupGate(conf, ctx, extensions, nil)
+}
+
+func runWithStartupGate(
+	conf *config.Config, ctx context.Context, extensions *Extensions, gate *startupGate) (*ZdmProxy, error) {
+	zdmProxy, err := NewZdmProxyWithExtensions(conf, extensions)
+	if err != nil {
+		log.Errorf("Couldn't create proxy: %v.", err)
+		return nil, err
+	}
+	zdmProxy.startupGate = gate
+
+	err = zdmProxy.Start(ctx)
+	if err != nil {
+		log.Errorf("Couldn't start proxy: %v.", err)
+		zdmProxy.Shutdown()
+		return nil, err
+	}
+
+	return zdmProxy, nil
+}
+
+func RunWithRetries(conf *config.Config, ctx context.Context, b *backoff.Backoff) (*ZdmProxy, error) {
+	return RunWithRetriesAndExtensions(conf, ctx, b, nil)
+}
+
+// RunWithRetriesAndExtensions starts the proxy and retries until it succeeds or ctx is done. With the
+// REFUSE_CONNECTIONS startup policy the client listener is bound before the first attempt.
+func RunWithRetriesAndExtensions(
+	conf *config.Config, ctx context.Context, b *backoff.Backoff, extensions *Extensions) (*ZdmProxy, error) {
+	startupPolicy, err := conf.ParseStartupPolicy()
+	if err != nil {
+		return nil, err
+	}
+	var gate *startupGate
+	if startupPolicy == common.StartupPolicyRefuseConnections {
+		gate, err = newStartupGate(conf.ProxyListenAddress, conf.ProxyListenPort)
+		if err != nil {
+			return nil, fmt.Errorf("could not bind the client listener: %w", err)
+		}
+	}
+
+	log.Info("Attempting to start the proxy...")
+	for {
+		zdmProxy, err := runWithStartupGate(conf, ctx, extensions, gate)
+		if zdmProxy != nil {
+			return zdmProxy, nil
+		}
+
+		nextDuration := b.Duration()
+		if !errors.Is(err, ShutdownErr) {
+			log.Errorf("Couldn't start proxy, retrying in %v: %v.", nextDuration, err)
+		}
+		timedOut, _ := sleepWithContext(nextDuration, ctx, nil)
+		if !timedOut {
+			log.Info("Cancellation detected. Aborting proxy startup...")
+			gate.close()
+			return nil, ShutdownErr
+		}
+	}
+}
+
+// sleepWithContext returns false if context Done() returns
+func sleepWithContext(d time.Duration, ctx context.Context, reconnectCh chan bool) (timedOut bool, reconnect bool) {
+	select {
+	case <-time.After(d):
+		return true, false
+	case <-ctx.Done():
+		return false, false
+	case <-reconnectCh:
+		return false, true
+	}
+}
+
+func (p *ZdmProxy) CreateProxyMetrics(metricFactory metrics.MetricFactory) (*metrics.ProxyMetrics, error) {
+	failedReadsOrigin, err := metricFactory.GetOrCreateCounter(metrics.FailedReadsOrigin)
+	if err != nil {
+		return nil, err
+	}
+
+	failedReadsTarget, err := metricFactory.GetOrCreateCounter(metrics.FailedReadsTarget)
+	if err != nil {
+		return nil, err
+	}
+
+	failedWritesOnOrigin, err := metricFactory.GetOrCreateCounter(metrics.FailedWritesOnOrigin)
+	if err != nil {
+		return nil, err
+	}
+
+	failedWritesOnTarget, err := metricFactory.GetOrCreateCounter(metrics.FailedWritesOnTarget)
+	if err != nil {
+		return nil, err
+	}
+
+	failedWritesOnBoth, err := metricFactory.GetOrCreateCounter(metrics.FailedWritesOnBoth)
+	if err != nil {
+		return nil, err
+	}
+
+	psCacheSize, err := metricFactory.GetOrCreateGaugeFunc(metrics.PSCacheSize, p.PreparedStatementCache.GetPreparedStatementCacheSize)
+	if err != nil {
+		return nil, err
+	}
+
+	psCacheMissCount, err := metricFactory.GetOrCreateCounter(metrics.PSCacheMissCount)
+	if err != nil {
+		return nil, err
+	}
+
+	proxyReadsOriginDuration, err := metricFactory.GetOrCreateHistogram(metrics.ProxyReadsOriginDuration, p.originBuckets)
+	if err != nil {
+		return nil, err
+	}
+
+	proxyReadsTargetDuration, err := metricFactory.GetOrCreateHistogram(metrics.ProxyReadsTargetDuration, p.targetBuckets)
+	if err != nil {
+		return nil, err
+	}
+
+	proxyWritesDuration, err := metricFactory.GetOrCreateHistogram(metrics.ProxyWritesDuration, p.originBuckets)
+	if err != nil {
+		return nil, err
+	}
+
+	inFlightReadsOrigin, err := metricFactory.GetOrCreateGauge(metrics.InFlightReadsOrigin)
+	if err != nil {
+		return nil, err
+	}
+
+	inFlightReadsTarget, err := metricFactory.GetOrCreateGauge(metrics.InFlightReadsTarget)
+	if err != nil {
+		return nil, err
+	}
+
+	inFlightWrites, err := metricFactory.GetOrCreateGauge(metrics.InFlightWrites)
+	if err != nil {
+		return nil, err
+	}
+
+	openClientConnections, err := metricFactory.GetOrCreateGaugeFunc(metrics.OpenClientConnections, func() float64 {
+		return float64(atomic.LoadInt32(&p.activeClients))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	handshakesInProgress, err := metricFactory.GetOrCreateGaugeFunc(metrics.HandshakesInProgress, func() float64 {
+		return p.connectionStormLimiter.getHandshakesInProgress()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	handshakeQueueTimeouts, err := metricFactory.GetOrCreateCounter(metrics.HandshakeQueueTimeouts)
+	if err != nil {
+		return nil, err
+	}
+
+	openOriginControlConnections, err := metricFactory.GetOrCreateGauge(metrics.OpenOriginControlConnections)
+	if err != nil {
+		return nil, err
+	}
+
+	openTargetControlConnections, err := metricFactory.GetOrCreateGauge(metrics.OpenTargetControlConnections)
+	if err != nil {
+		return nil, err
+	}
+
+	memoryPressureRejectedConnections, err := metricFactory.GetOrCreateCounter(metrics.MemoryPressureRejectedConnections)
+	if err != nil {
+		return nil, err
+	}
+
+	memoryPressureRejectedRequests, err := metricFactory.GetOrCreateCounter(metrics.MemoryPressureRejectedRequests)
+	if err != nil {
+		return nil, err
+	}
+
+	goroutines, err := metricFactory.GetOrCreateGauge(metrics.Goroutines)
+	if err != nil {
+		return nil, err
+	}
+
+	openClientHandlerGoroutines, err := metricFactory.GetOrCreateGauge(metrics.OpenClientHandlerGoroutines)
+	if err != nil {
+		return nil, err
+	}
+
+	closedClientHandlerGoroutines, err := metricFactory.GetOrCreateGauge(metrics.ClosedClientHandlerGoroutines)
+	if err != nil {
+		return nil, err
+	}
+
+	goroutineLeakSuspects, err := metricFactory.GetOrCreateCounter(metrics.GoroutineLeakSuspects)
+	if err != nil {
+		return nil, err
+	}
+
+	clientAddressRejectedConnections, err := metricFactory.GetOrCreateCounter(metrics.ClientAddressRejectedConnections)
+	if err != nil {
+		return nil, err
+	}
+
+	writeQueueOverflowRejectedRequests, err := metricFactory.GetOrCreateCounter(metrics.WriteQueueOverflowRejectedRequests)
+	if err != nil {
+		return nil, err
+	}
+
+	streamedResponses, err := metricFactory.GetOrCreateCounter(metrics.StreamedResponses)
+	if err != nil {
+		return nil, err
+	}
+
+	rateLimitedRequests, err := metricFactory.GetOrCreateCounter(metrics.RateLimitedRequests)
+	if err != nil {
+		return nil, err
+	}
+
+	globalRateLimitedRequests, err := metricFactory.GetOrCreateCounter(metrics.GlobalRateLimitedRequests)
+	if err != nil {
+		return nil, err
+	}
+
+	mutationExportDropped, err := metricFactory.GetOrCreateCounter(metrics.MutationExportDropped)
+	if err != nil {
+		return nil, err
+	}
+
+	targetWriteSamplingSkippedWrites, err := metricFactory.GetOrCreateCounter(metrics.TargetWriteSamplingSkippedWrites)
+	if err != nil {
+		return nil, err
+	}
+
+	originOnlyFallbackActive, err := metricFactory.GetOrCreateGaugeFunc(metrics.OriginOnlyFallbackActive, func() float64 {
+		p.lock.RLock()
+		fallback := p.originOnlyFallback
+		p.lock.RUnlock()
+		if fallback.isActive() {
+			return 1
+		}
+		return 0
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	originOnlyJournaledRequests, err := metricFactory.GetOrCreateCounter(metrics.OriginOnlyJournaledRequests)
+	if err != nil {
+		return nil, err
+	}
+
+	originOnlyJournalFailures, err := metricFactory.GetOrCreateCounter(metrics.OriginOnlyJournalFailures)
+	if err != nil {
+		return nil, err
+	}
+
+	originOnlyJournalSize, err := metricFactory.GetOrCreateGaugeFunc(metrics.OriginOnlyJournalSize, func() float64 {
+		p.lock.RLock()
+		fallback := p.originOnlyFallback
+		p.lock.RUnlock()
+		if fallback == nil {
+			return 0
+		}
+		fileJournal, ok := fallback.journal.(*journal.FileJournal)
+		if !ok {
+			return 0
+		}
+		size, err := fileJournal.GetSizeBytes()
+		if err != nil {
+			return 0
+		}
+		return float64(size)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	originOnlyJournalDroppedEntriesAge, err := metricFactory.GetOrCreateCounter(metrics.OriginOnlyJournalDroppedEntriesAge)
+	if err != nil {
+		return nil, err
+	}
+
+	originOnlyJournalDroppedEntriesSize, err := metricFactory.GetOrCreateCounter(metrics.OriginOnlyJournalDroppedEntriesSize)
+	if err != nil {
+		return nil, err
+	}
+
+	targetWritePipelinePublishedRequests, err := metricFactory.GetOrCreateCounter(metrics.TargetWritePipelinePublishedRequests)
+	if err != nil {
+		return nil, err
+	}
+
+	targetWritePipelinePublishFailures, err := metricFactory.GetOrCreateCounter(metrics.TargetWritePipelinePublishFailures)
+	if err != nil {
+		return nil, err
+	}
+
+	targetWritePipelineAppliedRequests, err := metricFactory.GetOrCreateCounter(metrics.TargetWritePipelineAppliedRequests)
+	if err != nil {
+		return nil, err
+	}
+
+	targetWritePipelineSkippedRequests, err := metricFactory.GetOrCreateCounter(metrics.TargetWritePipelineSkippedRequests)
+	if err != nil {
+		return nil, err
+	}
+
+	targetWritePipelineDuplicateRequests, err := metricFactory.GetOrCreateCounter(metrics.TargetWritePipelineDuplicateRequests)
+	if err != nil {
+		return nil, err
+	}
 
-	return nil
-}
+	readRepairs, err := metricFactory.GetOrCreateCounter(metrics.ReadRepairs)
+	if err != nil {
+		return nil, err
+	}
 
-// handleNewConnection creates the client handler and connectors for the new client connection
-func (p *ZdmProxy) handleNewConnection(clientConn net.Conn) {
+	readRepairFailures, err := metricFactory.GetOrCreateCounter(metrics.ReadRepairFailures)
+	if err != nil {
+		return nil, err
+	}
 
-	errFunc := func(e error) {
-		log.Errorf("Client Handler could not be created: %v", e)
-		clientConn.Close()
-		atomic.AddInt32(&p.activeClients, -1)
+	asymmetricTables, err := metricFactory.GetOrCreateGauge(metrics.AsymmetricTables)
+	if err != nil {
+		return nil, err
 	}
 
-	// there is a ClientHandler for each connection made by a client
+	asymmetricTableWrites, err := metricFactory.GetOrCreateCounter(metrics.AsymmetricTableWrites)
+	if err != nil {
+		return nil, err
+	}
 
-	var originEndpoint Endpoint
-	var originHost *Host
-	var err error
-	if p.Conf.OriginEnableHostAssignment {
-		originHost, err = p.originControlConn.NextAssignedHost()
-		if err != nil {
-			errFunc(err)
-			return
-		}
-		originEndpoint = p.originConnectionConfig.CreateEndpoint(originHost)
-	} else {
-		originEndpoint = p.originControlConn.GetCurrentContactPoint()
-		if originEndpoint == nil {
-			log.Warnf("Origin ControlConnection current endpoint is nil, "+
-				"falling back to first origin contact point (%v) for client connection %v.",
-				p.originConnectionConfig.GetContactPoints()[0].String(), clientConn.RemoteAddr().String())
-		}
+	preparedMetadataMismatches, err := metricFactory.GetOrCreateCounter(metrics.PreparedMetadataMismatches)
+	if err != nil {
+		return nil, err
 	}
 
-	var targetEndpoint Endpoint
-	var targetHost *Host
-	if p.Conf.TargetEnableHostAssignment {
-		targetHost, err = p.targetControlConn.NextAssignedHost()
-		if err != nil {
-			errFunc(err)
-			return
-		}
-		targetEndpoint = p.targetConnectionConfig.CreateEndpoint(targetHost)
-	} else {
-		targetEndpoint = p.targetControlConn.GetCurrentContactPoint()
-		if targetEndpoint == nil {
-			log.Warnf("Target ControlConnection current endpoint is nil, "+
-				"falling back to first target contact point (%v) for client connection %v.",
-				p.targetConnectionConfig.GetContactPoints()[0].String(), clientConn.RemoteAddr().String())
-		}
+	coalescedPrepares, err := metricFactory.GetOrCreateCounter(metrics.CoalescedPrepares)
+	if err != nil {
+		return nil, err
 	}
 
-	originCassandraConnInfo := NewClusterConnectionInfo(p.originConnectionConfig, originEndpoint, true)
-	targetCassandraConnInfo := NewClusterConnectionInfo(p.targetConnectionConfig, targetEndpoint, false)
-	clientHandler, err := NewClientHandler(
-		clientConn,
-		originCassandraConnInfo,
-		targetCassandraConnInfo,
-		p.originControlConn,
-		p.targetControlConn,
-		p.Conf,
-		p.TopologyConfig,
-		p.Conf.TargetUsername,
-		p.Conf.TargetPassword,
-		p.Conf.OriginUsername,
-		p.Conf.OriginPassword,
-		p.PreparedStatementCache,
-		p.metricHandler,
-		p.globalClientHandlersWg,
-		p.requestResponseScheduler,
-		p.readScheduler,
-		p.writeScheduler,
-		p.requestResponseNumWorkers,
-		p.clientHandlersShutdownRequestCtx,
-		originHost,
-		targetHost,
-		p.timeUuidGenerator,
-		p.readMode,
-		p.primaryCluster,
-		p.systemQueriesMode)
+	requestDeadlineExceeded, err := metricFactory.GetOrCreateCounter(metrics.RequestDeadlineExceeded)
+	if err != nil {
+		return nil, err
+	}
 
+	asyncReadComparisons, err := metricFactory.GetOrCreateCounter(metrics.AsyncReadComparisons)
 	if err != nil {
-		errFunc(err)
-		return
+		return nil, err
 	}
 
-	log.Tracef("ClientHandler created")
-	clientHandler.run(&p.activeClients)
-}
+	asyncReadDivergencesOutcome, err := metricFactory.GetOrCreateCounter(metrics.AsyncReadDivergencesOutcome)
+	if err != nil {
+		return nil, err
+	}
 
-func (p *ZdmProxy) Shutdown() {
-	log.Info("Initiating proxy shutdown...")
+	asyncReadDivergencesRowCount, err := metricFactory.GetOrCreateCounter(metrics.AsyncReadDivergencesRowCount)
+	if err != nil {
+		return nil, err
+	}
 
-	log.Debug("Requesting shutdown of the client listener...")
-	p.listenerLock.Lock()
-	if !p.listenerClosed {
-		p.listenerClosed = true
-		if p.clientListener != nil {
-			p.clientListener.Close()
-		}
+	asyncReadDivergencesResultSize, err := metricFactory.GetOrCreateCounter(metrics.AsyncReadDivergencesResultSize)
+	if err != nil {
+		return nil, err
 	}
-	p.listenerLock.Unlock()
 
-	p.listenerShutdownWg.Wait()
+	guardrailWarningsBatchSize, err := metricFactory.GetOrCreateCounter(metrics.GuardrailWarningsBatchSize)
+	if err != nil {
+		return nil, err
+	}
 
-	log.Debug("Requesting shutdown of the client handlers...")
-	p.clientHandlersShutdownRequestCancelFn()
+	guardrailWarningsBatchStatements, err := metricFactory.GetOrCreateCounter(metrics.GuardrailWarningsBatchStatements)
+	if err != nil {
+		return nil, err
+	}
 
-	log.Debug("Waiting until all client handlers are done...")
-	p.globalClientHandlersWg.Wait()
+	guardrailWarningsMutationSize, err := metricFactory.GetOrCreateCounter(metrics.GuardrailWarningsMutationSize)
+	if err != nil {
+		return nil, err
+	}
 
-	log.Debug("Requesting shutdown of the control connections...")
-	p.controlConnCancelFn()
+	guardrailRejectionsBatchSize, err := metricFactory.GetOrCreateCounter(metrics.GuardrailRejectionsBatchSize)
+	if err != nil {
+		return nil, err
+	}
 
-	log.Debug("Waiting until control connections done...")
-	p.controlConnShutdownWg.Wait()
+	guardrailRejectionsBatchStatements, err := metricFactory.GetOrCreateCounter(metrics.GuardrailRejectionsBatchStatements)
+	if err != nil {
+		return nil, err
+	}
 
-	log.Debug("Shutting down the schedulers and metrics handler...")
-	p.requestResponseScheduler.Shutdown()
-	p.writeScheduler.Shutdown()
-	p.readScheduler.Shutdown()
-	p.listenerScheduler.Shutdown()
+	guardrailRejectionsMutationSize, err := metricFactory.GetOrCreateCounter(metrics.GuardrailRejectionsMutationSize)
+	if err != nil {
+		return nil, err
+	}
 
-	p.lock.Lock()
-	if p.metricHandler != nil {
-		err := p.metricHandler.UnregisterAllMetrics()
-		if err != nil {
-			log.Warnf("Failed to unregister metrics: %v.", err)
-		}
+	guardrailRejectionsQueryLength, err := metricFactory.GetOrCreateCounter(metrics.GuardrailRejectionsQueryLength)
+	if err != nil {
+		return nil, err
 	}
-	p.lock.Unlock()
 
-	log.Info("Proxy shutdown complete.")
-}
+	guardrailRejectionsValuesSize, err := metricFactory.GetOrCreateCounter(metrics.GuardrailRejectionsValuesSize)
+	if err != nil {
+		return nil, err
+	}
 
-func (p *ZdmProxy) GetOriginControlConn() *ControlConn {
-	p.lock.RLock()
-	defer p.lock.RUnlock()
+	pageSizeOverriddenRequests, err := metricFactory.GetOrCreateCounter(metrics.PageSizeOverriddenRequests)
+	if err != nil {
+		return nil, err
+	}
 
-	return p.originControlConn
-}
+	clusterWarningsOrigin, err := metricFactory.GetOrCreateCounter(metrics.ClusterWarningsOrigin)
+	if err != nil {
+		return nil, err
+	}
 
-func (p *ZdmProxy) GetTargetControlConn() *ControlConn {
-	p.lock.RLock()
-	defer p.lock.RUnlock()
+	clusterWarningsTarget, err := metricFactory.GetOrCreateCounter(metrics.ClusterWarningsTarget)
+	if err != nil {
+		return nil, err
+	}
 
-	return p.targetControlConn
-}
+	translatedSecondaryErrorsWarning, err := metricFactory.GetOrCreateCounter(metrics.TranslatedSecondaryErrorsWarning)
+	if err != nil {
+		return nil, err
+	}
 
-func Run(conf *config.Config, ctx context.Context) (*ZdmProxy, error) {
-	zdmProxy, err := NewZdmProxy(conf)
+	translatedSecondaryErrorsMetric, err := metricFactory.GetOrCreateCounter(metrics.TranslatedSecondaryErrorsMetric)
 	if err != nil {
-		log.Errorf("Couldn't create proxy: %v.", err)
 		return nil, err
 	}
 
-	err = zdmProxy.Start(ctx)
+	dualWriteResponsePolicyPrimary, err := metricFactory.GetOrCreateCounter(metrics.DualWriteResponsePolicyPrimary)
 	if err != nil {
-		log.Errorf("Couldn't start proxy: %v.", err)
-		zdmProxy.Shutdown()
 		return nil, err
 	}
 
-	return zdmProxy, nil
-}
+	dualWriteResponsePolicyEither, err := metricFactory.GetOrCreateCounter(metrics.DualWriteResponsePolicyEither)
+	if err != nil {
+		return nil, err
+	}
 
-func RunWithRetries(conf *config.Config, ctx context.Context, b *backoff.Backoff) (*ZdmProxy, error) {
-	log.Info("Attempting to start the proxy...")
-	for {
-		zdmProxy, err := Run(conf, ctx)
-		if zdmProxy != nil {
-			return zdmProxy, nil
-		}
+	dualWriteResponsePolicyQuorum, err := metricFactory.GetOrCreateCounter(metrics.DualWriteResponsePolicyQuorum)
+	if err != nil {
+		return nil, err
+	}
 
-		nextDuration := b.Duration()
-		if !errors.Is(err, ShutdownErr) {
-			log.Errorf("Couldn't start proxy, retrying in %v: %v.", nextDuration, err)
-		}
-		timedOut, _ := sleepWithContext(nextDuration, ctx, nil)
-		if !timedOut {
-			log.Info("Cancellation detected. Aborting proxy startup...")
-			return nil, ShutdownErr
-		}
+	lwtRequestsOrigin, err := metricFactory.GetOrCreateCounter(metrics.LwtRequestsOrigin)
+	if err != nil {
+		return nil, err
 	}
-}
 
-// sleepWithContext returns false if context Done() returns
-func sleepWithContext(d time.Duration, ctx context.Context, reconnectCh chan bool) (timedOut bool, reconnect bool) {
-	select {
-	case <-time.After(d):
-		return true, false
-	case <-ctx.Done():
-		return false, false
-	case <-reconnectCh:
-		return false, true
+	lwtRequestsTarget, err := metricFactory.GetOrCreateCounter(metrics.LwtRequestsTarget)
+	if err != nil {
+		return nil, err
 	}
-}
 
-func (p *ZdmProxy) CreateProxyMetrics(metricFactory metrics.MetricFactory) (*metrics.ProxyMetrics, error) {
-	failedReadsOrigin, err := metricFactory.GetOrCreateCounter(metrics.FailedReadsOrigin)
+	lwtNotAppliedOrigin, err := metricFactory.GetOrCreateCounter(metrics.LwtNotAppliedOrigin)
 	if err != nil {
 		return nil, err
 	}
 
-	failedReadsTarget, err := metricFactory.GetOrCreateCounter(metrics.FailedReadsTarget)
+	lwtNotAppliedTarget, err := metricFactory.GetOrCreateCounter(metrics.LwtNotAppliedTarget)
 	if err != nil {
 		return nil, err
 	}
 
-	failedWritesOnOrigin, err := metricFactory.GetOrCreateCounter(metrics.FailedWritesOnOrigin)
+	lwtSerialConsistencyRemappedOrigin, err := metricFactory.GetOrCreateCounter(metrics.LwtSerialConsistencyRemappedOrigin)
 	if err != nil {
 		return nil, err
 	}
 
-	failedWritesOnTarget, err := metricFactory.GetOrCreateCounter(metrics.FailedWritesOnTarget)
+	lwtSerialConsistencyRemappedTarget, err := metricFactory.GetOrCreateCounter(metrics.LwtSerialConsistencyRemappedTarget)
 	if err != nil {
 		return nil, err
 	}
 
-	failedWritesOnBoth, err := metricFactory.GetOrCreateCounter(metrics.FailedWritesOnBoth)
+	lwtAppliedDivergences, err := metricFactory.GetOrCreateCounter(metrics.LwtAppliedDivergences)
 	if err != nil {
 		return nil, err
 	}
 
-	psCacheSize, err := metricFactory.GetOrCreateGaugeFunc(metrics.PSCacheSize, p.PreparedStatementCache.GetPreparedStatementCacheSize)
+	clusterClockOffsetOrigin, err := metricFactory.GetOrCreateGauge(metrics.ClusterClockOffsetOrigin)
 	if err != nil {
 		return nil, err
 	}
 
-	psCacheMissCount, err := metricFactory.GetOrCreateCounter(metrics.PSCacheMissCount)
+	clusterClockOffsetTarget, err := metricFactory.GetOrCreateGauge(metrics.ClusterClockOffsetTarget)
 	if err != nil {
 		return nil, err
 	}
 
-	proxyReadsOriginDuration, err := metricFactory.GetOrCreateHistogram(metrics.ProxyReadsOriginDuration, p.originBuckets)
+	clusterClockSkew, err := metricFactory.GetOrCreateGauge(metrics.ClusterClockSkew)
 	if err != nil {
 		return nil, err
 	}
 
-	proxyReadsTargetDuration, err := metricFactory.GetOrCreateHistogram(metrics.ProxyReadsTargetDuration, p.targetBuckets)
+	clusterClockSkewExceeded, err := metricFactory.GetOrCreateCounter(metrics.ClusterClockSkewExceeded)
 	if err != nil {
 		return nil, err
 	}
 
-	proxyWritesDuration, err := metricFactory.GetOrCreateHistogram(metrics.ProxyWritesDuration, p.originBuckets)
+	fleetLeader, err := metricFactory.GetOrCreateGauge(metrics.FleetLeader)
 	if err != nil {
 		return nil, err
 	}
 
-	inFlightReadsOrigin, err := metricFactory.GetOrCreateGauge(metrics.InFlightReadsOrigin)
+	// a gauge instead of a gauge function because gauge functions don't support labels
+	buildInfo, err := metricFactory.GetOrCreateGauge(metrics.BuildInfo)
 	if err != nil {
 		return nil, err
 	}
+	buildInfo.Set(1)
 
-	inFlightReadsTarget, err := metricFactory.GetOrCreateGauge(metrics.InFlightReadsTarget)
+	configInfoLabels, err := p.configInfoLabels()
+	if err != nil {
+		return nil, err
+	}
+	configInfo, err := metricFactory.GetOrCreateGauge(metrics.ConfigInfo.WithLabels(configInfoLabels))
 	if err != nil {
 		return nil, err
 	}
+	configInfo.Set(1)
 
-	inFlightWrites, err := metricFactory.GetOrCreateGauge(metrics.InFlightWrites)
+	originClusterHealth, err := metrics.CreateClusterHealthMetrics(
+		metricFactory, metrics.ClusterHealthOrigin, clusterHealthStateNames())
 	if err != nil {
 		return nil, err
 	}
 
-	openClientConnections, err := metricFactory.GetOrCreateGaugeFunc(metrics.OpenClientConnections, func() float64 {
-		return float64(atomic.LoadInt32(&p.activeClients))
-	})
+	targetClusterHealth, err := metrics.CreateClusterHealthMetrics(
+		metricFactory, metrics.ClusterHealthTarget, clusterHealthStateNames())
 	if err != nil {
 		return nil, err
 	}
 
 	proxyMetrics := &metrics.ProxyMetrics{
-		FailedReadsOrigin:        failedReadsOrigin,
-		FailedReadsTarget:        failedReadsTarget,
-		FailedWritesOnOrigin:     failedWritesOnOrigin,
-		FailedWritesOnTarget:     failedWritesOnTarget,
-		FailedWritesOnBoth:       failedWritesOnBoth,
-		PSCacheSize:              psCacheSize,
-		PSCacheMissCount:         psCacheMissCount,
-		ProxyReadsOriginDuration: proxyReadsOriginDuration,
-		ProxyReadsTargetDuration: proxyReadsTargetDuration,
-		ProxyWritesDuration:      proxyWritesDuration,
-		InFlightReadsOrigin:      inFlightReadsOrigin,
-		InFlightReadsTarget:      inFlightReadsTarget,
-		InFlightWrites:           inFlightWrites,
-		OpenClientConnections:    openClientConnections,
+		FailedReadsOrigin:                    failedReadsOrigin,
+		FailedReadsTarget:                    failedReadsTarget,
+		FailedWritesOnOrigin:                 failedWritesOnOrigin,
+		FailedWritesOnTarget:                 failedWritesOnTarget,
+		FailedWritesOnBoth:                   failedWritesOnBoth,
+		PSCacheSize:                          psCacheSize,
+		PSCacheMissCount:                     psCacheMissCount,
+		ProxyReadsOriginDuration:             proxyReadsOriginDuration,
+		ProxyReadsTargetDuration:             proxyReadsTargetDuration,
+		ProxyWritesDuration:                  proxyWritesDuration,
+		InFlightReadsOrigin:                  inFlightReadsOrigin,
+		InFlightReadsTarget:                  inFlightReadsTarget,
+		InFlightWrites:                       inFlightWrites,
+		OpenClientConnections:                openClientConnections,
+		HandshakesInProgress:                 handshakesInProgress,
+		HandshakeQueueTimeouts:               handshakeQueueTimeouts,
+		OpenOriginControlConnections:         openOriginControlConnections,
+		OpenTargetControlConnections:         openTargetControlConnections,
+		MemoryPressureRejectedConnections:    memoryPressureRejectedConnections,
+		MemoryPressureRejectedRequests:       memoryPressureRejectedRequests,
+		Goroutines:                           goroutines,
+		OpenClientHandlerGoroutines:          openClientHandlerGoroutines,
+		ClosedClientHandlerGoroutines:        closedClientHandlerGoroutines,
+		GoroutineLeakSuspects:                goroutineLeakSuspects,
+		ClientAddressRejectedConnections:     clientAddressRejectedConnections,
+		WriteQueueOverflowRejectedRequests:   writeQueueOverflowRejectedRequests,
+		StreamedResponses:                    streamedResponses,
+		RateLimitedRequests:                  rateLimitedRequests,
+		GlobalRateLimitedRequests:            globalRateLimitedRequests,
+		MutationExportDropped:                mutationExportDropped,
+		TargetWriteSamplingSkippedWrites:     targetWriteSamplingSkippedWrites,
+		OriginOnlyFallbackActive:             originOnlyFallbackActive,
+		OriginOnlyJournaledRequests:          originOnlyJournaledRequests,
+		OriginOnlyJournalFailures:            originOnlyJournalFailures,
+		OriginOnlyJournalSize:                originOnlyJournalSize,
+		OriginOnlyJournalDroppedEntriesAge:   originOnlyJournalDroppedEntriesAge,
+		OriginOnlyJournalDroppedEntriesSize:  originOnlyJournalDroppedEntriesSize,
+		TargetWritePipelinePublishedRequests: targetWritePipelinePublishedRequests,
+		TargetWritePipelinePublishFailures:   targetWritePipelinePublishFailures,
+		TargetWritePipelineAppliedRequests:   targetWritePipelineAppliedRequests,
+		TargetWritePipelineSkippedRequests:   targetWritePipelineSkippedRequests,
+		TargetWritePipelineDuplicateRequests: targetWritePipelineDuplicateRequests,
+		ReadRepairs:                          readRepairs,
+		ReadRepairFailures:                   readRepairFailures,
+		AsymmetricTables:                     asymmetricTables,
+		AsymmetricTableWrites:                asymmetricTableWrites,
+		PreparedMetadataMismatches:           preparedMetadataMismatches,
+		CoalescedPrepares:                    coalescedPrepares,
+		RequestDeadlineExceeded:              requestDeadlineExceeded,
+		AsyncReadComparisons:                 asyncReadComparisons,
+		AsyncReadDivergencesOutcome:          asyncReadDivergencesOutcome,
+		AsyncReadDivergencesRowCount:         asyncReadDivergencesRowCount,
+		AsyncReadDivergencesResultSize:       asyncReadDivergencesResultSize,
+		GuardrailWarningsBatchSize:           guardrailWarningsBatchSize,
+		GuardrailWarningsBatchStatements:     guardrailWarningsBatchStatements,
+		GuardrailWarningsMutationSize:        guardrailWarningsMutationSize,
+		GuardrailRejectionsBatchSize:         guardrailRejectionsBatchSize,
+		GuardrailRejectionsBatchStatements:   guardrailRejectionsBatchStatements,
+		GuardrailRejectionsMutationSize:      guardrailRejectionsMutationSize,
+		GuardrailRejectionsQueryLength:       guardrailRejectionsQueryLength,
+		GuardrailRejectionsValuesSize:        guardrailRejectionsValuesSize,
+		PageSizeOverriddenRequests:           pageSizeOverriddenRequests,
+		ClusterWarningsOrigin:                clusterWarningsOrigin,
+		ClusterWarningsTarget:                clusterWarningsTarget,
+		TranslatedSecondaryErrorsWarning:     translatedSecondaryErrorsWarning,
+		TranslatedSecondaryErrorsMetric:      translatedSecondaryErrorsMetric,
+		DualWriteResponsePolicyPrimary:       dualWriteResponsePolicyPrimary,
+		DualWriteResponsePolicyEither:        dualWriteResponsePolicyEither,
+		DualWriteResponsePolicyQuorum:        dualWriteResponsePolicyQuorum,
+		LwtRequestsOrigin:                    lwtRequestsOrigin,
+		LwtRequestsTarget:                    lwtRequestsTarget,
+		LwtNotAppliedOrigin:                  lwtNotAppliedOrigin,
+		LwtNotAppliedTarget:                  lwtNotAppliedTarget,
+		LwtSerialConsistencyRemappedOrigin:   lwtSerialConsistencyRemappedOrigin,
+		LwtSerialConsistencyRemappedTarget:   lwtSerialConsistencyRemappedTarget,
+		LwtAppliedDivergences:                lwtAppliedDivergences,
+		ClusterClockOffsetOrigin:             clusterClockOffsetOrigin,
+		ClusterClockOffsetTarget:             clusterClockOffsetTarget,
+		ClusterClockSkew:                     clusterClockSkew,
+		ClusterClockSkewExceeded:             clusterClockSkewExceeded,
+		FleetLeader:                          fleetLeader,
+		BuildInfo:                            buildInfo,
+		ConfigInfo:                           configInfo,
+		OriginClusterHealth:                  originClusterHealth,
+		TargetClusterHealth:                  targetClusterHealth,
 	}
 
 	return proxyMetrics, nil
@@ -836,8 +2336,7 @@ func (p *ZdmProxy) CreateOriginNodeMetrics(
 		return nil, err
 	}
 
-	// inflight requests metric for non async requests are implemented as proxy level metrics (not node metrics)
-	inflightRequests, err := noopmetrics.NewNoopMetricFactory().GetOrCreateGauge(nil)
+	inflightRequestsOrigin, err := metrics.CreateGaugeNodeMetric(metricFactory, originNodeDescription, metrics.InFlightRequestsOrigin)
 	if err != nil {
 		return nil, err
 	}
@@ -847,6 +2346,17 @@ func (p *ZdmProxy) CreateOriginNodeMetrics(
 		return nil, err
 	}
 
+	originStuckConnections, err := metrics.CreateCounterNodeMetric(metricFactory, originNodeDescription, metrics.OriginStuckConnections)
+	if err != nil {
+		return nil, err
+	}
+
+	originConnectionLossFailedRequests, err := metrics.CreateCounterNodeMetric(
+		metricFactory, originNodeDescription, metrics.OriginConnectionLossFailedRequests)
+	if err != nil {
+		return nil, err
+	}
+
 	return &metrics.NodeMetricsInstance{
 		ClientTimeouts:    originClientTimeouts,
 		ReadTimeouts:      originReadTimeouts,
@@ -859,8 +2369,11 @@ func (p *ZdmProxy) CreateOriginNodeMetrics(
 		OtherErrors:       originOtherErrors,
 		RequestDuration:   originRequestDuration,
 		OpenConnections:   openOriginConnections,
-		InFlightRequests:  inflightRequests,
+		InFlightRequests:  inflightRequestsOrigin,
 		UsedStreamIds:     originUsedStreamIds,
+		StuckConnections:  originStuckConnections,
+
+		ConnectionLossFailedRequests: originConnectionLossFailedRequests,
 	}, nil
 }
 
@@ -931,6 +2444,11 @@ func (p *ZdmProxy) CreateAsyncNodeMetrics(
 		return nil, err
 	}
 
+	asyncStuckConnections, err := metrics.CreateCounterNodeMetric(metricFactory, asyncNodeDescription, metrics.AsyncStuckConnections)
+	if err != nil {
+		return nil, err
+	}
+
 	return &metrics.NodeMetricsInstance{
 		ClientTimeouts:    asyncClientTimeouts,
 		ReadTimeouts:      asyncReadTimeouts,
@@ -945,6 +2463,7 @@ func (p *ZdmProxy) CreateAsyncNodeMetrics(
 		OpenConnections:   openAsyncConnections,
 		InFlightRequests:  inflightRequestsAsync,
 		UsedStreamIds:     asyncUsedStreamIds,
+		StuckConnections:  asyncStuckConnections,
 	}, nil
 }
 
@@ -1005,8 +2524,7 @@ func (p *ZdmProxy) CreateTargetNodeMetrics(
 		return nil, err
 	}
 
-	// inflight requests metric for non async requests are implemented as proxy level metrics (not node metrics)
-	inflightRequests, err := noopmetrics.NewNoopMetricFactory().GetOrCreateGauge(nil)
+	inflightRequestsTarget, err := metrics.CreateGaugeNodeMetric(metricFactory, targetNodeDescription, metrics.InFlightRequestsTarget)
 	if err != nil {
 		return nil, err
 	}
@@ -1016,6 +2534,17 @@ func (p *ZdmProxy) CreateTargetNodeMetrics(
 		return nil, err
 	}
 
+	targetStuckConnections, err := metrics.CreateCounterNodeMetric(metricFactory, targetNodeDescription, metrics.TargetStuckConnections)
+	if err != nil {
+		return nil, err
+	}
+
+	targetConnectionLossFailedRequests, err := metrics.CreateCounterNodeMetric(
+		metricFactory, targetNodeDescription, metrics.TargetConnectionLossFailedRequests)
+	if err != nil {
+		return nil, err
+	}
+
 	return &metrics.NodeMetricsInstance{
 		ClientTimeouts:    targetClientTimeouts,
 		ReadTimeouts:      targetReadTimeouts,
@@ -1028,7 +2557,10 @@ func (p *ZdmProxy) CreateTargetNodeMetrics(
 		OtherErrors:       targetOtherErrors,
 		RequestDuration:   targetRequestDuration,
 		OpenConnections:   openTargetConnections,
-		InFlightRequests:  inflightRequests,
+		InFlightRequests:  inflightRequestsTarget,
 		UsedStreamIds:     targetUsedStreamIds,
+		StuckConnections:  targetStuckConnections,
+
+		ConnectionLossFailedRequests: targetConnectionLossFailedRequests,
 	}, nil
 }
@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"github.com/datastax/go-cassandra-native-protocol/message"
 	log "github.com/sirupsen/logrus"
+	"sort"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type PreparedStatementCache struct {
@@ -42,7 +45,10 @@ func (psc *PreparedStatementCache) Store(
 	psc.lock.Lock()
 	defer psc.lock.Unlock()
 
-	psc.cache[originPrepareIdStr] = NewPreparedData(originPreparedResult, targetPreparedResult, prepareRequestInfo)
+	preparedData := NewPreparedData(originPreparedResult, targetPreparedResult, prepareRequestInfo)
+	// the statement is prepared again by every client connection, its usage statistics are kept
+	preparedData.(*preparedDataImpl).inheritUsage(psc.cache[originPrepareIdStr])
+	psc.cache[originPrepareIdStr] = preparedData
 	psc.index[targetPrepareIdStr] = originPrepareIdStr
 
 	log.Debugf("Storing PS cache entry: {OriginPreparedId=%v, TargetPreparedId: %v, RequestInfo: %v}",
@@ -55,6 +61,7 @@ func (psc *PreparedStatementCache) StoreIntercepted(preparedResult *message.Prep
 	defer psc.lock.Unlock()
 
 	preparedData := NewPreparedData(preparedResult, preparedResult, prepareRequestInfo)
+	preparedData.(*preparedDataImpl).inheritUsage(psc.interceptedCache[prepareIdStr])
 	psc.interceptedCache[prepareIdStr] = preparedData
 
 	log.Debugf("Storing intercepted PS cache entry: {PreparedId=%v, RequestInfo: %v}",
@@ -91,6 +98,72 @@ func (psc *PreparedStatementCache) GetByTargetPreparedId(targetPreparedId []byte
 	return data, true
 }
 
+// PreparedStatementStats are the usage statistics of a prepared statement since it was first prepared through the
+// proxy.
+type PreparedStatementStats struct {
+	OriginPreparedId string `json:"origin_prepared_id"`
+	TargetPreparedId string `json:"target_prepared_id"`
+	// Statement is the normalized statement, see normalizeCqlStatement.
+	Statement   string     `json:"statement"`
+	Keyspace    string     `json:"keyspace,omitempty"`
+	Intercepted bool       `json:"intercepted"`
+	PreparedAt  time.Time  `json:"prepared_at"`
+	Executions  uint64     `json:"executions"`
+	LastUsed    *time.Time `json:"last_used,omitempty"`
+}
+
+// GetStats returns the usage statistics of the cached prepared statements sorted by decreasing number of executions,
+// the executions of the children of the batches are included.
+func (psc *PreparedStatementCache) GetStats() []*PreparedStatementStats {
+	psc.lock.RLock()
+	stats := make([]*PreparedStatementStats, 0, len(psc.cache)+len(psc.interceptedCache))
+	for _, preparedData := range psc.cache {
+		stats = append(stats, getPreparedStatementStats(preparedData, false))
+	}
+	for _, preparedData := range psc.interceptedCache {
+		stats = append(stats, getPreparedStatementStats(preparedData, true))
+	}
+	psc.lock.RUnlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Executions != stats[j].Executions {
+			return stats[i].Executions > stats[j].Executions
+		}
+		return stats[i].OriginPreparedId < stats[j].OriginPreparedId
+	})
+	return stats
+}
+
+func getPreparedStatementStats(preparedData PreparedData, intercepted bool) *PreparedStatementStats {
+	stats := &PreparedStatementStats{
+		OriginPreparedId: hex.EncodeToString(preparedData.GetOriginPreparedId()),
+		TargetPreparedId: hex.EncodeToString(preparedData.GetTargetPreparedId()),
+		Intercepted:      intercepted,
+	}
+	if prepareRequestInfo := preparedData.GetPrepareRequestInfo(); prepareRequestInfo != nil {
+		stats.Statement = (&statementFingerprint{query: prepareRequestInfo.GetQuery()}).normalized()
+		stats.Keyspace = prepareRequestInfo.GetKeyspace()
+	}
+	if impl, ok := preparedData.(*preparedDataImpl); ok {
+		stats.PreparedAt = impl.preparedAt
+		stats.Executions = atomic.LoadUint64(&impl.executions)
+		if lastUsed := atomic.LoadInt64(&impl.lastUsedUnixNano); lastUsed != 0 {
+			lastUsedTime := time.Unix(0, lastUsed)
+			stats.LastUsed = &lastUsedTime
+		}
+	}
+	return stats
+}
+
+// recordPreparedStatementExecution updates the usage statistics of a prepared statement that is executed by an EXECUTE
+// request or a child of a BATCH.
+func recordPreparedStatementExecution(preparedData PreparedData) {
+	if impl, ok := preparedData.(*preparedDataImpl); ok {
+		atomic.AddUint64(&impl.executions, 1)
+		atomic.StoreInt64(&impl.lastUsedUnixNano, time.Now().UnixNano())
+	}
+}
+
 type PreparedData interface {
 	GetOriginPreparedId() []byte
 	GetTargetPreparedId() []byte
@@ -105,6 +178,37 @@ type preparedDataImpl struct {
 	prepareRequestInfo      *PrepareRequestInfo
 	originVariablesMetadata *message.VariablesMetadata
 	targetVariablesMetadata *message.VariablesMetadata
+
+	// usage statistics, see PreparedStatementStats
+	preparedAt       time.Time
+	executions       uint64
+	lastUsedUnixNano int64
+
+	// *preparedStatementInfo of the prepared query, it's set the first time it's needed (see getPreparedStatementInfo)
+	statementInfo atomic.Value
+}
+
+// preparedStatementInfo is what the payload size metrics and the LWT handling need to know about a prepared query,
+// it's cached so that the query is only inspected for the first EXECUTE.
+type preparedStatementInfo struct {
+	statementType statementType
+	lwt           bool
+}
+
+func getPreparedStatementInfo(
+	preparedData PreparedData, currentKeyspace string, timeUuidGenerator TimeUuidGenerator) *preparedStatementInfo {
+	impl, ok := preparedData.(*preparedDataImpl)
+	if ok {
+		if info, ok := impl.statementInfo.Load().(*preparedStatementInfo); ok {
+			return info
+		}
+	}
+	queryInfo := inspectPreparedQuery(preparedData, currentKeyspace, timeUuidGenerator)
+	info := &preparedStatementInfo{statementType: queryInfo.getStatementType(), lwt: queryInfo.isLwt()}
+	if ok {
+		impl.statementInfo.Store(info)
+	}
+	return info
 }
 
 func NewPreparedData(
@@ -116,7 +220,19 @@ func NewPreparedData(
 		prepareRequestInfo:      prepareRequestInfo,
 		originVariablesMetadata: originPreparedResult.VariablesMetadata,
 		targetVariablesMetadata: targetPreparedResult.VariablesMetadata,
+		preparedAt:              time.Now(),
+	}
+}
+
+// inheritUsage copies the usage statistics of the entry that this one replaces in the cache.
+func (recv *preparedDataImpl) inheritUsage(previous PreparedData) {
+	previousImpl, ok := previous.(*preparedDataImpl)
+	if !ok {
+		return
 	}
+	recv.preparedAt = previousImpl.preparedAt
+	recv.executions = atomic.LoadUint64(&previousImpl.executions)
+	recv.lastUsedUnixNano = atomic.LoadInt64(&previousImpl.lastUsedUnixNano)
 }
 
 func (recv *preparedDataImpl) GetOriginPreparedId() []byte {
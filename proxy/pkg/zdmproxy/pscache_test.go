@@ -0,0 +1,47 @@
+package zdmproxy
+
+import (
+	"encoding/hex"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestPreparedStatementCache_Stats(t *testing.T) {
+	psCache := NewPreparedStatementCache()
+	newPrepareRequestInfo := func(query string) *PrepareRequestInfo {
+		return NewPrepareRequestInfo(NewGenericRequestInfo(forwardToBoth, false, true), nil, false, query, "ks")
+	}
+	psCache.Store(&message.PreparedResult{PreparedQueryId: []byte{1}}, &message.PreparedResult{PreparedQueryId: []byte{2}},
+		newPrepareRequestInfo("SELECT * FROM ks.tbl WHERE id = ?"))
+	psCache.Store(&message.PreparedResult{PreparedQueryId: []byte{3}}, &message.PreparedResult{PreparedQueryId: []byte{4}},
+		newPrepareRequestInfo("SELECT * FROM ks.unused WHERE id = ?"))
+
+	metricHandler := newFakeMetricHandler()
+	decodedFrame := frame.NewFrame(primitive.ProtocolVersion4, 1, &message.Execute{QueryId: []byte{1}})
+	for i := 0; i < 3; i++ {
+		_, err := getPreparedData(psCache, metricHandler, []byte{1}, primitive.OpCodeExecute, decodedFrame)
+		require.Nil(t, err)
+	}
+
+	// preparing the statement again, e.g. from another client connection, keeps its statistics
+	psCache.Store(&message.PreparedResult{PreparedQueryId: []byte{1}}, &message.PreparedResult{PreparedQueryId: []byte{2}},
+		newPrepareRequestInfo("SELECT * FROM ks.tbl WHERE id = ?"))
+	_, err := getPreparedData(psCache, metricHandler, []byte{1}, primitive.OpCodeExecute, decodedFrame)
+	require.Nil(t, err)
+
+	stats := psCache.GetStats()
+	require.Len(t, stats, 2)
+	require.Equal(t, hex.EncodeToString([]byte{1}), stats[0].OriginPreparedId)
+	require.Equal(t, hex.EncodeToString([]byte{2}), stats[0].TargetPreparedId)
+	require.Equal(t, "select * from ks.tbl where id = ?", stats[0].Statement)
+	require.Equal(t, "ks", stats[0].Keyspace)
+	require.Equal(t, uint64(4), stats[0].Executions)
+	require.NotNil(t, stats[0].LastUsed)
+	require.False(t, stats[0].LastUsed.Before(stats[0].PreparedAt))
+	require.Equal(t, uint64(0), stats[1].Executions)
+	require.Nil(t, stats[1].LastUsed)
+	require.False(t, stats[1].Intercepted)
+}
@@ -82,6 +82,10 @@ type QueryInfo interface {
 	// This will always be false for non-INSERT statements or batches not containing INSERT statements.
 	hasNamedBindMarkers() bool
 
+	// Whether the query is a lightweight transaction, i.e. an INSERT, UPDATE or DELETE with an IF clause or a BATCH
+	// containing one.
+	isLwt() bool
+
 	// Whether the query contains at least one now() function call.
 	// This will always be false for non-INSERT statements or batches not containing INSERT statements.
 	hasNowFunctionCalls() bool
@@ -318,6 +322,7 @@ type cqlListener struct {
 	positionalBindMarkers bool
 	namedBindMarkers      bool
 	nowFunctionCalls      bool
+	lwt                   bool
 
 	// internal counters
 	currentPositionalIndex int
@@ -372,6 +377,10 @@ func (l *cqlListener) hasNamedBindMarkers() bool {
 	return l.namedBindMarkers
 }
 
+func (l *cqlListener) isLwt() bool {
+	return l.lwt
+}
+
 func (l *cqlListener) hasNowFunctionCalls() bool {
 	return l.nowFunctionCalls
 }
@@ -416,7 +425,7 @@ func (l *cqlListener) ExitSelectStatement(ctx *parser.SelectStatementContext) {
 		case antlr.TerminalNode:
 			if typedChild.GetSymbol().GetTokenType() == parser.SimplifiedCqlParserK_JSON ||
 				typedChild.GetSymbol().GetTokenType() == parser.SimplifiedCqlParserK_DISTINCT {
-				log.Warnf("Proxy does not support 'JSON' or 'DISTINCT' for system.local and system.peers queries: %v", ctx.GetText())
+				log.Warnf("Proxy does not support 'JSON' or 'DISTINCT' for system.local and system.peers queries: %v", redactedQuery(ctx.GetText()))
 				return
 			}
 		case *parser.SelectClauseContext:
@@ -428,7 +437,7 @@ func (l *cqlListener) ExitSelectStatement(ctx *parser.SelectStatementContext) {
 			l.parsedSelectClause = parsedSelectClause
 			return
 		default:
-			log.Errorf("Proxy could not parse SELECT query for system.local/peers: %v", ctx.GetText())
+			log.Errorf("Proxy could not parse SELECT query for system.local/peers: %v", redactedQuery(ctx.GetText()))
 			return
 		}
 	}
@@ -436,6 +445,7 @@ func (l *cqlListener) ExitSelectStatement(ctx *parser.SelectStatementContext) {
 
 func (l *cqlListener) EnterInsertStatement(ctx *parser.InsertStatementContext) {
 	parsedStmt := &parsedStatement{statementIndex: l.currentBatchChildIndex, statementType: statementTypeInsert}
+	l.lwt = l.lwt || ctx.K_IF() != nil
 	for _, childCtx := range ctx.GetChildren() {
 		switch childCtx.(type) {
 		case parser.ITermsContext:
@@ -451,6 +461,7 @@ func (l *cqlListener) EnterInsertStatement(ctx *parser.InsertStatementContext) {
 
 func (l *cqlListener) EnterUpdateStatement(ctx *parser.UpdateStatementContext) {
 	parsedStmt := &parsedStatement{statementIndex: l.currentBatchChildIndex, statementType: statementTypeUpdate}
+	l.lwt = l.lwt || ctx.K_IF() != nil
 
 	for _, childCtx := range ctx.GetChildren() {
 		switch childCtx.(type) {
@@ -480,6 +491,7 @@ func (l *cqlListener) EnterUpdateStatement(ctx *parser.UpdateStatementContext) {
 
 func (l *cqlListener) EnterDeleteStatement(ctx *parser.DeleteStatementContext) {
 	parsedStmt := &parsedStatement{statementIndex: l.currentBatchChildIndex, statementType: statementTypeDelete}
+	l.lwt = l.lwt || ctx.K_IF() != nil
 
 	for _, childCtx := range ctx.GetChildren() {
 		switch childCtx.(type) {
@@ -900,6 +912,7 @@ func (l *cqlListener) shallowClone() *cqlListener {
 		positionalBindMarkers:     l.positionalBindMarkers,
 		namedBindMarkers:          l.namedBindMarkers,
 		nowFunctionCalls:          l.nowFunctionCalls,
+		lwt:                       l.lwt,
 		currentPositionalIndex:    l.currentPositionalIndex,
 		currentBatchChildIndex:    l.currentBatchChildIndex,
 		timeUuidGenerator:         l.timeUuidGenerator,
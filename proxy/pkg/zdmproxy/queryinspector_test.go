@@ -590,3 +590,45 @@ type fakeTimeUuidGenerator struct {
 func (recv *fakeTimeUuidGenerator) GetTimeUuid() uuid.UUID {
 	return recv.uid
 }
+
+// FuzzInspectCqlQuery parses arbitrary CQL statements.
+//
+// Run it with: go test ./proxy/pkg/zdmproxy -run '^$' -fuzz FuzzInspectCqlQuery
+func FuzzInspectCqlQuery(f *testing.F) {
+	for _, query := range []string{
+		"SELECT * FROM ks.tb WHERE id = ?",
+		"SELECT a, now() AS b, count(*) FROM tb WHERE id = :id",
+		"INSERT INTO ks.tb (id, ts) VALUES (now(), ?) USING TTL 10 AND TIMESTAMP 1",
+		"UPDATE tb SET a = now(), b = b + [now()] WHERE id = ? IF EXISTS",
+		"DELETE FROM ks.tb WHERE id IN (now(), ?)",
+		"BEGIN BATCH INSERT INTO tb (id) VALUES (now()); APPLY BATCH",
+		"USE \"Ks\"",
+		"CREATE TABLE ks.tb (id uuid PRIMARY KEY)",
+		"SELECT * FROM system.peers",
+		"SELECT * FROM ks.tb WHERE id = 'unterminated",
+		"/* comment */ SELECT * FROM tb; -- comment",
+	} {
+		f.Add(query)
+	}
+
+	timeUuidGenerator, err := GetDefaultTimeUuidGenerator()
+	require.Nil(f, err)
+
+	f.Fuzz(func(t *testing.T, query string) {
+		queryInfo := inspectCqlQuery(query, "ks", timeUuidGenerator)
+		if queryInfo == nil {
+			t.Fatalf("nil query info for %q", query)
+		}
+		if queryInfo.hasPositionalBindMarkers() && queryInfo.hasNamedBindMarkers() {
+			t.Fatalf("query has both positional and named bind markers: %q", query)
+		}
+		if queryInfo.hasNowFunctionCalls() {
+			if queryInfo.hasNamedBindMarkers() {
+				_, _ = queryInfo.replaceNowFunctionCallsWithNamedBindMarkers()
+			} else {
+				_, _ = queryInfo.replaceNowFunctionCallsWithPositionalBindMarkers()
+			}
+			_, _ = queryInfo.replaceNowFunctionCallsWithLiteral()
+		}
+	})
+}
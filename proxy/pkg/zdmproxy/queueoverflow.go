@@ -0,0 +1,41 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+)
+
+// reserveWriteQueues returns false if the configured overflow policy rejects the request because the write queue of a
+// cluster connection that it would be sent to is full. Otherwise room is reserved in the queues of every destination
+// (if the policy applies to the request) until the returned function is called, which must happen after the request
+// was enqueued. The queues are checked and reserved atomically so that a write is never applied on a single cluster
+// because the other queue was filled by a concurrent request.
+func (ch *ClientHandler) reserveWriteQueues(fwdDecision forwardDecision) (release func(), ok bool) {
+	switch ch.requestWriteQueueOverflowPolicy {
+	case common.QueueOverflowPolicyShed:
+	case common.QueueOverflowPolicyPreferReads:
+		if fwdDecision != forwardToBoth {
+			return func() {}, true
+		}
+	default:
+		return func() {}, true
+	}
+
+	var coalescers []*writeCoalescer
+	switch fwdDecision {
+	case forwardToBoth:
+		coalescers = []*writeCoalescer{
+			ch.originCassandraConnector.writeCoalescer, ch.targetCassandraConnector.writeCoalescer}
+	case forwardToOrigin:
+		coalescers = []*writeCoalescer{ch.originCassandraConnector.writeCoalescer}
+	case forwardToTarget:
+		coalescers = []*writeCoalescer{ch.targetCassandraConnector.writeCoalescer}
+	default:
+		return func() {}, true
+	}
+	if !tryReserveWriteQueues(coalescers...) {
+		return nil, false
+	}
+	return func() {
+		releaseWriteQueues(coalescers...)
+	}, true
+}
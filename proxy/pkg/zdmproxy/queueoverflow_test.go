@@ -0,0 +1,94 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestClientHandler_ReserveWriteQueues(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      common.QueueOverflowPolicy
+		fwdDecision forwardDecision
+		originFull  bool
+		targetFull  bool
+		expected    bool
+	}{
+		{"block, write, queues full", common.QueueOverflowPolicyBlock, forwardToBoth, true, true, false},
+		{"block, read, queue full", common.QueueOverflowPolicyBlock, forwardToOrigin, true, true, false},
+		{"shed, write, origin full", common.QueueOverflowPolicyShed, forwardToBoth, true, false, true},
+		{"shed, write, target full", common.QueueOverflowPolicyShed, forwardToBoth, false, true, true},
+		{"shed, write, queues not full", common.QueueOverflowPolicyShed, forwardToBoth, false, false, false},
+		{"shed, origin read, origin full", common.QueueOverflowPolicyShed, forwardToOrigin, true, false, true},
+		{"shed, origin read, target full", common.QueueOverflowPolicyShed, forwardToOrigin, false, true, false},
+		{"shed, target read, target full", common.QueueOverflowPolicyShed, forwardToTarget, false, true, true},
+		{"shed, async only", common.QueueOverflowPolicyShed, forwardToAsyncOnly, true, true, false},
+		{"prefer reads, write, target full", common.QueueOverflowPolicyPreferReads, forwardToBoth, false, true, true},
+		{"prefer reads, read, queue full", common.QueueOverflowPolicyPreferReads, forwardToOrigin, true, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := &ClientHandler{
+				originCassandraConnector:        newClusterConnectorWithWriteQueue(tt.originFull),
+				targetCassandraConnector:        newClusterConnectorWithWriteQueue(tt.targetFull),
+				requestWriteQueueOverflowPolicy: tt.policy,
+			}
+			release, reserved := ch.reserveWriteQueues(tt.fwdDecision)
+			require.Equal(t, tt.expected, !reserved)
+			if reserved {
+				release()
+			}
+			require.Equal(t, 0, ch.originCassandraConnector.writeCoalescer.reserved)
+			require.Equal(t, 0, ch.targetCassandraConnector.writeCoalescer.reserved)
+		})
+	}
+}
+
+func TestTryReserveWriteQueues(t *testing.T) {
+	origin := newClusterConnectorWithWriteQueue(false).writeCoalescer
+	target := newClusterConnectorWithWriteQueue(false).writeCoalescer
+
+	// the room of a queue can only be reserved once and a full queue reserves nothing in the other one
+	require.True(t, tryReserveWriteQueues(origin, target))
+	require.False(t, tryReserveWriteQueues(origin, target))
+	require.False(t, tryReserveWriteQueues(target))
+	require.Equal(t, 1, origin.reserved)
+	require.Equal(t, 1, target.reserved)
+
+	origin.writeQueue <- &frame.RawFrame{}
+	target.writeQueue <- &frame.RawFrame{}
+	releaseWriteQueues(origin, target)
+	require.False(t, tryReserveWriteQueues(origin))
+	<-origin.writeQueue
+	<-target.writeQueue
+	require.True(t, tryReserveWriteQueues(origin, target))
+
+	// concurrent requests never reserve more room than the queues have
+	releaseWriteQueues(origin, target)
+	reservations := int32(0)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if tryReserveWriteQueues(origin, target) {
+				atomic.AddInt32(&reservations, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	require.Equal(t, int32(1), reservations)
+}
+
+func newClusterConnectorWithWriteQueue(full bool) *ClusterConnector {
+	writeQueue := make(chan *frame.RawFrame, 1)
+	if full {
+		writeQueue <- &frame.RawFrame{}
+	}
+	return &ClusterConnector{writeCoalescer: &writeCoalescer{writeQueue: writeQueue, reservationLock: &sync.Mutex{}}}
+}
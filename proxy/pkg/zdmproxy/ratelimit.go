@@ -0,0 +1,67 @@
+package zdmproxy
+
+import (
+	"sync"
+	"time"
+)
+
+// requestRateLimiter is a token bucket that limits the number of requests per second that the proxy forwards. The
+// bucket holds up to one second of requests so short bursts above the rate are allowed. The rate can be changed while
+// the proxy is running (e.g. by the shared configuration), a rate of 0 disables the limit.
+type requestRateLimiter struct {
+	lock   *sync.Mutex
+	rate   float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+func newRequestRateLimiter(rate int, now func() time.Time) *requestRateLimiter {
+	return &requestRateLimiter{
+		lock:   &sync.Mutex{},
+		rate:   float64(rate),
+		tokens: float64(rate),
+		last:   now(),
+		now:    now,
+	}
+}
+
+// Allow returns false if the request should be rejected, it's safe to call on a nil limiter (rate limiting disabled).
+func (l *requestRateLimiter) Allow() bool {
+	if l == nil {
+		return true
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.rate <= 0 {
+		return true
+	}
+	now := l.now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	l.last = now
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+func (l *requestRateLimiter) SetRate(rate int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if float64(rate) == l.rate {
+		return
+	}
+	l.rate = float64(rate)
+	l.tokens = l.rate
+	l.last = l.now()
+}
+
+func (l *requestRateLimiter) GetRate() int {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return int(l.rate)
+}
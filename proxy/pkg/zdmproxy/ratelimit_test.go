@@ -0,0 +1,47 @@
+package zdmproxy
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRequestRateLimiter_Allow(t *testing.T) {
+	now := time.Unix(1000, 0)
+	limiter := newRequestRateLimiter(10, func() time.Time {
+		return now
+	})
+
+	allowed := func(n int) int {
+		count := 0
+		for i := 0; i < n; i++ {
+			if limiter.Allow() {
+				count++
+			}
+		}
+		return count
+	}
+
+	// burst of one second of requests
+	require.Equal(t, 10, allowed(20))
+
+	now = now.Add(500 * time.Millisecond)
+	require.Equal(t, 5, allowed(20))
+
+	// the bucket doesn't hold more than one second of requests
+	now = now.Add(time.Minute)
+	require.Equal(t, 10, allowed(20))
+
+	limiter.SetRate(0)
+	require.Equal(t, 20, allowed(20))
+	require.Equal(t, 0, limiter.GetRate())
+
+	limiter.SetRate(2)
+	require.Equal(t, 2, allowed(20))
+	require.Equal(t, 2, limiter.GetRate())
+}
+
+func TestRequestRateLimiter_Disabled(t *testing.T) {
+	var limiter *requestRateLimiter
+	require.True(t, limiter.Allow())
+}
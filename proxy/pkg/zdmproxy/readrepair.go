@@ -0,0 +1,167 @@
+package zdmproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+	"time"
+)
+
+const readRepairTimeout = 10 * time.Second
+
+// ReadRepairSource is implemented by a ReadVerifier that can tell which rows are missing or stale on Target,
+// ZDM_READ_REPAIR_TO_TARGET_ENABLED requires it.
+type ReadRepairSource interface {
+	// SetTargetRepairer is called once when the proxy starts, the verifier then calls the repairer with the version of
+	// Origin of each row that it found missing or stale on Target.
+	SetTargetRepairer(repairer TargetRepairer)
+}
+
+// TargetRepairer writes the version of Origin of a row to Target, see ReadRepairSource.
+type TargetRepairer func(ctx context.Context, row *RowRepair) error
+
+// RowRepair is the version of Origin of a row that the read verifier found missing or stale on Target.
+type RowRepair struct {
+	Keyspace string
+	Table    string
+	// PrimaryKey holds the partition key and clustering columns of the row.
+	PrimaryKey []*RepairedColumn
+	// Columns holds the regular columns of the row, the columns that are null on Origin are skipped because they
+	// don't have a write time.
+	Columns []*RepairedColumn
+}
+
+// RepairedColumn is a column value serialized like the values of a result set along with its write time on Origin
+// (WRITETIME, in microseconds), which is ignored for the primary key columns.
+type RepairedColumn struct {
+	Name      string
+	Value     []byte
+	WriteTime int64
+}
+
+// targetReadRepairer turns the read verification into active reconciliation when ZDM_READ_REPAIR_TO_TARGET_ENABLED is
+// true: the rows that the verifier reports are written to Target through the Target control connection with the write
+// times of Origin, so a write that Target received in the meantime is never overwritten. The regular columns are
+// grouped by write time and each group is written with its own INSERT, a row without regular columns is inserted with
+// the current time since the primary key has no write time.
+type targetReadRepairer struct {
+	targetControlConn *ControlConn
+	metricHandler     *metrics.MetricHandler
+}
+
+func newTargetReadRepairer(targetControlConn *ControlConn, metricHandler *metrics.MetricHandler) *targetReadRepairer {
+	return &targetReadRepairer{
+		targetControlConn: targetControlConn,
+		metricHandler:     metricHandler,
+	}
+}
+
+func (r *targetReadRepairer) repair(ctx context.Context, row *RowRepair) error {
+	msgs, err := buildReadRepairMessages(row)
+	if err != nil {
+		r.metricHandler.GetProxyMetrics().ReadRepairFailures.Add(1)
+		return err
+	}
+
+	ctx, cancelFn := context.WithTimeout(ctx, readRepairTimeout)
+	defer cancelFn()
+	for _, msg := range msgs {
+		response, err := r.targetControlConn.Execute(msg, ctx)
+		if err = checkTargetWriteResponse(response, err); err != nil {
+			r.metricHandler.GetProxyMetrics().ReadRepairFailures.Add(1)
+			return fmt.Errorf("could not repair a row of %v.%v on %v: %w",
+				row.Keyspace, row.Table, common.ClusterTypeTarget, err)
+		}
+	}
+	log.Tracef("Repaired a row of %v.%v on %v with %d statement(s).",
+		row.Keyspace, row.Table, common.ClusterTypeTarget, len(msgs))
+	r.metricHandler.GetProxyMetrics().ReadRepairs.Add(1)
+	return nil
+}
+
+// buildReadRepairMessages returns the INSERT statements that write a row to Target, one per write time of its regular
+// columns.
+func buildReadRepairMessages(row *RowRepair) ([]*message.Query, error) {
+	if row.Keyspace == "" || row.Table == "" {
+		return nil, errors.New("could not repair a row: the keyspace and the table are required")
+	}
+	if len(row.PrimaryKey) == 0 {
+		return nil, fmt.Errorf("could not repair a row of %v.%v: the primary key is required", row.Keyspace, row.Table)
+	}
+	for _, column := range row.PrimaryKey {
+		if column.Value == nil {
+			return nil, fmt.Errorf("could not repair a row of %v.%v: the primary key column %v is null",
+				row.Keyspace, row.Table, column.Name)
+		}
+	}
+
+	columnsByWriteTime := map[int64][]*RepairedColumn{}
+	for _, column := range row.Columns {
+		if column.Value != nil {
+			columnsByWriteTime[column.WriteTime] = append(columnsByWriteTime[column.WriteTime], column)
+		}
+	}
+	if len(columnsByWriteTime) == 0 {
+		return []*message.Query{buildReadRepairInsert(row, nil, nil)}, nil
+	}
+
+	writeTimes := make([]int64, 0, len(columnsByWriteTime))
+	for writeTime := range columnsByWriteTime {
+		writeTimes = append(writeTimes, writeTime)
+	}
+	sort.Slice(writeTimes, func(i, j int) bool {
+		return writeTimes[i] < writeTimes[j]
+	})
+	msgs := make([]*message.Query, 0, len(writeTimes))
+	for _, writeTime := range writeTimes {
+		msgs = append(msgs, buildReadRepairInsert(
+			row, columnsByWriteTime[writeTime], &primitive.NillableInt64{Value: writeTime}))
+	}
+	return msgs, nil
+}
+
+func buildReadRepairInsert(
+	row *RowRepair, columns []*RepairedColumn, writeTime *primitive.NillableInt64) *message.Query {
+	names := make([]string, 0, len(row.PrimaryKey)+len(columns))
+	values := make([]*primitive.Value, 0, len(row.PrimaryKey)+len(columns))
+	for _, column := range append(append([]*RepairedColumn{}, row.PrimaryKey...), columns...) {
+		names = append(names, quoteIdentifier(column.Name))
+		values = append(values, primitive.NewValue(column.Value))
+	}
+	return &message.Query{
+		Query: fmt.Sprintf("INSERT INTO %v.%v (%v) VALUES (%v)", quoteIdentifier(row.Keyspace),
+			quoteIdentifier(row.Table), strings.Join(names, ", "), strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", ")),
+		Options: &message.QueryOptions{
+			Consistency:      primitive.ConsistencyLevelLocalQuorum,
+			PositionalValues: values,
+			DefaultTimestamp: writeTime,
+		},
+	}
+}
+
+func quoteIdentifier(name string) string {
+	return fmt.Sprintf("\"%s\"", strings.ReplaceAll(name, "\"", "\"\""))
+}
+
+// initializeReadRepair hands the repairer of ZDM_READ_REPAIR_TO_TARGET_ENABLED to the read verifier.
+func (p *ZdmProxy) initializeReadRepair() error {
+	if !p.Conf.ReadRepairToTargetEnabled {
+		return nil
+	}
+	source, ok := p.extensions.ReadVerifier.(ReadRepairSource)
+	if !ok {
+		return errors.New("ZDM_READ_REPAIR_TO_TARGET_ENABLED is true but there is no read verifier " +
+			"(Extensions.ReadVerifier) that reports the rows to repair (ReadRepairSource)")
+	}
+	source.SetTargetRepairer(newTargetReadRepairer(p.targetControlConn, p.metricHandler).repair)
+	log.Infof("Read repair to %v is enabled, the rows that the read verifier reports are written to %v.",
+		common.ClusterTypeTarget, common.ClusterTypeTarget)
+	return nil
+}
@@ -0,0 +1,95 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestBuildReadRepairMessages(t *testing.T) {
+	pk := []*RepairedColumn{{Name: "id", Value: []byte{0, 0, 0, 1}}, {Name: "ck", Value: []byte("a")}}
+	tests := []struct {
+		name             string
+		row              *RowRepair
+		expectedMessages []*message.Query
+		errMsg           string
+	}{
+		{
+			name: "columns grouped by write time",
+			row: &RowRepair{
+				Keyspace:   "ks",
+				Table:      "tb",
+				PrimaryKey: pk,
+				Columns: []*RepairedColumn{
+					{Name: "v1", Value: []byte("x"), WriteTime: 2000},
+					{Name: "V2", Value: []byte("y"), WriteTime: 1000},
+					{Name: "v3", Value: []byte("z"), WriteTime: 2000},
+					{Name: "v4", Value: nil, WriteTime: 3000},
+				},
+			},
+			expectedMessages: []*message.Query{
+				{
+					Query: `INSERT INTO "ks"."tb" ("id", "ck", "V2") VALUES (?, ?, ?)`,
+					Options: &message.QueryOptions{
+						Consistency: primitive.ConsistencyLevelLocalQuorum,
+						PositionalValues: []*primitive.Value{
+							primitive.NewValue([]byte{0, 0, 0, 1}), primitive.NewValue([]byte("a")), primitive.NewValue([]byte("y"))},
+						DefaultTimestamp: &primitive.NillableInt64{Value: 1000},
+					},
+				},
+				{
+					Query: `INSERT INTO "ks"."tb" ("id", "ck", "v1", "v3") VALUES (?, ?, ?, ?)`,
+					Options: &message.QueryOptions{
+						Consistency: primitive.ConsistencyLevelLocalQuorum,
+						PositionalValues: []*primitive.Value{
+							primitive.NewValue([]byte{0, 0, 0, 1}), primitive.NewValue([]byte("a")),
+							primitive.NewValue([]byte("x")), primitive.NewValue([]byte("z"))},
+						DefaultTimestamp: &primitive.NillableInt64{Value: 2000},
+					},
+				},
+			},
+		},
+		{
+			name: "primary key only",
+			row:  &RowRepair{Keyspace: "ks", Table: `t"b`, PrimaryKey: pk[:1]},
+			expectedMessages: []*message.Query{
+				{
+					Query: `INSERT INTO "ks"."t""b" ("id") VALUES (?)`,
+					Options: &message.QueryOptions{
+						Consistency:      primitive.ConsistencyLevelLocalQuorum,
+						PositionalValues: []*primitive.Value{primitive.NewValue([]byte{0, 0, 0, 1})},
+					},
+				},
+			},
+		},
+		{
+			name:   "missing table",
+			row:    &RowRepair{Keyspace: "ks", PrimaryKey: pk},
+			errMsg: "could not repair a row: the keyspace and the table are required",
+		},
+		{
+			name:   "missing primary key",
+			row:    &RowRepair{Keyspace: "ks", Table: "tb", Columns: []*RepairedColumn{{Name: "v", Value: []byte("x")}}},
+			errMsg: "could not repair a row of ks.tb: the primary key is required",
+		},
+		{
+			name:   "null primary key column",
+			row:    &RowRepair{Keyspace: "ks", Table: "tb", PrimaryKey: []*RepairedColumn{{Name: "id"}}},
+			errMsg: "could not repair a row of ks.tb: the primary key column id is null",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msgs, err := buildReadRepairMessages(tt.row)
+			if tt.errMsg != "" {
+				require.NotNil(t, err)
+				require.Equal(t, tt.errMsg, err.Error())
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedMessages, msgs)
+		})
+	}
+}
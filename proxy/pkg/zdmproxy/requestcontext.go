@@ -5,6 +5,7 @@ import (
 	"github.com/datastax/go-cassandra-native-protocol/frame"
 	"github.com/datastax/zdm-proxy/proxy/pkg/common"
 	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	"github.com/datastax/zdm-proxy/proxy/pkg/mutationexport"
 	log "github.com/sirupsen/logrus"
 	"sync"
 	"time"
@@ -99,6 +100,52 @@ type requestContextImpl struct {
 	lock                  *sync.Mutex
 	startTime             time.Time
 	customResponseChannel chan *customResponse
+
+	// requests that were actually sent to each cluster (they can differ from the client request, e.g. EXECUTE)
+	originRequest *frame.RawFrame
+	targetRequest *frame.RawFrame
+
+	originConsistencyDowngrade *consistencyDowngrade
+	targetConsistencyDowngrade *consistencyDowngrade
+
+	// hedged reads are sent to both clusters, see hedgedread.go
+	hedged                  bool
+	clientStreamId          int16
+	hedgedStreamId          int16
+	hedgedResponsesReceived int
+	hedgedReadReleased      bool
+
+	// dual-written mutations that are exported when both clusters respond, see mutationexport.go
+	exportedMutations []*mutationexport.Mutation
+
+	// warnings that are added to the client response, see originonly.go
+	responseWarnings []string
+
+	// statement that is accounted for each cluster response, see heavyhitters.go and statementmetrics.go
+	statement        *statementFingerprint
+	heavyHitters     *heavyHitterTracker
+	statementMetrics *statementMetricsTracker
+
+	// nil unless the payload sizes are recorded, see payloadsizemetrics.go
+	payloadSizeMetrics *payloadSizeMetrics
+	// only set for the payload size metrics and the dual write response policy
+	statementType statementType
+
+	// whether the request is a lightweight transaction, only checked when an lwtHandler is configured
+	lwt bool
+
+	// clusters that are tracked by the in-flight requests node metrics until they return a response
+	originInFlight bool
+	targetInFlight bool
+
+	// identical PREPAREs that wait for the response of this one, see preparecoalescing.go
+	prepareCall *prepareCall
+
+	// the request is not retried after this time, it's zero if there is no deadline (see requestdeadline.go)
+	deadline time.Time
+
+	// nil unless the response of the read is compared with the response of the async connector
+	asyncReadComparison *asyncReadComparison
 }
 
 func NewRequestContext(req *frame.RawFrame, requestInfo RequestInfo, startTime time.Time, customResponseChannel chan *customResponse) *requestContextImpl {
@@ -123,6 +170,141 @@ func (recv *requestContextImpl) SetTimer(timer *time.Timer) {
 	recv.timer = timer
 }
 
+// SetHedged marks this request as a hedged read. The request frame of this context must have the hedged read stream id
+// while the client stream id is restored on the responses.
+func (recv *requestContextImpl) SetHedged(clientStreamId int16, hedgedStreamId int16) {
+	recv.hedged = true
+	recv.clientStreamId = clientStreamId
+	recv.hedgedStreamId = hedgedStreamId
+}
+
+// TryReleaseHedgedRead returns true if the stream id of this hedged read can be released, i.e., the request is done and
+// both clusters returned a response (or force is true). It only returns true once.
+func (recv *requestContextImpl) TryReleaseHedgedRead(force bool) bool {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+
+	if !recv.hedged || recv.hedgedReadReleased || recv.state == RequestPending {
+		return false
+	}
+
+	if force || recv.state != RequestDone || recv.hedgedResponsesReceived >= 2 {
+		recv.hedgedReadReleased = true
+		return true
+	}
+	return false
+}
+
+// SetInFlight increments the in-flight requests node metrics of the clusters that the request is about to be sent to,
+// they are decremented when the respective cluster returns a response or when the request is no longer pending.
+func (recv *requestContextImpl) SetInFlight(nodeMetrics *metrics.NodeMetrics, sentOrigin bool, sentTarget bool) {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+
+	if sentOrigin {
+		nodeMetrics.OriginMetrics.InFlightRequests.Add(1)
+	}
+	if sentTarget {
+		nodeMetrics.TargetMetrics.InFlightRequests.Add(1)
+	}
+	recv.originInFlight = sentOrigin
+	recv.targetInFlight = sentTarget
+}
+
+// should only be called while holding the lock
+func (recv *requestContextImpl) releaseInFlight(nodeMetrics *metrics.NodeMetrics, origin bool, target bool) {
+	if origin && recv.originInFlight {
+		nodeMetrics.OriginMetrics.InFlightRequests.Subtract(1)
+		recv.originInFlight = false
+	}
+	if target && recv.targetInFlight {
+		nodeMetrics.TargetMetrics.InFlightRequests.Subtract(1)
+		recv.targetInFlight = false
+	}
+}
+
+func (recv *requestContextImpl) SetClusterRequests(originRequest *frame.RawFrame, targetRequest *frame.RawFrame) {
+	recv.originRequest = originRequest
+	recv.targetRequest = targetRequest
+}
+
+func (recv *requestContextImpl) SetStatement(
+	statement *statementFingerprint, heavyHitters *heavyHitterTracker, statementMetrics *statementMetricsTracker) {
+	recv.statement = statement
+	recv.heavyHitters = heavyHitters
+	recv.statementMetrics = statementMetrics
+}
+
+func (recv *requestContextImpl) SetStatementType(stmtType statementType, payloadSizeMetrics *payloadSizeMetrics) {
+	recv.statementType = stmtType
+	recv.payloadSizeMetrics = payloadSizeMetrics
+}
+
+func (recv *requestContextImpl) SetExportedMutations(mutations []*mutationexport.Mutation) {
+	recv.exportedMutations = mutations
+}
+
+func (recv *requestContextImpl) SetResponseWarnings(warnings []string) {
+	recv.responseWarnings = warnings
+}
+
+func (recv *requestContextImpl) GetClusterRequest(cluster common.ClusterType) *frame.RawFrame {
+	switch cluster {
+	case common.ClusterTypeOrigin:
+		return recv.originRequest
+	case common.ClusterTypeTarget:
+		return recv.targetRequest
+	default:
+		return nil
+	}
+}
+
+// SetConsistencyDowngrade records that the request will be retried on the provided cluster with a lower consistency level.
+// Returns false if the request is no longer pending or if it was already retried on that cluster.
+func (recv *requestContextImpl) SetConsistencyDowngrade(cluster common.ClusterType, downgrade *consistencyDowngrade) bool {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+
+	if recv.state != RequestPending {
+		return false
+	}
+
+	switch cluster {
+	case common.ClusterTypeOrigin:
+		if recv.originConsistencyDowngrade != nil {
+			return false
+		}
+		recv.originConsistencyDowngrade = downgrade
+	case common.ClusterTypeTarget:
+		if recv.targetConsistencyDowngrade != nil {
+			return false
+		}
+		recv.targetConsistencyDowngrade = downgrade
+	default:
+		return false
+	}
+	return true
+}
+
+// GetConsistencyDowngrades returns the cluster types on which the request was retried with a lower consistency
+// level along with the respective downgrades. Should only be called after the request is done.
+func (recv *requestContextImpl) GetConsistencyDowngrades() map[common.ClusterType]*consistencyDowngrade {
+	recv.lock.Lock()
+	defer recv.lock.Unlock()
+
+	var downgrades map[common.ClusterType]*consistencyDowngrade
+	if recv.originConsistencyDowngrade != nil || recv.targetConsistencyDowngrade != nil {
+		downgrades = make(map[common.ClusterType]*consistencyDowngrade)
+		if recv.originConsistencyDowngrade != nil {
+			downgrades[common.ClusterTypeOrigin] = recv.originConsistencyDowngrade
+		}
+		if recv.targetConsistencyDowngrade != nil {
+			downgrades[common.ClusterTypeTarget] = recv.targetConsistencyDowngrade
+		}
+	}
+	return downgrades
+}
+
 func (recv *requestContextImpl) SetTimeout(nodeMetrics *metrics.NodeMetrics, req *frame.RawFrame) bool {
 	recv.lock.Lock()
 	defer recv.lock.Unlock()
@@ -135,6 +317,7 @@ func (recv *requestContextImpl) SetTimeout(nodeMetrics *metrics.NodeMetrics, req
 	// check if it's the same request (could be a timeout for a previous one that has since completed)
 	if recv.request == req {
 		recv.state = RequestTimedOut
+		recv.releaseInFlight(nodeMetrics, true, true)
 		if recv.requestInfo.ShouldBeTrackedInMetrics() {
 			sentOrigin := false
 			sentTarget := false
@@ -147,6 +330,10 @@ func (recv *requestContextImpl) SetTimeout(nodeMetrics *metrics.NodeMetrics, req
 			case forwardToTarget:
 				sentTarget = true
 			}
+			if recv.hedged {
+				sentOrigin = true
+				sentTarget = true
+			}
 			if sentOrigin && recv.originResponse == nil {
 				nodeMetrics.OriginMetrics.ClientTimeouts.Add(1)
 			}
@@ -160,7 +347,7 @@ func (recv *requestContextImpl) SetTimeout(nodeMetrics *metrics.NodeMetrics, req
 	return false
 }
 
-func (recv *requestContextImpl) Cancel(_ *metrics.NodeMetrics) bool {
+func (recv *requestContextImpl) Cancel(nodeMetrics *metrics.NodeMetrics) bool {
 	recv.lock.Lock()
 	defer recv.lock.Unlock()
 
@@ -170,6 +357,7 @@ func (recv *requestContextImpl) Cancel(_ *metrics.NodeMetrics) bool {
 	}
 
 	recv.state = RequestCanceled
+	recv.releaseInFlight(nodeMetrics, true, true)
 	if recv.timer != nil {
 		recv.timer.Stop()
 	}
@@ -178,7 +366,7 @@ func (recv *requestContextImpl) Cancel(_ *metrics.NodeMetrics) bool {
 
 func (recv *requestContextImpl) SetResponse(nodeMetrics *metrics.NodeMetrics, f *frame.RawFrame,
 	cluster common.ClusterType, connectorType ClusterConnectorType) bool {
-	state, updated := recv.updateInternalState(f, cluster)
+	state, updated := recv.updateInternalState(nodeMetrics, f, cluster)
 	if !updated {
 		return false
 	}
@@ -200,15 +388,24 @@ func (recv *requestContextImpl) SetResponse(nodeMetrics *metrics.NodeMetrics, f
 		default:
 			log.Errorf("could not recognize connector type %v", connectorType)
 		}
+		recv.heavyHitters.record(connectorType, recv.statement, time.Since(recv.startTime))
+		recv.statementMetrics.record(connectorType, recv.statement, f, recv.startTime)
+		recv.payloadSizeMetrics.recordResponse(connectorType, recv.statementType, f)
 	}
 
 	return finished
 }
 
-func (recv *requestContextImpl) updateInternalState(f *frame.RawFrame, cluster common.ClusterType) (state int, updated bool) {
+func (recv *requestContextImpl) updateInternalState(
+	nodeMetrics *metrics.NodeMetrics, f *frame.RawFrame, cluster common.ClusterType) (state int, updated bool) {
 	recv.lock.Lock()
 	defer recv.lock.Unlock()
 
+	if recv.hedged {
+		recv.hedgedResponsesReceived++
+		f = setRawFrameStreamId(f, recv.clientStreamId)
+	}
+
 	if recv.state != RequestPending {
 		// already done
 		return recv.state, false
@@ -217,8 +414,10 @@ func (recv *requestContextImpl) updateInternalState(f *frame.RawFrame, cluster c
 	switch cluster {
 	case common.ClusterTypeOrigin:
 		recv.originResponse = f
+		recv.releaseInFlight(nodeMetrics, true, false)
 	case common.ClusterTypeTarget:
 		recv.targetResponse = f
+		recv.releaseInFlight(nodeMetrics, false, true)
 	default:
 		log.Errorf("could not recognize cluster type %v", cluster)
 	}
@@ -239,8 +438,14 @@ func (recv *requestContextImpl) updateInternalState(f *frame.RawFrame, cluster c
 		log.Errorf("unrecognized decision %v", recv.requestInfo.GetForwardDecision())
 	}
 
+	if recv.hedged {
+		// the first successful response wins, an error is only returned if both clusters fail
+		done = isResponseSuccessful(f) || (recv.originResponse != nil && recv.targetResponse != nil)
+	}
+
 	if done {
 		recv.state = RequestDone
+		recv.releaseInFlight(nodeMetrics, true, true)
 	}
 
 	return recv.state, true
@@ -254,6 +459,9 @@ type asyncRequestContextImpl struct {
 	expectedResponse bool
 	startTime        time.Time
 	requestInfo      RequestInfo
+
+	// compares the response with the response of the primary cluster, see asyncreadcomparison.go
+	readComparison *asyncReadComparison
 }
 
 func NewAsyncRequestContext(requestInfo RequestInfo, streamId int16, expectedResponse bool, startTime time.Time) *asyncRequestContextImpl {
@@ -0,0 +1,96 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestRequestContext_InFlightRequests(t *testing.T) {
+	response, err := defaultCodec.ConvertToRawFrame(frame.NewFrame(primitive.ProtocolVersion4, 1, &message.VoidResult{}))
+	require.Nil(t, err)
+
+	tests := []struct {
+		name      string
+		decision  forwardDecision
+		complete  func(reqCtx *requestContextImpl, nodeMetrics *metrics.NodeMetrics, request *frame.RawFrame)
+		remaining [2]int
+	}{
+		{
+			name:     "response of the first cluster",
+			decision: forwardToBoth,
+			complete: func(reqCtx *requestContextImpl, nodeMetrics *metrics.NodeMetrics, _ *frame.RawFrame) {
+				reqCtx.SetResponse(nodeMetrics, response, common.ClusterTypeTarget, ClusterConnectorTypeTarget)
+			},
+			remaining: [2]int{1, 0},
+		},
+		{
+			name:     "responses of both clusters",
+			decision: forwardToBoth,
+			complete: func(reqCtx *requestContextImpl, nodeMetrics *metrics.NodeMetrics, _ *frame.RawFrame) {
+				reqCtx.SetResponse(nodeMetrics, response, common.ClusterTypeTarget, ClusterConnectorTypeTarget)
+				reqCtx.SetResponse(nodeMetrics, response, common.ClusterTypeOrigin, ClusterConnectorTypeOrigin)
+			},
+			remaining: [2]int{0, 0},
+		},
+		{
+			name:     "timeout",
+			decision: forwardToBoth,
+			complete: func(reqCtx *requestContextImpl, nodeMetrics *metrics.NodeMetrics, request *frame.RawFrame) {
+				reqCtx.SetResponse(nodeMetrics, response, common.ClusterTypeOrigin, ClusterConnectorTypeOrigin)
+				reqCtx.SetTimeout(nodeMetrics, request)
+				reqCtx.SetResponse(nodeMetrics, response, common.ClusterTypeTarget, ClusterConnectorTypeTarget)
+			},
+			remaining: [2]int{0, 0},
+		},
+		{
+			name:     "cancel",
+			decision: forwardToOrigin,
+			complete: func(reqCtx *requestContextImpl, nodeMetrics *metrics.NodeMetrics, _ *frame.RawFrame) {
+				reqCtx.Cancel(nodeMetrics)
+			},
+			remaining: [2]int{0, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originInFlight := &countingGauge{}
+			targetInFlight := &countingGauge{}
+			nodeMetrics := &metrics.NodeMetrics{
+				OriginMetrics: &metrics.NodeMetricsInstance{
+					InFlightRequests: originInFlight, ClientTimeouts: newFakeCounter(), RequestDuration: newFakeHistogram()},
+				TargetMetrics: &metrics.NodeMetricsInstance{
+					InFlightRequests: targetInFlight, ClientTimeouts: newFakeCounter(), RequestDuration: newFakeHistogram()},
+			}
+			request := &frame.RawFrame{Header: &frame.Header{
+				Version: primitive.ProtocolVersion4, StreamId: 1, OpCode: primitive.OpCodeQuery}}
+			reqCtx := NewRequestContext(request, NewGenericRequestInfo(tt.decision, true, false), time.Now(), nil)
+
+			reqCtx.SetInFlight(nodeMetrics, tt.decision != forwardToTarget, tt.decision != forwardToOrigin)
+			tt.complete(reqCtx, nodeMetrics, request)
+			require.Equal(t, tt.remaining, [2]int{originInFlight.value, targetInFlight.value})
+		})
+	}
+}
+
+type countingGauge struct {
+	value int
+}
+
+func (recv *countingGauge) Add(valueToAdd int) {
+	recv.value += valueToAdd
+}
+
+func (recv *countingGauge) Subtract(valueToSubtract int) {
+	recv.value -= valueToSubtract
+}
+
+func (recv *countingGauge) Set(valueToSet int) {
+	recv.value = valueToSet
+}
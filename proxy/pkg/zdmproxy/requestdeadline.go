@@ -0,0 +1,30 @@
+package zdmproxy
+
+import (
+	"time"
+)
+
+// getRequestBudget returns how long the proxy can still wait for a request, see config.Config.ProxyRequestDeadlineMs.
+func (ch *ClientHandler) getRequestBudget(
+	overallRequestStartTime time.Time, requestTimeout time.Duration) (budget time.Duration, limitedByDeadline bool) {
+	if ch.conf.ProxyRequestDeadlineMs <= 0 {
+		return requestTimeout, false
+	}
+	remaining := time.Until(overallRequestStartTime.Add(time.Duration(ch.conf.ProxyRequestDeadlineMs) * time.Millisecond))
+	if remaining >= requestTimeout {
+		return requestTimeout, false
+	}
+	if remaining < 0 {
+		remaining = 0
+	}
+	return remaining, true
+}
+
+// getRequestDeadline returns the time at which the proxy stops waiting for the responses of a request that was read
+// at overallRequestStartTime, it's the zero time if there is no deadline.
+func (ch *ClientHandler) getRequestDeadline(overallRequestStartTime time.Time) time.Time {
+	if ch.conf.ProxyRequestDeadlineMs <= 0 {
+		return time.Time{}
+	}
+	return overallRequestStartTime.Add(time.Duration(ch.conf.ProxyRequestDeadlineMs) * time.Millisecond)
+}
@@ -0,0 +1,43 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestGetRequestBudget(t *testing.T) {
+	tests := []struct {
+		name              string
+		deadlineMs        int
+		elapsed           time.Duration
+		maxBudget         time.Duration
+		minBudget         time.Duration
+		limitedByDeadline bool
+	}{
+		{name: "no deadline", elapsed: time.Hour, minBudget: 10 * time.Second, maxBudget: 10 * time.Second},
+		{name: "deadline longer than the request timeout", deadlineMs: 20000, elapsed: time.Second,
+			minBudget: 10 * time.Second, maxBudget: 10 * time.Second},
+		{name: "remaining deadline shorter than the request timeout", deadlineMs: 12000, elapsed: 4 * time.Second,
+			minBudget: 7 * time.Second, maxBudget: 8 * time.Second, limitedByDeadline: true},
+		{name: "deadline elapsed", deadlineMs: 12000, elapsed: 13 * time.Second, limitedByDeadline: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch := &ClientHandler{conf: &config.Config{ProxyRequestDeadlineMs: tt.deadlineMs}}
+			start := time.Now().Add(-tt.elapsed)
+			budget, limitedByDeadline := ch.getRequestBudget(start, 10*time.Second)
+			require.GreaterOrEqual(t, budget, tt.minBudget)
+			require.LessOrEqual(t, budget, tt.maxBudget)
+			require.Equal(t, tt.limitedByDeadline, limitedByDeadline)
+
+			if tt.deadlineMs == 0 {
+				require.True(t, ch.getRequestDeadline(start).IsZero())
+			} else {
+				require.Equal(t, start.Add(time.Duration(tt.deadlineMs)*time.Millisecond), ch.getRequestDeadline(start))
+			}
+		})
+	}
+}
@@ -106,18 +106,31 @@ func (recv *PrepareRequestInfo) ContainsPositionalMarkers() bool {
 }
 
 type ExecuteRequestInfo struct {
-	preparedData PreparedData
+	preparedData   PreparedData
+	routedDecision forwardDecision
 }
 
 func NewExecuteRequestInfo(preparedData PreparedData) *ExecuteRequestInfo {
 	return &ExecuteRequestInfo{preparedData: preparedData}
 }
 
+// NewRoutedExecuteRequestInfo returns the request info of a request that is sent to other clusters than the ones of its
+// prepared statement (e.g. a read routed by token range), it is not sent to the async connector.
+func NewRoutedExecuteRequestInfo(preparedData PreparedData, decision forwardDecision) *ExecuteRequestInfo {
+	return &ExecuteRequestInfo{preparedData: preparedData, routedDecision: decision}
+}
+
 func (recv *ExecuteRequestInfo) String() string {
+	if recv.routedDecision != "" {
+		return fmt.Sprintf("ExecuteRequestInfo{PreparedData: %v, routedDecision: %v}", recv.preparedData, recv.routedDecision)
+	}
 	return fmt.Sprintf("ExecuteRequestInfo{PreparedData: %v}", recv.preparedData)
 }
 
 func (recv *ExecuteRequestInfo) GetForwardDecision() forwardDecision {
+	if recv.routedDecision != "" {
+		return recv.routedDecision
+	}
 	return recv.preparedData.GetPrepareRequestInfo().GetBaseRequestInfo().GetForwardDecision()
 }
 
@@ -126,6 +139,9 @@ func (recv *ExecuteRequestInfo) GetPreparedData() PreparedData {
 }
 
 func (recv *ExecuteRequestInfo) ShouldAlsoBeSentAsync() bool {
+	if recv.routedDecision != "" {
+		return false
+	}
 	return recv.preparedData.GetPrepareRequestInfo().GetBaseRequestInfo().ShouldAlsoBeSentAsync()
 }
 
@@ -165,17 +181,31 @@ func (recv *InterceptedRequestInfo) GetParsedSelectClause() *selectClause {
 
 type BatchRequestInfo struct {
 	preparedDataByStmtIdx map[int]PreparedData
+	routedDecision        forwardDecision
 }
 
 func NewBatchRequestInfo(preparedDataByStmtIdx map[int]PreparedData) *BatchRequestInfo {
 	return &BatchRequestInfo{preparedDataByStmtIdx: preparedDataByStmtIdx}
 }
 
+// NewRoutedBatchRequestInfo returns the request info of a BATCH that is not sent to both clusters, e.g. a write that
+// is only sent to Origin by the target write sampling.
+func NewRoutedBatchRequestInfo(preparedDataByStmtIdx map[int]PreparedData, decision forwardDecision) *BatchRequestInfo {
+	return &BatchRequestInfo{preparedDataByStmtIdx: preparedDataByStmtIdx, routedDecision: decision}
+}
+
 func (recv *BatchRequestInfo) String() string {
+	if recv.routedDecision != "" {
+		return fmt.Sprintf("BatchRequestInfo{PreparedDataByStmtIdx: %v, routedDecision: %v}",
+			recv.preparedDataByStmtIdx, recv.routedDecision)
+	}
 	return fmt.Sprintf("BatchRequestInfo{PreparedDataByStmtIdx: %v}", recv.preparedDataByStmtIdx)
 }
 
 func (recv *BatchRequestInfo) GetForwardDecision() forwardDecision {
+	if recv.routedDecision != "" {
+		return recv.routedDecision
+	}
 	return forwardToBoth // always send BATCH to both, use origin's prepared IDs
 }
 
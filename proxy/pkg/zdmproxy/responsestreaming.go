@@ -0,0 +1,157 @@
+package zdmproxy
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"time"
+)
+
+// responseStreamer forwards the body of a response to the client while it is being read from the cluster connection.
+// It returns false if the response can't be streamed, in which case the body wasn't read and the response has
+// to go through the regular path.
+type responseStreamer func(header *frame.Header, body io.Reader, connectorType ClusterConnectorType) bool
+
+// readOrStreamResponse reads the next frame from the connection, large ROWS results are streamed to the client
+// instead of being buffered when possible. It returns a nil response (and a nil error) if the response was streamed.
+// If idReleased is true then the stream id of the returned response was already released.
+func (cc *ClusterConnector) readOrStreamResponse(
+	reader *bufio.Reader, connectionAddr string, streamingAllowed bool) (response *frame.RawFrame, idReleased bool, err error) {
+	header, err := readRawFrameHeader(reader, connectionAddr, cc.clusterConnContext)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if streamingAllowed && cc.isStreamingCandidate(header, reader) {
+		releasedFrame, releaseErr := cc.frameProcessor.ReleaseId(&frame.RawFrame{Header: header})
+		if releaseErr != nil {
+			log.Errorf("[%v] Error releasing stream id: %v.", string(cc.connectorType), releaseErr)
+		} else {
+			idReleased = true
+			header = releasedFrame.Header
+			body := &progressTrackingReader{
+				reader:    io.LimitReader(reader, int64(header.BodyLength)),
+				connector: cc,
+			}
+			if cc.responseStreamer(header, body, cc.connectorType) {
+				cc.trackReceivedFrame(true)
+				// the client connection might have been closed before the whole body was forwarded
+				_, err = io.Copy(io.Discard, body)
+				if err != nil {
+					return nil, true, adaptConnErr(
+						connectionAddr, cc.clusterConnContext, fmt.Errorf("cannot read frame body: %w", err))
+				}
+				return nil, true, nil
+			}
+		}
+	}
+
+	response, err = readRawFrameWithHeader(header, reader, connectionAddr, cc.clusterConnContext)
+	return response, idReleased, err
+}
+
+// isStreamingCandidate returns true if the frame is a ROWS result larger than the configured threshold that the proxy
+// doesn't need to decode, i.e. it has no flags (compression, tracing, warnings, etc.) and a supported protocol version.
+func (cc *ClusterConnector) isStreamingCandidate(header *frame.Header, reader *bufio.Reader) bool {
+	if cc.responseStreamer == nil || cc.asyncConnector {
+		return false
+	}
+	if header.OpCode != primitive.OpCodeResult || header.Flags != 0 || header.StreamId < 0 {
+		return false
+	}
+	if header.BodyLength <= int32(cc.conf.ResponseStreamingThresholdBytes) || header.BodyLength > maxFrameBodyLength {
+		return false
+	}
+	if checkProtocolVersion(header.Version) != nil {
+		return false
+	}
+	resultType, err := reader.Peek(4)
+	if err != nil {
+		return false
+	}
+	return primitive.ResultType(binary.BigEndian.Uint32(resultType)) == primitive.ResultTypeRows
+}
+
+// progressTrackingReader keeps the watchdog from closing a connection that is slowly streaming a large response.
+type progressTrackingReader struct {
+	reader    io.Reader
+	connector *ClusterConnector
+}
+
+func (r *progressTrackingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if n > 0 {
+		r.connector.lastProgressTime.Store(time.Now())
+	}
+	return n, err
+}
+
+// streamResponse is the responseStreamer of the origin and target connectors. Only responses of reads that are
+// forwarded to a single cluster and whose RESULT the proxy doesn't need to modify are streamed.
+func (ch *ClientHandler) streamResponse(header *frame.Header, body io.Reader, connectorType ClusterConnectorType) bool {
+	if isHedgedReadStreamId(header.StreamId) {
+		return false
+	}
+
+	var connector *ClusterConnector
+	var expectedForwardDecision forwardDecision
+	switch connectorType {
+	case ClusterConnectorTypeOrigin:
+		connector = ch.originCassandraConnector
+		expectedForwardDecision = forwardToOrigin
+	case ClusterConnectorTypeTarget:
+		connector = ch.targetCassandraConnector
+		expectedForwardDecision = forwardToTarget
+	default:
+		return false
+	}
+
+	holder := getOrCreateRequestContextHolder(ch.requestContextHolders, header.StreamId)
+	reqCtx, ok := holder.Get().(*requestContextImpl)
+	if !ok || !canStreamResponse(reqCtx, expectedForwardDecision) {
+		return false
+	}
+	response := &frame.RawFrame{Header: header}
+	if !reqCtx.SetResponse(ch.nodeMetrics, response, connector.clusterType, connectorType) {
+		return false
+	}
+
+	log.Tracef("Streaming response with stream id %d and %d bytes from %v to the client.",
+		header.StreamId, header.BodyLength, connectorType)
+	<-ch.clientConnector.writeCoalescer.EnqueueStreamed(header, body)
+	ch.metricHandler.GetProxyMetrics().StreamedResponses.Add(1)
+	ch.finishStreamedRequest(holder, reqCtx, response)
+	return true
+}
+
+// canStreamResponse returns false if the response of the request must go through finishRequest, i.e. the proxy needs
+// the responses of both clusters, modifies the response (consistency downgrade and guardrail warnings) or needs its body
+// after it's sent to the client (async read comparison and coalesced PREPARE requests).
+func canStreamResponse(reqCtx *requestContextImpl, expectedForwardDecision forwardDecision) bool {
+	return !reqCtx.hedged && reqCtx.customResponseChannel == nil &&
+		reqCtx.requestInfo.GetForwardDecision() == expectedForwardDecision &&
+		len(reqCtx.GetConsistencyDowngrades()) == 0 && len(reqCtx.responseWarnings) == 0 &&
+		reqCtx.asyncReadComparison == nil && reqCtx.prepareCall == nil
+}
+
+// should only be called after SetResponse returns true for a streamed response, the response only has a header
+func (ch *ClientHandler) finishStreamedRequest(
+	holder *requestContextHolder, reqCtx *requestContextImpl, response *frame.RawFrame) {
+	defer ch.clientHandlerRequestWaitGroup.Done()
+
+	err := holder.Clear(reqCtx)
+	if err != nil {
+		log.Debugf("Could not free stream id: %v", err)
+	}
+
+	ch.trackFinishedRequestMetrics(reqCtx)
+	ch.queryStats.track(reqCtx, response)
+
+	reqCtx.request = nil
+	reqCtx.originResponse = nil
+	reqCtx.targetResponse = nil
+}
@@ -0,0 +1,138 @@
+package zdmproxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWriteCoalescer_EnqueueStreamed(t *testing.T) {
+	serverConn, clientConn := net.Pipe()
+	defer clientConn.Close()
+	conf := &config.Config{ResponseWriteQueueSizeFrames: 16, ResponseWriteBufferSizeBytes: 1024}
+	ctx, cancelFn := context.WithCancel(context.Background())
+	defer cancelFn()
+	scheduler := NewScheduler(1)
+	defer scheduler.Shutdown()
+	coalescer := NewWriteCoalescer(conf, serverConn, &sync.WaitGroup{}, ctx, cancelFn, "test", false, false, scheduler, nil)
+	coalescer.RunWriteQueueLoop()
+
+	rows := newTestRowsResponse(t, 1, 100)
+	streamed := newTestRowsResponse(t, 2, 5000)
+	void := mustConvertToRawFrame(t, frame.NewFrame(primitive.ProtocolVersion4, 3, &message.VoidResult{}))
+
+	go func() {
+		coalescer.Enqueue(rows)
+		<-coalescer.EnqueueStreamed(streamed.Header, bytes.NewReader(streamed.Body))
+		coalescer.Enqueue(void)
+	}()
+
+	reader := bufio.NewReader(clientConn)
+	for _, expected := range []*frame.RawFrame{rows, streamed, void} {
+		received, err := defaultCodec.DecodeRawFrame(reader)
+		require.Nil(t, err)
+		require.Equal(t, expected.Header, received.Header)
+		require.Equal(t, expected.Body, received.Body)
+	}
+}
+
+func TestClusterConnector_IsStreamingCandidate(t *testing.T) {
+	cc := &ClusterConnector{
+		conf: &config.Config{ResponseStreamingThresholdBytes: 1000},
+		responseStreamer: func(*frame.Header, io.Reader, ClusterConnectorType) bool {
+			return true
+		},
+	}
+
+	large := newTestRowsResponse(t, 1, 2000)
+	withWarnings := newTestRowsResponse(t, 1, 2000)
+	withWarnings.Header.Flags = withWarnings.Header.Flags.Add(primitive.HeaderFlagWarning)
+	internalStreamId := newTestRowsResponse(t, -1, 2000)
+	prepared := mustConvertToRawFrame(t, frame.NewFrame(primitive.ProtocolVersion4, 1, &message.PreparedResult{
+		PreparedQueryId: bytes.Repeat([]byte{1}, 2000)}))
+
+	tests := []struct {
+		name     string
+		response *frame.RawFrame
+		expected bool
+	}{
+		{"large rows", large, true},
+		{"small rows", newTestRowsResponse(t, 1, 10), false},
+		{"flags", withWarnings, false},
+		{"internal stream id", internalStreamId, false},
+		{"prepared result", prepared, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader := bufio.NewReader(bytes.NewReader(tt.response.Body))
+			require.Equal(t, tt.expected, cc.isStreamingCandidate(tt.response.Header, reader))
+		})
+	}
+
+	disabled := &ClusterConnector{conf: cc.conf}
+	require.False(t, disabled.isStreamingCandidate(large.Header, bufio.NewReader(bytes.NewReader(large.Body))))
+}
+
+func TestCanStreamResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		modify   func(reqCtx *requestContextImpl)
+		expected bool
+	}{
+		{"read", func(reqCtx *requestContextImpl) {}, true},
+		{"other cluster", func(reqCtx *requestContextImpl) {
+			reqCtx.requestInfo = NewGenericRequestInfo(forwardToTarget, false, true)
+		}, false},
+		{"hedged", func(reqCtx *requestContextImpl) { reqCtx.SetHedged(1, toHedgedReadStreamId(1)) }, false},
+		{"custom response channel", func(reqCtx *requestContextImpl) {
+			reqCtx.customResponseChannel = make(chan *customResponse, 1)
+		}, false},
+		{"consistency downgrade", func(reqCtx *requestContextImpl) {
+			reqCtx.SetConsistencyDowngrade(common.ClusterTypeOrigin, &consistencyDowngrade{
+				from: primitive.ConsistencyLevelQuorum, to: primitive.ConsistencyLevelOne})
+		}, false},
+		{"response warnings", func(reqCtx *requestContextImpl) {
+			reqCtx.SetResponseWarnings([]string{"warning"})
+		}, false},
+		{"async read comparison", func(reqCtx *requestContextImpl) {
+			reqCtx.asyncReadComparison = newAsyncReadComparison(nil)
+		}, false},
+		{"coalesced prepare", func(reqCtx *requestContextImpl) {
+			reqCtx.prepareCall = &prepareCall{key: "ks.SELECT * FROM tbl", done: make(chan struct{})}
+		}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reqCtx := NewRequestContext(
+				newTestRowsResponse(t, 1, 10), NewGenericRequestInfo(forwardToOrigin, false, true), time.Now(), nil)
+			tt.modify(reqCtx)
+			require.Equal(t, tt.expected, canStreamResponse(reqCtx, forwardToOrigin))
+		})
+	}
+}
+
+func newTestRowsResponse(t *testing.T, streamId int16, valueLength int) *frame.RawFrame {
+	return mustConvertToRawFrame(t, frame.NewFrame(primitive.ProtocolVersion4, streamId, &message.RowsResult{
+		Metadata: &message.RowsMetadata{ColumnCount: 1},
+		Data:     message.RowSet{{bytes.Repeat([]byte{1}, valueLength)}},
+	}))
+}
+
+func mustConvertToRawFrame(t *testing.T, f *frame.Frame) *frame.RawFrame {
+	rawFrame, err := defaultCodec.ConvertToRawFrame(f)
+	require.Nil(t, err)
+	return rawFrame
+}
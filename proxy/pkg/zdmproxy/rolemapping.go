@@ -0,0 +1,61 @@
+package zdmproxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"os"
+)
+
+// roleMapping is the content of ZDM_ROLE_MAPPING_FILE. It maps the username of a client to the credentials that are
+// used with each cluster when the credentials of the client are forwarded, e.g.
+// {"app_user": {"target": {"username": "app-user"}}} authenticates app_user as app-user on TARGET. If the password of a
+// mapped role is empty then the password provided by the client is used.
+type roleMapping map[string]*ClusterCredentials
+
+func newRoleMapping(path string) (roleMapping, error) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read role mapping file: %w", err)
+	}
+	var mapping roleMapping
+	err = json.Unmarshal(file, &mapping)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse role mapping file: %w", err)
+	}
+	if len(mapping) == 0 {
+		return nil, errors.New("role mapping file doesn't contain any role")
+	}
+	for role, clusterCredentials := range mapping {
+		if clusterCredentials == nil || (clusterCredentials.Origin == nil && clusterCredentials.Target == nil) {
+			return nil, fmt.Errorf("role %v of the role mapping file doesn't map to an origin or target role", role)
+		}
+		if (clusterCredentials.Origin != nil && clusterCredentials.Origin.Username == "") ||
+			(clusterCredentials.Target != nil && clusterCredentials.Target.Username == "") {
+			return nil, fmt.Errorf("role %v of the role mapping file maps to an empty username", role)
+		}
+	}
+	return mapping, nil
+}
+
+// clusterCredentials returns the credentials of the cluster that the role of the client is mapped to or
+// defaultCredentials if the role is not mapped for that cluster.
+func (m roleMapping) clusterCredentials(
+	clientCreds *AuthCredentials, clusterType common.ClusterType, defaultCredentials *AuthCredentials) *AuthCredentials {
+	clusterCredentials, ok := m[clientCreds.Username]
+	if !ok {
+		return defaultCredentials
+	}
+	mappedCredentials := clusterCredentials.Origin
+	if clusterType == common.ClusterTypeTarget {
+		mappedCredentials = clusterCredentials.Target
+	}
+	if mappedCredentials == nil {
+		return defaultCredentials
+	}
+	if mappedCredentials.Password == "" {
+		return &AuthCredentials{AuthId: clientCreds.AuthId, Username: mappedCredentials.Username, Password: clientCreds.Password}
+	}
+	return mappedCredentials
+}
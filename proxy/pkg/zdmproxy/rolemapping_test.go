@@ -0,0 +1,71 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRoleMapping(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "roles.json")
+	require.Nil(t, os.WriteFile(path, []byte(`{
+		"app_user": {"target": {"username": "app-user"}},
+		"admin": {"origin": {"username": "cassandra", "password": "originPassword"},
+		          "target": {"username": "target_admin", "password": "targetPassword"}}
+	}`), 0600))
+	mapping, err := newRoleMapping(path)
+	require.Nil(t, err)
+
+	defaultCredentials := &AuthCredentials{Username: "configured", Password: "configuredPassword"}
+	tests := []struct {
+		name                string
+		clientCredentials   *AuthCredentials
+		clusterType         common.ClusterType
+		expectedCredentials *AuthCredentials
+	}{
+		{"unmapped role", &AuthCredentials{Username: "other", Password: "password"},
+			common.ClusterTypeTarget, defaultCredentials},
+		{"unmapped cluster", &AuthCredentials{Username: "app_user", Password: "password"},
+			common.ClusterTypeOrigin, defaultCredentials},
+		{"mapped username", &AuthCredentials{Username: "app_user", Password: "password"},
+			common.ClusterTypeTarget, &AuthCredentials{Username: "app-user", Password: "password"}},
+		{"mapped username and password", &AuthCredentials{Username: "admin", Password: "password"},
+			common.ClusterTypeOrigin, &AuthCredentials{Username: "cassandra", Password: "originPassword"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expectedCredentials,
+				mapping.clusterCredentials(tt.clientCredentials, tt.clusterType, defaultCredentials))
+		})
+	}
+
+	var noMapping roleMapping
+	require.Equal(t, defaultCredentials, noMapping.clusterCredentials(
+		&AuthCredentials{Username: "app_user"}, common.ClusterTypeTarget, defaultCredentials))
+}
+
+func TestRoleMapping_InvalidFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   string
+		errMsg string
+	}{
+		{"empty", `{}`, "role mapping file doesn't contain any role"},
+		{"no cluster", `{"app_user": {}}`, "role app_user of the role mapping file doesn't map to an origin or target role"},
+		{"empty username", `{"app_user": {"origin": {"password": "password"}}}`,
+			"role app_user of the role mapping file maps to an empty username"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "roles.json")
+			require.Nil(t, os.WriteFile(path, []byte(tt.file), 0600))
+			_, err := newRoleMapping(path)
+			require.NotNil(t, err)
+			require.Equal(t, tt.errMsg, err.Error())
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package zdmproxy
+
+import (
+	"strings"
+)
+
+const (
+	routingHintPrefix = "zdm:"
+
+	routingHintOriginOnly = "origin-only"
+	routingHintTargetOnly = "target-only"
+	routingHintBoth       = "both"
+)
+
+// parseRoutingHint looks for a routing directive in a block comment at the start of the query string,
+// e.g. "/* zdm:target-only */ SELECT * FROM ks.tb". Only the first comment is considered and it must be the
+// first token of the query (leading whitespace is ignored).
+//
+// Returns false if the query doesn't contain a valid routing hint.
+func parseRoutingHint(query string) (forwardDecision, bool) {
+	trimmedQuery := strings.TrimSpace(query)
+	if !strings.HasPrefix(trimmedQuery, "/*") {
+		return "", false
+	}
+
+	endIdx := strings.Index(trimmedQuery, "*/")
+	if endIdx < 0 {
+		return "", false
+	}
+
+	comment := strings.ToLower(strings.TrimSpace(trimmedQuery[2:endIdx]))
+	if !strings.HasPrefix(comment, routingHintPrefix) {
+		return "", false
+	}
+
+	switch strings.TrimSpace(strings.TrimPrefix(comment, routingHintPrefix)) {
+	case routingHintOriginOnly:
+		return forwardToOrigin, true
+	case routingHintTargetOnly:
+		return forwardToTarget, true
+	case routingHintBoth:
+		return forwardToBoth, true
+	default:
+		return "", false
+	}
+}
@@ -1,28 +1,59 @@
 package zdmproxy
 
-import "sync"
+import (
+	"github.com/datastax/zdm-proxy/proxy/pkg/errorreporting"
+	"sync"
+)
 
 type Scheduler struct {
-	queue chan func()
-	wg    *sync.WaitGroup
+	queue         chan func()
+	priorityQueue chan func()
+	wg            *sync.WaitGroup
 }
 
 func NewScheduler(workers int) *Scheduler {
 	scheduler := &Scheduler{
-		queue: make(chan func(), workers),
-		wg:    &sync.WaitGroup{},
+		queue:         make(chan func(), workers),
+		priorityQueue: make(chan func(), workers),
+		wg:            &sync.WaitGroup{},
 	}
 
 	for i := 0; i < workers; i++ {
 		scheduler.wg.Add(1)
 		go func() {
 			defer scheduler.wg.Done()
-			for {
-				task, ok := <-scheduler.queue
-				if !ok {
-					return
+			defer errorreporting.ReportPanic()
+			queue := scheduler.queue
+			priorityQueue := scheduler.priorityQueue
+			for queue != nil || priorityQueue != nil {
+				// drain the priority queue before picking up regular tasks
+				if priorityQueue != nil {
+					select {
+					case task, ok := <-priorityQueue:
+						if !ok {
+							priorityQueue = nil
+						} else {
+							task()
+						}
+						continue
+					default:
+					}
+				}
+
+				select {
+				case task, ok := <-priorityQueue:
+					if !ok {
+						priorityQueue = nil
+						continue
+					}
+					task()
+				case task, ok := <-queue:
+					if !ok {
+						queue = nil
+						continue
+					}
+					task()
 				}
-				task()
 			}
 		}()
 	}
@@ -34,7 +65,15 @@ func (recv *Scheduler) Schedule(task func()) {
 	recv.queue <- task
 }
 
+// SchedulePriority schedules a task that is executed before any task that was scheduled with Schedule and is still
+// waiting for a worker. It's used for the frames of the control plane (handshakes, heartbeats, event registrations)
+// so that they don't time out when the workers are saturated with queries.
+func (recv *Scheduler) SchedulePriority(task func()) {
+	recv.priorityQueue <- task
+}
+
 func (recv *Scheduler) Shutdown() {
 	close(recv.queue)
+	close(recv.priorityQueue)
 	recv.wg.Wait()
 }
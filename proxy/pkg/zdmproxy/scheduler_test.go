@@ -0,0 +1,37 @@
+package zdmproxy
+
+import (
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+)
+
+func TestScheduler_PriorityTasksRunFirst(t *testing.T) {
+	scheduler := NewScheduler(1)
+
+	blockWorker := make(chan bool)
+	workerBlocked := make(chan bool)
+	scheduler.Schedule(func() {
+		workerBlocked <- true
+		<-blockWorker
+	})
+	<-workerBlocked
+
+	lock := &sync.Mutex{}
+	var order []string
+	record := func(name string) func() {
+		return func() {
+			lock.Lock()
+			defer lock.Unlock()
+			order = append(order, name)
+		}
+	}
+	scheduler.Schedule(record("query"))
+	scheduler.SchedulePriority(record("startup"))
+
+	close(blockWorker)
+	// pending tasks are still executed during shutdown
+	scheduler.Shutdown()
+
+	require.Equal(t, []string{"startup", "query"}, order)
+}
@@ -0,0 +1,320 @@
+package zdmproxy
+
+import (
+	"context"
+	"fmt"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+	"time"
+)
+
+const schemaDiffTimeout = 30 * time.Second
+
+const (
+	SchemaProblemMissingTable       = "MISSING_TABLE"
+	SchemaProblemMissingColumn      = "MISSING_COLUMN"
+	SchemaProblemTypeMismatch       = "TYPE_MISMATCH"
+	SchemaProblemPrimaryKeyMismatch = "PRIMARY_KEY_MISMATCH"
+	SchemaProblemMissingType        = "MISSING_TYPE"
+	SchemaProblemMissingField       = "MISSING_FIELD"
+)
+
+// SchemaDiff is the comparison of the schemas of the dual-written tables and of the user defined types of Origin with
+// the ones of Target, see ZdmProxy.CheckSchemas.
+type SchemaDiff struct {
+	Keyspaces         []string                 `json:"keyspaces"`
+	ComparedTables    int                      `json:"compared_tables"`
+	ComparedTypes     int                      `json:"compared_types"`
+	Incompatibilities []*SchemaIncompatibility `json:"incompatibilities"`
+}
+
+// SchemaIncompatibility is a difference between the schemas of the clusters that makes the writes to Target fail or
+// behave differently, e.g. a column of Origin that doesn't exist on Target. Column is the name of the UDT field for
+// the problems of Type.
+type SchemaIncompatibility struct {
+	Keyspace string `json:"keyspace"`
+	Table    string `json:"table,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Column   string `json:"column,omitempty"`
+	Problem  string `json:"problem"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+func (recv *SchemaIncompatibility) String() string {
+	name := recv.Keyspace + "." + recv.Table
+	if recv.Type != "" {
+		name = recv.Keyspace + "." + recv.Type
+	}
+	if recv.Column != "" {
+		name += "." + recv.Column
+	}
+	if recv.Detail == "" {
+		return fmt.Sprintf("%v: %v", name, recv.Problem)
+	}
+	return fmt.Sprintf("%v: %v (%v)", name, recv.Problem, recv.Detail)
+}
+
+type schemaColumn struct {
+	kind    string // partition_key, clustering, regular or static
+	cqlType string
+}
+
+type clusterSchema struct {
+	tables map[string]map[string]*schemaColumn // columns by table, tables are keyed by keyspace.table
+	types  map[string]map[string]string        // field types by UDT, UDTs are keyed by keyspace.type
+}
+
+func schemaKey(keyspace string, name string) string {
+	return keyspace + "." + name
+}
+
+// loadClusterSchema reads the columns of the tables (materialized views excluded) and the fields of the UDTs of a
+// cluster, it requires the system_schema keyspace (Cassandra 3.0 or later).
+func loadClusterSchema(
+	queryFn func(cql string, ctx context.Context) (*ParsedRowSet, error), ctx context.Context) (*clusterSchema, error) {
+	views := map[string]bool{}
+	viewRows, err := queryFn("SELECT keyspace_name, view_name FROM system_schema.views", ctx)
+	if err != nil {
+		// e.g. services that don't support materialized views
+		log.Debugf("Could not read system_schema.views, materialized views are compared as tables: %v", err)
+	} else {
+		for _, row := range viewRows.Rows {
+			keyspace, _ := parseString(row, "keyspace_name")
+			view, _ := parseString(row, "view_name")
+			views[schemaKey(keyspace, view)] = true
+		}
+	}
+
+	columnRows, err := queryFn("SELECT keyspace_name, table_name, column_name, kind, type FROM system_schema.columns", ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not read system_schema.columns: %w", err)
+	}
+	schema := &clusterSchema{
+		tables: map[string]map[string]*schemaColumn{},
+		types:  map[string]map[string]string{},
+	}
+	for _, row := range columnRows.Rows {
+		keyspace, err := parseString(row, "keyspace_name")
+		if err != nil {
+			return nil, err
+		}
+		table, err := parseString(row, "table_name")
+		if err != nil {
+			return nil, err
+		}
+		if views[schemaKey(keyspace, table)] {
+			continue
+		}
+		column, err := parseString(row, "column_name")
+		if err != nil {
+			return nil, err
+		}
+		kind, err := parseString(row, "kind")
+		if err != nil {
+			return nil, err
+		}
+		cqlType, err := parseString(row, "type")
+		if err != nil {
+			return nil, err
+		}
+		key := schemaKey(keyspace, table)
+		if schema.tables[key] == nil {
+			schema.tables[key] = map[string]*schemaColumn{}
+		}
+		schema.tables[key][column] = &schemaColumn{kind: kind, cqlType: cqlType}
+	}
+
+	typeRows, err := queryFn("SELECT keyspace_name, type_name, field_names, field_types FROM system_schema.types", ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not read system_schema.types: %w", err)
+	}
+	for _, row := range typeRows.Rows {
+		keyspace, err := parseString(row, "keyspace_name")
+		if err != nil {
+			return nil, err
+		}
+		typeName, err := parseString(row, "type_name")
+		if err != nil {
+			return nil, err
+		}
+		fieldNames, _ := parseNillableStringSlice(row, "field_names")
+		fieldTypes, _ := parseNillableStringSlice(row, "field_types")
+		if len(fieldNames) != len(fieldTypes) {
+			return nil, fmt.Errorf("the UDT %v.%v has %d field names but %d field types",
+				keyspace, typeName, len(fieldNames), len(fieldTypes))
+		}
+		fields := map[string]string{}
+		for i, fieldName := range fieldNames {
+			fields[fieldName] = fieldTypes[i]
+		}
+		schema.types[schemaKey(keyspace, typeName)] = fields
+	}
+	return schema, nil
+}
+
+// compareSchemas compares the tables and the UDTs of the given keyspaces of Origin with the ones of Target, all the
+// non system keyspaces of Origin are compared if keyspaces is empty. The tables and the columns that only exist on
+// Target are ignored unless they are part of the primary key since the writes of Origin wouldn't set them.
+func compareSchemas(origin *clusterSchema, target *clusterSchema, keyspaces []string) *SchemaDiff {
+	keyspaceSet := map[string]bool{}
+	for _, keyspace := range keyspaces {
+		keyspaceSet[keyspace] = true
+	}
+	if len(keyspaceSet) == 0 {
+		for key := range origin.tables {
+			keyspace := strings.SplitN(key, ".", 2)[0]
+			if !isSystemKeyspaceName(keyspace) {
+				keyspaceSet[keyspace] = true
+			}
+		}
+	}
+	diff := &SchemaDiff{Keyspaces: sortedKeys(keyspaceSet), Incompatibilities: []*SchemaIncompatibility{}}
+	inKeyspaces := func(key string) (string, string, bool) {
+		parts := strings.SplitN(key, ".", 2)
+		return parts[0], parts[1], keyspaceSet[parts[0]]
+	}
+
+	for _, key := range sortedKeys(origin.tables) {
+		keyspace, table, ok := inKeyspaces(key)
+		if !ok {
+			continue
+		}
+		diff.ComparedTables++
+		targetColumns, exists := target.tables[key]
+		if !exists {
+			diff.add(&SchemaIncompatibility{Keyspace: keyspace, Table: table, Problem: SchemaProblemMissingTable})
+			continue
+		}
+		originColumns := origin.tables[key]
+		for _, name := range sortedKeys(originColumns) {
+			originColumn := originColumns[name]
+			targetColumn, exists := targetColumns[name]
+			switch {
+			case !exists:
+				diff.add(&SchemaIncompatibility{Keyspace: keyspace, Table: table, Column: name,
+					Problem: SchemaProblemMissingColumn, Detail: originColumn.cqlType})
+			case isPrimaryKeyKind(originColumn.kind) != isPrimaryKeyKind(targetColumn.kind) ||
+				(isPrimaryKeyKind(originColumn.kind) && originColumn.kind != targetColumn.kind):
+				diff.add(&SchemaIncompatibility{Keyspace: keyspace, Table: table, Column: name,
+					Problem: SchemaProblemPrimaryKeyMismatch, Detail: describeSchemaDifference(originColumn.kind, targetColumn.kind)})
+			case normalizeCqlType(originColumn.cqlType) != normalizeCqlType(targetColumn.cqlType):
+				diff.add(&SchemaIncompatibility{Keyspace: keyspace, Table: table, Column: name,
+					Problem: SchemaProblemTypeMismatch, Detail: describeSchemaDifference(originColumn.cqlType, targetColumn.cqlType)})
+			}
+		}
+		for _, name := range sortedKeys(targetColumns) {
+			if _, exists := originColumns[name]; !exists && isPrimaryKeyKind(targetColumns[name].kind) {
+				diff.add(&SchemaIncompatibility{Keyspace: keyspace, Table: table, Column: name,
+					Problem: SchemaProblemPrimaryKeyMismatch,
+					Detail:  fmt.Sprintf("%v only exists on %v", targetColumns[name].kind, common.ClusterTypeTarget)})
+			}
+		}
+	}
+
+	for _, key := range sortedKeys(origin.types) {
+		keyspace, typeName, ok := inKeyspaces(key)
+		if !ok {
+			continue
+		}
+		diff.ComparedTypes++
+		targetFields, exists := target.types[key]
+		if !exists {
+			diff.add(&SchemaIncompatibility{Keyspace: keyspace, Type: typeName, Problem: SchemaProblemMissingType})
+			continue
+		}
+		originFields := origin.types[key]
+		for _, name := range sortedKeys(originFields) {
+			targetType, exists := targetFields[name]
+			if !exists {
+				diff.add(&SchemaIncompatibility{Keyspace: keyspace, Type: typeName, Column: name,
+					Problem: SchemaProblemMissingField, Detail: originFields[name]})
+			} else if normalizeCqlType(originFields[name]) != normalizeCqlType(targetType) {
+				diff.add(&SchemaIncompatibility{Keyspace: keyspace, Type: typeName, Column: name,
+					Problem: SchemaProblemTypeMismatch, Detail: describeSchemaDifference(originFields[name], targetType)})
+			}
+		}
+	}
+	return diff
+}
+
+func (recv *SchemaDiff) add(incompatibility *SchemaIncompatibility) {
+	recv.Incompatibilities = append(recv.Incompatibilities, incompatibility)
+}
+
+func isPrimaryKeyKind(kind string) bool {
+	return kind == "partition_key" || kind == "clustering"
+}
+
+func isSystemKeyspaceName(keyspace string) bool {
+	return keyspace == systemKeyspaceName || strings.HasPrefix(keyspace, "system_") || strings.HasPrefix(keyspace, "dse_")
+}
+
+func normalizeCqlType(cqlType string) string {
+	return strings.ToLower(strings.ReplaceAll(cqlType, " ", ""))
+}
+
+func describeSchemaDifference(originValue string, targetValue string) string {
+	return fmt.Sprintf("%v on %v, %v on %v", originValue, common.ClusterTypeOrigin, targetValue, common.ClusterTypeTarget)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// CheckSchemas compares the schemas of the tables and of the UDTs of ZDM_SCHEMA_CHECK_KEYSPACES (all the non system
+// keyspaces of Origin by default) on both clusters.
+func (p *ZdmProxy) CheckSchemas(ctx context.Context) (*SchemaDiff, error) {
+	p.lock.RLock()
+	originControlConn, targetControlConn := p.originControlConn, p.targetControlConn
+	p.lock.RUnlock()
+	if originControlConn == nil || targetControlConn == nil {
+		return nil, fmt.Errorf("the control connections are not open")
+	}
+
+	ctx, cancelFn := context.WithTimeout(ctx, schemaDiffTimeout)
+	defer cancelFn()
+	originSchema, err := loadClusterSchema(originControlConn.Query, ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the schema of %v: %w", common.ClusterTypeOrigin, err)
+	}
+	targetSchema, err := loadClusterSchema(targetControlConn.Query, ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not read the schema of %v: %w", common.ClusterTypeTarget, err)
+	}
+	keyspaces, err := p.Conf.ParseSchemaCheckKeyspaces()
+	if err != nil {
+		return nil, err
+	}
+	return compareSchemas(originSchema, targetSchema, keyspaces), nil
+}
+
+// runStartupSchemaCheck logs the schema incompatibilities when ZDM_SCHEMA_CHECK_ENABLED is true, the proxy starts
+// anyway since the tables could be fixed before the clients use them.
+func (p *ZdmProxy) runStartupSchemaCheck(ctx context.Context) {
+	if !p.Conf.SchemaCheckEnabled {
+		return
+	}
+	diff, err := p.CheckSchemas(ctx)
+	if err != nil {
+		log.Warnf("Schema check: %v", err)
+		return
+	}
+	if len(diff.Incompatibilities) == 0 {
+		log.Infof("Schema check: %d tables and %d UDTs of the keyspaces %v have compatible schemas on %v and %v.",
+			diff.ComparedTables, diff.ComparedTypes, diff.Keyspaces, common.ClusterTypeOrigin, common.ClusterTypeTarget)
+		return
+	}
+	for _, incompatibility := range diff.Incompatibilities {
+		log.Warnf("Schema check: %v", incompatibility)
+	}
+	log.Warnf("Schema check: found %d incompatibilities between the schemas of %v and %v in %d tables and %d UDTs, "+
+		"the writes to these tables are likely to fail on %v.", len(diff.Incompatibilities), common.ClusterTypeOrigin,
+		common.ClusterTypeTarget, diff.ComparedTables, diff.ComparedTypes, common.ClusterTypeTarget)
+}
@@ -0,0 +1,206 @@
+package zdmproxy
+
+import (
+	"context"
+	"errors"
+	"github.com/datastax/go-cassandra-native-protocol/datatype"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func newSchemaRowSet(columnNames []string, rows ...[]interface{}) *ParsedRowSet {
+	columnIndexes := map[string]int{}
+	var columns []*message.ColumnMetadata
+	for i, name := range columnNames {
+		columnIndexes[name] = i
+		columns = append(columns, &message.ColumnMetadata{Name: name, Type: datatype.Varchar})
+	}
+	rowSet := &ParsedRowSet{ColumnIndexes: columnIndexes, Columns: columns}
+	for _, row := range rows {
+		rowSet.Rows = append(rowSet.Rows, NewParsedRow(columnIndexes, columns, row))
+	}
+	return rowSet
+}
+
+func schemaColumnRow(keyspace string, table string, column string, kind string, cqlType string) []interface{} {
+	return []interface{}{keyspace, table, column, kind, cqlType}
+}
+
+func stringPtrs(values ...string) []*string {
+	ptrs := make([]*string, len(values))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	return ptrs
+}
+
+func TestLoadClusterSchema(t *testing.T) {
+	rowSets := map[string]*ParsedRowSet{
+		"system_schema.views": newSchemaRowSet([]string{"keyspace_name", "view_name"}, []interface{}{"ks", "tbl_by_v"}),
+		"system_schema.columns": newSchemaRowSet([]string{"keyspace_name", "table_name", "column_name", "kind", "type"},
+			schemaColumnRow("ks", "tbl", "pk", "partition_key", "int"),
+			schemaColumnRow("ks", "tbl", "v", "regular", "frozen<address>"),
+			schemaColumnRow("ks", "tbl_by_v", "v", "partition_key", "frozen<address>")),
+		"system_schema.types": newSchemaRowSet([]string{"keyspace_name", "type_name", "field_names", "field_types"},
+			[]interface{}{"ks", "address", stringPtrs("street", "zip"), stringPtrs("text", "int")}),
+	}
+	var queryErr error
+	queryFn := func(cql string, _ context.Context) (*ParsedRowSet, error) {
+		for table, rowSet := range rowSets {
+			if strings.HasSuffix(cql, " FROM "+table) {
+				return rowSet, queryErr
+			}
+		}
+		return nil, errors.New("unexpected query: " + cql)
+	}
+
+	schema, err := loadClusterSchema(queryFn, context.Background())
+	require.Nil(t, err)
+	require.Equal(t, map[string]map[string]*schemaColumn{
+		"ks.tbl": {
+			"pk": {kind: "partition_key", cqlType: "int"},
+			"v":  {kind: "regular", cqlType: "frozen<address>"},
+		},
+	}, schema.tables)
+	require.Equal(t, map[string]map[string]string{"ks.address": {"street": "text", "zip": "int"}}, schema.types)
+
+	// without system_schema.views the materialized views are compared like tables
+	delete(rowSets, "system_schema.views")
+	schema, err = loadClusterSchema(queryFn, context.Background())
+	require.Nil(t, err)
+	require.Contains(t, schema.tables, "ks.tbl_by_v")
+
+	queryErr = errors.New("unavailable")
+	_, err = loadClusterSchema(queryFn, context.Background())
+	require.ErrorContains(t, err, "could not read system_schema.columns: unavailable")
+}
+
+func TestCompareSchemas(t *testing.T) {
+	newSchema := func() *clusterSchema {
+		return &clusterSchema{
+			tables: map[string]map[string]*schemaColumn{
+				"ks.tbl": {
+					"pk": {kind: "partition_key", cqlType: "uuid"},
+					"ck": {kind: "clustering", cqlType: "int"},
+					"v":  {kind: "regular", cqlType: "map<text, frozen<address>>"},
+				},
+				"system_auth.roles": {"role": {kind: "partition_key", cqlType: "text"}},
+			},
+			types: map[string]map[string]string{"ks.address": {"street": "text", "zip": "int"}},
+		}
+	}
+
+	tests := []struct {
+		name              string
+		modifyOrigin      func(schema *clusterSchema)
+		modifyTarget      func(schema *clusterSchema)
+		keyspaces         []string
+		comparedKeyspaces []string
+		comparedTables    int
+		incompatibilities []*SchemaIncompatibility
+	}{
+		{
+			name:              "same schemas",
+			comparedKeyspaces: []string{"ks"},
+			comparedTables:    1,
+			incompatibilities: []*SchemaIncompatibility{},
+		},
+		{
+			name: "types differ by case and spaces only",
+			modifyTarget: func(schema *clusterSchema) {
+				schema.tables["ks.tbl"]["v"].cqlType = "MAP<text,frozen<address>>"
+			},
+			comparedKeyspaces: []string{"ks"},
+			comparedTables:    1,
+			incompatibilities: []*SchemaIncompatibility{},
+		},
+		{
+			name: "missing table, column, type and field",
+			modifyOrigin: func(schema *clusterSchema) {
+				schema.tables["ks.other"] = map[string]*schemaColumn{"pk": {kind: "partition_key", cqlType: "int"}}
+				schema.types["ks.other_udt"] = map[string]string{"f": "int"}
+			},
+			modifyTarget: func(schema *clusterSchema) {
+				delete(schema.tables["ks.tbl"], "v")
+				delete(schema.types["ks.address"], "zip")
+				schema.tables["ks.target_only"] = map[string]*schemaColumn{"pk": {kind: "partition_key", cqlType: "int"}}
+			},
+			comparedKeyspaces: []string{"ks"},
+			comparedTables:    2,
+			incompatibilities: []*SchemaIncompatibility{
+				{Keyspace: "ks", Table: "other", Problem: SchemaProblemMissingTable},
+				{Keyspace: "ks", Table: "tbl", Column: "v", Problem: SchemaProblemMissingColumn,
+					Detail: "map<text, frozen<address>>"},
+				{Keyspace: "ks", Type: "address", Column: "zip", Problem: SchemaProblemMissingField, Detail: "int"},
+				{Keyspace: "ks", Type: "other_udt", Problem: SchemaProblemMissingType},
+			},
+		},
+		{
+			name: "type mismatches",
+			modifyTarget: func(schema *clusterSchema) {
+				schema.tables["ks.tbl"]["v"].cqlType = "map<text, text>"
+				schema.types["ks.address"]["zip"] = "text"
+			},
+			comparedKeyspaces: []string{"ks"},
+			comparedTables:    1,
+			incompatibilities: []*SchemaIncompatibility{
+				{Keyspace: "ks", Table: "tbl", Column: "v", Problem: SchemaProblemTypeMismatch,
+					Detail: "map<text, frozen<address>> on ORIGIN, map<text, text> on TARGET"},
+				{Keyspace: "ks", Type: "address", Column: "zip", Problem: SchemaProblemTypeMismatch,
+					Detail: "int on ORIGIN, text on TARGET"},
+			},
+		},
+		{
+			name: "primary key mismatches",
+			modifyTarget: func(schema *clusterSchema) {
+				schema.tables["ks.tbl"]["ck"].kind = "partition_key"
+				schema.tables["ks.tbl"]["v"].kind = "clustering"
+				schema.tables["ks.tbl"]["extra"] = &schemaColumn{kind: "clustering", cqlType: "int"}
+				schema.tables["ks.tbl"]["regular"] = &schemaColumn{kind: "regular", cqlType: "int"}
+			},
+			comparedKeyspaces: []string{"ks"},
+			comparedTables:    1,
+			incompatibilities: []*SchemaIncompatibility{
+				{Keyspace: "ks", Table: "tbl", Column: "ck", Problem: SchemaProblemPrimaryKeyMismatch,
+					Detail: "clustering on ORIGIN, partition_key on TARGET"},
+				{Keyspace: "ks", Table: "tbl", Column: "v", Problem: SchemaProblemPrimaryKeyMismatch,
+					Detail: "regular on ORIGIN, clustering on TARGET"},
+				{Keyspace: "ks", Table: "tbl", Column: "extra", Problem: SchemaProblemPrimaryKeyMismatch,
+					Detail: "clustering only exists on TARGET"},
+			},
+		},
+		{
+			name: "only the configured keyspaces",
+			modifyOrigin: func(schema *clusterSchema) {
+				schema.tables["ks2.tbl"] = map[string]*schemaColumn{"pk": {kind: "partition_key", cqlType: "int"}}
+			},
+			modifyTarget: func(schema *clusterSchema) {
+				delete(schema.tables, "ks.tbl")
+			},
+			keyspaces:         []string{"ks2", "system_auth"},
+			comparedKeyspaces: []string{"ks2", "system_auth"},
+			comparedTables:    2,
+			incompatibilities: []*SchemaIncompatibility{
+				{Keyspace: "ks2", Table: "tbl", Problem: SchemaProblemMissingTable},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			origin, target := newSchema(), newSchema()
+			if tt.modifyOrigin != nil {
+				tt.modifyOrigin(origin)
+			}
+			if tt.modifyTarget != nil {
+				tt.modifyTarget(target)
+			}
+			diff := compareSchemas(origin, target, tt.keyspaces)
+			require.Equal(t, tt.comparedKeyspaces, diff.Keyspaces)
+			require.Equal(t, tt.comparedTables, diff.ComparedTables)
+			require.Equal(t, tt.incompatibilities, diff.Incompatibilities)
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package zdmproxy
+
+import (
+	"strings"
+)
+
+// ScyllaDB advertises its protocol extensions (shard awareness, LWT metadata flag, rate limit errors, etc.) in the
+// SUPPORTED response. None of these can be honored by the proxy: connections to the proxy are not bound to a shard,
+// the shard aware port would point clients at a port the proxy doesn't listen on and the other cluster might not
+// be ScyllaDB at all.
+const scyllaOptionPrefix = "SCYLLA_"
+
+// stripScyllaOptions returns a copy of the provided SUPPORTED options without the ScyllaDB specific extensions
+// and a boolean that is true if at least one option was removed.
+func stripScyllaOptions(options map[string][]string) (map[string][]string, bool) {
+	stripped := false
+	newOptions := make(map[string][]string, len(options))
+	for key, value := range options {
+		if strings.HasPrefix(strings.ToUpper(key), scyllaOptionPrefix) {
+			stripped = true
+			continue
+		}
+		newOptions[key] = value
+	}
+	return newOptions, stripped
+}
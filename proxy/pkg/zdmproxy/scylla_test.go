@@ -0,0 +1,52 @@
+package zdmproxy
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestStripScyllaOptions(t *testing.T) {
+	tests := []struct {
+		name             string
+		options          map[string][]string
+		expectedOptions  map[string][]string
+		expectedStripped bool
+	}{
+		{
+			name:             "cassandra",
+			options:          map[string][]string{"CQL_VERSION": {"3.4.5"}, "COMPRESSION": {"lz4", "snappy"}},
+			expectedOptions:  map[string][]string{"CQL_VERSION": {"3.4.5"}, "COMPRESSION": {"lz4", "snappy"}},
+			expectedStripped: false,
+		},
+		{
+			name: "scylla",
+			options: map[string][]string{
+				"CQL_VERSION":                  {"3.3.1"},
+				"COMPRESSION":                  {"lz4", "snappy"},
+				"SCYLLA_SHARD":                 {"1"},
+				"SCYLLA_NR_SHARDS":             {"8"},
+				"SCYLLA_PARTITIONER":           {"org.apache.cassandra.dht.Murmur3Partitioner"},
+				"SCYLLA_SHARDING_ALGORITHM":    {"biased-token-round-robin"},
+				"SCYLLA_SHARDING_IGNORE_MSB":   {"12"},
+				"SCYLLA_SHARD_AWARE_PORT":      {"19042"},
+				"SCYLLA_LWT_ADD_METADATA_MARK": {"LWT_OPTIMIZATION_META_BIT_MASK=2147483648"},
+			},
+			expectedOptions:  map[string][]string{"CQL_VERSION": {"3.3.1"}, "COMPRESSION": {"lz4", "snappy"}},
+			expectedStripped: true,
+		},
+		{
+			name:             "empty",
+			options:          map[string][]string{},
+			expectedOptions:  map[string][]string{},
+			expectedStripped: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actualOptions, actualStripped := stripScyllaOptions(tt.options)
+			require.Equal(t, tt.expectedOptions, actualOptions)
+			require.Equal(t, tt.expectedStripped, actualStripped)
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+)
+
+// names of the error codes in ZDM_SECONDARY_ERROR_POLICY, see config.SecondaryErrorCodes
+var secondaryErrorCodeNames = map[primitive.ErrorCode]string{
+	primitive.ErrorCodeServerError:     "SERVER_ERROR",
+	primitive.ErrorCodeUnavailable:     "UNAVAILABLE",
+	primitive.ErrorCodeOverloaded:      "OVERLOADED",
+	primitive.ErrorCodeIsBootstrapping: "IS_BOOTSTRAPPING",
+	primitive.ErrorCodeTruncateError:   "TRUNCATE_ERROR",
+	primitive.ErrorCodeWriteTimeout:    "WRITE_TIMEOUT",
+	primitive.ErrorCodeReadTimeout:     "READ_TIMEOUT",
+	primitive.ErrorCodeReadFailure:     "READ_FAILURE",
+	primitive.ErrorCodeFunctionFailure: "FUNCTION_FAILURE",
+	primitive.ErrorCodeWriteFailure:    "WRITE_FAILURE",
+	primitive.ErrorCodeSyntaxError:     "SYNTAX_ERROR",
+	primitive.ErrorCodeUnauthorized:    "UNAUTHORIZED",
+	primitive.ErrorCodeInvalid:         "INVALID",
+	primitive.ErrorCodeConfigError:     "CONFIG_ERROR",
+	primitive.ErrorCodeAlreadyExists:   "ALREADY_EXISTS",
+}
+
+// secondaryErrorPolicy decides what the client sees when a write (QUERY, EXECUTE or BATCH sent to both clusters) was
+// applied on the primary cluster but failed on the secondary cluster. By default the error of the secondary cluster
+// is returned like any failed write. With WARNING the response of the primary cluster is returned with a warning that
+// describes the failure and with METRIC it is returned as is, the failure is only visible in the metrics.
+//
+// A write that failed on the primary cluster is never affected.
+type secondaryErrorPolicy struct {
+	actions       map[string]common.SecondaryErrorAction
+	defaultAction common.SecondaryErrorAction
+
+	warningErrors metrics.Counter
+	metricErrors  metrics.Counter
+}
+
+func newSecondaryErrorPolicy(
+	actions map[string]common.SecondaryErrorAction, proxyMetrics *metrics.ProxyMetrics) *secondaryErrorPolicy {
+	defaultAction, ok := actions[config.SecondaryErrorCodeDefault]
+	if !ok {
+		defaultAction = common.SecondaryErrorActionError
+	}
+	return &secondaryErrorPolicy{
+		actions:       actions,
+		defaultAction: defaultAction,
+		warningErrors: proxyMetrics.TranslatedSecondaryErrorsWarning,
+		metricErrors:  proxyMetrics.TranslatedSecondaryErrorsMetric,
+	}
+}
+
+// translate returns the response that must be sent to the client instead of the aggregated one (along with its
+// cluster) and the action that was applied. The aggregated response is kept when the action is ERROR. It's nil safe.
+func (recv *secondaryErrorPolicy) translate(
+	primaryCluster common.ClusterType, request *frame.RawFrame, originResponse *frame.RawFrame,
+	targetResponse *frame.RawFrame) (*frame.RawFrame, common.ClusterType, common.SecondaryErrorAction) {
+	if recv == nil {
+		return nil, common.ClusterTypeNone, common.SecondaryErrorActionError
+	}
+	switch request.Header.OpCode {
+	case primitive.OpCodeQuery, primitive.OpCodeExecute, primitive.OpCodeBatch:
+	default:
+		// e.g. a PREPARE that failed on the secondary cluster would cause UNPREPARED errors later
+		return nil, common.ClusterTypeNone, common.SecondaryErrorActionError
+	}
+
+	primaryResponse, secondaryResponse := originResponse, targetResponse
+	if primaryCluster == common.ClusterTypeTarget {
+		primaryResponse, secondaryResponse = targetResponse, originResponse
+	}
+	if !isResponseSuccessful(primaryResponse) || isResponseSuccessful(secondaryResponse) {
+		return nil, common.ClusterTypeNone, common.SecondaryErrorActionError
+	}
+
+	errorMsg, err := decodeErrorResult(secondaryResponse)
+	if err != nil {
+		log.Warnf("Could not decode the error of the secondary cluster, returning it to the client: %v", err)
+		return nil, common.ClusterTypeNone, common.SecondaryErrorActionError
+	}
+	action := recv.getAction(errorMsg.GetErrorCode())
+	switch action {
+	case common.SecondaryErrorActionWarning:
+		recv.warningErrors.Add(1)
+	case common.SecondaryErrorActionMetric:
+		recv.metricErrors.Add(1)
+	default:
+		return nil, common.ClusterTypeNone, common.SecondaryErrorActionError
+	}
+	log.Debugf("Returning the response of %v instead of the %v error of the secondary cluster (%v).",
+		primaryCluster, errorMsg.GetErrorCode(), action)
+	return primaryResponse, primaryCluster, action
+}
+
+func (recv *secondaryErrorPolicy) getAction(errorCode primitive.ErrorCode) common.SecondaryErrorAction {
+	name, ok := secondaryErrorCodeNames[errorCode]
+	if !ok {
+		// UNPREPARED and codes that are not expected in a response to a write
+		return common.SecondaryErrorActionError
+	}
+	if action, ok := recv.actions[name]; ok {
+		return action
+	}
+	return recv.defaultAction
+}
@@ -0,0 +1,160 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSecondaryErrorPolicy_Translate(t *testing.T) {
+	success := mustEncodeResponse(t, primitive.ProtocolVersion4, &message.VoidResult{})
+	unavailable := mustEncodeResponse(t, primitive.ProtocolVersion4, &message.Unavailable{
+		ErrorMessage: "Cannot achieve consistency level QUORUM",
+		Consistency:  primitive.ConsistencyLevelQuorum,
+		Required:     2,
+		Alive:        1,
+	})
+	writeTimeout := mustEncodeResponse(t, primitive.ProtocolVersion4, &message.WriteTimeout{
+		ErrorMessage: "Operation timed out",
+		Consistency:  primitive.ConsistencyLevelQuorum,
+		Received:     1,
+		BlockFor:     2,
+		WriteType:    primitive.WriteTypeSimple,
+	})
+	unprepared := mustEncodeResponse(t, primitive.ProtocolVersion4, &message.Unprepared{
+		ErrorMessage: "Unprepared",
+		Id:           []byte{1, 2, 3},
+	})
+	insert := mockQueryFrame(t, "INSERT INTO ks.tbl (a) VALUES (1)")
+
+	policy := newSecondaryErrorPolicy(map[string]common.SecondaryErrorAction{
+		"UNAVAILABLE":   common.SecondaryErrorActionWarning,
+		"WRITE_TIMEOUT": common.SecondaryErrorActionMetric,
+	}, newFakeProxyMetrics())
+	policyWithDefault := newSecondaryErrorPolicy(map[string]common.SecondaryErrorAction{
+		"DEFAULT":     common.SecondaryErrorActionMetric,
+		"UNAVAILABLE": common.SecondaryErrorActionError,
+	}, newFakeProxyMetrics())
+
+	tests := []struct {
+		name                string
+		policy              *secondaryErrorPolicy
+		primaryCluster      common.ClusterType
+		request             *frame.RawFrame
+		originResponse      *frame.RawFrame
+		targetResponse      *frame.RawFrame
+		expectedAction      common.SecondaryErrorAction
+		expectedResponse    *frame.RawFrame
+		expectedClusterType common.ClusterType
+	}{
+		{
+			name:                "warning on target",
+			policy:              policy,
+			primaryCluster:      common.ClusterTypeOrigin,
+			request:             insert,
+			originResponse:      success,
+			targetResponse:      unavailable,
+			expectedAction:      common.SecondaryErrorActionWarning,
+			expectedResponse:    success,
+			expectedClusterType: common.ClusterTypeOrigin,
+		},
+		{
+			name:                "metric on origin",
+			policy:              policy,
+			primaryCluster:      common.ClusterTypeTarget,
+			request:             insert,
+			originResponse:      writeTimeout,
+			targetResponse:      success,
+			expectedAction:      common.SecondaryErrorActionMetric,
+			expectedResponse:    success,
+			expectedClusterType: common.ClusterTypeTarget,
+		},
+		{
+			name:           "error code not listed",
+			policy:         policy,
+			primaryCluster: common.ClusterTypeOrigin,
+			request:        insert,
+			originResponse: success,
+			targetResponse: unprepared,
+			expectedAction: common.SecondaryErrorActionError,
+		},
+		{
+			name:                "default action",
+			policy:              policyWithDefault,
+			primaryCluster:      common.ClusterTypeOrigin,
+			request:             insert,
+			originResponse:      success,
+			targetResponse:      writeTimeout,
+			expectedAction:      common.SecondaryErrorActionMetric,
+			expectedResponse:    success,
+			expectedClusterType: common.ClusterTypeOrigin,
+		},
+		{
+			name:           "listed action takes precedence over the default one",
+			policy:         policyWithDefault,
+			primaryCluster: common.ClusterTypeOrigin,
+			request:        insert,
+			originResponse: success,
+			targetResponse: unavailable,
+			expectedAction: common.SecondaryErrorActionError,
+		},
+		{
+			name:           "unprepared is never translated",
+			policy:         policyWithDefault,
+			primaryCluster: common.ClusterTypeOrigin,
+			request:        insert,
+			originResponse: success,
+			targetResponse: unprepared,
+			expectedAction: common.SecondaryErrorActionError,
+		},
+		{
+			name:           "failure on primary",
+			policy:         policy,
+			primaryCluster: common.ClusterTypeOrigin,
+			request:        insert,
+			originResponse: unavailable,
+			targetResponse: success,
+			expectedAction: common.SecondaryErrorActionError,
+		},
+		{
+			name:           "failure on both",
+			policy:         policy,
+			primaryCluster: common.ClusterTypeOrigin,
+			request:        insert,
+			originResponse: unavailable,
+			targetResponse: unavailable,
+			expectedAction: common.SecondaryErrorActionError,
+		},
+		{
+			name:           "prepare",
+			policy:         policy,
+			primaryCluster: common.ClusterTypeOrigin,
+			request:        mockPrepareFrame(t, "INSERT INTO ks.tbl (a) VALUES (?)"),
+			originResponse: success,
+			targetResponse: unavailable,
+			expectedAction: common.SecondaryErrorActionError,
+		},
+		{
+			name:           "disabled",
+			policy:         nil,
+			primaryCluster: common.ClusterTypeOrigin,
+			request:        insert,
+			originResponse: success,
+			targetResponse: unavailable,
+			expectedAction: common.SecondaryErrorActionError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, clusterType, action := tt.policy.translate(
+				tt.primaryCluster, tt.request, tt.originResponse, tt.targetResponse)
+			require.Equal(t, tt.expectedAction, action)
+			require.Equal(t, tt.expectedResponse, response)
+			require.Equal(t, tt.expectedClusterType, clusterType)
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package zdmproxy
+
+import (
+	"context"
+	"fmt"
+	"github.com/datastax/zdm-proxy/proxy/pkg/sharedconfig"
+	log "github.com/sirupsen/logrus"
+	"os"
+)
+
+// startSharedConfigWatcher applies the shared settings before the proxy starts accepting client connections, so that
+// every instance of the fleet starts with the same routing, and then applies their changes until shutdown.
+func (p *ZdmProxy) startSharedConfigWatcher(ctx context.Context) error {
+	settings, err := p.sharedConfigWatcher.Load(ctx)
+	if err != nil {
+		return err
+	}
+	err = p.applySharedSettings(settings)
+	if err != nil {
+		return fmt.Errorf("invalid shared configuration: %w", err)
+	}
+
+	p.sharedConfigWg.Add(1)
+	go func() {
+		defer p.sharedConfigWg.Done()
+		p.sharedConfigWatcher.Watch(p.sharedConfigCtx, func(settings *sharedconfig.Settings) {
+			err := p.applySharedSettings(settings)
+			if err != nil {
+				log.Errorf("Ignoring the change of the shared configuration, the previous settings are kept: %v", err)
+			}
+		})
+	}()
+	return nil
+}
+
+// applySharedSettings updates the routing used by new client connections (existing connections keep the routing they
+// were created with unless the cluster roles were swapped) and the request rate limits.
+func (p *ZdmProxy) applySharedSettings(settings *sharedconfig.Settings) error {
+	conf, err := settings.Apply(p.Conf)
+	if err != nil {
+		return err
+	}
+	primaryCluster, err := conf.ParsePrimaryCluster()
+	if err != nil {
+		return err
+	}
+	readMode, err := conf.ParseReadMode()
+	if err != nil {
+		return err
+	}
+	requestRateLimit, err := conf.ParseProxyRequestRateLimit()
+	if err != nil {
+		return err
+	}
+	globalRequestRateLimit, err := conf.ParseProxyGlobalRequestRateLimit()
+	if err != nil {
+		return err
+	}
+
+	p.lock.Lock()
+	if primaryCluster != p.primaryCluster {
+		log.Infof("Shared configuration: primary cluster changed from %v to %v for new client connections.",
+			p.primaryCluster, primaryCluster)
+		p.primaryCluster = primaryCluster
+	}
+	if readMode != p.readMode {
+		log.Infof("Shared configuration: read mode changed from %v to %v for new client connections.",
+			p.readMode, readMode)
+		p.readMode = readMode
+	}
+	clusterRolesSwapped := settings.ClusterRolesSwappedAt != "" &&
+		settings.ClusterRolesSwappedAt != p.clusterRolesSwappedAt
+	p.clusterRolesSwappedAt = settings.ClusterRolesSwappedAt
+	p.lock.Unlock()
+
+	if clusterRolesSwapped {
+		p.drainClientConnections(fmt.Sprintf("cluster roles swapped at %v", settings.ClusterRolesSwappedAt))
+	}
+
+	if previous := p.requestRateLimiter.GetRate(); previous != requestRateLimit {
+		log.Infof("Shared configuration: request rate limit changed from %d to %d requests per second.",
+			previous, requestRateLimit)
+		p.requestRateLimiter.SetRate(requestRateLimit)
+	}
+	if previous := p.globalRequestRateLimiter.GetRate(); previous != globalRequestRateLimit {
+		log.Infof("Shared configuration: global request rate limit changed from %d to %d requests per second.",
+			previous, globalRequestRateLimit)
+		p.globalRequestRateLimiter.SetRate(globalRequestRateLimit)
+	}
+	return nil
+}
+
+// startFleetTasks starts the Extensions.FleetTasks and the automatic cutover, on this instance only while it is the leader of the fleet if
+// shared configuration is enabled, and until shutdown otherwise.
+func (p *ZdmProxy) startFleetTasks() {
+	fleetTasks := p.extensions.FleetTasks
+	if p.autoCutover != nil {
+		fleetTasks = append(fleetTasks[:len(fleetTasks):len(fleetTasks)], p.autoCutover.run)
+	}
+	fleetLeader := p.metricHandler.GetProxyMetrics().FleetLeader
+	if p.leaderElector == nil {
+		fleetLeader.Set(1)
+		for _, task := range fleetTasks {
+			p.sharedConfigWg.Add(1)
+			go func(task func(context.Context)) {
+				defer p.sharedConfigWg.Done()
+				task(p.sharedConfigCtx)
+			}(task)
+		}
+		return
+	}
+
+	for _, task := range fleetTasks {
+		p.leaderElector.AddTask(task)
+	}
+	p.leaderElector.OnLeadershipChange(func(leader bool) {
+		if leader {
+			fleetLeader.Set(1)
+		} else {
+			fleetLeader.Set(0)
+		}
+	})
+	p.sharedConfigWg.Add(1)
+	go func() {
+		defer p.sharedConfigWg.Done()
+		p.leaderElector.Run(p.sharedConfigCtx)
+	}()
+}
+
+// IsFleetLeader returns true if this instance runs the tasks that run once per fleet, which is always the case when
+// shared configuration is disabled.
+func (p *ZdmProxy) IsFleetLeader() bool {
+	if p.leaderElector == nil {
+		return true
+	}
+	return p.leaderElector.IsLeader()
+}
+
+// fleetInstanceId identifies this instance in the leadership key of the fleet.
+func (p *ZdmProxy) fleetInstanceId() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%v:%d/%d", hostname, p.Conf.ProxyListenPort, p.Conf.ProxyTopologyIndex)
+}
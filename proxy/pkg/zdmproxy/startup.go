@@ -52,22 +52,41 @@ func (ch *ClientHandler) handleSecondaryHandshakeStartup(
 	}
 
 	log.Infof("Initiating startup between %v and %v (%v)", clientIPAddress, clusterAddress, logIdentifier)
-	phase := 1
-	attempts := 0
 
-	var authenticator *DsePlainTextAuthenticator
+	var authenticator Authenticator
 	if asyncConnector {
 		if ch.asyncHandshakeCreds != nil {
-			authenticator = &DsePlainTextAuthenticator{
-				Credentials: ch.asyncHandshakeCreds,
-			}
+			authenticator = newClusterAuthenticator(ch.conf, ch.asyncConnector.clusterType, ch.asyncHandshakeCreds)
 		}
 	} else if ch.secondaryHandshakeCreds != nil {
-		authenticator = &DsePlainTextAuthenticator{
-			Credentials: ch.secondaryHandshakeCreds,
+		secondaryClusterType := common.ClusterTypeTarget
+		if ch.forwardAuthToTarget {
+			secondaryClusterType = common.ClusterTypeOrigin
 		}
+		authenticator = newClusterAuthenticator(ch.conf, secondaryClusterType, ch.secondaryHandshakeCreds)
 	}
 
+	if asyncConnector {
+		// the async connector didn't receive the STARTUP request of the client
+		startupResponse = nil
+	}
+
+	return ch.performClusterHandshake(
+		startupRequest, startupResponse, authenticator, forwardToSecondary, asyncConnector,
+		clusterAddress, logIdentifier, requestTimeout)
+}
+
+// performClusterHandshake completes the handshake with a cluster on behalf of the client. If startupResponse is nil
+// then the STARTUP request is sent first, otherwise startupResponse is the response that the cluster already sent.
+func (ch *ClientHandler) performClusterHandshake(
+	startupRequest *frame.RawFrame, startupResponse *frame.RawFrame, authenticator Authenticator,
+	forwardDecision forwardDecision, asyncConnector bool, clusterAddress net.Addr, logIdentifier string,
+	requestTimeout time.Duration) error {
+
+	clientIPAddress := ch.clientConnector.connection.RemoteAddr()
+	phase := 1
+	attempts := 0
+
 	var lastResponse *frame.Frame
 	for {
 		if attempts > maxAuthRetries {
@@ -82,7 +101,7 @@ func (ch *ClientHandler) handleSecondaryHandshakeStartup(
 
 		switch phase {
 		case 1:
-			requestSent = !asyncConnector
+			requestSent = startupResponse != nil
 			request = startupRequest
 			response = startupResponse
 		case 2:
@@ -112,11 +131,13 @@ func (ch *ClientHandler) handleSecondaryHandshakeStartup(
 			channel := make(chan *customResponse, 1)
 			err := ch.executeRequest(
 				NewFrameDecodeContext(request),
-				NewGenericRequestInfo(forwardToSecondary, asyncConnector, false),
+				NewGenericRequestInfo(forwardDecision, asyncConnector, false),
 				ch.LoadCurrentKeyspace(),
 				overallRequestStartTime,
 				channel,
-				requestTimeout)
+				requestTimeout,
+				nil,
+				nil)
 
 			if err != nil {
 				return fmt.Errorf("unable to send secondary (%v) handshake frame to %v: %w", logIdentifier, clusterAddress, err)
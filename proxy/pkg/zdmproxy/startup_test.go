@@ -0,0 +1,83 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"net"
+	"testing"
+)
+
+func TestHandleSecondaryHandshakeResponse(t *testing.T) {
+	tests := []struct {
+		name          string
+		msg           message.Message
+		expectedPhase int
+		expectedDone  bool
+		expectedErr   bool
+	}{
+		{"authenticate", &message.Authenticate{Authenticator: "PasswordAuthenticator"}, 2, false, false},
+		{"auth challenge", &message.AuthChallenge{Token: []byte{1}}, 1, false, false},
+		{"ready", &message.Ready{}, 1, true, false},
+		{"auth success", &message.AuthSuccess{}, 1, true, false},
+		{"auth error", &message.AuthenticationError{ErrorMessage: "bad credentials"}, 1, false, true},
+		{"server error", &message.ServerError{ErrorMessage: "test"}, 1, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rawFrame, err := defaultCodec.ConvertToRawFrame(frame.NewFrame(primitive.ProtocolVersion4, 0, tt.msg))
+			require.Nil(t, err)
+			phase, parsedFrame, done, err := handleSecondaryHandshakeResponse(
+				1, rawFrame, &net.TCPAddr{}, &net.TCPAddr{}, "test")
+			require.Equal(t, tt.expectedPhase, phase)
+			require.Equal(t, tt.expectedDone, done)
+			require.Equal(t, tt.expectedErr, err != nil)
+			require.NotNil(t, parsedFrame)
+		})
+	}
+}
+
+// FuzzHandleSecondaryHandshakeResponse sends arbitrary responses to the secondary (async) handshake logic.
+//
+// Run it with: go test ./proxy/pkg/zdmproxy -run '^$' -fuzz FuzzHandleSecondaryHandshakeResponse
+func FuzzHandleSecondaryHandshakeResponse(f *testing.F) {
+	for _, msg := range []message.Message{
+		&message.Authenticate{Authenticator: "org.apache.cassandra.auth.PasswordAuthenticator"},
+		&message.AuthChallenge{Token: []byte("challenge")},
+		&message.AuthSuccess{Token: []byte("success")},
+		&message.Ready{},
+		&message.AuthenticationError{ErrorMessage: "bad credentials"},
+		&message.ProtocolError{ErrorMessage: "Invalid or unsupported protocol version"},
+	} {
+		rawFrame, err := defaultCodec.ConvertToRawFrame(frame.NewFrame(primitive.ProtocolVersion4, 0, msg))
+		require.Nil(f, err)
+		f.Add(uint8(rawFrame.Header.Version), uint8(rawFrame.Header.OpCode), rawFrame.Body)
+	}
+	// AUTHENTICATE with a string length that exceeds the body length
+	f.Add(uint8(primitive.ProtocolVersion4), uint8(primitive.OpCodeAuthenticate), []byte{0x7f, 0xff, 'a'})
+	// ERROR with an unknown error code
+	f.Add(uint8(primitive.ProtocolVersion4), uint8(primitive.OpCodeError), []byte{0x7f, 0xff, 0xff, 0xff, 0x00, 0x00})
+
+	f.Fuzz(func(t *testing.T, version uint8, opCode uint8, body []byte) {
+		rawFrame := &frame.RawFrame{
+			Header: &frame.Header{
+				IsResponse: true,
+				Version:    primitive.ProtocolVersion(version),
+				OpCode:     primitive.OpCode(opCode),
+				BodyLength: int32(len(body)),
+			},
+			Body: body,
+		}
+		defer freeMemoryAfterLargeAllocations()
+		phase, parsedFrame, done, err := handleSecondaryHandshakeResponse(
+			1, rawFrame, &net.TCPAddr{}, &net.TCPAddr{}, "fuzz")
+		if done && err != nil {
+			t.Fatalf("handshake can not be done and failed at the same time: %v", err)
+		}
+		if err == nil && parsedFrame == nil {
+			t.Fatalf("successful handshake step without a response frame (phase %d)", phase)
+		}
+	})
+}
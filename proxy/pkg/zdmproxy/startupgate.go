@@ -0,0 +1,92 @@
+package zdmproxy
+
+import (
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"sync"
+	"time"
+)
+
+// startupGate binds the client listener before the proxy is started when ZDM_STARTUP_POLICY is REFUSE_CONNECTIONS,
+// so that the port is open while the start is retried, and closes the client connections until the control
+// connections to both clusters are established and authenticated. The listener is then handed over to the started
+// proxy without being closed.
+type startupGate struct {
+	listener *net.TCPListener
+	wg       *sync.WaitGroup
+	lock     *sync.Mutex
+	stopped  bool
+}
+
+func newStartupGate(address string, port int) (*startupGate, error) {
+	l, err := net.Listen("tcp", fmt.Sprintf("%s:%d", address, port))
+	if err != nil {
+		return nil, err
+	}
+	g := &startupGate{listener: l.(*net.TCPListener), wg: &sync.WaitGroup{}, lock: &sync.Mutex{}}
+	log.Infof("Refusing client connections on %v until the control connections to ORIGIN and TARGET are "+
+		"established (ZDM_STARTUP_POLICY=REFUSE_CONNECTIONS).", l.Addr())
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		for {
+			conn, err := g.listener.Accept()
+			if err != nil {
+				if g.isStopped() {
+					return
+				}
+				var netErr net.Error
+				if !errors.As(err, &netErr) || !netErr.Timeout() {
+					log.Errorf("Error while refusing new connections during startup: %v", err)
+				}
+				continue
+			}
+			log.Debugf("Refusing client connection from %v because the proxy is starting.", conn.RemoteAddr())
+			err = conn.Close()
+			if err != nil {
+				log.Warnf("Error closing client connection from %v: %v", conn.RemoteAddr(), err)
+			}
+		}
+	}()
+	return g, nil
+}
+
+func (g *startupGate) isStopped() bool {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return g.stopped
+}
+
+func (g *startupGate) stop() {
+	g.lock.Lock()
+	g.stopped = true
+	g.lock.Unlock()
+	// unblocks Accept without closing the listener
+	_ = g.listener.SetDeadline(time.Now())
+	g.wg.Wait()
+	_ = g.listener.SetDeadline(time.Time{})
+}
+
+// handOver stops refusing the client connections and returns the listener, it's nil safe.
+func (g *startupGate) handOver() net.Listener {
+	if g == nil {
+		return nil
+	}
+	g.stop()
+	return g.listener
+}
+
+// close stops refusing the client connections and closes the listener, e.g. when the proxy could not be started.
+func (g *startupGate) close() {
+	if g == nil {
+		return
+	}
+	g.stop()
+	err := g.listener.Close()
+	if err != nil {
+		log.Warnf("Error closing the client listener of the startup gate: %v", err)
+	}
+}
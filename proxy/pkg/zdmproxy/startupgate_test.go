@@ -0,0 +1,52 @@
+package zdmproxy
+
+import (
+	"github.com/stretchr/testify/require"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestStartupGate_RefusesConnectionsUntilHandOver(t *testing.T) {
+	gate, err := newStartupGate("127.0.0.1", 0)
+	require.Nil(t, err)
+	addr := gate.listener.Addr().String()
+
+	// the connection is accepted and closed right away
+	conn, err := net.Dial("tcp", addr)
+	require.Nil(t, err)
+	require.Nil(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err = conn.Read(make([]byte, 1))
+	require.Equal(t, io.EOF, err)
+	require.Nil(t, conn.Close())
+
+	l := gate.handOver()
+	defer l.Close()
+	conn, err = net.Dial("tcp", addr)
+	require.Nil(t, err)
+	defer conn.Close()
+	accepted, err := l.Accept()
+	require.Nil(t, err)
+	defer accepted.Close()
+	_, err = conn.Write([]byte{1})
+	require.Nil(t, err)
+	buf := make([]byte, 1)
+	_, err = io.ReadFull(accepted, buf)
+	require.Nil(t, err)
+	require.Equal(t, byte(1), buf[0])
+}
+
+func TestStartupGate_Close(t *testing.T) {
+	gate, err := newStartupGate("127.0.0.1", 0)
+	require.Nil(t, err)
+	addr := gate.listener.Addr().String()
+	gate.close()
+	_, err = net.Dial("tcp", addr)
+	require.NotNil(t, err)
+
+	// nil safe when the policy is DELAY_LISTENER
+	var noGate *startupGate
+	require.Nil(t, noGate.handOver())
+	noGate.close()
+}
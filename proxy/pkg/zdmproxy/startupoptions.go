@@ -0,0 +1,60 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"sort"
+	"strings"
+)
+
+// sanitizeStartupRequest removes the STARTUP options that match one of the provided names (see
+// config.ParseStartupStrippedOptions) so that the clusters don't reject the handshake because of an option that
+// only one of them understands. It returns the original frame and no removed options if nothing matched.
+func sanitizeStartupRequest(f *frame.RawFrame, strippedOptions []string) (*frame.RawFrame, []string, error) {
+	decodedFrame, err := defaultCodec.ConvertFromRawFrame(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not decode STARTUP frame: %w", err)
+	}
+
+	startup, ok := decodedFrame.Body.Message.(*message.Startup)
+	if !ok {
+		return nil, nil, fmt.Errorf("expected STARTUP but got %v", decodedFrame.Body.Message)
+	}
+
+	newOptions := make(map[string]string, len(startup.Options))
+	var removedOptions []string
+	for key, value := range startup.Options {
+		if isStrippedStartupOption(key, strippedOptions) {
+			removedOptions = append(removedOptions, key)
+			continue
+		}
+		newOptions[key] = value
+	}
+	if len(removedOptions) == 0 {
+		return f, nil, nil
+	}
+	sort.Strings(removedOptions)
+
+	newFrame := decodedFrame.Clone()
+	newFrame.Body.Message = &message.Startup{Options: newOptions}
+	newRawFrame, err := defaultCodec.ConvertToRawFrame(newFrame)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not convert sanitized STARTUP frame to raw frame: %w", err)
+	}
+	return newRawFrame, removedOptions, nil
+}
+
+func isStrippedStartupOption(key string, strippedOptions []string) bool {
+	key = strings.ToUpper(key)
+	for _, strippedOption := range strippedOptions {
+		if strings.HasSuffix(strippedOption, "*") {
+			if strings.HasPrefix(key, strings.TrimSuffix(strippedOption, "*")) {
+				return true
+			}
+		} else if key == strippedOption {
+			return true
+		}
+	}
+	return false
+}
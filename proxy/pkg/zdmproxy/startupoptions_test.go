@@ -0,0 +1,56 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestSanitizeStartupRequest(t *testing.T) {
+	options := map[string]string{
+		"CQL_VERSION":       "3.0.0",
+		"COMPRESSION":       "lz4",
+		"THROW_ON_OVERLOAD": "1",
+		"DRIVER_NAME":       "DataStax Java driver for Apache Cassandra(R)",
+		"DRIVER_VERSION":    "4.17.0",
+	}
+
+	tests := []struct {
+		name            string
+		strippedOptions []string
+		expectedOptions map[string]string
+		expectedRemoved []string
+	}{
+		{"nothing matches", []string{"APPLICATION_NAME"}, options, nil},
+		{"exact names", []string{"COMPRESSION", "THROW_ON_OVERLOAD"},
+			map[string]string{
+				"CQL_VERSION":    "3.0.0",
+				"DRIVER_NAME":    "DataStax Java driver for Apache Cassandra(R)",
+				"DRIVER_VERSION": "4.17.0",
+			}, []string{"COMPRESSION", "THROW_ON_OVERLOAD"}},
+		{"prefix", []string{"DRIVER_*"},
+			map[string]string{"CQL_VERSION": "3.0.0", "COMPRESSION": "lz4", "THROW_ON_OVERLOAD": "1"},
+			[]string{"DRIVER_NAME", "DRIVER_VERSION"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request, err := defaultCodec.ConvertToRawFrame(
+				frame.NewFrame(primitive.ProtocolVersion4, 0, &message.Startup{Options: options}))
+			require.Nil(t, err)
+			newRequest, removed, err := sanitizeStartupRequest(request, tt.strippedOptions)
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedRemoved, removed)
+			if tt.expectedRemoved == nil {
+				require.Same(t, request, newRequest)
+				return
+			}
+			decodedRequest, err := defaultCodec.ConvertFromRawFrame(newRequest)
+			require.Nil(t, err)
+			require.Equal(t, request.Header.Version, newRequest.Header.Version)
+			require.Equal(t, &message.Startup{Options: tt.expectedOptions}, decodedRequest.Body.Message)
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"sync"
+	"time"
+)
+
+// StatementFingerprint maps a fingerprint of the statement metrics to its normalized statement.
+type StatementFingerprint struct {
+	Fingerprint string    `json:"fingerprint"`
+	Statement   string    `json:"statement"`
+	FirstSeen   time.Time `json:"first_seen"`
+}
+
+// statementMetricsTracker records the latency and the failures of the requests of each statement fingerprint on each
+// cluster (statement_request_duration_seconds and statement_requests_failed_total) when
+// ZDM_STATEMENT_METRICS_MAX_FINGERPRINTS is set. Once that many fingerprints have metrics, the requests of the other
+// statements are recorded with the "other" fingerprint so that the cardinality of the metrics stays bounded.
+//
+// The fingerprints are the labels of the metrics, the statements they stand for are returned by the admin API.
+type statementMetricsTracker struct {
+	metricFactory   metrics.MetricFactory
+	maxFingerprints int
+	originBuckets   []float64
+	targetBuckets   []float64
+
+	lock         *sync.RWMutex
+	fingerprints map[string]*trackedStatementFingerprint
+	other        *trackedStatementFingerprint
+}
+
+type trackedStatementFingerprint struct {
+	statement     StatementFingerprint
+	originMetrics *metrics.StatementMetrics
+	targetMetrics *metrics.StatementMetrics
+}
+
+func newStatementMetricsTracker(
+	metricFactory metrics.MetricFactory, maxFingerprints int, originBuckets []float64, targetBuckets []float64) (
+	*statementMetricsTracker, error) {
+	t := &statementMetricsTracker{
+		metricFactory:   metricFactory,
+		maxFingerprints: maxFingerprints,
+		originBuckets:   originBuckets,
+		targetBuckets:   targetBuckets,
+		lock:            &sync.RWMutex{},
+		fingerprints:    map[string]*trackedStatementFingerprint{},
+	}
+	other, err := t.createMetrics(metrics.StatementFingerprintOther, "")
+	if err != nil {
+		return nil, err
+	}
+	t.other = other
+	return t, nil
+}
+
+func (t *statementMetricsTracker) createMetrics(fingerprint string, statement string) (
+	*trackedStatementFingerprint, error) {
+	originMetrics, err := metrics.CreateStatementMetrics(
+		t.metricFactory, fingerprint, metrics.StatementClusterOrigin, t.originBuckets)
+	if err != nil {
+		return nil, fmt.Errorf("could not create the origin metrics of statement fingerprint %v: %w", fingerprint, err)
+	}
+	targetMetrics, err := metrics.CreateStatementMetrics(
+		t.metricFactory, fingerprint, metrics.StatementClusterTarget, t.targetBuckets)
+	if err != nil {
+		return nil, fmt.Errorf("could not create the target metrics of statement fingerprint %v: %w", fingerprint, err)
+	}
+	return &trackedStatementFingerprint{
+		statement:     StatementFingerprint{Fingerprint: fingerprint, Statement: statement, FirstSeen: time.Now()},
+		originMetrics: originMetrics,
+		targetMetrics: targetMetrics,
+	}, nil
+}
+
+// get returns the metrics of a statement fingerprint, they are created the first time the fingerprint is seen unless
+// the cardinality cap is reached.
+func (t *statementMetricsTracker) get(statement *statementFingerprint) *trackedStatementFingerprint {
+	t.lock.RLock()
+	tracked, ok := t.fingerprints[statement.fingerprint]
+	full := len(t.fingerprints) >= t.maxFingerprints
+	t.lock.RUnlock()
+	if ok {
+		return tracked
+	}
+	if full {
+		return t.other
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	if tracked, ok = t.fingerprints[statement.fingerprint]; ok {
+		return tracked
+	}
+	if len(t.fingerprints) >= t.maxFingerprints {
+		return t.other
+	}
+	tracked, err := t.createMetrics(statement.fingerprint, statement.normalized())
+	if err != nil {
+		log.Warnf("Recording the requests of statement fingerprint %v as %v: %v",
+			statement.fingerprint, metrics.StatementFingerprintOther, err)
+		return t.other
+	}
+	t.fingerprints[statement.fingerprint] = tracked
+	if len(t.fingerprints) == t.maxFingerprints {
+		log.Infof("The statement metrics reached %d fingerprints (ZDM_STATEMENT_METRICS_MAX_FINGERPRINTS), the "+
+			"requests of the new statements are recorded with the %v fingerprint.",
+			t.maxFingerprints, metrics.StatementFingerprintOther)
+	}
+	return tracked
+}
+
+// record accounts a response of a cluster to a statement.
+func (t *statementMetricsTracker) record(
+	connectorType ClusterConnectorType, statement *statementFingerprint, response *frame.RawFrame, startTime time.Time) {
+	if t == nil || statement == nil {
+		return
+	}
+	var statementMetrics *metrics.StatementMetrics
+	switch connectorType {
+	case ClusterConnectorTypeOrigin:
+		statementMetrics = t.get(statement).originMetrics
+	case ClusterConnectorTypeTarget:
+		statementMetrics = t.get(statement).targetMetrics
+	default:
+		return
+	}
+	statementMetrics.RequestDuration.Track(startTime)
+	if !isResponseSuccessful(response) {
+		statementMetrics.FailedRequests.Add(1)
+	}
+}
+
+// getFingerprints returns the fingerprints that have metrics, sorted by the time they were first seen.
+func (t *statementMetricsTracker) getFingerprints() []StatementFingerprint {
+	t.lock.RLock()
+	fingerprints := make([]StatementFingerprint, 0, len(t.fingerprints))
+	for _, tracked := range t.fingerprints {
+		fingerprints = append(fingerprints, tracked.statement)
+	}
+	t.lock.RUnlock()
+	sort.Slice(fingerprints, func(i, j int) bool {
+		return fingerprints[i].FirstSeen.Before(fingerprints[j].FirstSeen)
+	})
+	return fingerprints
+}
@@ -0,0 +1,72 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics/prommetrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestStatementMetrics_CardinalityCap(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	buckets := []float64{0.01, 0.1, 1}
+	tracker, err := newStatementMetricsTracker(
+		prommetrics.NewPrometheusMetricFactory(registry, "zdm"), 2, buckets, buckets)
+	require.Nil(t, err)
+
+	success := mockFrame(t, &message.VoidResult{}, primitive.ProtocolVersion4)
+	failure := mockFrame(t, &message.Overloaded{ErrorMessage: "overloaded"}, primitive.ProtocolVersion4)
+	first := newStatementFingerprint("SELECT * FROM ks.tbl WHERE id = 1")
+	second := newStatementFingerprint("INSERT INTO ks.tbl (id) VALUES (1)")
+	third := newStatementFingerprint("DELETE FROM ks.tbl WHERE id = 1")
+	start := time.Now()
+	tracker.record(ClusterConnectorTypeOrigin, first, success, start)
+	tracker.record(ClusterConnectorTypeOrigin, newStatementFingerprint("select * from ks.tbl where id = 2"), failure, start)
+	tracker.record(ClusterConnectorTypeOrigin, second, success, start)
+	tracker.record(ClusterConnectorTypeTarget, second, failure, start)
+	// the cap is reached
+	tracker.record(ClusterConnectorTypeOrigin, third, failure, start)
+	tracker.record(ClusterConnectorTypeAsync, third, failure, start)
+
+	fingerprints := tracker.getFingerprints()
+	require.Len(t, fingerprints, 2)
+	require.Equal(t, first.fingerprint, fingerprints[0].Fingerprint)
+	require.Equal(t, "select * from ks.tbl where id = ?", fingerprints[0].Statement)
+	require.Equal(t, second.fingerprint, fingerprints[1].Fingerprint)
+
+	families, err := registry.Gather()
+	require.Nil(t, err)
+	durations := map[string]uint64{}
+	failures := map[string]float64{}
+	for _, family := range families {
+		for _, m := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range m.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			key := labels["fingerprint"] + "/" + labels["cluster"]
+			switch family.GetName() {
+			case "zdm_statement_request_duration_seconds":
+				durations[key] = m.GetHistogram().GetSampleCount()
+			case "zdm_statement_requests_failed_total":
+				failures[key] = m.GetCounter().GetValue()
+			}
+		}
+	}
+	require.Equal(t, uint64(2), durations[first.fingerprint+"/origin"])
+	require.Equal(t, 1.0, failures[first.fingerprint+"/origin"])
+	require.Equal(t, uint64(1), durations[second.fingerprint+"/origin"])
+	require.Equal(t, uint64(1), durations[second.fingerprint+"/target"])
+	require.Equal(t, 1.0, failures[second.fingerprint+"/target"])
+	require.Equal(t, uint64(1), durations[metrics.StatementFingerprintOther+"/origin"])
+	require.Equal(t, 1.0, failures[metrics.StatementFingerprintOther+"/origin"])
+	require.Equal(t, uint64(0), durations[metrics.StatementFingerprintOther+"/target"])
+	require.NotContains(t, durations, third.fingerprint+"/origin")
+
+	var disabled *statementMetricsTracker
+	disabled.record(ClusterConnectorTypeOrigin, first, success, start)
+}
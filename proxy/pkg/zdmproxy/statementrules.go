@@ -0,0 +1,327 @@
+package zdmproxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/mutationexport"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+)
+
+const (
+	statementRuleActionAllow = "allow"
+	statementRuleActionDeny  = "deny"
+)
+
+// statementRules is the RequestInterceptor of ZDM_STATEMENT_RULES_FILE, a JSON object like
+//
+//	{"default_action": "allow", "rules": [
+//	  {"name": "no-filtering", "action": "deny", "pattern": "(?i)\\bALLOW\\s+FILTERING\\b", "error": "..."},
+//	  {"name": "reports", "action": "allow", "fingerprint": "8a4e0c3b5d2f1e07"}]}
+//
+// The statements of QUERY, PREPARE and BATCH requests are checked against the rules in order and the first rule that
+// matches (with a regular expression or with the fingerprint of the mutation export) decides whether the statement is
+// forwarded. Statements that don't match any rule get the default action. Denied requests receive an UNAUTHORIZED
+// error with the message of the rule. EXECUTE requests are not checked because their statement was checked when it was
+// prepared. With the deny default action the queries that drivers send to the system tables must be allowed too.
+//
+// The rules can be added and removed through the admin API without a restart, these changes are not written to the
+// file so they are lost when the proxy restarts.
+type statementRules struct {
+	lock          *sync.RWMutex
+	defaultAction string
+	rules         []*StatementRule // replaced, never modified, when a rule is added or removed
+}
+
+// StatementRuleSet is the content of the statement rules file and of the statement rules of the admin API.
+type StatementRuleSet struct {
+	DefaultAction string           `json:"default_action"`
+	Rules         []*StatementRule `json:"rules"`
+}
+
+// StatementRule allows or denies the statements that match its pattern (a regular expression) or its fingerprint,
+// see statementRules.
+type StatementRule struct {
+	Name        string `json:"name"`
+	Action      string `json:"action"`
+	Pattern     string `json:"pattern,omitempty"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+	Error       string `json:"error,omitempty"`
+
+	regex *regexp.Regexp
+}
+
+func (rule *StatementRule) compile() error {
+	if rule.Action != statementRuleActionAllow && rule.Action != statementRuleActionDeny {
+		return fmt.Errorf("invalid action %v of statement rule %v; possible values are: %v and %v",
+			rule.Action, rule.Name, statementRuleActionAllow, statementRuleActionDeny)
+	}
+	if (rule.Pattern == "") == (rule.Fingerprint == "") {
+		return fmt.Errorf("statement rule %v must have either a pattern or a fingerprint", rule.Name)
+	}
+	if rule.Pattern != "" {
+		regex, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern of statement rule %v: %w", rule.Name, err)
+		}
+		rule.regex = regex
+	}
+	return nil
+}
+
+// newEmptyStatementRules returns rules that allow every statement until rules are added through the admin API.
+func newEmptyStatementRules() *statementRules {
+	return &statementRules{
+		lock:          &sync.RWMutex{},
+		defaultAction: statementRuleActionAllow,
+	}
+}
+
+func newStatementRules(path string) (*statementRules, error) {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read statement rules file: %w", err)
+	}
+	ruleSet := &StatementRuleSet{}
+	err = json.Unmarshal(file, ruleSet)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse statement rules file: %w", err)
+	}
+
+	if ruleSet.DefaultAction == "" {
+		ruleSet.DefaultAction = statementRuleActionAllow
+	}
+	if ruleSet.DefaultAction != statementRuleActionAllow && ruleSet.DefaultAction != statementRuleActionDeny {
+		return nil, fmt.Errorf("invalid default action %v in statement rules file; possible values are: %v and %v",
+			ruleSet.DefaultAction, statementRuleActionAllow, statementRuleActionDeny)
+	}
+	for i, rule := range ruleSet.Rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("#%d", i+1)
+		}
+		if err = rule.compile(); err != nil {
+			return nil, err
+		}
+	}
+	return &statementRules{
+		lock:          &sync.RWMutex{},
+		defaultAction: ruleSet.DefaultAction,
+		rules:         ruleSet.Rules,
+	}, nil
+}
+
+func (r *statementRules) get() *StatementRuleSet {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return &StatementRuleSet{DefaultAction: r.defaultAction, Rules: append([]*StatementRule{}, r.rules...)}
+}
+
+// add inserts a copy of the rule at position (or after the last rule if position is negative) and returns the position
+// of the rule. The names of the rules that are added must be unique.
+func (r *statementRules) add(rule *StatementRule, position int) (int, error) {
+	ruleCopy := *rule
+	if ruleCopy.Name == "" {
+		return 0, fmt.Errorf("a statement rule that is added must have a name")
+	}
+	if err := ruleCopy.compile(); err != nil {
+		return 0, err
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for _, existingRule := range r.rules {
+		if existingRule.Name == ruleCopy.Name {
+			return 0, fmt.Errorf("statement rule %v already exists", ruleCopy.Name)
+		}
+	}
+	if position < 0 {
+		position = len(r.rules)
+	}
+	if position > len(r.rules) {
+		return 0, fmt.Errorf("invalid position %v, there are %v statement rules", position, len(r.rules))
+	}
+	rules := make([]*StatementRule, 0, len(r.rules)+1)
+	rules = append(rules, r.rules[:position]...)
+	rules = append(rules, &ruleCopy)
+	r.rules = append(rules, r.rules[position:]...)
+	return position, nil
+}
+
+// remove returns the rule with the name that was removed or nil if there is none.
+func (r *statementRules) remove(name string) *StatementRule {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	for i, rule := range r.rules {
+		if rule.Name == name {
+			rules := make([]*StatementRule, 0, len(r.rules)-1)
+			rules = append(rules, r.rules[:i]...)
+			r.rules = append(rules, r.rules[i+1:]...)
+			return rule
+		}
+	}
+	return nil
+}
+
+// isEmpty returns true if every statement is allowed, the requests are not decoded then.
+func (r *statementRules) isEmpty() bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+	return len(r.rules) == 0 && r.defaultAction == statementRuleActionAllow
+}
+
+// check returns the rule that denies the statement or nil if the statement is allowed.
+func (r *statementRules) check(query string) *StatementRule {
+	r.lock.RLock()
+	rules, defaultAction := r.rules, r.defaultAction
+	r.lock.RUnlock()
+
+	fingerprint := ""
+	for _, rule := range rules {
+		matches := false
+		if rule.regex != nil {
+			matches = rule.regex.MatchString(query)
+		} else {
+			if fingerprint == "" {
+				fingerprint = mutationexport.Fingerprint(query)
+			}
+			matches = rule.Fingerprint == fingerprint
+		}
+		if !matches {
+			continue
+		}
+		if rule.Action == statementRuleActionDeny {
+			return rule
+		}
+		return nil
+	}
+	if defaultAction == statementRuleActionDeny {
+		return defaultDenyStatementRule
+	}
+	return nil
+}
+
+var defaultDenyStatementRule = &StatementRule{Name: "default", Action: statementRuleActionDeny}
+
+// statementRuleChange is the audit record of a change of the statement rules through the admin API, it's logged.
+type statementRuleChange struct {
+	Time      time.Time      `json:"time"`
+	Operation string         `json:"operation"`
+	Rule      *StatementRule `json:"rule"`
+	Position  int            `json:"position"`
+	ChangedBy string         `json:"changed_by"`
+}
+
+func logStatementRuleChange(operation string, rule *StatementRule, position int, changedBy string) {
+	record, err := json.Marshal(&statementRuleChange{
+		Time:      time.Now().UTC(),
+		Operation: operation,
+		Rule:      rule,
+		Position:  position,
+		ChangedBy: changedBy,
+	})
+	if err != nil {
+		log.Warnf("Could not serialize the audit record of the change of statement rule %v: %v", rule.Name, err)
+		return
+	}
+	log.Infof("Statement rules changed: %s", record)
+}
+
+// GetStatementRules returns the statement rules that are currently checked, it returns false if the statement rules
+// are disabled (ZDM_STATEMENT_RULES_FILE is not set and ZDM_ADMIN_API_ENABLED is false).
+func (p *ZdmProxy) GetStatementRules() (*StatementRuleSet, bool) {
+	if p.statementRules == nil {
+		return nil, false
+	}
+	return p.statementRules.get(), true
+}
+
+// AddStatementRule validates a statement rule and inserts it at position (after the last rule if position is
+// negative), changedBy identifies the author of the change in the audit record.
+func (p *ZdmProxy) AddStatementRule(rule *StatementRule, position int, changedBy string) error {
+	if p.statementRules == nil {
+		return errStatementRulesDisabled
+	}
+	position, err := p.statementRules.add(rule, position)
+	if err != nil {
+		return err
+	}
+	logStatementRuleChange("add", rule, position, changedBy)
+	return nil
+}
+
+// RemoveStatementRule removes the statement rule with the name, it returns false if there is no such rule.
+func (p *ZdmProxy) RemoveStatementRule(name string, changedBy string) (bool, error) {
+	if p.statementRules == nil {
+		return false, errStatementRulesDisabled
+	}
+	rule := p.statementRules.remove(name)
+	if rule == nil {
+		return false, nil
+	}
+	logStatementRuleChange("remove", rule, -1, changedBy)
+	return true, nil
+}
+
+var errStatementRulesDisabled = errors.New("statement rules are disabled")
+
+func (r *statementRules) OnRequest(
+	conn *InterceptedConnection, request *frame.RawFrame) (*frame.RawFrame, *frame.RawFrame, error) {
+	switch request.Header.OpCode {
+	case primitive.OpCodeQuery, primitive.OpCodePrepare, primitive.OpCodeBatch:
+	default:
+		return nil, nil, nil
+	}
+	if r.isEmpty() {
+		return nil, nil, nil
+	}
+
+	body, err := defaultCodec.DecodeBody(request.Header, bytes.NewReader(request.Body))
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not decode request to check the statement rules: %w", err)
+	}
+	var queries []string
+	switch msg := body.Message.(type) {
+	case *message.Query:
+		queries = append(queries, msg.Query)
+	case *message.Prepare:
+		queries = append(queries, msg.Query)
+	case *message.Batch:
+		for _, child := range msg.Children {
+			// prepared children were checked when they were prepared
+			if query, ok := child.QueryOrId.(string); ok {
+				queries = append(queries, query)
+			}
+		}
+	}
+
+	for _, query := range queries {
+		rule := r.check(query)
+		if rule == nil {
+			continue
+		}
+		log.Debugf("Statement rule %v denied request from %v: %v", rule.Name, conn.ClientAddress, redactedQuery(query))
+		errorMessage := rule.Error
+		if errorMessage == "" {
+			errorMessage = fmt.Sprintf("Statement rejected by the proxy (rule %v)", rule.Name)
+		}
+		response, err := defaultCodec.ConvertToRawFrame(frame.NewFrame(
+			request.Header.Version, request.Header.StreamId, &message.Unauthorized{ErrorMessage: errorMessage}))
+		if err != nil {
+			return nil, nil, errors.New("statement was denied but the error response could not be created")
+		}
+		return nil, response, nil
+	}
+	return nil, nil, nil
+}
+
+func (r *statementRules) OnResponse(
+	_ *InterceptedConnection, _ *frame.RawFrame, _ *frame.RawFrame) (*frame.RawFrame, error) {
+	return nil, nil
+}
@@ -0,0 +1,158 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/zdm-proxy/proxy/pkg/mutationexport"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStatementRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	require.Nil(t, os.WriteFile(path, []byte(`{"rules": [
+		{"name": "allowed scan", "action": "allow", "fingerprint": "`+
+		mutationexport.Fingerprint("SELECT * FROM ks.small_table ALLOW FILTERING")+`"},
+		{"name": "no filtering", "action": "deny", "pattern": "(?i)\\bALLOW\\s+FILTERING\\b",
+		 "error": "ALLOW FILTERING is not allowed"},
+		{"action": "deny", "pattern": "(?i)^\\s*TRUNCATE\\b"}
+	]}`), 0600))
+	rules, err := newStatementRules(path)
+	require.Nil(t, err)
+
+	tests := []struct {
+		name          string
+		request       message.Message
+		expectedError message.Message
+	}{
+		{"allowed query", &message.Query{Query: "SELECT * FROM ks.tb WHERE pk = 1"}, nil},
+		{"denied query", &message.Query{Query: "SELECT * FROM ks.tb WHERE c = 1 allow  filtering"},
+			&message.Unauthorized{ErrorMessage: "ALLOW FILTERING is not allowed"}},
+		{"query allowed by fingerprint", &message.Query{Query: "select *  from ks.small_table allow filtering"}, nil},
+		{"denied prepare", &message.Prepare{Query: "TRUNCATE ks.tb"},
+			&message.Unauthorized{ErrorMessage: "Statement rejected by the proxy (rule #3)"}},
+		{"denied batch child", &message.Batch{Children: []*message.BatchChild{
+			{QueryOrId: "INSERT INTO ks.tb (pk) VALUES (1)"},
+			{QueryOrId: []byte{1, 2, 3}},
+			{QueryOrId: "TRUNCATE ks.tb"}}},
+			&message.Unauthorized{ErrorMessage: "Statement rejected by the proxy (rule #3)"}},
+		{"execute", &message.Execute{QueryId: []byte{1, 2, 3}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := newInterceptorTestFrame(t, 5, tt.request)
+			newRequest, response, err := rules.OnRequest(&InterceptedConnection{}, request)
+			require.Nil(t, err)
+			require.Nil(t, newRequest)
+			if tt.expectedError == nil {
+				require.Nil(t, response)
+				return
+			}
+			require.NotNil(t, response)
+			decodedResponse, err := defaultCodec.ConvertFromRawFrame(response)
+			require.Nil(t, err)
+			require.Equal(t, int16(5), decodedResponse.Header.StreamId)
+			require.Equal(t, tt.expectedError, decodedResponse.Body.Message)
+		})
+	}
+}
+
+func TestStatementRules_DefaultDeny(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.json")
+	require.Nil(t, os.WriteFile(path, []byte(`{"default_action": "deny", "rules": [
+		{"action": "allow", "pattern": "(?i)^SELECT .* FROM system\\."}
+	]}`), 0600))
+	rules, err := newStatementRules(path)
+	require.Nil(t, err)
+
+	require.Nil(t, rules.check("SELECT * FROM system.local"))
+	require.Equal(t, defaultDenyStatementRule, rules.check("SELECT * FROM ks.tb"))
+}
+
+func TestStatementRules_InvalidFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   string
+		errMsg string
+	}{
+		{"invalid default action", `{"default_action": "block"}`,
+			"invalid default action block in statement rules file; possible values are: allow and deny"},
+		{"invalid action", `{"rules": [{"name": "r", "action": "block", "pattern": "x"}]}`,
+			"invalid action block of statement rule r; possible values are: allow and deny"},
+		{"no pattern or fingerprint", `{"rules": [{"action": "deny"}]}`,
+			"statement rule #1 must have either a pattern or a fingerprint"},
+		{"pattern and fingerprint", `{"rules": [{"action": "deny", "pattern": "x", "fingerprint": "y"}]}`,
+			"statement rule #1 must have either a pattern or a fingerprint"},
+		{"invalid pattern", `{"rules": [{"action": "deny", "pattern": "("}]}`,
+			"invalid pattern of statement rule #1: error parsing regexp: missing closing ): `(`"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "rules.json")
+			require.Nil(t, os.WriteFile(path, []byte(tt.file), 0600))
+			_, err := newStatementRules(path)
+			require.NotNil(t, err)
+			require.Equal(t, tt.errMsg, err.Error())
+		})
+	}
+}
+
+func TestStatementRules_AddRemove(t *testing.T) {
+	rules := newEmptyStatementRules()
+	require.True(t, rules.isEmpty())
+	require.Nil(t, rules.check("TRUNCATE ks.tb"))
+
+	position, err := rules.add(&StatementRule{Name: "no truncate", Action: "deny", Pattern: "(?i)^\\s*TRUNCATE\\b"}, -1)
+	require.Nil(t, err)
+	require.Equal(t, 0, position)
+	require.False(t, rules.isEmpty())
+	require.Equal(t, "no truncate", rules.check("truncate ks.tb").Name)
+
+	// a rule inserted before the deny rule takes precedence
+	position, err = rules.add(&StatementRule{Name: "truncate tmp", Action: "allow", Pattern: "(?i)^\\s*TRUNCATE ks\\.tmp\\b"}, 0)
+	require.Nil(t, err)
+	require.Equal(t, 0, position)
+	require.Nil(t, rules.check("TRUNCATE ks.tmp"))
+	require.NotNil(t, rules.check("TRUNCATE ks.tb"))
+
+	ruleSet := rules.get()
+	require.Equal(t, statementRuleActionAllow, ruleSet.DefaultAction)
+	require.Equal(t, 2, len(ruleSet.Rules))
+	require.Equal(t, "truncate tmp", ruleSet.Rules[0].Name)
+	require.Equal(t, "no truncate", ruleSet.Rules[1].Name)
+
+	for _, tt := range []struct {
+		name     string
+		rule     *StatementRule
+		position int
+		errMsg   string
+	}{
+		{"no name", &StatementRule{Action: "deny", Pattern: "x"}, -1, "a statement rule that is added must have a name"},
+		{"duplicate name", &StatementRule{Name: "no truncate", Action: "deny", Pattern: "x"}, -1,
+			"statement rule no truncate already exists"},
+		{"invalid action", &StatementRule{Name: "r", Action: "block", Pattern: "x"}, -1,
+			"invalid action block of statement rule r; possible values are: allow and deny"},
+		{"invalid pattern", &StatementRule{Name: "r", Action: "deny", Pattern: "("}, -1,
+			"invalid pattern of statement rule r: error parsing regexp: missing closing ): `(`"},
+		{"invalid position", &StatementRule{Name: "r", Action: "deny", Pattern: "x"}, 3,
+			"invalid position 3, there are 2 statement rules"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := rules.add(tt.rule, tt.position)
+			require.NotNil(t, err)
+			require.Equal(t, tt.errMsg, err.Error())
+		})
+	}
+	require.Equal(t, 2, len(rules.get().Rules))
+
+	require.Nil(t, rules.remove("unknown"))
+	require.Equal(t, "no truncate", rules.remove("no truncate").Name)
+	require.Nil(t, rules.check("TRUNCATE ks.tb"))
+	require.Equal(t, "truncate tmp", rules.remove("truncate tmp").Name)
+	require.True(t, rules.isEmpty())
+	// the rule sets that were returned before are not modified
+	require.Equal(t, 2, len(ruleSet.Rules))
+}
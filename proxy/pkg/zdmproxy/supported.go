@@ -0,0 +1,107 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"strconv"
+	"strings"
+)
+
+const cqlVersionOption = "CQL_VERSION"
+
+// mergeSupportedOptions returns the SUPPORTED options that a client can rely on regardless of the cluster that ends
+// up serving its requests: options advertised by a single cluster are removed and the values of the other options
+// (protocol versions, compression algorithms, CQL versions, etc.) are the ones that both clusters advertise, in the
+// order used by target.
+//
+// CQL_VERSION is always returned because some drivers pick the first advertised version when sending STARTUP, if both
+// clusters advertise different versions then the lowest one is used.
+func mergeSupportedOptions(originOptions map[string][]string, targetOptions map[string][]string) map[string][]string {
+	merged := make(map[string][]string)
+	for key, targetValues := range targetOptions {
+		originValues, ok := originOptions[key]
+		if !ok {
+			continue
+		}
+		values := intersectOptionValues(originValues, targetValues)
+		if len(values) == 0 && key == cqlVersionOption {
+			values = lowestCqlVersion(originValues, targetValues)
+		}
+		if len(values) > 0 {
+			merged[key] = values
+		}
+	}
+	merged, _ = stripScyllaOptions(merged)
+	return merged
+}
+
+func intersectOptionValues(originValues []string, targetValues []string) []string {
+	originValuesSet := make(map[string]bool, len(originValues))
+	for _, value := range originValues {
+		originValuesSet[value] = true
+	}
+	values := make([]string, 0, len(targetValues))
+	for _, value := range targetValues {
+		if originValuesSet[value] {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func lowestCqlVersion(originValues []string, targetValues []string) []string {
+	lowest := ""
+	for _, value := range append(append([]string{}, originValues...), targetValues...) {
+		if lowest == "" || compareCqlVersions(value, lowest) < 0 {
+			lowest = value
+		}
+	}
+	if lowest == "" {
+		return nil
+	}
+	return []string{lowest}
+}
+
+// compareCqlVersions compares two CQL versions (e.g. 3.4.5) component by component, non numeric components are
+// considered to be 0.
+func compareCqlVersions(a string, b string) int {
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		var aValue, bValue int
+		if i < len(aParts) {
+			aValue, _ = strconv.Atoi(aParts[i])
+		}
+		if i < len(bParts) {
+			bValue, _ = strconv.Atoi(bParts[i])
+		}
+		if aValue != bValue {
+			if aValue < bValue {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// createLocalSupportedResponse builds the SUPPORTED response of an OPTIONS request from the options of both control
+// connections. It returns nil if the options of one of the clusters are not known yet, in which case the request
+// should be forwarded.
+func (ch *ClientHandler) createLocalSupportedResponse(request *frame.RawFrame) (*frame.RawFrame, error) {
+	originOptions := ch.originControlConn.GetSupportedOptions()
+	targetOptions := ch.targetControlConn.GetSupportedOptions()
+	if originOptions == nil || targetOptions == nil {
+		return nil, nil
+	}
+
+	supportedFrame := frame.NewFrame(request.Header.Version, request.Header.StreamId, &message.Supported{
+		Options: limitProtocolVersions(mergeSupportedOptions(originOptions, targetOptions), ch.maxProtocolVersion),
+	})
+	supportedRawFrame, err := defaultCodec.ConvertToRawFrame(supportedFrame)
+	if err != nil {
+		return nil, fmt.Errorf("could not convert SUPPORTED response %v: %w", supportedFrame, err)
+	}
+	return supportedRawFrame, nil
+}
@@ -0,0 +1,66 @@
+package zdmproxy
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestMergeSupportedOptions(t *testing.T) {
+	tests := []struct {
+		name            string
+		originOptions   map[string][]string
+		targetOptions   map[string][]string
+		expectedOptions map[string][]string
+	}{
+		{
+			name: "same options",
+			originOptions: map[string][]string{
+				"CQL_VERSION": {"3.4.5"}, "COMPRESSION": {"snappy", "lz4"}, "PROTOCOL_VERSIONS": {"3/v3", "4/v4", "5/v5"}},
+			targetOptions: map[string][]string{
+				"CQL_VERSION": {"3.4.5"}, "COMPRESSION": {"lz4", "snappy"}, "PROTOCOL_VERSIONS": {"3/v3", "4/v4", "5/v5"}},
+			expectedOptions: map[string][]string{
+				"CQL_VERSION": {"3.4.5"}, "COMPRESSION": {"lz4", "snappy"}, "PROTOCOL_VERSIONS": {"3/v3", "4/v4", "5/v5"}},
+		},
+		{
+			name: "intersection",
+			originOptions: map[string][]string{
+				"CQL_VERSION": {"3.4.4"}, "COMPRESSION": {"snappy", "lz4"}, "PROTOCOL_VERSIONS": {"3/v3", "4/v4"}},
+			targetOptions: map[string][]string{
+				"CQL_VERSION": {"3.4.5"}, "COMPRESSION": {"lz4", "zstd"}, "PROTOCOL_VERSIONS": {"3/v3", "4/v4", "5/v5"}},
+			expectedOptions: map[string][]string{
+				"CQL_VERSION": {"3.4.4"}, "COMPRESSION": {"lz4"}, "PROTOCOL_VERSIONS": {"3/v3", "4/v4"}},
+		},
+		{
+			name:            "options of a single cluster",
+			originOptions:   map[string][]string{"CQL_VERSION": {"3.4.5"}, "COMPRESSION": {"snappy"}},
+			targetOptions:   map[string][]string{"CQL_VERSION": {"3.4.5"}, "COMPRESSION": {"lz4"}, "PAGE_UNIT": {"bytes"}},
+			expectedOptions: map[string][]string{"CQL_VERSION": {"3.4.5"}},
+		},
+		{
+			name:            "scylla",
+			originOptions:   map[string][]string{"CQL_VERSION": {"3.3.1"}, "SCYLLA_SHARD": {"0"}},
+			targetOptions:   map[string][]string{"CQL_VERSION": {"3.10.0"}, "SCYLLA_SHARD": {"0"}},
+			expectedOptions: map[string][]string{"CQL_VERSION": {"3.3.1"}},
+		},
+		{
+			name:            "empty",
+			originOptions:   map[string][]string{},
+			targetOptions:   map[string][]string{"CQL_VERSION": {"3.4.5"}},
+			expectedOptions: map[string][]string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expectedOptions, mergeSupportedOptions(tt.originOptions, tt.targetOptions))
+		})
+	}
+}
+
+func TestCompareCqlVersions(t *testing.T) {
+	require.Equal(t, 0, compareCqlVersions("3.4.5", "3.4.5"))
+	require.Equal(t, -1, compareCqlVersions("3.4.4", "3.4.5"))
+	require.Equal(t, 1, compareCqlVersions("3.10.0", "3.4.5"))
+	require.Equal(t, 0, compareCqlVersions("3.4", "3.4.0"))
+	require.Equal(t, -1, compareCqlVersions("3.4", "3.4.1"))
+}
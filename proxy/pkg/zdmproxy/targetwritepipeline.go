@@ -0,0 +1,179 @@
+package zdmproxy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/journal"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	"io"
+	"strings"
+	"time"
+)
+
+const targetWriteApplyTimeout = 10 * time.Second
+
+// targetWritePipeline replaces the direct writes to Target when ZDM_TARGET_WRITE_PIPELINE is KAFKA. The statements
+// that would be sent to both clusters are written to a Kafka topic before they are sent to Origin (a request that
+// can't be written to the topic is rejected) and the consumer applies them to Target through the Target control
+// connection, so the writes survive a Target outage and the loss of a proxy host. The other requests (reads, USE,
+// PREPARE, etc.) are forwarded as usual.
+//
+// The entries are applied with the timestamp at which the proxy received them unless the client set one, so a write
+// that is applied late doesn't overwrite a more recent write. They are applied at least once: the consumer skips the
+// operations that it already applied (see ZDM_TARGET_WRITE_PIPELINE_DEDUP_WINDOW_SIZE) but an entry that is consumed
+// again by another proxy after a rebalance is applied twice, which is only an issue for the statements that are not
+// idempotent (counter updates, list appends, etc.).
+type targetWritePipeline struct {
+	journal           journal.Journal
+	consumer          *journal.KafkaConsumer
+	targetControlConn *ControlConn
+	metricHandler     *metrics.MetricHandler
+}
+
+func newTargetWritePipeline(
+	journal journal.Journal, consumer *journal.KafkaConsumer, targetControlConn *ControlConn,
+	metricHandler *metrics.MetricHandler) *targetWritePipeline {
+	return &targetWritePipeline{
+		journal:           journal,
+		consumer:          consumer,
+		targetControlConn: targetControlConn,
+		metricHandler:     metricHandler,
+	}
+}
+
+// route writes the statements that would be sent to both clusters to the topic and returns their request info with
+// Origin as forward decision, the other requests are returned as is. It's nil safe.
+func (p *targetWritePipeline) route(
+	requestInfo RequestInfo, frameContext *frameDecodeContext, currentKeyspace string, client string,
+	timeUuidGenerator TimeUuidGenerator) (RequestInfo, error) {
+	if p == nil {
+		return requestInfo, nil
+	}
+	originOnlyRequestInfo, journaled, err := journalTargetWrite(
+		p.journal, requestInfo, frameContext, currentKeyspace, client, timeUuidGenerator)
+	if err != nil {
+		p.metricHandler.GetProxyMetrics().TargetWritePipelinePublishFailures.Add(1)
+		return nil, err
+	}
+	if journaled {
+		p.metricHandler.GetProxyMetrics().TargetWritePipelinePublishedRequests.Add(1)
+	}
+	return originOnlyRequestInfo, nil
+}
+
+func (p *targetWritePipeline) run(ctx context.Context) {
+	p.consumer.Run(ctx, p.apply)
+}
+
+// apply sends an entry to Target, it returns a journal.PermanentError if the entry can never be applied.
+func (p *targetWritePipeline) apply(entry *journal.Entry) error {
+	decodedFrame, err := defaultCodec.DecodeFrame(bytes.NewReader(entry.Frame))
+	if err != nil {
+		return &journal.PermanentError{Err: fmt.Errorf("could not decode the journaled request: %w", err)}
+	}
+	msg, err := buildTargetWriteMessage(decodedFrame.Body.Message, entry)
+	if err == nil {
+		// the request is sent with the protocol version of the control connection
+		err = defaultCodec.EncodeFrame(frame.NewFrame(ccProtocolVersion, 0, msg), io.Discard)
+	}
+	if err != nil {
+		return &journal.PermanentError{Err: fmt.Errorf("could not convert the journaled %v request: %w", entry.OpCode, err)}
+	}
+
+	ctx, cancelFn := context.WithTimeout(context.Background(), targetWriteApplyTimeout)
+	defer cancelFn()
+	if entry.Keyspace != "" {
+		// the control connection is shared but its own queries are fully qualified
+		useQuery := &message.Query{Query: fmt.Sprintf("USE \"%s\"", strings.ReplaceAll(entry.Keyspace, "\"", "\"\""))}
+		response, err := p.targetControlConn.Execute(useQuery, ctx)
+		if err = checkTargetWriteResponse(response, err); err != nil {
+			return err
+		}
+	}
+	response, err := p.targetControlConn.Execute(msg, ctx)
+	if err = checkTargetWriteResponse(response, err); err != nil {
+		return err
+	}
+	p.metricHandler.GetProxyMetrics().TargetWritePipelineAppliedRequests.Add(1)
+	return nil
+}
+
+// buildTargetWriteMessage returns the QUERY or BATCH message that applies a journaled request to Target: the prepared
+// statements are replaced with their CQL statements (the prepared ids are the ones of Origin) and the options that the
+// control connection protocol version doesn't support are removed.
+func buildTargetWriteMessage(msg message.Message, entry *journal.Entry) (message.Message, error) {
+	timestamp := entry.Timestamp.UnixNano() / int64(time.Microsecond)
+	switch typedMsg := msg.(type) {
+	case *message.Query:
+		return &message.Query{Query: typedMsg.Query, Options: buildTargetWriteOptions(typedMsg.Options, timestamp)}, nil
+	case *message.Execute:
+		if len(entry.Statements) != 1 || entry.Statements[0] == "" {
+			return nil, fmt.Errorf("the prepared statement is unknown")
+		}
+		return &message.Query{Query: entry.Statements[0], Options: buildTargetWriteOptions(typedMsg.Options, timestamp)}, nil
+	case *message.Batch:
+		if len(entry.Statements) != len(typedMsg.Children) {
+			return nil, fmt.Errorf("expected %d statements but got %d", len(typedMsg.Children), len(entry.Statements))
+		}
+		batch := &message.Batch{
+			Type:              typedMsg.Type,
+			Consistency:       typedMsg.Consistency,
+			SerialConsistency: typedMsg.SerialConsistency,
+			DefaultTimestamp:  typedMsg.DefaultTimestamp,
+		}
+		if batch.DefaultTimestamp == nil {
+			batch.DefaultTimestamp = &primitive.NillableInt64{Value: timestamp}
+		}
+		for idx, child := range typedMsg.Children {
+			if entry.Statements[idx] == "" {
+				return nil, fmt.Errorf("the prepared statement of child %d is unknown", idx)
+			}
+			batch.Children = append(batch.Children, &message.BatchChild{QueryOrId: entry.Statements[idx], Values: child.Values})
+		}
+		return batch, nil
+	default:
+		return nil, fmt.Errorf("unexpected %v request", msg.GetOpCode())
+	}
+}
+
+func buildTargetWriteOptions(options *message.QueryOptions, timestamp int64) *message.QueryOptions {
+	if options == nil {
+		options = &message.QueryOptions{}
+	}
+	targetOptions := &message.QueryOptions{
+		Consistency:       options.Consistency,
+		PositionalValues:  options.PositionalValues,
+		NamedValues:       options.NamedValues,
+		SerialConsistency: options.SerialConsistency,
+		DefaultTimestamp:  options.DefaultTimestamp,
+	}
+	if targetOptions.DefaultTimestamp == nil {
+		targetOptions.DefaultTimestamp = &primitive.NillableInt64{Value: timestamp}
+	}
+	return targetOptions
+}
+
+// checkTargetWriteResponse returns nil if Target applied the request and a journal.PermanentError if Target rejected
+// it for a reason that retrying doesn't fix.
+func checkTargetWriteResponse(response message.Message, err error) error {
+	if err != nil {
+		return fmt.Errorf("could not send the request to %v: %w", common.ClusterTypeTarget, err)
+	}
+	errMsg, ok := response.(message.Error)
+	if !ok {
+		return nil
+	}
+	switch errMsg.GetErrorCode() {
+	case primitive.ErrorCodeServerError, primitive.ErrorCodeOverloaded, primitive.ErrorCodeIsBootstrapping,
+		primitive.ErrorCodeUnavailable, primitive.ErrorCodeWriteTimeout, primitive.ErrorCodeReadTimeout,
+		primitive.ErrorCodeWriteFailure, primitive.ErrorCodeTruncateError:
+		return fmt.Errorf("%v returned %v", common.ClusterTypeTarget, errMsg)
+	default:
+		return &journal.PermanentError{Err: fmt.Errorf("%v rejected the request: %v", common.ClusterTypeTarget, errMsg)}
+	}
+}
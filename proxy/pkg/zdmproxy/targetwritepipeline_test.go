@@ -0,0 +1,167 @@
+package zdmproxy
+
+import (
+	"errors"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/journal"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestTargetWritePipeline_Route(t *testing.T) {
+	fakeJournal := &fakeJournal{}
+	timeUuidGenerator, err := GetDefaultTimeUuidGenerator()
+	require.Nil(t, err)
+	pipeline := newTargetWritePipeline(fakeJournal, nil, nil, newFakeMetricHandler())
+	route := func(query string, decision forwardDecision) RequestInfo {
+		requestInfo, err := pipeline.route(
+			NewGenericRequestInfo(decision, false, true), NewFrameDecodeContext(mockQueryFrame(t, query)), "ks", "client", timeUuidGenerator)
+		require.Nil(t, err)
+		return requestInfo
+	}
+
+	require.Equal(t, forwardToOrigin, route("INSERT INTO tbl (pk) VALUES (1)", forwardToBoth).GetForwardDecision())
+	require.Len(t, fakeJournal.entries, 1)
+	require.Equal(t, []string{"INSERT INTO tbl (pk) VALUES (1)"}, fakeJournal.entries[0].Statements)
+
+	// the reads and USE are forwarded as usual
+	require.Equal(t, forwardToTarget, route("SELECT * FROM tbl", forwardToTarget).GetForwardDecision())
+	require.Equal(t, forwardToBoth, route("USE ks", forwardToBoth).GetForwardDecision())
+	require.Len(t, fakeJournal.entries, 1)
+
+	fakeJournal.err = errors.New("broker unavailable")
+	_, err = pipeline.route(
+		NewGenericRequestInfo(forwardToBoth, false, true),
+		NewFrameDecodeContext(mockQueryFrame(t, "INSERT INTO tbl (pk) VALUES (1)")), "ks", "client", timeUuidGenerator)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "broker unavailable")
+
+	var disabled *targetWritePipeline
+	requestInfo := NewGenericRequestInfo(forwardToBoth, false, true)
+	routed, err := disabled.route(
+		requestInfo, NewFrameDecodeContext(mockQueryFrame(t, "INSERT INTO tbl (pk) VALUES (1)")), "ks", "client", timeUuidGenerator)
+	require.Nil(t, err)
+	require.Equal(t, requestInfo, routed)
+}
+
+func TestBuildTargetWriteMessage(t *testing.T) {
+	receivedAt := time.Date(2026, 10, 14, 12, 0, 0, 0, time.UTC)
+	receivedAtMicros := receivedAt.UnixNano() / 1000
+	values := []*primitive.Value{primitive.NewValue([]byte{0, 0, 0, 1})}
+	serialConsistency := &primitive.NillableConsistencyLevel{Value: primitive.ConsistencyLevelLocalSerial}
+
+	type test struct {
+		name       string
+		msg        message.Message
+		statements []string
+		expected   message.Message
+		errMsg     string
+	}
+	tests := []test{
+		{
+			name: "query without options",
+			msg:  &message.Query{Query: "INSERT INTO tbl (pk) VALUES (1)"},
+			expected: &message.Query{Query: "INSERT INTO tbl (pk) VALUES (1)", Options: &message.QueryOptions{
+				DefaultTimestamp: &primitive.NillableInt64{Value: receivedAtMicros}}},
+		},
+		{
+			name: "query with client timestamp and v5 options",
+			msg: &message.Query{Query: "INSERT INTO tbl (pk) VALUES (?)", Options: &message.QueryOptions{
+				Consistency:       primitive.ConsistencyLevelQuorum,
+				PositionalValues:  values,
+				SerialConsistency: serialConsistency,
+				DefaultTimestamp:  &primitive.NillableInt64{Value: 42},
+				Keyspace:          "ks",
+				NowInSeconds:      &primitive.NillableInt32{Value: 1},
+				PageSize:          100,
+			}},
+			expected: &message.Query{Query: "INSERT INTO tbl (pk) VALUES (?)", Options: &message.QueryOptions{
+				Consistency:       primitive.ConsistencyLevelQuorum,
+				PositionalValues:  values,
+				SerialConsistency: serialConsistency,
+				DefaultTimestamp:  &primitive.NillableInt64{Value: 42},
+			}},
+		},
+		{
+			name: "execute",
+			msg: &message.Execute{QueryId: []byte("origin"), Options: &message.QueryOptions{
+				Consistency: primitive.ConsistencyLevelLocalQuorum, PositionalValues: values}},
+			statements: []string{"UPDATE tbl SET v = 1 WHERE pk = ?"},
+			expected: &message.Query{Query: "UPDATE tbl SET v = 1 WHERE pk = ?", Options: &message.QueryOptions{
+				Consistency:      primitive.ConsistencyLevelLocalQuorum,
+				PositionalValues: values,
+				DefaultTimestamp: &primitive.NillableInt64{Value: receivedAtMicros}}},
+		},
+		{
+			name:   "execute of unknown prepared statement",
+			msg:    &message.Execute{QueryId: []byte("origin")},
+			errMsg: "the prepared statement is unknown",
+		},
+		{
+			name: "batch",
+			msg: &message.Batch{
+				Type:        primitive.BatchTypeUnlogged,
+				Consistency: primitive.ConsistencyLevelQuorum,
+				Keyspace:    "ks",
+				Children: []*message.BatchChild{
+					{QueryOrId: "INSERT INTO tbl (pk) VALUES (1)"},
+					{QueryOrId: []byte("origin"), Values: values},
+				},
+			},
+			statements: []string{"INSERT INTO tbl (pk) VALUES (1)", "INSERT INTO tbl (pk) VALUES (?)"},
+			expected: &message.Batch{
+				Type:             primitive.BatchTypeUnlogged,
+				Consistency:      primitive.ConsistencyLevelQuorum,
+				DefaultTimestamp: &primitive.NillableInt64{Value: receivedAtMicros},
+				Children: []*message.BatchChild{
+					{QueryOrId: "INSERT INTO tbl (pk) VALUES (1)"},
+					{QueryOrId: "INSERT INTO tbl (pk) VALUES (?)", Values: values},
+				},
+			},
+		},
+		{
+			name: "batch with unknown prepared statement",
+			msg: &message.Batch{Children: []*message.BatchChild{
+				{QueryOrId: "INSERT INTO tbl (pk) VALUES (1)"}, {QueryOrId: []byte("origin")}}},
+			statements: []string{"INSERT INTO tbl (pk) VALUES (1)", ""},
+			errMsg:     "the prepared statement of child 1 is unknown",
+		},
+		{
+			name:   "unexpected request",
+			msg:    &message.Prepare{Query: "SELECT * FROM tbl"},
+			errMsg: "unexpected OpCode PREPARE [0x09] request",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg, err := buildTargetWriteMessage(tt.msg, &journal.Entry{Timestamp: receivedAt, Statements: tt.statements})
+			if tt.errMsg != "" {
+				require.NotNil(t, err)
+				require.Equal(t, tt.errMsg, err.Error())
+			} else {
+				require.Nil(t, err)
+				require.Equal(t, tt.expected, msg)
+			}
+		})
+	}
+}
+
+func TestCheckTargetWriteResponse(t *testing.T) {
+	require.Nil(t, checkTargetWriteResponse(&message.VoidResult{}, nil))
+
+	var permanentErr *journal.PermanentError
+	err := checkTargetWriteResponse(nil, errors.New("control connection to TARGET is not open"))
+	require.NotNil(t, err)
+	require.False(t, errors.As(err, &permanentErr))
+
+	err = checkTargetWriteResponse(&message.WriteTimeout{ErrorMessage: "timeout", WriteType: primitive.WriteTypeSimple}, nil)
+	require.NotNil(t, err)
+	require.False(t, errors.As(err, &permanentErr))
+
+	err = checkTargetWriteResponse(&message.Invalid{ErrorMessage: "unconfigured table tbl"}, nil)
+	require.NotNil(t, err)
+	require.True(t, errors.As(err, &permanentErr))
+}
@@ -0,0 +1,162 @@
+package zdmproxy
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// tlsConfigReloader periodically checks the certificate and key files of a TLS configuration and rebuilds the
+// configuration when one of them changes, new connections use the new certificates without restarting the proxy.
+// This is required for short-lived certificates that are rotated by cert-manager, Vault, etc.
+//
+// Existing connections are not affected. If the new files can't be loaded (e.g. the certificate was written but not
+// the key yet) then the previous configuration keeps being used and the files are loaded again on the next check.
+type tlsConfigReloader struct {
+	name  string
+	paths []string
+	load  func() (*tls.Config, error)
+
+	current    *atomic.Value
+	fileStates map[string]watchedFileState
+
+	cancelFn context.CancelFunc
+	wg       *sync.WaitGroup
+}
+
+type watchedFileState struct {
+	modTime time.Time
+	size    int64
+}
+
+func newTlsConfigReloader(name string, paths []string, load func() (*tls.Config, error)) (*tlsConfigReloader, error) {
+	var filePaths []string
+	for _, path := range paths {
+		if path != "" {
+			filePaths = append(filePaths, path)
+		}
+	}
+	r := &tlsConfigReloader{
+		name:       name,
+		paths:      filePaths,
+		load:       load,
+		current:    &atomic.Value{},
+		fileStates: readTlsFileStates(filePaths),
+		cancelFn:   func() {},
+		wg:         &sync.WaitGroup{},
+	}
+	tlsConfig, err := load()
+	if err != nil {
+		return nil, err
+	}
+	r.current.Store(tlsConfig)
+	return r, nil
+}
+
+func readTlsFileStates(paths []string) map[string]watchedFileState {
+	fileStates := make(map[string]watchedFileState, len(paths))
+	for _, path := range paths {
+		// os.Stat follows symlinks so the swap of a mounted kubernetes secret is detected
+		fileInfo, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		fileStates[path] = watchedFileState{modTime: fileInfo.ModTime(), size: fileInfo.Size()}
+	}
+	return fileStates
+}
+
+func (r *tlsConfigReloader) Get() *tls.Config {
+	return r.current.Load().(*tls.Config)
+}
+
+func (r *tlsConfigReloader) Start(interval time.Duration) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	r.cancelFn = cancelFn
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.reloadIfChanged()
+			}
+		}
+	}()
+}
+
+func (r *tlsConfigReloader) Close() {
+	r.cancelFn()
+	r.wg.Wait()
+}
+
+// reloadIfChanged returns true if the configuration was rebuilt
+func (r *tlsConfigReloader) reloadIfChanged() bool {
+	fileStates := readTlsFileStates(r.paths)
+	changed := len(fileStates) != len(r.fileStates)
+	for path, state := range fileStates {
+		if previousState, ok := r.fileStates[path]; !ok || previousState != state {
+			changed = true
+		}
+	}
+	if !changed {
+		return false
+	}
+
+	tlsConfig, err := r.load()
+	if err != nil {
+		log.Warnf("TLS files of %v changed but they could not be loaded, the previous certificates will be used "+
+			"until the next successful reload: %v", r.name, err)
+		return false
+	}
+	r.fileStates = fileStates
+	r.current.Store(tlsConfig)
+	log.Infof("Reloaded TLS certificates of %v.", r.name)
+	return true
+}
+
+// serverSideTlsConfig returns a configuration that uses the currently loaded one for each new client connection.
+func (r *tlsConfigReloader) serverSideTlsConfig() *tls.Config {
+	return &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return r.Get(), nil
+		},
+	}
+}
+
+// clientSideTlsConfig returns a configuration that verifies the server and presents a client certificate using the
+// currently loaded configuration. The configurations of non Astra clusters always verify the server with
+// VerifyConnection (hostname verification is not supported) so that's the only verification that is needed here.
+// The protocol options don't come from the reloaded files so they are copied once.
+func (r *tlsConfigReloader) clientSideTlsConfig() *tls.Config {
+	current := r.Get()
+	return &tls.Config{
+		MinVersion:         current.MinVersion,
+		CipherSuites:       current.CipherSuites,
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			current := r.Get()
+			if current.VerifyConnection == nil {
+				return fmt.Errorf("TLS configuration of %v can not verify the server certificate", r.name)
+			}
+			return current.VerifyConnection(cs)
+		},
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			certificates := r.Get().Certificates
+			if len(certificates) == 0 {
+				// one-way TLS, an empty certificate means that no certificate is sent
+				return &tls.Certificate{}, nil
+			}
+			return &certificates[0], nil
+		},
+	}
+}
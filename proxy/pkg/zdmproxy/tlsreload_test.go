@@ -0,0 +1,154 @@
+package zdmproxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTlsConfigReloader_ServerSide(t *testing.T) {
+	dir := t.TempDir()
+	proxyTlsConfig := &common.ProxyTlsConfig{
+		TlsEnabled:    true,
+		ProxyCaPath:   filepath.Join(dir, "ca.pem"),
+		ProxyCertPath: filepath.Join(dir, "cert.pem"),
+		ProxyKeyPath:  filepath.Join(dir, "key.pem"),
+	}
+	firstCert := writeSelfSignedCertificate(t, "first", proxyTlsConfig.ProxyCaPath, proxyTlsConfig.ProxyCertPath,
+		proxyTlsConfig.ProxyKeyPath, time.Now().Add(-time.Hour))
+
+	reloader, err := newTlsConfigReloader("client connections",
+		[]string{proxyTlsConfig.ProxyCaPath, proxyTlsConfig.ProxyCertPath, proxyTlsConfig.ProxyKeyPath},
+		func() (*tls.Config, error) {
+			return getServerSideTlsConfigFromProxyClusterTlsConfig(proxyTlsConfig)
+		})
+	require.Nil(t, err)
+	serverTlsConfig := reloader.serverSideTlsConfig()
+
+	require.False(t, reloader.reloadIfChanged())
+	require.Equal(t, firstCert, tlsHandshakeServerCertificate(t, serverTlsConfig, &tls.Config{InsecureSkipVerify: true}))
+
+	secondCert := writeSelfSignedCertificate(t, "second", proxyTlsConfig.ProxyCaPath, proxyTlsConfig.ProxyCertPath,
+		proxyTlsConfig.ProxyKeyPath, time.Now())
+	require.True(t, reloader.reloadIfChanged())
+	require.Equal(t, secondCert, tlsHandshakeServerCertificate(t, serverTlsConfig, &tls.Config{InsecureSkipVerify: true}))
+
+	// the previous certificates keep being used if the new files are invalid
+	require.Nil(t, os.WriteFile(proxyTlsConfig.ProxyKeyPath, []byte("invalid"), 0600))
+	require.False(t, reloader.reloadIfChanged())
+	require.Equal(t, secondCert, tlsHandshakeServerCertificate(t, serverTlsConfig, &tls.Config{InsecureSkipVerify: true}))
+}
+
+func TestTlsConfigReloader_ClientSide(t *testing.T) {
+	dir := t.TempDir()
+	clusterTlsConfig := &common.ClusterTlsConfig{
+		TlsEnabled:   true,
+		ServerCaPath: filepath.Join(dir, "ca.pem"),
+	}
+	serverCertPath, serverKeyPath := filepath.Join(dir, "server.pem"), filepath.Join(dir, "server-key.pem")
+	writeSelfSignedCertificate(t, "first", clusterTlsConfig.ServerCaPath, serverCertPath, serverKeyPath,
+		time.Now().Add(-time.Hour))
+	firstServerCert, err := tls.LoadX509KeyPair(serverCertPath, serverKeyPath)
+	require.Nil(t, err)
+
+	reloader, err := newTlsConfigReloader(string(common.ClusterTypeOrigin), []string{clusterTlsConfig.ServerCaPath},
+		func() (*tls.Config, error) {
+			return getClientSideTlsConfigFromProxyClusterTlsConfig(clusterTlsConfig, common.ClusterTypeOrigin)
+		})
+	require.Nil(t, err)
+	clientTlsConfig := reloader.clientSideTlsConfig()
+
+	tlsHandshakeServerCertificate(t, &tls.Config{Certificates: []tls.Certificate{firstServerCert}}, clientTlsConfig)
+
+	// the server certificate is signed by a new CA, it is only trusted after the CA file is reloaded
+	writeSelfSignedCertificate(t, "second", clusterTlsConfig.ServerCaPath, serverCertPath, serverKeyPath, time.Now())
+	secondServerCert, err := tls.LoadX509KeyPair(serverCertPath, serverKeyPath)
+	require.Nil(t, err)
+	require.True(t, reloader.reloadIfChanged())
+
+	tlsHandshakeServerCertificate(t, &tls.Config{Certificates: []tls.Certificate{secondServerCert}}, clientTlsConfig)
+	_, err = tlsHandshake(&tls.Config{Certificates: []tls.Certificate{firstServerCert}}, clientTlsConfig)
+	require.NotNil(t, err)
+}
+
+// writeSelfSignedCertificate writes a self-signed certificate (used as its own CA) and its key, the modification time
+// of the files is set to modTime so that consecutive writes are detected even if the file system has a low timestamp
+// resolution.
+func writeSelfSignedCertificate(t *testing.T, commonName string, caPath string, certPath string, keyPath string, modTime time.Time) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.Nil(t, err)
+	keyBytes, err := x509.MarshalECPrivateKey(key)
+	require.Nil(t, err)
+
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBytes})
+	for path, content := range map[string][]byte{caPath: certPem, certPath: certPem, keyPath: keyPem} {
+		require.Nil(t, os.WriteFile(path, content, 0600))
+		require.Nil(t, os.Chtimes(path, modTime, modTime))
+	}
+	return cert
+}
+
+// tlsHandshakeServerCertificate returns the certificate that the server presented to the client
+func tlsHandshakeServerCertificate(t *testing.T, serverTlsConfig *tls.Config, clientTlsConfig *tls.Config) []byte {
+	serverCert, err := tlsHandshake(serverTlsConfig, clientTlsConfig)
+	require.Nil(t, err)
+	return serverCert
+}
+
+func tlsHandshake(serverTlsConfig *tls.Config, clientTlsConfig *tls.Config) ([]byte, error) {
+	// a TCP connection instead of net.Pipe because both sides can write at the same time during a failed handshake
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	defer listener.Close()
+
+	go func() {
+		serverConn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer serverConn.Close()
+		// the client handshake fails as well if the server handshake fails
+		_ = tls.Server(serverConn, serverTlsConfig).Handshake()
+	}()
+
+	clientConn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		return nil, err
+	}
+	defer clientConn.Close()
+
+	clientTlsConn := tls.Client(clientConn, clientTlsConfig)
+	err = clientTlsConn.Handshake()
+	if err != nil {
+		return nil, err
+	}
+	return clientTlsConn.ConnectionState().PeerCertificates[0].Raw, nil
+}
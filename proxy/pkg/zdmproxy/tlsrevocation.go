@@ -0,0 +1,129 @@
+package zdmproxy
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+// certificateRevocationLists contains the revoked certificates of the CRLs that were loaded from the CRL file of a
+// cluster. The CRLs are only trusted if they are signed by one of the CAs of the cluster.
+type certificateRevocationLists struct {
+	lists []*x509.RevocationList
+}
+
+// loadCertificateRevocationLists parses the PEM (one or more "X509 CRL" blocks) or DER encoded CRL file and checks
+// the signature of each CRL with the CA certificates of the cluster.
+func loadCertificateRevocationLists(crlFile []byte, caCertFile []byte) (*certificateRevocationLists, error) {
+	var derLists [][]byte
+	rest := crlFile
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type == "X509 CRL" {
+			derLists = append(derLists, block.Bytes)
+		}
+	}
+	if len(derLists) == 0 {
+		// not PEM, try DER
+		derLists = append(derLists, crlFile)
+	}
+
+	caCerts, err := parseCertificatesFromPem(caCertFile)
+	if err != nil {
+		return nil, err
+	}
+
+	crls := &certificateRevocationLists{}
+	for _, derList := range derLists {
+		crl, err := x509.ParseRevocationList(derList)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse certificate revocation list: %w", err)
+		}
+		if err = checkRevocationListSignature(crl, caCerts); err != nil {
+			return nil, err
+		}
+		if !crl.NextUpdate.IsZero() && crl.NextUpdate.Before(time.Now()) {
+			log.Warnf("The certificate revocation list of %v should have been updated on %v, "+
+				"it will be used until it is replaced.", crl.Issuer, crl.NextUpdate)
+		}
+		crls.lists = append(crls.lists, crl)
+	}
+	return crls, nil
+}
+
+func parseCertificatesFromPem(pemFile []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := pemFile
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return certs, nil
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse CA certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+}
+
+func checkRevocationListSignature(crl *x509.RevocationList, caCerts []*x509.Certificate) error {
+	for _, caCert := range caCerts {
+		if !bytes.Equal(caCert.RawSubject, crl.RawIssuer) {
+			continue
+		}
+		if err := crl.CheckSignatureFrom(caCert); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("the certificate revocation list of %v is not signed by any of the provided CA certificates",
+		crl.Issuer)
+}
+
+// isRevoked returns an error if the certificate is in the CRL of its issuer
+func (crls *certificateRevocationLists) isRevoked(cert *x509.Certificate) error {
+	for _, crl := range crls.lists {
+		if !bytes.Equal(crl.RawIssuer, cert.RawIssuer) {
+			continue
+		}
+		for _, revokedCert := range crl.RevokedCertificates {
+			if revokedCert.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+				return fmt.Errorf("certificate %v with serial number %v was revoked on %v",
+					cert.Subject, cert.SerialNumber, revokedCert.RevocationTime)
+			}
+		}
+	}
+	return nil
+}
+
+// withRevocationCheck wraps the VerifyConnection callback of the configuration so that the certificate chain of the
+// server is accepted only if none of its certificates were revoked.
+func (crls *certificateRevocationLists) withRevocationCheck(tlsConfig *tls.Config) *tls.Config {
+	verifyConnection := tlsConfig.VerifyConnection
+	tlsConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+		if verifyConnection != nil {
+			if err := verifyConnection(cs); err != nil {
+				return err
+			}
+		}
+		for _, cert := range cs.PeerCertificates {
+			if err := crls.isRevoked(cert); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return tlsConfig
+}
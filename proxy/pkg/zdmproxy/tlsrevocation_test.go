@@ -0,0 +1,121 @@
+package zdmproxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCertificateRevocationLists(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := newTestCertificateAuthority(t, "ca")
+	caPath := filepath.Join(dir, "ca.pem")
+	require.Nil(t, os.WriteFile(caPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}), 0600))
+
+	validServerCert := newTestServerCertificate(t, ca, caKey, 10)
+	revokedServerCert := newTestServerCertificate(t, ca, caKey, 11)
+
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-time.Hour),
+		NextUpdate: time.Now().Add(time.Hour),
+		RevokedCertificates: []pkix.RevokedCertificate{
+			{SerialNumber: big.NewInt(11), RevocationTime: time.Now().Add(-time.Minute)},
+		},
+	}, ca, caKey)
+	require.Nil(t, err)
+
+	otherCa, otherCaKey := newTestCertificateAuthority(t, "other ca")
+	otherCrl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now().Add(-time.Hour),
+		NextUpdate: time.Now().Add(time.Hour),
+	}, otherCa, otherCaKey)
+	require.Nil(t, err)
+
+	tests := []struct {
+		name         string
+		crlFile      []byte
+		serverCert   tls.Certificate
+		loadError    bool
+		revokedError bool
+	}{
+		{"pem not revoked", pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crl}), validServerCert, false, false},
+		{"pem revoked", pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: crl}), revokedServerCert, false, true},
+		{"der revoked", crl, revokedServerCert, false, true},
+		{"crl of another ca", otherCrl, validServerCert, true, false},
+		{"invalid crl", []byte("invalid"), validServerCert, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crlPath := filepath.Join(dir, "crl")
+			require.Nil(t, os.WriteFile(crlPath, tt.crlFile, 0600))
+
+			clientTlsConfig, err := getClientSideTlsConfigFromProxyClusterTlsConfig(&common.ClusterTlsConfig{
+				TlsEnabled:   true,
+				ServerCaPath: caPath,
+				CrlPath:      crlPath,
+			}, common.ClusterTypeTarget)
+			if tt.loadError {
+				require.NotNil(t, err)
+				return
+			}
+			require.Nil(t, err)
+
+			_, err = tlsHandshake(&tls.Config{Certificates: []tls.Certificate{tt.serverCert}}, clientTlsConfig)
+			if tt.revokedError {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), "was revoked")
+			} else {
+				require.Nil(t, err)
+			}
+		})
+	}
+}
+
+func newTestCertificateAuthority(t *testing.T, commonName string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.Nil(t, err)
+	return cert, key
+}
+
+func newTestServerCertificate(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, serialNumber int64) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serialNumber),
+		Subject:      pkix.Name{CommonName: "server"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	require.Nil(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
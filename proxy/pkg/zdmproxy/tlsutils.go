@@ -37,7 +37,29 @@ func getClientSideTlsConfigFromProxyClusterTlsConfig(clusterTlsConfig *common.Cl
 		return nil, err
 	}
 	// currently not supporting server hostname verification for non-Astra clusters
-	return getClientSideTlsConfig(serverCAFile, clientCertFile, clientKeyFile, "", "", clusterType)
+	tlsConfig, err := getClientSideTlsConfig(serverCAFile, clientCertFile, clientKeyFile, "", "", clusterType)
+	if err != nil {
+		return nil, err
+	}
+	if clusterTlsConfig.CrlPath != "" {
+		crlFile, err := loadTlsFile(clusterTlsConfig.CrlPath)
+		if err != nil {
+			return nil, err
+		}
+		crls, err := loadCertificateRevocationLists(crlFile, serverCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not load certificate revocation lists of %s: %w", clusterType, err)
+		}
+		tlsConfig = crls.withRevocationCheck(tlsConfig)
+	}
+	return applyTlsProtocolOptions(tlsConfig, clusterTlsConfig.MinVersion, clusterTlsConfig.CipherSuites), nil
+}
+
+// applyTlsProtocolOptions sets the minimum TLS version and the allowed cipher suites, zero values keep the defaults.
+func applyTlsProtocolOptions(tlsConfig *tls.Config, minVersion uint16, cipherSuites []uint16) *tls.Config {
+	tlsConfig.MinVersion = minVersion
+	tlsConfig.CipherSuites = cipherSuites
+	return tlsConfig
 }
 
 func getClientSideTlsConfig(
@@ -139,7 +161,11 @@ func getServerSideTlsConfigFromProxyClusterTlsConfig(proxyTlsConfig *common.Prox
 		return nil, err
 	}
 	// currently not supporting server hostname verification for client connections
-	return getServerSideTlsConfig(proxyCaFile, proxyCertFile, proxyCertKey, proxyTlsConfig.ClientAuth)
+	tlsConfig, err := getServerSideTlsConfig(proxyCaFile, proxyCertFile, proxyCertKey, proxyTlsConfig.ClientAuth)
+	if err != nil {
+		return nil, err
+	}
+	return applyTlsProtocolOptions(tlsConfig, proxyTlsConfig.MinVersion, proxyTlsConfig.CipherSuites), nil
 }
 
 func getServerSideTlsConfig(
@@ -0,0 +1,59 @@
+package zdmproxy
+
+import (
+	"crypto/tls"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTlsProtocolOptions(t *testing.T) {
+	dir := t.TempDir()
+	proxyTlsConfig := &common.ProxyTlsConfig{
+		TlsEnabled:    true,
+		ProxyCaPath:   filepath.Join(dir, "ca.pem"),
+		ProxyCertPath: filepath.Join(dir, "cert.pem"),
+		ProxyKeyPath:  filepath.Join(dir, "key.pem"),
+		MinVersion:    tls.VersionTLS12,
+		CipherSuites:  []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+	}
+	writeSelfSignedCertificate(t, "proxy", proxyTlsConfig.ProxyCaPath, proxyTlsConfig.ProxyCertPath,
+		proxyTlsConfig.ProxyKeyPath, time.Now())
+	clusterTlsConfig := &common.ClusterTlsConfig{
+		TlsEnabled:   true,
+		ServerCaPath: proxyTlsConfig.ProxyCaPath,
+		MinVersion:   tls.VersionTLS12,
+		CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+	}
+
+	serverTlsConfig, err := getServerSideTlsConfigFromProxyClusterTlsConfig(proxyTlsConfig)
+	require.Nil(t, err)
+	require.Equal(t, uint16(tls.VersionTLS12), serverTlsConfig.MinVersion)
+	clientTlsConfig, err := getClientSideTlsConfigFromProxyClusterTlsConfig(clusterTlsConfig, common.ClusterTypeOrigin)
+	require.Nil(t, err)
+	require.Equal(t, uint16(tls.VersionTLS12), clientTlsConfig.MinVersion)
+
+	tests := []struct {
+		name          string
+		clientConfig  *tls.Config
+		errorExpected bool
+	}{
+		{"allowed version and cipher suite", clientTlsConfig, false},
+		{"older version", &tls.Config{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS11}, true},
+		{"other cipher suite", &tls.Config{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS12,
+			CipherSuites: []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tlsHandshake(serverTlsConfig, tt.clientConfig)
+			if tt.errorExpected {
+				require.NotNil(t, err)
+			} else {
+				require.Nil(t, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package zdmproxy
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	log "github.com/sirupsen/logrus"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// tokenRangeRouter sends the reads of the tables that are being migrated range by range to the cluster that has the
+// data of their partition, according to ZDM_TOKEN_RANGE_ROUTING_FILE. The file is a JSON object with the token ranges
+// of each table that were already migrated to Target:
+//
+//	{"tables": [{"keyspace": "ks", "table": "tb", "migrated_ranges": [
+//	  {"start": -9223372036854775808, "end": -3074457345618258603}]}]}
+//
+// Like Cassandra token ranges, a range goes from start (exclusive) to end (inclusive) and wraps around the ring if
+// start is not lower than end. Keyspace and table names are the ones of the schema (lower case unless they were
+// quoted). Tokens are computed with the Murmur3Partitioner.
+//
+// Only bound statements (EXECUTE) are routed because the proxy needs the partition key indices that the cluster
+// returns when the statement is prepared, the other reads are sent to the primary cluster. A routed read that goes to
+// the secondary cluster is not also sent to the async connector. The file is read again when it changes so that the
+// migration can update it as ranges complete.
+type tokenRangeRouter struct {
+	path      string
+	tables    *atomic.Value // map[string][]*tokenRange, the key is keyspace.table
+	fileState watchedFileState
+
+	cancelFn context.CancelFunc
+	wg       *sync.WaitGroup
+}
+
+type tokenRangeRoutingFile struct {
+	Tables []*struct {
+		Keyspace       string        `json:"keyspace"`
+		Table          string        `json:"table"`
+		MigratedRanges []*tokenRange `json:"migrated_ranges"`
+	} `json:"tables"`
+}
+
+type tokenRange struct {
+	Start int64 `json:"start"`
+	End   int64 `json:"end"`
+}
+
+func (r *tokenRange) contains(token int64) bool {
+	if r.Start < r.End {
+		return token > r.Start && token <= r.End
+	}
+	return token > r.Start || token <= r.End
+}
+
+func newTokenRangeRouter(path string) (*tokenRangeRouter, error) {
+	r := &tokenRangeRouter{
+		path:     path,
+		tables:   &atomic.Value{},
+		cancelFn: func() {},
+		wg:       &sync.WaitGroup{},
+	}
+	r.fileState = r.readFileState()
+	tables, err := r.load()
+	if err != nil {
+		return nil, err
+	}
+	r.tables.Store(tables)
+	return r, nil
+}
+
+func (r *tokenRangeRouter) readFileState() watchedFileState {
+	// os.Stat follows symlinks so the swap of a mounted kubernetes config map is detected
+	fileInfo, err := os.Stat(r.path)
+	if err != nil {
+		return watchedFileState{}
+	}
+	return watchedFileState{modTime: fileInfo.ModTime(), size: fileInfo.Size()}
+}
+
+func (r *tokenRangeRouter) load() (map[string][]*tokenRange, error) {
+	file, err := os.ReadFile(r.path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read token range routing file: %w", err)
+	}
+	routingFile := &tokenRangeRoutingFile{}
+	err = json.Unmarshal(file, routingFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse token range routing file: %w", err)
+	}
+	tables := make(map[string][]*tokenRange, len(routingFile.Tables))
+	for _, table := range routingFile.Tables {
+		if table == nil || table.Keyspace == "" || table.Table == "" {
+			return nil, fmt.Errorf("every table of the token range routing file must have a keyspace and a table")
+		}
+		name := table.Keyspace + "." + table.Table
+		if _, ok := tables[name]; ok {
+			return nil, fmt.Errorf("table %v is defined more than once in the token range routing file", name)
+		}
+		for _, migratedRange := range table.MigratedRanges {
+			if migratedRange == nil {
+				return nil, fmt.Errorf("invalid migrated range of table %v in the token range routing file", name)
+			}
+		}
+		tables[name] = table.MigratedRanges
+	}
+	return tables, nil
+}
+
+func (r *tokenRangeRouter) Start(interval time.Duration) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	r.cancelFn = cancelFn
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				r.reloadIfChanged()
+			}
+		}
+	}()
+}
+
+func (r *tokenRangeRouter) Close() {
+	r.cancelFn()
+	r.wg.Wait()
+}
+
+// reloadIfChanged returns true if the migrated ranges were read again
+func (r *tokenRangeRouter) reloadIfChanged() bool {
+	fileState := r.readFileState()
+	if fileState == r.fileState {
+		return false
+	}
+	tables, err := r.load()
+	if err != nil {
+		log.Warnf("Token range routing file changed but it could not be loaded, the previous ranges will be used "+
+			"until the next successful reload: %v", err)
+		return false
+	}
+	r.fileState = fileState
+	r.tables.Store(tables)
+	log.Infof("Reloaded token range routing file %v (%d tables).", r.path, len(tables))
+	return true
+}
+
+// route returns the request info of a read of a migrated table with the cluster that has the data of its partition,
+// other requests are returned as is.
+func (r *tokenRangeRouter) route(requestInfo RequestInfo, frameContext *frameDecodeContext) RequestInfo {
+	if r == nil {
+		return requestInfo
+	}
+	executeRequestInfo, ok := requestInfo.(*ExecuteRequestInfo)
+	if !ok {
+		return requestInfo
+	}
+	decision := executeRequestInfo.GetForwardDecision()
+	if decision != forwardToOrigin && decision != forwardToTarget {
+		// writes are sent to both clusters
+		return requestInfo
+	}
+	variablesMetadata := executeRequestInfo.GetPreparedData().GetOriginVariablesMetadata()
+	if variablesMetadata == nil || len(variablesMetadata.PkIndices) == 0 {
+		return requestInfo
+	}
+	if int(variablesMetadata.PkIndices[0]) >= len(variablesMetadata.Columns) {
+		return requestInfo
+	}
+	column := variablesMetadata.Columns[variablesMetadata.PkIndices[0]]
+	migratedRanges, ok := r.tables.Load().(map[string][]*tokenRange)[column.Keyspace+"."+column.Table]
+	if !ok {
+		return requestInfo
+	}
+
+	decodedFrame, err := frameContext.GetOrDecodeFrame()
+	if err != nil {
+		log.Debugf("Could not decode EXECUTE to compute its token, it is sent to %v: %v", decision, err)
+		return requestInfo
+	}
+	executeMsg, ok := decodedFrame.Body.Message.(*message.Execute)
+	if !ok {
+		return requestInfo
+	}
+	partitionKey, ok := serializePartitionKey(variablesMetadata, executeMsg.Options)
+	if !ok {
+		return requestInfo
+	}
+
+	token := murmur3Token(partitionKey)
+	routedDecision := forwardToOrigin
+	for _, migratedRange := range migratedRanges {
+		if migratedRange.contains(token) {
+			routedDecision = forwardToTarget
+			break
+		}
+	}
+	if routedDecision == decision {
+		return requestInfo
+	}
+	log.Tracef("Read of %v.%v with token %d is sent to %v instead of %v.",
+		column.Keyspace, column.Table, token, routedDecision, decision)
+	return NewRoutedExecuteRequestInfo(executeRequestInfo.GetPreparedData(), routedDecision)
+}
+
+// serializePartitionKey returns the partition key of a bound statement as the partitioner reads it, i.e. the value of
+// the single partition key column or the composite key of the columns. It returns false if a value is missing.
+func serializePartitionKey(variablesMetadata *message.VariablesMetadata, options *message.QueryOptions) ([]byte, bool) {
+	if options == nil {
+		return nil, false
+	}
+	components := make([][]byte, 0, len(variablesMetadata.PkIndices))
+	for _, pkIndex := range variablesMetadata.PkIndices {
+		var value *primitive.Value
+		if options.NamedValues != nil {
+			if int(pkIndex) < len(variablesMetadata.Columns) {
+				value = options.NamedValues[variablesMetadata.Columns[pkIndex].Name]
+			}
+		} else if int(pkIndex) < len(options.PositionalValues) {
+			value = options.PositionalValues[pkIndex]
+		}
+		if value == nil || value.Type != primitive.ValueTypeRegular {
+			return nil, false
+		}
+		components = append(components, value.Contents)
+	}
+	if len(components) == 1 {
+		return components[0], true
+	}
+
+	var compositeKey []byte
+	for _, component := range components {
+		compositeKey = binary.BigEndian.AppendUint16(compositeKey, uint16(len(component)))
+		compositeKey = append(compositeKey, component...)
+		compositeKey = append(compositeKey, 0)
+	}
+	return compositeKey, true
+}
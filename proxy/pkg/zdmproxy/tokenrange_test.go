@@ -0,0 +1,157 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/datatype"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTokenRange_Contains(t *testing.T) {
+	tests := []struct {
+		name     string
+		r        *tokenRange
+		token    int64
+		expected bool
+	}{
+		{"start is excluded", &tokenRange{Start: 0, End: 100}, 0, false},
+		{"end is included", &tokenRange{Start: 0, End: 100}, 100, true},
+		{"inside", &tokenRange{Start: 0, End: 100}, 50, true},
+		{"after end", &tokenRange{Start: 0, End: 100}, 101, false},
+		{"wrapping, before end", &tokenRange{Start: 100, End: -100}, math.MinInt64, true},
+		{"wrapping, after start", &tokenRange{Start: 100, End: -100}, math.MaxInt64, true},
+		{"wrapping, outside", &tokenRange{Start: 100, End: -100}, 0, false},
+		{"whole ring", &tokenRange{Start: math.MinInt64, End: math.MinInt64}, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected, tt.r.contains(tt.token))
+		})
+	}
+}
+
+func TestTokenRangeRouter_Load(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   string
+		errMsg string
+	}{
+		{"valid", `{"tables": [{"keyspace": "ks", "table": "tb", "migrated_ranges": [{"start": 1, "end": 2}]}]}`, ""},
+		{"invalid json", `{"tables": [`, "could not parse token range routing file"},
+		{"missing table", `{"tables": [{"keyspace": "ks"}]}`, "every table of the token range routing file must have a keyspace and a table"},
+		{"duplicate table", `{"tables": [{"keyspace": "ks", "table": "tb"}, {"keyspace": "ks", "table": "tb"}]}`,
+			"table ks.tb is defined more than once in the token range routing file"},
+		{"null range", `{"tables": [{"keyspace": "ks", "table": "tb", "migrated_ranges": [null]}]}`,
+			"invalid migrated range of table ks.tb in the token range routing file"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "ranges.json")
+			require.Nil(t, os.WriteFile(path, []byte(tt.file), 0644))
+			_, err := newTokenRangeRouter(path)
+			if tt.errMsg == "" {
+				require.Nil(t, err)
+			} else {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestTokenRangeRouter_Route(t *testing.T) {
+	migratedKey := []byte{0, 0, 0, 1}
+	otherKey := []byte{0, 0, 0, 2}
+	migratedToken := murmur3Token(migratedKey)
+	path := filepath.Join(t.TempDir(), "ranges.json")
+	writeRanges := func(ranges string) {
+		require.Nil(t, os.WriteFile(path, []byte(fmt.Sprintf(
+			`{"tables": [{"keyspace": "ks", "table": "tb", "migrated_ranges": [%v]}]}`, ranges)), 0644))
+	}
+	writeRanges(fmt.Sprintf(`{"start": %d, "end": %d}`, migratedToken-1, migratedToken))
+	router, err := newTokenRangeRouter(path)
+	require.Nil(t, err)
+
+	newPreparedData := func(table string, decision forwardDecision) PreparedData {
+		variablesMetadata := &message.VariablesMetadata{
+			PkIndices: []uint16{0},
+			Columns: []*message.ColumnMetadata{
+				{Keyspace: "ks", Table: table, Name: "pk", Index: 0, Type: datatype.Int},
+			},
+		}
+		return NewPreparedData(
+			&message.PreparedResult{PreparedQueryId: []byte("origin"), VariablesMetadata: variablesMetadata},
+			&message.PreparedResult{PreparedQueryId: []byte("target"), VariablesMetadata: variablesMetadata},
+			NewPrepareRequestInfo(NewGenericRequestInfo(decision, false, true), nil, false, "", ""))
+	}
+	route := func(preparedData PreparedData, key []byte) forwardDecision {
+		executeMsg := &message.Execute{
+			QueryId: []byte("origin"),
+			Options: &message.QueryOptions{PositionalValues: []*primitive.Value{primitive.NewValue(key)}},
+		}
+		frameContext := NewFrameDecodeContext(mockFrame(t, executeMsg, primitive.ProtocolVersion4))
+		return router.route(NewExecuteRequestInfo(preparedData), frameContext).GetForwardDecision()
+	}
+
+	require.Equal(t, forwardToTarget, route(newPreparedData("tb", forwardToOrigin), migratedKey))
+	require.Equal(t, forwardToOrigin, route(newPreparedData("tb", forwardToOrigin), otherKey))
+	require.Equal(t, forwardToOrigin, route(newPreparedData("tb", forwardToTarget), otherKey))
+	require.Equal(t, forwardToOrigin, route(newPreparedData("other", forwardToOrigin), migratedKey))
+	require.Equal(t, forwardToBoth, route(newPreparedData("tb", forwardToBoth), migratedKey))
+
+	routed := NewExecuteRequestInfo(newPreparedData("tb", forwardToOrigin))
+	executeMsg := &message.Execute{
+		QueryId: []byte("origin"),
+		Options: &message.QueryOptions{PositionalValues: []*primitive.Value{primitive.NewValue(migratedKey)}},
+	}
+	routedRequestInfo := router.route(routed, NewFrameDecodeContext(mockFrame(t, executeMsg, primitive.ProtocolVersion4)))
+	require.False(t, routedRequestInfo.ShouldAlsoBeSentAsync())
+
+	// the migration moved on to the next range
+	time.Sleep(10 * time.Millisecond)
+	writeRanges(`{"start": 0, "end": 1}`)
+	require.True(t, router.reloadIfChanged())
+	require.False(t, router.reloadIfChanged())
+	require.Equal(t, forwardToOrigin, route(newPreparedData("tb", forwardToOrigin), migratedKey))
+
+	var disabled *tokenRangeRouter
+	requestInfo := NewExecuteRequestInfo(newPreparedData("tb", forwardToOrigin))
+	require.Equal(t, requestInfo, disabled.route(requestInfo, nil))
+}
+
+func TestSerializePartitionKey(t *testing.T) {
+	variablesMetadata := &message.VariablesMetadata{
+		PkIndices: []uint16{1, 0},
+		Columns: []*message.ColumnMetadata{
+			{Name: "b", Index: 0, Type: datatype.Int},
+			{Name: "a", Index: 1, Type: datatype.Varchar},
+		},
+	}
+	expected := []byte{0, 2, 'h', 'i', 0, 0, 4, 0, 0, 0, 7, 0}
+
+	key, ok := serializePartitionKey(variablesMetadata, &message.QueryOptions{
+		PositionalValues: []*primitive.Value{primitive.NewValue([]byte{0, 0, 0, 7}), primitive.NewValue([]byte("hi"))},
+	})
+	require.True(t, ok)
+	require.Equal(t, expected, key)
+
+	key, ok = serializePartitionKey(variablesMetadata, &message.QueryOptions{
+		NamedValues: map[string]*primitive.Value{
+			"a": primitive.NewValue([]byte("hi")),
+			"b": primitive.NewValue([]byte{0, 0, 0, 7}),
+		},
+	})
+	require.True(t, ok)
+	require.Equal(t, expected, key)
+
+	_, ok = serializePartitionKey(variablesMetadata, &message.QueryOptions{
+		PositionalValues: []*primitive.Value{primitive.NewValue([]byte{0, 0, 0, 7}), primitive.NewNullValue()},
+	})
+	require.False(t, ok)
+}
@@ -0,0 +1,89 @@
+package zdmproxy
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	log "github.com/sirupsen/logrus"
+	"regexp"
+	"strings"
+)
+
+const traceParentPayloadKey = "traceparent"
+
+var traceParentRegex = regexp.MustCompile("^([0-9a-f]{2})-([0-9a-f]{32})-([0-9a-f]{16})-([0-9a-f]{2})$")
+
+// traceContextPropagator is the RequestInterceptor of ZDM_TRACE_CONTEXT_PROPAGATION. It adds a W3C traceparent entry to
+// the custom payload of the QUERY, PREPARE, EXECUTE and BATCH requests that are forwarded to the clusters.
+//
+// If the client sent a valid traceparent then its trace id and flags are kept and the parent id is replaced with a new
+// span id for the proxy, otherwise a new sampled trace is started. The other custom payload entries (e.g. tracestate)
+// are forwarded untouched. Custom payloads require protocol v4 or higher so older requests are not modified.
+type traceContextPropagator struct{}
+
+func (p *traceContextPropagator) OnRequest(
+	conn *InterceptedConnection, request *frame.RawFrame) (*frame.RawFrame, *frame.RawFrame, error) {
+	if request.Header.Version < primitive.ProtocolVersion4 {
+		return nil, nil, nil
+	}
+	switch request.Header.OpCode {
+	case primitive.OpCodeQuery, primitive.OpCodePrepare, primitive.OpCodeExecute, primitive.OpCodeBatch:
+	default:
+		return nil, nil, nil
+	}
+
+	decodedRequest, err := defaultCodec.ConvertFromRawFrame(request)
+	if err != nil {
+		log.Warnf("Could not decode %v request to propagate the trace context, forwarding it unchanged: %v",
+			request.Header.OpCode, err)
+		return nil, nil, nil
+	}
+
+	clientTraceParent := string(decodedRequest.Body.CustomPayload[traceParentPayloadKey])
+	traceParent := newProxyTraceParent(clientTraceParent)
+	customPayload := make(map[string][]byte, len(decodedRequest.Body.CustomPayload)+1)
+	for key, value := range decodedRequest.Body.CustomPayload {
+		customPayload[key] = value
+	}
+	customPayload[traceParentPayloadKey] = []byte(traceParent)
+	// the decoded frame shares the header of the raw request which must not be modified
+	decodedRequest.Header = decodedRequest.Header.Clone()
+	decodedRequest.SetCustomPayload(customPayload)
+
+	newRequest, err := defaultCodec.ConvertToRawFrame(decodedRequest)
+	if err != nil {
+		log.Warnf("Could not encode %v request with the trace context, forwarding it unchanged: %v",
+			request.Header.OpCode, err)
+		return nil, nil, nil
+	}
+	log.Debugf("Forwarding %v request (stream id %d) from %v with traceparent %v (client traceparent: %v).",
+		request.Header.OpCode, request.Header.StreamId, conn.ClientAddress, traceParent, clientTraceParent)
+	return newRequest, nil, nil
+}
+
+func (p *traceContextPropagator) OnResponse(
+	_ *InterceptedConnection, _ *frame.RawFrame, _ *frame.RawFrame) (*frame.RawFrame, error) {
+	return nil, nil
+}
+
+// newProxyTraceParent returns the traceparent of the proxy span, a child of the client traceparent if it is valid.
+func newProxyTraceParent(clientTraceParent string) string {
+	traceId, flags := "", "01"
+	if match := traceParentRegex.FindStringSubmatch(strings.TrimSpace(clientTraceParent)); match != nil &&
+		match[1] != "ff" && strings.Trim(match[2], "0") != "" && strings.Trim(match[3], "0") != "" {
+		traceId, flags = match[2], match[4]
+	} else {
+		if clientTraceParent != "" {
+			log.Debugf("Ignoring invalid traceparent %v, a new trace is started.", clientTraceParent)
+		}
+		traceId = randomHex(16)
+	}
+	return "00-" + traceId + "-" + randomHex(8) + "-" + flags
+}
+
+func randomHex(numBytes int) string {
+	buf := make([]byte, numBytes)
+	_, _ = rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
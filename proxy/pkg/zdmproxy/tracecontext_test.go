@@ -0,0 +1,76 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTraceContextPropagator(t *testing.T) {
+	clientTraceParent := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"
+
+	tests := []struct {
+		name             string
+		version          primitive.ProtocolVersion
+		msg              message.Message
+		customPayload    map[string][]byte
+		modified         bool
+		expectedTraceId  string
+		expectedFlags    string
+		expectedTraceKey []string
+	}{
+		{"client traceparent", primitive.ProtocolVersion4, &message.Query{Query: "SELECT * FROM ks.tb"},
+			map[string][]byte{"traceparent": []byte(clientTraceParent), "tracestate": []byte("vendor=value")},
+			true, "4bf92f3577b34da6a3ce929d0e0e4736", "00", []string{"traceparent", "tracestate"}},
+		{"generated traceparent", primitive.ProtocolVersion4, &message.Execute{QueryId: []byte{1, 2}},
+			nil, true, "", "01", []string{"traceparent"}},
+		{"invalid client traceparent", primitive.ProtocolVersion5, &message.Prepare{Query: "SELECT * FROM ks.tb"},
+			map[string][]byte{"traceparent": []byte("00-00000000000000000000000000000000-00f067aa0ba902b7-01")},
+			true, "", "01", []string{"traceparent"}},
+		{"not a statement", primitive.ProtocolVersion4, &message.Options{}, nil, false, "", "", nil},
+		{"protocol v3", primitive.ProtocolVersion3, &message.Query{Query: "SELECT * FROM ks.tb"}, nil, false, "", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requestFrame := frame.NewFrame(tt.version, 3, tt.msg)
+			if tt.customPayload != nil {
+				requestFrame.SetCustomPayload(tt.customPayload)
+			}
+			request, err := defaultCodec.ConvertToRawFrame(requestFrame)
+			require.Nil(t, err)
+
+			newRequest, response, err := (&traceContextPropagator{}).OnRequest(&InterceptedConnection{}, request)
+			require.Nil(t, err)
+			require.Nil(t, response)
+			if !tt.modified {
+				require.Nil(t, newRequest)
+				return
+			}
+			require.NotNil(t, newRequest)
+
+			decodedRequest, err := defaultCodec.ConvertFromRawFrame(newRequest)
+			require.Nil(t, err)
+			originalRequest, err := defaultCodec.ConvertFromRawFrame(request)
+			require.Nil(t, err)
+			require.Equal(t, originalRequest.Body.Message, decodedRequest.Body.Message)
+			var keys []string
+			for key := range decodedRequest.Body.CustomPayload {
+				keys = append(keys, key)
+			}
+			require.ElementsMatch(t, tt.expectedTraceKey, keys)
+
+			traceParent := string(decodedRequest.Body.CustomPayload["traceparent"])
+			match := traceParentRegex.FindStringSubmatch(traceParent)
+			require.NotNil(t, match, traceParent)
+			require.Equal(t, "00", match[1])
+			if tt.expectedTraceId != "" {
+				require.Equal(t, tt.expectedTraceId, match[2])
+			}
+			require.NotEqual(t, "00f067aa0ba902b7", match[3])
+			require.Equal(t, tt.expectedFlags, match[4])
+		})
+	}
+}
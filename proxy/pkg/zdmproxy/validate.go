@@ -0,0 +1,200 @@
+package zdmproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics/noopmetrics"
+	"net"
+	"strings"
+)
+
+// ValidationCheck is the result of one of the checks of ValidateDeployment, Err is nil if the check passed.
+type ValidationCheck struct {
+	Name   string
+	Detail string
+	Err    error
+}
+
+// ValidateDeployment checks the parts of the configuration that can only be verified in the environment of the proxy
+// without serving any traffic: it loads the TLS files of the proxy and of both clusters and resolves the contact
+// points. If probe is true it also opens a control connection to both clusters which verifies connectivity, TLS,
+// authentication and the local datacenter.
+//
+// The configuration must be valid, i.e. it must have been returned by Config.ParseEnvVars or Config.Validate must have
+// been called.
+func ValidateDeployment(ctx context.Context, conf *config.Config, probe bool) []*ValidationCheck {
+	checks := []*ValidationCheck{validateProxyTls(conf)}
+	for _, clusterType := range []common.ClusterType{common.ClusterTypeOrigin, common.ClusterTypeTarget} {
+		tlsCheck, clusterTlsConfig := validateClusterTls(conf, clusterType)
+		checks = append(checks, tlsCheck)
+		contactPointsCheck, contactPoints := validateContactPoints(ctx, conf, clusterType, clusterTlsConfig)
+		checks = append(checks, contactPointsCheck)
+		if !probe {
+			continue
+		}
+		probeCheck := &ValidationCheck{Name: fmt.Sprintf("%v connection", clusterType)}
+		if tlsCheck.Err != nil || contactPointsCheck.Err != nil {
+			probeCheck.Err = errors.New("skipped because of the previous errors")
+		} else {
+			probeCheck.Detail, probeCheck.Err = probeCluster(ctx, conf, clusterType, clusterTlsConfig, contactPoints)
+		}
+		checks = append(checks, probeCheck)
+	}
+	return checks
+}
+
+func validateProxyTls(conf *config.Config) *ValidationCheck {
+	check := &ValidationCheck{Name: "proxy TLS"}
+	proxyTlsConfig, err := conf.ParseProxyTlsConfig(false)
+	if err != nil {
+		check.Err = err
+		return check
+	}
+	if !proxyTlsConfig.TlsEnabled {
+		check.Detail = "disabled"
+		return check
+	}
+	_, check.Err = getServerSideTlsConfigFromProxyClusterTlsConfig(proxyTlsConfig)
+	check.Detail = fmt.Sprintf("client certificate required: %v", proxyTlsConfig.ClientAuth)
+	return check
+}
+
+func validateClusterTls(conf *config.Config, clusterType common.ClusterType) (*ValidationCheck, *common.ClusterTlsConfig) {
+	check := &ValidationCheck{Name: fmt.Sprintf("%v TLS", clusterType)}
+	var clusterTlsConfig *common.ClusterTlsConfig
+	if clusterType == common.ClusterTypeTarget {
+		clusterTlsConfig, check.Err = conf.ParseTargetTlsConfig(false)
+	} else {
+		clusterTlsConfig, check.Err = conf.ParseOriginTlsConfig(false)
+	}
+	if check.Err != nil {
+		return check, nil
+	}
+
+	switch {
+	case !clusterTlsConfig.TlsEnabled:
+		check.Detail = "disabled"
+	case clusterTlsConfig.SecureConnectBundlePath != "":
+		var fileMap map[string][]byte
+		fileMap, check.Err = extractFilesFromZipArchive(clusterTlsConfig.SecureConnectBundlePath)
+		if check.Err != nil {
+			break
+		}
+		var metadataServiceHostName, metadataServicePort string
+		metadataServiceHostName, metadataServicePort, check.Err = parseHostAndPortFromSCBConfig(fileMap["config.json"])
+		if check.Err != nil {
+			break
+		}
+		_, check.Err = initializeTlsConfigurationFromSecureConnectBundle(fileMap, metadataServiceHostName, clusterType)
+		check.Detail = fmt.Sprintf("secure connect bundle, metadata service %v:%v",
+			metadataServiceHostName, metadataServicePort)
+	default:
+		_, check.Err = getClientSideTlsConfigFromProxyClusterTlsConfig(clusterTlsConfig, clusterType)
+		check.Detail = "enabled"
+	}
+	return check, clusterTlsConfig
+}
+
+func validateContactPoints(ctx context.Context, conf *config.Config, clusterType common.ClusterType,
+	clusterTlsConfig *common.ClusterTlsConfig) (*ValidationCheck, []string) {
+	check := &ValidationCheck{Name: fmt.Sprintf("%v contact points", clusterType)}
+	var contactPoints []string
+	if clusterType == common.ClusterTypeTarget {
+		contactPoints, check.Err = conf.ParseTargetContactPoints()
+	} else {
+		contactPoints, check.Err = conf.ParseOriginContactPoints()
+	}
+	if check.Err != nil {
+		return check, nil
+	}
+	if clusterTlsConfig != nil && clusterTlsConfig.SecureConnectBundlePath != "" {
+		check.Detail = "provided by the secure connect bundle"
+		return check, nil
+	}
+
+	var resolved, unresolved []string
+	for _, contactPoint := range contactPoints {
+		// the port was validated when the contact points were parsed
+		host, _, _ := config.SplitContactPoint(contactPoint, 0)
+		addresses, err := net.DefaultResolver.LookupHost(ctx, host)
+		if err != nil {
+			unresolved = append(unresolved, fmt.Sprintf("%v (%v)", contactPoint, err))
+			continue
+		}
+		resolved = append(resolved, fmt.Sprintf("%v -> %v", contactPoint, strings.Join(addresses, ", ")))
+	}
+	if len(unresolved) > 0 {
+		check.Err = fmt.Errorf("could not resolve %v", strings.Join(unresolved, "; "))
+	}
+	check.Detail = strings.Join(resolved, "; ")
+	return check, contactPoints
+}
+
+func probeCluster(ctx context.Context, conf *config.Config, clusterType common.ClusterType,
+	clusterTlsConfig *common.ClusterTlsConfig, contactPoints []string) (string, error) {
+	topologyConfig, err := conf.ParseTopologyConfig()
+	if err != nil {
+		return "", err
+	}
+
+	port, connectionTimeoutMs, datacenter := conf.OriginPort, conf.OriginConnectionTimeoutMs, conf.OriginLocalDatacenter
+	username, password, err := conf.ParseOriginCredentials()
+	if clusterType == common.ClusterTypeTarget {
+		port, connectionTimeoutMs, datacenter = conf.TargetPort, conf.TargetConnectionTimeoutMs, conf.TargetLocalDatacenter
+		username, password, err = conf.ParseTargetCredentials()
+	}
+	if err != nil {
+		return "", err
+	}
+	peerPortMapping, err := conf.ParseOriginPeerPortMapping()
+	if clusterType == common.ClusterTypeTarget {
+		peerPortMapping, err = conf.ParseTargetPeerPortMapping()
+	}
+	if err != nil {
+		return "", err
+	}
+
+	connConfig, err := InitializeConnectionConfig(
+		clusterTlsConfig, contactPoints, port, peerPortMapping, connectionTimeoutMs, clusterType, datacenter, ctx, nil)
+	if err != nil {
+		return "", err
+	}
+
+	// the control connection only updates the gauges of the open control connections
+	metricFactory := noopmetrics.NewNoopMetricFactory()
+	openOriginControlConnections, err := metricFactory.GetOrCreateGauge(metrics.OpenOriginControlConnections)
+	if err != nil {
+		return "", err
+	}
+	openTargetControlConnections, err := metricFactory.GetOrCreateGauge(metrics.OpenTargetControlConnections)
+	if err != nil {
+		return "", err
+	}
+	metricHandler := metrics.NewMetricHandler(metricFactory, nil, nil, nil, &metrics.ProxyMetrics{
+		OpenOriginControlConnections: openOriginControlConnections,
+		OpenTargetControlConnections: openTargetControlConnections,
+	}, nil, nil, nil)
+
+	controlConn := NewControlConn(
+		ctx, port, connConfig, username, password, conf, topologyConfig, NewThreadSafeRand(), metricHandler)
+	_, err = controlConn.Open(true, ctx)
+	if err != nil {
+		return "", err
+	}
+	defer controlConn.Close()
+
+	hosts, err := controlConn.GetHostsInLocalDatacenter()
+	if err != nil {
+		return "", err
+	}
+	authEnabled, err := controlConn.IsAuthEnabled()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("cluster %v, datacenter %v, %d hosts, authentication enabled: %v",
+		controlConn.GetClusterName(), controlConn.datacenter, len(hosts), authEnabled), nil
+}
@@ -0,0 +1,73 @@
+package zdmproxy
+
+import (
+	"context"
+	"github.com/datastax/zdm-proxy/proxy/pkg/config"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateDeployment(t *testing.T) {
+	missingFile := filepath.Join(t.TempDir(), "missing.crt")
+	tests := []struct {
+		name           string
+		modifyConf     func(conf *config.Config)
+		expectedErrors map[string]string
+	}{
+		{
+			name:           "valid",
+			modifyConf:     func(conf *config.Config) {},
+			expectedErrors: map[string]string{},
+		},
+		{
+			name: "missing origin CA file",
+			modifyConf: func(conf *config.Config) {
+				conf.OriginTlsServerCaPath = missingFile
+			},
+			expectedErrors: map[string]string{"ORIGIN TLS": "missing.crt"},
+		},
+		{
+			name: "missing proxy TLS files",
+			modifyConf: func(conf *config.Config) {
+				conf.ProxyTlsCaPath = missingFile
+				conf.ProxyTlsCertPath = missingFile
+				conf.ProxyTlsKeyPath = missingFile
+			},
+			expectedErrors: map[string]string{"proxy TLS": "missing.crt"},
+		},
+		{
+			name: "unresolvable target contact point",
+			modifyConf: func(conf *config.Config) {
+				conf.TargetContactPoints = "127.0.0.1, nonexistent.invalid"
+			},
+			expectedErrors: map[string]string{"TARGET contact points": "could not resolve nonexistent.invalid"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := config.New()
+			conf.OriginContactPoints = "127.0.0.1"
+			conf.OriginPort = 9042
+			conf.TargetContactPoints = "localhost"
+			conf.TargetPort = 9042
+			conf.ProxyTopologyNumTokens = 8
+			tt.modifyConf(conf)
+
+			checks := ValidateDeployment(context.Background(), conf, false)
+			var names []string
+			for _, check := range checks {
+				names = append(names, check.Name)
+				if expectedErr, ok := tt.expectedErrors[check.Name]; ok {
+					require.NotNil(t, check.Err, check.Name)
+					require.Contains(t, check.Err.Error(), expectedErr)
+				} else {
+					require.Nil(t, check.Err, check.Name)
+				}
+			}
+			require.Equal(t, []string{"proxy TLS", "ORIGIN TLS", "ORIGIN contact points", "TARGET TLS",
+				"TARGET contact points"}, names)
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package zdmproxy
+
+import (
+	"context"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// viewIndexTracker finds the tables that have a materialized view or a secondary index on only one cluster, which is
+// enabled by ZDM_VIEW_INDEX_CHECK_ENABLED. The writes to such a table succeed on both clusters but the view or the
+// index of the other cluster is never populated, so the reads that use it silently return different results after
+// the cutover.
+//
+// The views and the indexes of both clusters are read again every ZDM_VIEW_INDEX_CHECK_REFRESH_INTERVAL_MS, a failed
+// refresh keeps the previous state. The writes that are forwarded to both clusters are counted by the
+// asymmetric_table_writes_total metric when they modify such a table and the first write to each table since the
+// last refresh is logged as a warning.
+type viewIndexTracker struct {
+	originQueryFn    func(cql string, ctx context.Context) (*ParsedRowSet, error)
+	targetQueryFn    func(cql string, ctx context.Context) (*ParsedRowSet, error)
+	asymmetricTables *atomic.Value // map[string]*tableAsymmetry, the key is keyspace.table
+	warnedTables     *sync.Map
+	proxyMetrics     *metrics.ProxyMetrics
+
+	cancelFn context.CancelFunc
+	wg       *sync.WaitGroup
+}
+
+// tableAsymmetry holds the views and the indexes of a table that only exist on one cluster, the views are keyspace
+// qualified.
+type tableAsymmetry struct {
+	originOnly []string
+	targetOnly []string
+}
+
+func (recv *tableAsymmetry) String() string {
+	var parts []string
+	if len(recv.originOnly) > 0 {
+		parts = append(parts,
+			fmt.Sprintf("only on %v: %v", common.ClusterTypeOrigin, strings.Join(recv.originOnly, ", ")))
+	}
+	if len(recv.targetOnly) > 0 {
+		parts = append(parts,
+			fmt.Sprintf("only on %v: %v", common.ClusterTypeTarget, strings.Join(recv.targetOnly, ", ")))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func newViewIndexTracker(
+	originQueryFn func(cql string, ctx context.Context) (*ParsedRowSet, error),
+	targetQueryFn func(cql string, ctx context.Context) (*ParsedRowSet, error),
+	proxyMetrics *metrics.ProxyMetrics) *viewIndexTracker {
+	t := &viewIndexTracker{
+		originQueryFn:    originQueryFn,
+		targetQueryFn:    targetQueryFn,
+		asymmetricTables: &atomic.Value{},
+		warnedTables:     &sync.Map{},
+		proxyMetrics:     proxyMetrics,
+		cancelFn:         func() {},
+		wg:               &sync.WaitGroup{},
+	}
+	t.asymmetricTables.Store(map[string]*tableAsymmetry{})
+	return t
+}
+
+func (t *viewIndexTracker) Start(interval time.Duration) {
+	ctx, cancelFn := context.WithCancel(context.Background())
+	t.cancelFn = cancelFn
+	t.wg.Add(1)
+	go func() {
+		defer t.wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := t.refresh(ctx)
+				if err != nil && ctx.Err() == nil {
+					log.Warnf("Could not refresh the materialized views and secondary indexes, the previous ones "+
+						"will be used until the next successful refresh: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+func (t *viewIndexTracker) Close() {
+	t.cancelFn()
+	t.wg.Wait()
+}
+
+// refresh reads the views and the indexes of both clusters and logs the tables whose asymmetry changed.
+func (t *viewIndexTracker) refresh(ctx context.Context) error {
+	originObjects, err := loadViewsAndIndexes(t.originQueryFn, ctx)
+	if err != nil {
+		return fmt.Errorf("could not read the views and indexes of %v: %w", common.ClusterTypeOrigin, err)
+	}
+	targetObjects, err := loadViewsAndIndexes(t.targetQueryFn, ctx)
+	if err != nil {
+		return fmt.Errorf("could not read the views and indexes of %v: %w", common.ClusterTypeTarget, err)
+	}
+	asymmetricTables := compareViewsAndIndexes(originObjects, targetObjects)
+
+	previous := t.asymmetricTables.Load().(map[string]*tableAsymmetry)
+	for _, table := range sortedKeys(asymmetricTables) {
+		if previous[table] == nil || previous[table].String() != asymmetricTables[table].String() {
+			log.Warnf("Table %v has materialized views or secondary indexes that don't exist on both clusters (%v), "+
+				"they won't be populated by the writes of the other cluster.", table, asymmetricTables[table])
+		}
+	}
+	for _, table := range sortedKeys(previous) {
+		if asymmetricTables[table] == nil {
+			log.Infof("Table %v now has the same materialized views and secondary indexes on both clusters.", table)
+		}
+	}
+	t.asymmetricTables.Store(asymmetricTables)
+	t.warnedTables.Range(func(key, _ interface{}) bool {
+		t.warnedTables.Delete(key)
+		return true
+	})
+	t.proxyMetrics.AsymmetricTables.Set(len(asymmetricTables))
+	return nil
+}
+
+// loadViewsAndIndexes returns the materialized views (keyspace qualified) and the secondary indexes of each table, the
+// key is keyspace.table. The views are optional since some services don't support them.
+func loadViewsAndIndexes(
+	queryFn func(cql string, ctx context.Context) (*ParsedRowSet, error), ctx context.Context) (map[string][]string, error) {
+	objects := map[string][]string{}
+	viewRows, err := queryFn("SELECT keyspace_name, view_name, base_table_name FROM system_schema.views", ctx)
+	if err != nil {
+		log.Debugf("Could not read system_schema.views, only the secondary indexes are compared: %v", err)
+	} else {
+		for _, row := range viewRows.Rows {
+			keyspace, _ := getStringColumn(row, "keyspace_name")
+			view, _ := getStringColumn(row, "view_name")
+			table, ok := getStringColumn(row, "base_table_name")
+			if ok && !isSystemKeyspaceName(keyspace) {
+				objects[schemaKey(keyspace, table)] = append(
+					objects[schemaKey(keyspace, table)], fmt.Sprintf("view %v", schemaKey(keyspace, view)))
+			}
+		}
+	}
+
+	indexRows, err := queryFn("SELECT keyspace_name, table_name, index_name FROM system_schema.indexes", ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not read system_schema.indexes: %w", err)
+	}
+	for _, row := range indexRows.Rows {
+		keyspace, _ := getStringColumn(row, "keyspace_name")
+		table, _ := getStringColumn(row, "table_name")
+		index, ok := getStringColumn(row, "index_name")
+		if ok && !isSystemKeyspaceName(keyspace) {
+			objects[schemaKey(keyspace, table)] = append(objects[schemaKey(keyspace, table)], fmt.Sprintf("index %v", index))
+		}
+	}
+	return objects, nil
+}
+
+func compareViewsAndIndexes(originObjects map[string][]string, targetObjects map[string][]string) map[string]*tableAsymmetry {
+	onlyIn := func(objects []string, other []string) []string {
+		otherSet := make(map[string]bool, len(other))
+		for _, object := range other {
+			otherSet[object] = true
+		}
+		var missing []string
+		for _, object := range objects {
+			if !otherSet[object] {
+				missing = append(missing, object)
+			}
+		}
+		sort.Strings(missing)
+		return missing
+	}
+
+	asymmetricTables := map[string]*tableAsymmetry{}
+	tables := map[string]bool{}
+	for table := range originObjects {
+		tables[table] = true
+	}
+	for table := range targetObjects {
+		tables[table] = true
+	}
+	for table := range tables {
+		asymmetry := &tableAsymmetry{
+			originOnly: onlyIn(originObjects[table], targetObjects[table]),
+			targetOnly: onlyIn(targetObjects[table], originObjects[table]),
+		}
+		if len(asymmetry.originOnly) > 0 || len(asymmetry.targetOnly) > 0 {
+			asymmetricTables[table] = asymmetry
+		}
+	}
+	return asymmetricTables
+}
+
+// track counts the writes forwarded to both clusters that modify a table with an asymmetric view or index, it
+// doesn't change how the request is forwarded.
+func (t *viewIndexTracker) track(
+	requestInfo RequestInfo, frameContext *frameDecodeContext, currentKeyspace string,
+	timeUuidGenerator TimeUuidGenerator) {
+	if t == nil || requestInfo.GetForwardDecision() != forwardToBoth || !requestInfo.ShouldBeTrackedInMetrics() {
+		return
+	}
+	asymmetricTables := t.asymmetricTables.Load().(map[string]*tableAsymmetry)
+	if len(asymmetricTables) == 0 {
+		return
+	}
+
+	var tables []string
+	switch castedRequestInfo := requestInfo.(type) {
+	case *GenericRequestInfo:
+		if frameContext.GetRawFrame().Header.OpCode != primitive.OpCodeQuery {
+			return
+		}
+		stmtQueryData, err := frameContext.GetOrInspectStatement(currentKeyspace, timeUuidGenerator)
+		if err != nil || !isWriteStatement(stmtQueryData.queryData) {
+			return
+		}
+		tables = append(tables, getQueryTable(stmtQueryData.queryData))
+	case *ExecuteRequestInfo:
+		tables = append(tables, getPreparedWriteTable(castedRequestInfo.GetPreparedData(), currentKeyspace, timeUuidGenerator))
+	case *BatchRequestInfo:
+		for _, preparedData := range castedRequestInfo.GetPreparedDataByStmtIdx() {
+			tables = append(tables, getPreparedWriteTable(preparedData, currentKeyspace, timeUuidGenerator))
+		}
+		stmtsQueryData, err := frameContext.GetOrInspectAllStatements(currentKeyspace, timeUuidGenerator)
+		if err == nil {
+			for _, stmtQueryData := range stmtsQueryData {
+				tables = append(tables, getQueryTable(stmtQueryData.queryData))
+			}
+		}
+	default:
+		return
+	}
+
+	counted := false
+	for _, table := range tables {
+		asymmetry, ok := asymmetricTables[table]
+		if !ok {
+			continue
+		}
+		if !counted {
+			t.proxyMetrics.AsymmetricTableWrites.Add(1)
+			counted = true
+		}
+		if _, warned := t.warnedTables.LoadOrStore(table, true); !warned {
+			log.Warnf("Forwarding a write to table %v whose materialized views or secondary indexes differ between "+
+				"the clusters (%v).", table, asymmetry)
+		}
+	}
+}
+
+func getQueryTable(queryInfo QueryInfo) string {
+	return schemaKey(queryInfo.getApplicableKeyspace(), queryInfo.getTableName())
+}
+
+// getPreparedWriteTable returns the table of a prepared write, from the bound variables if possible so that the query
+// isn't parsed on every execution. It returns an empty string if the prepared statement is not a write.
+func getPreparedWriteTable(preparedData PreparedData, currentKeyspace string, timeUuidGenerator TimeUuidGenerator) string {
+	variablesMetadata := preparedData.GetOriginVariablesMetadata()
+	if variablesMetadata != nil && len(variablesMetadata.Columns) > 0 {
+		column := variablesMetadata.Columns[0]
+		return schemaKey(column.Keyspace, column.Table)
+	}
+	queryInfo := inspectPreparedQuery(preparedData, currentKeyspace, timeUuidGenerator)
+	if !isWriteStatement(queryInfo) {
+		return ""
+	}
+	return getQueryTable(queryInfo)
+}
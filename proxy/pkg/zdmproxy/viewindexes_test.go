@@ -0,0 +1,111 @@
+package zdmproxy
+
+import (
+	"context"
+	"errors"
+	"github.com/datastax/go-cassandra-native-protocol/datatype"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"testing"
+)
+
+func newViewIndexQueryFn(views [][]interface{}, indexes [][]interface{}) func(string, context.Context) (*ParsedRowSet, error) {
+	return func(cql string, _ context.Context) (*ParsedRowSet, error) {
+		switch {
+		case strings.HasSuffix(cql, "FROM system_schema.views"):
+			if views == nil {
+				return nil, errors.New("unconfigured table views")
+			}
+			return newSchemaRowSet([]string{"keyspace_name", "view_name", "base_table_name"}, views...), nil
+		case strings.HasSuffix(cql, "FROM system_schema.indexes"):
+			return newSchemaRowSet([]string{"keyspace_name", "table_name", "index_name"}, indexes...), nil
+		default:
+			return nil, errors.New("unexpected query: " + cql)
+		}
+	}
+}
+
+func TestViewIndexTracker_Refresh(t *testing.T) {
+	originQueryFn := newViewIndexQueryFn(
+		[][]interface{}{{"ks", "tbl_by_v", "tbl"}, {"ks", "other_by_v", "other"}, {"system_auth", "v", "roles"}},
+		[][]interface{}{{"ks", "tbl", "tbl_v_idx"}, {"ks", "same", "same_idx"}})
+	targetQueryFn := newViewIndexQueryFn(
+		[][]interface{}{{"ks", "other_by_v", "other"}},
+		[][]interface{}{{"ks", "same", "same_idx"}, {"ks", "tbl", "tbl_w_idx"}})
+	proxyMetrics := newFakeProxyMetrics()
+	asymmetricTables := &countingGauge{}
+	proxyMetrics.AsymmetricTables = asymmetricTables
+	tracker := newViewIndexTracker(originQueryFn, targetQueryFn, proxyMetrics)
+
+	require.Nil(t, tracker.refresh(context.Background()))
+	require.Equal(t, map[string]*tableAsymmetry{
+		"ks.tbl": {originOnly: []string{"index tbl_v_idx", "view ks.tbl_by_v"}, targetOnly: []string{"index tbl_w_idx"}},
+	}, tracker.asymmetricTables.Load())
+	require.Equal(t, 1, asymmetricTables.value)
+	require.Equal(t, "only on ORIGIN: index tbl_v_idx, view ks.tbl_by_v; only on TARGET: index tbl_w_idx",
+		tracker.asymmetricTables.Load().(map[string]*tableAsymmetry)["ks.tbl"].String())
+
+	// the views are optional
+	tracker.targetQueryFn = newViewIndexQueryFn(nil, [][]interface{}{{"ks", "same", "same_idx"}})
+	require.Nil(t, tracker.refresh(context.Background()))
+	require.Equal(t, map[string]*tableAsymmetry{
+		"ks.tbl":   {originOnly: []string{"index tbl_v_idx", "view ks.tbl_by_v"}},
+		"ks.other": {originOnly: []string{"view ks.other_by_v"}},
+	}, tracker.asymmetricTables.Load())
+	require.Equal(t, 2, asymmetricTables.value)
+
+	// a failed refresh keeps the previous state
+	tracker.targetQueryFn = func(string, context.Context) (*ParsedRowSet, error) {
+		return nil, errors.New("unavailable")
+	}
+	require.ErrorContains(t, tracker.refresh(context.Background()),
+		"could not read the views and indexes of TARGET: could not read system_schema.indexes: unavailable")
+	require.Len(t, tracker.asymmetricTables.Load(), 2)
+}
+
+func TestViewIndexTracker_Track(t *testing.T) {
+	proxyMetrics := newFakeProxyMetrics()
+	writes := &countingGauge{}
+	proxyMetrics.AsymmetricTableWrites = writes
+	tracker := newViewIndexTracker(nil, nil, proxyMetrics)
+	tracker.asymmetricTables.Store(map[string]*tableAsymmetry{
+		"ks.tbl": {originOnly: []string{"view ks.tbl_by_v"}},
+	})
+
+	trackQuery := func(query string, decision forwardDecision) {
+		frameContext := NewFrameDecodeContext(mockQueryFrame(t, query))
+		tracker.track(NewGenericRequestInfo(decision, true, true), frameContext, "ks", nil)
+	}
+	trackExecute := func(query string, columns []*message.ColumnMetadata) {
+		preparedData := NewPreparedData(
+			&message.PreparedResult{PreparedQueryId: []byte("origin"), VariablesMetadata: &message.VariablesMetadata{Columns: columns}},
+			&message.PreparedResult{PreparedQueryId: []byte("target")},
+			NewPrepareRequestInfo(NewGenericRequestInfo(forwardToBoth, false, true), nil, false, query, ""))
+		frameContext := NewFrameDecodeContext(mockExecuteFrame(t, "origin"))
+		tracker.track(NewExecuteRequestInfo(preparedData), frameContext, "ks", nil)
+	}
+
+	trackQuery("INSERT INTO tbl (pk) VALUES (1)", forwardToBoth)
+	trackQuery("DELETE FROM ks.tbl WHERE pk = 1", forwardToBoth)
+	require.Equal(t, 2, writes.value)
+	trackExecute("UPDATE tbl SET v = ? WHERE pk = ?",
+		[]*message.ColumnMetadata{{Keyspace: "ks", Table: "tbl", Name: "v", Type: datatype.Int}})
+	trackExecute("DELETE FROM ks.tbl WHERE pk = 1", nil)
+	require.Equal(t, 4, writes.value)
+	batchFrameContext := NewFrameDecodeContext(mockBatch(t, "INSERT INTO ks.tbl (pk) VALUES (1)"))
+	tracker.track(NewBatchRequestInfo(map[int]PreparedData{}), batchFrameContext, "ks", nil)
+	require.Equal(t, 5, writes.value)
+
+	// other tables, reads and requests that are not forwarded to both clusters are not tracked
+	trackQuery("INSERT INTO other (pk) VALUES (1)", forwardToBoth)
+	trackQuery("INSERT INTO ks2.tbl (pk) VALUES (1)", forwardToBoth)
+	trackQuery("USE ks", forwardToBoth)
+	trackQuery("SELECT * FROM tbl", forwardToOrigin)
+	trackExecute("SELECT * FROM tbl WHERE pk = 1", nil)
+	require.Equal(t, 5, writes.value)
+
+	var disabled *viewIndexTracker
+	disabled.track(NewGenericRequestInfo(forwardToBoth, true, true),
+		NewFrameDecodeContext(mockQueryFrame(t, "INSERT INTO tbl (pk) VALUES (1)")), "ks", nil)
+}
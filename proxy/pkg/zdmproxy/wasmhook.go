@@ -0,0 +1,280 @@
+package zdmproxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	log "github.com/sirupsen/logrus"
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"os"
+	"time"
+)
+
+const (
+	wasmHookAllocFunctionName     = "zdm_alloc"
+	wasmHookTransformFunctionName = "zdm_transform"
+
+	wasmHookCallTimeout      = 100 * time.Millisecond
+	wasmHookMemoryLimitPages = 256 // 16 MiB
+	wasmHookMaxIdleInstances = 64
+)
+
+// wasmStatementInfo is the JSON document that the WASM module receives for every QUERY and PREPARE request.
+type wasmStatementInfo struct {
+	OpCode          string `json:"opcode"`
+	Query           string `json:"query"`
+	StatementType   string `json:"statementType"`
+	Keyspace        string `json:"keyspace"`
+	Table           string `json:"table"`
+	CurrentKeyspace string `json:"currentKeyspace"`
+}
+
+// wasmStatementTransformation is the JSON document that the WASM module returns. Empty fields mean "no change".
+type wasmStatementTransformation struct {
+	Query string `json:"query"`
+	Route string `json:"route"`
+	Error string `json:"error"`
+}
+
+// wasmQueryHook runs a user provided WebAssembly module that can rewrite the query string of QUERY and PREPARE
+// requests, override their forward decision or reject them.
+//
+// The module must export its memory and two functions:
+//   - zdm_alloc(size i32) i32 returns a buffer of the provided size where the proxy writes the statement info
+//   - zdm_transform(ptr i32, len i32) i64 receives the statement info (JSON) and returns the pointer (high 32 bits)
+//     and length (low 32 bits) of the transformation (JSON), 0 means that the request is not modified
+//
+// The module only has access to the WASI functions that don't touch the host (no filesystem or network) and its
+// memory is limited. Each call runs on an instance that isn't used concurrently by other requests, instances are
+// reused so the module is responsible for freeing (or reusing) the buffers that it allocates.
+type wasmQueryHook struct {
+	runtime       wazero.Runtime
+	compiled      wazero.CompiledModule
+	idleInstances chan api.Module
+}
+
+func newWasmQueryHook(ctx context.Context, path string) (*wasmQueryHook, error) {
+	wasmBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read WASM hook module %v: %w", path, err)
+	}
+
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+		WithCloseOnContextDone(true).
+		WithMemoryLimitPages(wasmHookMemoryLimitPages))
+	hook := &wasmQueryHook{
+		runtime:       runtime,
+		idleInstances: make(chan api.Module, wasmHookMaxIdleInstances),
+	}
+
+	_, err = wasi_snapshot_preview1.Instantiate(ctx, runtime)
+	if err != nil {
+		hook.Close()
+		return nil, fmt.Errorf("could not instantiate WASI for the WASM hook module: %w", err)
+	}
+
+	hook.compiled, err = runtime.CompileModule(ctx, wasmBytes)
+	if err != nil {
+		hook.Close()
+		return nil, fmt.Errorf("could not compile WASM hook module %v: %w", path, err)
+	}
+
+	exportedFunctions := hook.compiled.ExportedFunctions()
+	for _, name := range []string{wasmHookAllocFunctionName, wasmHookTransformFunctionName} {
+		if _, ok := exportedFunctions[name]; !ok {
+			hook.Close()
+			return nil, fmt.Errorf("WASM hook module %v does not export function %v", path, name)
+		}
+	}
+	if len(hook.compiled.ExportedMemories()) == 0 {
+		hook.Close()
+		return nil, fmt.Errorf("WASM hook module %v does not export its memory", path)
+	}
+
+	// instantiate one module right away so that errors in the module initialization are reported on startup
+	instance, err := hook.newInstance(ctx)
+	if err != nil {
+		hook.Close()
+		return nil, err
+	}
+	hook.releaseInstance(ctx, instance)
+
+	log.Infof("Loaded WASM hook module %v.", path)
+	return hook, nil
+}
+
+func (recv *wasmQueryHook) Close() {
+	err := recv.runtime.Close(context.Background())
+	if err != nil {
+		log.Warnf("Error closing WASM hook runtime: %v", err)
+	}
+}
+
+func (recv *wasmQueryHook) newInstance(ctx context.Context) (api.Module, error) {
+	instance, err := recv.runtime.InstantiateModule(ctx, recv.compiled, wazero.NewModuleConfig().
+		WithName("").
+		WithStartFunctions("_initialize"))
+	if err != nil {
+		return nil, fmt.Errorf("could not instantiate WASM hook module: %w", err)
+	}
+	return instance, nil
+}
+
+func (recv *wasmQueryHook) acquireInstance(ctx context.Context) (api.Module, error) {
+	select {
+	case instance := <-recv.idleInstances:
+		return instance, nil
+	default:
+		return recv.newInstance(ctx)
+	}
+}
+
+func (recv *wasmQueryHook) releaseInstance(ctx context.Context, instance api.Module) {
+	if instance.IsClosed() {
+		return
+	}
+	select {
+	case recv.idleInstances <- instance:
+	default:
+		_ = instance.Close(ctx)
+	}
+}
+
+func (recv *wasmQueryHook) transform(info *wasmStatementInfo) (*wasmStatementTransformation, error) {
+	input, err := json.Marshal(info)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode statement info: %w", err)
+	}
+
+	ctx, cancelFn := context.WithTimeout(context.Background(), wasmHookCallTimeout)
+	defer cancelFn()
+
+	instance, err := recv.acquireInstance(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// an instance is closed by the runtime if the call times out so it won't be reused
+	defer recv.releaseInstance(context.Background(), instance)
+
+	results, err := instance.ExportedFunction(wasmHookAllocFunctionName).Call(ctx, uint64(len(input)))
+	if err != nil {
+		return nil, fmt.Errorf("%v failed: %w", wasmHookAllocFunctionName, err)
+	}
+	ptr := uint32(results[0])
+	if !instance.Memory().Write(ptr, input) {
+		return nil, fmt.Errorf("%v returned an out of range buffer (ptr=%v, len=%v)",
+			wasmHookAllocFunctionName, ptr, len(input))
+	}
+
+	results, err = instance.ExportedFunction(wasmHookTransformFunctionName).Call(ctx, uint64(ptr), uint64(len(input)))
+	if err != nil {
+		return nil, fmt.Errorf("%v failed: %w", wasmHookTransformFunctionName, err)
+	}
+	if results[0] == 0 {
+		return nil, nil
+	}
+
+	outputPtr, outputLen := uint32(results[0]>>32), uint32(results[0])
+	output, ok := instance.Memory().Read(outputPtr, outputLen)
+	if !ok {
+		return nil, fmt.Errorf("%v returned an out of range buffer (ptr=%v, len=%v)",
+			wasmHookTransformFunctionName, outputPtr, outputLen)
+	}
+	transformation := &wasmStatementTransformation{}
+	err = json.Unmarshal(output, transformation)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode the result of %v: %w", wasmHookTransformFunctionName, err)
+	}
+	return transformation, nil
+}
+
+// transformRequest calls the WASM module for QUERY and PREPARE requests and returns the (possibly rewritten) request
+// and the forward decision that the module chose, which is empty if the module didn't override it.
+func (recv *wasmQueryHook) transformRequest(
+	currentKeyspace string, context *frameDecodeContext, timeUuidGenerator TimeUuidGenerator) (*frameDecodeContext, forwardDecision, error) {
+	opCode := context.GetRawFrame().Header.OpCode
+	if opCode != primitive.OpCodeQuery && opCode != primitive.OpCodePrepare {
+		return context, "", nil
+	}
+
+	stmtQueryData, err := context.GetOrInspectStatement(currentKeyspace, timeUuidGenerator)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not inspect %v request: %w", opCode, err)
+	}
+	queryInfo := stmtQueryData.queryData
+	transformation, err := recv.transform(&wasmStatementInfo{
+		OpCode:          opCode.String(),
+		Query:           queryInfo.getQuery(),
+		StatementType:   string(queryInfo.getStatementType()),
+		Keyspace:        queryInfo.getApplicableKeyspace(),
+		Table:           queryInfo.getTableName(),
+		CurrentKeyspace: currentKeyspace,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if transformation == nil {
+		return context, "", nil
+	}
+	if transformation.Error != "" {
+		return nil, "", errors.New(transformation.Error)
+	}
+
+	var decision forwardDecision
+	switch transformation.Route {
+	case "":
+	case string(forwardToOrigin), string(forwardToTarget), string(forwardToBoth):
+		decision = forwardDecision(transformation.Route)
+	default:
+		return nil, "", fmt.Errorf("%v returned an invalid route: %v", wasmHookTransformFunctionName, transformation.Route)
+	}
+
+	if transformation.Query == "" || transformation.Query == queryInfo.getQuery() {
+		return context, decision, nil
+	}
+
+	decodedFrame, err := context.GetOrDecodeFrame()
+	if err != nil {
+		return nil, "", fmt.Errorf("could not decode %v request: %w", opCode, err)
+	}
+	newFrame := decodedFrame.Clone()
+	switch msg := newFrame.Body.Message.(type) {
+	case *message.Query:
+		msg.Query = transformation.Query
+	case *message.Prepare:
+		msg.Query = transformation.Query
+	default:
+		return nil, "", fmt.Errorf("unexpected message type for %v request: %T", opCode, msg)
+	}
+	newRawFrame, err := defaultCodec.ConvertToRawFrame(newFrame)
+	if err != nil {
+		return nil, "", fmt.Errorf("could not convert transformed frame to raw frame: %w", err)
+	}
+	log.Debugf("WASM hook rewrote query of request with stream id %v from '%v' to '%v'",
+		newRawFrame.Header.StreamId, redactedQuery(queryInfo.getQuery()), redactedQuery(transformation.Query))
+	return NewInitializedFrameDecodeContext(newRawFrame, newFrame, nil), decision, nil
+}
+
+// overrideForwardDecision applies the forward decision chosen by the WASM module, requests that are handled by the
+// proxy itself (e.g. system.local when virtualization is enabled) keep their forward decision.
+func overrideForwardDecision(requestInfo RequestInfo, decision forwardDecision) RequestInfo {
+	switch castedRequestInfo := requestInfo.(type) {
+	case *GenericRequestInfo:
+		return NewGenericRequestInfo(decision, false, castedRequestInfo.ShouldBeTrackedInMetrics())
+	case *PrepareRequestInfo:
+		if castedRequestInfo.GetBaseRequestInfo().GetForwardDecision() == forwardToNone {
+			return requestInfo
+		}
+		return NewPrepareRequestInfo(
+			NewGenericRequestInfo(decision, false, castedRequestInfo.GetBaseRequestInfo().ShouldBeTrackedInMetrics()),
+			castedRequestInfo.GetReplacedTerms(), castedRequestInfo.ContainsPositionalMarkers(),
+			castedRequestInfo.GetQuery(), castedRequestInfo.GetKeyspace())
+	default:
+		return requestInfo
+	}
+}
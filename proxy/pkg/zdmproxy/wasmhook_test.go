@@ -0,0 +1,166 @@
+package zdmproxy
+
+import (
+	"context"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWasmQueryHook_TransformRequest(t *testing.T) {
+	query := &message.Query{Query: "SELECT * FROM ks.tb"}
+	prepare := &message.Prepare{Query: "SELECT * FROM ks.tb"}
+
+	tests := []struct {
+		name             string
+		output           string
+		request          message.Message
+		expectedQuery    string
+		expectedDecision forwardDecision
+		expectedErr      string
+	}{
+		{"no transformation", "", query, "SELECT * FROM ks.tb", "", ""},
+		{"rewrite query", `{"query":"SELECT * FROM ks.tb2"}`, query, "SELECT * FROM ks.tb2", "", ""},
+		{"rewrite prepare", `{"query":"SELECT * FROM ks.tb2"}`, prepare, "SELECT * FROM ks.tb2", "", ""},
+		{"route", `{"route":"target"}`, query, "SELECT * FROM ks.tb", forwardToTarget, ""},
+		{"rewrite and route", `{"query":"SELECT * FROM ks.tb2","route":"both"}`, query,
+			"SELECT * FROM ks.tb2", forwardToBoth, ""},
+		{"reject", `{"error":"ks.tb is read only"}`, query, "", "", "ks.tb is read only"},
+		{"invalid route", `{"route":"none"}`, query, "", "", "zdm_transform returned an invalid route: none"},
+		{"invalid output", `{"query":`, query, "", "", "could not decode the result of zdm_transform"},
+		{"ignored opcode", `{"query":"SELECT * FROM ks.tb2"}`, &message.Options{}, "", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook, err := newWasmQueryHook(context.Background(), writeTestWasmHookModule(t, buildTestWasmHookModule(tt.output)))
+			require.Nil(t, err)
+			defer hook.Close()
+
+			rawFrame, err := defaultCodec.ConvertToRawFrame(frame.NewFrame(primitive.ProtocolVersion4, 1, tt.request))
+			require.Nil(t, err)
+			frameContext := NewFrameDecodeContext(rawFrame)
+			newFrameContext, decision, err := hook.transformRequest("", frameContext, nil)
+			if tt.expectedErr != "" {
+				require.NotNil(t, err)
+				require.Contains(t, err.Error(), tt.expectedErr)
+				return
+			}
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedDecision, decision)
+
+			decodedFrame, err := newFrameContext.GetOrDecodeFrame()
+			require.Nil(t, err)
+			require.Equal(t, int16(1), decodedFrame.Header.StreamId)
+			switch msg := decodedFrame.Body.Message.(type) {
+			case *message.Query:
+				require.Equal(t, tt.expectedQuery, msg.Query)
+			case *message.Prepare:
+				require.Equal(t, tt.expectedQuery, msg.Query)
+			default:
+				require.Same(t, frameContext, newFrameContext)
+			}
+		})
+	}
+}
+
+func TestNewWasmQueryHook_InvalidModule(t *testing.T) {
+	_, err := newWasmQueryHook(context.Background(), filepath.Join(t.TempDir(), "missing.wasm"))
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "could not read WASM hook module")
+
+	_, err = newWasmQueryHook(context.Background(), writeTestWasmHookModule(t, []byte("not wasm")))
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "could not compile WASM hook module")
+
+	emptyModule := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	_, err = newWasmQueryHook(context.Background(), writeTestWasmHookModule(t, emptyModule))
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "does not export function zdm_alloc")
+}
+
+func TestOverrideForwardDecision(t *testing.T) {
+	genericRequestInfo := overrideForwardDecision(NewGenericRequestInfo(forwardToBoth, false, true), forwardToTarget)
+	require.Equal(t, NewGenericRequestInfo(forwardToTarget, false, true), genericRequestInfo)
+
+	prepareRequestInfo := overrideForwardDecision(NewPrepareRequestInfo(
+		NewGenericRequestInfo(forwardToOrigin, true, true), nil, false, "SELECT * FROM ks.tb", "ks"), forwardToTarget)
+	require.Equal(t, forwardToBoth, prepareRequestInfo.GetForwardDecision())
+	require.Equal(t, forwardToTarget, prepareRequestInfo.(*PrepareRequestInfo).GetBaseRequestInfo().GetForwardDecision())
+
+	interceptedRequestInfo := NewPrepareRequestInfo(NewInterceptedRequestInfo(local, nil), nil, false, "SELECT * FROM system.local", "")
+	require.Same(t, interceptedRequestInfo, overrideForwardDecision(interceptedRequestInfo, forwardToTarget))
+}
+
+func writeTestWasmHookModule(t *testing.T, module []byte) string {
+	path := filepath.Join(t.TempDir(), "hook.wasm")
+	require.Nil(t, os.WriteFile(path, module, 0644))
+	return path
+}
+
+// buildTestWasmHookModule returns a WASM module whose zdm_transform function ignores the statement info and always
+// returns the provided output (or 0 if output is empty).
+func buildTestWasmHookModule(output string) []byte {
+	const outputOffset = 16
+	var transformResult int64
+	if output != "" {
+		transformResult = outputOffset<<32 | int64(len(output))
+	}
+
+	section := func(id byte, content ...[]byte) []byte {
+		var body []byte
+		for _, c := range content {
+			body = append(body, c...)
+		}
+		return append(append([]byte{id}, wasmUleb128(uint64(len(body)))...), body...)
+	}
+	name := func(s string) []byte {
+		return append(wasmUleb128(uint64(len(s))), s...)
+	}
+	allocBody := []byte{0x00, 0x41, 0x80, 0x08, 0x0b} // no locals, i32.const 1024, end
+	transformBody := append(append([]byte{0x00, 0x42}, wasmSleb128(transformResult)...), 0x0b)
+
+	module := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	module = append(module, section(0x01, // types: (i32) -> i32 and (i32, i32) -> i64
+		[]byte{0x02, 0x60, 0x01, 0x7f, 0x01, 0x7f, 0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7e})...)
+	module = append(module, section(0x03, []byte{0x02, 0x00, 0x01})...)
+	module = append(module, section(0x05, []byte{0x01, 0x00, 0x01})...)
+	module = append(module, section(0x07, []byte{0x03},
+		name("memory"), []byte{0x02, 0x00},
+		name(wasmHookAllocFunctionName), []byte{0x00, 0x00},
+		name(wasmHookTransformFunctionName), []byte{0x00, 0x01})...)
+	module = append(module, section(0x0a, []byte{0x02},
+		wasmUleb128(uint64(len(allocBody))), allocBody,
+		wasmUleb128(uint64(len(transformBody))), transformBody)...)
+	module = append(module, section(0x0b, []byte{0x01, 0x00, 0x41}, wasmSleb128(outputOffset), []byte{0x0b},
+		name(output))...)
+	return module
+}
+
+func wasmUleb128(v uint64) []byte {
+	var result []byte
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 {
+			return append(result, b)
+		}
+		result = append(result, b|0x80)
+	}
+}
+
+func wasmSleb128(v int64) []byte {
+	var result []byte
+	for {
+		b := byte(v & 0x7f)
+		v >>= 7
+		if (v == 0 && b&0x40 == 0) || (v == -1 && b&0x40 != 0) {
+			return append(result, b)
+		}
+		result = append(result, b|0x80)
+	}
+}
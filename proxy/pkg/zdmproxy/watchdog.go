@@ -0,0 +1,79 @@
+package zdmproxy
+
+import (
+	log "github.com/sirupsen/logrus"
+	"sync/atomic"
+	"time"
+)
+
+// runWatchdog periodically checks whether the connection stopped receiving responses while requests are waiting for
+// one. A connection in this state is closed so that it can be established again: for the ORIGIN and TARGET connectors
+// this closes the client connection (drivers reconnect right away) while an ASYNC connector is just shut down.
+func (cc *ClusterConnector) runWatchdog(timeout time.Duration) {
+	cc.clientHandlerWg.Add(1)
+	cc.goroutines.start(func() {
+		defer cc.clientHandlerWg.Done()
+		ticker := time.NewTicker(timeout / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-cc.clusterConnContext.Done():
+				return
+			case now := <-ticker.C:
+				if cc.isStuck(now, timeout) {
+					cc.closeStuckConnection(now)
+					return
+				}
+			}
+		}
+	})
+}
+
+func (cc *ClusterConnector) isStuck(now time.Time, timeout time.Duration) bool {
+	if atomic.LoadInt32(&cc.pendingResponses) <= 0 {
+		return false
+	}
+	return now.Sub(cc.lastProgressTime.Load().(time.Time)) > timeout
+}
+
+func (cc *ClusterConnector) closeStuckConnection(now time.Time) {
+	log.Warnf("[%s] Connection to %v (%v) did not receive any frame for %v while %d requests were waiting "+
+		"for a response (%d frames in the write queue), closing it.",
+		cc.connectorType, cc.clusterType, cc.connection.RemoteAddr(),
+		now.Sub(cc.lastProgressTime.Load().(time.Time)).Truncate(time.Millisecond),
+		atomic.LoadInt32(&cc.pendingResponses), len(cc.writeCoalescer.writeQueue))
+
+	nodeMetricsInstance, err := GetNodeMetricsByClusterConnector(cc.nodeMetrics, cc.connectorType)
+	if err != nil {
+		log.Errorf("Failed to track stuck connection metrics for conn %v: %v.", cc.connection.RemoteAddr(), err)
+	} else {
+		nodeMetricsInstance.StuckConnections.Add(1)
+	}
+	cc.markConnectionLost()
+	cc.Shutdown()
+}
+
+// trackPendingResponse should be called when a request is sent to the cluster, the watchdog measures the time
+// without progress from the moment the connection starts waiting for responses.
+func (cc *ClusterConnector) trackPendingResponse() {
+	if atomic.AddInt32(&cc.pendingResponses, 1) == 1 {
+		cc.lastProgressTime.Store(time.Now())
+	}
+}
+
+// trackReceivedFrame should be called for every frame that is read from the connection.
+func (cc *ClusterConnector) trackReceivedFrame(isResponse bool) {
+	cc.lastProgressTime.Store(time.Now())
+	if isResponse {
+		cc.releasePendingResponse()
+	}
+}
+
+func (cc *ClusterConnector) releasePendingResponse() {
+	for {
+		pending := atomic.LoadInt32(&cc.pendingResponses)
+		if pending <= 0 || atomic.CompareAndSwapInt32(&cc.pendingResponses, pending, pending-1) {
+			return
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package zdmproxy
+
+import (
+	"github.com/stretchr/testify/require"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClusterConnector_IsStuck(t *testing.T) {
+	cc := &ClusterConnector{lastProgressTime: &atomic.Value{}}
+	cc.lastProgressTime.Store(time.Now().Add(-time.Minute))
+	timeout := time.Second
+
+	// no requests waiting for a response
+	require.False(t, cc.isStuck(time.Now(), timeout))
+
+	cc.trackPendingResponse()
+	require.False(t, cc.isStuck(time.Now(), timeout))
+	require.True(t, cc.isStuck(time.Now().Add(2*timeout), timeout))
+
+	// an event is progress but doesn't complete a request
+	cc.trackPendingResponse()
+	cc.trackReceivedFrame(false)
+	require.Equal(t, int32(2), atomic.LoadInt32(&cc.pendingResponses))
+	require.False(t, cc.isStuck(time.Now(), timeout))
+
+	cc.trackReceivedFrame(true)
+	cc.trackReceivedFrame(true)
+	require.Equal(t, int32(0), atomic.LoadInt32(&cc.pendingResponses))
+	require.False(t, cc.isStuck(time.Now().Add(2*timeout), timeout))
+
+	// responses of requests that were not tracked (e.g. sent before the handshake finished)
+	cc.trackReceivedFrame(true)
+	cc.releasePendingResponse()
+	require.Equal(t, int32(0), atomic.LoadInt32(&cc.pendingResponses))
+}
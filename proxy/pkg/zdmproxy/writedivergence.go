@@ -0,0 +1,57 @@
+package zdmproxy
+
+import (
+	"fmt"
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+)
+
+// getSecondaryWriteFailureWarning returns the warning that describes a write that was applied on the primary cluster
+// but failed on the secondary cluster. It returns an empty string if both responses have the same outcome or if the
+// failure happened on the primary cluster.
+func getSecondaryWriteFailureWarning(
+	primaryCluster common.ClusterType, originResponse *frame.RawFrame, targetResponse *frame.RawFrame) (string, error) {
+	primaryResponse, secondaryResponse := originResponse, targetResponse
+	secondaryCluster := common.ClusterTypeTarget
+	if primaryCluster == common.ClusterTypeTarget {
+		primaryResponse, secondaryResponse = targetResponse, originResponse
+		secondaryCluster = common.ClusterTypeOrigin
+	}
+
+	if !isResponseSuccessful(primaryResponse) || isResponseSuccessful(secondaryResponse) {
+		return "", nil
+	}
+
+	decodedResponse, err := defaultCodec.ConvertFromRawFrame(secondaryResponse)
+	if err != nil {
+		return "", fmt.Errorf("could not decode %v response to describe the write failure: %w", secondaryCluster, err)
+	}
+	errorMessage := fmt.Sprintf("%v", decodedResponse.Body.Message)
+	if errMsg, ok := decodedResponse.Body.Message.(message.Error); ok {
+		errorMessage = errMsg.GetErrorMessage()
+	}
+	return fmt.Sprintf("Write was applied on %v (primary cluster) but failed on %v: %v. "+
+		"The data of both clusters may have diverged.", primaryCluster, secondaryCluster, errorMessage), nil
+}
+
+// addResponseWarnings appends the provided warnings to the client response, v3 responses are returned unchanged since
+// the warnings flag was only introduced in v4.
+func addResponseWarnings(response *frame.RawFrame, warnings ...string) (*frame.RawFrame, error) {
+	if len(warnings) == 0 || response == nil || response.Header.Version < primitive.ProtocolVersion4 {
+		return response, nil
+	}
+
+	decodedFrame, err := defaultCodec.ConvertFromRawFrame(response)
+	if err != nil {
+		return nil, fmt.Errorf("could not decode response to add warnings: %w", err)
+	}
+	decodedFrame.SetWarnings(append(decodedFrame.Body.Warnings, warnings...))
+
+	newResponse, err := defaultCodec.ConvertToRawFrame(decodedFrame)
+	if err != nil {
+		return nil, fmt.Errorf("could not encode response with warnings: %w", err)
+	}
+	return newResponse, nil
+}
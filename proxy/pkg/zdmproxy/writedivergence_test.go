@@ -0,0 +1,101 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/frame"
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/common"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestGetSecondaryWriteFailureWarning(t *testing.T) {
+	success := mustEncodeResponse(t, primitive.ProtocolVersion4, &message.VoidResult{})
+	failure := mustEncodeResponse(t, primitive.ProtocolVersion4, &message.WriteTimeout{
+		ErrorMessage: "Operation timed out",
+		Consistency:  primitive.ConsistencyLevelQuorum,
+		Received:     1,
+		BlockFor:     2,
+		WriteType:    primitive.WriteTypeSimple,
+	})
+
+	tests := []struct {
+		name            string
+		primaryCluster  common.ClusterType
+		originResponse  *frame.RawFrame
+		targetResponse  *frame.RawFrame
+		expectedWarning string
+	}{
+		{
+			name:           "failure on target",
+			primaryCluster: common.ClusterTypeOrigin,
+			originResponse: success,
+			targetResponse: failure,
+			expectedWarning: "Write was applied on ORIGIN (primary cluster) but failed on TARGET: Operation timed out. " +
+				"The data of both clusters may have diverged.",
+		},
+		{
+			name:           "failure on origin",
+			primaryCluster: common.ClusterTypeTarget,
+			originResponse: failure,
+			targetResponse: success,
+			expectedWarning: "Write was applied on TARGET (primary cluster) but failed on ORIGIN: Operation timed out. " +
+				"The data of both clusters may have diverged.",
+		},
+		{
+			name:            "failure on primary",
+			primaryCluster:  common.ClusterTypeOrigin,
+			originResponse:  failure,
+			targetResponse:  success,
+			expectedWarning: "",
+		},
+		{
+			name:            "failure on both",
+			primaryCluster:  common.ClusterTypeOrigin,
+			originResponse:  failure,
+			targetResponse:  failure,
+			expectedWarning: "",
+		},
+		{
+			name:            "success on both",
+			primaryCluster:  common.ClusterTypeOrigin,
+			originResponse:  success,
+			targetResponse:  success,
+			expectedWarning: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warning, err := getSecondaryWriteFailureWarning(tt.primaryCluster, tt.originResponse, tt.targetResponse)
+			require.Nil(t, err)
+			require.Equal(t, tt.expectedWarning, warning)
+		})
+	}
+}
+
+func TestAddResponseWarnings(t *testing.T) {
+	errorFrame := frame.NewFrame(primitive.ProtocolVersion4, 1, &message.Overloaded{ErrorMessage: "overloaded"})
+	errorFrame.SetWarnings([]string{"existing warning"})
+	rawResponse, err := defaultCodec.ConvertToRawFrame(errorFrame)
+	require.Nil(t, err)
+
+	newRawResponse, err := addResponseWarnings(rawResponse, "new warning")
+	require.Nil(t, err)
+	newResponse, err := defaultCodec.ConvertFromRawFrame(newRawResponse)
+	require.Nil(t, err)
+	require.Equal(t, int16(1), newResponse.Header.StreamId)
+	require.Equal(t, &message.Overloaded{ErrorMessage: "overloaded"}, newResponse.Body.Message)
+	require.Equal(t, []string{"existing warning", "new warning"}, newResponse.Body.Warnings)
+
+	v3Response := mustEncodeResponse(t, primitive.ProtocolVersion3, &message.VoidResult{})
+	newRawResponse, err = addResponseWarnings(v3Response, "new warning")
+	require.Nil(t, err)
+	require.Same(t, v3Response, newRawResponse)
+}
+
+func mustEncodeResponse(t *testing.T, version primitive.ProtocolVersion, msg message.Message) *frame.RawFrame {
+	rawFrame, err := defaultCodec.ConvertToRawFrame(frame.NewFrame(version, 1, msg))
+	require.Nil(t, err)
+	return rawFrame
+}
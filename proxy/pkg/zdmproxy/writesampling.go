@@ -0,0 +1,76 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/primitive"
+	"github.com/datastax/zdm-proxy/proxy/pkg/metrics"
+)
+
+// targetWriteSampler sends only a percentage of the writes to Target while every write is still sent to Origin, which
+// is set by ZDM_TARGET_WRITE_SAMPLING_PERCENT. It is meant to warm up Target and to validate its capacity and schema
+// with a part of the production writes before the full dual writes are enabled.
+//
+// Each write is sampled independently. A write that is not sampled is only sent to Origin and its response is
+// returned to the client whichever cluster is primary, it's accounted in the metrics like the other requests that are
+// only sent to Origin. Requests that must reach both clusters for the proxy to work (USE, PREPARE, etc.) are not
+// sampled.
+type targetWriteSampler struct {
+	percent      float64
+	randFn       func() float64 // returns a number in [0, 1)
+	proxyMetrics *metrics.ProxyMetrics
+}
+
+func newTargetWriteSampler(percent float64, proxyMetrics *metrics.ProxyMetrics) *targetWriteSampler {
+	return &targetWriteSampler{
+		percent:      percent,
+		randFn:       NewThreadSafeRand().Float64,
+		proxyMetrics: proxyMetrics,
+	}
+}
+
+// route returns the request info of a write that is not sampled with Origin as forward decision, other requests are
+// returned as is.
+func (s *targetWriteSampler) route(
+	requestInfo RequestInfo, frameContext *frameDecodeContext, currentKeyspace string,
+	timeUuidGenerator TimeUuidGenerator) RequestInfo {
+	if s == nil || requestInfo.GetForwardDecision() != forwardToBoth || !requestInfo.ShouldBeTrackedInMetrics() {
+		return requestInfo
+	}
+	// the random number is drawn first so that the sampled writes are not inspected
+	if s.randFn()*100 < s.percent {
+		return requestInfo
+	}
+
+	var sampledOut RequestInfo
+	switch castedRequestInfo := requestInfo.(type) {
+	case *GenericRequestInfo:
+		if frameContext.GetRawFrame().Header.OpCode != primitive.OpCodeQuery {
+			return requestInfo
+		}
+		stmtQueryData, err := frameContext.GetOrInspectStatement(currentKeyspace, timeUuidGenerator)
+		if err != nil || !isWriteStatement(stmtQueryData.queryData) {
+			return requestInfo
+		}
+		sampledOut = NewGenericRequestInfo(forwardToOrigin, false, castedRequestInfo.ShouldBeTrackedInMetrics())
+	case *ExecuteRequestInfo:
+		queryInfo := inspectPreparedQuery(castedRequestInfo.GetPreparedData(), currentKeyspace, timeUuidGenerator)
+		if !isWriteStatement(queryInfo) {
+			return requestInfo
+		}
+		sampledOut = NewRoutedExecuteRequestInfo(castedRequestInfo.GetPreparedData(), forwardToOrigin)
+	case *BatchRequestInfo:
+		sampledOut = NewRoutedBatchRequestInfo(castedRequestInfo.GetPreparedDataByStmtIdx(), forwardToOrigin)
+	default:
+		return requestInfo
+	}
+	s.proxyMetrics.TargetWriteSamplingSkippedWrites.Add(1)
+	return sampledOut
+}
+
+func isWriteStatement(queryInfo QueryInfo) bool {
+	switch queryInfo.getStatementType() {
+	case statementTypeInsert, statementTypeUpdate, statementTypeDelete, statementTypeBatch:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,70 @@
+package zdmproxy
+
+import (
+	"github.com/datastax/go-cassandra-native-protocol/message"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestTargetWriteSampler_Route(t *testing.T) {
+	random := 0.0
+	sampler := newTargetWriteSampler(25, newFakeProxyMetrics())
+	sampler.randFn = func() float64 {
+		return random
+	}
+	routeQuery := func(query string, decision forwardDecision) RequestInfo {
+		frameContext := NewFrameDecodeContext(mockQueryFrame(t, query))
+		return sampler.route(NewGenericRequestInfo(decision, false, true), frameContext, "ks", nil)
+	}
+	routeExecute := func(query string) RequestInfo {
+		preparedData := NewPreparedData(
+			&message.PreparedResult{PreparedQueryId: []byte("origin")},
+			&message.PreparedResult{PreparedQueryId: []byte("target")},
+			NewPrepareRequestInfo(NewGenericRequestInfo(forwardToBoth, false, true), nil, false, query, ""))
+		frameContext := NewFrameDecodeContext(mockExecuteFrame(t, "origin"))
+		return sampler.route(NewExecuteRequestInfo(preparedData), frameContext, "ks", nil)
+	}
+	routeBatch := func() RequestInfo {
+		frameContext := NewFrameDecodeContext(mockBatch(t, "INSERT INTO ks.tbl (pk) VALUES (1)"))
+		return sampler.route(NewBatchRequestInfo(map[int]PreparedData{}), frameContext, "ks", nil)
+	}
+
+	// sampled
+	random = 0.2
+	require.Equal(t, forwardToBoth, routeQuery("INSERT INTO tbl (pk) VALUES (1)", forwardToBoth).GetForwardDecision())
+	require.Equal(t, forwardToBoth, routeExecute("UPDATE tbl SET v = ? WHERE pk = ?").GetForwardDecision())
+	require.Equal(t, forwardToBoth, routeBatch().GetForwardDecision())
+
+	// not sampled
+	random = 0.25
+	require.Equal(t, forwardToOrigin, routeQuery("INSERT INTO tbl (pk) VALUES (1)", forwardToBoth).GetForwardDecision())
+	require.Equal(t, forwardToOrigin, routeQuery("DELETE FROM ks.tbl WHERE pk = 1", forwardToBoth).GetForwardDecision())
+	routed := routeExecute("UPDATE tbl SET v = ? WHERE pk = ?")
+	require.Equal(t, forwardToOrigin, routed.GetForwardDecision())
+	require.False(t, routed.ShouldAlsoBeSentAsync())
+	require.Equal(t, forwardToOrigin, routeBatch().GetForwardDecision())
+
+	// requests that must be sent to both clusters and reads are not affected
+	require.Equal(t, forwardToBoth, routeQuery("USE ks", forwardToBoth).GetForwardDecision())
+	require.Equal(t, forwardToBoth, routeQuery("CREATE TABLE tbl (pk int PRIMARY KEY)", forwardToBoth).GetForwardDecision())
+	require.Equal(t, forwardToBoth, routeExecute("SELECT * FROM tbl WHERE pk = ?").GetForwardDecision())
+	require.Equal(t, forwardToTarget, routeQuery("SELECT * FROM tbl", forwardToTarget).GetForwardDecision())
+	prepareFrameContext := NewFrameDecodeContext(mockPrepareFrame(t, "INSERT INTO tbl (pk) VALUES (?)"))
+	prepareRequestInfo := NewPrepareRequestInfo(
+		NewGenericRequestInfo(forwardToBoth, false, true), nil, false, "INSERT INTO tbl (pk) VALUES (?)", "")
+	require.Equal(t, prepareRequestInfo, sampler.route(prepareRequestInfo, prepareFrameContext, "ks", nil))
+
+	var disabled *targetWriteSampler
+	requestInfo := NewGenericRequestInfo(forwardToBoth, false, true)
+	require.Equal(t, requestInfo, disabled.route(
+		requestInfo, NewFrameDecodeContext(mockQueryFrame(t, "INSERT INTO tbl (pk) VALUES (1)")), "ks", nil))
+}
+
+func TestTargetWriteSampler_NoWrites(t *testing.T) {
+	sampler := newTargetWriteSampler(0, newFakeProxyMetrics())
+	for i := 0; i < 100; i++ {
+		frameContext := NewFrameDecodeContext(mockQueryFrame(t, "INSERT INTO tbl (pk) VALUES (1)"))
+		requestInfo := sampler.route(NewGenericRequestInfo(forwardToBoth, false, true), frameContext, "ks", nil)
+		require.Equal(t, forwardToOrigin, requestInfo.GetForwardDecision())
+	}
+}